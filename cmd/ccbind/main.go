@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// ccbind generates the Go bindings of a chaincode from its schema, for go:generate:
+//
+//	//go:generate go run github.com/hyperledger-labs/fabric-smart-client/cmd/ccbind --schema echo.yaml --client client.go
+package main
+
+import (
+	"os"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema/cmd"
+)
+
+func main() {
+	// On failure Cobra prints the usage message and error string, so we only
+	// need to exit with a non-0 status
+	if cmd.NewCmd().Execute() != nil {
+		os.Exit(1)
+	}
+}
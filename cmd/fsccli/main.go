@@ -15,6 +15,9 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/cmd/artifactgen"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/cmd/cryptogen"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/cmd/hsm"
+	ccbind "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema/cmd"
+	evidence "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/evidence/cmd"
+	importer "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/importer/cmd"
 	view "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/client/view/cmd"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -45,6 +48,9 @@ func main() {
 	mainCmd.AddCommand(cryptogen.NewCmd())
 	mainCmd.AddCommand(view.NewCmd())
 	mainCmd.AddCommand(hsm.NewCmd())
+	mainCmd.AddCommand(evidence.NewCmd())
+	mainCmd.AddCommand(importer.NewCmd())
+	mainCmd.AddCommand(ccbind.NewCmd())
 	mainCmd.AddCommand(version.Cmd())
 
 	// On failure Cobra prints the usage message and error string, so we only
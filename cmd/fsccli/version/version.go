@@ -8,8 +8,8 @@ package version
 
 import (
 	"fmt"
-	"runtime"
 
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 	"github.com/spf13/cobra"
 )
 
@@ -38,8 +38,5 @@ var cobraCommand = &cobra.Command{
 
 // GetInfo returns version information for the peer
 func GetInfo() string {
-	return fmt.Sprintf("%s:\n Go version: %s\n"+
-		" OS/Arch: %s\n",
-		ProgramName, runtime.Version(),
-		fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH))
+	return fmt.Sprintf("%s:\n%s", ProgramName, version.Get())
 }
@@ -8,6 +8,7 @@ package chaincode_test
 
 import (
 	"encoding/base64"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -16,6 +17,8 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/integration/fabric/atsa/chaincode"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/fabric/atsa/chaincode/views"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/state"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-protos-go/peer"
 )
 
 var _ = Describe("EndToEnd", func() {
@@ -44,6 +47,8 @@ var _ = Describe("EndToEnd", func() {
 
 			alice = chaincode.NewClient(ii.Client("alice"), ii.Identity("alice"))
 			bob = chaincode.NewClient(ii.Client("bob"), ii.Identity("bob"))
+			Expect(alice.Init()).ToNot(HaveOccurred())
+			Expect(bob.Init()).ToNot(HaveOccurred())
 		})
 
 		It("succeeded", func() {
@@ -71,6 +76,9 @@ var _ = Describe("EndToEnd", func() {
 			Expect(asset.ObjectType).To(BeEquivalentTo("asset"))
 			Expect(asset.PublicDescription).To(BeEquivalentTo("A new asset for Org1MSP"))
 			Expect(asset.OwnerOrg).To(BeEquivalentTo("Org1MSP"))
+			// the chaincode set the key-level endorsement policy of the asset, the local vaults reflect it
+			expectEndorsementPolicy(alice, ap.ID, "Org1MSP")
+			expectEndorsementPolicy(bob, ap.ID, "Org1MSP")
 
 			Expect(alice.ChangePublicDescription(ap.ID, "This asset is for sale")).ToNot(HaveOccurred())
 			asset, err = alice.ReadAsset(ap.ID)
@@ -133,6 +141,9 @@ var _ = Describe("EndToEnd", func() {
 			Expect(asset.ObjectType).To(BeEquivalentTo("asset"))
 			Expect(asset.PublicDescription).To(BeEquivalentTo("This asset is for sale"))
 			Expect(asset.OwnerOrg).To(BeEquivalentTo("Org2MSP"))
+			// the transfer changed the key-level endorsement policy of the asset
+			expectEndorsementPolicy(alice, ap.ID, "Org2MSP")
+			expectEndorsementPolicy(bob, ap.ID, "Org2MSP")
 
 			ap2, err = bob.ReadAssetPrivateProperties(ap.ID)
 			Expect(err).ToNot(HaveOccurred())
@@ -147,3 +158,19 @@ var _ = Describe("EndToEnd", func() {
 		})
 	})
 })
+
+// expectEndorsementPolicy checks that the local vault of the passed client stores, as key-level endorsement policy
+// of the passed asset, the one the chaincode sets for the passed owner
+func expectEndorsementPolicy(c *chaincode.Client, id string, owner string) {
+	ep, err := statebased.NewStateEP(nil)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.AddOrgs(statebased.RoleTypePeer, owner)).ToNot(HaveOccurred())
+	policy, err := ep.Policy()
+	Expect(err).ToNot(HaveOccurred())
+
+	Eventually(func() []byte {
+		metadata, err := c.StateMetadata(id)
+		Expect(err).ToNot(HaveOccurred())
+		return metadata[peer.MetaDataKeys_VALIDATION_PARAMETER.String()]
+	}, 30*time.Second, time.Second).Should(Equal(policy))
+}
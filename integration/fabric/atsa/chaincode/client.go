@@ -90,6 +90,25 @@ func (c *Client) Transfer(assetProperties *views.AssetProperties, assetPrice *vi
 	return err
 }
 
+// Init makes the vault of the node process the transactions of the asset_transfer chaincode
+func (c *Client) Init() error {
+	_, err := c.c.CallView("init", nil)
+	return err
+}
+
+// StateMetadata returns the metadata of the passed asset, as stored in the local vault
+func (c *Client) StateMetadata(id string) (map[string][]byte, error) {
+	result, err := c.c.CallView("StateMetadata", common.JSONMarshall(&views.StateMetadata{
+		ID: id,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string][]byte{}
+	common.JSONUnmarshal(result.([]byte), &metadata)
+	return metadata, nil
+}
+
 func (c *Client) Identity() view.Identity {
 	return c.id
 }
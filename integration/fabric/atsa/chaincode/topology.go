@@ -50,6 +50,8 @@ func Topology() []api.Topology {
 	alice.RegisterViewFactory("AgreeToSell", &views.AgreeToSellViewFactory{})
 	alice.RegisterViewFactory("AgreeToBuy", &views.AgreeToBuyViewFactory{})
 	alice.RegisterViewFactory("Transfer", &views.TransferViewFactory{})
+	alice.RegisterViewFactory("init", &views.InitViewFactory{})
+	alice.RegisterViewFactory("StateMetadata", &views.StateMetadataViewFactory{})
 
 	// Define Bob's FSC node
 	bob := fscTopology.AddNodeByName("bob")
@@ -63,6 +65,8 @@ func Topology() []api.Topology {
 	bob.RegisterViewFactory("AgreeToSell", &views.AgreeToSellViewFactory{})
 	bob.RegisterViewFactory("AgreeToBuy", &views.AgreeToBuyViewFactory{})
 	bob.RegisterViewFactory("Transfer", &views.TransferViewFactory{})
+	bob.RegisterViewFactory("init", &views.InitViewFactory{})
+	bob.RegisterViewFactory("StateMetadata", &views.StateMetadataViewFactory{})
 
 	// Add Fabric SDK to FSC Nodes
 	fscTopology.AddSDK(&fabric2.SDK{})
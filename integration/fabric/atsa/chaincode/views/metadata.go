@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package views
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/assert"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+// InitView makes the vault of the node process the transactions of the asset_transfer chaincode
+type InitView struct{}
+
+func (i *InitView) Call(context view.Context) (interface{}, error) {
+	assert.NoError(fabric.GetDefaultChannel(context).Committer().ProcessNamespace("asset_transfer"), "failed to setup namespace to process")
+	return nil, nil
+}
+
+type InitViewFactory struct{}
+
+func (i *InitViewFactory) NewView(in []byte) (view.View, error) {
+	return &InitView{}, nil
+}
+
+type StateMetadata struct {
+	ID string
+}
+
+// StateMetadataView returns the metadata of an asset, as stored in the local vault
+type StateMetadataView struct {
+	*StateMetadata
+}
+
+func (s *StateMetadataView) Call(context view.Context) (interface{}, error) {
+	qe, err := fabric.GetDefaultChannel(context).Vault().NewQueryExecutor()
+	assert.NoError(err, "failed getting query executor")
+	defer qe.Done()
+
+	metadata, _, _, err := qe.GetStateMetadata("asset_transfer", s.ID)
+	assert.NoError(err, "failed getting metadata of [%s]", s.ID)
+	return metadata, nil
+}
+
+type StateMetadataViewFactory struct{}
+
+func (s *StateMetadataViewFactory) NewView(in []byte) (view.View, error) {
+	f := &StateMetadataView{StateMetadata: &StateMetadata{}}
+	err := json.Unmarshal(in, f.StateMetadata)
+	assert.NoError(err, "failed unmarshalling input")
+	return f, nil
+}
@@ -8,13 +8,17 @@ package views
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/integration/fabric/fpc/echo/views/echocc"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/fpc"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/assert"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
 )
 
 // Echo models the parameters to be used to invoke the Echo FPC
@@ -106,6 +110,19 @@ func (e *EchoView) Call(context view.Context) (interface{}, error) {
 	assert.Equal(e.Function, string(res))
 	assert.NoError(fabric.GetDefaultFNS(context).Ordering().Broadcast(envelope))
 
+	// Invoke and query the `echo` chaincode with the client generated from its schema, the deployed chaincode
+	// implements version 1
+	client := echocc.NewClient().WithDeployedVersion(1)
+	echoed, _, err := client.Echo(context, strings.Join(e.Args, " "))
+	assert.NoError(err, "failed invoking echo with the typed client")
+	assert.Equal("echo", echoed)
+	pong, err := client.Ping(context)
+	assert.NoError(err, "failed querying ping with the typed client")
+	assert.Equal("ping", pong)
+	// the functions of the later versions fail before reaching the chaincode
+	_, _, err = client.EchoAll(context, e.Args)
+	assert.True(errors.Is(err, schema.ErrNotDeployed), "echoAll should not be deployed, got [%v]", err)
+
 	return res, nil
 }
 
@@ -0,0 +1,94 @@
+// Code generated by ccbind from echo.yaml. DO NOT EDIT.
+
+package echocc
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+// Schema is the schema of the chaincode echo, up to version 2
+var Schema = &schema.Schema{
+	Chaincode: "echo",
+	Version:   2,
+	Functions: []schema.Function{
+		{
+			Name:   "echo",
+			Method: "Echo",
+			Args: []schema.Arg{
+				{Name: "message", Type: "string"},
+			},
+			Returns: "string",
+			Since:   1,
+		},
+		{
+			Name:    "ping",
+			Method:  "Ping",
+			Query:   true,
+			Returns: "string",
+			Since:   1,
+		},
+		{
+			Name:   "echoAll",
+			Method: "EchoAll",
+			Args: []schema.Arg{
+				{Name: "messages", Type: "[]string"},
+			},
+			Returns: "string",
+			Since:   2,
+		},
+	},
+}
+
+// Client invokes the functions of the chaincode echo.
+// The schema version of the deployed chaincode is queried to the chaincode, unless set with WithDeployedVersion.
+type Client struct {
+	*chaincode.Typed
+}
+
+// NewClient returns a client of the chaincode echo
+func NewClient() *Client {
+	return &Client{Typed: chaincode.NewTyped(Schema)}
+}
+
+func (c *Client) WithNetwork(name string) *Client {
+	c.Typed.WithNetwork(name)
+	return c
+}
+
+func (c *Client) WithChannel(name string) *Client {
+	c.Typed.WithChannel(name)
+	return c
+}
+
+func (c *Client) WithSignerIdentity(id view.Identity) *Client {
+	c.Typed.WithSignerIdentity(id)
+	return c
+}
+
+func (c *Client) WithDeployedVersion(version int) *Client {
+	c.Typed.WithDeployedVersion(version)
+	return c
+}
+
+// Echo invokes the function echo. Returns echo.
+// Available from schema version 1 on.
+func (c *Client) Echo(context view.Context, message string) (res string, txID string, err error) {
+	txID, err = c.Invoke(context, "echo", &res, message)
+	return
+}
+
+// Ping queries the function ping. Returns ping.
+// Available from schema version 1 on.
+func (c *Client) Ping(context view.Context) (res string, err error) {
+	err = c.Query(context, "ping", &res)
+	return
+}
+
+// EchoAll invokes the function echoAll. Returns echoAll.
+// Available from schema version 2 on.
+func (c *Client) EchoAll(context view.Context, messages []string) (res string, txID string, err error) {
+	txID, err = c.Invoke(context, "echoAll", &res, messages)
+	return
+}
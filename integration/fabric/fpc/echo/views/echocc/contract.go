@@ -0,0 +1,68 @@
+// Code generated by ccbind from echo.yaml. DO NOT EDIT.
+
+package echocc
+
+import (
+	"strconv"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
+	"github.com/pkg/errors"
+)
+
+// ContractVersion is the schema version of the chaincode echo the Contract implements
+const ContractVersion = 2
+
+// Contract is implemented by the chaincode echo, with the functions of its schema version 2
+type Contract interface {
+	// Echo implements the function echo
+	Echo(message string) (string, error)
+	// Ping implements the function ping
+	Ping() (string, error)
+	// EchoAll implements the function echoAll
+	EchoAll(messages []string) (string, error)
+}
+
+// Dispatch decodes and validates the arguments of the invocation of the passed function, calls the method of the
+// contract implementing it, and encodes its result. It answers schema.VersionFunction with ContractVersion.
+func Dispatch(contract Contract, function string, args [][]byte) ([]byte, error) {
+	switch function {
+	case schema.VersionFunction:
+		return []byte(strconv.Itoa(ContractVersion)), nil
+	case "echo":
+		if len(args) != 1 {
+			return nil, errors.Errorf("function [%s] expects [1] arguments, got [%d]", function, len(args))
+		}
+		var a0 string
+		if err := schema.Decode(args[0], &a0); err != nil {
+			return nil, errors.WithMessagef(err, "invalid argument [message] of [%s]", function)
+		}
+		res, err := contract.Echo(a0)
+		if err != nil {
+			return nil, err
+		}
+		return schema.Encode(res)
+	case "ping":
+		if len(args) != 0 {
+			return nil, errors.Errorf("function [%s] expects [0] arguments, got [%d]", function, len(args))
+		}
+		res, err := contract.Ping()
+		if err != nil {
+			return nil, err
+		}
+		return schema.Encode(res)
+	case "echoAll":
+		if len(args) != 1 {
+			return nil, errors.Errorf("function [%s] expects [1] arguments, got [%d]", function, len(args))
+		}
+		var a0 []string
+		if err := schema.Decode(args[0], &a0); err != nil {
+			return nil, errors.WithMessagef(err, "invalid argument [messages] of [%s]", function)
+		}
+		res, err := contract.EchoAll(a0)
+		if err != nil {
+			return nil, err
+		}
+		return schema.Encode(res)
+	}
+	return nil, errors.Wrapf(schema.ErrUnknownFunction, "function [%s]", function)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package echocc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// echo implements the contract as the echo chaincode does
+type echo struct{}
+
+func (e *echo) Echo(message string) (string, error) { return "echo", nil }
+
+func (e *echo) Ping() (string, error) { return "ping", nil }
+
+func (e *echo) EchoAll(messages []string) (string, error) {
+	if len(messages) == 0 {
+		return "", errors.New("nothing to echo")
+	}
+	return strings.Join(messages, ","), nil
+}
+
+func TestDispatch(t *testing.T) {
+	res, err := Dispatch(&echo{}, schema.VersionFunction, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(res))
+
+	res, err = Dispatch(&echo{}, "echo", [][]byte{[]byte("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "echo", string(res))
+	res, err = Dispatch(&echo{}, "echoAll", [][]byte{[]byte(`["a","b"]`)})
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b", string(res))
+
+	_, err = Dispatch(&echo{}, "echo", nil)
+	assert.EqualError(t, err, "function [echo] expects [1] arguments, got [0]")
+	_, err = Dispatch(&echo{}, "echoAll", [][]byte{[]byte(`"a"`)})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid argument [messages] of [echoAll]")
+	_, err = Dispatch(&echo{}, "echoAll", [][]byte{[]byte(`[]`)})
+	assert.EqualError(t, err, "nothing to echo")
+	_, err = Dispatch(&echo{}, "shout", nil)
+	assert.True(t, errors.Is(err, schema.ErrUnknownFunction))
+}
+
+func TestClientFailsFast(t *testing.T) {
+	// the checks precede any use of the context
+	client := NewClient().WithDeployedVersion(1)
+	_, _, err := client.EchoAll(nil, []string{"a"})
+	assert.True(t, errors.Is(err, schema.ErrNotDeployed))
+	assert.EqualError(t, err, "function [echoAll] of chaincode [echo] requires schema version [2], deployed [1]: function not deployed")
+
+	_, err = client.Invoke(nil, "shout", nil)
+	assert.True(t, errors.Is(err, schema.ErrUnknownFunction))
+	_, err = client.Invoke(nil, "echo", nil)
+	assert.EqualError(t, err, "function [echo] of chaincode [echo] expects [1] arguments, got [0]")
+}
@@ -0,0 +1,11 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package echocc holds the bindings of the echo chaincode generated from its schema in echo.yaml:
+// the typed client the views invoke it with, and the stubs a Go implementation of it dispatches with.
+package echocc
+
+//go:generate go run github.com/hyperledger-labs/fabric-smart-client/cmd/ccbind --schema echo.yaml --client client.go --stubs contract.go
@@ -0,0 +1,72 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/multisig"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+// Params of a collection: the signers are the names of the nodes whose signature is collected
+type Params struct {
+	ID      string
+	Payload string
+	Signers []string
+	Timeout time.Duration
+}
+
+// Result of a collection: the names of the signers that signed, and of those that did not
+type Result struct {
+	Complete bool
+	Signed   []string
+	Failed   []string
+}
+
+type Initiator struct {
+	Params
+}
+
+func (p *Initiator) Call(context view.Context) (interface{}, error) {
+	// Resolve the identities of the signers
+	names := map[string]string{}
+	var signers []view.Identity
+	for _, name := range p.Signers {
+		signer := view2.GetIdentityProvider(context).Identity(name)
+		names[signer.UniqueID()] = name
+		signers = append(signers, signer)
+	}
+
+	// Collect the signatures, resuming the collection if it has been interrupted
+	payload := []byte(p.Payload)
+	v := multisig.NewCollectSignaturesView(p.ID, payload, signers...)
+	if p.Timeout != 0 {
+		v.WithTimeout(p.Timeout)
+	}
+	res, err := context.RunView(v)
+	if err != nil {
+		return nil, err
+	}
+	bundle := res.(*multisig.SignatureBundle)
+
+	// Check the signatures
+	result := &Result{Complete: bundle.Complete()}
+	for _, signature := range bundle.Signatures {
+		result.Signed = append(result.Signed, names[signature.Party.UniqueID()])
+	}
+	for _, failure := range bundle.Failures {
+		result.Failed = append(result.Failed, names[failure.Party.UniqueID()])
+	}
+	if result.Complete {
+		if err := bundle.VerifyAll(payload); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
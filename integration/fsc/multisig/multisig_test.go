@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/fsc/multisig"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
+)
+
+var _ = Describe("EndToEnd", func() {
+
+	Describe("Multi-Party Signatures", func() {
+		var (
+			ii *integration.Infrastructure
+		)
+
+		BeforeEach(func() {
+			var err error
+			// Create the integration ii
+			ii, err = integration.Generate(StartPort(), true, multisig.Topology()...)
+			Expect(err).NotTo(HaveOccurred())
+			// Start the integration ii
+			ii.Start()
+			time.Sleep(3 * time.Second)
+		})
+
+		AfterEach(func() {
+			// Stop the ii
+			ii.Stop()
+		})
+
+		collect := func(params *multisig.Params) *multisig.Result {
+			res, err := ii.Client("alice").CallView("collect", common.JSONMarshall(params))
+			Expect(err).NotTo(HaveOccurred())
+			result := &multisig.Result{}
+			common.JSONUnmarshal(res.([]byte), result)
+			return result
+		}
+
+		It("collects the signatures of the three signers", func() {
+			result := collect(&multisig.Params{ID: "c1", Payload: "transfer 10", Signers: []string{"bob", "charlie", "dave"}})
+			Expect(result.Complete).To(BeTrue())
+			Expect(result.Signed).To(ConsistOf("bob", "charlie", "dave"))
+			Expect(result.Failed).To(BeEmpty())
+		})
+
+		It("reports the signers refusing the payload", func() {
+			result := collect(&multisig.Params{ID: "c2", Payload: "forbidden transfer", Signers: []string{"bob", "charlie", "dave"}})
+			Expect(result.Complete).To(BeFalse())
+			Expect(result.Signed).To(BeEmpty())
+			Expect(result.Failed).To(ConsistOf("bob", "charlie", "dave"))
+		})
+
+		It("resumes the collection once a signer is back", func() {
+			ii.StopFSCNode("dave")
+			time.Sleep(3 * time.Second)
+			params := &multisig.Params{ID: "c3", Payload: "transfer 20", Signers: []string{"bob", "charlie", "dave"}, Timeout: 10 * time.Second}
+			result := collect(params)
+			Expect(result.Complete).To(BeFalse())
+			Expect(result.Signed).To(ConsistOf("bob", "charlie"))
+			Expect(result.Failed).To(ConsistOf("dave"))
+
+			// the initiator restarts, the signatures collected so far are kept
+			ii.StopFSCNode("alice")
+			ii.StartFSCNode("dave")
+			ii.StartFSCNode("alice")
+			time.Sleep(3 * time.Second)
+			result = collect(params)
+			Expect(result.Complete).To(BeTrue())
+			Expect(result.Signed).To(ConsistOf("bob", "charlie", "dave"))
+		})
+
+	})
+
+})
@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/multisig"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+type Responder struct{}
+
+func (p *Responder) Call(context view.Context) (interface{}, error) {
+	// Sign the payload received, unless it is forbidden
+	return context.RunView(multisig.NewSignView(func(context view.Context, request *multisig.Request) error {
+		if strings.Contains(string(request.Payload), "forbidden") {
+			return errors.Errorf("payload of collection [%s] is forbidden", request.ID)
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,25 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fsc"
+)
+
+// Topology has an initiator, alice, collecting the signatures of three signers: bob, charlie, and dave
+func Topology() []api.Topology {
+	// Create an empty FSC topology
+	topology := fsc.NewTopology()
+
+	topology.AddNodeByName("alice").RegisterViewFactory("collect", &InitiatorViewFactory{})
+
+	for _, signer := range []string{"bob", "charlie", "dave"} {
+		topology.AddNodeByName(signer).RegisterResponder(&Responder{}, &Initiator{})
+	}
+	return []api.Topology{topology}
+}
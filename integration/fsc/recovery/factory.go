@@ -0,0 +1,33 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+type InitiatorViewFactory struct{}
+
+func (i *InitiatorViewFactory) NewView(in []byte) (view.View, error) {
+	f := &Initiator{}
+	if err := json.Unmarshal(in, &f.Params); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type StatusViewFactory struct{}
+
+func (s *StatusViewFactory) NewView(in []byte) (view.View, error) {
+	f := &StatusView{}
+	if err := json.Unmarshal(in, &f.Params); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"encoding/json"
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Checkpointed is the status of a transfer whose first step is checkpointed by the initiator
+	Checkpointed = "checkpointed"
+	// Started is the status of a transfer whose first step is acknowledged by the responder
+	Started = "started"
+	// Completed is the status of a transfer whose second step is acknowledged by the responder
+	Completed = "completed"
+)
+
+// Params of a transfer
+type Params struct {
+	ID string
+}
+
+// State is the progress of a transfer, stored at each checkpoint
+type State struct {
+	Params
+	Step int
+}
+
+// Initiator runs a transfer with bob in two steps, and checkpoints after each of them.
+// The first run of a transfer stops after its first checkpoint, as if the node was killed there:
+// the transfer completes once resumed.
+type Initiator struct {
+	State
+	resumed bool
+}
+
+func (i *Initiator) Call(context view.Context) (interface{}, error) {
+	checkpointer, ok := context.(view.CheckpointContext)
+	if !ok {
+		return nil, errors.New("the context does not support checkpoints")
+	}
+	bob := view2.GetIdentityProvider(context).Identity("bob")
+	session, err := context.GetSession(context.Initiator(), bob)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.Step == 0 {
+		if err := session.Send([]byte(i.ID)); err != nil {
+			return nil, err
+		}
+		if err := expect(session, "ack1"); err != nil {
+			return nil, err
+		}
+		i.Step = 1
+		if err := checkpointer.Checkpoint(i.State); err != nil {
+			return nil, err
+		}
+		if err := setStatus(context, i.ID, Checkpointed); err != nil {
+			return nil, err
+		}
+	}
+	if !i.resumed {
+		// the flow is never terminated, its checkpoint is kept until the node is killed
+		select {}
+	}
+
+	if err := session.Send([]byte("commit")); err != nil {
+		return nil, err
+	}
+	if err := expect(session, "ack2"); err != nil {
+		return nil, err
+	}
+	i.Step = 2
+	if err := checkpointer.Checkpoint(i.State); err != nil {
+		return nil, err
+	}
+	return "OK", setStatus(context, i.ID, Completed)
+}
+
+func (i *Initiator) Resume(state []byte) (view.View, error) {
+	v := &Initiator{resumed: true}
+	if err := json.Unmarshal(state, &v.State); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// StatusView returns the status of a transfer on this node, empty if not known
+type StatusView struct {
+	Params
+}
+
+func (s *StatusView) Call(context view.Context) (interface{}, error) {
+	k, err := statusKey(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	kvss := kvs.GetService(context)
+	if !kvss.Exists(k) {
+		return "", nil
+	}
+	var status string
+	if err := kvss.Get(k, &status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func setStatus(context view.Context, id, status string) error {
+	k, err := statusKey(id)
+	if err != nil {
+		return err
+	}
+	return kvs.GetService(context).Put(k, status)
+}
+
+func statusKey(id string) (string, error) {
+	return kvs.CreateCompositeKey("recovery", []string{id})
+}
+
+func expect(session view.Session, payload string) error {
+	select {
+	case msg := <-session.Receive():
+		if string(msg.Payload) != payload {
+			return errors.Errorf("expected [%s], got [%s]", payload, msg.Payload)
+		}
+		return nil
+	case <-time.After(time.Minute):
+		return errors.Errorf("timeout waiting for [%s]", payload)
+	}
+}
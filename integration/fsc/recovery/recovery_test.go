@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/fsc/recovery"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
+)
+
+var _ = Describe("EndToEnd", func() {
+
+	Describe("Flow Recovery", func() {
+		var (
+			ii *integration.Infrastructure
+		)
+
+		BeforeEach(func() {
+			var err error
+			// Create the integration ii
+			ii, err = integration.Generate(StartPort(), true, recovery.Topology()...)
+			Expect(err).NotTo(HaveOccurred())
+			// Start the integration ii
+			ii.Start()
+			time.Sleep(3 * time.Second)
+		})
+
+		AfterEach(func() {
+			// Stop the ii
+			ii.Stop()
+		})
+
+		status := func(node, id string) func() string {
+			return func() string {
+				res, err := ii.Client(node).CallView("status", common.JSONMarshall(&recovery.Params{ID: id}))
+				Expect(err).NotTo(HaveOccurred())
+				return common.JSONUnmarshalString(res)
+			}
+		}
+
+		It("completes the flow of the initiator killed between two checkpoints", func() {
+			// the first run of the transfer never returns, the initiator is killed after its first checkpoint
+			go func() {
+				defer GinkgoRecover()
+				_, _ = ii.Client("alice").CallView("transfer", common.JSONMarshall(&recovery.Params{ID: "t1"}))
+			}()
+			Eventually(status("alice", "t1"), 30*time.Second, time.Second).Should(Equal(recovery.Checkpointed))
+			Expect(status("bob", "t1")()).To(Equal(recovery.Started))
+			ii.KillFSCNode("alice")
+			time.Sleep(3 * time.Second)
+
+			// once restarted, the initiator resumes the transfer on the session bob is still waiting on
+			ii.StartFSCNode("alice")
+			Eventually(status("bob", "t1"), time.Minute, time.Second).Should(Equal(recovery.Completed))
+			Eventually(status("alice", "t1"), time.Minute, time.Second).Should(Equal(recovery.Completed))
+		})
+
+	})
+
+})
@@ -0,0 +1,43 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// Responder acknowledges the two steps of a transfer. Between them, it waits for the initiator on the same session,
+// also while the initiator restarts.
+type Responder struct{}
+
+func (r *Responder) Call(context view.Context) (interface{}, error) {
+	session := context.Session()
+	var id string
+	select {
+	case msg := <-session.Receive():
+		id = string(msg.Payload)
+	case <-time.After(time.Minute):
+		return nil, errors.New("timeout waiting for the transfer")
+	}
+	if err := setStatus(context, id, Started); err != nil {
+		return nil, err
+	}
+	if err := session.Send([]byte("ack1")); err != nil {
+		return nil, err
+	}
+
+	if err := expect(session, "commit"); err != nil {
+		return nil, err
+	}
+	if err := setStatus(context, id, Completed); err != nil {
+		return nil, err
+	}
+	return nil, session.Send([]byte("ack2"))
+}
@@ -0,0 +1,28 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fsc"
+)
+
+// Topology has an initiator, alice, running a recoverable transfer with bob
+func Topology() []api.Topology {
+	// Create an empty FSC topology
+	topology := fsc.NewTopology()
+
+	topology.AddNodeByName("alice").
+		RegisterViewFactory("transfer", &InitiatorViewFactory{}).
+		RegisterViewFactory("status", &StatusViewFactory{}).
+		RegisterRecoverable(&Initiator{})
+
+	topology.AddNodeByName("bob").
+		RegisterResponder(&Responder{}, &Initiator{}).
+		RegisterViewFactory("status", &StatusViewFactory{})
+	return []api.Topology{topology}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/integration"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/fsc/stoprestart"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fsc"
 )
 
 var _ = Describe("EndToEnd", func() {
@@ -54,6 +55,30 @@ var _ = Describe("EndToEnd", func() {
 			Expect(common.JSONUnmarshalString(res)).To(BeEquivalentTo("OK"))
 		})
 
+		It("replace a node with a new one assuming its identity", func() {
+			res, err := ii.Client("alice").CallView("init", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(common.JSONUnmarshalString(res)).To(BeEquivalentTo("OK"))
+
+			// bob is lost, a new node takes over its identity
+			ii.StopFSCNode("bob")
+			bundle, err := ii.ExportNodeIdentity("bob")
+			Expect(err).NotTo(HaveOccurred())
+			archive, err := bundle.Archive()
+			Expect(err).NotTo(HaveOccurred())
+			bundle, err = fsc.ReadIdentityBundle(archive)
+			Expect(err).NotTo(HaveOccurred())
+			name, err := ii.AddNodeFromIdentity(bundle)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).NotTo(Equal("bob"))
+			time.Sleep(3 * time.Second)
+
+			// alice, still running with its configuration, reaches bob at the new node
+			res, err = ii.Client("alice").CallView("init", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(common.JSONUnmarshalString(res)).To(BeEquivalentTo("OK"))
+		})
+
 	})
 
 })
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package integration
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -20,6 +21,7 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/context"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/faults"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fsc"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fsc/commands"
@@ -31,6 +33,7 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	"github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit/grouper"
 )
 
 var logger = flogging.MustGetLogger("fsc.integration")
@@ -40,13 +43,18 @@ type Configuration struct {
 }
 
 type Infrastructure struct {
-	TestDir           string
-	StartPort         int
-	Ctx               *context.Context
-	NWO               *nwo.NWO
-	BuildServer       *common.BuildServer
-	DeleteOnStop      bool
-	DeleteOnStart     bool
+	TestDir       string
+	StartPort     int
+	Ctx           *context.Context
+	NWO           *nwo.NWO
+	BuildServer   *common.BuildServer
+	DeleteOnStop  bool
+	DeleteOnStart bool
+	// Fresh forces the regeneration of all the artifacts, even if compatible ones exist already
+	Fresh bool
+	// AdoptArtifacts reuses the artifacts generated by a previous version, that do not record their inputs,
+	// instead of generating them again
+	AdoptArtifacts    bool
 	PlatformFactories map[string]api.PlatformFactory
 	Topologies        []api.Topology
 	FscPlatform       *fsc.Platform
@@ -156,13 +164,23 @@ func (i *Infrastructure) RegisterPlatformFactory(factory api.PlatformFactory) {
 	i.PlatformFactories[factory.Name()] = factory
 }
 
+// Generate generates the artifacts of the networks.
+// Artifacts already existing in the test folder and compatible with the topologies are reused,
+// unless DeleteOnStart or Fresh are set.
 func (i *Infrastructure) Generate() {
-	if i.DeleteOnStart {
+	switch {
+	case i.DeleteOnStart || i.Fresh:
 		logger.Infof("Delete test folder [%s]", i.TestDir)
 		if err := os.RemoveAll(i.TestDir); err != nil {
 			panic(err)
 		}
+	case !i.compatible():
+		logger.Infof("Test folder [%s] generated for different topologies, delete it", i.TestDir)
+		if err := os.RemoveAll(i.TestDir); err != nil {
+			panic(err)
+		}
 	}
+	i.Ctx.ArtifactsAdoption = i.AdoptArtifacts
 	i.initNWO()
 	i.NWO.Generate()
 	i.storeAdditionalConfigurations()
@@ -185,6 +203,24 @@ func (i *Infrastructure) Start() {
 	i.NWO.Start()
 }
 
+// Resume reattaches to the networks started by a previous run, if they are still running.
+// Otherwise, the networks are started.
+func (i *Infrastructure) Resume() {
+	if i.NWO == nil {
+		panic("call generate or load first")
+	}
+	i.NWO.Resume()
+}
+
+// Export writes, next to the generated artifacts, the services and the scripts running the networks outside NWO.
+// Nothing is started.
+func (i *Infrastructure) Export(opts nwo.ExportOptions) error {
+	if i.NWO == nil {
+		panic("call generate or load first")
+	}
+	return i.NWO.Export(opts)
+}
+
 func (i *Infrastructure) Stop() {
 	if i.NWO == nil {
 		panic("call generate or load first")
@@ -258,6 +294,15 @@ func (i *Infrastructure) StopFSCNode(id string) {
 	i.NWO.StopFSCNode(id)
 }
 
+// KillFSCNode kills the FSC node with the passed id, it has no chance to shut down
+func (i *Infrastructure) KillFSCNode(id string) {
+	if i.NWO == nil {
+		panic("call generate or load first")
+	}
+
+	i.NWO.KillFSCNode(id)
+}
+
 func (i *Infrastructure) StartFSCNode(id string) {
 	if i.NWO == nil {
 		panic("call generate or load first")
@@ -266,6 +311,41 @@ func (i *Infrastructure) StartFSCNode(id string) {
 	i.NWO.StartFSCNode(id)
 }
 
+// Faults returns the injector of faults in the nodes of the networks, to script them from the specs.
+// The faults injected during a spec are healed once the spec is over.
+func (i *Infrastructure) Faults() *faults.Faults {
+	if i.NWO == nil {
+		panic("call generate or load first")
+	}
+
+	return i.NWO.Faults()
+}
+
+// ExportNodeIdentity returns the identity bundle of the passed FSC node
+func (i *Infrastructure) ExportNodeIdentity(name string) (*fsc.IdentityBundle, error) {
+	if i.NWO == nil {
+		panic("call generate or load first")
+	}
+
+	return i.FscPlatform.ExportNodeIdentity(name)
+}
+
+// AddNodeFromIdentity provisions and starts a new FSC node that assumes the identity in the passed bundle,
+// in place of the node the bundle has been exported from. It returns the name of the new node.
+func (i *Infrastructure) AddNodeFromIdentity(bundle *fsc.IdentityBundle, opts ...smartclient.Option) (string, error) {
+	if i.NWO == nil {
+		panic("call generate or load first")
+	}
+
+	peer, err := i.FscPlatform.AddNodeFromIdentity(bundle, opts...)
+	if err != nil {
+		return "", err
+	}
+	i.NWO.AddFSCNode(grouper.Member{Name: peer.ID(), Runner: i.FscPlatform.FSCNodeRunner(peer)})
+	i.FscPlatform.PostRun(true)
+	return peer.Name, nil
+}
+
 func (i *Infrastructure) EnableRaceDetector() {
 	i.BuildServer.EnableRaceDetector()
 }
@@ -307,26 +387,54 @@ func (i *Infrastructure) initNWO() {
 
 func (i *Infrastructure) storeAdditionalConfigurations() {
 	// store configuration
-	conf := &Configuration{
-		StartPort: i.StartPort,
-	}
-	raw, err := json.Marshal(conf)
-	if err != nil {
+	if err := ioutil.WriteFile(filepath.Join(i.TestDir, "conf.json"), i.configuration(), 0770); err != nil {
 		panic(err)
 	}
-	if err := ioutil.WriteFile(filepath.Join(i.TestDir, "conf.json"), raw, 0770); err != nil {
+
+	// store topology
+	if err := ioutil.WriteFile(filepath.Join(i.TestDir, "topology.yaml"), i.topologies(), 0770); err != nil {
 		panic(err)
 	}
+}
 
-	// store topology
-	t := api.Topologies{Topologies: i.Topologies}
-	raw, err = t.Export()
+// compatible returns true if the artifacts in the test folder, if any, have been generated
+// with the same configuration and topologies of this infrastructure
+func (i *Infrastructure) compatible() bool {
+	for name, expected := range map[string][]byte{
+		"conf.json":     i.configuration(),
+		"topology.yaml": i.topologies(),
+	} {
+		raw, err := ioutil.ReadFile(filepath.Join(i.TestDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			panic(err)
+		}
+		if !bytes.Equal(raw, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *Infrastructure) configuration() []byte {
+	raw, err := json.Marshal(&Configuration{
+		StartPort: i.StartPort,
+	})
 	if err != nil {
 		panic(err)
 	}
-	if err := ioutil.WriteFile(filepath.Join(i.TestDir, "topology.yaml"), raw, 0770); err != nil {
+	return raw
+}
+
+func (i *Infrastructure) topologies() []byte {
+	t := api.Topologies{Topologies: i.Topologies}
+	raw, err := t.Export()
+	if err != nil {
 		panic(err)
 	}
+	return raw
 }
 
 func failMe(message string, callerSkip ...int) {
@@ -7,6 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package api
 
 import (
+	"os"
+	"os/exec"
+
 	"github.com/tedsuo/ifrit/grouper"
 	"gopkg.in/yaml.v2"
 
@@ -75,6 +78,7 @@ type Context interface {
 	GetViewIdentityAliases(name string) []string
 	AdminSigningIdentity(name string) view.SigningIdentity
 	IgnoreSigHUP() bool
+	AdoptArtifacts() bool
 }
 
 type Builder interface {
@@ -99,6 +103,60 @@ type Platform interface {
 	Cleanup()
 }
 
+// Resumable is implemented by the platforms running services that are not processes
+// started by NWO (for example, containers)
+type Resumable interface {
+	// Running returns true if the services started by a previous run are still running
+	Running() bool
+}
+
+// Process is a process a platform runs, described so that it can be run outside NWO
+type Process struct {
+	Name string
+	// Args are the executable, as an absolute path, followed by its arguments
+	Args []string
+	// Env are the environment variables set explicitly for the process, in the KEY=VALUE form
+	Env []string
+	// Dir is the working directory, if any
+	Dir string
+	// Volumes are the host paths the process needs, besides the executable and the artifacts of the networks
+	Volumes []string
+}
+
+// NewProcess returns the process running the passed command.
+// The environment inherited from the current process is not part of the returned process.
+func NewProcess(name string, cmd *exec.Cmd, volumes ...string) Process {
+	env := cmd.Env
+	inherited := os.Environ()
+	if len(env) >= len(inherited) {
+		i := 0
+		for i < len(inherited) && env[i] == inherited[i] {
+			i++
+		}
+		if i == len(inherited) {
+			env = env[i:]
+		}
+	}
+	return Process{
+		Name:    name,
+		Args:    append([]string{cmd.Path}, cmd.Args[1:]...),
+		Env:     append([]string{}, env...),
+		Dir:     cmd.Dir,
+		Volumes: volumes,
+	}
+}
+
+// Exportable is implemented by the platforms whose networks can be exported, to run them outside NWO
+type Exportable interface {
+	// Services returns the long-running processes of the platform, in start order
+	Services() []Process
+	// Setup returns the commands bootstrapping the networks once the services are running, in execution order.
+	// These are the steps PostRun performs the first time the networks are started, the channel creation for instance.
+	Setup() []Process
+	// Teardown returns the commands cleaning up what the services created outside the artifacts, the chaincode containers for instance
+	Teardown() []Process
+}
+
 type PlatformFactory interface {
 	Name() string
 	New(registry Context, t Topology, builder Builder) Platform
@@ -11,6 +11,7 @@ import (
 	"os"
 
 	"github.com/hyperledger-labs/fabric-smart-client/integration"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/pkg/errors"
@@ -29,6 +30,11 @@ var (
 
 	path     string
 	topology string
+	fresh    bool
+	adopt    bool
+	format   string
+	image    string
+	port     int
 	// StartCMDPostNew is executed after the testing infrastructure is created
 	StartCMDPostNew CallbackFunc
 	// StartCMDPostStart is executed after the testing infrastructure is started
@@ -55,6 +61,7 @@ func NewCmdWithMultipleTopologies(topologies Topologies) *cobra.Command {
 		GenerateCmd(topologies),
 		CleanCmd(),
 		StartCmd(topologies),
+		ExportCmd(topologies),
 	)
 
 	return rootCommand
@@ -78,6 +85,8 @@ func GenerateCmd(topologies Topologies) *cobra.Command {
 	flags := cmd.Flags()
 	flags.StringVarP(&path, "path", "p", "", "where to store the generated network artifacts")
 	flags.StringVarP(&topology, "topology", "t", "default", "topology to use (in case multiple topologies are provided)")
+	flags.BoolVarP(&fresh, "fresh", "f", false, "regenerate all the network artifacts, even if compatible ones exist already")
+	flags.BoolVarP(&adopt, "adopt", "a", false, "reuse the artifacts generated by a previous version, that do not record their inputs")
 
 	return cmd
 }
@@ -95,6 +104,8 @@ func Generate(topologies Topologies) error {
 			return errors.WithMessage(err, "failed to post new")
 		}
 	}
+	ii.Fresh = fresh
+	ii.AdoptArtifacts = adopt
 	ii.Generate()
 	return nil
 }
@@ -153,6 +164,8 @@ func StartCmd(topologies Topologies) *cobra.Command {
 	flags := cmd.Flags()
 	flags.StringVarP(&path, "path", "p", "", "where to store the generated network artifacts")
 	flags.StringVarP(&topology, "topology", "t", "default", "topology to use (in case multiple topologies are provided)")
+	flags.BoolVarP(&fresh, "fresh", "f", false, "regenerate all the network artifacts and restart the networks from scratch")
+	flags.BoolVarP(&adopt, "adopt", "a", false, "reuse the artifacts generated by a previous version, that do not record their inputs")
 
 	return cmd
 }
@@ -165,12 +178,7 @@ func Start(topologies Topologies) error {
 	logger.Infof("___) |    | |    / ___ \\  |  _ <    | |")
 	logger.Infof("|____/    |_|   /_/   \\_\\ |_| \\_\\   |_|")
 
-	// if ./artifacts exists, then load. Otherwise, create new artifacts
-	var ii *integration.Infrastructure
-	_, err := os.Stat(path)
-	init := os.IsNotExist(err)
-
-	ii, err = integration.New(20000, path, topologies[topology]...)
+	ii, err := integration.New(20000, path, topologies[topology]...)
 	if err != nil {
 		return errors.WithMessage(err, "failed to create new infrastructure")
 	}
@@ -182,14 +190,12 @@ func Start(topologies Topologies) error {
 		}
 	}
 
-	if init {
-		ii.Generate()
-	} else {
-		ii.Load()
-	}
-
+	// existing artifacts are reused, if compatible, and running networks are resumed
+	ii.Fresh = fresh
+	ii.AdoptArtifacts = adopt
+	ii.Generate()
 	ii.DeleteOnStop = false
-	ii.Start()
+	ii.Resume()
 	if StartCMDPostStart != nil {
 		err = StartCMDPostStart(ii)
 		if err != nil {
@@ -206,3 +212,51 @@ func Start(topologies Topologies) error {
 
 	return ii.Serve()
 }
+
+// ExportCmd returns the Cobra Command for Export
+func ExportCmd(topologies Topologies) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export Artifacts.",
+		Long:  `Generate the network artifacts, and the docker-compose or Kubernetes services running them, without starting anything.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("trailing args detected")
+			}
+			// Parsing of the command line is done so silence cmd usage
+			cmd.SilenceUsage = true
+			return Export(topologies)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&path, "path", "p", "", "where to store the generated network artifacts and services")
+	flags.StringVarP(&topology, "topology", "t", "default", "topology to use (in case multiple topologies are provided)")
+	flags.BoolVarP(&fresh, "fresh", "f", false, "regenerate all the network artifacts, even if compatible ones exist already")
+	flags.BoolVarP(&adopt, "adopt", "a", false, "reuse the artifacts generated by a previous version, that do not record their inputs")
+	flags.StringVarP(&format, "format", "o", nwo.ComposeFormat, "format of the services, compose or kubernetes")
+	flags.StringVarP(&image, "image", "i", nwo.DefaultExportImage, "image running the services")
+	flags.IntVar(&port, "port", 20000, "first port assigned to the services, the same topology and port give the same assignments")
+
+	return cmd
+}
+
+// Export generates the network artifacts and exports the services running them
+func Export(topologies Topologies) error {
+	if len(path) == 0 {
+		return errors.New("the path of the export is required")
+	}
+	ii, err := integration.New(port, path, topologies[topology]...)
+	if err != nil {
+		return errors.WithMessage(err, "failed to create new infrastructure")
+	}
+	if StartCMDPostNew != nil {
+		err = StartCMDPostNew(ii)
+		if err != nil {
+			return errors.WithMessage(err, "failed to post new")
+		}
+	}
+	ii.Fresh = fresh
+	ii.AdoptArtifacts = adopt
+	ii.Generate()
+	return ii.Export(nwo.ExportOptions{Format: format, Image: image})
+}
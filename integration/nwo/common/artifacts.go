@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	. "github.com/onsi/gomega"
+)
+
+// ArtifactFingerprintPath returns the path of the file storing the fingerprint of the inputs the passed artifact
+// has been generated from. It can be used as an input of the artifacts depending on the passed one.
+func ArtifactFingerprintPath(artifact string) string {
+	return artifact + ".fingerprint"
+}
+
+// Fingerprint returns the hash of the content of the passed files, regardless of their order
+func Fingerprint(inputs ...string) string {
+	sorted := append([]string{}, inputs...)
+	sort.Strings(sorted)
+	h := sha256.New()
+	for _, input := range sorted {
+		raw, err := ioutil.ReadFile(input)
+		Expect(err).NotTo(HaveOccurred(), "failed reading [%s]", input)
+		h.Write(raw)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ArtifactUpToDate returns true if the passed artifact (a file or a non-empty directory) exists and
+// it has been generated from the passed inputs, as recorded by RecordArtifact.
+// An artifact without a recorded fingerprint, generated by a previous version, is not up to date, see AdoptArtifact.
+func ArtifactUpToDate(artifact string, inputs ...string) bool {
+	if !artifactExists(artifact) {
+		return false
+	}
+	raw, err := ioutil.ReadFile(ArtifactFingerprintPath(artifact))
+	if os.IsNotExist(err) {
+		return false
+	}
+	Expect(err).NotTo(HaveOccurred())
+	return string(raw) == Fingerprint(inputs...)
+}
+
+// AdoptArtifact records the passed inputs as the ones the passed artifact has been generated from, if the artifact
+// exists without a recorded fingerprint, as generated by a previous version. It returns true if the artifact has
+// been adopted, the caller is trusted to know that the artifact is compatible with the inputs.
+func AdoptArtifact(artifact string, inputs ...string) bool {
+	if !artifactExists(artifact) {
+		return false
+	}
+	if _, err := os.Stat(ArtifactFingerprintPath(artifact)); !os.IsNotExist(err) {
+		Expect(err).NotTo(HaveOccurred())
+		return false
+	}
+	RecordArtifact(artifact, inputs...)
+	return true
+}
+
+// RecordArtifact records the fingerprint of the inputs the passed artifact has been generated from
+func RecordArtifact(artifact string, inputs ...string) {
+	Expect(ioutil.WriteFile(ArtifactFingerprintPath(artifact), []byte(Fingerprint(inputs...)), 0660)).NotTo(HaveOccurred())
+}
+
+func artifactExists(artifact string) bool {
+	info, err := os.Stat(artifact)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(artifact)
+		Expect(err).NotTo(HaveOccurred())
+		return len(entries) != 0
+	}
+	return true
+}
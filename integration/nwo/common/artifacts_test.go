@@ -0,0 +1,54 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestArtifactUpToDate(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "artifacts")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.yaml")
+	artifact := filepath.Join(dir, "crypto")
+	Expect(ioutil.WriteFile(input, []byte("v1"), 0660)).NotTo(HaveOccurred())
+
+	// missing or empty artifacts must be generated
+	Expect(ArtifactUpToDate(artifact, input)).To(BeFalse())
+	Expect(os.MkdirAll(artifact, 0770)).NotTo(HaveOccurred())
+	Expect(ArtifactUpToDate(artifact, input)).To(BeFalse())
+
+	Expect(ioutil.WriteFile(filepath.Join(artifact, "key.pem"), []byte("key"), 0660)).NotTo(HaveOccurred())
+	RecordArtifact(artifact, input)
+	Expect(ArtifactUpToDate(artifact, input)).To(BeTrue())
+
+	// a change of the inputs invalidates the artifact
+	Expect(ioutil.WriteFile(input, []byte("v2"), 0660)).NotTo(HaveOccurred())
+	Expect(ArtifactUpToDate(artifact, input)).To(BeFalse())
+
+	// an artifact without fingerprint must be generated, unless it is adopted
+	Expect(os.Remove(ArtifactFingerprintPath(artifact))).NotTo(HaveOccurred())
+	Expect(ArtifactUpToDate(artifact, input)).To(BeFalse())
+	Expect(ArtifactFingerprintPath(artifact)).NotTo(BeAnExistingFile())
+	Expect(AdoptArtifact(artifact, input)).To(BeTrue())
+	Expect(ArtifactUpToDate(artifact, input)).To(BeTrue())
+
+	// only artifacts without fingerprint are adopted
+	Expect(ioutil.WriteFile(input, []byte("v3"), 0660)).NotTo(HaveOccurred())
+	Expect(AdoptArtifact(artifact, input)).To(BeFalse())
+	Expect(ArtifactUpToDate(artifact, input)).To(BeFalse())
+	Expect(AdoptArtifact(filepath.Join(dir, "missing"), input)).To(BeFalse())
+}
@@ -48,6 +48,9 @@ type Context struct {
 	AdminSigningIdentities  map[string]SigningIdentity
 
 	SigHUPIgnore bool
+	// ArtifactsAdoption adopts the artifacts generated by a previous version, without a recorded fingerprint,
+	// instead of generating them again
+	ArtifactsAdoption bool
 }
 
 func New(rootDir string, portCounter uint16, builder api.Builder, topologies ...api.Topology) *Context {
@@ -205,3 +208,7 @@ func (c *Context) AddPlatform(platform api.Platform) {
 func (c *Context) IgnoreSigHUP() bool {
 	return c.SigHUPIgnore
 }
+
+func (c *Context) AdoptArtifacts() bool {
+	return c.ArtifactsAdoption
+}
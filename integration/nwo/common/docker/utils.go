@@ -72,6 +72,23 @@ func (d *Docker) CreateNetwork(networkID string) error {
 	return errors.Wrapf(err, "failed creating new docker network with ID='%s'", networkID)
 }
 
+// Running returns true if at least one running container meets the condition of the `matchName` predicate function,
+// returns an error in case of a failure.
+func (d *Docker) Running(matchName func(name string) bool) (bool, error) {
+	containers, err := d.Client.ListContainers(docker.ListContainersOptions{})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed listing running containers")
+	}
+	for _, c := range containers {
+		for _, name := range c.Names {
+			if matchName(name) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 // Cleanup is a helper function to release all container associated with `networkID`, returns an error in case of a failure.
 // It removes all container that meet the condition of the `matchName` predicate function, removes the attached volumes,
 // container images, the network.
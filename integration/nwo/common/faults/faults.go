@@ -0,0 +1,198 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package faults
+
+import (
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("nwo.faults")
+
+// Route is a connection opened by a node to a service of another node, through a proxy where faults can be injected
+type Route struct {
+	// Source is the identifier of the node opening the connections
+	Source string
+	// Target is the identifier of the node serving them
+	Target string
+	// Listen is the address of the proxy, the one the source node connects to
+	Listen string
+	// Address is the address of the target node
+	Address string
+}
+
+// Process is the process of a node
+type Process interface {
+	PID() (string, int)
+}
+
+// Platform is implemented by the platforms whose nodes faults can be injected in
+type Platform interface {
+	// FaultRoutes returns the routes going through a proxy, empty if the fault injection is not enabled
+	FaultRoutes() []Route
+	// FaultProcesses returns the processes of the nodes, by node identifier
+	FaultProcesses() map[string]Process
+}
+
+type route struct {
+	Route
+	proxy *Proxy
+}
+
+// Faults injects faults in the nodes of the networks: it stops and resumes their processes, and alters the traffic
+// going through the proxies of their routes.
+// The nodes are identified by their full identifier (for example, `Org1.peer0`) or by its last part (`peer0`).
+// The faults injected during a Ginkgo spec are healed once the spec is over, whatever its outcome.
+type Faults struct {
+	lock      sync.Mutex
+	routes    []*route
+	processes map[string]Process
+	// stopped are the PIDs of the processes stopped, by node identifier
+	stopped map[string]int
+	// armed tells if the healing is already registered for the current spec
+	armed bool
+}
+
+// New starts a proxy for each passed route
+func New(routes []Route, processes map[string]Process) (*Faults, error) {
+	f := &Faults{processes: processes, stopped: map[string]int{}}
+	for _, r := range routes {
+		proxy, err := NewProxy(r.Listen, r.Address)
+		if err != nil {
+			f.Close()
+			return nil, errors.WithMessagef(err, "failed starting the proxy from [%s] to [%s]", r.Source, r.Target)
+		}
+		logger.Debugf("proxy from [%s] to [%s] at [%s]->[%s]", r.Source, r.Target, r.Listen, r.Address)
+		f.routes = append(f.routes, &route{Route: r, proxy: proxy})
+	}
+	return f, nil
+}
+
+// StopPeer suspends the process of the passed peer, until Heal
+func (f *Faults) StopPeer(id string) {
+	f.stop(id)
+}
+
+// StopOrderer suspends the process of the passed orderer, until Heal
+func (f *Faults) StopOrderer(id string) {
+	f.stop(id)
+}
+
+// PartitionNodes drops the connections between the two passed nodes, in both directions, and refuses the new ones
+func (f *Faults) PartitionNodes(a, b string) {
+	routes := append(f.find(a, b), f.find(b, a)...)
+	Expect(routes).NotTo(BeEmpty(), "no route between [%s] and [%s]", a, b)
+	f.arm()
+	for _, r := range routes {
+		logger.Infof("partition [%s] from [%s]", r.Source, r.Target)
+		r.proxy.SetPartitioned(true)
+	}
+}
+
+// AddLatency delays the traffic of the connections of the passed node to the passed target by the passed duration,
+// in each direction
+func (f *Faults) AddLatency(node, target string, latency time.Duration) {
+	routes := f.find(node, target)
+	Expect(routes).NotTo(BeEmpty(), "no route from [%s] to [%s]", node, target)
+	f.arm()
+	for _, r := range routes {
+		logger.Infof("add latency [%s] from [%s] to [%s]", latency, r.Source, r.Target)
+		r.proxy.SetLatency(latency)
+	}
+}
+
+// CorruptStream alters the bytes the passed target sends back to the passed node
+func (f *Faults) CorruptStream(node, target string) {
+	routes := f.find(node, target)
+	Expect(routes).NotTo(BeEmpty(), "no route from [%s] to [%s]", node, target)
+	f.arm()
+	for _, r := range routes {
+		logger.Infof("corrupt stream from [%s] to [%s]", r.Target, r.Source)
+		r.proxy.SetCorrupted(true)
+	}
+}
+
+// Heal resumes the processes stopped and removes the faults injected in the routes
+func (f *Faults) Heal() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for id, pid := range f.stopped {
+		logger.Infof("resume [%s]", id)
+		if err := syscall.Kill(pid, syscall.SIGCONT); err != nil {
+			logger.Warnf("failed resuming [%s:%d]: [%s]", id, pid, err)
+		}
+		delete(f.stopped, id)
+	}
+	for _, r := range f.routes {
+		r.proxy.Heal()
+	}
+	f.armed = false
+}
+
+// Close heals the faults and stops the proxies
+func (f *Faults) Close() {
+	f.Heal()
+	for _, r := range f.routes {
+		if err := r.proxy.Close(); err != nil {
+			logger.Warnf("failed closing the proxy from [%s] to [%s]: [%s]", r.Source, r.Target, err)
+		}
+	}
+	f.routes = nil
+}
+
+func (f *Faults) stop(id string) {
+	var found []string
+	for name := range f.processes {
+		if matches(name, id) {
+			found = append(found, name)
+		}
+	}
+	Expect(found).To(HaveLen(1), "expected exactly one process for [%s]", id)
+	_, pid := f.processes[found[0]].PID()
+	Expect(pid).NotTo(BeZero(), "process of [%s] not running", found[0])
+
+	f.arm()
+	logger.Infof("stop [%s:%d]", found[0], pid)
+	Expect(syscall.Kill(pid, syscall.SIGSTOP)).To(Succeed())
+	f.lock.Lock()
+	f.stopped[found[0]] = pid
+	f.lock.Unlock()
+}
+
+// find returns the routes from the passed source to the passed target
+func (f *Faults) find(source, target string) []*route {
+	var res []*route
+	for _, r := range f.routes {
+		if matches(r.Source, source) && matches(r.Target, target) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// arm registers the healing of the faults at the end of the current spec, once per spec
+func (f *Faults) arm() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.armed {
+		return
+	}
+	f.armed = true
+	ginkgo.DeferCleanup(f.Heal)
+}
+
+// matches returns true if the passed name is the full identifier of the passed node, or its last part
+func matches(id, name string) bool {
+	return id == name || strings.HasSuffix(id, "."+name)
+}
@@ -0,0 +1,210 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package faults
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// dialTimeout bounds the time to connect to the target of a proxy
+const dialTimeout = 5 * time.Second
+
+// Proxy forwards the TCP connections it accepts to a target address.
+// Faults can be injected in the forwarded traffic: added latency, a partition that drops the open connections and
+// refuses the new ones, and the corruption of the bytes sent back by the target.
+type Proxy struct {
+	listener net.Listener
+	target   string
+
+	lock        sync.Mutex
+	latency     time.Duration
+	partitioned bool
+	corrupted   bool
+	conns       map[net.Conn]struct{}
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// NewProxy returns a proxy listening on the passed address and forwarding to the target address
+func NewProxy(listen, target string) (*Proxy, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed listening on [%s]", listen)
+	}
+	p := &Proxy{
+		listener: listener,
+		target:   target,
+		conns:    map[net.Conn]struct{}{},
+	}
+	p.wg.Add(1)
+	go p.serve()
+	return p, nil
+}
+
+// Address returns the address the proxy listens on
+func (p *Proxy) Address() string {
+	return p.listener.Addr().String()
+}
+
+// Target returns the address the proxy forwards to
+func (p *Proxy) Target() string {
+	return p.target
+}
+
+// SetLatency delays each chunk of bytes forwarded, in both directions, by the passed duration
+func (p *Proxy) SetLatency(latency time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.latency = latency
+}
+
+// SetPartitioned drops the open connections and refuses the new ones, until called with false
+func (p *Proxy) SetPartitioned(partitioned bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.partitioned = partitioned
+	if partitioned {
+		p.dropConns()
+	}
+}
+
+// SetCorrupted alters the bytes sent back by the target, until called with false
+func (p *Proxy) SetCorrupted(corrupted bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.corrupted = corrupted
+}
+
+// Heal removes all the faults injected. The connections dropped by a partition are not restored, the clients
+// are expected to reconnect.
+func (p *Proxy) Heal() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.latency = 0
+	p.partitioned = false
+	p.corrupted = false
+}
+
+// Close stops listening and drops the open connections
+func (p *Proxy) Close() error {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil
+	}
+	p.closed = true
+	err := p.listener.Close()
+	p.dropConns()
+	p.lock.Unlock()
+	p.wg.Wait()
+	return err
+}
+
+func (p *Proxy) serve() {
+	defer p.wg.Done()
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go p.handle(client)
+	}
+}
+
+func (p *Proxy) handle(client net.Conn) {
+	defer p.wg.Done()
+	if p.isPartitioned() {
+		client.Close()
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", p.target, dialTimeout)
+	if err != nil {
+		logger.Debugf("failed connecting to [%s]: [%s]", p.target, err)
+		client.Close()
+		return
+	}
+	if !p.track(client, upstream) {
+		client.Close()
+		upstream.Close()
+		return
+	}
+	p.wg.Add(2)
+	go p.pipe(upstream, client, false)
+	go p.pipe(client, upstream, true)
+}
+
+// pipe copies from src to dst until either fails, then closes both
+func (p *Proxy) pipe(dst, src net.Conn, response bool) {
+	defer p.wg.Done()
+	defer p.untrack(dst, src)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			latency, corrupted := p.faults()
+			if latency > 0 {
+				time.Sleep(latency)
+			}
+			if response && corrupted {
+				buf[n/2] ^= 0xff
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *Proxy) faults() (time.Duration, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.latency, p.corrupted
+}
+
+func (p *Proxy) isPartitioned() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.partitioned
+}
+
+// track records the connections of a forwarded connection, false if they must be dropped right away
+func (p *Proxy) track(conns ...net.Conn) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed || p.partitioned {
+		return false
+	}
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+	return true
+}
+
+func (p *Proxy) untrack(conns ...net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, c := range conns {
+		c.Close()
+		delete(p.conns, c)
+	}
+}
+
+// dropConns closes the open connections, p.lock must be held
+func (p *Proxy) dropConns() {
+	for c := range p.conns {
+		c.Close()
+		delete(p.conns, c)
+	}
+}
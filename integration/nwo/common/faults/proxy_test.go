@@ -0,0 +1,95 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package faults
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// echo returns the address of a server echoing back the lines it receives
+func echo() (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if _, err := conn.Write(append(scanner.Bytes(), '\n')); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+// roundTrip sends a line through the passed connection and returns the one received back
+func roundTrip(conn net.Conn, line string) (string, error) {
+	if _, err := conn.Write([]byte(line + "\n")); err != nil {
+		return "", err
+	}
+	Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+	return bufio.NewReader(conn).ReadString('\n')
+}
+
+func TestProxy(t *testing.T) {
+	RegisterTestingT(t)
+
+	target, stop := echo()
+	defer stop()
+	p, err := NewProxy("127.0.0.1:0", target)
+	Expect(err).NotTo(HaveOccurred())
+	defer p.Close()
+	Expect(p.Target()).To(Equal(target))
+
+	conn, err := net.Dial("tcp", p.Address())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(roundTrip(conn, "hello")).To(Equal("hello\n"))
+
+	// the latency applies to each direction
+	p.SetLatency(100 * time.Millisecond)
+	start := time.Now()
+	Expect(roundTrip(conn, "slow")).To(Equal("slow\n"))
+	Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+
+	// only the responses are corrupted
+	p.Heal()
+	p.SetCorrupted(true)
+	Expect(roundTrip(conn, "abcd")).NotTo(Equal("abcd\n"))
+
+	// a partition drops the open connections and refuses the new ones
+	p.Heal()
+	p.SetPartitioned(true)
+	_, err = roundTrip(conn, "lost")
+	Expect(err).To(HaveOccurred())
+	conn, err = net.Dial("tcp", p.Address())
+	Expect(err).NotTo(HaveOccurred())
+	_, err = roundTrip(conn, "refused")
+	Expect(err).To(HaveOccurred())
+
+	// once healed, the new connections go through
+	p.Heal()
+	conn, err = net.Dial("tcp", p.Address())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(roundTrip(conn, "healed")).To(Equal("healed\n"))
+
+	Expect(p.Close()).To(Succeed())
+	_, err = net.Dial("tcp", p.Address())
+	Expect(err).To(HaveOccurred())
+}
@@ -193,6 +193,12 @@ func (r *Runner) Stop() {
 	r.stop <- syscall.SIGTERM
 }
 
+// Kill kills the process, it has no chance to shut down
+func (r *Runner) Kill() {
+	logger.Infof("Send SIGKILL to [%s]", r.Name)
+	r.stop <- syscall.SIGKILL
+}
+
 func (r *Runner) PID() (string, int) {
 	return r.Command.Path, r.Command.Process.Pid
 }
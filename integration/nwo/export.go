@@ -0,0 +1,450 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package nwo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ComposeFormat exports the services as a docker-compose.yaml
+	ComposeFormat = "compose"
+	// KubernetesFormat exports the services as Kubernetes pods, one manifest per phase in the kubernetes folder
+	KubernetesFormat = "kubernetes"
+	// DefaultExportImage is the image running the exported services, unless configured otherwise
+	DefaultExportImage = "ubuntu:20.04"
+
+	exportBinDir = "bin"
+	// setupDoneFile marks the setup as done, the networks are bootstrapped only once
+	setupDoneFile = ".setup.done"
+)
+
+var invalidServiceChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ExportOptions configures the export of the networks
+type ExportOptions struct {
+	// Format is ComposeFormat or KubernetesFormat, default ComposeFormat
+	Format string
+	// Image is the image running the services, default DefaultExportImage
+	Image string
+}
+
+// exportPhase is a group of services started together, followed by the commands bootstrapping them.
+// As in Start, the networks are started and bootstrapped before the FSC nodes.
+type exportPhase struct {
+	name     string
+	services []exportService
+	setup    []api.Process
+	teardown []api.Process
+}
+
+type exportService struct {
+	name    string
+	process api.Process
+	volumes []string
+}
+
+// Export writes to the root folder, next to the artifacts of the networks, the files running the networks outside NWO:
+// the services, as docker-compose.yaml or Kubernetes manifests, and the scripts up.sh and down.sh.
+// Nothing is started. The services run the same processes, with the same configuration, Start would run,
+// on the host network, and mount the root folder at the same path, so that the absolute paths in the configuration hold.
+// The executables are copied to the bin folder. All the platforms must implement api.Exportable.
+func (n *NWO) Export(opts ExportOptions) error {
+	if len(opts.Format) == 0 {
+		opts.Format = ComposeFormat
+	}
+	if opts.Format != ComposeFormat && opts.Format != KubernetesFormat {
+		return errors.Errorf("unknown export format [%s], expected %s or %s", opts.Format, ComposeFormat, KubernetesFormat)
+	}
+	if len(opts.Image) == 0 {
+		opts.Image = DefaultExportImage
+	}
+	rootDir := n.ctx.RootDir()
+
+	networks := &exportPhase{name: "networks"}
+	fsc := &exportPhase{name: "fsc"}
+	for _, platform := range n.Platforms {
+		exportable, ok := platform.(api.Exportable)
+		if !ok {
+			return errors.Errorf("platform [%s] of type [%s] cannot be exported", platform.Name(), platform.Type())
+		}
+		phase := networks
+		if platform.Type() == "fsc" {
+			phase = fsc
+		}
+		for _, p := range exportable.Services() {
+			phase.services = append(phase.services, exportService{name: serviceName(p.Name), process: p})
+		}
+		phase.setup = append(phase.setup, exportable.Setup()...)
+		phase.teardown = append(phase.teardown, exportable.Teardown()...)
+	}
+	phases := []*exportPhase{networks, fsc}
+
+	// the executables are copied to the bin folder, the services mount it with the root folder
+	binaries := &binaries{dir: filepath.Join(rootDir, exportBinDir), paths: map[string]string{}, names: map[string]bool{}}
+	names := map[string]bool{}
+	for _, phase := range phases {
+		for i := range phase.services {
+			s := &phase.services[i]
+			if names[s.name] {
+				return errors.Errorf("service [%s] exported twice", s.name)
+			}
+			names[s.name] = true
+			if err := binaries.relocate(&s.process); err != nil {
+				return err
+			}
+			s.volumes = dedup(append([]string{rootDir}, s.process.Volumes...))
+		}
+		for _, processes := range [][]api.Process{phase.setup, phase.teardown} {
+			for i := range processes {
+				if err := binaries.relocate(&processes[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	files := map[string][]byte{}
+	var err error
+	switch opts.Format {
+	case ComposeFormat:
+		files["docker-compose.yaml"], err = composeFile(opts.Image, phases)
+	case KubernetesFormat:
+		for _, phase := range phases {
+			if len(phase.services) == 0 {
+				continue
+			}
+			if files[filepath.Join("kubernetes", phase.name+".yaml")], err = kubernetesManifest(opts.Image, phase); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed exporting the services")
+	}
+	for _, phase := range phases {
+		if len(phase.setup) != 0 {
+			files["setup-"+phase.name+".sh"] = setupScript(phase)
+		}
+	}
+	files["up.sh"] = upScript(opts.Format, phases)
+	files["down.sh"] = downScript(opts.Format, phases)
+
+	for name, content := range files {
+		path := filepath.Join(rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return errors.Wrapf(err, "failed creating folder of [%s]", path)
+		}
+		mode := os.FileMode(0644)
+		if strings.HasSuffix(name, ".sh") {
+			mode = 0755
+		}
+		if err := os.WriteFile(path, content, mode); err != nil {
+			return errors.Wrapf(err, "failed writing [%s]", path)
+		}
+	}
+	logger.Infof("Networks exported to [%s], run [%s] to start them", rootDir, filepath.Join(rootDir, "up.sh"))
+	return nil
+}
+
+// binaries copies the executables of the processes to dir, the processes are changed to run the copies
+type binaries struct {
+	dir string
+	// paths maps the original executables to their copies
+	paths map[string]string
+	names map[string]bool
+}
+
+func (b *binaries) relocate(p *api.Process) error {
+	if len(p.Args) == 0 || !filepath.IsAbs(p.Args[0]) {
+		// the commands in the PATH of the host, like sh, are not copied
+		return nil
+	}
+	if copied, ok := b.paths[p.Args[0]]; ok {
+		p.Args[0] = copied
+		return nil
+	}
+	// executables with the same name get a suffix, in order of appearance, so that the names are stable
+	base := filepath.Base(p.Args[0])
+	name := base
+	for i := 1; b.names[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	dst := filepath.Join(b.dir, name)
+	if err := copyExecutable(p.Args[0], dst); err != nil {
+		return errors.Wrapf(err, "failed copying executable of [%s]", p.Name)
+	}
+	b.names[name] = true
+	b.paths[p.Args[0]] = dst
+	p.Args[0] = dst
+	return nil
+}
+
+func copyExecutable(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type composeService struct {
+	Image       string   `yaml:"image"`
+	NetworkMode string   `yaml:"network_mode"`
+	Entrypoint  []string `yaml:"entrypoint"`
+	Environment []string `yaml:"environment,omitempty"`
+	WorkingDir  string   `yaml:"working_dir,omitempty"`
+	Volumes     []string `yaml:"volumes"`
+	Restart     string   `yaml:"restart"`
+}
+
+func composeFile(image string, phases []*exportPhase) ([]byte, error) {
+	services := map[string]composeService{}
+	for _, phase := range phases {
+		for _, s := range phase.services {
+			volumes := make([]string, len(s.volumes))
+			for i, v := range s.volumes {
+				volumes[i] = v + ":" + v
+			}
+			env := make([]string, len(s.process.Env))
+			for i, e := range s.process.Env {
+				// compose interpolates the variables
+				env[i] = strings.ReplaceAll(e, "$", "$$")
+			}
+			services[s.name] = composeService{
+				Image:       image,
+				NetworkMode: "host",
+				Entrypoint:  s.process.Args,
+				Environment: env,
+				WorkingDir:  s.process.Dir,
+				Volumes:     volumes,
+				Restart:     "unless-stopped",
+			}
+		}
+	}
+	return yaml.Marshal(map[string]interface{}{"services": services})
+}
+
+type kubernetesPod struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   kubernetesMetadata `yaml:"metadata"`
+	Spec       kubernetesPodSpec  `yaml:"spec"`
+}
+
+type kubernetesMetadata struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+type kubernetesPodSpec struct {
+	HostNetwork   bool                  `yaml:"hostNetwork"`
+	RestartPolicy string                `yaml:"restartPolicy"`
+	Containers    []kubernetesContainer `yaml:"containers"`
+	Volumes       []kubernetesVolume    `yaml:"volumes"`
+}
+
+type kubernetesContainer struct {
+	Name         string                  `yaml:"name"`
+	Image        string                  `yaml:"image"`
+	Command      []string                `yaml:"command"`
+	Env          []kubernetesEnvVar      `yaml:"env,omitempty"`
+	WorkingDir   string                  `yaml:"workingDir,omitempty"`
+	VolumeMounts []kubernetesVolumeMount `yaml:"volumeMounts"`
+}
+
+type kubernetesEnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type kubernetesVolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+}
+
+type kubernetesVolume struct {
+	Name     string             `yaml:"name"`
+	HostPath kubernetesHostPath `yaml:"hostPath"`
+}
+
+type kubernetesHostPath struct {
+	Path string `yaml:"path"`
+}
+
+// kubernetesManifest returns the pods of the services of the passed phase. The pods use the host network and
+// host paths, the cluster is expected to be a single node running on the host the networks were generated on.
+func kubernetesManifest(image string, phase *exportPhase) ([]byte, error) {
+	var docs [][]byte
+	for _, s := range phase.services {
+		c := kubernetesContainer{
+			Name:       s.name,
+			Image:      image,
+			Command:    s.process.Args,
+			WorkingDir: s.process.Dir,
+		}
+		for _, e := range s.process.Env {
+			kv := strings.SplitN(e, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			c.Env = append(c.Env, kubernetesEnvVar{Name: kv[0], Value: kv[1]})
+		}
+		var volumes []kubernetesVolume
+		for i, v := range s.volumes {
+			name := fmt.Sprintf("volume-%d", i)
+			c.VolumeMounts = append(c.VolumeMounts, kubernetesVolumeMount{Name: name, MountPath: v})
+			volumes = append(volumes, kubernetesVolume{Name: name, HostPath: kubernetesHostPath{Path: v}})
+		}
+		doc, err := yaml.Marshal(kubernetesPod{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Metadata: kubernetesMetadata{
+				Name:   s.name,
+				Labels: map[string]string{"app.kubernetes.io/part-of": "fsc-" + phase.name},
+			},
+			Spec: kubernetesPodSpec{
+				HostNetwork:   true,
+				RestartPolicy: "Always",
+				Containers:    []kubernetesContainer{c},
+				Volumes:       volumes,
+			},
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed marshalling pod [%s]", s.name)
+		}
+		docs = append(docs, doc)
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// setupScript returns the bash script running the setup commands of the passed phase in order, on the host
+func setupScript(phase *exportPhase) []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "#!/usr/bin/env bash\n# Bootstraps the %s once their services are running.\nset -euo pipefail\n\n", phase.name)
+	// the services might not be ready yet
+	b.WriteString("retry() {\n  for i in $(seq 1 30); do\n    if \"$@\"; then\n      return 0\n    fi\n    sleep 2\n  done\n  return 1\n}\n\n")
+	writeCommands(b, "retry ", phase.setup)
+	return b.Bytes()
+}
+
+// writeCommands writes a shell line per process, prefixed with run
+func writeCommands(b *bytes.Buffer, run string, processes []api.Process) {
+	for _, p := range processes {
+		fmt.Fprintf(b, "echo %s\n", shellQuote(p.Name))
+		var args []string
+		if len(p.Env) != 0 {
+			args = append(args, "env")
+		}
+		for _, e := range append(append([]string{}, p.Env...), p.Args...) {
+			args = append(args, shellQuote(e))
+		}
+		line := run + strings.Join(args, " ")
+		if len(p.Dir) != 0 {
+			line = fmt.Sprintf("(cd %s && %s)", shellQuote(p.Dir), line)
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+func upScript(format string, phases []*exportPhase) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("#!/usr/bin/env bash\n# Starts the services, the networks are bootstrapped the first time only.\nset -euo pipefail\ncd \"$(dirname \"$0\")\"\n")
+	for _, phase := range phases {
+		if len(phase.services) != 0 {
+			fmt.Fprintf(b, "\n# %s\n%s\n", phase.name, startCommand(format, phase))
+		}
+		if len(phase.setup) != 0 {
+			marker := setupDoneFile + "." + phase.name
+			fmt.Fprintf(b, "if [ ! -f %s ]; then\n  ./setup-%s.sh\n  touch %s\nfi\n", marker, phase.name, marker)
+		}
+	}
+	return b.Bytes()
+}
+
+func downScript(format string, phases []*exportPhase) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("#!/usr/bin/env bash\n# Stops the services, the ledgers are kept.\nset -uo pipefail\ncd \"$(dirname \"$0\")\"\n\n")
+	// the FSC nodes are stopped first
+	for i := len(phases) - 1; i >= 0; i-- {
+		if len(phases[i].services) == 0 {
+			continue
+		}
+		switch format {
+		case ComposeFormat:
+			fmt.Fprintf(b, "docker compose rm -sf %s\n", strings.Join(serviceNames(phases[i]), " "))
+		case KubernetesFormat:
+			fmt.Fprintf(b, "kubectl delete --ignore-not-found -f kubernetes/%s.yaml\n", phases[i].name)
+		}
+	}
+	for _, phase := range phases {
+		writeCommands(b, "", phase.teardown)
+	}
+	return b.Bytes()
+}
+
+func startCommand(format string, phase *exportPhase) string {
+	if format == KubernetesFormat {
+		return fmt.Sprintf("kubectl apply -f kubernetes/%s.yaml", phase.name)
+	}
+	return "docker compose up -d " + strings.Join(serviceNames(phase), " ")
+}
+
+func serviceNames(phase *exportPhase) []string {
+	var res []string
+	for _, s := range phase.services {
+		res = append(res, s.name)
+	}
+	return res
+}
+
+// serviceName returns the passed name as a valid compose service and Kubernetes pod name
+func serviceName(name string) string {
+	name = strings.Trim(invalidServiceChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+func dedup(values []string) []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, v := range values {
+		if !seen[v] && len(v) != 0 {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
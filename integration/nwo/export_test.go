@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package nwo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/tedsuo/ifrit/grouper"
+	"gopkg.in/yaml.v2"
+)
+
+type exportablePlatform struct {
+	typ      string
+	services []api.Process
+	setup    []api.Process
+}
+
+func (p *exportablePlatform) Name() string              { return p.typ }
+func (p *exportablePlatform) Type() string              { return p.typ }
+func (p *exportablePlatform) GenerateConfigTree()       {}
+func (p *exportablePlatform) GenerateArtifacts()        {}
+func (p *exportablePlatform) Load()                     {}
+func (p *exportablePlatform) Members() []grouper.Member { return nil }
+func (p *exportablePlatform) PostRun(bool)              {}
+func (p *exportablePlatform) Cleanup()                  {}
+func (p *exportablePlatform) Services() []api.Process   { return p.services }
+func (p *exportablePlatform) Setup() []api.Process      { return p.setup }
+func (p *exportablePlatform) Teardown() []api.Process   { return nil }
+
+func TestExport(t *testing.T) {
+	root := t.TempDir()
+	bins := t.TempDir()
+	for _, name := range []string{"peer", "orderer"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(bins, name), []byte(name), 0755))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(bins, "fsc"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(bins, "fsc", "peer"), []byte("fsc"), 0755))
+
+	fabric := &exportablePlatform{
+		typ: "fabric",
+		services: []api.Process{
+			{Name: "default-OrdererOrg.orderer", Args: []string{filepath.Join(bins, "orderer")}, Env: []string{"FABRIC_CFG_PATH=" + root}},
+			{Name: "default-Org1.peer0", Args: []string{filepath.Join(bins, "peer"), "node", "start"}, Volumes: []string{"/var/run/docker.sock"}},
+		},
+		setup: []api.Process{{Name: "channel-create", Args: []string{filepath.Join(bins, "peer"), "channel", "create", "-c", "it's"}}},
+	}
+	fsc := &exportablePlatform{
+		typ:      "fsc",
+		services: []api.Process{{Name: "alice", Args: []string{filepath.Join(bins, "fsc", "peer"), "node", "start"}, Env: []string{"FSCNODE_CFG_PATH=$HOME"}}},
+	}
+	n := New(context.New(root, 20000, nil), fsc, fabric)
+
+	assert.EqualError(t, n.Export(ExportOptions{Format: "helm"}), "unknown export format [helm], expected compose or kubernetes")
+	assert.NoError(t, n.Export(ExportOptions{}))
+
+	// the executables are copied, the names clashing get a suffix
+	peer, err := os.ReadFile(filepath.Join(root, "bin", "peer"))
+	assert.NoError(t, err)
+	assert.Equal(t, "peer", string(peer))
+	peer, err = os.ReadFile(filepath.Join(root, "bin", "peer-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "fsc", string(peer))
+
+	raw, err := os.ReadFile(filepath.Join(root, "docker-compose.yaml"))
+	assert.NoError(t, err)
+	compose := map[string]map[string]composeService{}
+	assert.NoError(t, yaml.Unmarshal(raw, &compose))
+	assert.Len(t, compose["services"], 3)
+	assert.Equal(t, composeService{
+		Image:       DefaultExportImage,
+		NetworkMode: "host",
+		Entrypoint:  []string{filepath.Join(root, "bin", "peer"), "node", "start"},
+		Volumes:     []string{root + ":" + root, "/var/run/docker.sock:/var/run/docker.sock"},
+		Restart:     "unless-stopped",
+	}, compose["services"]["default-org1-peer0"])
+	assert.Equal(t, []string{"FSCNODE_CFG_PATH=$$HOME"}, compose["services"]["alice"].Environment)
+
+	// the networks are started and bootstrapped before the FSC nodes
+	up, err := os.ReadFile(filepath.Join(root, "up.sh"))
+	assert.NoError(t, err)
+	networks := strings.Index(string(up), "docker compose up -d default-ordererorg-orderer default-org1-peer0")
+	setup := strings.Index(string(up), "./setup-networks.sh")
+	nodes := strings.Index(string(up), "docker compose up -d alice")
+	assert.True(t, networks >= 0 && networks < setup && setup < nodes, string(up))
+	setupScript, err := os.ReadFile(filepath.Join(root, "setup-networks.sh"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(setupScript), "retry '"+filepath.Join(root, "bin", "peer")+"' 'channel' 'create' '-c' 'it'\\''s'")
+	_, err = os.Stat(filepath.Join(root, "setup-fsc.sh"))
+	assert.True(t, os.IsNotExist(err))
+
+	// the same networks give the same files
+	assert.NoError(t, n.Export(ExportOptions{Format: KubernetesFormat}))
+	first, err := os.ReadFile(filepath.Join(root, "kubernetes", "networks.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(first), "hostNetwork: true")
+	assert.NoError(t, n.Export(ExportOptions{Format: KubernetesFormat}))
+	second, err := os.ReadFile(filepath.Join(root, "kubernetes", "networks.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+}
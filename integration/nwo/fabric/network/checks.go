@@ -29,6 +29,7 @@ func (n *Network) CheckTopology() {
 	n.CheckTopologyOrderers()
 	n.CheckTopologyOrgs(n.CheckTopologyFSCNodes())
 	n.CheckTopologyFabricPeers()
+	n.CheckTopologyFaultRoutes()
 	n.CheckTopologyExtensions()
 }
 
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/packager"
@@ -35,7 +36,14 @@ func PackageAndInstallChaincode(n *Network, chaincode *topology.Chaincode, peers
 		chaincode.PackageFile = tempFile.Name()
 	}
 
-	// only create chaincode package if it doesn't already exist
+	ensureChaincodePackage(chaincode)
+
+	// install on all peers
+	InstallChaincode(n, chaincode, peers...)
+}
+
+// ensureChaincodePackage creates the chaincode package, if it doesn't already exist
+func ensureChaincodePackage(chaincode *topology.Chaincode) {
 	if _, err := os.Stat(chaincode.PackageFile); os.IsNotExist(err) {
 		switch chaincode.Lang {
 		case "binary":
@@ -46,9 +54,6 @@ func PackageAndInstallChaincode(n *Network, chaincode *topology.Chaincode, peers
 			Expect(err).NotTo(HaveOccurred())
 		}
 	}
-
-	// install on all peers
-	InstallChaincode(n, chaincode, peers...)
 }
 
 func PackageChaincode(n *Network, chaincode *topology.Chaincode, peer *topology.Peer) {
@@ -82,6 +87,45 @@ func InstallChaincode(n *Network, chaincode *topology.Chaincode, peers ...*topol
 	}
 }
 
+// UseChaincodePackage makes the passed chaincode use the passed package, built in memory with packager.Build.
+// The package is written to the package file of the chaincode, one in the network directory if not set,
+// and the label and the package ID of the chaincode are the ones of the package.
+// InstallChaincode, ApproveChaincodeForMyOrg and CommitChaincode then deploy the package.
+func UseChaincodePackage(n *Network, chaincode *topology.Chaincode, pkg *packager.ChaincodePackage) {
+	if chaincode.PackageFile == "" {
+		chaincode.PackageFile = packageFile(n, pkg)
+	}
+	Expect(pkg.WriteFile(chaincode.PackageFile)).To(Succeed())
+	chaincode.Label = pkg.Metadata.Label
+	chaincode.PackageID = pkg.ID()
+}
+
+// InstallChaincodePackage installs the passed package, built in memory, on the passed peers, see UseChaincodePackage
+func InstallChaincodePackage(n *Network, chaincode *topology.Chaincode, pkg *packager.ChaincodePackage, peers ...*topology.Peer) {
+	UseChaincodePackage(n, chaincode, pkg)
+	InstallChaincode(n, chaincode, peers...)
+}
+
+// InstallChaincodePackageSession starts the installation of the passed package, built in memory, on the passed peer,
+// and returns its session without waiting for it. The tests expecting the peer to reject a crafted package
+// check the exit code and the output of the session.
+func InstallChaincodePackageSession(n *Network, pkg *packager.ChaincodePackage, peer *topology.Peer) (*gexec.Session, error) {
+	file := packageFile(n, pkg)
+	if err := pkg.WriteFile(file); err != nil {
+		return nil, err
+	}
+	return n.PeerAdminSession(peer, commands.ChaincodeInstall{
+		NetworkPrefix: n.Prefix,
+		PackageFile:   file,
+		ClientAuth:    n.ClientAuthRequired,
+	})
+}
+
+// packageFile returns the file, in the network directory, the passed package is written to
+func packageFile(n *Network, pkg *packager.ChaincodePackage) string {
+	return filepath.Join(n.Context.RootDir(), n.Prefix, "packages", pkg.Hash()+".tar.gz")
+}
+
 func ApproveChaincodeForMyOrg(n *Network, channel string, orderer *topology.Orderer, chaincode *topology.Chaincode, peers ...*topology.Peer) {
 	if chaincode.PackageID == "" {
 		chaincode.SetPackageIDFromPackageFile()
@@ -0,0 +1,242 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/commands"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/topology"
+	. "github.com/onsi/gomega"
+)
+
+// dockerSocket is where the peers reach the docker daemon launching the chaincode containers, see the vm section of core.yaml
+const dockerSocket = "/var/run/docker.sock"
+
+// ExportDir returns the directory of the files used by the exported setup commands
+func (n *Network) ExportDir() string {
+	return filepath.Join(n.Context.RootDir(), n.Prefix, "export")
+}
+
+// Services returns the processes of the orderers and of the peers, as OrdererRunner and PeerRunner start them
+func (n *Network) Services() []api.Process {
+	var res []api.Process
+	for _, o := range n.Orderers {
+		res = append(res, api.NewProcess(n.Prefix+"-"+o.ID(), n.OrdererRunner(o).Command))
+	}
+	for _, p := range n.Peers {
+		if p.Type != topology.FabricPeer {
+			continue
+		}
+		volumes := []string{dockerSocket}
+		for _, builder := range n.ExternalBuilders {
+			volumes = append(volumes, builder.Path)
+		}
+		res = append(res, api.NewProcess(n.Prefix+"-"+p.ID(), n.PeerRunner(p).Command, volumes...))
+	}
+	return res
+}
+
+// Setup returns the commands PostRun executes the first time the network is started:
+// the channels are created and joined, the anchor peers updated, and the chaincodes deployed.
+// The chaincode packages are created by Setup itself, the private chaincodes are not deployed.
+func (n *Network) Setup() []api.Process {
+	Expect(os.MkdirAll(n.ExportDir(), 0755)).NotTo(HaveOccurred())
+
+	var res []api.Process
+	orderer := n.Orderer("orderer")
+	for _, channel := range n.Channels {
+		res = append(res, n.channelSetup(orderer, channel.Name)...)
+	}
+	for _, chaincode := range n.topology.Chaincodes {
+		for _, ccp := range n.ccps {
+			chaincode = ccp.Process(n, chaincode)
+		}
+		if chaincode.Private {
+			logger.Warnf("private chaincode [%s] cannot be exported, skipping it", chaincode.Chaincode.Name)
+			continue
+		}
+		res = append(res, n.chaincodeSetup(orderer, chaincode)...)
+	}
+	return res
+}
+
+// Teardown returns the command removing the chaincode containers launched by the peers
+func (n *Network) Teardown() []api.Process {
+	// the network id is the one of the peers' configuration, it might have been generated by a previous run
+	networkID := n.NetworkID
+	for _, p := range n.Peers {
+		if p.Type == topology.FabricPeer {
+			networkID = n.ReadPeerConfig(p).Peer.NetworkID
+			break
+		}
+	}
+	return []api.Process{{
+		Name: n.Prefix + "-chaincodes",
+		Args: []string{"sh", "-c", fmt.Sprintf("docker ps -aq --filter name=^/%s- | xargs -r docker rm -f", networkID)},
+	}}
+}
+
+// channelSetup returns the commands of CreateAndJoinChannel and UpdateChannelAnchors
+func (n *Network) channelSetup(o *topology.Orderer, channelName string) []api.Process {
+	peers := n.PeersWithChannel(channelName)
+	if len(peers) == 0 {
+		return nil
+	}
+
+	genesisBlock := filepath.Join(n.ExportDir(), channelName+"_genesis.block")
+	res := []api.Process{
+		n.peerAdminProcess(peers[0], commands.ChannelCreate{
+			NetworkPrefix: n.Prefix,
+			ChannelID:     channelName,
+			Orderer:       n.OrdererAddress(o, ListenPort),
+			File:          n.CreateChannelTxPath(channelName),
+			OutputBlock:   "/dev/null",
+			ClientAuth:    n.ClientAuthRequired,
+		}),
+		n.peerAdminProcess(peers[0], commands.ChannelFetch{
+			NetworkPrefix: n.Prefix,
+			Block:         "0",
+			ChannelID:     channelName,
+			Orderer:       n.OrdererAddress(o, ListenPort),
+			OutputFile:    genesisBlock,
+			ClientAuth:    n.ClientAuthRequired,
+		}),
+	}
+	for _, p := range peers {
+		res = append(res, n.peerAdminProcess(p, commands.ChannelJoin{
+			NetworkPrefix: n.Prefix,
+			BlockPath:     genesisBlock,
+			ClientAuth:    n.ClientAuthRequired,
+		}))
+	}
+
+	peersByOrg := map[string]*topology.Peer{}
+	var orgs []string
+	for _, p := range n.AnchorsForChannel(channelName) {
+		if _, ok := peersByOrg[p.Organization]; !ok {
+			orgs = append(orgs, p.Organization)
+		}
+		peersByOrg[p.Organization] = p
+	}
+	for _, orgName := range orgs {
+		anchorsUpdate := filepath.Join(n.ExportDir(), fmt.Sprintf("%s_%s_anchors.tx", channelName, orgName))
+		anchorUpdate := commands.OutputAnchorPeersUpdate{
+			NetworkPrefix:           n.Prefix,
+			OutputAnchorPeersUpdate: anchorsUpdate,
+			ChannelID:               channelName,
+			Profile:                 n.ProfileForChannel(channelName),
+			ConfigPath:              filepath.Join(n.Context.RootDir(), n.Prefix),
+			AsOrg:                   orgName,
+		}
+		cmdPath := findCmdAtEnv(configtxgenCMD)
+		Expect(cmdPath).NotTo(Equal(""), "could not find %s in %s directory %s", configtxgenCMD, FabricBinsPathEnvKey, os.Getenv(FabricBinsPathEnvKey))
+		res = append(res,
+			api.NewProcess(anchorUpdate.SessionName(), common.NewCommand(cmdPath, anchorUpdate)),
+			n.peerAdminProcess(peersByOrg[orgName], commands.ChannelUpdate{
+				NetworkPrefix: n.Prefix,
+				ChannelID:     channelName,
+				Orderer:       n.OrdererAddress(o, ListenPort),
+				File:          anchorsUpdate,
+				ClientAuth:    n.ClientAuthRequired,
+			}),
+		)
+	}
+	return res
+}
+
+// chaincodeSetup packages the chaincode and returns the commands of DeployChaincode
+func (n *Network) chaincodeSetup(orderer *topology.Orderer, chaincode *topology.ChannelChaincode) []api.Process {
+	peers := n.PeersByName(chaincode.Peers)
+
+	if len(chaincode.Chaincode.PackageFile) == 0 {
+		if len(chaincode.Path) != 0 {
+			chaincode.Chaincode.Path = n.Builder.Build(chaincode.Path)
+			chaincode.Chaincode.Lang = "binary"
+		}
+		chaincode.Chaincode.PackageFile = filepath.Join(n.Context.RootDir(), n.Prefix, chaincode.Chaincode.Name+chaincode.Chaincode.Version+".tar.gz")
+	}
+	ensureChaincodePackage(&chaincode.Chaincode)
+	if chaincode.Chaincode.PackageID == "" {
+		chaincode.Chaincode.SetPackageIDFromPackageFile()
+	}
+
+	var res []api.Process
+	for _, p := range peers {
+		res = append(res, n.peerAdminProcess(p, commands.ChaincodeInstall{
+			NetworkPrefix: n.Prefix,
+			PackageFile:   chaincode.Chaincode.PackageFile,
+			ClientAuth:    n.ClientAuthRequired,
+		}))
+	}
+
+	// approve once per org, commit and init using one peer per org
+	orgs := map[string]bool{}
+	var peerAddresses []string
+	for _, p := range peers {
+		if orgs[p.Organization] {
+			continue
+		}
+		orgs[p.Organization] = true
+		peerAddresses = append(peerAddresses, n.PeerAddress(p, ListenPort))
+		res = append(res, n.peerAdminProcess(p, commands.ChaincodeApproveForMyOrg{
+			NetworkPrefix:       n.Prefix,
+			ChannelID:           chaincode.Channel,
+			Orderer:             n.OrdererAddress(orderer, ListenPort),
+			Name:                chaincode.Chaincode.Name,
+			Version:             chaincode.Chaincode.Version,
+			PackageID:           chaincode.Chaincode.PackageID,
+			Sequence:            chaincode.Chaincode.Sequence,
+			EndorsementPlugin:   chaincode.Chaincode.EndorsementPlugin,
+			ValidationPlugin:    chaincode.Chaincode.ValidationPlugin,
+			SignaturePolicy:     chaincode.Chaincode.SignaturePolicy,
+			ChannelConfigPolicy: chaincode.Chaincode.ChannelConfigPolicy,
+			InitRequired:        chaincode.Chaincode.InitRequired,
+			CollectionsConfig:   chaincode.Chaincode.CollectionsConfig,
+			ClientAuth:          n.ClientAuthRequired,
+		}))
+	}
+	res = append(res, n.peerAdminProcess(peers[0], commands.ChaincodeCommit{
+		NetworkPrefix:       n.Prefix,
+		ChannelID:           chaincode.Channel,
+		Orderer:             n.OrdererAddress(orderer, ListenPort),
+		Name:                chaincode.Chaincode.Name,
+		Version:             chaincode.Chaincode.Version,
+		Sequence:            chaincode.Chaincode.Sequence,
+		EndorsementPlugin:   chaincode.Chaincode.EndorsementPlugin,
+		ValidationPlugin:    chaincode.Chaincode.ValidationPlugin,
+		SignaturePolicy:     chaincode.Chaincode.SignaturePolicy,
+		ChannelConfigPolicy: chaincode.Chaincode.ChannelConfigPolicy,
+		InitRequired:        chaincode.Chaincode.InitRequired,
+		CollectionsConfig:   chaincode.Chaincode.CollectionsConfig,
+		PeerAddresses:       peerAddresses,
+		ClientAuth:          n.ClientAuthRequired,
+	}))
+	if chaincode.Chaincode.InitRequired {
+		command := commands.ChaincodeInvoke{
+			NetworkPrefix: n.Prefix,
+			ChannelID:     chaincode.Channel,
+			Orderer:       n.OrdererAddress(orderer, ListenPort),
+			Name:          chaincode.Chaincode.Name,
+			Ctor:          chaincode.Chaincode.Ctor,
+			PeerAddresses: peerAddresses,
+			WaitForEvent:  true,
+			IsInit:        true,
+			ClientAuth:    n.ClientAuthRequired,
+		}
+		res = append(res, api.NewProcess(command.SessionName(), n.peerUserCommand(peers[0], "User1", command)))
+	}
+	return res
+}
+
+func (n *Network) peerAdminProcess(p *topology.Peer, command common.Command) api.Process {
+	return api.NewProcess(command.SessionName(), n.peerUserCommand(p, "Admin", command))
+}
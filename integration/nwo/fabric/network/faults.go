@@ -0,0 +1,100 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package network
+
+import (
+	"fmt"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/faults"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/topology"
+	. "github.com/onsi/gomega"
+)
+
+// faultTarget is a peer or an orderer the FSC nodes connect to
+type faultTarget struct {
+	ID      string
+	Address string
+}
+
+// CheckTopologyFaultRoutes reserves, for each FSC node, the ports of the proxies of its connections to the peers
+// and to the orderers, if the fault injection is enabled
+func (n *Network) CheckTopologyFaultRoutes() {
+	if !n.topology.FaultInjection {
+		return
+	}
+	for _, p := range n.Peers {
+		if p.Type != topology.FSCPeer {
+			continue
+		}
+		ports := api.Ports{}
+		for _, target := range n.faultTargets() {
+			ports[api.PortName(target.ID)] = n.Context.ReservePort()
+		}
+		n.Context.SetPortsByPeerID(n.Prefix, faultsID(p), ports)
+	}
+}
+
+// FaultRoutes returns the routes of the connections of the FSC nodes to the peers and to the orderers,
+// empty if the fault injection is not enabled
+func (n *Network) FaultRoutes() []faults.Route {
+	var routes []faults.Route
+	for _, p := range n.Peers {
+		if p.Type == topology.FSCPeer {
+			routes = append(routes, n.faultRoutesOf(p)...)
+		}
+	}
+	return routes
+}
+
+// FaultProcesses returns the processes of the peers and of the orderers started, by identifier
+func (n *Network) FaultProcesses() map[string]faults.Process {
+	processes := map[string]faults.Process{}
+	for id, r := range n.runners {
+		if r.Command.Process != nil {
+			processes[id] = r
+		}
+	}
+	return processes
+}
+
+// faultRoutesOf returns the routes of the connections of the passed FSC node
+func (n *Network) faultRoutesOf(p *topology.Peer) []faults.Route {
+	if !n.topology.FaultInjection {
+		return nil
+	}
+	ports := n.Context.PortsByPeerID(n.Prefix, faultsID(p))
+	Expect(ports).NotTo(BeNil(), "expected proxy ports to be initialized [%s]", p.ID())
+	var routes []faults.Route
+	for _, target := range n.faultTargets() {
+		routes = append(routes, faults.Route{
+			Source:  p.ID(),
+			Target:  target.ID,
+			Listen:  fmt.Sprintf("127.0.0.1:%d", ports[api.PortName(target.ID)]),
+			Address: target.Address,
+		})
+	}
+	return routes
+}
+
+// faultTargets returns the orderers and the fabric peers, in the order their proxy ports are reserved
+func (n *Network) faultTargets() []faultTarget {
+	var targets []faultTarget
+	for _, o := range n.Orderers {
+		targets = append(targets, faultTarget{ID: o.ID(), Address: n.OrdererAddress(o, ListenPort)})
+	}
+	for _, p := range n.Peers {
+		if p.Type == topology.FabricPeer {
+			targets = append(targets, faultTarget{ID: p.ID(), Address: n.PeerAddress(p, ListenPort)})
+		}
+	}
+	return targets
+}
+
+func faultsID(p *topology.Peer) string {
+	return p.ID() + ".faults"
+}
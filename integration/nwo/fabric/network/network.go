@@ -7,11 +7,14 @@ SPDX-License-Identifier: Apache-2.0
 package network
 
 import (
+	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
+	runner2 "github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/runner"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/commands"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/fabricconfig"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/topology"
@@ -69,6 +72,8 @@ type Network struct {
 
 	colorIndex uint
 	ccps       []ChaincodeProcessor
+	// runners are the runners of the peers and of the orderers, by identifier
+	runners map[string]*runner2.Runner
 }
 
 func New(reg api.Context, topology *topology.Topology, builderClient BuilderClient, ccps []ChaincodeProcessor, NetworkID string) *Network {
@@ -106,6 +111,7 @@ func New(reg api.Context, topology *topology.Topology, builderClient BuilderClie
 		PvtTxCCSupport:    topology.PvtTxCCSupport,
 		ccps:              ccps,
 		Extensions:        []Extension{},
+		runners:           map[string]*runner2.Runner{},
 	}
 	return network
 }
@@ -127,29 +133,51 @@ func (n *Network) GenerateConfigTree() {
 }
 
 func (n *Network) GenerateArtifacts() {
-	sess, err := n.Cryptogen(commands.Generate{
-		NetworkPrefix: n.Prefix,
-		Config:        n.CryptoConfigPath(),
-		Output:        n.CryptoPath(),
-	})
-	Expect(err).NotTo(HaveOccurred())
-	Eventually(sess, n.EventuallyTimeout).Should(gexec.Exit(0))
-	n.bootstrapIdemix()
-	n.bootstrapExtraIdentities()
-
-	if len(n.SystemChannel.Name) != 0 {
-		sess, err = n.ConfigTxGen(commands.OutputBlock{
+	// the artifacts generated by a previous run are reused if they have been generated from the same inputs
+	if n.artifactUpToDate(n.CryptoPath(), n.CryptoConfigPath()) {
+		logger.Infof("reusing crypto material at [%s]", n.CryptoPath())
+	} else {
+		Expect(os.RemoveAll(n.CryptoPath())).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(n.CryptoPath(), 0770)).NotTo(HaveOccurred())
+		sess, err := n.Cryptogen(commands.Generate{
 			NetworkPrefix: n.Prefix,
-			ChannelID:     n.SystemChannel.Name,
-			Profile:       n.SystemChannel.Profile,
-			ConfigPath:    filepath.Join(n.Context.RootDir(), n.Prefix),
-			OutputBlock:   n.OutputBlockPath(n.SystemChannel.Name),
+			Config:        n.CryptoConfigPath(),
+			Output:        n.CryptoPath(),
 		})
 		Expect(err).NotTo(HaveOccurred())
 		Eventually(sess, n.EventuallyTimeout).Should(gexec.Exit(0))
+		n.bootstrapIdemix()
+		n.bootstrapExtraIdentities()
+		common.RecordArtifact(n.CryptoPath(), n.CryptoConfigPath())
+	}
+	// the channel artifacts depend on the crypto material, regenerated crypto material comes with new CAs
+	channelInputs := n.ListTLSCACertificates()
+	if len(n.Channels) != 0 {
+		channelInputs = append(channelInputs, n.ConfigTxConfigPath())
+	}
+
+	if len(n.SystemChannel.Name) != 0 {
+		if n.artifactUpToDate(n.OutputBlockPath(n.SystemChannel.Name), channelInputs...) {
+			logger.Infof("reusing genesis block of [%s]", n.SystemChannel.Name)
+		} else {
+			sess, err := n.ConfigTxGen(commands.OutputBlock{
+				NetworkPrefix: n.Prefix,
+				ChannelID:     n.SystemChannel.Name,
+				Profile:       n.SystemChannel.Profile,
+				ConfigPath:    filepath.Join(n.Context.RootDir(), n.Prefix),
+				OutputBlock:   n.OutputBlockPath(n.SystemChannel.Name),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(sess, n.EventuallyTimeout).Should(gexec.Exit(0))
+			common.RecordArtifact(n.OutputBlockPath(n.SystemChannel.Name), channelInputs...)
+		}
 	}
 
 	for _, c := range n.Channels {
+		if n.artifactUpToDate(n.CreateChannelTxPath(c.Name), channelInputs...) {
+			logger.Infof("reusing create channel transaction of [%s]", c.Name)
+			continue
+		}
 		sess, err := n.ConfigTxGen(commands.CreateChannelTx{
 			NetworkPrefix:         n.Prefix,
 			ChannelID:             c.Name,
@@ -160,6 +188,7 @@ func (n *Network) GenerateArtifacts() {
 		})
 		Expect(err).NotTo(HaveOccurred())
 		Eventually(sess, n.EventuallyTimeout).Should(gexec.Exit(0))
+		common.RecordArtifact(n.CreateChannelTxPath(c.Name), channelInputs...)
 	}
 
 	n.ConcatenateTLSCACertificates()
@@ -177,6 +206,12 @@ func (n *Network) GenerateArtifacts() {
 	}
 }
 
+// artifactUpToDate returns true if the passed artifact has been generated from the passed inputs,
+// or if it has been generated by a previous version and the context adopts such artifacts
+func (n *Network) artifactUpToDate(artifact string, inputs ...string) bool {
+	return common.ArtifactUpToDate(artifact, inputs...) || n.Context.AdoptArtifacts() && common.AdoptArtifact(artifact, inputs...)
+}
+
 func (n *Network) Load() {
 }
 
@@ -22,6 +22,7 @@ import (
 
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/faults"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/pkcs11"
 	runner2 "github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/runner"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/commands"
@@ -916,7 +917,9 @@ func (n *Network) OrdererRunner(o *topology.Orderer) *runner2.Runner {
 func (n *Network) OrdererGroupRunner() ifrit.Runner {
 	members := grouper.Members{}
 	for _, o := range n.Orderers {
-		members = append(members, grouper.Member{Name: o.ID(), Runner: n.OrdererRunner(o)})
+		r := n.OrdererRunner(o)
+		n.runners[o.ID()] = r
+		members = append(members, grouper.Member{Name: o.ID(), Runner: r})
 	}
 	if len(members) == 0 {
 		return nil
@@ -992,7 +995,9 @@ func (n *Network) PeerGroupRunner() ifrit.Runner {
 	for _, p := range n.Peers {
 		switch {
 		case p.Type == topology.FabricPeer:
-			members = append(members, grouper.Member{Name: p.ID(), Runner: n.PeerRunner(p)})
+			r := n.PeerRunner(p)
+			n.runners[p.ID()] = r
+			members = append(members, grouper.Member{Name: p.ID(), Runner: r})
 		}
 	}
 	if len(members) == 0 {
@@ -1064,14 +1069,17 @@ func (n *Network) PeerAdminSession(p *topology.Peer, command common.Command) (*g
 // command. This is intended to be used by short running peer fsccli commands that
 // execute in the context of a peer configuration.
 func (n *Network) PeerUserSession(p *topology.Peer, user string, command common.Command) (*gexec.Session, error) {
-	cmd := n.peerCommand(
+	return n.StartSession(n.peerUserCommand(p, user, command), command.SessionName())
+}
+
+func (n *Network) peerUserCommand(p *topology.Peer, user string, command common.Command) *exec.Cmd {
+	return n.peerCommand(
 		p.ExecutablePath,
 		command,
 		n.PeerUserTLSDir(p, user),
 		fmt.Sprintf("FABRIC_CFG_PATH=%s", n.PeerDir(p)),
 		fmt.Sprintf("CORE_PEER_MSPCONFIGPATH=%s", n.PeerUserMSPDir(p, user)),
 	)
-	return n.StartSession(cmd, command.SessionName())
 }
 
 // OrdererAdminSession starts a gexec.Session as an orderer admin user. This
@@ -1591,6 +1599,7 @@ func (n *Network) GenerateCoreConfig(p *topology.Peer) {
 			"FabricName":                  func() string { return n.topology.Name() },
 			"DefaultNetwork":              func() bool { return defaultNetwork },
 			"Chaincodes":                  func(channel string) []*topology.ChannelChaincode { return n.Chaincodes(channel) },
+			"AddressOverrides":            func() []faults.Route { return n.faultRoutesOf(p) },
 		}).Parse(coreTemplate)
 		Expect(err).NotTo(HaveOccurred())
 
@@ -1658,12 +1667,19 @@ func GetLinkedIdentities(peer *topology.Peer) []string {
 	return v.([]string)
 }
 
+// GetPersistenceType returns the driver of the vault of the passed node: orion, if the node has an orion vault,
+// the driver set with the fabric.vault.driver option, memory in fast test mode, see FastTestEnvKey, badger otherwise
 func GetPersistenceType(peer *topology.Peer) string {
-	v := peer.FSCNode.Options.Get("fabric.vault.persistence.orion")
-	if v == nil {
-		return "badger"
+	if v := peer.FSCNode.Options.Get("fabric.vault.persistence.orion"); v != nil {
+		return "orion"
+	}
+	if v := peer.FSCNode.Options.Get("fabric.vault.driver"); v != nil {
+		return v.(string)
+	}
+	if FastTest() {
+		return "memory"
 	}
-	return "orion"
+	return "badger"
 }
 
 func GetVaultPersistenceOrionNetwork(peer *topology.Peer) string {
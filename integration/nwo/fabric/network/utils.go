@@ -9,8 +9,13 @@ package network
 import (
 	"os"
 	"path"
+	"strconv"
 )
 
+// FastTestEnvKey selects the fast test mode, if true: the vaults of the FSC nodes are kept in memory,
+// unless their driver is set explicitly, see fabric.WithVaultDriver
+const FastTestEnvKey = "FSC_FAST_TEST"
+
 const (
 	FabricBinsPathEnvKey = "FAB_BINS"
 	configtxgenCMD       = "configtxgen"
@@ -24,6 +29,12 @@ const (
 	peerCMD              = "peer"
 )
 
+// FastTest returns true if the fast test mode is selected by FastTestEnvKey
+func FastTest() bool {
+	fast, err := strconv.ParseBool(os.Getenv(FastTestEnvKey))
+	return err == nil && fast
+}
+
 func pathExists(path string) bool {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return false
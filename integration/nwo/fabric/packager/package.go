@@ -0,0 +1,212 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package packager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+	"github.com/pkg/errors"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/packager/replacer"
+)
+
+// ChaincodePackage is a chaincode package, in the format used by _lifecycle, built in memory
+type ChaincodePackage struct {
+	// Metadata is the content of the metadata.json of the package
+	Metadata PackageMetadata
+	// Bytes is the package, a tar.gz of metadata.json and code.tar.gz
+	Bytes []byte
+}
+
+// ID returns the package ID the peers assign to the package: its label and the hash of its bytes.
+// If the metadata has been replaced with WithRawMetadata, the label is the one of Metadata, the peers
+// might compute a different one.
+func (c *ChaincodePackage) ID() string {
+	return persistence.PackageID(c.Metadata.Label, c.Bytes)
+}
+
+// Hash returns the hex encoded SHA-256 hash of the package
+func (c *ChaincodePackage) Hash() string {
+	hash := sha256.Sum256(c.Bytes)
+	return hex.EncodeToString(hash[:])
+}
+
+// WriteFile writes the package to the passed file, creating its directory if needed
+func (c *ChaincodePackage) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "failed creating directory of chaincode package [%s]", path)
+	}
+	if err := ioutil.WriteFile(path, c.Bytes, 0644); err != nil {
+		return errors.Wrapf(err, "failed writing chaincode package [%s]", path)
+	}
+	return nil
+}
+
+type entry struct {
+	name    string
+	payload []byte
+}
+
+type buildOptions struct {
+	replacer     replacer.Func
+	metadata     func(*PackageMetadata)
+	rawMetadata  []byte
+	code         []byte
+	omit         map[string]bool
+	extraEntries []entry
+}
+
+// Option customizes the package built by Build.
+// The options altering the content of the package let the tests craft packages the peers must reject.
+type Option func(*buildOptions)
+
+// WithReplacer sets the replacer applied to the sources of the chaincode
+func WithReplacer(replacer replacer.Func) Option {
+	return func(o *buildOptions) {
+		o.replacer = replacer
+	}
+}
+
+// WithCode sets the content of code.tar.gz, the sources of the chaincode are not packaged
+func WithCode(code []byte) Option {
+	return func(o *buildOptions) {
+		o.code = code
+	}
+}
+
+// WithMetadataLabel writes the passed label in metadata.json, instead of the one passed to Build. It is not validated.
+func WithMetadataLabel(label string) Option {
+	return withMetadata(func(m *PackageMetadata) { m.Label = label })
+}
+
+// WithMetadataType writes the passed chaincode type in metadata.json, instead of the one passed to Build
+func WithMetadataType(typ string) Option {
+	return withMetadata(func(m *PackageMetadata) { m.Type = typ })
+}
+
+// WithMetadataPath writes the passed path in metadata.json, instead of the normalized one passed to Build
+func WithMetadataPath(path string) Option {
+	return withMetadata(func(m *PackageMetadata) { m.Path = path })
+}
+
+// WithRawMetadata sets the content of metadata.json, like a malformed document
+func WithRawMetadata(raw []byte) Option {
+	return func(o *buildOptions) {
+		o.rawMetadata = raw
+	}
+}
+
+// WithoutEntry omits the passed entry, metadata.json or code.tar.gz, from the package
+func WithoutEntry(name string) Option {
+	return func(o *buildOptions) {
+		if o.omit == nil {
+			o.omit = map[string]bool{}
+		}
+		o.omit[name] = true
+	}
+}
+
+// WithExtraEntry adds an entry to the package, after metadata.json and code.tar.gz
+func WithExtraEntry(name string, payload []byte) Option {
+	return func(o *buildOptions) {
+		o.extraEntries = append(o.extraEntries, entry{name: name, payload: payload})
+	}
+}
+
+func withMetadata(f func(*PackageMetadata)) Option {
+	return func(o *buildOptions) {
+		previous := o.metadata
+		o.metadata = func(m *PackageMetadata) {
+			if previous != nil {
+				previous(m)
+			}
+			f(m)
+		}
+	}
+}
+
+// Build builds in memory the package of the chaincode of the passed type at the passed path, with the passed label.
+// The package ID of the result is the one computed by the peers installing it.
+func (p *Packager) Build(path, typ, label string, opts ...Option) (*ChaincodePackage, error) {
+	o := &buildOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if path == "" {
+		return nil, errors.New("chaincode path must be specified")
+	}
+	if typ == "" {
+		return nil, errors.New("chaincode language must be specified")
+	}
+	if label == "" {
+		return nil, errors.New("package label must be specified")
+	}
+	if err := persistence.ValidateLabel(label); err != nil {
+		return nil, err
+	}
+	return p.build(path, typ, label, o)
+}
+
+func (p *Packager) build(path, typ, label string, o *buildOptions) (*ChaincodePackage, error) {
+	metadata := PackageMetadata{Path: path, Type: typ, Label: label}
+	code := o.code
+	if code == nil {
+		normalizedPath, err := p.PlatformRegistry.NormalizePath(strings.ToUpper(typ), path)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to normalize chaincode path")
+		}
+		metadata.Path = normalizedPath
+		if code, err = p.PlatformRegistry.GetDeploymentPayload(strings.ToUpper(typ), path, o.replacer); err != nil {
+			return nil, errors.WithMessage(err, "error getting chaincode bytes")
+		}
+	}
+	if o.metadata != nil {
+		o.metadata(&metadata)
+	}
+	metadataBytes := o.rawMetadata
+	if metadataBytes == nil {
+		var err error
+		if metadataBytes, err = toJSON(metadata.Path, metadata.Type, metadata.Label); err != nil {
+			return nil, err
+		}
+	}
+
+	payload := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(payload)
+	tw := tar.NewWriter(gw)
+	entries := append([]entry{
+		{name: persistence.MetadataFile, payload: metadataBytes},
+		{name: persistence.CodePackageFile, payload: code},
+	}, o.extraEntries...)
+	for _, e := range entries {
+		if o.omit[e.name] {
+			continue
+		}
+		if err := writeBytesToPackage(tw, e.name, e.payload); err != nil {
+			return nil, errors.Wrapf(err, "error writing package entry [%s] to tar", e.name)
+		}
+	}
+	err := tw.Close()
+	if err == nil {
+		err = gw.Close()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create tar for chaincode")
+	}
+
+	return &ChaincodePackage{Metadata: metadata, Bytes: payload.Bytes()}, nil
+}
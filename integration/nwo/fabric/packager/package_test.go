@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package packager
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	p := New()
+	pkg, err := p.Build("github.com/example/mycc", "golang", "mycc_1.0", WithCode([]byte("code")))
+	assert.NoError(t, err)
+
+	// the package is the one parsed by the peers
+	metadata, code, err := persistence.ParseChaincodePackage(pkg.Bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, &persistence.ChaincodePackageMetadata{Type: "golang", Path: "github.com/example/mycc", Label: "mycc_1.0"}, metadata)
+	assert.Equal(t, []byte("code"), code)
+	assert.Equal(t, persistence.PackageID("mycc_1.0", pkg.Bytes), pkg.ID())
+	assert.Equal(t, "mycc_1.0:"+pkg.Hash(), pkg.ID())
+
+	path := filepath.Join(t.TempDir(), "packages", "mycc.tar.gz")
+	assert.NoError(t, pkg.WriteFile(path))
+	raw, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, pkg.Bytes, raw)
+
+	for _, args := range [][3]string{{"", "golang", "mycc"}, {"mycc", "", "mycc"}, {"mycc", "golang", ""}, {"mycc", "golang", "my cc"}} {
+		_, err := p.Build(args[0], args[1], args[2], WithCode([]byte("code")))
+		assert.Error(t, err, "%v", args)
+	}
+}
+
+func TestBuildCorrupted(t *testing.T) {
+	p := New()
+
+	// the label of the metadata is not the expected one
+	pkg, err := p.Build("mycc", "golang", "mycc_1.0", WithCode([]byte("code")), WithMetadataLabel("other"), WithMetadataType("binary"))
+	assert.NoError(t, err)
+	metadata, _, err := persistence.ParseChaincodePackage(pkg.Bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", metadata.Label)
+	assert.Equal(t, "binary", metadata.Type)
+	assert.Equal(t, "other:"+pkg.Hash(), pkg.ID())
+
+	// the label of the metadata is not validated, the peers reject it
+	pkg, err = p.Build("mycc", "golang", "mycc_1.0", WithCode([]byte("code")), WithMetadataLabel("not a label"))
+	assert.NoError(t, err)
+	_, _, err = persistence.ParseChaincodePackage(pkg.Bytes)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid label 'not a label'")
+
+	// malformed metadata
+	pkg, err = p.Build("mycc", "golang", "mycc_1.0", WithCode([]byte("code")), WithRawMetadata([]byte("{not json")))
+	assert.NoError(t, err)
+	_, _, err = persistence.ParseChaincodePackage(pkg.Bytes)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not unmarshal metadata.json as json")
+
+	// missing entries
+	pkg, err = p.Build("mycc", "golang", "mycc_1.0", WithCode([]byte("code")), WithoutEntry(persistence.CodePackageFile))
+	assert.NoError(t, err)
+	_, _, err = persistence.ParseChaincodePackage(pkg.Bytes)
+	assert.EqualError(t, err, "did not find a code package inside the package")
+	pkg, err = p.Build("mycc", "golang", "mycc_1.0", WithCode([]byte("code")), WithoutEntry(persistence.MetadataFile))
+	assert.NoError(t, err)
+	_, _, err = persistence.ParseChaincodePackage(pkg.Bytes)
+	assert.Error(t, err)
+
+	// an extra entry changes the package ID, not the metadata
+	plain, err := p.Build("mycc", "golang", "mycc_1.0", WithCode([]byte("code")))
+	assert.NoError(t, err)
+	pkg, err = p.Build("mycc", "golang", "mycc_1.0", WithCode([]byte("code")), WithExtraEntry("extra.txt", []byte("extra")))
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain.ID(), pkg.ID())
+	metadata, _, err = persistence.ParseChaincodePackage(pkg.Bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "mycc_1.0", metadata.Label)
+}
@@ -8,11 +8,8 @@ package packager
 
 import (
 	"archive/tar"
-	"bytes"
-	"compress/gzip"
 	"encoding/json"
 	"path/filepath"
-	"strings"
 
 	"github.com/hyperledger/fabric/core/chaincode/persistence"
 	"github.com/pkg/errors"
@@ -101,7 +98,7 @@ func (p *Packager) Package(replacer replacer.Func) error {
 		return err
 	}
 
-	pkgTarGzBytes, err := p.getTarGzBytes(replacer)
+	pkg, err := p.build(p.Input.Path, p.Input.Type, p.Input.Label, &buildOptions{replacer: replacer})
 	if err != nil {
 		return err
 	}
@@ -112,7 +109,7 @@ func (p *Packager) Package(replacer replacer.Func) error {
 	if dir, err = filepath.Abs(dir); err != nil {
 		return err
 	}
-	err = p.Writer.WriteFile(dir, name, pkgTarGzBytes)
+	err = p.Writer.WriteFile(dir, name, pkg.Bytes)
 	if err != nil {
 		err = errors.Wrapf(err, "error writing chaincode package to %s", p.Input.OutputFile)
 		logger.Error(err.Error())
@@ -122,47 +119,6 @@ func (p *Packager) Package(replacer replacer.Func) error {
 	return nil
 }
 
-func (p *Packager) getTarGzBytes(replacer replacer.Func) ([]byte, error) {
-	payload := bytes.NewBuffer(nil)
-	gw := gzip.NewWriter(payload)
-	tw := tar.NewWriter(gw)
-
-	normalizedPath, err := p.PlatformRegistry.NormalizePath(strings.ToUpper(p.Input.Type), p.Input.Path)
-	if err != nil {
-		return nil, errors.WithMessage(err, "failed to normalize chaincode path")
-	}
-	metadataBytes, err := toJSON(normalizedPath, p.Input.Type, p.Input.Label)
-	if err != nil {
-		return nil, err
-	}
-	err = writeBytesToPackage(tw, "metadata.json", metadataBytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "error writing package metadata to tar")
-	}
-
-	codeBytes, err := p.PlatformRegistry.GetDeploymentPayload(strings.ToUpper(p.Input.Type), p.Input.Path, replacer)
-	if err != nil {
-		return nil, errors.WithMessage(err, "error getting chaincode bytes")
-	}
-
-	codePackageName := "code.tar.gz"
-
-	err = writeBytesToPackage(tw, codePackageName, codeBytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "error writing package code bytes to tar")
-	}
-
-	err = tw.Close()
-	if err == nil {
-		err = gw.Close()
-	}
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create tar for chaincode")
-	}
-
-	return payload.Bytes(), nil
-}
-
 func writeBytesToPackage(tw *tar.Writer, name string, payload []byte) error {
 	err := tw.WriteHeader(&tar.Header{
 		Name: name,
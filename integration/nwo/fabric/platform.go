@@ -18,6 +18,7 @@ import (
 
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/faults"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/commands"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/fpc"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fabric/network"
@@ -146,6 +147,16 @@ func (p *Platform) Members() []grouper.Member {
 	return p.Network.Members()
 }
 
+// FaultRoutes returns the routes of the connections of the FSC nodes to the peers and to the orderers
+func (p *Platform) FaultRoutes() []faults.Route {
+	return p.Network.FaultRoutes()
+}
+
+// FaultProcesses returns the processes of the peers and of the orderers
+func (p *Platform) FaultProcesses() map[string]faults.Process {
+	return p.Network.FaultProcesses()
+}
+
 func (p *Platform) PostRun(load bool) {
 	p.Network.PostRun(load)
 
@@ -176,6 +187,21 @@ func (p *Platform) PostRun(load bool) {
 
 }
 
+// Services returns the processes of the orderers and of the peers
+func (p *Platform) Services() []api.Process {
+	return p.Network.Services()
+}
+
+// Setup returns the commands creating the channels and deploying the chaincodes
+func (p *Platform) Setup() []api.Process {
+	return p.Network.Setup()
+}
+
+// Teardown returns the command removing the chaincode containers
+func (p *Platform) Teardown() []api.Process {
+	return p.Network.Teardown()
+}
+
 func (p *Platform) Cleanup() {
 	p.Network.Cleanup()
 
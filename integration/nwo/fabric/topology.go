@@ -188,6 +188,15 @@ func WithOrionVaultPersistence(network, db, creator string) node.Option {
 	}
 }
 
+// WithVaultDriver is a configuration with the passed vault driver, badger or memory,
+// it takes precedence over the fast test mode
+func WithVaultDriver(driver string) node.Option {
+	return func(o *node.Options) error {
+		o.Put("fabric.vault.driver", driver)
+		return nil
+	}
+}
+
 // Network returns the fabric network from the passed context bound to the passed id.
 // It returns nil, if nothing is found
 func Network(ctx *context.Context, id string) *Platform {
@@ -295,6 +295,12 @@ fabric:
         tlsRootCertFile: {{ CACertsBundlePath }}
         serverNameOverride:
     {{- end }}
+    {{- if AddressOverrides }}
+    addressOverrides: {{ range AddressOverrides }}
+      - from: {{ .Address }}
+        to: {{ .Listen }}
+    {{- end }}
+    {{- end }}
     channels: {{ range .Channels }}
       - name: {{ .Name }}
         default: {{ .Default }}
@@ -41,6 +41,7 @@ type Topology struct {
 	Weaver            bool                `yaml:"weaver,omitempty"`
 	LogPeersToFile    bool                `yaml:"logPeersToFile,omitempty"`
 	LogOrderersToFile bool                `yaml:"logOrderersToFile,omitempty"`
+	FaultInjection    bool                `yaml:"faultInjection,omitempty"`
 }
 
 func (t *Topology) Name() string {
@@ -350,3 +351,9 @@ func (t *Topology) EnableLogPeersToFile() {
 func (t *Topology) EnableLogOrderersToFile() {
 	t.LogOrderersToFile = true
 }
+
+// EnableFaultInjection routes the connections of the FSC nodes to the peers and the orderers through proxies,
+// so that the faults injected with NWO#Faults can partition them, delay them, or corrupt them
+func (t *Topology) EnableFaultInjection() {
+	t.FaultInjection = true
+}
@@ -42,9 +42,12 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/client/view"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/client/view/cmd"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/crypto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 )
 
+var logger = flogging.MustGetLogger("fsc.integration.fsc")
+
 func init() {
 	// define the unmarshallers for the given file extensions, blank extension is the global unmarshaller
 	conflate.Unmarshallers = conflate.UnmarshallerMap{
@@ -58,10 +61,11 @@ func init() {
 }
 
 const (
-	ListenPort api.PortName = "Listen" // Port at which the fsc node might listen for some service
-	ViewPort   api.PortName = "View"   // Port at which the View Service Server respond
-	P2PPort    api.PortName = "P2P"    // Port at which the P2P Communication Layer respond
-	WebPort    api.PortName = "Web"    // Port at which the Web Server respond
+	ListenPort     api.PortName = "Listen"     // Port at which the fsc node might listen for some service
+	ViewPort       api.PortName = "View"       // Port at which the View Service Server respond
+	P2PPort        api.PortName = "P2P"        // Port at which the P2P Communication Layer respond
+	WebPort        api.PortName = "Web"        // Port at which the Web Server respond
+	OperationsPort api.PortName = "Operations" // Port at which the Operations Server respond
 )
 
 func WithAlias(alias string) node2.Option {
@@ -110,12 +114,21 @@ func (p *Platform) GenerateConfigTree() {
 }
 
 func (p *Platform) GenerateArtifacts() {
-	sess, err := p.Cryptogen(commands.Generate{
-		Config: p.CryptoConfigPath(),
-		Output: p.CryptoPath(),
-	})
-	Expect(err).NotTo(HaveOccurred())
-	Eventually(sess, p.EventuallyTimeout).Should(gexec.Exit(0))
+	// the crypto material generated by a previous run is reused if it has been generated from the same configuration
+	if common.ArtifactUpToDate(p.CryptoPath(), p.CryptoConfigPath()) ||
+		p.Context.AdoptArtifacts() && common.AdoptArtifact(p.CryptoPath(), p.CryptoConfigPath()) {
+		logger.Infof("reusing crypto material at [%s]", p.CryptoPath())
+	} else {
+		Expect(os.RemoveAll(p.CryptoPath())).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(p.CryptoPath(), 0755)).NotTo(HaveOccurred())
+		sess, err := p.Cryptogen(commands.Generate{
+			Config: p.CryptoConfigPath(),
+			Output: p.CryptoPath(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(sess, p.EventuallyTimeout).Should(gexec.Exit(0))
+		common.RecordArtifact(p.CryptoPath(), p.CryptoConfigPath())
+	}
 
 	p.ConcatenateTLSCACertificates()
 
@@ -124,41 +137,7 @@ func (p *Platform) GenerateArtifacts() {
 	// Generate core.yaml for all fsc nodes by including all the additional configurations coming
 	// from other platforms
 	for _, peer := range p.Peers {
-		cc := &grpc.ConnectionConfig{
-			Address:           p.PeerAddress(peer, ListenPort),
-			TLSEnabled:        true,
-			TLSRootCertFile:   path.Join(p.NodeLocalTLSDir(peer), "ca.crt"),
-			ConnectionTimeout: 10 * time.Minute,
-		}
-		p.Context.SetConnectionConfig(peer.Name, cc)
-
-		clientID, err := p.GetSigningIdentity(peer)
-		Expect(err).ToNot(HaveOccurred())
-		p.Context.SetClientSigningIdentity(peer.Name, clientID)
-
-		adminID, err := p.GetAdminSigningIdentity(peer)
-		Expect(err).ToNot(HaveOccurred())
-		p.Context.SetAdminSigningIdentity(peer.Name, adminID)
-
-		cert, err := ioutil.ReadFile(p.LocalMSPIdentityCert(peer))
-		Expect(err).ToNot(HaveOccurred())
-		p.Context.SetViewIdentity(peer.Name, cert)
-
-		p.GenerateCoreConfig(peer)
-
-		c := view2.Config{
-			Version: 0,
-			Address: p.PeerAddress(peer, ListenPort),
-			TLSConfig: comm.Config{
-				PeerCACertPath: path.Join(p.NodeLocalTLSDir(peer), "ca.crt"),
-				Timeout:        10 * time.Minute,
-			},
-			SignerConfig: signer.Config{
-				IdentityPath: p.LocalMSPIdentityCert(peer),
-				KeyPath:      p.LocalMSPPrivateKey(peer),
-			},
-		}
-		Expect(c.ToFile(p.NodeClientConfigPath(peer))).ToNot(HaveOccurred())
+		p.generateNodeArtifacts(peer)
 	}
 
 	// Generate commands
@@ -169,6 +148,46 @@ func (p *Platform) GenerateArtifacts() {
 	}
 }
 
+// generateNodeArtifacts generates the core.yaml of the passed node, by including all the additional configurations
+// coming from other platforms, and the configuration of its view client
+func (p *Platform) generateNodeArtifacts(peer *node2.Peer) {
+	cc := &grpc.ConnectionConfig{
+		Address:           p.PeerAddress(peer, ListenPort),
+		TLSEnabled:        true,
+		TLSRootCertFile:   path.Join(p.NodeLocalTLSDir(peer), "ca.crt"),
+		ConnectionTimeout: 10 * time.Minute,
+	}
+	p.Context.SetConnectionConfig(peer.Name, cc)
+
+	clientID, err := p.GetSigningIdentity(peer)
+	Expect(err).ToNot(HaveOccurred())
+	p.Context.SetClientSigningIdentity(peer.Name, clientID)
+
+	adminID, err := p.GetAdminSigningIdentity(peer)
+	Expect(err).ToNot(HaveOccurred())
+	p.Context.SetAdminSigningIdentity(peer.Name, adminID)
+
+	cert, err := ioutil.ReadFile(p.LocalMSPIdentityCert(peer))
+	Expect(err).ToNot(HaveOccurred())
+	p.Context.SetViewIdentity(peer.Name, cert)
+
+	p.GenerateCoreConfig(peer)
+
+	c := view2.Config{
+		Version: 0,
+		Address: p.PeerAddress(peer, ListenPort),
+		TLSConfig: comm.Config{
+			PeerCACertPath: path.Join(p.NodeLocalTLSDir(peer), "ca.crt"),
+			Timeout:        10 * time.Minute,
+		},
+		SignerConfig: signer.Config{
+			IdentityPath: p.LocalMSPIdentityCert(peer),
+			KeyPath:      p.LocalMSPPrivateKey(peer),
+		},
+	}
+	Expect(c.ToFile(p.NodeClientConfigPath(peer))).ToNot(HaveOccurred())
+}
+
 func (p *Platform) Load() {
 }
 
@@ -190,6 +209,29 @@ func (p *Platform) Members() []grouper.Member {
 	return members
 }
 
+// Services returns the processes of the FSC nodes, the bootstrap nodes first, as Members starts them
+func (p *Platform) Services() []api.Process {
+	var res []api.Process
+	for _, bootstrap := range []bool{true, false} {
+		for _, node := range p.Peers {
+			if node.Bootstrap == bootstrap {
+				res = append(res, api.NewProcess(node.ID(), p.FSCNodeRunner(node).Command))
+			}
+		}
+	}
+	return res
+}
+
+// Setup returns no command, the FSC nodes need no bootstrap
+func (p *Platform) Setup() []api.Process {
+	return nil
+}
+
+// Teardown returns no command, the FSC nodes create nothing outside their folders
+func (p *Platform) Teardown() []api.Process {
+	return nil
+}
+
 func (p *Platform) PostRun(bool) {
 	for _, peer := range p.Peers {
 		v := viper.New()
@@ -259,6 +301,11 @@ func (p *Platform) PostRun(bool) {
 		for _, alias := range node.Aliases {
 			p.Context.SetViewClient(alias, c)
 		}
+		// a node that has assumed the identity of another one serves the clients of the latter
+		if len(node.Replaces) != 0 {
+			p.Context.SetCLI(node.Replaces, cli)
+			p.Context.SetViewClient(node.Replaces, c)
+		}
 	}
 }
 
@@ -334,7 +381,7 @@ func (p *Platform) OperationAddress(peer *node2.Peer) string {
 	if runtime.GOOS == "darwin" {
 		fabricHost = "host.docker.internal"
 	}
-	return net.JoinHostPort(fabricHost, strconv.Itoa(int(p.Context.PortsByPeerID("fsc", peer.ID())[WebPort])))
+	return net.JoinHostPort(fabricHost, strconv.Itoa(int(p.Context.PortsByPeerID("fsc", peer.ID())[OperationsPort])))
 }
 
 func (p *Platform) InitClients() {
@@ -396,10 +443,6 @@ func (p *Platform) GenerateCoreConfig(peer *node2.Peer) {
 	err := os.MkdirAll(p.NodeDir(peer), 0755)
 	Expect(err).NotTo(HaveOccurred())
 
-	core, err := os.Create(p.NodeConfigPath(peer))
-	Expect(err).NotTo(HaveOccurred())
-	defer core.Close()
-
 	var extensions []string
 	for _, extensionsByPeerID := range p.Context.ExtensionsByPeerID(peer.Name) {
 		// if len(extensionsByPeerID) > 1, we need a merge
@@ -419,7 +462,7 @@ func (p *Platform) GenerateCoreConfig(peer *node2.Peer) {
 	var resolvers []*Resolver
 	// remove myself from the resolvers
 	for _, r := range p.Resolvers {
-		if r.Name != peer.Name {
+		if r.Name != resolverName(peer) {
 			resolvers = append(resolvers, r)
 		}
 	}
@@ -439,7 +482,15 @@ func (p *Platform) GenerateCoreConfig(peer *node2.Peer) {
 		"Resolvers":              func() []*Resolver { return resolvers },
 	}).Parse(p.Topology.Templates.CoreTemplate())
 	Expect(err).NotTo(HaveOccurred())
+	core := &bytes.Buffer{}
 	Expect(t.Execute(io.MultiWriter(core), p)).NotTo(HaveOccurred())
+
+	// reuse the configuration generated by a previous run, if it has not changed
+	if existing, err := ioutil.ReadFile(p.NodeConfigPath(peer)); err == nil && bytes.Equal(existing, core.Bytes()) {
+		logger.Infof("reusing configuration of [%s]", peer.Name)
+		return
+	}
+	Expect(ioutil.WriteFile(p.NodeConfigPath(peer), core.Bytes(), 0644)).NotTo(HaveOccurred())
 }
 
 func (p *Platform) BootstrapViewNodeGroupRunner() ifrit.Runner {
@@ -539,8 +590,10 @@ func (p *Platform) GenerateCmd(output io.Writer, node *node2.Peer) string {
 	}
 
 	t, err := template.New("node").Funcs(template.FuncMap{
-		"Alias":       func(s string) string { return node.Node.Alias(s) },
-		"InstallView": func() bool { return len(node.Node.Responders) != 0 || len(node.Node.Factories) != 0 },
+		"Alias": func(s string) string { return node.Node.Alias(s) },
+		"InstallView": func() bool {
+			return len(node.Node.Responders) != 0 || len(node.Node.Factories) != 0 || len(node.Node.Recoverables) != 0
+		},
 	}).Parse(p.Topology.Templates.NodeTemplate())
 	Expect(err).NotTo(HaveOccurred())
 
@@ -608,6 +661,9 @@ func (p *Platform) BootstrapNode(me *node2.Peer) string {
 			if node.Name == me.Name {
 				return ""
 			}
+			if peer := p.peerByName(node.Name); peer != nil {
+				return resolverName(peer)
+			}
 			return node.Name
 		}
 	}
@@ -848,5 +904,5 @@ func GetKVSOrionCreator(peer *node2.Peer) string {
 
 // PeerPortNames returns the list of ports that need to be reserved for a Peer.
 func PeerPortNames() []api.PortName {
-	return []api.PortName{ListenPort, P2PPort, WebPort}
+	return []api.PortName{ListenPort, P2PPort, WebPort, OperationsPort}
 }
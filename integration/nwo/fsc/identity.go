@@ -0,0 +1,320 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fsc
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
+	node2 "github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fsc/node"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const identityBundleHeader = "bundle.yaml"
+
+// IdentityBundle is a portable archive of the material an FSC node is known by:
+// its MSP and TLS crypto material, and its resolver binding.
+type IdentityBundle struct {
+	// Name is the name the other nodes resolve the identity by
+	Name         string   `yaml:"name"`
+	Organization string   `yaml:"organization"`
+	Aliases      []string `yaml:"aliases,omitempty"`
+	// Files maps the paths of the crypto material, relative to the crypto directory of the node, to their content
+	Files map[string][]byte `yaml:"-"`
+}
+
+// Archive returns the bundle as a gzipped tarball
+func (b *IdentityBundle) Archive() ([]byte, error) {
+	header, err := yaml.Marshal(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling bundle header")
+	}
+	paths := make([]string, 0, len(b.Files))
+	for p := range b.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	write := func(name string, content []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}); err != nil {
+			return errors.Wrapf(err, "failed writing header of [%s]", name)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return errors.Wrapf(err, "failed writing [%s]", name)
+		}
+		return nil
+	}
+	if err := write(identityBundleHeader, header); err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		if err := write(path.Join("crypto", p), b.Files[p]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrapf(err, "failed closing tarball")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, errors.Wrapf(err, "failed closing gzip stream")
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadIdentityBundle reads a bundle from the passed archive, as returned by IdentityBundle.Archive
+func ReadIdentityBundle(archive []byte) (*IdentityBundle, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening gzip stream")
+	}
+	defer gr.Close()
+
+	b := &IdentityBundle{Files: map[string][]byte{}}
+	headerFound := false
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading tarball")
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading [%s]", h.Name)
+		}
+		switch {
+		case h.Name == identityBundleHeader:
+			if err := yaml.Unmarshal(content, b); err != nil {
+				return nil, errors.Wrapf(err, "failed unmarshalling bundle header")
+			}
+			headerFound = true
+		case strings.HasPrefix(h.Name, "crypto/"):
+			p := path.Clean(strings.TrimPrefix(h.Name, "crypto/"))
+			if strings.HasPrefix(p, "..") || path.IsAbs(p) {
+				return nil, errors.Errorf("invalid path [%s] in bundle", h.Name)
+			}
+			b.Files[p] = content
+		default:
+			return nil, errors.Errorf("unexpected entry [%s] in bundle", h.Name)
+		}
+	}
+	if !headerFound {
+		return nil, errors.Errorf("bundle header not found")
+	}
+	return b, nil
+}
+
+// ExportNodeIdentity returns the identity bundle of the passed node
+func (p *Platform) ExportNodeIdentity(nodeName string) (*IdentityBundle, error) {
+	peer := p.peerByName(nodeName)
+	if peer == nil {
+		return nil, errors.Errorf("node [%s] not found", nodeName)
+	}
+	b := &IdentityBundle{
+		Name:         resolverName(peer),
+		Organization: peer.Organization,
+		Aliases:      peer.Aliases,
+		Files:        map[string][]byte{},
+	}
+	root := p.peerCryptoDir(peer)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		b.Files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading crypto material of [%s]", nodeName)
+	}
+	if _, ok := b.Files[p.signCertPath(peer)]; !ok {
+		return nil, errors.Errorf("identity of [%s] not found at [%s]", nodeName, root)
+	}
+	return b, nil
+}
+
+// AddNodeFromIdentity provisions a new node that assumes the passed identity, reusing its crypto material.
+// The new node runs the same views of the node the identity has been exported from, listens on fresh ports,
+// and takes its place in the platform: the node the identity has been exported from must not be running anymore.
+// The other nodes find the new node through the P2P routing, their configuration is regenerated
+// to resolve the identity to the new address, if it is bound to one, and takes effect when they restart.
+// The new node is not started.
+func (p *Platform) AddNodeFromIdentity(bundle *IdentityBundle, opts ...node2.Option) (*node2.Peer, error) {
+	org := p.Organization(bundle.Organization)
+	if org == nil {
+		return nil, errors.Errorf("organization [%s] not found", bundle.Organization)
+	}
+	var original *node2.Peer
+	for _, peer := range p.Peers {
+		if resolverName(peer) == bundle.Name {
+			original = peer
+		}
+	}
+	if original == nil {
+		return nil, errors.Errorf("no node runs the views of [%s]", bundle.Name)
+	}
+
+	n := node2.NewNodeFromTemplate(p.replacementName(bundle.Name), original.Node)
+	n.Synthesizer = original.Node.Synthesizer
+	if err := n.Options.Parse(opts...); err != nil {
+		return nil, errors.WithMessagef(err, "failed parsing options")
+	}
+	for _, alias := range bundle.Aliases {
+		if !contains(n.Options.Aliases(), alias) {
+			n.Options.AddAlias(alias)
+		}
+	}
+	executablePath := original.ExecutablePath
+	if len(executablePath) == 0 {
+		executablePath = p.NodeCmdPackage(original)
+	}
+	peer := &node2.Peer{
+		Name:           n.Name,
+		Organization:   org.Name,
+		Bootstrap:      n.Bootstrap,
+		ExecutablePath: executablePath,
+		Node:           n,
+		Aliases:        n.Options.Aliases(),
+		Admins:         original.Admins,
+		Replaces:       bundle.Name,
+	}
+
+	// install the crypto material where the platform expects the one of the new node
+	root := p.peerCryptoDir(peer)
+	if err := os.RemoveAll(root); err != nil {
+		return nil, errors.Wrapf(err, "failed cleaning [%s]", root)
+	}
+	for rel, content := range bundle.Files {
+		if strings.HasPrefix(rel, "msp/signcerts/") {
+			rel = p.signCertPath(peer)
+		}
+		target := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, errors.Wrapf(err, "failed creating [%s]", filepath.Dir(target))
+		}
+		if err := ioutil.WriteFile(target, content, 0600); err != nil {
+			return nil, errors.Wrapf(err, "failed writing [%s]", target)
+		}
+	}
+
+	ports := api.Ports{}
+	for _, portName := range PeerPortNames() {
+		ports[portName] = p.Context.ReservePort()
+	}
+	p.Context.SetPortsByPeerID("fsc", peer.ID(), ports)
+	p.Context.SetHostByPeerID("fsc", peer.ID(), "0.0.0.0")
+	// the configurations the other platforms have for the identity are reused as they are
+	for name, extensions := range p.Context.ExtensionsByPeerID(original.Name) {
+		for _, extension := range extensions {
+			p.Context.AddExtension(peer.Name, name, extension)
+		}
+	}
+
+	// the new node takes the place of the original one
+	p.Peers = append(p.removePeer(original), peer)
+	var nodes []*node2.Node
+	for _, node := range p.Topology.Nodes {
+		if node.Name != original.Name {
+			nodes = append(nodes, node)
+		}
+	}
+	p.Topology.Nodes = append(nodes, n)
+
+	p.GenerateResolverMap()
+	for _, other := range p.Peers {
+		if other != peer {
+			p.GenerateCoreConfig(other)
+		}
+	}
+	p.generateNodeArtifacts(peer)
+
+	logger.Infof("node [%s] provisioned with the identity of [%s]", peer.Name, bundle.Name)
+	return peer, nil
+}
+
+// resolverName returns the name the passed node is resolved by
+func resolverName(peer *node2.Peer) string {
+	if len(peer.Replaces) != 0 {
+		return peer.Replaces
+	}
+	return peer.Name
+}
+
+// replacementName returns the name of the next node assuming the passed identity
+func (p *Platform) replacementName(name string) string {
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if p.peerByName(candidate) == nil {
+			return candidate
+		}
+	}
+}
+
+func (p *Platform) peerByName(name string) *node2.Peer {
+	for _, peer := range p.Peers {
+		if peer.Name == name {
+			return peer
+		}
+	}
+	return nil
+}
+
+func (p *Platform) removePeer(peer *node2.Peer) []*node2.Peer {
+	var peers []*node2.Peer
+	for _, other := range p.Peers {
+		if other != peer {
+			peers = append(peers, other)
+		}
+	}
+	return peers
+}
+
+// peerCryptoDir returns the directory containing the msp and tls folders of the passed node
+func (p *Platform) peerCryptoDir(peer *node2.Peer) string {
+	return filepath.Dir(p.peerLocalCryptoDir(peer, "msp"))
+}
+
+// signCertPath returns the path of the identity certificate of the passed node, relative to its crypto directory
+func (p *Platform) signCertPath(peer *node2.Peer) string {
+	rel, err := filepath.Rel(p.peerCryptoDir(peer), p.LocalMSPIdentityCert(peer))
+	Expect(err).NotTo(HaveOccurred())
+	return filepath.ToSlash(rel)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fsc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	context2 "github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/context"
+	node2 "github.com/hyperledger-labs/fabric-smart-client/integration/nwo/fsc/node"
+)
+
+// installCrypto writes the crypto material the platform expects for the passed node
+func installCrypto(p *Platform, peer *node2.Peer) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	raw, err := x509.MarshalPKCS8PrivateKey(sk)
+	Expect(err).NotTo(HaveOccurred())
+	files := map[string][]byte{
+		p.LocalMSPIdentityCert(peer):                     []byte("cert of " + peer.Name),
+		p.LocalMSPPrivateKey(peer):                       pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: raw}),
+		filepath.Join(p.NodeLocalTLSDir(peer), "ca.crt"): []byte("tls ca"),
+		p.AdminLocalMSPIdentityCert(peer):                []byte("admin cert"),
+		p.AdminLocalMSPPrivateKey(peer):                  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: raw}),
+	}
+	for path, content := range files {
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(path, content, 0600)).NotTo(HaveOccurred())
+	}
+}
+
+type coreConfig struct {
+	FSC struct {
+		ID  string `yaml:"id"`
+		P2P struct {
+			ListenAddress string `yaml:"listenAddress"`
+		} `yaml:"p2p"`
+		Endpoint struct {
+			Resolvers []struct {
+				Name     string `yaml:"name"`
+				Identity struct {
+					Path string `yaml:"path"`
+				} `yaml:"identity"`
+			} `yaml:"resolvers"`
+		} `yaml:"endpoint"`
+	} `yaml:"fsc"`
+}
+
+func readCoreConfig(p *Platform, peer *node2.Peer) *coreConfig {
+	raw, err := ioutil.ReadFile(p.NodeConfigPath(peer))
+	Expect(err).NotTo(HaveOccurred())
+	c := &coreConfig{}
+	Expect(yaml.Unmarshal(raw, c)).NotTo(HaveOccurred())
+	return c
+}
+
+var _ = Describe("Identity bundles", func() {
+	var (
+		p       *Platform
+		rootDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		rootDir, err = ioutil.TempDir("", "fsc-identity")
+		Expect(err).NotTo(HaveOccurred())
+
+		topology := NewTopology()
+		topology.AddNodeByName("alice")
+		topology.AddNodeByName("bob").AddOptions(WithAlias("robert"))
+		p = NewPlatform(context2.New(rootDir, 20000, nil), topology, nil)
+		for _, peer := range p.Peers {
+			installCrypto(p, peer)
+		}
+		p.GenerateResolverMap()
+		for _, peer := range p.Peers {
+			p.generateNodeArtifacts(peer)
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(rootDir)).NotTo(HaveOccurred())
+	})
+
+	It("should survive the archive round trip", func() {
+		bundle, err := p.ExportNodeIdentity("bob")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bundle.Name).To(Equal("bob"))
+		Expect(bundle.Aliases).To(Equal([]string{"robert"}))
+		Expect(bundle.Files).To(HaveKey("msp/signcerts/bob.fsc.example.com-cert.pem"))
+		Expect(bundle.Files).To(HaveKey("msp/keystore/priv_sk"))
+		Expect(bundle.Files).To(HaveKey("tls/ca.crt"))
+
+		archive, err := bundle.Archive()
+		Expect(err).NotTo(HaveOccurred())
+		read, err := ReadIdentityBundle(archive)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(bundle))
+
+		_, err = p.ExportNodeIdentity("charlie")
+		Expect(err).To(HaveOccurred())
+		_, err = ReadIdentityBundle([]byte("not an archive"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should provision a node assuming the identity", func() {
+		bundle, err := p.ExportNodeIdentity("bob")
+		Expect(err).NotTo(HaveOccurred())
+		bob := p.peerByName("bob")
+
+		peer, err := p.AddNodeFromIdentity(bundle)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(peer.Name).To(Equal("bob-1"))
+		Expect(peer.Replaces).To(Equal("bob"))
+		Expect(peer.Aliases).To(Equal([]string{"robert"}))
+		Expect(peer.Node.Responders).To(Equal(bob.Node.Responders))
+
+		// the new node replaces bob, with the same identity on fresh ports
+		Expect(p.peerByName("bob")).To(BeNil())
+		Expect(p.Topology.ListNodes("bob")).To(BeEmpty())
+		Expect(p.Topology.ListNodes("bob-1")).To(HaveLen(1))
+		Expect(p.PeerPort(peer, P2PPort)).NotTo(Equal(p.PeerPort(bob, P2PPort)))
+		cert, err := ioutil.ReadFile(p.LocalMSPIdentityCert(peer))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(cert)).To(Equal("cert of bob"))
+
+		// the new node is resolved by the name of bob, and does not resolve itself
+		c := readCoreConfig(p, peer)
+		Expect(c.FSC.ID).To(Equal(peer.ID()))
+		var resolvers []string
+		for _, r := range c.FSC.Endpoint.Resolvers {
+			resolvers = append(resolvers, r.Name)
+		}
+		Expect(resolvers).To(Equal([]string{"alice"}))
+		c = readCoreConfig(p, p.peerByName("alice"))
+		Expect(c.FSC.Endpoint.Resolvers).To(HaveLen(1))
+		Expect(c.FSC.Endpoint.Resolvers[0].Name).To(Equal("bob"))
+		Expect(c.FSC.Endpoint.Resolvers[0].Identity.Path).To(Equal(p.LocalMSPIdentityCert(peer)))
+
+		// the identity can move again
+		bundle, err = p.ExportNodeIdentity("bob-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bundle.Name).To(Equal("bob"))
+		peer, err = p.AddNodeFromIdentity(bundle)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(peer.Name).To(Equal("bob-2"))
+		Expect(p.Peers).To(HaveLen(2))
+
+		bundle.Name = "charlie"
+		_, err = p.AddNodeFromIdentity(bundle)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -110,6 +110,20 @@ fsc:
       clientRootCAs:
         files:
         - {{ .NodeLocalTLSDir Peer }}/ca.crt
+  # Operations Server configuration for metrics, health, logspec, version, and the admin endpoints
+  operations:
+    enabled: true
+    listenAddress: 0.0.0.0:{{ .NodePort Peer "Operations" }}
+    tls:
+      enabled:  true
+      cert:
+        file: {{ .NodeLocalTLSDir Peer }}/server.crt
+      key:
+        file: {{ .NodeLocalTLSDir Peer }}/server.key
+      clientAuthRequired: false
+      clientRootCAs:
+        files:
+        - {{ .NodeLocalTLSDir Peer }}/ca.crt
   tracing:
     provider: {{ Topology.TracingProvider }}
     udp:
@@ -102,6 +102,10 @@ type ResponderEntry struct {
 	Initiator string
 }
 
+type RecoverableEntry struct {
+	Type string
+}
+
 type SDKEntry struct {
 	Id   string
 	Type string
@@ -118,6 +122,8 @@ type Synthesizer struct {
 	Factories  []FactoryEntry   `yaml:"Factories,omitempty"`
 	SDKs       []SDKEntry       `yaml:"SDKs,omitempty"`
 	Responders []ResponderEntry `yaml:"Responders,omitempty"`
+	// Recoverables are the prototypes of the recoverable views, see view.Recoverable
+	Recoverables []RecoverableEntry `yaml:"Recoverables,omitempty"`
 }
 
 type Node struct {
@@ -145,10 +151,11 @@ func NewNode(name string) *Node {
 func NewNodeFromTemplate(name string, template *Node) *Node {
 	return &Node{
 		Synthesizer: Synthesizer{
-			Aliases:    map[string]Alias{},
-			Imports:    template.Imports,
-			Factories:  template.Factories,
-			Responders: template.Responders,
+			Aliases:      map[string]Alias{},
+			Imports:      template.Imports,
+			Factories:    template.Factories,
+			Responders:   template.Responders,
+			Recoverables: template.Recoverables,
 		},
 		Name:           name,
 		Bootstrap:      template.Bootstrap,
@@ -231,6 +238,24 @@ func (n *Node) RegisterResponder(responder view.View, initiator view.View) *Node
 	return n
 }
 
+// RegisterRecoverable registers the passed prototype of a recoverable view: after a restart, the interrupted flows
+// it initiated are resumed from their last checkpoint
+func (n *Node) RegisterRecoverable(prototype view.Recoverable) *Node {
+	isPtr := reflect.ValueOf(prototype).Kind() == reflect.Ptr
+	prototypeType := reflect.Indirect(reflect.ValueOf(prototype)).Type()
+
+	alias := n.addImport(prototypeType.PkgPath())
+	prototypeStr := ""
+	if isPtr {
+		prototypeStr += "&"
+	}
+	prototypeStr += alias + "." + prototypeType.Name() + "{}"
+
+	n.Recoverables = append(n.Recoverables, RecoverableEntry{Type: prototypeStr})
+
+	return n
+}
+
 func (n *Node) AddOptions(opts ...Option) *Node {
 	if err := n.Options.Parse(opts...); err != nil {
 		panic(err.Error())
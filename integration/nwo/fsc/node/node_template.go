@@ -36,6 +36,10 @@ func main() {
 		}{{ end }}
 		{{- range .Responders }}
 		registry.RegisterResponder({{ .Responder }}, {{ .Initiator }}){{ end }}
+		{{- range .Recoverables }}
+		if err := registry.RegisterRecoverable({{ .Type }}); err != nil {
+			return err
+		}{{ end }}
 		{{ end }}
 		return nil
 	})
@@ -46,6 +46,8 @@ type Peer struct {
 	ExtraIdentities []*PeerIdentity `yaml:"extraidentities,omitempty"`
 	Admins          []string        `yaml:"admins,omitempty"`
 	Aliases         []string        `yaml:"aliases,omitempty"`
+	// Replaces is the name of the node whose identity this node has assumed, if any
+	Replaces string `yaml:"replaces,omitempty"`
 }
 
 // ID provides a unique identifier for a peer instance.
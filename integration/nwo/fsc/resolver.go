@@ -39,10 +39,12 @@ func (p *Platform) GenerateResolverMap() {
 			addresses[P2PPort] = fmt.Sprintf("%s:%d", p.Context.HostByPeerID("fsc", peer.ID()), p.Context.PortsByPeerID("fsc", peer.ID())[P2PPort])
 		}
 
+		// a node that has assumed the identity of another one is resolved by the name of the latter
+		name := resolverName(peer)
 		p.Resolvers = append(p.Resolvers, &Resolver{
-			Name: peer.Name,
+			Name: name,
 			Identity: ResolverIdentity{
-				ID:   peer.Name,
+				ID:   name,
 				Path: p.LocalMSPIdentityCert(peer),
 			},
 			Domain:    org.Domain,
@@ -7,15 +7,18 @@ SPDX-License-Identifier: Apache-2.0
 package nwo
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/api"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/context"
+	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/faults"
 	"github.com/hyperledger-labs/fabric-smart-client/integration/nwo/common/runner"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	. "github.com/onsi/gomega"
@@ -46,8 +49,17 @@ type NWO struct {
 
 	ctx       *context.Context
 	isLoading bool
+	// resumed contains the PIDs of the processes started by a previous run this NWO has reattached to
+	resumed []int
+	faults  *faults.Faults
 }
 
+const (
+	pidsFile = "pids.txt"
+	// startedFile marks the networks as started at least once, their ledgers exist already
+	startedFile = "nwo.started"
+)
+
 func New(ctx *context.Context, platforms ...api.Platform) *NWO {
 	return &NWO{
 		ctx:                    ctx,
@@ -88,6 +100,11 @@ func (n *NWO) Load() {
 
 func (n *NWO) Start() {
 	logger.Infof("Starting...")
+	if !n.isLoading && n.wasStarted() {
+		// the networks have been bootstrapped by a previous run
+		logger.Infof("Networks already bootstrapped, loading...")
+		n.isLoading = true
+	}
 
 	logger.Infof("Collect members...")
 	members := grouper.Members{}
@@ -120,6 +137,7 @@ func (n *NWO) Start() {
 	process := ifrit.Invoke(Runner)
 	n.Processes = append(n.Processes, process)
 	Eventually(process.Ready(), n.StartEventuallyTimeout).Should(BeClosed())
+	n.startFaults()
 
 	logger.Infof("Post execution for nodes...")
 	for _, platform := range n.Platforms {
@@ -141,7 +159,7 @@ func (n *NWO) Start() {
 	}
 
 	// store PIDs of all processes
-	f, err := os.Create(filepath.Join(n.ctx.RootDir(), "pids.txt"))
+	f, err := os.Create(filepath.Join(n.ctx.RootDir(), pidsFile))
 	Expect(err).NotTo(HaveOccurred())
 	n.storePIDs(f, members)
 	n.storePIDs(f, fscMembers)
@@ -154,10 +172,70 @@ func (n *NWO) Start() {
 			platform.PostRun(n.isLoading)
 		}
 	}
+
+	Expect(os.WriteFile(filepath.Join(n.ctx.RootDir(), startedFile), []byte(time.Now().String()), 0660)).NotTo(HaveOccurred())
+}
+
+// Resume reattaches to the processes, and containers, started by a previous run and still running.
+// If some of them are not running anymore, those still running are stopped and the networks are started again.
+func (n *NWO) Resume() {
+	logger.Infof("Resuming...")
+	pids := n.loadPIDs()
+	running := len(pids) != 0
+	for _, pid := range pids {
+		if !processRunning(pid) {
+			logger.Infof("Process [%d] not running anymore", pid)
+			running = false
+		}
+	}
+	for _, platform := range n.Platforms {
+		if r, ok := platform.(api.Resumable); ok && !r.Running() {
+			logger.Infof("Services of [%s] not running anymore", platform.Name())
+			running = false
+		}
+	}
+	if !running {
+		logger.Infof("Cannot resume, starting...")
+		n.terminate(pids)
+		for _, platform := range n.Platforms {
+			if _, ok := platform.(api.Resumable); ok {
+				platform.Cleanup()
+			}
+		}
+		n.Start()
+		return
+	}
+
+	n.isLoading = true
+	n.resumed = pids
+	for _, platform := range n.Platforms {
+		if platform.Type() == "fsc" {
+			n.ViewMembers = append(n.ViewMembers, platform.Members()...)
+		} else {
+			n.Members = append(n.Members, platform.Members()...)
+		}
+	}
+	n.startFaults()
+	// the containers of resumable platforms are left as they are, the others load their state
+	for _, platform := range n.Platforms {
+		if _, ok := platform.(api.Resumable); !ok && platform.Type() != "fsc" {
+			platform.PostRun(true)
+		}
+	}
+	for _, platform := range n.Platforms {
+		if platform.Type() == "fsc" {
+			platform.PostRun(true)
+		}
+	}
+	logger.Infof("Resuming...done, reattached to [%d] processes", len(pids))
 }
 
 func (n *NWO) Stop() {
 	logger.Infof("Stopping...")
+	if n.faults != nil {
+		n.faults.Close()
+		n.faults = nil
+	}
 	if len(n.Processes) != 0 {
 		logger.Infof("Sending sigterm signal...")
 		for _, process := range n.Processes {
@@ -165,6 +243,11 @@ func (n *NWO) Stop() {
 			Eventually(process.Wait(), n.StopEventuallyTimeout).Should(Receive())
 		}
 	}
+	if len(n.resumed) != 0 {
+		logger.Infof("Sending sigterm signal to resumed processes...")
+		n.terminate(n.resumed)
+		n.resumed = nil
+	}
 
 	logger.Infof("Cleanup...")
 	for _, platform := range n.Platforms {
@@ -186,6 +269,20 @@ func (n *NWO) StopFSCNode(id string) {
 	logger.Infof("FSC node [%s] not found", id)
 }
 
+// KillFSCNode kills the FSC node with the passed id, it has no chance to shut down
+func (n *NWO) KillFSCNode(id string) {
+	logger.Infof("Search FSC node [%s]...", id)
+	for _, member := range n.ViewMembers {
+		if strings.HasSuffix(member.Name, id) {
+			logger.Infof("FSC node [%s] found. Killing...", id)
+			member.Runner.(*runner.Runner).Kill()
+			logger.Infof("FSC node [%s:%s] killed", member.Name, id)
+			return
+		}
+	}
+	logger.Infof("FSC node [%s] not found", id)
+}
+
 func (n *NWO) StartFSCNode(id string) {
 	logger.Infof("Search FSC node [%s]...", id)
 	for i, member := range n.ViewMembers {
@@ -203,6 +300,93 @@ func (n *NWO) StartFSCNode(id string) {
 	logger.Infof("FSC node [%s] not found", id)
 }
 
+// Faults returns the injector of faults in the nodes of the networks.
+// The connections of the FSC nodes can be altered only for the platforms enabling the fault injection.
+func (n *NWO) Faults() *faults.Faults {
+	Expect(n.faults).NotTo(BeNil(), "networks not started")
+	return n.faults
+}
+
+// startFaults starts the proxies of the platforms supporting the fault injection, before the FSC nodes connect to them
+func (n *NWO) startFaults() {
+	var routes []faults.Route
+	processes := map[string]faults.Process{}
+	for _, platform := range n.Platforms {
+		p, ok := platform.(faults.Platform)
+		if !ok {
+			continue
+		}
+		routes = append(routes, p.FaultRoutes()...)
+		for id, process := range p.FaultProcesses() {
+			processes[id] = process
+		}
+	}
+	var err error
+	n.faults, err = faults.New(routes, processes)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// AddFSCNode starts a new FSC node, not part of the networks at the time they have been started
+func (n *NWO) AddFSCNode(member grouper.Member) {
+	logger.Infof("Run new FSC node [%s]...", member.Name)
+	n.ViewMembers = append(n.ViewMembers, member)
+	process := ifrit.Invoke(grouper.NewOrdered(n.TerminationSignal, []grouper.Member{member}))
+	Eventually(process.Ready(), n.StartEventuallyTimeout).Should(BeClosed())
+	n.Processes = append(n.Processes, process)
+	n.FSCProcesses = append(n.FSCProcesses, process)
+	logger.Infof("FSC node [%s] started", member.Name)
+}
+
+// wasStarted returns true if the networks have been started by a previous run
+func (n *NWO) wasStarted() bool {
+	_, err := os.Stat(filepath.Join(n.ctx.RootDir(), startedFile))
+	return err == nil
+}
+
+// loadPIDs returns the PIDs stored by a previous run
+func (n *NWO) loadPIDs() []int {
+	f, err := os.Open(filepath.Join(n.ctx.RootDir(), pidsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	Expect(err).NotTo(HaveOccurred())
+	defer f.Close()
+
+	var pids []int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		// each line is of the form `<path> <pid>`
+		pid, err := strconv.Atoi(line[strings.LastIndex(line, " ")+1:])
+		Expect(err).NotTo(HaveOccurred(), "invalid entry [%s] in [%s]", line, pidsFile)
+		pids = append(pids, pid)
+	}
+	Expect(scanner.Err()).NotTo(HaveOccurred())
+	return pids
+}
+
+// terminate sends a sigterm signal to the passed processes, if still running, and waits for them to exit
+func (n *NWO) terminate(pids []int) {
+	for _, pid := range pids {
+		if !processRunning(pid) {
+			continue
+		}
+		logger.Infof("Terminating process [%d]...", pid)
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+			logger.Warnf("failed terminating process [%d]: [%s]", pid, err)
+			continue
+		}
+		Eventually(func() bool { return processRunning(pid) }, n.StopEventuallyTimeout).Should(BeFalse())
+	}
+}
+
+func processRunning(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
 func (n *NWO) storePIDs(f *os.File, members grouper.Members) {
 	for _, member := range members {
 		switch r := member.Runner.(type) {
@@ -199,6 +199,17 @@ func (p *Platform) PostRun(load bool) {
 	p.InitOrionServer()
 }
 
+// Running returns true if the containers of this platform started by a previous run are still running
+func (p *Platform) Running() bool {
+	d, err := docker.GetInstance()
+	Expect(err).NotTo(HaveOccurred())
+	running, err := d.Running(func(name string) bool {
+		return strings.HasPrefix(name, "/"+p.NetworkID)
+	})
+	Expect(err).NotTo(HaveOccurred())
+	return running
+}
+
 func (p *Platform) Cleanup() {
 	dockerClient, err := docker.GetInstance()
 	Expect(err).NotTo(HaveOccurred())
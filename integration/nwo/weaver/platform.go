@@ -198,6 +198,17 @@ func (p *Platform) PostRun(bool) {
 	}
 }
 
+// Running returns true if the relay and driver containers started by a previous run are still running
+func (p *Platform) Running() bool {
+	d, err := docker.GetInstance()
+	Expect(err).NotTo(HaveOccurred())
+	running, err := d.Running(func(name string) bool {
+		return strings.HasPrefix(name, "/driver") || strings.HasPrefix(name, "/relay")
+	})
+	Expect(err).NotTo(HaveOccurred())
+	return running
+}
+
 func (p *Platform) Cleanup() {
 	cleanupFunc()
 
@@ -38,6 +38,8 @@ const (
 	TwoFabricNetworksWithWeaverRelayPort
 	FabricStopRestart
 	PingPongOrion
+	MultiSigPort
+	RecoveryPort
 )
 
 // StartPortForNode On linux, the default ephemeral port range is 32768-60999 and can be
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package node
 
 import (
+	"context"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -16,6 +17,8 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/node/version"
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/api"
 	node3 "github.com/hyperledger-labs/fabric-smart-client/pkg/node"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
@@ -27,6 +30,8 @@ type ExecuteCallbackFunc = func() error
 type FabricSmartClient interface {
 	Start() error
 	Stop()
+	Drain(ctx context.Context) (*drain.Report, error)
+	Validate(ctx context.Context, opts *dryrun.Options) *dryrun.Report
 	InstallSDK(p api.SDK) error
 	Registry() node3.Registry
 	GetService(v interface{}) (interface{}, error)
@@ -7,7 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package node
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strconv"
@@ -16,7 +18,11 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/node/node/profile"
 	node3 "github.com/hyperledger-labs/fabric-smart-client/pkg/node"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -32,6 +38,11 @@ type Node interface {
 	Callback() chan<- error
 }
 
+// Validator is implemented by the nodes supporting the dry-run of their startup
+type Validator interface {
+	Validate(ctx context.Context, opts *dryrun.Options) *dryrun.Report
+}
+
 var (
 	logger = flogging.MustGetLogger("fsc.node.start")
 	node   Node
@@ -52,6 +63,8 @@ var nodeCmd = &cobra.Command{
 }
 
 func startCmd() *cobra.Command {
+	var printEffectiveConfig, dryRun bool
+	dryRunOpts := &dryrun.Options{}
 	var nodeStartCmd = &cobra.Command{
 		Use:   "start",
 		Short: "Starts the fabric smart client node.",
@@ -61,18 +74,64 @@ func startCmd() *cobra.Command {
 				return fmt.Errorf("trailing args detected")
 			}
 			cmd.SilenceUsage = true
+			if printEffectiveConfig {
+				return printConfig(cmd.OutOrStdout())
+			}
+			if dryRun {
+				return validate(cmd.OutOrStdout(), dryRunOpts)
+			}
 			return serve()
 		},
 	}
+	nodeStartCmd.Flags().BoolVar(&printEffectiveConfig, "print-effective-config", false,
+		"print the configuration merged with its overlays, the secrets masked, and exit without starting the node")
+	nodeStartCmd.Flags().BoolVar(&dryRun, "dry-run", false,
+		"validate the configuration, the crypto material, the stores and the endpoints of the node without starting it, "+
+			"print a JSON report, and exit with an error if any check failed")
+	nodeStartCmd.Flags().BoolVar(&dryRunOpts.CheckPorts, "check-ports", false,
+		"with --dry-run, check that the listen addresses of the node can be bound, releasing them at once")
+	nodeStartCmd.Flags().DurationVar(&dryRunOpts.DialTimeout, "dial-timeout", dryrun.DefaultDialTimeout,
+		"with --dry-run, the timeout of each connection attempt to the remote endpoints")
 	return nodeStartCmd
 }
 
-func serve() error {
-	// config path
+// configPath returns the path of the configuration of the node
+func nodeConfigPath() string {
 	configPath := os.Getenv("FSCNODE_CFG_PATH")
 	if configPath == "" {
 		configPath = "./"
 	}
+	return configPath
+}
+
+// printConfig writes the effective configuration of the node, with the overlays selected by the environment
+func printConfig(w io.Writer) error {
+	p, err := config.NewProvider(nodeConfigPath())
+	if err != nil {
+		return err
+	}
+	return p.WriteEffectiveConfig(w)
+}
+
+// validate runs the dry-run of the startup of the node and writes its report. It fails if any check failed.
+func validate(w io.Writer, opts *dryrun.Options) error {
+	v, ok := node.(Validator)
+	if !ok {
+		return errors.New("dry-run not supported by this node")
+	}
+	report := v.Validate(context.Background(), opts)
+	if err := report.WriteJSON(w); err != nil {
+		return err
+	}
+	if failures := report.Failures(); len(failures) != 0 {
+		return errors.Errorf("dry-run failed: %d checks failed", len(failures))
+	}
+	return nil
+}
+
+func serve() error {
+	// config path
+	configPath := nodeConfigPath()
 
 	// profile
 	enableProfile := false
@@ -152,6 +211,16 @@ func serve() error {
 
 	callback(nil)
 
+	// a drained node exits
+	if s := drain.GetService(node.Registry()); s != nil {
+		go func() {
+			<-s.Done()
+			logger.Infof("Node drained, exiting...")
+			node.Stop()
+			serve <- nil
+		}()
+	}
+
 	cs := view.GetConfigService(node.Registry())
 	logger.Infof("Started peer with ID=[%s], address=[%s]",
 		cs.GetString("fsc.id"),
@@ -18,7 +18,10 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/api"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
 	view3 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config"
 	viewsdk "github.com/hyperledger-labs/fabric-smart-client/platform/view/sdk"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
@@ -96,6 +99,10 @@ func (n *node) Start() (err error) {
 	logger.Infof("Installing sdks...done")
 
 	n.context, n.cancel = context.WithCancel(context.Background())
+	// a drained node stops its listeners
+	if s := drain.GetService(n.registry); s != nil {
+		s.OnDrained(n.Stop)
+	}
 
 	// Start
 	logger.Info("Starting sdks...")
@@ -130,6 +137,41 @@ func (n *node) Stop() {
 	}
 }
 
+// Drain makes the node refuse new view invocations and sessions, waits, until the deadline of the passed context,
+// for the flows in flight and the block commits to complete, and then stops the node.
+// The work that did not complete is recorded, it is reported by the node after the restart.
+func (n *node) Drain(ctx context.Context) (*drain.Report, error) {
+	s := drain.GetService(n.registry)
+	if s == nil {
+		return nil, errors.New("drain not supported, the node is not started")
+	}
+	return s.Drain(ctx)
+}
+
+// Validate checks the configuration of the node, as the node itself would load it, without starting it,
+// and returns a report of all the problems found. The SDKs installed that implement dryrun.Validator
+// validate their part: nothing is written to the stores of the node, nothing is sent but the connection attempts
+// to the remote endpoints, and no port is bound, unless the options enable the self-check of the ports.
+func (n *node) Validate(ctx context.Context, opts *dryrun.Options) *dryrun.Report {
+	report := dryrun.NewReport()
+	configProvider, err := config.NewProvider(n.confPath)
+	if err != nil {
+		report.Add("node", "config", n.confPath, errors.WithMessagef(err, "failed loading configuration"))
+		return report
+	}
+	report.Add("node", "config", configProvider.ConfigFileUsed(), nil)
+
+	for _, p := range n.sdks {
+		v, ok := p.(dryrun.Validator)
+		if !ok {
+			logger.Debugf("platform [%T] does not support validation, skipping", p)
+			continue
+		}
+		v.Validate(ctx, configProvider, opts, report)
+	}
+	return report
+}
+
 func (n *node) InstallSDK(p api.SDK) error {
 	if n.running {
 		return errors.New("failed installing platform, the system is already running")
@@ -0,0 +1,240 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package canonical
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/pkg/errors"
+)
+
+/*
+This package serializes the application state to bytes that do not depend on the node, the platform, or the
+language producing them, so that the nodes computing the same state can compare its hashes and verify the
+signatures on it.
+
+The canonical JSON follows RFC 8785 (JSON Canonicalization Scheme): no whitespace, the members of the objects
+sorted by the UTF-16 code units of their names, the strings escaping only the quotation mark, the reverse solidus
+and the control characters, and the numbers in the shortest form reading back the same float64.
+Unlike RFC 8785, the integers are kept exactly as they are, also beyond 2^53, so that the uint64 heights and
+sequences survive.
+*/
+
+// JSON returns the canonical JSON of the passed value, marshalled as encoding/json does
+func JSON(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling [%T]", v)
+	}
+	return NormalizeJSON(raw)
+}
+
+// NormalizeJSON returns the canonical form of the passed JSON document.
+// The documents with duplicate member names or trailing data are refused.
+func NormalizeJSON(raw []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	res, err := normalize(d, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON, trailing data after the value")
+	}
+	return res, nil
+}
+
+// Proto returns the deterministic protobuf marshalling of the passed message, the map entries sorted by key.
+// Unlike the canonical JSON, the output is stable for the same protobuf library only.
+func Proto(m proto.Message) ([]byte, error) {
+	raw, err := proto.MarshalDeterministic(m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling [%T]", m)
+	}
+	return raw, nil
+}
+
+// HashState returns the SHA-256 hash of the canonical serialization of the passed state:
+// Proto for the protobuf messages, JSON for the other values
+func HashState(v interface{}) ([32]byte, error) {
+	var raw []byte
+	var err error
+	if m, ok := v.(proto.Message); ok {
+		raw, err = Proto(m)
+	} else {
+		raw, err = JSON(v)
+	}
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(raw), nil
+}
+
+// normalize appends to b the canonical form of the next value of the decoder
+func normalize(d *json.Decoder, b []byte) ([]byte, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid JSON")
+	}
+	switch v := t.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			return normalizeObject(d, b)
+		case '[':
+			return normalizeArray(d, b)
+		}
+		return nil, errors.Errorf("invalid JSON, unexpected [%s]", v)
+	case string:
+		return appendString(b, v), nil
+	case json.Number:
+		return appendNumber(b, v)
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case nil:
+		return append(b, "null"...), nil
+	}
+	return nil, errors.Errorf("invalid JSON, unexpected token [%v]", t)
+}
+
+type member struct {
+	name  string
+	key   []uint16
+	value []byte
+}
+
+func normalizeObject(d *json.Decoder, b []byte) ([]byte, error) {
+	var members []member
+	names := map[string]struct{}{}
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid JSON")
+		}
+		name, ok := t.(string)
+		if !ok {
+			return nil, errors.Errorf("invalid JSON, member name expected, got [%v]", t)
+		}
+		if _, ok := names[name]; ok {
+			return nil, errors.Errorf("invalid JSON, duplicate member [%s]", name)
+		}
+		names[name] = struct{}{}
+		value, err := normalize(d, nil)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member{name: name, key: utf16.Encode([]rune(name)), value: value})
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON")
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return lessUTF16(members[i].key, members[j].key)
+	})
+	b = append(b, '{')
+	for i, m := range members {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendString(b, m.name)
+		b = append(b, ':')
+		b = append(b, m.value...)
+	}
+	return append(b, '}'), nil
+}
+
+func normalizeArray(d *json.Decoder, b []byte) ([]byte, error) {
+	b = append(b, '[')
+	for first := true; d.More(); first = false {
+		if !first {
+			b = append(b, ',')
+		}
+		var err error
+		if b, err = normalize(d, b); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON")
+	}
+	return append(b, ']'), nil
+}
+
+func lessUTF16(a, b []uint16) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b = append(b, `\"`...)
+		case '\\':
+			b = append(b, `\\`...)
+		case '\b':
+			b = append(b, `\b`...)
+		case '\f':
+			b = append(b, `\f`...)
+		case '\n':
+			b = append(b, `\n`...)
+		case '\r':
+			b = append(b, `\r`...)
+		case '\t':
+			b = append(b, `\t`...)
+		default:
+			if r < 0x20 {
+				b = append(b, fmt.Sprintf(`\u%04x`, r)...)
+				continue
+			}
+			b = utf8.AppendRune(b, r)
+		}
+	}
+	return append(b, '"')
+}
+
+// appendNumber appends the integers as they are, the other numbers in the shortest form of their float64,
+// as ECMAScript formats them
+func appendNumber(b []byte, n json.Number) ([]byte, error) {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if s == "-0" {
+			s = "0"
+		}
+		return append(b, s...), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid JSON, number [%s] out of the float64 range", s)
+	}
+	if f == 0 {
+		return append(b, '0'), nil
+	}
+	if abs := math.Abs(f); abs >= 1e21 || abs < 1e-6 {
+		// ECMAScript has no leading zeros in the exponent, 1e-07 is 1e-7
+		mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+		return append(b, mantissa+"e"+exp[:1]+strings.TrimLeft(exp[1:], "0")...), nil
+	}
+	return strconv.AppendFloat(b, f, 'f', -1, 64), nil
+}
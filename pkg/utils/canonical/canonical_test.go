@@ -0,0 +1,95 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package canonical
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// The golden outputs lock the bytes: they must not change across platforms, Go versions, or releases.
+
+func TestNormalizeJSON(t *testing.T) {
+	for _, test := range []struct {
+		in, out string
+	}{
+		// whitespace, and members sorted by their UTF-16 code units: U+1F600 (surrogates D83D DE00) before U+FB33
+		{"{ \"b\" : [ 1 , 2 ] ,\n\"a\":{\"d\":true,\"c\":null} }", `{"a":{"c":null,"d":true},"b":[1,2]}`},
+		{"{\"\ufb33\":1,\"\U0001F600\":2,\"\u00e9\":3,\"z\":4,\"\":5}", "{\"\":5,\"z\":4,\"\u00e9\":3,\"\U0001F600\":2,\"\ufb33\":1}"},
+		// strings escape only the quotation mark, the reverse solidus, and the control characters
+		{`"<\/a> \"\\ \u0007\b\t\n\f\r\u001f €"`, `"</a> \"\\ \u0007\b\t\n\f\r\u001f €"`},
+		// integers kept exactly, other numbers in their shortest ECMAScript form
+		{`[0,-0,18446744073709551615,-9007199254740993]`, `[0,0,18446744073709551615,-9007199254740993]`},
+		{`[1.0,1.50,-0.0,1e2,1E21,1e-6,1e-7,123456789.125,0.1,5e-324,1.7976931348623157e308]`, `[1,1.5,0,100,1e+21,0.000001,1e-7,123456789.125,0.1,5e-324,1.7976931348623157e+308]`},
+		{`[[],{},""]`, `[[],{},""]`},
+	} {
+		out, err := NormalizeJSON([]byte(test.in))
+		assert.NoError(t, err, test.in)
+		assert.Equal(t, test.out, string(out), test.in)
+		again, err := NormalizeJSON(out)
+		assert.NoError(t, err)
+		assert.Equal(t, out, again)
+	}
+
+	for _, in := range []string{`{"a":1,"a":2}`, `{"a":1} {}`, `{"a":}`, `[1e400]`, ``} {
+		_, err := NormalizeJSON([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+type state struct {
+	Owner  string            `json:"owner"`
+	Amount uint64            `json:"amount"`
+	Rate   float64           `json:"rate"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	Raw    []byte            `json:"raw"`
+}
+
+func TestJSON(t *testing.T) {
+	s := &state{Owner: "alice <a&b>", Amount: 1<<64 - 1, Rate: 0.25, Tags: map[string]string{"z": "1", "a": "2"}, Raw: []byte{1, 2}}
+	raw, err := JSON(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"amount":18446744073709551615,"owner":"alice <a&b>","rate":0.25,"raw":"AQI=","tags":{"a":"2","z":"1"}}`, string(raw))
+
+	// the same state, whatever the order of its members, has the same hash
+	h, err := HashState(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "2bb8dd220a45b936e75da89991dd613c3c64d072461781d53648a52ce72a2242", hex.EncodeToString(h[:]))
+	m := map[string]interface{}{"tags": map[string]string{"a": "2", "z": "1"}, "raw": "AQI=", "rate": 0.25, "owner": "alice <a&b>", "amount": uint64(1<<64 - 1)}
+	h2, err := HashState(m)
+	assert.NoError(t, err)
+	assert.Equal(t, h, h2)
+
+	_, err = JSON(func() {})
+	assert.Error(t, err)
+}
+
+func TestProto(t *testing.T) {
+	group := &common.ConfigGroup{
+		Version: 1,
+		Values: map[string]*common.ConfigValue{
+			"c": {Version: 3, Value: []byte("c")},
+			"a": {Version: 1, Value: []byte("a")},
+			"b": {Version: 2, Value: []byte("b")},
+		},
+	}
+	raw, err := Proto(group)
+	assert.NoError(t, err)
+	assert.Equal(t, "0801"+"1a0a0a016112050801120161"+"1a0a0a016212050802120162"+"1a0a0a016312050803120163", hex.EncodeToString(raw))
+	for i := 0; i < 10; i++ {
+		again, err := Proto(group)
+		assert.NoError(t, err)
+		assert.Equal(t, raw, again)
+	}
+
+	h, err := HashState(group)
+	assert.NoError(t, err)
+	assert.Equal(t, "6810bb60d29f8505b8962c2dd133fd6fbda5df16af4f91a67e6f71a2e24fc021", hex.EncodeToString(h[:]))
+}
@@ -34,3 +34,18 @@ func Equal(x, y Message) bool {
 func Clone(src Message) Message {
 	return protoV1.Clone(src)
 }
+
+func Size(m Message) int {
+	return protoV1.Size(m)
+}
+
+// MarshalDeterministic marshals the passed message with the map entries sorted by key, the output is stable
+// for the same message and protobuf library, not across libraries or with unknown fields
+func MarshalDeterministic(m Message) ([]byte, error) {
+	b := protoV1.NewBuffer(nil)
+	b.SetDeterministic(true)
+	if err := b.Marshal(m); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/sdk/metadata"
+)
+
+// module is the path of the fabric-smart-client module
+const module = "github.com/hyperledger-labs/fabric-smart-client"
+
+// Info is the build information of the node
+type Info struct {
+	Version   string `json:"Version"`
+	CommitSHA string `json:"CommitSHA,omitempty"`
+	BuildDate string `json:"BuildDate,omitempty"`
+	GoVersion string `json:"GoVersion"`
+	Platform  string `json:"Platform"`
+}
+
+// Get returns the build information of the node.
+// The version, the commit and the build date are the ones stamped with ldflags in the metadata package.
+// When they are not stamped, the ones recorded by the go toolchain in the binary are used, if any:
+// the version of the fabric-smart-client module, and the revision of the sources it has been built from.
+func Get() *Info {
+	info := &Info{
+		Version:   metadata.Version,
+		CommitSHA: metadata.CommitSHA,
+		BuildDate: metadata.BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if Parse(info.Version).IsUnknown() {
+		if v := moduleVersion(bi); !Parse(v).IsUnknown() {
+			info.Version = v
+		}
+	}
+	if bi.Main.Path != module {
+		return info
+	}
+	for _, setting := range bi.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.CommitSHA == "development build":
+			info.CommitSHA = setting.Value
+		case setting.Key == "vcs.time" && len(info.BuildDate) == 0:
+			info.BuildDate = setting.Value
+		}
+	}
+	return info
+}
+
+// String returns a description of the build information, one field per line
+func (i *Info) String() string {
+	s := fmt.Sprintf(" Version: %s\n Commit SHA: %s\n", i.Version, i.CommitSHA)
+	if len(i.BuildDate) != 0 {
+		s += fmt.Sprintf(" Build date: %s\n", i.BuildDate)
+	}
+	return s + fmt.Sprintf(" Go version: %s\n OS/Arch: %s\n", i.GoVersion, i.Platform)
+}
+
+// Current returns the version of the node
+func Current() Version {
+	return Parse(Get().Version)
+}
+
+// moduleVersion returns the version of the fabric-smart-client module recorded in the binary, if any
+func moduleVersion(bi *debug.BuildInfo) string {
+	if bi.Main.Path == module {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path != module {
+			continue
+		}
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return ""
+}
+
+// Version is a semantic version. The zero value is Unknown.
+type Version struct {
+	known      bool
+	major      int
+	minor      int
+	patch      int
+	prerelease []string
+	raw        string
+}
+
+// Unknown is the version of the nodes that do not tell theirs, like the legacy ones,
+// or whose version is not a semantic version (e.g. the development builds).
+// It precedes every known version.
+var Unknown = Version{}
+
+// Parse parses a semantic version, with or without the leading `v`.
+// It returns Unknown if the passed string is not a semantic version.
+func Parse(s string) Version {
+	raw := strings.TrimSpace(s)
+	v := strings.TrimPrefix(raw, "v")
+	// the build metadata is not relevant to the precedence
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	var prerelease []string
+	if i := strings.Index(v, "-"); i >= 0 {
+		prerelease = strings.Split(v[i+1:], ".")
+		v = v[:i]
+		for _, id := range prerelease {
+			if len(id) == 0 {
+				return Unknown
+			}
+		}
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return Unknown
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Unknown
+		}
+		numbers[i] = n
+	}
+	return Version{
+		known:      true,
+		major:      numbers[0],
+		minor:      numbers[1],
+		patch:      numbers[2],
+		prerelease: prerelease,
+		raw:        raw,
+	}
+}
+
+// IsUnknown returns true if the version is Unknown
+func (v Version) IsUnknown() bool {
+	return !v.known
+}
+
+// String returns the version as parsed, `unknown` for Unknown
+func (v Version) String() string {
+	if !v.known {
+		return "unknown"
+	}
+	return v.raw
+}
+
+// Compare returns -1, 0 or +1 if the version precedes, equals or follows the passed one, following the
+// precedence of the semantic versions. Unknown precedes every known version.
+func (v Version) Compare(o Version) int {
+	switch {
+	case !v.known && !o.known:
+		return 0
+	case !v.known:
+		return -1
+	case !o.known:
+		return 1
+	}
+	for _, c := range [][2]int{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if c[0] != c[1] {
+			return sign(c[0] - c[1])
+		}
+	}
+	// a pre-release precedes the release
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := compareIdentifiers(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(v.prerelease) - len(o.prerelease))
+}
+
+// AtLeast returns true if the version is known and does not precede the passed one.
+// It returns false if the passed string is not a semantic version.
+func (v Version) AtLeast(min string) bool {
+	m := Parse(min)
+	if !v.known || !m.known {
+		return false
+	}
+	return v.Compare(m) >= 0
+}
+
+// compareIdentifiers compares two pre-release identifiers: the numeric ones numerically,
+// and before the alphanumeric ones, compared lexically
+func compareIdentifiers(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(na - nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
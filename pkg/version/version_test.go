@@ -0,0 +1,89 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/sdk/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"0.3.0", "v0.3.0", "1.2.3-rc.1", "1.2.3+build.5", "v0.0.0-20230101000000-abcdef012345"} {
+		assert.False(t, Parse(s).IsUnknown(), s)
+		assert.Equal(t, s, Parse(s).String())
+	}
+	for _, s := range []string{"", "latest", "(devel)", "1.2", "1.2.3.4", "1.x.3", "1.2.3-", "1.2.3-rc..1"} {
+		assert.True(t, Parse(s).IsUnknown(), s)
+		assert.Equal(t, "unknown", Parse(s).String())
+	}
+	assert.True(t, Version{}.IsUnknown())
+	assert.Equal(t, Unknown, Parse("latest"))
+}
+
+func TestCompare(t *testing.T) {
+	// in increasing precedence
+	ordered := []string{
+		"unknown",
+		"0.2.9",
+		"0.3.0-alpha",
+		"0.3.0-alpha.1",
+		"0.3.0-alpha.beta",
+		"0.3.0-beta.2",
+		"0.3.0-beta.11",
+		"0.3.0-rc.1",
+		"0.3.0",
+		"0.3.1",
+		"0.10.0",
+		"1.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			assert.Equal(t, expected, Parse(ordered[i]).Compare(Parse(ordered[j])), "%s vs %s", ordered[i], ordered[j])
+		}
+	}
+	assert.Equal(t, 0, Parse("v0.3.0+build.1").Compare(Parse("0.3.0")))
+}
+
+func TestAtLeast(t *testing.T) {
+	v := Parse("v0.3.1")
+	assert.True(t, v.AtLeast("0.3.0"))
+	assert.True(t, v.AtLeast("v0.3.1"))
+	assert.False(t, v.AtLeast("0.4.0"))
+	assert.False(t, v.AtLeast("not a version"))
+	assert.False(t, Parse("0.3.0-rc.1").AtLeast("0.3.0"))
+
+	// the legacy nodes do not support anything
+	assert.False(t, Unknown.AtLeast("0.0.0"))
+}
+
+func TestGet(t *testing.T) {
+	defer func(version, commit, date string) {
+		metadata.Version, metadata.CommitSHA, metadata.BuildDate = version, commit, date
+	}(metadata.Version, metadata.CommitSHA, metadata.BuildDate)
+
+	metadata.Version, metadata.CommitSHA, metadata.BuildDate = "v0.3.0", "0123456789abcdef", "2023-05-04T10:00:00Z"
+	info := Get()
+	assert.Equal(t, &Info{
+		Version:   "v0.3.0",
+		CommitSHA: "0123456789abcdef",
+		BuildDate: "2023-05-04T10:00:00Z",
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}, info)
+	assert.Equal(t, " Version: v0.3.0\n Commit SHA: 0123456789abcdef\n Build date: 2023-05-04T10:00:00Z\n"+
+		" Go version: "+runtime.Version()+"\n OS/Arch: "+info.Platform+"\n", info.String())
+	assert.True(t, Current().AtLeast("0.3.0"))
+}
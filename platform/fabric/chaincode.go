@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package fabric
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -159,6 +160,13 @@ func (i *ChaincodeInvocation) WithEndorsersFromMyOrg() *ChaincodeInvocation {
 	return i
 }
 
+// WithEndorsersFromChannelPeers sets the MSP IDs of the organizations whose peers must endorse.
+// The peers of other organizations are taken from the channel configuration, see MSPManager#ChannelPeers.
+func (i *ChaincodeInvocation) WithEndorsersFromChannelPeers(mspIDs ...string) *ChaincodeInvocation {
+	i.ChaincodeInvocation.WithEndorsersFromChannelPeers(mspIDs...)
+	return i
+}
+
 func (i *ChaincodeInvocation) WithInvokerIdentity(id view.Identity) *ChaincodeInvocation {
 	i.ChaincodeInvocation.WithSignerIdentity(id)
 	return i
@@ -176,6 +184,14 @@ func (i *ChaincodeInvocation) WithRetrySleep(duration time.Duration) *ChaincodeI
 	return i
 }
 
+// WithContext sets the context of the flow running the invocation, see view.Context#Context.
+// The endorsement, the ordering and the finality of the invocation are accounted to the latency phases of the flow,
+// and the cancellation of the context interrupts the wait for the finality.
+func (i *ChaincodeInvocation) WithContext(ctx context.Context) *ChaincodeInvocation {
+	i.ChaincodeInvocation.WithContext(ctx)
+	return i
+}
+
 type ChaincodeQuery struct {
 	driver.ChaincodeInvocation
 }
@@ -206,6 +222,13 @@ func (i *ChaincodeQuery) WithEndorsersFromMyOrg() *ChaincodeQuery {
 	return i
 }
 
+// WithEndorsersFromChannelPeers sets the MSP IDs of the organizations whose peers must endorse.
+// The peers of other organizations are taken from the channel configuration, see MSPManager#ChannelPeers.
+func (i *ChaincodeQuery) WithEndorsersFromChannelPeers(mspIDs ...string) *ChaincodeQuery {
+	i.ChaincodeInvocation.WithEndorsersFromChannelPeers(mspIDs...)
+	return i
+}
+
 func (i *ChaincodeQuery) WithInvokerIdentity(id view.Identity) *ChaincodeQuery {
 	i.ChaincodeInvocation.WithSignerIdentity(id)
 	return i
@@ -238,6 +261,13 @@ func (i *ChaincodeQuery) WithRetrySleep(duration time.Duration) *ChaincodeQuery
 	return i
 }
 
+// WithNoCache sends the query to the peers even if the query cache of the chaincode is enabled.
+// The result is not cached.
+func (i *ChaincodeQuery) WithNoCache() *ChaincodeQuery {
+	i.ChaincodeInvocation.WithNoCache()
+	return i
+}
+
 type ChaincodeEndorse struct {
 	ChaincodeInvocation driver.ChaincodeInvocation
 }
@@ -265,6 +295,13 @@ func (i *ChaincodeEndorse) WithEndorsersFromMyOrg() *ChaincodeEndorse {
 	return i
 }
 
+// WithEndorsersFromChannelPeers sets the MSP IDs of the organizations whose peers must endorse.
+// The peers of other organizations are taken from the channel configuration, see MSPManager#ChannelPeers.
+func (i *ChaincodeEndorse) WithEndorsersFromChannelPeers(mspIDs ...string) *ChaincodeEndorse {
+	i.ChaincodeInvocation.WithEndorsersFromChannelPeers(mspIDs...)
+	return i
+}
+
 func (i *ChaincodeEndorse) WithInvokerIdentity(id view.Identity) *ChaincodeEndorse {
 	i.ChaincodeInvocation.WithSignerIdentity(id)
 	return i
@@ -294,3 +331,9 @@ func (i *ChaincodeEndorse) WithRetrySleep(duration time.Duration) *ChaincodeEndo
 	i.ChaincodeInvocation.WithRetrySleep(duration)
 	return i
 }
+
+// WithContext sets the context of the flow running the endorsement, to whose endorsement phase it is accounted
+func (i *ChaincodeEndorse) WithContext(ctx context.Context) *ChaincodeEndorse {
+	i.ChaincodeInvocation.WithContext(ctx)
+	return i
+}
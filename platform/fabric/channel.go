@@ -7,8 +7,11 @@ SPDX-License-Identifier: Apache-2.0
 package fabric
 
 import (
+	"time"
+
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/pkg/errors"
 )
 
 type Channel struct {
@@ -27,7 +30,11 @@ func (c *Channel) Name() string {
 }
 
 func (c *Channel) Vault() *Vault {
-	return &Vault{ch: c.ch}
+	return &Vault{
+		ch:         c.ch,
+		guard:      namespaceGuard(c.sp, c.fns.Name(), c.ch.Name()),
+		writeGuard: namespaceWriteGuard(c.sp, c.fns.Name(), c.ch.Name()),
+	}
 }
 
 func (c *Channel) Ledger() *Ledger {
@@ -50,7 +57,7 @@ func (c *Channel) Chaincode(name string) *Chaincode {
 	return &Chaincode{
 		fns:           c.fns,
 		chaincode:     c.ch.Chaincode(name),
-		EventListener: newEventListener(c.sp, name),
+		EventListener: newEventListener(c.sp, c.ch, name),
 	}
 }
 
@@ -65,3 +72,118 @@ func (c *Channel) MetadataService() *MetadataService {
 func (c *Channel) EnvelopeService() *EnvelopeService {
 	return &EnvelopeService{ms: c.ch.EnvelopeService()}
 }
+
+type (
+	ConfigUpdateSimulation = driver.ConfigUpdateSimulation
+	ConfigDiff             = driver.ConfigDiff
+	ConfigError            = driver.ConfigError
+)
+
+// SimulateConfigUpdate validates the passed envelope of type CONFIG_UPDATE against the active configuration of the channel,
+// as it would be validated once committed, and returns the changes it would make. Nothing is committed.
+func (c *Channel) SimulateConfigUpdate(envelope []byte) (*ConfigUpdateSimulation, error) {
+	s, ok := c.ch.(driver.ConfigUpdateSimulator)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not support simulating config updates", c.ch.Name())
+	}
+	return s.SimulateConfigUpdate(envelope)
+}
+
+type ChannelDescription = driver.ChannelDescription
+
+// Describe returns what is bound to the channel: the chaincodes, the subscriptions to their events and the config sequence in force
+func (c *Channel) Describe() (*ChannelDescription, error) {
+	i, ok := c.ch.(driver.ChannelInspector)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not support introspection", c.ch.Name())
+	}
+	return i.Describe(), nil
+}
+
+// Evidence packages the artifacts proving that a transaction has been committed, see services/evidence to verify it
+type Evidence = driver.Evidence
+
+// EvidenceBundle returns the evidence that the passed transaction has been committed on this channel,
+// verifiable without access to this node
+func (c *Channel) EvidenceBundle(txID string) (*Evidence, error) {
+	p, ok := c.ch.(driver.EvidenceProvider)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not support evidence bundles", c.ch.Name())
+	}
+	return p.EvidenceBundle(txID)
+}
+
+// Receipt is the statement, signed by this node, that a transaction has been committed as valid or invalid,
+// see services/receipt to verify it
+type Receipt = driver.Receipt
+
+// ErrNotFinal is returned when a receipt is requested for a transaction neither valid nor invalid
+var ErrNotFinal = driver.ErrNotFinal
+
+// IssueReceipt returns the receipt, signed by the default identity of this node, stating the status of the passed
+// transaction and where it has been committed. It returns ErrNotFinal if the transaction is not final yet.
+func (c *Channel) IssueReceipt(txID string) (*Receipt, error) {
+	i, ok := c.ch.(driver.ReceiptIssuer)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not support receipts", c.ch.Name())
+	}
+	return i.IssueReceipt(txID)
+}
+
+type (
+	// ResolutionPolicy tells when a stuck transaction with no trace on the ledger can be abandoned
+	ResolutionPolicy = driver.ResolutionPolicy
+	// Resolution is the outcome of the resolution of a stuck transaction
+	Resolution = driver.Resolution
+)
+
+// ErrTransactionAbandoned is returned to the waiters of the finality of an abandoned transaction
+var ErrTransactionAbandoned = driver.ErrTransactionAbandoned
+
+// ResolveStuckTransaction resolves the passed transaction stuck in the Busy status. The ledger of the peers has the
+// last word: if the transaction is there, the vault is repaired; otherwise, once the transaction is older than the
+// policy allows, it is abandoned and the waiters of its finality fail with ErrTransactionAbandoned.
+func (c *Channel) ResolveStuckTransaction(txID string, policy ResolutionPolicy) (*Resolution, error) {
+	r, ok := c.ch.(driver.StuckTransactionResolver)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not support resolving stuck transactions", c.ch.Name())
+	}
+	return r.ResolveStuckTransaction(txID, policy)
+}
+
+// OrderingParameters are the parameters of the ordering service of a channel
+type OrderingParameters = driver.OrderingParameters
+
+// OrderingParameters returns the batch timeout, the batch size and the consensus type of the orderers, as set by the
+// active configuration of the channel: they follow the config updates
+func (c *Channel) OrderingParameters() (*OrderingParameters, error) {
+	i, ok := c.ch.(driver.OrderingInspector)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not expose its ordering parameters", c.ch.Name())
+	}
+	return i.OrderingParameters()
+}
+
+// ExpectedOrderingLatency returns a hint of the time a transaction takes from its broadcast to its commit,
+// combining the batch timeout with the latency observed on the transactions this node broadcast.
+// A flow can use it to decide whether to wait for the finality inline or to hand it off.
+func (c *Channel) ExpectedOrderingLatency() (time.Duration, error) {
+	i, ok := c.ch.(driver.OrderingInspector)
+	if !ok {
+		return 0, errors.Errorf("channel [%s] does not expose its ordering parameters", c.ch.Name())
+	}
+	return i.ExpectedOrderingLatency()
+}
+
+// Provenance is the routing of a transaction: the peers that endorsed it and the orderer that received its broadcast
+type Provenance = driver.Provenance
+
+// GetTransactionProvenance returns the provenance of the passed transaction, as recorded by this node when it
+// collected its endorsements and broadcast it. It is recorded whatever the outcome of the validation of the transaction.
+func (c *Channel) GetTransactionProvenance(txID string) (*Provenance, error) {
+	p, ok := c.ch.(driver.ProvenanceProvider)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not support transaction provenance", c.ch.Name())
+	}
+	return p.ProvenanceService().GetTransactionProvenance(txID)
+}
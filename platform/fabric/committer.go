@@ -9,6 +9,8 @@ package fabric
 import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
 )
 
 // TxStatusChangeListener is the interface that must be implemented to receive transaction status change notifications
@@ -17,6 +19,36 @@ type TxStatusChangeListener interface {
 	OnStatusChange(txID string, status int) error
 }
 
+// ValidationOutcome refines the validation code of a transaction with the reason of its validity, or invalidity
+type ValidationOutcome = driver.ValidationOutcome
+
+const (
+	OutcomeNone                     = driver.OutcomeNone
+	OutcomeValid                    = driver.OutcomeValid
+	OutcomeDiscarded                = driver.OutcomeDiscarded
+	OutcomeDuplicateTxID            = driver.OutcomeDuplicateTxID
+	OutcomeReadConflict             = driver.OutcomeReadConflict
+	OutcomeEndorsementPolicyFailure = driver.OutcomeEndorsementPolicyFailure
+	OutcomeBadSignature             = driver.OutcomeBadSignature
+	OutcomeMalformed                = driver.OutcomeMalformed
+	OutcomeChaincodeMismatch        = driver.OutcomeChaincodeMismatch
+	OutcomeNotValidated             = driver.OutcomeNotValidated
+	OutcomeOther                    = driver.OutcomeOther
+)
+
+// ValidationStatus is the status of a transaction together with the outcome of its validation, see Committer#StatusWithMessage
+type ValidationStatus struct {
+	TxID string
+	Code ValidationCode
+	// Outcome is the reason of the status, OutcomeNone if not known
+	Outcome ValidationOutcome
+	// FabricCode is the validation code the peers assigned to the transaction, it is set only if HasFabricCode is true
+	FabricCode    pb.TxValidationCode
+	HasFabricCode bool
+	// Message describes the outcome, it is empty if there is nothing to add to it
+	Message string
+}
+
 type Committer struct {
 	ch          driver.Channel
 	subscribers *events.Subscribers
@@ -38,6 +70,47 @@ func (c *Committer) Status(txid string) (ValidationCode, []string, error) {
 	return ValidationCode(vc), deps, err
 }
 
+// StatusWithHeight returns a validation code this committer bind to the passed transaction id, plus
+// the number of the block and the position in the block where the transaction has been committed, or invalidated.
+// If the height is not known, UnknownBlock and UnknownTxNum are returned.
+func (c *Committer) StatusWithHeight(txid string) (ValidationCode, uint64, int, error) {
+	vc, block, txNum, err := c.ch.StatusWithHeight(txid)
+	return ValidationCode(vc), block, txNum, err
+}
+
+// StatusWithMessage returns the status of the passed transaction together with the outcome of its validation and,
+// if known, the validation code the peers assigned to it. The invalid transactions keep the Invalid code,
+// the outcome tells why they are.
+func (c *Committer) StatusWithMessage(txid string) (*ValidationStatus, error) {
+	oc, ok := c.ch.(driver.OutcomeCommitter)
+	if !ok {
+		return nil, errors.Errorf("committer of channel [%s] does not support the outcomes of the transactions", c.ch.Name())
+	}
+	s, err := oc.StatusWithMessage(txid)
+	if err != nil {
+		return nil, err
+	}
+	return &ValidationStatus{
+		TxID:          s.TxID,
+		Code:          ValidationCode(s.Code),
+		Outcome:       s.Outcome,
+		FabricCode:    s.FabricCode,
+		HasFabricCode: s.HasFabricCode,
+		Message:       s.Message,
+	}, nil
+}
+
+// AddStateWriteListener registers the passed listener, invoked synchronously from the commit pipeline
+// with the keys written by each valid transaction, in commit order
+func (c *Committer) AddStateWriteListener(listener driver.StateWriteListener) error {
+	n, ok := c.ch.(driver.StateWriteNotifier)
+	if !ok {
+		return errors.Errorf("committer of channel [%s] does not support state write listeners", c.ch.Name())
+	}
+	n.AddStateWriteListener(listener)
+	return nil
+}
+
 // SubscribeTxStatusChanges registers a listener for transaction status changes for the passed transaction id.
 // If the transaction id is empty, the listener will be called for all transactions.
 func (c *Committer) SubscribeTxStatusChanges(txID string, listener TxStatusChangeListener) error {
@@ -6,7 +6,11 @@ SPDX-License-Identifier: Apache-2.0
 
 package fabric
 
-import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+import (
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+)
 
 type ConfigService struct {
 	confService driver.ConfigService
@@ -15,3 +19,23 @@ type ConfigService struct {
 func (s *ConfigService) GetString(key string) string {
 	return s.confService.GetString(key)
 }
+
+// GetDuration returns the value associated with the key as a duration
+func (s *ConfigService) GetDuration(key string) time.Duration {
+	return s.confService.GetDuration(key)
+}
+
+// IsSet checks to see if the key has been set in any of the data locations
+func (s *ConfigService) IsSet(key string) bool {
+	return s.confService.IsSet(key)
+}
+
+// UnmarshalKey takes a single key and unmarshals it into a Struct
+func (s *ConfigService) UnmarshalKey(key string, rawVal interface{}) error {
+	return s.confService.UnmarshalKey(key, rawVal)
+}
+
+// TranslatePath translates the passed path relative to the config path
+func (s *ConfigService) TranslatePath(path string) string {
+	return s.confService.TranslatePath(path)
+}
@@ -12,6 +12,15 @@ import (
 	"github.com/pkg/errors"
 )
 
+// platformKeys are the keys of the `fabric` section configuring the platform, and not a network
+var platformKeys = map[string]bool{
+	"enabled":        true,
+	"committer":      true,
+	"reconciliation": true,
+	"statesharing":   true,
+	"statediff":      true,
+}
+
 type ConfigProvider interface {
 	UnmarshalKey(key string, rawVal interface{}) error
 }
@@ -31,7 +40,7 @@ func NewConfig(configProvider ConfigProvider) (*Config, error) {
 	var defaultName string
 	for k, v := range m {
 		name := k
-		if strings.ToLower(name) != "enabled" {
+		if !platformKeys[strings.ToLower(name)] {
 			names = append(names, name)
 			// is this default?
 			defaultValue, ok := (v.(map[string]interface{}))["default"]
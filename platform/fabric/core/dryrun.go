@@ -0,0 +1,45 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package core
+
+import (
+	"context"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	driver2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
+	"github.com/pkg/errors"
+)
+
+// Validator is implemented by the network drivers able to validate the configuration of a network
+// without instantiating it, see dryrun.Validator
+type Validator interface {
+	Validate(ctx context.Context, sp view.ServiceProvider, config driver2.ConfigService, network string, defaultNetwork bool, opts *dryrun.Options, report *dryrun.Report)
+}
+
+// Validate validates the configuration of all the fabric networks with the registered drivers implementing Validator
+func Validate(ctx context.Context, sp view.ServiceProvider, config driver2.ConfigService, opts *dryrun.Options, report *dryrun.Report) {
+	fnsConfig, err := NewConfig(config)
+	if err != nil {
+		report.Add("fabric", "config", "", errors.WithMessagef(err, "failed parsing configuration"))
+		return
+	}
+	if len(fnsConfig.Names()) == 0 {
+		report.Add("fabric", "config", "", errors.New("no fabric network names found"))
+		return
+	}
+
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	for _, name := range fnsConfig.Names() {
+		for _, d := range drivers {
+			if v, ok := d.(Validator); ok {
+				v.Validate(ctx, sp, config, name, name == fnsConfig.DefaultName(), opts, report)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	checkpointPrefix = "vault-checkpoint"
+
+	// DefaultResyncTimeout is the maximum amount of time to wait, on startup, for a vault restored from a backup
+	// to catch up with its checkpoint
+	DefaultResyncTimeout = 10 * time.Minute
+	resyncPollInterval   = 100 * time.Millisecond
+)
+
+// PauseCommits drains the in-flight block commits of this channel and blocks new ones until resume is called.
+// See vault.Vault#PauseCommits.
+func (c *channel) PauseCommits(ctx context.Context) (func(), error) {
+	return c.vault.PauseCommits(ctx)
+}
+
+// AddSnapshotHook registers a hook invoked every time it is safe to snapshot the vault of this channel
+func (c *channel) AddSnapshotHook(hook func(height uint64) error) {
+	c.vault.AddSnapshotHook(hook)
+}
+
+// commitBlock commits the passed block in the vault. The vault records its height with the commits of the
+// transactions of the block, SetHeight records it for the blocks without transactions stored by the vault.
+// The checkpoint is stored outside the vault, in the KVS, so that a vault restored from an older backup
+// can be detected on startup, see resync. The checkpoint is written with the commit provenance of the transactions
+// of the block and the journal of the events of the block, in a single unit of work, once the vault has committed
+// the block: after a crash in between, the delivery restarts from the block of the last transaction committed,
+// and the block is committed again, its events included.
+func (c *channel) commitBlock(block *common.Block) error {
+	c.vault.BeginBlockCommit()
+	defer c.vault.EndBlockCommit()
+
+	uow := bookkeeping.Begin(c.sp)
+	defer uow.Discard()
+	committed := false
+	c.sinks.Enlist(uow)
+	defer func() { c.sinks.Release(committed) }()
+
+	if err := c.committer.Commit(block); err != nil {
+		return err
+	}
+	if err := c.commitBookkeeping(block, uow); err != nil {
+		return err
+	}
+	committed = true
+	if err := c.vault.SetHeight(block.Header.Number); err != nil {
+		return errors.WithMessagef(err, "failed updating vault height to [%d]", block.Header.Number)
+	}
+	c.verifyIntegrityEvery(block.Header.Number)
+	return nil
+}
+
+// resync waits for a vault restored from a backup to catch up with the last checkpoint.
+// The checkpoint is written once its block is fully committed, while the height of the vault is the block
+// of its last transaction committed: a vault at or past the checkpoint is not behind, even if the block at its
+// height is committed only in part. The delivery service restarts from the last transaction known by the vault,
+// therefore the missing blocks, and the rest of a block committed in part, are fetched again from the peer.
+func (c *channel) resync(ctx context.Context) error {
+	checkpoint, err := c.checkpoint()
+	if err != nil {
+		return err
+	}
+	height, err := c.vault.Height()
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting vault height")
+	}
+	if height >= checkpoint {
+		return nil
+	}
+
+	logger.Warnf("vault [%s:%s] at height [%d] is behind its checkpoint [%d], it might have been restored from a backup, resync...",
+		c.network.Name(), c.name, height, checkpoint)
+	timeout := c.config.VaultResyncTimeout()
+	if timeout <= 0 {
+		timeout = DefaultResyncTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(resyncPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "vault [%s:%s] did not reach checkpoint [%d], stuck at height [%d]", c.network.Name(), c.name, checkpoint, height)
+		case <-ticker.C:
+			height, err = c.vault.Height()
+			if err != nil {
+				return errors.WithMessagef(err, "failed getting vault height")
+			}
+			if height >= checkpoint {
+				logger.Infof("vault [%s:%s] resynced up to height [%d]", c.network.Name(), c.name, height)
+				return nil
+			}
+		}
+	}
+}
+
+func (c *channel) checkpoint() (uint64, error) {
+	k := c.checkpointKey()
+	kvss := kvs.GetService(c.sp)
+	if !kvss.Exists(k) {
+		return 0, nil
+	}
+	var checkpoint uint64
+	if err := kvss.Get(k, &checkpoint); err != nil {
+		return 0, errors.WithMessagef(err, "failed loading checkpoint")
+	}
+	return checkpoint, nil
+}
+
+func (c *channel) checkpointKey() string {
+	return kvs.CreateCompositeKeyOrPanic(checkpointPrefix, []string{c.network.Name(), c.name})
+}
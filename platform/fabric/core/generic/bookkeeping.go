@@ -0,0 +1,50 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// commitBookkeeping records, in the passed unit of work, the checkpoint of the passed block and the commit of its
+// transactions in their provenance, for those whose provenance is recorded, and commits it
+func (c *channel) commitBookkeeping(block *common.Block, uow bookkeeping.UnitOfWork) error {
+	if provenance := c.Enlist(uow).Provenance; provenance != nil && block.Data != nil {
+		var flags ValidationFlags
+		if block.Metadata != nil && len(block.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+			flags = block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+		}
+		for i, raw := range block.Data.Data {
+			env, err := protoutil.UnmarshalEnvelope(raw)
+			if err != nil {
+				return errors.Wrapf(err, "failed unmarshalling transaction [%d] of block [%d]", i, block.Header.Number)
+			}
+			chdr, err := protoutil.ChannelHeader(env)
+			if err != nil {
+				return errors.Wrapf(err, "failed reading the channel header of transaction [%d] of block [%d]", i, block.Header.Number)
+			}
+			code := int32(pb.TxValidationCode_NOT_VALIDATED)
+			if i < len(flags) {
+				code = int32(flags[i])
+			}
+			if err := provenance.RecordCommit(chdr.TxId, block.Header.Number, i, code); err != nil {
+				return errors.WithMessagef(err, "failed recording the commit of [%s]", chdr.TxId)
+			}
+		}
+	}
+	if err := uow.Put(c.checkpointKey(), block.Header.Number); err != nil {
+		return errors.WithMessagef(err, "failed storing checkpoint [%d]", block.Header.Number)
+	}
+	if err := uow.Commit(); err != nil {
+		return errors.WithMessagef(err, "failed committing the bookkeeping of block [%d]", block.Header.Number)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	driver2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	mem "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	mock2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// crashAt fails the SetState with this number, counted from when it is set, 0 for none
+var crashAt, setStates int
+
+type crashingDriver struct {
+	mem.Driver
+}
+
+func (d *crashingDriver) New(sp view2.ServiceProvider, dataSourceName string, config driver2.Config) (driver2.Persistence, error) {
+	p, err := d.Driver.New(sp, dataSourceName, config)
+	if err != nil {
+		return nil, err
+	}
+	return &crashingPersistence{Persistence: p}, nil
+}
+
+type crashingPersistence struct {
+	driver2.Persistence
+}
+
+func (p *crashingPersistence) SetState(namespace, key string, value []byte) error {
+	setStates++
+	if setStates == crashAt {
+		return errors.New("crashed")
+	}
+	return p.Persistence.SetState(namespace, key, value)
+}
+
+func init() {
+	db.Register("crashing-memory", &crashingDriver{})
+}
+
+func TestCommitBookkeeping(t *testing.T) {
+	registry := registry2.New()
+	kvss, err := kvs.NewWithConfig(registry, "crashing-memory", "", &mock2.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+
+	ch := &channel{
+		sp:                registry,
+		name:              "mychannel",
+		network:           &network{name: "default"},
+		provenanceService: transaction.NewProvenanceService(registry, "default", "mychannel", func() uint64 { return 2 }),
+	}
+	// tx1 and tx2 have been endorsed by this node, tx3 is of another node
+	for _, txID := range []string{"tx1", "tx2"} {
+		assert.NoError(t, ch.ProvenanceService().RecordEndorsements(txID, nil))
+	}
+	block := &common.Block{
+		Header: &common.BlockHeader{Number: 5},
+		Data: &common.BlockData{Data: [][]byte{
+			protoutil.MarshalOrPanic(newTxEnvelope("mychannel", "tx1")),
+			protoutil.MarshalOrPanic(newTxEnvelope("mychannel", "tx2")),
+			protoutil.MarshalOrPanic(newTxEnvelope("mychannel", "tx3")),
+		}},
+		Metadata: &common.BlockMetadata{Metadata: [][]byte{{}, {}, {byte(pb.TxValidationCode_VALID), byte(pb.TxValidationCode_MVCC_READ_CONFLICT), byte(pb.TxValidationCode_VALID)}}},
+	}
+
+	// a crash in the middle of the writes leaves none of them
+	for _, at := range []int{1, 2, 3} {
+		crashAt, setStates = at, 0
+		assert.Error(t, ch.commitBookkeeping(block, bookkeeping.Begin(ch.sp)))
+		crashAt = 0
+
+		checkpoint, err := ch.checkpoint()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), checkpoint)
+		// the records are read from the store, bypassing the cache
+		it, err := kvss.GetByPartialCompositeID("provenance", []string{"mychannel", "default"})
+		assert.NoError(t, err)
+		records := 0
+		for it.HasNext() {
+			p := &driver.Provenance{}
+			_, err := it.Next(p)
+			assert.NoError(t, err)
+			assert.Nil(t, p.Commit, "crash at [%d]", at)
+			records++
+		}
+		assert.NoError(t, it.Close())
+		assert.Equal(t, 2, records)
+	}
+
+	assert.NoError(t, ch.commitBookkeeping(block, bookkeeping.Begin(ch.sp)))
+	checkpoint, err := ch.checkpoint()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), checkpoint)
+	p, err := ch.ProvenanceService().GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, &driver.CommitProvenance{Block: 5, IndexInBlock: 0, ValidationCode: int32(pb.TxValidationCode_VALID), ConfigSequence: 2}, p.Commit)
+	p, err = ch.ProvenanceService().GetTransactionProvenance("tx2")
+	assert.NoError(t, err)
+	assert.Equal(t, &driver.CommitProvenance{Block: 5, IndexInBlock: 1, ValidationCode: int32(pb.TxValidationCode_MVCC_READ_CONFLICT), ConfigSequence: 2}, p.Commit)
+	_, err = ch.ProvenanceService().GetTransactionProvenance("tx3")
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/pkg/errors"
+)
+
+const bundleCachePrefix = "fabric-bundle-cache"
+
+// cachedBundle is the compact representation of the last configuration applied to a channel, stored in the KVS,
+// so that a restart builds the bundle of the channel once, without validating again all its config transactions
+type cachedBundle struct {
+	Sequence uint64
+	// EnvelopeHash is the hash of the config transaction of the sequence stored in the vault,
+	// the cache is trusted only if it matches
+	EnvelopeHash []byte
+	// Config is the validated configuration, a marshalled common.Config, the cache is trusted only if it is the one
+	// of the config transaction
+	Config []byte
+	// Orderers are the endpoints of the orderers derived from the configuration
+	Orderers []string
+}
+
+// cacheBundle stores the bundle of the passed config transaction in the cache, the failures are logged only:
+// the next restart validates again all the config transactions
+func (c *channel) cacheBundle(sequence uint64, envelope []byte, bundle *channelconfig.Bundle) {
+	config, err := proto.Marshal(bundle.ConfigtxValidator().ConfigProto())
+	if err != nil {
+		logger.Warnf("[channel: %s] failed marshalling config [%d] to cache: [%s]", c.name, sequence, err)
+		return
+	}
+	hash := sha256.Sum256(envelope)
+	entry := &cachedBundle{
+		Sequence:     sequence,
+		EnvelopeHash: hash[:],
+		Config:       config,
+		Orderers:     ordererEndpoints(bundle),
+	}
+	if err := kvs.GetService(c.sp).Put(c.bundleCacheKey(), entry); err != nil {
+		logger.Warnf("[channel: %s] failed caching config [%d]: [%s]", c.name, sequence, err)
+	}
+}
+
+// cachedBundle returns the bundle of the passed config transaction, raw and unmarshalled, once validated as cached,
+// nil if not cached. The bundle is built from the configuration of the config transaction, the cache tells that
+// it has been validated already. An error is returned if the cache does not match the config transaction,
+// it cannot be trusted.
+func (c *channel) cachedBundle(sequence uint64, envelope []byte, ctx *common.ConfigEnvelope) (*channelconfig.Bundle, error) {
+	kvss := kvs.GetService(c.sp)
+	if !kvss.Exists(c.bundleCacheKey()) {
+		return nil, nil
+	}
+	entry := &cachedBundle{}
+	if err := kvss.Get(c.bundleCacheKey(), entry); err != nil {
+		return nil, errors.WithMessagef(err, "failed loading cached config")
+	}
+	if entry.Sequence != sequence {
+		return nil, errors.Errorf("cached config has sequence [%d], the last config transaction [%d]", entry.Sequence, sequence)
+	}
+	hash := sha256.Sum256(envelope)
+	if !bytes.Equal(hash[:], entry.EnvelopeHash) {
+		return nil, errors.Errorf("cached config [%d] does not match the hash of its config transaction", sequence)
+	}
+	config := &common.Config{}
+	if err := proto.Unmarshal(entry.Config, config); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling cached config [%d]", sequence)
+	}
+	if ctx.Config == nil || ctx.Config.ChannelGroup == nil {
+		return nil, errors.Errorf("config transaction [%d] without channel group", sequence)
+	}
+	if !proto.Equal(config, ctx.Config) {
+		return nil, errors.Errorf("cached config [%d] is not the one of its config transaction", sequence)
+	}
+	bundle, err := newBundle(c.name, ctx.Config, c.cryptoProvider)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed building the bundle of cached config [%d]", sequence)
+	}
+	if orderers := ordererEndpoints(bundle); strings.Join(orderers, ",") != strings.Join(entry.Orderers, ",") {
+		return nil, errors.Errorf("cached config [%d] has orderers [%s], its bundle [%s]", sequence, strings.Join(entry.Orderers, ", "), strings.Join(orderers, ", "))
+	}
+	return bundle, nil
+}
+
+func (c *channel) bundleCacheKey() string {
+	return kvs.CreateCompositeKeyOrPanic(bundleCachePrefix, []string{c.network.Name(), c.name})
+}
+
+// ordererEndpoints returns, sorted, the endpoints of the orderers of the passed configuration,
+// those of the orderer organizations and the global ones
+func ordererEndpoints(res channelconfig.Resources) []string {
+	var endpoints []string
+	if oc, ok := res.OrdererConfig(); ok {
+		for _, org := range oc.Organizations() {
+			endpoints = append(endpoints, org.Endpoints()...)
+		}
+	}
+	if cc := res.ChannelConfig(); cc != nil {
+		endpoints = append(endpoints, cc.OrdererAddresses()...)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/rwset"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundleCache(t *testing.T) {
+	n, _ := newRemovableNetwork(t)
+	c, err := n.Channel("mychannel")
+	assert.NoError(t, err)
+	ch := c.(*channel)
+	ch.cryptoProvider, err = (&factory.SWFactory{}).Get(factory.GetDefaultOpts())
+	assert.NoError(t, err)
+	ch.configSequence = newConfigSequence(ch.name, nil)
+
+	// a config transaction stored in the vault
+	envelope := mspConfigEnvelope(t)
+	raw := protoutil.MarshalOrPanic(&common.Envelope{Payload: protoutil.MarshalOrPanic(&common.Payload{
+		Header: &common.Header{ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{Type: int32(common.HeaderType_CONFIG)})},
+		Data:   protoutil.MarshalOrPanic(envelope),
+	})})
+	rws, err := ch.vault.NewRWSet("configtx_1")
+	assert.NoError(t, err)
+	key, err := rwset.CreateCompositeKey(channelConfigKey, []string{"1"})
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState(peerNamespace, key, raw))
+	rws.Done()
+	assert.NoError(t, ch.vault.CommitTX("configtx_1", 1, 0))
+
+	// the first restart validates the config transactions and fills the cache
+	cached, err := ch.reloadConfigTransactions()
+	assert.NoError(t, err)
+	assert.False(t, cached)
+	entry := &cachedBundle{}
+	assert.NoError(t, kvs.GetService(n.sp).Get(ch.bundleCacheKey(), entry))
+	assert.Equal(t, uint64(1), entry.Sequence)
+	assert.Equal(t, []string{"orderer.example.com:7050"}, entry.Orderers)
+
+	// the next restarts build the bundle from the cache
+	ch.resources = nil
+	cached, err = ch.reloadConfigTransactions()
+	assert.NoError(t, err)
+	assert.True(t, cached)
+	assert.Equal(t, []string{"orderer.example.com:7050"}, ordererEndpoints(ch.Resources()))
+
+	// a valid configuration, with the same orderers, that is not the one of the config transaction
+	other := proto.Clone(envelope.Config).(*common.Config)
+	other.ChannelGroup.Version++
+	_, err = newBundle(ch.name, other, ch.cryptoProvider)
+	assert.NoError(t, err)
+
+	// a corrupted cache is never trusted, it is rebuilt
+	for _, corrupt := range []func(entry *cachedBundle){
+		func(entry *cachedBundle) { entry.EnvelopeHash[0]++ },
+		func(entry *cachedBundle) { entry.Config = []byte("corrupted") },
+		func(entry *cachedBundle) { entry.Config = protoutil.MarshalOrPanic(other) },
+		func(entry *cachedBundle) { entry.Orderers = []string{"evil.example.com:7050"} },
+		func(entry *cachedBundle) { entry.Sequence = 2 },
+	} {
+		corrupted := &cachedBundle{}
+		assert.NoError(t, kvs.GetService(n.sp).Get(ch.bundleCacheKey(), corrupted))
+		corrupt(corrupted)
+		assert.NoError(t, kvs.GetService(n.sp).Put(ch.bundleCacheKey(), corrupted))
+		_, err := ch.cachedBundle(1, raw, envelope)
+		assert.Error(t, err)
+
+		ch.resources = nil
+		cached, err = ch.reloadConfigTransactions()
+		assert.NoError(t, err)
+		assert.False(t, cached)
+		rebuilt := &cachedBundle{}
+		assert.NoError(t, kvs.GetService(n.sp).Get(ch.bundleCacheKey(), rebuilt))
+		assert.Equal(t, entry.Sequence, rebuilt.Sequence)
+		assert.Equal(t, entry.EnvelopeHash, rebuilt.EnvelopeHash)
+		assert.Equal(t, entry.Orderers, rebuilt.Orderers)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 // Chaincode returns a chaincode handler for the passed chaincode name
 func (c *channel) Chaincode(name string) driver.Chaincode {
+	c.touch()
 	c.chaincodesLock.RLock()
 	ch, ok := c.chaincodes[name]
 	if ok {
@@ -27,7 +28,19 @@ func (c *channel) Chaincode(name string) driver.Chaincode {
 	if ok {
 		return ch
 	}
-	ch = chaincode.NewChaincode(name, c.sp, c.network, c)
+	ch = chaincode.NewChaincode(name, c.sp, c.network, c, c.network.queryCacheMetrics)
 	c.chaincodes[name] = ch
 	return ch
 }
+
+// invalidateQueries drops the cached query results of the chaincodes whose namespaces have been written
+func (c *channel) invalidateQueries(txID string, namespaces []string) {
+	c.chaincodesLock.RLock()
+	defer c.chaincodesLock.RUnlock()
+	for _, ns := range namespaces {
+		if ch, ok := c.chaincodes[ns].(*chaincode.Chaincode); ok {
+			logger.Debugf("[%s] transaction [%s] writes to [%s], invalidating its cached queries", c.name, txID, ns)
+			ch.InvalidateQueries()
+		}
+	}
+}
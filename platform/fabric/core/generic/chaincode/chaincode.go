@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/ReneKroon/ttlcache/v2"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
 )
@@ -25,10 +26,13 @@ type Chaincode struct {
 
 	discoveryResultsCacheLock sync.RWMutex
 	discoveryResultsCache     ttlcache.SimpleCache
+
+	// queryCache, if enabled in the configuration, caches the query results
+	queryCache *QueryCache
 }
 
-func NewChaincode(name string, sp view.ServiceProvider, network Network, channel Channel) *Chaincode {
-	return &Chaincode{
+func NewChaincode(name string, sp view.ServiceProvider, network Network, channel Channel, queryCacheMetrics *QueryCacheMetrics) *Chaincode {
+	c := &Chaincode{
 		name:                      name,
 		sp:                        sp,
 		network:                   network,
@@ -38,6 +42,11 @@ func NewChaincode(name string, sp view.ServiceProvider, network Network, channel
 		discoveryResultsCacheLock: sync.RWMutex{},
 		discoveryResultsCache:     ttlcache.NewCache(),
 	}
+	if conf := c.config(); conf != nil && conf.QueryCache != nil && conf.QueryCache.Enabled {
+		c.queryCache = NewQueryCache(conf.QueryCache.TTL, queryCacheMetrics, "network", network.Name(), "channel", channel.Name(), "chaincode", name)
+		logger.Debugf("query cache enabled for chaincode [%s:%s:%s]", network.Name(), channel.Name(), name)
+	}
+	return c
 }
 
 func (c *Chaincode) NewInvocation(function string, args ...interface{}) driver.ChaincodeInvocation {
@@ -57,22 +66,39 @@ func (c *Chaincode) IsAvailable() (bool, error) {
 }
 
 func (c *Chaincode) IsPrivate() bool {
+	conf := c.config()
+	if conf == nil {
+		// Nothing was found
+		return false
+	}
+	return conf.Private
+}
+
+// InvalidateQueries drops the cached query results of this chaincode, if any.
+// It is invoked when a transaction writing to the namespace of this chaincode commits.
+func (c *Chaincode) InvalidateQueries() {
+	if c.queryCache != nil {
+		c.queryCache.Invalidate()
+	}
+}
+
+// config returns the configuration of this chaincode, nil if there is none
+func (c *Chaincode) config() *config.Chaincode {
 	channels, err := c.network.Config().Channels()
 	if err != nil {
-		logger.Error("failed getting channels' configurations [%s]", err)
-		return false
+		logger.Errorf("failed getting channels' configurations [%s]", err)
+		return nil
 	}
 	for _, channel := range channels {
 		if channel.Name == c.channel.Name() {
 			for _, chaincode := range channel.Chaincodes {
 				if chaincode.Name == c.name {
-					return chaincode.Private
+					return chaincode
 				}
 			}
 		}
 	}
-	// Nothing was found
-	return false
+	return nil
 }
 
 // Version returns the version of this chaincode.
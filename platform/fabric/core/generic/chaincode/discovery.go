@@ -13,6 +13,7 @@ import (
 
 	peer2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/peer"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	discovery2 "github.com/hyperledger/fabric-protos-go/discovery"
 	"github.com/hyperledger/fabric-protos-go/peer"
@@ -274,6 +275,7 @@ func (d *Discovery) toDiscoveredPeers(configResult *discovery2.ConfigResult, end
 			tlsRootCerts = append(tlsRootCerts, mspInfo.GetTlsRootCerts()...)
 			tlsRootCerts = append(tlsRootCerts, mspInfo.GetTlsIntermediateCerts()...)
 		}
+		grpc.RecordEndpointSource(member.Endpoint, "discovery of channel ["+d.chaincode.channel.Name()+"]")
 		discoveredEndorsers = append(discoveredEndorsers, driver.DiscoveredPeer{
 			Identity:     peer.Identity,
 			MSPID:        peer.MSPID,
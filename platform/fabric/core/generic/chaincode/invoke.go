@@ -9,7 +9,9 @@ package chaincode
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"math/rand"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,12 +45,15 @@ type Invoke struct {
 	ImplicitCollectionMSPIDs       []string
 	EndorsersFromMyOrg             bool
 	EndorsersByConnConfig          []*grpc.ConnectionConfig
+	EndorsersFromChannelPeers      []string
 	DiscoveredEndorsersByEndpoints []string
 	Function                       string
 	Args                           []interface{}
 	MatchEndorsementPolicy         bool
 	NumRetries                     int
 	RetrySleep                     time.Duration
+	NoCache                        bool
+	Context                        context.Context
 }
 
 func NewInvoke(chaincode *Chaincode, function string, args ...interface{}) *Invoke {
@@ -66,6 +71,9 @@ func NewInvoke(chaincode *Chaincode, function string, args ...interface{}) *Invo
 }
 
 func (i *Invoke) Endorse() (driver.Envelope, error) {
+	if err := i.checkWritable("endorse"); err != nil {
+		return nil, err
+	}
 	for j := 0; j < i.NumRetries; j++ {
 		res, err := i.endorse()
 		if err != nil {
@@ -81,10 +89,12 @@ func (i *Invoke) Endorse() (driver.Envelope, error) {
 }
 
 func (i *Invoke) endorse() (driver.Envelope, error) {
-	_, prop, responses, signer, err := i.prepare(false)
+	defer view.TrackPhase(i.Context, view.PhaseEndorsement)()
+	txID, prop, responses, endorsements, signer, err := i.prepare(false)
 	if err != nil {
 		return nil, err
 	}
+	i.recordEndorsements(txID, endorsements)
 
 	proposalResp := responses[0]
 	if proposalResp == nil {
@@ -101,6 +111,29 @@ func (i *Invoke) endorse() (driver.Envelope, error) {
 }
 
 func (i *Invoke) Query() ([]byte, error) {
+	cache := i.Chaincode.queryCache
+	// the transient entries are not part of the key, the queries with them are not cached
+	if cache == nil || i.NoCache || len(i.TransientMap) != 0 {
+		return i.queryWithRetries()
+	}
+	args, err := i.prepareArgs()
+	if err != nil {
+		return nil, err
+	}
+	key := QueryCacheKey(i.Function, args, i.SignerIdentity)
+	res, generation, ok := cache.Get(key)
+	if ok {
+		return res, nil
+	}
+	res, err = i.queryWithRetries()
+	if err != nil {
+		return nil, err
+	}
+	cache.Put(key, generation, res)
+	return res, nil
+}
+
+func (i *Invoke) queryWithRetries() ([]byte, error) {
 	for j := 0; j < i.NumRetries; j++ {
 		res, err := i.query()
 		if err != nil {
@@ -116,7 +149,7 @@ func (i *Invoke) Query() ([]byte, error) {
 }
 
 func (i *Invoke) query() ([]byte, error) {
-	_, _, responses, _, err := i.prepare(!i.MatchEndorsementPolicy)
+	_, _, responses, _, _, err := i.prepare(!i.MatchEndorsementPolicy)
 	if err != nil {
 		return nil, err
 	}
@@ -153,7 +186,25 @@ func (i *Invoke) query() ([]byte, error) {
 	return resp.Response.Payload, nil
 }
 
+// QueryResponses queries the endorsers, as Query does, and returns their responses, signed by them.
+// The responses are not cached.
+func (i *Invoke) QueryResponses() ([]*pb.ProposalResponse, error) {
+	_, _, responses, _, _, err := i.prepare(!i.MatchEndorsementPolicy)
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range responses {
+		if resp == nil || resp.Endorsement == nil || resp.Response == nil {
+			return nil, errors.New("endorsement failure during query: incomplete proposal response")
+		}
+	}
+	return responses, nil
+}
+
 func (i *Invoke) Submit() (string, []byte, error) {
+	if err := i.checkWritable("submit"); err != nil {
+		return "", nil, err
+	}
 	for j := 0; j < i.NumRetries; j++ {
 		txID, res, err := i.submit()
 		if err != nil {
@@ -169,10 +220,13 @@ func (i *Invoke) Submit() (string, []byte, error) {
 }
 
 func (i *Invoke) submit() (string, []byte, error) {
-	txID, prop, responses, signer, err := i.prepare(false)
+	endorsed := view.TrackPhase(i.Context, view.PhaseEndorsement)
+	txID, prop, responses, endorsements, signer, err := i.prepare(false)
+	endorsed()
 	if err != nil {
 		return "", nil, err
 	}
+	i.recordEndorsements(txID, endorsements)
 
 	proposalResp := responses[0]
 	if proposalResp == nil {
@@ -238,6 +292,14 @@ func (i *Invoke) WithEndorsersByConnConfig(ccs ...*grpc.ConnectionConfig) driver
 	return i
 }
 
+// WithEndorsersFromChannelPeers sets the MSP IDs of the organizations whose peers must endorse.
+// The peers of other organizations are taken from the channel configuration, see driver.ChannelMembership#ChannelPeers,
+// the peers of the invoker's organization from the configuration of this node.
+func (i *Invoke) WithEndorsersFromChannelPeers(mspIDs ...string) driver.ChaincodeInvocation {
+	i.EndorsersFromChannelPeers = mspIDs
+	return i
+}
+
 func (i *Invoke) WithImplicitCollections(mspIDs ...string) driver.ChaincodeInvocation {
 	i.ImplicitCollectionMSPIDs = mspIDs
 	return i
@@ -258,7 +320,18 @@ func (i *Invoke) WithRetrySleep(duration time.Duration) driver.ChaincodeInvocati
 	return i
 }
 
-func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalResponse, driver.SigningIdentity, error) {
+func (i *Invoke) WithNoCache() driver.ChaincodeInvocation {
+	i.NoCache = true
+	return i
+}
+
+func (i *Invoke) WithContext(ctx context.Context) driver.ChaincodeInvocation {
+	i.Context = ctx
+	return i
+}
+
+// prepare collects the endorsements of the invocation, along with their provenance
+func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalResponse, []driver.EndorserProvenance, driver.SigningIdentity, error) {
 	// TODO: improve by providing grpc connection pool
 	var peerClients []peer2.Client
 	defer func() {
@@ -268,14 +341,14 @@ func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalRespon
 	}()
 
 	if i.SignerIdentity.IsNone() {
-		return "", nil, nil, nil, errors.Errorf("no invoker specified")
+		return "", nil, nil, nil, nil, errors.Errorf("no invoker specified")
 	}
 	if len(i.ChaincodeName) == 0 {
-		return "", nil, nil, nil, errors.Errorf("no chaincode specified")
+		return "", nil, nil, nil, nil, errors.Errorf("no chaincode specified")
 	}
 
 	// load endorser clients
-	var endorserClients []pb.EndorserClient
+	var endorserClients []*peerEndorser
 	var discoveredPeers []driver.DiscoveredPeer
 	switch {
 	case len(i.EndorsersByConnConfig) != 0:
@@ -283,7 +356,20 @@ func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalRespon
 		for _, config := range i.EndorsersByConnConfig {
 			peerClient, err := i.Channel.NewPeerClientForAddress(*config)
 			if err != nil {
-				return "", nil, nil, nil, err
+				return "", nil, nil, nil, nil, err
+			}
+			peerClients = append(peerClients, peerClient)
+		}
+	case len(i.EndorsersFromChannelPeers) != 0:
+		// get a peer client for a peer of each organization
+		configs, err := i.channelPeersByMSPIDs(i.EndorsersFromChannelPeers...)
+		if err != nil {
+			return "", nil, nil, nil, nil, err
+		}
+		for _, config := range configs {
+			peerClient, err := i.Channel.NewPeerClientForAddress(*config)
+			if err != nil {
+				return "", nil, nil, nil, nil, errors.WithMessagef(err, "error getting endorser client for %s", config.Address)
 			}
 			peerClients = append(peerClients, peerClient)
 		}
@@ -292,7 +378,7 @@ func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalRespon
 			// retrieve invoker's MSP-ID
 			invokerMSPID, err := i.Channel.MSPManager().DeserializeIdentity(i.SignerIdentity)
 			if err != nil {
-				return "", nil, nil, nil, errors.WithMessagef(err, "failed to deserializer the invoker identity")
+				return "", nil, nil, nil, nil, errors.WithMessagef(err, "failed to deserializer the invoker identity")
 			}
 			i.EndorsersMSPIDs = []string{invokerMSPID.GetMSPIdentifier()}
 		}
@@ -312,7 +398,7 @@ func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalRespon
 		}
 		peers, err := discovery.Call()
 		if err != nil {
-			return "", nil, nil, nil, err
+			return "", nil, nil, nil, nil, err
 		}
 		if len(i.DiscoveredEndorsersByEndpoints) != 0 {
 			for _, peer := range peers {
@@ -337,7 +423,7 @@ func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalRespon
 			TLSRootCertBytes: peer.TLSRootCerts,
 		})
 		if err != nil {
-			return "", nil, nil, nil, errors.WithMessagef(err, "error getting endorser client for %s", peer.Endpoint)
+			return "", nil, nil, nil, nil, errors.WithMessagef(err, "error getting endorser client for %s", peer.Endpoint)
 		}
 		peerClients = append(peerClients, peerClient)
 	}
@@ -346,38 +432,42 @@ func (i *Invoke) prepare(query bool) (string, *pb.Proposal, []*pb.ProposalRespon
 	for _, client := range peerClients {
 		endorserClient, err := client.Endorser()
 		if err != nil {
-			return "", nil, nil, nil, errors.WithMessagef(err, "error getting endorser client for %s", client.Address())
+			return "", nil, nil, nil, nil, errors.WithMessagef(err, "error getting endorser client for %s", client.Address())
 		}
-		endorserClients = append(endorserClients, endorserClient)
+		endorserClients = append(endorserClients, &peerEndorser{EndorserClient: endorserClient, address: client.Address()})
 	}
 	if len(endorserClients) == 0 {
-		return "", nil, nil, nil, errors.New("no endorser clients retrieved with the current filters")
+		return "", nil, nil, nil, nil, errors.New("no endorser clients retrieved with the current filters")
 	}
 
 	// load signer
 	signer, err := i.Network.SignerService().GetSigningIdentity(i.SignerIdentity)
 	if err != nil {
-		return "", nil, nil, nil, err
+		return "", nil, nil, nil, nil, err
 	}
 
 	// prepare proposal
 	signedProp, prop, txID, err := i.prepareProposal(signer)
 	if err != nil {
-		return "", nil, nil, nil, err
+		return "", nil, nil, nil, nil, err
 	}
 
-	// collect responses
-	responses, err := i.collectResponses(endorserClients, signedProp)
+	// collect responses, verifying the endorsements against the channel MSPs
+	verifier, err := newEndorsementVerifier(i.Network.Config(), i.Channel)
 	if err != nil {
-		return "", nil, nil, nil, errors.Wrapf(err, "failed collecting proposal responses")
+		return "", nil, nil, nil, nil, err
+	}
+	responses, endorsements, err := i.collectResponses(endorserClients, signedProp, verifier)
+	if err != nil {
+		return "", nil, nil, nil, nil, errors.Wrapf(err, "failed collecting proposal responses")
 	}
 
 	if len(responses) == 0 {
 		// this should only happen if some new code has introduced a bug
-		return "", nil, nil, nil, errors.New("no proposal responses received - this might indicate a bug")
+		return "", nil, nil, nil, nil, errors.New("no proposal responses received - this might indicate a bug")
 	}
 
-	return txID, prop, responses, signer, nil
+	return txID, prop, responses, endorsements, signer, nil
 }
 
 func (i *Invoke) prepareProposal(signer SerializableSigner) (*pb.SignedProposal, *pb.Proposal, string, error) {
@@ -440,34 +530,78 @@ func (i *Invoke) createChaincodeProposalWithTxIDAndTransient(typ common.HeaderTy
 	return protoutil.CreateChaincodeProposalWithTxIDNonceAndTransient(txID, typ, channelID, cis, nonce, creator, transientMap)
 }
 
-// collectResponses sends a signed proposal to a set of peers, and gathers all the responses.
-func (i *Invoke) collectResponses(endorserClients []pb.EndorserClient, signedProposal *pb.SignedProposal) ([]*pb.ProposalResponse, error) {
-	responsesCh := make(chan *pb.ProposalResponse, len(endorserClients))
+// peerEndorser is the endorser client of the peer at address
+type peerEndorser struct {
+	pb.EndorserClient
+	address string
+}
+
+// endorsement is a proposal response with its provenance
+type endorsement struct {
+	response   *pb.ProposalResponse
+	provenance driver.EndorserProvenance
+}
+
+// collectResponses sends a signed proposal to a set of peers, and gathers all the responses, along with the peers
+// that sent them. If a verifier is passed, the responses whose endorsement fails verification are rejected.
+func (i *Invoke) collectResponses(endorserClients []*peerEndorser, signedProposal *pb.SignedProposal, verifier *EndorsementVerifier) ([]*pb.ProposalResponse, []driver.EndorserProvenance, error) {
+	responsesCh := make(chan *endorsement, len(endorserClients))
 	errorCh := make(chan error, len(endorserClients))
 	wg := sync.WaitGroup{}
 	for _, endorser := range endorserClients {
 		wg.Add(1)
-		go func(endorser pb.EndorserClient) {
+		go func(endorser *peerEndorser) {
 			defer wg.Done()
+			start := time.Now()
 			proposalResp, err := endorser.ProcessProposal(context.Background(), signedProposal)
+			received := time.Now()
 			if err != nil {
 				errorCh <- err
 				return
 			}
-			responsesCh <- proposalResp
+			if verifier != nil {
+				if err := verifier.Verify(endorser.address, proposalResp); err != nil {
+					errorCh <- err
+					return
+				}
+			}
+			digest := sha256.Sum256(proposalResp.Payload)
+			responsesCh <- &endorsement{
+				response: proposalResp,
+				provenance: driver.EndorserProvenance{
+					Endpoint:       endorser.address,
+					Endorser:       proposalResp.GetEndorsement().GetEndorser(),
+					ResponseDigest: digest[:],
+					Latency:        received.Sub(start),
+					Timestamp:      received,
+				},
+			}
 		}(endorser)
 	}
 	wg.Wait()
 	close(responsesCh)
 	close(errorCh)
 	for err := range errorCh {
-		return nil, err
+		return nil, nil, err
 	}
 	var responses []*pb.ProposalResponse
-	for response := range responsesCh {
-		responses = append(responses, response)
+	var provenance []driver.EndorserProvenance
+	for e := range responsesCh {
+		responses = append(responses, e.response)
+		provenance = append(provenance, e.provenance)
+	}
+	return responses, provenance, nil
+}
+
+// recordEndorsements records, if the channel keeps the provenance of its transactions, the endorsements of the passed transaction
+func (i *Invoke) recordEndorsements(txID string, endorsements []driver.EndorserProvenance) {
+	p, ok := i.Channel.(driver.ProvenanceProvider)
+	if !ok {
+		return
+	}
+	if err := p.ProvenanceService().RecordEndorsements(txID, endorsements); err != nil {
+		logger.Warnf("failed recording the endorsements of [%s]: [%s]", txID, err)
 	}
-	return responses, nil
 }
 
 // getChaincodeSpec get chaincode spec from the fsccli cmd parameters
@@ -524,9 +658,53 @@ func (i *Invoke) toBytes(arg interface{}) ([]byte, error) {
 	}
 }
 
+// checkWritable fails with a *driver.ErrReadOnly if the network is read-only, before the peers are contacted
+func (i *Invoke) checkWritable(operation string) error {
+	if i.Network.Config().ReadOnly() {
+		return &driver.ErrReadOnly{Network: i.Network.Name(), Operation: operation}
+	}
+	return nil
+}
+
+// broadcast sends the passed transaction to the ordering service and waits for its finality,
+// all accounted to the ordering phase of the flow of the invocation
 func (i *Invoke) broadcast(txID string, env *common.Envelope) error {
+	defer view.TrackPhase(i.Context, view.PhaseOrdering)()
 	if err := i.Network.Broadcast(env); err != nil {
 		return err
 	}
-	return i.Channel.IsFinal(context.Background(), txID)
+	ctx := i.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return i.Channel.IsFinal(ctx, txID)
+}
+
+// channelPeersByMSPIDs returns the connection config of a peer for each of the passed organizations
+func (i *Invoke) channelPeersByMSPIDs(mspIDs ...string) ([]*grpc.ConnectionConfig, error) {
+	invoker, err := i.Channel.MSPManager().DeserializeIdentity(i.SignerIdentity)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to deserializer the invoker identity")
+	}
+	channelPeers := i.Channel.ChannelPeers()
+
+	var configs []*grpc.ConnectionConfig
+	for _, mspID := range mspIDs {
+		if mspID == invoker.GetMSPIdentifier() {
+			configs = append(configs, i.Network.PickPeer())
+			continue
+		}
+		var candidates []*grpc.ConnectionConfig
+		for _, peer := range channelPeers {
+			if peer.MSPID == mspID {
+				cc := peer.ConnectionConfig
+				candidates = append(candidates, &cc)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, errors.Errorf("no peer of [%s] found in the configuration of channel [%s], or contacting it is not allowed", mspID, i.Channel.Name())
+		}
+		configs = append(configs, candidates[rand.Intn(len(candidates))])
+	}
+	return configs, nil
 }
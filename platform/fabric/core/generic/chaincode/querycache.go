@@ -0,0 +1,145 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+// DefaultQueryCacheTTL is the time a query result is cached when the chaincode configuration does not set it
+const DefaultQueryCacheTTL = 30 * time.Second
+
+var (
+	queryCacheHitsOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "chaincode",
+		Name:         "query_cache_hits",
+		Help:         "The number of chaincode queries served by the query cache.",
+		LabelNames:   []string{"network", "channel", "chaincode"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}.%{chaincode}",
+	}
+	queryCacheMissesOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "chaincode",
+		Name:         "query_cache_misses",
+		Help:         "The number of chaincode queries not found in the query cache and sent to the peers.",
+		LabelNames:   []string{"network", "channel", "chaincode"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}.%{chaincode}",
+	}
+)
+
+// QueryCacheMetrics collects the hits and misses of the query caches of a network
+type QueryCacheMetrics struct {
+	Hits   metrics.Counter
+	Misses metrics.Counter
+}
+
+func NewQueryCacheMetrics(p metrics.Provider) *QueryCacheMetrics {
+	return &QueryCacheMetrics{
+		Hits:   p.NewCounter(queryCacheHitsOpts),
+		Misses: p.NewCounter(queryCacheMissesOpts),
+	}
+}
+
+// QueryCache caches the results of the queries of a chaincode on a channel for a TTL.
+// The committer invalidates the cache when a valid transaction writes to the namespace of the chaincode.
+type QueryCache struct {
+	ttl     time.Duration
+	metrics *QueryCacheMetrics
+	labels  []string
+
+	// lock serializes the invalidations with the insertions
+	lock sync.Mutex
+	// generation is incremented at each invalidation, a result is cached only if no invalidation
+	// happened since its query was sent
+	generation uint64
+	cache      *ttlcache.Cache
+}
+
+// NewQueryCache returns a cache whose entries expire after the passed ttl.
+// The labels identify the network, the channel and the chaincode in the metrics, that can be nil.
+func NewQueryCache(ttl time.Duration, metrics *QueryCacheMetrics, labels ...string) *QueryCache {
+	if ttl <= 0 {
+		ttl = DefaultQueryCacheTTL
+	}
+	cache := ttlcache.NewCache()
+	// the results expire after the ttl since they have been fetched, no matter how often they are read
+	cache.SkipTTLExtensionOnHit(true)
+	return &QueryCache{
+		ttl:     ttl,
+		metrics: metrics,
+		labels:  labels,
+		cache:   cache,
+	}
+}
+
+// Get returns the cached result for the passed key, if any, and the generation to pass to Put
+// when the result is fetched from the peers
+func (c *QueryCache) Get(key string) ([]byte, uint64, bool) {
+	c.lock.Lock()
+	generation := c.generation
+	c.lock.Unlock()
+
+	v, err := c.cache.Get(key)
+	if err != nil {
+		if c.metrics != nil {
+			c.metrics.Misses.With(c.labels...).Add(1)
+		}
+		return nil, generation, false
+	}
+	if c.metrics != nil {
+		c.metrics.Hits.With(c.labels...).Add(1)
+	}
+	return v.([]byte), generation, true
+}
+
+// Put caches the passed result, unless the cache has been invalidated since the passed generation
+// was returned by Get. In that case, the result might be stale.
+func (c *QueryCache) Put(key string, generation uint64, result []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if err := c.cache.SetWithTTL(key, result, c.ttl); err != nil {
+		logger.Warnf("failed caching query result [%s]: [%s]", key, err)
+	}
+}
+
+// Invalidate drops all the cached results
+func (c *QueryCache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	if err := c.cache.Purge(); err != nil {
+		logger.Warnf("failed purging query cache: [%s]", err)
+	}
+}
+
+// QueryCacheKey returns the key of the query of the passed function and arguments by the passed identity
+func QueryCacheKey(function string, args [][]byte, invoker view.Identity) string {
+	h := sha256.New()
+	l := make([]byte, 8)
+	write := func(b []byte) {
+		binary.BigEndian.PutUint64(l, uint64(len(b)))
+		h.Write(l)
+		h.Write(b)
+	}
+	write(invoker)
+	for _, arg := range args {
+		write(arg)
+	}
+	return function + "/" + hex.EncodeToString(h.Sum(nil))
+}
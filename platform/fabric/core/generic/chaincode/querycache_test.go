@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCache(t *testing.T) {
+	hits := &metricsfakes.Counter{}
+	hits.WithReturns(hits)
+	misses := &metricsfakes.Counter{}
+	misses.WithReturns(misses)
+	c := NewQueryCache(time.Minute, &QueryCacheMetrics{Hits: hits, Misses: misses}, "network", "default", "channel", "mychannel", "chaincode", "assets")
+
+	key := QueryCacheKey("get", [][]byte{[]byte("get"), []byte("ref1")}, []byte("alice"))
+	assert.NotEqual(t, key, QueryCacheKey("get", [][]byte{[]byte("get"), []byte("ref1")}, []byte("bob")))
+	assert.NotEqual(t, key, QueryCacheKey("get", [][]byte{[]byte("get"), []byte("ref2")}, []byte("alice")))
+	assert.NotEqual(t, QueryCacheKey("get", [][]byte{[]byte("ab"), []byte("c")}, nil), QueryCacheKey("get", [][]byte{[]byte("a"), []byte("bc")}, nil))
+
+	_, generation, ok := c.Get(key)
+	assert.False(t, ok)
+	c.Put(key, generation, []byte("v1"))
+	res, _, ok := c.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v1"), res)
+	assert.Equal(t, 1, hits.AddCallCount())
+	assert.Equal(t, 1, misses.AddCallCount())
+	assert.Equal(t, []string{"network", "default", "channel", "mychannel", "chaincode", "assets"}, hits.WithArgsForCall(0))
+
+	// a commit affecting the namespace drops the cached results
+	c.Invalidate()
+	_, generation, ok = c.Get(key)
+	assert.False(t, ok)
+
+	// a query sent before a commit does not cache its result, that might be stale
+	c.Invalidate()
+	c.Put(key, generation, []byte("v1"))
+	_, generation, ok = c.Get(key)
+	assert.False(t, ok)
+	c.Put(key, generation, []byte("v2"))
+	res, _, ok = c.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v2"), res)
+
+	// the results expire
+	c = NewQueryCache(50*time.Millisecond, nil)
+	_, generation, _ = c.Get(key)
+	c.Put(key, generation, []byte("v1"))
+	_, _, ok = c.Get(key)
+	assert.True(t, ok)
+	assert.Eventually(t, func() bool {
+		_, _, ok := c.Get(key)
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
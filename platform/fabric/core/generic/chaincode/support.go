@@ -58,5 +58,8 @@ type Channel interface {
 
 	MSPManager() driver.MSPManager
 
+	// ChannelPeers returns the peers of the other organizations of the channel this node is allowed to contact
+	ChannelPeers() []driver.ChannelPeer
+
 	Chaincode(name string) driver.Chaincode
 }
@@ -0,0 +1,148 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/clock"
+	mb "github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/msp"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// errorThreshold is the status from which a proposal response reports a failure, see shim.ERRORTHRESHOLD
+const errorThreshold = 400
+
+// EndorsementVerificationError is returned when the endorsement of a peer fails verification
+type EndorsementVerificationError struct {
+	// Peer is the address of the peer the endorsement comes from
+	Peer string
+	// MSPID is the MSP of the endorser, if known
+	MSPID string
+	Err   error
+}
+
+func (e *EndorsementVerificationError) Error() string {
+	if len(e.MSPID) == 0 {
+		return fmt.Sprintf("endorsement of peer [%s] failed verification: %s", e.Peer, e.Err)
+	}
+	return fmt.Sprintf("endorsement of peer [%s] of [%s] failed verification: %s", e.Peer, e.MSPID, e.Err)
+}
+
+func (e *EndorsementVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// MSPProvider gives access to the MSPs of the current channel configuration
+type MSPProvider interface {
+	MSPManager() driver.MSPManager
+}
+
+type principalSatisfier interface {
+	SatisfiesPrincipal(principal *mb.MSPPrincipal) error
+}
+
+type ouIdentity interface {
+	GetOrganizationalUnits() []*msp.OUIdentifier
+}
+
+// EndorsementVerifier checks that a proposal response is signed by an endorser that validates
+// under the MSPs of the channel, and that the endorser has the expected role and organizational unit, if any.
+type EndorsementVerifier struct {
+	msps MSPProvider
+	role *mb.MSPRole_MSPRoleType
+	ou   string
+}
+
+// NewEndorsementVerifier returns a new EndorsementVerifier. The role, if any, is one of member, admin,
+// client, peer, and orderer.
+func NewEndorsementVerifier(msps MSPProvider, role string, ou string) (*EndorsementVerifier, error) {
+	v := &EndorsementVerifier{msps: msps, ou: ou}
+	if len(role) != 0 {
+		r, ok := mb.MSPRole_MSPRoleType_value[strings.ToUpper(role)]
+		if !ok {
+			return nil, errors.Errorf("invalid endorser role [%s]", role)
+		}
+		roleType := mb.MSPRole_MSPRoleType(r)
+		v.role = &roleType
+	}
+	return v, nil
+}
+
+// newEndorsementVerifier returns the verifier configured for the network, nil if the verification is disabled
+func newEndorsementVerifier(c *config.Config, msps MSPProvider) (*EndorsementVerifier, error) {
+	if c.EndorsementVerificationDisabled() {
+		logger.Debugf("endorsement verification disabled")
+		return nil, nil
+	}
+	return NewEndorsementVerifier(msps, c.EndorsementVerificationRole(), c.EndorsementVerificationOU())
+}
+
+// Verify verifies the endorsement of the passed proposal response, received from the passed peer.
+// Responses reporting a failure carry no endorsement and are not verified.
+func (v *EndorsementVerifier) Verify(peer string, response *pb.ProposalResponse) error {
+	if response.Response != nil && response.Response.Status >= errorThreshold {
+		return nil
+	}
+	if response.Endorsement == nil {
+		return &EndorsementVerificationError{Peer: peer, Err: errors.New("endorsement is missing")}
+	}
+
+	// the MSPs are taken from the channel configuration in use now
+	id, err := v.msps.MSPManager().DeserializeIdentity(response.Endorsement.Endorser)
+	if err != nil {
+		return &EndorsementVerificationError{Peer: peer, Err: errors.WithMessage(err, "failed deserializing endorser")}
+	}
+	fail := func(err error) error {
+		return &EndorsementVerificationError{Peer: peer, MSPID: id.GetMSPIdentifier(), Err: err}
+	}
+	if err := id.Validate(); err != nil {
+		return fail(errors.WithMessage(clock.Annotate(err), "endorser is not valid under the channel MSPs"))
+	}
+	msg := make([]byte, 0, len(response.Payload)+len(response.Endorsement.Endorser))
+	msg = append(append(msg, response.Payload...), response.Endorsement.Endorser...)
+	if err := id.Verify(msg, response.Endorsement.Signature); err != nil {
+		return fail(errors.WithMessage(err, "invalid endorsement signature"))
+	}
+
+	if v.role != nil {
+		ps, ok := id.(principalSatisfier)
+		if !ok {
+			return fail(errors.Errorf("cannot check the role of the endorser"))
+		}
+		principal := &mb.MSPPrincipal{
+			PrincipalClassification: mb.MSPPrincipal_ROLE,
+			Principal:               protoutil.MarshalOrPanic(&mb.MSPRole{MspIdentifier: id.GetMSPIdentifier(), Role: *v.role}),
+		}
+		if err := ps.SatisfiesPrincipal(principal); err != nil {
+			return fail(errors.WithMessagef(err, "endorser does not have role [%s]", v.role))
+		}
+	}
+	if len(v.ou) != 0 {
+		oi, ok := id.(ouIdentity)
+		if !ok {
+			return fail(errors.Errorf("cannot check the organizational units of the endorser"))
+		}
+		found := false
+		for _, ou := range oi.GetOrganizationalUnits() {
+			if ou.OrganizationalUnitIdentifier == v.ou {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail(errors.Errorf("endorser does not belong to organizational unit [%s]", v.ou))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	mb "github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/msp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// identity signs by prefixing the message with its name
+type identity struct {
+	name  string
+	mspID string
+	valid bool
+	role  mb.MSPRole_MSPRoleType
+	ous   []string
+}
+
+func (id *identity) GetMSPIdentifier() string { return id.mspID }
+
+func (id *identity) Validate() error {
+	if !id.valid {
+		return errors.New("certificate expired")
+	}
+	return nil
+}
+
+func (id *identity) Verify(message, sigma []byte) error {
+	if !bytes.Equal(sigma, id.sign(message)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (id *identity) SatisfiesPrincipal(principal *mb.MSPPrincipal) error {
+	role := &mb.MSPRole{}
+	if err := proto.Unmarshal(principal.Principal, role); err != nil {
+		return err
+	}
+	if role.MspIdentifier != id.mspID || role.Role != id.role {
+		return errors.New("principal not satisfied")
+	}
+	return nil
+}
+
+func (id *identity) GetOrganizationalUnits() []*msp.OUIdentifier {
+	var res []*msp.OUIdentifier
+	for _, ou := range id.ous {
+		res = append(res, &msp.OUIdentifier{OrganizationalUnitIdentifier: ou})
+	}
+	return res
+}
+
+func (id *identity) sign(message []byte) []byte {
+	return append([]byte(id.name+":"), message...)
+}
+
+// channelMSPs deserializes the identities known to the channel by name
+type channelMSPs map[string]*identity
+
+func (m channelMSPs) MSPManager() driver.MSPManager { return m }
+
+func (m channelMSPs) DeserializeIdentity(raw []byte) (driver.MSPIdentity, error) {
+	id, ok := m[string(raw)]
+	if !ok {
+		return nil, errors.Errorf("unknown MSP")
+	}
+	return id, nil
+}
+
+func endorsed(id *identity, payload string) *pb.ProposalResponse {
+	return &pb.ProposalResponse{
+		Response: &pb.Response{Status: 200},
+		Payload:  []byte(payload),
+		Endorsement: &pb.Endorsement{
+			Endorser:  []byte(id.name),
+			Signature: id.sign(append([]byte(payload), id.name...)),
+		},
+	}
+}
+
+func assertVerificationError(t *testing.T, err error, peer, mspID, cause string) {
+	verr := &EndorsementVerificationError{}
+	assert.True(t, errors.As(err, &verr), "expected a verification error, got [%v]", err)
+	assert.Equal(t, peer, verr.Peer)
+	assert.Equal(t, mspID, verr.MSPID)
+	assert.Contains(t, verr.Error(), cause)
+}
+
+func TestEndorsementVerifier(t *testing.T) {
+	peer0 := &identity{name: "peer0", mspID: "Org1MSP", valid: true, role: mb.MSPRole_PEER, ous: []string{"peer"}}
+	client := &identity{name: "client", mspID: "Org1MSP", valid: true, role: mb.MSPRole_CLIENT, ous: []string{"client"}}
+	expired := &identity{name: "expired", mspID: "Org2MSP", role: mb.MSPRole_PEER}
+	msps := channelMSPs{"peer0": peer0, "client": client, "expired": expired}
+
+	v, err := NewEndorsementVerifier(msps, "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, v.Verify("peer0:7051", endorsed(peer0, "results")))
+	assert.NoError(t, v.Verify("peer0:7051", endorsed(client, "results")))
+	// failures are left to the caller
+	assert.NoError(t, v.Verify("peer0:7051", &pb.ProposalResponse{Response: &pb.Response{Status: 500}}))
+
+	tampered := endorsed(peer0, "results")
+	tampered.Payload = []byte("other results")
+	assertVerificationError(t, v.Verify("peer0:7051", tampered), "peer0:7051", "Org1MSP", "invalid endorsement signature")
+	assertVerificationError(t, v.Verify("peer1:7051", endorsed(expired, "results")), "peer1:7051", "Org2MSP", "certificate expired")
+	unknown := &identity{name: "unknown", valid: true}
+	assertVerificationError(t, v.Verify("peer2:7051", endorsed(unknown, "results")), "peer2:7051", "", "unknown MSP")
+	assertVerificationError(t, v.Verify("peer2:7051", &pb.ProposalResponse{Response: &pb.Response{Status: 200}}), "peer2:7051", "", "endorsement is missing")
+
+	v, err = NewEndorsementVerifier(msps, "peer", "")
+	assert.NoError(t, err)
+	assert.NoError(t, v.Verify("peer0:7051", endorsed(peer0, "results")))
+	assertVerificationError(t, v.Verify("peer0:7051", endorsed(client, "results")), "peer0:7051", "Org1MSP", "endorser does not have role [PEER]")
+
+	v, err = NewEndorsementVerifier(msps, "", "peer")
+	assert.NoError(t, err)
+	assert.NoError(t, v.Verify("peer0:7051", endorsed(peer0, "results")))
+	assertVerificationError(t, v.Verify("peer0:7051", endorsed(client, "results")), "peer0:7051", "Org1MSP", "organizational unit [peer]")
+
+	_, err = NewEndorsementVerifier(msps, "superuser", "")
+	assert.EqualError(t, err, "invalid endorser role [superuser]")
+}
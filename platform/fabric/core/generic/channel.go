@@ -9,6 +9,7 @@ package generic
 import (
 	"context"
 	"io/ioutil"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -20,18 +21,22 @@ import (
 	peer2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/peer"
 	common2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/peer/common"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/rwset"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/sinks"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	api2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/clock"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/metrics"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/tracing"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	"github.com/hyperledger/fabric-protos-go/common"
 	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/bccsp"
 	"github.com/hyperledger/fabric/common/channelconfig"
 	discovery "github.com/hyperledger/fabric/discovery/client"
 	"github.com/hyperledger/fabric/protoutil"
@@ -54,7 +59,9 @@ const (
 
 type Delivery interface {
 	Start(ctx context.Context)
+	Run(ctx context.Context) error
 	Stop()
+	SetStreamListener(listener delivery2.StreamListener)
 }
 
 type channel struct {
@@ -67,9 +74,11 @@ type channel struct {
 	vault              *vault.Vault
 	processNamespaces  []string
 	externalCommitter  *committer.ExternalCommitter
+	committer          *committer.Committer
 	envelopeService    driver.EnvelopeService
 	transactionService driver.EndorserTransactionService
 	metadataService    driver.MetadataService
+	provenanceService  driver.ProvenanceService
 	eventsSubscriber   events.Subscriber
 	eventsPublisher    events.Publisher
 	deliveryService    Delivery
@@ -81,6 +90,14 @@ type channel struct {
 	lock sync.RWMutex
 	// resources is used to acquire configuration bundle resources.
 	resources channelconfig.Resources
+	// channelPeers are the peers of the other organizations extracted from resources
+	channelPeers []driver.ChannelPeer
+	// configSequence tracks the sequence of the last config applied, it is guarded by applyLock
+	configSequence *configSequence
+	// maxEnvelopeBytes is the configured limit of the size of the envelopes, 0 to use the one of the orderers
+	maxEnvelopeBytes uint32
+	// cryptoProvider is the BCCSP of the configuration bundles, injected by the network
+	cryptoProvider bccsp.BCCSP
 
 	chaincodesLock sync.RWMutex
 	chaincodes     map[string]driver.Chaincode
@@ -89,15 +106,43 @@ type channel struct {
 
 	// subscribers
 	subscribers *events.Subscribers
+	// chaincodeSubscriptions are the subscriptions to the chaincode events, they survive the instances of the channel
+	chaincodeSubscriptions *committer.Subscriptions
+	// sinks export the events of the channel to the configured external systems and to the subscriptions,
+	// nil if none is configured
+	sinks *sinks.Channel
+
+	// lifecycleLock serializes the start and the stop of the delivery, the unloading and the reloading of the channel
+	lifecycleLock sync.Mutex
+	// deliveryCtx is the context the delivery has been started with, nil if not started
+	deliveryCtx context.Context
+	// deliveryCancel stops the running delivery, deliveryDone is closed once it returned, both nil if not running
+	deliveryCancel context.CancelFunc
+	deliveryDone   chan struct{}
+	// lastUsed is the time, in unix nanoseconds, the channel has been used last
+	lastUsed int64
+	// inFlight counts the calls waiting on the channel, it is not unloaded meanwhile
+	inFlight int32
+	// verifying is 1 while the namespaces of the vault are verified in the background, see verifyIntegrityEvery
+	verifying int32
+	// unloaded is true while the delivery is stopped and the bundle released, unloadedConfig is the configuration
+	// of the released bundle. Both are guarded by lock.
+	unloaded       bool
+	unloadedConfig *common.Config
 }
 
-func newChannel(network *network, name string, quiet bool) (*channel, error) {
+// newChannel creates the passed channel of the passed network.
+// If rejoin is not nil, the channel joins the network again after its removal, see RejoinChannel.
+func newChannel(network *network, name string, quiet bool, rejoin *driver.RejoinOptions) (*channel, error) {
 	sp := network.sp
 	// Vault
-	v, txIDStore, err := NewVault(sp, network.config, name)
+	v, txIDStore, err := openVault(sp, network.config, network.Name(), name, rejoin)
 	if err != nil {
 		return nil, err
 	}
+	if network.vaultMetrics != nil {
+		v.SetMetrics(network.vaultMetrics, network.name, name)
+	}
 
 	// Fabric finality
 	fabricFinality, err := finality2.NewFabricFinality(
@@ -121,19 +166,13 @@ func newChannel(network *network, name string, quiet bool) (*channel, error) {
 		return nil, errors.Wrapf(err, "failed to get event publisher")
 	}
 
-	committerInst, err := committer.New(name, network, fabricFinality, waitForEventTimeout, quiet, tracing.Get(sp), publisher)
+	committerInst, err := committer.New(name, network, fabricFinality, waitForEventTimeout, quiet, tracing.Get(sp), publisher, network.bus, network.commitLimiter, network.commitMetrics)
 	if err != nil {
 		return nil, err
 	}
-
-	// Delivery
-	deliveryService, err := delivery2.New(name, sp, network, func(block *common.Block) (bool, error) {
-		// commit the block, if an error occurs then retry
-		err := committerInst.Commit(block)
-		return false, err
-	}, txIDStore, waitForEventTimeout)
-	if err != nil {
-		return nil, err
+	committerInst.SetLimits(committerLimits(network.config))
+	if path := network.config.CommitterQuarantinePath(); len(path) != 0 {
+		committerInst.SetQuarantine(committer.NewQuarantine(filepath.Join(path, network.Name())))
 	}
 
 	// Finality
@@ -157,9 +196,11 @@ func newChannel(network *network, name string, quiet bool) (*channel, error) {
 		return nil, errors.WithMessagef(err, "failed to get channel config")
 	}
 	var channelConfig *config2.Channel
+	var sinkConfigs []*config2.EventSink
 	for _, config := range channelConfigs {
 		if config.Name == name {
 			channelConfig = config
+			sinkConfigs = config.Sinks
 			break
 		}
 	}
@@ -181,7 +222,7 @@ func newChannel(network *network, name string, quiet bool) (*channel, error) {
 		vault:              v,
 		sp:                 sp,
 		finality:           fs,
-		deliveryService:    deliveryService,
+		committer:          committerInst,
 		externalCommitter:  externalCommitter,
 		TXIDStore:          txIDStore,
 		envelopeService:    transaction.NewEnvelopeService(sp, network.Name(), name),
@@ -191,7 +232,55 @@ func newChannel(network *network, name string, quiet bool) (*channel, error) {
 		eventsPublisher:    eventsPublisher,
 		eventsSubscriber:   eventsSubscriber,
 		subscribers:        events.NewSubscribers(),
+		cryptoProvider:     network.cryptoProvider,
+	}
+	c.chaincodeSubscriptions, err = network.chaincodeSubscriptions(name)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting the chaincode event subscriptions of channel [%s]", name)
+	}
+	c.touch()
+	c.configSequence = newConfigSequence(name, c.fetchBlock)
+	c.provenanceService = transaction.NewProvenanceService(sp, network.Name(), name, c.configSequenceInForce)
+	c.maxEnvelopeBytes = network.config.OrderingMaxEnvelopeBytes()
+	committerInst.AddWriteListener(c.invalidateQueries)
+	committerInst.AddChaincodeEventListener(c.chaincodeSubscriptions.Publish)
+	if monitor := clock.GetMonitor(sp); monitor != nil {
+		committerInst.AddTimestampListener(c.observeTimestamp(monitor))
+	}
+	var journal *sinks.JournalConfig
+	if network.config.SubscriptionsEnabled() {
+		journal = &sinks.JournalConfig{Retention: network.config.SubscriptionsRetention(), MaxLag: network.config.SubscriptionsMaxLag()}
+	}
+	if service := sinks.GetService(sp); service != nil {
+		c.sinks, err = service.NewChannel(network.Name(), name, sinkConfigs, journal)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed starting the event sinks of channel [%s]", name)
+		}
+	} else if len(sinkConfigs) > 0 || journal != nil {
+		logger.Warnf("event sinks service not available, the sinks and the subscriptions of channel [%s] are ignored", name)
+	}
+	if c.sinks != nil {
+		committerInst.AddWriteListener(c.sinks.OnWrite)
+		committerInst.AddChaincodeEventListener(c.sinks.OnChaincodeEvent)
+		committerInst.AddFinalityListener(func(event committer.TxEvent) {
+			c.sinks.OnFinality(event.Txid, event.Block, event.IndexInBlock, event.Committed && event.Err == nil, event.Err)
+		})
+	}
+	if maxPause := network.config.VaultBackupMaxPause(); maxPause > 0 {
+		v.SetMaxPauseDuration(maxPause)
 	}
+
+	// Delivery
+	c.deliveryService, err = delivery2.New(name, sp, network, func(block *common.Block) (bool, error) {
+		// commit the block, if an error occurs then retry
+		err := c.commitBlock(block)
+		return false, err
+	}, txIDStore, waitForEventTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c.deliveryService.SetStreamListener(c.chaincodeSubscriptions)
+
 	if err := c.init(); err != nil {
 		return nil, errors.WithMessagef(err, "failed initializing channel [%s]", name)
 	}
@@ -208,13 +297,25 @@ func (c *channel) NewPeerClientForAddress(cc grpc.ConnectionConfig) (peer2.Clien
 	return c.connCache.NewPeerClientForAddress(cc)
 }
 
+// observeTimestamp returns the listener feeding the passed monitor with the timestamps of the transactions
+// committed, those created by this node are timestamped by the local clock and then skipped
+func (c *channel) observeTimestamp(monitor *clock.Monitor) committer.TimestampListener {
+	sigService := view2.GetSigService(c.sp)
+	return func(creator []byte, timestamp time.Time) {
+		if sigService.IsMe(creator) {
+			return
+		}
+		monitor.Observe(timestamp)
+	}
+}
+
 func (c *channel) IsValid(identity view.Identity) error {
 	id, err := c.MSPManager().DeserializeIdentity(identity)
 	if err != nil {
 		return errors.Wrapf(err, "failed deserializing identity [%s]", identity.String())
 	}
 
-	return id.Validate()
+	return clock.Annotate(id.Validate())
 }
 
 func (c *channel) GetVerifier(identity view.Identity) (api2.Verifier, error) {
@@ -286,26 +387,64 @@ func (c *channel) GetTransactionByID(txID string) (driver.ProcessedTransaction,
 }
 
 func (c *channel) GetBlockNumberByTxID(txID string) (uint64, error) {
-	res, err := c.Chaincode("qscc").NewInvocation(GetBlockByTxID, c.name, txID).WithSignerIdentity(
-		c.network.LocalMembership().DefaultIdentity(),
-	).WithEndorsersByConnConfig(c.network.PickPeer()).Query()
-	if err != nil {
-		return 0, err
-	}
-
-	block := &common.Block{}
-	err = proto.Unmarshal(res, block)
+	block, err := c.blockByTxID(txID)
 	if err != nil {
 		return 0, err
 	}
 	return block.Header.Number, nil
 }
 
+// Close shuts the channel down in this order, so that no finality notification is lost:
+// the delivery stops accepting new blocks, the commits in flight complete, the finality waiters are released
+// and the pending chaincode events delivered, the subscriptions end with driver.ErrShuttingDown, and at last
+// the vault is closed. Each step waits at most for the configured shutdown timeout.
 func (c *channel) Close() error {
-	c.deliveryService.Stop()
+	c.quiesce()
+	c.release(errors.Wrapf(driver.ErrShuttingDown, "channel [%s] closed", c.name))
 	return c.vault.Close()
 }
 
+// quiesce stops the delivery and waits for the commits in flight, the finality waiters are released.
+// It returns the context the delivery had been started with, nil if not started.
+func (c *channel) quiesce() context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.ShutdownTimeout())
+	defer cancel()
+
+	c.lifecycleLock.Lock()
+	deliveryCtx := c.deliveryCtx
+	c.stopDelivery()
+	c.lifecycleLock.Unlock()
+	if err := c.committer.Shutdown(ctx); err != nil {
+		logger.Warnf("failed shutting down the committer of channel [%s]: %s", c.name, err)
+	}
+	return deliveryCtx
+}
+
+// release ends the subscriptions with the passed reason, once their pending events delivered, and closes the sinks
+func (c *channel) release(reason error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.ShutdownTimeout())
+	defer cancel()
+
+	if err := c.chaincodeSubscriptions.Close(ctx, reason); err != nil {
+		logger.Warnf("failed closing the chaincode subscriptions of channel [%s]: %s", c.name, err)
+	}
+	c.sinks.Close()
+}
+
+// SubscribeChaincodeEvents subscribes to the events of the passed chaincode with a subscription surviving the
+// restarts of the channel, see committer.Subscriptions
+func (c *channel) SubscribeChaincodeEvents(chaincode string, after *driver.EventPosition) (driver.ChaincodeEventSubscription, error) {
+	if err := c.use(); err != nil {
+		return nil, err
+	}
+	return c.chaincodeSubscriptions.Subscribe(chaincode, after)
+}
+
+// AddStateWriteListener registers the passed listener of the keys written by the transactions committed on this channel
+func (c *channel) AddStateWriteListener(listener driver.StateWriteListener) {
+	c.committer.AddStateWriteListener(listener)
+}
+
 func (c *channel) Config() *config2.Channel {
 	return c.channelConfig
 }
@@ -487,11 +626,36 @@ func (c *connCreator) NewPeerClientForAddress(cc grpc.ConnectionConfig) (peer2.C
 	if len(cc.ServerNameOverride) != 0 {
 		override = cc.ServerNameOverride
 	}
+	clientConfig.Compression = cc.Compression
+	if len(cc.Compression) != 0 {
+		clientConfig.CompressionMetrics = grpc.GetCompressionMetrics(metrics.GetProvider(c.ch.sp))
+	}
+	// the peers discovered or found in the channel configuration follow the resolution of the network
+	clientConfig.Resolution = cc.Resolution
+	if clientConfig.Resolution == nil {
+		clientConfig.Resolution = c.ch.network.resolution
+	}
 
 	return newPeerClientForClientConfig(
 		c.ch.DefaultSigner(),
-		cc.Address,
+		c.ch.network.overrideAddress(cc.Address),
 		override,
 		*clientConfig,
 	)
 }
+
+// committerLimits returns the limits of the blocks committed set in the passed configuration,
+// the unset ones are the default ones
+func committerLimits(c *config2.Config) committer.Limits {
+	limits := committer.DefaultLimits()
+	if v := c.CommitterMaxBlockBytes(); v != 0 {
+		limits.MaxBlockBytes = v
+	}
+	if v := c.CommitterMaxEnvelopeBytes(); v != 0 {
+		limits.MaxEnvelopeBytes = v
+	}
+	if v := c.CommitterMaxConfigDepth(); v != 0 {
+		limits.MaxConfigDepth = v
+	}
+	return limits
+}
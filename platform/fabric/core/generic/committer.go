@@ -14,11 +14,13 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
 	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
 )
 
 func (c *channel) Status(txid string) (driver.ValidationCode, []string, error) {
+	c.touch()
 	vc, err := c.vault.Status(txid)
 	if err != nil {
 		logger.Errorf("failed to get status of [%s]: %s", txid, err)
@@ -26,10 +28,11 @@ func (c *channel) Status(txid string) (driver.ValidationCode, []string, error) {
 	}
 	if vc == driver.Unknown {
 		// give it a second chance
-		if c.EnvelopeService().Exists(txid) {
-			if err := c.extractStoredEnvelopeToVault(txid); err != nil {
-				return driver.Unknown, nil, errors.WithMessagef(err, "failed to extract stored enveloper for [%s]", txid)
-			}
+		extracted, err := c.extractKnownToVault(txid)
+		if err != nil {
+			return driver.Unknown, nil, err
+		}
+		if extracted {
 			vc = driver.Busy
 		}
 	}
@@ -48,6 +51,20 @@ func (c *channel) Status(txid string) (driver.ValidationCode, []string, error) {
 	return vc, dependantTxIDs, nil
 }
 
+// StatusWithHeight returns the status of the passed transaction, as Status does, together with
+// the number of the block and the position in the block where the transaction has been committed.
+func (c *channel) StatusWithHeight(txid string) (driver.ValidationCode, uint64, int, error) {
+	vc, _, err := c.Status(txid)
+	if err != nil {
+		return driver.Unknown, driver.UnknownBlock, driver.UnknownTxNum, err
+	}
+	_, block, txNum, err := c.vault.StatusWithHeight(txid)
+	if err != nil {
+		return driver.Unknown, driver.UnknownBlock, driver.UnknownTxNum, err
+	}
+	return vc, block, txNum, nil
+}
+
 func (c *channel) ProcessNamespace(nss ...string) error {
 	c.processNamespaces = append(c.processNamespaces, nss...)
 	return nil
@@ -58,7 +75,60 @@ func (c *channel) GetProcessNamespace() []string {
 }
 
 func (c *channel) DiscardTx(txid string) error {
+	return c.discardTx(txid, c.vault.DiscardTx)
+}
+
+// DiscardTxWithCode discards the passed transaction, as DiscardTx does, recording the height it has been invalidated at
+// and the validation code the peers assigned to it, together with the passed message.
+// Its dependencies are discarded without code.
+func (c *channel) DiscardTxWithCode(txid string, block uint64, txNum int, code pb.TxValidationCode, message string) error {
+	return c.discardTx(txid, func(txid string) error {
+		return c.vault.DiscardTxWithCode(txid, block, txNum, int32(code), message)
+	})
+}
+
+// CommitLocalTX commits the passed transaction, that is not ordered, like a schema migration.
+// It does not become the last transaction committed, the one the delivery restarts from.
+func (c *channel) CommitLocalTX(txid string) error {
+	unlock := c.vault.LockTx(txid)
+	defer unlock()
+	return c.vault.CommitLocalTX(txid)
+}
+
+// StatusWithMessage returns the status of the passed transaction, as Status does, together with the outcome
+// of its validation. The valid transactions committed from a block are valid for Fabric too.
+func (c *channel) StatusWithMessage(txid string) (*driver.ValidationStatus, error) {
+	vc, block, _, err := c.StatusWithHeight(txid)
+	if err != nil {
+		return nil, err
+	}
+	status := &driver.ValidationStatus{TxID: txid, Code: vc}
+	switch vc {
+	case driver.Valid:
+		status.Outcome = driver.OutcomeValid
+		if block != driver.UnknownBlock {
+			status.FabricCode, status.HasFabricCode = pb.TxValidationCode_VALID, true
+		}
+	case driver.Invalid:
+		fabricCode, message, found, err := c.vault.Outcome(txid)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed getting the outcome of [%s]", txid)
+		}
+		if !found {
+			status.Outcome = driver.OutcomeDiscarded
+			break
+		}
+		status.FabricCode, status.HasFabricCode = pb.TxValidationCode(fabricCode), true
+		status.Outcome = driver.OutcomeOf(status.FabricCode)
+		status.Message = message
+	}
+	return status, nil
+}
+
+func (c *channel) discardTx(txid string, discard func(txid string) error) error {
 	logger.Debugf("Discarding transaction [%s]", txid)
+	unlock := c.vault.LockTx(txid)
+	defer unlock()
 
 	defer c.notifyTxStatus(txid, driver.Invalid)
 	vc, deps, err := c.Status(txid)
@@ -67,17 +137,17 @@ func (c *channel) DiscardTx(txid string) error {
 	}
 	if vc == driver.Unknown {
 		// give it a second chance
-		if c.EnvelopeService().Exists(txid) {
-			if err := c.extractStoredEnvelopeToVault(txid); err != nil {
-				return errors.WithMessagef(err, "failed to extract stored enveloper for [%s]", txid)
-			}
-		} else {
+		extracted, err := c.extractKnownToVault(txid)
+		if err != nil {
+			return err
+		}
+		if !extracted {
 			logger.Debugf("Discarding transaction [%s] skipped, tx is unknown", txid)
 			return nil
 		}
 	}
 
-	if err := c.vault.DiscardTx(txid); err != nil {
+	if err := discard(txid); err != nil {
 		logger.Errorf("failed discarding tx [%s] in vault: %s", txid, err)
 	}
 	for _, dep := range deps {
@@ -88,11 +158,17 @@ func (c *channel) DiscardTx(txid string) error {
 	return nil
 }
 
+// CommitTX commits the passed transaction. The paths committing the same transaction, the delivery of its block
+// and the local bookkeeping of a transaction this node broadcast for instance, take turns: the first commits it,
+// the others find it valid and only attach the height of its block, if missing, its read-write set is applied once.
 func (c *channel) CommitTX(txid string, block uint64, indexInBlock int, envelope *common.Envelope) (err error) {
 	logger.Debugf("Committing transaction [%s,%d,%d]", txid, block, indexInBlock)
 	defer logger.Debugf("Committing transaction [%s,%d,%d] done [%s]", txid, block, indexInBlock, err)
+	unlock := c.vault.LockTx(txid)
+	defer unlock()
+	reconciled := false
 	defer func() {
-		if err == nil {
+		if err == nil && !reconciled {
 			c.notifyTxStatus(txid, driver.Valid)
 		}
 	}()
@@ -103,14 +179,16 @@ func (c *channel) CommitTX(txid string, block uint64, indexInBlock int, envelope
 	}
 	switch vc {
 	case driver.Valid:
-		// This should generate a panic
-		logger.Debugf("[%s] is already valid", txid)
-		return errors.Errorf("[%s] is already valid", txid)
+		// committed by another path already, its finality has been notified
+		logger.Debugf("[%s] is already valid, reconcile", txid)
+		reconciled = true
+		return c.reconcileValid(txid, block, indexInBlock)
 	case driver.Invalid:
 		// This should generate a panic
 		logger.Debugf("[%s] is invalid", txid)
 		return errors.Errorf("[%s] is invalid", txid)
-	case driver.Unknown:
+	case driver.Unknown, driver.Abandoned:
+		// the ledger has the last word on an abandoned transaction
 		return c.commitUnknown(txid, block, indexInBlock, envelope)
 	case driver.HasDependencies:
 		return c.commitDeps(txid, block, indexInBlock)
@@ -179,6 +257,39 @@ func (c *channel) filterUnknownEnvelope(txID string) (bool, error) {
 	return false, nil
 }
 
+// reconcileValid attaches the passed height to the passed valid transaction, if it has none
+func (c *channel) reconcileValid(txID string, block uint64, indexInBlock int) error {
+	if block == driver.UnknownBlock {
+		return nil
+	}
+	if _, recorded, _, err := c.vault.StatusWithHeight(txID); err != nil || recorded != driver.UnknownBlock {
+		return err
+	}
+	return c.vault.CommitTX(txID, block, indexInBlock)
+}
+
+// extractKnownToVault loads into the vault the read-write set of the passed transaction, if this node knows it.
+// The transactions originated locally are looked up first in the transaction store, whose records are the richest,
+// then in the envelopes stored. It returns false if the transaction is not known.
+func (c *channel) extractKnownToVault(txID string) (bool, error) {
+	if c.TransactionService().Exists(txID) {
+		logger.Debugf("[%s] originated locally, loading its rwset from the transaction store", txID)
+		rws, _, err := c.GetRWSetFromETx(txID)
+		if err != nil {
+			return false, errors.WithMessagef(err, "failed to extract stored transaction for [%s]", txID)
+		}
+		rws.Done()
+		return true, nil
+	}
+	if c.EnvelopeService().Exists(txID) {
+		if err := c.extractStoredEnvelopeToVault(txID); err != nil {
+			return false, errors.WithMessagef(err, "failed to extract stored enveloper for [%s]", txID)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
 func (c *channel) commitStoredEnvelope(txID string, block uint64, indexInBlock int) error {
 	logger.Debugf("found envelope for transaction [%s], committing it...", txID)
 	if err := c.extractStoredEnvelopeToVault(txID); err != nil {
@@ -299,9 +410,14 @@ func (c *channel) commitLocal(txid string, block uint64, indexInBlock int, envel
 		}
 
 		if !c.vault.RWSExists(txid) {
-			if err := c.extractStoredEnvelopeToVault(txid); err != nil {
+			// the local record is matched against the block, rather than replaced by it
+			extracted, err := c.extractKnownToVault(txid)
+			if err != nil {
 				return errors.WithMessagef(err, "failed to load stored enveloper into the vault")
 			}
+			if !extracted {
+				return errors.Errorf("failed to load stored enveloper into the vault: [%s] is not known", txid)
+			}
 		}
 
 		if err := c.vault.Match(txid, pt.Results()); err != nil {
@@ -340,6 +456,9 @@ func (c *channel) postProcessTx(txid string) error {
 // SubscribeTxStatusChanges registers a listener for transaction status changes for the passed transaction id.
 // If the transaction id is empty, the listener will be called for all transactions.
 func (c *channel) SubscribeTxStatusChanges(txID string, listener driver.TxStatusChangeListener) error {
+	if err := c.use(); err != nil {
+		return err
+	}
 	_, topic := compose.CreateTxTopic(c.network.Name(), c.name, txID)
 	l := &TxEventsListener{listener: listener}
 	logger.Debugf("[%s] Subscribing to transaction status changes", txID)
@@ -368,6 +487,17 @@ func (c *channel) UnsubscribeTxStatusChanges(txID string, listener driver.TxStat
 }
 
 func (c *channel) notifyTxStatus(txID string, vc driver.ValidationCode) {
+	c.publishTxStatus(txID, vc)
+	if c.network.admission == nil {
+		return
+	}
+	// the transactions that joined this one share its status
+	for _, joined := range c.network.admission.Finalized(c.name, txID) {
+		c.publishTxStatus(joined, vc)
+	}
+}
+
+func (c *channel) publishTxStatus(txID string, vc driver.ValidationCode) {
 	// We publish two events here:
 	// 1. The first will be caught by the listeners that are listening for any transaction id.
 	// 2. The second will be caught by the listeners that are listening for the specific transaction id.
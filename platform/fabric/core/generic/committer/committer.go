@@ -37,6 +37,7 @@ type Finality interface {
 }
 
 type Network interface {
+	Name() string
 	Committer(channel string) (driver.Committer, error)
 	PickPeer() *grpc.ConnectionConfig
 	Ledger(channel string) (driver.Ledger, error)
@@ -51,13 +52,66 @@ type Committer struct {
 
 	quietNotifier bool
 
-	listeners      map[string][]chan TxEvent
-	mutex          sync.Mutex
 	pollingTimeout time.Duration
 	publisher      events.Publisher
+	// bus carries the finality events to the transactions waiting for them
+	bus *events.Bus
+	// subscription is the subscription of the committer to the finality events of its channel, shared by the
+	// transactions waiting for them, in waiting
+	subscribeOnce sync.Once
+	subscription  *events.Subscription
+	subscribeErr  error
+	waitingLock   sync.Mutex
+	waiting       map[string][]chan TxEvent
+
+	// limiter is shared among all the channels of all the networks
+	limiter       *Limiter
+	commitMetrics *CommitMetrics
+
+	// limits bounds the blocks and the transactions processed, quarantine saves the ones rejected, if set
+	limits     Limits
+	quarantine *Quarantine
+
+	// latency measures the time the transactions broadcast by this node take to be committed
+	latency *LatencyTracker
+
+	// shutdownLock guards closed, the commits and the waiters in flight register under its read lock, see Shutdown
+	shutdownLock sync.RWMutex
+	closed       bool
+	// closing is closed once the blocks in flight are committed, it releases the waiters
+	closing chan struct{}
+	commits sync.WaitGroup
+	waiters sync.WaitGroup
+
+	writeListenersLock sync.RWMutex
+	writeListeners     []WriteListener
+
+	stateWriteListenersLock sync.RWMutex
+	stateWriteListeners     []driver.StateWriteListener
+
+	chaincodeListenersLock sync.RWMutex
+	chaincodeListeners     []ChaincodeEventListener
+
+	timestampListenersLock sync.RWMutex
+	timestampListeners     []TimestampListener
+
+	finalityListenersLock sync.RWMutex
+	finalityListeners     []FinalityListener
 }
 
-func New(channel string, network Network, finality Finality, waitForEventTimeout time.Duration, quiet bool, metrics Metrics, publisher events.Publisher) (*Committer, error) {
+// WriteListener is invoked with the namespaces written by a valid transaction, before its finality is notified
+type WriteListener func(txID string, namespaces []string)
+
+// ChaincodeEventListener is invoked with the chaincode events of the valid transactions, in commit order
+type ChaincodeEventListener func(event *ChaincodeEvent)
+
+// TimestampListener is invoked with the creator and the timestamp of the endorser transactions, in commit order
+type TimestampListener func(creator []byte, timestamp time.Time)
+
+// FinalityListener is invoked with the finality of the endorser transactions, valid or not, in commit order
+type FinalityListener func(event TxEvent)
+
+func New(channel string, network Network, finality Finality, waitForEventTimeout time.Duration, quiet bool, metrics Metrics, publisher events.Publisher, bus *events.Bus, limiter *Limiter, commitMetrics *CommitMetrics) (*Committer, error) {
 	if len(channel) == 0 {
 		return nil, errors.Errorf("expected a channel, got empty string")
 	}
@@ -67,80 +121,227 @@ func New(channel string, network Network, finality Finality, waitForEventTimeout
 		network:             network,
 		waitForEventTimeout: waitForEventTimeout,
 		quietNotifier:       quiet,
-		listeners:           map[string][]chan TxEvent{},
-		mutex:               sync.Mutex{},
 		finality:            finality,
 		pollingTimeout:      100 * time.Millisecond,
 		metrics:             metrics,
 		publisher:           publisher,
+		bus:                 bus,
+		limiter:             limiter,
+		commitMetrics:       commitMetrics,
+		limits:              DefaultLimits(),
+		latency:             NewLatencyTracker(),
+		closing:             make(chan struct{}),
+		waiting:             map[string][]chan TxEvent{},
 	}
 	return d, nil
 }
 
-// Commit commits the transactions in the block passed as argument
+// SetLimits sets the limits of the blocks and of the transactions processed, see DefaultLimits
+func (c *Committer) SetLimits(limits Limits) {
+	c.limits = limits
+}
+
+// SetQuarantine sets the quarantine the blocks rejected, in full or in part, are saved in
+func (c *Committer) SetQuarantine(quarantine *Quarantine) {
+	c.quarantine = quarantine
+}
+
+// Latency returns the tracker of the time the transactions broadcast by this node take to be committed
+func (c *Committer) Latency() *LatencyTracker {
+	return c.latency
+}
+
+// Commit commits the transactions in the block passed as argument.
+// Before processing the block, Commit acquires a slot from the limiter shared with the other channels.
+// A malformed block is rejected with a MalformedBlockError, a malformed transaction is marked as invalid,
+// with a MalformedTxError, and the rest of its block is committed.
+// Once the committer shuts down, the blocks are rejected with driver.ErrShuttingDown.
 func (c *Committer) Commit(block *common.Block) error {
-	for i, tx := range block.Data.Data {
+	if !c.enter(&c.commits) {
+		return errors.Wrapf(driver.ErrShuttingDown, "[%s] block [%d] not committed", c.channel, block.GetHeader().GetNumber())
+	}
+	defer c.commits.Done()
+
+	labels := []string{"network", c.network.Name(), "channel", c.channel}
+	if err := validateBlock(block, c.limits); err != nil {
+		c.reject(block, "block", err)
+		return err
+	}
+
+	waitStart := time.Now()
+	c.limiter.Acquire()
+	defer c.limiter.Release()
+	if c.commitMetrics != nil {
+		c.commitMetrics.SlotWaitDuration.With(labels...).Observe(time.Since(waitStart).Seconds())
+		c.commitMetrics.SlotsInUse.With(labels...).Add(1)
+		defer c.commitMetrics.SlotsInUse.With(labels...).Add(-1)
+	}
 
-		env, err := protoutil.UnmarshalEnvelope(tx)
-		if err != nil {
-			logger.Errorf("Error getting tx from block: %s", err)
+	commitStart := time.Now()
+	if err := c.commit(block); err != nil {
+		return err
+	}
+	if c.commitMetrics != nil {
+		c.commitMetrics.BlockCommitDuration.With(labels...).Observe(time.Since(commitStart).Seconds())
+		c.commitMetrics.BlocksCommitted.With(labels...).Add(1)
+	}
+	return nil
+}
+
+// commit commits the transactions of the passed block one at a time, in their order in the block, as Fabric does.
+// A config transaction is applied at its position, with CommitConfig, before the next transaction is processed:
+// the transactions preceding it in the block are validated with the previous configuration, those following it
+// with the new one. The config transactions are not expected to be alone in their block.
+func (c *Committer) commit(block *common.Block) error {
+	for i := range block.Data.Data {
+		if _, err := c.commitTx(block, i); err != nil {
 			return err
 		}
-		payl, err := protoutil.UnmarshalPayload(env.Payload)
-		if err != nil {
-			logger.Errorf("[%s] unmarshal payload failed: %s", c.channel, err)
-			return err
+	}
+
+	return nil
+}
+
+// commitTx commits the transaction at the passed position of the passed block, and notifies its finality.
+// It returns the id of the transaction.
+func (c *Committer) commitTx(block *common.Block, i int) (string, error) {
+	tx, err := parseTx(block, i, c.limits)
+	if err != nil {
+		return c.rejectTx(block, i, tx, err)
+	}
+	env, payl, chdr := tx.env, tx.payload, tx.chdr
+
+	var event TxEvent
+
+	c.metrics.EmitKey(0, "Committer", "start", "Commit", chdr.TxId)
+	switch common.HeaderType(chdr.Type) {
+	case common.HeaderType_CONFIG:
+		if logger.IsEnabledFor(zapcore.DebugLevel) {
+			logger.Debugf("[%s] Config transaction received: %s", c.channel, chdr.TxId)
 		}
-		chdr, err := protoutil.UnmarshalChannelHeader(payl.Header.ChannelHeader)
-		if err != nil {
-			logger.Errorf("[%s] unmarshal channel header failed: %s", c.channel, err)
-			return err
+		if err := c.handleConfig(block, i, env); err != nil {
+			return "", err
+		}
+	case common.HeaderType_ENDORSER_TRANSACTION:
+		if logger.IsEnabledFor(zapcore.DebugLevel) {
+			logger.Debugf("[%s] Endorser transaction received: %s", c.channel, chdr.TxId)
 		}
+		if err := c.handleEndorserTransaction(block, i, &event, env, chdr); err != nil {
+			return "", err
+		}
+		c.notifyTimestamp(payl.Header, chdr)
+		c.latency.Committed(chdr.TxId)
+	default:
+		if logger.IsEnabledFor(zapcore.DebugLevel) {
+			logger.Debugf("[%s] Received unhandled transaction type: %s", c.channel, chdr.Type)
+		}
+	}
+	c.metrics.EmitKey(0, "Committer", "end", "Commit", chdr.TxId)
 
-		var event TxEvent
+	c.notify(event)
 
-		c.metrics.EmitKey(0, "Committer", "start", "Commit", chdr.TxId)
-		switch common.HeaderType(chdr.Type) {
-		case common.HeaderType_CONFIG:
-			if logger.IsEnabledFor(zapcore.DebugLevel) {
-				logger.Debugf("[%s] Config transaction received: %s", c.channel, chdr.TxId)
-			}
-			if err := c.handleConfig(block, i, env); err != nil {
-				return err
-			}
-		case common.HeaderType_ENDORSER_TRANSACTION:
-			if logger.IsEnabledFor(zapcore.DebugLevel) {
-				logger.Debugf("[%s] Endorser transaction received: %s", c.channel, chdr.TxId)
-			}
-			if len(block.Metadata.Metadata) < int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
-				return errors.Errorf("block metadata lacks transaction filter")
-			}
-			if err := c.handleEndorserTransaction(block, i, &event, env, chdr); err != nil {
-				return err
-			}
-		default:
-			if logger.IsEnabledFor(zapcore.DebugLevel) {
-				logger.Debugf("[%s] Received unhandled transaction type: %s", c.channel, chdr.Type)
-			}
-		}
-		c.metrics.EmitKey(0, "Committer", "end", "Commit", chdr.TxId)
+	if logger.IsEnabledFor(zapcore.DebugLevel) {
+		logger.Debugf("commit transaction [%s] in filteredBlock [%d]", chdr.TxId, block.Header.Number)
+	}
+	return chdr.TxId, nil
+}
+
+// Reprocess commits again the passed transaction of the passed block, leaving the other transactions of the block alone.
+// It repairs a vault that missed the transaction, the transaction is committed as the block says.
+func (c *Committer) Reprocess(block *common.Block, txID string) error {
+	if !c.enter(&c.commits) {
+		return errors.Wrapf(driver.ErrShuttingDown, "[%s] transaction [%s] not reprocessed", c.channel, txID)
+	}
+	defer c.commits.Done()
 
-		c.notify(event)
+	c.limiter.Acquire()
+	defer c.limiter.Release()
 
-		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("commit transaction [%s] in filteredBlock [%d]", chdr.TxId, block.Header.Number)
+	if err := validateBlock(block, c.limits); err != nil {
+		return err
+	}
+	for i := range block.Data.Data {
+		// the transactions whose header cannot be read are not the one looked for
+		tx, _ := parseTx(block, i, c.limits)
+		if tx.chdr == nil || tx.chdr.TxId != txID {
+			continue
 		}
+		_, err := c.commitTx(block, i)
+		return err
 	}
+	return errors.Errorf("transaction [%s] not found in block [%d]", txID, block.Header.Number)
+}
 
-	return nil
+// Abandon releases the waiters of the finality of the passed transaction, and of the transactions depending on it,
+// with ErrTransactionAbandoned. The transaction must have been marked as abandoned in the vault.
+func (c *Committer) Abandon(txID string, dependantTxIDs []string) {
+	c.AbandonWithError(txID, dependantTxIDs, errors.Wrapf(driver.ErrTransactionAbandoned, "transaction [%s] has been abandoned", txID))
+}
+
+// AbandonWithError releases the waiters as Abandon does, but with the passed error, that tells why the transaction
+// has been abandoned. The error should match ErrTransactionAbandoned, as *driver.ErrTransactionExpired does.
+func (c *Committer) AbandonWithError(txID string, dependantTxIDs []string, err error) {
+	c.latency.Forget(txID)
+	c.notify(TxEvent{
+		Txid:           txID,
+		DependantTxIDs: dependantTxIDs,
+		Block:          driver.UnknownBlock,
+		IndexInBlock:   driver.UnknownTxNum,
+		Err:            err,
+	})
+}
+
+// AddWriteListener registers the passed listener, that is then invoked synchronously, from the commit pipeline,
+// for each valid transaction writing to at least one namespace
+func (c *Committer) AddWriteListener(listener WriteListener) {
+	c.writeListenersLock.Lock()
+	defer c.writeListenersLock.Unlock()
+	c.writeListeners = append(c.writeListeners, listener)
+}
+
+// AddStateWriteListener registers the passed listener, that is then invoked synchronously, from the commit pipeline,
+// with the keys written by each valid transaction writing to at least one key
+func (c *Committer) AddStateWriteListener(listener driver.StateWriteListener) {
+	c.stateWriteListenersLock.Lock()
+	defer c.stateWriteListenersLock.Unlock()
+	c.stateWriteListeners = append(c.stateWriteListeners, listener)
+}
+
+// AddChaincodeEventListener registers the passed listener, that is then invoked synchronously, from the commit pipeline,
+// for each chaincode event
+func (c *Committer) AddChaincodeEventListener(listener ChaincodeEventListener) {
+	c.chaincodeListenersLock.Lock()
+	defer c.chaincodeListenersLock.Unlock()
+	c.chaincodeListeners = append(c.chaincodeListeners, listener)
+}
+
+// AddTimestampListener registers the passed listener, that is then invoked synchronously, from the commit pipeline,
+// with the creator and the timestamp of each endorser transaction, whatever its validation code
+func (c *Committer) AddTimestampListener(listener TimestampListener) {
+	c.timestampListenersLock.Lock()
+	defer c.timestampListenersLock.Unlock()
+	c.timestampListeners = append(c.timestampListeners, listener)
+}
+
+// AddFinalityListener registers the passed listener, that is then invoked synchronously, from the commit pipeline,
+// with the finality of each endorser transaction, after the finality is notified to the transactions waiting for it
+func (c *Committer) AddFinalityListener(listener FinalityListener) {
+	c.finalityListenersLock.Lock()
+	defer c.finalityListenersLock.Unlock()
+	c.finalityListeners = append(c.finalityListeners, listener)
 }
 
 // IsFinal takes in input a transaction id and waits for its confirmation
 // with the respect to the passed context that can be used to set a deadline
 // for the waiting time.
+// Once the committer shuts down, the waiters of a transaction not final get driver.ErrShuttingDown.
 func (c *Committer) IsFinal(ctx context.Context, txID string) error {
 	c.metrics.EmitKey(0, "Committer", "start", "IsFinal", txID)
 	defer c.metrics.EmitKey(0, "Committer", "end", "IsFinal", txID)
+	if !c.enter(&c.waiters) {
+		return c.released(txID)
+	}
+	defer c.waiters.Done()
 
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("Is [%s] final?", txID)
@@ -165,6 +366,8 @@ func (c *Committer) IsFinal(ctx context.Context, txID string) error {
 					logger.Debugf("Tx [%s] is not valid", txID)
 				}
 				return errors.Errorf("transaction [%s] is not valid", txID)
+			case driver.Abandoned:
+				return errors.Wrapf(driver.ErrTransactionAbandoned, "transaction [%s] has been abandoned", txID)
 			case driver.Busy:
 				if logger.IsEnabledFor(zapcore.DebugLevel) {
 					logger.Debugf("Tx [%s] is known with deps [%v]", txID, deps)
@@ -232,66 +435,75 @@ func (c *Committer) IsFinal(ctx context.Context, txID string) error {
 	return c.listenTo(ctx, txID, c.waitForEventTimeout)
 }
 
-func (c *Committer) addListener(txid string, ch chan TxEvent) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	ls, ok := c.listeners[txid]
-	if !ok {
-		ls = []chan TxEvent{}
-		c.listeners[txid] = ls
+// status returns the status of the passed transaction in the vault of the current instance of the channel
+func (c *Committer) status(txID string) (driver.ValidationCode, error) {
+	committer, err := c.network.Committer(c.channel)
+	if err != nil {
+		return driver.Unknown, err
 	}
-	ls = append(ls, ch)
-	c.listeners[txid] = ls
+	vd, _, err := committer.Status(txID)
+	return vd, err
 }
 
-func (c *Committer) deleteListener(txid string, ch chan TxEvent) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	ls, ok := c.listeners[txid]
-	if !ok {
-		return
-	}
-	for i, l := range ls {
-		if l == ch {
-			ls = append(ls[:i], ls[i+1:]...)
-			c.listeners[txid] = ls
-			return
-		}
-	}
+// channelKey is the key the finality events of the channel are published with.
+// The bus is shared by the channels of all the networks.
+func (c *Committer) channelKey() string {
+	return c.network.Name() + "/" + c.channel
 }
 
+// notify publishes the finality event of a transaction, and of the transactions depending on it, on the bus.
+// The events of a channel are published in commit order.
 func (c *Committer) notify(event TxEvent) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
 	if event.Err != nil && !c.quietNotifier {
 		logger.Warningf("An error occurred for tx [%s], event: [%v]", event.Txid, event)
 	}
 
-	listeners := c.listeners[event.Txid]
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("Notify the finality of [%s] to [%d] listeners, event: [%v]", event.Txid, len(listeners), event)
+		logger.Debugf("Notify the finality of [%s] and its dependants [%v], event: [%v]", event.Txid, event.DependantTxIDs, event)
 	}
-	for _, listener := range listeners {
-		listener <- event
+	if err := events.Publish(c.bus, event, c.channelKey()); err != nil {
+		logger.Warnf("failed notifying the finality of [%s]: [%s]", event.Txid, err)
 	}
 
-	for _, txid := range event.DependantTxIDs {
-		listeners := c.listeners[txid]
-		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("Notify the finality of [%s] (dependant) to [%d] listeners, event: [%v]", txid, len(listeners), event)
-		}
-		for _, listener := range listeners {
-			listener <- event
-		}
+	if len(event.Txid) == 0 {
+		return
+	}
+	c.finalityListenersLock.RLock()
+	listeners := c.finalityListeners
+	c.finalityListenersLock.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
 	}
 }
 
 // notifyChaincodeListeners notifies the chaincode event to the registered chaincode listeners.
 func (c *Committer) notifyChaincodeListeners(event *ChaincodeEvent) {
 	c.publisher.Publish(event)
+
+	c.chaincodeListenersLock.RLock()
+	listeners := c.chaincodeListeners
+	c.chaincodeListenersLock.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+func (c *Committer) notifyTimestamp(header *common.Header, chdr *common.ChannelHeader) {
+	c.timestampListenersLock.RLock()
+	listeners := c.timestampListeners
+	c.timestampListenersLock.RUnlock()
+	if len(listeners) == 0 || chdr.Timestamp == nil {
+		return
+	}
+	shdr, err := protoutil.UnmarshalSignatureHeader(header.SignatureHeader)
+	if err != nil {
+		logger.Debugf("[%s] invalid signature header of [%s]: %s", c.channel, chdr.TxId, err)
+		return
+	}
+	timestamp := chdr.Timestamp.AsTime()
+	for _, listener := range listeners {
+		listener(shdr.Creator, timestamp)
+	}
 }
 
 func (c *Committer) listenTo(ctx context.Context, txid string, timeout time.Duration) error {
@@ -301,17 +513,19 @@ func (c *Committer) listenTo(ctx context.Context, txid string, timeout time.Dura
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("Listen to finality of [%s]", txid)
 	}
+	if !c.enter(&c.waiters) {
+		return c.released(txid)
+	}
+	defer c.waiters.Done()
 
 	// notice that adding the listener can happen after the event we are looking for has already happened
 	// therefore we need to check more often before the timeout happens
-	ch := make(chan TxEvent, 100)
-	c.addListener(txid, ch)
-	defer c.deleteListener(txid, ch)
-
-	committer, err := c.network.Committer(c.channel)
+	ch, err := c.wait(txid)
 	if err != nil {
-		return err
+		return errors.WithMessagef(err, "failed listening to finality of [%s]", txid)
 	}
+	defer c.stopWaiting(txid, ch)
+
 	iterations := int(timeout.Milliseconds() / c.pollingTimeout.Milliseconds())
 	if iterations == 0 {
 		iterations = 1
@@ -330,12 +544,22 @@ func (c *Committer) listenTo(ctx context.Context, txid string, timeout time.Dura
 			}
 			timeout.Stop()
 			return event.Err
+		case <-c.closing:
+			timeout.Stop()
+			// the event of a block committed before the shutdown may still be on its way
+			select {
+			case event := <-ch:
+				return event.Err
+			default:
+			}
+			return c.released(txid)
 		case <-timeout.C:
 			timeout.Stop()
 			if logger.IsEnabledFor(zapcore.DebugLevel) {
 				logger.Debugf("Got a timeout for finality of [%s], check the status", txid)
 			}
-			vd, _, err := committer.Status(txid)
+			// the channel is looked up at each check, the waiters survive its restarts
+			vd, err := c.status(txid)
 			if err == nil {
 				switch vd {
 				case driver.Valid:
@@ -348,6 +572,8 @@ func (c *Committer) listenTo(ctx context.Context, txid string, timeout time.Dura
 						logger.Debugf("Listen to finality of [%s]. NOT VALID", txid)
 					}
 					return errors.Errorf("transaction [%s] is not valid", txid)
+				case driver.Abandoned:
+					return errors.Wrapf(driver.ErrTransactionAbandoned, "transaction [%s] has been abandoned", txid)
 				}
 			}
 			if logger.IsEnabledFor(zapcore.DebugLevel) {
@@ -363,3 +589,52 @@ func (c *Committer) listenTo(ctx context.Context, txid string, timeout time.Dura
 	}
 	return errors.Errorf("failed to listen to transaction [%s] for timeout", txid)
 }
+
+// wait returns the channel the finality event of the passed transaction is sent to, see stopWaiting.
+// The first call subscribes the committer to the finality events of its channel.
+func (c *Committer) wait(txid string) (chan TxEvent, error) {
+	c.subscribeOnce.Do(func() {
+		c.subscription, c.subscribeErr = events.Subscribe(c.bus, c.dispatch, events.WithKey(c.channelKey()))
+	})
+	if c.subscribeErr != nil {
+		return nil, c.subscribeErr
+	}
+	ch := make(chan TxEvent, 1)
+	c.waitingLock.Lock()
+	c.waiting[txid] = append(c.waiting[txid], ch)
+	c.waitingLock.Unlock()
+	return ch, nil
+}
+
+// stopWaiting removes the passed channel returned by wait
+func (c *Committer) stopWaiting(txid string, ch chan TxEvent) {
+	c.waitingLock.Lock()
+	defer c.waitingLock.Unlock()
+	list := c.waiting[txid]
+	for i, e := range list {
+		if e == ch {
+			list = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(c.waiting, txid)
+	} else {
+		c.waiting[txid] = list
+	}
+}
+
+// dispatch sends the passed finality event to the transactions waiting for it, the first event is the answer,
+// the committer does not wait for the waiters
+func (c *Committer) dispatch(event TxEvent) {
+	c.waitingLock.Lock()
+	defer c.waitingLock.Unlock()
+	for _, txid := range append([]string{event.Txid}, event.DependantTxIDs...) {
+		for _, ch := range c.waiting[txid] {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,505 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/tracing"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/test-go/testify/assert"
+)
+
+// blockingCommitter signals on started the config commits it gets, and blocks them until release is closed.
+// With nil channels, it commits right away.
+type blockingCommitter struct {
+	driver.Committer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingCommitter) CommitConfig(blockNumber uint64, indexInBlock int, raw []byte, envelope *common.Envelope) error {
+	if b.started != nil {
+		b.started <- struct{}{}
+		<-b.release
+	}
+	return nil
+}
+
+type fakeNetwork struct {
+	committers map[string]driver.Committer
+}
+
+func (f *fakeNetwork) Name() string {
+	return "network"
+}
+
+func (f *fakeNetwork) Committer(channel string) (driver.Committer, error) {
+	return f.committers[channel], nil
+}
+
+func (f *fakeNetwork) PickPeer() *grpc.ConnectionConfig {
+	return &grpc.ConnectionConfig{}
+}
+
+func (f *fakeNetwork) Ledger(channel string) (driver.Ledger, error) {
+	return nil, nil
+}
+
+func newConfigBlock(channel string, number uint64) *common.Block {
+	payload := &common.Payload{
+		Header: &common.Header{
+			ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{
+				Type:      int32(common.HeaderType_CONFIG),
+				ChannelId: channel,
+				TxId:      "tx",
+			}),
+		},
+		Data: protoutil.MarshalOrPanic(&common.ConfigEnvelope{Config: &common.Config{ChannelGroup: &common.ConfigGroup{}}}),
+	}
+	env := &common.Envelope{Payload: protoutil.MarshalOrPanic(payload)}
+	block := protoutil.NewBlock(number, nil)
+	block.Data.Data = [][]byte{protoutil.MarshalOrPanic(env)}
+	return block
+}
+
+func TestParallelChannelCommit(t *testing.T) {
+	slowCommitter := &blockingCommitter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	network := &fakeNetwork{committers: map[string]driver.Committer{
+		"slow": slowCommitter,
+		"fast": &blockingCommitter{},
+	}}
+	limiter := NewLimiter(2)
+	commitMetrics := NewCommitMetrics(&disabled.Provider{})
+
+	slow, err := New("slow", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), limiter, commitMetrics)
+	assert.NoError(t, err)
+	fast, err := New("fast", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), limiter, commitMetrics)
+	assert.NoError(t, err)
+
+	slowDone := make(chan error, 1)
+	go func() {
+		slowDone <- slow.Commit(newConfigBlock("slow", 0))
+	}()
+	// the slow channel holds its limiter slot until released
+	<-slowCommitter.started
+
+	fastDone := make(chan error, 1)
+	go func() {
+		for i := uint64(0); i < 10; i++ {
+			if err := fast.Commit(newConfigBlock("fast", i)); err != nil {
+				fastDone <- err
+				return
+			}
+		}
+		fastDone <- nil
+	}()
+	select {
+	case err := <-fastDone:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("commits on the fast channel must not wait for the slow one")
+	}
+	select {
+	case <-slowDone:
+		t.Fatal("the slow channel must still be blocked")
+	default:
+	}
+
+	close(slowCommitter.release)
+	assert.NoError(t, <-slowDone)
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(1)
+	limiter.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("limiter must not grant more slots than its capacity")
+	case <-time.After(50 * time.Millisecond):
+	}
+	limiter.Release()
+	<-acquired
+	limiter.Release()
+
+	// a non positive capacity means no cap
+	unlimited := NewLimiter(0)
+	for i := 0; i < 10; i++ {
+		unlimited.Acquire()
+	}
+}
+
+func TestFinalityEvents(t *testing.T) {
+	network := &fakeNetwork{committers: map[string]driver.Committer{}}
+	bus := events.NewBus()
+	limiter := NewLimiter(0)
+	commitMetrics := NewCommitMetrics(&disabled.Provider{})
+	ch1, err := New("ch1", network, nil, time.Second, true, tracing.NewNullAgent(), nil, bus, limiter, commitMetrics)
+	assert.NoError(t, err)
+	ch2, err := New("ch2", network, nil, time.Second, true, tracing.NewNullAgent(), nil, bus, limiter, commitMetrics)
+	assert.NoError(t, err)
+	// the events are expected before the vault is polled
+	ch1.pollingTimeout = time.Second
+	ch2.pollingTimeout = time.Second
+
+	listen := func(c *Committer, txid string) chan error {
+		res := make(chan error, 1)
+		go func() { res <- c.listenTo(context.Background(), txid, time.Second) }()
+		return res
+	}
+	tx1, tx2, dependant := listen(ch1, "tx1"), listen(ch1, "tx2"), listen(ch1, "tx3")
+	other := listen(ch2, "tx1")
+	// give the listeners the time to subscribe
+	time.Sleep(50 * time.Millisecond)
+
+	var finalities []string
+	ch1.AddFinalityListener(func(event TxEvent) { finalities = append(finalities, event.Txid) })
+	ch1.notify(TxEvent{Txid: "tx1", Committed: true})
+	ch1.notify(TxEvent{Txid: "tx2", DependantTxIDs: []string{"tx3"}, Err: errors.New("invalid")})
+	// the transactions other than the endorser transactions have no id, their finality does not reach the listeners
+	ch1.notify(TxEvent{})
+	assert.Equal(t, []string{"tx1", "tx2"}, finalities)
+	assert.NoError(t, <-tx1)
+	assert.EqualError(t, <-tx2, "invalid")
+	assert.EqualError(t, <-dependant, "invalid")
+
+	// the events of a channel do not reach the listeners of the others
+	select {
+	case err := <-other:
+		t.Fatalf("unexpected finality on another channel [%v]", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	ch2.notify(TxEvent{Txid: "tx1", Committed: true})
+	assert.NoError(t, <-other)
+
+	// the waiters share the subscription of their channel, and leave no trace once answered
+	assert.NotNil(t, ch1.subscription)
+	assert.Empty(t, ch1.waiting)
+	assert.Empty(t, ch2.waiting)
+	assert.NoError(t, bus.Close(context.Background()))
+}
+
+type validCommitter struct {
+	driver.Committer
+}
+
+func (v *validCommitter) Status(txid string) (driver.ValidationCode, []string, error) {
+	return driver.Valid, nil, nil
+}
+
+func (v *validCommitter) CommitTX(txid string, block uint64, indexInBloc int, envelope *common.Envelope) error {
+	return nil
+}
+
+func newEndorserTxBlock(t *testing.T, channel string, number uint64, txid string, vc pb.TxValidationCode) *common.Block {
+	rwsb := rwsetutil.NewRWSetBuilder()
+	rwsb.AddToWriteSet("asset", "k", []byte("v"))
+	rwsb.AddToReadSet("reference", "k", nil)
+	results, err := rwsb.GetTxSimulationResults()
+	assert.NoError(t, err)
+	pubResults, err := results.GetPubSimulationBytes()
+	assert.NoError(t, err)
+
+	prp := protoutil.MarshalOrPanic(&pb.ProposalResponsePayload{
+		Extension: protoutil.MarshalOrPanic(&pb.ChaincodeAction{Results: pubResults}),
+	})
+	actionPayload := protoutil.MarshalOrPanic(&pb.ChaincodeActionPayload{
+		Action: &pb.ChaincodeEndorsedAction{ProposalResponsePayload: prp},
+	})
+	payload := &common.Payload{
+		Header: &common.Header{
+			ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{
+				Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+				ChannelId: channel,
+				TxId:      txid,
+			}),
+		},
+		Data: protoutil.MarshalOrPanic(&pb.Transaction{Actions: []*pb.TransactionAction{{Payload: actionPayload}}}),
+	}
+	env := &common.Envelope{Payload: protoutil.MarshalOrPanic(payload)}
+	block := protoutil.NewBlock(number, nil)
+	block.Data.Data = [][]byte{protoutil.MarshalOrPanic(env)}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{uint8(vc)}
+	return block
+}
+
+func TestWriteListeners(t *testing.T) {
+	network := &fakeNetwork{committers: map[string]driver.Committer{"ch": &validCommitter{}}}
+	c, err := New("ch", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), NewLimiter(0), NewCommitMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+
+	type write struct {
+		txID       string
+		namespaces []string
+	}
+	var writes []write
+	c.AddWriteListener(func(txID string, namespaces []string) {
+		writes = append(writes, write{txID: txID, namespaces: namespaces})
+	})
+
+	// only the namespaces written by the valid transactions are notified
+	assert.NoError(t, c.Commit(newEndorserTxBlock(t, "ch", 1, "tx1", pb.TxValidationCode_VALID)))
+	assert.NoError(t, c.Commit(newEndorserTxBlock(t, "ch", 2, "tx2", pb.TxValidationCode_MVCC_READ_CONFLICT)))
+	assert.Equal(t, []write{{txID: "tx1", namespaces: []string{"asset"}}}, writes)
+}
+
+// statusCommitter is a committer whose transactions have the status in codes, the committed and discarded ones are recorded
+type statusCommitter struct {
+	driver.Committer
+	codes     map[string]driver.ValidationCode
+	committed []string
+}
+
+func (s *statusCommitter) Status(txid string) (driver.ValidationCode, []string, error) {
+	if code, ok := s.codes[txid]; ok {
+		return code, nil, nil
+	}
+	return driver.Unknown, nil, nil
+}
+
+func (s *statusCommitter) CommitTX(txid string, block uint64, indexInBloc int, envelope *common.Envelope) error {
+	s.committed = append(s.committed, txid)
+	s.codes[txid] = driver.Valid
+	return nil
+}
+
+func TestReprocessAndAbandon(t *testing.T) {
+	committer := &statusCommitter{codes: map[string]driver.ValidationCode{"tx1": driver.Busy, "tx2": driver.Valid}}
+	network := &fakeNetwork{committers: map[string]driver.Committer{"ch": committer}}
+	c, err := New("ch", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), NewLimiter(0), NewCommitMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+	c.pollingTimeout = time.Second
+
+	// only the passed transaction of the block is committed again
+	block := newEndorserTxBlock(t, "ch", 5, "tx2", pb.TxValidationCode_VALID)
+	block.Data.Data = append(block.Data.Data, newEndorserTxBlock(t, "ch", 5, "tx1", pb.TxValidationCode_VALID).Data.Data[0])
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{uint8(pb.TxValidationCode_VALID), uint8(pb.TxValidationCode_VALID)}
+	var finalities []TxEvent
+	c.AddFinalityListener(func(event TxEvent) { finalities = append(finalities, event) })
+	assert.NoError(t, c.Reprocess(block, "tx1"))
+	assert.Equal(t, []string{"tx1"}, committer.committed)
+	assert.Len(t, finalities, 1)
+	assert.Equal(t, uint64(5), finalities[0].Block)
+	assert.Equal(t, 1, finalities[0].IndexInBlock)
+	assert.EqualError(t, c.Reprocess(block, "tx3"), "transaction [tx3] not found in block [5]")
+
+	// the waiters of an abandoned transaction, and of its dependants, are released with ErrTransactionAbandoned
+	res := make(chan error, 2)
+	go func() { res <- c.listenTo(context.Background(), "tx3", time.Second) }()
+	go func() { res <- c.listenTo(context.Background(), "tx4", time.Second) }()
+	time.Sleep(50 * time.Millisecond)
+	committer.codes["tx3"] = driver.Abandoned
+	c.Abandon("tx3", []string{"tx4"})
+	for i := 0; i < 2; i++ {
+		assert.True(t, errors.Is(<-res, driver.ErrTransactionAbandoned))
+	}
+	assert.True(t, errors.Is(c.IsFinal(context.Background(), "tx3"), driver.ErrTransactionAbandoned))
+}
+
+// outcomeCommitter is a statusCommitter recording the validation codes of the transactions it discards
+type outcomeCommitter struct {
+	*statusCommitter
+	discarded map[string]pb.TxValidationCode
+}
+
+func (o *outcomeCommitter) StatusWithMessage(txid string) (*driver.ValidationStatus, error) {
+	code, _, _ := o.Status(txid)
+	return &driver.ValidationStatus{TxID: txid, Code: code}, nil
+}
+
+func (o *outcomeCommitter) DiscardTxWithCode(txid string, block uint64, txNum int, code pb.TxValidationCode, message string) error {
+	o.discarded[txid] = code
+	o.codes[txid] = driver.Invalid
+	return nil
+}
+
+func TestReplayedTransactions(t *testing.T) {
+	committer := &outcomeCommitter{
+		statusCommitter: &statusCommitter{codes: map[string]driver.ValidationCode{"tx2": driver.Busy}},
+		discarded:       map[string]pb.TxValidationCode{},
+	}
+	network := &fakeNetwork{committers: map[string]driver.Committer{"ch": committer}}
+	c, err := New("ch", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), NewLimiter(0), NewCommitMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+	var finalities []TxEvent
+	c.AddFinalityListener(func(event TxEvent) { finalities = append(finalities, event) })
+
+	// the replay of a committed transaction leaves it valid
+	assert.NoError(t, c.Commit(newEndorserTxBlock(t, "ch", 1, "tx1", pb.TxValidationCode_VALID)))
+	assert.NoError(t, c.Commit(newEndorserTxBlock(t, "ch", 2, "tx1", pb.TxValidationCode_DUPLICATE_TXID)))
+	assert.Equal(t, []string{"tx1"}, committer.committed)
+	assert.Equal(t, driver.Valid, committer.codes["tx1"])
+	assert.Empty(t, committer.discarded)
+	assert.Len(t, finalities, 2)
+	assert.NoError(t, finalities[1].Err)
+
+	// a busy transaction whose identifier is used already is discarded with the code of the peers
+	assert.NoError(t, c.Commit(newEndorserTxBlock(t, "ch", 3, "tx2", pb.TxValidationCode_DUPLICATE_TXID)))
+	assert.Equal(t, map[string]pb.TxValidationCode{"tx2": pb.TxValidationCode_DUPLICATE_TXID}, committer.discarded)
+	assert.Equal(t, driver.Invalid, committer.codes["tx2"])
+	assert.Len(t, finalities, 3)
+	assert.EqualError(t, finalities[2].Err, "transaction [tx2] status is not valid: DUPLICATE_TXID")
+}
+
+// loadedCommitter is a committer taking delay to commit each transaction, its transactions are valid once committed
+type loadedCommitter struct {
+	driver.Committer
+	delay time.Duration
+	lock  sync.Mutex
+	valid map[string]bool
+}
+
+func (l *loadedCommitter) Status(txid string) (driver.ValidationCode, []string, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.valid[txid] {
+		return driver.Valid, nil, nil
+	}
+	return driver.Unknown, nil, nil
+}
+
+func (l *loadedCommitter) CommitTX(txid string, block uint64, indexInBloc int, envelope *common.Envelope) error {
+	time.Sleep(l.delay)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.valid[txid] = true
+	return nil
+}
+
+func TestShutdownUnderLoad(t *testing.T) {
+	committer := &loadedCommitter{delay: 5 * time.Millisecond, valid: map[string]bool{}}
+	network := &fakeNetwork{committers: map[string]driver.Committer{"ch": committer}}
+	c, err := New("ch", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), NewLimiter(0), NewCommitMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+
+	// the blocks keep coming until the shutdown rejects them
+	rejected := make(chan error, 1)
+	go func() {
+		for i := uint64(1); ; i++ {
+			if err := c.Commit(newEndorserTxBlock(t, "ch", i, fmt.Sprintf("tx%d", i), pb.TxValidationCode_VALID)); err != nil {
+				rejected <- err
+				return
+			}
+		}
+	}()
+
+	// the waiters of the transactions being committed, and of transactions never committed, would wait for a minute
+	type result struct {
+		txID string
+		err  error
+	}
+	const waiters = 100
+	results := make(chan result, waiters)
+	for i := 0; i < waiters; i++ {
+		txID := fmt.Sprintf("tx%d", i%50)
+		if i%2 == 1 {
+			txID = fmt.Sprintf("unknown%d", i)
+		}
+		go func() { results <- result{txID: txID, err: c.listenTo(context.Background(), txID, time.Minute)} }()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.NoError(t, c.Shutdown(ctx))
+	assert.True(t, errors.Is(<-rejected, driver.ErrShuttingDown))
+
+	// every waiter is released within the deadline, with the status of its transaction once the commits are done
+	for i := 0; i < waiters; i++ {
+		select {
+		case r := <-results:
+			if v, _, _ := committer.Status(r.txID); v == driver.Valid {
+				assert.NoError(t, r.err, r.txID)
+			} else {
+				assert.True(t, errors.Is(r.err, driver.ErrShuttingDown), r.txID)
+			}
+		case <-ctx.Done():
+			t.Fatalf("waiter %d not released at shutdown", i)
+		}
+	}
+
+	// the committer stays shut down
+	assert.True(t, errors.Is(c.Commit(newEndorserTxBlock(t, "ch", 1000, "late", pb.TxValidationCode_VALID)), driver.ErrShuttingDown))
+	assert.True(t, errors.Is(c.IsFinal(context.Background(), "unknown1"), driver.ErrShuttingDown))
+	assert.NoError(t, c.IsFinal(context.Background(), "tx1"))
+	assert.NoError(t, c.Shutdown(ctx))
+}
+
+// configCommitter records the config in force when each transaction is committed, a config transaction
+// replaces the config in force with the id of its block and its position
+type configCommitter struct {
+	driver.Committer
+	config    string
+	validated map[string]string
+}
+
+func (c *configCommitter) CommitConfig(blockNumber uint64, indexInBlock int, raw []byte, envelope *common.Envelope) error {
+	c.config = fmt.Sprintf("%d/%d", blockNumber, indexInBlock)
+	return nil
+}
+
+func (c *configCommitter) Status(txid string) (driver.ValidationCode, []string, error) {
+	return driver.Unknown, nil, nil
+}
+
+func (c *configCommitter) CommitTX(txid string, block uint64, indexInBloc int, envelope *common.Envelope) error {
+	c.validated[txid] = c.config
+	return nil
+}
+
+// newMixedBlock returns a block holding the config transaction, at the passed position, and the passed endorser transactions
+func newMixedBlock(t *testing.T, number uint64, configAt int, txids ...string) *common.Block {
+	block := protoutil.NewBlock(number, nil)
+	for _, txid := range txids {
+		block.Data.Data = append(block.Data.Data, newEndorserTxBlock(t, "ch", number, txid, pb.TxValidationCode_VALID).Data.Data[0])
+	}
+	config := newConfigBlock("ch", number).Data.Data[0]
+	block.Data.Data = append(block.Data.Data[:configAt], append([][]byte{config}, block.Data.Data[configAt:]...)...)
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = make([]byte, len(block.Data.Data))
+	return block
+}
+
+func TestMixedConfigBlocks(t *testing.T) {
+	ch := &configCommitter{config: "genesis", validated: map[string]string{}}
+	network := &fakeNetwork{committers: map[string]driver.Committer{"ch": ch}}
+	c, err := New("ch", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), NewLimiter(0), NewCommitMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+
+	// config first: the transactions of the block are validated with the new config
+	assert.NoError(t, c.Commit(newMixedBlock(t, 1, 0, "tx1", "tx2")))
+	assert.Equal(t, map[string]string{"tx1": "1/0", "tx2": "1/0"}, ch.validated)
+
+	// config last: the transactions of the block are validated with the previous config
+	assert.NoError(t, c.Commit(newMixedBlock(t, 2, 2, "tx3", "tx4")))
+	assert.Equal(t, "1/0", ch.validated["tx3"])
+	assert.Equal(t, "1/0", ch.validated["tx4"])
+	assert.Equal(t, "2/2", ch.config)
+
+	// config in the middle: each transaction is validated with the config in force at its position
+	assert.NoError(t, c.Commit(newMixedBlock(t, 3, 1, "tx5", "tx6")))
+	assert.Equal(t, "2/2", ch.validated["tx5"])
+	assert.Equal(t, "3/1", ch.validated["tx6"])
+}
@@ -16,7 +16,7 @@ func (c *Committer) handleConfig(block *common.Block, i int, env *common.Envelop
 	if err != nil {
 		return errors.Wrapf(err, "cannot get Committer for channel [%s]", c.channel)
 	}
-	if err := committer.CommitConfig(block.Header.Number, block.Data.Data[i], env); err != nil {
+	if err := committer.CommitConfig(block.Header.Number, i, block.Data.Data[i], env); err != nil {
 		return errors.Wrapf(err, "cannot commit config envelope for channel [%s]", c.channel)
 	}
 	return nil
@@ -7,8 +7,14 @@ SPDX-License-Identifier: Apache-2.0
 package committer
 
 import (
+	"fmt"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
 	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
 	"go.uber.org/zap/zapcore"
 
@@ -27,10 +33,18 @@ func (c *Committer) handleEndorserTransaction(block *common.Block, i int, event
 		if err := c.CommitEndorserTransaction(txID, block, i, env, event); err != nil {
 			return errors.Wrapf(err, "failed committing transaction [%s]", txID)
 		}
-		if err := c.getChaincodeEvents(env, block); err != nil {
+		if err := c.getChaincodeEvents(env, block, i); err != nil {
 			return errors.Wrapf(err, "failed to publish chaincode events [%s]", txID)
 		}
+		if err := c.notifyWrites(txID, env); err != nil {
+			return errors.Wrapf(err, "failed to notify the writes of [%s]", txID)
+		}
+		if err := c.notifyStateWrites(txID, block.Header.Number, uint64(i), env); err != nil {
+			return errors.Wrapf(err, "failed to notify the state writes of [%s]", txID)
+		}
 	default:
+		event.Block = block.Header.Number
+		event.IndexInBlock = i
 		if err := c.DiscardEndorserTransaction(txID, block, event, validationCode); err != nil {
 			return errors.Wrapf(err, "failed discarding transaction [%s]", txID)
 		}
@@ -39,8 +53,8 @@ func (c *Committer) handleEndorserTransaction(block *common.Block, i int, event
 }
 
 // getChaincodeEvents reads the chaincode events and notifies the listeners registered to the specific chaincode.
-func (c *Committer) getChaincodeEvents(env *common.Envelope, block *common.Block) error {
-	chaincodeEvent, err := readChaincodeEvent(env, block.Header.Number)
+func (c *Committer) getChaincodeEvents(env *common.Envelope, block *common.Block, i int) error {
+	chaincodeEvent, err := readChaincodeEvent(env, block.Header.Number, i)
 	if err != nil {
 		return errors.Wrapf(err, "error reading chaincode event")
 	}
@@ -53,6 +67,112 @@ func (c *Committer) getChaincodeEvents(env *common.Envelope, block *common.Block
 	return nil
 }
 
+// notifyWrites invokes the write listeners with the namespaces written by the passed transaction
+func (c *Committer) notifyWrites(txID string, env *common.Envelope) error {
+	c.writeListenersLock.RLock()
+	listeners := c.writeListeners
+	c.writeListenersLock.RUnlock()
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	namespaces, err := writtenNamespaces(env)
+	if err != nil {
+		return err
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+	if logger.IsEnabledFor(zapcore.DebugLevel) {
+		logger.Debugf("transaction [%s] writes to namespaces [%v]", txID, namespaces)
+	}
+	for _, listener := range listeners {
+		listener(txID, namespaces)
+	}
+	return nil
+}
+
+// readTxRWSet returns the read-write set of the passed transaction, nil if it has none
+func readTxRWSet(env *common.Envelope) (*rwset.TxReadWriteSet, error) {
+	chaincodeAction, err := protoutil.GetActionFromEnvelopeMsg(env)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting chaincode actions from envelope")
+	}
+	if chaincodeAction == nil || len(chaincodeAction.Results) == 0 {
+		return nil, nil
+	}
+	txRWSet := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(chaincodeAction.Results, txRWSet); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshalling the read-write set")
+	}
+	return txRWSet, nil
+}
+
+// writtenNamespaces returns the namespaces the passed transaction writes to, including the ones whose
+// private collections it writes to
+func writtenNamespaces(env *common.Envelope) ([]string, error) {
+	txRWSet, err := readTxRWSet(env)
+	if err != nil || txRWSet == nil {
+		return nil, err
+	}
+	var namespaces []string
+	for _, nsRWSet := range txRWSet.NsRwset {
+		kvRWSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(nsRWSet.Rwset, kvRWSet); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshalling the read-write set of namespace [%s]", nsRWSet.Namespace)
+		}
+		if len(kvRWSet.Writes) != 0 || len(kvRWSet.MetadataWrites) != 0 || len(nsRWSet.CollectionHashedRwset) != 0 {
+			namespaces = append(namespaces, nsRWSet.Namespace)
+		}
+	}
+	return namespaces, nil
+}
+
+// notifyStateWrites invokes the state write listeners with the keys written by the passed transaction
+func (c *Committer) notifyStateWrites(txID string, block, txNum uint64, env *common.Envelope) error {
+	c.stateWriteListenersLock.RLock()
+	listeners := c.stateWriteListeners
+	c.stateWriteListenersLock.RUnlock()
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	writes, err := writtenStates(env, block, txNum)
+	if err != nil {
+		return err
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	for _, listener := range listeners {
+		listener(txID, writes)
+	}
+	return nil
+}
+
+// writtenStates returns the public keys written by the passed transaction, at the passed height
+func writtenStates(env *common.Envelope, block, txNum uint64) ([]driver.StateWrite, error) {
+	txRWSet, err := readTxRWSet(env)
+	if err != nil || txRWSet == nil {
+		return nil, err
+	}
+	var writes []driver.StateWrite
+	for _, nsRWSet := range txRWSet.NsRwset {
+		kvRWSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(nsRWSet.Rwset, kvRWSet); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshalling the read-write set of namespace [%s]", nsRWSet.Namespace)
+		}
+		for _, w := range kvRWSet.Writes {
+			write := driver.StateWrite{Namespace: nsRWSet.Namespace, Key: w.Key, Block: block, TxNum: txNum}
+			if !w.IsDelete {
+				write.Value = append([]byte{}, w.Value...)
+			}
+			writes = append(writes, write)
+		}
+	}
+	return writes, nil
+}
+
 // CommitEndorserTransaction commits the transaction to the vault
 func (c *Committer) CommitEndorserTransaction(txID string, block *common.Block, indexInBlock int, env *common.Envelope, event *TxEvent) error {
 	committer, err := c.network.Committer(c.channel)
@@ -78,9 +198,12 @@ func (c *Committer) CommitEndorserTransaction(txID string, block *common.Block,
 	switch vc {
 	case driver.Valid:
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("transaction [%s] in block [%d] is already marked as valid, skipping", txID, blockNum)
+			logger.Debugf("transaction [%s] in block [%d] is already marked as valid, reconcile", txID, blockNum)
+		}
+		// committed by the local bookkeeping already, the record gets the height of the block, nothing else is written
+		if err := committer.CommitTX(event.Txid, event.Block, event.IndexInBlock, env); err != nil {
+			return errors.Wrapf(err, "failed reconciling transaction [%s]", txID)
 		}
-		// Nothing to commit
 	case driver.Invalid:
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf("transaction [%s] in block [%d] is marked as invalid, skipping", txID, blockNum)
@@ -118,18 +241,25 @@ func (c *Committer) DiscardEndorserTransaction(txID string, block *common.Block,
 		return errors.Wrapf(err, "failed getting tx's status [%s]", txID)
 	}
 	event.DependantTxIDs = append(event.DependantTxIDs, deps...)
-	switch vc {
-	case driver.Valid:
-		// TODO: this might be due the fact that there are transactions with the same tx-id, the first is valid, the others are all invalid
-		logger.Warnf("transaction [%s] in block [%d] is marked as valid but for fabric is invalid", txID, blockNum)
-	case driver.Invalid:
+	switch {
+	case vc == driver.Valid && validationCode == pb.TxValidationCode_DUPLICATE_TXID:
+		// a replay of a transaction on the ledger already, the original keeps its status
+		logger.Infof("transaction [%s] in block [%d] replays a transaction committed already, ignored", txID, blockNum)
+	case vc == driver.Valid:
+		logger.Warnf("transaction [%s] in block [%d] is marked as valid but for fabric is invalid [%s]", txID, blockNum, validationCode)
+	case vc == driver.Invalid:
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf("transaction [%s] in block [%d] is marked as invalid, skipping", txID, blockNum)
 		}
 		// Nothing to commit
 	default:
-		event.Err = errors.Errorf("transaction [%s] status is not valid: %d", txID, validationCode)
-		err = committer.DiscardTx(event.Txid)
+		event.Err = errors.Errorf("transaction [%s] status is not valid: %s", txID, validationCode)
+		message := fmt.Sprintf("invalidated in block [%d] at position [%d]", blockNum, event.IndexInBlock)
+		if oc, ok := committer.(driver.OutcomeCommitter); ok {
+			err = oc.DiscardTxWithCode(event.Txid, blockNum, event.IndexInBlock, validationCode, message)
+		} else {
+			err = committer.DiscardTx(event.Txid)
+		}
 		if err != nil {
 			logger.Errorf("failed discarding tx in state db with err [%s]", err)
 		}
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package committer
 
 import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/pkg/errors"
 
 	"github.com/hyperledger/fabric-protos-go/common"
@@ -14,7 +15,9 @@ import (
 	"github.com/hyperledger/fabric/protoutil"
 )
 
-// TxEvent contains information for token transaction commit
+// TxEvent contains information for token transaction commit.
+// It is published on the event bus, with the key of its network and channel, once the transaction is committed.
+// The events of a channel are published in commit order.
 type TxEvent struct {
 	Txid           string
 	DependantTxIDs []string
@@ -26,21 +29,7 @@ type TxEvent struct {
 }
 
 // ChaincodeEvent models the chaincode event details.
-type ChaincodeEvent struct {
-	BlockNumber   uint64
-	TransactionID string
-	ChaincodeID   string
-	EventName     string
-	Payload       []byte
-}
-
-func (chaincodeEvent *ChaincodeEvent) Message() interface{} {
-	return chaincodeEvent
-}
-
-func (chaincodeEvent *ChaincodeEvent) Topic() string {
-	return chaincodeEvent.ChaincodeID
-}
+type ChaincodeEvent = driver.ChaincodeEvent
 
 // validChaincodeEvent validates the chaincode event received.
 func validChaincodeEvent(event *peer.ChaincodeEvent) bool {
@@ -48,7 +37,7 @@ func validChaincodeEvent(event *peer.ChaincodeEvent) bool {
 }
 
 // readChaincodeEvent parses the envelope proto message to get the chaincode events.
-func readChaincodeEvent(env *common.Envelope, blockNumber uint64) (*ChaincodeEvent, error) {
+func readChaincodeEvent(env *common.Envelope, blockNumber uint64, txNum int) (*ChaincodeEvent, error) {
 	var chaincodeEvent *ChaincodeEvent
 	chaincodeAction, err := protoutil.GetActionFromEnvelopeMsg(env)
 	if err != nil {
@@ -69,6 +58,7 @@ func readChaincodeEvent(env *common.Envelope, blockNumber uint64) (*ChaincodeEve
 	}
 	chaincodeEvent = &ChaincodeEvent{
 		BlockNumber:   blockNumber,
+		TxNum:         txNum,
 		TransactionID: chaincodeEventData.GetTxId(),
 		ChaincodeID:   chaincodeEventData.GetChaincodeId(),
 		EventName:     chaincodeEventData.GetEventName(),
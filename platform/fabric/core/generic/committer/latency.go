@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// LatencyWeight is the weight of the last transaction in the moving average of the broadcast-to-commit latency
+	LatencyWeight = 0.1
+	// maxBroadcasts bounds the broadcasts whose commit is awaited, the oldest are dropped beyond it
+	maxBroadcasts = 10000
+)
+
+// LatencyTracker maintains the exponentially-weighted moving average of the time the transactions broadcast
+// by this node take to be committed. The transactions not broadcast by this node are not accounted.
+type LatencyTracker struct {
+	lock sync.Mutex
+	// sent maps the transactions broadcast, not committed yet, to the time of their broadcast
+	sent    map[string]time.Time
+	average time.Duration
+	count   uint64
+	now     func() time.Time
+}
+
+// NewLatencyTracker returns a tracker with no observation
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{sent: map[string]time.Time{}, now: time.Now}
+}
+
+// Broadcast records that the passed transaction has been broadcast at the passed time
+func (l *LatencyTracker) Broadcast(txID string, sentAt time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.sent[txID]; !ok && len(l.sent) >= maxBroadcasts {
+		l.dropOldest()
+	}
+	l.sent[txID] = sentAt
+}
+
+// Committed records that the passed transaction has been committed, valid or not, and accounts its latency
+// if it has been broadcast by this node
+func (l *LatencyTracker) Committed(txID string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	sentAt, ok := l.sent[txID]
+	if !ok {
+		return
+	}
+	delete(l.sent, txID)
+	latency := l.now().Sub(sentAt)
+	if latency < 0 {
+		latency = 0
+	}
+	if l.count == 0 {
+		l.average = latency
+	} else {
+		l.average = time.Duration(LatencyWeight*float64(latency) + (1-LatencyWeight)*float64(l.average))
+	}
+	l.count++
+}
+
+// Forget drops the passed transaction, that will not be committed
+func (l *LatencyTracker) Forget(txID string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.sent, txID)
+}
+
+// Average returns the moving average of the latency, and the number of transactions it accounts
+func (l *LatencyTracker) Average() (time.Duration, uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.average, l.count
+}
+
+func (l *LatencyTracker) dropOldest() {
+	var oldest string
+	var at time.Time
+	for txID, sentAt := range l.sent {
+		if len(oldest) == 0 || sentAt.Before(at) {
+			oldest, at = txID, sentAt
+		}
+	}
+	delete(l.sent, oldest)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	l := NewLatencyTracker()
+	now := time.Unix(1000000, 0)
+	l.now = func() time.Time { return now }
+
+	// the transactions not broadcast by this node are not accounted
+	l.Committed("other")
+	average, count := l.Average()
+	assert.Zero(t, average)
+	assert.Zero(t, count)
+
+	l.Broadcast("tx1", now.Add(-2*time.Second))
+	l.Committed("tx1")
+	average, count = l.Average()
+	assert.Equal(t, 2*time.Second, average)
+	assert.Equal(t, uint64(1), count)
+
+	// the last transaction weighs LatencyWeight
+	l.Broadcast("tx2", now.Add(-12*time.Second))
+	l.Committed("tx2")
+	// a transaction is accounted once
+	l.Committed("tx2")
+	average, count = l.Average()
+	assert.Equal(t, 3*time.Second, average)
+	assert.Equal(t, uint64(2), count)
+
+	// the abandoned transactions are dropped
+	l.Broadcast("tx3", now)
+	l.Forget("tx3")
+	l.Committed("tx3")
+	_, count = l.Average()
+	assert.Equal(t, uint64(2), count)
+
+	// the oldest broadcasts are dropped beyond the bound
+	for i := 0; i < maxBroadcasts; i++ {
+		l.Broadcast(fmt.Sprintf("tx%d", i+10), now.Add(time.Duration(i)*time.Millisecond))
+	}
+	l.Broadcast("last", now.Add(time.Hour))
+	assert.Len(t, l.sent, maxBroadcasts)
+	_, ok := l.sent["tx10"]
+	assert.False(t, ok)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+// Limiter caps the number of blocks that are committed concurrently across all the channels of all the networks.
+// Each channel commits its blocks sequentially, therefore a channel holds at most one slot at a time
+// and a slow channel cannot starve the others as long as the cap is larger than one.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter returns a new limiter with the passed number of slots.
+// If slots is not positive, the limiter does not impose any cap.
+func NewLimiter(slots int) *Limiter {
+	if slots <= 0 {
+		return &Limiter{}
+	}
+	return &Limiter{slots: make(chan struct{}, slots)}
+}
+
+// Acquire blocks until a slot is available
+func (l *Limiter) Acquire() {
+	if l == nil || l.slots == nil {
+		return
+	}
+	l.slots <- struct{}{}
+}
+
+// Release returns a slot acquired with Acquire
+func (l *Limiter) Release() {
+	if l == nil || l.slots == nil {
+		return
+	}
+	<-l.slots
+}
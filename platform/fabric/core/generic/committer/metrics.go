@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+var (
+	blocksCommittedOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "committer",
+		Name:         "blocks_committed",
+		Help:         "The number of blocks committed per channel.",
+		LabelNames:   []string{"network", "channel"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}",
+	}
+	blockCommitDurationOpts = metrics.HistogramOpts{
+		Namespace:    "fabric",
+		Subsystem:    "committer",
+		Name:         "block_commit_duration",
+		Help:         "The time, in seconds, spent committing a block, per channel.",
+		LabelNames:   []string{"network", "channel"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}",
+	}
+	slotWaitDurationOpts = metrics.HistogramOpts{
+		Namespace:    "fabric",
+		Subsystem:    "committer",
+		Name:         "slot_wait_duration",
+		Help:         "The time, in seconds, a channel waited for a commit slot before committing a block.",
+		LabelNames:   []string{"network", "channel"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}",
+	}
+	slotsInUseOpts = metrics.GaugeOpts{
+		Namespace:    "fabric",
+		Subsystem:    "committer",
+		Name:         "slots_in_use",
+		Help:         "The number of commit slots currently held by a channel.",
+		LabelNames:   []string{"network", "channel"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}",
+	}
+	rejectedOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "committer",
+		Name:         "rejected",
+		Help:         "The number of malformed blocks and transactions rejected per channel, by kind.",
+		LabelNames:   []string{"network", "channel", "kind"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}.%{kind}",
+	}
+)
+
+// CommitMetrics collects the per-channel commit metrics.
+type CommitMetrics struct {
+	BlocksCommitted     metrics.Counter
+	BlockCommitDuration metrics.Histogram
+	SlotWaitDuration    metrics.Histogram
+	SlotsInUse          metrics.Gauge
+	Rejected            metrics.Counter
+}
+
+func NewCommitMetrics(p metrics.Provider) *CommitMetrics {
+	return &CommitMetrics{
+		BlocksCommitted:     p.NewCounter(blocksCommittedOpts),
+		BlockCommitDuration: p.NewHistogram(blockCommitDurationOpts),
+		SlotWaitDuration:    p.NewHistogram(slotWaitDurationOpts),
+		SlotsInUse:          p.NewGauge(slotsInUseOpts),
+		Rejected:            p.NewCounter(rejectedOpts),
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/pkg/errors"
+)
+
+// Quarantine saves the raw blocks the committer rejected, in full or in part, for later analysis.
+// Each block is saved in a .block file, next to a .reason file telling why it was rejected.
+type Quarantine struct {
+	dir  string
+	lock sync.Mutex
+	now  func() time.Time
+}
+
+// NewQuarantine returns a quarantine saving the blocks in the passed directory, created if needed
+func NewQuarantine(dir string) *Quarantine {
+	return &Quarantine{dir: dir, now: time.Now}
+}
+
+// Save saves the passed block of the passed channel, rejected for the passed reason, and returns the path of its file
+func (q *Quarantine) Save(channel string, block *common.Block, reason error) (string, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if err := os.MkdirAll(q.dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "failed creating quarantine directory [%s]", q.dir)
+	}
+	raw, err := proto.Marshal(block)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed marshalling rejected block")
+	}
+	var number uint64
+	if block.GetHeader() != nil {
+		number = block.Header.Number
+	}
+	base := filepath.Join(q.dir, fmt.Sprintf("%s_%020d_%d", channel, number, q.now().UnixNano()))
+	if err := ioutil.WriteFile(base+".block", raw, 0644); err != nil {
+		return "", errors.Wrapf(err, "failed saving rejected block [%d]", number)
+	}
+	if err := ioutil.WriteFile(base+".reason", []byte(reason.Error()+"\n"), 0644); err != nil {
+		return "", errors.Wrapf(err, "failed saving the reason of rejected block [%d]", number)
+	}
+	return base + ".block", nil
+}
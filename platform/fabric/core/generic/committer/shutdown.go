@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+// Shutdown stops the committer. The next blocks are rejected with driver.ErrShuttingDown, the blocks being committed
+// are completed, then the waiters of a finality are released: with the status of their transaction if it is final,
+// with driver.ErrShuttingDown otherwise. The vault must stay open until Shutdown returns.
+// Shutdown returns once the blocks and the waiters are done, or the passed context is done. The waiters are released
+// even if the blocks are not done by then.
+func (c *Committer) Shutdown(ctx context.Context) error {
+	c.shutdownLock.Lock()
+	if c.closed {
+		c.shutdownLock.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.shutdownLock.Unlock()
+
+	commitsErr := wait(ctx, &c.commits)
+	close(c.closing)
+	waitersErr := wait(ctx, &c.waiters)
+	// no waiter subscribes once the committer is closed
+	c.subscribeOnce.Do(func() {})
+	if c.subscription != nil {
+		c.subscription.Unsubscribe()
+	}
+	if commitsErr != nil {
+		return errors.WithMessagef(commitsErr, "[%s] blocks still being committed at shutdown", c.channel)
+	}
+	if waitersErr != nil {
+		return errors.WithMessagef(waitersErr, "[%s] finality waiters still running at shutdown", c.channel)
+	}
+	return nil
+}
+
+// enter registers a commit or a waiter in the passed group, it returns false if the committer is shutting down
+func (c *Committer) enter(group *sync.WaitGroup) bool {
+	c.shutdownLock.RLock()
+	defer c.shutdownLock.RUnlock()
+	if c.closed {
+		return false
+	}
+	group.Add(1)
+	return true
+}
+
+// released returns the answer to a waiter of the finality of the passed transaction released by the shutdown
+func (c *Committer) released(txID string) error {
+	vd, err := c.status(txID)
+	if err == nil {
+		switch vd {
+		case driver.Valid:
+			return nil
+		case driver.Invalid:
+			return errors.Errorf("transaction [%s] is not valid", txID)
+		case driver.Abandoned:
+			return errors.Wrapf(driver.ErrTransactionAbandoned, "transaction [%s] has been abandoned", txID)
+		}
+	}
+	return errors.Wrapf(driver.ErrShuttingDown, "[%s] finality of [%s] not known at shutdown", c.channel, txID)
+}
+
+// wait waits for the passed group, unless the passed context is done before
+func wait(ctx context.Context, group *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,443 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+)
+
+// DefaultReplayWindow is the number of chaincode events retained for the replay when no window is configured
+const DefaultReplayWindow = 1000
+
+// replayKeyPrefix prefixes the keys of the replay windows in the KVS
+const replayKeyPrefix = "chaincode-events"
+
+// notificationsBufferSize is the number of notifications not read yet a subscription holds, the next ones are dropped
+const notificationsBufferSize = 8
+
+// start is the position before the first event of the ledger
+var start = driver.EventPosition{Block: 0, TxNum: -1}
+
+// Subscriptions is the registry of the chaincode event subscriptions of a channel. It outlives the instances of
+// the channel: the subscriptions are interrupted when the channel stops delivering blocks, and resumed once it
+// delivers them again, even through a new instance of the channel.
+//
+// The registry retains the last events of the channel, the replay window. Each subscription tracks the position
+// of the last event it has been delivered, and reads the next ones from the window, therefore the events missed
+// while interrupted, or while the application was slow, are replayed in order. The events of a block re-delivered
+// after a restart are discarded as duplicates. A subscription whose next events have been pruned from the window
+// ends with driver.ErrReplayWindowPruned. Once the registry is closed, the subscriptions deliver the events published
+// so far and end with the reason of the closing.
+//
+// If a KVS is passed, the replay window is also stored there, numbered in commit order, and loaded back at the
+// creation of the registry: the subscriptions resume from the position of their last event across the restarts
+// of the node as well.
+type Subscriptions struct {
+	kvs     *kvs.KVS
+	network string
+	channel string
+	window  int
+
+	lock sync.Mutex
+	cond *sync.Cond
+	// journal holds the events of the replay window, in commit order
+	journal []*ChaincodeEvent
+	// first is the number of the first event of the journal in the KVS, head the number of the next event
+	first uint64
+	head  uint64
+	// last is the position of the last event published
+	last driver.EventPosition
+	// pruned is the position of the last event removed from the journal, start if none
+	pruned driver.EventPosition
+	// interrupted is the reason of the current interruption, nil if the channel delivers blocks
+	interrupted error
+	// closed is the reason of the closing, nil if not closed
+	closed error
+	subs   map[*subscription]struct{}
+}
+
+// replayCursor is the persisted state of a replay window: the events numbered in [First, Head) are retained
+type replayCursor struct {
+	First  uint64
+	Head   uint64
+	Last   driver.EventPosition
+	Pruned driver.EventPosition
+}
+
+// NewSubscriptions returns the registry of the passed channel, retaining up to window events,
+// DefaultReplayWindow if not positive. If kvs is not nil, the replay window stored there is loaded,
+// and the next events are stored there as well.
+func NewSubscriptions(kvs *kvs.KVS, network, channel string, window int) (*Subscriptions, error) {
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+	s := &Subscriptions{
+		kvs:     kvs,
+		network: network,
+		channel: channel,
+		window:  window,
+		last:    start,
+		pruned:  start,
+		subs:    map[*subscription]struct{}{},
+	}
+	s.cond = sync.NewCond(&s.lock)
+	if err := s.load(); err != nil {
+		return nil, errors.WithMessagef(err, "failed loading the replay window of channel [%s:%s]", network, channel)
+	}
+	return s, nil
+}
+
+// load reads the replay window stored in the KVS, if any, the events beyond the window are removed
+func (s *Subscriptions) load() error {
+	if s.kvs == nil || !s.kvs.Exists(s.cursorKey()) {
+		return nil
+	}
+	cursor := &replayCursor{}
+	if err := s.kvs.Get(s.cursorKey(), cursor); err != nil {
+		return errors.Wrapf(err, "failed loading cursor")
+	}
+	s.first, s.head, s.last, s.pruned = cursor.First, cursor.Head, cursor.Last, cursor.Pruned
+	for seq := s.first; seq < s.head; seq++ {
+		event := &ChaincodeEvent{}
+		if err := s.kvs.Get(s.eventKey(seq), event); err != nil {
+			return errors.Wrapf(err, "failed loading event [%d]", seq)
+		}
+		s.journal = append(s.journal, event)
+	}
+	if len(s.journal) > s.window {
+		s.prune()
+		if err := s.kvs.Put(s.cursorKey(), s.cursor()); err != nil {
+			return errors.Wrapf(err, "failed storing cursor")
+		}
+	}
+	logger.Debugf("[%s:%s] loaded [%d] chaincode events to replay, up to [%v]", s.network, s.channel, len(s.journal), s.last)
+	return nil
+}
+
+// Subscribe subscribes to the events of the passed chaincode committed after the passed position,
+// after the last event published if nil
+func (s *Subscriptions) Subscribe(chaincode string, after *driver.EventPosition) (driver.ChaincodeEventSubscription, error) {
+	if len(chaincode) == 0 {
+		return nil, errors.New("no chaincode passed")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed != nil {
+		return nil, errors.WithMessagef(s.closed, "[%s] cannot subscribe to the events of [%s]", s.channel, chaincode)
+	}
+	position := s.last
+	if after != nil {
+		position = *after
+		if s.pruned.After(position) {
+			return nil, errors.Wrapf(driver.ErrReplayWindowPruned, "[%s] the events of [%s] after [%v] are no longer retained", s.channel, chaincode, position)
+		}
+	}
+	sub := &subscription{
+		registry:      s,
+		chaincode:     chaincode,
+		position:      position,
+		cursor:        position,
+		events:        make(chan *ChaincodeEvent),
+		notifications: make(chan *driver.SubscriptionNotification, notificationsBufferSize),
+		done:          make(chan struct{}),
+		ended:         make(chan struct{}),
+	}
+	s.subs[sub] = struct{}{}
+	go sub.run()
+	return sub, nil
+}
+
+// Publish appends the passed event to the replay window and wakes up the subscriptions.
+// An event not after the last one published is a duplicate, it is discarded.
+func (s *Subscriptions) Publish(event *ChaincodeEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !event.Position().After(s.last) {
+		logger.Debugf("[%s] discard chaincode event [%s] at [%v], already published", s.channel, event.TransactionID, event.Position())
+		return
+	}
+	if s.kvs != nil {
+		if err := s.kvs.Put(s.eventKey(s.head), event); err != nil {
+			logger.Errorf("[%s:%s] failed storing chaincode event [%s] at [%v] to replay: [%s]", s.network, s.channel, event.TransactionID, event.Position(), err)
+		}
+	}
+	s.journal = append(s.journal, event)
+	s.head++
+	s.last = event.Position()
+	s.prune()
+	if s.kvs != nil {
+		if err := s.kvs.Put(s.cursorKey(), s.cursor()); err != nil {
+			logger.Errorf("[%s:%s] failed storing cursor of the chaincode events to replay: [%s]", s.network, s.channel, err)
+		}
+	}
+	s.cond.Broadcast()
+}
+
+// prune removes the events beyond the replay window, the lock must be held
+func (s *Subscriptions) prune() {
+	for len(s.journal) > s.window {
+		s.pruned = s.journal[0].Position()
+		s.journal[0] = nil
+		s.journal = s.journal[1:]
+		if s.kvs != nil {
+			if err := s.kvs.Delete(s.eventKey(s.first)); err != nil {
+				logger.Warnf("[%s:%s] failed removing chaincode event [%d] to replay: [%s]", s.network, s.channel, s.first, err)
+			}
+		}
+		s.first++
+	}
+}
+
+func (s *Subscriptions) cursor() *replayCursor {
+	return &replayCursor{First: s.first, Head: s.head, Last: s.last, Pruned: s.pruned}
+}
+
+func (s *Subscriptions) cursorKey() string {
+	return kvs.CreateCompositeKeyOrPanic(replayKeyPrefix, []string{s.network, s.channel, "cursor"})
+}
+
+func (s *Subscriptions) eventKey(seq uint64) string {
+	return kvs.CreateCompositeKeyOrPanic(replayKeyPrefix, []string{s.network, s.channel, "events", fmt.Sprintf("%020d", seq)})
+}
+
+// Interrupted interrupts the subscriptions for the passed reason, until Resumed is called
+func (s *Subscriptions) Interrupted(reason error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.interrupted != nil {
+		return
+	}
+	logger.Warnf("[%s] chaincode event subscriptions interrupted: [%s]", s.channel, reason)
+	s.interrupted = reason
+	s.cond.Broadcast()
+}
+
+// Resumed resumes the subscriptions, if interrupted
+func (s *Subscriptions) Resumed() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.interrupted == nil {
+		return
+	}
+	logger.Infof("[%s] chaincode event subscriptions resumed", s.channel)
+	s.interrupted = nil
+	s.cond.Broadcast()
+}
+
+// Close ends the subscriptions with the passed reason once they delivered the events published so far, and rejects
+// the next subscriptions. It waits for them until the passed context is done, then ends at once the ones whose
+// application does not read the events.
+func (s *Subscriptions) Close(ctx context.Context, reason error) error {
+	s.lock.Lock()
+	if s.closed == nil {
+		s.closed = reason
+	}
+	ended := make([]chan struct{}, 0, len(s.subs))
+	for sub := range s.subs {
+		ended = append(ended, sub.ended)
+	}
+	s.cond.Broadcast()
+	s.lock.Unlock()
+
+	for _, e := range ended {
+		select {
+		case <-e:
+		case <-ctx.Done():
+			s.lock.Lock()
+			pending := len(s.subs)
+			for sub := range s.subs {
+				if sub.err == nil {
+					sub.err = s.closed
+				}
+				sub.end()
+			}
+			s.lock.Unlock()
+			return errors.WithMessagef(ctx.Err(), "[%s] [%d] subscriptions ended before delivering their events", s.channel, pending)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of active subscriptions
+func (s *Subscriptions) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.subs)
+}
+
+// Chaincodes returns the number of active subscriptions per chaincode
+func (s *Subscriptions) Chaincodes() map[string]int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := map[string]int{}
+	for sub := range s.subs {
+		res[sub.chaincode]++
+	}
+	return res
+}
+
+// next returns the first event of the journal after the passed position
+func (s *Subscriptions) next(after driver.EventPosition) *ChaincodeEvent {
+	i := sort.Search(len(s.journal), func(i int) bool {
+		return s.journal[i].Position().After(after)
+	})
+	if i == len(s.journal) {
+		return nil
+	}
+	return s.journal[i]
+}
+
+type subscription struct {
+	registry  *Subscriptions
+	chaincode string
+
+	// the fields below are guarded by the lock of the registry
+	// position is the position of the last event delivered, cursor the position of the last event examined
+	position    driver.EventPosition
+	cursor      driver.EventPosition
+	interrupted bool
+	closed      bool
+	err         error
+
+	events        chan *ChaincodeEvent
+	notifications chan *driver.SubscriptionNotification
+	done          chan struct{}
+	// ended is closed once run returned
+	ended chan struct{}
+	once  sync.Once
+}
+
+func (s *subscription) Events() <-chan *ChaincodeEvent {
+	return s.events
+}
+
+func (s *subscription) Notifications() <-chan *driver.SubscriptionNotification {
+	return s.notifications
+}
+
+func (s *subscription) Position() driver.EventPosition {
+	s.registry.lock.Lock()
+	defer s.registry.lock.Unlock()
+	return s.position
+}
+
+func (s *subscription) Err() error {
+	s.registry.lock.Lock()
+	defer s.registry.lock.Unlock()
+	return s.err
+}
+
+func (s *subscription) Close() {
+	s.registry.lock.Lock()
+	defer s.registry.lock.Unlock()
+	s.end()
+}
+
+// end closes the subscription, the lock of the registry must be held
+func (s *subscription) end() {
+	s.once.Do(func() {
+		s.closed = true
+		close(s.done)
+		s.registry.cond.Broadcast()
+	})
+}
+
+// run delivers the events and the notifications of the subscription, one at a time, until it is closed or ends
+func (s *subscription) run() {
+	r := s.registry
+	defer close(s.ended)
+	defer close(s.notifications)
+	defer close(s.events)
+	defer func() {
+		r.lock.Lock()
+		delete(r.subs, s)
+		r.lock.Unlock()
+	}()
+
+	for {
+		r.lock.Lock()
+		var event *ChaincodeEvent
+		var notification *driver.SubscriptionNotification
+		for !s.closed {
+			if interrupted := r.interrupted != nil; r.closed == nil && interrupted != s.interrupted {
+				s.interrupted = interrupted
+				notification = &driver.SubscriptionNotification{Position: s.position, Reason: r.interrupted}
+				if !interrupted {
+					notification.Type = driver.SubscriptionResumed
+				}
+				break
+			}
+			// a registry closed delivers the events published so far, interrupted or not
+			if !s.interrupted || r.closed != nil {
+				if r.pruned.After(s.cursor) {
+					s.err = errors.Wrapf(driver.ErrReplayWindowPruned, "[%s] the events of [%s] after [%v] are no longer retained", r.channel, s.chaincode, s.cursor)
+					logger.Errorf("subscription to the chaincode events ended: [%s]", s.err)
+					s.closed = true
+					break
+				}
+				if event = s.nextEvent(); event != nil {
+					break
+				}
+				if r.closed != nil {
+					s.err = r.closed
+					s.closed = true
+					break
+				}
+			}
+			r.cond.Wait()
+		}
+		closed := s.closed
+		r.lock.Unlock()
+		if closed {
+			return
+		}
+
+		if notification != nil {
+			// the notifications do not hold back the events of the applications not reading them
+			select {
+			case s.notifications <- notification:
+			default:
+				logger.Warnf("[%s] notification [%s] of the subscription to [%s] dropped, not read", r.channel, notification.Type, s.chaincode)
+			}
+			continue
+		}
+		select {
+		case s.events <- event:
+		case <-s.done:
+			return
+		}
+		r.lock.Lock()
+		s.position = event.Position()
+		r.lock.Unlock()
+	}
+}
+
+// nextEvent returns the next event of the chaincode in the journal, and moves the cursor to it.
+// The cursor skips the events of the other chaincodes.
+func (s *subscription) nextEvent() *ChaincodeEvent {
+	for {
+		event := s.registry.next(s.cursor)
+		if event == nil {
+			return nil
+		}
+		s.cursor = event.Position()
+		if event.ChaincodeID == s.chaincode {
+			return event
+		}
+	}
+}
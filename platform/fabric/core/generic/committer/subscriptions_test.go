@@ -0,0 +1,192 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/pkg/errors"
+	"github.com/test-go/testify/assert"
+)
+
+func ccEvent(chaincode string, block uint64, txNum int) *ChaincodeEvent {
+	return &ChaincodeEvent{ChaincodeID: chaincode, BlockNumber: block, TxNum: txNum, TransactionID: "tx", EventName: "e"}
+}
+
+func nextEvent(t *testing.T, sub driver.ChaincodeEventSubscription) *ChaincodeEvent {
+	select {
+	case event := <-sub.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func nextNotification(t *testing.T, sub driver.ChaincodeEventSubscription) *driver.SubscriptionNotification {
+	select {
+	case n := <-sub.Notifications():
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification received")
+		return nil
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	s, err := NewSubscriptions(nil, "default", "ch", 3)
+	assert.NoError(t, err)
+	sub, err := s.Subscribe("asset", nil)
+	assert.NoError(t, err)
+
+	s.Publish(ccEvent("asset", 1, 0))
+	s.Publish(ccEvent("other", 1, 1))
+	s.Publish(ccEvent("asset", 2, 0))
+	assert.Equal(t, driver.EventPosition{Block: 1, TxNum: 0}, nextEvent(t, sub).Position())
+	assert.Equal(t, driver.EventPosition{Block: 2, TxNum: 0}, nextEvent(t, sub).Position())
+
+	// the channel restarts, the block is re-delivered, and the events committed meanwhile are replayed
+	s.Interrupted(errors.New("channel closed"))
+	n := nextNotification(t, sub)
+	assert.Equal(t, driver.SubscriptionInterrupted, n.Type)
+	assert.EqualError(t, n.Reason, "channel closed")
+	assert.Equal(t, driver.EventPosition{Block: 2, TxNum: 0}, n.Position)
+
+	s.Publish(ccEvent("asset", 2, 0))
+	s.Publish(ccEvent("asset", 3, 0))
+	s.Resumed()
+	n = nextNotification(t, sub)
+	assert.Equal(t, driver.SubscriptionResumed, n.Type)
+	assert.NoError(t, n.Reason)
+	assert.Equal(t, driver.EventPosition{Block: 3, TxNum: 0}, nextEvent(t, sub).Position())
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("unexpected event [%v]", event.Position())
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, driver.EventPosition{Block: 3, TxNum: 0}, sub.Position())
+
+	// a subscription resumed after the events it missed have been pruned ends
+	s.Interrupted(errors.New("network blip"))
+	assert.Equal(t, driver.SubscriptionInterrupted, nextNotification(t, sub).Type)
+	for block := uint64(4); block < 8; block++ {
+		s.Publish(ccEvent("asset", block, 0))
+	}
+	s.Resumed()
+	assert.Equal(t, driver.SubscriptionResumed, nextNotification(t, sub).Type)
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.True(t, errors.Is(sub.Err(), driver.ErrReplayWindowPruned))
+
+	// the new subscriptions start from a retained position
+	_, err = s.Subscribe("asset", &driver.EventPosition{Block: 3, TxNum: 0})
+	assert.True(t, errors.Is(err, driver.ErrReplayWindowPruned))
+	sub, err = s.Subscribe("asset", &driver.EventPosition{Block: 5, TxNum: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), nextEvent(t, sub).BlockNumber)
+	assert.Equal(t, uint64(7), nextEvent(t, sub).BlockNumber)
+	sub.Close()
+	_, ok = <-sub.Events()
+	assert.False(t, ok)
+	assert.NoError(t, sub.Err())
+	for i := 0; i < 100 && s.Len() != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, s.Len())
+}
+
+func TestSubscriptionsClose(t *testing.T) {
+	s, err := NewSubscriptions(nil, "default", "ch", 10)
+	assert.NoError(t, err)
+	reading, err := s.Subscribe("asset", nil)
+	assert.NoError(t, err)
+	stuck, err := s.Subscribe("other", nil)
+	assert.NoError(t, err)
+
+	// the events published before the close are delivered, interrupted or not, then the subscriptions end
+	s.Publish(ccEvent("asset", 1, 0))
+	s.Publish(ccEvent("other", 1, 1))
+	s.Publish(ccEvent("other", 2, 0))
+	s.Interrupted(errors.New("channel closed"))
+	assert.Equal(t, driver.SubscriptionInterrupted, nextNotification(t, reading).Type)
+	var events []uint64
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for event := range reading.Events() {
+			events = append(events, event.BlockNumber)
+		}
+	}()
+
+	// the subscription whose application does not read its events is ended at the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	reason := errors.Wrapf(driver.ErrShuttingDown, "channel [ch] closed")
+	err = s.Close(ctx, reason)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	<-received
+	assert.Equal(t, []uint64{1}, events)
+	assert.True(t, errors.Is(reading.Err(), driver.ErrShuttingDown))
+	for range stuck.Events() {
+	}
+	assert.True(t, errors.Is(stuck.Err(), driver.ErrShuttingDown))
+	assert.Equal(t, 0, s.Len())
+
+	// the registry closed rejects the next subscriptions
+	_, err = s.Subscribe("asset", nil)
+	assert.True(t, errors.Is(err, driver.ErrShuttingDown))
+	assert.NoError(t, s.Close(context.Background(), reason))
+}
+
+func TestSubscriptionsPersisted(t *testing.T) {
+	kvss, err := kvs.NewWithConfig(registry.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	s, err := NewSubscriptions(kvss, "default", "ch", 3)
+	assert.NoError(t, err)
+	for block := uint64(1); block < 6; block++ {
+		s.Publish(ccEvent("asset", block, 0))
+	}
+
+	// the registry of the node restarted replays the window stored, and discards the events already published
+	s, err = NewSubscriptions(kvss, "default", "ch", 3)
+	assert.NoError(t, err)
+	s.Publish(ccEvent("asset", 5, 0))
+	_, err = s.Subscribe("asset", &driver.EventPosition{Block: 1, TxNum: 0})
+	assert.True(t, errors.Is(err, driver.ErrReplayWindowPruned))
+	sub, err := s.Subscribe("asset", &driver.EventPosition{Block: 2, TxNum: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), nextEvent(t, sub).BlockNumber)
+	assert.Equal(t, uint64(4), nextEvent(t, sub).BlockNumber)
+	assert.Equal(t, uint64(5), nextEvent(t, sub).BlockNumber)
+	s.Publish(ccEvent("asset", 6, 0))
+	assert.Equal(t, uint64(6), nextEvent(t, sub).BlockNumber)
+	sub.Close()
+
+	// a smaller window prunes the events stored beyond it
+	s, err = NewSubscriptions(kvss, "default", "ch", 1)
+	assert.NoError(t, err)
+	_, err = s.Subscribe("asset", &driver.EventPosition{Block: 4, TxNum: 0})
+	assert.True(t, errors.Is(err, driver.ErrReplayWindowPruned))
+	sub, err = s.Subscribe("asset", &driver.EventPosition{Block: 5, TxNum: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), nextEvent(t, sub).BlockNumber)
+	sub.Close()
+	assert.False(t, kvss.Exists(s.eventKey(4)))
+
+	// the windows of the other channels are not affected
+	other, err := NewSubscriptions(kvss, "default", "other", 3)
+	assert.NoError(t, err)
+	assert.Len(t, other.journal, 0)
+}
@@ -0,0 +1,228 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"fmt"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/configtx"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxBlockBytes is the default maximum size of the blocks delivered
+	DefaultMaxBlockBytes = 256 * 1024 * 1024
+	// DefaultMaxEnvelopeBytes is the default maximum size of the envelopes of the blocks delivered
+	DefaultMaxEnvelopeBytes = 128 * 1024 * 1024
+	// DefaultMaxConfigDepth is the default maximum depth of the groups of the configurations delivered.
+	// The configurations generated by Fabric are 4 levels deep: channel, application or orderer, organization.
+	DefaultMaxConfigDepth = 16
+)
+
+// Limits bounds the blocks and the transactions the committer processes. A non-positive limit is no limit.
+type Limits struct {
+	MaxBlockBytes    int
+	MaxEnvelopeBytes int
+	// MaxConfigDepth bounds the nesting of the groups of a configuration
+	MaxConfigDepth int
+}
+
+// DefaultLimits returns the limits the committer applies unless set with Committer#SetLimits
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBlockBytes:    DefaultMaxBlockBytes,
+		MaxEnvelopeBytes: DefaultMaxEnvelopeBytes,
+		MaxConfigDepth:   DefaultMaxConfigDepth,
+	}
+}
+
+// MalformedBlockError is returned when a block delivered cannot be processed at all
+type MalformedBlockError struct {
+	Number uint64
+	Reason string
+}
+
+func (e *MalformedBlockError) Error() string {
+	return fmt.Sprintf("malformed block [%d]: %s", e.Number, e.Reason)
+}
+
+// MalformedTxError tells why a transaction of a block delivered has been rejected.
+// The transaction is marked as invalid, the rest of the block is committed.
+type MalformedTxError struct {
+	Block uint64
+	Index int
+	// TxID is the id of the transaction, empty if its header could not be read
+	TxID   string
+	Reason string
+}
+
+func (e *MalformedTxError) Error() string {
+	return fmt.Sprintf("malformed transaction [%s] at [%d:%d]: %s", e.TxID, e.Block, e.Index, e.Reason)
+}
+
+// validateBlock checks the structure and the size of the passed block
+func validateBlock(block *common.Block, limits Limits) error {
+	if block == nil {
+		return &MalformedBlockError{Reason: "nil block"}
+	}
+	if block.Header == nil {
+		return &MalformedBlockError{Reason: "no header"}
+	}
+	if block.Data == nil {
+		return &MalformedBlockError{Number: block.Header.Number, Reason: "no data"}
+	}
+	if block.Metadata == nil {
+		return &MalformedBlockError{Number: block.Header.Number, Reason: "no metadata"}
+	}
+	if limits.MaxBlockBytes > 0 {
+		if size := proto.Size(block); size > limits.MaxBlockBytes {
+			return &MalformedBlockError{Number: block.Header.Number, Reason: fmt.Sprintf("[%d] bytes exceed the limit of [%d] bytes", size, limits.MaxBlockBytes)}
+		}
+	}
+	return nil
+}
+
+// parsedTx is a transaction of a block, unmarshalled and checked
+type parsedTx struct {
+	env     *common.Envelope
+	payload *common.Payload
+	chdr    *common.ChannelHeader
+}
+
+// parseTx unmarshals and checks the transaction at the passed position of the passed block.
+// On error, the returned transaction carries what could be read, its channel header if any.
+// The unmarshalling paths of Fabric that panic on malformed input are recovered.
+func parseTx(block *common.Block, i int, limits Limits) (tx *parsedTx, err error) {
+	tx = &parsedTx{}
+	malformed := func(format string, args ...interface{}) error {
+		res := &MalformedTxError{Block: block.Header.Number, Index: i, Reason: fmt.Sprintf(format, args...)}
+		if tx.chdr != nil {
+			res.TxID = tx.chdr.TxId
+		}
+		return res
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = malformed("panic while unmarshalling: %v", r)
+		}
+	}()
+
+	raw := block.Data.Data[i]
+	if tx.env, err = protoutil.UnmarshalEnvelope(raw); err != nil {
+		return tx, malformed("invalid envelope: %s", err)
+	}
+	if tx.payload, err = protoutil.UnmarshalPayload(tx.env.Payload); err != nil {
+		return tx, malformed("invalid payload: %s", err)
+	}
+	if tx.payload.Header == nil {
+		return tx, malformed("payload without header")
+	}
+	if tx.chdr, err = protoutil.UnmarshalChannelHeader(tx.payload.Header.ChannelHeader); err != nil {
+		return tx, malformed("invalid channel header: %s", err)
+	}
+	// the header is read anyway, so that the transaction can be marked as invalid
+	if limits.MaxEnvelopeBytes > 0 && len(raw) > limits.MaxEnvelopeBytes {
+		return tx, malformed("envelope of [%d] bytes exceeds the limit of [%d] bytes", len(raw), limits.MaxEnvelopeBytes)
+	}
+
+	switch common.HeaderType(tx.chdr.Type) {
+	case common.HeaderType_CONFIG:
+		envelope, err := configtx.UnmarshalConfigEnvelope(tx.payload.Data)
+		if err != nil {
+			return tx, malformed("invalid config envelope: %s", err)
+		}
+		if envelope.Config == nil || envelope.Config.ChannelGroup == nil {
+			return tx, malformed("config envelope without config")
+		}
+		if err := checkConfigDepth(envelope.Config.ChannelGroup, 1, limits.MaxConfigDepth); err != nil {
+			return tx, malformed("%s", err)
+		}
+	case common.HeaderType_ENDORSER_TRANSACTION:
+		flags, err := validationFlags(block)
+		if err != nil {
+			return tx, malformed("%s", err)
+		}
+		if len(flags) <= i {
+			return tx, malformed("the transaction filter covers [%d] transactions only", len(flags))
+		}
+		if pb.TxValidationCode(flags[i]) != pb.TxValidationCode_VALID {
+			// the read-write set of an invalid transaction is not read
+			return tx, nil
+		}
+		if _, err := readTxRWSet(tx.env); err != nil {
+			return tx, malformed("invalid read-write set: %s", err)
+		}
+		if _, err := readChaincodeEvent(tx.env, block.Header.Number, i); err != nil {
+			return tx, malformed("invalid chaincode event: %s", err)
+		}
+	}
+	return tx, nil
+}
+
+// validationFlags returns the transaction filter of the passed block
+func validationFlags(block *common.Block) (ValidationFlags, error) {
+	if len(block.Metadata.Metadata) <= int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		return nil, errors.Errorf("block metadata lacks transaction filter")
+	}
+	return block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER], nil
+}
+
+// checkConfigDepth checks that the passed group, at the passed depth, does not nest groups deeper than max
+func checkConfigDepth(group *common.ConfigGroup, depth, max int) error {
+	if max > 0 && depth > max {
+		return errors.Errorf("config groups nested deeper than [%d] levels", max)
+	}
+	for name, child := range group.Groups {
+		if child == nil {
+			return errors.Errorf("nil config group [%s]", name)
+		}
+		if err := checkConfigDepth(child, depth+1, max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reject accounts the passed block, or one of its transactions, rejected for the passed reason,
+// and saves the block in the quarantine, if set
+func (c *Committer) reject(block *common.Block, kind string, reason error) {
+	logger.Warnf("[%s] reject %s: %s", c.channel, kind, reason)
+	if c.commitMetrics != nil {
+		c.commitMetrics.Rejected.With("network", c.network.Name(), "channel", c.channel, "kind", kind).Add(1)
+	}
+	if c.quarantine == nil || block == nil {
+		return
+	}
+	path, err := c.quarantine.Save(c.channel, block, reason)
+	if err != nil {
+		logger.Errorf("[%s] failed quarantining rejected block: %s", c.channel, err)
+		return
+	}
+	logger.Warnf("[%s] rejected block saved in [%s]", c.channel, path)
+}
+
+// rejectTx marks as invalid the malformed transaction at the passed position of the passed block, if its id is known,
+// and notifies its finality with the passed reason. It returns the id of the transaction.
+func (c *Committer) rejectTx(block *common.Block, i int, tx *parsedTx, reason error) (string, error) {
+	c.reject(block, "transaction", reason)
+	if tx.chdr == nil || len(tx.chdr.TxId) == 0 || common.HeaderType(tx.chdr.Type) != common.HeaderType_ENDORSER_TRANSACTION {
+		return "", nil
+	}
+	event := TxEvent{Txid: tx.chdr.TxId, Block: block.Header.Number, IndexInBlock: i}
+	if err := c.DiscardEndorserTransaction(tx.chdr.TxId, block, &event, pb.TxValidationCode_BAD_PAYLOAD); err != nil {
+		return "", errors.Wrapf(err, "failed discarding malformed transaction [%s]", tx.chdr.TxId)
+	}
+	if event.Err != nil {
+		event.Err = reason
+	}
+	c.notify(event)
+	return tx.chdr.TxId, nil
+}
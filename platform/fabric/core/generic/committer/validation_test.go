@@ -0,0 +1,160 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package committer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/tracing"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/test-go/testify/assert"
+)
+
+// recordingCommitter is a committer whose transactions are unknown, the transactions committed and discarded are recorded
+type recordingCommitter struct {
+	driver.Committer
+	committed []string
+	discarded []string
+	configs   int
+}
+
+func (r *recordingCommitter) Status(txid string) (driver.ValidationCode, []string, error) {
+	return driver.Unknown, nil, nil
+}
+
+func (r *recordingCommitter) CommitTX(txid string, block uint64, indexInBloc int, envelope *common.Envelope) error {
+	r.committed = append(r.committed, txid)
+	return nil
+}
+
+func (r *recordingCommitter) DiscardTx(txid string) error {
+	r.discarded = append(r.discarded, txid)
+	return nil
+}
+
+func (r *recordingCommitter) CommitConfig(blockNumber uint64, indexInBlock int, raw []byte, envelope *common.Envelope) error {
+	r.configs++
+	return nil
+}
+
+func newRecordingCommitter(t testing.TB) (*Committer, *recordingCommitter) {
+	committer := &recordingCommitter{}
+	network := &fakeNetwork{committers: map[string]driver.Committer{"ch": committer}}
+	c, err := New("ch", network, nil, time.Second, true, tracing.NewNullAgent(), nil, events.NewBus(), NewLimiter(0), NewCommitMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+	return c, committer
+}
+
+// newDeepConfigBlock returns a config block whose groups are nested at the passed depth
+func newDeepConfigBlock(number uint64, depth int) *common.Block {
+	group := &common.ConfigGroup{}
+	for i := 1; i < depth; i++ {
+		group = &common.ConfigGroup{Groups: map[string]*common.ConfigGroup{"g": group}}
+	}
+	block := newConfigBlock("ch", number)
+	env := protoutil.UnmarshalEnvelopeOrPanic(block.Data.Data[0])
+	payload := protoutil.UnmarshalPayloadOrPanic(env.Payload)
+	payload.Data = protoutil.MarshalOrPanic(&common.ConfigEnvelope{Config: &common.Config{ChannelGroup: group}})
+	env.Payload = protoutil.MarshalOrPanic(payload)
+	block.Data.Data[0] = protoutil.MarshalOrPanic(env)
+	return block
+}
+
+func TestMalformedBlocks(t *testing.T) {
+	c, committer := newRecordingCommitter(t)
+	dir, err := ioutil.TempDir("", "quarantine")
+	assert.NoError(t, err)
+	c.SetQuarantine(NewQuarantine(dir))
+	var finalities []TxEvent
+	c.AddFinalityListener(func(event TxEvent) { finalities = append(finalities, event) })
+
+	// the blocks without structure are rejected
+	malformed := &MalformedBlockError{}
+	assert.True(t, errors.As(c.Commit(&common.Block{Header: &common.BlockHeader{Number: 1}}), &malformed))
+	assert.Equal(t, uint64(1), malformed.Number)
+	assert.Error(t, c.Commit(&common.Block{}))
+
+	// the malformed transactions are marked as invalid, the others committed
+	block := newEndorserTxBlock(t, "ch", 2, "tx1", pb.TxValidationCode_VALID)
+	valid := block.Data.Data[0]
+	truncated := newEndorserTxBlock(t, "ch", 2, "tx2", pb.TxValidationCode_VALID).Data.Data[0]
+	env := protoutil.UnmarshalEnvelopeOrPanic(truncated)
+	payload := protoutil.UnmarshalPayloadOrPanic(env.Payload)
+	payload.Data = payload.Data[:len(payload.Data)/2]
+	env.Payload = protoutil.MarshalOrPanic(payload)
+	block.Data.Data = [][]byte{[]byte("not an envelope"), protoutil.MarshalOrPanic(env), valid}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{0, 0, 0}
+	assert.NoError(t, c.Commit(block))
+	assert.Equal(t, []string{"tx1"}, committer.committed)
+	assert.Equal(t, []string{"tx2"}, committer.discarded)
+	if assert.Len(t, finalities, 2) {
+		txErr := &MalformedTxError{}
+		assert.True(t, errors.As(finalities[0].Err, &txErr))
+		assert.Equal(t, "tx2", txErr.TxID)
+		assert.Equal(t, 1, txErr.Index)
+		assert.NoError(t, finalities[1].Err)
+	}
+
+	// the endorser transactions not covered by the transaction filter are rejected too
+	block = newEndorserTxBlock(t, "ch", 3, "tx3", pb.TxValidationCode_VALID)
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = nil
+	assert.NoError(t, c.Commit(block))
+	assert.Equal(t, []string{"tx2", "tx3"}, committer.discarded)
+
+	// so are the configurations nested too deep
+	assert.NoError(t, c.Commit(newDeepConfigBlock(4, DefaultMaxConfigDepth)))
+	assert.NoError(t, c.Commit(newDeepConfigBlock(5, DefaultMaxConfigDepth+1)))
+	assert.Equal(t, 1, committer.configs)
+
+	// and the envelopes too large
+	c.SetLimits(Limits{MaxEnvelopeBytes: 10})
+	assert.NoError(t, c.Commit(newEndorserTxBlock(t, "ch", 6, "tx6", pb.TxValidationCode_VALID)))
+	assert.Equal(t, []string{"tx2", "tx3", "tx6"}, committer.discarded)
+
+	// the rejected blocks are quarantined
+	blocks, err := filepath.Glob(filepath.Join(dir, "*.block"))
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 7)
+	reason, err := ioutil.ReadFile(strings.TrimSuffix(blocks[len(blocks)-1], ".block") + ".reason")
+	assert.NoError(t, err)
+	assert.Contains(t, string(reason), "exceeds the limit of [10] bytes")
+	raw, err := ioutil.ReadFile(blocks[len(blocks)-1])
+	assert.NoError(t, err)
+	quarantined, err := protoutil.UnmarshalBlock(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), quarantined.Header.Number)
+}
+
+// FuzzCommit commits the blocks the fuzzer derives from a corpus of valid and malformed blocks,
+// Commit must return an error or reject the malformed parts, never panic
+func FuzzCommit(f *testing.F) {
+	t := &testing.T{}
+	f.Add(protoutil.MarshalOrPanic(newEndorserTxBlock(t, "ch", 1, "tx1", pb.TxValidationCode_VALID)))
+	f.Add(protoutil.MarshalOrPanic(newEndorserTxBlock(t, "ch", 1, "tx1", pb.TxValidationCode_MVCC_READ_CONFLICT)))
+	f.Add(protoutil.MarshalOrPanic(newConfigBlock("ch", 2)))
+	f.Add(protoutil.MarshalOrPanic(newDeepConfigBlock(3, 5)))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		block, err := protoutil.UnmarshalBlock(raw)
+		if err != nil {
+			return
+		}
+		c, _ := newRecordingCommitter(t)
+		_ = c.Commit(block)
+	})
+}
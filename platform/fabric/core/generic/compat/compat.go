@@ -0,0 +1,247 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.compat")
+
+// Matrix tells the minimum versions of the Fabric components supported by this node
+type Matrix struct {
+	// Orderer is the minimum version of the orderers
+	Orderer string
+	// Application is the minimum application capability of the channels, the peers joining a channel support it
+	Application string
+}
+
+// SupportedMatrix is the matrix of this node: it relies on the block metadata of the 2.0 orderers, and
+// on the chaincode lifecycle of the 2.0 peers
+var SupportedMatrix = Matrix{
+	Orderer:     "2.0.0",
+	Application: "V2_0",
+}
+
+// Detection is what the blocks delivered by an orderer tell about its version: a range, whose bounds
+// are empty if unknown
+type Detection struct {
+	// Min is the minimum version, inclusive
+	Min string
+	// Below is the maximum version, exclusive
+	Below string
+}
+
+func (d Detection) String() string {
+	switch {
+	case len(d.Min) != 0 && len(d.Below) != 0:
+		return fmt.Sprintf(">= %s, < %s", d.Min, d.Below)
+	case len(d.Below) != 0:
+		return "< " + d.Below
+	case len(d.Min) != 0:
+		return ">= " + d.Min
+	}
+	return ""
+}
+
+// supports returns false if the detected versions are all below the passed minimum
+func (d Detection) supports(minimum string) bool {
+	if len(d.Below) == 0 {
+		return true
+	}
+	return version.Parse(d.Below).Compare(version.Parse(minimum)) > 0
+}
+
+// Prober detects the version of the passed orderer, from the blocks it delivers for the passed channel
+type Prober func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) (Detection, error)
+
+// DetectOrderer detects the version of the orderer that delivered the passed block, the newest of a channel.
+// Since 2.0, the orderers record the last config in the metadata signatures, the former ones in its own metadata.
+// The genesis block is not signed, it tells nothing.
+func DetectOrderer(block *common.Block) (Detection, error) {
+	if block.Metadata == nil || len(block.Metadata.Metadata) <= int(common.BlockMetadataIndex_SIGNATURES) {
+		return Detection{}, errors.Errorf("block [%d] has no signatures", block.Header.GetNumber())
+	}
+	md := &common.Metadata{}
+	if err := proto.Unmarshal(block.Metadata.Metadata[common.BlockMetadataIndex_SIGNATURES], md); err != nil {
+		return Detection{}, errors.Wrapf(err, "failed unmarshalling the signatures of block [%d]", block.Header.GetNumber())
+	}
+	if len(md.Signatures) == 0 {
+		return Detection{}, errors.Errorf("block [%d] is not signed", block.Header.GetNumber())
+	}
+	obm := &common.OrdererBlockMetadata{}
+	if err := proto.Unmarshal(md.Value, obm); err == nil && obm.LastConfig != nil {
+		return Detection{Min: "2.0.0"}, nil
+	}
+	return Detection{Min: "1.0.0", Below: "2.0.0"}, nil
+}
+
+// CapabilityVersion returns the version of a capability, e.g. 2.0.0 for V2_0, Unknown if it does not name one
+func CapabilityVersion(capability string) version.Version {
+	if !strings.HasPrefix(capability, "V") {
+		return version.Unknown
+	}
+	parts := strings.Split(capability[1:], "_")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	return version.Parse(strings.Join(parts, "."))
+}
+
+// highestCapability returns the capability naming the highest version, empty if none does
+func highestCapability(capabilities []string) string {
+	highest, v := "", version.Unknown
+	for _, c := range capabilities {
+		if cv := CapabilityVersion(c); !cv.IsUnknown() && cv.Compare(v) > 0 {
+			highest, v = c, cv
+		}
+	}
+	return highest
+}
+
+// Checker checks the versions of the components of a network against a matrix, and keeps the last outcome
+// of each component
+type Checker struct {
+	network string
+	matrix  Matrix
+	probe   Prober
+	timeout time.Duration
+
+	lock       sync.RWMutex
+	components map[string]driver.ComponentCompatibility
+}
+
+// NewChecker returns a Checker that gives each orderer the passed timeout to answer the probe
+func NewChecker(network string, matrix Matrix, probe Prober, timeout time.Duration) *Checker {
+	return &Checker{
+		network:    network,
+		matrix:     matrix,
+		probe:      probe,
+		timeout:    timeout,
+		components: map[string]driver.ComponentCompatibility{},
+	}
+}
+
+// CheckOrderers probes the passed orderers concurrently, they replace the ones checked before.
+// It returns a *driver.ErrIncompatible if some of them run a version not supported.
+// The orderers that cannot be probed are reported, they are not deemed incompatible.
+func (c *Checker) CheckOrderers(channel string, orderers []*grpc.ConnectionConfig) error {
+	results := make([]driver.ComponentCompatibility, len(orderers))
+	var wg sync.WaitGroup
+	for i, orderer := range orderers {
+		wg.Add(1)
+		go func(i int, orderer *grpc.ConnectionConfig) {
+			defer wg.Done()
+			results[i] = c.probeOrderer(channel, orderer)
+		}(i, orderer)
+	}
+	wg.Wait()
+
+	c.lock.Lock()
+	for k, component := range c.components {
+		if component.Component == driver.CompatibilityOrderer {
+			delete(c.components, k)
+		}
+	}
+	for _, r := range results {
+		c.components[key(r)] = r
+	}
+	c.lock.Unlock()
+	return c.incompatible(results)
+}
+
+func (c *Checker) probeOrderer(channel string, orderer *grpc.ConnectionConfig) driver.ComponentCompatibility {
+	res := driver.ComponentCompatibility{
+		Component:  driver.CompatibilityOrderer,
+		Name:       orderer.Address,
+		Minimum:    c.matrix.Orderer,
+		Compatible: true,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	d, err := c.probe(ctx, channel, orderer)
+	res.CheckedAt = time.Now()
+	if err != nil {
+		logger.Debugf("cannot detect the version of orderer [%s]: [%s]", orderer.Address, err)
+		res.Error = err.Error()
+		return res
+	}
+	res.Detected = d.String()
+	res.Compatible = d.supports(c.matrix.Orderer)
+	return res
+}
+
+// CheckApplication checks the passed application capabilities of the passed channel.
+// It returns a *driver.ErrIncompatible if the highest one is below the supported one.
+func (c *Checker) CheckApplication(channel string, capabilities []string) error {
+	res := driver.ComponentCompatibility{
+		Component: driver.CompatibilityApplication,
+		Name:      channel,
+		Minimum:   c.matrix.Application,
+		CheckedAt: time.Now(),
+	}
+	res.Detected = highestCapability(capabilities)
+	if len(res.Detected) == 0 {
+		res.Detected = "none"
+		res.Compatible = false
+	} else {
+		res.Compatible = CapabilityVersion(res.Detected).Compare(CapabilityVersion(c.matrix.Application)) >= 0
+	}
+
+	c.lock.Lock()
+	c.components[key(res)] = res
+	c.lock.Unlock()
+	return c.incompatible([]driver.ComponentCompatibility{res})
+}
+
+// Report returns the last outcome of each component checked so far
+func (c *Checker) Report() *driver.CompatibilityReport {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	res := &driver.CompatibilityReport{Compatible: true, Components: make([]driver.ComponentCompatibility, 0, len(c.components))}
+	for _, component := range c.components {
+		res.Components = append(res.Components, component)
+		res.Compatible = res.Compatible && component.Compatible
+	}
+	sort.Slice(res.Components, func(i, j int) bool {
+		if res.Components[i].Component != res.Components[j].Component {
+			return res.Components[i].Component < res.Components[j].Component
+		}
+		return res.Components[i].Name < res.Components[j].Name
+	})
+	return res
+}
+
+func (c *Checker) incompatible(components []driver.ComponentCompatibility) error {
+	var res []driver.ComponentCompatibility
+	for _, component := range components {
+		if !component.Compatible {
+			res = append(res, component)
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return &driver.ErrIncompatible{Network: c.network, Components: res}
+}
+
+func key(c driver.ComponentCompatibility) string {
+	return c.Component + "/" + c.Name
+}
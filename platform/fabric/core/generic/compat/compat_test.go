@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedBlock(number uint64, value []byte) *common.Block {
+	return &common.Block{
+		Header: &common.BlockHeader{Number: number},
+		Metadata: &common.BlockMetadata{Metadata: [][]byte{protoutil.MarshalOrPanic(&common.Metadata{
+			Value:      value,
+			Signatures: []*common.MetadataSignature{{Signature: []byte("sig")}},
+		})}},
+	}
+}
+
+func TestDetectOrderer(t *testing.T) {
+	d, err := DetectOrderer(signedBlock(5, protoutil.MarshalOrPanic(&common.OrdererBlockMetadata{LastConfig: &common.LastConfig{Index: 2}})))
+	assert.NoError(t, err)
+	assert.Equal(t, Detection{Min: "2.0.0"}, d)
+	assert.True(t, d.supports("2.0.0"))
+
+	// a 1.x orderer records the last config in its own metadata
+	d, err = DetectOrderer(signedBlock(5, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, ">= 1.0.0, < 2.0.0", d.String())
+	assert.False(t, d.supports("2.0.0"))
+	assert.True(t, d.supports("1.4.0"))
+
+	// the genesis block tells nothing
+	_, err = DetectOrderer(&common.Block{
+		Header:   &common.BlockHeader{},
+		Metadata: &common.BlockMetadata{Metadata: [][]byte{protoutil.MarshalOrPanic(&common.Metadata{})}},
+	})
+	assert.Error(t, err)
+	_, err = DetectOrderer(&common.Block{Header: &common.BlockHeader{}})
+	assert.Error(t, err)
+}
+
+func TestCapabilityVersion(t *testing.T) {
+	assert.Equal(t, "2.0.0", CapabilityVersion("V2_0").String())
+	assert.Equal(t, "1.4.2", CapabilityVersion("V1_4_2").String())
+	assert.Equal(t, "2.5.0", CapabilityVersion("V2_5").String())
+	assert.True(t, CapabilityVersion("Experimental").IsUnknown())
+	assert.Equal(t, "V2_5", highestCapability([]string{"V1_4_2", "V2_5", "V2_0", "Experimental"}))
+	assert.Equal(t, "", highestCapability(nil))
+}
+
+func TestChecker(t *testing.T) {
+	probe := func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) (Detection, error) {
+		assert.Equal(t, "mychannel", channel)
+		switch orderer.Address {
+		case "old:7050":
+			return Detection{Min: "1.0.0", Below: "2.0.0"}, nil
+		case "down:7050":
+			return Detection{}, errors.New("connection refused")
+		}
+		return Detection{Min: "2.0.0"}, nil
+	}
+	c := NewChecker("default", SupportedMatrix, probe, time.Second)
+	assert.True(t, c.Report().Compatible)
+
+	err := c.CheckOrderers("mychannel", []*grpc.ConnectionConfig{{Address: "new:7050"}, {Address: "old:7050"}, {Address: "down:7050"}})
+	assert.Error(t, err)
+	incompatible := &driver.ErrIncompatible{}
+	assert.True(t, errors.As(err, &incompatible))
+	assert.Len(t, incompatible.Components, 1)
+	assert.Equal(t, "old:7050", incompatible.Components[0].Name)
+	assert.Contains(t, err.Error(), "orderer [old:7050] runs [>= 1.0.0, < 2.0.0], at least [2.0.0] required")
+
+	assert.NoError(t, c.CheckApplication("mychannel", []string{"V2_0", "V1_4_2"}))
+	err = c.CheckApplication("legacy", []string{"V1_4_2"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "application [legacy] runs [V1_4_2], at least [V2_0] required")
+	assert.Error(t, c.CheckApplication("empty", nil))
+
+	report := c.Report()
+	assert.False(t, report.Compatible)
+	var names []string
+	for _, component := range report.Components {
+		names = append(names, component.Name)
+	}
+	assert.Equal(t, []string{"empty", "legacy", "mychannel", "down:7050", "new:7050", "old:7050"}, names)
+	assert.True(t, report.Components[3].Compatible)
+	assert.Equal(t, "connection refused", report.Components[3].Error)
+
+	// the new endpoints replace the ones checked before
+	assert.NoError(t, c.CheckOrderers("mychannel", []*grpc.ConnectionConfig{{Address: "new:7050"}}))
+	assert.NoError(t, c.CheckApplication("legacy", []string{"V2_0"}))
+	assert.NoError(t, c.CheckApplication("empty", []string{"V2_0"}))
+	report = c.Report()
+	assert.True(t, report.Compatible)
+	assert.Len(t, report.Components, 4)
+	assert.Equal(t, "new:7050", report.Components[3].Name)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/compat"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/ordering"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
+)
+
+// The compatibility modes, see config.Config#CompatibilityMode
+const (
+	compatibilityOff  = "off"
+	compatibilityWarn = "warn"
+	compatibilityFail = "fail"
+)
+
+// initCompatibility creates the compatibility checker, unless disabled, and probes the configured orderers
+// on the default channel. In fail mode, it waits for the probe and fails if some orderers are not supported,
+// otherwise the probe runs in background.
+func (f *network) initCompatibility() error {
+	switch mode := f.config.CompatibilityMode(); mode {
+	case compatibilityOff:
+		return nil
+	case compatibilityWarn, compatibilityFail:
+		f.failIncompatible = mode == compatibilityFail
+	default:
+		logger.Warnf("unknown compatibility mode [%s] for network [%s], resorting to [%s]", mode, f.name, compatibilityWarn)
+	}
+	f.compat = compat.NewChecker(
+		f.name,
+		compat.SupportedMatrix,
+		ordering.NewVersionProber(f.localMembership, hash.GetHasher(f.sp)),
+		f.config.CompatibilityTimeout(),
+	)
+	// the orderers are never contacted by a read-only network
+	if f.readOnly || len(f.defaultChannel) == 0 {
+		return nil
+	}
+	if !f.failIncompatible {
+		go f.checkCompatibleOrderers(f.defaultChannel, f.Orderers())
+		return nil
+	}
+	return f.checkCompatibleOrderers(f.defaultChannel, f.Orderers())
+}
+
+// checkCompatibleOrderers probes the versions of the passed orderers, they replace the ones probed before.
+// The orderers not supported are logged, the error is returned in fail mode only.
+func (f *network) checkCompatibleOrderers(channel string, orderers []*grpc.ConnectionConfig) error {
+	if f.compat == nil || f.readOnly {
+		return nil
+	}
+	return f.reportIncompatible(f.compat.CheckOrderers(channel, orderers))
+}
+
+// checkCompatibleApplication checks the application capabilities of the passed channel, nil if it has no
+// application group. The error is returned in fail mode only.
+func (f *network) checkCompatibleApplication(channel string, capabilities []string) error {
+	if f.compat == nil || capabilities == nil {
+		return nil
+	}
+	return f.reportIncompatible(f.compat.CheckApplication(channel, capabilities))
+}
+
+// checkCompatibleChannel checks the application capabilities of the active configuration of the passed channel,
+// if it has one
+func (f *network) checkCompatibleChannel(ch *channel) error {
+	if f.compat == nil {
+		return nil
+	}
+	resources := ch.Resources()
+	if resources == nil {
+		// the configuration is not known yet, it is checked once applied
+		return nil
+	}
+	return f.checkCompatibleApplication(ch.name, applicationCapabilities(resources.ConfigtxValidator().ConfigProto()))
+}
+
+func (f *network) reportIncompatible(err error) error {
+	if err == nil {
+		return nil
+	}
+	if f.failIncompatible {
+		logger.Errorf("%s", err)
+		return err
+	}
+	logger.Warnf("%s", err)
+	return nil
+}
+
+// Compatibility returns the outcome of the compatibility checks of the components of this network, nil if disabled
+func (f *network) Compatibility() *driver.CompatibilityReport {
+	if f.compat == nil {
+		return nil
+	}
+	return f.compat.Report()
+}
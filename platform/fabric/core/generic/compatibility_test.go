@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/compat"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplicationCapabilities(t *testing.T) {
+	config := configTree(t, "V2_0", 1024, "2s", []string{"Org1"}, "orderer1:7050")
+	assert.Equal(t, []string{"V2_0"}, applicationCapabilities(config))
+
+	delete(config.ChannelGroup.Groups["Application"].Values, "Capabilities")
+	assert.Equal(t, []string{}, applicationCapabilities(config))
+
+	delete(config.ChannelGroup.Groups, "Application")
+	assert.Nil(t, applicationCapabilities(config))
+}
+
+func TestCompatibilityOnConfigApplied(t *testing.T) {
+	probe := func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) (compat.Detection, error) {
+		if orderer.Address == "legacy:7050" {
+			return compat.Detection{Min: "1.0.0", Below: "2.0.0"}, nil
+		}
+		return compat.Detection{Min: "2.0.0"}, nil
+	}
+	n := newTestNetwork(t, false)
+	n.compat = compat.NewChecker(n.name, compat.SupportedMatrix, probe, time.Second)
+	n.orderers = []*grpc.ConnectionConfig{{Address: "orderer1:7050"}}
+	n.configuredOrderers = 1
+	assert.NoError(t, n.checkCompatibleOrderers("mychannel", n.Orderers()))
+	assert.True(t, n.Compatibility().Compatible)
+
+	// in warn mode the legacy components are reported only
+	n.onConfigApplied(driver.ConfigApplied{
+		Network:                 n.name,
+		Channel:                 "mychannel",
+		ApplicationCapabilities: []string{"V1_4_2"},
+		Orderers:                []*grpc.ConnectionConfig{{Address: "legacy:7050"}},
+	})
+	assert.Eventually(t, func() bool { return len(n.Compatibility().Components) == 3 }, 5*time.Second, 10*time.Millisecond)
+	report := n.Compatibility()
+	assert.False(t, report.Compatible)
+	assert.Equal(t, driver.ComponentCompatibility{
+		Component: driver.CompatibilityApplication,
+		Name:      "mychannel",
+		Detected:  "V1_4_2",
+		Minimum:   "V2_0",
+		CheckedAt: report.Components[0].CheckedAt,
+	}, report.Components[0])
+	assert.Equal(t, "legacy:7050", report.Components[1].Name)
+	assert.False(t, report.Components[1].Compatible)
+	assert.Equal(t, "orderer1:7050", report.Components[2].Name)
+
+	// in fail mode the error is returned
+	n = newTestNetwork(t, false)
+	n.compat = compat.NewChecker(n.name, compat.SupportedMatrix, probe, time.Second)
+	n.failIncompatible = true
+	err := n.checkCompatibleApplication("mychannel", []string{"V1_4_2"})
+	incompatible := &driver.ErrIncompatible{}
+	assert.True(t, errors.As(err, &incompatible))
+	assert.Equal(t, n.name, incompatible.Network)
+	assert.NoError(t, n.checkCompatibleApplication("mychannel", []string{"V2_0"}))
+	assert.NoError(t, n.checkCompatibleApplication("system", nil))
+}
+
+func TestCompatibilityDisabled(t *testing.T) {
+	n := newTestNetwork(t, false)
+	assert.Nil(t, n.Compatibility())
+	assert.NoError(t, n.checkCompatibleApplication("mychannel", []string{"V1_4_2"}))
+	assert.NoError(t, n.checkCompatibleOrderers("mychannel", []*grpc.ConnectionConfig{{Address: "legacy:7050"}}))
+}
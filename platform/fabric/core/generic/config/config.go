@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 	"github.com/pkg/errors"
 )
@@ -18,8 +19,41 @@ const (
 	DefaultMSPCacheSize        = 3
 	DefaultBroadcastNumRetries = 3
 	VaultPersistenceOptsKey    = "vault.persistence.opts"
+	// AnyForeignOrg allows the endorsement against the peers of any other organization of the channels
+	AnyForeignOrg = "*"
 )
 
+const (
+	// DefaultOrderingPreflightTimeout is the time each orderer has to pass the pre-flight check
+	DefaultOrderingPreflightTimeout = 5 * time.Second
+	// DefaultOrderingPreflightBudget is the time the initialization of a channel waits for the pre-flight check
+	DefaultOrderingPreflightBudget = time.Second
+	// DefaultOrderingPreflightRecheckInterval is the time a degraded orderer is checked again after, the first time
+	DefaultOrderingPreflightRecheckInterval = 5 * time.Second
+	// DefaultOrderingTLSRotationGracePeriod is the time the orderers trust their previous TLS roots after a rotation
+	DefaultOrderingTLSRotationGracePeriod = time.Minute
+)
+
+const (
+	// DefaultOrderingAdmissionWindow is the time a broadcast transaction is remembered to detect its duplicates
+	DefaultOrderingAdmissionWindow = time.Minute
+	// DefaultOrderingAdmissionPolicy rejects the duplicates
+	DefaultOrderingAdmissionPolicy = "reject"
+)
+
+// DefaultShutdownTimeout is the time the channels have to shut down
+const DefaultShutdownTimeout = 10 * time.Second
+
+const (
+	// DefaultCompatibilityMode logs the components whose version is not supported
+	DefaultCompatibilityMode = "warn"
+	// DefaultCompatibilityTimeout is the time each orderer has to answer the compatibility probe
+	DefaultCompatibilityTimeout = 5 * time.Second
+)
+
+// DefaultSubscriptionsRetention is the number of events kept in the journal of each channel for the subscriptions
+const DefaultSubscriptionsRetention = 10000
+
 // configService models a configuration registry
 type configService interface {
 	// GetString returns the value associated with the key as a string
@@ -107,9 +141,16 @@ func (c *Config) Orderers() ([]*grpc.ConnectionConfig, error) {
 	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"orderers", &res); err != nil {
 		return nil, err
 	}
+	resolution, err := c.Resolution()
+	if err != nil {
+		return nil, err
+	}
 
 	for _, v := range res {
 		v.TLSEnabled = c.TLSEnabled()
+		if v.Resolution == nil {
+			v.Resolution = resolution
+		}
 	}
 
 	return res, nil
@@ -120,11 +161,57 @@ func (c *Config) Peers() ([]*grpc.ConnectionConfig, error) {
 	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"peers", &res); err != nil {
 		return nil, err
 	}
+	resolution, err := c.Resolution()
+	if err != nil {
+		return nil, err
+	}
 
 	for _, v := range res {
 		v.TLSEnabled = c.TLSEnabled()
+		if v.Resolution == nil {
+			v.Resolution = resolution
+		}
+	}
+
+	return res, nil
+}
+
+// Resolution returns the resolution of the names of the orderers and of the peers not configuring their own,
+// nil if not set. In that case, the names are resolved by gRPC when the connections are dialed.
+func (c *Config) Resolution() (*grpc.ResolutionConfig, error) {
+	if !c.configService.IsSet("fabric." + c.prefix + "resolution") {
+		return nil, nil
+	}
+	res := &grpc.ResolutionConfig{}
+	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"resolution", res); err != nil {
+		return nil, errors.Wrap(err, "failed loading name resolution")
 	}
+	if err := res.Validate(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
 
+// AddressOverride replaces an address of an orderer or of a peer with another one
+type AddressOverride struct {
+	From string
+	To   string
+}
+
+// AddressOverrides returns the addresses to connect to in place of those of the orderers and of the peers, as found
+// in this configuration, in the channel configurations, or by discovery, keyed by the address they replace
+func (c *Config) AddressOverrides() (map[string]string, error) {
+	var overrides []*AddressOverride
+	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"addressOverrides", &overrides); err != nil {
+		return nil, errors.Wrap(err, "failed loading address overrides")
+	}
+	res := map[string]string{}
+	for _, o := range overrides {
+		if len(o.From) == 0 || len(o.To) == 0 {
+			return nil, errors.Errorf("invalid address override [%s]->[%s]", o.From, o.To)
+		}
+		res[o.From] = o.To
+	}
 	return res, nil
 }
 
@@ -136,7 +223,12 @@ func (c *Config) Channels() ([]*Channel, error) {
 	return res, nil
 }
 
+// VaultPersistenceType returns the driver of the vault persistence, as set by vault.driver or, if not set,
+// by vault.persistence.type
 func (c *Config) VaultPersistenceType() string {
+	if d := c.configService.GetString("fabric." + c.prefix + "vault.driver"); len(d) != 0 {
+		return d
+	}
 	return c.configService.GetString("fabric." + c.prefix + "vault.persistence.type")
 }
 
@@ -228,6 +320,304 @@ func (c *Config) BroadcastNumRetries() int {
 	return v
 }
 
+// CommitterMaxBlockBytes returns the maximum size in bytes of the blocks committed, 0 if not set, negative if unbounded
+func (c *Config) CommitterMaxBlockBytes() int {
+	return c.configService.GetInt("fabric." + c.prefix + "committer.limits.maxBlockBytes")
+}
+
+// CommitterMaxEnvelopeBytes returns the maximum size in bytes of the envelopes committed, 0 if not set, negative if unbounded
+func (c *Config) CommitterMaxEnvelopeBytes() int {
+	return c.configService.GetInt("fabric." + c.prefix + "committer.limits.maxEnvelopeBytes")
+}
+
+// CommitterMaxConfigDepth returns the maximum depth of the groups of the configurations committed,
+// 0 if not set, negative if unbounded
+func (c *Config) CommitterMaxConfigDepth() int {
+	return c.configService.GetInt("fabric." + c.prefix + "committer.limits.maxConfigDepth")
+}
+
+// CommitterQuarantinePath returns the directory the blocks rejected by the committer are saved in, empty if not set
+func (c *Config) CommitterQuarantinePath() string {
+	return c.configService.GetPath("fabric." + c.prefix + "committer.quarantine")
+}
+
+// MaxChannels returns the maximum number of channels of this network that can be open at the same time.
+// A non-positive value means no limit.
+func (c *Config) MaxChannels() int {
+	return c.configService.GetInt("fabric." + c.prefix + "channelLimits.max")
+}
+
+// ChannelIdleTTL returns the time after which a channel not used is unloaded: its delivery is stopped and its
+// configuration bundle released, until it is used again. 0 means the channels are never unloaded.
+func (c *Config) ChannelIdleTTL() time.Duration {
+	return c.configService.GetDuration("fabric." + c.prefix + "channelLimits.idleTTL")
+}
+
+// DeliveryReplayWindow returns the number of chaincode events retained to replay them to the subscriptions
+// interrupted by a restart of the channel. A non-positive value means the default one.
+func (c *Config) DeliveryReplayWindow() int {
+	return c.configService.GetInt("fabric." + c.prefix + "delivery.replayWindow")
+}
+
+// VaultBackupMaxPause returns the maximum amount of time the commits can stay paused during a vault backup
+func (c *Config) VaultBackupMaxPause() time.Duration {
+	return c.configService.GetDuration("fabric." + c.prefix + "vault.backup.maxPause")
+}
+
+// VaultMigrationBackupPath returns the directory the vaults are archived to before migrating their data format,
+// empty if not set
+func (c *Config) VaultMigrationBackupPath() string {
+	return c.configService.GetPath("fabric." + c.prefix + "vault.backup.migrationPath")
+}
+
+// VaultHistoryNamespaces returns the namespaces whose history of versions the vault keeps
+func (c *Config) VaultHistoryNamespaces() ([]string, error) {
+	var namespaces []string
+	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"vault.history.namespaces", &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// VaultQuotas returns the quotas of the namespaces of the vault, by namespace
+func (c *Config) VaultQuotas() (map[string]driver.NamespaceQuota, error) {
+	quotas := map[string]driver.NamespaceQuota{}
+	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"vault.quotas", &quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// VaultIntegrityVerifyOnStartup returns true if the namespaces of the vault are verified against their checksum
+// when the delivery of the channel starts
+func (c *Config) VaultIntegrityVerifyOnStartup() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "vault.integrity.verifyOnStartup")
+}
+
+// VaultIntegrityVerifyEvery returns the number of blocks between two verifications of the namespaces of the vault
+// against their checksum. 0 means the namespaces are not verified periodically.
+func (c *Config) VaultIntegrityVerifyEvery() uint64 {
+	if v := c.configService.GetInt("fabric." + c.prefix + "vault.integrity.verifyEvery"); v > 0 {
+		return uint64(v)
+	}
+	return 0
+}
+
+// VaultIntegrityRepair returns true if the namespaces failing a verification of the vault are re-derived from the
+// blocks of the peers
+func (c *Config) VaultIntegrityRepair() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "vault.integrity.repair")
+}
+
+// VaultResyncTimeout returns the maximum amount of time to wait, on startup, for a vault restored from a backup
+// to catch up with the last checkpoint
+func (c *Config) VaultResyncTimeout() time.Duration {
+	return c.configService.GetDuration("fabric." + c.prefix + "vault.backup.resyncTimeout")
+}
+
+// ShutdownTimeout returns the time each channel has to shut down: to complete the blocks being committed, release the
+// finality waiters and end the subscriptions, before its vault is closed
+func (c *Config) ShutdownTimeout() time.Duration {
+	if v := c.configService.GetDuration("fabric." + c.prefix + "shutdownTimeout"); v > 0 {
+		return v
+	}
+	return DefaultShutdownTimeout
+}
+
 func (c *Config) BroadcastRetryInterval() time.Duration {
 	return c.configService.GetDuration("fabric." + c.prefix + "ordering.retryInterval")
 }
+
+// OrderingPreflightEnabled returns true if the orderers are checked when a channel is initialized
+// and when the channel configuration updates them
+func (c *Config) OrderingPreflightEnabled() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "ordering.preflight.enabled")
+}
+
+// OrderingPreflightTimeout returns the time each orderer has to pass the pre-flight check
+func (c *Config) OrderingPreflightTimeout() time.Duration {
+	if v := c.configService.GetDuration("fabric." + c.prefix + "ordering.preflight.timeout"); v > 0 {
+		return v
+	}
+	return DefaultOrderingPreflightTimeout
+}
+
+// OrderingPreflightBudget returns the maximum amount of time the initialization of a channel waits
+// for the pre-flight check, the checks still running after that complete in background
+func (c *Config) OrderingPreflightBudget() time.Duration {
+	if !c.configService.IsSet("fabric." + c.prefix + "ordering.preflight.budget") {
+		return DefaultOrderingPreflightBudget
+	}
+	return c.configService.GetDuration("fabric." + c.prefix + "ordering.preflight.budget")
+}
+
+// OrderingPreflightRecheckInterval returns the time a degraded orderer is checked again after, the first time.
+// The interval doubles at each failed check, up to ordering.MaxRecheckInterval.
+func (c *Config) OrderingPreflightRecheckInterval() time.Duration {
+	if v := c.configService.GetDuration("fabric." + c.prefix + "ordering.preflight.recheckInterval"); v > 0 {
+		return v
+	}
+	return DefaultOrderingPreflightRecheckInterval
+}
+
+// OrderingPreflightDeliver returns true if the pre-flight check also seeks the latest block from each orderer
+func (c *Config) OrderingPreflightDeliver() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "ordering.preflight.deliver")
+}
+
+// OrderingTLSRotationGracePeriod returns the time the orderers whose TLS roots are rotated by a channel configuration
+// keep trusting their previous roots, and the connections opened before the rotation are kept
+func (c *Config) OrderingTLSRotationGracePeriod() time.Duration {
+	if v := c.configService.GetDuration("fabric." + c.prefix + "ordering.tlsRotation.gracePeriod"); v > 0 {
+		return v
+	}
+	return DefaultOrderingTLSRotationGracePeriod
+}
+
+// OrderingMaxEnvelopeBytes returns the maximum size in bytes of the envelopes broadcast to the orderers, 0 if not set.
+// In that case, the limit is the AbsoluteMaxBytes of the batch size in the configuration of the channel.
+func (c *Config) OrderingMaxEnvelopeBytes() uint32 {
+	v := c.configService.GetInt("fabric." + c.prefix + "ordering.maxEnvelopeBytes")
+	if v <= 0 {
+		return 0
+	}
+	return uint32(v)
+}
+
+// OrderingAdmissionEnabled returns true if the transactions duplicating one in flight are detected before broadcast
+func (c *Config) OrderingAdmissionEnabled() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "ordering.admission.enabled")
+}
+
+// OrderingAdmissionWindow returns the time a broadcast transaction is remembered to detect its duplicates,
+// unless it reaches finality before
+func (c *Config) OrderingAdmissionWindow() time.Duration {
+	if v := c.configService.GetDuration("fabric." + c.prefix + "ordering.admission.window"); v > 0 {
+		return v
+	}
+	return DefaultOrderingAdmissionWindow
+}
+
+// OrderingAdmissionPolicy returns what happens to the duplicates, either reject or join
+func (c *Config) OrderingAdmissionPolicy() string {
+	if v := c.configService.GetString("fabric." + c.prefix + "ordering.admission.policy"); len(v) != 0 {
+		return v
+	}
+	return DefaultOrderingAdmissionPolicy
+}
+
+// OrderingStaleReadCheckEnabled returns true if the read sets of the transactions are checked against the vault before broadcast
+func (c *Config) OrderingStaleReadCheckEnabled() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "ordering.staleReadCheck.enabled")
+}
+
+// OrderingTTL returns the time to live of the transactions: the ones created longer ago are not broadcast, 0 if not set.
+// The chaincodes and the transactions can set their own.
+func (c *Config) OrderingTTL() time.Duration {
+	if v := c.configService.GetDuration("fabric." + c.prefix + "ordering.ttl"); v > 0 {
+		return v
+	}
+	return 0
+}
+
+// CompatibilityMode returns what happens when a component of the network runs a version not supported:
+// off disables the checks, warn logs it, fail makes the initialization of the network or of the channel fail
+func (c *Config) CompatibilityMode() string {
+	if v := c.configService.GetString("fabric." + c.prefix + "compatibility.mode"); len(v) != 0 {
+		return v
+	}
+	return DefaultCompatibilityMode
+}
+
+// CompatibilityTimeout returns the time each orderer has to answer the compatibility probe
+func (c *Config) CompatibilityTimeout() time.Duration {
+	if v := c.configService.GetDuration("fabric." + c.prefix + "compatibility.timeout"); v > 0 {
+		return v
+	}
+	return DefaultCompatibilityTimeout
+}
+
+// SubscriptionsEnabled returns true if the events committed on the channels are kept in a journal,
+// read by the external clients subscribing to them over the view service
+func (c *Config) SubscriptionsEnabled() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "events.subscriptions.enabled")
+}
+
+// SubscriptionsRetention returns the number of events kept in the journal of each channel, the oldest are removed first
+func (c *Config) SubscriptionsRetention() int {
+	if v := c.configService.GetInt("fabric." + c.prefix + "events.subscriptions.retention"); v > 0 {
+		return v
+	}
+	return DefaultSubscriptionsRetention
+}
+
+// SubscriptionsMaxLag returns the number of events a subscription can be behind the head of the journal before being
+// disconnected, the retention if not set or larger
+func (c *Config) SubscriptionsMaxLag() int {
+	retention := c.SubscriptionsRetention()
+	if v := c.configService.GetInt("fabric." + c.prefix + "events.subscriptions.maxLag"); v > 0 && v < retention {
+		return v
+	}
+	return retention
+}
+
+// SubscriptionsClients returns the paths of the certificates of the clients allowed to subscribe to the events,
+// as the ones listed in fsc.client.certs. If empty, all the clients of the node are allowed.
+func (c *Config) SubscriptionsClients() ([]string, error) {
+	var res []string
+	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"events.subscriptions.clients", &res); err != nil {
+		return nil, err
+	}
+	for i, path := range res {
+		res[i] = c.configService.TranslatePath(path)
+	}
+	return res, nil
+}
+
+// ReadOnly returns true if this node is a read-only replica on the network: it maintains its vaults from
+// the delivered blocks, serves queries and events, but never signs nor broadcasts transactions
+func (c *Config) ReadOnly() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "readOnly")
+}
+
+// EndorsementForeignOrgs returns the MSP IDs of the other organizations of the channels whose peers
+// this node is willing to contact for endorsement. AnyForeignOrg matches all of them.
+func (c *Config) EndorsementForeignOrgs() ([]string, error) {
+	var res []string
+	if !c.configService.IsSet("fabric." + c.prefix + "endorsement.foreignOrgs") {
+		return res, nil
+	}
+	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"endorsement.foreignOrgs", &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// EndorsementForeignPeers returns the endpoints of the peers of other organizations to be used
+// on top of the anchor peers found in the channel configuration
+func (c *Config) EndorsementForeignPeers() ([]*ForeignPeer, error) {
+	var res []*ForeignPeer
+	if !c.configService.IsSet("fabric." + c.prefix + "endorsement.peers") {
+		return res, nil
+	}
+	if err := c.configService.UnmarshalKey("fabric."+c.prefix+"endorsement.peers", &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// EndorsementVerificationDisabled returns true if the endorsements collected from the peers are accepted
+// without verifying them against the channel MSPs. Meant for development only.
+func (c *Config) EndorsementVerificationDisabled() bool {
+	return c.configService.GetBool("fabric." + c.prefix + "endorsement.verification.disabled")
+}
+
+// EndorsementVerificationRole returns the role the endorsers are expected to have in their MSP, if any.
+func (c *Config) EndorsementVerificationRole() string {
+	return c.configService.GetString("fabric." + c.prefix + "endorsement.verification.role")
+}
+
+// EndorsementVerificationOU returns the organizational unit the endorsers are expected to belong to, if any.
+func (c *Config) EndorsementVerificationOU() string {
+	return c.configService.GetString("fabric." + c.prefix + "endorsement.verification.organizationalUnit")
+}
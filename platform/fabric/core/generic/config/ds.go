@@ -83,9 +83,25 @@ type ConnectionConfig struct {
 	ServerNameOverride string        `yaml:"serverNameOverride,omitempty"`
 }
 
+// ForeignPeer is the endpoint of a peer of another organization of the channels.
+// The TLS root certificates of the peer are taken from the channel configuration.
+type ForeignPeer struct {
+	MSPID   string `yaml:"mspID"`
+	Address string `yaml:"address"`
+}
+
 type Chaincode struct {
-	Name    string `yaml:"Name,omitempty"`
-	Private bool   `yaml:"Private,omitempty"`
+	Name       string      `yaml:"Name,omitempty"`
+	Private    bool        `yaml:"Private,omitempty"`
+	QueryCache *QueryCache `yaml:"QueryCache,omitempty"`
+	// TTL is the time to live of the transactions of the chaincode, it overrides the one of the network if set
+	TTL time.Duration `yaml:"TTL,omitempty"`
+}
+
+// QueryCache configures the caching of the results of the queries of a chaincode
+type QueryCache struct {
+	Enabled bool          `yaml:"Enabled,omitempty"`
+	TTL     time.Duration `yaml:"TTL,omitempty"`
 }
 
 type Channel struct {
@@ -95,6 +111,37 @@ type Channel struct {
 	NumRetries uint          `yaml:"NumRetries,omitempty"`
 	RetrySleep time.Duration `yaml:"RetrySleep,omitempty"`
 	Chaincodes []*Chaincode  `yaml:"Chaincodes,omitempty"`
+	Sinks      []*EventSink  `yaml:"Sinks,omitempty"`
+}
+
+// EventSink configures the export of the events committed on a channel to an external system.
+// Type is one of kafka, webhook or memory. The events are filtered by type (chaincode or finality),
+// namespace and chaincode event name, an empty filter matches everything.
+// BufferSize is the number of events buffered while the sink is not reachable beyond which the sink is degraded.
+type EventSink struct {
+	Name       string       `yaml:"Name,omitempty"`
+	Type       string       `yaml:"Type,omitempty"`
+	Types      []string     `yaml:"Types,omitempty"`
+	Namespaces []string     `yaml:"Namespaces,omitempty"`
+	Events     []string     `yaml:"Events,omitempty"`
+	BufferSize int          `yaml:"BufferSize,omitempty"`
+	BatchSize  int          `yaml:"BatchSize,omitempty"`
+	Kafka      *KafkaSink   `yaml:"Kafka,omitempty"`
+	Webhook    *WebhookSink `yaml:"Webhook,omitempty"`
+}
+
+// KafkaSink configures a sink publishing the events to a Kafka topic
+type KafkaSink struct {
+	Brokers []string `yaml:"Brokers,omitempty"`
+	Topic   string   `yaml:"Topic,omitempty"`
+}
+
+// WebhookSink configures a sink posting the events to an HTTP endpoint
+type WebhookSink struct {
+	URL     string        `yaml:"URL,omitempty"`
+	Retries int           `yaml:"Retries,omitempty"`
+	Backoff time.Duration `yaml:"Backoff,omitempty"`
+	Timeout time.Duration `yaml:"Timeout,omitempty"`
 }
 
 type Network struct {
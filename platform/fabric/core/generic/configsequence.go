@@ -0,0 +1,181 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/configtx"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// ErrConfigSequenceGap is returned, wrapped in a ConfigSequenceGapError, when a config transaction
+// does not immediately follow the last config applied to the channel
+var ErrConfigSequenceGap = errors.New("config sequence gap")
+
+// ConfigSequenceGapError describes a config transaction whose sequence is not the next one expected on the channel
+type ConfigSequenceGapError struct {
+	Channel string
+	// Expected is the sequence following the last config applied
+	Expected uint64
+	// Sequence is the sequence of the rejected config transaction
+	Sequence uint64
+}
+
+func (e *ConfigSequenceGapError) Error() string {
+	return fmt.Sprintf("%s: channel [%s] expected config sequence [%d], got [%d]", ErrConfigSequenceGap, e.Channel, e.Expected, e.Sequence)
+}
+
+func (e *ConfigSequenceGapError) Is(target error) bool {
+	return target == ErrConfigSequenceGap
+}
+
+// configTx is a config transaction found in a block
+type configTx struct {
+	blockNumber  uint64
+	indexInBlock int
+	raw          []byte
+	envelope     *common.ConfigEnvelope
+}
+
+func (tx *configTx) sequence() uint64 {
+	return tx.envelope.Config.Sequence
+}
+
+func newConfigTx(blockNumber uint64, indexInBlock int, raw []byte, env *common.Envelope) (*configTx, error) {
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get payload from config transaction, block number [%d]", blockNumber)
+	}
+	ctx, err := configtx.UnmarshalConfigEnvelope(payload.Data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error unmarshalling config which passed initial validity checks")
+	}
+	if ctx.Config == nil {
+		return nil, errors.Errorf("config transaction without config, block number [%d]", blockNumber)
+	}
+	return &configTx{blockNumber: blockNumber, indexInBlock: indexInBlock, raw: raw, envelope: ctx}, nil
+}
+
+// configSequence tracks the sequence of the last config applied to a channel.
+// Calls are serialized by the applyLock of the channel.
+type configSequence struct {
+	channel string
+	// last is the sequence of the last config applied, meaningful only once anchored
+	last     uint64
+	anchored bool
+	// blocks fetches a block of the channel from the ledger of a peer
+	blocks func(number uint64) (*common.Block, error)
+}
+
+func newConfigSequence(channel string, blocks func(number uint64) (*common.Block, error)) *configSequence {
+	return &configSequence{channel: channel, blocks: blocks}
+}
+
+// applied records that the config with the passed sequence is now in use
+func (s *configSequence) applied(sequence uint64) {
+	s.last = sequence
+	s.anchored = true
+}
+
+// check returns a ConfigSequenceGapError if the passed sequence does not follow the last one applied.
+// Any sequence is accepted before the first config is applied.
+func (s *configSequence) check(sequence uint64) error {
+	if !s.anchored || sequence == s.last+1 {
+		return nil
+	}
+	return &ConfigSequenceGapError{Channel: s.channel, Expected: s.last + 1, Sequence: sequence}
+}
+
+// commit applies the passed config transaction.
+// If config transactions are missing in between, they are fetched and applied first.
+func (s *configSequence) commit(tx *configTx, apply func(tx *configTx) error) error {
+	err := s.check(tx.sequence())
+	if err == nil {
+		return apply(tx)
+	}
+	if tx.sequence() <= s.last {
+		// a stale config, nothing can be done
+		return err
+	}
+
+	logger.Warnf("[channel: %s] config sequence gap detected at block [%d]: %s, fetching the missing config blocks...", s.channel, tx.blockNumber, err)
+	missing, fetchErr := s.missing(tx.blockNumber, tx.sequence())
+	if fetchErr != nil {
+		return errors.WithMessagef(err, "failed fetching missing config blocks [%s]", fetchErr)
+	}
+	for _, m := range missing {
+		logger.Infof("[channel: %s] applying missing config sequence [%d] from block [%d]", s.channel, m.sequence(), m.blockNumber)
+		if err := apply(m); err != nil {
+			return errors.WithMessagef(err, "failed applying missing config sequence [%d] from block [%d]", m.sequence(), m.blockNumber)
+		}
+	}
+	if err := s.check(tx.sequence()); err != nil {
+		return err
+	}
+	return apply(tx)
+}
+
+// missing returns, in ascending order, the config transactions between the last one applied
+// and the passed sequence, found in a block preceding the passed one.
+// The blocks are walked backwards following the last config index in their metadata.
+func (s *configSequence) missing(blockNumber uint64, sequence uint64) ([]*configTx, error) {
+	var missing []*configTx
+	for number := blockNumber; number > 0; {
+		block, err := s.blocks(number - 1)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed fetching block [%d]", number-1)
+		}
+		index, err := protoutil.GetLastConfigIndexFromBlock(block)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed getting last config index from block [%d]", number-1)
+		}
+		if index != block.Header.Number {
+			if block, err = s.blocks(index); err != nil {
+				return nil, errors.WithMessagef(err, "failed fetching config block [%d]", index)
+			}
+		}
+		tx, err := lastConfigTx(block)
+		if err != nil {
+			return nil, err
+		}
+		if tx.sequence() <= s.last {
+			break
+		}
+		if tx.sequence() >= sequence {
+			return nil, errors.Errorf("config block [%d] has sequence [%d], expected less than [%d]", index, tx.sequence(), sequence)
+		}
+		missing = append([]*configTx{tx}, missing...)
+		number = index
+	}
+	return missing, nil
+}
+
+// lastConfigTx returns the last config transaction of the passed block, wherever it is in the block:
+// the config transactions are not expected to be alone in their block
+func lastConfigTx(block *common.Block) (*configTx, error) {
+	for i := len(block.Data.Data) - 1; i >= 0; i-- {
+		env, err := protoutil.ExtractEnvelope(block, i)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed extracting envelope [%d] from block [%d]", i, block.Header.Number)
+		}
+		payload, err := protoutil.UnmarshalPayload(env.Payload)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed unmarshalling payload [%d] of block [%d]", i, block.Header.Number)
+		}
+		chdr, err := protoutil.UnmarshalChannelHeader(payload.GetHeader().GetChannelHeader())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed unmarshalling channel header [%d] of block [%d]", i, block.Header.Number)
+		}
+		if chdr.Type == int32(common.HeaderType_CONFIG) {
+			return newConfigTx(block.Header.Number, i, block.Data.Data[i], env)
+		}
+	}
+	return nil, errors.Errorf("block [%d] carries no config transaction", block.Header.Number)
+}
@@ -0,0 +1,198 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// ledger is a chain of blocks whose config blocks are at the passed numbers, with increasing sequences starting from 0
+type ledger struct {
+	blocks  []*common.Block
+	fetched []uint64
+}
+
+func newLedger(t *testing.T, height uint64, configBlocks ...uint64) *ledger {
+	l := &ledger{}
+	var lastConfig, sequence uint64
+	for number := uint64(0); number < height; number++ {
+		data := [][]byte{[]byte("tx")}
+		if len(configBlocks) != 0 && configBlocks[0] == number {
+			configBlocks = configBlocks[1:]
+			lastConfig = number
+			data = [][]byte{configEnvelope(t, sequence)}
+			sequence++
+		}
+		l.blocks = append(l.blocks, &common.Block{
+			Header: &common.BlockHeader{Number: number},
+			Data:   &common.BlockData{Data: data},
+			Metadata: &common.BlockMetadata{Metadata: [][]byte{protoutil.MarshalOrPanic(&common.Metadata{
+				Value: protoutil.MarshalOrPanic(&common.OrdererBlockMetadata{LastConfig: &common.LastConfig{Index: lastConfig}}),
+			})}},
+		})
+	}
+	return l
+}
+
+func (l *ledger) fetch(number uint64) (*common.Block, error) {
+	if number >= uint64(len(l.blocks)) {
+		return nil, errors.Errorf("block [%d] not found", number)
+	}
+	l.fetched = append(l.fetched, number)
+	return l.blocks[number], nil
+}
+
+// tx returns the config transaction of the passed block, as delivered
+func (l *ledger) tx(t *testing.T, number uint64) *configTx {
+	env, err := protoutil.ExtractEnvelope(l.blocks[number], 0)
+	assert.NoError(t, err)
+	tx, err := newConfigTx(number, 0, l.blocks[number].Data.Data[0], env)
+	assert.NoError(t, err)
+	return tx
+}
+
+func configEnvelope(t *testing.T, sequence uint64) []byte {
+	payload := &common.Payload{
+		Header: &common.Header{ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{Type: int32(common.HeaderType_CONFIG)})},
+		Data:   protoutil.MarshalOrPanic(&common.ConfigEnvelope{Config: &common.Config{Sequence: sequence}}),
+	}
+	raw, err := protoutil.Marshal(&common.Envelope{Payload: protoutil.MarshalOrPanic(payload)})
+	assert.NoError(t, err)
+	return raw
+}
+
+// applier records the config sequences applied, as the channel would
+type applier struct {
+	seq     *configSequence
+	applied []uint64
+	fail    map[uint64]error
+}
+
+func (a *applier) apply(tx *configTx) error {
+	if err := a.fail[tx.sequence()]; err != nil {
+		return err
+	}
+	a.applied = append(a.applied, tx.sequence())
+	a.seq.applied(tx.sequence())
+	return nil
+}
+
+func assertGap(t *testing.T, err error, expected, sequence uint64) {
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrConfigSequenceGap), "expected a gap error, got [%s]", err)
+	gap := &ConfigSequenceGapError{}
+	assert.True(t, errors.As(err, &gap))
+	assert.Equal(t, expected, gap.Expected)
+	assert.Equal(t, sequence, gap.Sequence)
+}
+
+func TestConfigSequenceInOrder(t *testing.T) {
+	l := newLedger(t, 10, 0, 3, 7)
+	s := newConfigSequence("ch", l.fetch)
+	a := &applier{seq: s}
+
+	for _, number := range []uint64{0, 3, 7} {
+		assert.NoError(t, s.commit(l.tx(t, number), a.apply))
+	}
+	assert.Equal(t, []uint64{0, 1, 2}, a.applied)
+	assert.Empty(t, l.fetched)
+}
+
+func TestConfigSequenceOutOfOrder(t *testing.T) {
+	l := newLedger(t, 10, 0, 3, 7)
+	s := newConfigSequence("ch", l.fetch)
+	a := &applier{seq: s}
+	assert.NoError(t, s.commit(l.tx(t, 0), a.apply))
+	assert.NoError(t, s.commit(l.tx(t, 3), a.apply))
+
+	// the same sequence again, and an older one, are rejected
+	assertGap(t, s.commit(l.tx(t, 3), a.apply), 2, 1)
+	assertGap(t, s.commit(l.tx(t, 0), a.apply), 2, 0)
+	assert.Equal(t, []uint64{0, 1}, a.applied)
+	assert.Empty(t, l.fetched)
+}
+
+func TestConfigSequenceGapFetchesMissingBlocks(t *testing.T) {
+	l := newLedger(t, 20, 0, 3, 7, 8, 15)
+	s := newConfigSequence("ch", l.fetch)
+	a := &applier{seq: s}
+	assert.NoError(t, s.commit(l.tx(t, 0), a.apply))
+	assert.NoError(t, s.commit(l.tx(t, 3), a.apply))
+
+	// the delivery skips the config blocks 7 and 8, they are fetched before applying the one in block 15
+	assert.NoError(t, s.commit(l.tx(t, 15), a.apply))
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4}, a.applied)
+	assert.Equal(t, []uint64{14, 8, 7, 6, 3}, l.fetched)
+}
+
+func TestConfigSequenceGapFromGenesis(t *testing.T) {
+	l := newLedger(t, 5, 0, 1, 2)
+	s := newConfigSequence("ch", l.fetch)
+	a := &applier{seq: s}
+	assert.NoError(t, s.commit(l.tx(t, 0), a.apply))
+
+	assert.NoError(t, s.commit(l.tx(t, 2), a.apply))
+	assert.Equal(t, []uint64{0, 1, 2}, a.applied)
+}
+
+func TestConfigSequenceGapNotRecovered(t *testing.T) {
+	l := newLedger(t, 20, 0, 3, 7, 15)
+	s := newConfigSequence("ch", l.fetch)
+	a := &applier{seq: s}
+	assert.NoError(t, s.commit(l.tx(t, 0), a.apply))
+
+	// the missing blocks cannot be fetched
+	tx := l.tx(t, 15)
+	blocks := l.blocks
+	l.blocks = l.blocks[:4]
+	assertGap(t, s.commit(tx, a.apply), 1, 3)
+	assert.Equal(t, []uint64{0}, a.applied)
+
+	// a missing config does not validate, the config of block 15 is not applied
+	l.blocks = blocks
+	a.fail = map[uint64]error{2: errors.New("invalid config")}
+	err := s.commit(tx, a.apply)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid config")
+	assert.Equal(t, []uint64{0, 1}, a.applied)
+
+	// the retry of the delivery resumes from the last config applied
+	a.fail = nil
+	assert.NoError(t, s.commit(tx, a.apply))
+	assert.Equal(t, []uint64{0, 1, 2, 3}, a.applied)
+}
+
+func TestConfigSequenceGapMixedBlocks(t *testing.T) {
+	l := newLedger(t, 10, 0, 3, 7)
+	endorserTx := protoutil.MarshalOrPanic(&common.Envelope{Payload: protoutil.MarshalOrPanic(&common.Payload{
+		Header: &common.Header{ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{Type: int32(common.HeaderType_ENDORSER_TRANSACTION), TxId: "tx"})},
+	})})
+	// the config transaction of block 3 follows, and precedes, application transactions
+	l.blocks[3].Data.Data = [][]byte{endorserTx, l.blocks[3].Data.Data[0], endorserTx}
+	s := newConfigSequence("ch", l.fetch)
+	a := &applier{seq: s}
+	assert.NoError(t, s.commit(l.tx(t, 0), a.apply))
+
+	// the missing config is found at its position in the block
+	var positions []int
+	apply := func(tx *configTx) error {
+		positions = append(positions, tx.indexInBlock)
+		return a.apply(tx)
+	}
+	assert.NoError(t, s.commit(l.tx(t, 7), apply))
+	assert.Equal(t, []uint64{0, 1, 2}, a.applied)
+	assert.Equal(t, []int{1, 0}, positions)
+
+	// a block without config transaction is refused with a clear error
+	_, err := lastConfigTx(&common.Block{Header: &common.BlockHeader{Number: 4}, Data: &common.BlockData{Data: [][]byte{endorserTx}}})
+	assert.EqualError(t, err, "block [4] carries no config transaction")
+}
@@ -0,0 +1,267 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// configPathRegexp matches the elements of the configuration tree in the errors of the configtx validator,
+// for instance `[Value]  /Channel/Orderer/BatchSize`
+var configPathRegexp = regexp.MustCompile(`\[(Group|Value|Policy)\]\s+(/[^\s:]*)`)
+
+// organizationSections are the groups whose sub-groups are organizations
+var organizationSections = map[string]bool{
+	"/" + channelconfig.ChannelGroupKey + "/" + channelconfig.ApplicationGroupKey: true,
+	"/" + channelconfig.ChannelGroupKey + "/" + channelconfig.OrdererGroupKey:     true,
+}
+
+// SimulateConfigUpdate validates the passed config update envelope as CommitConfig validates the config transactions:
+// the update is applied to the active configuration by the configtx validator, the result is validated, and its
+// bundle built and checked for the capabilities. Nothing is committed, and the active configuration is unchanged.
+func (c *channel) SimulateConfigUpdate(raw []byte) (*driver.ConfigUpdateSimulation, error) {
+	res := c.Resources()
+	if res == nil {
+		return nil, errors.Errorf("[channel: %s] no configuration applied yet", c.name)
+	}
+	env, err := protoutil.UnmarshalEnvelope(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config update envelope")
+	}
+
+	invalid := func(err error) *driver.ConfigUpdateSimulation {
+		return &driver.ConfigUpdateSimulation{Error: configError(err)}
+	}
+	validator := res.ConfigtxValidator()
+	configEnv, err := validator.ProposeConfigUpdate(env)
+	if err != nil {
+		return invalid(errors.Wrapf(err, "failed to propose config update")), nil
+	}
+	bundle, err := c.nextBundle(res, configEnv)
+	if err != nil {
+		return invalid(err), nil
+	}
+	return &driver.ConfigUpdateSimulation{
+		Valid:    true,
+		Sequence: bundle.ConfigtxValidator().Sequence(),
+		Diff:     DiffConfigs(validator.ConfigProto(), configEnv.Config),
+	}, nil
+}
+
+// configError returns the passed validation error with the element of the configuration tree it reports, if any
+func configError(err error) *driver.ConfigError {
+	e := &driver.ConfigError{Message: err.Error()}
+	if m := configPathRegexp.FindStringSubmatch(e.Message); m != nil {
+		e.Element, e.Path = m[1], m[2]
+	}
+	return e
+}
+
+// DiffConfigs returns the changes from the previous configuration to the next one
+func DiffConfigs(previous, next *common.Config) *driver.ConfigDiff {
+	diff := &driver.ConfigDiff{}
+	diffGroups("/"+channelconfig.ChannelGroupKey, previous.GetChannelGroup(), next.GetChannelGroup(), diff)
+	return diff
+}
+
+func diffGroups(path string, previous, next *common.ConfigGroup, diff *driver.ConfigDiff) {
+	if previous != nil && next != nil && previous.ModPolicy != next.ModPolicy {
+		diff.Other = append(diff.Other, driver.ConfigElementChange{Path: path, Kind: driver.ConfigModified})
+	}
+
+	for _, name := range unionKeys(previous.GetPolicies(), next.GetPolicies()) {
+		p, n := previous.GetPolicies()[name], next.GetPolicies()[name]
+		if kind, changed := changeOf(p != nil, n != nil, func() bool {
+			return p.ModPolicy == n.ModPolicy && proto.Equal(p.Policy, n.Policy)
+		}); changed {
+			diff.Policies = append(diff.Policies, driver.ConfigElementChange{Path: path + "/" + name, Kind: kind})
+		}
+	}
+
+	for _, name := range unionKeys(previous.GetValues(), next.GetValues()) {
+		p, n := previous.GetValues()[name], next.GetValues()[name]
+		if _, changed := changeOf(p != nil, n != nil, func() bool {
+			return p.ModPolicy == n.ModPolicy && bytes.Equal(p.Value, n.Value)
+		}); changed {
+			diffValue(path, name, p, n, diff)
+		}
+	}
+
+	for _, name := range unionKeys(previous.GetGroups(), next.GetGroups()) {
+		p, n := previous.GetGroups()[name], next.GetGroups()[name]
+		sub := path + "/" + name
+		if organizationSections[path] && (p == nil || n == nil) {
+			if p == nil {
+				diff.AddedOrganizations = append(diff.AddedOrganizations, organization(sub, name, n))
+			} else {
+				diff.RemovedOrganizations = append(diff.RemovedOrganizations, organization(sub, name, p))
+			}
+			continue
+		}
+		diffGroups(sub, p, n, diff)
+	}
+}
+
+// diffValue records the change of the value of the passed name, nil on the side it does not exist
+func diffValue(path, name string, previous, next *common.ConfigValue, diff *driver.ConfigDiff) {
+	sub := path + "/" + name
+	switch name {
+	case channelconfig.CapabilitiesKey:
+		p, n := &common.Capabilities{}, &common.Capabilities{}
+		if unmarshalValue(previous, p) == nil && unmarshalValue(next, n) == nil {
+			added, removed := diffStrings(capabilityNames(p), capabilityNames(n))
+			if len(added) != 0 || len(removed) != 0 {
+				diff.Capabilities = append(diff.Capabilities, driver.CapabilitiesChange{Path: sub, Added: added, Removed: removed})
+			}
+			return
+		}
+	case channelconfig.EndpointsKey, channelconfig.OrdererAddressesKey:
+		p, n := &common.OrdererAddresses{}, &common.OrdererAddresses{}
+		if unmarshalValue(previous, p) == nil && unmarshalValue(next, n) == nil {
+			added, removed := diffStrings(p.Addresses, n.Addresses)
+			if len(added) != 0 || len(removed) != 0 {
+				diff.OrdererEndpoints = append(diff.OrdererEndpoints, driver.EndpointsChange{Path: sub, Added: added, Removed: removed})
+			}
+			return
+		}
+	case channelconfig.BatchSizeKey:
+		p, n := &ab.BatchSize{}, &ab.BatchSize{}
+		if unmarshalValue(previous, p) == nil && unmarshalValue(next, n) == nil {
+			if diff.Batch == nil {
+				diff.Batch = &driver.BatchChange{}
+			}
+			if previous != nil {
+				diff.Batch.PreviousSize = &driver.BatchSize{MaxMessageCount: p.MaxMessageCount, AbsoluteMaxBytes: p.AbsoluteMaxBytes, PreferredMaxBytes: p.PreferredMaxBytes}
+			}
+			if next != nil {
+				diff.Batch.NextSize = &driver.BatchSize{MaxMessageCount: n.MaxMessageCount, AbsoluteMaxBytes: n.AbsoluteMaxBytes, PreferredMaxBytes: n.PreferredMaxBytes}
+			}
+			return
+		}
+	case channelconfig.BatchTimeoutKey:
+		p, n := &ab.BatchTimeout{}, &ab.BatchTimeout{}
+		if unmarshalValue(previous, p) == nil && unmarshalValue(next, n) == nil {
+			if diff.Batch == nil {
+				diff.Batch = &driver.BatchChange{}
+			}
+			diff.Batch.PreviousTimeout, diff.Batch.NextTimeout = p.Timeout, n.Timeout
+			return
+		}
+	}
+	kind, _ := changeOf(previous != nil, next != nil, func() bool { return false })
+	diff.Other = append(diff.Other, driver.ConfigElementChange{Path: sub, Kind: kind})
+}
+
+// organization returns the organization of the passed group, its MSP ID if it carries an MSP configuration
+func organization(path, name string, group *common.ConfigGroup) driver.OrganizationChange {
+	org := driver.OrganizationChange{Path: path, Name: name}
+	value, mspConfig := group.GetValues()[channelconfig.MSPKey], &mspproto.MSPConfig{}
+	if value == nil || proto.Unmarshal(value.Value, mspConfig) != nil {
+		return org
+	}
+	fabricConfig := &mspproto.FabricMSPConfig{}
+	if err := proto.Unmarshal(mspConfig.Config, fabricConfig); err == nil {
+		org.MSPID = fabricConfig.Name
+	}
+	return org
+}
+
+// unmarshalValue unmarshals the passed value, if not nil
+func unmarshalValue(value *common.ConfigValue, m proto.Message) error {
+	if value == nil {
+		return nil
+	}
+	return proto.Unmarshal(value.Value, m)
+}
+
+// changeOf returns the kind of change of an element, and if it changed: unchanged is called when it exists on both sides
+func changeOf(previous, next bool, unchanged func() bool) (driver.ConfigChange, bool) {
+	switch {
+	case !previous:
+		return driver.ConfigAdded, true
+	case !next:
+		return driver.ConfigRemoved, true
+	case unchanged():
+		return "", false
+	default:
+		return driver.ConfigModified, true
+	}
+}
+
+// applicationCapabilities returns the application capabilities of the passed config, sorted.
+// It returns nil if the config has no application group, an empty slice if it has no capabilities.
+func applicationCapabilities(config *common.Config) []string {
+	application, ok := config.GetChannelGroup().GetGroups()[channelconfig.ApplicationGroupKey]
+	if !ok {
+		return nil
+	}
+	c := &common.Capabilities{}
+	if err := unmarshalValue(application.Values[channelconfig.CapabilitiesKey], c); err != nil {
+		logger.Warnf("failed unmarshalling the application capabilities: [%s]", err)
+	}
+	names := append([]string{}, capabilityNames(c)...)
+	sort.Strings(names)
+	return names
+}
+
+func capabilityNames(c *common.Capabilities) []string {
+	var names []string
+	for name := range c.Capabilities {
+		names = append(names, name)
+	}
+	return names
+}
+
+// diffStrings returns the strings of next not in previous, and the ones of previous not in next, sorted
+func diffStrings(previous, next []string) (added, removed []string) {
+	p, n := map[string]bool{}, map[string]bool{}
+	for _, s := range previous {
+		p[s] = true
+	}
+	for _, s := range next {
+		n[s] = true
+		if !p[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range previous {
+		if !n[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
+
+// unionKeys returns the sorted keys of the passed maps
+func unionKeys[V any](previous, next map[string]V) []string {
+	keys := map[string]bool{}
+	for k := range previous {
+		keys[k] = true
+	}
+	for k := range next {
+		keys[k] = true
+	}
+	var res []string
+	for k := range keys {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
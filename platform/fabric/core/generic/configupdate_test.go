@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func configValue(t *testing.T, m proto.Message) *common.ConfigValue {
+	raw, err := proto.Marshal(m)
+	assert.NoError(t, err)
+	return &common.ConfigValue{Value: raw, ModPolicy: "Admins"}
+}
+
+func orgGroup(t *testing.T, mspID string, endpoints ...string) *common.ConfigGroup {
+	fabricConfig, err := proto.Marshal(&mspproto.FabricMSPConfig{Name: mspID})
+	assert.NoError(t, err)
+	g := &common.ConfigGroup{
+		Values:   map[string]*common.ConfigValue{"MSP": configValue(t, &mspproto.MSPConfig{Config: fabricConfig})},
+		Policies: map[string]*common.ConfigPolicy{"Admins": {ModPolicy: "Admins", Policy: &common.Policy{Type: 1}}},
+	}
+	if len(endpoints) != 0 {
+		g.Values["Endpoints"] = configValue(t, &common.OrdererAddresses{Addresses: endpoints})
+	}
+	return g
+}
+
+func configTree(t *testing.T, capability string, batchSize uint32, timeout string, orgs []string, endpoints ...string) *common.Config {
+	application := &common.ConfigGroup{
+		Groups:   map[string]*common.ConfigGroup{},
+		Values:   map[string]*common.ConfigValue{"Capabilities": configValue(t, &common.Capabilities{Capabilities: map[string]*common.Capability{capability: {}}})},
+		Policies: map[string]*common.ConfigPolicy{"Admins": {ModPolicy: "Admins", Policy: &common.Policy{Type: 3}}},
+	}
+	for _, name := range orgs {
+		application.Groups[name] = orgGroup(t, name+"MSP")
+	}
+	orderer := &common.ConfigGroup{
+		Groups: map[string]*common.ConfigGroup{"OrdererOrg": orgGroup(t, "OrdererMSP", endpoints...)},
+		Values: map[string]*common.ConfigValue{
+			"BatchSize":    configValue(t, &ab.BatchSize{MaxMessageCount: 10, AbsoluteMaxBytes: batchSize, PreferredMaxBytes: batchSize / 2}),
+			"BatchTimeout": configValue(t, &ab.BatchTimeout{Timeout: timeout}),
+		},
+	}
+	return &common.Config{ChannelGroup: &common.ConfigGroup{
+		Groups: map[string]*common.ConfigGroup{"Application": application, "Orderer": orderer},
+	}}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	previous := configTree(t, "V2_0", 1024, "2s", []string{"Org1", "Org2"}, "orderer1:7050", "orderer2:7050")
+
+	// no change
+	assert.Equal(t, &driver.ConfigDiff{}, DiffConfigs(previous, configTree(t, "V2_0", 1024, "2s", []string{"Org1", "Org2"}, "orderer1:7050", "orderer2:7050")))
+
+	next := configTree(t, "V2_5", 2048, "1s", []string{"Org1", "Org3"}, "orderer1:7050", "orderer3:7050")
+	next.ChannelGroup.Groups["Application"].Groups["Org1"].Policies["Admins"].Policy.Type = 2
+	next.ChannelGroup.Groups["Application"].Policies["Writers"] = &common.ConfigPolicy{ModPolicy: "Admins", Policy: &common.Policy{Type: 3}}
+	next.ChannelGroup.Groups["Application"].Values["ACLs"] = &common.ConfigValue{Value: []byte("acls"), ModPolicy: "Admins"}
+	next.ChannelGroup.Groups["Orderer"].ModPolicy = "Admins"
+
+	assert.Equal(t, &driver.ConfigDiff{
+		Policies: []driver.ConfigElementChange{
+			{Path: "/Channel/Application/Writers", Kind: driver.ConfigAdded},
+			{Path: "/Channel/Application/Org1/Admins", Kind: driver.ConfigModified},
+		},
+		AddedOrganizations:   []driver.OrganizationChange{{Path: "/Channel/Application/Org3", Name: "Org3", MSPID: "Org3MSP"}},
+		RemovedOrganizations: []driver.OrganizationChange{{Path: "/Channel/Application/Org2", Name: "Org2", MSPID: "Org2MSP"}},
+		OrdererEndpoints: []driver.EndpointsChange{
+			{Path: "/Channel/Orderer/OrdererOrg/Endpoints", Added: []string{"orderer3:7050"}, Removed: []string{"orderer2:7050"}},
+		},
+		Batch: &driver.BatchChange{
+			PreviousSize:    &driver.BatchSize{MaxMessageCount: 10, AbsoluteMaxBytes: 1024, PreferredMaxBytes: 512},
+			NextSize:        &driver.BatchSize{MaxMessageCount: 10, AbsoluteMaxBytes: 2048, PreferredMaxBytes: 1024},
+			PreviousTimeout: "2s",
+			NextTimeout:     "1s",
+		},
+		Capabilities: []driver.CapabilitiesChange{
+			{Path: "/Channel/Application/Capabilities", Added: []string{"V2_5"}, Removed: []string{"V2_0"}},
+		},
+		Other: []driver.ConfigElementChange{
+			{Path: "/Channel/Application/ACLs", Kind: driver.ConfigAdded},
+			{Path: "/Channel/Orderer", Kind: driver.ConfigModified},
+		},
+	}, DiffConfigs(previous, next))
+}
+
+func TestConfigError(t *testing.T) {
+	err := configError(errors.New("failed to propose config update: error authorizing update: error validating DeltaSet: policy for [Group]  /Channel/Application not satisfied: implicit policy evaluation failed"))
+	assert.Equal(t, "Group", err.Element)
+	assert.Equal(t, "/Channel/Application", err.Path)
+
+	err = configError(errors.New("invalid mod_policy for element [Value]  /Channel/Orderer/BatchSize: mod_policy not set"))
+	assert.Equal(t, "Value", err.Element)
+	assert.Equal(t, "/Channel/Orderer/BatchSize", err.Path)
+
+	err = configError(errors.New("failed to create next bundle: bad"))
+	assert.Empty(t, err.Path)
+	assert.Equal(t, "failed to create next bundle: bad", err.Message)
+
+	_, err2 := (&channel{name: "mychannel"}).SimulateConfigUpdate(nil)
+	assert.Error(t, err2)
+}
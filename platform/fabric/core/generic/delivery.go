@@ -19,7 +19,17 @@ import (
 type ValidationFlags []uint8
 
 func (c *channel) StartDelivery(ctx context.Context) error {
-	c.deliveryService.Start(ctx)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c.startDelivery(ctx)
+	// do not serve until a vault restored from a backup has caught up
+	if err := c.resync(ctx); err != nil {
+		return err
+	}
+	if c.config.VaultIntegrityVerifyOnStartup() {
+		c.verifyIntegrity()
+	}
 	return nil
 }
 
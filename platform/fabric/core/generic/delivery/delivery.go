@@ -48,6 +48,14 @@ type Vault interface {
 	GetLastTxID() (string, error)
 }
 
+// StreamListener is notified when the stream of blocks breaks and when it is established again
+type StreamListener interface {
+	// Interrupted is called when the stream breaks, or cannot be established, for the passed reason
+	Interrupted(reason error)
+	// Resumed is called each time the stream is established
+	Resumed()
+}
+
 type Network interface {
 	Channel(name string) (driver.Channel, error)
 	PickPeer() *grpc.ConnectionConfig
@@ -64,6 +72,7 @@ type Delivery struct {
 	client              peer.Client
 	lastBlockReceived   uint64
 	stop                chan bool
+	listener            StreamListener
 }
 
 func New(channel string, sp view2.ServiceProvider, network Network, callback Callback, vault Vault, waitForEventTimeout time.Duration) (*Delivery, error) {
@@ -82,6 +91,11 @@ func New(channel string, sp view2.ServiceProvider, network Network, callback Cal
 	return d, nil
 }
 
+// SetStreamListener sets the listener notified of the interruptions of the stream of blocks
+func (d *Delivery) SetStreamListener(listener StreamListener) {
+	d.listener = listener
+}
+
 // Start runs the delivery service in a goroutine
 func (d *Delivery) Start(ctx context.Context) {
 	go d.Run(ctx)
@@ -112,18 +126,23 @@ func (d *Delivery) Run(ctx context.Context) error {
 				}
 				df, err = d.connect(ctx)
 				if err != nil {
+					d.interrupted(err)
 					logger.Errorf("failed connecting to delivery service [%s:%s] [%s]. Wait 10 sec before reconnecting", d.channel, err)
-					time.Sleep(10 * time.Second)
+					if !d.pause(ctx, 10*time.Second) {
+						return d.stopped(ctx)
+					}
 					if logger.IsEnabledFor(zapcore.DebugLevel) {
 						logger.Debugf("reconnecting to delivery service [%s:%s]", d.channel)
 					}
 					continue
 				}
+				d.resumed()
 			}
 
 			resp, err := df.Recv()
 			if err != nil {
 				df = nil
+				d.interrupted(err)
 				logger.Errorf("delivery service [%s:%s], failed receiving response [%s]",
 					d.client.Address(), d.channel,
 					errors.WithMessagef(err, "error receiving deliver response from peer %s", d.client.Address()))
@@ -133,11 +152,12 @@ func (d *Delivery) Run(ctx context.Context) error {
 			switch r := resp.Type.(type) {
 			case *pb.DeliverResponse_Block:
 				if r.Block == nil || r.Block.Data == nil || r.Block.Header == nil || r.Block.Metadata == nil {
-					if logger.IsEnabledFor(zapcore.DebugLevel) {
-						logger.Debugf("deliver service [%s:%s], received nil block", d.client.Address(), d.channel)
+					logger.Warnf("deliver service [%s:%s], received malformed block, reconnect", d.client.Address(), d.channel)
+					if !d.pause(ctx, 10*time.Second) {
+						return d.stopped(ctx)
 					}
-					time.Sleep(10 * time.Second)
 					df = nil
+					continue
 				}
 
 				if logger.IsEnabledFor(zapcore.DebugLevel) {
@@ -148,8 +168,10 @@ func (d *Delivery) Run(ctx context.Context) error {
 				stop, err := d.callback(r.Block)
 				if err != nil {
 					logger.Errorf("error occurred when processing filtered block [%s], retry...", err)
-					time.Sleep(10 * time.Second)
 					df = nil
+					if !d.pause(ctx, 10*time.Second) {
+						return d.stopped(ctx)
+					}
 				}
 				if stop {
 					return nil
@@ -158,7 +180,9 @@ func (d *Delivery) Run(ctx context.Context) error {
 				if r.Status == common.Status_NOT_FOUND {
 					df = nil
 					logger.Warnf("delivery service [%s:%s] status [%s], wait a few seconds before retrying", d.client.Address(), d.channel, r.Status)
-					time.Sleep(10 * time.Second)
+					if !d.pause(ctx, 10*time.Second) {
+						return d.stopped(ctx)
+					}
 				} else {
 					logger.Warnf("delivery service [%s:%s] status [%s]", d.client.Address(), d.channel, r.Status)
 				}
@@ -170,6 +194,29 @@ func (d *Delivery) Run(ctx context.Context) error {
 	}
 }
 
+// pause waits for the passed duration before retrying, it returns false if the delivery is stopped,
+// or its context done, meanwhile
+func (d *Delivery) pause(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-d.stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stopped returns what Run returns once the delivery is stopped, or its context done
+func (d *Delivery) stopped(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return errors.New("context done")
+	}
+	return nil
+}
+
 func (d *Delivery) connect(ctx context.Context) (DeliverStream, error) {
 	// first cleanup everything
 	d.cleanup()
@@ -271,6 +318,18 @@ func (d *Delivery) GetStartPosition() *ab.SeekPosition {
 	return StartGenesis
 }
 
+func (d *Delivery) interrupted(reason error) {
+	if d.listener != nil {
+		d.listener.Interrupted(reason)
+	}
+}
+
+func (d *Delivery) resumed() {
+	if d.listener != nil {
+		d.listener.Resumed()
+	}
+}
+
 func (d *Delivery) cleanup() {
 	if d.client != nil {
 		d.client.Close()
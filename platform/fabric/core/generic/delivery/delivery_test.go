@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/fabrictest"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type hasher struct{}
+
+func (h *hasher) GetHash() hash.Hash {
+	return sha256.New()
+}
+
+func (h *hasher) Hash(msg []byte) ([]byte, error) {
+	digest := sha256.Sum256(msg)
+	return digest[:], nil
+}
+
+// services provides the hasher only
+type services struct{}
+
+func (s *services) GetService(v interface{}) (interface{}, error) {
+	return &hasher{}, nil
+}
+
+type signingIdentity struct{}
+
+func (s *signingIdentity) Serialize() ([]byte, error) {
+	return []byte("alice"), nil
+}
+
+func (s *signingIdentity) Sign(msg []byte) ([]byte, error) {
+	return []byte("signature"), nil
+}
+
+type localMembership struct {
+	driver.LocalMembership
+}
+
+func (m *localMembership) DefaultSigningIdentity() driver.SigningIdentity {
+	return &signingIdentity{}
+}
+
+// fakeNetwork picks the fake peers in turn
+type fakeNetwork struct {
+	peers *fabrictest.RoundRobin
+}
+
+func (n *fakeNetwork) Channel(name string) (driver.Channel, error) {
+	return &fabrictest.Channel{ChannelName: name}, nil
+}
+
+func (n *fakeNetwork) PickPeer() *grpc.ConnectionConfig {
+	return n.peers.Pick()
+}
+
+func (n *fakeNetwork) LocalMembership() driver.LocalMembership {
+	return &localMembership{}
+}
+
+type emptyVault struct{}
+
+func (v *emptyVault) GetLastTxID() (string, error) {
+	return "", nil
+}
+
+type listener struct {
+	lock        sync.Mutex
+	interrupted int
+	resumed     int
+}
+
+func (l *listener) Interrupted(reason error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.interrupted++
+}
+
+func (l *listener) Resumed() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.resumed++
+}
+
+// newBlocks returns five blocks, the third one being a config block
+func newBlocks(t *testing.T) *fabrictest.BlockSource {
+	blocks := fabrictest.NewBlockSource()
+	blocks.AppendTransactions(fabrictest.NewEnvelope("mychannel", "tx0"))
+	blocks.AppendTransactions(fabrictest.NewEnvelope("mychannel", "tx1"))
+	_, err := blocks.AppendConfig("mychannel", &common.Config{Sequence: 1})
+	assert.NoError(t, err)
+	blocks.AppendTransactions(fabrictest.NewEnvelope("mychannel", "tx3"))
+	blocks.AppendTransactions(fabrictest.NewEnvelope("mychannel", "tx4"))
+	return blocks
+}
+
+// run delivers the blocks of the passed peers, picked in turn, until the fifth block and returns the numbers of the
+// blocks received
+func run(t *testing.T, l StreamListener, peers ...fabrictest.Endpoint) []uint64 {
+	var received []uint64
+	d, err := New("mychannel", &services{}, &fakeNetwork{peers: fabrictest.NewRoundRobin(peers...)}, func(block *common.Block) (bool, error) {
+		received = append(received, block.Header.Number)
+		return block.Header.Number == 4, nil
+	}, &emptyVault{}, time.Second)
+	assert.NoError(t, err)
+	d.SetStreamListener(l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, d.Run(ctx))
+	return received
+}
+
+func TestDeliveryFailover(t *testing.T) {
+	blocks := newBlocks(t)
+	p1, err := fabrictest.NewPeer(blocks)
+	assert.NoError(t, err)
+	defer p1.Stop()
+	p2, err := fabrictest.NewPeer(blocks)
+	assert.NoError(t, err)
+	defer p2.Stop()
+	// the first peer drops the stream after two blocks, the delivery resumes with the second one
+	// from the last block received
+	p1.Deliveries.Then(fabrictest.DropAfter(2))
+
+	l := &listener{}
+	assert.Equal(t, []uint64{0, 1, 1, 2, 3, 4}, run(t, l, p1, p2))
+	assert.Equal(t, 1, p1.Deliveries.Requests())
+	assert.Equal(t, 1, p2.Deliveries.Requests())
+	assert.Equal(t, 1, l.interrupted)
+	assert.Equal(t, 2, l.resumed)
+}
+
+func TestDeliveryRejected(t *testing.T) {
+	blocks := newBlocks(t)
+	p1, err := fabrictest.NewPeer(blocks)
+	assert.NoError(t, err)
+	defer p1.Stop()
+	p2, err := fabrictest.NewPeer(blocks)
+	assert.NoError(t, err)
+	defer p2.Stop()
+	// the first peer rejects the stream, the delivery starts from the genesis with the second one
+	p1.Deliveries.Default(fabrictest.Reject(common.Status_SERVICE_UNAVAILABLE))
+
+	l := &listener{}
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4}, run(t, l, p1, p2))
+	assert.Equal(t, 1, p1.Deliveries.Requests())
+	assert.Equal(t, 1, l.interrupted)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"sort"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+)
+
+// Describe returns the chaincodes bound to the channel, the subscriptions to their events and the config sequence in force
+func (c *channel) Describe() *driver.ChannelDescription {
+	res := &driver.ChannelDescription{
+		Chaincodes:             []string{},
+		ChaincodeSubscriptions: c.chaincodeSubscriptions.Chaincodes(),
+		ProcessNamespaces:      append([]string(nil), c.processNamespaces...),
+	}
+	// an unloaded channel is described without reloading it
+	c.lock.RLock()
+	unloaded, config := c.unloaded, c.unloadedConfig
+	c.lock.RUnlock()
+	if unloaded {
+		res.Unloaded = true
+		res.ConfigSequence = config.GetSequence()
+	} else {
+		res.ConfigSequence = c.configSequenceInForce()
+	}
+	c.chaincodesLock.RLock()
+	for name := range c.chaincodes {
+		res.Chaincodes = append(res.Chaincodes, name)
+	}
+	c.chaincodesLock.RUnlock()
+	sort.Strings(res.Chaincodes)
+	return res
+}
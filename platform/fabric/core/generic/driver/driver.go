@@ -7,6 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 package driver
 
 import (
+	"context"
+
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
@@ -15,7 +17,11 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	driver2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/factory"
 	"github.com/pkg/errors"
 )
 
@@ -67,6 +73,11 @@ func (d *Driver) New(sp view.ServiceProvider, network string, defaultNetwork boo
 		return nil, errors.Wrap(err, "failed creating id provider")
 	}
 
+	cryptoProvider, err := defaultCryptoProvider()
+	if err != nil {
+		return nil, err
+	}
+
 	// New Network
 	net, err := generic.NewNetwork(
 		sp,
@@ -75,6 +86,7 @@ func (d *Driver) New(sp view.ServiceProvider, network string, defaultNetwork boo
 		idProvider,
 		mspService,
 		sigService,
+		cryptoProvider,
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed instantiating fabric service provider")
@@ -83,6 +95,31 @@ func (d *Driver) New(sp view.ServiceProvider, network string, defaultNetwork boo
 	return net, nil
 }
 
+// Validate checks the configuration of the passed network without instantiating it, see generic.Validate
+func (d *Driver) Validate(ctx context.Context, sp view.ServiceProvider, configService driver2.ConfigService, network string, defaultNetwork bool, opts *dryrun.Options, report *dryrun.Report) {
+	c, err := config.New(configService, network, defaultNetwork)
+	if err != nil {
+		report.Add("fabric."+network, "config", "", err)
+		return
+	}
+	cryptoProvider, err := defaultCryptoProvider()
+	if err != nil {
+		report.Add("fabric."+network, "bccsp", "", err)
+		return
+	}
+	generic.Validate(ctx, sp, c, cryptoProvider, opts, report)
+}
+
+// defaultCryptoProvider returns the default BCCSP, once the factories are initialized.
+// The factories are initialized with the default options if nobody did before; the concurrent initializations
+// wait for the first one to complete, the channels never observe an uninitialized provider.
+func defaultCryptoProvider() (bccsp.BCCSP, error) {
+	if err := factory.InitFactories(nil); err != nil {
+		return nil, errors.Wrap(err, "failed initializing the BCCSP factories")
+	}
+	return factory.GetDefault(), nil
+}
+
 func init() {
 	core.Register("fabric", &Driver{})
 }
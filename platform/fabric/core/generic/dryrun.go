@@ -0,0 +1,202 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/x509"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/rwset"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/common/configtx"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// Validate checks the configuration of the passed network without creating it, and adds to the passed report
+// all the problems found: the MSPs must load, with a signer able to sign, the TLS material must be readable,
+// the peers and the orderers dialable, and the vaults of the channels openable, with the last config transaction
+// they store parseable. The MSPs are loaded as on startup, by a read-only manager: the identities to enroll with a CA
+// are reported as warnings, see msp.NewReadOnlyMSPManager.
+// Nothing is written: the vaults are opened read-only, the ones not created yet are reported as warnings.
+// The orderers of the read-only networks are not dialed, they are never contacted.
+func Validate(ctx context.Context, sp view2.ServiceProvider, c *config.Config, cryptoProvider bccsp.BCCSP, opts *dryrun.Options, report *dryrun.Report) {
+	component := "fabric." + c.Name()
+
+	err := msp.NewReadOnlyMSPManager(sp, c, c.MSPCacheSize()).Check(func(id string, err error) {
+		if errors.Is(err, x509.ErrNotEnrolled) || errors.Is(err, msp.ErrUnknownMSPType) {
+			report.Warn(component, "msp", id, err)
+			return
+		}
+		report.Add(component, "msp", id, err)
+	})
+	if err != nil {
+		report.Add(component, "msps", "", err)
+	}
+
+	if c.TLSEnabled() && c.TLSClientAuthRequired() {
+		report.Add(component, "tls.clientCert", c.TLSClientCertFile(), dryrun.CheckCertificates(c.TLSClientCertFile()))
+		report.Add(component, "tls.clientKey", c.TLSClientKeyFile(), dryrun.CheckPrivateKey(c.TLSClientKeyFile()))
+	}
+
+	peers, err := c.Peers()
+	if err != nil {
+		report.Add(component, "peers", "", errors.Wrapf(err, "failed loading the peers from configuration"))
+	}
+	for _, peer := range peers {
+		checkEndpoint(ctx, component, "peer", peer, opts, report)
+	}
+	if !c.ReadOnly() {
+		orderers, err := c.Orderers()
+		if err != nil {
+			report.Add(component, "orderers", "", errors.Wrapf(err, "failed loading the orderers from configuration"))
+		}
+		for _, orderer := range orderers {
+			checkEndpoint(ctx, component, "orderer", orderer, opts, report)
+		}
+	}
+
+	channels, err := c.Channels()
+	if err != nil {
+		report.Add(component, "channels", "", errors.Wrapf(err, "failed loading the channels from configuration"))
+	}
+	for _, channel := range channels {
+		checkChannel(sp, component, c, channel.Name, cryptoProvider, report)
+	}
+}
+
+// checkEndpoint checks the TLS root certificates of the passed endpoint, if TLS is enabled, and dials it
+func checkEndpoint(ctx context.Context, component, name string, cc *grpc.ConnectionConfig, opts *dryrun.Options, report *dryrun.Report) {
+	if cc.TLSEnabled && len(cc.TLSRootCertFile) != 0 {
+		report.Add(component, name+".tlsRootCert", cc.TLSRootCertFile, dryrun.CheckCertificates(cc.TLSRootCertFile))
+	}
+	report.Add(component, name, cc.Address, dryrun.Dial(ctx, cc.Address, opts))
+}
+
+// checkChannel opens read-only the vault of the passed channel and builds the bundle of the last config transaction
+// it stores, without validating the ones before it
+func checkChannel(sp view2.ServiceProvider, component string, c *config.Config, channel string, cryptoProvider bccsp.BCCSP, report *dryrun.Report) {
+	pType := c.VaultPersistenceType()
+	if pType == "file" {
+		// for retro compatibility
+		pType = "badger"
+	}
+	persistence, err := db.OpenReadOnly(sp, pType, channel, db.NewPrefixConfig(c, c.VaultPersistencePrefix()))
+	if errors.Is(err, os.ErrNotExist) {
+		report.Warn(component, "vault", channel, errors.Errorf("vault of channel [%s] not created yet, the channel syncs from the genesis block", channel))
+		return
+	}
+	if err != nil {
+		report.Add(component, "vault", channel, errors.WithMessagef(err, "failed opening the vault of channel [%s]", channel))
+		return
+	}
+	defer func() {
+		if err := persistence.Close(); err != nil {
+			logger.Warnf("failed closing the vault of channel [%s]: %s", channel, err)
+		}
+	}()
+
+	format, err := vault.DataFormat(db.Unversioned(persistence))
+	switch {
+	case err != nil:
+		report.Add(component, "vault", channel, errors.WithMessagef(err, "failed checking the data format of the vault of channel [%s]", channel))
+		return
+	case format > vault.CurrentDataFormat:
+		report.Add(component, "vault", channel, errors.Errorf("vault of channel [%s] has data format [%d], newer than the format [%d] supported by this version", channel, format, vault.CurrentDataFormat))
+		return
+	case format != 0 && format < vault.CurrentDataFormat && len(c.VaultMigrationBackupPath()) == 0:
+		report.Add(component, "vault", channel, errors.Errorf("vault of channel [%s] has data format [%d], it must be migrated to [%d] on startup, but no backup directory is set in `vault.backup.migrationPath`", channel, format, vault.CurrentDataFormat))
+	case format != 0 && format < vault.CurrentDataFormat:
+		report.Warn(component, "vault", channel, errors.Errorf("vault of channel [%s] has data format [%d], it is migrated to [%d] on startup", channel, format, vault.CurrentDataFormat))
+	default:
+		report.Add(component, "vault", channel, nil)
+	}
+
+	sequence, err := lastConfigSequence(db.Unversioned(persistence))
+	if err != nil {
+		report.Add(component, "config", channel, err)
+		return
+	}
+	if sequence == 0 {
+		report.Warn(component, "config", channel, errors.Errorf("no config transaction stored for channel [%s], the channel syncs from the genesis block", channel))
+		return
+	}
+	report.Add(component, "config", channel, parseStoredConfig(persistence, channel, sequence, cryptoProvider))
+}
+
+// lastConfigSequence returns the sequence of the last valid config transaction recorded in the passed persistence,
+// 0 if none
+func lastConfigSequence(persistence driver.Persistence) (uint64, error) {
+	var last uint64
+	for sequence := uint64(1); ; sequence++ {
+		txID := committer.ConfigTXPrefix + strconv.FormatUint(sequence, 10)
+		vc, err := txidstore.ReadStatus(persistence, txID)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "failed getting tx's status [%s]", txID)
+		}
+		if vc == fdriver.Unknown {
+			return last, nil
+		}
+		if vc != fdriver.Valid {
+			return 0, errors.Errorf("invalid configtx's [%s] status [%d]", txID, vc)
+		}
+		last = sequence
+	}
+}
+
+// parseStoredConfig builds the bundle of the config transaction with the passed sequence stored in the passed persistence
+func parseStoredConfig(persistence driver.VersionedPersistence, channel string, sequence uint64, cryptoProvider bccsp.BCCSP) error {
+	txID := committer.ConfigTXPrefix + strconv.FormatUint(sequence, 10)
+	key, err := rwset.CreateCompositeKey(channelConfigKey, []string{strconv.FormatUint(sequence, 10)})
+	if err != nil {
+		return errors.Wrapf(err, "cannot create configtx rws key")
+	}
+	envelope, _, _, err := persistence.GetState(peerNamespace, key)
+	if err != nil {
+		return errors.Wrapf(err, "failed getting configtx state [%s]", txID)
+	}
+	if len(envelope) == 0 {
+		return errors.Errorf("config transaction [%s] recorded valid but not stored", txID)
+	}
+	env, err := protoutil.UnmarshalEnvelope(envelope)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get payload from config transaction [%s]", txID)
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get payload from config transaction [%s]", txID)
+	}
+	ctx, err := configtx.UnmarshalConfigEnvelope(payload.Data)
+	if err != nil {
+		return errors.Wrapf(err, "error unmarshalling config transaction [%s]", txID)
+	}
+	if ctx.Config == nil || ctx.Config.ChannelGroup == nil {
+		return errors.Errorf("config transaction [%s] without channel group", txID)
+	}
+	bundle, err := newBundle(channel, ctx.Config, cryptoProvider)
+	if err != nil {
+		return errors.Wrapf(err, "failed building the bundle of config transaction [%s]", txID)
+	}
+	if sequence == 1 {
+		// as on reload, the capabilities of the genesis configuration are not checked
+		return nil
+	}
+	return capabilitiesSupported(bundle)
+}
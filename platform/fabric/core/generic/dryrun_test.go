@@ -0,0 +1,153 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/rwset"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/otiai10/copy"
+	"github.com/stretchr/testify/assert"
+)
+
+// dryRunConfig returns the configuration of a network storing its vaults with badger in the passed directory
+func dryRunConfig(t *testing.T, dir string, readOnly bool, msps []config2.MSP, peers, orderers []*grpc.ConnectionConfig, channels []*config2.Channel) *config2.Config {
+	cp := &mock.ConfigProvider{}
+	cp.IsSetStub = func(key string) bool {
+		return key == "fabric.default"
+	}
+	cp.GetStringStub = func(key string) string {
+		switch key {
+		case "fabric.default.vault.persistence.type":
+			return "badger"
+		case "fabric.default.defaultMSP":
+			return "Org1MSP"
+		}
+		return ""
+	}
+	cp.GetBoolStub = func(key string) bool {
+		return key == "fabric.default.readOnly" && readOnly
+	}
+	cp.TranslatePathStub = func(path string) string {
+		return filepath.Join(dir, path)
+	}
+	cp.UnmarshalKeyStub = func(key string, rawVal interface{}) error {
+		switch v := rawVal.(type) {
+		case *badger.Opts:
+			v.Path = filepath.Join(dir, "vaults")
+		case *[]config2.MSP:
+			*v = msps
+		case *[]*grpc.ConnectionConfig:
+			if key == "fabric.default.peers" {
+				*v = peers
+			} else {
+				*v = orderers
+			}
+		case *[]*config2.Channel:
+			*v = channels
+		}
+		return nil
+	}
+	c, err := config2.New(cp, "default", true)
+	assert.NoError(t, err)
+	return c
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, copy.Copy("./msp/x509/testdata/msp", filepath.Join(dir, "msp")))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "nokey", "signcerts"), 0755))
+	assert.NoError(t, copy.Copy("./msp/x509/testdata/msp/signcerts", filepath.Join(dir, "nokey", "signcerts")))
+	cryptoProvider, err := (&factory.SWFactory{}).Get(factory.GetDefaultOpts())
+	assert.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	assert.NoError(t, closed.Close())
+
+	msps := []config2.MSP{
+		{ID: "Org1MSP", MSPType: "bccsp", MSPID: "Org1MSP", Path: "msp"},
+		{ID: "Org2MSP", MSPType: "bccsp", MSPID: "Org2MSP", Path: "missing"},
+		{ID: "Org3MSP", MSPType: "bccsp", MSPID: "Org3MSP", Path: "nokey"},
+		{ID: "Org4MSP", MSPType: "unknown", Path: "msp"},
+	}
+	peers := []*grpc.ConnectionConfig{{Address: l.Addr().String()}, {Address: closed.Addr().String()}}
+	orderers := []*grpc.ConnectionConfig{{Address: closed.Addr().String()}}
+	channels := []*config2.Channel{{Name: "mychannel"}, {Name: "newchannel"}}
+	c := dryRunConfig(t, dir, false, msps, peers, orderers, channels)
+
+	// the vault of mychannel stores a config transaction
+	registry := registry2.New()
+	v, _, err := openVault(registry, c, "default", "mychannel", nil)
+	assert.NoError(t, err)
+	raw := protoutil.MarshalOrPanic(&common.Envelope{Payload: protoutil.MarshalOrPanic(&common.Payload{
+		Header: &common.Header{ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{Type: int32(common.HeaderType_CONFIG)})},
+		Data:   protoutil.MarshalOrPanic(mspConfigEnvelope(t)),
+	})})
+	rws, err := v.NewRWSet("configtx_1")
+	assert.NoError(t, err)
+	key, err := rwset.CreateCompositeKey(channelConfigKey, []string{"1"})
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState(peerNamespace, key, raw))
+	rws.Done()
+	assert.NoError(t, v.CommitTX("configtx_1", 1, 0))
+	assert.NoError(t, v.Close())
+
+	report := dryrun.NewReport()
+	Validate(context.Background(), registry, c, cryptoProvider, &dryrun.Options{DialTimeout: time.Second}, report)
+	assert.False(t, report.OK)
+	statuses := map[string]string{}
+	for _, check := range report.Checks {
+		assert.Equal(t, "fabric.default", check.Component)
+		statuses[check.Name+"/"+check.Target] = check.Status
+	}
+	assert.Equal(t, map[string]string{
+		"msp/Org1MSP":                       dryrun.Passed,
+		"msp/Org2MSP":                       dryrun.Failed,
+		"msp/Org3MSP":                       dryrun.Failed,
+		"msp/Org4MSP":                       dryrun.Warning,
+		"peer/" + l.Addr().String():         dryrun.Passed,
+		"peer/" + closed.Addr().String():    dryrun.Failed,
+		"orderer/" + closed.Addr().String(): dryrun.Failed,
+		"vault/mychannel":                   dryrun.Passed,
+		"config/mychannel":                  dryrun.Passed,
+		"vault/newchannel":                  dryrun.Warning,
+	}, statuses)
+
+	// nothing has been created, the vault written can be opened again
+	_, err = os.Stat(filepath.Join(dir, "vaults", "newchannel"))
+	assert.True(t, os.IsNotExist(err))
+	v, _, err = openVault(registry, c, "default", "mychannel", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, v.Close())
+
+	// the orderers of the read-only networks are not dialed
+	c = dryRunConfig(t, dir, true, msps[:1], peers[:1], orderers, channels[:1])
+	report = dryrun.NewReport()
+	Validate(context.Background(), registry, c, cryptoProvider, &dryrun.Options{DialTimeout: time.Second}, report)
+	assert.True(t, report.OK, "%v", report.Failures())
+	for _, check := range report.Checks {
+		assert.NotEqual(t, "orderer", check.Name)
+	}
+}
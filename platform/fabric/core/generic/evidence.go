@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// EvidenceBundle assembles the evidence that the passed transaction has been committed: its envelope, the block it is
+// committed in, the last configuration block at that block, the headers of the blocks in between, and the attestation
+// of its validation code by the peers. The blocks are pulled from a peer, the headers linking the configuration block
+// require each block in between.
+func (c *channel) EvidenceBundle(txID string) (*driver.Evidence, error) {
+	status, err := c.vault.Status(txID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting the status of [%s]", txID)
+	}
+	block, err := c.blockByTxID(txID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting the block of [%s]", txID)
+	}
+	txNum, env, err := findTransaction(block, txID)
+	if err != nil {
+		return nil, err
+	}
+	lastConfig, err := protoutil.GetLastConfigIndexFromBlock(block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed getting the last config index of block [%d]", block.Header.Number)
+	}
+
+	configBlock := block
+	if lastConfig != block.Header.Number {
+		if configBlock, err = c.block(lastConfig); err != nil {
+			return nil, errors.WithMessagef(err, "failed getting config block [%d]", lastConfig)
+		}
+	}
+	var headers [][]byte
+	for n := lastConfig + 1; n < block.Header.Number; n++ {
+		b, err := c.block(n)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed getting block [%d]", n)
+		}
+		raw, err := proto.Marshal(b.Header)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed marshalling the header of block [%d]", n)
+		}
+		headers = append(headers, raw)
+	}
+
+	attestations, err := c.attest(txID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting the attestation of [%s] by the peers", txID)
+	}
+
+	rawBlock, err := proto.Marshal(block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling block [%d]", block.Header.Number)
+	}
+	rawConfigBlock, err := proto.Marshal(configBlock)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling config block [%d]", configBlock.Header.Number)
+	}
+	logger.Debugf("[%s] evidence of [%s] in block [%d], config block [%d]", c.name, txID, block.Header.Number, lastConfig)
+	return &driver.Evidence{
+		Channel:        c.name,
+		TxID:           txID,
+		TxNum:          txNum,
+		Envelope:       env,
+		Block:          rawBlock,
+		ConfigBlock:    rawConfigBlock,
+		Headers:        headers,
+		ValidationCode: int32(block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER][txNum]),
+		Attestations:   attestations,
+		VaultStatus:    status,
+	}, nil
+}
+
+// responsesQuerier is implemented by the chaincode invocations returning the signed responses of the endorsers
+type responsesQuerier interface {
+	QueryResponses() ([]*peer.ProposalResponse, error)
+}
+
+// attest returns the responses, signed by the peers, to the query of the passed transaction to qscc.
+// Unlike the transactions filter of the block, they authenticate its validation code.
+func (c *channel) attest(txID string) ([][]byte, error) {
+	invocation := c.Chaincode("qscc").NewInvocation(GetTransactionByID, c.name, txID).WithSignerIdentity(
+		c.network.LocalMembership().DefaultIdentity(),
+	).WithEndorsersByConnConfig(c.network.PickPeer())
+	querier, ok := invocation.(responsesQuerier)
+	if !ok {
+		return nil, errors.New("the chaincode invocations do not return the responses of the peers")
+	}
+	responses, err := querier.QueryResponses()
+	if err != nil {
+		return nil, err
+	}
+	var attestations [][]byte
+	for _, resp := range responses {
+		raw, err := proto.Marshal(resp)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed marshalling the response of the peer")
+		}
+		attestations = append(attestations, raw)
+	}
+	return attestations, nil
+}
+
+// findTransaction returns the index and the envelope of the passed transaction in the passed block
+func findTransaction(block *common.Block, txID string) (uint64, []byte, error) {
+	metadata := block.GetMetadata().GetMetadata()
+	if len(metadata) <= int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) || len(metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]) != len(block.GetData().GetData()) {
+		return 0, nil, errors.Errorf("block [%d] has no valid transactions filter", block.GetHeader().GetNumber())
+	}
+	for i, raw := range block.Data.Data {
+		env, err := protoutil.UnmarshalEnvelope(raw)
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "invalid envelope [%d] in block [%d]", i, block.Header.Number)
+		}
+		payload, err := protoutil.UnmarshalPayload(env.Payload)
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "invalid payload [%d] in block [%d]", i, block.Header.Number)
+		}
+		chdr, err := protoutil.UnmarshalChannelHeader(payload.GetHeader().GetChannelHeader())
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "invalid channel header [%d] in block [%d]", i, block.Header.Number)
+		}
+		if chdr.TxId == txID {
+			return uint64(i), raw, nil
+		}
+	}
+	return 0, nil, errors.Errorf("transaction [%s] not found in block [%d]", txID, block.Header.Number)
+}
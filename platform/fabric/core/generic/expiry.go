@@ -0,0 +1,150 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/ordering"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// expiry tells when the transaction of a blob to broadcast has been created, and how long it can be broadcast for
+type expiry struct {
+	txID      string
+	channel   string
+	chaincode string
+	// created is the timestamp of the channel header of the signed proposal, or envelope, of the transaction
+	created time.Time
+	// ttl is the time to live of the transaction itself, 0 if not set
+	ttl time.Duration
+}
+
+// checkExpiry fails with a *driver.ErrTransactionExpired if the passed transaction, or envelope, has been created
+// longer than its time to live ago. The creation time is the timestamp of the signed channel header of the transaction,
+// not the one the transaction records, which a counterparty can set. The time to live is the one of its chaincode or,
+// if not set, the one of the network; the transaction can shorten it, not extend it.
+// The expired transaction is abandoned in the vault of its channel, so that it is not broadcast again, and the waiters
+// of its finality are released. The transactions whose creation time is not known pass.
+func (f *network) checkExpiry(blob interface{}) error {
+	e := expiryOf(blob)
+	if len(e.txID) == 0 || len(e.channel) == 0 || e.created.IsZero() {
+		return nil
+	}
+	ttl := f.chaincodeTTL(e.channel, e.chaincode)
+	if e.ttl > 0 && (ttl <= 0 || e.ttl < ttl) {
+		ttl = e.ttl
+	}
+	if ttl <= 0 || time.Since(e.created) <= ttl {
+		return nil
+	}
+	logger.Warnf("transaction [%s] on channel [%s] created at [%s] is expired [%s], refuse to broadcast it", e.txID, e.channel, e.created, ttl)
+	expired := &driver.ErrTransactionExpired{TxID: e.txID, Channel: e.channel, Created: e.created, TTL: ttl}
+	if err := f.expire(expired); err != nil {
+		logger.Warnf("failed abandoning the expired transaction [%s]: %s", e.txID, err)
+	}
+	return expired
+}
+
+// chaincodeTTL returns the time to live of the transactions of the passed chaincode on the passed channel,
+// the one of the network if the chaincode does not set it
+func (f *network) chaincodeTTL(channel, chaincode string) time.Duration {
+	if len(chaincode) != 0 {
+		for _, chanDef := range f.channelDefs {
+			if chanDef.Name != channel {
+				continue
+			}
+			for _, cc := range chanDef.Chaincodes {
+				if cc.Name == chaincode && cc.TTL > 0 {
+					return cc.TTL
+				}
+			}
+		}
+	}
+	return f.ttl
+}
+
+// expire abandons the passed expired transaction, never broadcast, in the vault of its channel and releases
+// the waiters of its finality with the passed error
+func (f *network) expire(expired *driver.ErrTransactionExpired) error {
+	ch, err := f.Channel(expired.Channel)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting channel [%s]", expired.Channel)
+	}
+	c, ok := ch.(*channel)
+	if !ok {
+		return errors.Errorf("channel [%s] does not support abandoning transactions", expired.Channel)
+	}
+	vc, deps, err := c.Status(expired.TxID)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting the status of [%s]", expired.TxID)
+	}
+	switch vc {
+	case driver.Unknown, driver.HasDependencies:
+		// the vault does not know the transaction yet, record it so that it is not broadcast again
+		if err := c.vault.SetBusy(expired.TxID); err != nil {
+			return errors.WithMessagef(err, "failed recording [%s]", expired.TxID)
+		}
+	case driver.Busy:
+	default:
+		return errors.Errorf("transaction [%s] is not busy, its status is [%d]", expired.TxID, vc)
+	}
+	if err := c.vault.AbandonTx(expired.TxID); err != nil {
+		return errors.WithMessagef(err, "failed abandoning [%s]", expired.TxID)
+	}
+	c.committer.AbandonWithError(expired.TxID, deps, expired)
+	c.notifyTxStatus(expired.TxID, driver.Abandoned)
+	return nil
+}
+
+// expiryOf returns the expiry of the transaction of the passed blob, empty if not readable
+func expiryOf(blob interface{}) expiry {
+	var env *common.Envelope
+	switch b := blob.(type) {
+	case ordering.Transaction:
+		e := expiry{txID: b.ID(), channel: b.Channel()}
+		if cc, ok := b.(interface{ Chaincode() string }); ok {
+			e.chaincode = cc.Chaincode()
+		}
+		if et, ok := b.(driver.ExpirableTransaction); ok {
+			e.ttl = et.TTL()
+		}
+		if b.Proposal() != nil {
+			if hdr, err := protoutil.UnmarshalHeader(b.Proposal().Header()); err == nil {
+				if chHdr, err := protoutil.UnmarshalChannelHeader(hdr.ChannelHeader); err == nil {
+					e.created = timestampOf(chHdr)
+				}
+			}
+		}
+		return e
+	case *transaction.Envelope:
+		env = b.Envelope()
+	case *common.Envelope:
+		env = b
+	}
+	chHdr := envelopeChannelHeader(env)
+	if chHdr == nil {
+		return expiry{}
+	}
+	e := expiry{txID: chHdr.TxId, channel: chHdr.ChannelId, created: timestampOf(chHdr)}
+	if ext, err := protoutil.UnmarshalChaincodeHeaderExtension(chHdr.Extension); err == nil && ext.ChaincodeId != nil {
+		e.chaincode = ext.ChaincodeId.Name
+	}
+	return e
+}
+
+// timestampOf returns the timestamp of the passed channel header, the zero time if not set
+func timestampOf(chHdr *common.ChannelHeader) time.Time {
+	if chHdr.Timestamp == nil {
+		return time.Time{}
+	}
+	return chHdr.Timestamp.AsTime()
+}
@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+	"time"
+
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTimedTxEnvelope(channel, txID, chaincode string, created time.Time) *common.Envelope {
+	payload := &common.Payload{
+		Header: &common.Header{
+			ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{
+				Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+				ChannelId: channel,
+				TxId:      txID,
+				Timestamp: timestamppb.New(created),
+				Extension: protoutil.MarshalOrPanic(&pb.ChaincodeHeaderExtension{ChaincodeId: &pb.ChaincodeID{Name: chaincode}}),
+			}),
+		},
+	}
+	return &common.Envelope{Payload: protoutil.MarshalOrPanic(payload)}
+}
+
+// timedTransaction is a transaction recording its own creation time and time to live, as set by a counterparty
+type timedTransaction struct {
+	id, chaincode string
+	proposal      *common.Header
+	created       time.Time
+	ttl           time.Duration
+}
+
+func (t *timedTransaction) Channel() string                              { return "mychannel" }
+func (t *timedTransaction) ID() string                                   { return t.id }
+func (t *timedTransaction) Creator() view.Identity                       { return nil }
+func (t *timedTransaction) ProposalResponses() []driver.ProposalResponse { return nil }
+func (t *timedTransaction) Bytes() ([]byte, error)                       { return nil, nil }
+func (t *timedTransaction) Chaincode() string                            { return t.chaincode }
+func (t *timedTransaction) Created() time.Time                           { return t.created }
+func (t *timedTransaction) SetTTL(ttl time.Duration)                     { t.ttl = ttl }
+func (t *timedTransaction) TTL() time.Duration                           { return t.ttl }
+func (t *timedTransaction) Proposal() driver.Proposal                    { return t }
+func (t *timedTransaction) Header() []byte                               { return protoutil.MarshalOrPanic(t.proposal) }
+func (t *timedTransaction) Payload() []byte                              { return nil }
+
+func newTimedTransaction(txID, chaincode string, signed, recorded time.Time, ttl time.Duration) *timedTransaction {
+	env := newTimedTxEnvelope("mychannel", txID, chaincode, signed)
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		panic(err)
+	}
+	return &timedTransaction{id: txID, chaincode: chaincode, proposal: payload.Header, created: recorded, ttl: ttl}
+}
+
+func TestExpiredEnvelopes(t *testing.T) {
+	n, _ := newRemovableNetwork(t)
+	ordering := &recordingOrdering{}
+	n.ordering = ordering
+	n.ttl = 5 * time.Minute
+	n.channelDefs = []*config2.Channel{{Name: "mychannel", Chaincodes: []*config2.Chaincode{{Name: "pricing", TTL: time.Minute}}}}
+	ch := openWithState(t, n, "a")
+
+	// tx2 is busy, tx3 is not known to the vault
+	rws, err := ch.vault.NewRWSet("tx2")
+	assert.NoError(t, err)
+	rws.Done()
+	old := time.Now().Add(-10 * time.Minute)
+	for _, txID := range []string{"tx2", "tx3"} {
+		err = n.Broadcast(newTimedTxEnvelope("mychannel", txID, "assets", old))
+		expired := &driver.ErrTransactionExpired{}
+		assert.True(t, errors.As(err, &expired), "%v", err)
+		assert.Equal(t, txID, expired.TxID)
+		assert.Equal(t, "mychannel", expired.Channel)
+		assert.Equal(t, 5*time.Minute, expired.TTL)
+		assert.True(t, errors.Is(err, driver.ErrTransactionAbandoned))
+
+		// the expired transactions are abandoned, they are not broadcast again
+		vc, _, err := ch.Status(txID)
+		assert.NoError(t, err)
+		assert.Equal(t, driver.Abandoned, vc)
+		err = n.Broadcast(newTimedTxEnvelope("mychannel", txID, "assets", time.Now()))
+		replayed := &driver.ErrReplayedTxID{}
+		assert.True(t, errors.As(err, &replayed))
+	}
+	assert.Empty(t, ordering.broadcast)
+
+	// the time to live of the chaincode overrides the one of the network
+	err = n.Broadcast(newTimedTxEnvelope("mychannel", "tx4", "pricing", time.Now().Add(-2*time.Minute)))
+	expired := &driver.ErrTransactionExpired{}
+	assert.True(t, errors.As(err, &expired))
+	assert.Equal(t, time.Minute, expired.TTL)
+
+	// the fresh transactions, and the ones whose creation is not known, pass
+	assert.NoError(t, n.Broadcast(newTimedTxEnvelope("mychannel", "tx5", "assets", time.Now().Add(-2*time.Minute))))
+	assert.NoError(t, n.Broadcast(newTxEnvelope("mychannel", "tx6")))
+	assert.Len(t, ordering.broadcast, 2)
+
+	// without a time to live, nothing expires
+	n.ttl = 0
+	assert.NoError(t, n.Broadcast(newTimedTxEnvelope("mychannel", "tx7", "assets", old)))
+	assert.Len(t, ordering.broadcast, 3)
+
+	// the transactions are timed by their signed proposal, and their own time to live cannot extend the one of their chaincode
+	n.ttl = 5 * time.Minute
+	err = n.checkExpiry(newTimedTransaction("tx8", "assets", old, time.Now(), 0))
+	assert.True(t, errors.As(err, &expired))
+	assert.Equal(t, "tx8", expired.TxID)
+	err = n.checkExpiry(newTimedTransaction("tx9", "pricing", time.Now().Add(-2*time.Minute), time.Now(), time.Hour))
+	assert.True(t, errors.As(err, &expired))
+	assert.Equal(t, time.Minute, expired.TTL)
+	err = n.checkExpiry(newTimedTransaction("tx10", "assets", time.Now().Add(-2*time.Minute), time.Now(), time.Minute))
+	assert.True(t, errors.As(err, &expired))
+	assert.Equal(t, time.Minute, expired.TTL)
+	assert.NoError(t, n.checkExpiry(newTimedTransaction("tx11", "assets", time.Now().Add(-2*time.Minute), old, 0)))
+	assert.NoError(t, ch.Close())
+}
@@ -0,0 +1,109 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabrictest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// Behavior is the way a fake endpoint handles a request: an envelope broadcast, a deliver stream, or a proposal
+type Behavior struct {
+	// Status is the status of the response, SUCCESS if not set. Any other status rejects the request:
+	// the broadcasts are answered with it, the deliver streams send it in place of the blocks,
+	// the proposals are answered with an error response.
+	Status common.Status
+	// Delay is the time waited before each response, and before each block of the deliver streams
+	Delay time.Duration
+	// Drop breaks the stream, or fails the call, with an unavailable error in place of responding.
+	// The deliver streams are dropped after DropAfter blocks.
+	Drop bool
+	// DropAfter is the number of blocks a deliver stream sends before being dropped
+	DropAfter int
+}
+
+// Accept returns the behavior accepting the requests
+func Accept() Behavior {
+	return Behavior{}
+}
+
+// Reject returns the behavior rejecting the requests with the passed status
+func Reject(status common.Status) Behavior {
+	return Behavior{Status: status}
+}
+
+// Delay returns the behavior accepting the requests after the passed delay
+func Delay(delay time.Duration) Behavior {
+	return Behavior{Delay: delay}
+}
+
+// Drop returns the behavior breaking the streams, and failing the calls, before any response
+func Drop() Behavior {
+	return Behavior{Drop: true}
+}
+
+// DropAfter returns the behavior breaking the deliver streams once they sent the passed number of blocks
+func DropAfter(blocks int) Behavior {
+	return Behavior{Drop: true, DropAfter: blocks}
+}
+
+func (b Behavior) rejects() bool {
+	return b.Status != common.Status_UNKNOWN && b.Status != common.Status_SUCCESS
+}
+
+func (b Behavior) wait() {
+	if b.Delay > 0 {
+		time.Sleep(b.Delay)
+	}
+}
+
+// Script is the sequence of behaviors of a fake service: each request takes the next behavior,
+// the default one once the sequence is exhausted
+type Script struct {
+	lock      sync.Mutex
+	next      []Behavior
+	byDefault Behavior
+	requests  int
+}
+
+// Then appends the passed behaviors to the sequence
+func (s *Script) Then(behaviors ...Behavior) *Script {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.next = append(s.next, behaviors...)
+	return s
+}
+
+// Default sets the behavior of the requests once the sequence is exhausted, Accept if not set
+func (s *Script) Default(behavior Behavior) *Script {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.byDefault = behavior
+	return s
+}
+
+// Requests returns the number of requests handled so far
+func (s *Script) Requests() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+// take returns the behavior of the next request
+func (s *Script) take() Behavior {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests++
+	if len(s.next) == 0 {
+		return s.byDefault
+	}
+	b := s.next[0]
+	s.next = s.next[1:]
+	return b
+}
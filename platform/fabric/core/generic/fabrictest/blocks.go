@@ -0,0 +1,198 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabrictest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// BlockSource is the programmable sequence of blocks the fake peers and orderers deliver.
+// The blocks are delivered in the order they are appended, whatever their numbers, so that the tests
+// can feed gaps, duplicates or malformed blocks.
+type BlockSource struct {
+	lock   sync.Mutex
+	blocks []*common.Block
+	// appended is closed, and replaced, each time blocks are appended
+	appended chan struct{}
+}
+
+// NewBlockSource returns a source delivering the passed blocks
+func NewBlockSource(blocks ...*common.Block) *BlockSource {
+	return &BlockSource{blocks: blocks, appended: make(chan struct{})}
+}
+
+// Append appends the passed blocks, the streams waiting for new blocks deliver them
+func (s *BlockSource) Append(blocks ...*common.Block) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.blocks = append(s.blocks, blocks...)
+	close(s.appended)
+	s.appended = make(chan struct{})
+}
+
+// AppendTransactions appends, and returns, a block holding the passed envelopes, linked to the last block
+func (s *BlockSource) AppendTransactions(envelopes ...*common.Envelope) *common.Block {
+	s.lock.Lock()
+	number, previous := s.next()
+	s.lock.Unlock()
+	block := NewBlock(number, previous, envelopes...)
+	s.Append(block)
+	return block
+}
+
+// AppendConfig appends, and returns, a config block holding the passed configuration, linked to the last block
+func (s *BlockSource) AppendConfig(channel string, config *common.Config) (*common.Block, error) {
+	s.lock.Lock()
+	number, previous := s.next()
+	s.lock.Unlock()
+	block, err := NewConfigBlock(channel, number, previous, config)
+	if err != nil {
+		return nil, err
+	}
+	s.Append(block)
+	return block, nil
+}
+
+// Blocks returns the blocks appended so far
+func (s *BlockSource) Blocks() []*common.Block {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*common.Block{}, s.blocks...)
+}
+
+// next returns the number of, and the hash linking, the block following the last one
+func (s *BlockSource) next() (uint64, []byte) {
+	if len(s.blocks) == 0 {
+		return 0, nil
+	}
+	last := s.blocks[len(s.blocks)-1]
+	return last.Header.Number + 1, protoutil.BlockHeaderHash(last.Header)
+}
+
+// start returns the index of the first block to deliver from the passed position:
+// the first block whose number is not lower than the one specified
+func (s *BlockSource) start(position *ab.SeekPosition) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch p := position.GetType().(type) {
+	case *ab.SeekPosition_Newest:
+		if len(s.blocks) == 0 {
+			return 0
+		}
+		return len(s.blocks) - 1
+	case *ab.SeekPosition_Specified:
+		for i, block := range s.blocks {
+			if block.GetHeader().GetNumber() >= p.Specified.Number {
+				return i
+			}
+		}
+		return len(s.blocks)
+	default:
+		return 0
+	}
+}
+
+// wait returns the block at the passed index, waiting for it to be appended if block is true,
+// nil if it does not exist and block is false
+func (s *BlockSource) wait(ctx context.Context, index int, block bool) (*common.Block, error) {
+	for {
+		s.lock.Lock()
+		if index < len(s.blocks) {
+			b := s.blocks[index]
+			s.lock.Unlock()
+			return b, nil
+		}
+		appended := s.appended
+		s.lock.Unlock()
+		if !block {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-appended:
+		}
+	}
+}
+
+// NewBlock returns a block holding the passed envelopes, all marked valid
+func NewBlock(number uint64, previousHash []byte, envelopes ...*common.Envelope) *common.Block {
+	block := protoutil.NewBlock(number, previousHash)
+	for _, env := range envelopes {
+		block.Data.Data = append(block.Data.Data, protoutil.MarshalOrPanic(env))
+	}
+	block.Header.DataHash = protoutil.BlockDataHash(block.Data)
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = make([]byte, len(envelopes))
+	return block
+}
+
+// NewConfigBlock returns a config block holding the passed configuration of the passed channel
+func NewConfigBlock(channel string, number uint64, previousHash []byte, config *common.Config) (*common.Block, error) {
+	data, err := proto.Marshal(&common.ConfigEnvelope{Config: config})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed marshalling config envelope")
+	}
+	env, err := newEnvelope(common.HeaderType_CONFIG, channel, "", data)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlock(number, previousHash, env), nil
+}
+
+// NewEnvelope returns an unsigned endorser transaction with the passed id, enough for the fakes and the parsing of
+// the channel header
+func NewEnvelope(channel, txID string) *common.Envelope {
+	env, err := newEnvelope(common.HeaderType_ENDORSER_TRANSACTION, channel, txID, nil)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+func newEnvelope(typ common.HeaderType, channel, txID string, data []byte) (*common.Envelope, error) {
+	chdr := protoutil.MakeChannelHeader(typ, 0, channel, 0)
+	chdr.TxId = txID
+	payload, err := proto.Marshal(&common.Payload{
+		Header: protoutil.MakePayloadHeader(chdr, &common.SignatureHeader{}),
+		Data:   data,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed marshalling payload")
+	}
+	return &common.Envelope{Payload: payload}, nil
+}
+
+// filter returns the filtered version of the passed block
+func filter(block *common.Block) (*pb.FilteredBlock, error) {
+	fb := &pb.FilteredBlock{Number: block.GetHeader().GetNumber()}
+	codes := block.GetMetadata().GetMetadata()[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	for i := range block.GetData().GetData() {
+		env, err := protoutil.ExtractEnvelope(block, i)
+		if err != nil {
+			return nil, err
+		}
+		chdr, err := protoutil.ChannelHeader(env)
+		if err != nil {
+			return nil, err
+		}
+		fb.ChannelId = chdr.ChannelId
+		ft := &pb.FilteredTransaction{Txid: chdr.TxId, Type: common.HeaderType(chdr.Type)}
+		if i < len(codes) {
+			ft.TxValidationCode = pb.TxValidationCode(codes[i])
+		}
+		fb.FilteredTransactions = append(fb.FilteredTransactions, ft)
+	}
+	return fb, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabrictest
+
+import (
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/peer"
+	common2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/peer/common"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/pkg/errors"
+)
+
+// Endpoint is a fake peer or orderer
+type Endpoint interface {
+	ConnectionConfig() *grpc.ConnectionConfig
+}
+
+// NewPeerClient returns a client of the peer at the passed connection, as the channels create them
+func NewPeerClient(cc *grpc.ConnectionConfig) (peer.Client, error) {
+	client, err := grpc.CreateGRPCClient(cc)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed creating client to [%s]", cc.Address)
+	}
+	return &common2.PeerClient{
+		CommonClient: common2.CommonClient{
+			Client:  client,
+			Address: cc.Address,
+			Sn:      cc.ServerNameOverride,
+		},
+	}, nil
+}
+
+// Channel is a channel whose peer clients connect to the passed configurations with NewPeerClient.
+// The other methods of driver.Channel are the ones of the embedded channel, if any.
+type Channel struct {
+	driver.Channel
+	ChannelName string
+}
+
+func (c *Channel) Name() string {
+	return c.ChannelName
+}
+
+func (c *Channel) NewPeerClientForAddress(cc grpc.ConnectionConfig) (peer.Client, error) {
+	return NewPeerClient(&cc)
+}
+
+// RoundRobin picks the connections of the passed endpoints in turn, as the networks pick their peers and orderers
+type RoundRobin struct {
+	lock      sync.Mutex
+	endpoints []Endpoint
+	next      int
+}
+
+// NewRoundRobin returns a picker starting with the first of the passed endpoints
+func NewRoundRobin(endpoints ...Endpoint) *RoundRobin {
+	return &RoundRobin{endpoints: endpoints}
+}
+
+// Pick returns the connection to the next endpoint, nil if there is none
+func (r *RoundRobin) Pick() *grpc.ConnectionConfig {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.endpoints) == 0 {
+		return nil
+	}
+	e := r.endpoints[r.next%len(r.endpoints)]
+	r.next++
+	return e.ConnectionConfig()
+}
@@ -0,0 +1,187 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabrictest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func seekEnvelope(t *testing.T, start, stop *ab.SeekPosition) *common.Envelope {
+	data, err := proto.Marshal(&ab.SeekInfo{Start: start, Stop: stop, Behavior: ab.SeekInfo_BLOCK_UNTIL_READY})
+	assert.NoError(t, err)
+	env, err := newEnvelope(common.HeaderType_DELIVER_SEEK_INFO, "mychannel", "", data)
+	assert.NoError(t, err)
+	return env
+}
+
+func specified(number uint64) *ab.SeekPosition {
+	return &ab.SeekPosition{Type: &ab.SeekPosition_Specified{Specified: &ab.SeekSpecified{Number: number}}}
+}
+
+func oldest() *ab.SeekPosition {
+	return &ab.SeekPosition{Type: &ab.SeekPosition_Oldest{Oldest: &ab.SeekOldest{}}}
+}
+
+func TestOrdererBroadcast(t *testing.T) {
+	blocks := NewBlockSource()
+	o, err := NewOrderer(blocks)
+	assert.NoError(t, err)
+	defer o.Stop()
+	o.Broadcasts.Then(Reject(common.Status_SERVICE_UNAVAILABLE), Delay(50*time.Millisecond), Drop())
+
+	client, err := NewPeerClient(o.ConnectionConfig())
+	assert.NoError(t, err)
+	defer client.Close()
+	conn, err := client.Connection()
+	assert.NoError(t, err)
+	stream, err := ab.NewAtomicBroadcastClient(conn).Broadcast(context.Background())
+	assert.NoError(t, err)
+
+	assert.NoError(t, stream.Send(NewEnvelope("mychannel", "tx1")))
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, common.Status_SERVICE_UNAVAILABLE, resp.Status)
+
+	start := time.Now()
+	assert.NoError(t, stream.Send(NewEnvelope("mychannel", "tx2")))
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, common.Status_SUCCESS, resp.Status)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	assert.NoError(t, stream.Send(NewEnvelope("mychannel", "tx3")))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Len(t, o.Received(), 3)
+	assert.Equal(t, 3, o.Broadcasts.Requests())
+
+	// the envelope accepted is cut in a block
+	assert.Len(t, blocks.Blocks(), 1)
+	chdr, err := protoutil.ChannelHeader(protoutil.ExtractEnvelopeOrPanic(blocks.Blocks()[0], 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "tx2", chdr.TxId)
+}
+
+func TestPeerDeliver(t *testing.T) {
+	blocks := NewBlockSource()
+	blocks.AppendTransactions(NewEnvelope("mychannel", "tx0"))
+	blocks.AppendTransactions(NewEnvelope("mychannel", "tx1"))
+	config, err := blocks.AppendConfig("mychannel", &common.Config{Sequence: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), config.Header.Number)
+	assert.Equal(t, protoutil.BlockHeaderHash(blocks.Blocks()[1].Header), config.Header.PreviousHash)
+
+	p, err := NewPeer(blocks)
+	assert.NoError(t, err)
+	defer p.Stop()
+	p.Deliveries.Then(DropAfter(2), Reject(common.Status_FORBIDDEN))
+
+	client, err := NewPeerClient(p.ConnectionConfig())
+	assert.NoError(t, err)
+	defer client.Close()
+	dc, err := client.DeliverClient()
+	assert.NoError(t, err)
+	open := func(start, stop *ab.SeekPosition) pb.Deliver_DeliverClient {
+		stream, err := dc.Deliver(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(seekEnvelope(t, start, stop)))
+		return stream
+	}
+
+	// the stream is dropped after two blocks
+	stream := open(oldest(), specified(10))
+	for i := uint64(0); i < 2; i++ {
+		resp, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, i, resp.GetBlock().Header.Number)
+	}
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// the stream is rejected
+	resp, err := open(oldest(), specified(10)).Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, common.Status_FORBIDDEN, resp.GetStatus())
+
+	// the stream waits for the blocks appended
+	stream = open(specified(2), specified(3))
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), resp.GetBlock().Header.Number)
+	env := protoutil.ExtractEnvelopeOrPanic(resp.GetBlock(), 0)
+	chdr, err := protoutil.ChannelHeader(env)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(common.HeaderType_CONFIG), chdr.Type)
+	blocks.AppendTransactions(NewEnvelope("mychannel", "tx3"))
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), resp.GetBlock().Header.Number)
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, common.Status_SUCCESS, resp.GetStatus())
+
+	// the filtered streams carry the transaction ids
+	filtered, err := dc.DeliverFiltered(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, filtered.Send(seekEnvelope(t, specified(1), specified(1))))
+	fresp, err := filtered.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "tx1", fresp.GetFilteredBlock().FilteredTransactions[0].Txid)
+	assert.Equal(t, pb.TxValidationCode_VALID, fresp.GetFilteredBlock().FilteredTransactions[0].TxValidationCode)
+}
+
+func TestPeerEndorse(t *testing.T) {
+	p, err := NewPeer(nil)
+	assert.NoError(t, err)
+	defer p.Stop()
+	p.Endorsements.Then(Reject(common.Status_BAD_REQUEST), Drop())
+	p.Endorse(func(proposal *pb.SignedProposal) (*pb.ProposalResponse, error) {
+		return &pb.ProposalResponse{Response: &pb.Response{Status: 200, Payload: []byte("endorsed")}}, nil
+	})
+
+	client, err := NewPeerClient(p.ConnectionConfig())
+	assert.NoError(t, err)
+	defer client.Close()
+	ec, err := client.Endorser()
+	assert.NoError(t, err)
+
+	resp, err := ec.ProcessProposal(context.Background(), &pb.SignedProposal{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(500), resp.Response.Status)
+	_, err = ec.ProcessProposal(context.Background(), &pb.SignedProposal{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	resp, err = ec.ProcessProposal(context.Background(), &pb.SignedProposal{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("endorsed"), resp.Response.Payload)
+	assert.Len(t, p.Proposals(), 3)
+}
+
+func TestRoundRobin(t *testing.T) {
+	p1, err := NewPeer(nil)
+	assert.NoError(t, err)
+	defer p1.Stop()
+	p2, err := NewPeer(nil)
+	assert.NoError(t, err)
+	defer p2.Stop()
+
+	r := NewRoundRobin(p1, p2)
+	assert.Equal(t, p1.Address(), r.Pick().Address)
+	assert.Equal(t, p2.Address(), r.Pick().Address)
+	assert.Equal(t, p1.Address(), r.Pick().Address)
+	assert.Nil(t, NewRoundRobin().Pick())
+}
@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabrictest
+
+import (
+	"io"
+	"sync"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+)
+
+// Orderer is an in-process fake of the AtomicBroadcast service of an orderer.
+// The envelopes broadcast are handled by the behaviors of Broadcasts, and the deliver streams by the ones of
+// Deliveries. If the orderer has a block source, it cuts a block per envelope accepted.
+type Orderer struct {
+	*server
+	// Broadcasts scripts the handling of the envelopes broadcast, each envelope takes a behavior
+	Broadcasts *Script
+	// Deliveries scripts the handling of the deliver streams, each stream takes a behavior
+	Deliveries *Script
+
+	blocks   *BlockSource
+	lock     sync.Mutex
+	received []*common.Envelope
+}
+
+// NewOrderer starts a fake orderer delivering, and appending the envelopes accepted to, the passed blocks, if not nil
+func NewOrderer(blocks *BlockSource) (*Orderer, error) {
+	s, err := newServer()
+	if err != nil {
+		return nil, err
+	}
+	o := &Orderer{server: s, Broadcasts: &Script{}, Deliveries: &Script{}, blocks: blocks}
+	ab.RegisterAtomicBroadcastServer(s.server.Server(), o)
+	s.start()
+	return o, nil
+}
+
+// Received returns the envelopes received, accepted or not
+func (o *Orderer) Received() []*common.Envelope {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return append([]*common.Envelope{}, o.received...)
+}
+
+func (o *Orderer) Broadcast(stream ab.AtomicBroadcast_BroadcastServer) error {
+	for {
+		env, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		o.lock.Lock()
+		o.received = append(o.received, env)
+		o.lock.Unlock()
+
+		behavior := o.Broadcasts.take()
+		behavior.wait()
+		if behavior.Drop {
+			return errDropped
+		}
+		if behavior.rejects() {
+			if err := stream.Send(&ab.BroadcastResponse{Status: behavior.Status, Info: "rejected by the fake"}); err != nil {
+				return err
+			}
+			continue
+		}
+		if o.blocks != nil {
+			o.blocks.AppendTransactions(env)
+		}
+		if err := stream.Send(&ab.BroadcastResponse{Status: common.Status_SUCCESS}); err != nil {
+			return err
+		}
+	}
+}
+
+func (o *Orderer) Deliver(stream ab.AtomicBroadcast_DeliverServer) error {
+	blocks := o.blocks
+	if blocks == nil {
+		blocks = NewBlockSource()
+	}
+	return deliver(o.Deliveries, blocks, &ordererStream{stream})
+}
+
+type ordererStream struct {
+	ab.AtomicBroadcast_DeliverServer
+}
+
+func (s *ordererStream) SendBlock(block *common.Block) error {
+	return s.Send(&ab.DeliverResponse{Type: &ab.DeliverResponse_Block{Block: block}})
+}
+
+func (s *ordererStream) SendStatus(status common.Status) error {
+	return s.Send(&ab.DeliverResponse{Type: &ab.DeliverResponse_Status{Status: status}})
+}
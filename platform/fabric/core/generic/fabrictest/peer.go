@@ -0,0 +1,126 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabrictest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	grpc2 "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EndorseFunc answers a proposal accepted by a fake peer
+type EndorseFunc func(proposal *pb.SignedProposal) (*pb.ProposalResponse, error)
+
+// Peer is an in-process fake of the Deliver and Endorser services of a peer.
+// The deliver streams, filtered or not, serve the blocks of the source of the peer and are handled by the behaviors
+// of Deliveries. The proposals are handled by the behaviors of Endorsements, and answered by Endorse once accepted.
+type Peer struct {
+	*server
+	// Deliveries scripts the handling of the deliver streams, each stream takes a behavior
+	Deliveries *Script
+	// Endorsements scripts the handling of the proposals, each proposal takes a behavior
+	Endorsements *Script
+
+	blocks    *BlockSource
+	lock      sync.Mutex
+	endorse   EndorseFunc
+	proposals []*pb.SignedProposal
+}
+
+// NewPeer starts a fake peer delivering the passed blocks
+func NewPeer(blocks *BlockSource) (*Peer, error) {
+	s, err := newServer()
+	if err != nil {
+		return nil, err
+	}
+	if blocks == nil {
+		blocks = NewBlockSource()
+	}
+	p := &Peer{server: s, Deliveries: &Script{}, Endorsements: &Script{}, blocks: blocks}
+	pb.RegisterDeliverServer(s.server.Server(), p)
+	pb.RegisterEndorserServer(s.server.Server(), p)
+	s.start()
+	return p, nil
+}
+
+// Endorse sets the function answering the proposals accepted, by default they are answered with an empty
+// response of status 200
+func (p *Peer) Endorse(endorse EndorseFunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.endorse = endorse
+}
+
+// Proposals returns the proposals received, accepted or not
+func (p *Peer) Proposals() []*pb.SignedProposal {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]*pb.SignedProposal{}, p.proposals...)
+}
+
+func (p *Peer) ProcessProposal(ctx context.Context, proposal *pb.SignedProposal) (*pb.ProposalResponse, error) {
+	p.lock.Lock()
+	p.proposals = append(p.proposals, proposal)
+	endorse := p.endorse
+	p.lock.Unlock()
+
+	behavior := p.Endorsements.take()
+	behavior.wait()
+	if behavior.Drop {
+		return nil, errDropped
+	}
+	if behavior.rejects() {
+		return &pb.ProposalResponse{Response: &pb.Response{Status: 500, Message: behavior.Status.String()}}, nil
+	}
+	if endorse == nil {
+		return &pb.ProposalResponse{Response: &pb.Response{Status: 200}}, nil
+	}
+	return endorse(proposal)
+}
+
+func (p *Peer) Deliver(stream pb.Deliver_DeliverServer) error {
+	return deliver(p.Deliveries, p.blocks, &peerStream{ServerStream: stream, recv: stream.Recv, send: stream.Send})
+}
+
+func (p *Peer) DeliverFiltered(stream pb.Deliver_DeliverFilteredServer) error {
+	return deliver(p.Deliveries, p.blocks, &peerStream{ServerStream: stream, recv: stream.Recv, send: stream.Send, filtered: true})
+}
+
+func (p *Peer) DeliverWithPrivateData(stream pb.Deliver_DeliverWithPrivateDataServer) error {
+	return status.Error(codes.Unimplemented, "private data not supported by the fake")
+}
+
+type peerStream struct {
+	grpc2.ServerStream
+	recv     func() (*common.Envelope, error)
+	send     func(*pb.DeliverResponse) error
+	filtered bool
+}
+
+func (s *peerStream) Recv() (*common.Envelope, error) {
+	return s.recv()
+}
+
+func (s *peerStream) SendBlock(block *common.Block) error {
+	if !s.filtered {
+		return s.send(&pb.DeliverResponse{Type: &pb.DeliverResponse_Block{Block: block}})
+	}
+	fb, err := filter(block)
+	if err != nil {
+		return err
+	}
+	return s.send(&pb.DeliverResponse{Type: &pb.DeliverResponse_FilteredBlock{FilteredBlock: fb}})
+}
+
+func (s *peerStream) SendStatus(status common.Status) error {
+	return s.send(&pb.DeliverResponse{Type: &pb.DeliverResponse_Status{Status: status}})
+}
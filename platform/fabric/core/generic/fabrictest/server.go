@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabrictest
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric-protos-go/common"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.fabrictest")
+
+// errDropped is the error breaking the streams and failing the calls of the behaviors dropping them
+var errDropped = status.Error(codes.Unavailable, "dropped by the fake")
+
+// server is the gRPC server of a fake endpoint, listening on a local port without TLS
+type server struct {
+	server *grpc.GRPCServer
+}
+
+func newServer() (*server, error) {
+	s, err := grpc.NewGRPCServer("127.0.0.1:0", grpc.ServerConfig{KaOpts: grpc.DefaultKeepaliveOptions})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating grpc server")
+	}
+	return &server{server: s}, nil
+}
+
+func (s *server) start() {
+	go func() {
+		if err := s.server.Start(); err != nil {
+			logger.Debugf("fake server at [%s] stopped [%s]", s.Address(), err)
+		}
+	}()
+}
+
+// Address returns the address the fake listens to
+func (s *server) Address() string {
+	return s.server.Address()
+}
+
+// ConnectionConfig returns the configuration connecting to the fake, as the ones of the peers and orderers
+// of the networks
+func (s *server) ConnectionConfig() *grpc.ConnectionConfig {
+	return &grpc.ConnectionConfig{Address: s.Address(), ConnectionTimeout: time.Second}
+}
+
+// Stop stops the fake, breaking its streams
+func (s *server) Stop() {
+	s.server.Stop()
+}
+
+// deliverStream abstracts the deliver streams of the orderers and the peers
+type deliverStream interface {
+	Context() context.Context
+	Recv() (*common.Envelope, error)
+	SendBlock(block *common.Block) error
+	SendStatus(status common.Status) error
+}
+
+// deliver serves the blocks of the passed source requested by the seek envelope of the passed stream
+func deliver(script *Script, blocks *BlockSource, stream deliverStream) error {
+	env, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	seekInfo, err := unmarshalSeekInfo(env)
+	if err != nil {
+		return stream.SendStatus(common.Status_BAD_REQUEST)
+	}
+	behavior := script.take()
+	if behavior.Drop && behavior.DropAfter == 0 {
+		behavior.wait()
+		return errDropped
+	}
+	if behavior.rejects() {
+		behavior.wait()
+		return stream.SendStatus(behavior.Status)
+	}
+
+	stop := uint64(math.MaxUint64)
+	if specified, ok := seekInfo.Stop.GetType().(*ab.SeekPosition_Specified); ok {
+		stop = specified.Specified.Number
+	}
+	sent := 0
+	for i := blocks.start(seekInfo.Start); ; i++ {
+		block, err := blocks.wait(stream.Context(), i, seekInfo.Behavior == ab.SeekInfo_BLOCK_UNTIL_READY)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			return stream.SendStatus(common.Status_NOT_FOUND)
+		}
+		if block.GetHeader().GetNumber() > stop {
+			return stream.SendStatus(common.Status_SUCCESS)
+		}
+		if behavior.Drop && sent == behavior.DropAfter {
+			return errDropped
+		}
+		behavior.wait()
+		if err := stream.SendBlock(block); err != nil {
+			return err
+		}
+		sent++
+		if block.GetHeader().GetNumber() == stop {
+			return stream.SendStatus(common.Status_SUCCESS)
+		}
+	}
+}
+
+func unmarshalSeekInfo(env *common.Envelope) (*ab.SeekInfo, error) {
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return nil, err
+	}
+	seekInfo := &ab.SeekInfo{}
+	if err := proto.Unmarshal(payload.Data, seekInfo); err != nil {
+		return nil, errors.Wrap(err, "failed unmarshalling seek info")
+	}
+	return seekInfo, nil
+}
@@ -12,13 +12,31 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
 
+// IsFinal waits for the finality of the passed transaction, the wait is accounted to the ordering phase of the flow
+// running in the passed context, if any, and tracked for its introspection
 func (c *channel) IsFinal(ctx context.Context, txID string) error {
+	defer view.TrackPhase(ctx, view.PhaseOrdering)()
+	defer view.TrackBlocking(ctx, view.BlockedOnFinality, txID)()
+	defer c.acquire()()
+	if err := c.use(); err != nil {
+		return err
+	}
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if c.network.admission != nil {
+		if original, ok := c.network.admission.Original(c.name, txID); ok {
+			logger.Debugf("transaction [%s] joined [%s], wait for its finality", txID, original)
+			txID = original
+		}
+	}
 	return c.finality.IsFinal(ctx, txID)
 }
 
 func (c *channel) IsFinalForParties(txID string, parties ...view.Identity) error {
+	defer c.acquire()()
+	if err := c.use(); err != nil {
+		return err
+	}
 	return c.finality.IsFinalForParties(txID, parties...)
 }
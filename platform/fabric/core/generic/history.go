@@ -0,0 +1,19 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// NewQueryExecutorAt returns a query executor reading the vault of this channel at a past height, see vault.Vault#NewQueryExecutorAt
+func (c *channel) NewQueryExecutorAt(height uint64) (driver.QueryExecutor, error) {
+	return c.vault.NewQueryExecutorAt(height)
+}
+
+// PruneHistory drops the versions of the passed namespace not needed to read it at the passed height or after
+func (c *channel) PruneHistory(namespace string, horizon uint64) error {
+	return c.vault.PruneHistory(namespace, horizon)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// rederiveAttempts is the number of times a namespace is re-derived while the vault moves on
+const rederiveAttempts = 3
+
+// verifyIntegrity verifies the namespaces of the vault against their checksum and logs those corrupted.
+// If repair is configured, the corrupted namespaces are re-derived from the blocks of the peers, see RederiveNamespace.
+// Otherwise they are to be re-derived by the admin API, or replicated from another node.
+func (c *channel) verifyIntegrity() {
+	report, err := c.vault.VerifyIntegrity()
+	if err != nil {
+		logger.Errorf("failed verifying the integrity of vault [%s:%s]: [%s]", c.network.Name(), c.name, err)
+		return
+	}
+	if len(report.Failed) == 0 {
+		logger.Infof("vault [%s:%s]: [%d] namespaces verified", c.network.Name(), c.name, len(report.Namespaces))
+		return
+	}
+	if !c.config.VaultIntegrityRepair() {
+		logger.Errorf("vault [%s:%s] has [%d] corrupted namespaces: [%s], re-derive them from the peers or from another node",
+			c.network.Name(), c.name, len(report.Failed), strings.Join(report.Failed, ","))
+		return
+	}
+	logger.Errorf("vault [%s:%s] has [%d] corrupted namespaces: [%s], re-derive them from the peers",
+		c.network.Name(), c.name, len(report.Failed), strings.Join(report.Failed, ","))
+	for _, ns := range report.Failed {
+		integrity, err := c.RederiveNamespace(ns)
+		if err != nil {
+			logger.Errorf("failed re-deriving namespace [%s] of vault [%s:%s]: [%s]", ns, c.network.Name(), c.name, err)
+			continue
+		}
+		logger.Infof("namespace [%s] of vault [%s:%s] re-derived: [%d] keys, [%s]", ns, c.network.Name(), c.name, integrity.Keys, integrity.Status)
+	}
+}
+
+// verifyIntegrityEvery verifies in the background the namespaces of the vault once every the configured number
+// of blocks, the passed block is the one just committed. A verification still running is not overlapped.
+func (c *channel) verifyIntegrityEvery(block uint64) {
+	every := c.config.VaultIntegrityVerifyEvery()
+	if every == 0 || block%every != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&c.verifying, 0, 1) {
+		logger.Debugf("vault [%s:%s]: integrity verification still running at block [%d], skipped", c.network.Name(), c.name, block)
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.verifying, 0)
+		c.verifyIntegrity()
+	}()
+}
+
+// RederiveNamespace re-derives the passed namespace of the vault of this channel from the blocks of the peers, up to
+// the height of the vault: its states are replaced by the writes of the valid transactions the vault committed,
+// and its checksum is recomputed from them, see vault.Vault#RederiveNamespace.
+// The blocks committed meanwhile are fetched too, the namespace is re-derived again.
+// The writes of the transactions committed locally, rather than delivered by a block, are not re-derived.
+func (c *channel) RederiveNamespace(namespace string) (*driver.NamespaceIntegrity, error) {
+	states := map[string]driver.StateWrite{}
+	next := uint64(0)
+	for attempt := 1; ; attempt++ {
+		height, err := c.vault.Height()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed getting vault height")
+		}
+		for ; next <= height; next++ {
+			block, err := c.block(next)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed fetching block [%d]", next)
+			}
+			if err := c.rederiveBlock(namespace, block, states); err != nil {
+				return nil, err
+			}
+		}
+		writes := make([]driver.StateWrite, 0, len(states))
+		for _, w := range states {
+			writes = append(writes, w)
+		}
+		sort.Slice(writes, func(i, j int) bool { return writes[i].Key < writes[j].Key })
+		err = c.vault.RederiveNamespace(namespace, writes, height)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, vault.ErrHeightChanged) || attempt == rederiveAttempts {
+			return nil, errors.WithMessagef(err, "failed re-deriving namespace [%s]", namespace)
+		}
+		logger.Debugf("vault [%s:%s] moved while re-deriving [%s]: [%s], retry", c.network.Name(), c.name, namespace, err)
+	}
+	report, err := c.vault.VerifyIntegrity(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Namespaces) != 1 {
+		return nil, errors.Errorf("namespace [%s] has no checksum", namespace)
+	}
+	return &report.Namespaces[0], nil
+}
+
+// rederiveBlock applies to the passed states the writes to the passed namespace of the valid endorser transactions
+// of the passed block committed by the vault
+func (c *channel) rederiveBlock(namespace string, block *common.Block, states map[string]driver.StateWrite) error {
+	if block.Data == nil {
+		return nil
+	}
+	var flags ValidationFlags
+	if block.Metadata != nil && len(block.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		flags = block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+	for i, raw := range block.Data.Data {
+		if i >= len(flags) || pb.TxValidationCode(flags[i]) != pb.TxValidationCode_VALID {
+			continue
+		}
+		env, err := protoutil.UnmarshalEnvelope(raw)
+		if err != nil {
+			return errors.Wrapf(err, "failed unmarshalling transaction [%d] of block [%d]", i, block.Header.Number)
+		}
+		chdr, err := protoutil.ChannelHeader(env)
+		if err != nil {
+			return errors.Wrapf(err, "failed reading the channel header of transaction [%d] of block [%d]", i, block.Header.Number)
+		}
+		if common.HeaderType(chdr.Type) != common.HeaderType_ENDORSER_TRANSACTION {
+			continue
+		}
+		// the vault commits the transactions known to it only
+		if vc, err := c.vault.Status(chdr.TxId); err != nil || vc != driver.Valid {
+			continue
+		}
+		pt, err := newProcessedTransactionFromEnvelope(env)
+		if err != nil {
+			return errors.WithMessagef(err, "failed unpacking transaction [%s]", chdr.TxId)
+		}
+		txRWSet := &rwsetutil.TxRwSet{}
+		if err := txRWSet.FromProtoBytes(pt.Results()); err != nil {
+			return errors.Wrapf(err, "failed unmarshalling read-write set of [%s]", chdr.TxId)
+		}
+		for _, ns := range txRWSet.NsRwSets {
+			if ns.NameSpace != namespace {
+				continue
+			}
+			for _, w := range ns.KvRwSet.Writes {
+				if w.IsDelete {
+					delete(states, w.Key)
+					continue
+				}
+				states[w.Key] = driver.StateWrite{Namespace: namespace, Key: w.Key, Value: w.Value, Block: block.Header.Number, TxNum: uint64(i)}
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyIntegrity verifies the namespaces of the vault of this channel against their checksum, see vault.Vault#VerifyIntegrity
+func (c *channel) VerifyIntegrity(namespaces ...string) (*driver.IntegrityReport, error) {
+	return c.vault.VerifyIntegrity(namespaces...)
+}
@@ -12,12 +12,14 @@ import (
 	"github.com/hyperledger/fabric-protos-go/common"
 	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
 )
 
 // NewRWSet returns a RWSet for this ledger.
 // A client may obtain more than one such simulator; they are made unique
 // by way of the supplied txid
 func (c *channel) NewRWSet(txid string) (driver.RWSet, error) {
+	c.touch()
 	return c.vault.NewRWSet(txid)
 }
 
@@ -26,6 +28,7 @@ func (c *channel) NewRWSet(txid string) (driver.RWSet, error) {
 // A client may obtain more than one such simulator; they are made unique
 // by way of the supplied txid
 func (c *channel) GetRWSet(txid string, rwset []byte) (driver.RWSet, error) {
+	c.touch()
 	return c.vault.GetRWSet(txid, rwset)
 }
 
@@ -40,23 +43,50 @@ func (c *channel) GetEphemeralRWSet(rwset []byte, namespaces ...string) (driver.
 // A client can obtain more than one 'QueryExecutor's for parallel execution.
 // Any synchronization should be performed at the implementation level if required
 func (c *channel) NewQueryExecutor() (driver.QueryExecutor, error) {
+	c.touch()
 	return c.vault.NewQueryExecutor()
 }
 
 // GetBlockByNumber fetches a block by number
 func (c *channel) GetBlockByNumber(number uint64) (driver.Block, error) {
+	b, err := c.block(number)
+	if err != nil {
+		return nil, err
+	}
+	return &Block{Block: b}, nil
+}
+
+// block fetches from a peer the block with the passed number
+func (c *channel) block(number uint64) (*common.Block, error) {
 	res, err := c.Chaincode("qscc").NewInvocation(GetBlockByNumber, c.name, number).WithSignerIdentity(
 		c.network.LocalMembership().DefaultIdentity(),
 	).WithEndorsersByConnConfig(c.network.PickPeer()).Query()
 	if err != nil {
 		return nil, err
 	}
+	return unmarshalBlock(res)
+}
+
+// blockByTxID fetches from a peer the block where the passed transaction appears
+func (c *channel) blockByTxID(txID string) (*common.Block, error) {
+	res, err := c.Chaincode("qscc").NewInvocation(GetBlockByTxID, c.name, txID).WithSignerIdentity(
+		c.network.LocalMembership().DefaultIdentity(),
+	).WithEndorsersByConnConfig(c.network.PickPeer()).Query()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalBlock(res)
+}
 
-	b, err := protoutil.UnmarshalBlock(res)
+func unmarshalBlock(raw []byte) (*common.Block, error) {
+	b, err := protoutil.UnmarshalBlock(raw)
 	if err != nil {
 		return nil, err
 	}
-	return &Block{Block: b}, nil
+	if b.Header == nil {
+		return nil, errors.New("block without header")
+	}
+	return b, nil
 }
 
 // Block wraps a Fabric block
@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/pkg/errors"
+)
+
+var (
+	openChannelsOpts = metrics.GaugeOpts{
+		Namespace:    "fabric",
+		Subsystem:    "channels",
+		Name:         "open",
+		Help:         "The number of channels of a network holding their configuration bundle, the unloaded ones are not counted.",
+		LabelNames:   []string{"network"},
+		StatsdFormat: "%{#fqname}.%{network}",
+	}
+	deliveryStreamsOpts = metrics.GaugeOpts{
+		Namespace:    "fabric",
+		Subsystem:    "channels",
+		Name:         "delivery_streams",
+		Help:         "The number of channels of a network whose delivery is running.",
+		LabelNames:   []string{"network"},
+		StatsdFormat: "%{#fqname}.%{network}",
+	}
+	bundleBytesOpts = metrics.GaugeOpts{
+		Namespace:    "fabric",
+		Subsystem:    "channels",
+		Name:         "bundle_bytes",
+		Help:         "An estimate, in bytes, of the memory held by the configuration bundle of a channel: the size of its configuration.",
+		LabelNames:   []string{"network", "channel"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}",
+	}
+)
+
+// ChannelMetrics collects the metrics of the resources held by the channels
+type ChannelMetrics struct {
+	Open            metrics.Gauge
+	DeliveryStreams metrics.Gauge
+	BundleBytes     metrics.Gauge
+}
+
+func NewChannelMetrics(p metrics.Provider) *ChannelMetrics {
+	return &ChannelMetrics{
+		Open:            p.NewGauge(openChannelsOpts),
+		DeliveryStreams: p.NewGauge(deliveryStreamsOpts),
+		BundleBytes:     p.NewGauge(bundleBytesOpts),
+	}
+}
+
+// touch records that the channel is used now
+func (c *channel) touch() {
+	atomic.StoreInt64(&c.lastUsed, time.Now().UnixNano())
+}
+
+// use records that the channel is used now, and reloads it if unloaded
+func (c *channel) use() error {
+	c.touch()
+	c.lock.RLock()
+	unloaded := c.unloaded
+	c.lock.RUnlock()
+	if !unloaded {
+		return nil
+	}
+	return c.reload()
+}
+
+// acquire marks a call in flight, the channel is not unloaded until it is released
+func (c *channel) acquire() func() {
+	atomic.AddInt32(&c.inFlight, 1)
+	return func() {
+		c.touch()
+		atomic.AddInt32(&c.inFlight, -1)
+	}
+}
+
+// busy returns true if the channel cannot be unloaded: calls are in flight or subscriptions wait for its events
+func (c *channel) busy() bool {
+	return atomic.LoadInt32(&c.inFlight) > 0 || c.chaincodeSubscriptions.Len() > 0 || c.subscribers.Len() > 0
+}
+
+// startDelivery starts the delivery with the passed context, it is restarted with the same context after a reload
+func (c *channel) startDelivery(ctx context.Context) {
+	c.lifecycleLock.Lock()
+	defer c.lifecycleLock.Unlock()
+	c.deliveryCtx = ctx
+	c.runDelivery()
+}
+
+// runDelivery runs the delivery, if started and not running already. c.lifecycleLock must be held.
+func (c *channel) runDelivery() {
+	if c.deliveryCtx == nil || c.deliveryDone != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(c.deliveryCtx)
+	done := make(chan struct{})
+	c.deliveryCancel, c.deliveryDone = cancel, done
+	c.network.channelMetrics.DeliveryStreams.With("network", c.network.Name()).Add(1)
+	go func() {
+		defer close(done)
+		if err := c.deliveryService.Run(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorf("[channel: %s] delivery stopped: [%s]", c.name, err)
+		}
+	}()
+}
+
+// stopDelivery stops the delivery, if running, and waits for it to return. c.lifecycleLock must be held.
+func (c *channel) stopDelivery() {
+	if c.deliveryDone == nil {
+		return
+	}
+	c.deliveryCancel()
+	<-c.deliveryDone
+	c.deliveryCancel, c.deliveryDone = nil, nil
+	c.network.channelMetrics.DeliveryStreams.With("network", c.network.Name()).Add(-1)
+}
+
+// unload stops the delivery and releases the configuration bundle of the channel, if it has not been used
+// for the passed ttl and it is not busy. It returns true if the channel has been unloaded.
+// The channel is reloaded by its next use.
+func (c *channel) unload(ttl time.Duration) bool {
+	c.lifecycleLock.Lock()
+	defer c.lifecycleLock.Unlock()
+
+	c.lock.RLock()
+	unloaded := c.unloaded
+	c.lock.RUnlock()
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&c.lastUsed)))
+	if unloaded || idle < ttl || c.busy() {
+		return false
+	}
+
+	c.stopDelivery()
+	// no configuration is applied meanwhile
+	c.applyLock.Lock()
+	c.lock.Lock()
+	if c.resources != nil {
+		c.unloadedConfig = c.resources.ConfigtxValidator().ConfigProto()
+		c.resources = nil
+	}
+	c.unloaded = true
+	c.lock.Unlock()
+	c.applyLock.Unlock()
+
+	c.network.channelMetrics.Open.With("network", c.network.Name()).Add(-1)
+	c.network.channelMetrics.BundleBytes.With("network", c.network.Name(), "channel", c.name).Set(0)
+	logger.Infow("channel unloaded", "network", c.network.Name(), "channel", c.name, "idle", idle.String())
+	return true
+}
+
+// reload builds again the configuration bundle released by unload, and restarts the delivery if it was started
+func (c *channel) reload() error {
+	c.lifecycleLock.Lock()
+	defer c.lifecycleLock.Unlock()
+
+	c.lock.RLock()
+	unloaded, config := c.unloaded, c.unloadedConfig
+	c.lock.RUnlock()
+	if !unloaded {
+		return nil
+	}
+
+	// the configuration has been validated already, it is not published again
+	var bundle *channelconfig.Bundle
+	if config != nil {
+		var err error
+		if bundle, err = newBundle(c.name, config, c.cryptoProvider); err != nil {
+			return errors.WithMessagef(err, "failed reloading the configuration of channel [%s]", c.name)
+		}
+	}
+	c.lock.Lock()
+	if bundle != nil {
+		c.resources = bundle
+	}
+	c.unloaded = false
+	c.unloadedConfig = nil
+	c.lock.Unlock()
+
+	c.network.channelMetrics.Open.With("network", c.network.Name()).Add(1)
+	c.setBundleBytes(bundle)
+	c.runDelivery()
+	logger.Infow("channel reloaded", "network", c.network.Name(), "channel", c.name)
+	return nil
+}
+
+// setBundleBytes sets the estimate of the memory held by the passed bundle
+func (c *channel) setBundleBytes(bundle channelconfig.Resources) {
+	if bundle == nil || c.network == nil || c.network.channelMetrics == nil {
+		return
+	}
+	size := proto.Size(bundle.ConfigtxValidator().ConfigProto())
+	c.network.channelMetrics.BundleBytes.With("network", c.network.Name(), "channel", c.name).Set(float64(size))
+}
+
+// evictAdmissions removes, every window, the admission entries of the transactions that did not reach finality
+// within the window
+func (f *network) evictAdmissions(window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for range ticker.C {
+		if n := f.admission.Evict(); n != 0 {
+			logger.Debugf("evicted [%d] admission entries of network [%s]", n, f.name)
+		}
+	}
+}
+
+// unloadIdleChannels unloads, every half of the passed ttl, the channels of this network not used for the ttl
+func (f *network) unloadIdleChannels(ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		f.mutex.RLock()
+		channels := make([]*channel, 0, len(f.channels))
+		for _, ch := range f.channels {
+			channels = append(channels, ch)
+		}
+		f.mutex.RUnlock()
+		for _, ch := range channels {
+			ch.unload(ttl)
+		}
+	}
+}
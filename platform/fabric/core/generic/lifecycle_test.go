@@ -0,0 +1,174 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	delivery2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/delivery"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDelivery runs until its context is done
+type fakeDelivery struct {
+	runs    int32
+	running int32
+}
+
+func (d *fakeDelivery) Start(ctx context.Context) { go d.Run(ctx) }
+
+func (d *fakeDelivery) Run(ctx context.Context) error {
+	atomic.AddInt32(&d.runs, 1)
+	atomic.AddInt32(&d.running, 1)
+	defer atomic.AddInt32(&d.running, -1)
+	<-ctx.Done()
+	return errors.New("context done")
+}
+
+func (d *fakeDelivery) Stop() {}
+
+func (d *fakeDelivery) SetStreamListener(listener delivery2.StreamListener) {}
+
+type fakeChannelMetrics struct {
+	open, streams, bundle *metricsfakes.Gauge
+}
+
+func newFakeChannelMetrics() (*ChannelMetrics, *fakeChannelMetrics) {
+	f := &fakeChannelMetrics{open: &metricsfakes.Gauge{}, streams: &metricsfakes.Gauge{}, bundle: &metricsfakes.Gauge{}}
+	f.open.WithReturns(f.open)
+	f.streams.WithReturns(f.streams)
+	f.bundle.WithReturns(f.bundle)
+	return &ChannelMetrics{Open: f.open, DeliveryStreams: f.streams, BundleBytes: f.bundle}, f
+}
+
+func newLoadedChannel(t *testing.T) (*channel, *fakeDelivery, *fakeChannelMetrics) {
+	csp, err := (&factory.SWFactory{}).Get(factory.GetDefaultOpts())
+	assert.NoError(t, err)
+	m, fakes := newFakeChannelMetrics()
+	delivery := &fakeDelivery{}
+	subscriptions, err := committer.NewSubscriptions(nil, "default", "mychannel", 0)
+	assert.NoError(t, err)
+	c := &channel{
+		name:                   "mychannel",
+		network:                &network{name: "default", channelMetrics: m},
+		cryptoProvider:         csp,
+		deliveryService:        delivery,
+		subscribers:            events.NewSubscribers(),
+		chaincodeSubscriptions: subscriptions,
+	}
+	c.configSequence = newConfigSequence(c.name, nil)
+	bundle, err := c.nextBundle(nil, mspConfigEnvelope(t))
+	assert.NoError(t, err)
+	c.setBundle(bundle)
+	c.touch()
+	return c, delivery, fakes
+}
+
+func TestUnloadIdleChannel(t *testing.T) {
+	c, delivery, fakes := newLoadedChannel(t)
+	assert.Equal(t, 1, fakes.bundle.SetCallCount())
+	assert.NotZero(t, fakes.bundle.SetArgsForCall(0))
+
+	c.startDelivery(context.Background())
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&delivery.running) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(1), fakes.streams.AddArgsForCall(0))
+
+	// the channel has been used recently
+	assert.False(t, c.unload(time.Hour))
+
+	// idle, the delivery is stopped and the bundle released
+	assert.True(t, c.unload(0))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&delivery.running))
+	assert.Nil(t, c.resources)
+	description := c.Describe()
+	assert.True(t, description.Unloaded)
+	assert.Equal(t, uint64(0), description.ConfigSequence)
+	assert.Nil(t, c.resources)
+	assert.Equal(t, float64(-1), fakes.streams.AddArgsForCall(1))
+	assert.Equal(t, float64(-1), fakes.open.AddArgsForCall(0))
+	assert.Equal(t, float64(0), fakes.bundle.SetArgsForCall(1))
+	// unloaded already
+	assert.False(t, c.unload(0))
+
+	// the next use reloads the bundle and restarts the delivery
+	res := c.Resources()
+	assert.NotNil(t, res)
+	assert.Equal(t, "mychannel", res.ConfigtxValidator().ChannelID())
+	assert.False(t, c.Describe().Unloaded)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&delivery.running) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&delivery.runs))
+	assert.Equal(t, float64(1), fakes.open.AddArgsForCall(1))
+	assert.Equal(t, fakes.bundle.SetArgsForCall(0), fakes.bundle.SetArgsForCall(2))
+
+	c.lifecycleLock.Lock()
+	c.stopDelivery()
+	c.lifecycleLock.Unlock()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&delivery.running))
+}
+
+func TestUnloadBusyChannel(t *testing.T) {
+	c, _, _ := newLoadedChannel(t)
+
+	// a call waits on the channel
+	release := c.acquire()
+	assert.False(t, c.unload(0))
+	release()
+
+	// a subscription waits for the events of the channel
+	sub, err := c.SubscribeChaincodeEvents("mycc", nil)
+	assert.NoError(t, err)
+	assert.False(t, c.unload(0))
+	sub.Close()
+	assert.Eventually(t, func() bool { return c.unload(0) }, time.Second, 10*time.Millisecond)
+
+	// subscribing reloads the channel
+	sub, err = c.SubscribeChaincodeEvents("mycc", nil)
+	assert.NoError(t, err)
+	defer sub.Close()
+	assert.False(t, c.Describe().Unloaded)
+	assert.NotNil(t, c.resources)
+}
+
+func TestMaxChannels(t *testing.T) {
+	cp := &mock.ConfigProvider{}
+	cp.IsSetReturns(true)
+	cp.GetIntStub = func(key string) int {
+		if key == "fabric.default.channelLimits.max" {
+			return 1
+		}
+		return 0
+	}
+	c, err := config2.New(cp, "default", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.MaxChannels())
+	m, _ := newFakeChannelMetrics()
+	n := &network{name: "default", config: c, channelMetrics: m, channels: map[string]*channel{}}
+	n.channels["mychannel"] = &channel{name: "mychannel"}
+
+	_, err = n.Channel("otherchannel")
+	assert.Error(t, err)
+	tooMany := &driver.ErrTooManyChannels{}
+	assert.True(t, errors.As(err, &tooMany))
+	assert.Equal(t, "otherchannel", tooMany.Channel)
+	assert.Equal(t, 1, tooMany.Max)
+
+	// the channels open already are returned
+	ch, err := n.Channel("mychannel")
+	assert.NoError(t, err)
+	assert.Equal(t, "mychannel", ch.Name())
+}
@@ -7,9 +7,16 @@ SPDX-License-Identifier: Apache-2.0
 package generic
 
 import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/common/channelconfig"
 	"github.com/hyperledger/fabric/msp"
 
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 )
 
 // GetMSPIDs retrieves the MSP IDs of the organizations in the current channel
@@ -28,6 +35,95 @@ func (c *channel) GetMSPIDs() []string {
 	return mspIDs
 }
 
+// ChannelPeers returns the peers of the other application organizations of the channel this node
+// is allowed to contact, as configured under `endorsement`. The list follows the channel configuration updates.
+func (c *channel) ChannelPeers() []driver.ChannelPeer {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append([]driver.ChannelPeer(nil), c.channelPeers...)
+}
+
+// extractChannelPeers returns the peers of the other organizations found in the passed channel configuration
+func (c *channel) extractChannelPeers(res channelconfig.Resources) []driver.ChannelPeer {
+	ac, ok := res.ApplicationConfig()
+	if !ok {
+		return nil
+	}
+	var localMSPIDs []string
+	msps, err := c.network.config.MSPs()
+	if err != nil {
+		logger.Warnf("[channel: %s] failed loading local MSPs, cannot tell the other organizations apart [%s]", c.name, err)
+		return nil
+	}
+	for _, m := range msps {
+		localMSPIDs = append(localMSPIDs, m.MSPID)
+	}
+	return channelPeers(ac, localMSPIDs, c.network.foreignOrgs, c.network.foreignPeers, c.network.config.TLSEnabled())
+}
+
+// channelPeers returns the anchor peers, and the passed foreign peers, of the organizations in the passed application
+// config that are not local and are allowed by foreignOrgs. The TLS root certificates are taken from the organizations' MSPs.
+func channelPeers(ac channelconfig.Application, localMSPIDs []string, foreignOrgs []string, foreignPeers []*config2.ForeignPeer, tlsEnabled bool) []driver.ChannelPeer {
+	allowed := func(mspID string) bool {
+		for _, id := range localMSPIDs {
+			if id == mspID {
+				return false
+			}
+		}
+		for _, id := range foreignOrgs {
+			if id == config2.AnyForeignOrg || id == mspID {
+				return true
+			}
+		}
+		return false
+	}
+
+	var names []string
+	for name := range ac.Organizations() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var peers []driver.ChannelPeer
+	for _, name := range names {
+		org := ac.Organizations()[name]
+		if !allowed(org.MSPID()) {
+			continue
+		}
+		var tlsRootCerts [][]byte
+		tlsRootCerts = append(tlsRootCerts, org.MSP().GetTLSRootCerts()...)
+		tlsRootCerts = append(tlsRootCerts, org.MSP().GetTLSIntermediateCerts()...)
+
+		var addresses []string
+		for _, anchorPeer := range org.AnchorPeers() {
+			addresses = append(addresses, fmt.Sprintf("%s:%d", anchorPeer.Host, anchorPeer.Port))
+		}
+		for _, peer := range foreignPeers {
+			if peer.MSPID == org.MSPID() {
+				addresses = append(addresses, peer.Address)
+			}
+		}
+
+		seen := map[string]bool{}
+		for _, address := range addresses {
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			peers = append(peers, driver.ChannelPeer{
+				MSPID: org.MSPID(),
+				ConnectionConfig: grpc.ConnectionConfig{
+					Address:           address,
+					ConnectionTimeout: 10 * time.Second,
+					TLSEnabled:        tlsEnabled,
+					TLSRootCertBytes:  tlsRootCerts,
+				},
+			})
+		}
+	}
+	return peers
+}
+
 // MSPManager returns the msp.MSPManager that reflects the current channel
 // configuration. Users should not memoize references to this object.
 func (c *channel) MSPManager() driver.MSPManager {
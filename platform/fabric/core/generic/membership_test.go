@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/msp"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMSP struct {
+	msp.MSP
+	tlsRootCerts [][]byte
+}
+
+func (m *fakeMSP) GetTLSRootCerts() [][]byte { return m.tlsRootCerts }
+
+func (m *fakeMSP) GetTLSIntermediateCerts() [][]byte { return nil }
+
+type fakeOrg struct {
+	channelconfig.ApplicationOrg
+	mspID       string
+	msp         msp.MSP
+	anchorPeers []*pb.AnchorPeer
+}
+
+func (o *fakeOrg) MSPID() string { return o.mspID }
+
+func (o *fakeOrg) MSP() msp.MSP { return o.msp }
+
+func (o *fakeOrg) AnchorPeers() []*pb.AnchorPeer { return o.anchorPeers }
+
+type fakeApplication struct {
+	channelconfig.Application
+	orgs map[string]channelconfig.ApplicationOrg
+}
+
+func (a *fakeApplication) Organizations() map[string]channelconfig.ApplicationOrg { return a.orgs }
+
+func TestChannelPeers(t *testing.T) {
+	ac := &fakeApplication{orgs: map[string]channelconfig.ApplicationOrg{
+		"Org1": &fakeOrg{
+			mspID:       "Org1MSP",
+			msp:         &fakeMSP{tlsRootCerts: [][]byte{[]byte("org1-ca")}},
+			anchorPeers: []*pb.AnchorPeer{{Host: "peer0.org1", Port: 7051}},
+		},
+		"Org2": &fakeOrg{
+			mspID:       "Org2MSP",
+			msp:         &fakeMSP{tlsRootCerts: [][]byte{[]byte("org2-ca")}},
+			anchorPeers: []*pb.AnchorPeer{{Host: "peer0.org2", Port: 8051}},
+		},
+		"Org3": &fakeOrg{
+			mspID:       "Org3MSP",
+			msp:         &fakeMSP{tlsRootCerts: [][]byte{[]byte("org3-ca")}},
+			anchorPeers: []*pb.AnchorPeer{{Host: "peer0.org3", Port: 9051}},
+		},
+	}}
+	foreignPeers := []*config2.ForeignPeer{
+		{MSPID: "Org2MSP", Address: "peer1.org2:8051"},
+		{MSPID: "Org2MSP", Address: "peer0.org2:8051"},
+	}
+
+	// by default, no other organization is contacted
+	assert.Empty(t, channelPeers(ac, []string{"Org1MSP"}, nil, foreignPeers, true))
+
+	// only the allowed organizations, the local one is never returned
+	peers := channelPeers(ac, []string{"Org1MSP"}, []string{"Org1MSP", "Org2MSP"}, foreignPeers, true)
+	assert.Len(t, peers, 2)
+	for _, peer := range peers {
+		assert.Equal(t, "Org2MSP", peer.MSPID)
+		assert.True(t, peer.TLSEnabled)
+		assert.Equal(t, [][]byte{[]byte("org2-ca")}, peer.TLSRootCertBytes)
+	}
+	assert.Equal(t, "peer0.org2:8051", peers[0].Address)
+	assert.Equal(t, "peer1.org2:8051", peers[1].Address)
+
+	// all the other organizations
+	peers = channelPeers(ac, []string{"Org1MSP"}, []string{config2.AnyForeignOrg}, nil, false)
+	assert.Len(t, peers, 2)
+	assert.Equal(t, "Org2MSP", peers[0].MSPID)
+	assert.Equal(t, "Org3MSP", peers[1].MSPID)
+	assert.Equal(t, "peer0.org3:9051", peers[1].Address)
+	assert.Equal(t, [][]byte{[]byte("org3-ca")}, peers[1].TLSRootCertBytes)
+}
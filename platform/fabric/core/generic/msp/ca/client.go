@@ -0,0 +1,261 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is the time each request to the Fabric CA has
+const DefaultTimeout = 30 * time.Second
+
+// Signer is an identity, carrying an x509 certificate, the requests to the Fabric CA that require
+// an authorization token are signed with: a registrar, or the identity being re-enrolled
+type Signer interface {
+	// Serialize returns the serialized identity, carrying the certificate
+	Serialize() ([]byte, error)
+	// Sign signs the SHA-256 digest of the passed message
+	Sign(message []byte) ([]byte, error)
+}
+
+// Enrollment is the result of an enrollment, or re-enrollment, with a Fabric CA
+type Enrollment struct {
+	// Cert is the certificate issued, in PEM format
+	Cert []byte
+	// CAChain is the chain of the certificates of the CA, in PEM format
+	CAChain []byte
+}
+
+// Client registers, enrolls, re-enrolls, and revokes identities with a Fabric CA, through its REST API
+type Client struct {
+	url    string
+	name   string
+	client *http.Client
+}
+
+// NewClient returns a client of the CA at the passed url.
+// If the passed pool is not nil, the TLS certificate of the CA is verified against it.
+func NewClient(url, name string, rootCAs *x509.CertPool, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if rootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	}
+	return &Client{
+		url:    strings.TrimSuffix(url, "/"),
+		name:   name,
+		client: &http.Client{Transport: transport, Timeout: timeout},
+	}
+}
+
+// LoadRootCAs returns the pool of the certificates in the passed PEM files, nil if none is passed
+func LoadRootCAs(paths []string) (*x509.CertPool, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading TLS CA certificate [%s]", path)
+		}
+		if !pool.AppendCertsFromPEM(raw) {
+			return nil, errors.Errorf("no certificate found in [%s]", path)
+		}
+	}
+	return pool, nil
+}
+
+type registrationRequest struct {
+	Name           string `json:"id"`
+	Type           string `json:"type"`
+	Secret         string `json:"secret,omitempty"`
+	MaxEnrollments int    `json:"max_enrollments"`
+	Affiliation    string `json:"affiliation"`
+	CAName         string `json:"caname,omitempty"`
+}
+
+type enrollmentRequest struct {
+	Request string `json:"certificate_request"`
+	CAName  string `json:"caname,omitempty"`
+}
+
+type revocationRequest struct {
+	Name   string `json:"id"`
+	Reason string `json:"reason,omitempty"`
+	CAName string `json:"caname,omitempty"`
+}
+
+type enrollmentResponse struct {
+	Cert       string `json:"Cert"`
+	ServerInfo struct {
+		CAChain string `json:"CAChain"`
+	} `json:"ServerInfo"`
+}
+
+type caResponse struct {
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Register registers, on behalf of the passed registrar, a client identity that can be enrolled once, with the passed secret
+func (c *Client) Register(ctx context.Context, registrar Signer, enrollmentID, secret, affiliation string) error {
+	body, err := json.Marshal(&registrationRequest{
+		Name:           enrollmentID,
+		Type:           "client",
+		Secret:         secret,
+		MaxEnrollments: 1,
+		Affiliation:    affiliation,
+		CAName:         c.name,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling registration of [%s]", enrollmentID)
+	}
+	if _, err := c.call(ctx, "register", body, tokenAuth(registrar)); err != nil {
+		return errors.WithMessagef(err, "failed registering [%s]", enrollmentID)
+	}
+	return nil
+}
+
+// Enroll enrolls the passed identity with the passed certificate request, in PEM format
+func (c *Client) Enroll(ctx context.Context, enrollmentID, secret string, csr []byte) (*Enrollment, error) {
+	e, err := c.enroll(ctx, "enroll", csr, func(req *http.Request, _ []byte) error {
+		req.SetBasicAuth(enrollmentID, secret)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed enrolling [%s]", enrollmentID)
+	}
+	return e, nil
+}
+
+// Reenroll re-enrolls the passed identity, whose certificate must still be valid, with the passed certificate request, in PEM format
+func (c *Client) Reenroll(ctx context.Context, identity Signer, csr []byte) (*Enrollment, error) {
+	e, err := c.enroll(ctx, "reenroll", csr, tokenAuth(identity))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed re-enrolling")
+	}
+	return e, nil
+}
+
+// Revoke revokes, on behalf of the passed registrar, the passed identity and all its certificates
+func (c *Client) Revoke(ctx context.Context, registrar Signer, enrollmentID string) error {
+	body, err := json.Marshal(&revocationRequest{Name: enrollmentID, Reason: "cessationofoperation", CAName: c.name})
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling revocation of [%s]", enrollmentID)
+	}
+	if _, err := c.call(ctx, "revoke", body, tokenAuth(registrar)); err != nil {
+		return errors.WithMessagef(err, "failed revoking [%s]", enrollmentID)
+	}
+	return nil
+}
+
+func (c *Client) enroll(ctx context.Context, endpoint string, csr []byte, auth func(req *http.Request, body []byte) error) (*Enrollment, error) {
+	body, err := json.Marshal(&enrollmentRequest{Request: string(csr), CAName: c.name})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling enrollment")
+	}
+	result, err := c.call(ctx, endpoint, body, auth)
+	if err != nil {
+		return nil, err
+	}
+	res := &enrollmentResponse{}
+	if err := json.Unmarshal(result, res); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling enrollment")
+	}
+	e := &Enrollment{}
+	if e.Cert, err = base64.StdEncoding.DecodeString(res.Cert); err != nil {
+		return nil, errors.Wrapf(err, "invalid certificate enrolled")
+	}
+	if e.CAChain, err = base64.StdEncoding.DecodeString(res.ServerInfo.CAChain); err != nil {
+		return nil, errors.Wrapf(err, "invalid chain of the CA")
+	}
+	return e, nil
+}
+
+func (c *Client) call(ctx context.Context, endpoint string, body []byte, auth func(req *http.Request, body []byte) error) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/api/v1/"+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := auth(req, body); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed calling the CA at [%s]", c.url)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading the response of the CA")
+	}
+	res := &caResponse{}
+	if err := json.Unmarshal(raw, res); err != nil {
+		return nil, errors.Wrapf(err, "invalid response of the CA, status [%d]", resp.StatusCode)
+	}
+	if !res.Success {
+		var messages []string
+		for _, e := range res.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, errors.Errorf("the CA answered [%d]: [%s]", resp.StatusCode, strings.Join(messages, "; "))
+	}
+	return res.Result, nil
+}
+
+// tokenAuth sets the authorization token of the passed signer: its certificate and its signature of the request,
+// as verified by the Fabric CA.
+func tokenAuth(signer Signer) func(req *http.Request, body []byte) error {
+	return func(req *http.Request, body []byte) error {
+		if signer == nil {
+			return errors.New("no identity to sign the request with")
+		}
+		raw, err := signer.Serialize()
+		if err != nil {
+			return errors.WithMessagef(err, "failed serializing the signer")
+		}
+		sID := &msp.SerializedIdentity{}
+		if err := proto.Unmarshal(raw, sID); err != nil {
+			return errors.Wrapf(err, "failed unmarshalling the signer")
+		}
+		if block, _ := pem.Decode(sID.IdBytes); block == nil {
+			return errors.New("the signer does not carry a certificate")
+		}
+		b64Cert := base64.StdEncoding.EncodeToString(sID.IdBytes)
+		b64Body := base64.StdEncoding.EncodeToString(body)
+		b64URI := base64.StdEncoding.EncodeToString([]byte(req.URL.RequestURI()))
+		sig, err := signer.Sign([]byte(req.Method + "." + b64URI + "." + b64Body + "." + b64Cert))
+		if err != nil {
+			return errors.WithMessagef(err, "failed signing the request")
+		}
+		req.Header.Set("Authorization", b64Cert+"."+base64.StdEncoding.EncodeToString(sig))
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ca_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCSR(t *testing.T, cn string) (*ecdsa.PrivateKey, []byte) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: cn}}, sk)
+	assert.NoError(t, err)
+	return sk, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+}
+
+func TestClient(t *testing.T) {
+	fake, err := mock.NewCA()
+	assert.NoError(t, err)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	c := ca.NewClient(server.URL, "", nil, 0)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Register(ctx, fake.Registrar, "alice", "secret", "org1"))
+	sk, csr := newCSR(t, "alice")
+
+	_, err = c.Enroll(ctx, "alice", "wrong", csr)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid credentials")
+
+	enrollment, err := c.Enroll(ctx, "alice", "secret", csr)
+	assert.NoError(t, err)
+	assert.Equal(t, fake.Registrar.Cert, enrollment.CAChain)
+	block, _ := pem.Decode(enrollment.Cert)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", cert.Subject.CommonName)
+
+	// re-enrolled with the token of the identity itself
+	_, csr = newCSR(t, "alice")
+	reenrollment, err := c.Reenroll(ctx, &mock.Identity{MSPID: "Org1MSP", Key: sk, Cert: enrollment.Cert}, csr)
+	assert.NoError(t, err)
+	assert.NotEqual(t, enrollment.Cert, reenrollment.Cert)
+
+	assert.NoError(t, c.Revoke(ctx, fake.Registrar, "alice"))
+	assert.Equal(t, []string{"alice"}, fake.Revoked())
+
+	// the tokens of identities unknown to the CA are rejected
+	other, err := mock.NewCA()
+	assert.NoError(t, err)
+	assert.Error(t, c.Revoke(ctx, other.Registrar, "alice"))
+	assert.Error(t, c.Register(ctx, nil, "bob", "secret", "org1"))
+}
@@ -0,0 +1,254 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+)
+
+// Identity signs the SHA-256 digests of the messages with its key, its certificate is issued by a CA
+type Identity struct {
+	MSPID string
+	Key   *ecdsa.PrivateKey
+	// Cert is the certificate in PEM format
+	Cert []byte
+}
+
+func (i *Identity) Serialize() ([]byte, error) {
+	return proto.Marshal(&msp.SerializedIdentity{Mspid: i.MSPID, IdBytes: i.Cert})
+}
+
+func (i *Identity) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	return ecdsa.SignASN1(rand.Reader, i.Key, digest[:])
+}
+
+// CA is a fake Fabric CA, serving the register, enroll, reenroll and revoke endpoints of the REST API.
+// The authorization tokens are accepted if signed by an identity whose certificate has been issued by the CA.
+type CA struct {
+	// Registrar is the identity of the CA itself, the registration and revocation requests can be signed with
+	Registrar *Identity
+	// Lifetime is the lifetime of the certificates issued
+	Lifetime time.Duration
+
+	lock     sync.Mutex
+	cert     *x509.Certificate
+	serial   int64
+	secrets  map[string]string
+	revoked  []string
+	requests map[string]int
+}
+
+// NewCA returns a CA issuing certificates valid for an hour
+func NewCA() (*CA, error) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature | x509.KeyUsageCRLSign,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &sk.PublicKey, sk)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{
+		Registrar: &Identity{MSPID: "Org1MSP", Key: sk, Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})},
+		Lifetime:  time.Hour,
+		cert:      cert,
+		serial:    1,
+		secrets:   map[string]string{},
+		requests:  map[string]int{},
+	}, nil
+}
+
+// Revoked returns the enrollment IDs revoked so far
+func (ca *CA) Revoked() []string {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	return append([]string(nil), ca.revoked...)
+}
+
+// Requests returns the number of requests served so far by the passed endpoint
+func (ca *CA) Requests(endpoint string) int {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	return ca.requests[endpoint]
+}
+
+// Register registers an identity that can be enrolled with the passed secret
+func (ca *CA) Register(enrollmentID, secret string) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	ca.secrets[enrollmentID] = secret
+}
+
+func (ca *CA) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	endpoint := strings.TrimPrefix(req.URL.Path, "/api/v1/")
+	ca.requests[endpoint]++
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		ca.fail(w, err)
+		return
+	}
+	request := &struct {
+		ID      string `json:"id"`
+		Secret  string `json:"secret"`
+		Request string `json:"certificate_request"`
+	}{}
+	if err := json.Unmarshal(body, request); err != nil {
+		ca.fail(w, err)
+		return
+	}
+
+	var result interface{}
+	switch endpoint {
+	case "register", "revoke":
+		if _, err := ca.verifyToken(req, body); err != nil {
+			ca.fail(w, err)
+			return
+		}
+		if endpoint == "revoke" {
+			ca.revoked = append(ca.revoked, request.ID)
+		} else {
+			ca.secrets[request.ID] = request.Secret
+		}
+	case "enroll":
+		id, secret, ok := req.BasicAuth()
+		if !ok || ca.secrets[id] != secret {
+			ca.fail(w, errors.New("invalid credentials"))
+			return
+		}
+		if result, err = ca.issue(request.Request); err != nil {
+			ca.fail(w, err)
+			return
+		}
+	case "reenroll":
+		cert, err := ca.verifyToken(req, body)
+		if err != nil {
+			ca.fail(w, err)
+			return
+		}
+		if time.Now().After(cert.NotAfter) {
+			ca.fail(w, errors.New("certificate expired"))
+			return
+		}
+		if result, err = ca.issue(request.Request); err != nil {
+			ca.fail(w, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	raw, err := json.Marshal(map[string]interface{}{"success": true, "result": result})
+	if err != nil {
+		ca.fail(w, err)
+		return
+	}
+	_, _ = w.Write(raw)
+}
+
+func (ca *CA) verifyToken(req *http.Request, body []byte) (*x509.Certificate, error) {
+	parts := strings.Split(req.Header.Get("Authorization"), ".")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid token")
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("invalid token certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		return nil, errors.New("token certificate not issued by the CA")
+	}
+	sig, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	message := req.Method + "." + base64.StdEncoding.EncodeToString([]byte(req.URL.RequestURI())) + "." + base64.StdEncoding.EncodeToString(body) + "." + parts[0]
+	digest := sha256.Sum256([]byte(message))
+	pk, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !ecdsa.VerifyASN1(pk, digest[:], sig) {
+		return nil, errors.New("invalid token")
+	}
+	return cert, nil
+}
+
+func (ca *CA) issue(csrPEM string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		return nil, errors.New("invalid certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ca.serial++
+	now := time.Now()
+	raw, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      csr.Subject,
+		NotBefore:    now.Add(-time.Second),
+		NotAfter:     now.Add(ca.Lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, ca.cert, csr.PublicKey, ca.Registrar.Key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"Cert":       base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})),
+		"ServerInfo": map[string]string{"CAChain": base64.StdEncoding.EncodeToString(ca.Registrar.Cert)},
+	}, nil
+}
+
+func (ca *CA) fail(w http.ResponseWriter, err error) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"success": false,
+		"errors":  []map[string]interface{}{{"code": 20, "message": err.Error()}},
+	})
+	w.WriteHeader(http.StatusUnauthorized)
+	_, _ = w.Write(raw)
+}
@@ -56,6 +56,30 @@ type Manager interface {
 	SetDefaultIdentity(id string, defaultIdentity view.Identity, defaultSigningIdentity SigningIdentity)
 }
 
+// IdentityRenewer is implemented by the managers whose MSPs can be re-enrolled at runtime
+type IdentityRenewer interface {
+	// UpdateIdentity makes the passed identity the identity of the MSP of the passed name, and binds it to the
+	// default view identity. The identities the MSP had before stay known.
+	UpdateIdentity(name string, identity view.Identity, signingIdentity SigningIdentity) error
+	// AddRenewal registers the stop function of the job renewing the identity of the MSP of the passed name,
+	// it is called when the MSPs are refreshed
+	AddRenewal(name string, stop func())
+}
+
+// ReadOnlyManager is implemented by the managers loading the MSPs to check them only, see the dry run of the startup:
+// the loaders neither enroll nor renew the identities
+type ReadOnlyManager interface {
+	// ReadOnly returns true if the MSPs are loaded to be checked only
+	ReadOnly() bool
+}
+
+// SnapshotRegistry is implemented by the managers collecting the debug snapshots of their MSPs
+type SnapshotRegistry interface {
+	// AddSnapshot registers the function returning the snapshot of the MSP of the passed name,
+	// the function must not block and the snapshot must be serializable to JSON
+	AddSnapshot(name string, snapshot func() interface{})
+}
+
 type IdentityLoader interface {
 	Load(manager Manager, config config.MSP) error
 }
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	"go.uber.org/zap/zapcore"
 )
@@ -27,8 +28,12 @@ type IdentityCache struct {
 	once   sync.Once
 	backed IdentityCacheBackendFunc
 	cache  chan identityCacheEntry
+	stats  cacheStats
 }
 
+// NewIdentityCache returns a cache that prepares in the background up to size identities with the passed
+// backing function. If size is not larger than 0, the identities are generated with the backing function
+// when requested, in the order of the requests.
 func NewIdentityCache(backed IdentityCacheBackendFunc, size int) *IdentityCache {
 	ci := &IdentityCache{
 		backed: backed,
@@ -39,7 +44,8 @@ func NewIdentityCache(backed IdentityCacheBackendFunc, size int) *IdentityCache
 }
 
 func (c *IdentityCache) Identity(opts *driver.IdentityOptions) (view.Identity, []byte, error) {
-	if opts != nil {
+	c.stats.request(opts)
+	if opts != nil || cap(c.cache) == 0 {
 		return c.fetchIdentityFromBackend(opts)
 	}
 
@@ -58,6 +64,11 @@ func (c *IdentityCache) Identity(opts *driver.IdentityOptions) (view.Identity, [
 
 }
 
+// Snapshot returns the statistics of the cache, it does not block the generation of identities
+func (c *IdentityCache) Snapshot() *CacheSnapshot {
+	return c.stats.snapshot(len(c.cache), cap(c.cache))
+}
+
 func (c *IdentityCache) fetchIdentityFromCache(opts *driver.IdentityOptions) (view.Identity, []byte, error) {
 	var identity view.Identity
 	var audit []byte
@@ -78,11 +89,12 @@ func (c *IdentityCache) fetchIdentityFromCache(opts *driver.IdentityOptions) (vi
 		audit = entry.Audit
 
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("fetching identity from cache [%s][%d] took %v", identity, len(audit), time.Since(start))
+			logger.Debugf("fetching identity from cache [%s][%d] took %v", flogging.Sensitive(identity), len(audit), time.Since(start))
 		}
 
 	case <-timeout.C:
-		id, a, err := c.backed(opts)
+		c.stats.miss(opts)
+		id, a, err := c.generate(opts)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -90,7 +102,7 @@ func (c *IdentityCache) fetchIdentityFromCache(opts *driver.IdentityOptions) (vi
 		audit = a
 
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("fetching identity from backend after a timeout [%s][%d] took %v", identity, len(audit), time.Since(start))
+			logger.Debugf("fetching identity from backend after a timeout [%s][%d] took %v", flogging.Sensitive(identity), len(audit), time.Since(start))
 		}
 	}
 
@@ -101,12 +113,13 @@ func (c *IdentityCache) fetchIdentityFromBackend(opts *driver.IdentityOptions) (
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("fetching identity from backend")
 	}
-	id, audit, err := c.backed(opts)
+	c.stats.miss(opts)
+	id, audit, err := c.generate(opts)
 	if err != nil {
 		return nil, nil, err
 	}
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("fetch identity from backend done [%s][%d]", id, len(audit))
+		logger.Debugf("fetch identity from backend done [%s][%d]", flogging.Sensitive(id), len(audit))
 	}
 
 	return id, audit, nil
@@ -114,10 +127,18 @@ func (c *IdentityCache) fetchIdentityFromBackend(opts *driver.IdentityOptions) (
 
 func (c *IdentityCache) provisionIdentities() {
 	for {
-		id, audit, err := c.backed(nil)
+		id, audit, err := c.generate(nil)
 		if err != nil {
 			continue
 		}
 		c.cache <- identityCacheEntry{Identity: id, Audit: audit}
 	}
 }
+
+// generate returns a new identity from the backing function and records the outcome
+func (c *IdentityCache) generate(opts *driver.IdentityOptions) (view.Identity, []byte, error) {
+	start := time.Now()
+	id, audit, err := c.backed(opts)
+	c.stats.generation(time.Since(start), err)
+	return id, audit, err
+}
@@ -7,8 +7,11 @@ SPDX-License-Identifier: Apache-2.0
 package idemix
 
 import (
+	"fmt"
+	"sync/atomic"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
 	api2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
@@ -36,3 +39,62 @@ func TestIdentityCache(t *testing.T) {
 	assert.Equal(t, view.Identity([]byte("hello world")), id)
 	assert.Equal(t, []byte("audit"), audit)
 }
+
+func TestIdentityCacheDisabled(t *testing.T) {
+	var calls int
+	c := NewIdentityCache(
+		func(opts *api2.IdentityOptions) (view.Identity, []byte, error) {
+			calls++
+			return []byte(fmt.Sprintf("id%d", calls)), nil, nil
+		},
+		0,
+	)
+	// without a cache, the identities are generated when requested, in order
+	for i := 1; i <= 3; i++ {
+		id, _, err := c.Identity(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, view.Identity(fmt.Sprintf("id%d", i)), id)
+	}
+	assert.Equal(t, 3, calls)
+}
+
+func TestIdentityCacheSnapshot(t *testing.T) {
+	var calls int32
+	c := NewIdentityCache(
+		func(opts *api2.IdentityOptions) (view.Identity, []byte, error) {
+			if atomic.AddInt32(&calls, 1) == 2 {
+				return nil, nil, errors.New("no randomness")
+			}
+			return []byte("hello world"), nil, nil
+		},
+		0,
+	)
+	_, _, err := c.Identity(nil)
+	assert.NoError(t, err)
+	_, _, err = c.Identity(nil)
+	assert.EqualError(t, err, "no randomness")
+	_, _, err = c.Identity(&api2.IdentityOptions{EIDExtension: true, AuditInfo: []byte("audit")})
+	assert.NoError(t, err)
+
+	s := c.Snapshot()
+	assert.Equal(t, uint64(2), s.Generated)
+	assert.Equal(t, uint64(1), s.Failures)
+	assert.Equal(t, PoolSnapshot{Requests: 2, Misses: 2}, s.Pools[DefaultPool])
+	assert.Equal(t, PoolSnapshot{Requests: 1, Misses: 1}, s.Pools[EIDAuditPool])
+	assert.Equal(t, PoolSnapshot{}, s.Pools[EIDPool])
+	assert.Equal(t, 2, s.Latency.Samples)
+	assert.True(t, s.Latency.Min <= s.Latency.P50 && s.Latency.P50 <= s.Latency.Max)
+	if assert.Len(t, s.Errors, 1) {
+		assert.Equal(t, "no randomness", s.Errors[0].Error)
+		assert.False(t, s.Errors[0].Time.IsZero())
+	}
+
+	// the errors are bounded, the oldest are dropped
+	for i := 0; i < errorRecords+2; i++ {
+		c.stats.generation(0, errors.Errorf("error %d", i))
+	}
+	s = c.Snapshot()
+	assert.Len(t, s.Errors, errorRecords)
+	assert.Equal(t, "error 2", s.Errors[0].Error)
+	assert.Equal(t, fmt.Sprintf("error %d", errorRecords+1), s.Errors[errorRecords-1].Error)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package idemix
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"sync"
+
+	csp "github.com/IBM/idemix/bccsp"
+	bccsp "github.com/IBM/idemix/bccsp/schemes"
+	"github.com/IBM/idemix/bccsp/schemes/dlog/bridge"
+	idemix2 "github.com/IBM/idemix/bccsp/schemes/dlog/crypto"
+	"github.com/IBM/idemix/bccsp/schemes/dlog/handlers"
+	math "github.com/IBM/mathlib"
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/pkg/errors"
+)
+
+// DeterministicOptField is the key, in the options of an idemix msp, of the configuration of the deterministic mode
+const DeterministicOptField = "Deterministic"
+
+// DeterministicOpts configures the deterministic mode of an idemix msp.
+// The mode makes the pseudonyms and their proofs reproducible from the seed and it is meant for tests only:
+// it must never be enabled in production, where the pseudonyms of a node become linkable.
+type DeterministicOpts struct {
+	Seed string `yaml:"seed"`
+}
+
+// seedable is implemented by the random number generators of the amcl curves
+type seedable interface {
+	Clean()
+	Seed(rawlen int, raw []byte)
+}
+
+// deterministicRandomness feeds the generation of pseudonyms and of their proofs.
+// The i-th identity is generated from a generator seeded with the hash of the seed and i,
+// therefore two providers with the same seed generate the same sequence of identities.
+type deterministicRandomness struct {
+	// lock serializes the generation of the identities, it is held for the whole generation of one
+	lock    sync.Mutex
+	curve   *math.Curve
+	seed    []byte
+	counter uint64
+	rng     io.Reader
+}
+
+func newDeterministicRandomness(curve *math.Curve, seed []byte) (*deterministicRandomness, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("deterministic mode requires a non-empty seed")
+	}
+	d := &deterministicRandomness{curve: curve, seed: seed}
+	// fail now if the curve does not support seeding
+	if _, err := d.seeded(0); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// next moves to the generator of the next identity, the caller must hold the lock
+func (d *deterministicRandomness) next() error {
+	d.counter++
+	rng, err := d.seeded(d.counter)
+	if err != nil {
+		return err
+	}
+	d.rng = rng
+	return nil
+}
+
+func (d *deterministicRandomness) seeded(counter uint64) (io.Reader, error) {
+	rng, err := d.curve.Rand()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed getting random number generator")
+	}
+	// the generators of the curves cannot be seeded through the mathlib api, the amcl ones keep the seedable
+	// generator in their R field
+	v := reflect.ValueOf(rng)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("random number generator [%T] cannot be seeded", rng)
+	}
+	r := v.Elem().FieldByName("R")
+	if !r.IsValid() || !r.CanInterface() {
+		return nil, errors.Errorf("random number generator [%T] cannot be seeded", rng)
+	}
+	s, ok := r.Interface().(seedable)
+	if !ok {
+		return nil, errors.Errorf("random number generator [%T] cannot be seeded", rng)
+	}
+
+	h := sha256.New()
+	h.Write(d.seed)
+	c := make([]byte, 8)
+	binary.BigEndian.PutUint64(c, counter)
+	h.Write(c)
+	raw := h.Sum(nil)
+	s.Clean()
+	s.Seed(len(raw), raw)
+	return rng, nil
+}
+
+// reader returns the generator of the identity being generated
+func (d *deterministicRandomness) reader() io.Reader {
+	if d.rng != nil {
+		return d.rng
+	}
+	rng, err := d.curve.Rand()
+	if err != nil {
+		panic(err)
+	}
+	return rng
+}
+
+// install replaces the nym derivation and the signer of the user secret keys of the passed crypto provider
+// with ones drawing from this randomness. The other signatures of the user secret keys are left as they are.
+func (d *deterministicRandomness) install(base *csp.CSP, tr idemix2.Translator) {
+	idmx := &idemix2.Idemix{Curve: d.curve, Translator: tr}
+	userSecretKeyType := reflect.TypeOf(handlers.NewUserSecretKey(nil, true))
+
+	base.KeyDerivers[userSecretKeyType] = &handlers.NymKeyDerivation{
+		Exportable: true,
+		User:       &deterministicUser{User: &bridge.User{Idemix: idmx, Translator: tr}, randomness: d},
+		Translator: tr,
+	}
+	base.Signers[userSecretKeyType] = &deterministicSigner{
+		Signer: base.Signers[userSecretKeyType],
+		signer: &handlers.Signer{SignatureScheme: &deterministicSignatureScheme{
+			SignatureScheme: &bridge.SignatureScheme{Idemix: idmx, Translator: tr},
+			randomness:      d,
+		}},
+	}
+}
+
+type deterministicSigner struct {
+	csp.Signer
+	signer *handlers.Signer
+}
+
+func (s *deterministicSigner) Sign(k bccsp.Key, digest []byte, opts bccsp.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*bccsp.IdemixSignerOpts); ok {
+		return s.signer.Sign(k, digest, opts)
+	}
+	return s.Signer.Sign(k, digest, opts)
+}
+
+type deterministicUser struct {
+	*bridge.User
+	randomness *deterministicRandomness
+}
+
+func (u *deterministicUser) MakeNym(sk *math.Zr, ipk handlers.IssuerPublicKey) (r1 *math.G1, r2 *math.Zr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			r1 = nil
+			r2 = nil
+			err = errors.Errorf("failure [%s]", r)
+		}
+	}()
+
+	iipk, ok := ipk.(*bridge.IssuerPublicKey)
+	if !ok {
+		return nil, nil, errors.Errorf("invalid issuer public key, expected *IssuerPublicKey, got [%T]", ipk)
+	}
+	return u.Idemix.MakeNym(sk, iipk.PK, u.randomness.reader(), u.Translator)
+}
+
+type deterministicSignatureScheme struct {
+	*bridge.SignatureScheme
+	randomness *deterministicRandomness
+}
+
+func (s *deterministicSignatureScheme) Sign(cred []byte, sk *math.Zr, Nym *math.G1, RNym *math.Zr, ipk handlers.IssuerPublicKey, attributes []bccsp.IdemixAttribute,
+	msg []byte, rhIndex, eidIndex int, criRaw []byte, sigType bccsp.SignatureType, metadata *bccsp.IdemixSignerMetadata) (res []byte, meta *bccsp.IdemixSignerMetadata, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = nil
+			err = errors.Errorf("failure [%s]", r)
+		}
+	}()
+
+	iipk, ok := ipk.(*bridge.IssuerPublicKey)
+	if !ok {
+		return nil, nil, errors.Errorf("invalid issuer public key, expected *IssuerPublicKey, got [%T]", ipk)
+	}
+	credential := &idemix2.Credential{}
+	if err := proto.Unmarshal(cred, credential); err != nil {
+		return nil, nil, errors.Wrap(err, "failed unmarshalling credential")
+	}
+	cri := &idemix2.CredentialRevocationInformation{}
+	if err := proto.Unmarshal(criRaw, cri); err != nil {
+		return nil, nil, errors.Wrap(err, "failed unmarshalling credential revocation information")
+	}
+	disclosure := make([]byte, len(attributes))
+	for i := 0; i < len(attributes); i++ {
+		if attributes[i].Type != bccsp.IdemixHiddenAttribute {
+			disclosure[i] = 1
+		}
+	}
+
+	sig, meta, err := s.Idemix.NewSignature(credential, sk, Nym, RNym, iipk.PK, disclosure, msg, rhIndex, eidIndex, cri,
+		s.randomness.reader(), s.Translator, sigType, metadata)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed creating new signature")
+	}
+	sigBytes, err := proto.Marshal(sig)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "marshalling error")
+	}
+	return sigBytes, meta, nil
+}
@@ -10,10 +10,12 @@ import (
 	"io/ioutil"
 	"path/filepath"
 
+	math "github.com/IBM/mathlib"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
 	msp2 "github.com/hyperledger/fabric/msp"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -27,7 +29,19 @@ func (i *IdentityLoader) Load(manager driver.Manager, c config.MSP) error {
 	if err != nil {
 		return errors.Wrapf(err, "failed reading idemix msp configuration from [%s]", manager.Config().TranslatePath(c.Path))
 	}
-	provider, err := NewAnyProvider(conf, manager.ServiceProvider())
+	deterministicOpts, err := toDeterministicOpts(c.Opts)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal deterministic opts of idemix msp [%s]", c.ID)
+	}
+	var provider *provider
+	if deterministicOpts != nil {
+		logger.Warnf("!!! DETERMINISTIC MODE ENABLED FOR IDEMIX MSP [%s] !!! "+
+			"Its pseudonyms are reproducible from the configured seed and therefore linkable. "+
+			"This mode is meant for tests only and MUST NEVER be enabled in production.", c.ID)
+		provider, err = NewDeterministicProvider(conf, manager.ServiceProvider(), Any, math.FP256BN_AMCL, []byte(deterministicOpts.Seed))
+	} else {
+		provider, err = NewAnyProvider(conf, manager.ServiceProvider())
+	}
 	if err != nil {
 		return errors.Wrapf(err, "failed instantiating idemix msp provider from [%s]", manager.Config().TranslatePath(c.Path))
 	}
@@ -36,12 +50,40 @@ func (i *IdentityLoader) Load(manager driver.Manager, c config.MSP) error {
 	if c.CacheSize > 0 {
 		cacheSize = c.CacheSize
 	}
-	manager.AddMSP(c.ID, c.MSPType, provider.EnrollmentID(), NewIdentityCache(provider.Identity, cacheSize).Identity)
+	if deterministicOpts != nil {
+		// identities prepared in the background would be handed out in a non-deterministic order
+		cacheSize = 0
+	}
+	cache := NewIdentityCache(provider.Identity, cacheSize)
+	manager.AddMSP(c.ID, c.MSPType, provider.EnrollmentID(), cache.Identity)
+	if r, ok := manager.(driver.SnapshotRegistry); ok {
+		r.AddSnapshot(c.ID, func() interface{} { return TakeSnapshot(provider, cache) })
+	}
 	logger.Debugf("added %s msp for id %s with cache of size %d", c.MSPType, c.ID+"@"+provider.EnrollmentID(), cacheSize)
 
 	return nil
 }
 
+// toDeterministicOpts returns the deterministic mode configuration in the passed msp options, if any
+func toDeterministicOpts(opts map[interface{}]interface{}) (*DeterministicOpts, error) {
+	boxed, ok := opts[DeterministicOptField]
+	if !ok {
+		return nil, nil
+	}
+	raw, err := yaml.Marshal(boxed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal")
+	}
+	res := &DeterministicOpts{}
+	if err := yaml.Unmarshal(raw, res); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal")
+	}
+	if len(res.Seed) == 0 {
+		return nil, errors.New("deterministic mode requires a non-empty seed")
+	}
+	return res, nil
+}
+
 type FolderIdentityLoader struct {
 	*IdentityLoader
 }
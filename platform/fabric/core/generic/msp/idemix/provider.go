@@ -62,6 +62,9 @@ type provider struct {
 
 	sigType bccsp.SignatureType
 	verType bccsp.VerificationType
+
+	// randomness, if set, makes the identities reproducible
+	randomness *deterministicRandomness
 }
 
 func NewEIDNymProvider(conf1 *m.MSPConfig, sp view2.ServiceProvider) (*provider, error) {
@@ -85,6 +88,20 @@ func NewProviderWithSigType(conf1 *m.MSPConfig, sp view2.ServiceProvider, sigTyp
 }
 
 func NewProvider(conf1 *m.MSPConfig, sp view2.ServiceProvider, sigType bccsp.SignatureType, curveID math.CurveID) (*provider, error) {
+	return newProvider(conf1, sp, sigType, curveID, nil)
+}
+
+// NewDeterministicProvider returns a provider whose pseudonyms and proofs are generated from the passed seed:
+// two providers with the same configuration and seed generate the same sequence of identities.
+// It is meant for reproducible tests and must never be used in production.
+func NewDeterministicProvider(conf1 *m.MSPConfig, sp view2.ServiceProvider, sigType bccsp.SignatureType, curveID math.CurveID, seed []byte) (*provider, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("deterministic mode requires a non-empty seed")
+	}
+	return newProvider(conf1, sp, sigType, curveID, seed)
+}
+
+func newProvider(conf1 *m.MSPConfig, sp view2.ServiceProvider, sigType bccsp.SignatureType, curveID math.CurveID, seed []byte) (*provider, error) {
 	logger.Debugf("Setting up Idemix-based MSP instance")
 
 	if conf1 == nil {
@@ -115,6 +132,14 @@ func NewProvider(conf1 *m.MSPConfig, sp view2.ServiceProvider, sigType bccsp.Sig
 	if err != nil {
 		return nil, errors.Wrap(err, "failed getting crypto provider")
 	}
+	var randomness *deterministicRandomness
+	if len(seed) != 0 {
+		randomness, err = newDeterministicRandomness(curve, seed)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "deterministic mode not supported on curve [%d]", curveID)
+		}
+		randomness.install(cryptoProvider.CSP, tr)
+	}
 
 	var conf m.IdemixMSPConfig
 	err = proto.Unmarshal(conf1.Config, &conf)
@@ -213,15 +238,24 @@ func NewProvider(conf1 *m.MSPConfig, sp view2.ServiceProvider, sigType bccsp.Sig
 			epoch:           0,
 			VerType:         verType,
 		},
-		userKey: userKey,
-		conf:    conf,
-		sp:      sp,
-		sigType: sigType,
-		verType: verType,
+		userKey:    userKey,
+		conf:       conf,
+		sp:         sp,
+		sigType:    sigType,
+		verType:    verType,
+		randomness: randomness,
 	}, nil
 }
 
 func (p *provider) Identity(opts *driver2.IdentityOptions) (view.Identity, []byte, error) {
+	if p.randomness != nil {
+		p.randomness.lock.Lock()
+		defer p.randomness.lock.Unlock()
+		if err := p.randomness.next(); err != nil {
+			return nil, nil, errors.WithMessage(err, "failed seeding the generation of the identity")
+		}
+	}
+
 	// Derive NymPublicKey
 	nymKey, err := p.Csp.KeyDeriv(
 		p.userKey,
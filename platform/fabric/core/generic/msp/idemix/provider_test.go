@@ -7,10 +7,14 @@ SPDX-License-Identifier: Apache-2.0
 package idemix_test
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"strings"
 	"testing"
 
 	bccsp "github.com/IBM/idemix/bccsp/schemes"
+	math "github.com/IBM/mathlib"
 	idemix2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/idemix"
 	driver2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	sig2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
@@ -278,3 +282,101 @@ func TestProvider_DeserializeSigner(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, verifier.Verify(msg, sigma))
 }
+
+func TestDeterministicIdentity(t *testing.T) {
+	registry := registry2.New()
+
+	kvss, err := kvs.NewWithConfig(registry, "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+	sigService := sig2.NewSignService(registry, nil, kvss)
+	assert.NoError(t, registry.RegisterService(sigService))
+
+	config, err := msp2.GetLocalMspConfigWithType("./testdata/idemix", nil, "idemix", "idemix")
+	assert.NoError(t, err)
+
+	_, err = idemix2.NewDeterministicProvider(config, registry, bccsp.EidNym, math.FP256BN_AMCL, nil)
+	assert.Error(t, err)
+	_, err = idemix2.NewDeterministicProvider(config, registry, bccsp.EidNym, math.BN254, []byte("seed"))
+	assert.Error(t, err)
+
+	run := func(seed string) ([][]byte, [][]byte) {
+		p, err := idemix2.NewDeterministicProvider(config, registry, bccsp.EidNym, math.FP256BN_AMCL, []byte(seed))
+		assert.NoError(t, err)
+		var ids, audits [][]byte
+		for i := 0; i < 3; i++ {
+			id, audit, err := p.Identity(nil)
+			assert.NoError(t, err)
+			ids = append(ids, id)
+			audits = append(audits, audit)
+		}
+		return ids, audits
+	}
+
+	// two runs with the same seed produce the same identities
+	ids, audits := run("seed")
+	ids2, audits2 := run("seed")
+	assert.Equal(t, ids, ids2)
+	assert.Equal(t, audits, audits2)
+	// the identities of a run are still unlinkable pseudonyms
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.NotEqual(t, ids[1], ids[2])
+	// another seed produces other identities
+	ids3, _ := run("another seed")
+	assert.NotEqual(t, ids[0], ids3[0])
+
+	// the identities are valid
+	p, err := idemix2.NewEIDNymProvider(config, registry)
+	assert.NoError(t, err)
+	for i, id := range ids {
+		auditInfo, err := p.DeserializeAuditInfo(audits[i])
+		assert.NoError(t, err)
+		assert.NoError(t, auditInfo.Match(id))
+
+		signer, err := p.DeserializeSigner(id)
+		assert.NoError(t, err)
+		verifier, err := p.DeserializeVerifier(id)
+		assert.NoError(t, err)
+		sigma, err := signer.Sign([]byte("hello world!!!"))
+		assert.NoError(t, err)
+		assert.NoError(t, verifier.Verify([]byte("hello world!!!"), sigma))
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	registry := registry2.New()
+
+	kvss, err := kvs.NewWithConfig(registry, "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+	sigService := sig2.NewSignService(registry, nil, kvss)
+	assert.NoError(t, registry.RegisterService(sigService))
+
+	config, err := msp2.GetLocalMspConfigWithType("./testdata/idemix", nil, "idemix", "idemix")
+	assert.NoError(t, err)
+
+	p, err := idemix2.NewAnyProvider(config, registry)
+	assert.NoError(t, err)
+	cache := idemix2.NewIdentityCache(p.Identity, 0)
+	id, _, err := cache.Identity(nil)
+	assert.NoError(t, err)
+
+	s := idemix2.TakeSnapshot(p, cache)
+	assert.Equal(t, "idemix", s.MSPID)
+	assert.Len(t, s.IssuerPublicKeyFingerprint, 64)
+	assert.Equal(t, "any", s.SignatureType)
+	assert.False(t, s.Deterministic)
+	assert.Equal(t, uint64(1), s.Generated)
+
+	// the snapshot does not carry the pseudonyms
+	raw, err := json.Marshal(s)
+	assert.NoError(t, err)
+	d, err := p.Deserialize(id, true)
+	assert.NoError(t, err)
+	nym, err := d.NymPublicKey.Bytes()
+	assert.NoError(t, err)
+	for _, secret := range [][]byte{id, nym} {
+		assert.NotContains(t, string(raw), base64.StdEncoding.EncodeToString(secret))
+		assert.NotContains(t, string(raw), hex.EncodeToString(secret))
+	}
+}
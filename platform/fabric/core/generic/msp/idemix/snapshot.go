@@ -0,0 +1,230 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package idemix
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bccsp "github.com/IBM/idemix/bccsp/schemes"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+)
+
+const (
+	// DefaultPool is the pool of the identities requested without options, the only one prepared in the background
+	DefaultPool = "default"
+	// EIDPool is the pool of the identities requested with the enrollment ID extension
+	EIDPool = "eid"
+	// AuditPool is the pool of the identities requested with audit information
+	AuditPool = "audit"
+	// EIDAuditPool is the pool of the identities requested with both the enrollment ID extension and audit information
+	EIDAuditPool = "eid+audit"
+
+	latencySamples = 1024
+	errorRecords   = 16
+)
+
+// Snapshot describes the state of an idemix MSP for debugging purposes.
+// It carries neither key material nor pseudonyms, the identities generated cannot be linked through it.
+type Snapshot struct {
+	MSPID string `json:"mspID"`
+	// IssuerPublicKeyFingerprint is the hex encoded SHA-256 hash of the serialized issuer public key
+	IssuerPublicKeyFingerprint string `json:"issuerPublicKeyFingerprint"`
+	RevocationEpoch            int    `json:"revocationEpoch"`
+	SignatureType              string `json:"signatureType"`
+	Deterministic              bool   `json:"deterministic"`
+	*CacheSnapshot
+}
+
+// CacheSnapshot describes the state of an identity cache
+type CacheSnapshot struct {
+	// Pools are the identity requests by options shape, see DefaultPool, EIDPool, AuditPool, and EIDAuditPool
+	Pools map[string]PoolSnapshot `json:"pools"`
+	// Generated is the number of identities generated since the cache was created
+	Generated uint64 `json:"generated"`
+	// Failures is the number of generations failed since the cache was created
+	Failures uint64 `json:"failures"`
+	// Latency is the distribution of the latency of the last generations
+	Latency LatencySnapshot `json:"latency"`
+	// Errors are the last errors of the generations, the oldest first
+	Errors []ErrorRecord `json:"errors"`
+}
+
+// PoolSnapshot describes the requests of identities of an options shape
+type PoolSnapshot struct {
+	// Depth is the number of identities ready to be handed out
+	Depth int `json:"depth"`
+	// Capacity is the number of identities the pool prepares in the background, 0 if the pool is not cached
+	Capacity int `json:"capacity"`
+	// Requests is the number of identities requested
+	Requests uint64 `json:"requests"`
+	// Misses is the number of requests served by generating an identity on the spot
+	Misses uint64 `json:"misses"`
+}
+
+// LatencySnapshot is the distribution of the latency of the last generations of identities
+type LatencySnapshot struct {
+	Samples int           `json:"samples"`
+	Min     time.Duration `json:"min"`
+	Mean    time.Duration `json:"mean"`
+	P50     time.Duration `json:"p50"`
+	P90     time.Duration `json:"p90"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+}
+
+// ErrorRecord is an error of the generation of an identity
+type ErrorRecord struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// TakeSnapshot returns the snapshot of the idemix MSP made of the passed provider and cache.
+// Taking it does not slow down the generation of identities.
+func TakeSnapshot(p *provider, c *IdentityCache) *Snapshot {
+	fingerprint := sha256.Sum256(p.conf.Ipk)
+	return &Snapshot{
+		MSPID:                      p.name,
+		IssuerPublicKeyFingerprint: hex.EncodeToString(fingerprint[:]),
+		RevocationEpoch:            p.epoch,
+		SignatureType:              signatureTypeName(p.verType),
+		Deterministic:              p.randomness != nil,
+		CacheSnapshot:              c.Snapshot(),
+	}
+}
+
+func signatureTypeName(verType bccsp.VerificationType) string {
+	switch verType {
+	case bccsp.ExpectStandard:
+		return "standard"
+	case bccsp.ExpectEidNym:
+		return "eidNym"
+	case bccsp.BestEffort:
+		return "any"
+	default:
+		return "unknown"
+	}
+}
+
+// cacheStats collects the statistics of an identity cache. The counters are updated atomically
+// and the lock guards only the copy of the latency samples and errors.
+type cacheStats struct {
+	generated uint64
+	failures  uint64
+	requests  [4]uint64
+	misses    [4]uint64
+
+	lock      sync.Mutex
+	latencies [latencySamples]time.Duration
+	latencyN  int
+	errors    [errorRecords]ErrorRecord
+	errorN    int
+}
+
+var poolNames = [4]string{DefaultPool, EIDPool, AuditPool, EIDAuditPool}
+
+// poolOf returns the index in poolNames of the pool of the passed options
+func poolOf(opts *driver.IdentityOptions) int {
+	if opts == nil {
+		return 0
+	}
+	pool := 0
+	if opts.EIDExtension {
+		pool |= 1
+	}
+	if len(opts.AuditInfo) != 0 {
+		pool |= 2
+	}
+	return pool
+}
+
+func (s *cacheStats) request(opts *driver.IdentityOptions) {
+	atomic.AddUint64(&s.requests[poolOf(opts)], 1)
+}
+
+func (s *cacheStats) miss(opts *driver.IdentityOptions) {
+	atomic.AddUint64(&s.misses[poolOf(opts)], 1)
+}
+
+func (s *cacheStats) generation(latency time.Duration, err error) {
+	if err != nil {
+		atomic.AddUint64(&s.failures, 1)
+	} else {
+		atomic.AddUint64(&s.generated, 1)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		s.errors[s.errorN%errorRecords] = ErrorRecord{Time: time.Now(), Error: err.Error()}
+		s.errorN++
+		return
+	}
+	s.latencies[s.latencyN%latencySamples] = latency
+	s.latencyN++
+}
+
+func (s *cacheStats) snapshot(depth, capacity int) *CacheSnapshot {
+	s.lock.Lock()
+	latencies := make([]time.Duration, min(s.latencyN, latencySamples))
+	copy(latencies, s.latencies[:len(latencies)])
+	errs := make([]ErrorRecord, 0, min(s.errorN, errorRecords))
+	for i := s.errorN - cap(errs); i < s.errorN; i++ {
+		errs = append(errs, s.errors[i%errorRecords])
+	}
+	s.lock.Unlock()
+
+	res := &CacheSnapshot{
+		Pools:     map[string]PoolSnapshot{},
+		Generated: atomic.LoadUint64(&s.generated),
+		Failures:  atomic.LoadUint64(&s.failures),
+		Latency:   latencyOf(latencies),
+		Errors:    errs,
+	}
+	for i, name := range poolNames {
+		pool := PoolSnapshot{Requests: atomic.LoadUint64(&s.requests[i]), Misses: atomic.LoadUint64(&s.misses[i])}
+		if i == 0 {
+			pool.Depth, pool.Capacity = depth, capacity
+		}
+		res.Pools[name] = pool
+	}
+	return res
+}
+
+func latencyOf(samples []time.Duration) LatencySnapshot {
+	if len(samples) == 0 {
+		return LatencySnapshot{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	var total time.Duration
+	for _, sample := range samples {
+		total += sample
+	}
+	percentile := func(p int) time.Duration {
+		return samples[(len(samples)-1)*p/100]
+	}
+	return LatencySnapshot{
+		Samples: len(samples),
+		Min:     samples[0],
+		Mean:    total / time.Duration(len(samples)),
+		P50:     percentile(50),
+		P90:     percentile(90),
+		P99:     percentile(99),
+		Max:     samples[len(samples)-1],
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,190 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package msp
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/idemix"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
+	vdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownMSPType is reported by Check for the MSPs whose type has no loader, they are skipped on startup
+var ErrUnknownMSPType = errors.New("msp type not recognized")
+
+// probeMessage is the message the signers of the MSPs checked sign
+var probeMessage = []byte("fsc dry run")
+
+// NewReadOnlyMSPManager returns a manager loading the MSPs with the loaders NewLocalMSPManager has, to check them:
+// the identities are neither enrolled nor renewed, the signers and the deserializers the loaders register are kept
+// by the manager, and the KVS of the idemix MSPs is in memory. Nothing reaches the services of the passed provider.
+func NewReadOnlyMSPManager(sp view2.ServiceProvider, config driver.Config, cacheSize int) *service {
+	signers := &signers{m: map[string]signer{}}
+	s := NewLocalMSPManager(&readOnlyServices{ServiceProvider: sp, signers: signers}, config, signers, nil, nil, cacheSize)
+	s.signers = signers
+	return s
+}
+
+// ReadOnly returns true if the manager loads the MSPs to check them only, see NewReadOnlyMSPManager
+func (s *service) ReadOnly() bool {
+	return s.signers != nil
+}
+
+// Check loads the configured MSPs, as Load does, and probes the signer of the identity of each of them with
+// a signature. The passed function is called with the outcome of each MSP: the MSPs found in a folder are checked one
+// by one. It fails if the configuration cannot be read, or if the default identity is not set once loaded.
+// Only read-only managers check their MSPs.
+func (s *service) Check(check func(id string, err error)) error {
+	if !s.ReadOnly() {
+		return errors.New("the msps are checked by read-only managers only")
+	}
+	s.mspsMutex.Lock()
+	defer s.mspsMutex.Unlock()
+
+	configs, err := s.config.MSPs()
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading local MSP configs")
+	}
+	if err := s.setDefaultMSP(configs); err != nil {
+		return err
+	}
+	for _, config := range configs {
+		loader, ok := s.identityLoaders[config.MSPType]
+		if !ok {
+			check(config.ID, errors.Wrapf(ErrUnknownMSPType, "msp [%s] of type [%s] skipped", config.ID, config.MSPType))
+			continue
+		}
+		loaded := len(s.msps)
+		if err := loader.Load(s, config); err != nil {
+			check(config.ID, errors.WithMessagef(err, "failed to load msp [%s:%s] at [%s]", config.ID, config.MSPType, config.Path))
+			continue
+		}
+		for _, m := range s.msps[loaded:] {
+			check(m.Name, s.probe(m))
+		}
+	}
+	if s.defaultIdentity == nil {
+		return errors.Errorf("no default identity set for network [%s]", s.config.Name())
+	}
+	return nil
+}
+
+// probe signs a message with the signer registered for the identity of the passed MSP, and verifies the signature
+func (s *service) probe(m *driver.MSP) error {
+	id, _, err := m.GetIdentity(nil)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting the identity of msp [%s]", m.Name)
+	}
+	signer, ok := s.signers.get(id)
+	if !ok {
+		return errors.Errorf("no signer registered for the identity of msp [%s]", m.Name)
+	}
+	sigma, err := signer.Sign(probeMessage)
+	if err != nil {
+		return errors.WithMessagef(err, "failed signing with the identity of msp [%s]", m.Name)
+	}
+	if err := signer.Verify(probeMessage, sigma); err != nil {
+		return errors.WithMessagef(err, "failed verifying the signature of the identity of msp [%s]", m.Name)
+	}
+	return nil
+}
+
+type signer struct {
+	fdriver.Signer
+	fdriver.Verifier
+}
+
+// signers keeps the signers registered by the loaders of a read-only manager
+type signers struct {
+	lock sync.Mutex
+	m    map[string]signer
+}
+
+func (s *signers) RegisterSigner(identity view.Identity, sgn fdriver.Signer, verifier fdriver.Verifier) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.m[identity.UniqueID()] = signer{Signer: sgn, Verifier: verifier}
+	return nil
+}
+
+func (s *signers) get(identity view.Identity) (signer, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res, ok := s.m[identity.UniqueID()]
+	return res, ok
+}
+
+// idemixSigners registers the signers of the idemix identities in signers
+type idemixSigners struct {
+	*signers
+}
+
+func (s *idemixSigners) RegisterSigner(identity view.Identity, sgn vdriver.Signer, verifier vdriver.Verifier) error {
+	return s.signers.RegisterSigner(identity, sgn, verifier)
+}
+
+var (
+	idemixSignerServiceType   = reflect.TypeOf((*idemix.SignerService)(nil))
+	deserializerManagerType   = reflect.TypeOf((*driver.DeserializerManager)(nil))
+	readOnlyKVSConfigProvider = &emptyConfig{}
+)
+
+// readOnlyServices are the services of the loaders of a read-only manager: the signers and the deserializers are
+// kept by the manager, the KVS is in memory, the other services are the ones of the node
+type readOnlyServices struct {
+	view2.ServiceProvider
+	signers *signers
+
+	lock sync.Mutex
+	kvs  *kvs.KVS
+}
+
+func (r *readOnlyServices) GetService(v interface{}) (interface{}, error) {
+	switch v {
+	case idemixSignerServiceType:
+		return &idemixSigners{signers: r.signers}, nil
+	case deserializerManagerType:
+		return r, nil
+	}
+	if _, ok := v.(*kvs.KVS); ok {
+		return r.memoryKVS()
+	}
+	return r.ServiceProvider.GetService(v)
+}
+
+// AddDeserializer drops the passed deserializer, the identities loaded are not deserialized
+func (r *readOnlyServices) AddDeserializer(sig.Deserializer) {}
+
+func (r *readOnlyServices) memoryKVS() (*kvs.KVS, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.kvs == nil {
+		k, err := kvs.NewWithConfig(r, "memory", "", readOnlyKVSConfigProvider)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed opening the in-memory kvs of the msps checked")
+		}
+		r.kvs = k
+	}
+	return r.kvs, nil
+}
+
+// emptyConfig is the configuration of the in-memory KVS, with nothing set
+type emptyConfig struct{}
+
+func (e *emptyConfig) UnmarshalKey(string, interface{}) error { return nil }
+
+func (e *emptyConfig) IsSet(string) bool { return false }
+
+func (e *emptyConfig) GetInt(string) int { return 0 }
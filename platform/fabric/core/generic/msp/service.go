@@ -11,6 +11,7 @@ import (
 	"reflect"
 	"sync"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/idemix"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/x509"
@@ -49,7 +50,11 @@ type service struct {
 	mspsByEnrollmentID  map[string]*driver.MSP
 	mspsByTypeAndName   map[string]*driver.MSP
 	bccspMspsByIdentity map[string]*driver.MSP
+	renewals            map[string]func()
+	snapshots           map[string]func() interface{}
 	cacheSize           int
+	// signers are the signers registered by the loaders of a read-only manager, nil otherwise
+	signers *signers
 }
 
 func NewLocalMSPManager(
@@ -72,6 +77,8 @@ func NewLocalMSPManager(
 		mspsByName:          map[string]*driver.MSP{},
 		cacheSize:           cacheSize,
 		identityLoaders:     map[string]driver.IdentityLoader{},
+		renewals:            map[string]func(){},
+		snapshots:           map[string]func() interface{}{},
 	}
 	s.PutIdentityLoader(BccspMSP, &x509.IdentityLoader{})
 	s.PutIdentityLoader(BccspMSPFolder, &x509.FolderIdentityLoader{})
@@ -118,6 +125,8 @@ func (s *service) SetDefaultIdentity(id string, defaultIdentity view.Identity, d
 }
 
 func (s *service) DefaultIdentity() view.Identity {
+	s.mspsMutex.RLock()
+	defer s.mspsMutex.RUnlock()
 	return s.defaultIdentity
 }
 
@@ -145,6 +154,8 @@ func (s *service) IsMe(id view.Identity) bool {
 }
 
 func (s *service) DefaultSigningIdentity() fdriver.SigningIdentity {
+	s.mspsMutex.RLock()
+	defer s.mspsMutex.RUnlock()
 	return s.defaultSigningIdentity
 }
 
@@ -246,7 +257,9 @@ func (s *service) RegisterIdemixMSP(id string, path string, mspID string) error
 	}
 
 	s.DeserializerManager().AddDeserializer(provider)
-	s.AddMSP(id, IdemixMSP, provider.EnrollmentID(), idemix.NewIdentityCache(provider.Identity, s.cacheSize).Identity)
+	cache := idemix.NewIdentityCache(provider.Identity, s.cacheSize)
+	s.AddMSP(id, IdemixMSP, provider.EnrollmentID(), cache.Identity)
+	s.AddSnapshot(id, func() interface{} { return idemix.TakeSnapshot(provider, cache) })
 	logger.Debugf("added IdemixMSP msp for id %s with cache of size %d", id+"@"+provider.EnrollmentID(), s.cacheSize)
 	return nil
 }
@@ -270,6 +283,14 @@ func (s *service) Refresh() error {
 	s.mspsMutex.Lock()
 	defer s.mspsMutex.Unlock()
 
+	// stop the renewals, they are restarted by the loaders
+	for name, stop := range s.renewals {
+		logger.Debugf("stop renewal of msp [%s]", name)
+		stop()
+	}
+	s.renewals = map[string]func(){}
+	s.snapshots = map[string]func() interface{}{}
+
 	// clean cashes
 	s.msps = nil
 	s.mspsByTypeAndName = map[string]*driver.MSP{}
@@ -320,6 +341,59 @@ func (s *service) AddMSP(name string, mspType string, enrollmentID string, Ident
 	s.msps = append(s.msps, msp)
 }
 
+// UpdateIdentity makes the passed identity, re-enrolled, the identity of the bccsp msp of the passed name
+func (s *service) UpdateIdentity(name string, identity view.Identity, signingIdentity driver.SigningIdentity) error {
+	s.mspsMutex.Lock()
+	defer s.mspsMutex.Unlock()
+
+	msp, ok := s.mspsByTypeAndName[BccspMSP+name]
+	if !ok {
+		return errors.Errorf("bccsp msp [%s] not found", name)
+	}
+	if s.binderService != nil {
+		if err := s.binderService.Bind(s.defaultViewIdentity, identity); err != nil {
+			return errors.WithMessagef(err, "cannot bind identity for [%s]", name)
+		}
+	}
+	// the previous identity stays indexed, the transactions in flight refer to it
+	s.bccspMspsByIdentity[identity.String()] = msp
+	if name == s.defaultMSP {
+		logger.Infof("update default identity of [%s]", name)
+		s.defaultIdentity = identity
+		s.defaultSigningIdentity = signingIdentity
+	}
+	return nil
+}
+
+// AddRenewal registers the stop function of the renewal of the msp of the passed name, it is called by the loaders
+func (s *service) AddRenewal(name string, stop func()) {
+	if previous, ok := s.renewals[name]; ok {
+		previous()
+	}
+	s.renewals[name] = stop
+}
+
+// AddSnapshot registers the snapshot function of the msp of the passed name, it is called by the loaders
+func (s *service) AddSnapshot(name string, snapshot func() interface{}) {
+	s.snapshots[name] = snapshot
+}
+
+// Snapshots returns the debug snapshots of the MSPs, by name
+func (s *service) Snapshots() map[string]interface{} {
+	s.mspsMutex.RLock()
+	snapshots := make(map[string]func() interface{}, len(s.snapshots))
+	for name, snapshot := range s.snapshots {
+		snapshots[name] = snapshot
+	}
+	s.mspsMutex.RUnlock()
+
+	res := make(map[string]interface{}, len(snapshots))
+	for name, snapshot := range snapshots {
+		res[name] = snapshot()
+	}
+	return res
+}
+
 func (s *service) PutIdentityLoader(idType string, loader driver.IdentityLoader) {
 	s.mspsMutex.Lock()
 	defer s.mspsMutex.Unlock()
@@ -361,13 +435,8 @@ func (s *service) loadLocalMSPs() error {
 	if err != nil {
 		return errors.WithMessagef(err, "failed loading local MSP configs")
 	}
-	s.defaultMSP = s.config.DefaultMSP()
-	if len(s.defaultMSP) == 0 {
-		if len(configs) == 0 {
-			return errors.New("default MSP not configured and no MSPs set")
-		}
-		logger.Warnf("default MSP not configured, set it to [%s]", configs[0].ID)
-		s.defaultMSP = configs[0].ID
+	if err := s.setDefaultMSP(configs); err != nil {
+		return err
 	}
 
 	logger.Debugf("Local Local [%d] MSPS using default [%s]", len(configs), s.defaultMSP)
@@ -388,3 +457,16 @@ func (s *service) loadLocalMSPs() error {
 
 	return nil
 }
+
+// setDefaultMSP sets the default MSP to the configured one, or to the first of the passed configurations
+func (s *service) setDefaultMSP(configs []config.MSP) error {
+	s.defaultMSP = s.config.DefaultMSP()
+	if len(s.defaultMSP) == 0 {
+		if len(configs) == 0 {
+			return errors.New("default MSP not configured and no MSPs set")
+		}
+		logger.Warnf("default MSP not configured, set it to [%s]", configs[0].ID)
+		s.defaultMSP = configs[0].ID
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 
 	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	msp2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/idemix"
 	mock2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/mock"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
@@ -79,6 +80,13 @@ func TestIdemixTypeFolder(t *testing.T) {
 	for _, s := range mspService.Msps()[:4] {
 		assert.NotNil(t, mspService.GetIdentityInfoByLabel("idemix", s))
 	}
+
+	// the idemix msps loaded from the folder have a snapshot each
+	snapshots := mspService.Snapshots()
+	assert.Len(t, snapshots, 4)
+	for _, s := range mspService.Msps()[:4] {
+		assert.IsType(t, &idemix.Snapshot{}, snapshots[s])
+	}
 }
 
 func TestRegisterX509LocalMSP(t *testing.T) {
@@ -192,3 +200,21 @@ func TestRefresh(t *testing.T) {
 		assert.NotNil(t, mspService.GetIdentityInfoByLabel(msp2.BccspMSP, s))
 	}
 }
+
+func TestReadOnlyCheck(t *testing.T) {
+	// the registry has no service, nothing reaches it
+	registry := registry2.New()
+	cp, err := config.NewProvider("./testdata/idemixtypefolder")
+	assert.NoError(t, err)
+	config, err := config2.New(cp, "default", true)
+	assert.NoError(t, err)
+
+	mspService := msp2.NewReadOnlyMSPManager(registry, config, 100)
+	assert.True(t, mspService.ReadOnly())
+	checked := map[string]error{}
+	assert.NoError(t, mspService.Check(func(id string, err error) { checked[id] = err }))
+	assert.Equal(t, map[string]error{"idemix": nil, "manager.id1": nil, "manager.id2": nil, "manager.id3": nil, "apple": nil}, checked)
+
+	// the managers of the node do not check their msps
+	assert.Error(t, msp2.NewLocalMSPManager(registry, config, nil, nil, nil, 100).Check(func(string, error) {}))
+}
@@ -127,6 +127,11 @@ func NewVerifier(pk *ecdsa.PublicKey) *edsaVerifier {
 	return &edsaVerifier{pk: pk}
 }
 
+// NewEcdsaSigner returns a signer producing low-S signatures, with the passed key, of the SHA-256 digest of the messages
+func NewEcdsaSigner(sk *ecdsa.PrivateKey) *edsaSigner {
+	return &edsaSigner{sk: sk}
+}
+
 func NewIdentityFromBytes(raw []byte) (view.Identity, driver.Verifier, error) {
 	mspSI := &msp.SerializedIdentity{}
 	err := proto.Unmarshal(raw, mspSI)
@@ -0,0 +1,246 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package x509
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	x5092 "crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	kmsdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// EnrollmentOptField is the field of the options of an MSP configuring its enrollment with a Fabric CA
+	EnrollmentOptField = "CA"
+	// DefaultRenewAt is the fraction of the lifetime of the certificate after which it is renewed
+	DefaultRenewAt = 0.8
+	// DefaultCheckInterval is the interval between two checks of the expiry of the certificate
+	DefaultCheckInterval = 10 * time.Minute
+	// DefaultEnrollmentKeyID is the id of the key, held by the key manager, wrapping the enrolled keys
+	DefaultEnrollmentKeyID = "msp"
+
+	keystoreFolder = "keystore"
+	cacertsFolder  = "cacerts"
+	certFile       = "cert.pem"
+)
+
+// Enrollment configures the enrollment of an x509 MSP with a Fabric CA, at the first startup when no
+// certificate is on disk, and the re-enrollment of its certificate before it expires
+type Enrollment struct {
+	// URL is the address of the CA, for instance https://ca.org1.example.com:7054
+	URL string `yaml:"url"`
+	// Name is the name of the CA, if the server hosts more than one
+	Name string `yaml:"name,omitempty"`
+	// TLSCACerts are the paths of the certificates of the CAs the TLS certificate of the server is verified against
+	TLSCACerts []string `yaml:"tlsCACerts,omitempty"`
+	// EnrollmentID and EnrollmentSecret are the credentials of the first enrollment
+	EnrollmentID     string `yaml:"enrollmentID"`
+	EnrollmentSecret string `yaml:"enrollmentSecret"`
+	// RenewAt is the fraction of the lifetime of the certificate after which it is re-enrolled, DefaultRenewAt if not in (0, 1)
+	RenewAt float64 `yaml:"renewAt,omitempty"`
+	// CheckInterval is the interval between two checks of the expiry of the certificate, DefaultCheckInterval if not positive
+	CheckInterval time.Duration `yaml:"checkInterval,omitempty"`
+	// Timeout is the time each request to the CA has, ca.DefaultTimeout if not positive
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// KeyID is the id of the key, held by the key manager, wrapping the enrolled keys, DefaultEnrollmentKeyID if not set.
+	// Without key manager, the keys are written in clear.
+	KeyID string `yaml:"keyId,omitempty"`
+}
+
+// ToEnrollmentOpts unmarshals the enrollment options of an MSP
+func ToEnrollmentOpts(boxed interface{}) (*Enrollment, error) {
+	raw, err := yaml.Marshal(boxed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal")
+	}
+	opts := &Enrollment{}
+	if err := yaml.Unmarshal(raw, opts); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal")
+	}
+	if len(opts.URL) == 0 {
+		return nil, errors.New("no CA url set")
+	}
+	if opts.RenewAt <= 0 || opts.RenewAt >= 1 {
+		opts.RenewAt = DefaultRenewAt
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = DefaultCheckInterval
+	}
+	if len(opts.KeyID) == 0 {
+		opts.KeyID = DefaultEnrollmentKeyID
+	}
+	return opts, nil
+}
+
+// NewEnrollmentClient returns the client of the CA of the passed enrollment, the paths are translated with the passed function
+func NewEnrollmentClient(e *Enrollment, translatePath func(string) string) (*ca.Client, error) {
+	var paths []string
+	for _, path := range e.TLSCACerts {
+		paths = append(paths, translatePath(path))
+	}
+	rootCAs, err := ca.LoadRootCAs(paths)
+	if err != nil {
+		return nil, err
+	}
+	return ca.NewClient(e.URL, e.Name, rootCAs, e.Timeout), nil
+}
+
+// HasSignerCert returns true if the MSP folder at the passed path holds a signer certificate
+func HasSignerCert(dir string) bool {
+	certs, err := getPemMaterialFromDir(filepath.Join(dir, SignCerts))
+	return err == nil && len(certs) != 0
+}
+
+// EnrollAt enrolls a new key with the passed CA, and writes the key, the certificate and the chain of the CA
+// in the MSP folder at the passed path. If the passed key manager is not nil, the key is wrapped by its key of the passed id.
+func EnrollAt(ctx context.Context, client *ca.Client, enrollmentID, secret, dir string, km kmsdriver.KeyManager, keyID string) error {
+	sk, csr, err := newCertificateRequest(enrollmentID)
+	if err != nil {
+		return err
+	}
+	enrollment, err := client.Enroll(ctx, enrollmentID, secret, csr)
+	if err != nil {
+		return err
+	}
+	if len(enrollment.CAChain) == 0 {
+		return errors.Errorf("the CA did not return its chain for [%s]", enrollmentID)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, cacertsFolder), 0755); err != nil {
+		return errors.Wrapf(err, "failed creating msp folder [%s]", dir)
+	}
+	if err := writeFileAtomically(filepath.Join(dir, cacertsFolder, "ca.pem"), enrollment.CAChain, 0644); err != nil {
+		return err
+	}
+	if _, err := writeKey(dir, sk, km, keyID); err != nil {
+		return err
+	}
+	return writeSignerCert(dir, enrollment.Cert)
+}
+
+// ReenrollAt re-enrolls a new key with the passed CA, the request is signed by the passed current identity.
+// The key and the certificate are written in the MSP folder at the passed path, the certificate replaces the current one.
+// It returns the path of the key written, the previous keys are left in the keystore.
+// If the passed key manager is not nil, the key is wrapped by its key of the passed id.
+func ReenrollAt(ctx context.Context, client *ca.Client, current ca.Signer, enrollmentID, dir string, km kmsdriver.KeyManager, keyID string) (string, error) {
+	sk, csr, err := newCertificateRequest(enrollmentID)
+	if err != nil {
+		return "", err
+	}
+	enrollment, err := client.Reenroll(ctx, current, csr)
+	if err != nil {
+		return "", err
+	}
+	keyPath, err := writeKey(dir, sk, km, keyID)
+	if err != nil {
+		return "", err
+	}
+	if err := writeSignerCert(dir, enrollment.Cert); err != nil {
+		return "", err
+	}
+	return keyPath, nil
+}
+
+func newCertificateRequest(enrollmentID string) (*ecdsa.PrivateKey, []byte, error) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed generating key")
+	}
+	csr, err := x5092.CreateCertificateRequest(rand.Reader, &x5092.CertificateRequest{Subject: pkix.Name{CommonName: enrollmentID}}, sk)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed creating certificate request")
+	}
+	return sk, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), nil
+}
+
+// writeKey writes the passed key in the keystore of the MSP folder, named after its SKI as the software BCCSP expects.
+// If the passed key manager is not nil, the key is wrapped, it is never written in clear.
+func writeKey(dir string, sk *ecdsa.PrivateKey, km kmsdriver.KeyManager, keyID string) (string, error) {
+	raw, err := x5092.MarshalPKCS8PrivateKey(sk)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed marshalling key")
+	}
+	content := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: raw})
+	if km != nil {
+		if content, err = kms.WrapKey(km, keyID, content); err != nil {
+			return "", err
+		}
+	}
+	ski := sha256.Sum256(elliptic.Marshal(sk.Curve, sk.PublicKey.X, sk.PublicKey.Y))
+	if err := os.MkdirAll(filepath.Join(dir, keystoreFolder), 0700); err != nil {
+		return "", errors.Wrapf(err, "failed creating keystore of [%s]", dir)
+	}
+	path := filepath.Join(dir, keystoreFolder, hex.EncodeToString(ski[:])+"_sk")
+	if err := writeFileAtomically(path, content, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeSignerCert replaces the signer certificates of the MSP folder with the passed one
+func writeSignerCert(dir string, cert []byte) error {
+	signCerts := filepath.Join(dir, SignCerts)
+	if err := os.MkdirAll(signCerts, 0755); err != nil {
+		return errors.Wrapf(err, "failed creating signcerts of [%s]", dir)
+	}
+	if err := writeFileAtomically(filepath.Join(signCerts, certFile), cert, 0644); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(signCerts)
+	if err != nil {
+		return errors.Wrapf(err, "failed reading [%s]", signCerts)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == certFile {
+			continue
+		}
+		if err := os.Remove(filepath.Join(signCerts, entry.Name())); err != nil {
+			return errors.Wrapf(err, "failed removing previous certificate [%s]", entry.Name())
+		}
+	}
+	return nil
+}
+
+// writeFileAtomically writes the passed file through a temporary file renamed, the readers never see a partial content
+func writeFileAtomically(path string, raw []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "failed creating temporary file for [%s]", path)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed writing [%s]", path)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed writing [%s]", path)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed writing [%s]", path)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return errors.Wrapf(err, "failed setting permissions of [%s]", path)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "failed writing [%s]", path)
+	}
+	return nil
+}
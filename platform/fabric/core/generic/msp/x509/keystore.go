@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package x509
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	kmsdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/sw"
+	"github.com/pkg/errors"
+)
+
+// wrappedKeyStore is a file based key store whose private keys can also be wrapped by a key manager,
+// see kms.WrapKey. The wrapped keys are unwrapped when they are requested, they are never written in clear.
+type wrappedKeyStore struct {
+	bccsp.KeyStore
+	path       string
+	keyManager kmsdriver.KeyManager
+}
+
+func newWrappedKeyStore(path string, keyManager kmsdriver.KeyManager) (*wrappedKeyStore, error) {
+	ks, err := sw.NewFileBasedKeyStore(nil, path, true)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedKeyStore{KeyStore: ks, path: path, keyManager: keyManager}, nil
+}
+
+func (ks *wrappedKeyStore) GetKey(ski []byte) (bccsp.Key, error) {
+	k, err := ks.KeyStore.GetKey(ski)
+	if err == nil {
+		return k, nil
+	}
+
+	files, rerr := os.ReadDir(ks.path)
+	if rerr != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		raw, rerr := ioutil.ReadFile(filepath.Join(ks.path, f.Name()))
+		if rerr != nil || !kms.IsWrappedKey(raw) {
+			continue
+		}
+		key, rerr := ks.unwrap(raw)
+		if rerr != nil {
+			// the msp reports a missing key, the reason is logged
+			logger.Errorf("failed loading wrapped key [%s]: [%s]", filepath.Join(ks.path, f.Name()), rerr)
+			return nil, errors.WithMessagef(rerr, "failed loading wrapped key [%s]", filepath.Join(ks.path, f.Name()))
+		}
+		if bytes.Equal(key.SKI(), ski) {
+			return key, nil
+		}
+	}
+	return nil, err
+}
+
+func (ks *wrappedKeyStore) unwrap(raw []byte) (bccsp.Key, error) {
+	if ks.keyManager == nil {
+		return nil, errors.New("key is wrapped but no key manager is configured")
+	}
+	pemRaw, err := kms.UnwrapKey(ks.keyManager, raw)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemRaw)
+	if block == nil {
+		return nil, errors.New("unwrapped key is not pem encoded")
+	}
+	// the key is imported with a csp without key store, it is not persisted
+	csp, err := sw.NewDefaultSecurityLevelWithKeystore(sw.NewDummyKeyStore())
+	if err != nil {
+		return nil, err
+	}
+	return csp.KeyImport(block.Bytes, &bccsp.ECDSAPrivateKeyImportOpts{Temporary: true})
+}
@@ -13,8 +13,11 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	kmsdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
 	"github.com/pkg/errors"
 )
 
@@ -25,6 +28,10 @@ const (
 
 var logger = flogging.MustGetLogger("fabric-sdk.msp.x509")
 
+// ErrNotEnrolled is returned by the loading of a read-only manager for the MSPs enrolled with their CA on startup,
+// not enrolled yet
+var ErrNotEnrolled = errors.New("msp not enrolled yet")
+
 type IdentityLoader struct{}
 
 func (i *IdentityLoader) Load(manager driver.Manager, c config.MSP) error {
@@ -42,22 +49,62 @@ func (i *IdentityLoader) Load(manager driver.Manager, c config.MSP) error {
 		}
 	}
 
+	var enrollment *Enrollment
+	if c.Opts != nil {
+		if boxed, ok := c.Opts[EnrollmentOptField]; ok {
+			var err error
+			enrollment, err = ToEnrollmentOpts(boxed)
+			if err != nil {
+				return errors.WithMessagef(err, "failed to unmarshal CA opts of [%s]", c.ID)
+			}
+			if bccspOpts != nil && bccspOpts.Default == "PKCS11" {
+				return errors.Errorf("msp [%s] cannot be enrolled with a CA, its keys are in a PKCS11 token", c.ID)
+			}
+		}
+	}
+
+	var keyManager kmsdriver.KeyManager
+	if km := kms.GetKeyManager(manager.ServiceProvider()); km != nil {
+		keyManager = km
+	}
+
+	readOnly := false
+	if ro, ok := manager.(driver.ReadOnlyManager); ok {
+		readOnly = ro.ReadOnly()
+	}
+
 	// Try without "msp"
 	rootPath := filepath.Join(manager.Config().TranslatePath(c.Path))
-	provider, err := NewProviderWithBCCSPConfig(
+	var client *ca.Client
+	switch {
+	case enrollment != nil && readOnly:
+		if dir := enrollmentDir(rootPath); !HasSignerCert(dir) {
+			return errors.Wrapf(ErrNotEnrolled, "no certificate in [%s] for msp [%s]", dir, c.ID)
+		}
+	case enrollment != nil:
+		var err error
+		if client, err = enroll(manager, c, enrollmentDir(rootPath), enrollment, keyManager); err != nil {
+			return err
+		}
+	}
+	dir := rootPath
+	provider, err := NewProviderWithKeyManager(
 		rootPath,
 		c.MSPID,
 		manager.SignerService(),
 		bccspOpts,
+		keyManager,
 	)
 	if err != nil {
 		logger.Warnf("failed reading bccsp msp configuration from [%s]: [%s]", rootPath, err)
 		// Try with "msp"
-		provider, err = NewProviderWithBCCSPConfig(
-			filepath.Join(rootPath, "msp"),
+		dir = filepath.Join(rootPath, "msp")
+		provider, err = NewProviderWithKeyManager(
+			dir,
 			c.MSPID,
 			manager.SignerService(),
 			bccspOpts,
+			keyManager,
 		)
 		if err != nil {
 			logger.Warnf("failed reading bccsp msp configuration from [%s and %s]: [%s]",
@@ -81,6 +128,15 @@ func (i *IdentityLoader) Load(manager driver.Manager, c config.MSP) error {
 	}
 	manager.SetDefaultIdentity(c.ID, defaultIdentity, defaultSigningIdentity)
 
+	// re-enroll before the certificate expires
+	if enrollment != nil && !readOnly {
+		renewer := newRenewer(manager, c, dir, enrollment, client, provider, bccspOpts, keyManager, GetRenewalMetrics(manager.ServiceProvider()))
+		if r, ok := manager.(driver.IdentityRenewer); ok {
+			r.AddRenewal(c.ID, renewer.Stop)
+		}
+		renewer.Start()
+	}
+
 	return nil
 }
 
@@ -9,12 +9,14 @@ package x509
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"sync"
 
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	driver2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
 	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	kmsdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	"github.com/hyperledger/fabric-protos-go/msp"
 	"github.com/pkg/errors"
@@ -25,6 +27,8 @@ type SignerService interface {
 }
 
 type provider struct {
+	// lock guards the identity, that changes when re-enrolled
+	lock         sync.RWMutex
 	sID          driver2.SigningIdentity
 	id           []byte
 	enrollmentID string
@@ -35,7 +39,12 @@ func NewProvider(mspConfigPath, mspID string, signerService SignerService) (*pro
 }
 
 func NewProviderWithBCCSPConfig(mspConfigPath, mspID string, signerService SignerService, bccspConfig *config.BCCSP) (*provider, error) {
-	sID, err := GetSigningIdentity(mspConfigPath, mspID, bccspConfig)
+	return NewProviderWithKeyManager(mspConfigPath, mspID, signerService, bccspConfig, nil)
+}
+
+// NewProviderWithKeyManager is NewProviderWithBCCSPConfig, the keys in the keystore can be wrapped by the passed key manager
+func NewProviderWithKeyManager(mspConfigPath, mspID string, signerService SignerService, bccspConfig *config.BCCSP, keyManager kmsdriver.KeyManager) (*provider, error) {
+	sID, err := getSigningIdentity(mspConfigPath, mspID, bccspConfig, keyManager)
 	if err != nil {
 		return nil, err
 	}
@@ -58,6 +67,8 @@ func NewProviderWithBCCSPConfig(mspConfigPath, mspID string, signerService Signe
 }
 
 func (p *provider) Identity(opts *fdriver.IdentityOptions) (view.Identity, []byte, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.id, []byte(p.enrollmentID), nil
 }
 
@@ -103,9 +114,18 @@ func (p *provider) Info(raw []byte, auditInfo []byte) (string, error) {
 }
 
 func (p *provider) SerializedIdentity() (driver2.SigningIdentity, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.sID, nil
 }
 
+// swap replaces the identity of the provider, the identities served before stay valid for the deserializers
+func (p *provider) swap(sID driver2.SigningIdentity, id []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.sID, p.id = sID, id
+}
+
 func (p *provider) String() string {
 	return fmt.Sprintf("X509 Provider for EID [%s]", p.enrollmentID)
 }
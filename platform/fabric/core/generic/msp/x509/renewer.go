@@ -0,0 +1,267 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package x509
+
+import (
+	"context"
+	x5092 "crypto/x509"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	kmsdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	msp2 "github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+)
+
+var (
+	reenrollmentsOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "msp",
+		Name:         "reenrollments",
+		Help:         "The number of successful re-enrollments of the identity of an x509 MSP.",
+		LabelNames:   []string{"network", "msp"},
+		StatsdFormat: "%{#fqname}.%{network}.%{msp}",
+	}
+	reenrollmentFailuresOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "msp",
+		Name:         "reenrollment_failures",
+		Help:         "The number of failed re-enrollments of the identity of an x509 MSP.",
+		LabelNames:   []string{"network", "msp"},
+		StatsdFormat: "%{#fqname}.%{network}.%{msp}",
+	}
+	certificateExpiryOpts = metrics.GaugeOpts{
+		Namespace:    "fabric",
+		Subsystem:    "msp",
+		Name:         "certificate_expiry",
+		Help:         "The time, in seconds since the epoch, the certificate of the identity of an x509 MSP expires.",
+		LabelNames:   []string{"network", "msp"},
+		StatsdFormat: "%{#fqname}.%{network}.%{msp}",
+	}
+)
+
+// RenewalMetrics collects the re-enrollments of the identities of the x509 MSPs
+type RenewalMetrics struct {
+	Reenrollments        metrics.Counter
+	ReenrollmentFailures metrics.Counter
+	CertificateExpiry    metrics.Gauge
+}
+
+func NewRenewalMetrics(p metrics.Provider) *RenewalMetrics {
+	return &RenewalMetrics{
+		Reenrollments:        p.NewCounter(reenrollmentsOpts),
+		ReenrollmentFailures: p.NewCounter(reenrollmentFailuresOpts),
+		CertificateExpiry:    p.NewGauge(certificateExpiryOpts),
+	}
+}
+
+// GetRenewalMetrics returns the renewal metrics registered in the passed service provider, shared by the MSPs of all
+// the networks: the metric vectors can be registered only once per process. If none are registered, the returned
+// metrics are not recorded.
+func GetRenewalMetrics(sp view2.ServiceProvider) *RenewalMetrics {
+	if sp != nil {
+		if m, err := sp.GetService(reflect.TypeOf((*RenewalMetrics)(nil))); err == nil {
+			return m.(*RenewalMetrics)
+		}
+	}
+	return NewRenewalMetrics(&disabled.Provider{})
+}
+
+// Renewer re-enrolls the identity of an x509 MSP when its certificate reaches a fraction of its lifetime.
+// The new key and certificate are written in the MSP folder, the new identity is registered with the signer service
+// and made the identity of the MSP. A failure is logged and counted, the current identity is kept, and the
+// re-enrollment is retried at the next check.
+type Renewer struct {
+	name       string
+	mspID      string
+	dir        string
+	bccspOpts  *config.BCCSP
+	keyManager kmsdriver.KeyManager
+	keyID      string
+	client     *ca.Client
+	renewAt    float64
+	interval   time.Duration
+	provider   *provider
+	manager    driver.Manager
+	metrics    *RenewalMetrics
+	labels     []string
+
+	// lock serializes the re-enrollments
+	lock   sync.Mutex
+	stop   chan struct{}
+	once   sync.Once
+	cancel context.CancelFunc
+}
+
+// newRenewer returns a renewer of the passed MSP, recording its re-enrollments in the passed metrics,
+// labelled by network and MSP
+func newRenewer(manager driver.Manager, c config.MSP, dir string, enrollment *Enrollment, client *ca.Client, p *provider, bccspOpts *config.BCCSP, keyManager kmsdriver.KeyManager, metrics *RenewalMetrics) *Renewer {
+	return &Renewer{
+		name:       c.ID,
+		mspID:      c.MSPID,
+		dir:        dir,
+		bccspOpts:  bccspOpts,
+		keyManager: keyManager,
+		keyID:      enrollment.KeyID,
+		client:     client,
+		renewAt:    enrollment.RenewAt,
+		interval:   enrollment.CheckInterval,
+		provider:   p,
+		manager:    manager,
+		metrics:    metrics,
+		labels:     []string{"network", manager.Config().Name(), "msp", c.ID},
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start checks the certificate now, and then at every interval, until Stop is called
+func (r *Renewer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if err := r.Check(ctx, time.Now()); err != nil {
+				logger.Errorf("failed re-enrolling msp [%s], the current identity is kept and the re-enrollment retried in [%s]: [%s]", r.name, r.interval, err)
+			}
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the checks, a re-enrollment in progress is cancelled
+func (r *Renewer) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+		if r.cancel != nil {
+			r.cancel()
+		}
+	})
+}
+
+// Check re-enrolls the identity if its certificate has reached the renewal fraction of its lifetime at the passed time
+func (r *Renewer) Check(ctx context.Context, now time.Time) error {
+	cert, err := r.certificate()
+	if err != nil {
+		return err
+	}
+	r.metrics.CertificateExpiry.With(r.labels...).Set(float64(cert.NotAfter.Unix()))
+	if now.Before(RenewalTime(cert, r.renewAt)) {
+		return nil
+	}
+	if now.After(cert.NotAfter) {
+		logger.Errorf("the certificate of msp [%s] expired at [%s], the CA might refuse its re-enrollment", r.name, cert.NotAfter)
+	} else {
+		logger.Infof("the certificate of msp [%s] expires at [%s], re-enroll", r.name, cert.NotAfter)
+	}
+	if err := r.Reenroll(ctx); err != nil {
+		r.metrics.ReenrollmentFailures.With(r.labels...).Add(1)
+		return err
+	}
+	r.metrics.Reenrollments.With(r.labels...).Add(1)
+	return nil
+}
+
+// Reenroll re-enrolls the identity now, see Renewer
+func (r *Renewer) Reenroll(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	current, err := r.provider.SerializedIdentity()
+	if err != nil {
+		return err
+	}
+	keyPath, err := ReenrollAt(ctx, r.client, current, r.provider.EnrollmentID(), r.dir, r.keyManager, r.keyID)
+	if err != nil {
+		return err
+	}
+	sID, err := getSigningIdentity(r.dir, r.mspID, r.bccspOpts, r.keyManager)
+	if err != nil {
+		// the new key and certificate are on disk, they are loaded at the next startup
+		return errors.WithMessagef(err, "failed loading the re-enrolled identity of msp [%s] from [%s], new key at [%s]", r.name, r.dir, keyPath)
+	}
+	id, err := sID.Serialize()
+	if err != nil {
+		return errors.WithMessagef(err, "failed serializing the re-enrolled identity of msp [%s]", r.name)
+	}
+	if err := r.manager.SignerService().RegisterSigner(id, sID, sID); err != nil {
+		return errors.WithMessagef(err, "failed registering the re-enrolled identity of msp [%s]", r.name)
+	}
+	r.provider.swap(sID, id)
+	if u, ok := r.manager.(driver.IdentityRenewer); ok {
+		if err := u.UpdateIdentity(r.name, id, sID); err != nil {
+			return errors.WithMessagef(err, "failed updating the identity of msp [%s]", r.name)
+		}
+	}
+	logger.Infof("msp [%s] re-enrolled, new key at [%s]", r.name, keyPath)
+	return nil
+}
+
+func (r *Renewer) certificate() (*x5092.Certificate, error) {
+	sID, err := r.provider.SerializedIdentity()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := sID.Serialize()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed serializing the identity of msp [%s]", r.name)
+	}
+	si := &msp2.SerializedIdentity{}
+	if err := proto.Unmarshal(raw, si); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling the identity of msp [%s]", r.name)
+	}
+	return PemDecodeCert(si.IdBytes)
+}
+
+// RenewalTime returns the time the passed certificate must be renewed at, after the passed fraction of its lifetime
+func RenewalTime(cert *x5092.Certificate, renewAt float64) time.Time {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return cert.NotBefore.Add(time.Duration(float64(lifetime) * renewAt))
+}
+
+// enroll enrolls the MSP at the passed path if it has no signer certificate, and returns the client of its CA
+func enroll(manager driver.Manager, c config.MSP, dir string, enrollment *Enrollment, keyManager kmsdriver.KeyManager) (*ca.Client, error) {
+	client, err := NewEnrollmentClient(enrollment, manager.Config().TranslatePath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed creating the CA client of msp [%s]", c.ID)
+	}
+	if HasSignerCert(dir) {
+		return client, nil
+	}
+	if len(enrollment.EnrollmentID) == 0 {
+		return nil, errors.Errorf("no certificate in [%s] and no enrollment ID set for msp [%s]", dir, c.ID)
+	}
+	logger.Infof("no certificate found in [%s], enroll [%s] for msp [%s]", dir, enrollment.EnrollmentID, c.ID)
+	if err := EnrollAt(context.Background(), client, enrollment.EnrollmentID, enrollment.EnrollmentSecret, dir, keyManager, enrollment.KeyID); err != nil {
+		return nil, errors.WithMessagef(err, "failed enrolling msp [%s]", c.ID)
+	}
+	return client, nil
+}
+
+// enrollmentDir returns the MSP folder holding the signer certificate, the passed root path if there is none
+func enrollmentDir(rootPath string) string {
+	if !HasSignerCert(rootPath) {
+		if sub := filepath.Join(rootPath, "msp"); HasSignerCert(sub) {
+			return sub
+		}
+	}
+	return rootPath
+}
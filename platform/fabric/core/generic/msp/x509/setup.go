@@ -17,6 +17,7 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
+	kmsdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
 	msp2 "github.com/hyperledger/fabric-protos-go/msp"
 	"github.com/hyperledger/fabric/bccsp"
 	"github.com/hyperledger/fabric/bccsp/pkcs11"
@@ -32,7 +33,11 @@ const (
 
 // GetSigningIdentity retrieves a signing identity from the passed arguments
 func GetSigningIdentity(mspConfigPath, mspID string, bccspConfig *config.BCCSP) (driver.SigningIdentity, error) {
-	mspInstance, err := LoadLocalMSPAt(mspConfigPath, mspID, BCCSPType, bccspConfig)
+	return getSigningIdentity(mspConfigPath, mspID, bccspConfig, nil)
+}
+
+func getSigningIdentity(mspConfigPath, mspID string, bccspConfig *config.BCCSP, keyManager kmsdriver.KeyManager) (driver.SigningIdentity, error) {
+	mspInstance, err := loadLocalMSPAt(mspConfigPath, mspID, BCCSPType, bccspConfig, keyManager)
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +53,10 @@ func GetSigningIdentity(mspConfigPath, mspID string, bccspConfig *config.BCCSP)
 // LoadLocalMSPAt loads an MSP whose configuration is stored at 'dir', and whose
 // id and type are the passed as arguments.
 func LoadLocalMSPAt(dir, id, mspType string, bccspConfig *config.BCCSP) (msp.MSP, error) {
+	return loadLocalMSPAt(dir, id, mspType, bccspConfig, nil)
+}
+
+func loadLocalMSPAt(dir, id, mspType string, bccspConfig *config.BCCSP, keyManager kmsdriver.KeyManager) (msp.MSP, error) {
 	if mspType != BCCSPType {
 		return nil, errors.Errorf("invalid msp type, expected 'bccsp', got %s", mspType)
 	}
@@ -56,7 +65,7 @@ func LoadLocalMSPAt(dir, id, mspType string, bccspConfig *config.BCCSP) (msp.MSP
 		return nil, errors.WithMessagef(err, "could not get msp config from dir [%s]", dir)
 	}
 
-	cp, _, err := GetBCCSPFromConf(dir, bccspConfig)
+	cp, _, err := getBCCSPFromConf(dir, bccspConfig, keyManager)
 	if err != nil {
 		return nil, errors.WithMessagef(err, "failed to get bccsp from config [%v]", bccspConfig)
 	}
@@ -121,13 +130,18 @@ func LoadLocalMSPSignerCert(dir string) ([]byte, error) {
 // GetBCCSPFromConf returns a BCCSP instance and its relative key store from the passed configuration.
 // If no configuration is passed, the default one is used, namely the `SW` provider.
 func GetBCCSPFromConf(dir string, conf *config.BCCSP) (bccsp.BCCSP, bccsp.KeyStore, error) {
+	return getBCCSPFromConf(dir, conf, nil)
+}
+
+// getBCCSPFromConf is GetBCCSPFromConf, the keys of the `SW` provider can be wrapped by the passed key manager
+func getBCCSPFromConf(dir string, conf *config.BCCSP, keyManager kmsdriver.KeyManager) (bccsp.BCCSP, bccsp.KeyStore, error) {
 	if conf == nil {
-		return GetSWBCCSP(dir)
+		return getSWBCCSP(dir, keyManager)
 	}
 
 	switch conf.Default {
 	case "SW":
-		return GetSWBCCSP(dir)
+		return getSWBCCSP(dir, keyManager)
 	case "PKCS11":
 		return GetPKCS11BCCSP(conf)
 	default:
@@ -171,7 +185,11 @@ func skiMapper(p11Opts config.PKCS11) func([]byte) []byte {
 
 // GetSWBCCSP returns a new instance of the software-based BCCSP
 func GetSWBCCSP(dir string) (bccsp.BCCSP, bccsp.KeyStore, error) {
-	ks, err := sw.NewFileBasedKeyStore(nil, filepath.Join(dir, "keystore"), true)
+	return getSWBCCSP(dir, nil)
+}
+
+func getSWBCCSP(dir string, keyManager kmsdriver.KeyManager) (bccsp.BCCSP, bccsp.KeyStore, error) {
+	ks, err := newWrappedKeyStore(filepath.Join(dir, "keystore"), keyManager)
 	if err != nil {
 		return nil, nil, err
 	}
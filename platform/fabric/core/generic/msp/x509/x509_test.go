@@ -7,8 +7,25 @@ SPDX-License-Identifier: Apache-2.0
 package x509
 
 import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/driver"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver/file"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric/common/metrics/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,3 +43,238 @@ func TestDeserializer(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "MSP.x509: [f+hVlmGaPejN2G0XDcESSMX2ol29WPcPQ+Fp3lOARBQ=][apple][auditor.org1.example.com]", info)
 }
+
+func TestWrappedKeystore(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"cacerts", "signcerts", "admincerts", "tlscacerts", "keystore"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, sub), 0755))
+		entries, err := os.ReadDir(filepath.Join("testdata", "msp", sub))
+		assert.NoError(t, err)
+		for _, e := range entries {
+			raw, err := os.ReadFile(filepath.Join("testdata", "msp", sub, e.Name()))
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, sub, e.Name()), raw, 0600))
+		}
+	}
+
+	// replace the key with its wrapped version
+	km, err := file.NewKeyManager([]byte("0123456789abcdef0123456789abcdef"))
+	assert.NoError(t, err)
+	sk, err := os.ReadFile(filepath.Join(dir, "keystore", "priv_sk"))
+	assert.NoError(t, err)
+	wrapped, err := kms.WrapKey(km, "msp", sk)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Remove(filepath.Join(dir, "keystore", "priv_sk")))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "keystore", "wrapped_sk"), wrapped, 0600))
+
+	// without key manager, the key is not found
+	_, err = NewProvider(dir, "apple", nil)
+	assert.Error(t, err)
+
+	p, err := NewProviderWithKeyManager(dir, "apple", nil, nil, km)
+	assert.NoError(t, err)
+	sigma, err := p.sID.Sign([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, p.sID.Verify([]byte("hello"), sigma))
+	assert.Equal(t, "auditor.org1.example.com", p.EnrollmentID())
+}
+
+type mspConfig struct{}
+
+func (c *mspConfig) Name() string                     { return "default" }
+func (c *mspConfig) DefaultMSP() string               { return "node" }
+func (c *mspConfig) MSPs() ([]config.MSP, error)      { return nil, nil }
+func (c *mspConfig) TranslatePath(path string) string { return path }
+
+// manager records the identities of the msp, and the signers registered
+type manager struct {
+	sp       view2.ServiceProvider
+	lock     sync.Mutex
+	getter   fdriver.GetIdentityFunc
+	identity view.Identity
+	updates  int
+	signers  map[string]bool
+	stop     func()
+}
+
+func (m *manager) AddDeserializer(sig.Deserializer) {}
+
+func (m *manager) AddMSP(_ string, _ string, _ string, getter fdriver.GetIdentityFunc) {
+	m.getter = getter
+}
+
+func (m *manager) Config() driver.Config                  { return &mspConfig{} }
+func (m *manager) DefaultMSP() string                     { return "node" }
+func (m *manager) SignerService() driver.SignerService    { return m }
+func (m *manager) ServiceProvider() view2.ServiceProvider { return m.sp }
+func (m *manager) CacheSize() int                         { return 0 }
+func (m *manager) AddRenewal(_ string, stop func())       { m.stop = stop }
+
+func (m *manager) SetDefaultIdentity(_ string, identity view.Identity, _ driver.SigningIdentity) {
+	m.identity = identity
+}
+
+func (m *manager) UpdateIdentity(_ string, identity view.Identity, _ driver.SigningIdentity) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.identity = identity
+	m.updates++
+	return nil
+}
+
+func (m *manager) RegisterSigner(identity view.Identity, _ fdriver.Signer, _ fdriver.Verifier) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.signers[identity.UniqueID()] = true
+	return nil
+}
+
+func (m *manager) current() (view.Identity, int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.identity, m.updates
+}
+
+func TestEnrollment(t *testing.T) {
+	fake, err := mock.NewCA()
+	assert.NoError(t, err)
+	fake.Register("node", "secret")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir := t.TempDir()
+	m := &manager{sp: registry.New(), signers: map[string]bool{}}
+	c := config.MSP{ID: "node", MSPType: MSPType, MSPID: "Org1MSP", Path: dir, Opts: map[interface{}]interface{}{
+		EnrollmentOptField: map[interface{}]interface{}{
+			"url":              server.URL,
+			"enrollmentID":     "node",
+			"enrollmentSecret": "secret",
+			"renewAt":          0.5,
+			"checkInterval":    "100ms",
+		},
+	}}
+
+	// enrolled at the first load, the certificate is valid long enough
+	assert.NoError(t, (&IdentityLoader{}).Load(m, c))
+	assert.True(t, HasSignerCert(dir))
+	assert.Equal(t, 1, fake.Requests("enroll"))
+	first, _ := m.current()
+	assert.True(t, m.signers[first.UniqueID()])
+	id, eid, err := m.getter(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, first, id)
+	assert.Equal(t, "node", string(eid))
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 0, fake.Requests("reenroll"))
+	m.stop()
+
+	// the certificate on disk is loaded at the next load, and re-enrolled when half of its lifetime is over
+	fake.Lifetime = 2 * time.Second
+	assert.NoError(t, os.RemoveAll(filepath.Join(dir, SignCerts)))
+	assert.NoError(t, (&IdentityLoader{}).Load(m, c))
+	assert.Equal(t, 2, fake.Requests("enroll"))
+	second, _ := m.current()
+	assert.NotEqual(t, first, second)
+
+	assert.Eventually(t, func() bool {
+		_, updates := m.current()
+		return updates != 0
+	}, 5*time.Second, 50*time.Millisecond)
+	m.stop()
+	third, _ := m.current()
+	assert.NotEqual(t, second, third)
+	id, _, err = m.getter(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, third, id)
+	assert.True(t, m.signers[third.UniqueID()])
+
+	// the re-enrolled identity is loaded from disk
+	p, err := NewProvider(dir, "Org1MSP", nil)
+	assert.NoError(t, err)
+	id, _, err = p.Identity(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, third, id)
+
+	// a failure keeps the current identity
+	server.Close()
+	r := newRenewer(m, c, dir, &Enrollment{RenewAt: 0.5, CheckInterval: time.Hour}, nil, p, nil, nil, GetRenewalMetrics(nil))
+	r.client, err = NewEnrollmentClient(&Enrollment{URL: server.URL}, func(s string) string { return s })
+	assert.NoError(t, err)
+	assert.Error(t, r.Check(context.Background(), time.Now().Add(time.Hour)))
+	id, _, err = p.Identity(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, third, id)
+}
+
+func TestEnrollmentWrapsKeys(t *testing.T) {
+	fake, err := mock.NewCA()
+	assert.NoError(t, err)
+	fake.Register("node", "secret")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client, err := NewEnrollmentClient(&Enrollment{URL: server.URL}, func(s string) string { return s })
+	assert.NoError(t, err)
+	km, err := file.NewKeyManager([]byte("0123456789abcdef0123456789abcdef"))
+	assert.NoError(t, err)
+
+	// the enrolled keys are never written in clear
+	inClear := func(dir string) bool {
+		entries, err := os.ReadDir(filepath.Join(dir, keystoreFolder))
+		assert.NoError(t, err)
+		for _, e := range entries {
+			raw, err := os.ReadFile(filepath.Join(dir, keystoreFolder, e.Name()))
+			assert.NoError(t, err)
+			if !kms.IsWrappedKey(raw) {
+				return true
+			}
+		}
+		return len(entries) == 0
+	}
+
+	dir := t.TempDir()
+	assert.NoError(t, EnrollAt(context.Background(), client, "node", "secret", dir, km, DefaultEnrollmentKeyID))
+	assert.False(t, inClear(dir))
+	_, err = NewProvider(dir, "Org1MSP", nil)
+	assert.Error(t, err)
+	p, err := NewProviderWithKeyManager(dir, "Org1MSP", nil, nil, km)
+	assert.NoError(t, err)
+	first, _, err := p.Identity(nil)
+	assert.NoError(t, err)
+
+	current, err := p.SerializedIdentity()
+	assert.NoError(t, err)
+	_, err = ReenrollAt(context.Background(), client, current, p.EnrollmentID(), dir, km, DefaultEnrollmentKeyID)
+	assert.NoError(t, err)
+	assert.False(t, inClear(dir))
+	p, err = NewProviderWithKeyManager(dir, "Org1MSP", nil, nil, km)
+	assert.NoError(t, err)
+	second, _, err := p.Identity(nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	sigma, err := p.sID.Sign([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, p.sID.Verify([]byte("hello"), sigma))
+
+	// without key manager, the key is written in clear
+	dir = t.TempDir()
+	fake.Register("other", "secret")
+	assert.NoError(t, EnrollAt(context.Background(), client, "other", "secret", dir, nil, ""))
+	assert.True(t, inClear(dir))
+	_, err = NewProvider(dir, "Org1MSP", nil)
+	assert.NoError(t, err)
+}
+
+func TestRenewalMetricsShared(t *testing.T) {
+	// prometheus refuses to register the same metric vector twice
+	m := NewRenewalMetrics(&prometheus.Provider{})
+	sp := registry.New()
+	assert.NoError(t, sp.RegisterService(m))
+
+	// the renewers of all the MSPs record their re-enrollments in the registered metrics, labelled by MSP
+	for _, msp := range []string{"alice", "bob"} {
+		shared := GetRenewalMetrics(sp)
+		assert.Same(t, m, shared)
+		shared.Reenrollments.With("network", "default", "msp", msp).Add(1)
+	}
+	assert.NotNil(t, GetRenewalMetrics(registry.New()).Reenrollments)
+}
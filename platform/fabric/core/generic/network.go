@@ -9,15 +9,24 @@ package generic
 import (
 	"math/rand"
 	"sync"
+	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/chaincode"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/compat"
 	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/ordering"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/rwset"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric/bccsp"
 	"github.com/pkg/errors"
 )
 
@@ -33,17 +42,64 @@ type network struct {
 	processorManager   driver.ProcessorManager
 	transactionManager driver.TransactionManager
 	sigService         driver.SignerService
+	// readOnly is true if this node never signs nor broadcasts transactions on this network
+	readOnly bool
+	// cryptoProvider is the BCCSP of the configurations of the channels
+	cryptoProvider bccsp.BCCSP
 
+	// orderersLock guards orderers, updated by the channel configurations
+	orderersLock       sync.RWMutex
 	orderers           []*grpc.ConnectionConfig
 	configuredOrderers int
-	peers              []*grpc.ConnectionConfig
-	defaultChannel     string
-	channelDefs        []*config2.Channel
+	// preflight is the pre-flight check of the orderers, nil if disabled
+	preflight *ordering.Preflight
+	// rotator applies the rotations of the TLS roots of the orderers make-before-break, nil if read-only
+	rotator *ordering.Rotator
+	// admission detects the duplicates of the transactions in flight before broadcast, nil if disabled
+	admission *ordering.Admission
+	// checkReadSets is true if the read sets of the transactions are checked against the vault before broadcast
+	checkReadSets bool
+	// ttl is the time to live of the transactions, the ones created longer ago are not broadcast, 0 if not set
+	ttl time.Duration
+	// compat checks the versions of the orderers and the application capabilities of the channels, nil if disabled
+	compat *compat.Checker
+	// failIncompatible is true if the components whose version is not supported make the initialization fail
+	failIncompatible bool
+	peers            []*grpc.ConnectionConfig
+	// resolution of the names of the orderers and of the peers not configuring their own, nil to leave it to gRPC
+	resolution *grpc.ResolutionConfig
+	// addressOverrides are the addresses to connect to in place of those of the orderers and of the peers
+	addressOverrides map[string]string
+	// foreignOrgs and foreignPeers tell which peers of the other organizations of the channels can be contacted
+	foreignOrgs    []string
+	foreignPeers   []*config2.ForeignPeer
+	defaultChannel string
+	channelDefs    []*config2.Channel
 
 	ordering driver.Ordering
-	channels map[string]driver.Channel
-	mutex    sync.RWMutex
-	name     string
+	// bus carries the events of the channels of this network
+	bus *events.Bus
+	// commitLimiter caps the number of blocks committed concurrently across the channels of all the networks
+	commitLimiter *committer.Limiter
+	commitMetrics *committer.CommitMetrics
+	channels      map[string]*channel
+	mutex         sync.RWMutex
+	name          string
+	// removed are the channels removed from this network and not joined again, guarded by mutex, see RemoveChannel
+	removed map[string]*removedChannel
+	// channelFactory creates the instances of the channels, nil to use newChannel
+	channelFactory func(name string, quiet bool, rejoin *driver.RejoinOptions) (*channel, error)
+
+	// subscriptions are the chaincode event subscriptions of the channels, they outlive the instances of the channels
+	subscriptionsLock sync.Mutex
+	subscriptions     map[string]*committer.Subscriptions
+
+	// queryCacheMetrics are shared by the query caches of the chaincodes of all the channels
+	queryCacheMetrics *chaincode.QueryCacheMetrics
+	// vaultMetrics are shared by the vaults of all the channels
+	vaultMetrics *vault.Metrics
+	// channelMetrics track the resources held by the channels
+	channelMetrics *ChannelMetrics
 }
 
 func NewNetwork(
@@ -53,16 +109,22 @@ func NewNetwork(
 	idProvider driver.IdentityProvider,
 	localMembership driver.LocalMembership,
 	sigService driver.SignerService,
+	cryptoProvider bccsp.BCCSP,
 ) (*network, error) {
+	if cryptoProvider == nil {
+		return nil, errors.Errorf("no crypto provider for fabric network [%s]", name)
+	}
 	// Load configuration
 	fsp := &network{
 		sp:              sp,
 		name:            name,
 		config:          config,
-		channels:        map[string]driver.Channel{},
+		channels:        map[string]*channel{},
+		subscriptions:   map[string]*committer.Subscriptions{},
 		localMembership: localMembership,
 		idProvider:      idProvider,
 		sigService:      sigService,
+		cryptoProvider:  cryptoProvider,
 	}
 	err := fsp.init()
 	if err != nil {
@@ -80,28 +142,62 @@ func (f *network) DefaultChannel() string {
 }
 
 func (f *network) Channels() []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
 	var chs []string
 	for _, c := range f.channelDefs {
+		if _, removed := f.removed[c.Name]; removed {
+			continue
+		}
 		chs = append(chs, c.Name)
 	}
 	return chs
 }
 
 func (f *network) Orderers() []*grpc.ConnectionConfig {
+	f.orderersLock.RLock()
+	defer f.orderersLock.RUnlock()
 	return f.orderers
 }
 
+// PickOrderer returns a random orderer, preferring the ones that passed the pre-flight check
 func (f *network) PickOrderer() *grpc.ConnectionConfig {
-	if len(f.orderers) == 0 {
+	f.orderersLock.RLock()
+	orderers := f.orderers
+	f.orderersLock.RUnlock()
+	if f.preflight != nil {
+		orderers = f.preflight.Healthy(orderers)
+	}
+	if len(orderers) == 0 {
 		return nil
 	}
-	return f.orderers[rand.Intn(len(f.orderers))]
+	return orderers[rand.Intn(len(orderers))]
+}
+
+// Broadcasted clears the degraded flag of the passed orderer, that accepted a broadcast
+func (f *network) Broadcasted(orderer string) {
+	if f.preflight != nil {
+		f.preflight.Succeeded(orderer)
+	}
+}
+
+// OrdererStatuses returns the outcome of the pre-flight check of the orderers, nil if disabled
+func (f *network) OrdererStatuses() []driver.OrdererStatus {
+	if f.preflight == nil {
+		return nil
+	}
+	return f.preflight.Statuses()
 }
 
 func (f *network) Peers() []*grpc.ConnectionConfig {
 	return f.peers
 }
 
+// ReadOnly returns true if this node is a read-only replica on this network
+func (f *network) ReadOnly() bool {
+	return f.readOnly
+}
+
 func (f *network) PickPeer() *grpc.ConnectionConfig {
 	return f.peers[rand.Intn(len(f.peers))]
 }
@@ -114,43 +210,96 @@ func (f *network) Channel(name string) (driver.Channel, error) {
 		logger.Debugf("Resorting to default channel [%s]", name)
 	}
 
-	chanQuiet := false
-	for _, chanDef := range f.channelDefs {
-		if chanDef.Name == name {
-			chanQuiet = chanDef.Quiet
-			break
-		}
-	}
+	chanQuiet := f.channelQuiet(name)
 
 	// first check the cache
 	f.mutex.RLock()
 	ch, ok := f.channels[name]
 	f.mutex.RUnlock()
 	if ok {
+		if err := ch.use(); err != nil {
+			return nil, err
+		}
 		logger.Debugf("Returning channel for [%s]", name)
 		return ch, nil
 	}
 
 	// create channel and store in cache
 	f.mutex.Lock()
-	defer f.mutex.Unlock()
-
 	ch, ok = f.channels[name]
 	if !ok {
+		if _, removed := f.removed[name]; removed {
+			f.mutex.Unlock()
+			return nil, errors.Wrapf(driver.ErrChannelRemoved, "channel [%s] of network [%s]", name, f.name)
+		}
+		if max := f.config.MaxChannels(); max > 0 && len(f.channels) >= max {
+			f.mutex.Unlock()
+			return nil, &driver.ErrTooManyChannels{Network: f.name, Channel: name, Max: max}
+		}
 		logger.Debugf("Channel [%s] not found, allocate resources", name)
 		var err error
-		ch, err = newChannel(f, name, chanQuiet)
+		ch, err = f.openChannel(name, chanQuiet, nil)
 		if err != nil {
+			f.mutex.Unlock()
+			return nil, err
+		}
+		if err := f.checkCompatibleChannel(ch); err != nil {
+			f.mutex.Unlock()
+			if err2 := ch.Close(); err2 != nil {
+				logger.Warnf("failed closing incompatible channel [%s]: [%s]", name, err2)
+			}
 			return nil, err
 		}
 		f.channels[name] = ch
+		f.channelMetrics.Open.With("network", f.name).Add(1)
 		logger.Debugf("Channel [%s] not found, created", name)
 	}
+	f.mutex.Unlock()
+
+	if !ok {
+		// the other channels can be fetched while the orderers are checked
+		f.checkOrderers(name)
+	}
 
 	logger.Debugf("Returning channel for [%s]", name)
 	return ch, nil
 }
 
+// channelQuiet returns true if the passed channel is configured quiet
+func (f *network) channelQuiet(name string) bool {
+	for _, chanDef := range f.channelDefs {
+		if chanDef.Name == name {
+			return chanDef.Quiet
+		}
+	}
+	return false
+}
+
+// openChannel creates an instance of the passed channel, rejoin is not nil if it joins again after its removal
+func (f *network) openChannel(name string, quiet bool, rejoin *driver.RejoinOptions) (*channel, error) {
+	if f.channelFactory != nil {
+		return f.channelFactory(name, quiet, rejoin)
+	}
+	return newChannel(f, name, quiet, rejoin)
+}
+
+// chaincodeSubscriptions returns the registry of the chaincode event subscriptions of the passed channel.
+// Its replay window is stored in the KVS, the subscriptions resume across the restarts of the node.
+func (f *network) chaincodeSubscriptions(channel string) (*committer.Subscriptions, error) {
+	f.subscriptionsLock.Lock()
+	defer f.subscriptionsLock.Unlock()
+	s, ok := f.subscriptions[channel]
+	if !ok {
+		var err error
+		s, err = committer.NewSubscriptions(kvs.GetService(f.sp), f.name, channel, f.config.DeliveryReplayWindow())
+		if err != nil {
+			return nil, err
+		}
+		f.subscriptions[channel] = s
+	}
+	return s, nil
+}
+
 func (f *network) Ledger(name string) (driver.Ledger, error) {
 	return f.Channel(name)
 }
@@ -176,7 +325,50 @@ func (f *network) TransactionManager() driver.TransactionManager {
 }
 
 func (f *network) Broadcast(blob interface{}) error {
-	return f.ordering.Broadcast(blob)
+	if f.readOnly {
+		return &driver.ErrReadOnly{Network: f.name, Operation: "broadcast"}
+	}
+	if err := f.checkReplay(blob); err != nil {
+		return err
+	}
+	if err := f.checkExpiry(blob); err != nil {
+		return err
+	}
+	tx, ok := blob.(ordering.Transaction)
+	if ok && f.checkReadSets {
+		if err := f.checkReadSet(tx); err != nil {
+			return err
+		}
+	}
+	if !ok || f.admission == nil {
+		return f.ordering.Broadcast(blob)
+	}
+	admitted, err := f.admission.Admit(tx)
+	if err != nil {
+		return err
+	}
+	if !admitted {
+		// the transaction joined a duplicate in flight
+		return nil
+	}
+	if err := f.ordering.Broadcast(blob); err != nil {
+		f.admission.Release(tx)
+		return err
+	}
+	return nil
+}
+
+// checkReadSet fails with a *driver.ErrStaleReadSet if the read set of the passed transaction is already stale,
+// unless the transaction skips the check
+func (f *network) checkReadSet(tx ordering.Transaction) error {
+	if s, ok := tx.(driver.StaleReadCheckSkipper); ok && s.StaleReadCheckSkipped() {
+		return nil
+	}
+	ch, err := f.Channel(tx.Channel())
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting channel [%s] to check the read set of [%s]", tx.Channel(), tx.ID())
+	}
+	return ordering.CheckReadSet(tx, ch)
 }
 
 func (f *network) SignerService() driver.SignerService {
@@ -196,24 +388,53 @@ func (f *network) init() error {
 	f.transactionManager = transaction.NewManager(f.sp, f)
 
 	var err error
+	f.addressOverrides, err = f.config.AddressOverrides()
+	if err != nil {
+		return err
+	}
 	f.orderers, err = f.config.Orderers()
 	if err != nil {
 		return errors.Wrap(err, "failed loading orderers")
 	}
+	for _, o := range f.orderers {
+		o.Address = f.overrideAddress(o.Address)
+		grpc.RecordEndpointSource(o.Address, "configuration of network ["+f.name+"]")
+	}
 	f.configuredOrderers = len(f.orderers)
+	f.resolution, err = f.config.Resolution()
+	if err != nil {
+		return err
+	}
 	logger.Debugf("Orderers [%v]", f.orderers)
 
 	f.peers, err = f.config.Peers()
 	if err != nil {
 		return errors.Wrap(err, "failed loading peers")
 	}
+	for _, p := range f.peers {
+		grpc.RecordEndpointSource(f.overrideAddress(p.Address), "configuration of network ["+f.name+"]")
+	}
 	logger.Debugf("Peers [%v]", f.peers)
 
+	f.foreignOrgs, err = f.config.EndorsementForeignOrgs()
+	if err != nil {
+		return errors.Wrap(err, "failed loading foreign organizations")
+	}
+	f.foreignPeers, err = f.config.EndorsementForeignPeers()
+	if err != nil {
+		return errors.Wrap(err, "failed loading foreign peers")
+	}
+	logger.Debugf("Foreign organizations [%v], peers [%v]", f.foreignOrgs, f.foreignPeers)
+
 	f.channelDefs, err = f.config.Channels()
 	if err != nil {
 		return errors.Wrap(err, "failed loading channels")
 	}
 	logger.Debugf("Channels [%v]", f.channelDefs)
+	f.removed, err = f.loadRemovedChannels()
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading the removed channels")
+	}
 	for _, channel := range f.channelDefs {
 		if channel.Default {
 			f.defaultChannel = channel.Name
@@ -221,13 +442,116 @@ func (f *network) init() error {
 		}
 	}
 
-	f.ordering = ordering.NewService(f.sp, f)
+	f.bus, err = events.GetBus(f.sp)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting event bus")
+	}
+
+	f.setReadOnly()
+	// the orderers are never contacted by a read-only network
+	if f.config.OrderingPreflightEnabled() && !f.readOnly {
+		f.preflight = ordering.NewPreflight(
+			ordering.NewDialer(f.localMembership, hash.GetHasher(f.sp), f.config.OrderingPreflightDeliver()),
+			f.config.OrderingPreflightTimeout(),
+			f.config.OrderingPreflightBudget(),
+			f.config.OrderingPreflightRecheckInterval(),
+		)
+	}
+	if f.config.OrderingAdmissionEnabled() && !f.readOnly {
+		f.admission, err = ordering.NewAdmission(
+			f.name,
+			kvs.GetService(f.sp),
+			f.config.OrderingAdmissionWindow(),
+			ordering.AdmissionPolicy(f.config.OrderingAdmissionPolicy()),
+		)
+		if err != nil {
+			return errors.WithMessagef(err, "failed creating admission layer")
+		}
+		go f.evictAdmissions(f.config.OrderingAdmissionWindow())
+	}
+	f.checkReadSets = f.config.OrderingStaleReadCheckEnabled()
+	f.ttl = f.config.OrderingTTL()
+	resources := GetResources(f.sp)
+	orderingService := ordering.NewService(f.sp, f)
+	f.ordering = orderingService
+	if !f.readOnly {
+		f.rotator = ordering.NewRotator(
+			f.name,
+			ordering.NewDialer(f.localMembership, hash.GetHasher(f.sp), false),
+			f.config.OrderingPreflightTimeout(),
+			f.config.OrderingTLSRotationGracePeriod(),
+			f.replaceConfigOrderers,
+			orderingService,
+			resources.RotationMetrics,
+		)
+	}
+	if err := f.initCompatibility(); err != nil {
+		return err
+	}
+	f.commitLimiter = resources.CommitLimiter
+	f.commitMetrics = resources.CommitMetrics
+	f.queryCacheMetrics = resources.QueryCacheMetrics
+	f.vaultMetrics = resources.VaultMetrics
+	f.channelMetrics = resources.ChannelMetrics
+	if ttl := f.config.ChannelIdleTTL(); ttl > 0 {
+		go f.unloadIdleChannels(ttl)
+	}
 	return nil
 }
 
-func (f *network) setConfigOrderers(orderers []*grpc.ConnectionConfig) {
+// onConfigApplied updates the orderers with the ones of the passed channel configuration, and checks their
+// compatibility, and the one of the channel, again. It is called by the channels of this network, in the order
+// of the sequences of their configurations, before the configuration is published on the bus.
+func (f *network) onConfigApplied(event driver.ConfigApplied) {
+	// the channel is in use already, the outcome is reported only
+	_ = f.checkCompatibleApplication(event.Channel, event.ApplicationCapabilities)
+	if len(event.Orderers) == 0 {
+		return
+	}
+	logger.Debugf("[channel: %s] Updating the list of orderers: (%d) found", event.Channel, len(event.Orderers))
+	f.setConfigOrderers(event.Channel, event.Orderers)
+	f.checkOrderers(event.Channel)
+	go func() { _ = f.checkCompatibleOrderers(event.Channel, f.Orderers()) }()
+}
+
+// checkOrderers runs the pre-flight check of the orderers, if enabled, waiting at most for its budget
+func (f *network) checkOrderers(channel string) {
+	if f.preflight == nil {
+		return
+	}
+	f.preflight.Check(channel, f.Orderers())
+}
+
+// overrideAddress returns the address to connect to in place of the passed one
+func (f *network) overrideAddress(address string) string {
+	if to, ok := f.addressOverrides[address]; ok {
+		logger.Debugf("connect to [%s] in place of [%s]", to, address)
+		return to
+	}
+	return address
+}
+
+// setConfigOrderers replaces the orderers of the channel configurations with the passed ones.
+// If they rotate their TLS roots, the rotator applies them make-before-break.
+func (f *network) setConfigOrderers(channel string, orderers []*grpc.ConnectionConfig) {
+	if f.rotator != nil {
+		f.orderersLock.RLock()
+		current := f.orderers[f.configuredOrderers:]
+		f.orderersLock.RUnlock()
+		if f.rotator.Rotate(channel, current, orderers) {
+			return
+		}
+	}
+	f.replaceConfigOrderers(orderers)
+}
+
+func (f *network) replaceConfigOrderers(orderers []*grpc.ConnectionConfig) {
+	f.orderersLock.Lock()
+	defer f.orderersLock.Unlock()
 	// the first configuredOrderers are from the configuration, keep them
-	// and append the new ones
-	f.orderers = append(f.orderers[:f.configuredOrderers], orderers...)
+	// and append the new ones. The slice is copied, the previous one may still be in use.
+	newOrderers := make([]*grpc.ConnectionConfig, 0, f.configuredOrderers+len(orderers))
+	newOrderers = append(newOrderers, f.orderers[:f.configuredOrderers]...)
+	f.orderers = append(newOrderers, orderers...)
 	logger.Debugf("New Orderers [%d]", len(f.orderers))
 }
@@ -0,0 +1,408 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/canonical"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// AdmissionPolicy tells what happens to the transactions duplicating one in flight
+type AdmissionPolicy string
+
+const (
+	// RejectDuplicates makes the broadcast of a duplicate fail with driver.ErrDuplicateInFlight
+	RejectDuplicates AdmissionPolicy = "reject"
+	// JoinDuplicates does not broadcast a duplicate, its finality is the one of the transaction in flight
+	JoinDuplicates AdmissionPolicy = "join"
+)
+
+const (
+	admittedPrefix   = "admission"
+	admittedTxPrefix = "admission-tx"
+	joinedPrefix     = "admission-joined"
+)
+
+// KVS models the store the admission entries are kept in, to survive restarts
+type KVS interface {
+	Exists(id string) bool
+	Put(id string, state interface{}) error
+	Get(id string, state interface{}) error
+	Delete(id string) error
+	GetByPartialCompositeID(prefix string, attrs []string) (kvs.Iterator, error)
+}
+
+// admitted is the entry of a transaction broadcast and not final yet
+type admitted struct {
+	TxID     string
+	Admitted time.Time
+	// Joined are the duplicates that joined the transaction
+	Joined []string
+}
+
+// joined is the entry of a duplicate that joined a transaction in flight
+type joined struct {
+	Original string
+	// Final is the time the original reached finality, zero before
+	Final time.Time
+}
+
+// Admission detects, before broadcast, the transactions semantically identical to one broadcast within a window
+// and not final yet. The fingerprint of a transaction is the hash of its chaincode, arguments and read set.
+// The fingerprints are cleared when the transactions reach finality, or are abandoned, or they expire at the end of
+// the window: Evict removes the entries expired.
+type Admission struct {
+	network string
+	kvs     KVS
+	window  time.Duration
+	policy  AdmissionPolicy
+	now     func() time.Time
+
+	// lock serializes the checks with the updates of the entries
+	lock sync.Mutex
+}
+
+// NewAdmission returns the admission layer of the passed network, keeping its entries in the passed store
+func NewAdmission(network string, kvs KVS, window time.Duration, policy AdmissionPolicy) (*Admission, error) {
+	switch policy {
+	case "":
+		policy = RejectDuplicates
+	case RejectDuplicates, JoinDuplicates:
+	default:
+		return nil, errors.Errorf("invalid admission policy [%s], expected [%s] or [%s]", policy, RejectDuplicates, JoinDuplicates)
+	}
+	return &Admission{
+		network: network,
+		kvs:     kvs,
+		window:  window,
+		policy:  policy,
+		now:     time.Now,
+	}, nil
+}
+
+// Admit returns true if the passed transaction must be broadcast.
+// If the transaction duplicates one in flight, with the reject policy, it returns an error wrapping driver.ErrDuplicateInFlight,
+// with the join policy, it returns false: the transaction joins the one in flight.
+// The transactions that cannot be fingerprinted are always admitted.
+func (a *Admission) Admit(tx Transaction) (bool, error) {
+	fingerprint, err := Fingerprint(tx)
+	if err != nil {
+		logger.Debugf("cannot fingerprint transaction [%s], admit it [%s]", tx.ID(), err)
+		return true, nil
+	}
+	key, err := kvs.CreateCompositeKey(admittedPrefix, []string{tx.Channel(), a.network, fingerprint})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed creating admission key for [%s]", tx.ID())
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := a.now()
+	if a.kvs.Exists(key) {
+		entry := &admitted{}
+		if err := a.kvs.Get(key, entry); err != nil {
+			return false, errors.Wrapf(err, "failed loading admission entry for [%s]", tx.ID())
+		}
+		switch {
+		case entry.TxID == tx.ID():
+			// broadcast again
+			return true, nil
+		case now.Sub(entry.Admitted) <= a.window:
+			return false, a.duplicate(tx, key, entry)
+		}
+		logger.Debugf("admission entry of [%s] expired, replace it with [%s]", entry.TxID, tx.ID())
+		a.deleteTx(tx.Channel(), entry.TxID)
+	}
+
+	if err := a.kvs.Put(key, &admitted{TxID: tx.ID(), Admitted: now}); err != nil {
+		return false, errors.Wrapf(err, "failed storing admission entry for [%s]", tx.ID())
+	}
+	txKey, err := kvs.CreateCompositeKey(admittedTxPrefix, []string{tx.Channel(), a.network, tx.ID()})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed creating admission key for [%s]", tx.ID())
+	}
+	if err := a.kvs.Put(txKey, fingerprint); err != nil {
+		return false, errors.Wrapf(err, "failed storing admission entry for [%s]", tx.ID())
+	}
+	return true, nil
+}
+
+func (a *Admission) duplicate(tx Transaction, key string, entry *admitted) error {
+	if a.policy == RejectDuplicates {
+		logger.Debugf("transaction [%s] duplicates [%s], reject it", tx.ID(), entry.TxID)
+		return errors.Wrapf(driver.ErrDuplicateInFlight, "transaction [%s] on channel [%s] duplicates transaction [%s], still in flight", tx.ID(), tx.Channel(), entry.TxID)
+	}
+
+	logger.Debugf("transaction [%s] duplicates [%s], join it", tx.ID(), entry.TxID)
+	joinedKey, err := kvs.CreateCompositeKey(joinedPrefix, []string{tx.Channel(), a.network, tx.ID()})
+	if err != nil {
+		return errors.Wrapf(err, "failed creating admission key for [%s]", tx.ID())
+	}
+	if err := a.kvs.Put(joinedKey, &joined{Original: entry.TxID}); err != nil {
+		return errors.Wrapf(err, "failed storing joined transaction [%s]", tx.ID())
+	}
+	entry.Joined = append(entry.Joined, tx.ID())
+	if err := a.kvs.Put(key, entry); err != nil {
+		return errors.Wrapf(err, "failed storing admission entry for [%s]", entry.TxID)
+	}
+	return nil
+}
+
+// Release forgets the passed transaction, whose broadcast failed, the next duplicate is admitted.
+// The duplicates that joined it share its fate.
+func (a *Admission) Release(tx Transaction) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.deleteTx(tx.Channel(), tx.ID())
+}
+
+// Finalized clears the fingerprint of the passed transaction, that reached finality or has been abandoned.
+// It returns the transactions that joined it.
+func (a *Admission) Finalized(channel, txID string) []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	entry := a.deleteTx(channel, txID)
+	if entry == nil {
+		return nil
+	}
+	now := a.now()
+	for _, id := range entry.Joined {
+		key, err := kvs.CreateCompositeKey(joinedPrefix, []string{channel, a.network, id})
+		if err != nil {
+			continue
+		}
+		if err := a.kvs.Put(key, &joined{Original: txID, Final: now}); err != nil {
+			logger.Warnf("failed storing finality of joined transaction [%s]: [%s]", id, err)
+		}
+	}
+	return entry.Joined
+}
+
+// Original returns the transaction the passed one joined, if any.
+// A duplicate is resolved up to a window after its original reached finality.
+func (a *Admission) Original(channel, txID string) (string, bool) {
+	key, err := kvs.CreateCompositeKey(joinedPrefix, []string{channel, a.network, txID})
+	if err != nil {
+		return "", false
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.kvs.Exists(key) {
+		return "", false
+	}
+	entry := &joined{}
+	if err := a.kvs.Get(key, entry); err != nil {
+		logger.Warnf("failed loading joined transaction [%s]: [%s]", txID, err)
+		return "", false
+	}
+	if !entry.Final.IsZero() && a.now().Sub(entry.Final) > a.window {
+		if err := a.kvs.Delete(key); err != nil {
+			logger.Warnf("failed deleting joined transaction [%s]: [%s]", txID, err)
+		}
+		return "", false
+	}
+	return entry.Original, true
+}
+
+// Evict removes the entries of the transactions broadcast before the window and not final yet, and the ones of the
+// duplicates resolved for a window after their original reached finality, or whose original has been forgotten.
+// It returns the number of entries removed. The entries are scanned without holding the lock, which is held
+// for the removal of each of them only.
+func (a *Admission) Evict() int {
+	now := a.now()
+	n := 0
+	err := a.scan(admittedPrefix, func(key string, attrs []string) {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		entry := &admitted{}
+		if err := a.kvs.Get(key, entry); err != nil || now.Sub(entry.Admitted) <= a.window {
+			// replaced meanwhile
+			return
+		}
+		a.deleteTx(attrs[0], entry.TxID)
+		if a.kvs.Exists(key) {
+			if err := a.kvs.Delete(key); err != nil {
+				logger.Warnf("failed deleting admission entry [%s]: [%s]", key, err)
+				return
+			}
+		}
+		n++
+	})
+	if err != nil {
+		logger.Warnf("failed scanning admission entries: [%s]", err)
+	}
+
+	err = a.scan(joinedPrefix, func(key string, attrs []string) {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		entry := &joined{}
+		if err := a.kvs.Get(key, entry); err != nil {
+			return
+		}
+		if entry.Final.IsZero() && a.inFlight(attrs[0], entry.Original) {
+			return
+		}
+		if !entry.Final.IsZero() && now.Sub(entry.Final) <= a.window {
+			return
+		}
+		if err := a.kvs.Delete(key); err != nil {
+			logger.Warnf("failed deleting joined transaction [%s]: [%s]", key, err)
+			return
+		}
+		n++
+	})
+	if err != nil {
+		logger.Warnf("failed scanning joined transactions: [%s]", err)
+	}
+	return n
+}
+
+// scan calls the passed function with the keys of the entries of this network with the passed prefix,
+// and their attributes, once the scan is over
+func (a *Admission) scan(prefix string, f func(key string, attrs []string)) error {
+	it, err := a.kvs.GetByPartialCompositeID(prefix, nil)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for it.HasNext() {
+		var raw json.RawMessage
+		key, err := it.Next(&raw)
+		if err != nil {
+			it.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	it.Close()
+	for _, key := range keys {
+		_, attrs, err := kvs.SplitCompositeKey(key)
+		if err != nil || len(attrs) != 3 || attrs[1] != a.network {
+			continue
+		}
+		f(key, attrs)
+	}
+	return nil
+}
+
+// inFlight returns true if the passed transaction has an admission entry
+func (a *Admission) inFlight(channel, txID string) bool {
+	txKey, err := kvs.CreateCompositeKey(admittedTxPrefix, []string{channel, a.network, txID})
+	return err == nil && a.kvs.Exists(txKey)
+}
+
+// deleteTx deletes the entry of the passed transaction and returns it, nil if there is none.
+// The caller must hold the lock.
+func (a *Admission) deleteTx(channel, txID string) *admitted {
+	txKey, err := kvs.CreateCompositeKey(admittedTxPrefix, []string{channel, a.network, txID})
+	if err != nil || !a.kvs.Exists(txKey) {
+		return nil
+	}
+	var fingerprint string
+	if err := a.kvs.Get(txKey, &fingerprint); err != nil {
+		logger.Warnf("failed loading admission entry for [%s]: [%s]", txID, err)
+		return nil
+	}
+	if err := a.kvs.Delete(txKey); err != nil {
+		logger.Warnf("failed deleting admission entry for [%s]: [%s]", txID, err)
+	}
+
+	key, err := kvs.CreateCompositeKey(admittedPrefix, []string{channel, a.network, fingerprint})
+	if err != nil || !a.kvs.Exists(key) {
+		return nil
+	}
+	entry := &admitted{}
+	if err := a.kvs.Get(key, entry); err != nil {
+		logger.Warnf("failed loading admission entry for [%s]: [%s]", txID, err)
+		return nil
+	}
+	if entry.TxID != txID {
+		// the fingerprint has been taken over by another transaction
+		return nil
+	}
+	if err := a.kvs.Delete(key); err != nil {
+		logger.Warnf("failed deleting admission entry for [%s]: [%s]", txID, err)
+	}
+	return entry
+}
+
+// Fingerprint returns the semantic fingerprint of the passed transaction, that is, the hash of its chaincode,
+// of its arguments and of its read set, as endorsed by the first endorser
+func Fingerprint(tx Transaction) (string, error) {
+	if tx.Proposal() == nil {
+		return "", errors.New("no proposal")
+	}
+	responses := tx.ProposalResponses()
+	if len(responses) == 0 {
+		return "", errors.New("no proposal responses")
+	}
+	payload, err := protoutil.UnmarshalChaincodeProposalPayload(tx.Proposal().Payload())
+	if err != nil {
+		return "", errors.Wrap(err, "failed unmarshalling proposal payload")
+	}
+	cis, err := protoutil.UnmarshalChaincodeInvocationSpec(payload.Input)
+	if err != nil {
+		return "", errors.Wrap(err, "failed unmarshalling chaincode invocation spec")
+	}
+	if cis.ChaincodeSpec == nil || cis.ChaincodeSpec.ChaincodeId == nil || cis.ChaincodeSpec.Input == nil {
+		return "", errors.New("no chaincode invocation")
+	}
+	txRWSet := &rwsetutil.TxRwSet{}
+	if err := txRWSet.FromProtoBytes(responses[0].Results()); err != nil {
+		return "", errors.Wrap(err, "failed unmarshalling read-write set")
+	}
+
+	h := sha256.New()
+	l := make([]byte, 8)
+	write := func(b []byte) {
+		binary.BigEndian.PutUint64(l, uint64(len(b)))
+		h.Write(l)
+		h.Write(b)
+	}
+	write([]byte(tx.Channel()))
+	write([]byte(cis.ChaincodeSpec.ChaincodeId.Name))
+	binary.BigEndian.PutUint64(l, uint64(len(cis.ChaincodeSpec.Input.Args)))
+	h.Write(l)
+	for _, arg := range cis.ChaincodeSpec.Input.Args {
+		write(arg)
+	}
+	for _, ns := range txRWSet.NsRwSets {
+		write([]byte(ns.NameSpace))
+		for _, read := range ns.KvRwSet.Reads {
+			write([]byte(read.Key))
+			if read.Version != nil {
+				binary.BigEndian.PutUint64(l, read.Version.BlockNum)
+				h.Write(l)
+				binary.BigEndian.PutUint64(l, read.Version.TxNum)
+				h.Write(l)
+			}
+		}
+		for _, rq := range ns.KvRwSet.RangeQueriesInfo {
+			raw, err := canonical.Proto(rq)
+			if err != nil {
+				return "", errors.Wrap(err, "failed marshalling range query")
+			}
+			write(raw)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,277 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryKVS stores the states as json, as the kvs does
+type memoryKVS map[string][]byte
+
+func (m memoryKVS) Exists(id string) bool {
+	_, ok := m[id]
+	return ok
+}
+
+func (m memoryKVS) Put(id string, state interface{}) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	m[id] = raw
+	return nil
+}
+
+func (m memoryKVS) Get(id string, state interface{}) error {
+	raw, ok := m[id]
+	if !ok {
+		return errors.Errorf("state [%s] not found", id)
+	}
+	return json.Unmarshal(raw, state)
+}
+
+func (m memoryKVS) Delete(id string) error {
+	delete(m, id)
+	return nil
+}
+
+func (m memoryKVS) GetByPartialCompositeID(prefix string, attrs []string) (kvs.Iterator, error) {
+	partial, err := kvs.CreateCompositeKey(prefix, attrs)
+	if err != nil {
+		return nil, err
+	}
+	it := &memoryIterator{kvs: m}
+	for key := range m {
+		if strings.HasPrefix(key, partial) {
+			it.keys = append(it.keys, key)
+		}
+	}
+	sort.Strings(it.keys)
+	return it, nil
+}
+
+type memoryIterator struct {
+	kvs  memoryKVS
+	keys []string
+	next string
+}
+
+func (i *memoryIterator) HasNext() bool {
+	if len(i.keys) == 0 {
+		return false
+	}
+	i.next, i.keys = i.keys[0], i.keys[1:]
+	return true
+}
+
+func (i *memoryIterator) Next(state interface{}) (string, error) {
+	return i.next, json.Unmarshal(i.kvs[i.next], state)
+}
+
+func (i *memoryIterator) Close() error { return nil }
+
+type fakeProposal struct {
+	payload []byte
+}
+
+func (p *fakeProposal) Header() []byte  { return nil }
+func (p *fakeProposal) Payload() []byte { return p.payload }
+
+type fakeResponse struct {
+	driver.ProposalResponse
+	results []byte
+}
+
+func (r *fakeResponse) Results() []byte { return r.results }
+
+type fakeTransaction struct {
+	id       string
+	proposal driver.Proposal
+	results  []byte
+}
+
+func (t *fakeTransaction) Channel() string        { return "mychannel" }
+func (t *fakeTransaction) ID() string             { return t.id }
+func (t *fakeTransaction) Creator() view.Identity { return nil }
+func (t *fakeTransaction) Proposal() driver.Proposal {
+	return t.proposal
+}
+func (t *fakeTransaction) ProposalResponses() []driver.ProposalResponse {
+	if t.results == nil {
+		return nil
+	}
+	return []driver.ProposalResponse{&fakeResponse{results: t.results}}
+}
+func (t *fakeTransaction) Bytes() ([]byte, error) { return nil, nil }
+
+// newTransaction returns a transaction invoking the passed function of the asset chaincode, reading the passed key
+func newTransaction(t *testing.T, id string, function string, key string, block uint64) *fakeTransaction {
+	cis := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		ChaincodeId: &pb.ChaincodeID{Name: "asset"},
+		Input:       &pb.ChaincodeInput{Args: [][]byte{[]byte(function), []byte(key)}},
+	}}
+	payload := protoutil.MarshalOrPanic(&pb.ChaincodeProposalPayload{Input: protoutil.MarshalOrPanic(cis)})
+
+	txRWSet := &rwsetutil.TxRwSet{NsRwSets: []*rwsetutil.NsRwSet{{
+		NameSpace: "asset",
+		KvRwSet: &kvrwset.KVRWSet{
+			Reads:  []*kvrwset.KVRead{{Key: key, Version: &kvrwset.Version{BlockNum: block}}},
+			Writes: []*kvrwset.KVWrite{{Key: key, Value: []byte(id)}},
+		},
+	}}}
+	results, err := txRWSet.ToProtoBytes()
+	assert.NoError(t, err)
+	return &fakeTransaction{id: id, proposal: &fakeProposal{payload: payload}, results: results}
+}
+
+func TestAdmissionReject(t *testing.T) {
+	store := memoryKVS{}
+	a, err := NewAdmission("default", store, time.Minute, RejectDuplicates)
+	assert.NoError(t, err)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	admitted, err := a.Admit(newTransaction(t, "tx1", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.True(t, admitted)
+	// the same transaction can be broadcast again
+	admitted, err = a.Admit(newTransaction(t, "tx1", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.True(t, admitted)
+	// the writes do not matter, the arguments and the reads do
+	admitted, err = a.Admit(newTransaction(t, "tx2", "transfer", "alice", 1))
+	assert.EqualError(t, err, "transaction [tx2] on channel [mychannel] duplicates transaction [tx1], still in flight: duplicate of a transaction in flight")
+	assert.True(t, errors.Is(err, driver.ErrDuplicateInFlight))
+	assert.False(t, admitted)
+	for _, tx := range []*fakeTransaction{
+		newTransaction(t, "tx3", "burn", "alice", 1),
+		newTransaction(t, "tx4", "transfer", "bob", 1),
+		newTransaction(t, "tx5", "transfer", "alice", 2),
+		{id: "tx6"},
+	} {
+		admitted, err = a.Admit(tx)
+		assert.NoError(t, err)
+		assert.True(t, admitted, "transaction [%s] not admitted", tx.id)
+	}
+
+	// the entries survive a restart
+	a, err = NewAdmission("default", store, time.Minute, RejectDuplicates)
+	assert.NoError(t, err)
+	a.now = func() time.Time { return now }
+	_, err = a.Admit(newTransaction(t, "tx7", "transfer", "alice", 1))
+	assert.Error(t, err)
+	// and they are cleared at finality
+	assert.Empty(t, a.Finalized("mychannel", "tx1"))
+	admitted, err = a.Admit(newTransaction(t, "tx7", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.True(t, admitted)
+
+	// or when the broadcast fails
+	a.Release(newTransaction(t, "tx7", "transfer", "alice", 1))
+	admitted, err = a.Admit(newTransaction(t, "tx8", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.True(t, admitted)
+
+	// or at the end of the window
+	now = now.Add(2 * time.Minute)
+	admitted, err = a.Admit(newTransaction(t, "tx9", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.True(t, admitted)
+	assert.Empty(t, a.Finalized("mychannel", "tx8"))
+
+	_, err = NewAdmission("default", store, time.Minute, "drop")
+	assert.EqualError(t, err, "invalid admission policy [drop], expected [reject] or [join]")
+}
+
+func TestAdmissionJoin(t *testing.T) {
+	a, err := NewAdmission("default", memoryKVS{}, time.Minute, JoinDuplicates)
+	assert.NoError(t, err)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	admitted, err := a.Admit(newTransaction(t, "tx1", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.True(t, admitted)
+	for _, id := range []string{"tx2", "tx3"} {
+		admitted, err = a.Admit(newTransaction(t, id, "transfer", "alice", 1))
+		assert.NoError(t, err)
+		assert.False(t, admitted)
+		original, ok := a.Original("mychannel", id)
+		assert.True(t, ok)
+		assert.Equal(t, "tx1", original)
+	}
+	_, ok := a.Original("mychannel", "tx1")
+	assert.False(t, ok)
+	_, ok = a.Original("otherchannel", "tx2")
+	assert.False(t, ok)
+
+	// the duplicates get the finality of the original
+	assert.Equal(t, []string{"tx2", "tx3"}, a.Finalized("mychannel", "tx1"))
+	assert.Empty(t, a.Finalized("mychannel", "tx1"))
+	original, ok := a.Original("mychannel", "tx2")
+	assert.True(t, ok)
+	assert.Equal(t, "tx1", original)
+
+	// up to a window after it
+	now = now.Add(2 * time.Minute)
+	_, ok = a.Original("mychannel", "tx2")
+	assert.False(t, ok)
+}
+
+func TestAdmissionEvict(t *testing.T) {
+	store := memoryKVS{}
+	a, err := NewAdmission("default", store, time.Minute, JoinDuplicates)
+	assert.NoError(t, err)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	// tx1 never reaches finality, tx2 joined it, tx3 reaches finality with tx4 joining it
+	for _, id := range []string{"tx1", "tx2"} {
+		_, err := a.Admit(newTransaction(t, id, "transfer", "alice", 1))
+		assert.NoError(t, err)
+	}
+	for _, id := range []string{"tx3", "tx4"} {
+		_, err := a.Admit(newTransaction(t, id, "transfer", "bob", 1))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"tx4"}, a.Finalized("mychannel", "tx3"))
+	// the entries of the other networks are left alone
+	other, err := NewAdmission("other", store, time.Minute, JoinDuplicates)
+	assert.NoError(t, err)
+	_, err = other.Admit(newTransaction(t, "tx5", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.Len(t, store, 6)
+
+	// within the window, nothing is evicted
+	assert.Equal(t, 0, a.Evict())
+	assert.Len(t, store, 6)
+
+	// past it, only the entries of the other network remain
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, 3, a.Evict())
+	assert.Len(t, store, 2)
+	_, ok := a.Original("mychannel", "tx2")
+	assert.False(t, ok)
+	admitted, err := a.Admit(newTransaction(t, "tx6", "transfer", "alice", 1))
+	assert.NoError(t, err)
+	assert.True(t, admitted)
+}
@@ -8,6 +8,7 @@ package ordering
 
 import (
 	"context"
+	"crypto/sha256"
 	"sync"
 	"time"
 
@@ -17,11 +18,13 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	common2 "github.com/hyperledger/fabric-protos-go/common"
 	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
 	"go.uber.org/zap/zapcore"
 )
@@ -50,6 +53,12 @@ type Network interface {
 	Config() *config.Config
 }
 
+// BroadcastObserver is implemented by the networks that track the orderers accepting the broadcasts
+type BroadcastObserver interface {
+	// Broadcasted is called once the orderer with the passed address accepted a broadcast
+	Broadcasted(orderer string)
+}
+
 type Transaction interface {
 	Channel() string
 	ID() string
@@ -86,6 +95,9 @@ func (o *service) Broadcast(blob interface{}) error {
 		if err != nil {
 			return err
 		}
+		if err := o.storeTransaction(b); err != nil {
+			return err
+		}
 	case *transaction.Envelope:
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf("new envelope to broadcast (boxed)...")
@@ -100,23 +112,118 @@ func (o *service) Broadcast(blob interface{}) error {
 		return errors.Errorf("invalid blob's type, got [%T]", blob)
 	}
 
-	return o.broadcastEnvelope(env)
+	if err := o.checkEnvelopeSize(env); err != nil {
+		return err
+	}
+	sentAt := time.Now()
+	orderer, err := o.broadcastEnvelope(env)
+	if err != nil {
+		return err
+	}
+	if observer, ok := o.network.(BroadcastObserver); ok {
+		observer.Broadcasted(orderer)
+	}
+	o.recordBroadcast(env, orderer, sentAt)
+	return nil
 }
 
-func (o *service) createFabricEndorseTransactionEnvelope(tx Transaction) (*common2.Envelope, error) {
+// storeTransaction stores, before its broadcast, the passed transaction, its transient, and the provenance of the
+// endorsements it carries. They are written in a single unit of work: after a crash, either all of them or none are found.
+// The envelope is not stored: it is built from the transaction, and a stored envelope marks the transactions ordered
+// already, see checkReplay. The committer stores the one delivered, if the transaction is not known by the vault.
+// The busy status of the transaction is in the vault already, it is not part of the unit of work.
+func (o *service) storeTransaction(tx Transaction) error {
 	ch, err := o.network.Channel(tx.Channel())
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed getting channel [%s]", tx.Channel())
+		return errors.Wrapf(err, "failed getting channel [%s]", tx.Channel())
 	}
 	txRaw, err := tx.Bytes()
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed marshalling tx [%s]", tx.ID())
+		return errors.Wrapf(err, "failed marshalling tx [%s]", tx.ID())
+	}
+
+	uow := bookkeeping.Begin(o.sp)
+	defer uow.Discard()
+	stores := &driver.BookkeepingStores{
+		Envelopes:    ch.EnvelopeService(),
+		Transactions: ch.TransactionService(),
+		Metadata:     ch.MetadataService(),
+	}
+	if p, ok := ch.(driver.BookkeepingProvider); ok {
+		stores = p.Enlist(uow)
+	} else if p, ok := ch.(driver.ProvenanceProvider); ok {
+		stores.Provenance = p.ProvenanceService()
+	}
+
+	if err := stores.Transactions.StoreTransaction(tx.ID(), txRaw); err != nil {
+		return errors.Wrap(err, "failed storing tx")
+	}
+	if t, ok := tx.(interface{ Transient() driver.TransientMap }); ok && len(t.Transient()) != 0 {
+		if err := stores.Metadata.StoreTransient(tx.ID(), t.Transient()); err != nil {
+			return errors.WithMessagef(err, "failed storing transient of tx [%s]", tx.ID())
+		}
+	}
+	if stores.Provenance != nil {
+		var endorsers []driver.EndorserProvenance
+		now := time.Now()
+		for _, response := range tx.ProposalResponses() {
+			digest := sha256.Sum256(response.Payload())
+			endorsers = append(endorsers, driver.EndorserProvenance{
+				Endorser:       response.Endorser(),
+				ResponseDigest: digest[:],
+				Timestamp:      now,
+			})
+		}
+		if err := stores.Provenance.RecordEndorsements(tx.ID(), endorsers); err != nil {
+			return errors.WithMessagef(err, "failed recording the endorsements of [%s]", tx.ID())
+		}
+	}
+	if err := uow.Commit(); err != nil {
+		return errors.WithMessagef(err, "failed storing tx [%s]", tx.ID())
+	}
+	return nil
+}
+
+// recordBroadcast records, in the provenance of the transaction in the passed envelope, the orderer that acknowledged it.
+// The channel of the transaction then measures the time it takes to be committed.
+func (o *service) recordBroadcast(env *common2.Envelope, orderer string, sentAt time.Time) {
+	chdr, err := protoutil.ChannelHeader(env)
+	if err != nil {
+		logger.Warnf("failed reading the channel header of the envelope broadcast to [%s]: [%s]", orderer, err)
+		return
+	}
+	if chdr.Type != int32(common2.HeaderType_ENDORSER_TRANSACTION) {
+		return
+	}
+	if ch, err := o.network.Channel(chdr.ChannelId); err == nil {
+		if observer, ok := ch.(driver.BroadcastObserver); ok {
+			observer.ObserveBroadcast(chdr.TxId, sentAt)
+		}
 	}
-	err = ch.TransactionService().StoreTransaction(tx.ID(), txRaw)
+	ps := o.provenanceService(chdr.ChannelId)
+	if ps == nil {
+		return
+	}
+	if err := ps.RecordBroadcast(chdr.TxId, orderer, sentAt, time.Now()); err != nil {
+		logger.Warnf("failed recording the broadcast of [%s]: [%s]", chdr.TxId, err)
+	}
+}
+
+// provenanceService returns the provenance service of the passed channel, nil if it does not record the provenance
+func (o *service) provenanceService(channel string) driver.ProvenanceService {
+	ch, err := o.network.Channel(channel)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed storing tx")
+		logger.Warnf("failed getting channel [%s] to record provenance: [%s]", channel, err)
+		return nil
 	}
+	p, ok := ch.(driver.ProvenanceProvider)
+	if !ok {
+		return nil
+	}
+	return p.ProvenanceService()
+}
 
+func (o *service) createFabricEndorseTransactionEnvelope(tx Transaction) (*common2.Envelope, error) {
 	// tx contains the proposal and the endorsements, assemble them in a fabric transaction
 	signerID := tx.Creator()
 	signer, err := o.network.SignerService().GetSigner(signerID)
@@ -192,7 +299,26 @@ func (o *service) cleanupOrderedClient() {
 	}
 }
 
-func (o *service) broadcastEnvelope(env *common2.Envelope) error {
+// Reconnect makes the next broadcasts open a new connection to an orderer, and returns a function closing the current one.
+// It waits for the broadcast in flight, if any.
+func (o *service) Reconnect() func() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	stream, client := o.oStream, o.oClient
+	o.oStream, o.oClient = nil, nil
+	return func() {
+		if stream != nil {
+			stream.CloseSend()
+		}
+		if client != nil {
+			logger.Debugf("retire ordering client to [%s]", client.ordererAddr)
+			client.Close()
+		}
+	}
+}
+
+// broadcastEnvelope sends the passed envelope to an orderer, and returns the address of the one that acknowledged it
+func (o *service) broadcastEnvelope(env *common2.Envelope) (string, error) {
 	forceConnect := false
 	stream, err := o.getOrSetOrdererClient()
 	if err != nil {
@@ -236,12 +362,12 @@ func (o *service) broadcastEnvelope(env *common2.Envelope) error {
 		}
 
 		if status.GetStatus() != common2.Status_SUCCESS {
-			return errors.Wrapf(err, "failed broadcasting, status %s", common2.Status_name[int32(status.GetStatus())])
+			return "", errors.Errorf("failed broadcasting, status %s", common2.Status_name[int32(status.GetStatus())])
 		}
 
-		return nil
+		return o.oClient.ordererAddr, nil
 	}
-	return errors.Wrap(err, "failed to send transaction to orderer")
+	return "", errors.Wrap(err, "failed to send transaction to orderer")
 }
 
 type signerWrapper struct {
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/fabrictest"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	common2 "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func (n *fakeNetwork) PickOrderer() *grpc.ConnectionConfig {
+	return n.orderers.Pick()
+}
+
+func (n *fakeNetwork) Config() *config.Config {
+	return n.config
+}
+
+// newFakeNetwork returns a network with no channel, picking the passed orderers in turn
+func newFakeNetwork(t *testing.T, retries int, orderers ...fabrictest.Endpoint) *fakeNetwork {
+	cp := &mock.ConfigProvider{}
+	cp.GetIntReturns(retries)
+	c, err := config.New(cp, "default", true)
+	assert.NoError(t, err)
+	return &fakeNetwork{orderers: fabrictest.NewRoundRobin(orderers...), config: c}
+}
+
+func TestBroadcastRetry(t *testing.T) {
+	o1, err := fabrictest.NewOrderer(nil)
+	assert.NoError(t, err)
+	defer o1.Stop()
+	o2, err := fabrictest.NewOrderer(nil)
+	assert.NoError(t, err)
+	defer o2.Stop()
+	// the first orderer drops the stream, the broadcast is retried with the second one
+	o1.Broadcasts.Default(fabrictest.Drop())
+
+	s := NewService(nil, newFakeNetwork(t, 3, o1, o2))
+	orderer, err := s.broadcastEnvelope(fabrictest.NewEnvelope("mychannel", "tx1"))
+	assert.NoError(t, err)
+	assert.Equal(t, o2.Address(), orderer)
+	assert.Len(t, o1.Received(), 1)
+	assert.Len(t, o2.Received(), 1)
+
+	// the stream to the second orderer is reused
+	assert.NoError(t, s.Broadcast(fabrictest.NewEnvelope("mychannel", "tx2")))
+	assert.Len(t, o1.Received(), 1)
+	assert.Len(t, o2.Received(), 2)
+
+	// the rejections are not retried
+	o2.Broadcasts.Then(fabrictest.Reject(common2.Status_BAD_REQUEST))
+	err = s.Broadcast(fabrictest.NewEnvelope("mychannel", "tx3"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed broadcasting, status BAD_REQUEST")
+	assert.Len(t, o2.Received(), 3)
+}
+
+func TestBroadcastRetriesExhausted(t *testing.T) {
+	o, err := fabrictest.NewOrderer(nil)
+	assert.NoError(t, err)
+	defer o.Stop()
+	o.Broadcasts.Default(fabrictest.Drop())
+
+	s := NewService(nil, newFakeNetwork(t, 2, o))
+	err = s.Broadcast(fabrictest.NewEnvelope("mychannel", "tx1"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to send transaction to orderer")
+	assert.Len(t, o.Received(), 2)
+}
@@ -0,0 +1,333 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"context"
+	"crypto/tls"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/compat"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/delivery"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric-protos-go/common"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/pkg/errors"
+	grpc2 "google.golang.org/grpc"
+)
+
+// Dialer checks that the passed orderer can be used for the passed channel, within the deadline of the context
+type Dialer func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) error
+
+// MaxRecheckInterval bounds the interval between the checks of a degraded orderer
+const MaxRecheckInterval = 5 * time.Minute
+
+// Preflight checks the orderer endpoints and keeps track of the ones that failed, the degraded ones.
+// A degraded endpoint is checked again, with an exponential backoff, until it passes the check, or until a
+// broadcast to it succeeds.
+type Preflight struct {
+	dial    Dialer
+	timeout time.Duration
+	budget  time.Duration
+	recheck time.Duration
+
+	lock     sync.RWMutex
+	statuses map[string]*driver.OrdererStatus
+	// running tells which endpoints are being checked
+	running map[string]bool
+	// rechecks are the next checks of the degraded endpoints
+	rechecks map[string]*recheck
+}
+
+// recheck is the next check of a degraded endpoint
+type recheck struct {
+	failures int
+	timer    *time.Timer
+}
+
+// NewPreflight returns a Preflight that gives each endpoint the passed timeout and waits for the results
+// at most for the passed budget. A degraded endpoint is checked again after the passed interval, doubled at each
+// failure up to MaxRecheckInterval.
+func NewPreflight(dial Dialer, timeout, budget, recheckInterval time.Duration) *Preflight {
+	return &Preflight{
+		dial:     dial,
+		timeout:  timeout,
+		budget:   budget,
+		recheck:  recheckInterval,
+		statuses: map[string]*driver.OrdererStatus{},
+		running:  map[string]bool{},
+		rechecks: map[string]*recheck{},
+	}
+}
+
+// Check checks the passed orderers concurrently and waits for them at most for the budget.
+// The checks still running after that keep going and record their outcome when done.
+// An endpoint already being checked is not checked again.
+func (p *Preflight) Check(channel string, orderers []*grpc.ConnectionConfig) {
+	var wg sync.WaitGroup
+	p.lock.Lock()
+	for _, orderer := range orderers {
+		if p.running[orderer.Address] {
+			continue
+		}
+		p.running[orderer.Address] = true
+		wg.Add(1)
+		go func(orderer *grpc.ConnectionConfig) {
+			defer wg.Done()
+			p.check(channel, orderer)
+		}(orderer)
+	}
+	p.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(p.budget):
+		logger.Warnf("[channel: %s] orderers pre-flight check still running after [%s], continuing in background", channel, p.budget)
+	}
+}
+
+func (p *Preflight) check(channel string, orderer *grpc.ConnectionConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	start := time.Now()
+	err := p.dial(ctx, channel, orderer)
+	status := &driver.OrdererStatus{
+		Address:   orderer.Address,
+		Degraded:  err != nil,
+		Err:       err,
+		Latency:   time.Since(start),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		logger.Warnf("[channel: %s] orderer [%s] failed the pre-flight check in [%s], marked as degraded: [%s]", channel, orderer.Address, status.Latency, err)
+	} else {
+		logger.Infof("[channel: %s] orderer [%s] passed the pre-flight check in [%s]", channel, orderer.Address, status.Latency)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.statuses[orderer.Address] = status
+	delete(p.running, orderer.Address)
+	if err == nil {
+		p.clearRecheck(orderer.Address)
+		return
+	}
+	p.scheduleRecheck(channel, orderer)
+}
+
+// scheduleRecheck schedules the next check of the passed degraded endpoint. p.lock must be held.
+func (p *Preflight) scheduleRecheck(channel string, orderer *grpc.ConnectionConfig) {
+	if p.recheck <= 0 {
+		return
+	}
+	r, ok := p.rechecks[orderer.Address]
+	if !ok {
+		r = &recheck{}
+		p.rechecks[orderer.Address] = r
+	}
+	interval := p.recheck
+	for i := 0; i < r.failures && interval < MaxRecheckInterval; i++ {
+		interval *= 2
+	}
+	if interval > MaxRecheckInterval {
+		interval = MaxRecheckInterval
+	}
+	r.failures++
+	logger.Debugf("[channel: %s] check degraded orderer [%s] again in [%s]", channel, orderer.Address, interval)
+	r.timer = time.AfterFunc(interval, func() {
+		p.lock.Lock()
+		if p.rechecks[orderer.Address] != r || p.running[orderer.Address] {
+			// the endpoint recovered meanwhile, or it is being checked already
+			p.lock.Unlock()
+			return
+		}
+		p.running[orderer.Address] = true
+		p.lock.Unlock()
+		p.check(channel, orderer)
+	})
+}
+
+// clearRecheck cancels the next check of the passed endpoint, if any. p.lock must be held.
+func (p *Preflight) clearRecheck(address string) {
+	if r, ok := p.rechecks[address]; ok {
+		r.timer.Stop()
+		delete(p.rechecks, address)
+	}
+}
+
+// Succeeded records that a broadcast to the passed endpoint succeeded: the endpoint is not degraded anymore
+func (p *Preflight) Succeeded(address string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	status, ok := p.statuses[address]
+	if !ok || !status.Degraded {
+		return
+	}
+	logger.Infof("orderer [%s] accepted a broadcast, not degraded anymore", address)
+	p.statuses[address] = &driver.OrdererStatus{Address: address, Latency: status.Latency, CheckedAt: time.Now()}
+	p.clearRecheck(address)
+}
+
+// Degraded returns true if the last check of the passed endpoint failed
+func (p *Preflight) Degraded(address string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	status, ok := p.statuses[address]
+	return ok && status.Degraded
+}
+
+// Healthy returns the passed orderers that are not degraded, or all of them if they all are.
+// The endpoints not checked yet are considered healthy.
+func (p *Preflight) Healthy(orderers []*grpc.ConnectionConfig) []*grpc.ConnectionConfig {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	var res []*grpc.ConnectionConfig
+	for _, orderer := range orderers {
+		if status, ok := p.statuses[orderer.Address]; !ok || !status.Degraded {
+			res = append(res, orderer)
+		}
+	}
+	if len(res) == 0 {
+		return orderers
+	}
+	return res
+}
+
+// Statuses returns the outcome of the last check of the endpoints checked so far, sorted by address
+func (p *Preflight) Statuses() []driver.OrdererStatus {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	res := make([]driver.OrdererStatus, 0, len(p.statuses))
+	for _, status := range p.statuses {
+		res = append(res, *status)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Address < res[j].Address })
+	return res
+}
+
+// NewDialer returns a Dialer that connects to the orderer, which requires the TLS handshake to succeed
+// and the connection to be ready. If seek is true, it also asks the orderer for the latest block of the channel,
+// signing the request with the default identity of the passed membership.
+func NewDialer(membership driver.LocalMembership, hasher delivery.Hasher, seek bool) Dialer {
+	return func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) error {
+		if !seek {
+			client, _, err := connect(ctx, orderer)
+			if err != nil {
+				return err
+			}
+			client.Close()
+			return nil
+		}
+		_, err := seekNewest(ctx, membership, hasher, channel, orderer)
+		return err
+	}
+}
+
+// NewVersionProber returns a compat.Prober that asks the orderer for the latest block of the channel,
+// as the Dialer does, and detects the version of the orderer from it
+func NewVersionProber(membership driver.LocalMembership, hasher delivery.Hasher) compat.Prober {
+	return func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) (compat.Detection, error) {
+		block, err := seekNewest(ctx, membership, hasher, channel, orderer)
+		if err != nil {
+			return compat.Detection{}, err
+		}
+		if block == nil {
+			return compat.Detection{}, errors.Errorf("orderer %s did not deliver the latest block", orderer.Address)
+		}
+		return compat.DetectOrderer(block)
+	}
+}
+
+// connect connects to the orderer within the deadline of the context, the client must be closed by the caller
+func connect(ctx context.Context, orderer *grpc.ConnectionConfig) (*grpc.Client, *grpc2.ClientConn, error) {
+	cc := *orderer
+	if deadline, ok := ctx.Deadline(); ok {
+		cc.ConnectionTimeout = time.Until(deadline)
+	}
+	client, err := grpc.CreateGRPCClient(&cc)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "failed to create a client to orderer %s", orderer.Address)
+	}
+	conn, err := client.NewConnection(orderer.Address)
+	if err != nil {
+		client.Close()
+		return nil, nil, errors.WithMessagef(err, "failed to connect to orderer %s", orderer.Address)
+	}
+	return client, conn, nil
+}
+
+// seekNewest asks the orderer for the latest block of the channel. It returns the block, if the orderer delivered it.
+func seekNewest(ctx context.Context, membership driver.LocalMembership, hasher delivery.Hasher, channel string, orderer *grpc.ConnectionConfig) (*common.Block, error) {
+	client, conn, err := connect(ctx, orderer)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	stream, err := ab.NewAtomicBroadcastClient(conn).Deliver(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open deliver stream to orderer %s", orderer.Address)
+	}
+	cert := client.Certificate()
+	env, err := createSeekNewestEnvelope(channel, membership.DefaultSigningIdentity(), &cert, hasher)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(env); err != nil {
+		return nil, errors.Wrapf(err, "failed to send seek to orderer %s", orderer.Address)
+	}
+	if err := stream.CloseSend(); err != nil {
+		logger.Warnf("error closing deliver stream to orderer %s: %s", orderer.Address, err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to receive the latest block from orderer %s", orderer.Address)
+	}
+	switch r := resp.Type.(type) {
+	case *ab.DeliverResponse_Status:
+		if r.Status != common.Status_SUCCESS {
+			return nil, errors.Errorf("orderer %s answered the seek with status %s", orderer.Address, r.Status)
+		}
+	case *ab.DeliverResponse_Block:
+		return r.Block, nil
+	}
+	return nil, nil
+}
+
+func createSeekNewestEnvelope(channel string, signer driver.SigningIdentity, cert *tls.Certificate, hasher delivery.Hasher) (*common.Envelope, error) {
+	creator, err := signer.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	tlsCertHash, err := grpc.GetTLSCertHash(cert, hasher)
+	if err != nil {
+		return nil, err
+	}
+	_, header, err := delivery.CreateHeader(common.HeaderType_DELIVER_SEEK_INFO, channel, creator, tlsCertHash)
+	if err != nil {
+		return nil, err
+	}
+	newest := &ab.SeekPosition{Type: &ab.SeekPosition_Newest{Newest: &ab.SeekNewest{}}}
+	raw, err := proto.Marshal(&ab.SeekInfo{
+		Start:    newest,
+		Stop:     newest,
+		Behavior: ab.SeekInfo_FAIL_IF_NOT_READY,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling SeekInfo")
+	}
+	return delivery.CreateEnvelope(raw, header, signer)
+}
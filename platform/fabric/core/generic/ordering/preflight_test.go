@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflight(t *testing.T) {
+	release := make(chan struct{})
+	var dials int32
+	dial := func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) error {
+		atomic.AddInt32(&dials, 1)
+		assert.Equal(t, "mychannel", channel)
+		switch orderer.Address {
+		case "down:7050":
+			return errors.New("connection refused")
+		case "slow:7050":
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+	p := NewPreflight(dial, time.Minute, 50*time.Millisecond, 0)
+
+	up := &grpc.ConnectionConfig{Address: "up:7050"}
+	down := &grpc.ConnectionConfig{Address: "down:7050"}
+	slow := &grpc.ConnectionConfig{Address: "slow:7050"}
+	orderers := []*grpc.ConnectionConfig{up, down, slow}
+
+	// the check does not wait for the slow orderer beyond the budget
+	start := time.Now()
+	p.Check("mychannel", orderers)
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.False(t, p.Degraded("up:7050"))
+	assert.True(t, p.Degraded("down:7050"))
+	assert.False(t, p.Degraded("slow:7050"))
+	// the ones not checked yet are healthy
+	assert.Equal(t, []*grpc.ConnectionConfig{up, slow}, p.Healthy(orderers))
+	statuses := p.Statuses()
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, "down:7050", statuses[0].Address)
+	assert.True(t, statuses[0].Degraded)
+	assert.EqualError(t, statuses[0].Err, "connection refused")
+	assert.Equal(t, "up:7050", statuses[1].Address)
+	assert.NoError(t, statuses[1].Err)
+
+	// the slow orderer is still being checked, it is not checked twice
+	p.Check("mychannel", []*grpc.ConnectionConfig{slow})
+	assert.Equal(t, int32(3), atomic.LoadInt32(&dials))
+
+	close(release)
+	assert.Eventually(t, func() bool { return len(p.Statuses()) == 3 }, time.Second, 10*time.Millisecond)
+	assert.False(t, p.Degraded("slow:7050"))
+
+	// if all are degraded, they are all returned
+	assert.Equal(t, []*grpc.ConnectionConfig{down}, p.Healthy([]*grpc.ConnectionConfig{down}))
+}
+
+func TestPreflightRecheck(t *testing.T) {
+	var up int32
+	var dials int32
+	dial := func(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) error {
+		atomic.AddInt32(&dials, 1)
+		if atomic.LoadInt32(&up) == 0 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	p := NewPreflight(dial, time.Minute, time.Second, 10*time.Millisecond)
+	orderer := &grpc.ConnectionConfig{Address: "orderer:7050"}
+
+	// the degraded orderer is checked again, with a backoff, until it passes the check
+	p.Check("mychannel", []*grpc.ConnectionConfig{orderer})
+	assert.True(t, p.Degraded("orderer:7050"))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&dials) >= 3 }, time.Second, 5*time.Millisecond)
+	atomic.StoreInt32(&up, 1)
+	assert.Eventually(t, func() bool { return !p.Degraded("orderer:7050") }, time.Second, 5*time.Millisecond)
+	p.lock.RLock()
+	assert.Len(t, p.rechecks, 0)
+	p.lock.RUnlock()
+	checked := atomic.LoadInt32(&dials)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, checked, atomic.LoadInt32(&dials))
+
+	// a successful broadcast clears the flag, and the next checks
+	atomic.StoreInt32(&up, 0)
+	p.recheck = time.Hour
+	p.Check("mychannel", []*grpc.ConnectionConfig{orderer})
+	assert.True(t, p.Degraded("orderer:7050"))
+	p.Succeeded("orderer:7050")
+	assert.False(t, p.Degraded("orderer:7050"))
+	assert.NoError(t, p.Statuses()[0].Err)
+	p.lock.RLock()
+	assert.Len(t, p.rechecks, 0)
+	p.lock.RUnlock()
+}
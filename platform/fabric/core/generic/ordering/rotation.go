@@ -0,0 +1,300 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+var (
+	tlsRotationInProgressOpts = metrics.GaugeOpts{
+		Namespace:    "fabric",
+		Subsystem:    "ordering",
+		Name:         "tls_rotation_in_progress",
+		Help:         "1 while the orderers of a network trust both their previous and their new TLS roots, 0 otherwise.",
+		LabelNames:   []string{"network"},
+		StatsdFormat: "%{#fqname}.%{network}",
+	}
+	tlsRotationsOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "ordering",
+		Name:         "tls_rotations",
+		Help:         "The number of channel configurations rotating the TLS roots of the orderers of a network.",
+		LabelNames:   []string{"network"},
+		StatsdFormat: "%{#fqname}.%{network}",
+	}
+)
+
+// RotationMetrics collects the metrics of the rotations of the TLS roots of the orderers
+type RotationMetrics struct {
+	InProgress metrics.Gauge
+	Rotations  metrics.Counter
+}
+
+func NewRotationMetrics(p metrics.Provider) *RotationMetrics {
+	return &RotationMetrics{
+		InProgress: p.NewGauge(tlsRotationInProgressOpts),
+		Rotations:  p.NewCounter(tlsRotationsOpts),
+	}
+}
+
+// Reconnector is implemented by the ordering services that can replace their connection to the orderers
+type Reconnector interface {
+	// Reconnect makes the next broadcasts open a new connection, and returns a function closing the current one
+	Reconnect() (retire func())
+}
+
+// Rotator applies the orderers of the channel configurations that change their TLS roots, make-before-break:
+// the new roots are checked first, then, for a grace period, the rotated orderers trust both the previous
+// and the new roots, and the connections opened before the rotation are kept for the calls in flight.
+// The previous roots and connections are retired when the grace period is over and the rotated orderers
+// accept the new roots, otherwise the grace period starts again.
+type Rotator struct {
+	network     string
+	dial        Dialer
+	timeout     time.Duration
+	grace       time.Duration
+	apply       func(orderers []*grpc.ConnectionConfig)
+	reconnector Reconnector
+	metrics     *RotationMetrics
+
+	lock sync.Mutex
+	// target are the orderers of the last configuration, nil if no rotation is in progress
+	target  []*grpc.ConnectionConfig
+	channel string
+	// trusted are the previous roots of the rotated orderers, by address, trusted until the rotation completes
+	trusted map[string][][]byte
+	// retire close the connections opened before the rotation
+	retire     []func()
+	startedAt  time.Time
+	timer      *time.Timer
+	generation uint64
+}
+
+// NewRotator returns a Rotator for the passed network. The new roots are checked with the passed dialer,
+// giving each orderer the passed timeout. apply sets the orderers used by the network.
+func NewRotator(network string, dial Dialer, timeout, grace time.Duration, apply func(orderers []*grpc.ConnectionConfig), reconnector Reconnector, metrics *RotationMetrics) *Rotator {
+	return &Rotator{
+		network:     network,
+		dial:        dial,
+		timeout:     timeout,
+		grace:       grace,
+		apply:       apply,
+		reconnector: reconnector,
+		metrics:     metrics,
+	}
+}
+
+// Rotate applies the passed orderers of a configuration of the passed channel in place of the current ones.
+// It returns false, without applying them, if no orderer changes its TLS roots and no rotation is in progress.
+func (r *Rotator) Rotate(channel string, current, next []*grpc.ConnectionConfig) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	base := current
+	if r.target != nil {
+		base = r.target
+	}
+	rotated := RotatedOrderers(base, next)
+	if len(rotated) == 0 {
+		if r.target == nil {
+			return false
+		}
+		// a rotation is in progress, the previous roots are still trusted
+		r.target = next
+		r.apply(TransitionOrderers(next, r.trusted))
+		return true
+	}
+
+	// make: the new roots are checked before they are used
+	failed := r.check(channel, next, rotated)
+	if r.target == nil {
+		r.trusted = map[string][][]byte{}
+		r.startedAt = time.Now()
+		r.metrics.InProgress.With("network", r.network).Set(1)
+	}
+	for _, address := range rotated {
+		r.trusted[address] = mergeRoots(r.trusted[address], roots(base, address))
+	}
+	r.target = next
+	r.channel = channel
+	r.apply(TransitionOrderers(next, r.trusted))
+	r.retire = append(r.retire, r.reconnector.Reconnect())
+	r.metrics.Rotations.With("network", r.network).Add(1)
+	logger.Infow("orderers TLS rotation started",
+		"network", r.network, "channel", channel, "orderers", rotated, "failed", failed, "grace", r.grace.String())
+	r.schedule()
+	return true
+}
+
+// InProgress returns true if the previous roots of some orderers are still trusted
+func (r *Rotator) InProgress() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.target != nil
+}
+
+// schedule starts the grace period again
+func (r *Rotator) schedule() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.generation++
+	generation := r.generation
+	r.timer = time.AfterFunc(r.grace, func() { r.complete(generation) })
+}
+
+// complete retires the previous roots and connections, if the rotated orderers accept the new roots
+func (r *Rotator) complete(generation uint64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if generation != r.generation || r.target == nil {
+		return
+	}
+
+	var rotated []string
+	for _, orderer := range r.target {
+		if _, ok := r.trusted[orderer.Address]; ok {
+			rotated = append(rotated, orderer.Address)
+		}
+	}
+	// break: the previous roots are retired only once the new ones are accepted
+	if failed := r.check(r.channel, r.target, rotated); len(failed) != 0 {
+		logger.Warnw("orderers TLS rotation extended, the new roots are not accepted yet",
+			"network", r.network, "channel", r.channel, "failed", failed, "grace", r.grace.String())
+		r.schedule()
+		return
+	}
+
+	r.apply(r.target)
+	for _, retire := range r.retire {
+		retire()
+	}
+	r.metrics.InProgress.With("network", r.network).Set(0)
+	logger.Infow("orderers TLS rotation completed",
+		"network", r.network, "channel", r.channel, "orderers", rotated, "duration", time.Since(r.startedAt).String())
+	r.target = nil
+	r.trusted = nil
+	r.retire = nil
+	r.timer = nil
+}
+
+// check dials concurrently the passed addresses, among the passed orderers, and returns the ones that failed, sorted
+func (r *Rotator) check(channel string, orderers []*grpc.ConnectionConfig, addresses []string) []string {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var failed []string
+	for _, address := range addresses {
+		orderer := find(orderers, address)
+		if orderer == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(orderer *grpc.ConnectionConfig) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+			defer cancel()
+			if err := r.dial(ctx, channel, orderer); err != nil {
+				logger.Warnf("[channel: %s] orderer [%s] does not accept the new TLS roots yet: [%s]", channel, orderer.Address, err)
+				lock.Lock()
+				failed = append(failed, orderer.Address)
+				lock.Unlock()
+			}
+		}(orderer)
+	}
+	wg.Wait()
+	sort.Strings(failed)
+	return failed
+}
+
+// RotatedOrderers returns the addresses of the orderers in next whose TLS roots differ from the ones they have in previous.
+// The orderers that are not in previous are not rotated.
+func RotatedOrderers(previous, next []*grpc.ConnectionConfig) []string {
+	var res []string
+	for _, orderer := range next {
+		p := find(previous, orderer.Address)
+		if p == nil || sameRoots(p.TLSRootCertBytes, orderer.TLSRootCertBytes) {
+			continue
+		}
+		res = append(res, orderer.Address)
+	}
+	return res
+}
+
+// TransitionOrderers returns copies of the passed orderers that trust, in addition to their roots, the trusted ones of their address
+func TransitionOrderers(orderers []*grpc.ConnectionConfig, trusted map[string][][]byte) []*grpc.ConnectionConfig {
+	res := make([]*grpc.ConnectionConfig, len(orderers))
+	for i, orderer := range orderers {
+		previous, ok := trusted[orderer.Address]
+		if !ok {
+			res[i] = orderer
+			continue
+		}
+		cc := *orderer
+		cc.TLSRootCertBytes = mergeRoots(orderer.TLSRootCertBytes, previous)
+		res[i] = &cc
+	}
+	return res
+}
+
+func find(orderers []*grpc.ConnectionConfig, address string) *grpc.ConnectionConfig {
+	for _, orderer := range orderers {
+		if orderer.Address == address {
+			return orderer
+		}
+	}
+	return nil
+}
+
+func roots(orderers []*grpc.ConnectionConfig, address string) [][]byte {
+	if orderer := find(orderers, address); orderer != nil {
+		return orderer.TLSRootCertBytes
+	}
+	return nil
+}
+
+// mergeRoots returns the roots in a followed by the ones in b not in a
+func mergeRoots(a, b [][]byte) [][]byte {
+	res := make([][]byte, 0, len(a)+len(b))
+	res = append(res, a...)
+	for _, root := range b {
+		if !containsRoot(res, root) {
+			res = append(res, root)
+		}
+	}
+	return res
+}
+
+func sameRoots(a, b [][]byte) bool {
+	for _, root := range a {
+		if !containsRoot(b, root) {
+			return false
+		}
+	}
+	for _, root := range b {
+		if !containsRoot(a, root) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsRoot(roots [][]byte, root []byte) bool {
+	for _, r := range roots {
+		if bytes.Equal(r, root) {
+			return true
+		}
+	}
+	return false
+}
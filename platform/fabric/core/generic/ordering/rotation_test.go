@@ -0,0 +1,146 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReconnector struct {
+	reconnects int32
+	retired    int32
+}
+
+func (f *fakeReconnector) Reconnect() func() {
+	atomic.AddInt32(&f.reconnects, 1)
+	return func() { atomic.AddInt32(&f.retired, 1) }
+}
+
+// fakeOrderers records the orderers applied and simulates orderers presenting a certificate of one of the CAs
+type fakeOrderers struct {
+	lock    sync.Mutex
+	applied []*grpc.ConnectionConfig
+	// ca is the CA of the TLS certificates the orderers present
+	ca atomic.Value
+}
+
+func (f *fakeOrderers) apply(orderers []*grpc.ConnectionConfig) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.applied = orderers
+}
+
+func (f *fakeOrderers) current() []*grpc.ConnectionConfig {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.applied
+}
+
+func (f *fakeOrderers) roots(address string) [][]byte {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return roots(f.applied, address)
+}
+
+func (f *fakeOrderers) dial(ctx context.Context, channel string, orderer *grpc.ConnectionConfig) error {
+	if !containsRoot(orderer.TLSRootCertBytes, []byte(f.ca.Load().(string))) {
+		return errors.New("x509: certificate signed by unknown authority")
+	}
+	return nil
+}
+
+func TestRotatedOrderers(t *testing.T) {
+	previous := []*grpc.ConnectionConfig{
+		{Address: "o1:7050", TLSRootCertBytes: [][]byte{[]byte("ca1"), []byte("ca2")}},
+		{Address: "o2:7050", TLSRootCertBytes: [][]byte{[]byte("ca1")}},
+	}
+	next := []*grpc.ConnectionConfig{
+		{Address: "o1:7050", TLSRootCertBytes: [][]byte{[]byte("ca2"), []byte("ca1")}},
+		{Address: "o2:7050", TLSRootCertBytes: [][]byte{[]byte("ca3")}},
+		{Address: "o3:7050", TLSRootCertBytes: [][]byte{[]byte("ca3")}},
+	}
+	// the order of the roots does not matter, the new orderers are not rotated
+	assert.Equal(t, []string{"o2:7050"}, RotatedOrderers(previous, next))
+
+	transition := TransitionOrderers(next, map[string][][]byte{"o2:7050": {[]byte("ca1")}})
+	assert.Same(t, next[0], transition[0])
+	assert.Equal(t, [][]byte{[]byte("ca3"), []byte("ca1")}, transition[1].TLSRootCertBytes)
+	assert.Equal(t, [][]byte{[]byte("ca3")}, next[1].TLSRootCertBytes)
+}
+
+func TestRotator(t *testing.T) {
+	orderers := &fakeOrderers{}
+	// the orderers still present the certificates of the previous CA
+	orderers.ca.Store("old-ca")
+	reconnector := &fakeReconnector{}
+	gauge := &metricsfakes.Gauge{}
+	gauge.WithReturns(gauge)
+	counter := &metricsfakes.Counter{}
+	counter.WithReturns(counter)
+	grace := 50 * time.Millisecond
+	r := NewRotator("mynetwork", orderers.dial, time.Second, grace, orderers.apply, reconnector, &RotationMetrics{InProgress: gauge, Rotations: counter})
+
+	current := []*grpc.ConnectionConfig{
+		{Address: "o1:7050", TLSRootCertBytes: [][]byte{[]byte("old-ca")}},
+		{Address: "o2:7050", TLSRootCertBytes: [][]byte{[]byte("old-ca")}},
+	}
+	orderers.apply(current)
+
+	// a configuration that does not rotate the roots is left to the caller
+	added := append(current, &grpc.ConnectionConfig{Address: "o3:7050", TLSRootCertBytes: [][]byte{[]byte("old-ca")}})
+	assert.False(t, r.Rotate("mychannel", current, added))
+	assert.False(t, r.InProgress())
+
+	// the configuration update rotates the CA of the TLS certificates of the orderers
+	rotated := []*grpc.ConnectionConfig{
+		{Address: "o1:7050", TLSRootCertBytes: [][]byte{[]byte("new-ca")}},
+		{Address: "o2:7050", TLSRootCertBytes: [][]byte{[]byte("new-ca")}},
+	}
+	assert.True(t, r.Rotate("mychannel", current, rotated))
+	assert.True(t, r.InProgress())
+	// both root sets are trusted, the connection opened before is replaced but not closed yet
+	assert.Equal(t, [][]byte{[]byte("new-ca"), []byte("old-ca")}, orderers.roots("o1:7050"))
+	assert.Equal(t, [][]byte{[]byte("new-ca"), []byte("old-ca")}, orderers.roots("o2:7050"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reconnector.reconnects))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&reconnector.retired))
+	assert.Equal(t, 1, gauge.SetCallCount())
+	assert.Equal(t, float64(1), gauge.SetArgsForCall(0))
+	assert.Equal(t, []string{"network", "mynetwork"}, gauge.WithArgsForCall(0))
+	assert.Equal(t, 1, counter.AddCallCount())
+
+	// the same configuration, applied by another channel, does not start another rotation
+	assert.True(t, r.Rotate("otherchannel", orderers.current(), rotated))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reconnector.reconnects))
+	assert.Equal(t, []byte("old-ca"), orderers.roots("o1:7050")[1])
+
+	// the orderers do not accept the new roots yet, the grace period is extended
+	time.Sleep(3 * grace)
+	assert.True(t, r.InProgress())
+	assert.Len(t, orderers.roots("o1:7050"), 2)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&reconnector.retired))
+
+	// the orderers switch to the certificates of the new CA, the previous roots and connections are retired
+	orderers.ca.Store("new-ca")
+	assert.Eventually(t, func() bool { return !r.InProgress() }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, [][]byte{[]byte("new-ca")}, orderers.roots("o1:7050"))
+	assert.Equal(t, [][]byte{[]byte("new-ca")}, orderers.roots("o2:7050"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reconnector.retired))
+	assert.Equal(t, 2, gauge.SetCallCount())
+	assert.Equal(t, float64(0), gauge.SetArgsForCall(1))
+
+	// once completed, the same configuration is left to the caller again
+	assert.False(t, r.Rotate("mychannel", orderers.current(), rotated))
+}
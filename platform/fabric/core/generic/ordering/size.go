@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"sort"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	common2 "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric/protoutil"
+)
+
+// numLargestWrites is the number of writes reported by driver.ErrEnvelopeTooLarge
+const numLargestWrites = 5
+
+// checkEnvelopeSize returns a driver.ErrEnvelopeTooLarge if the passed envelope exceeds the size limit of its channel.
+// The envelopes whose channel cannot be determined are left to the orderers.
+func (o *service) checkEnvelopeSize(env *common2.Envelope) error {
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil || payload.Header == nil {
+		logger.Debugf("cannot determine the channel of the envelope, skip size check [%v]", err)
+		return nil
+	}
+	chdr, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil {
+		logger.Debugf("cannot determine the channel of the envelope, skip size check [%s]", err)
+		return nil
+	}
+	ch, err := o.network.Channel(chdr.ChannelId)
+	if err != nil {
+		logger.Debugf("channel [%s] not available, skip size check [%s]", chdr.ChannelId, err)
+		return nil
+	}
+	limiter, ok := ch.(driver.EnvelopeSizeLimiter)
+	if !ok {
+		return nil
+	}
+	limit := limiter.EnvelopeSizeLimit()
+	if limit == 0 {
+		return nil
+	}
+	size := proto.Size(env)
+	if size <= int(limit) {
+		return nil
+	}
+	return &driver.ErrEnvelopeTooLarge{
+		TxID:          chdr.TxId,
+		Channel:       chdr.ChannelId,
+		Size:          size,
+		Limit:         limit,
+		LargestWrites: largestWrites(env, numLargestWrites),
+	}
+}
+
+// largestWrites returns the n public writes of the passed envelope with the largest key and value, the largest first
+func largestWrites(env *common2.Envelope, n int) []driver.WriteSize {
+	chaincodeAction, err := protoutil.GetActionFromEnvelopeMsg(env)
+	if err != nil || chaincodeAction == nil || len(chaincodeAction.Results) == 0 {
+		return nil
+	}
+	txRWSet := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(chaincodeAction.Results, txRWSet); err != nil {
+		return nil
+	}
+	var writes []driver.WriteSize
+	for _, nsRWSet := range txRWSet.NsRwset {
+		kvRWSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(nsRWSet.Rwset, kvRWSet); err != nil {
+			continue
+		}
+		for _, w := range kvRWSet.Writes {
+			writes = append(writes, driver.WriteSize{
+				Namespace: nsRWSet.Namespace,
+				Key:       w.Key,
+				Size:      len(w.Key) + len(w.Value),
+			})
+		}
+	}
+	sort.SliceStable(writes, func(i, j int) bool {
+		return writes[i].Size > writes[j].Size
+	})
+	if len(writes) > n {
+		writes = writes[:n]
+	}
+	return writes
+}
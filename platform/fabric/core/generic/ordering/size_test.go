@@ -0,0 +1,107 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/fabrictest"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeChannel struct {
+	driver.Channel
+	limit uint32
+}
+
+func (c *fakeChannel) EnvelopeSizeLimit() uint32 { return c.limit }
+
+type fakeNetwork struct {
+	Network
+	channels map[string]driver.Channel
+	orderers *fabrictest.RoundRobin
+	config   *config.Config
+}
+
+func (n *fakeNetwork) Channel(name string) (driver.Channel, error) {
+	ch, ok := n.channels[name]
+	if !ok {
+		return nil, errors.Errorf("channel [%s] not found", name)
+	}
+	return ch, nil
+}
+
+func newEnvelope(t *testing.T, channel string, txid string) *common.Envelope {
+	rwsb := rwsetutil.NewRWSetBuilder()
+	rwsb.AddToWriteSet("asset", "small", []byte("v"))
+	rwsb.AddToWriteSet("asset", "large", bytes.Repeat([]byte("v"), 4096))
+	rwsb.AddToWriteSet("asset", "medium", bytes.Repeat([]byte("v"), 1024))
+	rwsb.AddToWriteSet("token", "medium", bytes.Repeat([]byte("v"), 2048))
+	results, err := rwsb.GetTxSimulationResults()
+	assert.NoError(t, err)
+	pubResults, err := results.GetPubSimulationBytes()
+	assert.NoError(t, err)
+
+	prp := protoutil.MarshalOrPanic(&pb.ProposalResponsePayload{
+		Extension: protoutil.MarshalOrPanic(&pb.ChaincodeAction{Results: pubResults}),
+	})
+	actionPayload := protoutil.MarshalOrPanic(&pb.ChaincodeActionPayload{
+		Action: &pb.ChaincodeEndorsedAction{ProposalResponsePayload: prp},
+	})
+	payload := &common.Payload{
+		Header: &common.Header{
+			ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{
+				Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+				ChannelId: channel,
+				TxId:      txid,
+			}),
+		},
+		Data: protoutil.MarshalOrPanic(&pb.Transaction{Actions: []*pb.TransactionAction{{Payload: actionPayload}}}),
+	}
+	return &common.Envelope{Payload: protoutil.MarshalOrPanic(payload)}
+}
+
+func TestCheckEnvelopeSize(t *testing.T) {
+	ch := &fakeChannel{limit: 1024 * 1024}
+	o := NewService(nil, &fakeNetwork{channels: map[string]driver.Channel{"mychannel": ch}})
+	env := newEnvelope(t, "mychannel", "tx1")
+	size := len(protoutil.MarshalOrPanic(env))
+
+	assert.NoError(t, o.checkEnvelopeSize(env))
+
+	// the limit of the channel shrinks after a config update
+	ch.limit = 4096
+	err := o.checkEnvelopeSize(env)
+	tooLarge := &driver.ErrEnvelopeTooLarge{}
+	assert.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, "tx1", tooLarge.TxID)
+	assert.Equal(t, "mychannel", tooLarge.Channel)
+	assert.Equal(t, size, tooLarge.Size)
+	assert.Equal(t, uint32(4096), tooLarge.Limit)
+	assert.Equal(t, []driver.WriteSize{
+		{Namespace: "asset", Key: "large", Size: 4101},
+		{Namespace: "token", Key: "medium", Size: 2054},
+		{Namespace: "asset", Key: "medium", Size: 1030},
+		{Namespace: "asset", Key: "small", Size: 6},
+	}, tooLarge.LargestWrites)
+	assert.Contains(t, err.Error(), "largest writes [asset:large (4101 bytes), token:medium (2054 bytes)")
+
+	// no limit
+	ch.limit = 0
+	assert.NoError(t, o.checkEnvelopeSize(env))
+
+	// the envelopes of unknown channels are left to the orderers
+	assert.NoError(t, o.checkEnvelopeSize(newEnvelope(t, "otherchannel", "tx2")))
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/pkg/errors"
+)
+
+// Vault is the part of the vault of a channel the check of the read sets uses
+type Vault interface {
+	NewQueryExecutor() (driver.QueryExecutor, error)
+}
+
+// CheckReadSet compares the versions of the reads of the passed transaction, as endorsed by the first endorser,
+// with the versions committed in the passed vault. If a transaction committed after the endorsement wrote one
+// of the keys read, it returns a *driver.ErrStaleReadSet listing them.
+// This is a best-effort check, not a guarantee: the vault may lag behind the ledger of the peers, and it does not know
+// the keys of the namespaces it does not track, so a transaction passing it can still fail for an MVCC conflict.
+// The keys not in the vault and the range queries are not checked.
+func CheckReadSet(tx Transaction, vault Vault) error {
+	responses := tx.ProposalResponses()
+	if len(responses) == 0 {
+		return nil
+	}
+	txRWSet := &rwsetutil.TxRwSet{}
+	if err := txRWSet.FromProtoBytes(responses[0].Results()); err != nil {
+		return errors.Wrapf(err, "failed unmarshalling read-write set of [%s]", tx.ID())
+	}
+	qe, err := vault.NewQueryExecutor()
+	if err != nil {
+		return errors.Wrapf(err, "failed getting query executor to check the read set of [%s]", tx.ID())
+	}
+	defer qe.Done()
+
+	var stale []driver.StaleRead
+	for _, ns := range txRWSet.NsRwSets {
+		for _, read := range ns.KvRwSet.Reads {
+			_, block, txNum, err := qe.GetStateMetadata(ns.NameSpace, read.Key)
+			if err != nil {
+				return errors.Wrapf(err, "failed reading version of [%s:%s] to check the read set of [%s]", ns.NameSpace, read.Key, tx.ID())
+			}
+			if block == 0 && txNum == 0 {
+				// not in the vault, either never written, deleted, or not tracked
+				continue
+			}
+			r := driver.StaleRead{Namespace: ns.NameSpace, Key: read.Key, CommittedBlock: block, CommittedTxNum: txNum}
+			if read.Version != nil {
+				r.Block, r.TxNum = read.Version.BlockNum, read.Version.TxNum
+			}
+			// a vault behind the endorsers has older versions, they are not stale
+			if block > r.Block || (block == r.Block && txNum > r.TxNum) {
+				stale = append(stale, r)
+			}
+		}
+	}
+	if len(stale) != 0 {
+		return &driver.ErrStaleReadSet{TxID: tx.ID(), Channel: tx.Channel(), Reads: stale}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	driver2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+type version struct {
+	block, txNum uint64
+}
+
+// fakeVault returns the versions of the keys of the asset namespace
+type fakeVault map[string]version
+
+func (v fakeVault) NewQueryExecutor() (driver.QueryExecutor, error) {
+	return &fakeQueryExecutor{versions: v}, nil
+}
+
+type fakeQueryExecutor struct {
+	versions fakeVault
+}
+
+func (q *fakeQueryExecutor) GetState(namespace string, key string) ([]byte, error) {
+	return nil, nil
+}
+
+func (q *fakeQueryExecutor) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	v := q.versions[key]
+	return nil, v.block, v.txNum, nil
+}
+
+func (q *fakeQueryExecutor) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver2.VersionedResultsIterator, error) {
+	return nil, nil
+}
+
+func (q *fakeQueryExecutor) Done() {}
+
+func TestCheckReadSet(t *testing.T) {
+	tx := newTransaction(t, "tx1", "transfer", "asset1", 5)
+
+	// the vault has the version read, or an older one, or not the key
+	assert.NoError(t, CheckReadSet(tx, fakeVault{"asset1": {block: 5}}))
+	assert.NoError(t, CheckReadSet(tx, fakeVault{"asset1": {block: 4, txNum: 2}}))
+	assert.NoError(t, CheckReadSet(tx, fakeVault{}))
+
+	// a transaction committed after the endorsement wrote the key
+	err := CheckReadSet(tx, fakeVault{"asset1": {block: 7, txNum: 1}})
+	assert.EqualError(t, err, "read set of transaction [tx1] on channel [mychannel] is stale, keys changed since endorsement [asset:asset1 (block 7)]")
+	stale, ok := err.(*driver.ErrStaleReadSet)
+	assert.True(t, ok)
+	assert.Equal(t, []driver.StaleRead{{Namespace: "asset", Key: "asset1", Block: 5, CommittedBlock: 7, CommittedTxNum: 1}}, stale.Reads)
+
+	// the transactions not endorsed yet are not checked
+	assert.NoError(t, CheckReadSet(&fakeTransaction{id: "tx2"}, fakeVault{"asset1": {block: 7}}))
+}
@@ -0,0 +1,15 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// NewQueryExecutorWithPending returns a query executor overlaying the writes of the passed pending transactions
+// on the vault of this channel, see vault.Vault#NewQueryExecutorWithPending
+func (c *channel) NewQueryExecutorWithPending(txIDs ...string) (driver.QueryExecutor, error) {
+	return c.vault.NewQueryExecutorWithPending(txIDs...)
+}
@@ -0,0 +1,20 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// RegisterProjection registers a projection maintaining an index namespace of the vault of this channel,
+// see vault.Vault#RegisterProjection
+func (c *channel) RegisterProjection(namespace string, projection driver.Projection) error {
+	return c.vault.RegisterProjection(namespace, projection)
+}
+
+// RebuildProjection rebuilds an index namespace of the vault of this channel, see vault.Vault#RebuildProjection
+func (c *channel) RebuildProjection(index string) error {
+	return c.vault.RebuildProjection(index)
+}
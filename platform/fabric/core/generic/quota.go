@@ -0,0 +1,19 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// NamespaceUsage returns the storage taken by the namespaces of the vault of this channel, see vault.Vault#NamespaceUsage
+func (c *channel) NamespaceUsage() ([]driver.NamespaceUsage, error) {
+	return c.vault.NamespaceUsage()
+}
+
+// SetNamespaceQuota sets the quota of a namespace of the vault of this channel, see vault.Vault#SetNamespaceQuota
+func (c *channel) SetNamespaceQuota(namespace string, quota driver.NamespaceQuota) error {
+	return c.vault.SetNamespaceQuota(namespace, quota)
+}
@@ -0,0 +1,34 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+// readOnlySignerService is the signer service of a read-only network, it refuses to sign transactions.
+// The signing identities are still returned, the chaincode queries sign their proposals with them;
+// the chaincode invocations that would be ordered are refused before.
+type readOnlySignerService struct {
+	driver.SignerService
+	network string
+}
+
+func (s *readOnlySignerService) GetSigner(id view.Identity) (driver.Signer, error) {
+	return nil, &driver.ErrReadOnly{Network: s.network, Operation: "sign transaction"}
+}
+
+// setReadOnly makes this network read-only, if so configured
+func (f *network) setReadOnly() {
+	f.readOnly = f.config.ReadOnly()
+	if !f.readOnly {
+		return
+	}
+	logger.Infof("fabric network [%s] is read-only, transactions are neither signed nor broadcast", f.name)
+	f.sigService = &readOnlySignerService{SignerService: f.sigService, network: f.name}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/chaincode"
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSignerService struct {
+	driver.SignerService
+}
+
+func (s *fakeSignerService) GetSigner(id view.Identity) (driver.Signer, error) {
+	return nil, nil
+}
+
+func (s *fakeSignerService) GetSigningIdentity(id view.Identity) (driver.SigningIdentity, error) {
+	return nil, nil
+}
+
+// writeView signs and broadcasts a transaction, as the views submitting transactions do
+type writeView struct {
+	fns driver.FabricNetworkService
+}
+
+func (w *writeView) Call(context view.Context) (interface{}, error) {
+	if _, err := w.fns.SignerService().GetSigner(view.Identity("alice")); err != nil {
+		return nil, err
+	}
+	return nil, w.fns.Broadcast(&struct{}{})
+}
+
+func newTestNetwork(t *testing.T, readOnly bool) *network {
+	cp := &mock.ConfigProvider{}
+	cp.IsSetReturns(true)
+	cp.GetBoolStub = func(key string) bool {
+		return readOnly && key == "fabric.default.readOnly"
+	}
+	c, err := config2.New(cp, "default", false)
+	assert.NoError(t, err)
+	n := &network{name: "default", config: c, sigService: &fakeSignerService{}}
+	n.setReadOnly()
+	return n
+}
+
+func TestReadOnly(t *testing.T) {
+	n := newTestNetwork(t, true)
+	assert.True(t, n.ReadOnly())
+
+	// the view fails at the signature, before reaching the orderers
+	_, err := (&writeView{fns: n}).Call(nil)
+	readOnly := &driver.ErrReadOnly{}
+	assert.True(t, errors.As(err, &readOnly))
+	assert.Equal(t, "default", readOnly.Network)
+	assert.Equal(t, "sign transaction", readOnly.Operation)
+	assert.EqualError(t, err, "fabric network [default] is read-only, [sign transaction] refused")
+
+	err = n.Broadcast(&struct{}{})
+	assert.True(t, errors.As(err, &readOnly))
+	assert.Equal(t, "broadcast", readOnly.Operation)
+
+	// the chaincode invocations to be ordered are refused before contacting the peers, the queries sign their proposals
+	_, err = (&chaincode.Invoke{Network: n, NumRetries: 1}).Endorse()
+	assert.True(t, errors.As(err, &readOnly))
+	assert.Equal(t, "endorse", readOnly.Operation)
+	_, _, err = (&chaincode.Invoke{Network: n, NumRetries: 1}).Submit()
+	assert.True(t, errors.As(err, &readOnly))
+	assert.Equal(t, "submit", readOnly.Operation)
+	_, err = n.SignerService().GetSigningIdentity(view.Identity("alice"))
+	assert.NoError(t, err)
+}
+
+func TestNotReadOnly(t *testing.T) {
+	n := newTestNetwork(t, false)
+	assert.False(t, n.ReadOnly())
+	_, err := n.SignerService().GetSigner(view.Identity("alice"))
+	assert.NoError(t, err)
+}
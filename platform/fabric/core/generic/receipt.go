@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+// IssueReceipt returns the receipt of the passed transaction, signed by the default signing identity of the node.
+// The receipt states the status of the transaction in the vault, the transactions neither valid nor invalid are refused
+// with driver.ErrNotFinal. The height of the transactions committed before the vault recorded it is taken from the
+// ledger of the peers.
+func (c *channel) IssueReceipt(txID string) (*driver.Receipt, error) {
+	code, block, txNum, err := c.StatusWithHeight(txID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting the status of [%s]", txID)
+	}
+	if code != driver.Valid && code != driver.Invalid {
+		return nil, errors.Wrapf(driver.ErrNotFinal, "transaction [%s] has status [%d]", txID, code)
+	}
+	if block == driver.UnknownBlock {
+		b, err := c.blockByTxID(txID)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed getting the block of [%s]", txID)
+		}
+		num, _, err := findTransaction(b, txID)
+		if err != nil {
+			return nil, err
+		}
+		block, txNum = b.Header.Number, int(num)
+	}
+
+	signer := c.network.LocalMembership().DefaultSigningIdentity()
+	issuer, err := signer.Serialize()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed serializing the identity of the node")
+	}
+	receipt := &driver.Receipt{
+		Version:  driver.ReceiptVersion,
+		Network:  c.network.Name(),
+		Channel:  c.name,
+		TxID:     txID,
+		Code:     code,
+		Block:    block,
+		TxNum:    txNum,
+		IssuedAt: time.Now().UTC(),
+		Issuer:   issuer,
+	}
+	statement, err := receipt.Statement()
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Signature, err = signer.Sign(statement); err != nil {
+		return nil, errors.WithMessagef(err, "failed signing the receipt of [%s]", txID)
+	}
+	logger.Debugf("[%s] receipt of [%s] issued, status [%d] in block [%d:%d]", c.name, txID, code, block, txNum)
+	return receipt, nil
+}
@@ -0,0 +1,264 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+)
+
+const removedChannelPrefix = "channel-removed"
+
+// channelDataPrefixes are the prefixes of the keys of the KVS holding the data of the transactions of a channel,
+// followed by the channel and the network
+var channelDataPrefixes = []string{"envelope", "etx", "metadata", "provenance"}
+
+// removedChannel records the removal of a channel in the KVS, see RemoveChannel
+type removedChannel struct {
+	Removed time.Time `json:"removed"`
+	// Archive is the file the vault has been archived to, empty if it has been deleted only
+	Archive string `json:"archive,omitempty"`
+	// deliveryCtx is the context the delivery had been started with, RejoinChannel starts it again with it.
+	// It does not outlive the node.
+	deliveryCtx context.Context
+}
+
+func removedChannelKey(network, channel string) string {
+	return kvs.CreateCompositeKeyOrPanic(removedChannelPrefix, []string{network, channel})
+}
+
+// loadRemovedChannels returns the channels of this network removed and not joined again
+func (f *network) loadRemovedChannels() (map[string]*removedChannel, error) {
+	it, err := kvs.GetService(f.sp).GetByPartialCompositeID(removedChannelPrefix, []string{f.name})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed listing the removed channels")
+	}
+	defer it.Close()
+	removed := map[string]*removedChannel{}
+	for it.HasNext() {
+		record := &removedChannel{}
+		key, err := it.Next(record)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed loading removed channel [%s]", key)
+		}
+		_, attrs, err := kvs.SplitCompositeKey(key)
+		if err != nil || len(attrs) != 2 {
+			return nil, errors.Errorf("invalid key of removed channel [%s]", key)
+		}
+		removed[attrs[1]] = record
+		logger.Infof("channel [%s:%s] has been removed at [%s], it is not served until joined again", f.name, attrs[1], record.Removed)
+	}
+	return removed, nil
+}
+
+// RemoveChannel stops the delivery of the passed channel and waits for the commits in flight, archives its vault
+// to opts.Path if set, ends its subscriptions with driver.ErrChannelRemoved, and deletes its vault, configuration
+// transactions included, and the data of its transactions kept in the KVS. The channel is no longer served,
+// until joined again with RejoinChannel, across the restarts of the node too.
+// If the archive fails, nothing is deleted and the channel is served again.
+func (f *network) RemoveChannel(name string, opts driver.ArchiveOptions) error {
+	if len(name) == 0 {
+		return errors.New("no channel to remove passed")
+	}
+	if len(opts.Path) != 0 {
+		if _, err := os.Stat(opts.Path); err == nil {
+			return errors.Errorf("cannot archive channel [%s] to [%s], the file exists already", name, opts.Path)
+		}
+	}
+	// the channel is opened, if not open yet, to reach its vault
+	if _, err := f.Channel(name); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	ch, ok := f.channels[name]
+	if !ok {
+		f.mutex.Unlock()
+		return errors.Errorf("channel [%s] of network [%s] not open", name, f.name)
+	}
+	record := &removedChannel{Removed: time.Now(), Archive: opts.Path}
+	if err := kvs.GetService(f.sp).Put(removedChannelKey(f.name, name), record); err != nil {
+		f.mutex.Unlock()
+		return errors.WithMessagef(err, "failed recording the removal of channel [%s]", name)
+	}
+	delete(f.channels, name)
+	if f.removed == nil {
+		f.removed = map[string]*removedChannel{}
+	}
+	f.removed[name] = record
+	f.mutex.Unlock()
+	ch.lock.RLock()
+	unloaded := ch.unloaded
+	ch.lock.RUnlock()
+	if !unloaded {
+		f.channelMetrics.Open.With("network", f.name).Add(-1)
+	}
+	f.channelMetrics.BundleBytes.With("network", f.name, "channel", name).Set(0)
+
+	deliveryCtx := ch.quiesce()
+	if len(opts.Path) != 0 {
+		if err := ch.archive(opts.Path); err != nil {
+			ch.sinks.Close()
+			if err1 := ch.vault.Close(); err1 != nil {
+				logger.Warnf("failed closing the vault of channel [%s]: %s", name, err1)
+			}
+			f.cancelRemoval(name, deliveryCtx)
+			return errors.WithMessagef(err, "failed archiving channel [%s], not removed", name)
+		}
+	}
+	ch.release(errors.Wrapf(driver.ErrChannelRemoved, "channel [%s] removed", name))
+	f.subscriptionsLock.Lock()
+	delete(f.subscriptions, name)
+	f.subscriptionsLock.Unlock()
+
+	f.mutex.Lock()
+	record.deliveryCtx = deliveryCtx
+	f.mutex.Unlock()
+	if err := ch.purge(); err != nil {
+		// the removal stays, RejoinChannel deletes what is left
+		return errors.WithMessagef(err, "failed deleting the data of channel [%s]", name)
+	}
+	logger.Infow("channel removed", "network", f.name, "channel", name, "archive", opts.Path)
+	return nil
+}
+
+// cancelRemoval serves again the passed channel whose removal failed, its delivery restarted with the passed context
+func (f *network) cancelRemoval(name string, deliveryCtx context.Context) {
+	if err := kvs.GetService(f.sp).Delete(removedChannelKey(f.name, name)); err != nil {
+		logger.Errorf("failed cancelling the removal of channel [%s]: %s", name, err)
+		return
+	}
+	f.mutex.Lock()
+	delete(f.removed, name)
+	f.mutex.Unlock()
+	if deliveryCtx == nil {
+		return
+	}
+	ch, err := f.Channel(name)
+	if err != nil {
+		logger.Errorf("failed reopening channel [%s]: %s", name, err)
+		return
+	}
+	ch.(*channel).startDelivery(deliveryCtx)
+}
+
+// RejoinChannel joins again the passed channel removed: its vault is empty, or restored from opts.Archive,
+// and the delivery resyncs it. It returns once the vault has caught up with the checkpoint, if any.
+func (f *network) RejoinChannel(name string, opts driver.RejoinOptions) (driver.Channel, error) {
+	f.mutex.Lock()
+	record, removed := f.removed[name]
+	if !removed {
+		f.mutex.Unlock()
+		return nil, errors.Errorf("channel [%s] of network [%s] has not been removed", name, f.name)
+	}
+	if max := f.config.MaxChannels(); max > 0 && len(f.channels) >= max {
+		f.mutex.Unlock()
+		return nil, &driver.ErrTooManyChannels{Network: f.name, Channel: name, Max: max}
+	}
+	ch, err := f.openChannel(name, f.channelQuiet(name), &opts)
+	if err != nil {
+		f.mutex.Unlock()
+		return nil, errors.WithMessagef(err, "failed rejoining channel [%s]", name)
+	}
+	if err := kvs.GetService(f.sp).Delete(removedChannelKey(f.name, name)); err != nil {
+		f.mutex.Unlock()
+		if err1 := ch.Close(); err1 != nil {
+			logger.Warnf("failed closing channel [%s]: %s", name, err1)
+		}
+		return nil, errors.WithMessagef(err, "failed recording that channel [%s] joined again", name)
+	}
+	delete(f.removed, name)
+	f.channels[name] = ch
+	f.mutex.Unlock()
+	f.channelMetrics.Open.With("network", f.name).Add(1)
+	logger.Infow("channel joined again", "network", f.name, "channel", name, "archive", opts.Archive)
+	f.checkOrderers(name)
+
+	ctx := record.deliveryCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ch.StartDelivery(ctx); err != nil {
+		return nil, errors.WithMessagef(err, "failed resyncing channel [%s]", name)
+	}
+	return ch, nil
+}
+
+// archive archives the vault to the passed file, which must not exist. The file appears once complete.
+func (c *channel) archive(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrapf(err, "failed creating the directory of [%s]", path)
+	}
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrapf(err, "failed creating [%s]", tmp)
+	}
+	err = c.vault.Archive(file, c.network.Name(), c.name)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		if _, err1 := os.Stat(path); err1 == nil {
+			err = errors.Errorf("[%s] exists already", path)
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		if err1 := os.Remove(tmp); err1 != nil && !os.IsNotExist(err1) {
+			logger.Warnf("failed removing [%s]: %s", tmp, err1)
+		}
+		return errors.WithMessagef(err, "failed archiving the vault of channel [%s] to [%s]", c.name, path)
+	}
+	return nil
+}
+
+// purge deletes the vault, the data of the transactions kept in the KVS and the checkpoint, then closes the vault
+func (c *channel) purge() error {
+	if err := c.vault.Purge(); err != nil {
+		return err
+	}
+	kvss := kvs.GetService(c.sp)
+	var keys []string
+	for _, prefix := range channelDataPrefixes {
+		it, err := kvss.GetByPartialCompositeID(prefix, []string{c.name, c.network.Name()})
+		if err != nil {
+			return errors.WithMessagef(err, "failed listing the [%s] entries", prefix)
+		}
+		for it.HasNext() {
+			var raw json.RawMessage
+			// the key is returned even if the value cannot be read
+			key, _ := it.Next(&raw)
+			keys = append(keys, key)
+		}
+		if err := it.Close(); err != nil {
+			return errors.WithMessagef(err, "failed listing the [%s] entries", prefix)
+		}
+	}
+	if kvss.Exists(c.checkpointKey()) {
+		keys = append(keys, c.checkpointKey())
+	}
+	for _, key := range keys {
+		if err := kvss.Delete(key); err != nil {
+			return errors.WithMessagef(err, "failed deleting [%s]", key)
+		}
+	}
+	logger.Debugf("deleted [%d] entries of channel [%s] from the KVS", len(keys), c.name)
+	return c.vault.Close()
+}
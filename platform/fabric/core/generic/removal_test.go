@@ -0,0 +1,209 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events/simple"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	mock2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRemovableNetwork returns a network whose channels have a memory vault and a fake delivery
+func newRemovableNetwork(t *testing.T) (*network, *[]*fakeDelivery) {
+	registry := registry2.New()
+	kvss, err := kvs.NewWithConfig(registry, "memory", "", &mock2.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+
+	cp := &mock.ConfigProvider{}
+	cp.IsSetReturns(true)
+	cp.GetStringStub = func(key string) string {
+		if key == "fabric.default.vault.persistence.type" {
+			return "memory"
+		}
+		return ""
+	}
+	c, err := config2.New(cp, "default", false)
+	assert.NoError(t, err)
+	m, _ := newFakeChannelMetrics()
+	n := &network{
+		sp:             registry,
+		name:           "default",
+		config:         c,
+		channelMetrics: m,
+		channels:       map[string]*channel{},
+		subscriptions:  map[string]*committer.Subscriptions{},
+		channelDefs:    []*config2.Channel{{Name: "mychannel"}},
+		bus:            events.NewBus(),
+	}
+	n.removed, err = n.loadRemovedChannels()
+	assert.NoError(t, err)
+
+	var deliveries []*fakeDelivery
+	n.channelFactory = func(name string, quiet bool, rejoin *driver.RejoinOptions) (*channel, error) {
+		v, txIDStore, err := openVault(registry, c, n.name, name, rejoin)
+		if err != nil {
+			return nil, err
+		}
+		committerInst, err := committer.New(name, n, nil, 0, quiet, nil, nil, n.bus, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		delivery := &fakeDelivery{}
+		deliveries = append(deliveries, delivery)
+		c := &channel{
+			sp:                 registry,
+			name:               name,
+			config:             c,
+			network:            n,
+			vault:              v,
+			TXIDStore:          txIDStore,
+			committer:          committerInst,
+			deliveryService:    delivery,
+			envelopeService:    transaction.NewEnvelopeService(registry, n.name, name),
+			transactionService: transaction.NewEndorseTransactionService(registry, n.name, name),
+			subscribers:        events.NewSubscribers(),
+			eventsPublisher:    simple.NewEventBus(),
+		}
+		c.chaincodeSubscriptions, err = n.chaincodeSubscriptions(name)
+		return c, err
+	}
+	return n, &deliveries
+}
+
+// openWithState opens the channel, starts its delivery and commits a transaction writing the passed key
+func openWithState(t *testing.T, n *network, key string) *channel {
+	c, err := n.Channel("mychannel")
+	assert.NoError(t, err)
+	ch := c.(*channel)
+	assert.NoError(t, ch.StartDelivery(context.Background()))
+
+	rws, err := ch.vault.NewRWSet("tx1")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("assets", key, []byte("1")))
+	assert.NoError(t, rws.SetState(peerNamespace, "config", []byte("config")))
+	rws.Done()
+	assert.NoError(t, ch.vault.CommitTX("tx1", 1, 0))
+	assert.NoError(t, ch.vault.SetHeight(1))
+	assert.NoError(t, ch.EnvelopeService().StoreEnvelope("tx1", []byte("envelope")))
+	assert.NoError(t, kvs.GetService(n.sp).Put(ch.checkpointKey(), uint64(1)))
+	return ch
+}
+
+func stateOf(t *testing.T, ch *channel, namespace, key string) []byte {
+	qe, err := ch.vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	defer qe.Done()
+	v, err := qe.GetState(namespace, key)
+	assert.NoError(t, err)
+	return v
+}
+
+func TestRemoveAndRejoinFromScratch(t *testing.T) {
+	n, deliveries := newRemovableNetwork(t)
+	ch := openWithState(t, n, "a")
+	sub, err := ch.SubscribeChaincodeEvents("mycc", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mychannel"}, n.Channels())
+
+	assert.NoError(t, n.RemoveChannel("mychannel", driver.ArchiveOptions{}))
+
+	// the delivery is stopped, the subscriptions ended, the state deleted, and the channel is no longer served
+	assert.Equal(t, int32(0), atomic.LoadInt32(&(*deliveries)[0].running))
+	select {
+	case _, ok := <-sub.Events():
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		assert.Fail(t, "the subscription did not end")
+	}
+	assert.True(t, errors.Is(sub.Err(), driver.ErrChannelRemoved))
+	assert.Nil(t, stateOf(t, ch, "assets", "a"))
+	assert.Nil(t, stateOf(t, ch, peerNamespace, "config"))
+	assert.False(t, ch.EnvelopeService().Exists("tx1"))
+	assert.False(t, kvs.GetService(n.sp).Exists(ch.checkpointKey()))
+	_, err = n.Channel("mychannel")
+	assert.True(t, errors.Is(err, driver.ErrChannelRemoved))
+	assert.Empty(t, n.Channels())
+	assert.Error(t, n.RemoveChannel("mychannel", driver.ArchiveOptions{}))
+
+	// the removal outlives the restarts
+	removed, err := n.loadRemovedChannels()
+	assert.NoError(t, err)
+	assert.Contains(t, removed, "mychannel")
+
+	// joined again, the channel starts empty and its delivery runs again
+	c, err := n.RejoinChannel("mychannel", driver.RejoinOptions{})
+	assert.NoError(t, err)
+	rejoined := c.(*channel)
+	assert.NotSame(t, ch, rejoined)
+	assert.Nil(t, stateOf(t, rejoined, "assets", "a"))
+	code, err := rejoined.vault.Status("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Unknown, code)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&(*deliveries)[1].running) == 1 }, time.Second, 10*time.Millisecond)
+	c, err = n.Channel("mychannel")
+	assert.NoError(t, err)
+	assert.Same(t, rejoined, c)
+	assert.Equal(t, []string{"mychannel"}, n.Channels())
+	_, err = n.RejoinChannel("mychannel", driver.RejoinOptions{})
+	assert.EqualError(t, err, "channel [mychannel] of network [default] has not been removed")
+	removed, err = n.loadRemovedChannels()
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.NoError(t, rejoined.Close())
+}
+
+func TestRemoveAndRestoreFromArchive(t *testing.T) {
+	n, _ := newRemovableNetwork(t)
+	ch := openWithState(t, n, "a")
+	archive := filepath.Join(t.TempDir(), "archives", "mychannel.vault")
+
+	assert.NoError(t, n.RemoveChannel("mychannel", driver.ArchiveOptions{Path: archive}))
+	assert.Nil(t, stateOf(t, ch, "assets", "a"))
+	_, err := n.Channel("mychannel")
+	assert.True(t, errors.Is(err, driver.ErrChannelRemoved))
+
+	// the vault, its configuration transactions included, is restored from the archive
+	_, err = n.RejoinChannel("mychannel", driver.RejoinOptions{Archive: archive + ".missing"})
+	assert.Error(t, err)
+	c, err := n.RejoinChannel("mychannel", driver.RejoinOptions{Archive: archive})
+	assert.NoError(t, err)
+	restored := c.(*channel)
+	assert.Equal(t, []byte("1"), stateOf(t, restored, "assets", "a"))
+	assert.Equal(t, []byte("config"), stateOf(t, restored, peerNamespace, "config"))
+	code, err := restored.vault.Status("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Valid, code)
+	height, err := restored.vault.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), height)
+
+	// the archives are not overwritten
+	err = n.RemoveChannel("mychannel", driver.ArchiveOptions{Path: archive})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the file exists already")
+	c, err = n.Channel("mychannel")
+	assert.NoError(t, err)
+	assert.Same(t, restored, c)
+	assert.NoError(t, restored.Close())
+}
@@ -0,0 +1,19 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// RepairStates repairs the vault of this channel with the authoritative states read from a peer, see vault.Vault#RepairStates
+func (c *channel) RepairStates(repairs []driver.StateRepair, provenance driver.RepairProvenance) ([]driver.StateRepair, error) {
+	return c.vault.RepairStates(repairs, provenance)
+}
+
+// RepairRecord returns the provenance of the last repair of the passed key in the vault of this channel
+func (c *channel) RepairRecord(namespace, key string) (*driver.RepairRecord, error) {
+	return c.vault.RepairRecord(namespace, key)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/ordering"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// checkReplay fails with a *driver.ErrReplayedTxID if the identifier of the passed transaction, or envelope, has been
+// used already on its channel: the peers would invalidate the replay as a duplicate. It is the case of the terminal states,
+// valid and invalid, the transaction is on the ledger, and abandoned, the transaction has been given up and its waiters
+// released, only ResolveStuckTransaction brings it back. It is also the case of the busy, and unknown, transactions seen by the ordering service already: their envelope has
+// been delivered, or an orderer acknowledged their broadcast. The other busy transactions, whose read-write set is open,
+// have not been broadcast yet, they pass.
+// The blobs whose identifier cannot be read pass too, the ordering service rejects them.
+func (f *network) checkReplay(blob interface{}) error {
+	txID, channelName := broadcastTxID(blob)
+	if len(txID) == 0 || len(channelName) == 0 {
+		return nil
+	}
+	ch, err := f.Channel(channelName)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting channel [%s] to check the status of [%s]", channelName, txID)
+	}
+	vc, _, err := ch.Status(txID)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting the status of [%s] before broadcast", txID)
+	}
+	switch vc {
+	case driver.Valid, driver.Invalid, driver.Abandoned:
+		logger.Warnf("transaction [%s] on channel [%s] is final already [%d], refuse to broadcast it again", txID, channelName, vc)
+		return &driver.ErrReplayedTxID{TxID: txID, Channel: channelName, Code: vc}
+	case driver.Busy, driver.Unknown:
+		if orderedAlready(ch, txID) {
+			logger.Warnf("transaction [%s] on channel [%s] has been ordered already [%d], refuse to broadcast it again", txID, channelName, vc)
+			return &driver.ErrReplayedTxID{TxID: txID, Channel: channelName, Code: vc}
+		}
+	}
+	return nil
+}
+
+// orderedAlready returns true if the envelope of the passed transaction has been delivered, or if an orderer
+// acknowledged its broadcast
+func orderedAlready(ch driver.Channel, txID string) bool {
+	if ch.EnvelopeService().Exists(txID) {
+		return true
+	}
+	p, ok := ch.(driver.ProvenanceProvider)
+	if !ok || p.ProvenanceService() == nil {
+		return false
+	}
+	// no provenance is recorded for the transactions not endorsed or broadcast by this node
+	provenance, err := p.ProvenanceService().GetTransactionProvenance(txID)
+	return err == nil && provenance.Broadcast != nil
+}
+
+// broadcastTxID returns the identifier and the channel of the transaction of the passed blob, empty if not readable
+func broadcastTxID(blob interface{}) (string, string) {
+	var env *common.Envelope
+	switch b := blob.(type) {
+	case ordering.Transaction:
+		return b.ID(), b.Channel()
+	case *transaction.Envelope:
+		env = b.Envelope()
+	case *common.Envelope:
+		env = b
+	}
+	chHdr := envelopeChannelHeader(env)
+	if chHdr == nil {
+		return "", ""
+	}
+	return chHdr.TxId, chHdr.ChannelId
+}
+
+// envelopeChannelHeader returns the channel header of the passed envelope, nil if not readable
+func envelopeChannelHeader(env *common.Envelope) *common.ChannelHeader {
+	if env == nil {
+		return nil
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil || payload.Header == nil {
+		return nil
+	}
+	chHdr, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil {
+		return nil
+	}
+	return chHdr
+}
@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingOrdering records the blobs broadcast
+type recordingOrdering struct {
+	broadcast []interface{}
+}
+
+func (o *recordingOrdering) Broadcast(blob interface{}) error {
+	o.broadcast = append(o.broadcast, blob)
+	return nil
+}
+
+func newTxEnvelope(channel, txID string) *common.Envelope {
+	payload := &common.Payload{
+		Header: &common.Header{
+			ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{
+				Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+				ChannelId: channel,
+				TxId:      txID,
+			}),
+		},
+	}
+	return &common.Envelope{Payload: protoutil.MarshalOrPanic(payload)}
+}
+
+func TestReplayedEnvelopes(t *testing.T) {
+	n, _ := newRemovableNetwork(t)
+	ordering := &recordingOrdering{}
+	n.ordering = ordering
+	ch := openWithState(t, n, "a")
+
+	// tx2 is invalidated by the peers as a duplicate, tx3 is busy
+	rws, err := ch.vault.NewRWSet("tx2")
+	assert.NoError(t, err)
+	rws.Done()
+	assert.NoError(t, ch.DiscardTxWithCode("tx2", 2, 0, pb.TxValidationCode_DUPLICATE_TXID, "invalidated in block [2] at position [0]"))
+	rws, err = ch.vault.NewRWSet("tx3")
+	assert.NoError(t, err)
+	rws.Done()
+
+	// the outcomes are queryable
+	status, err := ch.StatusWithMessage("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, &driver.ValidationStatus{TxID: "tx1", Code: driver.Valid, Outcome: driver.OutcomeValid, FabricCode: pb.TxValidationCode_VALID, HasFabricCode: true}, status)
+	status, err = ch.StatusWithMessage("tx2")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Invalid, status.Code)
+	assert.Equal(t, driver.OutcomeDuplicateTxID, status.Outcome)
+	assert.True(t, status.HasFabricCode)
+	assert.Equal(t, pb.TxValidationCode_DUPLICATE_TXID, status.FabricCode)
+	assert.Equal(t, "invalidated in block [2] at position [0]", status.Message)
+	status, err = ch.StatusWithMessage("tx3")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.OutcomeNone, status.Outcome)
+	assert.False(t, status.HasFabricCode)
+
+	// the final transactions are not broadcast again, the others are
+	for txID, code := range map[string]driver.ValidationCode{"tx1": driver.Valid, "tx2": driver.Invalid} {
+		err = n.Broadcast(newTxEnvelope("mychannel", txID))
+		replayed := &driver.ErrReplayedTxID{}
+		assert.True(t, errors.As(err, &replayed))
+		assert.Equal(t, txID, replayed.TxID)
+		assert.Equal(t, "mychannel", replayed.Channel)
+		assert.Equal(t, code, replayed.Code)
+	}
+	assert.Empty(t, ordering.broadcast)
+	assert.NoError(t, n.Broadcast(newTxEnvelope("mychannel", "tx3")))
+	assert.NoError(t, n.Broadcast(newTxEnvelope("mychannel", "tx4")))
+	assert.Len(t, ordering.broadcast, 2)
+
+	// once acknowledged by an orderer, the busy and the unknown transactions are duplicates
+	ch.provenanceService = transaction.NewProvenanceService(n.sp, "default", "mychannel", func() uint64 { return 0 })
+	assert.NoError(t, ch.ProvenanceService().RecordBroadcast("tx3", "orderer0", time.Now(), time.Now()))
+	assert.NoError(t, ch.ProvenanceService().RecordBroadcast("tx4", "orderer0", time.Now(), time.Now()))
+	for txID, code := range map[string]driver.ValidationCode{"tx3": driver.Busy, "tx4": driver.Unknown} {
+		err = n.Broadcast(newTxEnvelope("mychannel", txID))
+		replayed := &driver.ErrReplayedTxID{}
+		assert.True(t, errors.As(err, &replayed), txID)
+		assert.Equal(t, code, replayed.Code, txID)
+	}
+	assert.Len(t, ordering.broadcast, 2)
+	assert.NoError(t, ch.Close())
+}
@@ -0,0 +1,14 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// ReplicateStates applies to the vault of this channel the states replicated from another node, see vault.Vault#ReplicateStates
+func (c *channel) ReplicateStates(writes []driver.StateWrite, reset ...string) error {
+	return c.vault.ReplicateStates(writes, reset...)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+// notFoundInIndex is the error returned by qscc for the transactions the ledger of the peer does not have
+const notFoundInIndex = "no such transaction ID"
+
+// ResolveStuckTransaction resolves the passed transaction, stuck in the Busy status, or abandoned before.
+// The ledger of a peer is queried first: if it has the transaction, its block is re-processed and the vault gets the
+// status the ledger recorded. If the ledger has no trace of it, and the transaction has been busy for at least
+// policy.MinAge, the transaction is abandoned and the waiters of its finality are released with driver.ErrTransactionAbandoned.
+// If the peer cannot be queried, nothing changes.
+func (c *channel) ResolveStuckTransaction(txID string, policy driver.ResolutionPolicy) (*driver.Resolution, error) {
+	vc, deps, err := c.Status(txID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting the status of [%s]", txID)
+	}
+	if vc != driver.Busy && vc != driver.Abandoned {
+		return nil, errors.Errorf("transaction [%s] is not stuck, its status is [%d]", txID, vc)
+	}
+	res := &driver.Resolution{TxID: txID, Previous: vc, Block: driver.UnknownBlock, TxNum: driver.UnknownTxNum}
+
+	// the ledger is authoritative
+	_, err = c.GetTransactionByID(txID)
+	switch {
+	case err == nil:
+		if err := c.repair(txID); err != nil {
+			return nil, err
+		}
+		if res.Code, res.Block, res.TxNum, err = c.StatusWithHeight(txID); err != nil {
+			return nil, errors.WithMessagef(err, "failed getting the status of [%s] after the repair", txID)
+		}
+		res.Action = driver.ResolutionRepaired
+		return res, nil
+	case !strings.Contains(err.Error(), notFoundInIndex):
+		return nil, errors.WithMessagef(err, "failed looking up [%s] on the ledger", txID)
+	}
+
+	if vc == driver.Abandoned {
+		res.Code, res.Action = vc, driver.ResolutionAbandoned
+		return res, nil
+	}
+	since, err := c.vault.StatusTimestamp(txID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting since when [%s] is busy", txID)
+	}
+	if since.IsZero() {
+		return nil, errors.Errorf("transaction [%s] has no trace on the ledger, but since when it is busy is not known", txID)
+	}
+	if res.Age = time.Since(since); res.Age < policy.MinAge {
+		return nil, errors.Errorf("transaction [%s] has no trace on the ledger, but it is busy for [%s] only, at least [%s] required", txID, res.Age, policy.MinAge)
+	}
+	if err := c.vault.AbandonTx(txID); err != nil {
+		return nil, errors.WithMessagef(err, "failed abandoning [%s]", txID)
+	}
+	c.committer.Abandon(txID, deps)
+	c.notifyTxStatus(txID, driver.Abandoned)
+	res.Code, res.Action = driver.Abandoned, driver.ResolutionAbandoned
+	return res, nil
+}
+
+// repair re-processes the block of the ledger containing the passed transaction, for this transaction only
+func (c *channel) repair(txID string) error {
+	block, err := c.blockByTxID(txID)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting the block of [%s]", txID)
+	}
+	c.vault.BeginBlockCommit()
+	defer c.vault.EndBlockCommit()
+	if err := c.committer.Reprocess(block, txID); err != nil {
+		return errors.WithMessagef(err, "failed re-processing [%s] in block [%d]", txID, block.Header.Number)
+	}
+	return nil
+}
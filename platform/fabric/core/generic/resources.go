@@ -0,0 +1,62 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"reflect"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/chaincode"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/ordering"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+)
+
+var resourcesType = reflect.TypeOf((*Resources)(nil))
+
+// Resources are shared by all the fabric networks of a node.
+// The metric vectors can be registered only once per process, therefore they are created here,
+// and the networks label the metrics with their name.
+type Resources struct {
+	// CommitLimiter caps the number of blocks committed concurrently across the channels of all the networks
+	CommitLimiter *committer.Limiter
+	CommitMetrics *committer.CommitMetrics
+	// QueryCacheMetrics are shared by the query caches of the chaincodes of all the channels
+	QueryCacheMetrics *chaincode.QueryCacheMetrics
+	// RotationMetrics track the rotations of the TLS roots of the orderers of all the networks
+	RotationMetrics *ordering.RotationMetrics
+	// VaultMetrics are shared by the vaults of all the channels
+	VaultMetrics *vault.Metrics
+	// ChannelMetrics track the resources held by the channels of all the networks
+	ChannelMetrics *ChannelMetrics
+}
+
+// NewResources returns the resources whose metrics are registered in the passed provider.
+// commitParallelism caps the number of blocks committed concurrently, no cap if not positive.
+func NewResources(p metrics.Provider, commitParallelism int) *Resources {
+	return &Resources{
+		CommitLimiter:     committer.NewLimiter(commitParallelism),
+		CommitMetrics:     committer.NewCommitMetrics(p),
+		QueryCacheMetrics: chaincode.NewQueryCacheMetrics(p),
+		RotationMetrics:   ordering.NewRotationMetrics(p),
+		VaultMetrics:      vault.NewMetrics(p),
+		ChannelMetrics:    NewChannelMetrics(p),
+	}
+}
+
+// GetResources returns the resources registered in the passed service provider.
+// If none are registered, the returned resources do not record metrics and do not cap the commits.
+func GetResources(sp view2.ServiceProvider) *Resources {
+	s, err := sp.GetService(resourcesType)
+	if err != nil {
+		logger.Debugf("no fabric resources registered, use resources without metrics: [%s]", err)
+		return NewResources(&disabled.Provider{}, 0)
+	}
+	return s.(*Resources)
+}
@@ -0,0 +1,36 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"testing"
+
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger/fabric/common/metrics/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourcesShared(t *testing.T) {
+	registry := registry2.New()
+	// prometheus refuses to register the same metric vector twice
+	resources := NewResources(&prometheus.Provider{}, 2)
+	assert.NoError(t, registry.RegisterService(resources))
+
+	// the networks get the same limiter and the same metrics
+	for _, network := range []string{"alpha", "beta"} {
+		r := GetResources(registry)
+		assert.Same(t, resources, r, "network [%s] must share the resources", network)
+		r.CommitMetrics.BlocksCommitted.With("network", network, "channel", "ch").Add(1)
+		r.RotationMetrics.Rotations.With("network", network).Add(1)
+		r.VaultMetrics.QuotaExceeded.With("network", network, "channel", "ch", "namespace", "ns", "quota", "soft").Add(1)
+		r.ChannelMetrics.Open.With("network", network).Add(1)
+		r.QueryCacheMetrics.Hits.With("network", network, "channel", "ch", "chaincode", "cc").Add(1)
+	}
+
+	// without registered resources, the networks do not record metrics
+	assert.NotNil(t, GetResources(registry2.New()).CommitMetrics)
+}
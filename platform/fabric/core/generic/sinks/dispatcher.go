@@ -0,0 +1,309 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+)
+
+const keyPrefix = "sinks"
+
+var (
+	// minBackoff and maxBackoff bound the time between two attempts to deliver the same events
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// cursor is the persisted state of a sink: the events in [Next, Head) are buffered and not delivered yet.
+// Head and Dropped are written with the events, by the commit pipeline, Next by the dispatcher: they are stored
+// under different keys, the head key and the cursor key.
+type cursor struct {
+	Next    uint64
+	Head    uint64
+	Dropped uint64
+}
+
+// head is the part of the cursor written with the events
+type head struct {
+	Head    uint64
+	Dropped uint64
+}
+
+// Status is the status of the buffer of a sink
+type Status struct {
+	Buffered uint64
+	Dropped  uint64
+	Degraded bool
+}
+
+// dispatcher buffers the events of a sink in the KVS and delivers them from its own goroutine
+type dispatcher struct {
+	kvs        *kvs.KVS
+	network    string
+	channel    string
+	name       string
+	sink       Sink
+	bufferSize uint64
+	batchSize  int
+
+	filter filter
+
+	lock     sync.Mutex
+	cursor   cursor
+	degraded bool
+	// uow is the unit of work the events are buffered in, nil if none is enlisted, see enlist.
+	// staged is the head of the buffer once the unit of work is committed
+	uow    bookkeeping.Store
+	staged head
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newDispatcher(kvs *kvs.KVS, network, channel string, c *config.EventSink, sink Sink) (*dispatcher, error) {
+	if len(c.Name) == 0 {
+		return nil, errors.Errorf("sink of channel [%s:%s] without name", network, channel)
+	}
+	d := &dispatcher{
+		kvs:        kvs,
+		network:    network,
+		channel:    channel,
+		name:       c.Name,
+		sink:       sink,
+		bufferSize: DefaultBufferSize,
+		batchSize:  DefaultBatchSize,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	// the config events are delivered to the sinks listing them only
+	types := c.Types
+	if len(types) == 0 {
+		types = []string{ChaincodeEvent, FinalityEvent}
+	}
+	filter, err := newFilter(Filter{Types: types, Namespaces: c.Namespaces, Events: c.Events})
+	if err != nil {
+		return nil, errors.Errorf("sink [%s] of channel [%s:%s] filters %s", c.Name, network, channel, err)
+	}
+	d.filter = filter
+	if c.BufferSize > 0 {
+		d.bufferSize = uint64(c.BufferSize)
+	}
+	if c.BatchSize > 0 {
+		d.batchSize = c.BatchSize
+	}
+	// resume from the persisted cursor
+	if d.kvs.Exists(d.cursorKey()) {
+		if err := d.kvs.Get(d.cursorKey(), &d.cursor); err != nil {
+			return nil, errors.Wrapf(err, "failed loading cursor of sink [%s] of channel [%s:%s]", c.Name, network, channel)
+		}
+	}
+	if d.kvs.Exists(d.headKey()) {
+		h := &head{}
+		if err := d.kvs.Get(d.headKey(), h); err != nil {
+			return nil, errors.Wrapf(err, "failed loading head of sink [%s] of channel [%s:%s]", c.Name, network, channel)
+		}
+		d.cursor.Head, d.cursor.Dropped = h.Head, h.Dropped
+	}
+	if d.cursor.Head != d.cursor.Next {
+		logger.Infof("sink [%s] of channel [%s:%s] resumes with [%d] events buffered", c.Name, network, channel, d.cursor.Head-d.cursor.Next)
+	}
+	d.degraded = d.cursor.Head-d.cursor.Next >= d.bufferSize
+	return d, nil
+}
+
+func (d *dispatcher) start() {
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	go d.run()
+}
+
+func (d *dispatcher) stop() {
+	if d.cancel == nil {
+		_ = d.sink.Close()
+		return
+	}
+	d.cancel()
+	<-d.done
+	if err := d.sink.Close(); err != nil {
+		logger.Warnf("failed closing sink [%s] of channel [%s:%s]: [%s]", d.name, d.network, d.channel, err)
+	}
+}
+
+// enqueue buffers the passed event, if it passes the filters, from the commit pipeline.
+// If a unit of work is enlisted, the event is buffered in it, and delivered once it is committed.
+// When the buffer is full, the sink is degraded until the buffer has room again: the events are buffered anyway,
+// beyond the bound, they are never dropped to make room.
+func (d *dispatcher) enqueue(event *Event) {
+	if !d.filter.matches(event) {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var store bookkeeping.Store = d.kvs
+	h := &head{Head: d.cursor.Head, Dropped: d.cursor.Dropped}
+	if d.uow != nil {
+		store, h = d.uow, &d.staged
+	}
+	if h.Head-d.cursor.Next >= d.bufferSize && !d.degraded {
+		logger.Errorf("buffer of sink [%s] of channel [%s:%s] full, the events are buffered beyond the bound", d.name, d.network, d.channel)
+		d.degraded = true
+	}
+
+	e := *event
+	e.Seq = h.Head
+	if err := store.Put(d.eventKey(e.Seq), &e); err != nil {
+		logger.Errorf("failed buffering event of [%s] for sink [%s] of channel [%s:%s]: [%s]", e.TxID, d.name, d.network, d.channel, err)
+		h.Dropped++
+	} else {
+		h.Head++
+	}
+	if err := store.Put(d.headKey(), h); err != nil {
+		logger.Errorf("failed storing head of sink [%s] of channel [%s:%s]: [%s]", d.name, d.network, d.channel, err)
+	}
+	if d.uow == nil {
+		d.cursor.Head, d.cursor.Dropped = h.Head, h.Dropped
+		d.wakeUp()
+	}
+}
+
+// enlist buffers the next events in the passed unit of work, until release is called
+func (d *dispatcher) enlist(uow bookkeeping.Store) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.uow, d.staged = uow, head{Head: d.cursor.Head, Dropped: d.cursor.Dropped}
+}
+
+// release stops buffering in the enlisted unit of work. If it has been committed, its events are delivered,
+// otherwise they are dropped with it.
+func (d *dispatcher) release(committed bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.uow == nil {
+		return
+	}
+	d.uow = nil
+	if !committed {
+		d.degraded = d.cursor.Head-d.cursor.Next >= d.bufferSize
+		return
+	}
+	d.cursor.Head, d.cursor.Dropped = d.staged.Head, d.staged.Dropped
+	d.wakeUp()
+}
+
+// wakeUp wakes up the dispatcher. If it is woken up already, it reads the new events with the others.
+func (d *dispatcher) wakeUp() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dispatcher) status() Status {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return Status{Buffered: d.cursor.Head - d.cursor.Next, Dropped: d.cursor.Dropped, Degraded: d.degraded}
+}
+
+func (d *dispatcher) run() {
+	defer close(d.done)
+	backoff := minBackoff
+	for {
+		batch, err := d.next()
+		if err == nil && len(batch) == 0 {
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-d.wake:
+				continue
+			}
+		}
+		if err == nil {
+			err = d.sink.Deliver(d.ctx, batch)
+		}
+		if err != nil {
+			logger.Warnf("failed delivering events to sink [%s] of channel [%s:%s], retry in [%s]: [%s]", d.name, d.network, d.channel, backoff, err)
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = minBackoff
+		d.ack(batch)
+	}
+}
+
+// next returns the next batch of buffered events
+func (d *dispatcher) next() ([]*Event, error) {
+	d.lock.Lock()
+	from, to := d.cursor.Next, d.cursor.Head
+	d.lock.Unlock()
+	if to-from > uint64(d.batchSize) {
+		to = from + uint64(d.batchSize)
+	}
+
+	batch := make([]*Event, 0, to-from)
+	for seq := from; seq < to; seq++ {
+		e := &Event{}
+		if err := d.kvs.Get(d.eventKey(seq), e); err != nil {
+			return nil, errors.Wrapf(err, "failed loading buffered event [%d]", seq)
+		}
+		batch = append(batch, e)
+	}
+	return batch, nil
+}
+
+// ack removes the passed delivered events from the buffer
+func (d *dispatcher) ack(batch []*Event) {
+	for _, e := range batch {
+		if err := d.kvs.Delete(d.eventKey(e.Seq)); err != nil {
+			logger.Warnf("failed removing delivered event [%d] of sink [%s] of channel [%s:%s]: [%s]", e.Seq, d.name, d.network, d.channel, err)
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.cursor.Next += uint64(len(batch))
+	if d.degraded && d.cursor.Head-d.cursor.Next < d.bufferSize {
+		logger.Infof("buffer of sink [%s] of channel [%s:%s] has room again, [%d] events dropped so far", d.name, d.network, d.channel, d.cursor.Dropped)
+		d.degraded = false
+	}
+	d.persistCursor()
+}
+
+// persistCursor stores the cursor of the delivered events, d.lock must be held
+func (d *dispatcher) persistCursor() {
+	if err := d.kvs.Put(d.cursorKey(), &d.cursor); err != nil {
+		logger.Errorf("failed storing cursor of sink [%s] of channel [%s:%s]: [%s]", d.name, d.network, d.channel, err)
+	}
+}
+
+func (d *dispatcher) cursorKey() string {
+	return kvs.CreateCompositeKeyOrPanic(keyPrefix, []string{d.network, d.channel, d.name, "cursor"})
+}
+
+func (d *dispatcher) headKey() string {
+	return kvs.CreateCompositeKeyOrPanic(keyPrefix, []string{d.network, d.channel, d.name, "head"})
+}
+
+func (d *dispatcher) eventKey(seq uint64) string {
+	return kvs.CreateCompositeKeyOrPanic(keyPrefix, []string{d.network, d.channel, d.name, "events", fmt.Sprintf("%020d", seq)})
+}
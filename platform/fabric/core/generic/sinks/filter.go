@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import "github.com/pkg/errors"
+
+// Filter selects events by type (chaincode, finality or config), namespace and chaincode event name,
+// an empty field matches everything
+type Filter struct {
+	Types []string
+	// Namespaces are the chaincode of a chaincode event, the namespaces written by a transaction
+	Namespaces []string
+	// Events are the names of the chaincode events
+	Events []string
+}
+
+type filter struct {
+	types      map[string]bool
+	namespaces map[string]bool
+	events     map[string]bool
+}
+
+func newFilter(f Filter) (filter, error) {
+	for _, typ := range f.Types {
+		if typ != ChaincodeEvent && typ != FinalityEvent && typ != ConfigEvent {
+			return filter{}, errors.Errorf("unknown event type [%s]", typ)
+		}
+	}
+	return filter{types: set(f.Types), namespaces: set(f.Namespaces), events: set(f.Events)}, nil
+}
+
+// matches tells whether the passed event passes the filter.
+// The filter on the event names applies to the chaincode events only, the config events have no namespace.
+func (f filter) matches(e *Event) bool {
+	if len(f.types) > 0 && !f.types[e.Type] {
+		return false
+	}
+	switch e.Type {
+	case ConfigEvent:
+		return true
+	case ChaincodeEvent:
+		if len(f.namespaces) > 0 && !f.namespaces[e.Namespace] {
+			return false
+		}
+		return len(f.events) == 0 || f.events[e.Name]
+	default:
+		if len(f.namespaces) == 0 {
+			return true
+		}
+		for _, ns := range e.Namespaces {
+			if f.namespaces[ns] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func set(values []string) map[string]bool {
+	res := make(map[string]bool, len(values))
+	for _, v := range values {
+		res[v] = true
+	}
+	return res
+}
@@ -0,0 +1,342 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+)
+
+// JournalConfig configures the journal of the events of a channel, read by the subscriptions
+type JournalConfig struct {
+	// Retention bounds the events kept, the oldest are removed first
+	Retention int
+	// MaxLag bounds the events a subscription can be behind the head of the journal, the retention if not positive
+	MaxLag int
+}
+
+// ErrInvalidToken is returned when a resume token is malformed, or does not belong to the channel subscribed
+type ErrInvalidToken struct {
+	Token  string
+	Reason string
+}
+
+func (e *ErrInvalidToken) Error() string {
+	return fmt.Sprintf("invalid resume token [%s]: %s", e.Token, e.Reason)
+}
+
+// ErrTokenExpired is returned when the events following a resume token are not retained any more
+type ErrTokenExpired struct {
+	Network string
+	Channel string
+	// Next is the event the token resumes from, First the oldest event retained
+	Next  uint64
+	First uint64
+}
+
+func (e *ErrTokenExpired) Error() string {
+	return fmt.Sprintf("resume token of [%s:%s] expired, event [%d] not retained, the oldest is [%d]", e.Network, e.Channel, e.Next, e.First)
+}
+
+// ErrLagging ends the subscriptions that fell too far behind the head of the journal
+type ErrLagging struct {
+	Network string
+	Channel string
+	Lag     uint64
+	MaxLag  uint64
+}
+
+func (e *ErrLagging) Error() string {
+	return fmt.Sprintf("subscription to [%s:%s] is [%d] events behind, more than [%d]", e.Network, e.Channel, e.Lag, e.MaxLag)
+}
+
+// journalCursor is the persisted state of a journal: the events in [First, Head) are retained
+type journalCursor struct {
+	First uint64
+	Head  uint64
+}
+
+// journal keeps the last events of a channel in the KVS, numbered in commit order, for the subscriptions
+type journal struct {
+	kvs       *kvs.KVS
+	network   string
+	channel   string
+	retention uint64
+	maxLag    uint64
+
+	lock          sync.Mutex
+	cursor        journalCursor
+	subscriptions map[*Subscription]struct{}
+	closed        error
+	// uow is the unit of work the events are written to, nil if none is enlisted, see enlist.
+	// staged is the cursor of the journal once the unit of work is committed
+	uow    bookkeeping.Store
+	staged journalCursor
+}
+
+func newJournal(kvs *kvs.KVS, network, channel string, c *JournalConfig) (*journal, error) {
+	if c.Retention <= 0 {
+		return nil, errors.Errorf("journal of channel [%s:%s] without retention", network, channel)
+	}
+	j := &journal{
+		kvs:           kvs,
+		network:       network,
+		channel:       channel,
+		retention:     uint64(c.Retention),
+		maxLag:        uint64(c.Retention),
+		subscriptions: map[*Subscription]struct{}{},
+	}
+	if c.MaxLag > 0 && c.MaxLag < c.Retention {
+		j.maxLag = uint64(c.MaxLag)
+	}
+	if j.kvs.Exists(j.cursorKey()) {
+		if err := j.kvs.Get(j.cursorKey(), &j.cursor); err != nil {
+			return nil, errors.Wrapf(err, "failed loading cursor of the journal of channel [%s:%s]", network, channel)
+		}
+	}
+	return j, nil
+}
+
+// append stores the passed event at the head of the journal, removes the events not retained any more,
+// and ends the subscriptions lagging behind.
+// If a unit of work is enlisted, the writes are part of it, and the subscriptions see the event once it is committed.
+func (j *journal) append(event *Event) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.closed != nil {
+		return
+	}
+
+	var store bookkeeping.Store = j.kvs
+	cursor := &j.cursor
+	if j.uow != nil {
+		store, cursor = j.uow, &j.staged
+	}
+	e := *event
+	e.Seq = cursor.Head
+	if err := store.Put(j.eventKey(e.Seq), &e); err != nil {
+		logger.Errorf("failed journaling event of [%s] of channel [%s:%s]: [%s]", e.TxID, j.network, j.channel, err)
+		return
+	}
+	cursor.Head++
+	for ; cursor.Head-cursor.First > j.retention; cursor.First++ {
+		if err := store.Delete(j.eventKey(cursor.First)); err != nil {
+			logger.Warnf("failed removing journaled event [%d] of channel [%s:%s]: [%s]", cursor.First, j.network, j.channel, err)
+		}
+	}
+	if err := store.Put(j.cursorKey(), cursor); err != nil {
+		logger.Errorf("failed storing cursor of the journal of channel [%s:%s]: [%s]", j.network, j.channel, err)
+	}
+	if j.uow == nil {
+		j.notify()
+	}
+}
+
+// enlist writes the next events to the passed unit of work, until release is called
+func (j *journal) enlist(uow bookkeeping.Store) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.uow, j.staged = uow, j.cursor
+}
+
+// release stops writing to the enlisted unit of work. If it has been committed, the subscriptions see its events,
+// otherwise they are dropped.
+func (j *journal) release(committed bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.uow == nil {
+		return
+	}
+	j.uow = nil
+	if !committed || j.staged == j.cursor {
+		return
+	}
+	j.cursor = j.staged
+	if j.closed == nil {
+		j.notify()
+	}
+}
+
+// notify ends the subscriptions lagging behind, and wakes up the others. It must be called with the lock held.
+func (j *journal) notify() {
+	for s := range j.subscriptions {
+		if lag := j.cursor.Head - s.next; lag > j.maxLag {
+			s.end(&ErrLagging{Network: j.network, Channel: j.channel, Lag: lag, MaxLag: j.maxLag})
+			delete(j.subscriptions, s)
+			continue
+		}
+		s.wakeUp()
+	}
+}
+
+// subscribe returns a subscription to the events following the passed token, or to the next events if empty
+func (j *journal) subscribe(f filter, token string) (*Subscription, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.closed != nil {
+		return nil, j.closed
+	}
+
+	next := j.cursor.Head
+	if len(token) != 0 {
+		var err error
+		next, err = j.parseToken(token)
+		if err != nil {
+			return nil, err
+		}
+		if next < j.cursor.First {
+			return nil, &ErrTokenExpired{Network: j.network, Channel: j.channel, Next: next, First: j.cursor.First}
+		}
+		if next > j.cursor.Head {
+			return nil, &ErrInvalidToken{Token: token, Reason: "ahead of the journal"}
+		}
+	}
+	s := &Subscription{journal: j, filter: f, next: next, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	j.subscriptions[s] = struct{}{}
+	return s, nil
+}
+
+func (j *journal) unsubscribe(s *Subscription) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	delete(j.subscriptions, s)
+}
+
+// close ends the subscriptions, they can resume from their last token when the channel is open again
+func (j *journal) close() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.closed = errors.Errorf("channel [%s:%s] closed", j.network, j.channel)
+	for s := range j.subscriptions {
+		s.end(j.closed)
+	}
+	j.subscriptions = map[*Subscription]struct{}{}
+}
+
+// Token returns the resume token of the position following the passed event of the journal
+func (j *journal) token(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(j.network + "\x00" + j.channel + "\x00" + strconv.FormatUint(seq+1, 10)))
+}
+
+func (j *journal) parseToken(token string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, &ErrInvalidToken{Token: token, Reason: "not encoded"}
+	}
+	parts := strings.Split(string(raw), "\x00")
+	if len(parts) != 3 {
+		return 0, &ErrInvalidToken{Token: token, Reason: "malformed"}
+	}
+	if parts[0] != j.network || parts[1] != j.channel {
+		return 0, &ErrInvalidToken{Token: token, Reason: fmt.Sprintf("token of channel [%s:%s]", parts[0], parts[1])}
+	}
+	next, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return 0, &ErrInvalidToken{Token: token, Reason: "malformed position"}
+	}
+	return next, nil
+}
+
+func (j *journal) cursorKey() string {
+	return kvs.CreateCompositeKeyOrPanic(keyPrefix, []string{j.network, j.channel, "journal", "cursor"})
+}
+
+func (j *journal) eventKey(seq uint64) string {
+	return kvs.CreateCompositeKeyOrPanic(keyPrefix, []string{j.network, j.channel, "journal", "events", fmt.Sprintf("%020d", seq)})
+}
+
+// Subscription reads the events of the journal of a channel, in commit order, see Service.Subscribe
+type Subscription struct {
+	journal *journal
+	filter  filter
+
+	// next is the position of the next event to read, guarded by the lock of the journal
+	next uint64
+	wake chan struct{}
+
+	endOnce sync.Once
+	err     error
+	done    chan struct{}
+}
+
+// Next returns the next event passing the filter of the subscription, with the token resuming after it.
+// It waits for the event to be committed, until the passed context is done or the subscription ends:
+// the subscription ends with *ErrLagging when it falls too far behind, and when the channel is closed.
+func (s *Subscription) Next(ctx context.Context) (*Event, string, error) {
+	for {
+		j := s.journal
+		j.lock.Lock()
+		next, head, first := s.next, j.cursor.Head, j.cursor.First
+		j.lock.Unlock()
+
+		select {
+		case <-s.done:
+			return nil, "", s.err
+		default:
+		}
+		if next < first {
+			s.Close()
+			return nil, "", &ErrLagging{Network: j.network, Channel: j.channel, Lag: head - next, MaxLag: j.maxLag}
+		}
+		if next == head {
+			select {
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			case <-s.done:
+				return nil, "", s.err
+			case <-s.wake:
+				continue
+			}
+		}
+
+		e := &Event{}
+		if err := j.kvs.Get(j.eventKey(next), e); err != nil {
+			// the event may have been removed in the meantime
+			j.lock.Lock()
+			first = j.cursor.First
+			j.lock.Unlock()
+			if next < first {
+				continue
+			}
+			return nil, "", errors.Wrapf(err, "failed loading journaled event [%d] of channel [%s:%s]", next, j.network, j.channel)
+		}
+		j.lock.Lock()
+		s.next = next + 1
+		j.lock.Unlock()
+		if s.filter.matches(e) {
+			return e, j.token(next), nil
+		}
+	}
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.journal.unsubscribe(s)
+	s.end(errors.New("subscription closed"))
+}
+
+func (s *Subscription) end(err error) {
+	s.endOnce.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+func (s *Subscription) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
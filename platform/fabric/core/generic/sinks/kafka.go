@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/pkg/errors"
+)
+
+// KafkaSink publishes the events to a Kafka topic, one message per event, keyed by transaction id and
+// acknowledged by all the in-sync replicas
+type KafkaSink struct {
+	brokers []string
+	topic   string
+
+	lock     sync.Mutex
+	producer sarama.SyncProducer
+}
+
+// NewKafkaSink returns the Kafka sink of the passed configuration.
+// The brokers are contacted at the first delivery.
+func NewKafkaSink(c *config.EventSink) (Sink, error) {
+	if c.Kafka == nil || len(c.Kafka.Brokers) == 0 || len(c.Kafka.Topic) == 0 {
+		return nil, errors.Errorf("kafka sink [%s] requires brokers and topic", c.Name)
+	}
+	return &KafkaSink{brokers: c.Kafka.Brokers, topic: c.Kafka.Topic}, nil
+}
+
+func (k *KafkaSink) Deliver(_ context.Context, events []*Event) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.producer == nil {
+		conf := sarama.NewConfig()
+		conf.Producer.RequiredAcks = sarama.WaitForAll
+		conf.Producer.Return.Successes = true
+		producer, err := sarama.NewSyncProducer(k.brokers, conf)
+		if err != nil {
+			return errors.Wrapf(err, "failed connecting to kafka brokers %v", k.brokers)
+		}
+		k.producer = producer
+	}
+
+	messages := make([]*sarama.ProducerMessage, len(events))
+	for i, e := range events {
+		value, err := json.Marshal(e)
+		if err != nil {
+			return errors.Wrapf(err, "failed marshalling event [%d]", e.Seq)
+		}
+		messages[i] = &sarama.ProducerMessage{Topic: k.topic, Key: sarama.StringEncoder(e.TxID), Value: sarama.ByteEncoder(value)}
+	}
+	if err := k.producer.SendMessages(messages); err != nil {
+		return errors.Wrapf(err, "failed publishing to kafka topic [%s]", k.topic)
+	}
+	return nil
+}
+
+func (k *KafkaSink) Close() error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.producer == nil {
+		return nil
+	}
+	err := k.producer.Close()
+	k.producer = nil
+	return err
+}
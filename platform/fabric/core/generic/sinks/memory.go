@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import (
+	"context"
+	"sync"
+)
+
+// MemorySink records the events delivered, for testing.
+// It can be made to fail, to simulate an external system not reachable.
+type MemorySink struct {
+	lock   sync.Mutex
+	events []*Event
+	err    error
+}
+
+// NewMemorySink returns an empty memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (m *MemorySink) Deliver(_ context.Context, events []*Event) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func (m *MemorySink) Close() error {
+	return nil
+}
+
+// Fail makes the next deliveries fail with the passed error, nil to make them succeed again
+func (m *MemorySink) Fail(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.err = err
+}
+
+// Events returns the events delivered so far
+func (m *MemorySink) Events() []*Event {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*Event(nil), m.events...)
+}
@@ -0,0 +1,352 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.sinks")
+
+const (
+	// ChaincodeEvent is the type of the events carrying a chaincode event
+	ChaincodeEvent = "chaincode"
+	// FinalityEvent is the type of the events carrying the finality of a transaction
+	FinalityEvent = "finality"
+	// ConfigEvent is the type of the events carrying the commit of a configuration transaction
+	ConfigEvent = "config"
+
+	// DefaultBufferSize is the number of events buffered by a sink beyond which it is degraded, unless configured otherwise
+	DefaultBufferSize = 10000
+	// DefaultBatchSize bounds the events delivered at once, unless configured otherwise
+	DefaultBatchSize = 100
+)
+
+var serviceType = reflect.TypeOf((*Service)(nil))
+
+// Event is an event committed on a channel, as exported to the sinks.
+// Seq orders the events delivered to the same sink: a sink may receive an event more than once, after a failure
+// or a restart, and can use Seq to discard the duplicates.
+type Event struct {
+	Seq     uint64 `json:"seq"`
+	Type    string `json:"type"`
+	Network string `json:"network"`
+	Channel string `json:"channel"`
+	TxID    string `json:"txid"`
+	Block   uint64 `json:"block"`
+	TxNum   uint64 `json:"txnum"`
+	// Namespace and Name are the chaincode and the name of a chaincode event
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Payload   []byte `json:"payload,omitempty"`
+	// Valid, Namespaces and Error are the outcome, the namespaces written and the error of a finality event
+	Valid      bool     `json:"valid,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Sink delivers the events to an external system
+type Sink interface {
+	// Deliver delivers the passed events, in order. If an error is returned, the events are delivered again later.
+	Deliver(ctx context.Context, events []*Event) error
+	// Close releases the resources of the sink
+	Close() error
+}
+
+// Factory returns the sink of the passed configuration
+type Factory func(c *config.EventSink) (Sink, error)
+
+// Service exports the events committed on the channels to the sinks configured for them.
+// The events of each sink are buffered in the KVS, together with the cursor of the sink, in the unit of work of the
+// commit of their block, and delivered asynchronously, at least once: the delivery failures do not slow down the
+// commit pipeline.
+type Service struct {
+	kvs *kvs.KVS
+
+	lock      sync.RWMutex
+	factories map[string]Factory
+	channels  map[string]*Channel
+}
+
+// NewService returns a service buffering the events in the passed KVS, with the kafka, webhook and memory sinks
+func NewService(kvs *kvs.KVS) *Service {
+	return &Service{
+		kvs: kvs,
+		factories: map[string]Factory{
+			"kafka":   NewKafkaSink,
+			"webhook": NewWebhookSink,
+			"memory":  func(*config.EventSink) (Sink, error) { return NewMemorySink(), nil },
+		},
+		channels: map[string]*Channel{},
+	}
+}
+
+// GetService returns the sinks service registered in the passed service provider, nil if not available
+func GetService(sp view2.ServiceProvider) *Service {
+	s, err := sp.GetService(serviceType)
+	if err != nil {
+		return nil
+	}
+	return s.(*Service)
+}
+
+// RegisterFactory registers the factory of the sinks of the passed type
+func (s *Service) RegisterFactory(typ string, factory Factory) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.factories[typ] = factory
+}
+
+// NewChannel starts the sinks configured for the passed channel, resuming the delivery of the events buffered
+// before the last shutdown. If the passed journal configuration is not nil, the events are journaled too,
+// for the subscriptions, see Subscribe. It returns nil if no sink is configured and no journal.
+func (s *Service) NewChannel(network, channel string, configs []*config.EventSink, journal *JournalConfig) (*Channel, error) {
+	if len(configs) == 0 && journal == nil {
+		return nil, nil
+	}
+	c := &Channel{service: s, network: network, channel: channel, namespaces: map[string][]string{}}
+	if journal != nil {
+		j, err := newJournal(s.kvs, network, channel, journal)
+		if err != nil {
+			return nil, err
+		}
+		c.journal = j
+	}
+	for _, sc := range configs {
+		s.lock.RLock()
+		factory, ok := s.factories[sc.Type]
+		s.lock.RUnlock()
+		if !ok {
+			c.Close()
+			return nil, errors.Errorf("sink [%s] of channel [%s:%s] has unknown type [%s]", sc.Name, network, channel, sc.Type)
+		}
+		sink, err := factory(sc)
+		if err != nil {
+			c.Close()
+			return nil, errors.WithMessagef(err, "failed creating sink [%s] of channel [%s:%s]", sc.Name, network, channel)
+		}
+		d, err := newDispatcher(s.kvs, network, channel, sc, sink)
+		if err != nil {
+			_ = sink.Close()
+			c.Close()
+			return nil, err
+		}
+		c.dispatchers = append(c.dispatchers, d)
+	}
+	for _, d := range c.dispatchers {
+		d.start()
+	}
+
+	s.lock.Lock()
+	s.channels[network+":"+channel] = c
+	s.lock.Unlock()
+	return c, nil
+}
+
+// Sink returns the sink with the passed name of the passed channel, nil if not found
+func (s *Service) Sink(network, channel, name string) Sink {
+	s.lock.RLock()
+	c, ok := s.channels[network+":"+channel]
+	s.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	for _, d := range c.dispatchers {
+		if d.name == name {
+			return d.sink
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a subscription to the events of the passed channel that pass the passed filter.
+// The subscription starts after the event of the passed resume token, as returned by Subscription.Next,
+// or with the next event committed if the token is empty.
+// It fails with *ErrTokenExpired if the events following the token are not retained any more,
+// and with *ErrInvalidToken if the token is not one of the channel.
+func (s *Service) Subscribe(network, channel string, f Filter, token string) (*Subscription, error) {
+	s.lock.RLock()
+	c, ok := s.channels[network+":"+channel]
+	s.lock.RUnlock()
+	if !ok || c.journal == nil {
+		return nil, errors.Errorf("no subscriptions to the events of channel [%s:%s]", network, channel)
+	}
+	ff, err := newFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	return c.journal.subscribe(ff, token)
+}
+
+// HealthCheck fails if the buffer of any sink is full
+func (s *Service) HealthCheck(context.Context) error {
+	s.lock.RLock()
+	channels := make([]*Channel, 0, len(s.channels))
+	for _, c := range s.channels {
+		channels = append(channels, c)
+	}
+	s.lock.RUnlock()
+
+	var degraded []string
+	for _, c := range channels {
+		for _, d := range c.dispatchers {
+			if status := d.status(); status.Degraded {
+				degraded = append(degraded, fmt.Sprintf("%s:%s/%s (%d events buffered, %d dropped)", c.network, c.channel, d.name, status.Buffered, status.Dropped))
+			}
+		}
+	}
+	if len(degraded) > 0 {
+		sort.Strings(degraded)
+		return errors.Errorf("degraded event sinks [%s]", strings.Join(degraded, "; "))
+	}
+	return nil
+}
+
+func (s *Service) remove(c *Channel) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.channels[c.network+":"+c.channel] == c {
+		delete(s.channels, c.network+":"+c.channel)
+	}
+}
+
+// Channel feeds the sinks of a channel with its events, from the commit pipeline
+type Channel struct {
+	service     *Service
+	network     string
+	channel     string
+	dispatchers []*dispatcher
+	journal     *journal
+
+	// namespaces are the namespaces written by the transactions whose finality is not notified yet
+	lock       sync.Mutex
+	namespaces map[string][]string
+}
+
+// OnWrite records the namespaces written by a valid transaction, they are exported with its finality
+func (c *Channel) OnWrite(txID string, namespaces []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.namespaces[txID] = namespaces
+}
+
+// OnChaincodeEvent exports the passed chaincode event
+func (c *Channel) OnChaincodeEvent(event *driver.ChaincodeEvent) {
+	c.enqueue(&Event{
+		Type:      ChaincodeEvent,
+		Network:   c.network,
+		Channel:   c.channel,
+		TxID:      event.TransactionID,
+		Block:     event.BlockNumber,
+		TxNum:     uint64(event.TxNum),
+		Namespace: event.ChaincodeID,
+		Name:      event.EventName,
+		Payload:   event.Payload,
+	})
+}
+
+// OnFinality exports the finality of the passed transaction
+func (c *Channel) OnFinality(txID string, block uint64, txNum int, valid bool, err error) {
+	c.lock.Lock()
+	namespaces := c.namespaces[txID]
+	delete(c.namespaces, txID)
+	c.lock.Unlock()
+
+	event := &Event{
+		Type:       FinalityEvent,
+		Network:    c.network,
+		Channel:    c.channel,
+		TxID:       txID,
+		Block:      block,
+		TxNum:      uint64(txNum),
+		Valid:      valid,
+		Namespaces: namespaces,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	c.enqueue(event)
+}
+
+// OnConfigUpdate exports the commit of the passed configuration transaction
+func (c *Channel) OnConfigUpdate(txID string, block uint64, txNum int) {
+	if c == nil {
+		return
+	}
+	c.enqueue(&Event{
+		Type:    ConfigEvent,
+		Network: c.network,
+		Channel: c.channel,
+		TxID:    txID,
+		Block:   block,
+		TxNum:   uint64(txNum),
+	})
+}
+
+func (c *Channel) enqueue(event *Event) {
+	for _, d := range c.dispatchers {
+		d.enqueue(event)
+	}
+	if c.journal != nil {
+		c.journal.append(event)
+	}
+}
+
+// Enlist writes the events buffered and journaled next to the passed unit of work, until Release is called.
+// The commit pipeline enlists the unit of work of the bookkeeping of the block being committed.
+func (c *Channel) Enlist(uow bookkeeping.Store) {
+	if c == nil {
+		return
+	}
+	for _, d := range c.dispatchers {
+		d.enlist(uow)
+	}
+	if c.journal != nil {
+		c.journal.enlist(uow)
+	}
+}
+
+// Release stops writing to the unit of work enlisted. If it has been committed, its events are delivered to the
+// sinks and the subscriptions see them.
+func (c *Channel) Release(committed bool) {
+	if c == nil {
+		return
+	}
+	for _, d := range c.dispatchers {
+		d.release(committed)
+	}
+	if c.journal != nil {
+		c.journal.release(committed)
+	}
+}
+
+// Close stops the sinks of the channel, the events not delivered yet are delivered after the next start
+func (c *Channel) Close() {
+	if c == nil {
+		return
+	}
+	for _, d := range c.dispatchers {
+		d.stop()
+	}
+	if c.journal != nil {
+		c.journal.close()
+	}
+	c.service.remove(c)
+}
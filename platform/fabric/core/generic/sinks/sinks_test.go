@@ -0,0 +1,270 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	minBackoff = 10 * time.Millisecond
+	maxBackoff = 10 * time.Millisecond
+}
+
+func newService(t *testing.T) *Service {
+	kvss, err := kvs.NewWithConfig(registry2.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	return NewService(kvss)
+}
+
+func TestSinks(t *testing.T) {
+	s := newService(t)
+	configs := []*config.EventSink{{Name: "exporter", Type: "memory", Namespaces: []string{"ns1"}, Events: []string{"transfer"}, BufferSize: 3}}
+	c, err := s.NewChannel("network", "ch", configs, nil)
+	assert.NoError(t, err)
+	sink := s.Sink("network", "ch", "exporter").(*MemorySink)
+
+	// only the events of ns1, and the chaincode events named transfer, are exported
+	c.OnChaincodeEvent(&driver.ChaincodeEvent{BlockNumber: 1, TxNum: 0, TransactionID: "tx1", ChaincodeID: "ns1", EventName: "transfer", Payload: []byte("p1")})
+	c.OnChaincodeEvent(&driver.ChaincodeEvent{BlockNumber: 1, TxNum: 0, TransactionID: "tx1", ChaincodeID: "ns1", EventName: "mint"})
+	c.OnChaincodeEvent(&driver.ChaincodeEvent{BlockNumber: 1, TxNum: 1, TransactionID: "tx2", ChaincodeID: "ns2", EventName: "transfer"})
+	c.OnWrite("tx1", []string{"ns1", "ns3"})
+	c.OnFinality("tx1", 1, 0, true, nil)
+	c.OnWrite("tx2", []string{"ns2"})
+	c.OnFinality("tx2", 1, 1, true, nil)
+	assert.Eventually(t, func() bool { return len(sink.Events()) == 2 }, time.Second, 10*time.Millisecond)
+	events := sink.Events()
+	assert.Equal(t, &Event{Seq: 0, Type: ChaincodeEvent, Network: "network", Channel: "ch", TxID: "tx1", Block: 1, Namespace: "ns1", Name: "transfer", Payload: []byte("p1")}, events[0])
+	assert.Equal(t, &Event{Seq: 1, Type: FinalityEvent, Network: "network", Channel: "ch", TxID: "tx1", Block: 1, Valid: true, Namespaces: []string{"ns1", "ns3"}}, events[1])
+	assert.NoError(t, s.HealthCheck(context.Background()))
+
+	// the sink is not reachable, the events are buffered, beyond the bound the sink is degraded
+	sink.Fail(errors.New("unreachable"))
+	for i := 0; i < 5; i++ {
+		c.OnWrite("tx3", []string{"ns1"})
+		c.OnFinality("tx3", 2, i, false, errors.Errorf("invalid %d", i))
+	}
+	assert.EqualError(t, s.HealthCheck(context.Background()), "degraded event sinks [network:ch/exporter (5 events buffered, 0 dropped)]")
+
+	// after a restart, the buffered events are delivered and the sink recovers
+	c.Close()
+	assert.Nil(t, s.Sink("network", "ch", "exporter"))
+	c, err = s.NewChannel("network", "ch", configs, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	sink = s.Sink("network", "ch", "exporter").(*MemorySink)
+	assert.Eventually(t, func() bool { return len(sink.Events()) == 5 }, time.Second, 10*time.Millisecond)
+	for i, e := range sink.Events() {
+		assert.Equal(t, uint64(2+i), e.Seq)
+		assert.Equal(t, "invalid "+string(rune('0'+i)), e.Error)
+	}
+	assert.NoError(t, s.HealthCheck(context.Background()))
+}
+
+func TestSinksUnitOfWork(t *testing.T) {
+	s := newService(t)
+	configs := []*config.EventSink{{Name: "exporter", Type: "memory"}}
+	c, err := s.NewChannel("network", "ch", configs, nil)
+	assert.NoError(t, err)
+	sink := s.Sink("network", "ch", "exporter").(*MemorySink)
+
+	// the events of a unit of work discarded are dropped
+	uow := s.kvs.NewBatch()
+	c.Enlist(uow)
+	c.OnFinality("tx1", 1, 0, true, nil)
+	uow.Discard()
+	c.Release(false)
+
+	// those of a unit of work committed are delivered once it is
+	uow = s.kvs.NewBatch()
+	c.Enlist(uow)
+	c.OnFinality("tx2", 2, 0, true, nil)
+	c.OnFinality("tx3", 2, 1, true, nil)
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, sink.Events())
+	assert.NoError(t, uow.Commit())
+	c.Release(true)
+	assert.Eventually(t, func() bool { return len(sink.Events()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "tx2", sink.Events()[0].TxID)
+	assert.Equal(t, uint64(0), sink.Events()[0].Seq)
+
+	// after a restart, the cursor resumes after the events committed
+	c.Close()
+	c, err = s.NewChannel("network", "ch", configs, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	sink = s.Sink("network", "ch", "exporter").(*MemorySink)
+	c.OnFinality("tx4", 3, 0, true, nil)
+	assert.Eventually(t, func() bool { return len(sink.Events()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, &Event{Seq: 2, Type: FinalityEvent, Network: "network", Channel: "ch", TxID: "tx4", Block: 3, Valid: true}, sink.Events()[0])
+}
+
+func TestSinksConfig(t *testing.T) {
+	s := newService(t)
+	c, err := s.NewChannel("network", "ch", nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+	c.Close()
+
+	_, err = s.NewChannel("network", "ch", []*config.EventSink{{Name: "a", Type: "unknown"}}, nil)
+	assert.EqualError(t, err, "sink [a] of channel [network:ch] has unknown type [unknown]")
+	_, err = s.NewChannel("network", "ch", []*config.EventSink{{Name: "a", Type: "kafka"}}, nil)
+	assert.EqualError(t, err, "failed creating sink [a] of channel [network:ch]: kafka sink [a] requires brokers and topic")
+	_, err = s.NewChannel("network", "ch", []*config.EventSink{{Name: "a", Type: "memory", Types: []string{"block"}}}, nil)
+	assert.EqualError(t, err, "sink [a] of channel [network:ch] filters unknown event type [block]")
+}
+
+func TestWebhookSink(t *testing.T) {
+	var calls, failing int32
+	var received []*Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt fails
+		if atomic.AddInt32(&calls, 1) == 1 || atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(&config.EventSink{Name: "hook", Webhook: &config.WebhookSink{URL: server.URL, Retries: 1, Backoff: time.Millisecond}})
+	assert.NoError(t, err)
+	defer sink.Close()
+	events := []*Event{{Seq: 7, Type: FinalityEvent, TxID: "tx1", Valid: true}}
+	assert.NoError(t, sink.Deliver(context.Background(), events))
+	assert.Equal(t, events, received)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// the retries are bounded
+	atomic.StoreInt32(&calls, 0)
+	atomic.StoreInt32(&failing, 1)
+	assert.Error(t, sink.Deliver(context.Background(), events))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestSubscriptions(t *testing.T) {
+	s := newService(t)
+	c, err := s.NewChannel("network", "ch", nil, &JournalConfig{Retention: 5, MaxLag: 4})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// the subscriptions start with the next event committed, and filter the events
+	all, err := s.Subscribe("network", "ch", Filter{}, "")
+	assert.NoError(t, err)
+	chaincode, err := s.Subscribe("network", "ch", Filter{Types: []string{ChaincodeEvent}, Namespaces: []string{"ns1"}}, "")
+	assert.NoError(t, err)
+	c.OnChaincodeEvent(&driver.ChaincodeEvent{BlockNumber: 1, TransactionID: "tx1", ChaincodeID: "ns1", EventName: "transfer"})
+	c.OnWrite("tx1", []string{"ns1"})
+	c.OnFinality("tx1", 1, 0, true, nil)
+	c.OnConfigUpdate("configtx_1", 2, 0)
+	c.OnChaincodeEvent(&driver.ChaincodeEvent{BlockNumber: 3, TransactionID: "tx2", ChaincodeID: "ns2", EventName: "transfer"})
+
+	var tokens []string
+	for _, typ := range []string{ChaincodeEvent, FinalityEvent, ConfigEvent, ChaincodeEvent} {
+		e, token, err := all.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, typ, e.Type)
+		tokens = append(tokens, token)
+	}
+	e, _, err := chaincode.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "tx1", e.TxID)
+	_, _, err = chaincode.Next(ctx)
+
+	// a subscription resumes after the event of its token
+	resumed, err := s.Subscribe("network", "ch", Filter{}, tokens[1])
+	assert.NoError(t, err)
+	e, token, err := resumed.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &Event{Seq: 2, Type: ConfigEvent, Network: "network", Channel: "ch", TxID: "configtx_1", Block: 2}, e)
+	assert.Equal(t, tokens[2], token)
+
+	// the subscriptions lagging behind are ended
+	for i := 0; i < 4; i++ {
+		c.OnConfigUpdate("configtx", 4, 0)
+	}
+	_, _, err = resumed.Next(ctx)
+	lagging := &ErrLagging{}
+	assert.True(t, errors.As(err, &lagging))
+	assert.Equal(t, &ErrLagging{Network: "network", Channel: "ch", Lag: 5, MaxLag: 4}, lagging)
+	_, _, err = all.Next(ctx)
+	assert.NoError(t, err)
+
+	// the tokens of the events not retained any more expire
+	_, err = s.Subscribe("network", "ch", Filter{}, tokens[0])
+	assert.EqualError(t, err, "resume token of [network:ch] expired, event [1] not retained, the oldest is [3]")
+	_, err = s.Subscribe("network", "ch", Filter{}, "invalid")
+	invalid := &ErrInvalidToken{}
+	assert.True(t, errors.As(err, &invalid))
+	_, err = s.Subscribe("network", "other", Filter{}, "")
+	assert.EqualError(t, err, "no subscriptions to the events of channel [network:other]")
+
+	// the journal survives a restart, the subscriptions end with the channel
+	c.Close()
+	_, _, err = all.Next(ctx)
+	assert.EqualError(t, err, "channel [network:ch] closed")
+	_, err = s.NewChannel("network", "ch", nil, &JournalConfig{Retention: 5})
+	assert.NoError(t, err)
+	resumed, err = s.Subscribe("network", "ch", Filter{Types: []string{ConfigEvent}}, tokens[3])
+	assert.NoError(t, err)
+	e, _, err = resumed.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), e.Seq)
+}
+
+func TestSubscriptionsUnitOfWork(t *testing.T) {
+	s := newService(t)
+	c, err := s.NewChannel("network", "ch", nil, &JournalConfig{Retention: 5})
+	assert.NoError(t, err)
+	sub, err := s.Subscribe("network", "ch", Filter{}, "")
+	assert.NoError(t, err)
+
+	// the events of a unit of work discarded are dropped
+	uow := s.kvs.NewBatch()
+	c.Enlist(uow)
+	c.OnConfigUpdate("configtx_1", 1, 0)
+	uow.Discard()
+	c.Release(false)
+
+	// those of a unit of work committed are seen once it is, with the writes of the commit
+	uow = s.kvs.NewBatch()
+	c.Enlist(uow)
+	c.OnConfigUpdate("configtx_2", 2, 0)
+	assert.NoError(t, uow.Put("checkpoint", uint64(2)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, _, err = sub.Next(ctx)
+	cancel()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.NoError(t, uow.Commit())
+	c.Release(true)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	e, _, err := sub.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &Event{Seq: 0, Type: ConfigEvent, Network: "network", Channel: "ch", TxID: "configtx_2", Block: 2}, e)
+	c.OnConfigUpdate("configtx_3", 3, 0)
+	e, _, err = sub.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), e.Seq)
+}
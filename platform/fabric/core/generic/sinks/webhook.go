@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultWebhookRetries is the number of times a webhook sink retries a delivery before giving up for a while
+	DefaultWebhookRetries = 3
+	// DefaultWebhookBackoff is the time a webhook sink waits before retrying a delivery
+	DefaultWebhookBackoff = time.Second
+	// DefaultWebhookTimeout bounds each request of a webhook sink
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookSink posts the events to an HTTP endpoint as a JSON array, any status other than 2xx is a failure
+type WebhookSink struct {
+	url     string
+	retries int
+	backoff time.Duration
+	client  *http.Client
+}
+
+// NewWebhookSink returns the webhook sink of the passed configuration
+func NewWebhookSink(c *config.EventSink) (Sink, error) {
+	if c.Webhook == nil || len(c.Webhook.URL) == 0 {
+		return nil, errors.Errorf("webhook sink [%s] requires a URL", c.Name)
+	}
+	w := &WebhookSink{
+		url:     c.Webhook.URL,
+		retries: DefaultWebhookRetries,
+		backoff: DefaultWebhookBackoff,
+		client:  &http.Client{Timeout: DefaultWebhookTimeout},
+	}
+	if c.Webhook.Retries > 0 {
+		w.retries = c.Webhook.Retries
+	}
+	if c.Webhook.Backoff > 0 {
+		w.backoff = c.Webhook.Backoff
+	}
+	if c.Webhook.Timeout > 0 {
+		w.client.Timeout = c.Webhook.Timeout
+	}
+	return w, nil
+}
+
+func (w *WebhookSink) Deliver(ctx context.Context, events []*Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling events")
+	}
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil || attempt >= w.retries {
+			return err
+		}
+		logger.Debugf("failed posting to [%s], attempt [%d]: [%s]", w.url, attempt+1, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.backoff):
+		}
+	}
+}
+
+func (w *WebhookSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed creating request to [%s]", w.url)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed posting to [%s]", w.url)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("webhook [%s] answered [%s]", w.url, resp.Status)
+	}
+	return nil
+}
+
+func (w *WebhookSink) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
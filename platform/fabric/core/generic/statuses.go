@@ -0,0 +1,14 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// ListStatuses lists the statuses of the transactions in the vault of this channel, see vault.Vault#ListStatuses
+func (c *channel) ListStatuses(filter driver.StatusFilter, page driver.PageToken) (*driver.StatusPage, error) {
+	return c.vault.ListStatuses(filter, page)
+}
@@ -8,6 +8,7 @@ package transaction
 
 import (
 	"crypto/rand"
+	"time"
 
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
@@ -64,6 +65,7 @@ func (m *Manager) NewTransaction(creator view2.Identity, nonce []byte, txid stri
 		TNetwork:   m.fns.Name(),
 		TChannel:   channel,
 		TTransient: map[string][]byte{},
+		TCreated:   time.Now(),
 	}, nil
 }
 
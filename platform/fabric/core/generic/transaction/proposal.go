@@ -10,6 +10,7 @@ import (
 	"crypto/sha256"
 
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/clock"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	cb "github.com/hyperledger/fabric-protos-go/common"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
@@ -199,7 +200,7 @@ func (up *UnpackedProposal) Validate(idDeserializer msp.IdentityDeserializer) er
 	// ensure that creator is a valid certificate
 	err = creator.Validate()
 	if err != nil {
-		logger.Warningf("access denied: identity is not valid: %s", err)
+		logger.Warningf("access denied: identity is not valid: %s", clock.Annotate(err))
 		return genericAuthError
 	}
 
@@ -7,9 +7,14 @@ SPDX-License-Identifier: Apache-2.0
 package transaction
 
 import (
+	"bytes"
+	"sync"
+	"time"
+
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
 	"github.com/hyperledger/fabric-protos-go/common"
@@ -19,10 +24,30 @@ import (
 
 var logger = flogging.MustGetLogger("fabric-sdk.core")
 
+// Enlist returns the passed bookkeeping stores writing to the passed unit of work.
+// The stores not created by this package are returned as they are, writing directly.
+func Enlist(uow bookkeeping.Store, stores driver.BookkeepingStores) *driver.BookkeepingStores {
+	if s, ok := stores.Envelopes.(*envs); ok {
+		stores.Envelopes = s.enlist(uow)
+	}
+	if s, ok := stores.Transactions.(*ets); ok {
+		stores.Transactions = s.enlist(uow)
+	}
+	if s, ok := stores.Metadata.(*mds); ok {
+		stores.Metadata = s.enlist(uow)
+	}
+	if s, ok := stores.Provenance.(*provs); ok {
+		stores.Provenance = s.enlist(uow)
+	}
+	return &stores
+}
+
 type mds struct {
 	sp      view2.ServiceProvider
 	network string
 	channel string
+	// uow is the unit of work the writes are enlisted in, nil if written directly to the KVS
+	uow bookkeeping.Store
 }
 
 func NewMetadataService(sp view2.ServiceProvider, network string, channel string) *mds {
@@ -33,12 +58,20 @@ func NewMetadataService(sp view2.ServiceProvider, network string, channel string
 	}
 }
 
+func (s *mds) enlist(uow bookkeeping.Store) *mds {
+	return &mds{sp: s.sp, network: s.network, channel: s.channel, uow: uow}
+}
+
+func (s *mds) store() bookkeeping.Store {
+	return bookkeeping.Get(s.sp, s.uow)
+}
+
 func (s *mds) Exists(txid string) bool {
 	key, err := kvs.CreateCompositeKey("metadata", []string{s.channel, s.network, txid})
 	if err != nil {
 		return false
 	}
-	return kvs.GetService(s.sp).Exists(key)
+	return s.store().Exists(key)
 }
 
 func (s *mds) StoreTransient(txid string, transientMap driver.TransientMap) error {
@@ -47,9 +80,9 @@ func (s *mds) StoreTransient(txid string, transientMap driver.TransientMap) erro
 		return err
 	}
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("store transient for [%s][%v]", txid, transientMap)
+		logger.Debugf("store transient for [%s][%v]", txid, flogging.Sensitive(transientMap))
 	}
-	return kvs.GetService(s.sp).Put(key, transientMap)
+	return s.store().Put(key, transientMap)
 }
 
 func (s *mds) LoadTransient(txid string) (driver.TransientMap, error) {
@@ -60,7 +93,7 @@ func (s *mds) LoadTransient(txid string) (driver.TransientMap, error) {
 		return nil, err
 	}
 	transientMap := driver.TransientMap{}
-	err = kvs.GetService(s.sp).Get(key, &transientMap)
+	err = s.store().Get(key, &transientMap)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +104,8 @@ type envs struct {
 	sp      view2.ServiceProvider
 	network string
 	channel string
+	// uow is the unit of work the writes are enlisted in, nil if written directly to the KVS
+	uow bookkeeping.Store
 }
 
 func NewEnvelopeService(sp view2.ServiceProvider, network string, channel string) *envs {
@@ -81,13 +116,21 @@ func NewEnvelopeService(sp view2.ServiceProvider, network string, channel string
 	}
 }
 
+func (s *envs) enlist(uow bookkeeping.Store) *envs {
+	return &envs{sp: s.sp, network: s.network, channel: s.channel, uow: uow}
+}
+
+func (s *envs) store() bookkeeping.Store {
+	return bookkeeping.Get(s.sp, s.uow)
+}
+
 func (s *envs) Exists(txid string) bool {
 	key, err := kvs.CreateCompositeKey("envelope", []string{s.channel, s.network, txid})
 	if err != nil {
 		return false
 	}
 
-	return kvs.GetService(s.sp).Exists(key)
+	return s.store().Exists(key)
 }
 
 func (s *envs) StoreEnvelope(txID string, env interface{}) error {
@@ -99,13 +142,13 @@ func (s *envs) StoreEnvelope(txID string, env interface{}) error {
 
 	switch e := env.(type) {
 	case []byte:
-		return kvs.GetService(s.sp).Put(key, e)
+		return s.store().Put(key, e)
 	case *common.Envelope:
 		envBytes, err := proto.Marshal(e)
 		if err != nil {
 			return errors.WithMessagef(err, "failed marshalling envelop for tx [%s]", txID)
 		}
-		return kvs.GetService(s.sp).Put(key, envBytes)
+		return s.store().Put(key, envBytes)
 	default:
 		return errors.Errorf("invalid env, expected []byte or *common.Envelope, got [%T]", env)
 	}
@@ -119,7 +162,7 @@ func (s *envs) LoadEnvelope(txid string) ([]byte, error) {
 		return nil, err
 	}
 	env := []byte{}
-	err = kvs.GetService(s.sp).Get(key, &env)
+	err = s.store().Get(key, &env)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +173,8 @@ type ets struct {
 	sp      view2.ServiceProvider
 	network string
 	channel string
+	// uow is the unit of work the writes are enlisted in, nil if written directly to the KVS
+	uow bookkeeping.Store
 }
 
 func NewEndorseTransactionService(sp view2.ServiceProvider, network string, channel string) *ets {
@@ -140,12 +185,20 @@ func NewEndorseTransactionService(sp view2.ServiceProvider, network string, chan
 	}
 }
 
+func (s *ets) enlist(uow bookkeeping.Store) *ets {
+	return &ets{sp: s.sp, network: s.network, channel: s.channel, uow: uow}
+}
+
+func (s *ets) store() bookkeeping.Store {
+	return bookkeeping.Get(s.sp, s.uow)
+}
+
 func (s *ets) Exists(txid string) bool {
 	key, err := kvs.CreateCompositeKey("etx", []string{s.channel, s.network, txid})
 	if err != nil {
 		return false
 	}
-	return kvs.GetService(s.sp).Exists(key)
+	return s.store().Exists(key)
 }
 
 func (s *ets) StoreTransaction(txid string, env []byte) error {
@@ -155,7 +208,7 @@ func (s *ets) StoreTransaction(txid string, env []byte) error {
 	}
 	logger.Debugf("store etx for [%s]", txid)
 
-	return kvs.GetService(s.sp).Put(key, env)
+	return s.store().Put(key, env)
 }
 
 func (s *ets) LoadTransaction(txid string) ([]byte, error) {
@@ -166,9 +219,148 @@ func (s *ets) LoadTransaction(txid string) ([]byte, error) {
 		return nil, err
 	}
 	env := []byte{}
-	err = kvs.GetService(s.sp).Get(key, &env)
+	err = s.store().Get(key, &env)
 	if err != nil {
 		return nil, err
 	}
 	return env, nil
 }
+
+type provs struct {
+	sp      view2.ServiceProvider
+	network string
+	channel string
+	// sequence returns the sequence of the channel config in force
+	sequence func() uint64
+	// lock serializes the updates of the records, it is shared with the stores enlisted in units of work,
+	// which hold it until the unit of work ends
+	lock *sync.Mutex
+	// uow is the unit of work the writes are enlisted in, nil if written directly to the KVS
+	uow bookkeeping.Store
+}
+
+// NewProvenanceService returns the store of the provenance of the transactions of the passed channel, stamping the
+// records with the config sequence returned by the passed function
+func NewProvenanceService(sp view2.ServiceProvider, network string, channel string, sequence func() uint64) *provs {
+	return &provs{
+		sp:       sp,
+		network:  network,
+		channel:  channel,
+		sequence: sequence,
+		lock:     &sync.Mutex{},
+	}
+}
+
+func (s *provs) enlist(uow bookkeeping.Store) *provs {
+	return &provs{sp: s.sp, network: s.network, channel: s.channel, sequence: s.sequence, lock: s.lock, uow: uow}
+}
+
+func (s *provs) store() bookkeeping.Store {
+	return bookkeeping.Get(s.sp, s.uow)
+}
+
+// RecordEndorsements merges the passed endorsements with those recorded before. An endorsement recorded already,
+// same endorser and same response, keeps what has been recorded first, its timestamp included: the collector of the
+// endorsements records them before the broadcast records them again. The details it lacks are completed.
+func (s *provs) RecordEndorsements(txID string, endorsers []driver.EndorserProvenance) error {
+	logger.Debugf("store endorsement provenance for [%s]", txID)
+	return s.update(txID, func(p *driver.Provenance) {
+		if len(p.Endorsers) == 0 {
+			p.EndorsementConfigSequence = s.sequence()
+		}
+		for _, endorser := range endorsers {
+			recorded := false
+			for i := range p.Endorsers {
+				e := &p.Endorsers[i]
+				if !bytes.Equal(e.Endorser, endorser.Endorser) || !bytes.Equal(e.ResponseDigest, endorser.ResponseDigest) {
+					continue
+				}
+				if len(e.Endpoint) == 0 {
+					e.Endpoint = endorser.Endpoint
+				}
+				if e.Latency == 0 {
+					e.Latency = endorser.Latency
+				}
+				recorded = true
+				break
+			}
+			if !recorded {
+				p.Endorsers = append(p.Endorsers, endorser)
+			}
+		}
+	})
+}
+
+func (s *provs) RecordBroadcast(txID string, orderer string, sentAt time.Time, acknowledgedAt time.Time) error {
+	logger.Debugf("store broadcast provenance for [%s]", txID)
+	return s.update(txID, func(p *driver.Provenance) {
+		p.Broadcast = &driver.BroadcastProvenance{
+			Orderer:        orderer,
+			SentAt:         sentAt,
+			AcknowledgedAt: acknowledgedAt,
+			ConfigSequence: s.sequence(),
+		}
+	})
+}
+
+func (s *provs) RecordRouting(txID string, routing driver.RoutingProvenance) error {
+	logger.Debugf("store routing provenance for [%s]", txID)
+	return s.update(txID, func(p *driver.Provenance) {
+		p.Routing = &routing
+	})
+}
+
+func (s *provs) RecordCommit(txID string, block uint64, indexInBlock int, validationCode int32) error {
+	key, err := kvs.CreateCompositeKey("provenance", []string{s.channel, s.network, txID})
+	if err != nil {
+		return err
+	}
+	if !s.store().Exists(key) {
+		// the transaction has not been created, endorsed or broadcast by this node
+		return nil
+	}
+	logger.Debugf("store commit provenance for [%s]", txID)
+	return s.update(txID, func(p *driver.Provenance) {
+		p.Commit = &driver.CommitProvenance{
+			Block:          block,
+			IndexInBlock:   indexInBlock,
+			ValidationCode: validationCode,
+			ConfigSequence: s.sequence(),
+		}
+	})
+}
+
+func (s *provs) GetTransactionProvenance(txID string) (*driver.Provenance, error) {
+	key, err := kvs.CreateCompositeKey("provenance", []string{s.channel, s.network, txID})
+	if err != nil {
+		return nil, err
+	}
+	p := &driver.Provenance{}
+	if err := s.store().Get(key, p); err != nil {
+		return nil, errors.WithMessagef(err, "no provenance recorded for [%s]", txID)
+	}
+	return p, nil
+}
+
+func (s *provs) update(txID string, update func(p *driver.Provenance)) error {
+	key, err := kvs.CreateCompositeKey("provenance", []string{s.channel, s.network, txID})
+	if err != nil {
+		return err
+	}
+	if uow, ok := s.uow.(bookkeeping.UnitOfWork); ok {
+		// the record read here is written when the unit of work commits, the updates in between wait
+		uow.Hold(s.lock)
+	} else {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+	}
+
+	p := &driver.Provenance{TxID: txID, Channel: s.channel}
+	if s.store().Exists(key) {
+		if err := s.store().Get(key, p); err != nil {
+			return errors.WithMessagef(err, "failed loading provenance of [%s]", txID)
+		}
+	}
+	update(p)
+	return s.store().Put(key, p)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transaction
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	registry := registry2.New()
+	kvss, err := kvs.NewWithConfig(registry, "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+
+	sequence := uint64(3)
+	s := NewProvenanceService(registry, "network", "ch", func() uint64 { return sequence })
+	_, err = s.GetTransactionProvenance("tx1")
+	assert.Error(t, err)
+
+	// the endorsements and the broadcast are recorded in the same record, each with the config sequence at the time
+	endorsers := []driver.EndorserProvenance{{
+		Endpoint:       "peer0:7051",
+		Endorser:       []byte("peer0"),
+		ResponseDigest: []byte("digest"),
+		Latency:        time.Millisecond,
+		Timestamp:      time.Now().UTC().Round(0),
+	}}
+	assert.NoError(t, s.RecordEndorsements("tx1", endorsers))
+	sequence = 4
+	sentAt := time.Now().UTC().Round(0)
+	assert.NoError(t, s.RecordBroadcast("tx1", "orderer0:7050", sentAt, sentAt.Add(time.Second)))
+
+	p, err := s.GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, &driver.Provenance{
+		TxID:                      "tx1",
+		Channel:                   "ch",
+		Endorsers:                 endorsers,
+		EndorsementConfigSequence: 3,
+		Broadcast: &driver.BroadcastProvenance{
+			Orderer:        "orderer0:7050",
+			SentAt:         sentAt,
+			AcknowledgedAt: sentAt.Add(time.Second),
+			ConfigSequence: 4,
+		},
+	}, p)
+
+	// the endorsements recorded again, at the broadcast, keep their first record, the new ones are added
+	again := []driver.EndorserProvenance{
+		{Endorser: []byte("peer0"), ResponseDigest: []byte("digest"), Timestamp: sentAt},
+		{Endorser: []byte("peer1"), ResponseDigest: []byte("digest"), Timestamp: sentAt},
+	}
+	assert.NoError(t, s.RecordEndorsements("tx1", again))
+	assert.NoError(t, s.RecordEndorsements("tx1", nil))
+	p, err = s.GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, []driver.EndorserProvenance{endorsers[0], again[1]}, p.Endorsers)
+	assert.Equal(t, uint64(3), p.EndorsementConfigSequence)
+	assert.Equal(t, "orderer0:7050", p.Broadcast.Orderer)
+
+	// the routing is kept with the rest
+	routing := driver.RoutingProvenance{Network: "network", Channel: "ch", NetworkSource: driver.RoutingDefault, ChannelSource: driver.RoutingContext}
+	assert.NoError(t, s.RecordRouting("tx1", routing))
+	p, err = s.GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, &routing, p.Routing)
+	assert.Equal(t, "orderer0:7050", p.Broadcast.Orderer)
+
+	// the commit is recorded only for the transactions with a provenance
+	assert.NoError(t, s.RecordCommit("tx1", 10, 2, 11))
+	assert.NoError(t, s.RecordCommit("tx2", 10, 3, 0))
+	p, err = s.GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, &driver.CommitProvenance{Block: 10, IndexInBlock: 2, ValidationCode: 11, ConfigSequence: 4}, p.Commit)
+	_, err = s.GetTransactionProvenance("tx2")
+	assert.Error(t, err)
+}
+
+func TestEnlist(t *testing.T) {
+	registry := registry2.New()
+	kvss, err := kvs.NewWithConfig(registry, "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+
+	ets := NewEndorseTransactionService(registry, "network", "ch")
+	mds := NewMetadataService(registry, "network", "ch")
+	ps := NewProvenanceService(registry, "network", "ch", func() uint64 { return 1 })
+	uow := kvss.NewBatch()
+	stores := Enlist(uow, driver.BookkeepingStores{Transactions: ets, Metadata: mds, Provenance: ps})
+
+	// the writes are visible to the enlisted stores only, until committed
+	assert.NoError(t, stores.Transactions.StoreTransaction("tx1", []byte("tx")))
+	assert.NoError(t, stores.Metadata.StoreTransient("tx1", driver.TransientMap{"k": []byte("v")}))
+	assert.NoError(t, stores.Provenance.RecordEndorsements("tx1", nil))
+	assert.NoError(t, stores.Provenance.RecordBroadcast("tx1", "orderer0:7050", time.Now(), time.Now()))
+	assert.True(t, stores.Transactions.Exists("tx1"))
+	p, err := stores.Provenance.GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, "orderer0:7050", p.Broadcast.Orderer)
+	assert.False(t, ets.Exists("tx1"))
+	assert.False(t, mds.Exists("tx1"))
+	_, err = ps.GetTransactionProvenance("tx1")
+	assert.Error(t, err)
+
+	assert.NoError(t, uow.Commit())
+	raw, err := ets.LoadTransaction("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("tx"), raw)
+	tm, err := mds.LoadTransient("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v"), tm["k"])
+	p, err = ps.GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, "orderer0:7050", p.Broadcast.Orderer)
+
+	// an update written directly waits for the unit of work updating the same record, and is not overwritten
+	uow = kvss.NewBatch()
+	assert.NoError(t, Enlist(uow, driver.BookkeepingStores{Provenance: ps}).Provenance.RecordCommit("tx1", 10, 2, 0))
+	done := make(chan error)
+	go func() {
+		done <- ps.RecordBroadcast("tx1", "orderer1:7050", time.Now(), time.Now())
+	}()
+	select {
+	case <-done:
+		t.Fatal("the direct update did not wait for the unit of work")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, uow.Commit())
+	assert.NoError(t, <-done)
+	p, err = ps.GetTransactionProvenance("tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, "orderer1:7050", p.Broadcast.Orderer)
+	assert.Equal(t, uint64(10), p.Commit.Block)
+
+	// a discarded unit of work releases the record too
+	uow = kvss.NewBatch()
+	assert.NoError(t, Enlist(uow, driver.BookkeepingStores{Provenance: ps}).Provenance.RecordCommit("tx1", 11, 0, 0))
+	uow.Discard()
+	assert.NoError(t, ps.RecordBroadcast("tx1", "orderer2:7050", time.Now(), time.Now()))
+}
@@ -10,12 +10,14 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/fabricutils"
 
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	pcommon "github.com/hyperledger/fabric-protos-go/common"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
@@ -99,6 +101,13 @@ type Transaction struct {
 	TProposal          *pb.Proposal
 	TSignedProposal    *pb.SignedProposal
 	TProposalResponses []*pb.ProposalResponse
+
+	// TCreated is when the transaction has been created, TTimeToLive is its own time to live, 0 if not set
+	TCreated    time.Time
+	TTimeToLive time.Duration
+
+	// skipStaleReadCheck is a local choice of the party broadcasting the transaction, it is not marshalled
+	skipStaleReadCheck bool
 }
 
 func (t *Transaction) Creator() view.Identity {
@@ -178,6 +187,8 @@ func (t *Transaction) From(tx driver.Transaction) (err error) {
 	}
 	t.TProposalResponses = payload.TProposalResponses
 	t.TTransient = payload.TTransient
+	t.TCreated = payload.TCreated
+	t.TTimeToLive = payload.TTimeToLive
 	return
 }
 
@@ -186,7 +197,7 @@ func (t *Transaction) SetFromBytes(raw []byte) error {
 	if err != nil {
 		return errors.Wrapf(err, "SetFromBytes: failed unmarshalling payload [%s]", string(raw))
 	}
-	logger.Debugf("set transient [%v]", t.TTransient)
+	logger.Debugf("set transient [%v]", flogging.Sensitive(t.TTransient))
 
 	if t.TSignedProposal != nil {
 		// TODO: check the current payload is compatible with the content of the signed proposal
@@ -208,6 +219,9 @@ func (t *Transaction) SetFromBytes(raw []byte) error {
 		if len(t.TCreator) == 0 {
 			t.TCreator = up.SignatureHeader.Creator
 		}
+		if t.TCreated.IsZero() {
+			t.TCreated = headerTimestamp(up.ChannelHeader)
+		}
 		t.signedProposal, err = newSignedProposal(t.TSignedProposal)
 		if err != nil {
 			return err
@@ -245,6 +259,9 @@ func (t *Transaction) SetFromEnvelopeBytes(raw []byte) error {
 	if len(t.TCreator) == 0 {
 		t.TCreator = upe.SignatureHeader.Creator
 	}
+	if t.TCreated.IsZero() {
+		t.TCreated = headerTimestamp(upe.ChannelHeader)
+	}
 	t.TProposalResponses = upe.ProposalResponses
 
 	// Set the channel
@@ -388,7 +405,7 @@ func (t *Transaction) Bytes() ([]byte, error) {
 	if err := t.Done(); err != nil {
 		return nil, err
 	}
-	logger.Debugf("get transient [%v]", t.TTransient)
+	logger.Debugf("get transient [%v]", flogging.Sensitive(t.TTransient))
 	return json.Marshal(t)
 }
 
@@ -427,7 +444,7 @@ func (t *Transaction) EndorseWithIdentity(identity view.Identity) error {
 			return errors.Wrapf(err, "failed setting proposal")
 		}
 	} else {
-		logger.Debugf("signed proposal already set [%v]", t.signedProposal)
+		logger.Debugf("signed proposal already set [%v]", flogging.Sensitive(t.signedProposal))
 	}
 
 	// is there already a response or a simulation is in progress?
@@ -578,6 +595,71 @@ func (t *Transaction) Envelope() (driver.Envelope, error) {
 	return NewEnvelopeFromEnv(env)
 }
 
+// EstimatedSize returns the estimated size in bytes of the envelope of this transaction.
+// Once endorsed, the envelope is assembled from the proposal responses, and only the signature of the creator is estimated.
+// Before that, the estimate accounts for the proposal and the read-write set simulated so far.
+func (t *Transaction) EstimatedSize() (int, error) {
+	if len(t.TProposalResponses) != 0 {
+		hdr, data, err := fabricutils.CreateEndorserTX(&signerWrapper{creator: t.Creator()}, t.Proposal(), t.ProposalResponses()...)
+		if err != nil {
+			return 0, errors.WithMessage(err, "could not assemble transaction")
+		}
+		payload, err := protoutil.GetBytesPayload(&pcommon.Payload{Header: hdr, Data: data})
+		if err != nil {
+			return 0, errors.Wrap(err, "failed marshalling payload")
+		}
+		return proto.Size(&pcommon.Envelope{Payload: payload, Signature: make([]byte, signatureSizeAllowance)}), nil
+	}
+
+	size := signatureSizeAllowance
+	if t.TProposal != nil {
+		size += len(t.TProposal.Header) + len(t.TProposal.Payload)
+	}
+	rws := t.RWSet
+	if t.rwset != nil {
+		var err error
+		rws, err = t.rwset.Bytes()
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed marshalling rws")
+		}
+	}
+	return size + len(rws), nil
+}
+
+// SkipStaleReadCheck tells whether the read set of the transaction is checked against the vault before broadcast
+func (t *Transaction) SkipStaleReadCheck(skip bool) {
+	t.skipStaleReadCheck = skip
+}
+
+// StaleReadCheckSkipped returns true if the read set of the transaction is not checked before broadcast
+func (t *Transaction) StaleReadCheckSkipped() bool {
+	return t.skipStaleReadCheck
+}
+
+// Created returns when the transaction has been created, or, if not known, when its proposal has been
+func (t *Transaction) Created() time.Time {
+	return t.TCreated
+}
+
+// SetTTL sets the time to live of the transaction: it is not broadcast once this time elapsed since its creation.
+// 0 means the time to live of its chaincode, or of its network.
+func (t *Transaction) SetTTL(ttl time.Duration) {
+	t.TTimeToLive = ttl
+}
+
+// TTL returns the time to live of the transaction, 0 if not set
+func (t *Transaction) TTL() time.Duration {
+	return t.TTimeToLive
+}
+
+// headerTimestamp returns the timestamp of the passed channel header, the zero time if not set
+func headerTimestamp(chHdr *pcommon.ChannelHeader) time.Time {
+	if chHdr == nil || chHdr.Timestamp == nil {
+		return time.Time{}
+	}
+	return chHdr.Timestamp.AsTime()
+}
+
 func (t *Transaction) generateProposal(signer SerializableSigner) error {
 	logger.Debugf("generate proposal...")
 	// Build the spec
@@ -681,9 +763,9 @@ func (t *Transaction) getProposalResponse(signer SerializableSigner) (*pb.Propos
 	)
 	logger.Debugf("ProposalResponse [%s][%s]->\n[%s]\n[%s] \n",
 		base64.StdEncoding.EncodeToString(signedProposal.ProposalHash()),
-		base64.StdEncoding.EncodeToString(pubSimResBytes),
-		base64.StdEncoding.EncodeToString(prpBytes),
-		base64.StdEncoding.EncodeToString(creator),
+		flogging.Sensitive(base64.StdEncoding.EncodeToString(pubSimResBytes)),
+		flogging.Sensitive(base64.StdEncoding.EncodeToString(prpBytes)),
+		flogging.Sensitive(base64.StdEncoding.EncodeToString(creator)),
 	)
 	if err != nil {
 		return nil, errors.WithMessage(err, "failed to create the proposal response")
@@ -703,6 +785,10 @@ func (t *Transaction) getProposalResponse(signer SerializableSigner) (*pb.Propos
 	}, nil
 }
 
+// signatureSizeAllowance is the room left by EstimatedSize for the signatures not produced yet.
+// It covers ECDSA signatures and idemix signatures with few attributes.
+const signatureSizeAllowance = 1024
+
 type Signer interface {
 	Sign(message []byte) ([]byte, error)
 }
@@ -14,9 +14,10 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/rwset"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 	"github.com/hyperledger/fabric-protos-go/common"
-	"github.com/hyperledger/fabric/bccsp/factory"
+	"github.com/hyperledger/fabric/bccsp"
 	"github.com/hyperledger/fabric/common/channelconfig"
 	"github.com/hyperledger/fabric/common/configtx"
 	"github.com/hyperledger/fabric/protoutil"
@@ -29,105 +30,157 @@ const (
 )
 
 // TODO: introduced due to a race condition in idemix.
-var commitConfigMutex = &sync.Mutex{}
+// bundleMutex serializes only the creation of channel configuration bundles, across all channels.
+// The validation and the commit of config transactions are serialized per channel by applyLock,
+// therefore a config commit on a channel does not block the commits on the other channels.
+var bundleMutex = &sync.Mutex{}
+
+// newBundle returns the bundle of the passed configuration, with the passed crypto provider.
+// The crypto provider is the one injected in the channel, never the global default one, that could be still uninitialized.
+func newBundle(channelID string, config *common.Config, cryptoProvider bccsp.BCCSP) (*channelconfig.Bundle, error) {
+	if cryptoProvider == nil {
+		return nil, errors.Errorf("no crypto provider for the configuration of channel [%s]", channelID)
+	}
+	bundleMutex.Lock()
+	defer bundleMutex.Unlock()
+	return channelconfig.NewBundle(channelID, config, cryptoProvider)
+}
 
+// ReloadConfigTransactions applies the config transactions stored in the vault, on startup.
+// The bundle of the last one is built from the cache, if it matches the config transaction stored,
+// otherwise all the config transactions are validated again, in sequence, and the cache is rebuilt.
 func (c *channel) ReloadConfigTransactions() error {
+	_, err := c.reloadConfigTransactions()
+	return err
+}
+
+// reloadConfigTransactions returns true if the bundle has been built from the cache
+func (c *channel) reloadConfigTransactions() (bool, error) {
 	c.applyLock.Lock()
 	defer c.applyLock.Unlock()
 
 	qe, err := c.vault.NewQueryExecutor()
 	if err != nil {
-		return errors.WithMessagef(err, "failed getting query executor")
+		return false, errors.WithMessagef(err, "failed getting query executor")
 	}
 	defer qe.Done()
 
 	logger.Infof("looking up the latest config block available")
-	var sequence uint64 = 1
-	for {
+	var last uint64
+	for sequence := uint64(1); ; sequence++ {
 		txID := committer.ConfigTXPrefix + strconv.FormatUint(sequence, 10)
 		vc, err := c.vault.Status(txID)
 		if err != nil {
-			return errors.WithMessagef(err, "failed getting tx's status [%s]", txID)
-		}
-		done := false
-		switch vc {
-		case driver.Valid:
-			logger.Infof("config block available, txID [%s], loading...", txID)
-
-			key, err := rwset.CreateCompositeKey(channelConfigKey, []string{strconv.FormatUint(sequence, 10)})
-			if err != nil {
-				return errors.Wrapf(err, "cannot create configtx rws key")
-			}
-			envelope, err := qe.GetState(peerNamespace, key)
-			if err != nil {
-				return errors.Wrapf(err, "failed setting configtx state in rws")
-			}
-			env, err := protoutil.UnmarshalEnvelope(envelope)
-			if err != nil {
-				return errors.Wrapf(err, "cannot get payload from config transaction [%s]", txID)
-			}
-			payload, err := protoutil.UnmarshalPayload(env.Payload)
-			if err != nil {
-				return errors.Wrapf(err, "cannot get payload from config transaction [%s]", txID)
-			}
-			ctx, err := configtx.UnmarshalConfigEnvelope(payload.Data)
-			if err != nil {
-				return errors.Wrapf(err, "error unmarshalling config which passed initial validity checks [%s]", txID)
-			}
-
-			var bundle *channelconfig.Bundle
-			if c.Resources() == nil {
-				// setup the genesis block
-				bundle, err = channelconfig.NewBundle(c.name, ctx.Config, factory.GetDefault())
-				if err != nil {
-					return errors.Wrapf(err, "failed to build a new bundle")
-				}
-			} else {
-				configTxValidator := c.Resources().ConfigtxValidator()
-				err := configTxValidator.Validate(ctx)
-				if err != nil {
-					return errors.Wrapf(err, "failed to validate config transaction [%s]", txID)
-				}
-
-				bundle, err = channelconfig.NewBundle(configTxValidator.ChannelID(), ctx.Config, factory.GetDefault())
-				if err != nil {
-					return errors.Wrapf(err, "failed to create next bundle")
-				}
-
-				channelconfig.LogSanityChecks(bundle)
-				if err := capabilitiesSupported(bundle); err != nil {
-					return err
-				}
-			}
-
-			c.applyBundle(bundle)
-
-			sequence = sequence + 1
-			continue
-		case driver.Unknown:
-			done = true
-		default:
-			return errors.Errorf("invalid configtx's [%s] status [%d]", txID, vc)
+			return false, errors.WithMessagef(err, "failed getting tx's status [%s]", txID)
 		}
-		if done {
+		if vc == driver.Unknown {
 			break
 		}
+		if vc != driver.Valid {
+			return false, errors.Errorf("invalid configtx's [%s] status [%d]", txID, vc)
+		}
+		last = sequence
 	}
-	if sequence == 1 {
+	if last == 0 {
 		logger.Infof("no config block available, must start from genesis")
 		// no configuration block found
-		return nil
+		return false, nil
 	}
-	logger.Infof("latest config block available at sequence [%d]", sequence-1)
 
-	return nil
+	raw, ctx, err := c.storedConfigTx(qe, last)
+	if err != nil {
+		return false, err
+	}
+	bundle, err := c.cachedBundle(last, raw, ctx)
+	switch {
+	case err != nil:
+		logger.Warnf("[channel: %s] cached config not trusted, validating again all the config transactions: [%s]", c.name, err)
+	case bundle != nil:
+		logger.Infof("latest config block available at sequence [%d], loaded from the cache", last)
+		c.applyBundle(bundle)
+		return true, nil
+	}
+
+	for sequence := uint64(1); sequence <= last; sequence++ {
+		txID := committer.ConfigTXPrefix + strconv.FormatUint(sequence, 10)
+		logger.Infof("config block available, txID [%s], loading...", txID)
+		raw, ctx, err := c.storedConfigTx(qe, sequence)
+		if err != nil {
+			return false, err
+		}
+		bundle, err := c.nextBundle(c.Resources(), ctx)
+		if err != nil {
+			return false, errors.WithMessagef(err, "config transaction [%s]", txID)
+		}
+		c.applyBundle(bundle)
+		if sequence == last {
+			c.cacheBundle(sequence, raw, bundle)
+		}
+	}
+	logger.Infof("latest config block available at sequence [%d]", last)
+
+	return false, nil
 }
 
-// CommitConfig is used to validate and apply configuration transactions for a channel.
-func (c *channel) CommitConfig(blockNumber uint64, raw []byte, env *common.Envelope) error {
-	commitConfigMutex.Lock()
-	defer commitConfigMutex.Unlock()
+// storedConfigTx returns the config transaction with the passed sequence stored in the vault, raw and unmarshalled
+func (c *channel) storedConfigTx(qe driver.QueryExecutor, sequence uint64) ([]byte, *common.ConfigEnvelope, error) {
+	txID := committer.ConfigTXPrefix + strconv.FormatUint(sequence, 10)
+	key, err := rwset.CreateCompositeKey(channelConfigKey, []string{strconv.FormatUint(sequence, 10)})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot create configtx rws key")
+	}
+	envelope, err := qe.GetState(peerNamespace, key)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed getting configtx state [%s]", txID)
+	}
+	env, err := protoutil.UnmarshalEnvelope(envelope)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot get payload from config transaction [%s]", txID)
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot get payload from config transaction [%s]", txID)
+	}
+	ctx, err := configtx.UnmarshalConfigEnvelope(payload.Data)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error unmarshalling config which passed initial validity checks [%s]", txID)
+	}
+	return envelope, ctx, nil
+}
+
+// nextBundle validates the passed config envelope against the passed active configuration, nil for the genesis one,
+// and returns the bundle of the configuration it carries
+func (c *channel) nextBundle(res channelconfig.Resources, envelope *common.ConfigEnvelope) (*channelconfig.Bundle, error) {
+	if envelope.Config == nil || envelope.Config.ChannelGroup == nil {
+		return nil, errors.Errorf("config envelope without channel group")
+	}
+	if res == nil {
+		// setup the genesis block
+		bundle, err := newBundle(c.name, envelope.Config, c.cryptoProvider)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to build a new bundle")
+		}
+		return bundle, nil
+	}
+
+	configTxValidator := res.ConfigtxValidator()
+	if err := configTxValidator.Validate(envelope); err != nil {
+		return nil, errors.Wrapf(err, "failed to validate config transaction")
+	}
+	bundle, err := newBundle(configTxValidator.ChannelID(), envelope.Config, c.cryptoProvider)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create next bundle")
+	}
 
+	channelconfig.LogSanityChecks(bundle)
+	if err := capabilitiesSupported(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// CommitConfig is used to validate and apply configuration transactions for a channel.
+func (c *channel) CommitConfig(blockNumber uint64, indexInBlock int, raw []byte, env *common.Envelope) error {
 	c.applyLock.Lock()
 	defer c.applyLock.Unlock()
 
@@ -137,17 +190,12 @@ func (c *channel) CommitConfig(blockNumber uint64, raw []byte, env *common.Envel
 		return errors.Errorf("channel config found nil")
 	}
 
-	payload, err := protoutil.UnmarshalPayload(env.Payload)
-	if err != nil {
-		return errors.Wrapf(err, "cannot get payload from config transaction, block number [%d]", blockNumber)
-	}
-
-	ctx, err := configtx.UnmarshalConfigEnvelope(payload.Data)
+	tx, err := newConfigTx(blockNumber, indexInBlock, raw, env)
 	if err != nil {
-		return errors.Wrapf(err, "error unmarshalling config which passed initial validity checks")
+		return err
 	}
 
-	txid := committer.ConfigTXPrefix + strconv.FormatUint(ctx.Config.Sequence, 10)
+	txid := committer.ConfigTXPrefix + strconv.FormatUint(tx.sequence(), 10)
 	vc, err := c.vault.Status(txid)
 	if err != nil {
 		return errors.Wrapf(err, "failed getting tx's status [%s]", txid)
@@ -161,49 +209,125 @@ func (c *channel) CommitConfig(blockNumber uint64, raw []byte, env *common.Envel
 		return errors.Errorf("invalid configtx's [%s] status [%d]", txid, vc)
 	}
 
-	var bundle *channelconfig.Bundle
-	if c.Resources() == nil {
-		// setup the genesis block
-		bundle, err = channelconfig.NewBundle(c.name, ctx.Config, factory.GetDefault())
-		if err != nil {
-			return errors.Wrapf(err, "failed to build a new bundle")
-		}
-	} else {
-		configTxValidator := c.Resources().ConfigtxValidator()
-		err := configTxValidator.Validate(ctx)
-		if err != nil {
-			return errors.Wrapf(err, "failed to validate config transaction, block number [%d]", blockNumber)
-		}
-
-		bundle, err = channelconfig.NewBundle(configTxValidator.ChannelID(), ctx.Config, factory.GetDefault())
-		if err != nil {
-			return errors.Wrapf(err, "failed to create next bundle")
-		}
+	return c.configSequence.commit(tx, c.applyConfigTx)
+}
 
-		channelconfig.LogSanityChecks(bundle)
-		if err := capabilitiesSupported(bundle); err != nil {
-			return err
-		}
+// applyConfigTx validates the passed config transaction against the active configuration,
+// commits it to the vault, and makes it the active configuration
+func (c *channel) applyConfigTx(tx *configTx) error {
+	bundle, err := c.nextBundle(c.Resources(), tx.envelope)
+	if err != nil {
+		return errors.WithMessagef(err, "block number [%d]", tx.blockNumber)
 	}
 
-	if err := c.commitConfig(txid, blockNumber, ctx.Config.Sequence, raw); err != nil {
+	txid := committer.ConfigTXPrefix + strconv.FormatUint(tx.sequence(), 10)
+	if err := c.commitConfig(txid, tx.blockNumber, tx.indexInBlock, tx.sequence(), tx.raw); err != nil {
 		return errors.Wrapf(err, "failed committing configtx to the vault")
 	}
 
 	c.applyBundle(bundle)
+	c.cacheBundle(tx.sequence(), tx.raw, bundle)
+	c.sinks.OnConfigUpdate(txid, tx.blockNumber, tx.indexInBlock)
 
 	return nil
 }
 
 // Resources returns the active channel configuration bundle.
+// If the channel has been unloaded, the bundle is built again.
 func (c *channel) Resources() channelconfig.Resources {
+	if err := c.use(); err != nil {
+		logger.Errorf("[channel: %s] failed reloading: [%s]", c.name, err)
+	}
 	c.lock.RLock()
 	res := c.resources
 	c.lock.RUnlock()
 	return res
 }
 
-func (c *channel) commitConfig(txid string, blockNumber uint64, seq uint64, envelope []byte) error {
+// EnvelopeSizeLimit returns the maximum size in bytes of the envelopes broadcast on this channel, 0 if there is none.
+// Unless set by the configuration, it is the AbsoluteMaxBytes of the orderers in the active configuration bundle,
+// therefore it follows the config updates changing the batch size.
+func (c *channel) EnvelopeSizeLimit() uint32 {
+	if c.maxEnvelopeBytes > 0 {
+		return c.maxEnvelopeBytes
+	}
+	res := c.Resources()
+	if res == nil {
+		return 0
+	}
+	oc, ok := res.OrdererConfig()
+	if !ok || oc.BatchSize() == nil {
+		return 0
+	}
+	return oc.BatchSize().AbsoluteMaxBytes
+}
+
+// latencyPriorWeight is the number of transactions the batch timeout counts for in ExpectedOrderingLatency
+const latencyPriorWeight = 5
+
+// OrderingParameters returns the parameters of the orderers in the active configuration bundle,
+// therefore they follow the config updates
+func (c *channel) OrderingParameters() (*driver.OrderingParameters, error) {
+	res := c.Resources()
+	if res == nil {
+		return nil, errors.Errorf("[channel: %s] no configuration applied yet", c.name)
+	}
+	oc, ok := res.OrdererConfig()
+	if !ok {
+		return nil, errors.Errorf("[channel: %s] no orderer configuration", c.name)
+	}
+	params := &driver.OrderingParameters{
+		ConsensusType: oc.ConsensusType(),
+		BatchTimeout:  oc.BatchTimeout(),
+	}
+	if bs := oc.BatchSize(); bs != nil {
+		params.MaxMessageCount = bs.MaxMessageCount
+		params.AbsoluteMaxBytes = bs.AbsoluteMaxBytes
+		params.PreferredMaxBytes = bs.PreferredMaxBytes
+	}
+	if v := res.ConfigtxValidator(); v != nil {
+		params.Sequence = v.Sequence()
+	}
+	return params, nil
+}
+
+// ExpectedOrderingLatency returns a hint of the time a transaction takes from its broadcast to its commit.
+// The batch timeout is the prior, weighing as latencyPriorWeight transactions, combined with the moving average
+// of the latency of the transactions this node broadcast: the more transactions observed, the more the hint
+// follows the observed latency.
+func (c *channel) ExpectedOrderingLatency() (time.Duration, error) {
+	params, err := c.OrderingParameters()
+	if err != nil {
+		return 0, err
+	}
+	if c.committer == nil {
+		return params.BatchTimeout, nil
+	}
+	average, count := c.committer.Latency().Average()
+	if count == 0 {
+		return params.BatchTimeout, nil
+	}
+	n := float64(count)
+	return time.Duration((latencyPriorWeight*float64(params.BatchTimeout) + n*float64(average)) / (latencyPriorWeight + n)), nil
+}
+
+// ObserveBroadcast records the broadcast of the passed transaction, its latency is measured once committed
+func (c *channel) ObserveBroadcast(txID string, sentAt time.Time) {
+	if c.committer != nil {
+		c.committer.Latency().Broadcast(txID, sentAt)
+	}
+}
+
+// fetchBlock fetches the passed block from the ledger of a peer
+func (c *channel) fetchBlock(number uint64) (*common.Block, error) {
+	block, err := c.GetBlockByNumber(number)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed fetching block [%d]", number)
+	}
+	return block.(*Block).Block, nil
+}
+
+func (c *channel) commitConfig(txid string, blockNumber uint64, indexInBlock int, seq uint64, envelope []byte) error {
 	rws, err := c.vault.NewRWSet(txid)
 	if err != nil {
 		return errors.Wrapf(err, "cannot create rws for configtx")
@@ -218,7 +342,7 @@ func (c *channel) commitConfig(txid string, blockNumber uint64, seq uint64, enve
 		return errors.Wrapf(err, "failed setting configtx state in rws")
 	}
 	rws.Done()
-	if err := c.CommitTX(txid, blockNumber, 0, nil); err != nil {
+	if err := c.CommitTX(txid, blockNumber, indexInBlock, nil); err != nil {
 		if err2 := c.DiscardTx(txid); err2 != nil {
 			logger.Errorf("failed committing configtx rws [%s]", err2)
 		}
@@ -227,10 +351,36 @@ func (c *channel) commitConfig(txid string, blockNumber uint64, seq uint64, enve
 	return nil
 }
 
+// applyBundle makes the passed bundle the active configuration, applies it to the network, and publishes
+// driver.ConfigApplied. The calls are serialized by applyLock, the configurations of the channel are applied to the
+// network, and published, in the order of their sequence.
 func (c *channel) applyBundle(bundle *channelconfig.Bundle) {
+	event := c.setBundle(bundle)
+	// the network and the subscribers may access the channel, the configuration is applied once unlocked
+	c.network.onConfigApplied(*event)
+	if err := events.Publish(c.network.bus, *event); err != nil {
+		logger.Warnf("[channel: %s] failed publishing config [%d]: [%s]", c.name, event.Sequence, err)
+	}
+}
+
+func (c *channel) setBundle(bundle *channelconfig.Bundle) *driver.ConfigApplied {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	c.resources = bundle
+	c.configSequence.applied(bundle.ConfigtxValidator().Sequence())
+	c.setBundleBytes(bundle)
+
+	// update the list of peers of the other organizations
+	c.channelPeers = c.extractChannelPeers(bundle)
+	logger.Debugf("[channel: %s] Found (%d) peers of other organizations in channel config", c.name, len(c.channelPeers))
+
+	event := &driver.ConfigApplied{
+		Network:  c.network.Name(),
+		Channel:  c.name,
+		Sequence: bundle.ConfigtxValidator().Sequence(),
+		// the peers joining the channel support its application capabilities
+		ApplicationCapabilities: applicationCapabilities(bundle.ConfigtxValidator().ConfigProto()),
+	}
 
 	// update the list of orderers
 	orderers, any := c.resources.OrdererConfig()
@@ -246,23 +396,26 @@ func (c *channel) applyBundle(bundle *channelconfig.Bundle) {
 			tlsRootCerts = append(tlsRootCerts, msp.GetTLSIntermediateCerts()...)
 			for _, endpoint := range org.Endpoints() {
 				logger.Debugf("[channel: %s] Adding orderer endpoint: [%s:%s:%s]", c.name, org.Name(), org.MSPID(), endpoint)
+				address := c.network.overrideAddress(endpoint)
+				grpc.RecordEndpointSource(address, "config ["+strconv.FormatUint(bundle.ConfigtxValidator().Sequence(), 10)+"] of channel ["+c.name+"]")
 				newOrderers = append(newOrderers, &grpc.ConnectionConfig{
-					Address:           endpoint,
+					Address:           address,
 					ConnectionTimeout: 10 * time.Second,
 					TLSEnabled:        true,
 					TLSRootCertBytes:  tlsRootCerts,
+					Resolution:        c.network.resolution,
 				})
 			}
 		}
 		if len(newOrderers) != 0 {
-			logger.Debugf("[channel: %s] Updating the list of orderers: (%d) found", c.name, len(newOrderers))
-			c.network.setConfigOrderers(newOrderers)
+			event.Orderers = newOrderers
 		} else {
 			logger.Debugf("[channel: %s] No orderers found in channel config", c.name)
 		}
 	} else {
 		logger.Debugf("no orderer configuration found in channel config")
 	}
+	return event
 }
 
 func capabilitiesSupported(res channelconfig.Resources) error {
@@ -271,12 +424,13 @@ func capabilitiesSupported(res channelconfig.Resources) error {
 		return errors.Errorf("[channel %s] does not have application config so is incompatible", res.ConfigtxValidator().ChannelID())
 	}
 
+	// the paths are formatted as the ones of the configtx validator, see configError
 	if err := ac.Capabilities().Supported(); err != nil {
-		return errors.Wrapf(err, "[channel %s] incompatible", res.ConfigtxValidator().ChannelID())
+		return errors.Wrapf(err, "[channel %s] incompatible, capabilities of [Value]  /Channel/Application/Capabilities", res.ConfigtxValidator().ChannelID())
 	}
 
 	if err := res.ChannelConfig().Capabilities().Supported(); err != nil {
-		return errors.Wrapf(err, "[channel %s] incompatible", res.ConfigtxValidator().ChannelID())
+		return errors.Wrapf(err, "[channel %s] incompatible, capabilities of [Value]  /Channel/Capabilities", res.ConfigtxValidator().ChannelID())
 	}
 
 	return nil
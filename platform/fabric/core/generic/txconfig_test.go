@@ -0,0 +1,256 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/ordering"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/rwset"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/policydsl"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResources struct {
+	channelconfig.Resources
+}
+
+func (r *fakeResources) OrdererConfig() (channelconfig.Orderer, bool) {
+	return nil, false
+}
+
+// configTxEnvelope returns the config transaction carrying the passed config envelope, raw and unmarshalled
+func configTxEnvelope(t *testing.T, channel string, configEnv *common.ConfigEnvelope) ([]byte, *common.Envelope) {
+	env := &common.Envelope{Payload: protoMarshal(t, &common.Payload{
+		Header: &common.Header{ChannelHeader: protoMarshal(t, &common.ChannelHeader{Type: int32(common.HeaderType_CONFIG), ChannelId: channel})},
+		Data:   protoMarshal(t, configEnv),
+	})}
+	return protoMarshal(t, env), env
+}
+
+// batchSizeUpdate returns the config update setting the batch size of the orderers to the passed one
+func batchSizeUpdate(t *testing.T, channel string, batchSize *ab.BatchSize) *common.Envelope {
+	update := &common.ConfigUpdate{
+		ChannelId: channel,
+		ReadSet: &common.ConfigGroup{Groups: map[string]*common.ConfigGroup{
+			"Orderer": {},
+		}},
+		WriteSet: &common.ConfigGroup{Groups: map[string]*common.ConfigGroup{
+			"Orderer": {Values: map[string]*common.ConfigValue{
+				"BatchSize": {Version: 1, ModPolicy: "Admins", Value: protoMarshal(t, batchSize)},
+			}},
+		}},
+	}
+	return &common.Envelope{Payload: protoMarshal(t, &common.Payload{
+		Header: &common.Header{ChannelHeader: protoMarshal(t, &common.ChannelHeader{Type: int32(common.HeaderType_CONFIG_UPDATE), ChannelId: channel})},
+		Data:   protoMarshal(t, &common.ConfigUpdateEnvelope{ConfigUpdate: protoMarshal(t, update)}),
+	})}
+}
+
+func TestEnvelopeSizeLimit(t *testing.T) {
+	n, _ := newRemovableNetwork(t)
+	n.processorManager = rwset.NewProcessorManager(n.sp, n, nil)
+	c, err := n.Channel("mychannel")
+	assert.NoError(t, err)
+	ch := c.(*channel)
+	ch.cryptoProvider, err = (&factory.SWFactory{}).Get(factory.GetDefaultOpts())
+	assert.NoError(t, err)
+	ch.configSequence = newConfigSequence(ch.name, nil)
+	// no config applied yet
+	assert.Equal(t, uint32(0), ch.EnvelopeSizeLimit())
+
+	// the genesis config, the orderers accept any update of their values
+	genesis := mspConfigEnvelope(t)
+	genesis.Config.ChannelGroup.Groups["Application"] = &common.ConfigGroup{}
+	genesis.Config.ChannelGroup.Groups["Orderer"].Policies["Admins"] = &common.ConfigPolicy{Policy: &common.Policy{
+		Type:  int32(common.Policy_SIGNATURE),
+		Value: protoMarshal(t, policydsl.AcceptAllPolicy),
+	}}
+	raw, env := configTxEnvelope(t, "mychannel", genesis)
+	assert.NoError(t, ch.CommitConfig(0, 0, raw, env))
+	assert.Equal(t, uint32(1024*1024), ch.EnvelopeSizeLimit())
+
+	// a config update shrinks the batch size
+	configEnv, err := ch.Resources().ConfigtxValidator().ProposeConfigUpdate(batchSizeUpdate(t, "mychannel", &ab.BatchSize{MaxMessageCount: 10, AbsoluteMaxBytes: 4096, PreferredMaxBytes: 2048}))
+	assert.NoError(t, err)
+	raw, env = configTxEnvelope(t, "mychannel", configEnv)
+	assert.NoError(t, ch.CommitConfig(5, 0, raw, env))
+	assert.Equal(t, uint64(1), ch.Resources().ConfigtxValidator().Sequence())
+	assert.Equal(t, uint32(4096), ch.EnvelopeSizeLimit())
+
+	// the broadcast of an envelope within the previous limit is refused against the new one, before reaching the orderers
+	large := &common.Envelope{Payload: protoMarshal(t, &common.Payload{
+		Header: &common.Header{ChannelHeader: protoMarshal(t, &common.ChannelHeader{Type: int32(common.HeaderType_ENDORSER_TRANSACTION), ChannelId: "mychannel", TxId: "tx1"})},
+		Data:   bytes.Repeat([]byte("v"), 8192),
+	})}
+	err = ordering.NewService(n.sp, n).Broadcast(large)
+	tooLarge := &driver.ErrEnvelopeTooLarge{}
+	assert.True(t, errors.As(err, &tooLarge), "expected an envelope too large error, got [%v]", err)
+	assert.Equal(t, "tx1", tooLarge.TxID)
+	assert.Equal(t, "mychannel", tooLarge.Channel)
+	assert.Equal(t, uint32(4096), tooLarge.Limit)
+	assert.Equal(t, len(protoMarshal(t, large)), tooLarge.Size)
+
+	// the configured limit wins over the one of the orderers
+	ch.maxEnvelopeBytes = 16384
+	assert.Equal(t, uint32(16384), ch.EnvelopeSizeLimit())
+}
+
+func TestOrderingParameters(t *testing.T) {
+	csp, err := (&factory.SWFactory{}).Get(factory.GetDefaultOpts())
+	assert.NoError(t, err)
+	c := &channel{name: "mychannel", cryptoProvider: csp}
+	_, err = c.OrderingParameters()
+	assert.EqualError(t, err, "[channel: mychannel] no configuration applied yet")
+
+	bundle, err := c.nextBundle(nil, mspConfigEnvelope(t))
+	assert.NoError(t, err)
+	c.resources = bundle
+	params, err := c.OrderingParameters()
+	assert.NoError(t, err)
+	assert.Equal(t, &driver.OrderingParameters{
+		ConsensusType:     "solo",
+		BatchTimeout:      2 * time.Second,
+		MaxMessageCount:   10,
+		AbsoluteMaxBytes:  1024 * 1024,
+		PreferredMaxBytes: 512 * 1024,
+	}, params)
+	// no transaction observed yet, the hint is the batch timeout
+	latency, err := c.ExpectedOrderingLatency()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, latency)
+
+	// a config update changes the batch timeout
+	envelope := mspConfigEnvelope(t)
+	envelope.Config.ChannelGroup.Groups["Orderer"].Values["BatchTimeout"] = configValue(t, &ab.BatchTimeout{Timeout: "500ms"})
+	bundle, err = c.nextBundle(nil, envelope)
+	assert.NoError(t, err)
+	c.lock.Lock()
+	c.resources = bundle
+	c.lock.Unlock()
+	params, err = c.OrderingParameters()
+	assert.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, params.BatchTimeout)
+
+	// the latency observed is combined with the batch timeout
+	c.committer, err = committer.New("mychannel", nil, nil, 0, false, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		txID := fmt.Sprintf("tx%d", i)
+		c.ObserveBroadcast(txID, time.Now().Add(-1500*time.Millisecond))
+		c.committer.Latency().Committed(txID)
+	}
+	latency, err = c.ExpectedOrderingLatency()
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(time.Second), float64(latency), float64(50*time.Millisecond))
+
+	c.resources = &fakeResources{}
+	_, err = c.OrderingParameters()
+	assert.EqualError(t, err, "[channel: mychannel] no orderer configuration")
+}
+
+// countingCryptoProvider counts the keys imported, the MSPs of the configuration import the keys of their certificates
+type countingCryptoProvider struct {
+	bccsp.BCCSP
+	imported int
+}
+
+func (p *countingCryptoProvider) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error) {
+	p.imported++
+	return p.BCCSP.KeyImport(raw, opts)
+}
+
+func protoMarshal(t *testing.T, m proto.Message) []byte {
+	raw, err := proto.Marshal(m)
+	assert.NoError(t, err)
+	return raw
+}
+
+// mspConfigEnvelope returns the envelope of a configuration made of an orderer organization with a valid MSP
+func mspConfigEnvelope(t *testing.T) *common.ConfigEnvelope {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          []byte{1},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	fabricMSP := &mspproto.FabricMSPConfig{Name: "OrdererMSP", RootCerts: [][]byte{ca}}
+	member := &common.ConfigPolicy{Policy: &common.Policy{Type: int32(common.Policy_SIGNATURE), Value: protoMarshal(t, policydsl.SignedByMspMember("OrdererMSP"))}}
+	org := &common.ConfigGroup{
+		Values:   map[string]*common.ConfigValue{"MSP": configValue(t, &mspproto.MSPConfig{Config: protoMarshal(t, fabricMSP)})},
+		Policies: map[string]*common.ConfigPolicy{"Writers": member},
+	}
+	anyWriters := &common.ConfigPolicy{Policy: &common.Policy{
+		Type:  int32(common.Policy_IMPLICIT_META),
+		Value: protoMarshal(t, &common.ImplicitMetaPolicy{SubPolicy: "Writers", Rule: common.ImplicitMetaPolicy_ANY}),
+	}}
+	return &common.ConfigEnvelope{Config: &common.Config{ChannelGroup: &common.ConfigGroup{
+		Values: map[string]*common.ConfigValue{
+			"HashingAlgorithm":          configValue(t, &common.HashingAlgorithm{Name: "SHA256"}),
+			"BlockDataHashingStructure": configValue(t, &common.BlockDataHashingStructure{Width: math.MaxUint32}),
+			"OrdererAddresses":          configValue(t, &common.OrdererAddresses{Addresses: []string{"orderer.example.com:7050"}}),
+		},
+		Groups: map[string]*common.ConfigGroup{
+			"Orderer": {
+				Groups: map[string]*common.ConfigGroup{"OrdererOrg": org},
+				Values: map[string]*common.ConfigValue{
+					"ConsensusType": configValue(t, &ab.ConsensusType{Type: "solo"}),
+					"BatchSize":     configValue(t, &ab.BatchSize{MaxMessageCount: 10, AbsoluteMaxBytes: 1024 * 1024, PreferredMaxBytes: 512 * 1024}),
+					"BatchTimeout":  configValue(t, &ab.BatchTimeout{Timeout: "2s"}),
+				},
+				Policies: map[string]*common.ConfigPolicy{"Writers": anyWriters, "BlockValidation": anyWriters},
+			},
+		},
+	}}}
+}
+
+func TestBundleCryptoProvider(t *testing.T) {
+	// the global factories are not initialized, the channels do not depend on them
+	c := &channel{name: "mychannel"}
+	_, err := c.nextBundle(nil, mspConfigEnvelope(t))
+	assert.EqualError(t, err, "failed to build a new bundle: no crypto provider for the configuration of channel [mychannel]")
+
+	csp, err := (&factory.SWFactory{}).Get(factory.GetDefaultOpts())
+	assert.NoError(t, err)
+	provider := &countingCryptoProvider{BCCSP: csp}
+	c = &channel{name: "mychannel", cryptoProvider: provider}
+	bundle, err := c.nextBundle(nil, mspConfigEnvelope(t))
+	assert.NoError(t, err)
+	assert.NotNil(t, bundle)
+	assert.NotZero(t, provider.imported)
+}
@@ -7,7 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package generic
 
 import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/transaction"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
 )
 
 func (c *channel) EnvelopeService() driver.EnvelopeService {
@@ -21,3 +23,26 @@ func (c *channel) TransactionService() driver.EndorserTransactionService {
 func (c *channel) MetadataService() driver.MetadataService {
 	return c.metadataService
 }
+
+func (c *channel) ProvenanceService() driver.ProvenanceService {
+	return c.provenanceService
+}
+
+// Enlist returns the bookkeeping stores of this channel writing to the passed unit of work
+func (c *channel) Enlist(uow bookkeeping.Store) *driver.BookkeepingStores {
+	return transaction.Enlist(uow, driver.BookkeepingStores{
+		Envelopes:    c.envelopeService,
+		Transactions: c.transactionService,
+		Metadata:     c.metadataService,
+		Provenance:   c.provenanceService,
+	})
+}
+
+// configSequenceInForce returns the sequence of the active channel config, 0 if none has been applied yet
+func (c *channel) configSequenceInForce() uint64 {
+	res := c.Resources()
+	if res == nil {
+		return 0
+	}
+	return res.ConfigtxValidator().Sequence()
+}
@@ -7,6 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 package generic
 
 import (
+	"os"
+
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
@@ -14,6 +16,7 @@ import (
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/cache/secondcache"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
 	"github.com/pkg/errors"
 )
 
@@ -25,9 +28,18 @@ type TXIDStore interface {
 	fdriver.TXIDStore
 	Get(txid string) (fdriver.ValidationCode, error)
 	Set(txid string, code fdriver.ValidationCode) error
+	GetWithHeight(txid string) (fdriver.ValidationCode, uint64, int, error)
+	SetWithHeight(txid string, code fdriver.ValidationCode, block uint64, txNum int) error
 }
 
 func NewVault(sp view2.ServiceProvider, config *config.Config, channel string) (*vault.Vault, TXIDStore, error) {
+	return openVault(sp, config, "", channel, nil)
+}
+
+// openVault opens the vault of the passed channel. If rejoin is not nil, the channel joins its network again after
+// its removal: the keys a removal interrupted might have left are deleted, and the vault is restored from the archive
+// of the options, if any, see vault.Restore.
+func openVault(sp view2.ServiceProvider, config *config.Config, network, channel string, rejoin *fdriver.RejoinOptions) (*vault.Vault, TXIDStore, error) {
 	pType := config.VaultPersistenceType()
 	if pType == "file" {
 		// for retro compatibility
@@ -37,6 +49,18 @@ func NewVault(sp view2.ServiceProvider, config *config.Config, channel string) (
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "failed creating vault")
 	}
+	if rejoin != nil {
+		if err := rejoinVault(persistence, network, channel, rejoin.Archive); err != nil {
+			if err1 := persistence.Close(); err1 != nil {
+				logger.Warnf("failed closing the vault of channel [%s]: %s", channel, err1)
+			}
+			return nil, nil, err
+		}
+	}
+
+	if err := vault.OpenDataFormat(network, channel, persistence, config.VaultMigrationBackupPath()); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed opening vault")
+	}
 
 	var txidStore TXIDStore
 	txidStore, err = txidstore.NewTXIDStore(db.Unversioned(persistence))
@@ -54,5 +78,39 @@ func NewVault(sp view2.ServiceProvider, config *config.Config, channel string) (
 		txidStore = txidstore.NewCache(txidStore, secondcache.New(txIDStoreCacheSize))
 	}
 
-	return vault.New(persistence, txidStore), txidStore, nil
+	v := vault.New(persistence, txidStore)
+	historyNamespaces, err := config.VaultHistoryNamespaces()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed loading the namespaces with history from configuration")
+	}
+	// the history of the namespaces no longer configured is dropped
+	if err := v.SetHistoryNamespaces(historyNamespaces...); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed setting the namespaces with history")
+	}
+	quotas, err := config.VaultQuotas()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed loading the quotas of the namespaces from configuration")
+	}
+	if err := v.SetNamespaceQuotas(quotas); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed setting the quotas of the namespaces")
+	}
+	return v, txidStore, nil
+}
+
+func rejoinVault(persistence driver.VersionedPersistence, network, channel, archive string) error {
+	if err := vault.PurgeStore(persistence); err != nil {
+		return errors.WithMessagef(err, "failed deleting the vault left by the removal of channel [%s:%s]", network, channel)
+	}
+	if len(archive) == 0 {
+		return nil
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return errors.Wrapf(err, "failed opening vault archive")
+	}
+	defer f.Close()
+	if _, err := vault.Restore(persistence, f, network, channel); err != nil {
+		return errors.WithMessagef(err, "failed restoring the vault of channel [%s:%s] from [%s]", network, channel, archive)
+	}
+	return nil
 }
@@ -0,0 +1,271 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ArchiveFormat identifies the archives of the vaults, see Archive
+	ArchiveFormat = "fsc-vault-archive"
+	// ArchiveVersion is the version of the archives written by this vault
+	ArchiveVersion = 1
+
+	// archiveBatchSize is the number of keys restored or purged per update of the store
+	archiveBatchSize = 1000
+)
+
+// ArchiveHeader is the first line of an archive, the entries follow one per line
+type ArchiveHeader struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Network string    `json:"network"`
+	Channel string    `json:"channel"`
+	Height  uint64    `json:"height"`
+	Created time.Time `json:"created"`
+}
+
+// archiveEntry is a key of the store, with its version and metadata
+type archiveEntry struct {
+	Namespace string            `json:"ns"`
+	Key       string            `json:"key"`
+	Value     []byte            `json:"value,omitempty"`
+	Meta      map[string][]byte `json:"meta,omitempty"`
+	Block     uint64            `json:"block"`
+	TxNum     uint64            `json:"txNum"`
+}
+
+// Archive writes to the passed writer all the namespaces of the store, the reserved ones included, as gzipped JSON
+// lines: an ArchiveHeader, then one entry per key. The archive is portable across the persistence types,
+// see Restore. The store must be able to list its namespaces.
+func (db *Vault) Archive(w io.Writer, network, channel string) error {
+	lister, ok := db.store.(driver.NamespaceLister)
+	if !ok {
+		return errors.New("the vault store does not support listing its namespaces")
+	}
+	db.readLockStore()
+	defer db.storeLock.RUnlock()
+
+	namespaces, err := lister.Namespaces()
+	if err != nil {
+		return errors.WithMessagef(err, "failed listing the namespaces of the vault")
+	}
+	header := &ArchiveHeader{Format: ArchiveFormat, Version: ArchiveVersion, Network: network, Channel: channel, Created: time.Now()}
+	if header.Height, err = db.height(); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	if err := enc.Encode(header); err != nil {
+		return errors.Wrapf(err, "failed writing the archive header")
+	}
+	entries := 0
+	for _, ns := range namespaces {
+		reads, err := db.scan(ns, "", "")
+		if err != nil {
+			return err
+		}
+		for _, read := range reads {
+			meta, _, _, err := db.store.GetStateMetadata(ns, read.Key)
+			if err != nil {
+				return errors.Wrapf(err, "failed retrieving the metadata of [%s:%s]", ns, read.Key)
+			}
+			entry := &archiveEntry{Namespace: ns, Key: read.Key, Value: read.Raw, Meta: meta, Block: read.Block, TxNum: uint64(read.IndexInBlock)}
+			if err := enc.Encode(entry); err != nil {
+				return errors.Wrapf(err, "failed archiving [%s:%s]", ns, read.Key)
+			}
+			entries++
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return errors.Wrapf(err, "failed closing the archive")
+	}
+	logger.Infof("archived vault of channel [%s:%s]: [%d] keys in [%d] namespaces at height [%d]", network, channel, entries, len(namespaces), header.Height)
+	return nil
+}
+
+// Purge deletes all the keys of all the namespaces of the store, the reserved ones included.
+// The commits are paused meanwhile, the vault is meant to be closed afterwards.
+func (db *Vault) Purge() error {
+	db.BeginBlockCommit()
+	defer db.EndBlockCommit()
+
+	db.lockStore()
+	defer db.unlockStore()
+
+	return PurgeStore(db.store)
+}
+
+// Restore writes the passed archive of the vault of the passed channel to the passed store, which must be empty.
+// It returns the header of the archive. If the restore fails, the keys restored so far are deleted.
+func Restore(store driver.VersionedPersistence, r io.Reader, network, channel string) (*ArchiveHeader, error) {
+	lister, ok := store.(driver.NamespaceLister)
+	if !ok {
+		return nil, errors.New("the vault store does not support listing its namespaces")
+	}
+	namespaces, err := lister.Namespaces()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed listing the namespaces of the vault")
+	}
+	if len(namespaces) != 0 {
+		return nil, errors.Errorf("cannot restore to a vault not empty, it holds [%d] namespaces", len(namespaces))
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid archive")
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(bufio.NewReader(zr))
+	header, err := readArchiveHeader(dec, network, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := restoreEntries(store, dec)
+	if err != nil {
+		if err1 := PurgeStore(store); err1 != nil {
+			logger.Errorf("failed deleting the keys of the restore failed: %s", err1)
+		}
+		return nil, err
+	}
+	logger.Infof("restored vault of channel [%s:%s]: [%d] keys at height [%d], archived at [%s]", network, channel, entries, header.Height, header.Created)
+	return header, nil
+}
+
+// readArchiveHeader reads the header of an archive, which must be of the passed channel
+func readArchiveHeader(dec *json.Decoder, network, channel string) (*ArchiveHeader, error) {
+	header := &ArchiveHeader{}
+	if err := dec.Decode(header); err != nil {
+		return nil, errors.Wrapf(err, "invalid archive header")
+	}
+	switch {
+	case header.Format != ArchiveFormat:
+		return nil, errors.Errorf("invalid archive format [%s], expected [%s]", header.Format, ArchiveFormat)
+	case header.Version > ArchiveVersion:
+		return nil, errors.Errorf("archive version [%d] not supported, the latest supported is [%d]", header.Version, ArchiveVersion)
+	case header.Network != network || header.Channel != channel:
+		return nil, errors.Errorf("the archive is of channel [%s:%s], not of [%s:%s]", header.Network, header.Channel, network, channel)
+	}
+	return header, nil
+}
+
+func restoreEntries(store driver.VersionedPersistence, dec *json.Decoder) (int, error) {
+	entries, inUpdate := 0, false
+	for {
+		entry := &archiveEntry{}
+		err := dec.Decode(entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if inUpdate {
+				discard(store)
+			}
+			return entries, errors.Wrapf(err, "invalid archive entry after [%d] entries", entries)
+		}
+		if !inUpdate {
+			if err := store.BeginUpdate(); err != nil {
+				return entries, errors.WithMessagef(err, "begin update failed")
+			}
+			inUpdate = true
+		}
+		if err := store.SetState(entry.Namespace, entry.Key, entry.Value, entry.Block, entry.TxNum); err != nil {
+			discard(store)
+			return entries, errors.Wrapf(err, "failed restoring [%s:%s]", entry.Namespace, entry.Key)
+		}
+		if len(entry.Meta) != 0 {
+			if err := store.SetStateMetadata(entry.Namespace, entry.Key, entry.Meta, entry.Block, entry.TxNum); err != nil {
+				discard(store)
+				return entries, errors.Wrapf(err, "failed restoring the metadata of [%s:%s]", entry.Namespace, entry.Key)
+			}
+		}
+		entries++
+		if entries%archiveBatchSize == 0 {
+			if err := store.Commit(); err != nil {
+				return entries, errors.WithMessagef(err, "committing the restored keys failed")
+			}
+			inUpdate = false
+		}
+	}
+	if inUpdate {
+		if err := store.Commit(); err != nil {
+			return entries, errors.WithMessagef(err, "committing the restored keys failed")
+		}
+	}
+	return entries, nil
+}
+
+// PurgeStore deletes all the keys of the passed store, a batch of keys per update. The store must be able to list
+// its namespaces.
+func PurgeStore(store driver.VersionedPersistence) error {
+	lister, ok := store.(driver.NamespaceLister)
+	if !ok {
+		return errors.New("the vault store does not support listing its namespaces")
+	}
+	namespaces, err := lister.Namespaces()
+	if err != nil {
+		return errors.WithMessagef(err, "failed listing the namespaces of the vault")
+	}
+	var keys [][2]string
+	for _, ns := range namespaces {
+		it, err := store.GetStateRangeScanIterator(ns, "", "")
+		if err != nil {
+			return errors.WithMessagef(err, "failed scanning namespace [%s]", ns)
+		}
+		for {
+			read, err := it.Next()
+			if err != nil {
+				it.Close()
+				return errors.WithMessagef(err, "failed scanning namespace [%s]", ns)
+			}
+			if read == nil {
+				break
+			}
+			keys = append(keys, [2]string{ns, read.Key})
+		}
+		it.Close()
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	for start := 0; start < len(keys); start += archiveBatchSize {
+		end := start + archiveBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := store.BeginUpdate(); err != nil {
+			return errors.WithMessagef(err, "begin update failed")
+		}
+		for _, k := range keys[start:end] {
+			if err := store.DeleteState(k[0], k[1]); err != nil {
+				discard(store)
+				return errors.Wrapf(err, "failed deleting [%s:%s]", k[0], k[1])
+			}
+		}
+		if err := store.Commit(); err != nil {
+			return errors.WithMessagef(err, "committing the deletion of [%d] keys failed", end-start)
+		}
+	}
+	logger.Infof("purged [%d] keys in [%d] namespaces", len(keys), len(namespaces))
+	return nil
+}
+
+func discard(store driver.VersionedPersistence) {
+	if err := store.Discard(); err != nil {
+		logger.Errorf("discarding failed: %s", err)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveRestore(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	rws, err := vault.NewRWSet("tx2")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("assets", "c", []byte("3")))
+	assert.NoError(t, rws.SetStateMetadata("assets", "c", map[string][]byte{"owner": []byte("alice")}))
+	rws.Done()
+	assert.NoError(t, vault.CommitTX("tx2", 2, 1))
+	assert.NoError(t, vault.SetHeight(2))
+	expected, err := vault.VerifyIntegrity()
+	assert.NoError(t, err)
+
+	archive := &bytes.Buffer{}
+	assert.NoError(t, vault.Archive(archive, "default", "mychannel"))
+
+	// once purged, nothing is left
+	assert.NoError(t, vault.Purge())
+	namespaces, err := ddb.(driver.NamespaceLister).Namespaces()
+	assert.NoError(t, err)
+	assert.Empty(t, namespaces)
+	v, _, _, err := ddb.GetState("assets", "a")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// the archive is restored to the vault of the same channel only, and to an empty vault only
+	_, err = Restore(ddb, bytes.NewReader(archive.Bytes()), "default", "otherchannel")
+	assert.EqualError(t, err, "the archive is of channel [default:mychannel], not of [default:otherchannel]")
+	_, err = Restore(ddb, bytes.NewReader([]byte("garbage")), "default", "mychannel")
+	assert.Error(t, err)
+	other, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, other.BeginUpdate())
+	assert.NoError(t, other.SetState("assets", "z", []byte("0"), 1, 0))
+	assert.NoError(t, other.Commit())
+	_, err = Restore(other, bytes.NewReader(archive.Bytes()), "default", "mychannel")
+	assert.EqualError(t, err, "cannot restore to a vault not empty, it holds [1] namespaces")
+
+	header, err := Restore(ddb, bytes.NewReader(archive.Bytes()), "default", "mychannel")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), header.Height)
+	assert.Equal(t, ArchiveVersion, header.Version)
+
+	// the states, the metadata, the statuses and the checksums are back
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	restored := New(ddb, tidstore)
+	height, err := restored.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), height)
+	code, err := restored.Status("tx2")
+	assert.NoError(t, err)
+	assert.Equal(t, fdriver.Valid, code)
+	qe, err := restored.NewQueryExecutor()
+	assert.NoError(t, err)
+	v, err = qe.GetState("assets", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), v)
+	meta, block, txNum, err := qe.GetStateMetadata("assets", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"owner": []byte("alice")}, meta)
+	assert.Equal(t, uint64(2), block)
+	assert.Equal(t, uint64(1), txNum)
+	qe.Done()
+	report, err := restored.VerifyIntegrity()
+	assert.NoError(t, err)
+	assert.Empty(t, report.Failed)
+	assert.Equal(t, expected.Namespaces, report.Namespaces)
+}
@@ -0,0 +1,339 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxPauseDuration is the maximum amount of time commits stay paused
+	// if the resume function returned by PauseCommits is never called.
+	DefaultMaxPauseDuration = 5 * time.Minute
+
+	// reservedPrefix prefixes the namespaces the vault keeps its own data in.
+	// The chaincode names cannot start with it, the namespaces of the chaincodes never clash with them.
+	reservedPrefix = "_"
+	// vaultNamespace is the namespace of the records of the vault: its height, its data format, the tombstones
+	// of the private keys purged and the index of the private keys by hash
+	vaultNamespace = reservedPrefix + "vault"
+	heightKey      = "height"
+)
+
+// SnapshotHook is invoked by PauseCommits once all in-flight block commits, and store updates, have been drained.
+// While the hook runs, the vault store is not modified, neither by the block commits nor by the local updates,
+// like the local commits and the transactions marked busy or discarded, therefore it is safe to take a snapshot of it.
+// The passed height is the number of the block of the last transaction committed in the vault, see Vault#Height:
+// as the in-flight block commits have been drained, that block is fully committed unless its commit failed.
+// If the hook returns an error, the commits are resumed and PauseCommits returns the error.
+type SnapshotHook func(height uint64) error
+
+// commitGate coordinates block commits, and the other updates of the store, with backups.
+type commitGate struct {
+	lock     sync.Mutex
+	inFlight int
+	// resumed is not nil while the commits are paused, it gets closed on resume
+	resumed chan struct{}
+	// drained gets closed when the commits are paused and there are no more block commits in flight
+	drained chan struct{}
+
+	// updates is the number of store updates in progress, see lockUpdates
+	updates int
+	// frozen is not nil while the snapshot hooks run, it gets closed once they have returned
+	frozen chan struct{}
+	// updated gets closed when the store is frozen and there are no more store updates in progress
+	updated chan struct{}
+
+	hooksLock sync.RWMutex
+	hooks     []SnapshotHook
+
+	maxPause time.Duration
+}
+
+// AddSnapshotHook registers a hook that is invoked every time it is safe to snapshot the vault
+func (db *Vault) AddSnapshotHook(hook SnapshotHook) {
+	db.gate.hooksLock.Lock()
+	defer db.gate.hooksLock.Unlock()
+	db.gate.hooks = append(db.gate.hooks, hook)
+}
+
+// SetMaxPauseDuration sets the maximum amount of time the commits stay paused.
+// If d is not positive, DefaultMaxPauseDuration is used.
+func (db *Vault) SetMaxPauseDuration(d time.Duration) {
+	db.gate.lock.Lock()
+	defer db.gate.lock.Unlock()
+	db.gate.maxPause = d
+}
+
+// BeginBlockCommit must be called before committing the transactions of a block.
+// It blocks while the commits are paused.
+func (db *Vault) BeginBlockCommit() {
+	for {
+		db.gate.lock.Lock()
+		resumed := db.gate.resumed
+		if resumed == nil {
+			db.gate.inFlight++
+			db.gate.lock.Unlock()
+			return
+		}
+		db.gate.lock.Unlock()
+
+		logger.Debugf("commits are paused, wait...")
+		<-resumed
+	}
+}
+
+// EndBlockCommit must be called once the transactions of a block, whose commit was started with BeginBlockCommit,
+// have been processed.
+func (db *Vault) EndBlockCommit() {
+	db.gate.lock.Lock()
+	defer db.gate.lock.Unlock()
+
+	db.gate.inFlight--
+	if db.gate.inFlight == 0 {
+		// the blocks are fully committed, their changes can be sent
+		db.watches.flush()
+	}
+	if db.gate.inFlight == 0 && db.gate.drained != nil {
+		close(db.gate.drained)
+		db.gate.drained = nil
+	}
+}
+
+// PauseCommits waits for the in-flight block commits to complete and blocks new ones.
+// Then it waits for the store updates in progress to complete, and blocks new ones while the snapshot hooks are
+// invoked: once the hooks return, the store updates out of the block commits go on.
+// The commits stay paused until the returned resume function is called, or the max pause duration, counted from
+// the return of the hooks, expires.
+// If the context is done before the in-flight commits, or updates, complete, the commits are resumed and an error
+// is returned.
+func (db *Vault) PauseCommits(ctx context.Context) (func(), error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	db.gate.lock.Lock()
+	if db.gate.resumed != nil {
+		db.gate.lock.Unlock()
+		return nil, errors.New("commits are already paused")
+	}
+	resumed := make(chan struct{})
+	drained := make(chan struct{})
+	db.gate.resumed = resumed
+	if db.gate.inFlight == 0 {
+		close(drained)
+	} else {
+		db.gate.drained = drained
+	}
+	maxPause := db.gate.maxPause
+	if maxPause <= 0 {
+		maxPause = DefaultMaxPauseDuration
+	}
+	db.gate.lock.Unlock()
+
+	var once sync.Once
+	resume := func() {
+		once.Do(func() {
+			db.gate.lock.Lock()
+			defer db.gate.lock.Unlock()
+			db.gate.resumed = nil
+			db.gate.drained = nil
+			close(resumed)
+		})
+	}
+
+	logger.Debugf("pausing commits, wait for in-flight commits to complete...")
+	select {
+	case <-ctx.Done():
+		resume()
+		return nil, errors.Wrapf(ctx.Err(), "failed draining in-flight commits")
+	case <-drained:
+	}
+
+	thaw, err := db.freezeStore(ctx)
+	if err != nil {
+		resume()
+		return nil, err
+	}
+	height, err := db.Height()
+	if err != nil {
+		thaw()
+		resume()
+		return nil, errors.WithMessagef(err, "failed getting vault height")
+	}
+	// the hooks run with the commits paused, however long they take
+	db.gate.hooksLock.RLock()
+	hooks := db.gate.hooks
+	db.gate.hooksLock.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(height); err != nil {
+			thaw()
+			resume()
+			return nil, errors.WithMessagef(err, "snapshot hook failed at height [%d]", height)
+		}
+	}
+	thaw()
+
+	timer := time.AfterFunc(maxPause, func() {
+		logger.Warnf("commits paused for more than [%s], resume them", maxPause)
+		resume()
+	})
+	logger.Debugf("commits paused at height [%d]", height)
+	return func() {
+		timer.Stop()
+		resume()
+	}, nil
+}
+
+// freezeStore waits for the store updates in progress to complete and blocks new ones until the returned thaw
+// function is called. If the context is done before the updates complete, the store is thawed and an error is returned.
+func (db *Vault) freezeStore(ctx context.Context) (func(), error) {
+	db.gate.lock.Lock()
+	frozen := make(chan struct{})
+	updated := make(chan struct{})
+	db.gate.frozen = frozen
+	if db.gate.updates == 0 {
+		close(updated)
+	} else {
+		db.gate.updated = updated
+	}
+	db.gate.lock.Unlock()
+
+	thaw := func() {
+		db.gate.lock.Lock()
+		defer db.gate.lock.Unlock()
+		db.gate.frozen = nil
+		db.gate.updated = nil
+		close(frozen)
+	}
+
+	logger.Debugf("freezing the store, wait for the updates in progress to complete...")
+	select {
+	case <-ctx.Done():
+		thaw()
+		return nil, errors.Wrapf(ctx.Err(), "failed draining store updates")
+	case <-updated:
+	}
+	return thaw, nil
+}
+
+// lockUpdates must be called before updating the store, and before taking db.storeLock: it blocks while the
+// snapshot hooks run. unlockUpdates must be called once the update is committed, or discarded.
+func (db *Vault) lockUpdates() {
+	for {
+		db.gate.lock.Lock()
+		frozen := db.gate.frozen
+		if frozen == nil {
+			db.gate.updates++
+			db.gate.lock.Unlock()
+			return
+		}
+		db.gate.lock.Unlock()
+
+		logger.Debugf("the store is frozen, wait...")
+		<-frozen
+	}
+}
+
+// unlockUpdates ends a store update started with lockUpdates
+func (db *Vault) unlockUpdates() {
+	db.gate.lock.Lock()
+	defer db.gate.lock.Unlock()
+
+	db.gate.updates--
+	if db.gate.updates == 0 && db.gate.updated != nil {
+		close(db.gate.updated)
+		db.gate.updated = nil
+	}
+}
+
+// SetHeight records the passed block as the height of the vault, unless the height is past it already.
+// The height of a block is recorded already, in the same update, by the commits of its transactions:
+// SetHeight writes only the height of the blocks none of whose transactions the vault stores.
+func (db *Vault) SetHeight(block uint64) error {
+	db.lockStore()
+	defer db.unlockStore()
+
+	height, err := db.height()
+	if err != nil {
+		return err
+	}
+	if block <= height {
+		return nil
+	}
+	if err := db.store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update for height [%d] failed", block)
+	}
+	if err := db.store.SetState(vaultNamespace, heightKey, encodeHeight(block), block, 0); err != nil {
+		if err1 := db.store.Discard(); err1 != nil {
+			logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+		}
+		return errors.Wrapf(err, "failed storing height [%d]", block)
+	}
+	if err := db.store.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing height [%d] failed", block)
+	}
+	return nil
+}
+
+// Height returns the number of the block of the last transaction committed in the vault, or zero if none has
+// been recorded. The height is recorded with the commit of the first transaction of a block, see advanceHeight,
+// therefore the block at the height might be committed only in part: after a crash, the delivery restarts from
+// the last transaction known by the vault and the rest of the block is committed again.
+func (db *Vault) Height() (uint64, error) {
+	db.storeLock.RLock()
+	defer db.storeLock.RUnlock()
+
+	return db.height()
+}
+
+// advanceHeight records, in the update in progress, the passed block as the height of the vault, unless the block
+// is unknown or the height is past it already. db.storeLock must be held.
+func (db *Vault) advanceHeight(block uint64) error {
+	if block == fdriver.UnknownBlock {
+		return nil
+	}
+	height, err := db.height()
+	if err != nil {
+		return err
+	}
+	if block <= height {
+		return nil
+	}
+	if err := db.store.SetState(vaultNamespace, heightKey, encodeHeight(block), block, 0); err != nil {
+		return errors.Wrapf(err, "failed storing height [%d]", block)
+	}
+	return nil
+}
+
+// height returns the height of the vault. The height recorded by the previous versions is moved on opening,
+// see OpenDataFormat. db.storeLock must be held.
+func (db *Vault) height() (uint64, error) {
+	heightBytes, _, _, err := db.store.GetState(vaultNamespace, heightKey)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed retrieving height")
+	}
+	if len(heightBytes) == 0 {
+		return 0, nil
+	}
+	if len(heightBytes) < 8 {
+		return 0, errors.Errorf("invalid height [%x]", heightBytes)
+	}
+	return binary.BigEndian.Uint64(heightBytes), nil
+}
+
+func encodeHeight(block uint64) []byte {
+	heightBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(heightBytes, block)
+	return heightBytes
+}
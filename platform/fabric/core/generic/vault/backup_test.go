@@ -0,0 +1,264 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBackupVault(t *testing.T) (*Vault, driver.VersionedPersistence) {
+	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	return New(ddb, tidstore), ddb
+}
+
+// commitBlock simulates the committer: each block carries a single transaction writing its number
+func commitBlock(t *testing.T, vault *Vault, block uint64) {
+	vault.BeginBlockCommit()
+	defer vault.EndBlockCommit()
+
+	txid := fmt.Sprintf("tx%d", block)
+	rws, err := vault.NewRWSet(txid)
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("ns", "key", []byte(txid)))
+	rws.Done()
+	// widen the window in which a snapshot could see a partial commit
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, vault.CommitTX(txid, block, 0))
+	assert.NoError(t, vault.SetHeight(block))
+}
+
+func TestPauseCommitsDuringActiveCommits(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+
+	var snapshots []uint64
+	vault.AddSnapshotHook(func(height uint64) error {
+		// the vault is quiet: the last write matches the height
+		v, _, _, err := ddb.GetState("ns", "key")
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("tx%d", height), string(v))
+		snapshots = append(snapshots, height)
+		return nil
+	})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for block := uint64(1); ; block++ {
+			select {
+			case <-stop:
+				return
+			default:
+				commitBlock(t, vault, block)
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+		resume, err := vault.PauseCommits(context.Background())
+		assert.NoError(t, err)
+
+		// no commit goes through while paused
+		height, err := vault.Height()
+		assert.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		current, err := vault.Height()
+		assert.NoError(t, err)
+		assert.Equal(t, height, current)
+
+		_, err = vault.PauseCommits(context.Background())
+		assert.Error(t, err, "commits are already paused")
+		resume()
+		// resume is idempotent
+		resume()
+	}
+	close(stop)
+	wg.Wait()
+
+	assert.Len(t, snapshots, 5)
+	for i := 1; i < len(snapshots); i++ {
+		assert.True(t, snapshots[i] > snapshots[i-1], "commits must be resumed between backups")
+	}
+}
+
+func TestPauseCommitsTimeout(t *testing.T) {
+	vault, _ := newBackupVault(t)
+
+	// a block commit that never completes
+	vault.BeginBlockCommit()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := vault.PauseCommits(ctx)
+	assert.Error(t, err)
+	vault.EndBlockCommit()
+
+	// commits are not left paused
+	commitBlock(t, vault, 1)
+	height, err := vault.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), height)
+
+	// a failing hook resumes the commits
+	vault.AddSnapshotHook(func(height uint64) error {
+		return fmt.Errorf("disk full")
+	})
+	_, err = vault.PauseCommits(context.Background())
+	assert.Error(t, err)
+	commitBlock(t, vault, 2)
+}
+
+func TestPauseCommitsMaxDuration(t *testing.T) {
+	vault, _ := newBackupVault(t)
+	vault.SetMaxPauseDuration(50 * time.Millisecond)
+
+	_, err := vault.PauseCommits(context.Background())
+	assert.NoError(t, err)
+
+	// the resume function is never called, commits resume after the max pause duration
+	done := make(chan struct{})
+	go func() {
+		commitBlock(t, vault, 1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("commits not resumed after the max pause duration")
+	}
+}
+
+func TestPauseCommitsSlowHook(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+	vault.SetMaxPauseDuration(20 * time.Millisecond)
+
+	// a hook running longer than the max pause duration, while a block commit waits
+	done := make(chan struct{})
+	vault.AddSnapshotHook(func(height uint64) error {
+		go func() {
+			commitBlock(t, vault, 1)
+			close(done)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		v, _, _, err := ddb.GetState("ns", "key")
+		assert.NoError(t, err)
+		assert.Nil(t, v, "the block has been committed while the hook was running")
+		return nil
+	})
+	resume, err := vault.PauseCommits(context.Background())
+	assert.NoError(t, err)
+
+	// the max pause duration is counted from the return of the hook
+	select {
+	case <-done:
+		t.Fatal("commits resumed as soon as the hook returned")
+	case <-time.After(5 * time.Millisecond):
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("commits not resumed after the max pause duration")
+	}
+	resume()
+}
+
+func TestPauseCommitsDuringLocalCommit(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+	commitBlock(t, vault, 1)
+
+	// a local transaction, like a schema migration, is ready to commit
+	rws, err := vault.NewRWSet("migration")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("ns", "schema", []byte("v2")))
+	rws.Done()
+
+	committed := make(chan struct{})
+	vault.AddSnapshotHook(func(height uint64) error {
+		go func() {
+			assert.NoError(t, vault.CommitLocalTX("migration"))
+			close(committed)
+		}()
+		// the local commit waits for the hook to return
+		time.Sleep(50 * time.Millisecond)
+		v, _, _, err := ddb.GetState("ns", "schema")
+		assert.NoError(t, err)
+		assert.Nil(t, v, "the local transaction has been committed while the hook was running")
+		code, err := vault.Status("migration")
+		assert.NoError(t, err)
+		assert.Equal(t, fdriver.Busy, code)
+		return nil
+	})
+	resume, err := vault.PauseCommits(context.Background())
+	assert.NoError(t, err)
+	defer resume()
+
+	// the local commits go on while the block commits are paused
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("local commit not completed once the hook returned")
+	}
+	v, _, _, err := ddb.GetState("ns", "schema")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(v))
+}
+
+func TestPauseCommitsDrainsUpdates(t *testing.T) {
+	vault, _ := newBackupVault(t)
+
+	// a store update in progress
+	vault.lockUpdates()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := vault.PauseCommits(ctx)
+	assert.Error(t, err)
+	vault.unlockUpdates()
+
+	// neither the store nor the commits are left frozen
+	assert.NoError(t, vault.SetBusy("tx1"))
+	commitBlock(t, vault, 1)
+	resume, err := vault.PauseCommits(context.Background())
+	assert.NoError(t, err)
+	resume()
+}
+
+func TestHeight(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+
+	// the height is recorded with the commit of the transaction, before SetHeight
+	rws, err := vault.NewRWSet("tx1")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("ns", "key", []byte("tx1")))
+	rws.Done()
+	assert.NoError(t, vault.CommitTX("tx1", 3, 0))
+	height, err := vault.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), height)
+	raw, _, _, err := ddb.GetState(vaultNamespace, heightKey)
+	assert.NoError(t, err)
+	assert.Len(t, raw, 8)
+
+	// the height does not go back
+	assert.NoError(t, vault.SetHeight(2))
+	height, err = vault.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), height)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	benchKeys        = 1000
+	benchReadsPerSec = 1000
+	// benchHold is how long a view keeps its query executor after the read
+	benchHold = time.Millisecond
+)
+
+// BenchmarkPointReadsDuringCommits issues 1000 point reads per second, each with its own query executor kept
+// by the view for a millisecond, while blocks are committed back to back. The reads go through the lock of the vault,
+// as before the snapshots, or through snapshots of the store. It reports the latency of the reads, until the value
+// is returned, and the blocks committed per second.
+func BenchmarkPointReadsDuringCommits(b *testing.B) {
+	b.Run("locked", func(b *testing.B) { benchmarkPointReadsDuringCommits(b, "locked", true) })
+	b.Run("snapshot", func(b *testing.B) { benchmarkPointReadsDuringCommits(b, "snapshot", false) })
+}
+
+func benchmarkPointReadsDuringCommits(b *testing.B, name string, locked bool) {
+	ns := "namespace"
+	vault, ddb := openBadgerVault(b, fmt.Sprintf("DB-BenchmarkPointReads-%s-%d", name, b.N), locked)
+	defer ddb.Close()
+	assertNoError := func(err error) {
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	assertNoError(ddb.BeginUpdate())
+	for i := 0; i < benchKeys; i++ {
+		assertNoError(ddb.SetState(ns, fmt.Sprintf("key%d", i), []byte("value"), 1, uint64(i)))
+	}
+	assertNoError(ddb.Commit())
+
+	// the committer commits blocks of 10 transactions, each writing 10 keys
+	stop := make(chan struct{})
+	var blocks int64
+	var committer sync.WaitGroup
+	committer.Add(1)
+	go func() {
+		defer committer.Done()
+		for block := uint64(2); ; block++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for tx := 0; tx < 10; tx++ {
+				txid := fmt.Sprintf("tx-%d-%d", block, tx)
+				rws, err := vault.NewRWSet(txid)
+				assertNoError(err)
+				for k := 0; k < 10; k++ {
+					assertNoError(rws.SetState(ns, fmt.Sprintf("key%d", (int(block)*100+tx*10+k)%benchKeys), []byte(txid)))
+				}
+				rws.Done()
+				assertNoError(vault.CommitTX(txid, block, tx))
+			}
+			atomic.AddInt64(&blocks, 1)
+		}
+	}()
+
+	latencies := make([]time.Duration, b.N)
+	var readers sync.WaitGroup
+	ticker := time.NewTicker(time.Second / benchReadsPerSec)
+	defer ticker.Stop()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		<-ticker.C
+		readers.Add(1)
+		go func(i int) {
+			defer readers.Done()
+			begin := time.Now()
+			qe, err := vault.NewQueryExecutor()
+			assertNoError(err)
+			_, err = qe.GetState(ns, fmt.Sprintf("key%d", i%benchKeys))
+			assertNoError(err)
+			latencies[i] = time.Since(begin)
+			time.Sleep(benchHold)
+			qe.Done()
+		}(i)
+	}
+	readers.Wait()
+	elapsed := time.Since(start)
+	b.StopTimer()
+	close(stop)
+	committer.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	b.ReportMetric(float64(total)/float64(b.N), "ns/read")
+	b.ReportMetric(float64(latencies[b.N*99/100]), "p99-ns/read")
+	b.ReportMetric(float64(atomic.LoadInt64(&blocks))/elapsed.Seconds(), "blocks/s")
+}
@@ -0,0 +1,348 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/pkg/errors"
+)
+
+// CurrentDataFormat is the format of the data of the vaults written by this version.
+// The formats are:
+//  1. the statuses of the transactions are stored by transaction id and by position.
+//     The vaults of this format were not stamped with their format.
+//  2. the statuses of the transactions are also indexed by status. The vault keeps its own records,
+//     its height and its format, in legacyNamespace.
+//  3. the vault keeps its own records in its reserved namespace, legacyNamespace is a namespace as any other.
+const CurrentDataFormat = 3
+
+const (
+	formatKey    = "format"
+	migrationKey = "migration"
+	// legacyNamespace is the namespace the vaults of format 2 keep their own records in, see CurrentDataFormat.
+	// It may also be the namespace of an application: a record is taken as the vault's only if it is well formed.
+	// The namespaces it prefixes, followed by a dash, are still the vault's.
+	legacyNamespace = "vault"
+	// legacyFormat is the only format stamped in legacyNamespace
+	legacyFormat = 2
+)
+
+// formatMigration migrates the data of a vault from a format to the next one.
+// A migration must be idempotent: if it gets interrupted, it is run again the next time the vault is opened.
+type formatMigration struct {
+	description string
+	migrate     func(name string, store driver.VersionedPersistence) error
+}
+
+// formatMigrations are indexed by the format they migrate from
+var formatMigrations = map[int]formatMigration{
+	1: {description: "index the statuses of the transactions", migrate: migrateStatusIndex},
+	2: {description: "move the records of the vault to its reserved namespace", migrate: migrateReservedNamespace},
+}
+
+// MigrationMarker is stored in a vault when a migration of its data format starts, and it is updated when
+// the migration completes. It records the format the vault had before the migration, and the archive of the vault
+// taken before it, see Archive: the archive can be restored only by a version supporting that format.
+type MigrationMarker struct {
+	From      int        `json:"from"`
+	To        int        `json:"to"`
+	Backup    string     `json:"backup"`
+	Started   time.Time  `json:"started"`
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// DataFormat returns the format of the data of the passed vault persistence.
+// The vaults created before the stamp are recognized from their content, the empty ones have format zero.
+func DataFormat(persistence driver.Persistence) (int, error) {
+	raw, err := persistence.GetState(vaultNamespace, formatKey)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed retrieving data format")
+	}
+	if len(raw) != 0 {
+		if len(raw) != 4 {
+			return 0, errors.Errorf("invalid data format stamp [%x]", raw)
+		}
+		return int(binary.BigEndian.Uint32(raw)), nil
+	}
+	legacy, err := legacyStamped(persistence)
+	if err != nil {
+		return 0, err
+	}
+	if legacy {
+		return legacyFormat, nil
+	}
+
+	exists, err := txidstore.Exists(persistence)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	indexed, err := txidstore.HasStatusIndex(persistence)
+	if err != nil {
+		return 0, err
+	}
+	if indexed {
+		return 2, nil
+	}
+	return 1, nil
+}
+
+// LastMigration returns the marker of the last migration of the data format of the passed vault persistence,
+// nil if the format has never been migrated
+func LastMigration(persistence driver.Persistence) (*MigrationMarker, error) {
+	raw, err := persistence.GetState(vaultNamespace, migrationKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed retrieving migration marker")
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	marker := &MigrationMarker{}
+	if err := json.Unmarshal(raw, marker); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling migration marker")
+	}
+	return marker, nil
+}
+
+// OpenDataFormat must be called before opening the vault of the passed channel on the passed store.
+// The new vaults are stamped with CurrentDataFormat, the ones with an older format are migrated to it,
+// one format at a time, once archived to the passed backup directory, see Archive. The archive taken before
+// a migration that did not complete is verified and kept instead.
+// It fails if the vault has been written by a version with a newer format, or if it must be migrated and
+// no backup directory is set.
+func OpenDataFormat(network, channel string, store driver.VersionedPersistence, backupDir string) error {
+	persistence := db.Unversioned(store)
+	format, err := DataFormat(persistence)
+	if err != nil {
+		return errors.WithMessagef(err, "failed checking data format of vault [%s]", channel)
+	}
+	if format > CurrentDataFormat {
+		return errors.Errorf("vault [%s] has data format [%d], newer than the format [%d] supported by this version: open it with the version that wrote it or a later one", channel, format, CurrentDataFormat)
+	}
+	if format == 0 {
+		logger.Debugf("stamping new vault [%s] with data format [%d]", channel, CurrentDataFormat)
+		return stampDataFormat(persistence, CurrentDataFormat)
+	}
+	if format == CurrentDataFormat {
+		return nil
+	}
+
+	last, err := LastMigration(persistence)
+	if err != nil {
+		return errors.WithMessagef(err, "failed checking data format of vault [%s]", channel)
+	}
+	backup, err := migrationBackup(network, channel, store, format, backupDir, last)
+	if err != nil {
+		return errors.WithMessagef(err, "failed backing up vault [%s] before migrating it from data format [%d]", channel, format)
+	}
+	for ; format < CurrentDataFormat; format++ {
+		m, ok := formatMigrations[format]
+		if !ok {
+			return errors.Errorf("vault [%s] has data format [%d], no migration from it is available", channel, format)
+		}
+		logger.Infof("migrating vault [%s] from data format [%d] to [%d]: %s...", channel, format, format+1, m.description)
+		start := time.Now()
+		marker := &MigrationMarker{From: format, To: format + 1, Backup: backup, Started: start}
+		if err := setMigrationMarker(persistence, marker); err != nil {
+			return errors.WithMessagef(err, "failed migrating vault [%s] from data format [%d]", channel, format)
+		}
+		if err := m.migrate(channel, store); err != nil {
+			return errors.WithMessagef(err, "failed migrating vault [%s] from data format [%d]", channel, format)
+		}
+		if err := stampDataFormat(persistence, format+1); err != nil {
+			return errors.WithMessagef(err, "failed migrating vault [%s] from data format [%d]", channel, format)
+		}
+		completed := time.Now()
+		marker.Completed = &completed
+		if err := setMigrationMarker(persistence, marker); err != nil {
+			return errors.WithMessagef(err, "failed migrating vault [%s] from data format [%d]", channel, format)
+		}
+		logger.Infof("migrated vault [%s] to data format [%d] in [%s]", channel, format+1, completed.Sub(start))
+	}
+	return nil
+}
+
+// migrationBackup returns the path of the archive of the vault of the passed channel, taken before migrating it from
+// the passed format. The archive of the last migration, if it did not complete, is kept if it can be read in full.
+// Otherwise, the vault is archived to the passed directory.
+func migrationBackup(network, channel string, store driver.VersionedPersistence, format int, dir string, last *MigrationMarker) (string, error) {
+	if last != nil && last.Completed == nil && len(last.Backup) != 0 {
+		logger.Warnf("migration of vault [%s] from data format [%d] to [%d], started at [%s], did not complete, run it again", channel, last.From, last.To, last.Started)
+		entries, err := verifyArchive(last.Backup, network, channel)
+		if err == nil {
+			logger.Infof("vault [%s]: keep the backup [%s] taken before the interrupted migration, [%d] keys", channel, last.Backup, entries)
+			return last.Backup, nil
+		}
+		logger.Warnf("vault [%s]: the backup [%s] taken before the interrupted migration cannot be read, take a new one: %s", channel, last.Backup, err)
+	}
+	if len(dir) == 0 {
+		return "", errors.Errorf("no backup directory set, the vault must be archived before migrating it to data format [%d]", CurrentDataFormat)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrapf(err, "failed creating backup directory [%s]", dir)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-format%d-%s.archive", channel, format, time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed creating backup [%s]", path)
+	}
+	err = New(store, nil).Archive(f, network, channel)
+	if err == nil {
+		err = errors.Wrapf(f.Sync(), "failed syncing backup [%s]", path)
+	}
+	if err1 := f.Close(); err == nil && err1 != nil {
+		err = errors.Wrapf(err1, "failed closing backup [%s]", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	entries, err := verifyArchive(path, network, channel)
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed verifying backup [%s]", path)
+	}
+	logger.Infof("vault [%s]: backed up [%d] keys with data format [%d] to [%s]", channel, entries, format, path)
+	return path, nil
+}
+
+// verifyArchive reads in full the archive at the passed path, which must be of the passed channel, and returns
+// the number of its entries
+func verifyArchive(path, network, channel string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed opening archive [%s]", path)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid archive [%s]", path)
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(bufio.NewReader(zr))
+	if _, err := readArchiveHeader(dec, network, channel); err != nil {
+		return 0, err
+	}
+	entries := 0
+	for {
+		if err := dec.Decode(&archiveEntry{}); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, errors.Wrapf(err, "invalid archive entry after [%d] entries", entries)
+		}
+		entries++
+	}
+}
+
+func migrateStatusIndex(name string, store driver.VersionedPersistence) error {
+	return txidstore.BuildStatusIndex(db.Unversioned(store), func(indexed int) {
+		logger.Infof("vault [%s]: indexed the statuses of [%d] transactions", name, indexed)
+	})
+}
+
+// migrateReservedNamespace moves the records of the vault from legacyNamespace to its reserved namespace.
+// The height is taken as the vault's only if it is the block it has been written at, as the vault did;
+// the format stamp and the migration marker only if the vault has been stamped there.
+func migrateReservedNamespace(name string, store driver.VersionedPersistence) error {
+	raw, block, _, err := store.GetState(legacyNamespace, heightKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed retrieving legacy height")
+	}
+	legacyHeight := len(raw) >= 8 && binary.BigEndian.Uint64(raw) == block
+	current, _, _, err := store.GetState(vaultNamespace, heightKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed retrieving height")
+	}
+	stamped, err := legacyStamped(db.Unversioned(store))
+	if err != nil {
+		return err
+	}
+	if !legacyHeight && !stamped {
+		return nil
+	}
+
+	if err := store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update failed")
+	}
+	if legacyHeight {
+		if len(current) == 0 {
+			if err := store.SetState(vaultNamespace, heightKey, encodeHeight(block), block, 0); err != nil {
+				discard(store)
+				return errors.Wrapf(err, "failed storing height [%d]", block)
+			}
+		}
+		if err := store.DeleteState(legacyNamespace, heightKey); err != nil {
+			discard(store)
+			return errors.Wrapf(err, "failed deleting legacy height")
+		}
+	}
+	if stamped {
+		for _, key := range []string{formatKey, migrationKey} {
+			if err := store.DeleteState(legacyNamespace, key); err != nil {
+				discard(store)
+				return errors.Wrapf(err, "failed deleting legacy [%s]", key)
+			}
+		}
+	}
+	if err := store.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing the records moved failed")
+	}
+	logger.Infof("vault [%s]: moved the records of the vault from namespace [%s] to [%s]", name, legacyNamespace, vaultNamespace)
+	return nil
+}
+
+// legacyStamped returns true if the passed vault persistence has been stamped in legacyNamespace
+func legacyStamped(persistence driver.Persistence) (bool, error) {
+	raw, err := persistence.GetState(legacyNamespace, formatKey)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed retrieving legacy data format")
+	}
+	return len(raw) == 4 && binary.BigEndian.Uint32(raw) == legacyFormat, nil
+}
+
+func stampDataFormat(persistence driver.Persistence, format int) error {
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, uint32(format))
+	return setFormatState(persistence, formatKey, raw)
+}
+
+func setMigrationMarker(persistence driver.Persistence, marker *MigrationMarker) error {
+	raw, err := json.Marshal(marker)
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling migration marker")
+	}
+	return setFormatState(persistence, migrationKey, raw)
+}
+
+func setFormatState(persistence driver.Persistence, key string, value []byte) error {
+	if err := persistence.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update for [%s] failed", key)
+	}
+	if err := persistence.SetState(vaultNamespace, key, value); err != nil {
+		if err1 := persistence.Discard(); err1 != nil {
+			logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+		}
+		return errors.Wrapf(err, "failed storing [%s]", key)
+	}
+	if err := persistence.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing [%s] failed", key)
+	}
+	return nil
+}
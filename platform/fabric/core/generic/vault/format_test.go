@@ -0,0 +1,239 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/mocks"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func openBadger(t *testing.T, path string) driver.VersionedPersistence {
+	c := &mocks.Config{}
+	c.UnmarshalKeyReturns(nil)
+	ddb, err := db.OpenVersioned(nil, "badger", path, c)
+	assert.NoError(t, err)
+	return ddb
+}
+
+// openFixture opens a copy of the badger vault in the passed directory of testdata.
+// format1 has been written by the vault of the version preceding the data format stamps: tx1 and tx3 committed
+// in blocks 1 and 3, tx2 discarded. That version recorded neither the heights of the statuses nor its own.
+func openFixture(t *testing.T, fixture string) (driver.VersionedPersistence, string) {
+	path := filepath.Join(t.TempDir(), fixture)
+	assert.NoError(t, os.MkdirAll(path, 0755))
+	entries, err := os.ReadDir(filepath.Join("testdata", fixture))
+	assert.NoError(t, err)
+	for _, e := range entries {
+		raw, err := os.ReadFile(filepath.Join("testdata", fixture, e.Name()))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(path, e.Name()), raw, 0600))
+	}
+	return openBadger(t, path), path
+}
+
+// setLegacyHeight records the passed height in legacyNamespace, as the versions preceding the reserved namespace did
+func setLegacyHeight(t *testing.T, store driver.VersionedPersistence, block uint64) {
+	legacy := make([]byte, binary.MaxVarintLen64)
+	binary.BigEndian.PutUint64(legacy, block)
+	assert.NoError(t, store.BeginUpdate())
+	assert.NoError(t, store.SetState(legacyNamespace, heightKey, legacy, block, 0))
+	assert.NoError(t, store.Commit())
+}
+
+func TestDataFormatMigration(t *testing.T) {
+	ddb, path := openFixture(t, "format1")
+	format, err := DataFormat(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, format)
+	marker, err := LastMigration(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Nil(t, marker)
+
+	// a vault is not migrated without a backup
+	assert.EqualError(t, OpenDataFormat("", "format1", ddb, ""), "failed backing up vault [format1] before migrating it from data format [1]: no backup directory set, the vault must be archived before migrating it to data format [3]")
+
+	backups := filepath.Join(t.TempDir(), "backups")
+	assert.NoError(t, OpenDataFormat("", "format1", ddb, backups))
+	format, err = DataFormat(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, CurrentDataFormat, format)
+	marker, err = LastMigration(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, marker.From)
+	assert.Equal(t, 3, marker.To)
+	assert.NotNil(t, marker.Completed)
+
+	// the backup is the vault as it was before the migration
+	entries, err := os.ReadDir(backups)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, filepath.Join(backups, entries[0].Name()), marker.Backup)
+	restored, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	f, err := os.Open(marker.Backup)
+	assert.NoError(t, err)
+	_, err = Restore(restored, f, "", "format1")
+	assert.NoError(t, f.Close())
+	assert.NoError(t, err)
+	format, err = DataFormat(db.Unversioned(restored))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, format)
+
+	// the content written by the old version is readable
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	code, block, _, err := tidstore.GetWithHeight("tx2")
+	assert.NoError(t, err)
+	assert.Equal(t, fdriver.Invalid, code)
+	assert.Equal(t, fdriver.UnknownBlock, block)
+	last, err := tidstore.GetLastTxID()
+	assert.NoError(t, err)
+	assert.Equal(t, "tx3", last)
+	page, err := tidstore.ListStatuses(fdriver.StatusFilter{Codes: []fdriver.ValidationCode{fdriver.Valid}}, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Statuses, 2)
+	assert.Equal(t, "tx1", page.Statuses[0].TxID)
+	assert.Equal(t, "tx3", page.Statuses[1].TxID)
+	vault := New(ddb, tidstore)
+	qe, err := vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	v, err := qe.GetState("ns", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), v)
+	qe.Done()
+
+	// no height has been recorded
+	height, err := vault.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), height)
+
+	// once migrated, nothing changes at the next opening
+	assert.NoError(t, ddb.Close())
+	ddb = openBadger(t, path)
+	defer ddb.Close()
+	assert.NoError(t, OpenDataFormat("", "format1", ddb, backups))
+	again, err := LastMigration(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, marker.Started.UnixNano(), again.Started.UnixNano())
+}
+
+func TestDataFormatInterruptedMigration(t *testing.T) {
+	ddb, _ := openFixture(t, "format1")
+	backups := t.TempDir()
+	backup, err := migrationBackup("", "format1", ddb, 1, backups, nil)
+	assert.NoError(t, err)
+
+	// the backup taken before a migration that did not complete is kept
+	assert.NoError(t, setMigrationMarker(db.Unversioned(ddb), &MigrationMarker{From: 1, To: 2, Backup: backup, Started: time.Now()}))
+	assert.NoError(t, OpenDataFormat("", "format1", ddb, filepath.Join(backups, "unused")))
+	marker, err := LastMigration(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, backup, marker.Backup)
+	assert.NoDirExists(t, filepath.Join(backups, "unused"))
+
+	// unless it cannot be read
+	ddb, _ = openFixture(t, "format1")
+	assert.NoError(t, os.WriteFile(backup, []byte("truncated"), 0600))
+	assert.NoError(t, setMigrationMarker(db.Unversioned(ddb), &MigrationMarker{From: 1, To: 2, Backup: backup, Started: time.Now()}))
+	assert.NoError(t, OpenDataFormat("", "format1", ddb, filepath.Join(backups, "new")))
+	marker, err = LastMigration(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(backups, "new"), filepath.Dir(marker.Backup))
+}
+
+func TestDataFormatLegacyNamespace(t *testing.T) {
+	// format 2 is stamped in legacyNamespace, the records are moved to the reserved namespace
+	ddb, _ := openFixture(t, "format1")
+	setLegacyHeight(t, ddb, 3)
+	assert.NoError(t, txidstore.BuildStatusIndex(db.Unversioned(ddb), nil))
+	stamp := make([]byte, 4)
+	binary.BigEndian.PutUint32(stamp, 2)
+	assert.NoError(t, ddb.BeginUpdate())
+	assert.NoError(t, ddb.SetState(legacyNamespace, formatKey, stamp, 0, 0))
+	assert.NoError(t, ddb.SetState(legacyNamespace, "asset", []byte("app"), 4, 0))
+	assert.NoError(t, ddb.Commit())
+	format, err := DataFormat(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, format)
+
+	assert.NoError(t, OpenDataFormat("", "format2", ddb, t.TempDir()))
+	marker, err := LastMigration(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, marker.From)
+	for _, key := range []string{heightKey, formatKey} {
+		raw, _, _, err := ddb.GetState(legacyNamespace, key)
+		assert.NoError(t, err)
+		assert.Nil(t, raw, key)
+	}
+	raw, _, _, err := ddb.GetState(legacyNamespace, "asset")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("app"), raw)
+	height, err := New(ddb, nil).Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), height)
+
+	// the keys of an application in legacyNamespace are not taken as the vault's
+	ddb, _ = openFixture(t, "format1")
+	assert.NoError(t, ddb.BeginUpdate())
+	assert.NoError(t, ddb.SetState(legacyNamespace, heightKey, []byte("tall....."), 5, 0))
+	assert.NoError(t, ddb.SetState(legacyNamespace, formatKey, []byte("json"), 5, 0))
+	assert.NoError(t, ddb.Commit())
+	assert.NoError(t, OpenDataFormat("", "app", ddb, t.TempDir()))
+	for key, value := range map[string]string{heightKey: "tall.....", formatKey: "json"} {
+		raw, _, _, err := ddb.GetState(legacyNamespace, key)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(raw))
+	}
+	height, err = New(ddb, nil).Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), height)
+}
+
+func TestDataFormatLegacyHeight(t *testing.T) {
+	// the height recorded by the previous versions is read, until a new one is recorded
+	ddb, _ := openFixture(t, "format1")
+	setLegacyHeight(t, ddb, 7)
+	assert.NoError(t, OpenDataFormat("", "format1", ddb, t.TempDir()))
+	vault := New(ddb, nil)
+	height, err := vault.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), height)
+	assert.NoError(t, vault.SetHeight(8))
+	height, err = vault.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), height)
+}
+
+func TestDataFormatStamp(t *testing.T) {
+	ddb := openBadger(t, filepath.Join(t.TempDir(), "new"))
+	defer ddb.Close()
+
+	// a new vault gets the current format at creation, no backup is needed
+	assert.NoError(t, OpenDataFormat("", "new", ddb, ""))
+	format, err := DataFormat(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Equal(t, CurrentDataFormat, format)
+	marker, err := LastMigration(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	assert.Nil(t, marker)
+
+	// a vault written by a newer version is not opened
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, CurrentDataFormat+1)
+	assert.NoError(t, setFormatState(db.Unversioned(ddb), formatKey, raw))
+	assert.EqualError(t, OpenDataFormat("", "new", ddb, ""), "vault [new] has data format [4], newer than the format [3] supported by this version: open it with the version that wrote it or a later one")
+}
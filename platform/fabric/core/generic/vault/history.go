@@ -0,0 +1,496 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/keys"
+	"github.com/pkg/errors"
+)
+
+const (
+	// historyNamespace is the reserved namespace the versions of the keys of the namespaces with history are stored in
+	historyNamespace = "vault-history"
+	// historyMetaNamespace is the reserved namespace the horizons of the namespaces with history,
+	// and the height of the vault, are stored in
+	historyMetaNamespace = "vault-history-meta"
+	// historyHeightKey is the key of the last block committed since the history is kept
+	historyHeightKey = "height"
+	// horizonPrefix prefixes the keys of the horizons of the namespaces
+	horizonPrefix = "horizon"
+)
+
+// history tracks the namespaces whose versions are kept, and their retention horizon
+type history struct {
+	lock sync.RWMutex
+	// horizons maps the namespaces with history to their retention horizon: the lowest height they can be read at
+	horizons map[string]uint64
+	// height is the last block committed
+	height uint64
+}
+
+func (h *history) enabled(namespace string) bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	_, ok := h.horizons[namespace]
+	return ok
+}
+
+func (h *history) any() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.horizons) != 0
+}
+
+// SetHistoryNamespaces keeps the history of the versions of the keys of the passed namespaces, so that they can
+// be read at a past height with NewQueryExecutorAt. Each version committed takes an extra entry in the store.
+// A namespace whose history is newly kept is read from its current state onwards: the current height is its horizon.
+// The history of the namespaces no longer passed is dropped.
+func (db *Vault) SetHistoryNamespaces(namespaces ...string) error {
+	db.lockStore()
+	defer db.unlockStore()
+
+	horizons, height, err := db.readHistoryMeta()
+	if err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	for _, ns := range namespaces {
+		wanted[ns] = true
+	}
+	if len(wanted) == 0 && len(horizons) == 0 {
+		return nil
+	}
+
+	if err := db.store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update for history failed")
+	}
+	err = db.updateHistoryNamespaces(wanted, horizons, &height)
+	if err != nil {
+		if err1 := db.store.Discard(); err1 != nil {
+			logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+		}
+		return err
+	}
+	if err := db.store.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing history namespaces failed")
+	}
+
+	db.history.lock.Lock()
+	defer db.history.lock.Unlock()
+	db.history.horizons = horizons
+	db.history.height = height
+	return nil
+}
+
+func (db *Vault) updateHistoryNamespaces(wanted map[string]bool, horizons map[string]uint64, height *uint64) error {
+	for ns := range horizons {
+		if wanted[ns] {
+			continue
+		}
+		logger.Infof("drop the history of namespace [%s]", ns)
+		if err := db.deleteRange(historyNamespace, ns+keys.NamespaceSeparator, ns+"\x01"); err != nil {
+			return err
+		}
+		if err := db.store.DeleteState(historyMetaNamespace, horizonKey(ns)); err != nil {
+			return errors.Wrapf(err, "failed dropping the horizon of [%s]", ns)
+		}
+		delete(horizons, ns)
+	}
+
+	// the current states of the new namespaces are their first versions
+	var added []string
+	snapshots := map[string][]*driver.VersionedRead{}
+	for ns := range wanted {
+		if _, ok := horizons[ns]; ok {
+			continue
+		}
+		reads, err := db.scan(ns, "", "")
+		if err != nil {
+			return err
+		}
+		for _, read := range reads {
+			if read.Block > *height {
+				*height = read.Block
+			}
+		}
+		snapshots[ns] = reads
+		added = append(added, ns)
+	}
+	sort.Strings(added)
+	for _, ns := range added {
+		logger.Infof("keep the history of namespace [%s] from height [%d], [%d] keys", ns, *height, len(snapshots[ns]))
+		for _, read := range snapshots[ns] {
+			if err := db.store.SetState(historyNamespace, historyKey(ns, read.Key, read.Block, uint64(read.IndexInBlock)), encodeVersion(read.Raw), read.Block, uint64(read.IndexInBlock)); err != nil {
+				return errors.Wrapf(err, "failed storing the version of [%s:%s]", ns, read.Key)
+			}
+		}
+		if err := db.store.SetState(historyMetaNamespace, horizonKey(ns), []byte(strconv.FormatUint(*height, 10)), *height, 0); err != nil {
+			return errors.Wrapf(err, "failed storing the horizon of [%s]", ns)
+		}
+		horizons[ns] = *height
+	}
+	if len(added) != 0 {
+		return db.store.SetState(historyMetaNamespace, historyHeightKey, []byte(strconv.FormatUint(*height, 10)), *height, 0)
+	}
+	return nil
+}
+
+// readHistoryMeta returns the horizons of the namespaces with history, and the last block committed
+func (db *Vault) readHistoryMeta() (map[string]uint64, uint64, error) {
+	reads, err := db.scan(historyMetaNamespace, "", "")
+	if err != nil {
+		return nil, 0, err
+	}
+	horizons := map[string]uint64{}
+	var height uint64
+	for _, read := range reads {
+		value, err := strconv.ParseUint(string(read.Raw), 10, 64)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid history metadata [%s]", read.Key)
+		}
+		if read.Key == historyHeightKey {
+			height = value
+			continue
+		}
+		horizons[strings.TrimPrefix(read.Key, horizonPrefix+keys.NamespaceSeparator)] = value
+	}
+	return horizons, height, nil
+}
+
+// recordVersion stores, as part of the current update of the store, the passed version of the passed key
+// if the history of its namespace is kept. A nil value records a deletion.
+func (db *Vault) recordVersion(namespace, key string, value []byte, block, txNum uint64) error {
+	if !db.history.enabled(namespace) {
+		return nil
+	}
+	if err := db.store.SetState(historyNamespace, historyKey(namespace, key, block, txNum), encodeVersion(value), block, txNum); err != nil {
+		return errors.Wrapf(err, "failed storing the version of [%s:%s] at [%d:%d]", namespace, key, block, txNum)
+	}
+	return nil
+}
+
+// recordHeight stores, as part of the current update of the store, the passed block if it is the last one committed.
+// It returns a function to call once the update is committed.
+func (db *Vault) recordHeight(block uint64) (func(), error) {
+	if !db.history.any() {
+		return func() {}, nil
+	}
+	db.history.lock.RLock()
+	height := db.history.height
+	db.history.lock.RUnlock()
+	if block <= height {
+		return func() {}, nil
+	}
+	if err := db.store.SetState(historyMetaNamespace, historyHeightKey, []byte(strconv.FormatUint(block, 10)), block, 0); err != nil {
+		return nil, errors.Wrapf(err, "failed storing the height [%d]", block)
+	}
+	return func() {
+		db.history.lock.Lock()
+		defer db.history.lock.Unlock()
+		if block > db.history.height {
+			db.history.height = block
+		}
+	}, nil
+}
+
+// NewQueryExecutorAt returns a query executor reading the namespaces with history as they were once the passed
+// block has been committed. It returns fdriver.ErrHeightNotCommitted if the block is not committed yet,
+// fdriver.ErrHeightBeforeHorizon if it precedes the horizons of all the namespaces with history.
+// Reading a namespace without history returns fdriver.ErrHistoryNotKept.
+func (db *Vault) NewQueryExecutorAt(height uint64) (fdriver.QueryExecutor, error) {
+	db.history.lock.RLock()
+	current := db.history.height
+	horizons := make(map[string]uint64, len(db.history.horizons))
+	lowest := uint64(0)
+	for ns, horizon := range db.history.horizons {
+		horizons[ns] = horizon
+		if len(horizons) == 1 || horizon < lowest {
+			lowest = horizon
+		}
+	}
+	db.history.lock.RUnlock()
+
+	if len(horizons) == 0 {
+		return nil, errors.Wrapf(fdriver.ErrHistoryNotKept, "no namespace keeps its history")
+	}
+	if height > current {
+		return nil, errors.Wrapf(fdriver.ErrHeightNotCommitted, "height [%d] is after the current height [%d]", height, current)
+	}
+	if height < lowest {
+		return nil, errors.Wrapf(fdriver.ErrHeightBeforeHorizon, "height [%d] precedes the retention horizon [%d]", height, lowest)
+	}
+
+	db.counter.Inc()
+	db.readLockStore()
+	return &historicQueryExecutor{vault: db, height: height, horizons: horizons}, nil
+}
+
+// PruneHistory drops the versions of the keys of the passed namespace not needed to read it at the passed height
+// or after, the horizon of the namespace becomes that height
+func (db *Vault) PruneHistory(namespace string, horizon uint64) error {
+	db.lockStore()
+	defer db.unlockStore()
+
+	db.history.lock.RLock()
+	current, ok := db.history.horizons[namespace]
+	height := db.history.height
+	db.history.lock.RUnlock()
+	if !ok {
+		return errors.Wrapf(fdriver.ErrHistoryNotKept, "namespace [%s]", namespace)
+	}
+	if horizon <= current {
+		return nil
+	}
+	if horizon > height {
+		return errors.Wrapf(fdriver.ErrHeightNotCommitted, "horizon [%d] is after the current height [%d]", horizon, height)
+	}
+
+	reads, err := db.scan(historyNamespace, namespace+keys.NamespaceSeparator, namespace+"\x01")
+	if err != nil {
+		return err
+	}
+	if err := db.store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update for pruning failed")
+	}
+	pruned := 0
+	for i, read := range reads {
+		key, block, _, err := parseHistoryKey(read.Key)
+		if err != nil {
+			return db.discard(err)
+		}
+		// a version is superseded at the horizon if the next version of the same key is committed by then
+		if i+1 < len(reads) {
+			nextKey, nextBlock, _, err := parseHistoryKey(reads[i+1].Key)
+			if err != nil {
+				return db.discard(err)
+			}
+			if nextKey == key && nextBlock <= horizon && block < horizon {
+				if err := db.store.DeleteState(historyNamespace, read.Key); err != nil {
+					return db.discard(errors.Wrapf(err, "failed pruning [%s]", read.Key))
+				}
+				pruned++
+			}
+		}
+	}
+	if err := db.store.SetState(historyMetaNamespace, horizonKey(namespace), []byte(strconv.FormatUint(horizon, 10)), horizon, 0); err != nil {
+		return db.discard(errors.Wrapf(err, "failed storing the horizon of [%s]", namespace))
+	}
+	if err := db.store.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing pruning failed")
+	}
+	logger.Infof("pruned [%d] versions of namespace [%s], horizon [%d]", pruned, namespace, horizon)
+
+	db.history.lock.Lock()
+	defer db.history.lock.Unlock()
+	db.history.horizons[namespace] = horizon
+	return nil
+}
+
+func (db *Vault) discard(err error) error {
+	if err1 := db.store.Discard(); err1 != nil {
+		logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+	}
+	return err
+}
+
+// scan returns the states of the keys of the passed namespace in [startKey, endKey)
+func (db *Vault) scan(namespace, startKey, endKey string) ([]*driver.VersionedRead, error) {
+	it, err := db.store.GetStateRangeScanIterator(namespace, startKey, endKey)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	defer it.Close()
+	var reads []*driver.VersionedRead
+	for {
+		read, err := it.Next()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+		}
+		if read == nil {
+			return reads, nil
+		}
+		reads = append(reads, read)
+	}
+}
+
+func (db *Vault) deleteRange(namespace, startKey, endKey string) error {
+	reads, err := db.scan(namespace, startKey, endKey)
+	if err != nil {
+		return err
+	}
+	for _, read := range reads {
+		if err := db.store.DeleteState(namespace, read.Key); err != nil {
+			return errors.Wrapf(err, "failed deleting [%s:%s]", namespace, read.Key)
+		}
+	}
+	return nil
+}
+
+// historicQueryExecutor reads the versions of the keys committed up to a height
+type historicQueryExecutor struct {
+	vault    *Vault
+	height   uint64
+	horizons map[string]uint64
+}
+
+func (q *historicQueryExecutor) check(namespace string) error {
+	horizon, ok := q.horizons[namespace]
+	if !ok {
+		return errors.Wrapf(fdriver.ErrHistoryNotKept, "namespace [%s]", namespace)
+	}
+	if q.height < horizon {
+		return errors.Wrapf(fdriver.ErrHeightBeforeHorizon, "height [%d] precedes the retention horizon [%d] of namespace [%s]", q.height, horizon, namespace)
+	}
+	return nil
+}
+
+func (q *historicQueryExecutor) GetState(namespace string, key string) ([]byte, error) {
+	if err := q.check(namespace); err != nil {
+		return nil, err
+	}
+	prefix := namespace + keys.NamespaceSeparator + hex.EncodeToString([]byte(key)) + keys.NamespaceSeparator
+	reads, err := q.vault.scan(historyNamespace, prefix, prefix+heightSuffix(q.height+1, 0))
+	if err != nil {
+		return nil, err
+	}
+	if len(reads) == 0 {
+		return nil, nil
+	}
+	return decodeVersion(reads[len(reads)-1].Raw), nil
+}
+
+func (q *historicQueryExecutor) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	return nil, 0, 0, errors.Errorf("the metadata of [%s:%s] is not kept at past heights", namespace, key)
+}
+
+func (q *historicQueryExecutor) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
+	if err := q.check(namespace); err != nil {
+		return nil, err
+	}
+	start := namespace + keys.NamespaceSeparator + hex.EncodeToString([]byte(startKey))
+	end := namespace + "\x01"
+	if len(endKey) != 0 {
+		end = namespace + keys.NamespaceSeparator + hex.EncodeToString([]byte(endKey))
+	}
+	it, err := q.vault.store.GetStateRangeScanIterator(historyNamespace, start, end)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed scanning the history of namespace [%s]", namespace)
+	}
+	return &historicIterator{it: it, height: q.height}, nil
+}
+
+func (q *historicQueryExecutor) Done() {
+	q.vault.counter.Dec()
+	q.vault.storeLock.RUnlock()
+}
+
+// historicIterator returns, for each key, the last version committed up to the height, if not a deletion
+type historicIterator struct {
+	it      driver.VersionedResultsIterator
+	height  uint64
+	pending *driver.VersionedRead
+}
+
+func (h *historicIterator) Next() (*driver.VersionedRead, error) {
+	var key string
+	var last *driver.VersionedRead
+	for {
+		read := h.pending
+		h.pending = nil
+		if read == nil {
+			var err error
+			if read, err = h.it.Next(); err != nil {
+				return nil, err
+			}
+		}
+		if read == nil {
+			if last != nil && last.Raw != nil {
+				return last, nil
+			}
+			return nil, nil
+		}
+		k, block, txNum, err := parseHistoryKey(read.Key)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil && k != key {
+			if last.Raw != nil {
+				h.pending = read
+				return last, nil
+			}
+			last = nil
+		}
+		key = k
+		if block > h.height {
+			continue
+		}
+		last = &driver.VersionedRead{Key: k, Raw: decodeVersion(read.Raw), Block: block, IndexInBlock: int(txNum)}
+	}
+}
+
+func (h *historicIterator) Close() {
+	h.it.Close()
+}
+
+// historyKey is the key of the version of the passed key: the key is hex encoded, so that the keys of the versions
+// of a key share a prefix, sort as the keys do, and then by height
+func historyKey(namespace, key string, block, txNum uint64) string {
+	return namespace + keys.NamespaceSeparator + hex.EncodeToString([]byte(key)) + keys.NamespaceSeparator + heightSuffix(block, txNum)
+}
+
+func heightSuffix(block, txNum uint64) string {
+	return fmt.Sprintf("%020d%020d", block, txNum)
+}
+
+// parseHistoryKey returns the key and the height of the passed key of a version
+func parseHistoryKey(historyKey string) (string, uint64, uint64, error) {
+	parts := strings.Split(historyKey, keys.NamespaceSeparator)
+	if len(parts) != 3 || len(parts[2]) != 40 {
+		return "", 0, 0, errors.Errorf("invalid history key [%s]", historyKey)
+	}
+	key, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return "", 0, 0, errors.Wrapf(err, "invalid history key [%s]", historyKey)
+	}
+	block, err := strconv.ParseUint(parts[2][:20], 10, 64)
+	if err != nil {
+		return "", 0, 0, errors.Wrapf(err, "invalid history key [%s]", historyKey)
+	}
+	txNum, err := strconv.ParseUint(parts[2][20:], 10, 64)
+	if err != nil {
+		return "", 0, 0, errors.Wrapf(err, "invalid history key [%s]", historyKey)
+	}
+	return string(key), block, txNum, nil
+}
+
+func horizonKey(namespace string) string {
+	return horizonPrefix + keys.NamespaceSeparator + namespace
+}
+
+// encodeVersion prefixes the value with 1, a deletion is encoded as 0
+func encodeVersion(value []byte) []byte {
+	if len(value) == 0 {
+		return []byte{0}
+	}
+	return append([]byte{1}, value...)
+}
+
+func decodeVersion(raw []byte) []byte {
+	if len(raw) == 0 || raw[0] == 0 {
+		return nil
+	}
+	return raw[1:]
+}
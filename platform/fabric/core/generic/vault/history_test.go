@@ -0,0 +1,120 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"fmt"
+	"testing"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// commitWrites commits, in the passed block, a transaction with the passed writes, a nil value deletes the key
+func commitWrites(t *testing.T, vault *Vault, block uint64, writes map[string][]byte) {
+	txid := fmt.Sprintf("tx%d", block)
+	rws, err := vault.NewRWSet(txid)
+	assert.NoError(t, err)
+	for key, value := range writes {
+		if value == nil {
+			assert.NoError(t, rws.DeleteState("assets", key))
+			continue
+		}
+		assert.NoError(t, rws.SetState("assets", key, value))
+	}
+	rws.Done()
+	assert.NoError(t, vault.CommitTX(txid, block, 0))
+}
+
+// readAt returns the states of the assets at the passed height, read by key and by range
+func readAt(t *testing.T, vault *Vault, height uint64) (map[string]string, map[string]string) {
+	qe, err := vault.NewQueryExecutorAt(height)
+	assert.NoError(t, err)
+	defer qe.Done()
+
+	byKey := map[string]string{}
+	for _, key := range []string{"a", "b"} {
+		v, err := qe.GetState("assets", key)
+		assert.NoError(t, err)
+		if v != nil {
+			byKey[key] = string(v)
+		}
+	}
+	byRange := map[string]string{}
+	it, err := qe.GetStateRangeScanIterator("assets", "", "")
+	assert.NoError(t, err)
+	defer it.Close()
+	for {
+		read, err := it.Next()
+		assert.NoError(t, err)
+		if read == nil {
+			return byKey, byRange
+		}
+		byRange[read.Key] = string(read.Raw)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+
+	// without history, nothing can be read at a past height
+	_, err := vault.NewQueryExecutorAt(0)
+	assert.True(t, errors.Is(err, fdriver.ErrHistoryNotKept))
+
+	// the state when the history is enabled is its first version
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("1")})
+	assert.NoError(t, vault.SetHistoryNamespaces("assets"))
+	commitWrites(t, vault, 2, map[string][]byte{"a": []byte("2"), "b": []byte("1")})
+	commitWrites(t, vault, 3, map[string][]byte{"a": nil})
+	commitWrites(t, vault, 4, map[string][]byte{"a": []byte("4")})
+
+	for height, expected := range map[uint64]map[string]string{
+		1: {"a": "1"},
+		2: {"a": "2", "b": "1"},
+		3: {"b": "1"},
+		4: {"a": "4", "b": "1"},
+	} {
+		byKey, byRange := readAt(t, vault, height)
+		assert.Equal(t, expected, byKey, "height %d", height)
+		assert.Equal(t, expected, byRange, "height %d", height)
+	}
+
+	qe, err := vault.NewQueryExecutorAt(4)
+	assert.NoError(t, err)
+	_, err = qe.GetState("other", "a")
+	assert.True(t, errors.Is(err, fdriver.ErrHistoryNotKept))
+	qe.Done()
+	_, err = vault.NewQueryExecutorAt(5)
+	assert.True(t, errors.Is(err, fdriver.ErrHeightNotCommitted))
+	_, err = vault.NewQueryExecutorAt(0)
+	assert.True(t, errors.Is(err, fdriver.ErrHeightBeforeHorizon))
+
+	// pruning moves the horizon, the versions still needed are kept
+	assert.NoError(t, vault.PruneHistory("assets", 3))
+	_, err = vault.NewQueryExecutorAt(2)
+	assert.True(t, errors.Is(err, fdriver.ErrHeightBeforeHorizon))
+	byKey, _ := readAt(t, vault, 3)
+	assert.Equal(t, map[string]string{"b": "1"}, byKey)
+	byKey, _ = readAt(t, vault, 4)
+	assert.Equal(t, map[string]string{"a": "4", "b": "1"}, byKey)
+
+	// the history survives a restart, until the namespace is no longer listed
+	restarted := New(ddb, vault.txidStore)
+	assert.NoError(t, restarted.SetHistoryNamespaces("assets"))
+	_, err = restarted.NewQueryExecutorAt(2)
+	assert.True(t, errors.Is(err, fdriver.ErrHeightBeforeHorizon))
+	byKey, _ = readAt(t, restarted, 4)
+	assert.Equal(t, map[string]string{"a": "4", "b": "1"}, byKey)
+
+	assert.NoError(t, restarted.SetHistoryNamespaces())
+	_, err = restarted.NewQueryExecutorAt(4)
+	assert.True(t, errors.Is(err, fdriver.ErrHistoryNotKept))
+	versions, err := restarted.keys(historyNamespace)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+}
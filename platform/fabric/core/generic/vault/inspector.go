@@ -29,6 +29,9 @@ func newInspector() *Inspector {
 			metaWriteSet: metaWriteSet{
 				metawrites: namespaceKeyedMetaWrites{},
 			},
+			purgeSet: purgeSet{
+				purges: purges{},
+			},
 		},
 	}
 }
@@ -106,6 +109,9 @@ func (i *Inspector) Namespaces() []string {
 	for ns := range i.rws.writes {
 		mergedMaps[ns] = struct{}{}
 	}
+	for ns := range i.rws.purges {
+		mergedMaps[ns] = struct{}{}
+	}
 
 	namespaces := make([]string, 0, len(mergedMaps))
 	for ns := range mergedMaps {
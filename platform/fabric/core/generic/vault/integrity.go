@@ -0,0 +1,433 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+// checksumNamespace is the reserved namespace the checksums of the namespaces are stored in, one key per namespace
+const checksumNamespace = "vault-checksums"
+
+// digest is the contribution of a state to the checksum of its namespace, zero if the key is deleted
+type digest [sha256.Size]byte
+
+// entryDigest returns the digest of the passed state. The versions and the metadata are not covered:
+// the metadata writes change the version of a key without going through the checksums.
+func entryDigest(key string, value []byte) digest {
+	if len(value) == 0 {
+		return digest{}
+	}
+	h := sha256.New()
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(key)))
+	h.Write(l[:])
+	h.Write([]byte(key))
+	h.Write(value)
+	var d digest
+	copy(d[:], h.Sum(nil))
+	return d
+}
+
+// checksum is the checksum of a namespace: the sum, modulo 2^256, of the digests of its states.
+// The sum does not depend on the order of the states, a commit updates it by removing the digests of the states
+// it overwrites and adding those of the states it writes.
+type checksum struct {
+	sum  digest
+	keys uint64
+	// block and txNum are the height of the last write accounted, the checksum is anchored to it
+	block uint64
+	txNum uint64
+}
+
+type storedChecksum struct {
+	Sum   string `json:"sum"`
+	Keys  uint64 `json:"keys"`
+	Block uint64 `json:"block"`
+	TxNum uint64 `json:"txNum"`
+}
+
+func (c *checksum) add(d digest) {
+	if d == (digest{}) {
+		return
+	}
+	carry := 0
+	for i := len(c.sum) - 1; i >= 0; i-- {
+		s := int(c.sum[i]) + int(d[i]) + carry
+		c.sum[i], carry = byte(s), s>>8
+	}
+	c.keys++
+}
+
+func (c *checksum) remove(d digest) {
+	if d == (digest{}) {
+		return
+	}
+	borrow := 0
+	for i := len(c.sum) - 1; i >= 0; i-- {
+		s := int(c.sum[i]) - int(d[i]) - borrow
+		borrow = 0
+		if s < 0 {
+			s += 256
+			borrow = 1
+		}
+		c.sum[i] = byte(s)
+	}
+	if c.keys > 0 {
+		c.keys--
+	}
+}
+
+// merge adds the states accounted in the passed checksum
+func (c *checksum) merge(other *checksum) {
+	keys := c.keys
+	c.add(other.sum)
+	c.keys = keys + other.keys
+	c.anchor(other.block, other.txNum)
+}
+
+func (c *checksum) anchor(block, txNum uint64) {
+	if block > c.block || (block == c.block && txNum > c.txNum) {
+		c.block, c.txNum = block, txNum
+	}
+}
+
+func (c *checksum) marshal() ([]byte, error) {
+	return json.Marshal(&storedChecksum{Sum: hex.EncodeToString(c.sum[:]), Keys: c.keys, Block: c.block, TxNum: c.txNum})
+}
+
+func unmarshalChecksum(raw []byte) (*checksum, error) {
+	s := &storedChecksum{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	sum, err := hex.DecodeString(s.Sum)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, errors.Errorf("invalid sum [%s]", s.Sum)
+	}
+	c := &checksum{keys: s.Keys, block: s.Block, txNum: s.TxNum}
+	copy(c.sum[:], sum)
+	return c, nil
+}
+
+// storedChecksumOf returns the checksum stored for the passed namespace, nil if there is none.
+// db.storeLock must be held.
+func (db *Vault) storedChecksumOf(namespace string) (*checksum, error) {
+	raw, _, _, err := db.store.GetState(checksumNamespace, namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed retrieving the checksum of [%s]", namespace)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	c, err := unmarshalChecksum(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid checksum of [%s]", namespace)
+	}
+	return c, nil
+}
+
+// computeChecksum computes the checksum of the passed namespace by scanning it, db.storeLock must be held
+func (db *Vault) computeChecksum(namespace string) (*checksum, error) {
+	reads, err := db.scan(namespace, "", "")
+	if err != nil {
+		return nil, err
+	}
+	c := &checksum{}
+	for _, read := range reads {
+		c.add(entryDigest(read.Key, read.Raw))
+		c.anchor(read.Block, uint64(read.IndexInBlock))
+	}
+	return c, nil
+}
+
+// checksumOf returns the checksum of the passed namespace, db.storeLock must be held exclusively.
+// A namespace with states but no checksum, written before the checksums were kept, gets its checksum computed in the
+// background, see baseline: its baseline is returned too, the writes of the keys it does not cover yet are not accounted.
+func (db *Vault) checksumOf(namespace string) (*checksum, *baseline, error) {
+	c, err := db.storedChecksumOf(namespace)
+	if err != nil || c != nil {
+		return c, nil, err
+	}
+	db.baselines.lock.Lock()
+	defer db.baselines.lock.Unlock()
+	if b, ok := db.baselines.m[namespace]; ok {
+		sum := b.sum
+		return &sum, b, nil
+	}
+	empty, err := db.isEmpty(namespace)
+	if err != nil || empty {
+		return &checksum{}, nil, err
+	}
+	b := &baseline{}
+	if db.baselines.m == nil {
+		db.baselines.m = map[string]*baseline{}
+	}
+	db.baselines.m[namespace] = b
+	logger.Infof("namespace [%s] has no checksum, computing it in the background", namespace)
+	go db.baseline(namespace, b)
+	return &checksum{}, b, nil
+}
+
+// isEmpty returns true if the passed namespace has no states, db.storeLock must be held
+func (db *Vault) isEmpty(namespace string) (bool, error) {
+	it, err := db.store.GetStateRangeScanIterator(namespace, "", "")
+	if err != nil {
+		return false, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	defer it.Close()
+	read, err := it.Next()
+	if err != nil {
+		return false, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	return read == nil, nil
+}
+
+// baselinePageSize is the number of states digested under each read lock of the store, see baseline
+var baselinePageSize = 1000
+
+// baselines are the checksums of the namespaces computed in the background, see checksumOf
+type baselines struct {
+	lock sync.Mutex
+	m    map[string]*baseline
+}
+
+// baseline is the checksum of a namespace being computed: it accounts the states of the keys before next, all of them
+// once done. Its fields are written holding both db.storeLock and baselines.lock.
+type baseline struct {
+	sum  checksum
+	next string
+	done bool
+}
+
+// covers returns true if the passed key is accounted in the baseline
+func (b *baseline) covers(key string) bool {
+	return b.done || key < b.next
+}
+
+// baseline computes the checksum of the passed namespace, page by page, and stores it once done. Each page is read
+// under the read lock of the store, so that the commits wait for one page at most. In between, the commits account
+// the writes of the keys covered already, the others are digested afterwards.
+func (db *Vault) baseline(namespace string, b *baseline) {
+	for {
+		done, err := db.baselinePage(namespace, b)
+		if err == nil && done {
+			err = db.storeBaseline(namespace, b)
+		}
+		if err != nil {
+			// the next commit writing the namespace starts over
+			logger.Errorf("failed computing the checksum of namespace [%s]: [%s]", namespace, err)
+			db.baselines.lock.Lock()
+			if db.baselines.m[namespace] == b {
+				delete(db.baselines.m, namespace)
+			}
+			db.baselines.lock.Unlock()
+			return
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// baselinePage accounts the next page of states in the passed baseline, and returns true once done or dropped
+func (db *Vault) baselinePage(namespace string, b *baseline) (bool, error) {
+	db.readLockStore()
+	defer db.storeLock.RUnlock()
+
+	it, err := db.store.GetStateRangeScanIterator(namespace, b.next, "")
+	if err != nil {
+		return false, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	defer it.Close()
+	page := &checksum{}
+	next, n := b.next, 0
+	for ; n < baselinePageSize; n++ {
+		read, err := it.Next()
+		if err != nil {
+			return false, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+		}
+		if read == nil {
+			break
+		}
+		page.add(entryDigest(read.Key, read.Raw))
+		page.anchor(read.Block, uint64(read.IndexInBlock))
+		// the key right after the one read
+		next = read.Key + "\x00"
+	}
+
+	db.baselines.lock.Lock()
+	defer db.baselines.lock.Unlock()
+	if db.baselines.m[namespace] != b {
+		// the namespace has been re-derived meanwhile
+		return true, nil
+	}
+	b.sum.merge(page)
+	b.next = next
+	b.done = n < baselinePageSize
+	return b.done, nil
+}
+
+// storeBaseline stores the checksum of the passed baseline, once done, unless a commit stored it already
+func (db *Vault) storeBaseline(namespace string, b *baseline) error {
+	db.BeginBlockCommit()
+	defer db.EndBlockCommit()
+	db.lockStore()
+	defer db.unlockStore()
+	db.baselines.lock.Lock()
+	defer db.baselines.lock.Unlock()
+
+	if db.baselines.m[namespace] != b {
+		return nil
+	}
+	raw, err := b.sum.marshal()
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling the checksum of [%s]", namespace)
+	}
+	if err := db.store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update for the checksum of [%s] failed", namespace)
+	}
+	if err := db.store.SetState(checksumNamespace, namespace, raw, 0, 0); err != nil {
+		return db.discard(errors.Wrapf(err, "failed storing the checksum of [%s]", namespace))
+	}
+	if err := db.store.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing the checksum of [%s] failed", namespace)
+	}
+	delete(db.baselines.m, namespace)
+	logger.Infof("computed the checksum of namespace [%s]: [%d] keys at height [%d:%d]", namespace, b.sum.keys, b.sum.block, b.sum.txNum)
+	return nil
+}
+
+// committedBaselines records in the baselines the writes of the update, once committed. The baselines done have been
+// stored by the update, as the checksums of the namespaces re-derived by it, they are dropped.
+func (u *usageUpdate) committedBaselines() {
+	if len(u.baselines) == 0 && len(u.rederived) == 0 {
+		return
+	}
+	u.db.baselines.lock.Lock()
+	defer u.db.baselines.lock.Unlock()
+	for ns := range u.rederived {
+		delete(u.db.baselines.m, ns)
+	}
+	for ns, b := range u.baselines {
+		if u.db.baselines.m[ns] != b {
+			continue
+		}
+		if b.done {
+			delete(u.db.baselines.m, ns)
+			continue
+		}
+		b.sum = *u.checksums[ns]
+	}
+}
+
+// VerifyIntegrity verifies the passed namespaces, or all those with a checksum if none is passed, against their
+// checksum: each namespace is scanned and the checksum of its states compared with the one the commits kept.
+// Each namespace is scanned under the read lock of the store, the commits wait for the scan of one namespace at most.
+func (db *Vault) VerifyIntegrity(namespaces ...string) (*fdriver.IntegrityReport, error) {
+	if len(namespaces) == 0 {
+		db.readLockStore()
+		stored, err := db.scan(checksumNamespace, "", "")
+		db.storeLock.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		for _, read := range stored {
+			namespaces = append(namespaces, read.Key)
+		}
+	}
+	sort.Strings(namespaces)
+
+	report := &fdriver.IntegrityReport{Verified: time.Now(), Namespaces: make([]fdriver.NamespaceIntegrity, 0, len(namespaces))}
+	for _, ns := range namespaces {
+		result, err := db.verifyNamespace(ns)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status == fdriver.IntegrityCorrupted {
+			logger.Errorf("namespace [%s] fails its integrity check: checksum [%s] over [%d] keys expected at height [%d:%d], got [%s] over [%d] keys",
+				ns, result.Expected, result.Keys, result.Block, result.TxNum, result.Actual, result.ActualKeys)
+			report.Failed = append(report.Failed, ns)
+		}
+		report.Namespaces = append(report.Namespaces, *result)
+	}
+	return report, nil
+}
+
+func (db *Vault) verifyNamespace(namespace string) (*fdriver.NamespaceIntegrity, error) {
+	db.readLockStore()
+	defer db.storeLock.RUnlock()
+
+	result := &fdriver.NamespaceIntegrity{Namespace: namespace}
+	db.baselines.lock.Lock()
+	_, baselining := db.baselines.m[namespace]
+	db.baselines.lock.Unlock()
+	if baselining {
+		result.Status = fdriver.IntegrityUnchecked
+		return result, nil
+	}
+	expected, err := db.storedChecksumOf(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if expected == nil {
+		result.Status = fdriver.IntegrityUnchecked
+		return result, nil
+	}
+	actual, err := db.computeChecksum(namespace)
+	if err != nil {
+		return nil, err
+	}
+	result.Expected, result.Keys = hex.EncodeToString(expected.sum[:]), expected.keys
+	result.Actual, result.ActualKeys = hex.EncodeToString(actual.sum[:]), actual.keys
+	result.Block, result.TxNum = expected.block, expected.txNum
+	result.Status = fdriver.IntegrityOK
+	if actual.sum != expected.sum || actual.keys != expected.keys {
+		result.Status = fdriver.IntegrityCorrupted
+	}
+	return result, nil
+}
+
+// ErrHeightChanged is returned by RederiveNamespace if the vault is no longer at the height the states were derived at
+var ErrHeightChanged = errors.New("vault height changed")
+
+// RederiveNamespace replaces the states of the passed namespace with the passed ones, derived from the blocks up to
+// the passed height, and recomputes its checksum from them, in a single update of the store.
+// It fails with ErrHeightChanged if the vault is no longer at that height: the blocks committed since are to be
+// derived too. The index namespaces are rebuilt from their source namespace instead, see RebuildProjection.
+func (db *Vault) RederiveNamespace(namespace string, states []fdriver.StateWrite, height uint64) error {
+	if len(namespace) == 0 {
+		return errors.New("empty namespace")
+	}
+	if db.projections.index(namespace) != nil {
+		return errors.Errorf("[%s] is an index namespace, rebuild its projection instead", namespace)
+	}
+	for _, w := range states {
+		if w.Namespace != namespace {
+			return errors.Errorf("state [%s:%s] out of the namespace [%s] re-derived", w.Namespace, w.Key, namespace)
+		}
+	}
+	return db.replicate(states, []string{namespace}, func() error {
+		current, err := db.height()
+		if err != nil {
+			return err
+		}
+		if current != height {
+			return errors.Wrapf(ErrHeightChanged, "states of [%s] derived at height [%d], the vault is at [%d]", namespace, height, current)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,155 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"testing"
+	"time"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func integrityOf(t *testing.T, vault *Vault, namespace string) fdriver.NamespaceIntegrity {
+	report, err := vault.VerifyIntegrity(namespace)
+	assert.NoError(t, err)
+	assert.Len(t, report.Namespaces, 1)
+	return report.Namespaces[0]
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+
+	// the states written before the checksums are kept are accounted in the background, from the first commit on
+	assert.NoError(t, ddb.BeginUpdate())
+	assert.NoError(t, ddb.SetState("assets", "z", []byte("0"), 1, 0))
+	assert.NoError(t, ddb.Commit())
+	assert.Equal(t, fdriver.IntegrityUnchecked, integrityOf(t, vault, "assets").Status)
+
+	commitWrites(t, vault, 2, map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	baselined(t, vault)
+	commitWrites(t, vault, 3, map[string][]byte{"a": nil, "c": []byte("3"), "z": []byte("0")})
+	assert.NoError(t, vault.ReplicateStates([]fdriver.StateWrite{{Namespace: "other", Key: "k", Value: []byte("v"), Block: 3}}))
+	report, err := vault.VerifyIntegrity()
+	assert.NoError(t, err)
+	assert.Empty(t, report.Failed)
+	assert.Len(t, report.Namespaces, 2)
+	assets := report.Namespaces[0]
+	assert.Equal(t, "assets", assets.Namespace)
+	assert.Equal(t, fdriver.IntegrityOK, assets.Status)
+	assert.Equal(t, uint64(3), assets.Keys)
+	assert.Equal(t, uint64(3), assets.Block)
+	assert.Equal(t, assets.Expected, assets.Actual)
+
+	// the checksum does not depend on the order of the writes
+	c, err := vault.computeChecksum("assets")
+	assert.NoError(t, err)
+	assert.Equal(t, assets.Expected, integrityOf(t, vault, "assets").Actual)
+	assert.Equal(t, uint64(3), c.keys)
+	assert.Equal(t, uint64(3), c.block)
+
+	// the states altered behind the commits fail the check, the other namespaces pass it
+	assert.NoError(t, ddb.BeginUpdate())
+	assert.NoError(t, ddb.SetState("assets", "b", []byte("x"), 2, 0))
+	assert.NoError(t, ddb.Commit())
+	report, err = vault.VerifyIntegrity()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"assets"}, report.Failed)
+	assert.Equal(t, fdriver.IntegrityCorrupted, report.Namespaces[0].Status)
+	assert.Equal(t, fdriver.IntegrityOK, report.Namespaces[1].Status)
+
+	// a later commit does not hide the corruption
+	commitWrites(t, vault, 4, map[string][]byte{"c": []byte("4")})
+	assert.Equal(t, fdriver.IntegrityCorrupted, integrityOf(t, vault, "assets").Status)
+
+	// replicating the namespace from another node re-derives it, together with its checksum
+	assert.NoError(t, vault.ReplicateStates([]fdriver.StateWrite{
+		{Namespace: "assets", Key: "b", Value: []byte("2"), Block: 2},
+		{Namespace: "assets", Key: "c", Value: []byte("4"), Block: 4},
+	}, "assets"))
+	assets = integrityOf(t, vault, "assets")
+	assert.Equal(t, fdriver.IntegrityOK, assets.Status)
+	assert.Equal(t, uint64(2), assets.Keys)
+	assert.Equal(t, uint64(4), assets.Block)
+
+	// re-deriving the namespace from the blocks replaces its states, unless the vault has moved meanwhile
+	assert.NoError(t, ddb.BeginUpdate())
+	assert.NoError(t, ddb.DeleteState("assets", "c"))
+	assert.NoError(t, ddb.Commit())
+	assert.Equal(t, fdriver.IntegrityCorrupted, integrityOf(t, vault, "assets").Status)
+	states := []fdriver.StateWrite{
+		{Namespace: "assets", Key: "b", Value: []byte("2"), Block: 2},
+		{Namespace: "assets", Key: "c", Value: []byte("4"), Block: 4},
+	}
+	assert.NoError(t, vault.SetHeight(4))
+	err = vault.RederiveNamespace("assets", states, 3)
+	assert.True(t, errors.Is(err, ErrHeightChanged))
+	assert.Equal(t, fdriver.IntegrityCorrupted, integrityOf(t, vault, "assets").Status)
+	assert.NoError(t, vault.RederiveNamespace("assets", states, 4))
+	assets = integrityOf(t, vault, "assets")
+	assert.Equal(t, fdriver.IntegrityOK, assets.Status)
+	assert.Equal(t, uint64(2), assets.Keys)
+	assert.EqualError(t, vault.RederiveNamespace("assets", []fdriver.StateWrite{{Namespace: "other", Key: "k"}}, 4), "state [other:k] out of the namespace [assets] re-derived")
+}
+
+// baselined waits for the checksums computed in the background to be stored
+func baselined(t *testing.T, vault *Vault) {
+	assert.Eventually(t, func() bool {
+		vault.baselines.lock.Lock()
+		defer vault.baselines.lock.Unlock()
+		return len(vault.baselines.m) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBaseline(t *testing.T) {
+	defer func(size int) { baselinePageSize = size }(baselinePageSize)
+	baselinePageSize = 1
+	vault, ddb := newBackupVault(t)
+	assert.NoError(t, ddb.BeginUpdate())
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, ddb.SetState("assets", key, []byte(key), 1, 0))
+	}
+	assert.NoError(t, ddb.Commit())
+
+	// the commits in between the pages account the keys covered already only, the checksum is stored once done
+	b := &baseline{}
+	vault.baselines.m = map[string]*baseline{"assets": b}
+	done, err := vault.baselinePage("assets", b)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	commitWrites(t, vault, 2, map[string][]byte{"a": []byte("1"), "c": nil, "d": []byte("1")})
+	assert.Equal(t, fdriver.IntegrityUnchecked, integrityOf(t, vault, "assets").Status)
+	assert.Equal(t, uint64(1), b.sum.keys)
+	for !done {
+		done, err = vault.baselinePage("assets", b)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, vault.storeBaseline("assets", b))
+	assets := integrityOf(t, vault, "assets")
+	assert.Equal(t, fdriver.IntegrityOK, assets.Status)
+	assert.Equal(t, uint64(3), assets.Keys)
+	assert.Equal(t, uint64(2), assets.Block)
+	assert.Empty(t, vault.baselines.m)
+
+	// a baseline done is stored by the next commit, if any
+	assert.NoError(t, ddb.BeginUpdate())
+	assert.NoError(t, ddb.SetState("other", "a", []byte("a"), 1, 0))
+	assert.NoError(t, ddb.Commit())
+	b = &baseline{}
+	vault.baselines.m["other"] = b
+	for done = false; !done; {
+		done, err = vault.baselinePage("other", b)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, vault.ReplicateStates([]fdriver.StateWrite{{Namespace: "other", Key: "b", Value: []byte("b"), Block: 3}}))
+	assert.Empty(t, vault.baselines.m)
+	assert.NoError(t, vault.storeBaseline("other", b))
+	other := integrityOf(t, vault, "other")
+	assert.Equal(t, fdriver.IntegrityOK, other.Status)
+	assert.Equal(t, uint64(2), other.Keys)
+}
@@ -28,6 +28,10 @@ type Interceptor struct {
 	rws       readWriteSet
 	closed    bool
 	txid      string
+	// quota checks the hard quota of a namespace before it is written, nil for the read-write sets received
+	quota func(namespace string) error
+	// index rejects the writes of the index namespaces of the projections, nil for the read-write sets received
+	index func(namespace string) error
 }
 
 func newInterceptor(qe QueryExecutor, txidStore TXIDStoreReader, txid string) *Interceptor {
@@ -49,6 +53,9 @@ func newInterceptor(qe QueryExecutor, txidStore TXIDStoreReader, txid string) *I
 			metaWriteSet: metaWriteSet{
 				metawrites: namespaceKeyedMetaWrites{},
 			},
+			purgeSet: purgeSet{
+				purges: purges{},
+			},
 		},
 	}
 }
@@ -86,6 +93,7 @@ func (i *Interceptor) Clear(ns string) error {
 	i.rws.readSet.clear(ns)
 	i.rws.writeSet.clear(ns)
 	i.rws.metaWriteSet.clear(ns)
+	i.rws.purgeSet.clear(ns)
 
 	return nil
 }
@@ -151,6 +159,12 @@ func (i *Interceptor) Namespaces() []string {
 	for ns := range i.rws.writes {
 		mergedMaps[ns] = struct{}{}
 	}
+	for ns := range i.rws.metawrites {
+		mergedMaps[ns] = struct{}{}
+	}
+	for ns := range i.rws.purges {
+		mergedMaps[ns] = struct{}{}
+	}
 
 	namespaces := make([]string, 0, len(mergedMaps))
 	for ns := range mergedMaps {
@@ -173,6 +187,17 @@ func (i *Interceptor) SetState(namespace string, key string, value []byte) error
 		return errors.New("this instance was closed")
 	}
 	logger.Debugf("SetState [%s,%s,%s]", namespace, key, hash.Hashable(value).String())
+	if i.index != nil {
+		if err := i.index(namespace); err != nil {
+			return err
+		}
+	}
+	// the deletions free storage, they are allowed above the quota
+	if i.quota != nil && len(value) != 0 {
+		if err := i.quota(namespace); err != nil {
+			return err
+		}
+	}
 
 	return i.rws.writeSet.add(namespace, key, value)
 }
@@ -185,6 +210,17 @@ func (i *Interceptor) SetStateMetadata(namespace string, key string, value map[s
 	return i.rws.metaWriteSet.add(namespace, key, value)
 }
 
+// PurgePrivateData purges the passed key of the passed collection of the passed namespace, as in Fabric:
+// the key is deleted from the collection, and its private values from the private data stores of the peers
+func (i *Interceptor) PurgePrivateData(namespace, collection, key string) error {
+	if i.closed {
+		return errors.New("this instance was closed")
+	}
+	logger.Debugf("PurgePrivateData [%s,%s]", namespace, collection)
+
+	return i.rws.purgeSet.add(namespace, collection, keyHash(key), key)
+}
+
 func (i *Interceptor) GetStateMetadata(namespace, key string, opts ...driver.GetStateOpt) (map[string][]byte, error) {
 	if i.closed {
 		return nil, errors.New("this instance was closed")
@@ -352,6 +388,10 @@ func (i *Interceptor) AppendRWSet(raw []byte, nss ...string) error {
 				return err
 			}
 		}
+
+		if err := i.rws.purgeSet.addHashedRwSets(ns, nsrws.CollHashedRwSets); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -379,13 +419,31 @@ func (i *Interceptor) Bytes() ([]byte, error) {
 			rwsb.AddToMetadataWriteSet(ns, key, v)
 		}
 	}
+	for ns, collections := range i.rws.purges {
+		for coll, hashes := range collections {
+			for _, key := range hashes {
+				if len(key) != 0 {
+					// the private write set deletes the key, its hash is set
+					rwsb.AddToPvtAndHashedWriteSet(ns, coll, key, nil)
+				}
+			}
+		}
+	}
 
 	simRes, err := rwsb.GetTxSimulationResults()
 	if err != nil {
 		return nil, err
 	}
+	if len(i.rws.purges) == 0 {
+		return simRes.GetPubSimulationBytes()
+	}
 
-	return simRes.GetPubSimulationBytes()
+	txRWSet, err := rwsetutil.TxRwSetFromProtoMsg(simRes.PubSimulationResults)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading the simulation results")
+	}
+	markPurges(txRWSet, i.rws.purges)
+	return txRWSet.ToProtoBytes()
 }
 
 func (i *Interceptor) Equals(other interface{}, nss ...string) error {
@@ -400,6 +458,9 @@ func (i *Interceptor) Equals(other interface{}, nss ...string) error {
 		if err := i.rws.metawrites.equals(o.rws.metawrites, nss...); err != nil {
 			return errors.Wrap(err, "meta writes do not match")
 		}
+		if err := i.rws.purges.equals(o.rws.purges, nss...); err != nil {
+			return errors.Wrap(err, "purges do not match")
+		}
 	case *Inspector:
 		if err := i.rws.reads.equals(o.rws.reads, nss...); err != nil {
 			return errors.Wrap(err, "reads do not match")
@@ -410,6 +471,9 @@ func (i *Interceptor) Equals(other interface{}, nss ...string) error {
 		if err := i.rws.metawrites.equals(o.rws.metawrites, nss...); err != nil {
 			return errors.Wrap(err, "meta writes do not match")
 		}
+		if err := i.rws.purges.equals(o.rws.purges, nss...); err != nil {
+			return errors.Wrap(err, "purges do not match")
+		}
 	default:
 		return errors.Errorf("cannot compare to the passed value [%v]", other)
 	}
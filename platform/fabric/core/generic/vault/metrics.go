@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+var (
+	readerWaitDurationOpts = metrics.HistogramOpts{
+		Namespace:    "fabric",
+		Subsystem:    "vault",
+		Name:         "reader_wait_duration",
+		Help:         "The time, in seconds, the readers of the vault of a channel waited for the writers to release the store.",
+		LabelNames:   []string{"network", "channel"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}",
+	}
+	writerStallDurationOpts = metrics.HistogramOpts{
+		Namespace:    "fabric",
+		Subsystem:    "vault",
+		Name:         "writer_stall_duration",
+		Help:         "The time, in seconds, the commits in the vault of a channel waited for the readers to release the store.",
+		LabelNames:   []string{"network", "channel"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}",
+	}
+	namespaceBytesOpts = metrics.GaugeOpts{
+		Namespace:    "fabric",
+		Subsystem:    "vault",
+		Name:         "namespace_bytes",
+		Help:         "The approximate storage, in bytes, taken by the states of a namespace of the vault of a channel.",
+		LabelNames:   []string{"network", "channel", "namespace"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}.%{namespace}",
+	}
+	quotaExceededOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "vault",
+		Name:         "quota_exceeded",
+		Help:         "The number of times the usage of a namespace of the vault of a channel exceeded its soft or hard quota.",
+		LabelNames:   []string{"network", "channel", "namespace", "quota"},
+		StatsdFormat: "%{#fqname}.%{network}.%{channel}.%{namespace}.%{quota}",
+	}
+)
+
+// Metrics collects the contention metrics of the vault, and the usage of its namespaces.
+// The query executors reading from snapshots of the store do not wait, and do not make the writers stall.
+type Metrics struct {
+	ReaderWaitDuration  metrics.Histogram
+	WriterStallDuration metrics.Histogram
+	NamespaceBytes      metrics.Gauge
+	QuotaExceeded       metrics.Counter
+}
+
+func NewMetrics(p metrics.Provider) *Metrics {
+	return &Metrics{
+		ReaderWaitDuration:  p.NewHistogram(readerWaitDurationOpts),
+		WriterStallDuration: p.NewHistogram(writerStallDurationOpts),
+		NamespaceBytes:      p.NewGauge(namespaceBytesOpts),
+		QuotaExceeded:       p.NewCounter(quotaExceededOpts),
+	}
+}
+
+// SetMetrics makes the vault of the passed channel of the passed network record its contention in the passed metrics,
+// shared by the vaults of all the networks. It is meant to be called before the vault is used.
+func (db *Vault) SetMetrics(metrics *Metrics, network, channel string) {
+	db.metrics = metrics
+	db.metricsLabels = []string{"network", network, "channel", channel}
+}
+
+// readLockStore takes the read lock of the store, recording how long the reader waited
+func (db *Vault) readLockStore() {
+	start := time.Now()
+	db.storeLock.RLock()
+	if db.metrics != nil {
+		db.metrics.ReaderWaitDuration.With(db.metricsLabels...).Observe(time.Since(start).Seconds())
+	}
+}
+
+// lockStore takes the exclusive lock of the store, recording how long the writer stalled.
+// The store update is gated by the snapshot hooks, see lockUpdates. unlockStore releases the lock.
+func (db *Vault) lockStore() {
+	start := time.Now()
+	db.lockUpdates()
+	db.storeLock.Lock()
+	if db.metrics != nil {
+		db.metrics.WriterStallDuration.With(db.metricsLabels...).Observe(time.Since(start).Seconds())
+	}
+}
+
+// unlockStore releases the exclusive lock of the store taken with lockStore
+func (db *Vault) unlockStore() {
+	db.storeLock.Unlock()
+	db.unlockUpdates()
+}
+
+// recordUsage records the usage of the passed namespace
+func (db *Vault) recordUsage(namespace string, usage uint64) {
+	if db.metrics == nil || db.metrics.NamespaceBytes == nil {
+		return
+	}
+	db.metrics.NamespaceBytes.With(append(append([]string{}, db.metricsLabels...), "namespace", namespace)...).Set(float64(usage))
+}
+
+// recordQuotaExceeded counts that the usage of the passed namespace exceeded its soft or hard quota
+func (db *Vault) recordQuotaExceeded(namespace, quota string) {
+	if db.metrics == nil || db.metrics.QuotaExceeded == nil {
+		return
+	}
+	db.metrics.QuotaExceeded.With(append(append([]string{}, db.metricsLabels...), "namespace", namespace, "quota", quota)...).Add(1)
+}
@@ -0,0 +1,224 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"sort"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+)
+
+// pendingTx holds the writes of the closed read-write set of a pending transaction
+type pendingTx struct {
+	txID       string
+	writes     writes
+	metawrites namespaceKeyedMetaWrites
+}
+
+// pendingQueryExecutor overlays the writes of pending transactions on a query executor of the committed state.
+// The status of the transactions is checked at each read: the writes of the busy transactions are speculative,
+// those of the transactions committed since are read as committed, the others are retracted.
+type pendingQueryExecutor struct {
+	fdriver.QueryExecutor
+	vault *Vault
+	// pending are the transactions in the order passed, the last one writing a key wins
+	pending []*pendingTx
+}
+
+// NewQueryExecutorWithPending returns a query executor reading the committed state, as NewQueryExecutor does,
+// overlaid with the writes of the passed transactions still pending: their read-write set is closed, and they are
+// neither committed nor invalid, like the transactions this node broadcast. When more transactions write a key,
+// the last one passed wins.
+// The states read from the write set of a busy transaction are speculative: their metadata carry
+// fdriver.SpeculativeMetadataKey, and their block and position are fdriver.UnknownBlock and fdriver.UnknownTxNum.
+// The writes of a transaction committed since are read as committed, those of a transaction invalidated or
+// abandoned are retracted, also from the query executors open already.
+func (db *Vault) NewQueryExecutorWithPending(txIDs ...string) (fdriver.QueryExecutor, error) {
+	// the writes are captured before the committed state, a transaction committed in between is read as committed
+	pending := db.pendingTxs(txIDs)
+	qe, err := db.NewQueryExecutor()
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return qe, nil
+	}
+	return &pendingQueryExecutor{QueryExecutor: qe, vault: db, pending: pending}, nil
+}
+
+func (db *Vault) pendingTxs(txIDs []string) []*pendingTx {
+	db.interceptorsLock.RLock()
+	defer db.interceptorsLock.RUnlock()
+
+	var pending []*pendingTx
+	for _, txID := range txIDs {
+		i, in := db.interceptors[txID]
+		if !in || !i.closed {
+			// the transaction is final already, unknown, or its read-write set is still being assembled
+			logger.Debugf("transaction [%s] has no pending writes", txID)
+			continue
+		}
+		// a closed read-write set is not modified anymore
+		pending = append(pending, &pendingTx{txID: txID, writes: i.rws.writes, metawrites: i.rws.metawrites})
+	}
+	return pending
+}
+
+// status returns the status of the passed transaction, and its height if committed.
+// The writes of the transactions neither busy nor valid are retracted.
+func (q *pendingQueryExecutor) status(tx *pendingTx) (code fdriver.ValidationCode, block uint64, txNum int, err error) {
+	code, block, txNum, err = q.vault.StatusWithHeight(tx.txID)
+	if err != nil {
+		return 0, fdriver.UnknownBlock, fdriver.UnknownTxNum, err
+	}
+	if code != fdriver.Valid {
+		block, txNum = fdriver.UnknownBlock, fdriver.UnknownTxNum
+	}
+	return code, block, txNum, nil
+}
+
+// lookup returns the last transaction, busy or valid, writing the value, or the metadata if asked, of the passed key.
+// It returns nil if none does.
+func (q *pendingQueryExecutor) lookup(namespace, key string, metadata bool) (*pendingTx, fdriver.ValidationCode, uint64, int, error) {
+	for i := len(q.pending) - 1; i >= 0; i-- {
+		tx := q.pending[i]
+		_, writes := tx.writes[namespace][key]
+		_, metawrites := tx.metawrites[namespace][key]
+		if !writes && !(metadata && metawrites) {
+			continue
+		}
+		code, block, txNum, err := q.status(tx)
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		if code == fdriver.Busy || code == fdriver.Valid {
+			return tx, code, block, txNum, nil
+		}
+		logger.Debugf("writes of transaction [%s] retracted, its status is [%d]", tx.txID, code)
+	}
+	return nil, 0, 0, 0, nil
+}
+
+func (q *pendingQueryExecutor) GetState(namespace string, key string) ([]byte, error) {
+	tx, _, _, _, err := q.lookup(namespace, key, false)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return q.QueryExecutor.GetState(namespace, key)
+	}
+	return append([]byte(nil), tx.writes[namespace][key]...), nil
+}
+
+func (q *pendingQueryExecutor) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	tx, code, block, txNum, err := q.lookup(namespace, key, true)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if tx == nil {
+		return q.QueryExecutor.GetStateMetadata(namespace, key)
+	}
+
+	metadata, in := tx.metawrites[namespace][key]
+	if !in {
+		if metadata, _, _, err = q.QueryExecutor.GetStateMetadata(namespace, key); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	res := make(map[string][]byte, len(metadata)+1)
+	for k, v := range metadata {
+		res[k] = append([]byte(nil), v...)
+	}
+	if code == fdriver.Busy {
+		res[fdriver.SpeculativeMetadataKey] = []byte(tx.txID)
+		return res, fdriver.UnknownBlock, uint64(fdriver.UnknownBlock), nil
+	}
+	return res, block, uint64(txNum), nil
+}
+
+func (q *pendingQueryExecutor) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
+	// the last write of each key in the range, by a busy or valid transaction
+	overlay := map[string]*driver.VersionedRead{}
+	for _, tx := range q.pending {
+		nsWrites := tx.writes[namespace]
+		if len(nsWrites) == 0 {
+			continue
+		}
+		code, block, txNum, err := q.status(tx)
+		if err != nil {
+			return nil, err
+		}
+		if code != fdriver.Busy && code != fdriver.Valid {
+			logger.Debugf("writes of transaction [%s] retracted, its status is [%d]", tx.txID, code)
+			continue
+		}
+		for key, value := range nsWrites {
+			if key < startKey || (len(endKey) != 0 && key >= endKey) {
+				continue
+			}
+			overlay[key] = &driver.VersionedRead{Key: key, Raw: append([]byte(nil), value...), Block: block, IndexInBlock: txNum}
+		}
+	}
+
+	it, err := q.QueryExecutor.GetStateRangeScanIterator(namespace, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(overlay) == 0 {
+		return it, nil
+	}
+	reads := make([]*driver.VersionedRead, 0, len(overlay))
+	for _, read := range overlay {
+		reads = append(reads, read)
+	}
+	sort.Slice(reads, func(i, j int) bool { return reads[i].Key < reads[j].Key })
+	return &pendingIterator{committed: it, overlay: reads}, nil
+}
+
+// pendingIterator merges, by key, the reads of a range of the committed state with the pending writes of the range
+type pendingIterator struct {
+	committed driver.VersionedResultsIterator
+	// next is the next committed read, nil if it has not been read yet
+	next *driver.VersionedRead
+	done bool
+	// overlay are the pending writes not returned yet, sorted by key. The deletes have no value.
+	overlay []*driver.VersionedRead
+}
+
+func (it *pendingIterator) Next() (*driver.VersionedRead, error) {
+	for {
+		if it.next == nil && !it.done {
+			next, err := it.committed.Next()
+			if err != nil {
+				return nil, err
+			}
+			it.next, it.done = next, next == nil
+		}
+		if len(it.overlay) == 0 || (it.next != nil && it.next.Key < it.overlay[0].Key) {
+			next := it.next
+			it.next = nil
+			return next, nil
+		}
+
+		read := it.overlay[0]
+		it.overlay = it.overlay[1:]
+		if it.next != nil && it.next.Key == read.Key {
+			// the committed state is overwritten
+			it.next = nil
+		}
+		if len(read.Raw) == 0 {
+			// the key is deleted
+			continue
+		}
+		return read, nil
+	}
+}
+
+func (it *pendingIterator) Close() {
+	it.committed.Close()
+}
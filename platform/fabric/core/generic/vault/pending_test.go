@@ -0,0 +1,145 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/mocks"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// newPendingTx closes, without committing it, a transaction writing the passed states of the assets
+func newPendingTx(t *testing.T, vault *Vault, txid string, writes map[string][]byte) {
+	rws, err := vault.NewRWSet(txid)
+	assert.NoError(t, err)
+	for key, value := range writes {
+		if value == nil {
+			assert.NoError(t, rws.DeleteState("assets", key))
+			continue
+		}
+		assert.NoError(t, rws.SetState("assets", key, value))
+	}
+	rws.Done()
+}
+
+func scan(t *testing.T, qe fdriver.QueryExecutor) []driver.VersionedRead {
+	it, err := qe.GetStateRangeScanIterator("assets", "a", "z")
+	assert.NoError(t, err)
+	defer it.Close()
+	var reads []driver.VersionedRead
+	for {
+		read, err := it.Next()
+		assert.NoError(t, err)
+		if read == nil {
+			return reads
+		}
+		reads = append(reads, *read)
+	}
+}
+
+func TestPendingReads(t *testing.T) {
+	vault, _ := newBackupVault(t)
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("a0"), "b": []byte("b0")})
+	newPendingTx(t, vault, "pending", map[string][]byte{"a": []byte("a1"), "b": nil, "c": []byte("c1")})
+
+	// the transactions unknown, or whose read-write set is still open, are not overlaid
+	open, err := vault.NewRWSet("open")
+	assert.NoError(t, err)
+	assert.NoError(t, open.SetState("assets", "d", []byte("d1")))
+	qe, err := vault.NewQueryExecutorWithPending("pending", "open", "unknown")
+	assert.NoError(t, err)
+	open.Done()
+	v, err := qe.GetState("assets", "d")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	v, err = qe.GetState("assets", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a1"), v)
+	v, err = qe.GetState("assets", "b")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	metadata, block, txNum, err := qe.GetStateMetadata("assets", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{fdriver.SpeculativeMetadataKey: []byte("pending")}, metadata)
+	assert.Equal(t, fdriver.UnknownBlock, block)
+	assert.Equal(t, uint64(fdriver.UnknownBlock), txNum)
+	assert.Equal(t, []driver.VersionedRead{
+		{Key: "a", Raw: []byte("a1"), Block: fdriver.UnknownBlock, IndexInBlock: fdriver.UnknownTxNum},
+		{Key: "c", Raw: []byte("c1"), Block: fdriver.UnknownBlock, IndexInBlock: fdriver.UnknownTxNum},
+	}, scan(t, qe))
+
+	// once the transaction is invalid, its writes are retracted from the query executors open already
+	assert.NoError(t, vault.DiscardTx("pending"))
+	v, err = qe.GetState("assets", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a0"), v)
+	metadata, block, _, err = qe.GetStateMetadata("assets", "c")
+	assert.NoError(t, err)
+	assert.Empty(t, metadata)
+	assert.Equal(t, uint64(0), block)
+	assert.Equal(t, []driver.VersionedRead{
+		{Key: "a", Raw: []byte("a0"), Block: 1},
+		{Key: "b", Raw: []byte("b0"), Block: 1},
+	}, scan(t, qe))
+	qe.Done()
+
+	// an abandoned transaction is retracted too, and the last transaction passed wins
+	newPendingTx(t, vault, "first", map[string][]byte{"a": []byte("a2")})
+	newPendingTx(t, vault, "second", map[string][]byte{"a": []byte("a3")})
+	qe, err = vault.NewQueryExecutorWithPending("first", "second")
+	assert.NoError(t, err)
+	v, err = qe.GetState("assets", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a3"), v)
+	assert.NoError(t, vault.AbandonTx("second"))
+	v, err = qe.GetState("assets", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a2"), v)
+	qe.Done()
+}
+
+func TestPendingReadsCommitted(t *testing.T) {
+	c := &mocks.Config{}
+	c.UnmarshalKeyReturns(nil)
+	c.IsSetReturns(false)
+	ddb, err := db.OpenVersioned(nil, "badger", filepath.Join(tempDir, "DB-TestPendingReadsCommitted"), c)
+	assert.NoError(t, err)
+	defer ddb.Close()
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	vault := New(ddb, tidstore)
+
+	newPendingTx(t, vault, "pending", map[string][]byte{"a": []byte("a1")})
+	// the query executor reads from a snapshot, the transaction commits while it is open
+	qe, err := vault.NewQueryExecutorWithPending("pending")
+	assert.NoError(t, err)
+	defer qe.Done()
+	assert.NoError(t, vault.CommitTX("pending", 5, 2))
+
+	v, err := qe.GetState("assets", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a1"), v)
+	metadata, block, txNum, err := qe.GetStateMetadata("assets", "a")
+	assert.NoError(t, err)
+	assert.NotContains(t, metadata, fdriver.SpeculativeMetadataKey)
+	assert.Equal(t, uint64(5), block)
+	assert.Equal(t, uint64(2), txNum)
+	assert.Equal(t, []driver.VersionedRead{{Key: "a", Raw: []byte("a1"), Block: 5, IndexInBlock: 2}}, scan(t, qe))
+
+	// the transactions final already are read from the committed state
+	qe2, err := vault.NewQueryExecutorWithPending("pending")
+	assert.NoError(t, err)
+	defer qe2.Done()
+	_, ok := qe2.(*pendingQueryExecutor)
+	assert.False(t, ok)
+}
@@ -0,0 +1,348 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+// projectionNamespace is the reserved namespace the versions of the projections are stored in, one key per index namespace
+const projectionNamespace = "vault-projections"
+
+// projectionMeta is the version of a projection that built an index namespace, and of its source
+type projectionMeta struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+}
+
+type projection struct {
+	source     string
+	index      string
+	projection fdriver.Projection
+}
+
+// projections holds the projections registered, by source namespace and by index namespace
+type projections struct {
+	lock     sync.RWMutex
+	bySource map[string][]*projection
+	byIndex  map[string]*projection
+}
+
+func (p *projections) of(namespace string) []*projection {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.bySource[namespace]
+}
+
+func (p *projections) index(namespace string) *projection {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.byIndex[namespace]
+}
+
+// RegisterProjection registers the passed projection of the passed source namespace: from then on, the writes
+// of the source namespace are projected into the index namespace of the projection in the same update of the store.
+// The index namespace is rebuilt, by replaying the source namespace, if it has been built by another version of the
+// projection, or never built.
+// The projections are not stored, they are meant to be registered on startup, before the blocks are delivered.
+func (db *Vault) RegisterProjection(namespace string, p fdriver.Projection) error {
+	index := p.IndexNamespace()
+	switch {
+	case len(namespace) == 0 || len(index) == 0:
+		return errors.New("empty namespace")
+	case namespace == index:
+		return errors.Errorf("namespace [%s] cannot be projected into itself", namespace)
+	case strings.HasPrefix(index, reservedPrefix) || strings.HasPrefix(index, legacyNamespace+"-"):
+		return errors.Errorf("namespace [%s] is reserved", index)
+	}
+
+	db.projections.lock.Lock()
+	if db.projections.byIndex[index] != nil {
+		db.projections.lock.Unlock()
+		return errors.Errorf("namespace [%s] is the index of a projection already", index)
+	}
+	if db.projections.byIndex[namespace] != nil {
+		db.projections.lock.Unlock()
+		return errors.Errorf("namespace [%s] is the index of a projection, it cannot be projected", namespace)
+	}
+	if len(db.projections.bySource[index]) != 0 {
+		db.projections.lock.Unlock()
+		return errors.Errorf("namespace [%s] is projected, it cannot be the index of a projection", index)
+	}
+	if db.projections.byIndex == nil {
+		db.projections.byIndex = map[string]*projection{}
+		db.projections.bySource = map[string][]*projection{}
+	}
+	pr := &projection{source: namespace, index: index, projection: p}
+	db.projections.byIndex[index] = pr
+	db.projections.bySource[namespace] = append(db.projections.bySource[namespace], pr)
+	db.projections.lock.Unlock()
+
+	meta, err := db.projectionMeta(index)
+	if err == nil && meta != nil && *meta == (projectionMeta{Source: namespace, Version: p.Version()}) {
+		logger.Infof("registered projection of [%s] into [%s], version [%s]", namespace, index, p.Version())
+		return nil
+	}
+	if err == nil {
+		if meta != nil {
+			logger.Infof("index [%s] built by version [%s] of the projection of [%s], rebuild it", index, meta.Version, meta.Source)
+		}
+		err = db.RebuildProjection(index)
+	}
+	if err != nil {
+		db.unregisterProjection(pr)
+		return errors.WithMessagef(err, "failed registering projection of [%s] into [%s]", namespace, index)
+	}
+	logger.Infof("registered projection of [%s] into [%s], version [%s]", namespace, index, p.Version())
+	return nil
+}
+
+func (db *Vault) unregisterProjection(pr *projection) {
+	db.projections.lock.Lock()
+	defer db.projections.lock.Unlock()
+	delete(db.projections.byIndex, pr.index)
+	sources := db.projections.bySource[pr.source]
+	for i, p := range sources {
+		if p == pr {
+			db.projections.bySource[pr.source] = append(sources[:i:i], sources[i+1:]...)
+			break
+		}
+	}
+	if len(db.projections.bySource[pr.source]) == 0 {
+		delete(db.projections.bySource, pr.source)
+	}
+}
+
+// RebuildProjection rebuilds the passed index namespace in a single update of the store: its keys are deleted,
+// then the states of the source namespace are projected again, in key order, and the version of the projection
+// is recorded. Like block commits, the rebuilds wait while the commits are paused for a backup.
+func (db *Vault) RebuildProjection(index string) error {
+	pr := db.projections.index(index)
+	if pr == nil {
+		return errors.Errorf("namespace [%s] is not the index of a projection", index)
+	}
+	db.BeginBlockCommit()
+	defer db.EndBlockCommit()
+
+	db.lockStore()
+	defer db.unlockStore()
+
+	stale, err := db.keys(index)
+	if err != nil {
+		return err
+	}
+	sources, err := db.scan(pr.source, "", "")
+	if err != nil {
+		return err
+	}
+
+	if err := db.store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update for rebuild of [%s] failed", index)
+	}
+	usage := db.newUsageUpdate()
+	overlay := map[string][]byte{}
+	for _, key := range stale {
+		if err := usage.write(index, key, nil, 0, 0); err != nil {
+			return db.discard(errors.Wrapf(err, "failed deleting [%s:%s]", index, key))
+		}
+		overlay[key] = nil
+	}
+	reader := &indexReader{db: db, namespace: index, overlay: overlay}
+	for _, read := range sources {
+		w := fdriver.ProjectedWrite{Key: read.Key, Value: read.Raw, Block: read.Block, TxNum: uint64(read.IndexInBlock)}
+		derived, err := pr.projection.Project(w, reader)
+		if err != nil {
+			return db.discard(errors.Wrapf(err, "projection of [%s:%s] into [%s] failed", pr.source, read.Key, index))
+		}
+		for _, d := range derived {
+			if err := db.writeIndex(usage, reader, d, w.Block, w.TxNum); err != nil {
+				return db.discard(err)
+			}
+		}
+	}
+	raw, err := json.Marshal(&projectionMeta{Source: pr.source, Version: pr.projection.Version()})
+	if err != nil {
+		return db.discard(errors.Wrapf(err, "failed marshalling version of projection [%s]", index))
+	}
+	if err := db.store.SetState(projectionNamespace, index, raw, 0, 0); err != nil {
+		return db.discard(errors.Wrapf(err, "failed storing version of projection [%s]", index))
+	}
+	if err := usage.store(); err != nil {
+		return db.discard(err)
+	}
+	if err := db.store.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing rebuild of [%s] failed", index)
+	}
+	usage.committed()
+	logger.Infof("rebuilt index [%s] from [%d] keys of [%s], version [%s]", index, len(sources), pr.source, pr.projection.Version())
+	return nil
+}
+
+// projectionMeta returns the version of the projection that built the passed index, nil if never built
+func (db *Vault) projectionMeta(index string) (*projectionMeta, error) {
+	db.readLockStore()
+	defer db.storeLock.RUnlock()
+	raw, _, _, err := db.store.GetState(projectionNamespace, index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed retrieving version of projection [%s]", index)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	meta := &projectionMeta{}
+	if err := json.Unmarshal(raw, meta); err != nil {
+		return nil, errors.Wrapf(err, "invalid version of projection [%s]", index)
+	}
+	return meta, nil
+}
+
+// checkIndex returns an error if the passed namespace is the index of a projection, only the vault writes it.
+// It is called by the read-write sets created locally.
+func (db *Vault) checkIndex(namespace string) error {
+	if pr := db.projections.index(namespace); pr != nil {
+		return errors.Errorf("namespace [%s] is the index of the projection of [%s], it cannot be written", namespace, pr.source)
+	}
+	return nil
+}
+
+// projector derives the index writes of an update of the store, before the source writes are applied.
+// db.storeLock must be held exclusively.
+type projector struct {
+	db *Vault
+	// sources holds the source states written so far by the update, to pass their previous value
+	sources map[string]map[string][]byte
+	// indexes holds the readers of the index namespaces written so far by the update
+	indexes map[string]*indexReader
+}
+
+func (db *Vault) newProjector() *projector {
+	return &projector{db: db, sources: map[string]map[string][]byte{}, indexes: map[string]*indexReader{}}
+}
+
+// project writes, as part of the current update of the store, the index writes derived from the passed write
+// by the projections of its namespace. It returns the index writes, nil if the namespace is not projected.
+func (p *projector) project(usage *usageUpdate, w fdriver.StateWrite) ([]fdriver.StateWrite, error) {
+	prs := p.db.projections.of(w.Namespace)
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	previous, ok := p.sources[w.Namespace][w.Key]
+	if !ok {
+		raw, _, _, err := p.db.store.GetState(w.Namespace, w.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed retrieving state [%s:%s]", w.Namespace, w.Key)
+		}
+		previous = raw
+	}
+	if p.sources[w.Namespace] == nil {
+		p.sources[w.Namespace] = map[string][]byte{}
+	}
+	p.sources[w.Namespace][w.Key] = w.Value
+
+	pw := fdriver.ProjectedWrite{Key: w.Key, Block: w.Block, TxNum: w.TxNum}
+	if len(w.Value) != 0 {
+		pw.Value = w.Value
+	}
+	if len(previous) != 0 {
+		pw.Previous = previous
+	}
+	var res []fdriver.StateWrite
+	for _, pr := range prs {
+		reader, ok := p.indexes[pr.index]
+		if !ok {
+			reader = &indexReader{db: p.db, namespace: pr.index, overlay: map[string][]byte{}}
+			p.indexes[pr.index] = reader
+		}
+		derived, err := pr.projection.Project(pw, reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "projection of [%s:%s] into [%s] failed at [%d:%d]", w.Namespace, w.Key, pr.index, w.Block, w.TxNum)
+		}
+		for _, d := range derived {
+			if err := p.db.writeIndex(usage, reader, d, w.Block, w.TxNum); err != nil {
+				return nil, err
+			}
+			res = append(res, fdriver.StateWrite{Namespace: pr.index, Key: d.Key, Value: d.Value, Block: w.Block, TxNum: w.TxNum})
+		}
+	}
+	return res, nil
+}
+
+// projectWrites projects the passed writes of a transaction, in key order so that the projections are deterministic
+func (p *projector) projectWrites(usage *usageUpdate, txWrites writes, block, txNum uint64) (writes, error) {
+	namespaces := make([]string, 0, len(txWrites))
+	for ns := range txWrites {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	derived := writes{}
+	for _, ns := range namespaces {
+		if len(p.db.projections.of(ns)) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(txWrites[ns]))
+		for key := range txWrites[ns] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			res, err := p.project(usage, fdriver.StateWrite{Namespace: ns, Key: key, Value: txWrites[ns][key], Block: block, TxNum: txNum})
+			if err != nil {
+				return nil, err
+			}
+			for _, w := range res {
+				if derived[w.Namespace] == nil {
+					derived[w.Namespace] = namespaceWrites{}
+				}
+				derived[w.Namespace][w.Key] = w.Value
+			}
+		}
+	}
+	return derived, nil
+}
+
+// writeIndex stores, as part of the current update of the store, the passed index write
+func (db *Vault) writeIndex(usage *usageUpdate, reader *indexReader, w fdriver.IndexWrite, block, txNum uint64) error {
+	if len(w.Key) == 0 {
+		return errors.Errorf("projection into [%s] derived an empty key", reader.namespace)
+	}
+	err := usage.write(reader.namespace, w.Key, w.Value, block, txNum)
+	if err == nil {
+		err = db.recordVersion(reader.namespace, w.Key, w.Value, block, txNum)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed writing index [%s:%s]", reader.namespace, w.Key)
+	}
+	reader.overlay[w.Key] = w.Value
+	return nil
+}
+
+// indexReader reads an index namespace, with the writes of the current update of the store
+type indexReader struct {
+	db        *Vault
+	namespace string
+	overlay   map[string][]byte
+}
+
+func (r *indexReader) GetState(key string) ([]byte, error) {
+	if value, ok := r.overlay[key]; ok {
+		if len(value) == 0 {
+			return nil, nil
+		}
+		return value, nil
+	}
+	raw, _, _, err := r.db.store.GetState(r.namespace, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed retrieving state [%s:%s]", r.namespace, key)
+	}
+	return raw, nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// ownerProjection indexes the assets by owner, the value of an asset being its owner.
+// The index holds a key per owner and asset, and a key per owner counting its assets, deleted at zero.
+type ownerProjection struct {
+	version   string
+	projected int
+}
+
+func (p *ownerProjection) Version() string {
+	return p.version
+}
+
+func (p *ownerProjection) IndexNamespace() string {
+	return "owners"
+}
+
+func (p *ownerProjection) Project(w fdriver.ProjectedWrite, index fdriver.IndexReader) ([]fdriver.IndexWrite, error) {
+	p.projected++
+	if string(w.Value) == "bad" {
+		return nil, errors.New("bad owner")
+	}
+	var res []fdriver.IndexWrite
+	count := func(owner string, delta int) error {
+		raw, err := index.GetState(owner)
+		if err != nil {
+			return err
+		}
+		n := len(raw) + delta
+		res = append(res, fdriver.IndexWrite{Key: owner, Value: make([]byte, n)})
+		return nil
+	}
+	if w.Previous != nil {
+		res = append(res, fdriver.IndexWrite{Key: string(w.Previous) + "/" + w.Key})
+		if err := count(string(w.Previous), -1); err != nil {
+			return nil, err
+		}
+	}
+	if w.Value != nil {
+		prefix := ""
+		if p.version == "v2" {
+			prefix = "v2:"
+		}
+		res = append(res, fdriver.IndexWrite{Key: string(w.Value) + "/" + w.Key, Value: []byte(prefix + w.Key)})
+		if err := count(string(w.Value), 1); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// index returns the states of the index, the counters as their length
+func index(t *testing.T, vault *Vault) map[string]string {
+	reads, err := vault.scan("owners", "", "")
+	assert.NoError(t, err)
+	res := map[string]string{}
+	for _, read := range reads {
+		if len(read.Raw) != 0 && read.Raw[0] == 0 {
+			res[read.Key] = string(rune('0' + len(read.Raw)))
+			continue
+		}
+		res[read.Key] = string(read.Raw)
+	}
+	return res
+}
+
+func TestProjection(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("alice")})
+
+	// the states committed before the registration are projected
+	p := &ownerProjection{version: "v1"}
+	assert.NoError(t, vault.RegisterProjection("assets", p))
+	assert.Equal(t, map[string]string{"alice/a": "a", "alice": "1"}, index(t, vault))
+
+	// the writes of a commit see the index as updated by the previous writes of the same commit
+	commitWrites(t, vault, 2, map[string][]byte{"b": []byte("alice"), "c": []byte("bob")})
+	assert.Equal(t, map[string]string{"alice/a": "a", "alice/b": "b", "alice": "2", "bob/c": "c", "bob": "1"}, index(t, vault))
+	commitWrites(t, vault, 3, map[string][]byte{"a": []byte("bob"), "c": nil})
+	assert.Equal(t, map[string]string{"alice/b": "b", "alice": "1", "bob/a": "a", "bob": "1"}, index(t, vault))
+
+	// the index has the height of the source write
+	_, block, _, err := ddb.GetState("owners", "bob/a")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), block)
+
+	// the transactions cannot write the index
+	rws, err := vault.NewRWSet("tx4")
+	assert.NoError(t, err)
+	assert.EqualError(t, rws.SetState("owners", "bob/z", []byte("z")), "namespace [owners] is the index of the projection of [assets], it cannot be written")
+	rws.Done()
+	assert.NoError(t, vault.DiscardTx("tx4"))
+
+	// a projection failing fails the commit, nothing is written
+	rws, err = vault.NewRWSet("tx5")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("assets", "d", []byte("bad")))
+	assert.NoError(t, rws.SetState("assets", "e", []byte("carol")))
+	rws.Done()
+	err = vault.CommitTX("tx5", 5, 0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "projection of [assets:d] into [owners] failed at [5:0]: bad owner")
+	v, _, _, err := ddb.GetState("assets", "e")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	assert.Equal(t, map[string]string{"alice/b": "b", "alice": "1", "bob/a": "a", "bob": "1"}, index(t, vault))
+
+	// the replications and the repairs are projected too
+	assert.NoError(t, vault.ReplicateStates([]fdriver.StateWrite{{Namespace: "assets", Key: "b", Value: []byte("bob"), Block: 6}}))
+	assert.Equal(t, map[string]string{"bob/a": "a", "bob/b": "b", "bob": "2"}, index(t, vault))
+	_, err = vault.RepairStates([]fdriver.StateRepair{{Namespace: "assets", Key: "b", Value: []byte("alice"), Block: 7, LocalBlock: 6}}, fdriver.RepairProvenance{Source: "peer"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice/b": "b", "alice": "1", "bob/a": "a", "bob": "1"}, index(t, vault))
+
+	// the same version is not rebuilt on restart, another one is
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	reopened := New(ddb, tidstore)
+	same := &ownerProjection{version: "v1"}
+	assert.NoError(t, reopened.RegisterProjection("assets", same))
+	assert.Zero(t, same.projected)
+
+	reopened = New(ddb, tidstore)
+	assert.NoError(t, reopened.RegisterProjection("assets", &ownerProjection{version: "v2"}))
+	assert.Equal(t, map[string]string{"alice/b": "v2:b", "alice": "1", "bob/a": "v2:a", "bob": "1"}, index(t, reopened))
+	assert.NoError(t, reopened.RebuildProjection("owners"))
+	assert.Equal(t, map[string]string{"alice/b": "v2:b", "alice": "1", "bob/a": "v2:a", "bob": "1"}, index(t, reopened))
+	assert.EqualError(t, reopened.RebuildProjection("assets"), "namespace [assets] is not the index of a projection")
+}
+
+func TestRegisterProjection(t *testing.T) {
+	vault, _ := newBackupVault(t)
+	assert.EqualError(t, vault.RegisterProjection("owners", &ownerProjection{}), "namespace [owners] cannot be projected into itself")
+	assert.NoError(t, vault.RegisterProjection("assets", &ownerProjection{}))
+	assert.EqualError(t, vault.RegisterProjection("others", &ownerProjection{}), "namespace [owners] is the index of a projection already")
+
+	// the rebuild of a projection failing on the states committed fails the registration
+	vault, _ = newBackupVault(t)
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("bad")})
+	err := vault.RegisterProjection("assets", &ownerProjection{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "projection of [assets:a] into [owners] failed")
+	assert.Nil(t, vault.projections.index("owners"))
+	assert.Empty(t, vault.projections.of("assets"))
+}
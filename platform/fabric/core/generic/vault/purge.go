@@ -0,0 +1,321 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/keys"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// privateNamespacePrefix prefixes the namespaces the private values of the collections are stored in,
+	// see PrivateNamespace
+	privateNamespacePrefix = reservedPrefix + "private"
+	// tombstonePrefix prefixes the keys, in vaultNamespace, of the tombstones of the private keys purged,
+	// stored at the height of the transaction purging them
+	tombstonePrefix = "purged"
+	// privateIndexPrefix prefixes the keys, in vaultNamespace, of the index of the private keys stored by hash,
+	// the purges delivered by the blocks carry the hashes of the keys only
+	privateIndexPrefix = "private"
+)
+
+// PrivateNamespace returns the namespace of the vault the private values of the passed collection of the passed
+// namespace are stored in, if this node keeps them. The purges of the collection delete them.
+// The vault does not get the private values from the blocks, which carry their hashes only: the services keeping
+// the private data of a collection on this node store them in this namespace, in the read-write sets they commit,
+// usually as local transactions (CommitLocalTX). The vault indexes the keys written there by hash.
+func PrivateNamespace(namespace, collection string) string {
+	return privateNamespacePrefix + "." + namespace + "." + collection
+}
+
+// purges maps the namespaces to their collections, and these to the hex encoded hashes of the keys purged,
+// and to the keys, if known: the purges delivered by the blocks carry the hashes only
+type purges map[string]map[string]map[string]string
+
+func (p purges) equals(o purges, nss ...string) error {
+	rKeys := p.keys(nss...)
+	sort.Strings(rKeys)
+	oKeys := o.keys(nss...)
+	sort.Strings(oKeys)
+	if diff := cmp.Diff(rKeys, oKeys); len(diff) != 0 {
+		return errors.Errorf("namespaces do not match [%s]", diff)
+	}
+
+	for _, ns := range rKeys {
+		if len(p[ns]) != len(o[ns]) {
+			return errors.Errorf("number of collections of namespace [%s] do not match [%v]!=[%v]", ns, len(p[ns]), len(o[ns]))
+		}
+		for coll, hashes := range p[ns] {
+			others, ok := o[ns][coll]
+			if !ok || len(hashes) != len(others) {
+				return errors.Errorf("purges of collection [%s:%s] do not match", ns, coll)
+			}
+			for h := range hashes {
+				if _, ok := others[h]; !ok {
+					return errors.Errorf("purge of [%s] not found in collection [%s:%s]", h, ns, coll)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p purges) keys(nss ...string) []string {
+	var res []string
+	for k := range p {
+		if len(nss) == 0 {
+			res = append(res, k)
+			continue
+		}
+
+		for _, s := range nss {
+			if s == k {
+				res = append(res, k)
+				break
+			}
+		}
+	}
+	return res
+}
+
+type purgeSet struct {
+	purges purges
+}
+
+// add records the purge of the passed key hash, the key is empty if not known
+func (p *purgeSet) add(ns, coll string, keyHash []byte, key string) error {
+	if err := keys.ValidateNs(ns); err != nil {
+		return err
+	}
+	if len(coll) == 0 {
+		return errors.Errorf("purge of namespace [%s] without collection", ns)
+	}
+
+	collections, in := p.purges[ns]
+	if !in {
+		collections = map[string]map[string]string{}
+		p.purges[ns] = collections
+	}
+	hashes, in := collections[coll]
+	if !in {
+		hashes = map[string]string{}
+		collections[coll] = hashes
+	}
+	h := hex.EncodeToString(keyHash)
+	if len(key) != 0 || len(hashes[h]) == 0 {
+		hashes[h] = key
+	}
+
+	return nil
+}
+
+// addHashedRwSets records the purges carried by the passed hashed read-write sets of the collections
+func (p *purgeSet) addHashedRwSets(ns string, collections []*rwsetutil.CollHashedRwSet) error {
+	for _, coll := range collections {
+		if coll.HashedRwSet == nil {
+			continue
+		}
+		for _, write := range coll.HashedRwSet.HashedWrites {
+			if !write.IsPurge {
+				continue
+			}
+			if err := p.add(ns, coll.CollectionName, write.KeyHash, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *purgeSet) clear(ns string) {
+	delete(p.purges, ns)
+}
+
+func keyHash(key string) []byte {
+	h := sha256.Sum256([]byte(key))
+	return h[:]
+}
+
+// markPurges adds to the passed read-write set the purges, as in Fabric: writes of the hashes of the keys
+// that delete and purge them
+func markPurges(txRWSet *rwsetutil.TxRwSet, p purges) {
+	namespaces := p.keys()
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		var nsRWSet *rwsetutil.NsRwSet
+		for _, s := range txRWSet.NsRwSets {
+			if s.NameSpace == ns {
+				nsRWSet = s
+				break
+			}
+		}
+		if nsRWSet == nil {
+			nsRWSet = &rwsetutil.NsRwSet{NameSpace: ns, KvRwSet: &kvrwset.KVRWSet{}}
+			txRWSet.NsRwSets = append(txRWSet.NsRwSets, nsRWSet)
+		}
+
+		collections := make([]string, 0, len(p[ns]))
+		for coll := range p[ns] {
+			collections = append(collections, coll)
+		}
+		sort.Strings(collections)
+		for _, coll := range collections {
+			var collRWSet *rwsetutil.CollHashedRwSet
+			for _, s := range nsRWSet.CollHashedRwSets {
+				if s.CollectionName == coll {
+					collRWSet = s
+					break
+				}
+			}
+			if collRWSet == nil {
+				collRWSet = &rwsetutil.CollHashedRwSet{CollectionName: coll, HashedRwSet: &kvrwset.HashedRWSet{}}
+				nsRWSet.CollHashedRwSets = append(nsRWSet.CollHashedRwSets, collRWSet)
+			}
+
+			for _, h := range sortedKeys(p[ns][coll]) {
+				hash, _ := hex.DecodeString(h)
+				marked := false
+				for _, write := range collRWSet.HashedRwSet.HashedWrites {
+					if bytes.Equal(write.KeyHash, hash) {
+						write.IsDelete, write.IsPurge, write.ValueHash = true, true, nil
+						marked = true
+					}
+				}
+				if !marked {
+					collRWSet.HashedRwSet.HashedWrites = append(collRWSet.HashedRwSet.HashedWrites, &kvrwset.KVWriteHash{KeyHash: hash, IsDelete: true, IsPurge: true})
+				}
+			}
+		}
+		sort.Slice(nsRWSet.CollHashedRwSets, func(i, j int) bool {
+			return nsRWSet.CollHashedRwSets[i].CollectionName < nsRWSet.CollHashedRwSets[j].CollectionName
+		})
+	}
+	sort.Slice(txRWSet.NsRwSets, func(i, j int) bool {
+		return txRWSet.NsRwSets[i].NameSpace < txRWSet.NsRwSets[j].NameSpace
+	})
+}
+
+func sortedKeys(m map[string]string) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// PurgedAt returns the height of the transaction that purged the passed private key, false if it has not been purged
+func (db *Vault) PurgedAt(namespace, collection, key string) (uint64, uint64, bool, error) {
+	db.storeLock.RLock()
+	defer db.storeLock.RUnlock()
+
+	txid, block, txNum, err := db.store.GetState(vaultNamespace, tombstoneKey(PrivateNamespace(namespace, collection), hex.EncodeToString(keyHash(key))))
+	if err != nil {
+		return 0, 0, false, errors.WithMessagef(err, "failed reading the tombstone of [%s:%s]", namespace, collection)
+	}
+	if len(txid) == 0 {
+		return 0, 0, false, nil
+	}
+	return block, txNum, true, nil
+}
+
+// purge deletes, as part of the current update of the store, the private values of the keys purged by the passed
+// transaction, if stored, and their versions kept by the history, and records a tombstone for each key at the height
+// of the transaction. It returns the deletions of the private values, for the watches.
+func (db *Vault) purge(usage *usageUpdate, txid string, p purges, block, txNum uint64) (writes, error) {
+	deleted := writes{}
+	for ns, collections := range p {
+		for coll, hashes := range collections {
+			privateNs := PrivateNamespace(ns, coll)
+			stored, err := db.purgedKeys(privateNs, hashes)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range stored {
+				logger.Debugf("purge private key [%s:%s] at height [%d:%d]", ns, coll, block, txNum)
+				if err := usage.write(privateNs, key, nil, block, txNum); err != nil {
+					return nil, errors.Wrapf(err, "failed purging private key of [%s:%s] at height [%d:%d]", ns, coll, block, txNum)
+				}
+				if db.history.enabled(privateNs) {
+					// the heights of the versions are digits, they sort before ':'
+					prefix := privateNs + keys.NamespaceSeparator + hex.EncodeToString([]byte(key)) + keys.NamespaceSeparator
+					if err := db.deleteRange(historyNamespace, prefix, prefix+":"); err != nil {
+						return nil, errors.WithMessagef(err, "failed purging the history of a private key of [%s:%s]", ns, coll)
+					}
+				}
+				if deleted[privateNs] == nil {
+					deleted[privateNs] = namespaceWrites{}
+				}
+				deleted[privateNs][key] = nil
+			}
+			for h := range hashes {
+				if err := db.store.SetState(vaultNamespace, tombstoneKey(privateNs, h), []byte(txid), block, txNum); err != nil {
+					return nil, errors.Wrapf(err, "failed storing the tombstone of a private key of [%s:%s] at height [%d:%d]", ns, coll, block, txNum)
+				}
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// purgedKeys returns the keys of the passed private namespace stored whose hashes are passed. The keys not known
+// are looked up in the index of the private keys.
+func (db *Vault) purgedKeys(privateNs string, hashes map[string]string) ([]string, error) {
+	var res []string
+	for h, key := range hashes {
+		if len(key) == 0 {
+			raw, _, _, err := db.store.GetState(vaultNamespace, privateIndexKey(privateNs, h))
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed reading the index of private namespace [%s]", privateNs)
+			}
+			if len(raw) == 0 {
+				continue
+			}
+			key = string(raw)
+		}
+		v, _, _, err := db.store.GetState(privateNs, key)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed reading private namespace [%s]", privateNs)
+		}
+		if len(v) != 0 {
+			res = append(res, key)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// indexPrivateKey records, as part of the current update of the store, the passed key of the passed namespace
+// in the index of the private keys by hash, if the namespace is a private one, or removes it if deleted
+func (db *Vault) indexPrivateKey(namespace, key string, value []byte, block, txNum uint64) error {
+	if !strings.HasPrefix(namespace, privateNamespacePrefix+".") {
+		return nil
+	}
+	k := privateIndexKey(namespace, hex.EncodeToString(keyHash(key)))
+	if len(value) == 0 {
+		return db.store.DeleteState(vaultNamespace, k)
+	}
+	return db.store.SetState(vaultNamespace, k, []byte(key), block, txNum)
+}
+
+func tombstoneKey(privateNs, hash string) string {
+	return tombstonePrefix + keys.NamespaceSeparator + privateNs + keys.NamespaceSeparator + hash
+}
+
+func privateIndexKey(privateNs, hash string) string {
+	return privateIndexPrefix + keys.NamespaceSeparator + privateNs + keys.NamespaceSeparator + hash
+}
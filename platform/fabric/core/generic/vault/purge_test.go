@@ -0,0 +1,241 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// purgeBytes returns the read-write set of a transaction purging the passed private key
+func purgeBytes(t *testing.T, vault *Vault, txid, ns, coll, key string) []byte {
+	rws, err := vault.NewRWSet(txid)
+	assert.NoError(t, err)
+	assert.NoError(t, rws.PurgePrivateData(ns, coll, key))
+	raw, err := rws.Bytes()
+	assert.NoError(t, err)
+	rws.Done()
+	assert.NoError(t, vault.DiscardTx(txid))
+	return raw
+}
+
+// commitDelivered commits the passed read-write set as the committer does for the transactions delivered by a block
+func commitDelivered(t *testing.T, vault *Vault, txid string, raw []byte, block uint64) {
+	rws, err := vault.GetRWSet(txid, raw)
+	assert.NoError(t, err)
+	rws.Done()
+	assert.NoError(t, vault.CommitTX(txid, block, 0))
+}
+
+func TestPurgeRWSet(t *testing.T) {
+	vault, _ := newBackupVault(t)
+	raw := purgeBytes(t, vault, "tx1", "assets", "secrets", "k1")
+
+	// the purge is a write of the hash of the key in the hashed read-write set of the collection
+	txRWSet := &rwset.TxReadWriteSet{}
+	assert.NoError(t, proto.Unmarshal(raw, txRWSet))
+	rws, err := rwsetutil.TxRwSetFromProtoMsg(txRWSet)
+	assert.NoError(t, err)
+	assert.Len(t, rws.NsRwSets, 1)
+	assert.Equal(t, "assets", rws.NsRwSets[0].NameSpace)
+	assert.Len(t, rws.NsRwSets[0].CollHashedRwSets, 1)
+	coll := rws.NsRwSets[0].CollHashedRwSets[0]
+	assert.Equal(t, "secrets", coll.CollectionName)
+	assert.NotEmpty(t, coll.PvtRwSetHash)
+	assert.Len(t, coll.HashedRwSet.HashedWrites, 1)
+	write := coll.HashedRwSet.HashedWrites[0]
+	assert.Equal(t, keyHash("k1"), write.KeyHash)
+	assert.True(t, write.IsDelete)
+	assert.True(t, write.IsPurge)
+
+	// the purges delivered are recognized
+	i, err := vault.InspectRWSet(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"assets"}, i.Namespaces())
+	delivered, err := vault.GetRWSet("tx2", raw)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"assets"}, delivered.Namespaces())
+	again, err := delivered.Bytes()
+	assert.NoError(t, err)
+	delivered.Done()
+	assert.NoError(t, vault.DiscardTx("tx2"))
+	other := &rwset.TxReadWriteSet{}
+	assert.NoError(t, proto.Unmarshal(again, other))
+	rws, err = rwsetutil.TxRwSetFromProtoMsg(other)
+	assert.NoError(t, err)
+	assert.Equal(t, write.KeyHash, rws.NsRwSets[0].CollHashedRwSets[0].HashedRwSet.HashedWrites[0].KeyHash)
+	assert.True(t, rws.NsRwSets[0].CollHashedRwSets[0].HashedRwSet.HashedWrites[0].IsPurge)
+}
+
+func TestPurgePrivateData(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+	defer ddb.Close()
+	private := PrivateNamespace("assets", "secrets")
+	assert.NoError(t, vault.SetHistoryNamespaces(private))
+
+	commitWrite(t, vault, "tx1", private, "k1", []byte("v1"), 1, 0)
+	commitWrite(t, vault, "tx2", private, "k2", []byte("w1"), 2, 0)
+	commitWrite(t, vault, "tx3", private, "k1", []byte("v2"), 3, 0)
+	changes, cancel, err := vault.WatchKeys(private, "k", fdriver.WatchOptions{})
+	assert.NoError(t, err)
+	defer cancel()
+
+	// the private keys are indexed by hash, the purges delivered by the blocks find them there
+	indexKey := privateIndexKey(private, hex.EncodeToString(keyHash("k1")))
+	raw, _, _, err := ddb.GetState(vaultNamespace, indexKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", string(raw))
+
+	// a purge delivered by a block carries the hash of the key only
+	commitDelivered(t, vault, "tx4", purgeBytes(t, vault, "purge", "assets", "secrets", "k1"), 4)
+
+	raw, _, _, err = ddb.GetState(vaultNamespace, indexKey)
+	assert.NoError(t, err)
+	assert.Nil(t, raw)
+	qe, err := vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	v, err := qe.GetState(private, "k1")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	v, err = qe.GetState(private, "k2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("w1"), v)
+	qe.Done()
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, "k1", change.Key)
+		assert.True(t, change.Deleted)
+		assert.Equal(t, uint64(4), change.Block)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the purge has not been notified")
+	}
+
+	// the tombstone records the height of the purge
+	block, txNum, purged, err := vault.PurgedAt("assets", "secrets", "k1")
+	assert.NoError(t, err)
+	assert.True(t, purged)
+	assert.Equal(t, uint64(4), block)
+	assert.Equal(t, uint64(0), txNum)
+	_, _, purged, err = vault.PurgedAt("assets", "secrets", "k2")
+	assert.NoError(t, err)
+	assert.False(t, purged)
+
+	// the past versions of the key are purged from the history, the other keys keep theirs
+	for _, height := range []uint64{1, 2, 3, 4} {
+		qe, err := vault.NewQueryExecutorAt(height)
+		assert.NoError(t, err)
+		v, err := qe.GetState(private, "k1")
+		assert.NoError(t, err)
+		assert.Nil(t, v, "height %d", height)
+		it, err := qe.GetStateRangeScanIterator(private, "", "")
+		assert.NoError(t, err)
+		var keys []string
+		for {
+			read, err := it.Next()
+			assert.NoError(t, err)
+			if read == nil {
+				break
+			}
+			keys = append(keys, read.Key)
+		}
+		it.Close()
+		qe.Done()
+		if height >= 2 {
+			assert.Equal(t, []string{"k2"}, keys, "height %d", height)
+		} else {
+			assert.Empty(t, keys, "height %d", height)
+		}
+	}
+
+	// a purge of a key not stored records its tombstone only
+	commitDelivered(t, vault, "tx5", purgeBytes(t, vault, "purge-unknown", "assets", "secrets", "k3"), 5)
+	block, _, purged, err = vault.PurgedAt("assets", "secrets", "k3")
+	assert.NoError(t, err)
+	assert.True(t, purged)
+	assert.Equal(t, uint64(5), block)
+
+	// the purges created locally know the key
+	rws, err := vault.NewRWSet("tx6")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.PurgePrivateData("assets", "secrets", "k2"))
+	rws.Done()
+	assert.NoError(t, vault.CommitTX("tx6", 6, 1))
+	qe, err = vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	v, err = qe.GetState(private, "k2")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	qe.Done()
+	block, txNum, _, err = vault.PurgedAt("assets", "secrets", "k2")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), block)
+	assert.Equal(t, uint64(1), txNum)
+}
+
+func TestPurgeQueryExecutors(t *testing.T) {
+	private := PrivateNamespace("assets", "secrets")
+
+	// a snapshot opened before the purge reads the private value until it is done
+	vault, ddb := openBadgerVault(t, "DB-TestPurgeSnapshot", false)
+	defer ddb.Close()
+	commitWrite(t, vault, "tx1", private, "k1", []byte("v1"), 1, 0)
+	qe, err := vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	commitDelivered(t, vault, "tx2", purgeBytes(t, vault, "purge", "assets", "secrets", "k1"), 2)
+	v, err := qe.GetState(private, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	qe.Done()
+	qe, err = vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	v, err = qe.GetState(private, "k1")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	qe.Done()
+
+	// without snapshots, the purge waits for the query executors opened before it
+	locked, lddb := openBadgerVault(t, "DB-TestPurgeLocked", true)
+	defer lddb.Close()
+	commitWrite(t, locked, "tx1", private, "k1", []byte("v1"), 1, 0)
+	raw := purgeBytes(t, locked, "purge", "assets", "secrets", "k1")
+	qe, err = locked.NewQueryExecutor()
+	assert.NoError(t, err)
+	assert.IsType(t, &directQueryExecutor{}, qe)
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		rws, err := locked.GetRWSet("tx2", raw)
+		assert.NoError(t, err)
+		rws.Done()
+		assert.NoError(t, locked.CommitTX("tx2", 2, 0))
+	}()
+	select {
+	case <-committed:
+		t.Fatal("the purge did not wait for the query executor")
+	case <-time.After(100 * time.Millisecond):
+	}
+	v, err = qe.GetState(private, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	qe.Done()
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the purge has not been committed")
+	}
+	_, _, purged, err := locked.PurgedAt("assets", "secrets", "k1")
+	assert.NoError(t, err)
+	assert.True(t, purged)
+}
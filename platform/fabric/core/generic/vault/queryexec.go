@@ -14,9 +14,9 @@ import (
 )
 
 // this file contains all structs that perform DB access. They
-// differ in terms of the results that they return. They are both
-// created with the assumption that a read lock on the vault is held.
-// The lock is released when Done is called.
+// differ in terms of the results that they return. Except for the
+// snapshotQueryExecutor, they are created with the assumption that
+// a read lock on the vault is held. The lock is released when Done is called.
 
 type directQueryExecutor struct {
 	vault *Vault
@@ -46,6 +46,28 @@ func (q *directQueryExecutor) Done() {
 	q.vault.storeLock.RUnlock()
 }
 
+// snapshotQueryExecutor reads from a snapshot of the store, it holds no lock
+type snapshotQueryExecutor struct {
+	snapshot driver.VersionedSnapshot
+}
+
+func (q *snapshotQueryExecutor) GetState(namespace string, key string) ([]byte, error) {
+	v, _, _, err := q.snapshot.GetState(namespace, key)
+	return v, err
+}
+
+func (q *snapshotQueryExecutor) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
+	return q.snapshot.GetStateRangeScanIterator(namespace, startKey, endKey)
+}
+
+func (q *snapshotQueryExecutor) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	return q.snapshot.GetStateMetadata(namespace, key)
+}
+
+func (q *snapshotQueryExecutor) Done() {
+	q.snapshot.Done()
+}
+
 type interceptorQueryExecutor struct {
 	*Vault
 }
@@ -0,0 +1,354 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"sort"
+	"sync"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+// measurePageSize is the number of keys measured under each read lock of the store, see measure
+var measurePageSize = 1000
+
+// quotas accounts the storage taken by the namespaces with a quota, and bounds it
+type quotas struct {
+	lock sync.RWMutex
+	// usage maps the namespaces with a quota to their usage, in bytes
+	usage map[string]uint64
+	// measuring maps the namespaces being measured to their measurement
+	measuring map[string]*measurement
+	// limits maps the namespaces with a quota to it
+	limits map[string]fdriver.NamespaceQuota
+}
+
+// measurement is the measurement of the usage of a namespace, in progress:
+// the keys before next are accounted in the usage of the namespace, the others are not yet
+type measurement struct {
+	next string
+}
+
+func (q *quotas) limit(namespace string) fdriver.NamespaceQuota {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.limits[namespace]
+}
+
+// accounts returns the usage of the passed namespace, and tells if the passed key is accounted in it:
+// the namespace has a quota, and the key has been measured already
+func (q *quotas) accounts(namespace, key string) (uint64, bool) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	usage, ok := q.usage[namespace]
+	if !ok {
+		return 0, false
+	}
+	if m, ok := q.measuring[namespace]; ok && key >= m.next {
+		return usage, false
+	}
+	return usage, true
+}
+
+// SetNamespaceQuota sets the quota of the passed namespace, a zero quota removes it.
+// The usage of a namespace is accounted while it has a quota only: when a quota is set, the namespace is measured
+// in the background, then the commits keep its usage up to date.
+// The quotas are not stored, they are meant to be set from the configuration on startup.
+func (db *Vault) SetNamespaceQuota(namespace string, quota fdriver.NamespaceQuota) error {
+	if len(namespace) == 0 {
+		return errors.New("empty namespace")
+	}
+	if quota.Hard != 0 && quota.Soft > quota.Hard {
+		return errors.Errorf("soft quota [%d] of namespace [%s] exceeds its hard quota [%d]", quota.Soft, namespace, quota.Hard)
+	}
+	db.quotas.lock.Lock()
+	defer db.quotas.lock.Unlock()
+	if quota == (fdriver.NamespaceQuota{}) {
+		delete(db.quotas.limits, namespace)
+		delete(db.quotas.usage, namespace)
+		delete(db.quotas.measuring, namespace)
+		logger.Infof("removed the quota of namespace [%s]", namespace)
+		return nil
+	}
+	if db.quotas.limits == nil {
+		db.quotas.limits = map[string]fdriver.NamespaceQuota{}
+		db.quotas.usage = map[string]uint64{}
+		db.quotas.measuring = map[string]*measurement{}
+	}
+	if _, ok := db.quotas.limits[namespace]; !ok {
+		m := &measurement{}
+		db.quotas.usage[namespace] = 0
+		db.quotas.measuring[namespace] = m
+		go db.measure(namespace, m)
+	}
+	db.quotas.limits[namespace] = quota
+	logger.Infof("set the quota of namespace [%s]: soft [%d] bytes, hard [%d] bytes", namespace, quota.Soft, quota.Hard)
+	return nil
+}
+
+// SetNamespaceQuotas sets the quotas of the passed namespaces, see SetNamespaceQuota
+func (db *Vault) SetNamespaceQuotas(quotas map[string]fdriver.NamespaceQuota) error {
+	for namespace, quota := range quotas {
+		if err := db.SetNamespaceQuota(namespace, quota); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NamespaceUsage returns the usage of the namespaces with a quota, sorted by namespace.
+// The usage of a namespace being measured is the usage of the keys measured so far.
+func (db *Vault) NamespaceUsage() ([]fdriver.NamespaceUsage, error) {
+	db.quotas.lock.RLock()
+	defer db.quotas.lock.RUnlock()
+	usage := make([]fdriver.NamespaceUsage, 0, len(db.quotas.limits))
+	for ns, quota := range db.quotas.limits {
+		_, measuring := db.quotas.measuring[ns]
+		usage = append(usage, fdriver.NamespaceUsage{Namespace: ns, Bytes: db.quotas.usage[ns], Quota: quota, Measuring: measuring})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Namespace < usage[j].Namespace })
+	return usage, nil
+}
+
+// checkQuota returns fdriver.ErrNamespaceQuotaExceeded if the passed namespace has reached its hard quota.
+// It is called by the read-write sets created locally, that hold the read lock of the store.
+func (db *Vault) checkQuota(namespace string) error {
+	db.quotas.lock.RLock()
+	quota := db.quotas.limits[namespace]
+	usage := db.quotas.usage[namespace]
+	db.quotas.lock.RUnlock()
+	if quota.Hard != 0 && usage >= quota.Hard {
+		return &fdriver.ErrNamespaceQuotaExceeded{Namespace: namespace, Usage: usage, Quota: quota.Hard}
+	}
+	return nil
+}
+
+// measure measures the usage of the passed namespace, page by page, until done or the quota of the namespace is
+// removed. Each page is read under the read lock of the store, so that the commits wait for one page at most.
+// In between, the commits account the writes of the keys measured already, the others are measured afterwards.
+func (db *Vault) measure(namespace string, m *measurement) {
+	keys := 0
+	for {
+		done, n, err := db.measurePage(namespace, m)
+		if err != nil {
+			logger.Errorf("failed measuring namespace [%s], its usage accounts the keys before [%s] only: [%s]", namespace, m.next, err)
+			return
+		}
+		keys += n
+		if done {
+			logger.Debugf("measured namespace [%s]: [%d] keys", namespace, keys)
+			return
+		}
+	}
+}
+
+// measurePage accounts the usage of the next page of keys of the passed measurement, and returns true once done
+func (db *Vault) measurePage(namespace string, m *measurement) (bool, int, error) {
+	db.readLockStore()
+	defer db.storeLock.RUnlock()
+
+	it, err := db.store.GetStateRangeScanIterator(namespace, m.next, "")
+	if err != nil {
+		return false, 0, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	defer it.Close()
+	var usage uint64
+	next, n := m.next, 0
+	for ; n < measurePageSize; n++ {
+		read, err := it.Next()
+		if err != nil {
+			return false, 0, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+		}
+		if read == nil {
+			break
+		}
+		usage += entrySize(read.Key, read.Raw)
+		// the key right after the one read
+		next = read.Key + "\x00"
+	}
+
+	db.quotas.lock.Lock()
+	defer db.quotas.lock.Unlock()
+	if db.quotas.measuring[namespace] != m {
+		// the quota has been removed
+		return true, n, nil
+	}
+	db.quotas.usage[namespace] += usage
+	m.next = next
+	if n < measurePageSize {
+		delete(db.quotas.measuring, namespace)
+		db.recordUsage(namespace, db.quotas.usage[namespace])
+		return true, n, nil
+	}
+	return false, n, nil
+}
+
+// usageUpdate applies the writes of an update of the store, accounting them in the usage and in the checksum
+// of their namespaces. db.storeLock must be held exclusively.
+type usageUpdate struct {
+	db *Vault
+	// usage maps the namespaces with a quota written to their usage once the update is committed
+	usage map[string]uint64
+	// sizes maps the keys written to their size once the update is committed
+	sizes map[string]map[string]uint64
+	// checksums maps the namespaces written to their checksum once the update is committed
+	checksums map[string]*checksum
+	// digests maps the keys written to their digest once the update is committed
+	digests map[string]map[string]digest
+	// rederived are the namespaces whose states not written by the update are deleted by it, see rederive
+	rederived map[string]bool
+	// baselines maps the namespaces written whose checksum is being computed to their baseline, see checksumOf
+	baselines map[string]*baseline
+}
+
+func (db *Vault) newUsageUpdate() *usageUpdate {
+	return &usageUpdate{
+		db:        db,
+		usage:     map[string]uint64{},
+		sizes:     map[string]map[string]uint64{},
+		checksums: map[string]*checksum{},
+		digests:   map[string]map[string]digest{},
+		rederived: map[string]bool{},
+		baselines: map[string]*baseline{},
+	}
+}
+
+// rederive tells that the update re-derives the passed namespace: the namespace holds the states written by the
+// update only, its checksum is computed from them, not from the states it had before
+func (u *usageUpdate) rederive(namespace string) {
+	u.checksums[namespace] = &checksum{}
+	u.rederived[namespace] = true
+}
+
+// write stores, as part of the current update of the store, the passed state of the passed key.
+// An empty value deletes the key.
+func (u *usageUpdate) write(namespace, key string, value []byte, block, txNum uint64) error {
+	usage, accounted := u.db.quotas.accounts(namespace, key)
+	if updated, ok := u.usage[namespace]; ok {
+		usage = updated
+	}
+	sum, ok := u.checksums[namespace]
+	if !ok {
+		var b *baseline
+		var err error
+		if sum, b, err = u.db.checksumOf(namespace); err != nil {
+			return err
+		}
+		u.checksums[namespace] = sum
+		if b != nil {
+			u.baselines[namespace] = b
+		}
+	}
+	previous, sized := u.sizes[namespace][key]
+	previousDigest, digested := u.digests[namespace][key]
+	digested = digested || u.rederived[namespace]
+	if !sized || !digested {
+		raw, _, _, err := u.db.store.GetState(namespace, key)
+		if err != nil {
+			return errors.Wrapf(err, "failed retrieving state [%s:%s]", namespace, key)
+		}
+		if !sized {
+			previous = entrySize(key, raw)
+		}
+		if !digested {
+			previousDigest = entryDigest(key, raw)
+		}
+	}
+
+	var err error
+	if len(value) != 0 {
+		err = u.db.store.SetState(namespace, key, value, block, txNum)
+	} else {
+		err = u.db.store.DeleteState(namespace, key)
+	}
+	if err == nil {
+		err = u.db.indexPrivateKey(namespace, key, value, block, txNum)
+	}
+	if err != nil {
+		return err
+	}
+
+	size := entrySize(key, value)
+	if accounted {
+		if previous > usage {
+			previous = usage
+		}
+		u.usage[namespace] = usage - previous + size
+	}
+	if u.sizes[namespace] == nil {
+		u.sizes[namespace] = map[string]uint64{}
+	}
+	u.sizes[namespace][key] = size
+
+	d := entryDigest(key, value)
+	if b, ok := u.baselines[namespace]; !ok || b.covers(key) {
+		sum.remove(previousDigest)
+		sum.add(d)
+		sum.anchor(block, txNum)
+	}
+	if u.digests[namespace] == nil {
+		u.digests[namespace] = map[string]digest{}
+	}
+	u.digests[namespace][key] = d
+	return nil
+}
+
+// store stores, as part of the current update of the store, the checksum of the namespaces written.
+// The checksums still being computed are not stored, see baseline.
+func (u *usageUpdate) store() error {
+	for ns, sum := range u.checksums {
+		if b, ok := u.baselines[ns]; ok && !b.done {
+			continue
+		}
+		raw, err := sum.marshal()
+		if err != nil {
+			return errors.Wrapf(err, "failed marshalling the checksum of [%s]", ns)
+		}
+		if err := u.db.store.SetState(checksumNamespace, ns, raw, 0, 0); err != nil {
+			return errors.Wrapf(err, "failed storing the checksum of [%s]", ns)
+		}
+	}
+	return nil
+}
+
+// committed is called once the update is committed. It records the usage of the namespaces written,
+// and warns about those whose usage has just exceeded their quota.
+func (u *usageUpdate) committed() {
+	u.committedBaselines()
+	for ns, usage := range u.usage {
+		u.db.quotas.lock.Lock()
+		previous, ok := u.db.quotas.usage[ns]
+		if !ok {
+			// the quota has been removed meanwhile
+			u.db.quotas.lock.Unlock()
+			continue
+		}
+		u.db.quotas.usage[ns] = usage
+		quota := u.db.quotas.limits[ns]
+		u.db.quotas.lock.Unlock()
+
+		u.db.recordUsage(ns, usage)
+		if quota.Soft != 0 && usage > quota.Soft && previous <= quota.Soft {
+			logger.Warnf("namespace [%s] uses [%d] bytes, above its soft quota of [%d] bytes", ns, usage, quota.Soft)
+			u.db.recordQuotaExceeded(ns, "soft")
+		}
+		if quota.Hard != 0 && usage >= quota.Hard && previous < quota.Hard {
+			logger.Warnf("namespace [%s] uses [%d] bytes, it has reached its hard quota of [%d] bytes: "+
+				"the transactions created locally can no longer write it", ns, usage, quota.Hard)
+			u.db.recordQuotaExceeded(ns, "hard")
+		}
+	}
+}
+
+// entrySize is the approximate storage taken by the passed state, zero if the key is deleted
+func entrySize(key string, value []byte) uint64 {
+	if len(value) == 0 {
+		return 0
+	}
+	return uint64(len(key) + len(value))
+}
@@ -0,0 +1,171 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func usageOf(t *testing.T, vault *Vault, namespace string) fdriver.NamespaceUsage {
+	usage, err := vault.NamespaceUsage()
+	assert.NoError(t, err)
+	for _, u := range usage {
+		if u.Namespace == namespace {
+			return u
+		}
+	}
+	return fdriver.NamespaceUsage{Namespace: namespace}
+}
+
+// measured waits for the usage of the passed namespace to be measured
+func measured(t *testing.T, vault *Vault, namespace string) fdriver.NamespaceUsage {
+	assert.Eventually(t, func() bool { return !usageOf(t, vault, namespace).Measuring }, 5*time.Second, 10*time.Millisecond)
+	return usageOf(t, vault, namespace)
+}
+
+func TestNamespaceUsage(t *testing.T) {
+	vault, ddb := newBackupVault(t)
+
+	// the namespaces without a quota are not accounted, those with a quota are measured
+	assert.NoError(t, ddb.BeginUpdate())
+	assert.NoError(t, ddb.SetState("assets", "a", []byte("12345"), 1, 0))
+	assert.NoError(t, ddb.Commit())
+	commitWrites(t, vault, 1, map[string][]byte{"b": []byte("1")})
+	usage, err := vault.NamespaceUsage()
+	assert.NoError(t, err)
+	assert.Empty(t, usage)
+	assert.NoError(t, vault.SetNamespaceQuota("assets", fdriver.NamespaceQuota{Soft: 100}))
+	assert.Equal(t, uint64(6+2), measured(t, vault, "assets").Bytes)
+
+	commitWrites(t, vault, 2, map[string][]byte{"a": []byte("1"), "bb": []byte("123")})
+	assert.Equal(t, uint64(2+2+5), usageOf(t, vault, "assets").Bytes)
+	commitWrites(t, vault, 3, map[string][]byte{"a": nil})
+	assert.Equal(t, uint64(2+5), usageOf(t, vault, "assets").Bytes)
+
+	// the usage is kept in memory, the namespace is measured again on restart
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	reopened := New(ddb, tidstore)
+	assert.NoError(t, reopened.SetNamespaceQuotas(map[string]fdriver.NamespaceQuota{"assets": {Hard: 100}, "other": {Soft: 10}}))
+	measured(t, reopened, "assets")
+	measured(t, reopened, "other")
+	usage, err = reopened.NamespaceUsage()
+	assert.NoError(t, err)
+	assert.Equal(t, []fdriver.NamespaceUsage{
+		{Namespace: "assets", Bytes: 7, Quota: fdriver.NamespaceQuota{Hard: 100}},
+		{Namespace: "other", Quota: fdriver.NamespaceQuota{Soft: 10}},
+	}, usage)
+
+	// the repairs and the replications are accounted too
+	_, err = reopened.RepairStates([]fdriver.StateRepair{{Namespace: "assets", Key: "bb", Value: []byte("1"), Block: 4, LocalBlock: 2}}, fdriver.RepairProvenance{Source: "peer"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2+3), usageOf(t, reopened, "assets").Bytes)
+	assert.NoError(t, reopened.ReplicateStates([]fdriver.StateWrite{{Namespace: "assets", Key: "c", Value: []byte("12"), Block: 5}}, "assets"))
+	assert.Equal(t, uint64(3), usageOf(t, reopened, "assets").Bytes)
+}
+
+func TestNamespaceMeasure(t *testing.T) {
+	defer func(size int) { measurePageSize = size }(measurePageSize)
+	measurePageSize = 1
+	vault, _ := newBackupVault(t)
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("1"), "b": []byte("12"), "c": []byte("123")})
+
+	// the commits in between the pages account the keys measured already only
+	m := &measurement{}
+	vault.quotas.limits = map[string]fdriver.NamespaceQuota{"assets": {Soft: 100}}
+	vault.quotas.usage = map[string]uint64{"assets": 0}
+	vault.quotas.measuring = map[string]*measurement{"assets": m}
+	done, _, err := vault.measurePage("assets", m)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, uint64(2), usageOf(t, vault, "assets").Bytes)
+	commitWrites(t, vault, 2, map[string][]byte{"a": []byte("1234"), "c": nil, "d": []byte("1")})
+	assert.Equal(t, uint64(5), usageOf(t, vault, "assets").Bytes)
+	for !done {
+		done, _, err = vault.measurePage("assets", m)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, fdriver.NamespaceUsage{Namespace: "assets", Bytes: 5 + 3 + 2, Quota: fdriver.NamespaceQuota{Soft: 100}}, usageOf(t, vault, "assets"))
+
+	// a measurement stops once the quota is removed
+	assert.NoError(t, vault.SetNamespaceQuota("assets", fdriver.NamespaceQuota{}))
+	done, _, err = vault.measurePage("assets", m)
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.Empty(t, vault.quotas.usage)
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	vault, _ := newBackupVault(t)
+	histogram := &metricsfakes.Histogram{}
+	histogram.WithReturns(histogram)
+	usage := &metricsfakes.Gauge{}
+	usage.WithReturns(usage)
+	exceeded := &metricsfakes.Counter{}
+	exceeded.WithReturns(exceeded)
+	vault.SetMetrics(&Metrics{ReaderWaitDuration: histogram, WriterStallDuration: histogram, NamespaceBytes: usage, QuotaExceeded: exceeded}, "mynetwork", "mychannel")
+
+	assert.Error(t, vault.SetNamespaceQuota("assets", fdriver.NamespaceQuota{Soft: 20, Hard: 10}))
+	assert.NoError(t, vault.SetNamespaceQuotas(map[string]fdriver.NamespaceQuota{"assets": {Soft: 5, Hard: 10}}))
+	measured(t, vault, "assets")
+	assert.Equal(t, 1, usage.SetCallCount())
+	assert.Equal(t, float64(0), usage.SetArgsForCall(0))
+
+	// above the soft quota, the commit warns
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("12345")})
+	assert.Equal(t, 2, usage.SetCallCount())
+	assert.Equal(t, float64(6), usage.SetArgsForCall(1))
+	assert.Equal(t, []string{"network", "mynetwork", "channel", "mychannel", "namespace", "assets"}, usage.WithArgsForCall(1))
+	assert.Equal(t, 1, exceeded.AddCallCount())
+	assert.Equal(t, []string{"network", "mynetwork", "channel", "mychannel", "namespace", "assets", "quota", "soft"}, exceeded.WithArgsForCall(0))
+
+	// the writes delivered by the blocks are applied above the hard quota
+	other, _ := newBackupVault(t)
+	rws, err := other.NewRWSet("tx2")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("assets", "b", []byte("12345")))
+	raw, err := rws.Bytes()
+	assert.NoError(t, err)
+	rws.Done()
+	delivered, err := vault.GetRWSet("tx2", raw)
+	assert.NoError(t, err)
+	delivered.Done()
+	assert.NoError(t, vault.CommitTX("tx2", 2, 0))
+	assert.Equal(t, uint64(12), usageOf(t, vault, "assets").Bytes)
+	assert.Equal(t, 2, exceeded.AddCallCount())
+	assert.Equal(t, []string{"network", "mynetwork", "channel", "mychannel", "namespace", "assets", "quota", "hard"}, exceeded.WithArgsForCall(1))
+
+	// the read-write sets created locally can no longer write the namespace, but can delete from it
+	rws, err = vault.NewRWSet("tx3")
+	assert.NoError(t, err)
+	err = rws.SetState("assets", "c", []byte("1"))
+	quotaErr := &fdriver.ErrNamespaceQuotaExceeded{}
+	assert.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, fdriver.ErrNamespaceQuotaExceeded{Namespace: "assets", Usage: 12, Quota: 10}, *quotaErr)
+	assert.NoError(t, rws.SetState("others", "c", []byte("1")))
+	assert.NoError(t, rws.DeleteState("assets", "b"))
+	rws.Done()
+	assert.NoError(t, vault.CommitTX("tx3", 3, 0))
+	assert.Equal(t, uint64(6), usageOf(t, vault, "assets").Bytes)
+
+	// below the hard quota, or without quota, the namespace can be written again
+	commitWrites(t, vault, 4, map[string][]byte{"b": []byte("1")})
+	assert.NoError(t, vault.SetNamespaceQuota("assets", fdriver.NamespaceQuota{}))
+	commitWrites(t, vault, 5, map[string][]byte{"c": []byte("123456789")})
+	assert.Equal(t, fdriver.NamespaceUsage{Namespace: "assets"}, usageOf(t, vault, "assets"))
+	assert.NoError(t, vault.SetNamespaceQuota("assets", fdriver.NamespaceQuota{Soft: 100}))
+	assert.Equal(t, uint64(18), measured(t, vault, "assets").Bytes)
+	assert.Equal(t, 2, exceeded.AddCallCount())
+}
@@ -0,0 +1,124 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"encoding/json"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/keys"
+	"github.com/pkg/errors"
+)
+
+// repairNamespace is the reserved namespace the provenance of the repairs is stored in
+const repairNamespace = "vault-repairs"
+
+// RepairStates applies the passed repairs in a single update of the store and records, for each repaired key,
+// the provenance of the repair and the state the key had before it.
+// A repair is skipped if the version of its key is no longer the one the drift has been detected at:
+// the key has been committed again in the meantime and the new commit prevails.
+// Like block commits, the repairs wait while the commits are paused for a backup.
+func (db *Vault) RepairStates(repairs []fdriver.StateRepair, provenance fdriver.RepairProvenance) ([]fdriver.StateRepair, error) {
+	if len(repairs) == 0 {
+		return nil, nil
+	}
+	db.BeginBlockCommit()
+	defer db.EndBlockCommit()
+
+	db.lockStore()
+	defer db.unlockStore()
+
+	if err := db.store.BeginUpdate(); err != nil {
+		return nil, errors.WithMessagef(err, "begin update for repairs failed")
+	}
+	usage := db.newUsageUpdate()
+	projector := db.newProjector()
+	var applied []fdriver.StateRepair
+	for _, repair := range repairs {
+		ok, err := db.repairState(usage, projector, repair, provenance)
+		if err != nil {
+			if err1 := db.store.Discard(); err1 != nil {
+				logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+			}
+			return nil, err
+		}
+		if ok {
+			applied = append(applied, repair)
+		}
+	}
+	if err := usage.store(); err != nil {
+		return nil, db.discard(err)
+	}
+	if err := db.store.Commit(); err != nil {
+		return nil, errors.WithMessagef(err, "committing repairs failed")
+	}
+	usage.committed()
+	logger.Infof("repaired [%d] of [%d] keys from [%s]", len(applied), len(repairs), provenance.Source)
+	return applied, nil
+}
+
+func (db *Vault) repairState(usage *usageUpdate, projector *projector, repair fdriver.StateRepair, provenance fdriver.RepairProvenance) (bool, error) {
+	previous, block, txNum, err := db.store.GetState(repair.Namespace, repair.Key)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed retrieving state [%s:%s]", repair.Namespace, repair.Key)
+	}
+	if block != repair.LocalBlock || txNum != repair.LocalTxNum {
+		logger.Infof("skip repair of [%s:%s], committed at [%d:%d] since the drift has been detected at [%d:%d]",
+			repair.Namespace, repair.Key, block, txNum, repair.LocalBlock, repair.LocalTxNum)
+		return false, nil
+	}
+
+	_, err = projector.project(usage, fdriver.StateWrite{Namespace: repair.Namespace, Key: repair.Key, Value: repair.Value, Block: repair.Block, TxNum: repair.TxNum})
+	if err == nil {
+		err = usage.write(repair.Namespace, repair.Key, repair.Value, repair.Block, repair.TxNum)
+	}
+	if err == nil {
+		err = db.recordVersion(repair.Namespace, repair.Key, repair.Value, repair.Block, repair.TxNum)
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed repairing [%s:%s]", repair.Namespace, repair.Key)
+	}
+
+	raw, err := json.Marshal(&fdriver.RepairRecord{
+		RepairProvenance: provenance,
+		Namespace:        repair.Namespace,
+		Key:              repair.Key,
+		Previous:         previous,
+		PreviousBlock:    block,
+		PreviousTxNum:    txNum,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed marshalling provenance of [%s:%s]", repair.Namespace, repair.Key)
+	}
+	if err := db.store.SetState(repairNamespace, repairKey(repair.Namespace, repair.Key), raw, repair.Block, repair.TxNum); err != nil {
+		return false, errors.Wrapf(err, "failed storing provenance of [%s:%s]", repair.Namespace, repair.Key)
+	}
+	return true, nil
+}
+
+// RepairRecord returns the provenance of the last repair of the passed key, nil if the key has never been repaired
+func (db *Vault) RepairRecord(namespace, key string) (*fdriver.RepairRecord, error) {
+	db.storeLock.RLock()
+	defer db.storeLock.RUnlock()
+
+	raw, _, _, err := db.store.GetState(repairNamespace, repairKey(namespace, key))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed retrieving provenance of [%s:%s]", namespace, key)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	record := &fdriver.RepairRecord{}
+	if err := json.Unmarshal(raw, record); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling provenance of [%s:%s]", namespace, key)
+	}
+	return record, nil
+}
+
+func repairKey(namespace, key string) string {
+	return namespace + keys.NamespaceSeparator + key
+}
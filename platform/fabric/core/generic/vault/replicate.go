@@ -0,0 +1,122 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
+)
+
+// ReplicateStates applies the passed writes, replicated from another node, in a single update of the store,
+// with the version they have on that node.
+// The keys of the reset namespaces that are not written are deleted first, and their checksum is re-derived from
+// the writes: a namespace failing its integrity check is repaired by replicating it from another node.
+// Like block commits, the replications wait while the commits are paused for a backup.
+func (db *Vault) ReplicateStates(writes []fdriver.StateWrite, reset ...string) error {
+	if len(writes) == 0 && len(reset) == 0 {
+		return nil
+	}
+	return db.replicate(writes, reset, nil)
+}
+
+// replicate applies the passed writes as ReplicateStates does, once the passed check, if not nil, passes under the
+// exclusive lock of the store
+func (db *Vault) replicate(writes []fdriver.StateWrite, reset []string, check func() error) error {
+	db.BeginBlockCommit()
+	defer db.EndBlockCommit()
+
+	db.lockStore()
+	defer db.unlockStore()
+
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+
+	written := map[string]map[string]bool{}
+	for _, w := range writes {
+		if written[w.Namespace] == nil {
+			written[w.Namespace] = map[string]bool{}
+		}
+		written[w.Namespace][w.Key] = true
+	}
+	// the stale keys are deleted at the height of the last write
+	var last fdriver.StateWrite
+	for _, w := range writes {
+		if w.Block > last.Block || (w.Block == last.Block && w.TxNum > last.TxNum) {
+			last = w
+		}
+	}
+	var stale []fdriver.StateWrite
+	for _, ns := range reset {
+		keys, err := db.keys(ns)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !written[ns][key] {
+				stale = append(stale, fdriver.StateWrite{Namespace: ns, Key: key, Block: last.Block, TxNum: last.TxNum})
+			}
+		}
+	}
+
+	if err := db.store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update for replication failed")
+	}
+	usage := db.newUsageUpdate()
+	for _, ns := range reset {
+		usage.rederive(ns)
+	}
+	projector := db.newProjector()
+	for _, w := range append(stale, writes...) {
+		_, err := projector.project(usage, w)
+		if err != nil {
+			logger.Errorf("failed projecting the replicated write of [%s:%s]: [%s]", w.Namespace, w.Key, err)
+			return db.discard(err)
+		}
+		err = usage.write(w.Namespace, w.Key, w.Value, w.Block, w.TxNum)
+		if err == nil {
+			err = db.recordVersion(w.Namespace, w.Key, w.Value, w.Block, w.TxNum)
+		}
+		if err != nil {
+			if err1 := db.store.Discard(); err1 != nil {
+				logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+			}
+			return errors.Wrapf(err, "failed replicating [%s:%s]", w.Namespace, w.Key)
+		}
+	}
+	if err := usage.store(); err != nil {
+		return db.discard(err)
+	}
+	if err := db.store.Commit(); err != nil {
+		return errors.WithMessagef(err, "committing replication failed")
+	}
+	usage.committed()
+	logger.Debugf("replicated [%d] writes, [%d] stale keys deleted", len(writes), len(stale))
+	return nil
+}
+
+// keys returns the keys of the passed namespace in the store
+func (db *Vault) keys(namespace string) ([]string, error) {
+	it, err := db.store.GetStateRangeScanIterator(namespace, "", "")
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	defer it.Close()
+	var keys []string
+	for {
+		read, err := it.Next()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+		}
+		if read == nil {
+			return keys, nil
+		}
+		keys = append(keys, read.Key)
+	}
+}
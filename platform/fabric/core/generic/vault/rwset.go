@@ -22,6 +22,7 @@ type readWriteSet struct {
 	readSet
 	writeSet
 	metaWriteSet
+	purgeSet
 }
 
 func (rws *readWriteSet) populate(rwsetBytes []byte, txid string, namespaces ...string) error {
@@ -79,6 +80,10 @@ func (rws *readWriteSet) populate(rwsetBytes []byte, txid string, namespaces ...
 				return err
 			}
 		}
+
+		if err := rws.purgeSet.addHashedRwSets(ns, nsrws.CollHashedRwSets); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,122 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/mocks"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedPersistence hides the snapshots of the persistence it wraps, the query executors take the lock of the vault
+type lockedPersistence struct {
+	driver.VersionedPersistence
+}
+
+func openBadgerVault(t testing.TB, name string, locked bool) (*Vault, driver.VersionedPersistence) {
+	c := &mocks.Config{}
+	c.UnmarshalKeyReturns(nil)
+	c.IsSetReturns(false)
+	ddb, err := db.OpenVersioned(nil, "badger", filepath.Join(tempDir, name), c)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	var store driver.VersionedPersistence = ddb
+	if locked {
+		store = &lockedPersistence{VersionedPersistence: ddb}
+	}
+	return New(store, tidstore), ddb
+}
+
+func commitWrite(t testing.TB, vault *Vault, txid, ns, key string, value []byte, block uint64, indexInBlock int) {
+	rws, err := vault.NewRWSet(txid)
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState(ns, key, value))
+	rws.Done()
+	assert.NoError(t, vault.CommitTX(txid, block, indexInBlock))
+}
+
+func TestSnapshotQueryExecutor(t *testing.T) {
+	ns := "namespace"
+	vault, ddb := openBadgerVault(t, "DB-TestSnapshotQueryExecutor", false)
+	defer ddb.Close()
+	commitWrite(t, vault, "tx1", ns, "k1", []byte("v1"), 35, 1)
+
+	qe, err := vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	assert.IsType(t, &snapshotQueryExecutor{}, qe)
+
+	// the commit does not wait for the query executor
+	committed := make(chan struct{})
+	go func() {
+		commitWrite(t, vault, "tx2", ns, "k1", []byte("v2"), 36, 0)
+		close(committed)
+	}()
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the commit waited for the query executor")
+	}
+
+	// the query executor reads the state committed when it was created
+	v, err := qe.GetState(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	itr, err := qe.GetStateRangeScanIterator(ns, "", "")
+	assert.NoError(t, err)
+	read, err := itr.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(35), read.Block)
+	itr.Close()
+	qe.Done()
+
+	qe, err = vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	defer qe.Done()
+	v, err = qe.GetState(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2"), v)
+}
+
+func TestContentionMetrics(t *testing.T) {
+	ns := "namespace"
+	vault, ddb := openBadgerVault(t, "DB-TestContentionMetrics", true)
+	defer ddb.Close()
+	readerWait := &metricsfakes.Histogram{}
+	readerWait.WithReturns(readerWait)
+	writerStall := &metricsfakes.Histogram{}
+	writerStall.WithReturns(writerStall)
+	vault.SetMetrics(&Metrics{ReaderWaitDuration: readerWait, WriterStallDuration: writerStall}, "mynetwork", "mychannel")
+
+	// without snapshots, the query executor holds the lock of the vault and the commit stalls
+	qe, err := vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	assert.IsType(t, &directQueryExecutor{}, qe)
+	assert.Equal(t, 1, readerWait.ObserveCallCount())
+	assert.Equal(t, []string{"network", "mynetwork", "channel", "mychannel"}, readerWait.WithArgsForCall(0))
+
+	committed := make(chan struct{})
+	go func() {
+		commitWrite(t, vault, "tx1", ns, "k1", []byte("v1"), 35, 1)
+		close(committed)
+	}()
+	// the writer starts waiting for the lock some time after the goroutine is spawned
+	time.Sleep(200 * time.Millisecond)
+	qe.Done()
+	<-committed
+
+	assert.Equal(t, 1, writerStall.ObserveCallCount())
+	assert.GreaterOrEqual(t, writerStall.ObserveArgsForCall(0), (100 * time.Millisecond).Seconds())
+	assert.Equal(t, []string{"network", "mynetwork", "channel", "mychannel"}, writerStall.WithArgsForCall(0))
+}
@@ -7,7 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package txidstore
 
 import (
+	"time"
+
 	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/pkg/errors"
 )
 
 type cache interface {
@@ -19,6 +22,21 @@ type txidStore interface {
 	fdriver.TXIDStore
 	Get(txid string) (fdriver.ValidationCode, error)
 	Set(txid string, code fdriver.ValidationCode) error
+	GetWithHeight(txid string) (fdriver.ValidationCode, uint64, int, error)
+	SetWithHeight(txid string, code fdriver.ValidationCode, block uint64, txNum int) error
+}
+
+type statusLister interface {
+	ListStatuses(filter fdriver.StatusFilter, page fdriver.PageToken) (*fdriver.StatusPage, error)
+}
+
+type timestampReader interface {
+	GetTimestamp(txid string) (time.Time, error)
+}
+
+type outcomeRecorder interface {
+	SetWithOutcome(txid string, code fdriver.ValidationCode, block uint64, txNum int, fabricCode int32, message string) error
+	GetOutcome(txid string) (int32, string, bool, error)
 }
 
 type Cache struct {
@@ -52,6 +70,20 @@ func (s *Cache) Set(txid string, code fdriver.ValidationCode) error {
 	return nil
 }
 
+// GetWithHeight returns the validation code and the height of the passed transaction.
+// Heights are not cached.
+func (s *Cache) GetWithHeight(txid string) (fdriver.ValidationCode, uint64, int, error) {
+	return s.backed.GetWithHeight(txid)
+}
+
+func (s *Cache) SetWithHeight(txid string, code fdriver.ValidationCode, block uint64, txNum int) error {
+	if err := s.backed.SetWithHeight(txid, code, block, txNum); err != nil {
+		return err
+	}
+	s.cache.Add(txid, code)
+	return nil
+}
+
 func (s *Cache) GetLastTxID() (string, error) {
 	return s.backed.GetLastTxID()
 }
@@ -59,3 +91,43 @@ func (s *Cache) GetLastTxID() (string, error) {
 func (s *Cache) Iterator(pos interface{}) (fdriver.TxidIterator, error) {
 	return s.backed.Iterator(pos)
 }
+
+// ListStatuses lists the statuses of the backed store, statuses are not cached
+func (s *Cache) ListStatuses(filter fdriver.StatusFilter, page fdriver.PageToken) (*fdriver.StatusPage, error) {
+	lister, ok := s.backed.(statusLister)
+	if !ok {
+		return nil, errors.Errorf("listing statuses not supported")
+	}
+	return lister.ListStatuses(filter, page)
+}
+
+// GetTimestamp returns when the status of the passed transaction has been set in the backed store, timestamps are not cached
+func (s *Cache) GetTimestamp(txid string) (time.Time, error) {
+	reader, ok := s.backed.(timestampReader)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return reader.GetTimestamp(txid)
+}
+
+// SetWithOutcome sets the validation code of the passed transaction, and the outcome, in the backed store
+func (s *Cache) SetWithOutcome(txid string, code fdriver.ValidationCode, block uint64, txNum int, fabricCode int32, message string) error {
+	recorder, ok := s.backed.(outcomeRecorder)
+	if !ok {
+		return s.SetWithHeight(txid, code, block, txNum)
+	}
+	if err := recorder.SetWithOutcome(txid, code, block, txNum, fabricCode, message); err != nil {
+		return err
+	}
+	s.cache.Add(txid, code)
+	return nil
+}
+
+// GetOutcome returns the outcome of the passed transaction recorded in the backed store, outcomes are not cached
+func (s *Cache) GetOutcome(txid string) (int32, string, bool, error) {
+	recorder, ok := s.backed.(outcomeRecorder)
+	if !ok {
+		return 0, "", false, nil
+	}
+	return recorder.GetOutcome(txid)
+}
@@ -9,6 +9,7 @@ package txidstore
 import (
 	"encoding/binary"
 	"math"
+	"time"
 
 	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
@@ -27,6 +28,13 @@ const (
 type SimpleTXIDStore struct {
 	persistence driver.Persistence
 	ctr         uint64
+	// lastTimestamp is the timestamp of the last status set, timestamps never go backwards
+	lastTimestamp int64
+	now           func() time.Time
+	// written are the statuses set in the ongoing update, the reads do not see them yet.
+	// They are forgotten once the counter committed reaches writtenUpTo.
+	written     map[string]*ByTxid
+	writtenUpTo uint64
 }
 
 func keyByCtr(ctr uint64) string {
@@ -91,10 +99,43 @@ func NewTXIDStore(persistence driver.Persistence) (*SimpleTXIDStore, error) {
 		ctrBytes = make([]byte, binary.MaxVarintLen64)
 	}
 
-	return &SimpleTXIDStore{
+	if err := BuildStatusIndex(persistence, nil); err != nil {
+		return nil, err
+	}
+
+	s := &SimpleTXIDStore{
 		persistence: persistence,
 		ctr:         getCtrFromBytes(ctrBytes),
-	}, nil
+		now:         time.Now,
+		written:     map[string]*ByTxid{},
+	}
+	if s.lastTimestamp, err = s.lastSetAt(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// lastSetAt returns the timestamp of the last status set, zero if none
+func (s *SimpleTXIDStore) lastSetAt() (int64, error) {
+	if s.ctr == 0 {
+		return 0, nil
+	}
+	raw, err := s.persistence.GetState(txidNamespace, keyByCtr(s.ctr-1))
+	if err != nil {
+		return 0, errors.Errorf("error retrieving last ByNum [%s]", err.Error())
+	}
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	bn := &ByNum{}
+	if err := proto.Unmarshal(raw, bn); err != nil {
+		return 0, errors.Errorf("error unmarshalling last ByNum [%s]", err.Error())
+	}
+	bt, err := s.get(bn.Txid)
+	if err != nil || bt == nil {
+		return 0, err
+	}
+	return bt.Timestamp, nil
 }
 
 func (s *SimpleTXIDStore) get(txid string) (*ByTxid, error) {
@@ -129,15 +170,74 @@ func (s *SimpleTXIDStore) Get(txid string) (fdriver.ValidationCode, error) {
 	return fdriver.ValidationCode(bt.Code), nil
 }
 
+// GetWithHeight returns the validation code of the passed transaction together with the block number and the
+// position in the block where it has been committed.
+// Records stored before heights were recorded, or set without height, return fdriver.UnknownBlock and fdriver.UnknownTxNum.
+func (s *SimpleTXIDStore) GetWithHeight(txid string) (fdriver.ValidationCode, uint64, int, error) {
+	bt, err := s.get(txid)
+	if err != nil {
+		return fdriver.Unknown, fdriver.UnknownBlock, fdriver.UnknownTxNum, err
+	}
+
+	if bt == nil {
+		return fdriver.Unknown, fdriver.UnknownBlock, fdriver.UnknownTxNum, nil
+	}
+	if !bt.HasHeight {
+		return fdriver.ValidationCode(bt.Code), fdriver.UnknownBlock, fdriver.UnknownTxNum, nil
+	}
+
+	return fdriver.ValidationCode(bt.Code), bt.Block, int(bt.TxNum), nil
+}
+
 func (s *SimpleTXIDStore) Set(txid string, code fdriver.ValidationCode) error {
+	return s.set(txid, code, &ByTxid{}, true)
+}
+
+// SetLocal sets the validation code of the passed transaction, committed locally and never ordered.
+// Unlike Set, it does not make the transaction the last one committed, the one the delivery restarts from.
+func (s *SimpleTXIDStore) SetLocal(txid string, code fdriver.ValidationCode) error {
+	return s.set(txid, code, &ByTxid{}, false)
+}
+
+// SetWithHeight sets the validation code of the passed transaction and records where it has been committed
+func (s *SimpleTXIDStore) SetWithHeight(txid string, code fdriver.ValidationCode, block uint64, txNum int) error {
+	return s.set(txid, code, &ByTxid{Block: block, TxNum: uint64(txNum), HasHeight: true}, true)
+}
+
+// SetWithOutcome sets the validation code of the passed transaction, records where it has been invalidated,
+// and the Fabric validation code the peers assigned to it, together with the passed message
+func (s *SimpleTXIDStore) SetWithOutcome(txid string, code fdriver.ValidationCode, block uint64, txNum int, fabricCode int32, message string) error {
+	return s.set(txid, code, &ByTxid{Block: block, TxNum: uint64(txNum), HasHeight: true, FabricCode: fabricCode, HasFabricCode: true, Message: message}, true)
+}
+
+// GetOutcome returns the Fabric validation code and the message recorded for the passed transaction.
+// The returned flag is false if none has been recorded.
+func (s *SimpleTXIDStore) GetOutcome(txid string) (int32, string, bool, error) {
+	bt, err := s.get(txid)
+	if err != nil {
+		return 0, "", false, err
+	}
+	if bt == nil || !bt.HasFabricCode {
+		return 0, "", false, nil
+	}
+	return bt.FabricCode, bt.Message, true, nil
+}
+
+func (s *SimpleTXIDStore) set(txid string, code fdriver.ValidationCode, bt *ByTxid, last bool) error {
 	// NOTE: we assume that the commit is in progress so no need to update/commit
 	// err := s.persistence.BeginUpdate()
 	// if err != nil {
 	// 	return errors.Errorf("error starting update to set txid %s [%s]", txid, err.Error())
 	// }
 
+	previous, err := s.previous(txid)
+	if err != nil {
+		s.persistence.Discard()
+		return err
+	}
+
 	// 1: increment ctr in persistence
-	err := setCtr(s.persistence, s.ctr+1)
+	err = setCtr(s.persistence, s.ctr+1)
 	if err != nil {
 		s.persistence.Discard()
 		return errors.Errorf("error storing updated counter for txid %s [%s]", txid, err.Error())
@@ -159,10 +259,10 @@ func (s *SimpleTXIDStore) Set(txid string, code fdriver.ValidationCode) error {
 	}
 
 	// 3: store by txid
-	byTxidBytes, err := proto.Marshal(&ByTxid{
-		Pos:  s.ctr,
-		Code: int32(code),
-	})
+	bt.Pos = s.ctr
+	bt.Code = int32(code)
+	bt.Timestamp = s.timestamp()
+	byTxidBytes, err := proto.Marshal(bt)
 	if err != nil {
 		s.persistence.Discard()
 		return errors.Errorf("error marshalling ByTxid for txid %s [%s]", txid, err.Error())
@@ -173,7 +273,22 @@ func (s *SimpleTXIDStore) Set(txid string, code fdriver.ValidationCode) error {
 		return errors.Errorf("error storing ByTxid for txid %s [%s]", txid, err.Error())
 	}
 
-	if code == fdriver.Valid {
+	// 4: move the transaction in the indexes by status
+	for _, p := range previous {
+		if err := deleteByStatus(s.persistence, txid, p); err != nil {
+			s.persistence.Discard()
+			return err
+		}
+	}
+	err = setByStatus(s.persistence, txid, bt)
+	if err != nil {
+		s.persistence.Discard()
+		return err
+	}
+	s.written[txid] = bt
+	s.writtenUpTo = bt.Pos + 1
+
+	if code == fdriver.Valid && last {
 		err = s.persistence.SetState(txidNamespace, lastTX, []byte(txid))
 		if err != nil {
 			s.persistence.Discard()
@@ -191,6 +306,32 @@ func (s *SimpleTXIDStore) Set(txid string, code fdriver.ValidationCode) error {
 	return nil
 }
 
+// previous returns the statuses of the passed transaction whose index entries must be removed when it is set again:
+// the one committed and, if set again in the ongoing update, or in an update discarded, the one set there
+func (s *SimpleTXIDStore) previous(txid string) ([]*ByTxid, error) {
+	if len(s.written) != 0 {
+		committed, err := getCtr(s.persistence)
+		if err != nil {
+			return nil, err
+		}
+		if committed >= s.writtenUpTo {
+			s.written = map[string]*ByTxid{}
+		}
+	}
+	var res []*ByTxid
+	bt, err := s.get(txid)
+	if err != nil {
+		return nil, err
+	}
+	if bt != nil {
+		res = append(res, bt)
+	}
+	if w, ok := s.written[txid]; ok && (bt == nil || w.Pos != bt.Pos) {
+		res = append(res, w)
+	}
+	return res, nil
+}
+
 func (s *SimpleTXIDStore) GetLastTxID() (string, error) {
 	v, err := s.persistence.GetState(txidNamespace, lastTX)
 	if err != nil {
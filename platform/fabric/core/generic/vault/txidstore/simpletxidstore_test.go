@@ -18,6 +18,7 @@ import (
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger"
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
 	"github.com/test-go/testify/assert"
+	"google.golang.org/protobuf/proto"
 )
 
 //go:generate counterfeiter -o mocks/config.go -fake-name Config . config
@@ -275,3 +276,86 @@ func testTXIDStore(t *testing.T, store *SimpleTXIDStore) {
 	}
 	assert.Equal(t, []string{"txid12", "txid21", "txid100", "txid200", "txid1025"}, txids)
 }
+
+func TestTXIDStoreHeight(t *testing.T) {
+	db, err := db.Open(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	store, err := NewTXIDStore(db)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, store.SetWithHeight("txid1", driver.Valid, 5, 3))
+	assert.NoError(t, store.SetWithHeight("txid2", driver.Valid, 6, 0))
+	assert.NoError(t, store.Set("txid3", driver.Busy))
+	// a record written before heights were recorded
+	raw, err := proto.Marshal(&ByTxid{Pos: 42, Code: int32(driver.Valid)})
+	assert.NoError(t, err)
+	assert.NoError(t, db.SetState(txidNamespace, keyByTxid("txid4"), raw))
+	assert.NoError(t, db.Commit())
+
+	code, block, txNum, err := store.GetWithHeight("txid1")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Valid, code)
+	assert.Equal(t, uint64(5), block)
+	assert.Equal(t, 3, txNum)
+
+	code, block, txNum, err = store.GetWithHeight("txid2")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Valid, code)
+	assert.Equal(t, uint64(6), block)
+	assert.Equal(t, 0, txNum)
+
+	code, block, txNum, err = store.GetWithHeight("txid3")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Busy, code)
+	assert.Equal(t, driver.UnknownBlock, block)
+	assert.Equal(t, driver.UnknownTxNum, txNum)
+
+	code, block, txNum, err = store.GetWithHeight("txid4")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Valid, code)
+	assert.Equal(t, driver.UnknownBlock, block)
+	assert.Equal(t, driver.UnknownTxNum, txNum)
+
+	code, block, txNum, err = store.GetWithHeight("txid5")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Unknown, code)
+	assert.Equal(t, driver.UnknownBlock, block)
+	assert.Equal(t, driver.UnknownTxNum, txNum)
+
+	// the old accessor is unaffected
+	code, err = store.Get("txid4")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Valid, code)
+}
+
+func TestTXIDStoreOutcome(t *testing.T) {
+	db, err := db.Open(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	store, err := NewTXIDStore(db)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, store.Set("txid1", driver.Busy))
+	assert.NoError(t, store.SetWithOutcome("txid1", driver.Invalid, 4, 2, 9, "duplicate"))
+	assert.NoError(t, store.Set("txid2", driver.Invalid))
+	assert.NoError(t, db.Commit())
+
+	code, block, txNum, err := store.GetWithHeight("txid1")
+	assert.NoError(t, err)
+	assert.Equal(t, driver.Invalid, code)
+	assert.Equal(t, uint64(4), block)
+	assert.Equal(t, 2, txNum)
+	fabricCode, message, found, err := store.GetOutcome("txid1")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int32(9), fabricCode)
+	assert.Equal(t, "duplicate", message)
+
+	// the statuses set otherwise have no outcome
+	for _, txid := range []string{"txid2", "txid3"} {
+		_, _, found, err = store.GetOutcome(txid)
+		assert.NoError(t, err)
+		assert.False(t, found)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txidstore
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// byStatusPrefix is the prefix of the index by status and time, byHeightPrefix the one of the index by status
+	// and height, of the transactions whose height is known
+	byStatusPrefix = "S"
+	byHeightPrefix = "H"
+	// statusIndexKey marks the stores whose index by status is in place
+	statusIndexKey = "statusIndex"
+	// statusIndexProgressStep is the number of transactions indexed between two progress reports
+	statusIndexProgressStep = 10000
+)
+
+// listedCodes are the codes listed when the filter does not select any
+var listedCodes = []fdriver.ValidationCode{fdriver.Valid, fdriver.Invalid, fdriver.Busy, fdriver.Unknown, fdriver.HasDependencies, fdriver.Abandoned}
+
+// indexKey returns the key of an index entry of the passed code, sorted by the pair (a, b)
+func indexKey(prefix string, code int32, a, b uint64) string {
+	key := make([]byte, 20)
+	binary.BigEndian.PutUint32(key, uint32(code))
+	binary.BigEndian.PutUint64(key[4:], a)
+	binary.BigEndian.PutUint64(key[12:], b)
+	return prefix + string(key)
+}
+
+// keyByStatus returns the key of the transaction set with the passed code at the passed time and position.
+// Within a code, the keys are sorted by time, then position, that is, in the order the statuses have been set:
+// the timestamps of a store never go backwards, the statuses set before they were recorded come first.
+func keyByStatus(code int32, timestamp int64, pos uint64) string {
+	return indexKey(byStatusPrefix, code, uint64(timestamp), pos)
+}
+
+// keyByHeight returns the key of the transaction set with the passed code at the passed height
+func keyByHeight(code int32, block, txNum uint64) string {
+	return indexKey(byHeightPrefix, code, block, txNum)
+}
+
+// sortKey returns the part of an index key following the code
+func sortKey(key string) string {
+	return key[len(byStatusPrefix)+4:]
+}
+
+func setByStatus(persistence driver.Persistence, txid string, bt *ByTxid) error {
+	raw, err := proto.Marshal(&ByStatus{
+		Txid:      txid,
+		Block:     bt.Block,
+		TxNum:     bt.TxNum,
+		HasHeight: bt.HasHeight,
+		Timestamp: bt.Timestamp,
+	})
+	if err != nil {
+		return errors.Errorf("error marshalling ByStatus for txid %s [%s]", txid, err.Error())
+	}
+	if err := persistence.SetState(txidNamespace, keyByStatus(bt.Code, bt.Timestamp, bt.Pos), raw); err != nil {
+		return errors.Errorf("error storing ByStatus for txid %s [%s]", txid, err.Error())
+	}
+	if !bt.HasHeight {
+		return nil
+	}
+	if err := persistence.SetState(txidNamespace, keyByHeight(bt.Code, bt.Block, bt.TxNum), raw); err != nil {
+		return errors.Errorf("error storing ByStatus for txid %s [%s]", txid, err.Error())
+	}
+	return nil
+}
+
+// deleteByStatus removes the entries of the passed status from the indexes
+func deleteByStatus(persistence driver.Persistence, txid string, bt *ByTxid) error {
+	if err := persistence.DeleteState(txidNamespace, keyByStatus(bt.Code, bt.Timestamp, bt.Pos)); err != nil {
+		return errors.Errorf("error removing ByStatus for txid %s [%s]", txid, err.Error())
+	}
+	if !bt.HasHeight {
+		return nil
+	}
+	if err := persistence.DeleteState(txidNamespace, keyByHeight(bt.Code, bt.Block, bt.TxNum)); err != nil {
+		return errors.Errorf("error removing ByStatus for txid %s [%s]", txid, err.Error())
+	}
+	return nil
+}
+
+// HasStatusIndex returns true if the transactions of the passed store are indexed by status
+func HasStatusIndex(persistence driver.Persistence) (bool, error) {
+	marker, err := persistence.GetState(txidNamespace, statusIndexKey)
+	if err != nil {
+		return false, errors.Errorf("error retrieving status index marker [%s]", err.Error())
+	}
+	return len(marker) != 0, nil
+}
+
+// Exists returns true if the passed persistence holds a store, that is, a store has been created on it already
+func Exists(persistence driver.Persistence) (bool, error) {
+	ctrBytes, err := persistence.GetState(txidNamespace, ctrKey)
+	if err != nil {
+		return false, errors.Errorf("error retrieving txid counter [%s]", err.Error())
+	}
+	return ctrBytes != nil, nil
+}
+
+// ReadStatus returns the validation code of the passed transaction recorded in the passed persistence, without
+// opening a store on it, which might write: the persistence can be read-only
+func ReadStatus(persistence driver.Persistence, txid string) (fdriver.ValidationCode, error) {
+	return (&SimpleTXIDStore{persistence: persistence}).Get(txid)
+}
+
+// BuildStatusIndex indexes by status the transactions of a store created before the index existed.
+// It does nothing if the index is in place already. If progress is not nil, it is called every
+// statusIndexProgressStep indexed transactions and at the end, with the number of transactions indexed so far.
+func BuildStatusIndex(persistence driver.Persistence, progress func(indexed int)) error {
+	indexed, err := HasStatusIndex(persistence)
+	if err != nil {
+		return err
+	}
+	if indexed {
+		return nil
+	}
+
+	// the keys by txid are the ones between their prefix and the next letter
+	it, err := persistence.GetStateRangeScanIterator(txidNamespace, byTxidPrefix, "U")
+	if err != nil {
+		return errors.Errorf("error scanning transactions [%s]", err.Error())
+	}
+	entries := map[string]*ByTxid{}
+	for {
+		d, err := it.Next()
+		if err != nil {
+			it.Close()
+			return errors.Errorf("error scanning transactions [%s]", err.Error())
+		}
+		if d == nil {
+			break
+		}
+		bt := &ByTxid{}
+		if err := proto.Unmarshal(d.Raw, bt); err != nil {
+			it.Close()
+			return errors.Errorf("error unmarshalling data for key %s [%s]", d.Key, err.Error())
+		}
+		entries[d.Key[len(byTxidPrefix):]] = bt
+	}
+	it.Close()
+
+	if err := persistence.BeginUpdate(); err != nil {
+		return errors.Errorf("error starting update to build status index [%s]", err.Error())
+	}
+	n := 0
+	for txid, bt := range entries {
+		if err := setByStatus(persistence, txid, bt); err != nil {
+			persistence.Discard()
+			return err
+		}
+		n++
+		if progress != nil && n%statusIndexProgressStep == 0 {
+			progress(n)
+		}
+	}
+	if err := persistence.SetState(txidNamespace, statusIndexKey, []byte{1}); err != nil {
+		persistence.Discard()
+		return errors.Errorf("error storing status index marker [%s]", err.Error())
+	}
+	if err := persistence.Commit(); err != nil {
+		return errors.Errorf("error committing status index [%s]", err.Error())
+	}
+	if progress != nil {
+		progress(n)
+	}
+	return nil
+}
+
+// timestamp returns the timestamp of a status being set now, never before the previous one
+func (s *SimpleTXIDStore) timestamp() int64 {
+	ts := s.now().UnixNano()
+	if ts <= s.lastTimestamp {
+		ts = s.lastTimestamp + 1
+	}
+	s.lastTimestamp = ts
+	return ts
+}
+
+// GetTimestamp returns when the status of the passed transaction has been set, zero if not known
+func (s *SimpleTXIDStore) GetTimestamp(txid string) (time.Time, error) {
+	bt, err := s.get(txid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if bt == nil || bt.Timestamp == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, bt.Timestamp), nil
+}
+
+type listedStatus struct {
+	key    string
+	status fdriver.TxStatus
+}
+
+// ListStatuses returns the page, identified by the passed token, of the statuses of the transactions matching the filter.
+// The statuses are read from the index by status and time, or from the index by status and height if the filter
+// bounds the blocks: the scan of a code starts at the first status in the bounds and stops past them.
+func (s *SimpleTXIDStore) ListStatuses(filter fdriver.StatusFilter, page fdriver.PageToken) (*fdriver.StatusPage, error) {
+	from, err := decodePageToken(page)
+	if err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = fdriver.DefaultStatusPageSize
+	}
+	codes := filter.Codes
+	if len(codes) == 0 {
+		codes = listedCodes
+	}
+
+	// take from each code the first limit+1 matches, to know if there is a next page
+	var listed []*listedStatus
+	for _, code := range codes {
+		matches, err := s.listStatuses(code, filter, from, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		listed = append(listed, matches...)
+	}
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].key < listed[j].key
+	})
+
+	res := &fdriver.StatusPage{}
+	if len(listed) > limit {
+		res.Next = encodePageToken(listed[limit].key)
+		listed = listed[:limit]
+	}
+	for _, l := range listed {
+		res.Statuses = append(res.Statuses, l.status)
+	}
+	return res, nil
+}
+
+func (s *SimpleTXIDStore) listStatuses(code fdriver.ValidationCode, filter fdriver.StatusFilter, from string, limit int) ([]*listedStatus, error) {
+	start, end := statusRange(int32(code), filter)
+	if len(from) != 0 && start[:len(byStatusPrefix)+4]+from > start {
+		start = start[:len(byStatusPrefix)+4] + from
+	}
+	if start >= end {
+		return nil, nil
+	}
+	it, err := s.persistence.GetStateRangeScanIterator(txidNamespace, start, end)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed scanning statuses [%d]", code)
+	}
+	defer it.Close()
+
+	var res []*listedStatus
+	for len(res) < limit {
+		d, err := it.Next()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed scanning statuses [%d]", code)
+		}
+		if d == nil {
+			break
+		}
+		bs := &ByStatus{}
+		if err := proto.Unmarshal(d.Raw, bs); err != nil {
+			return nil, errors.Wrapf(err, "failed unmarshalling status at [%s]", d.Key)
+		}
+
+		var ts time.Time
+		if bs.Timestamp != 0 {
+			ts = time.Unix(0, bs.Timestamp)
+		}
+		// the index by height is bounded by the blocks only
+		if !matches(filter, ts) {
+			continue
+		}
+		status := fdriver.TxStatus{TxID: bs.Txid, Code: code, Block: fdriver.UnknownBlock, TxNum: fdriver.UnknownTxNum, Timestamp: ts}
+		if bs.HasHeight {
+			status.Block, status.TxNum = bs.Block, int(bs.TxNum)
+		}
+		res = append(res, &listedStatus{key: sortKey(d.Key), status: status})
+	}
+	return res, nil
+}
+
+// statusRange returns the range of the keys of the passed code in the bounds of the passed filter.
+// The end of the range is exclusive.
+func statusRange(code int32, filter fdriver.StatusFilter) (string, string) {
+	if filter.FromBlock != nil || filter.ToBlock != nil {
+		start, end := keyByHeight(code, 0, 0), keyByHeight(code+1, 0, 0)
+		if filter.FromBlock != nil {
+			start = keyByHeight(code, *filter.FromBlock, 0)
+		}
+		if filter.ToBlock != nil && *filter.ToBlock != math.MaxUint64 {
+			end = keyByHeight(code, *filter.ToBlock+1, 0)
+		}
+		return start, end
+	}
+	if filter.Since.IsZero() && filter.Until.IsZero() {
+		return keyByStatus(code, 0, 0), keyByStatus(code+1, 0, 0)
+	}
+	// the statuses without timestamp do not match
+	since, until := int64(1), int64(math.MaxInt64)
+	if !filter.Since.IsZero() && filter.Since.UnixNano() > since {
+		since = filter.Since.UnixNano()
+	}
+	if !filter.Until.IsZero() {
+		until = filter.Until.UnixNano()
+	}
+	if until < since {
+		return "", ""
+	}
+	if until == math.MaxInt64 {
+		return keyByStatus(code, since, 0), keyByStatus(code+1, 0, 0)
+	}
+	return keyByStatus(code, since, 0), keyByStatus(code, until+1, 0)
+}
+
+// matches tells if a status set at the passed time, in the index by height, is in the time bounds of the filter
+func matches(filter fdriver.StatusFilter, ts time.Time) bool {
+	if filter.Since.IsZero() && filter.Until.IsZero() {
+		return true
+	}
+	if ts.IsZero() {
+		return false
+	}
+	return !ts.Before(filter.Since) && (filter.Until.IsZero() || !ts.After(filter.Until))
+}
+
+// encodePageToken returns the token of the page starting at the passed sort key
+func encodePageToken(key string) fdriver.PageToken {
+	return fdriver.PageToken(base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
+
+// decodePageToken returns the sort key the passed page starts at, empty for the first page
+func decodePageToken(page fdriver.PageToken) (string, error) {
+	if len(page) == 0 {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(string(page))
+	if err != nil || len(raw) != 16 {
+		return "", errors.Errorf("invalid page token [%s]", page)
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txidstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	driver2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/test-go/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func txids(page *driver.StatusPage) []string {
+	var res []string
+	for _, s := range page.Statuses {
+		res = append(res, s.TxID)
+	}
+	return res
+}
+
+func TestListStatuses(t *testing.T) {
+	db, err := db.Open(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	store, err := NewTXIDStore(db)
+	assert.NoError(t, err)
+	start := time.Unix(1000, 0)
+	now := start
+	store.now = func() time.Time { return now }
+
+	assert.NoError(t, db.BeginUpdate())
+	for i, txid := range []string{"tx1", "tx2", "tx3", "tx4", "tx5"} {
+		now = start.Add(time.Duration(i) * time.Minute)
+		assert.NoError(t, store.Set(txid, driver.Busy))
+	}
+	now = start.Add(10 * time.Minute)
+	assert.NoError(t, store.SetWithHeight("tx2", driver.Valid, 7, 0))
+	assert.NoError(t, store.SetWithHeight("tx4", driver.Invalid, 7, 1))
+	now = start.Add(20 * time.Minute)
+	assert.NoError(t, store.SetWithHeight("tx1", driver.Valid, 9, 0))
+	assert.NoError(t, db.Commit())
+
+	// the statuses set again, in the same update or not, leave no entry behind in the indexes
+	assert.Equal(t, 5, countKeys(t, db, byStatusPrefix))
+	assert.Equal(t, 3, countKeys(t, db, byHeightPrefix))
+
+	// all the statuses, in the order they have been set
+	page, err := store.ListStatuses(driver.StatusFilter{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx3", "tx5", "tx2", "tx4", "tx1"}, txids(page))
+	assert.Empty(t, string(page.Next))
+	assert.Equal(t, driver.TxStatus{TxID: "tx2", Code: driver.Valid, Block: 7, TxNum: 0, Timestamp: start.Add(10 * time.Minute)}, page.Statuses[2])
+	assert.Equal(t, driver.UnknownBlock, page.Statuses[0].Block)
+	assert.Equal(t, driver.UnknownTxNum, page.Statuses[0].TxNum)
+
+	// pagination
+	page, err = store.ListStatuses(driver.StatusFilter{Limit: 2}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx3", "tx5"}, txids(page))
+	assert.NotEmpty(t, page.Next)
+	next := page.Next
+
+	// the tokens are stable across commits
+	assert.NoError(t, db.BeginUpdate())
+	now = start.Add(30 * time.Minute)
+	assert.NoError(t, store.SetWithHeight("tx6", driver.Valid, 10, 0))
+	assert.NoError(t, db.Commit())
+	page, err = store.ListStatuses(driver.StatusFilter{Limit: 2}, next)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx2", "tx4"}, txids(page))
+	page, err = store.ListStatuses(driver.StatusFilter{Limit: 2}, page.Next)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx1", "tx6"}, txids(page))
+	assert.Empty(t, string(page.Next))
+
+	// codes
+	page, err = store.ListStatuses(driver.StatusFilter{Codes: []driver.ValidationCode{driver.Valid}}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx2", "tx1", "tx6"}, txids(page))
+	page, err = store.ListStatuses(driver.StatusFilter{Codes: []driver.ValidationCode{driver.Busy, driver.Invalid}}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx3", "tx5", "tx4"}, txids(page))
+
+	// blocks, the transactions without height are left out
+	from, to := uint64(8), uint64(9)
+	page, err = store.ListStatuses(driver.StatusFilter{FromBlock: &from}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx1", "tx6"}, txids(page))
+	page, err = store.ListStatuses(driver.StatusFilter{ToBlock: &to}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx2", "tx4", "tx1"}, txids(page))
+	page, err = store.ListStatuses(driver.StatusFilter{ToBlock: &to, Limit: 2}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx2", "tx4"}, txids(page))
+	page, err = store.ListStatuses(driver.StatusFilter{ToBlock: &to, Limit: 2}, page.Next)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx1"}, txids(page))
+	page, err = store.ListStatuses(driver.StatusFilter{FromBlock: &from, Since: start.Add(25 * time.Minute)}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx6"}, txids(page))
+
+	// time
+	page, err = store.ListStatuses(driver.StatusFilter{Since: start.Add(10 * time.Minute), Until: start.Add(20 * time.Minute)}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx2", "tx4", "tx1"}, txids(page))
+	page, err = store.ListStatuses(driver.StatusFilter{Until: start.Add(5 * time.Minute)}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx3", "tx5"}, txids(page))
+
+	page, err = store.ListStatuses(driver.StatusFilter{Since: start.Add(time.Hour), Until: start}, "")
+	assert.NoError(t, err)
+	assert.Empty(t, page.Statuses)
+
+	// a store opened again goes on from the last timestamp
+	store, err = NewTXIDStore(db)
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(30*time.Minute).UnixNano(), store.lastTimestamp)
+
+	_, err = store.ListStatuses(driver.StatusFilter{}, "not a token")
+	assert.EqualError(t, err, "invalid page token [not a token]")
+}
+
+func TestBuildStatusIndex(t *testing.T) {
+	db, err := db.Open(nil, "memory", "", nil)
+	assert.NoError(t, err)
+
+	// a store written before the index existed
+	assert.NoError(t, db.BeginUpdate())
+	for i, txid := range []string{"tx1", "tx2"} {
+		raw, err := proto.Marshal(&ByTxid{Pos: uint64(i), Code: int32(driver.Valid)})
+		assert.NoError(t, err)
+		assert.NoError(t, db.SetState(txidNamespace, keyByTxid(txid), raw))
+	}
+	assert.NoError(t, db.Commit())
+
+	store, err := NewTXIDStore(db)
+	assert.NoError(t, err)
+	page, err := store.ListStatuses(driver.StatusFilter{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx1", "tx2"}, txids(page))
+	assert.True(t, page.Statuses[0].Timestamp.IsZero())
+
+	// the statuses without timestamp do not match time filters
+	page, err = store.ListStatuses(driver.StatusFilter{Since: time.Unix(0, 1)}, "")
+	assert.NoError(t, err)
+	assert.Empty(t, page.Statuses)
+}
+
+func countKeys(t *testing.T, db driver2.Persistence, prefix string) int {
+	it, err := db.GetStateRangeScanIterator(txidNamespace, prefix, prefix+"\xff")
+	assert.NoError(t, err)
+	defer it.Close()
+	n := 0
+	for {
+		d, err := it.Next()
+		assert.NoError(t, err)
+		if d == nil {
+			return n
+		}
+		n++
+	}
+}
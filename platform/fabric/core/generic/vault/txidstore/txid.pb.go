@@ -83,6 +83,17 @@ type ByTxid struct {
 
 	Pos  uint64 `protobuf:"varint,1,opt,name=pos,proto3" json:"pos,omitempty"`
 	Code int32  `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	// block and tx_num locate the transaction in the ledger, they are set only if has_height is true
+	Block     uint64 `protobuf:"varint,3,opt,name=block,proto3" json:"block,omitempty"`
+	TxNum     uint64 `protobuf:"varint,4,opt,name=tx_num,json=txNum,proto3" json:"tx_num,omitempty"`
+	HasHeight bool   `protobuf:"varint,5,opt,name=has_height,json=hasHeight,proto3" json:"has_height,omitempty"`
+	// timestamp is the time, in unix nanoseconds, the status has been set
+	Timestamp int64 `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// fabric_code is the peer.TxValidationCode the peers assigned to the transaction, it is set only if has_fabric_code is true
+	FabricCode    int32 `protobuf:"varint,7,opt,name=fabric_code,json=fabricCode,proto3" json:"fabric_code,omitempty"`
+	HasFabricCode bool  `protobuf:"varint,8,opt,name=has_fabric_code,json=hasFabricCode,proto3" json:"has_fabric_code,omitempty"`
+	// message describes the outcome of the transaction
+	Message string `protobuf:"bytes,9,opt,name=message,proto3" json:"message,omitempty"`
 }
 
 func (x *ByTxid) Reset() {
@@ -131,6 +142,135 @@ func (x *ByTxid) GetCode() int32 {
 	return 0
 }
 
+func (x *ByTxid) GetBlock() uint64 {
+	if x != nil {
+		return x.Block
+	}
+	return 0
+}
+
+func (x *ByTxid) GetTxNum() uint64 {
+	if x != nil {
+		return x.TxNum
+	}
+	return 0
+}
+
+func (x *ByTxid) GetHasHeight() bool {
+	if x != nil {
+		return x.HasHeight
+	}
+	return false
+}
+
+func (x *ByTxid) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ByTxid) GetFabricCode() int32 {
+	if x != nil {
+		return x.FabricCode
+	}
+	return 0
+}
+
+func (x *ByTxid) GetHasFabricCode() bool {
+	if x != nil {
+		return x.HasFabricCode
+	}
+	return false
+}
+
+func (x *ByTxid) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// ByStatus is an entry of the index of the transactions by status
+type ByStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Txid      string `protobuf:"bytes,1,opt,name=txid,proto3" json:"txid,omitempty"`
+	Block     uint64 `protobuf:"varint,2,opt,name=block,proto3" json:"block,omitempty"`
+	TxNum     uint64 `protobuf:"varint,3,opt,name=tx_num,json=txNum,proto3" json:"tx_num,omitempty"`
+	HasHeight bool   `protobuf:"varint,4,opt,name=has_height,json=hasHeight,proto3" json:"has_height,omitempty"`
+	Timestamp int64  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *ByStatus) Reset() {
+	*x = ByStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_platform_fabric_core_vault_txidstore_txid_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ByStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ByStatus) ProtoMessage() {}
+
+func (x *ByStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_platform_fabric_core_vault_txidstore_txid_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ByStatus.ProtoReflect.Descriptor instead.
+func (*ByStatus) Descriptor() ([]byte, []int) {
+	return file_platform_fabric_core_vault_txidstore_txid_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ByStatus) GetTxid() string {
+	if x != nil {
+		return x.Txid
+	}
+	return ""
+}
+
+func (x *ByStatus) GetBlock() uint64 {
+	if x != nil {
+		return x.Block
+	}
+	return 0
+}
+
+func (x *ByStatus) GetTxNum() uint64 {
+	if x != nil {
+		return x.TxNum
+	}
+	return 0
+}
+
+func (x *ByStatus) GetHasHeight() bool {
+	if x != nil {
+		return x.HasHeight
+	}
+	return false
+}
+
+func (x *ByStatus) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
 var File_platform_fabric_core_vault_txidstore_txid_proto protoreflect.FileDescriptor
 
 var file_platform_fabric_core_vault_txidstore_txid_proto_rawDesc = []byte{
@@ -140,11 +280,32 @@ var file_platform_fabric_core_vault_txidstore_txid_proto_rawDesc = []byte{
 	0x6f, 0x12, 0x09, 0x74, 0x78, 0x69, 0x64, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x22, 0x2f, 0x0a, 0x05,
 	0x42, 0x79, 0x4e, 0x75, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x78, 0x69, 0x64, 0x18, 0x01, 0x20,
 	0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x78, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64,
-	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x22, 0x2e, 0x0a,
-	0x06, 0x42, 0x79, 0x54, 0x78, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x6f, 0x73, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x70, 0x6f, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64,
-	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x22, 0xfb, 0x01,
+	0x0a, 0x06, 0x42, 0x79, 0x54, 0x78, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x6f, 0x73, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x70, 0x6f, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x14,
+	0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x15, 0x0a, 0x06, 0x74, 0x78, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x74, 0x78, 0x4e, 0x75, 0x6d, 0x12, 0x1d, 0x0a, 0x0a, 0x68,
+	0x61, 0x73, 0x5f, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x09, 0x68, 0x61, 0x73, 0x48, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x1f, 0x0a, 0x0b, 0x66, 0x61, 0x62, 0x72,
+	0x69, 0x63, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x66,
+	0x61, 0x62, 0x72, 0x69, 0x63, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x26, 0x0a, 0x0f, 0x68, 0x61, 0x73,
+	0x5f, 0x66, 0x61, 0x62, 0x72, 0x69, 0x63, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x0d, 0x68, 0x61, 0x73, 0x46, 0x61, 0x62, 0x72, 0x69, 0x63, 0x43, 0x6f, 0x64,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x88, 0x01, 0x0a, 0x08,
+	0x42, 0x79, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x78, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x78, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05,
+	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x62, 0x6c, 0x6f,
+	0x63, 0x6b, 0x12, 0x15, 0x0a, 0x06, 0x74, 0x78, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x05, 0x74, 0x78, 0x4e, 0x75, 0x6d, 0x12, 0x1d, 0x0a, 0x0a, 0x68, 0x61, 0x73,
+	0x5f, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x68,
+	0x61, 0x73, 0x48, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -159,10 +320,11 @@ func file_platform_fabric_core_vault_txidstore_txid_proto_rawDescGZIP() []byte {
 	return file_platform_fabric_core_vault_txidstore_txid_proto_rawDescData
 }
 
-var file_platform_fabric_core_vault_txidstore_txid_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_platform_fabric_core_vault_txidstore_txid_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
 var file_platform_fabric_core_vault_txidstore_txid_proto_goTypes = []interface{}{
-	(*ByNum)(nil),  // 0: txidstore.ByNum
-	(*ByTxid)(nil), // 1: txidstore.ByTxid
+	(*ByNum)(nil),    // 0: txidstore.ByNum
+	(*ByTxid)(nil),   // 1: txidstore.ByTxid
+	(*ByStatus)(nil), // 2: txidstore.ByStatus
 }
 var file_platform_fabric_core_vault_txidstore_txid_proto_depIdxs = []int32{
 	0, // [0:0] is the sub-list for method output_type
@@ -202,6 +364,18 @@ func file_platform_fabric_core_vault_txidstore_txid_proto_init() {
 				return nil
 			}
 		}
+		file_platform_fabric_core_vault_txidstore_txid_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ByStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -209,7 +383,7 @@ func file_platform_fabric_core_vault_txidstore_txid_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_platform_fabric_core_vault_txidstore_txid_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   3,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
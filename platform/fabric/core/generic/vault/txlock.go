@@ -0,0 +1,45 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import "sync"
+
+// txLocks serializes the bookkeeping of the same transaction, the locks are dropped once released by all the holders
+type txLocks struct {
+	lock  sync.Mutex
+	locks map[string]*txLock
+}
+
+type txLock struct {
+	sync.Mutex
+	holders int
+}
+
+func (l *txLocks) acquire(txid string) func() {
+	l.lock.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*txLock{}
+	}
+	tl, ok := l.locks[txid]
+	if !ok {
+		tl = &txLock{}
+		l.locks[txid] = tl
+	}
+	tl.holders++
+	l.lock.Unlock()
+
+	tl.Lock()
+	return func() {
+		tl.Unlock()
+		l.lock.Lock()
+		tl.holders--
+		if tl.holders == 0 {
+			delete(l.locks, txid)
+		}
+		l.lock.Unlock()
+	}
+}
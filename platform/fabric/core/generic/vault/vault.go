@@ -9,6 +9,7 @@ package vault
 import (
 	"bytes"
 	"sync"
+	"time"
 
 	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
@@ -22,11 +23,35 @@ var logger = flogging.MustGetLogger("fabric-sdk.vault")
 
 type TXIDStoreReader interface {
 	Get(txid string) (fdriver.ValidationCode, error)
+	GetWithHeight(txid string) (fdriver.ValidationCode, uint64, int, error)
 }
 
 type TXIDStore interface {
 	TXIDStoreReader
 	Set(txid string, code fdriver.ValidationCode) error
+	SetWithHeight(txid string, code fdriver.ValidationCode, block uint64, txNum int) error
+}
+
+// StatusLister is implemented by the TXIDStores that can list the statuses of the transactions
+type StatusLister interface {
+	ListStatuses(filter fdriver.StatusFilter, page fdriver.PageToken) (*fdriver.StatusPage, error)
+}
+
+// TimestampReader is implemented by the TXIDStores that record when the status of the transactions has been set
+type TimestampReader interface {
+	GetTimestamp(txid string) (time.Time, error)
+}
+
+// OutcomeRecorder is implemented by the TXIDStores that record the Fabric validation code of the transactions
+type OutcomeRecorder interface {
+	SetWithOutcome(txid string, code fdriver.ValidationCode, block uint64, txNum int, fabricCode int32, message string) error
+	GetOutcome(txid string) (int32, string, bool, error)
+}
+
+// LocalRecorder is implemented by the TXIDStores that can record the statuses of the transactions committed
+// locally, without making them the last transaction committed
+type LocalRecorder interface {
+	SetLocal(txid string, code fdriver.ValidationCode) error
 }
 
 // Vault models a key-value store that can be modified by committing rwsets
@@ -38,15 +63,43 @@ type Vault struct {
 
 	// the vault handles access concurrency to the store using storeLock.
 	// In particular:
-	// * when a directQueryExecutor is returned, it holds a read-lock;
+	// * when the store supports snapshots, a snapshotQueryExecutor is returned,
+	//   it reads from a snapshot of the store and holds no lock.
+	//   Otherwise, a directQueryExecutor is returned, it holds a read-lock;
 	//   when Done is called on it, the lock is released.
 	// * when an interceptor is returned (using NewRWSet (in case the
 	//   transaction context is generated from nothing) or GetRWSet
 	//   (in case the transaction context is received from another node)),
 	//   it holds a read-lock; when Done is called on it, the lock is released.
-	// * an exclusive lock is held when Commit is called.
+	// * an exclusive lock is held when Commit is called. It is taken once the snapshot hooks,
+	//   if running, have returned, see lockStore.
 	store     driver.VersionedPersistence
 	storeLock sync.RWMutex
+
+	// gate coordinates block commits, and the other store updates, with backups, see PauseCommits
+	gate commitGate
+
+	// history tracks the namespaces whose versions are kept, see SetHistoryNamespaces
+	history history
+
+	// txLocks serializes the paths committing the same transaction, see LockTx
+	txLocks txLocks
+
+	// quotas accounts the storage taken by the namespaces, see SetNamespaceQuota
+	quotas quotas
+
+	// baselines computes the checksums of the namespaces written before the checksums were kept, see checksumOf
+	baselines baselines
+
+	// watches sends the changes of the keys committed, see WatchKeys
+	watches watches
+
+	// projections derives the index namespaces from their source namespaces, see RegisterProjection
+	projections projections
+
+	// metrics records the contention on storeLock, and the usage of the namespaces, nil if not set, see SetMetrics
+	metrics       *Metrics
+	metricsLabels []string
 }
 
 // New returns a new instance of Vault
@@ -58,10 +111,21 @@ func New(store driver.VersionedPersistence, txIDStore TXIDStore) *Vault {
 	}
 }
 
+// NewQueryExecutor returns a query executor reading the committed state. If the store supports snapshots,
+// the query executor reads from a snapshot, and neither waits for nor blocks the commits. Otherwise,
+// it holds the read lock of the store until Done is called.
 func (db *Vault) NewQueryExecutor() (fdriver.QueryExecutor, error) {
+	if sp, ok := db.store.(driver.SnapshotProvider); ok {
+		snapshot, err := sp.NewSnapshot()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed taking a snapshot of the store")
+		}
+		return &snapshotQueryExecutor{snapshot: snapshot}, nil
+	}
+
 	logger.Debugf("getting lock for query executor")
 	db.counter.Inc()
-	db.storeLock.RLock()
+	db.readLockStore()
 
 	logger.Debugf("return new query executor")
 	return &directQueryExecutor{
@@ -96,39 +160,136 @@ func (db *Vault) unmapInterceptor(txid string) (*Interceptor, error) {
 }
 
 func (db *Vault) Status(txid string) (fdriver.ValidationCode, error) {
-	code, err := db.txidStore.Get(txid)
+	code, _, _, err := db.StatusWithHeight(txid)
+	return code, err
+}
+
+// StatusWithHeight returns the status of the passed transaction together with the number of the block
+// and the position in the block where the transaction has been committed, or invalidated.
+// If the height is not known, fdriver.UnknownBlock and fdriver.UnknownTxNum are returned.
+func (db *Vault) StatusWithHeight(txid string) (fdriver.ValidationCode, uint64, int, error) {
+	code, block, txNum, err := db.txidStore.GetWithHeight(txid)
 	if err != nil {
-		return 0, nil
+		return 0, fdriver.UnknownBlock, fdriver.UnknownTxNum, nil
 	}
 
 	if code != fdriver.Unknown {
-		return code, nil
+		return code, block, txNum, nil
 	}
 
 	db.interceptorsLock.RLock()
 	defer db.interceptorsLock.RUnlock()
 
 	if _, in := db.interceptors[txid]; in {
-		return fdriver.Busy, nil
+		return fdriver.Busy, fdriver.UnknownBlock, fdriver.UnknownTxNum, nil
+	}
+
+	return fdriver.Unknown, fdriver.UnknownBlock, fdriver.UnknownTxNum, nil
+}
+
+// ListStatuses returns the page, identified by the passed token, of the statuses of the transactions matching the filter.
+// The transactions whose read-write set is still open are not listed, unless marked busy.
+func (db *Vault) ListStatuses(filter fdriver.StatusFilter, page fdriver.PageToken) (*fdriver.StatusPage, error) {
+	lister, ok := db.txidStore.(StatusLister)
+	if !ok {
+		return nil, errors.Errorf("the txid store does not support listing statuses")
+	}
+	return lister.ListStatuses(filter, page)
+}
+
+// StatusTimestamp returns when the status of the passed transaction has been set, zero if not known
+func (db *Vault) StatusTimestamp(txid string) (time.Time, error) {
+	reader, ok := db.txidStore.(TimestampReader)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return reader.GetTimestamp(txid)
+}
+
+// AbandonTx marks the passed busy transaction as abandoned, and drops its read-write set.
+// It fails if the transaction is not busy, or its read-write set is still open.
+func (db *Vault) AbandonTx(txid string) error {
+	code, err := db.Status(txid)
+	if err != nil {
+		return err
+	}
+	if code != fdriver.Busy {
+		return errors.Errorf("cannot abandon [%s], its status is [%d]", txid, code)
+	}
+	if _, err := db.unmapInterceptor(txid); err != nil {
+		return err
+	}
+
+	db.lockUpdates()
+	defer db.unlockUpdates()
+
+	err = db.store.BeginUpdate()
+	if err != nil {
+		return errors.WithMessagef(err, "begin update for txid '%s' failed", txid)
+	}
+
+	err = db.txidStore.Set(txid, fdriver.Abandoned)
+	if err != nil {
+		return err
+	}
+
+	err = db.store.Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "committing tx for txid '%s' failed", txid)
 	}
 
-	return fdriver.Unknown, nil
+	return nil
 }
 
 func (db *Vault) DiscardTx(txid string) error {
+	return db.discardTx(txid, func() error {
+		return db.txidStore.Set(txid, fdriver.Invalid)
+	})
+}
+
+// DiscardTxWithCode discards the passed transaction, invalidated at the passed height, as DiscardTx does, and records,
+// in the same update, the height and the Fabric validation code the peers assigned to it, together with the passed
+// message. The code is not recorded if the TXIDStore does not support it.
+func (db *Vault) DiscardTxWithCode(txid string, block uint64, txNum int, fabricCode int32, message string) error {
+	recorder, ok := db.txidStore.(OutcomeRecorder)
+	return db.discardTx(txid, func() error {
+		if err := db.advanceHeight(block); err != nil {
+			return err
+		}
+		if !ok {
+			return db.txidStore.SetWithHeight(txid, fdriver.Invalid, block, txNum)
+		}
+		return recorder.SetWithOutcome(txid, fdriver.Invalid, block, txNum, fabricCode, message)
+	})
+}
+
+// Outcome returns the Fabric validation code and the message recorded for the passed transaction.
+// The returned flag is false if none has been recorded.
+func (db *Vault) Outcome(txid string) (int32, string, bool, error) {
+	recorder, ok := db.txidStore.(OutcomeRecorder)
+	if !ok {
+		return 0, "", false, nil
+	}
+	return recorder.GetOutcome(txid)
+}
+
+func (db *Vault) discardTx(txid string, set func() error) error {
 	_, err := db.unmapInterceptor(txid)
 	if err != nil {
 		return err
 	}
 
+	db.lockStore()
+	defer db.unlockStore()
+
 	err = db.store.BeginUpdate()
 	if err != nil {
 		return errors.WithMessagef(err, "begin update for txid '%s' failed", txid)
 	}
 
-	err = db.txidStore.Set(txid, fdriver.Invalid)
+	err = set()
 	if err != nil {
-		return err
+		return db.discard(err)
 	}
 
 	err = db.store.Commit()
@@ -139,34 +300,84 @@ func (db *Vault) DiscardTx(txid string) error {
 	return nil
 }
 
+// LockTx serializes the bookkeeping of the passed transaction: the commit pipeline and the local paths committing,
+// or discarding, the same transaction take turns. The returned function releases the lock.
+func (db *Vault) LockTx(txid string) func() {
+	return db.txLocks.acquire(txid)
+}
+
+// CommitTX applies the writes of the read-write set of the passed transaction and marks it as valid.
+// The writes are applied exactly once: if the transaction is valid already, because another path committed it,
+// the transaction gets the passed height, if it has none, and nothing else is written.
 func (db *Vault) CommitTX(txid string, block uint64, indexInBloc int) error {
+	return db.commitTX(txid, block, indexInBloc, false)
+}
+
+// CommitLocalTX commits the passed transaction, that is not ordered, at height zero.
+// The transaction does not become the last one committed, the delivery of the blocks does not restart from it.
+func (db *Vault) CommitLocalTX(txid string) error {
+	if _, ok := db.txidStore.(LocalRecorder); !ok {
+		return errors.Errorf("the txid store does not support local transactions")
+	}
+	return db.commitTX(txid, 0, 0, true)
+}
+
+func (db *Vault) commitTX(txid string, block uint64, indexInBloc int, local bool) error {
 	logger.Debugf("unmapInterceptor [%s]", txid)
 	i, err := db.unmapInterceptor(txid)
 	if err != nil {
 		return err
 	}
+
+	logger.Debugf("get lock [%s][%d]", txid, db.counter.Load())
+	db.lockStore()
+	defer db.unlockStore()
+
+	reconciled := block
+	if local {
+		// the height of a local transaction is never attached
+		reconciled = fdriver.UnknownBlock
+	}
+	committed, err := db.reconcileCommitted(txid, reconciled, indexInBloc)
+	if err != nil && !committed && i != nil {
+		// the status is not known, the read-write set is kept for the commit to be retried
+		db.interceptorsLock.Lock()
+		if _, in := db.interceptors[txid]; !in {
+			db.interceptors[txid] = i
+		}
+		db.interceptorsLock.Unlock()
+	}
+	if err != nil || committed {
+		return err
+	}
 	if i == nil {
 		return errors.Errorf("cannot find rwset for [%s]", txid)
 	}
 
-	logger.Debugf("get lock [%s][%d]", txid, db.counter.Load())
-	db.storeLock.Lock()
-	defer db.storeLock.Unlock()
-
 	err = db.store.BeginUpdate()
 	if err != nil {
 		return errors.WithMessagef(err, "begin update for txid '%s' failed", txid)
 	}
 
+	usage := db.newUsageUpdate()
+	derived, err := db.newProjector().projectWrites(usage, i.rws.writes, block, uint64(indexInBloc))
+	if err != nil {
+		logger.Errorf("failed projecting the writes of [%s] at height [%d:%d]: [%s]", txid, block, indexInBloc, err)
+		return db.discard(err)
+	}
+
 	logger.Debugf("parse writes [%s]", txid)
 	for ns, keyMap := range i.rws.writes {
+		if db.projections.index(ns) != nil {
+			// the index namespaces are written by their projections only
+			logger.Warnf("skip the writes of [%s] to the index namespace [%s]", txid, ns)
+			continue
+		}
 		for key, v := range keyMap {
 			logger.Debugf("store write [%s,%s,%v]", ns, key, hash.Hashable(v).String())
-			var err error
-			if len(v) != 0 {
-				err = db.store.SetState(ns, key, v, block, uint64(indexInBloc))
-			} else {
-				err = db.store.DeleteState(ns, key)
+			err := usage.write(ns, key, v, block, uint64(indexInBloc))
+			if err == nil {
+				err = db.recordVersion(ns, key, v, block, uint64(indexInBloc))
 			}
 
 			if err != nil {
@@ -182,6 +393,11 @@ func (db *Vault) CommitTX(txid string, block uint64, indexInBloc int) error {
 	logger.Debugf("parse meta writes [%s]", txid)
 	for ns, keyMap := range i.rws.metawrites {
 		for key, v := range keyMap {
+			if value, written := i.rws.writes[ns][key]; written && len(value) == 0 {
+				// as in Fabric, the key is deleted together with its metadata
+				logger.Debugf("skip meta write of deleted key [%s,%s]", ns, key)
+				continue
+			}
 			logger.Debugf("store meta write [%s,%s]", ns, key)
 
 			err := db.store.SetStateMetadata(ns, key, v, block, uint64(indexInBloc))
@@ -195,8 +411,34 @@ func (db *Vault) CommitTX(txid string, block uint64, indexInBloc int) error {
 		}
 	}
 
+	logger.Debugf("parse purges [%s]", txid)
+	purged, err := db.purge(usage, txid, i.rws.purges, block, uint64(indexInBloc))
+	if err != nil {
+		return db.discard(err)
+	}
+
+	if err := usage.store(); err != nil {
+		return db.discard(err)
+	}
+
+	heightRecorded, err := db.recordHeight(block)
+	if err == nil && !local {
+		err = db.advanceHeight(block)
+	}
+	if err != nil {
+		if err1 := db.store.Discard(); err1 != nil {
+			logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+		}
+
+		return err
+	}
+
 	logger.Debugf("set state to valid [%s]", txid)
-	err = db.txidStore.Set(txid, fdriver.Valid)
+	if local {
+		err = db.txidStore.(LocalRecorder).SetLocal(txid, fdriver.Valid)
+	} else {
+		err = db.txidStore.SetWithHeight(txid, fdriver.Valid, block, indexInBloc)
+	}
 	if err != nil {
 		if err1 := db.store.Discard(); err1 != nil {
 			logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
@@ -209,10 +451,52 @@ func (db *Vault) CommitTX(txid string, block uint64, indexInBloc int) error {
 	if err != nil {
 		return errors.WithMessagef(err, "committing tx for txid '%s' failed", txid)
 	}
+	heightRecorded()
+	usage.committed()
+	db.watches.collect(txid, block, uint64(indexInBloc), i.rws.writes)
+	db.watches.collect(txid, block, uint64(indexInBloc), derived)
+	db.watches.collect(txid, block, uint64(indexInBloc), purged)
+	db.notifyChanges()
 
 	return nil
 }
 
+// reconcileCommitted returns true if the passed transaction is valid already, in which case the passed height is
+// attached to its status, if it has none. It returns false and an error if the status cannot be read:
+// the transaction must not be applied without knowing it is not committed yet. db.storeLock must be held.
+func (db *Vault) reconcileCommitted(txid string, block uint64, indexInBloc int) (bool, error) {
+	code, recorded, _, err := db.txidStore.GetWithHeight(txid)
+	if err != nil {
+		return false, errors.WithMessagef(err, "failed reading the status of txid '%s'", txid)
+	}
+	if code != fdriver.Valid {
+		return false, nil
+	}
+	if recorded != fdriver.UnknownBlock || block == fdriver.UnknownBlock {
+		logger.Debugf("[%s] is already committed, skipping", txid)
+		return true, nil
+	}
+
+	logger.Debugf("[%s] is already committed, attach height [%d:%d]", txid, block, indexInBloc)
+	if err := db.store.BeginUpdate(); err != nil {
+		return true, errors.WithMessagef(err, "begin update for txid '%s' failed", txid)
+	}
+	err = db.advanceHeight(block)
+	if err == nil {
+		err = db.txidStore.SetWithHeight(txid, fdriver.Valid, block, indexInBloc)
+	}
+	if err != nil {
+		if err1 := db.store.Discard(); err1 != nil {
+			logger.Errorf("got error %s; discarding caused %s", err.Error(), err1.Error())
+		}
+		return true, err
+	}
+	if err := db.store.Commit(); err != nil {
+		return true, errors.WithMessagef(err, "committing height for txid '%s' failed", txid)
+	}
+	return true, nil
+}
+
 func (db *Vault) SetBusy(txid string) error {
 	code, err := db.txidStore.Get(txid)
 	if err != nil {
@@ -223,6 +507,9 @@ func (db *Vault) SetBusy(txid string) error {
 		return nil
 	}
 
+	db.lockUpdates()
+	defer db.unlockUpdates()
+
 	err = db.store.BeginUpdate()
 	if err != nil {
 		return errors.WithMessagef(err, "begin update for txid '%s' failed", txid)
@@ -244,6 +531,8 @@ func (db *Vault) SetBusy(txid string) error {
 func (db *Vault) NewRWSet(txid string) (*Interceptor, error) {
 	logger.Debugf("NewRWSet[%s][%d]", txid, db.counter.Load())
 	i := newInterceptor(&interceptorQueryExecutor{db}, db.txidStore, txid)
+	i.quota = db.checkQuota
+	i.index = db.checkIndex
 
 	db.interceptorsLock.Lock()
 	if _, in := db.interceptors[txid]; in {
@@ -258,7 +547,7 @@ func (db *Vault) NewRWSet(txid string) (*Interceptor, error) {
 	db.interceptorsLock.Unlock()
 
 	db.counter.Inc()
-	db.storeLock.RLock()
+	db.readLockStore()
 
 	return i, nil
 }
@@ -286,7 +575,7 @@ func (db *Vault) GetRWSet(txid string, rwsetBytes []byte) (*Interceptor, error)
 	db.interceptorsLock.Unlock()
 
 	db.counter.Inc()
-	db.storeLock.RLock()
+	db.readLockStore()
 
 	return i, nil
 }
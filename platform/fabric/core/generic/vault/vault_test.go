@@ -11,6 +11,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -24,6 +26,7 @@ import (
 	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
 	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
 	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -700,6 +703,41 @@ func TestVaultErr(t *testing.T) {
 	assert.Equal(t, fdriver.Unknown, code)
 }
 
+func TestAbandonTx(t *testing.T) {
+	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	vault := New(ddb, tidstore)
+
+	// a transaction whose read-write set is open cannot be abandoned
+	rws, err := vault.NewRWSet("busy")
+	assert.NoError(t, err)
+	since, err := vault.StatusTimestamp("busy")
+	assert.NoError(t, err)
+	assert.False(t, since.IsZero())
+	assert.EqualError(t, vault.AbandonTx("busy"), "attempted to retrieve read-write set for busy when done has not been called")
+	rws.Done()
+	assert.NoError(t, vault.AbandonTx("busy"))
+	code, err := vault.Status("busy")
+	assert.NoError(t, err)
+	assert.Equal(t, fdriver.Abandoned, code)
+	assert.Len(t, vault.interceptors, 0)
+
+	// only the busy transactions can be abandoned
+	assert.EqualError(t, vault.AbandonTx("busy"), "cannot abandon [busy], its status is [6]")
+	assert.EqualError(t, vault.AbandonTx("unknown"), "cannot abandon [unknown], its status is [4]")
+
+	// the ledger has the last word, an abandoned transaction can still be committed
+	rws, err = vault.GetRWSet("busy", nil)
+	assert.NoError(t, err)
+	rws.Done()
+	assert.NoError(t, vault.CommitTX("busy", 3, 0))
+	code, err = vault.Status("busy")
+	assert.NoError(t, err)
+	assert.Equal(t, fdriver.Valid, code)
+}
+
 func TestInterceptorErr(t *testing.T) {
 	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
 	assert.NoError(t, err)
@@ -861,6 +899,114 @@ func TestQueryExecutor(t *testing.T) {
 	}, res)
 }
 
+func TestReplicateStates(t *testing.T) {
+	ns := "replicated"
+
+	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	vault := New(ddb, tidstore)
+
+	assert.NoError(t, vault.ReplicateStates([]fdriver.StateWrite{
+		{Namespace: ns, Key: "k1", Value: []byte("v1"), Block: 10, TxNum: 1},
+		{Namespace: ns, Key: "k2", Value: []byte("v2"), Block: 10, TxNum: 2},
+		{Namespace: "other", Key: "k1", Value: []byte("o1"), Block: 3},
+	}))
+	// a delete and an update
+	assert.NoError(t, vault.ReplicateStates([]fdriver.StateWrite{
+		{Namespace: ns, Key: "k1", Block: 11},
+		{Namespace: ns, Key: "k2", Value: []byte("v2'"), Block: 11, TxNum: 1},
+	}))
+	v, block, txNum, err := ddb.GetState(ns, "k2")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2'"), v)
+	assert.Equal(t, uint64(11), block)
+	assert.Equal(t, uint64(1), txNum)
+	v, _, _, err = ddb.GetState(ns, "k1")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+
+	// a reset leaves the namespace with the passed writes only
+	assert.NoError(t, vault.ReplicateStates([]fdriver.StateWrite{
+		{Namespace: ns, Key: "k3", Value: []byte("v3"), Block: 12},
+	}, ns))
+	keys, err := vault.keys(ns)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"k3"}, keys)
+	v, _, _, err = ddb.GetState("other", "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("o1"), v)
+}
+
+func TestStateMetadataCommit(t *testing.T) {
+	ns := "namespace"
+	policy := map[string][]byte{"VALIDATION_PARAMETER": []byte("policy")}
+
+	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	vault := New(ddb, tidstore)
+
+	err = ddb.BeginUpdate()
+	assert.NoError(t, err)
+	err = ddb.SetState(ns, "k1", []byte("v1"), 35, 1)
+	assert.NoError(t, err)
+	err = ddb.SetState(ns, "k2", []byte("v2"), 35, 2)
+	assert.NoError(t, err)
+	err = ddb.Commit()
+	assert.NoError(t, err)
+
+	// a transaction changing the endorsement policy of a key only, as received from a block
+	rwsb := rwsetutil.NewRWSetBuilder()
+	rwsb.AddToMetadataWriteSet(ns, "k1", policy)
+	simRes, err := rwsb.GetTxSimulationResults()
+	assert.NoError(t, err)
+	rwsBytes, err := simRes.GetPubSimulationBytes()
+	assert.NoError(t, err)
+	rws, err := vault.GetRWSet("txid1", rwsBytes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ns}, rws.Namespaces())
+	rws.Done()
+	assert.NoError(t, vault.CommitTX("txid1", 36, 1))
+
+	qe, err := vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	meta, block, txnum, err := qe.GetStateMetadata(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, policy, meta)
+	assert.Equal(t, uint64(36), block)
+	assert.Equal(t, uint64(1), txnum)
+	v, err := qe.GetState(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	qe.Done()
+
+	// the metadata of a key deleted by the same transaction is not stored
+	rwsb = rwsetutil.NewRWSetBuilder()
+	rwsb.AddToWriteSet(ns, "k2", nil)
+	rwsb.AddToMetadataWriteSet(ns, "k2", policy)
+	simRes, err = rwsb.GetTxSimulationResults()
+	assert.NoError(t, err)
+	rwsBytes, err = simRes.GetPubSimulationBytes()
+	assert.NoError(t, err)
+	rws, err = vault.GetRWSet("txid2", rwsBytes)
+	assert.NoError(t, err)
+	rws.Done()
+	assert.NoError(t, vault.CommitTX("txid2", 37, 1))
+
+	qe, err = vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	defer qe.Done()
+	meta, _, _, err = qe.GetStateMetadata(ns, "k2")
+	assert.NoError(t, err)
+	assert.Empty(t, meta)
+	v, err = qe.GetState(ns, "k2")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+}
+
 func TestShardLikeCommit(t *testing.T) {
 	ns := "namespace"
 	k1 := "key1"
@@ -983,3 +1129,151 @@ func TestMain(m *testing.M) {
 
 	m.Run()
 }
+
+type countingPersistence struct {
+	driver.VersionedPersistence
+	writes int32
+}
+
+func (p *countingPersistence) SetState(namespace, key string, value []byte, block, txnum uint64) error {
+	atomic.AddInt32(&p.writes, 1)
+	return p.VersionedPersistence.SetState(namespace, key, value, block, txnum)
+}
+
+func TestCommitTXRace(t *testing.T) {
+	for run := 0; run < 50; run++ {
+		ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+		assert.NoError(t, err)
+		store := &countingPersistence{VersionedPersistence: ddb}
+		tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+		assert.NoError(t, err)
+		vault := New(store, tidstore)
+
+		rws, err := vault.NewRWSet("txid")
+		assert.NoError(t, err)
+		assert.NoError(t, rws.SetState("ns", "k1", []byte("v1")))
+		assert.NoError(t, rws.SetState("ns", "k2", []byte("v2")))
+		raw, err := rws.Bytes()
+		assert.NoError(t, err)
+		rws.Done()
+
+		// the local bookkeeping and the delivery of the block race on the same transaction
+		var wg sync.WaitGroup
+		commit := func(block uint64, indexInBloc int) {
+			defer wg.Done()
+			release := vault.LockTx("txid")
+			defer release()
+			if code, _ := vault.Status("txid"); code != fdriver.Valid && !vault.RWSExists("txid") {
+				// the read-write set is mapped again from the envelope, as the delivery does
+				rws, err := vault.GetRWSet("txid", raw)
+				assert.NoError(t, err)
+				rws.Done()
+			}
+			assert.NoError(t, vault.CommitTX("txid", block, indexInBloc))
+		}
+		wg.Add(4)
+		go commit(fdriver.UnknownBlock, fdriver.UnknownTxNum)
+		go commit(5, 2)
+		go commit(fdriver.UnknownBlock, fdriver.UnknownTxNum)
+		go commit(5, 2)
+		wg.Wait()
+
+		// the two keys, the height of the vault, and the checksum of the namespace
+		assert.Equal(t, int32(4), atomic.LoadInt32(&store.writes))
+		code, block, txNum, err := vault.StatusWithHeight("txid")
+		assert.NoError(t, err)
+		assert.Equal(t, fdriver.Valid, code)
+		// the height of the block is attached, whichever came first
+		assert.Equal(t, uint64(5), block)
+		assert.Equal(t, 2, txNum)
+		assert.Len(t, vault.interceptors, 0)
+		assert.Len(t, vault.txLocks.locks, 0)
+	}
+}
+
+// failingStatusStore fails reading the heights of the statuses while failing is set
+type failingStatusStore struct {
+	TXIDStore
+	failing bool
+}
+
+func (s *failingStatusStore) GetWithHeight(txid string) (fdriver.ValidationCode, uint64, int, error) {
+	if s.failing {
+		return fdriver.Unknown, fdriver.UnknownBlock, fdriver.UnknownTxNum, errors.New("status unavailable")
+	}
+	return s.TXIDStore.GetWithHeight(txid)
+}
+
+func TestCommitTXStatusUnavailable(t *testing.T) {
+	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	store := &countingPersistence{VersionedPersistence: ddb}
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	statuses := &failingStatusStore{TXIDStore: tidstore, failing: true}
+	vault := New(store, statuses)
+
+	rws, err := vault.NewRWSet("txid")
+	assert.NoError(t, err)
+	assert.NoError(t, rws.SetState("ns", "k1", []byte("v1")))
+	rws.Done()
+
+	// the transaction is not applied without knowing whether it is committed already
+	err = vault.CommitTX("txid", 5, 2)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status unavailable")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&store.writes))
+	assert.True(t, vault.RWSExists("txid"))
+
+	// the commit succeeds once the status can be read
+	statuses.failing = false
+	assert.NoError(t, vault.CommitTX("txid", 5, 2))
+	qe, err := vault.NewQueryExecutor()
+	assert.NoError(t, err)
+	v, err := qe.GetState("ns", "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	qe.Done()
+	code, block, txNum, err := vault.StatusWithHeight("txid")
+	assert.NoError(t, err)
+	assert.Equal(t, fdriver.Valid, code)
+	assert.Equal(t, uint64(5), block)
+	assert.Equal(t, 2, txNum)
+	assert.False(t, vault.RWSExists("txid"))
+}
+
+func TestDiscardTxWithCode(t *testing.T) {
+	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	vault := New(ddb, tidstore)
+
+	rws, err := vault.NewRWSet("txid")
+	assert.NoError(t, err)
+	rws.Done()
+	assert.NoError(t, vault.DiscardTxWithCode("txid", 7, 3, 11, "invalidated in block [7] at position [3]"))
+
+	// the height is recorded with the status
+	code, block, txNum, err := vault.StatusWithHeight("txid")
+	assert.NoError(t, err)
+	assert.Equal(t, fdriver.Invalid, code)
+	assert.Equal(t, uint64(7), block)
+	assert.Equal(t, 3, txNum)
+	fabricCode, message, found, err := vault.Outcome("txid")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int32(11), fabricCode)
+	assert.Equal(t, "invalidated in block [7] at position [3]", message)
+
+	// the transactions discarded locally have no height
+	rws, err = vault.NewRWSet("txid2")
+	assert.NoError(t, err)
+	rws.Done()
+	assert.NoError(t, vault.DiscardTx("txid2"))
+	code, block, txNum, err = vault.StatusWithHeight("txid2")
+	assert.NoError(t, err)
+	assert.Equal(t, fdriver.Invalid, code)
+	assert.Equal(t, fdriver.UnknownBlock, block)
+	assert.Equal(t, fdriver.UnknownTxNum, txNum)
+}
@@ -0,0 +1,231 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/keys"
+	"github.com/pkg/errors"
+)
+
+// watch is a watch of the keys of a namespace with a prefix
+type watch struct {
+	namespace string
+	prefix    string
+	// ch keeps a slot free for the overflow marker
+	ch     chan fdriver.KeyChange
+	closed bool
+}
+
+func (w *watch) matches(change *fdriver.KeyChange) bool {
+	return change.Namespace == w.namespace && strings.HasPrefix(change.Key, w.prefix)
+}
+
+// send sends the passed change without blocking. If the consumer did not keep up, the overflow marker is sent
+// instead and the watch is closed. It returns false if the watch is closed.
+func (w *watch) send(change fdriver.KeyChange) bool {
+	if len(w.ch) < cap(w.ch)-1 {
+		w.ch <- change
+		return true
+	}
+	logger.Warnf("watch of [%s:%s] overflowed at [%d:%d], close it", w.namespace, w.prefix, change.Block, change.TxNum)
+	w.ch <- fdriver.KeyChange{Namespace: w.namespace, Block: change.Block, TxNum: change.TxNum, Overflow: true}
+	w.close()
+	return false
+}
+
+func (w *watch) close() {
+	if !w.closed {
+		w.closed = true
+		close(w.ch)
+	}
+}
+
+// watches holds the watches of the keys of the vault, and the changes committed not sent yet.
+// The changes of a block are held until the block is fully committed.
+// lock is taken after storeLock and gate.lock.
+type watches struct {
+	lock    sync.Mutex
+	nextID  uint64
+	watches map[uint64]*watch
+	pending []fdriver.KeyChange
+}
+
+// collect holds the writes of the passed transaction, just committed.
+// They are held even if there is no watch yet, for the watches registered before the block is fully committed.
+func (w *watches) collect(txID string, block, txNum uint64, txWrites writes) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var changes []fdriver.KeyChange
+	for ns, keyMap := range txWrites {
+		for key, value := range keyMap {
+			change := fdriver.KeyChange{Namespace: ns, Key: key, Block: block, TxNum: txNum, TxID: txID}
+			if len(value) == 0 {
+				change.Deleted = true
+			} else {
+				change.Value = append([]byte{}, value...)
+			}
+			changes = append(changes, change)
+		}
+	}
+	sortChanges(changes)
+	w.pending = append(w.pending, changes...)
+}
+
+// flush sends the changes held to the watches matching them
+func (w *watches) flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, change := range w.pending {
+		for id, wt := range w.watches {
+			if wt.matches(&change) && !wt.send(change) {
+				delete(w.watches, id)
+			}
+		}
+	}
+	w.pending = nil
+}
+
+// add registers a watch that sends first the passed changes replayed, but the ones held, that are sent once flushed
+func (w *watches) add(namespace, prefix string, buffer int, replay []fdriver.KeyChange) (<-chan fdriver.KeyChange, func()) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	held := map[string]bool{}
+	for _, change := range w.pending {
+		held[changeID(&change)] = true
+	}
+	wt := &watch{namespace: namespace, prefix: prefix, ch: make(chan fdriver.KeyChange, len(replay)+buffer+1)}
+	for _, change := range replay {
+		if !held[changeID(&change)] {
+			wt.ch <- change
+		}
+	}
+
+	if w.watches == nil {
+		w.watches = map[uint64]*watch{}
+	}
+	id := w.nextID
+	w.nextID++
+	w.watches[id] = wt
+
+	var once sync.Once
+	return wt.ch, func() {
+		once.Do(func() {
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			delete(w.watches, id)
+			wt.close()
+		})
+	}
+}
+
+// WatchKeys returns a channel of the changes of the keys of the passed namespace with the passed prefix,
+// committed by the valid transactions, and a function to cancel the watch, that closes the channel.
+// The changes of a block are sent once the block is fully committed, in the order they were committed.
+// The channel buffers opts.Buffer changes: once full, the commits do not wait for the consumer, an overflow
+// marker is sent and the channel is closed.
+// If opts.Replay is set, the changes committed from the block opts.From onwards are sent first, without their txID.
+// They are read from the history of the namespace if kept, including the deletions, otherwise from the current
+// state: then only the last version of each key is replayed, and the deletions are not.
+// Replaying a namespace with history from before its horizon returns fdriver.ErrHeightBeforeHorizon.
+func (db *Vault) WatchKeys(namespace, prefix string, opts fdriver.WatchOptions) (<-chan fdriver.KeyChange, func(), error) {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = fdriver.DefaultWatchBuffer
+	}
+	if !opts.Replay {
+		ch, cancel := db.watches.add(namespace, prefix, buffer, nil)
+		return ch, cancel, nil
+	}
+
+	// no transaction is committed between the replay and the registration of the watch
+	db.readLockStore()
+	defer db.storeLock.RUnlock()
+	replay, err := db.replayChanges(namespace, prefix, opts.From)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, cancel := db.watches.add(namespace, prefix, buffer, replay)
+	return ch, cancel, nil
+}
+
+// replayChanges returns the changes of the keys of the passed namespace with the passed prefix committed from
+// the passed block onwards. db.storeLock must be held.
+func (db *Vault) replayChanges(namespace, prefix string, from uint64) ([]fdriver.KeyChange, error) {
+	var changes []fdriver.KeyChange
+	db.history.lock.RLock()
+	horizon, kept := db.history.horizons[namespace]
+	db.history.lock.RUnlock()
+	if kept {
+		if from < horizon {
+			return nil, errors.Wrapf(fdriver.ErrHeightBeforeHorizon, "height [%d] precedes the retention horizon [%d] of namespace [%s]", from, horizon, namespace)
+		}
+		// the hex digits sort before g
+		start := namespace + keys.NamespaceSeparator + hex.EncodeToString([]byte(prefix))
+		reads, err := db.scan(historyNamespace, start, start+"g")
+		if err != nil {
+			return nil, err
+		}
+		for _, read := range reads {
+			key, block, txNum, err := parseHistoryKey(read.Key)
+			if err != nil {
+				return nil, err
+			}
+			if block < from {
+				continue
+			}
+			value := decodeVersion(read.Raw)
+			changes = append(changes, fdriver.KeyChange{Namespace: namespace, Key: key, Value: value, Deleted: value == nil, Block: block, TxNum: txNum, Replayed: true})
+		}
+	} else {
+		reads, err := db.scan(namespace, prefix, prefix+string(utf8.MaxRune))
+		if err != nil {
+			return nil, err
+		}
+		for _, read := range reads {
+			if read.Block < from || len(read.Raw) == 0 {
+				continue
+			}
+			changes = append(changes, fdriver.KeyChange{Namespace: namespace, Key: read.Key, Value: read.Raw, Block: read.Block, TxNum: uint64(read.IndexInBlock), Replayed: true})
+		}
+	}
+	sortChanges(changes)
+	return changes, nil
+}
+
+// notifyChanges sends the changes held, unless a block commit is in flight: then they are sent once it ends
+func (db *Vault) notifyChanges() {
+	db.gate.lock.Lock()
+	defer db.gate.lock.Unlock()
+	if db.gate.inFlight == 0 {
+		db.watches.flush()
+	}
+}
+
+// sortChanges sorts the passed changes by height, and then by key
+func sortChanges(changes []fdriver.KeyChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Block != changes[j].Block {
+			return changes[i].Block < changes[j].Block
+		}
+		if changes[i].TxNum != changes[j].TxNum {
+			return changes[i].TxNum < changes[j].TxNum
+		}
+		return changes[i].Key < changes[j].Key
+	})
+}
+
+func changeID(change *fdriver.KeyChange) string {
+	return historyKey(change.Namespace, change.Key, change.Block, change.TxNum)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"testing"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// drain returns the changes sent on the passed channel so far, and whether the channel is closed
+func drain(ch <-chan fdriver.KeyChange) ([]fdriver.KeyChange, bool) {
+	var changes []fdriver.KeyChange
+	for {
+		select {
+		case change, ok := <-ch:
+			if !ok {
+				return changes, true
+			}
+			changes = append(changes, change)
+		default:
+			return changes, false
+		}
+	}
+}
+
+func TestWatchKeys(t *testing.T) {
+	vault, _ := newBackupVault(t)
+	ch, cancel, err := vault.WatchKeys("assets", "a", fdriver.WatchOptions{})
+	assert.NoError(t, err)
+
+	// the changes of a block are sent once the block is fully committed
+	vault.BeginBlockCommit()
+	commitWrites(t, vault, 1, map[string][]byte{"a1": []byte("v1"), "a0": []byte("v0"), "b1": []byte("v1")})
+	changes, _ := drain(ch)
+	assert.Empty(t, changes)
+	vault.EndBlockCommit()
+	changes, closed := drain(ch)
+	assert.False(t, closed)
+	assert.Equal(t, []fdriver.KeyChange{
+		{Namespace: "assets", Key: "a0", Value: []byte("v0"), Block: 1, TxID: "tx1"},
+		{Namespace: "assets", Key: "a1", Value: []byte("v1"), Block: 1, TxID: "tx1"},
+	}, changes)
+
+	// outside a block commit, the changes are sent right away
+	commitWrites(t, vault, 2, map[string][]byte{"a1": nil})
+	changes, _ = drain(ch)
+	assert.Equal(t, []fdriver.KeyChange{{Namespace: "assets", Key: "a1", Deleted: true, Block: 2, TxID: "tx2"}}, changes)
+
+	cancel()
+	cancel()
+	commitWrites(t, vault, 3, map[string][]byte{"a1": []byte("v3")})
+	changes, closed = drain(ch)
+	assert.Empty(t, changes)
+	assert.True(t, closed)
+}
+
+func TestWatchOverflow(t *testing.T) {
+	vault, _ := newBackupVault(t)
+	slow, cancelSlow, err := vault.WatchKeys("assets", "", fdriver.WatchOptions{Buffer: 2})
+	assert.NoError(t, err)
+	defer cancelSlow()
+	fast, cancelFast, err := vault.WatchKeys("assets", "", fdriver.WatchOptions{Buffer: 2})
+	assert.NoError(t, err)
+	defer cancelFast()
+
+	// the commits do not wait for the slow consumer, that is told it overflowed
+	for block := uint64(1); block <= 4; block++ {
+		commitWrites(t, vault, block, map[string][]byte{"a": []byte{byte(block)}})
+		changes, closed := drain(fast)
+		assert.Len(t, changes, 1)
+		assert.False(t, closed)
+	}
+	changes, closed := drain(slow)
+	assert.True(t, closed)
+	if assert.Len(t, changes, 3) {
+		assert.Equal(t, uint64(2), changes[1].Block)
+		assert.Equal(t, fdriver.KeyChange{Namespace: "assets", Block: 3, Overflow: true}, changes[2])
+	}
+}
+
+func TestWatchReplay(t *testing.T) {
+	// the namespace keeps its history, the deletions are replayed
+	vault, _ := newBackupVault(t)
+	assert.NoError(t, vault.SetHistoryNamespaces("assets"))
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("1")})
+	commitWrites(t, vault, 2, map[string][]byte{"a": nil, "c": []byte("2")})
+	commitWrites(t, vault, 3, map[string][]byte{"b": []byte("3")})
+
+	vault.BeginBlockCommit()
+	commitWrites(t, vault, 4, map[string][]byte{"b": []byte("4")})
+	ch, cancel, err := vault.WatchKeys("assets", "", fdriver.WatchOptions{Replay: true, From: 2})
+	assert.NoError(t, err)
+	defer cancel()
+	vault.EndBlockCommit()
+	changes, _ := drain(ch)
+	assert.Equal(t, []fdriver.KeyChange{
+		{Namespace: "assets", Key: "a", Deleted: true, Block: 2, Replayed: true},
+		{Namespace: "assets", Key: "c", Value: []byte("2"), Block: 2, Replayed: true},
+		{Namespace: "assets", Key: "b", Value: []byte("3"), Block: 3, Replayed: true},
+		{Namespace: "assets", Key: "b", Value: []byte("4"), Block: 4, TxID: "tx4"},
+	}, changes)
+
+	assert.NoError(t, vault.PruneHistory("assets", 3))
+	_, _, err = vault.WatchKeys("assets", "", fdriver.WatchOptions{Replay: true, From: 2})
+	assert.ErrorIs(t, err, fdriver.ErrHeightBeforeHorizon)
+
+	// otherwise, the last versions are replayed from the current state
+	vault, _ = newBackupVault(t)
+	commitWrites(t, vault, 1, map[string][]byte{"a": []byte("1"), "b": []byte("1")})
+	commitWrites(t, vault, 2, map[string][]byte{"a": []byte("2"), "c": nil})
+	commitWrites(t, vault, 3, map[string][]byte{"a": []byte("3"), "b": nil})
+	ch, cancel, err = vault.WatchKeys("assets", "", fdriver.WatchOptions{Replay: true, From: 2})
+	assert.NoError(t, err)
+	defer cancel()
+	changes, _ = drain(ch)
+	assert.Equal(t, []fdriver.KeyChange{{Namespace: "assets", Key: "a", Value: []byte("3"), Block: 3, Replayed: true}}, changes)
+}
@@ -0,0 +1,14 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package generic
+
+import "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+
+// WatchKeys watches the changes of the keys of the vault of this channel, see vault.Vault#WatchKeys
+func (c *channel) WatchKeys(namespace, prefix string, opts driver.WatchOptions) (<-chan driver.KeyChange, func(), error) {
+	return c.vault.WatchKeys(namespace, prefix, opts)
+}
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package driver
 
 import (
+	"context"
 	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
@@ -40,6 +41,10 @@ type ChaincodeInvocation interface {
 
 	WithImplicitCollections(mspIDs ...string) ChaincodeInvocation
 
+	// WithEndorsersFromChannelPeers sets the MSP IDs of the organizations whose peers must endorse.
+	// The peers of other organizations are taken from the channel configuration.
+	WithEndorsersFromChannelPeers(mspIDs ...string) ChaincodeInvocation
+
 	// WithDiscoveredEndorsersByEndpoints sets the endpoints to be used to filter the result of
 	// discovery. Discovery is used to identify the chaincode's endorsers, if not set otherwise.
 	WithDiscoveredEndorsersByEndpoints(endpoints ...string) ChaincodeInvocation
@@ -53,6 +58,13 @@ type ChaincodeInvocation interface {
 
 	// WithRetrySleep sets the time interval between each retry
 	WithRetrySleep(duration time.Duration) ChaincodeInvocation
+
+	// WithNoCache makes the query bypass the query cache of the chaincode, if enabled
+	WithNoCache() ChaincodeInvocation
+
+	// WithContext sets the context of the flow running the invocation, whose latency phases the invocation marks,
+	// and whose cancellation interrupts the wait for the finality of the transaction submitted
+	WithContext(ctx context.Context) ChaincodeInvocation
 }
 
 // DiscoveredPeer contains the information of a discovered peer
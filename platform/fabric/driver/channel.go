@@ -7,10 +7,27 @@ SPDX-License-Identifier: Apache-2.0
 package driver
 
 import (
+	"fmt"
+
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/peer"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 )
 
+// ConfigApplied is published on the event bus when a configuration becomes the active one of a channel,
+// either while committing its block or while reloading the configurations from the vault at startup.
+// The configurations of a channel are published in the order of their sequence.
+type ConfigApplied struct {
+	Network string
+	Channel string
+	// Sequence is the sequence number of the configuration
+	Sequence uint64
+	// Orderers are the orderer endpoints listed by the configuration, if any
+	Orderers []*grpc.ConnectionConfig
+	// ApplicationCapabilities are the application capabilities of the configuration, sorted,
+	// nil if it has no application group
+	ApplicationCapabilities []string
+}
+
 // Channel gives access to Fabric channel related information
 type Channel interface {
 	Committer
@@ -39,3 +56,132 @@ type Channel interface {
 
 	Close() error
 }
+
+// ConfigChange is the kind of change of an element of a channel configuration
+type ConfigChange string
+
+const (
+	ConfigAdded    ConfigChange = "added"
+	ConfigRemoved  ConfigChange = "removed"
+	ConfigModified ConfigChange = "modified"
+)
+
+// ConfigElementChange is the change of a policy, a value, or the mod policy of a group, at a path of the configuration tree
+type ConfigElementChange struct {
+	Path string       `json:"path"`
+	Kind ConfigChange `json:"kind"`
+}
+
+// OrganizationChange is an organization added to, or removed from, a section of the configuration
+type OrganizationChange struct {
+	// Path is the path of the group of the organization, for instance /Channel/Application/Org1MSP
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	MSPID string `json:"mspID,omitempty"`
+}
+
+// EndpointsChange is the change of the orderer endpoints listed at a path of the configuration
+type EndpointsChange struct {
+	Path    string   `json:"path"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// CapabilitiesChange is the change of the capabilities listed at a path of the configuration
+type CapabilitiesChange struct {
+	Path    string   `json:"path"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// BatchSize are the batch size parameters of the orderers
+type BatchSize struct {
+	MaxMessageCount   uint32 `json:"maxMessageCount"`
+	AbsoluteMaxBytes  uint32 `json:"absoluteMaxBytes"`
+	PreferredMaxBytes uint32 `json:"preferredMaxBytes"`
+}
+
+// BatchChange is the change of the batch parameters of the orderers, the unchanged ones are nil
+type BatchChange struct {
+	PreviousSize    *BatchSize `json:"previousSize,omitempty"`
+	NextSize        *BatchSize `json:"nextSize,omitempty"`
+	PreviousTimeout string     `json:"previousTimeout,omitempty"`
+	NextTimeout     string     `json:"nextTimeout,omitempty"`
+}
+
+// ConfigDiff is the difference between two configurations of a channel
+type ConfigDiff struct {
+	Policies             []ConfigElementChange `json:"policies,omitempty"`
+	AddedOrganizations   []OrganizationChange  `json:"addedOrganizations,omitempty"`
+	RemovedOrganizations []OrganizationChange  `json:"removedOrganizations,omitempty"`
+	OrdererEndpoints     []EndpointsChange     `json:"ordererEndpoints,omitempty"`
+	Batch                *BatchChange          `json:"batch,omitempty"`
+	Capabilities         []CapabilitiesChange  `json:"capabilities,omitempty"`
+	// Other are the changes of the other values, and of the mod policies of the groups
+	Other []ConfigElementChange `json:"other,omitempty"`
+}
+
+// ConfigError is the failure of the validation of a config update, at a path of the configuration tree if known
+type ConfigError struct {
+	// Path is the path of the failing element, for instance /Channel/Application/Org1MSP, empty if unknown
+	Path string `json:"path,omitempty"`
+	// Element is the type of the failing element, Group, Value or Policy, empty if unknown
+	Element string `json:"element,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ConfigError) Error() string {
+	return e.Message
+}
+
+// ConfigUpdateSimulation is the result of the simulation of a config update against the active configuration of a channel
+type ConfigUpdateSimulation struct {
+	Valid bool `json:"valid"`
+	// Sequence is the sequence the configuration would have once the update committed
+	Sequence uint64 `json:"sequence,omitempty"`
+	// Error is set when the update is not valid
+	Error *ConfigError `json:"error,omitempty"`
+	// Diff is set when the update is valid
+	Diff *ConfigDiff `json:"diff,omitempty"`
+}
+
+// ConfigUpdateSimulator is implemented by the channels able to validate config updates without committing them
+type ConfigUpdateSimulator interface {
+	// SimulateConfigUpdate validates the passed envelope of type CONFIG_UPDATE against the active configuration,
+	// as a config transaction is when committed, and returns the changes it would make.
+	// An update that does not pass the validation is reported by the result, an error is returned
+	// only if the simulation could not run.
+	SimulateConfigUpdate(envelope []byte) (*ConfigUpdateSimulation, error)
+}
+
+// ErrTooManyChannels is returned when opening a channel would exceed the maximum number of open channels of a network
+type ErrTooManyChannels struct {
+	Network string
+	Channel string
+	// Max is the maximum number of open channels
+	Max int
+}
+
+func (e *ErrTooManyChannels) Error() string {
+	return fmt.Sprintf("fabric network [%s] cannot open channel [%s], [%d] channels are open already", e.Network, e.Channel, e.Max)
+}
+
+// ChannelDescription describes what is bound to a channel, it does not contain sensitive values
+type ChannelDescription struct {
+	// ConfigSequence is the sequence of the configuration in force, 0 if none has been applied yet
+	ConfigSequence uint64 `json:"configSequence"`
+	// Chaincodes are the chaincodes bound to the channel, sorted by name
+	Chaincodes []string `json:"chaincodes"`
+	// ChaincodeSubscriptions maps the chaincodes to the number of the active subscriptions to their events
+	ChaincodeSubscriptions map[string]int `json:"chaincodeSubscriptions,omitempty"`
+	// ProcessNamespaces are the namespaces whose transactions are processed even if not known to the vault
+	ProcessNamespaces []string `json:"processNamespaces,omitempty"`
+	// Unloaded is true if the channel has been unloaded while idle, it is reloaded by its next use
+	Unloaded bool `json:"unloaded,omitempty"`
+}
+
+// ChannelInspector is implemented by the channels able to describe what is bound to them
+type ChannelInspector interface {
+	// Describe returns the description of the channel as it is now
+	Describe() *ChannelDescription
+}
@@ -6,7 +6,13 @@ SPDX-License-Identifier: Apache-2.0
 
 package driver
 
-import "github.com/hyperledger/fabric-protos-go/common"
+import (
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/pkg/errors"
+)
 
 // ValidationCode of transaction
 type ValidationCode int
@@ -18,8 +24,24 @@ const (
 	Busy                           // Transaction does not yet have a validity state
 	Unknown                        // Transaction is unknown
 	HasDependencies                // Transaction is unknown but has known dependencies
+	Abandoned                      // Transaction has been busy with no trace on the ledger, and has been given up
+)
+
+const (
+	// UnknownBlock is the block number returned for a transaction whose height is not known.
+	// This is the case of transactions not yet committed or committed before heights were recorded.
+	UnknownBlock uint64 = math.MaxUint64
+	// UnknownTxNum is the position in the block returned for a transaction whose height is not known
+	UnknownTxNum = -1
 )
 
+// ErrTransactionAbandoned is returned to the waiters of the finality of an abandoned transaction
+var ErrTransactionAbandoned = errors.New("transaction abandoned")
+
+// ErrShuttingDown is returned to the waiters of the finality of a transaction not final when the channel shuts down,
+// and ends the subscriptions of the channel
+var ErrShuttingDown = errors.New("shutting down")
+
 // TransactionStatusChanged is sent when the status of a transaction changes
 type TransactionStatusChanged struct {
 	ThisTopic string
@@ -52,6 +74,11 @@ type Committer interface {
 	// a list of dependant transaction ids if they exist.
 	Status(txid string) (ValidationCode, []string, error)
 
+	// StatusWithHeight returns the validation code this committer bind to the passed transaction id, plus
+	// the number of the block and the position in the block where the transaction has been committed, or invalidated.
+	// If the height is not known, UnknownBlock and UnknownTxNum are returned.
+	StatusWithHeight(txid string) (code ValidationCode, block uint64, txNum int, err error)
+
 	// DiscardTx discards the transaction with the passed id and all its dependencies, if they exists.
 	DiscardTx(txid string) error
 
@@ -59,14 +86,15 @@ type Committer interface {
 	// Depending on tx's status, CommitTX does the following:
 	// Tx is Unknown, CommitTx does nothing and returns no error.
 	// Tx is HasDependencies, CommitTx proceeds with the multi-shard private transaction commit protocol.
-	// Tx is Valid, CommitTx does nothing and returns an error.
+	// Tx is Valid, CommitTx attaches the passed height to the transaction, if it has none, and returns no error.
 	// Tx is Invalid, CommitTx does nothing and returns an error.
 	// Tx is Busy, if Tx is a multi-shard private transaction then CommitTx proceeds with the multi-shard private transaction commit protocol,
 	// otherwise, CommitTx commits the transaction.
+	// The calls for the same transaction are serialized, its read-write set is applied once.
 	CommitTX(txid string, block uint64, indexInBloc int, envelope *common.Envelope) error
 
-	// CommitConfig commits the passed configuration envelope.
-	CommitConfig(blockNumber uint64, raw []byte, envelope *common.Envelope) error
+	// CommitConfig commits the passed configuration envelope found in the passed block at the passed position.
+	CommitConfig(blockNumber uint64, indexInBlock int, raw []byte, envelope *common.Envelope) error
 
 	// SubscribeTxStatusChanges registers a listener for transaction status changes for the passed transaction id.
 	// If the transaction id is empty, the listener will be called for all transactions.
@@ -76,3 +104,40 @@ type Committer interface {
 	// If the transaction id is empty, the listener will be called for all transactions.
 	UnsubscribeTxStatusChanges(txID string, listener TxStatusChangeListener) error
 }
+
+const (
+	// ResolutionRepaired tells that the transaction has been found on the ledger, and its block re-processed
+	ResolutionRepaired = "repaired"
+	// ResolutionAbandoned tells that the transaction has no trace on the ledger, and has been abandoned
+	ResolutionAbandoned = "abandoned"
+)
+
+// ResolutionPolicy tells when a stuck transaction with no trace on the ledger can be abandoned
+type ResolutionPolicy struct {
+	// MinAge is how long the transaction must have been busy before it can be abandoned
+	MinAge time.Duration
+}
+
+// Resolution is the outcome of the resolution of a stuck transaction
+type Resolution struct {
+	TxID string `json:"txid"`
+	// Previous and Code are the status of the transaction in the vault before and after the resolution
+	Previous ValidationCode `json:"previous"`
+	Code     ValidationCode `json:"code"`
+	// Action is ResolutionRepaired or ResolutionAbandoned
+	Action string `json:"action"`
+	// Block and TxNum locate the transaction on the ledger, UnknownBlock and UnknownTxNum if not found
+	Block uint64 `json:"block"`
+	TxNum int    `json:"txNum"`
+	// Age is how long the transaction has been busy, zero if not known
+	Age time.Duration `json:"age,omitempty"`
+}
+
+// StuckTransactionResolver is implemented by the channels able to resolve the transactions stuck in the Busy status
+type StuckTransactionResolver interface {
+	// ResolveStuckTransaction checks the ledger of the peers for the passed busy, or abandoned, transaction.
+	// If the ledger has it, the vault is repaired by re-processing its block. Otherwise, if the transaction is
+	// older than the policy allows, the transaction is marked as Abandoned and its finality waiters are released
+	// with ErrTransactionAbandoned. A status the ledger contradicts is never set.
+	ResolveStuckTransaction(txID string, policy ResolutionPolicy) (*Resolution, error)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The components of a Fabric network whose compatibility with this node is checked
+const (
+	// CompatibilityOrderer is an orderer endpoint, its version is told by the blocks it delivers
+	CompatibilityOrderer = "orderer"
+	// CompatibilityApplication is the application capability level of a channel, the peers joining the channel
+	// support it, therefore it bounds their version
+	CompatibilityApplication = "application"
+)
+
+// ComponentCompatibility is the outcome of the last compatibility check of a component of a Fabric network
+type ComponentCompatibility struct {
+	// Component is CompatibilityOrderer or CompatibilityApplication
+	Component string `json:"component"`
+	// Name is the address of the orderer, or the name of the channel
+	Name string `json:"name"`
+	// Detected is the version detected, empty if it could not be detected
+	Detected string `json:"detected,omitempty"`
+	// Minimum is the minimum version supported
+	Minimum string `json:"minimum"`
+	// Compatible is false if the detected version is not supported. The components whose version
+	// could not be detected are compatible.
+	Compatible bool `json:"compatible"`
+	// Error tells why the version could not be detected, if so
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// CompatibilityReport is the outcome of the compatibility checks of a Fabric network, the last one for each component
+type CompatibilityReport struct {
+	// Compatible is false if at least one component is not compatible
+	Compatible bool `json:"compatible"`
+	// Components are sorted by component and name
+	Components []ComponentCompatibility `json:"components"`
+}
+
+// CompatibilityChecker is implemented by the networks checking the versions of the Fabric components they connect to
+type CompatibilityChecker interface {
+	// Compatibility returns the outcome of the compatibility checks run so far, nil if disabled
+	Compatibility() *CompatibilityReport
+}
+
+// ErrIncompatible is returned when the versions of some components of a Fabric network are not supported
+type ErrIncompatible struct {
+	Network    string
+	Components []ComponentCompatibility
+}
+
+func (e *ErrIncompatible) Error() string {
+	components := make([]string, len(e.Components))
+	for i, c := range e.Components {
+		components[i] = fmt.Sprintf("%s [%s] runs [%s], at least [%s] required", c.Component, c.Name, c.Detected, c.Minimum)
+	}
+	return fmt.Sprintf("network [%s] is not compatible: %s", e.Network, strings.Join(components, "; "))
+}
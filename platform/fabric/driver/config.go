@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package driver
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
@@ -37,4 +38,19 @@ type Config interface {
 	Orderers() []*grpc.ConnectionConfig
 
 	Peers() []*grpc.ConnectionConfig
+
+	// ReadOnly returns true if this node is a read-only replica on the network,
+	// the operations signing or broadcasting transactions fail with an ErrReadOnly
+	ReadOnly() bool
+}
+
+// ErrReadOnly is returned by the operations refused on a read-only network
+type ErrReadOnly struct {
+	Network string
+	// Operation is the refused operation
+	Operation string
+}
+
+func (e *ErrReadOnly) Error() string {
+	return fmt.Sprintf("fabric network [%s] is read-only, [%s] refused", e.Network, e.Operation)
 }
@@ -10,6 +10,7 @@ import (
 	"reflect"
 
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/pkg/errors"
 )
 
 // FabricNetworkService gives access to a Fabric network components
@@ -42,6 +43,34 @@ type FabricNetworkService interface {
 	ConfigService() ConfigService
 }
 
+// ErrChannelRemoved is returned to the subscriptions and to the callers of a channel removed from its network
+var ErrChannelRemoved = errors.New("channel removed")
+
+// ArchiveOptions tell what happens to the data of a channel removed from its network
+type ArchiveOptions struct {
+	// Path is the file the vault of the channel, its configuration transactions included, is archived to before
+	// being deleted. The file must not exist. If empty, the vault is deleted without archive.
+	Path string
+}
+
+// RejoinOptions tell how a channel removed from its network joins it again
+type RejoinOptions struct {
+	// Archive is the file written by the removal of the channel its vault is restored from.
+	// If empty, the vault starts empty.
+	Archive string
+}
+
+// ChannelRemover is implemented by the networks whose channels can be removed at runtime and joined again
+type ChannelRemover interface {
+	// RemoveChannel stops the delivery of the passed channel, ends its subscriptions with ErrChannelRemoved,
+	// archives or deletes its vault, and removes it from the network: its state can no longer be queried.
+	// The removal outlives the restarts of the node, until the channel is joined again.
+	RemoveChannel(name string, opts ArchiveOptions) error
+	// RejoinChannel joins again the passed channel removed, its vault is empty or restored from an archive,
+	// and resyncs it from the ordering service
+	RejoinChannel(name string, opts RejoinOptions) (Channel, error)
+}
+
 type FabricNetworkServiceProvider interface {
 	Names() []string
 	DefaultName() string
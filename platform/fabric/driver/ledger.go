@@ -39,3 +39,36 @@ type Ledger interface {
 	// GetBlockByNumber fetches a block by number
 	GetBlockByNumber(number uint64) (Block, error)
 }
+
+// Evidence packages the artifacts proving that a transaction has been committed,
+// so that it can be verified without access to the node
+type Evidence struct {
+	// Channel is the channel the transaction is committed on
+	Channel string `json:"channel"`
+	// TxID is the id of the transaction
+	TxID string `json:"txID"`
+	// TxNum is the index of the transaction in its block
+	TxNum uint64 `json:"txNum"`
+	// Envelope is the envelope of the transaction
+	Envelope []byte `json:"envelope"`
+	// Block is the block the transaction is committed in, with the signatures of the orderers
+	Block []byte `json:"block"`
+	// ConfigBlock is the last configuration block of the channel when Block has been cut, Block itself for a config transaction
+	ConfigBlock []byte `json:"configBlock"`
+	// Headers are the headers of the blocks between ConfigBlock and Block, excluded, linking them by their hashes
+	Headers [][]byte `json:"headers,omitempty"`
+	// ValidationCode is the validation code of the transaction recorded in Block.
+	// The orderers do not sign it, it is attested by the peers in Attestations.
+	ValidationCode int32 `json:"validationCode"`
+	// Attestations are the responses of the peers, signed by them, to the query GetTransactionByID of qscc,
+	// carrying the envelope and the validation code of the transaction
+	Attestations [][]byte `json:"attestations"`
+	// VaultStatus is the status of the transaction recorded by the vault of the node
+	VaultStatus ValidationCode `json:"vaultStatus"`
+}
+
+// EvidenceProvider is implemented by the channels able to assemble the evidence of their transactions
+type EvidenceProvider interface {
+	// EvidenceBundle returns the evidence that the passed transaction has been committed
+	EvidenceBundle(txID string) (*Evidence, error)
+}
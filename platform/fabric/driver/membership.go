@@ -8,6 +8,7 @@ package driver
 
 import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
 
@@ -42,6 +43,12 @@ type LocalMembership interface {
 	Refresh() error
 }
 
+// MSPSnapshotter is implemented by the local memberships able to describe their MSPs for debugging purposes
+type MSPSnapshotter interface {
+	// Snapshots returns the debug snapshots of the local MSPs, by name
+	Snapshots() map[string]interface{}
+}
+
 type MSPIdentity interface {
 	GetMSPIdentifier() string
 	Validate() error
@@ -52,8 +59,18 @@ type MSPManager interface {
 	DeserializeIdentity(serializedIdentity []byte) (MSPIdentity, error)
 }
 
+// ChannelPeer is a peer of another application organization of the channel, as found in the channel configuration
+type ChannelPeer struct {
+	// MSPID is the MSP identifier of the organization the peer belongs to
+	MSPID string
+	grpc.ConnectionConfig
+}
+
 type ChannelMembership interface {
 	GetMSPIDs() []string
+	// ChannelPeers returns the peers of the other application organizations of the channel
+	// this node is allowed to contact
+	ChannelPeers() []ChannelPeer
 	MSPManager() MSPManager
 	IsValid(identity view.Identity) error
 	GetVerifier(identity view.Identity) (driver.Verifier, error)
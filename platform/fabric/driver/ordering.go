@@ -6,8 +6,167 @@ SPDX-License-Identifier: Apache-2.0
 
 package driver
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 // Ordering models the ordering service
 type Ordering interface {
 	// Broadcast sends the passed blob to the ordering service to be ordered
 	Broadcast(blob interface{}) error
 }
+
+// OrdererStatus is the outcome of the last pre-flight check of an orderer endpoint
+type OrdererStatus struct {
+	Address string
+	// Degraded is true if the check failed, the broadcast prefers the other endpoints
+	Degraded bool
+	// Err is the reason of the failure, if any
+	Err       error
+	Latency   time.Duration
+	CheckedAt time.Time
+}
+
+// OrdererChecker is implemented by the networks that check their orderer endpoints before using them
+type OrdererChecker interface {
+	// OrdererStatuses returns the outcome of the last pre-flight check of each orderer endpoint checked so far
+	OrdererStatuses() []OrdererStatus
+}
+
+// EnvelopeSizeLimiter is implemented by the channels that bound the size of the envelopes they broadcast
+type EnvelopeSizeLimiter interface {
+	// EnvelopeSizeLimit returns the maximum size in bytes of an envelope, 0 if there is no limit
+	EnvelopeSizeLimit() uint32
+}
+
+// OrderingParameters are the parameters of the ordering service of a channel, read from its active configuration
+type OrderingParameters struct {
+	// ConsensusType is the consensus of the orderers, like etcdraft or BFT
+	ConsensusType string
+	// BatchTimeout is the time the orderers wait for more transactions before cutting a block
+	BatchTimeout time.Duration
+	// MaxMessageCount, AbsoluteMaxBytes and PreferredMaxBytes bound the blocks the orderers cut
+	MaxMessageCount   uint32
+	AbsoluteMaxBytes  uint32
+	PreferredMaxBytes uint32
+	// Sequence is the sequence of the configuration the parameters are read from
+	Sequence uint64
+}
+
+// OrderingInspector is implemented by the channels exposing the parameters of their ordering service
+type OrderingInspector interface {
+	// OrderingParameters returns the parameters of the ordering service in the active configuration
+	OrderingParameters() (*OrderingParameters, error)
+	// ExpectedOrderingLatency returns a hint of the time a transaction takes from its broadcast to its commit
+	ExpectedOrderingLatency() (time.Duration, error)
+}
+
+// BroadcastObserver is implemented by the channels measuring the time their transactions take from the broadcast
+// to the commit
+type BroadcastObserver interface {
+	// ObserveBroadcast records that the passed transaction has been broadcast at the passed time
+	ObserveBroadcast(txID string, sentAt time.Time)
+}
+
+// WriteSize is the contribution of a write to the size of an envelope
+type WriteSize struct {
+	Namespace string
+	Key       string
+	// Size is the size in bytes of the key and of the value
+	Size int
+}
+
+// ErrEnvelopeTooLarge is returned, before broadcasting, for the envelopes exceeding the size limit of their channel
+type ErrEnvelopeTooLarge struct {
+	TxID    string
+	Channel string
+	Size    int
+	Limit   uint32
+	// LargestWrites are the writes contributing the most to the size, the largest first
+	LargestWrites []WriteSize
+}
+
+func (e *ErrEnvelopeTooLarge) Error() string {
+	writes := make([]string, len(e.LargestWrites))
+	for i, w := range e.LargestWrites {
+		writes[i] = fmt.Sprintf("%s:%s (%d bytes)", w.Namespace, w.Key, w.Size)
+	}
+	return fmt.Sprintf("envelope of transaction [%s] on channel [%s] is [%d] bytes, exceeding the limit of [%d] bytes, largest writes [%s]",
+		e.TxID, e.Channel, e.Size, e.Limit, strings.Join(writes, ", "))
+}
+
+// ErrDuplicateInFlight is returned, instead of broadcasting, for the transactions semantically identical
+// to one broadcast recently and not final yet. It is wrapped with the transaction and the one in flight.
+var ErrDuplicateInFlight = errors.New("duplicate of a transaction in flight")
+
+// StaleRead is a read of a transaction whose key has been written, in the local vault, after the endorsement
+type StaleRead struct {
+	Namespace string
+	Key       string
+	// Block and TxNum are the version read at endorsement, zero if the key did not exist
+	Block, TxNum uint64
+	// CommittedBlock and CommittedTxNum are the version of the key committed in the vault, CommittedBlock is the block that changed it
+	CommittedBlock, CommittedTxNum uint64
+}
+
+// ErrStaleReadSet is returned, instead of broadcasting, for the transactions whose read set is already stale
+// with respect to the local vault. The transaction would fail for an MVCC conflict, it can be endorsed again right away.
+type ErrStaleReadSet struct {
+	TxID    string
+	Channel string
+	Reads   []StaleRead
+}
+
+func (e *ErrStaleReadSet) Error() string {
+	reads := make([]string, len(e.Reads))
+	for i, r := range e.Reads {
+		reads[i] = fmt.Sprintf("%s:%s (block %d)", r.Namespace, r.Key, r.CommittedBlock)
+	}
+	return fmt.Sprintf("read set of transaction [%s] on channel [%s] is stale, keys changed since endorsement [%s]",
+		e.TxID, e.Channel, strings.Join(reads, ", "))
+}
+
+// StaleReadCheckSkipper is implemented by the transactions that can skip the check of their read set before broadcast
+type StaleReadCheckSkipper interface {
+	// SkipStaleReadCheck tells whether the read set of the transaction is checked before broadcast
+	SkipStaleReadCheck(skip bool)
+	// StaleReadCheckSkipped returns true if the read set of the transaction is not checked before broadcast
+	StaleReadCheckSkipped() bool
+}
+
+// ErrTransactionExpired is returned, instead of broadcasting, for the transactions created longer than their time
+// to live ago. The transaction is abandoned: it is not broadcast again, and the waiters of its finality are released
+// with this error. It matches ErrTransactionAbandoned.
+type ErrTransactionExpired struct {
+	TxID    string
+	Channel string
+	// Created is when the transaction has been created
+	Created time.Time
+	// TTL is the time to live of the transaction
+	TTL time.Duration
+}
+
+func (e *ErrTransactionExpired) Error() string {
+	return fmt.Sprintf("transaction [%s] on channel [%s] created at [%s] is expired, its time to live is [%s]",
+		e.TxID, e.Channel, e.Created.Format(time.RFC3339), e.TTL)
+}
+
+// Is returns true for ErrTransactionAbandoned, the expired transactions are abandoned
+func (e *ErrTransactionExpired) Is(target error) bool {
+	return target == ErrTransactionAbandoned
+}
+
+// ExpirableTransaction is implemented by the transactions that record when they have been created,
+// and that can have a time to live of their own
+type ExpirableTransaction interface {
+	// Created returns when the transaction has been created
+	Created() time.Time
+	// SetTTL sets the time to live of the transaction, 0 to use the one of its chaincode or network
+	SetTTL(ttl time.Duration)
+	// TTL returns the time to live of the transaction, 0 if not set
+	TTL() time.Duration
+}
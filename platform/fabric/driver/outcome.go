@@ -0,0 +1,119 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package driver
+
+import (
+	"fmt"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ValidationOutcome refines the validation code of a transaction with the reason of its validity, or invalidity.
+// The validation code stays the class of the status: all the outcomes but OutcomeValid and OutcomeNone are Invalid.
+type ValidationOutcome int
+
+const (
+	OutcomeNone                     ValidationOutcome = iota // No outcome recorded, the transaction is not final or has been set before the outcomes were recorded
+	OutcomeValid                                             // The transaction is valid
+	OutcomeDiscarded                                         // The transaction has been discarded by this node, the peers did not validate it
+	OutcomeDuplicateTxID                                     // The identifier of the transaction has been used already by a transaction on the ledger
+	OutcomeReadConflict                                      // The read set is stale, MVCC or phantom read conflict
+	OutcomeEndorsementPolicyFailure                          // The endorsements do not satisfy the endorsement policy
+	OutcomeBadSignature                                      // The signature of the creator is not valid
+	OutcomeMalformed                                         // The envelope, the headers or the read-write set are malformed
+	OutcomeChaincodeMismatch                                 // The chaincode is expired, at another version, not valid, or does not allow the writes
+	OutcomeNotValidated                                      // The peers did not validate the transaction
+	OutcomeOther                                             // The peers invalidated the transaction for another reason
+)
+
+var outcomeNames = map[ValidationOutcome]string{
+	OutcomeNone:                     "none",
+	OutcomeValid:                    "valid",
+	OutcomeDiscarded:                "discarded",
+	OutcomeDuplicateTxID:            "duplicate-txid",
+	OutcomeReadConflict:             "read-conflict",
+	OutcomeEndorsementPolicyFailure: "endorsement-policy-failure",
+	OutcomeBadSignature:             "bad-signature",
+	OutcomeMalformed:                "malformed",
+	OutcomeChaincodeMismatch:        "chaincode-mismatch",
+	OutcomeNotValidated:             "not-validated",
+	OutcomeOther:                    "other",
+}
+
+func (o ValidationOutcome) String() string {
+	if name, ok := outcomeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("outcome(%d)", int(o))
+}
+
+// OutcomeOf maps the passed Fabric validation code to the outcome it tells
+func OutcomeOf(code pb.TxValidationCode) ValidationOutcome {
+	switch code {
+	case pb.TxValidationCode_VALID:
+		return OutcomeValid
+	case pb.TxValidationCode_DUPLICATE_TXID:
+		return OutcomeDuplicateTxID
+	case pb.TxValidationCode_MVCC_READ_CONFLICT, pb.TxValidationCode_PHANTOM_READ_CONFLICT:
+		return OutcomeReadConflict
+	case pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE:
+		return OutcomeEndorsementPolicyFailure
+	case pb.TxValidationCode_BAD_CREATOR_SIGNATURE:
+		return OutcomeBadSignature
+	case pb.TxValidationCode_NIL_ENVELOPE, pb.TxValidationCode_BAD_PAYLOAD, pb.TxValidationCode_BAD_COMMON_HEADER,
+		pb.TxValidationCode_INVALID_ENDORSER_TRANSACTION, pb.TxValidationCode_INVALID_CONFIG_TRANSACTION,
+		pb.TxValidationCode_UNSUPPORTED_TX_PAYLOAD, pb.TxValidationCode_BAD_PROPOSAL_TXID,
+		pb.TxValidationCode_UNKNOWN_TX_TYPE, pb.TxValidationCode_TARGET_CHAIN_NOT_FOUND,
+		pb.TxValidationCode_MARSHAL_TX_ERROR, pb.TxValidationCode_NIL_TXACTION, pb.TxValidationCode_BAD_HEADER_EXTENSION,
+		pb.TxValidationCode_BAD_CHANNEL_HEADER, pb.TxValidationCode_BAD_RESPONSE_PAYLOAD, pb.TxValidationCode_BAD_RWSET:
+		return OutcomeMalformed
+	case pb.TxValidationCode_EXPIRED_CHAINCODE, pb.TxValidationCode_CHAINCODE_VERSION_CONFLICT,
+		pb.TxValidationCode_INVALID_CHAINCODE, pb.TxValidationCode_ILLEGAL_WRITESET, pb.TxValidationCode_INVALID_WRITESET:
+		return OutcomeChaincodeMismatch
+	case pb.TxValidationCode_NOT_VALIDATED:
+		return OutcomeNotValidated
+	default:
+		return OutcomeOther
+	}
+}
+
+// ValidationStatus is the status of a transaction together with the outcome of its validation
+type ValidationStatus struct {
+	TxID string
+	Code ValidationCode
+	// Outcome is the reason of the status, OutcomeNone if not known
+	Outcome ValidationOutcome
+	// FabricCode is the validation code the peers assigned to the transaction, it is set only if HasFabricCode is true
+	FabricCode    pb.TxValidationCode
+	HasFabricCode bool
+	// Message describes the outcome, it is empty if there is nothing to add to it
+	Message string
+}
+
+// OutcomeCommitter is implemented by the committers recording the outcome of the validation of the transactions
+type OutcomeCommitter interface {
+	// StatusWithMessage returns the status of the passed transaction, as Status does, together with the outcome of
+	// its validation and the Fabric validation code the peers assigned to it, if known.
+	StatusWithMessage(txid string) (*ValidationStatus, error)
+
+	// DiscardTxWithCode discards the transaction with the passed id, as DiscardTx does, and records the block and
+	// the position it has been invalidated at, and the validation code the peers assigned to it, with the passed message
+	DiscardTxWithCode(txid string, block uint64, txNum int, code pb.TxValidationCode, message string) error
+}
+
+// ErrReplayedTxID is returned, instead of broadcasting, for the transactions whose identifier is already final
+// in the vault, or whose envelope has been ordered already: the peers would invalidate them as duplicates
+type ErrReplayedTxID struct {
+	TxID    string
+	Channel string
+	// Code is the status of the transaction in the vault
+	Code ValidationCode
+}
+
+func (e *ErrReplayedTxID) Error() string {
+	return fmt.Sprintf("transaction [%s] on channel [%s] has been used already, with status [%d], it cannot be broadcast again", e.TxID, e.Channel, e.Code)
+}
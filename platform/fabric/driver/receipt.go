@@ -0,0 +1,71 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package driver
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/canonical"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReceiptVersion is the version of the statements carried by the receipts, in canonical JSON
+	ReceiptVersion = 2
+	// ReceiptVersionJSON is the version of the receipts issued before, whose statement is in plain JSON.
+	// They are still verified.
+	ReceiptVersionJSON = 1
+)
+
+// ErrNotFinal is returned when a receipt is requested for a transaction whose status is not final yet
+var ErrNotFinal = errors.New("transaction not final")
+
+// Receipt is the statement, signed by the identity of an FSC node, that the node observed a transaction committed
+// on a channel with the status in Code, Valid or Invalid. A receipt is never issued for a transaction not final.
+type Receipt struct {
+	Version int    `json:"version"`
+	Network string `json:"network"`
+	Channel string `json:"channel"`
+	TxID    string `json:"txID"`
+	// Code is the status of the transaction in the vault of the node, Valid or Invalid
+	Code ValidationCode `json:"code"`
+	// Block and TxNum locate the transaction in the ledger
+	Block uint64 `json:"block"`
+	TxNum int    `json:"txNum"`
+	// IssuedAt is when the node issued the receipt, in UTC
+	IssuedAt time.Time `json:"issuedAt"`
+	// Issuer is the serialized identity of the node that signed the receipt
+	Issuer []byte `json:"issuer"`
+	// Signature is the signature of the issuer on the statement of the receipt
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Statement returns the bytes signed by the issuer: the receipt without its signature, in canonical JSON,
+// or in plain JSON for the receipts of version ReceiptVersionJSON
+func (r *Receipt) Statement() ([]byte, error) {
+	s := *r
+	s.Signature = nil
+	var raw []byte
+	var err error
+	if r.Version == ReceiptVersionJSON {
+		raw, err = json.Marshal(&s)
+	} else {
+		raw, err = canonical.JSON(&s)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling the statement of the receipt of [%s]", r.TxID)
+	}
+	return raw, nil
+}
+
+// ReceiptIssuer is implemented by the channels able to issue receipts of their transactions
+type ReceiptIssuer interface {
+	// IssueReceipt returns the receipt of the passed transaction, signed by the default identity of the node.
+	// It returns ErrNotFinal if the transaction is neither valid nor invalid.
+	IssueReceipt(txID string) (*Receipt, error)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The sources of the network and of the channel of a transaction
+const (
+	// RoutingExplicit is a network or a channel set explicitly, as by fabric.WithChannel
+	RoutingExplicit = "explicit"
+	// RoutingContext is a network or a channel set by the routing of the context of the view, see view.Routing
+	RoutingContext = "context"
+	// RoutingDefault is the default network, or the default channel of the network
+	RoutingDefault = "default"
+)
+
+// RoutingProvenance records how the network and the channel of a transaction have been chosen
+type RoutingProvenance struct {
+	Network string `json:"network"`
+	Channel string `json:"channel"`
+	// NetworkSource and ChannelSource are RoutingExplicit, RoutingContext, or RoutingDefault
+	NetworkSource string `json:"networkSource"`
+	ChannelSource string `json:"channelSource"`
+}
+
+// ErrUnknownNetwork is returned when a transaction targets a network this node is not part of
+type ErrUnknownNetwork struct {
+	Network string
+	Source  string
+	// Valid are the networks of this node
+	Valid []string
+}
+
+func (e *ErrUnknownNetwork) Error() string {
+	return fmt.Sprintf("unknown network [%s] set by %s routing, valid networks are [%s]", e.Network, e.Source, strings.Join(e.Valid, ", "))
+}
+
+// ErrUnknownChannel is returned when a transaction targets a channel this node does not have
+type ErrUnknownChannel struct {
+	Network string
+	Channel string
+	Source  string
+	// Valid are the channels of the network on this node
+	Valid []string
+}
+
+func (e *ErrUnknownChannel) Error() string {
+	return fmt.Sprintf("unknown channel [%s] of network [%s] set by %s routing, valid channels are [%s]", e.Channel, e.Network, e.Source, strings.Join(e.Valid, ", "))
+}
@@ -60,6 +60,13 @@ type RWSet interface {
 	Equals(rws interface{}, nss ...string) error
 }
 
+// PrivateDataPurger is implemented by the RWSets that can purge private data, see Fabric 2.5
+type PrivateDataPurger interface {
+	// PurgePrivateData purges the passed key of the passed collection of the passed namespace:
+	// the key is deleted, and its private values, past ones included, are removed from the private data stores
+	PurgePrivateData(namespace, collection, key string) error
+}
+
 type RWSetLoader interface {
 	GetRWSetFromEvn(txID string) (RWSet, ProcessTransaction, error)
 	GetRWSetFromETx(txID string) (RWSet, ProcessTransaction, error)
@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package driver
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrReplayWindowPruned is the terminal error of a subscription whose missed events are no longer retained
+var ErrReplayWindowPruned = errors.New("replay window pruned")
+
+// ChaincodeEvent models the chaincode event details.
+type ChaincodeEvent struct {
+	BlockNumber   uint64
+	TxNum         int
+	TransactionID string
+	ChaincodeID   string
+	EventName     string
+	Payload       []byte
+}
+
+func (chaincodeEvent *ChaincodeEvent) Message() interface{} {
+	return chaincodeEvent
+}
+
+func (chaincodeEvent *ChaincodeEvent) Topic() string {
+	return chaincodeEvent.ChaincodeID
+}
+
+// Position returns the position of the event in the ledger
+func (chaincodeEvent *ChaincodeEvent) Position() EventPosition {
+	return EventPosition{Block: chaincodeEvent.BlockNumber, TxNum: chaincodeEvent.TxNum}
+}
+
+// EventPosition is the position of a chaincode event in the ledger: the block and the position of its transaction in the block
+type EventPosition struct {
+	Block uint64
+	TxNum int
+}
+
+// After returns true if this position comes after the passed one
+func (p EventPosition) After(o EventPosition) bool {
+	return p.Block > o.Block || (p.Block == o.Block && p.TxNum > o.TxNum)
+}
+
+// SubscriptionNotificationType tells if a subscription stopped or restarted receiving events
+type SubscriptionNotificationType int
+
+const (
+	// SubscriptionInterrupted is notified when the channel stops delivering events,
+	// the events committed meanwhile are delivered once the subscription is resumed
+	SubscriptionInterrupted SubscriptionNotificationType = iota
+	// SubscriptionResumed is notified when the channel delivers events again, the events missed meanwhile follow
+	SubscriptionResumed
+)
+
+func (t SubscriptionNotificationType) String() string {
+	switch t {
+	case SubscriptionInterrupted:
+		return "interrupted"
+	case SubscriptionResumed:
+		return "resumed"
+	default:
+		return "unknown"
+	}
+}
+
+// SubscriptionNotification notifies the interruption or the resumption of a subscription
+type SubscriptionNotification struct {
+	Type SubscriptionNotificationType
+	// Position is the position of the last event delivered to the subscription
+	Position EventPosition
+	// Reason is the cause of the interruption, nil for a resumption
+	Reason error
+}
+
+// ChaincodeEventSubscription is a subscription to the chaincode events of a chaincode.
+// It survives the restarts of the channel: the events committed while it is interrupted are replayed,
+// in order and without duplicates, when it is resumed.
+type ChaincodeEventSubscription interface {
+	// Events returns the channel the events are delivered on, it is closed when the subscription ends
+	Events() <-chan *ChaincodeEvent
+	// Notifications returns the channel the interruptions and resumptions are notified on, it is closed when the subscription ends.
+	// The notifications are buffered, once the buffer is full the next ones are dropped.
+	Notifications() <-chan *SubscriptionNotification
+	// Position returns the position of the last event delivered
+	Position() EventPosition
+	// Err returns the error that ended the subscription, ErrReplayWindowPruned if the events it missed
+	// are no longer retained, nil if it is still active or has been closed
+	Err() error
+	// Close ends the subscription
+	Close()
+}
+
+// ChaincodeEventSubscriber is implemented by the channels supporting the subscriptions that survive their restarts
+type ChaincodeEventSubscriber interface {
+	// SubscribeChaincodeEvents subscribes to the events of the passed chaincode, committed after the passed position if not nil
+	SubscribeChaincodeEvents(chaincode string, after *EventPosition) (ChaincodeEventSubscription, error)
+}
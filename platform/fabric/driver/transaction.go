@@ -7,6 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package driver
 
 import (
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/bookkeeping"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
 
@@ -55,6 +58,94 @@ type EndorserTransactionService interface {
 	LoadTransaction(txid string) ([]byte, error)
 }
 
+// EndorserProvenance describes an endorsement of a transaction
+type EndorserProvenance struct {
+	// Endpoint is the address of the endorsing peer, empty if the endorsement has been collected by other means,
+	// as by the FSC nodes of the endorser service
+	Endpoint string `json:"endpoint,omitempty"`
+	// Endorser is the serialized identity that signed the endorsement
+	Endorser []byte `json:"endorser"`
+	// ResponseDigest is the SHA-256 digest of the payload of the proposal response
+	ResponseDigest []byte `json:"responseDigest"`
+	// Latency is the time the peer took to answer the proposal, zero if not known
+	Latency time.Duration `json:"latency,omitempty"`
+	// Timestamp is when the endorsement has been received
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BroadcastProvenance describes the broadcast of a transaction to the ordering service
+type BroadcastProvenance struct {
+	// Orderer is the address of the orderer that acknowledged the broadcast
+	Orderer string `json:"orderer"`
+	// SentAt is when the broadcast started, AcknowledgedAt is when the orderer acknowledged it
+	SentAt         time.Time `json:"sentAt"`
+	AcknowledgedAt time.Time `json:"acknowledgedAt"`
+	// ConfigSequence is the sequence of the channel config in force at the time
+	ConfigSequence uint64 `json:"configSequence"`
+}
+
+// Provenance is the routing of a transaction: the peers that endorsed it and the orderer that received its broadcast
+type Provenance struct {
+	TxID    string `json:"txid"`
+	Channel string `json:"channel"`
+	// Endorsers are the endorsements of the last endorsement round of the transaction
+	Endorsers []EndorserProvenance `json:"endorsers,omitempty"`
+	// EndorsementConfigSequence is the sequence of the channel config in force when the endorsements have been collected
+	EndorsementConfigSequence uint64 `json:"endorsementConfigSequence"`
+	// Broadcast is nil if the transaction has not been broadcast by this node
+	Broadcast *BroadcastProvenance `json:"broadcast,omitempty"`
+	// Routing tells how the network and the channel have been chosen, nil if the transaction has not been created by this node
+	Routing *RoutingProvenance `json:"routing,omitempty"`
+	// Commit is nil if the transaction has not been committed yet
+	Commit *CommitProvenance `json:"commit,omitempty"`
+}
+
+// CommitProvenance describes the commit of a transaction in a block of the ledger
+type CommitProvenance struct {
+	Block        uint64 `json:"block"`
+	IndexInBlock int    `json:"indexInBlock"`
+	// ValidationCode is the validation code of the transaction set by the committing peers
+	ValidationCode int32 `json:"validationCode"`
+	// ConfigSequence is the sequence of the channel config in force at the time
+	ConfigSequence uint64 `json:"configSequence"`
+}
+
+// ProvenanceService stores the provenance of the transactions of a channel, the records are kept whatever the outcome
+// of the validation of the transactions
+type ProvenanceService interface {
+	// RecordEndorsements records the endorsements of the passed transaction, merged with those recorded before:
+	// an endorsement recorded already keeps its first record
+	RecordEndorsements(txID string, endorsers []EndorserProvenance) error
+	// RecordBroadcast records the orderer that acknowledged the broadcast of the passed transaction
+	RecordBroadcast(txID string, orderer string, sentAt time.Time, acknowledgedAt time.Time) error
+	// RecordRouting records how the network and the channel of the passed transaction have been chosen
+	RecordRouting(txID string, routing RoutingProvenance) error
+	// RecordCommit records the commit of the passed transaction, if a provenance has been recorded for it before
+	RecordCommit(txID string, block uint64, indexInBlock int, validationCode int32) error
+	// GetTransactionProvenance returns the provenance recorded for the passed transaction
+	GetTransactionProvenance(txID string) (*Provenance, error)
+}
+
+// ProvenanceProvider is implemented by the channels recording the provenance of their transactions
+type ProvenanceProvider interface {
+	ProvenanceService() ProvenanceService
+}
+
+// BookkeepingStores are the bookkeeping stores of a channel writing to a unit of work, see bookkeeping.Begin
+type BookkeepingStores struct {
+	Envelopes    EnvelopeService
+	Transactions EndorserTransactionService
+	Metadata     MetadataService
+	// Provenance is nil if the channel does not record the provenance of its transactions
+	Provenance ProvenanceService
+}
+
+// BookkeepingProvider is implemented by the channels whose bookkeeping stores can enlist their writes in a unit of work
+type BookkeepingProvider interface {
+	// Enlist returns the bookkeeping stores of the channel writing to the passed unit of work
+	Enlist(uow bookkeeping.Store) *BookkeepingStores
+}
+
 type TransactionManager interface {
 	ComputeTxID(id *TxID) string
 	NewEnvelope() Envelope
@@ -116,6 +207,8 @@ type Transaction interface {
 	ProposalResponse() ([]byte, error)
 	BytesNoTransient() ([]byte, error)
 	Envelope() (Envelope, error)
+	// EstimatedSize returns the estimated size in bytes of the envelope of the transaction
+	EstimatedSize() (int, error)
 }
 
 type SignedProposal interface {
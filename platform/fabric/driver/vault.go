@@ -6,6 +6,14 @@ SPDX-License-Identifier: Apache-2.0
 
 package driver
 
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 // Vault models a key value store that can be updated by committing rwsets
 type Vault interface {
 	// NewQueryExecutor gives handle to a query executor.
@@ -29,3 +37,341 @@ type Vault interface {
 	// If namespaces is not empty, the returned RWSet will be filtered by the passed namespaces
 	GetEphemeralRWSet(rwset []byte, namespaces ...string) (RWSet, error)
 }
+
+// LocalCommitter is implemented by the channels whose vault can commit transactions that are not ordered
+type LocalCommitter interface {
+	// CommitLocalTX commits the read-write set of the passed local transaction.
+	// The transaction does not become the last one committed, the delivery does not restart from it.
+	CommitLocalTX(txid string) error
+}
+
+// BackupCoordinator is implemented by the channels whose vault can be safely backed up while the node is running
+type BackupCoordinator interface {
+	// PauseCommits waits for the in-flight block commits to complete and blocks new ones.
+	// The commits stay paused until the returned resume function is called or a max pause duration expires.
+	PauseCommits(ctx context.Context) (resume func(), err error)
+
+	// AddSnapshotHook registers a hook invoked, with the vault height, every time commits get paused.
+	// While the hook runs, it is safe to snapshot the vault.
+	AddSnapshotHook(hook func(height uint64) error)
+}
+
+// DefaultStatusPageSize is the number of statuses listed per page when the filter does not set a limit
+const DefaultStatusPageSize = 100
+
+// StatusFilter selects the transactions listed by StatusLister#ListStatuses. Zero values do not filter.
+type StatusFilter struct {
+	// Codes are the validation codes of the transactions to list
+	Codes []ValidationCode
+	// FromBlock and ToBlock bound, inclusively, the block the transactions have been committed in.
+	// If any is set, the transactions whose height is not known are not listed.
+	FromBlock *uint64
+	ToBlock   *uint64
+	// Since and Until bound, inclusively, the time the status of the transactions has been set.
+	// If any is set, the transactions whose status has been set before the time was recorded are not listed.
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of statuses per page. DefaultStatusPageSize is used if not positive.
+	Limit int
+}
+
+// PageToken identifies a page of a listing. The empty token identifies the first page.
+// A token stays valid while new transactions are committed.
+type PageToken string
+
+// TxStatus is the status of a transaction in the vault
+type TxStatus struct {
+	TxID string
+	Code ValidationCode
+	// Block and TxNum locate the transaction in the ledger, they are UnknownBlock and UnknownTxNum if not known
+	Block uint64
+	TxNum int
+	// Timestamp is when the status has been set, zero if not known
+	Timestamp time.Time
+}
+
+// StatusPage is a page of a listing of transaction statuses, in the order they have been set, or by height if the
+// filter bounds the blocks
+type StatusPage struct {
+	Statuses []TxStatus
+	// Next is the token of the next page, empty if this is the last page
+	Next PageToken
+}
+
+// StatusLister is implemented by the channels whose vault can list the statuses of its transactions
+type StatusLister interface {
+	// ListStatuses returns the page, identified by the passed token, of the statuses of the transactions matching the filter
+	ListStatuses(filter StatusFilter, page PageToken) (*StatusPage, error)
+}
+
+// StateRepair re-applies to the vault the authoritative state of a key, as read from a peer
+type StateRepair struct {
+	Namespace string
+	Key       string
+	// Value is the authoritative value, the key is deleted if empty
+	Value []byte
+	// Block and TxNum are the version the value is stored with
+	Block uint64
+	TxNum uint64
+	// LocalBlock and LocalTxNum are the version of the key in the vault when the drift has been detected.
+	// If the key has been committed again since then, the repair is skipped: the new commit prevails.
+	LocalBlock uint64
+	LocalTxNum uint64
+}
+
+// RepairProvenance records where the values of a repair come from
+type RepairProvenance struct {
+	// Source identifies where the authoritative values have been read from, for instance the peer and the chaincode
+	Source string
+	// Reference identifies the reconciliation the repair is part of
+	Reference string
+	// Timestamp is when the repair has been applied
+	Timestamp time.Time
+}
+
+// RepairRecord is the provenance of the last repair of a key, together with the state the key had before it
+type RepairRecord struct {
+	RepairProvenance
+	Namespace string
+	Key       string
+	// Previous, PreviousBlock, and PreviousTxNum are the state of the key before the repair, Previous is empty if missing
+	Previous      []byte
+	PreviousBlock uint64
+	PreviousTxNum uint64
+}
+
+// StateRepairer is implemented by the channels whose vault can be repaired with the authoritative state read from a peer
+type StateRepairer interface {
+	// RepairStates applies the passed repairs atomically and records their provenance.
+	// It returns the repairs applied, those of the keys committed again since the drift has been detected are skipped.
+	RepairStates(repairs []StateRepair, provenance RepairProvenance) ([]StateRepair, error)
+
+	// RepairRecord returns the provenance of the last repair of the passed key, nil if the key has never been repaired
+	RepairRecord(namespace, key string) (*RepairRecord, error)
+}
+
+// StateWrite is the write of a key by a committed transaction
+type StateWrite struct {
+	Namespace string
+	Key       string
+	// Value is the value written, nil if the key is deleted
+	Value []byte
+	// Block and TxNum are the height of the transaction
+	Block uint64
+	TxNum uint64
+}
+
+// StateWriteListener is invoked with the writes of a valid transaction, once committed, in commit order
+type StateWriteListener func(txID string, writes []StateWrite)
+
+// StateWriteNotifier is implemented by the channels notifying the writes of their committed transactions
+type StateWriteNotifier interface {
+	// AddStateWriteListener registers the passed listener, invoked synchronously from the commit pipeline
+	AddStateWriteListener(listener StateWriteListener)
+}
+
+// StateReplicator is implemented by the channels whose vault can hold the states replicated from another node
+type StateReplicator interface {
+	// ReplicateStates applies the passed writes atomically, with their version.
+	// The keys of the reset namespaces not written are deleted, the namespaces then hold the passed writes only.
+	ReplicateStates(writes []StateWrite, reset ...string) error
+}
+
+var (
+	// ErrHistoryNotKept is returned when reading at a past height a namespace whose history is not kept
+	ErrHistoryNotKept = errors.New("history not kept")
+	// ErrHeightNotCommitted is returned when reading at a height not committed yet
+	ErrHeightNotCommitted = errors.New("height not committed")
+	// ErrHeightBeforeHorizon is returned when reading at a height whose versions are no longer retained
+	ErrHeightBeforeHorizon = errors.New("height before the retention horizon")
+)
+
+// HistoryReader is implemented by the channels whose vault keeps the history of the versions of the keys
+// of selected namespaces
+type HistoryReader interface {
+	// NewQueryExecutorAt returns a query executor reading the vault as it was once the passed block has been committed
+	NewQueryExecutorAt(height uint64) (QueryExecutor, error)
+	// PruneHistory drops the versions of the passed namespace not needed to read it at the passed height or after
+	PruneHistory(namespace string, horizon uint64) error
+}
+
+// SpeculativeMetadataKey is set in the metadata of the states a query executor with pending transactions reads
+// from the write set of a transaction not committed yet. Its value is the id of the transaction.
+const SpeculativeMetadataKey = "fsc.speculative"
+
+// PendingReader is implemented by the channels whose vault can overlay the writes of the local transactions
+// not committed yet on the committed state, so that a view reads its own writes
+type PendingReader interface {
+	// NewQueryExecutorWithPending returns a query executor overlaying the writes of the passed pending transactions
+	NewQueryExecutorWithPending(txIDs ...string) (QueryExecutor, error)
+}
+
+// NamespaceQuota bounds the approximate storage, in bytes, taken by the states of a namespace of the vault.
+// A zero bound is unbounded.
+type NamespaceQuota struct {
+	// Soft is the usage above which the commits log a warning
+	Soft uint64 `json:"soft,omitempty" yaml:"soft,omitempty"`
+	// Hard is the usage above which the read-write sets created locally can no longer write the namespace.
+	// The writes delivered by the blocks are applied anyway.
+	Hard uint64 `json:"hard,omitempty" yaml:"hard,omitempty"`
+}
+
+// NamespaceUsage is the approximate storage, in bytes, taken by the states of a namespace of the vault:
+// the sum of the sizes of the keys and of the values
+type NamespaceUsage struct {
+	Namespace string         `json:"namespace"`
+	Bytes     uint64         `json:"bytes"`
+	Quota     NamespaceQuota `json:"quota"`
+	// Measuring tells that the namespace is being measured, Bytes is the usage of the keys measured so far
+	Measuring bool `json:"measuring,omitempty"`
+}
+
+// ErrNamespaceQuotaExceeded is returned when a read-write set created locally writes a namespace
+// whose usage has reached its hard quota
+type ErrNamespaceQuotaExceeded struct {
+	Namespace string
+	// Usage is the usage of the namespace, in bytes
+	Usage uint64
+	// Quota is the hard quota of the namespace, in bytes
+	Quota uint64
+}
+
+func (e *ErrNamespaceQuotaExceeded) Error() string {
+	return fmt.Sprintf("namespace [%s] uses [%d] bytes, its quota is [%d] bytes", e.Namespace, e.Usage, e.Quota)
+}
+
+// NamespaceQuotaManager is implemented by the channels whose vault accounts the storage taken by each namespace
+type NamespaceQuotaManager interface {
+	// NamespaceUsage returns the usage of the namespaces written, or with a quota, sorted by namespace
+	NamespaceUsage() ([]NamespaceUsage, error)
+	// SetNamespaceQuota sets the quota of the passed namespace, a zero quota removes it
+	SetNamespaceQuota(namespace string, quota NamespaceQuota) error
+}
+
+// IntegrityStatus is the outcome of the integrity check of a namespace of the vault
+type IntegrityStatus string
+
+const (
+	// IntegrityOK namespaces hold the states their checksum has been kept for
+	IntegrityOK IntegrityStatus = "ok"
+	// IntegrityCorrupted namespaces hold states that differ from those their checksum has been kept for
+	IntegrityCorrupted IntegrityStatus = "corrupted"
+	// IntegrityUnchecked namespaces have no checksum, they have not been written since the checksums are kept
+	IntegrityUnchecked IntegrityStatus = "unchecked"
+)
+
+// NamespaceIntegrity is the integrity check of a namespace of the vault: the checksum kept by the commits,
+// anchored to the height of the last write, against the one of the states found in the namespace
+type NamespaceIntegrity struct {
+	Namespace string          `json:"namespace"`
+	Status    IntegrityStatus `json:"status"`
+	// Expected and Keys are the checksum, hex encoded, and the number of keys kept by the commits
+	Expected string `json:"expected,omitempty"`
+	Keys     uint64 `json:"keys"`
+	// Actual and ActualKeys are the checksum, hex encoded, and the number of keys of the states found in the namespace
+	Actual     string `json:"actual,omitempty"`
+	ActualKeys uint64 `json:"actualKeys"`
+	// Block and TxNum are the height of the last write accounted in the checksum
+	Block uint64 `json:"block"`
+	TxNum uint64 `json:"txNum"`
+}
+
+// IntegrityReport is the integrity check of the namespaces of the vault
+type IntegrityReport struct {
+	Verified   time.Time            `json:"verified"`
+	Namespaces []NamespaceIntegrity `json:"namespaces"`
+	// Failed are the namespaces corrupted, sorted
+	Failed []string `json:"failed,omitempty"`
+}
+
+// IntegrityVerifier is implemented by the channels whose vault keeps a checksum of each namespace, updated by every commit
+type IntegrityVerifier interface {
+	// VerifyIntegrity verifies the passed namespaces, or all those with a checksum if none is passed, against their checksum
+	VerifyIntegrity(namespaces ...string) (*IntegrityReport, error)
+	// RederiveNamespace re-derives the states of the passed namespace, and its checksum, from the ledger of the peers
+	RederiveNamespace(namespace string) (*NamespaceIntegrity, error)
+}
+
+// DefaultWatchBuffer is the number of changes a watch buffers, if not set, before it overflows
+const DefaultWatchBuffer = 1024
+
+// KeyChange is a change of a key of the vault, committed by a valid transaction
+type KeyChange struct {
+	Namespace string
+	Key       string
+	// Value is the new value of the key, nil if the key has been deleted
+	Value []byte
+	// Deleted tells that the key has been deleted: the change is a tombstone
+	Deleted bool
+	Block   uint64
+	TxNum   uint64
+	// TxID is the id of the transaction, empty for the changes replayed
+	TxID string
+	// Replayed tells that the change was committed before the watch was registered
+	Replayed bool
+	// Overflow tells that the consumer did not keep up and changes have been dropped: it is the last change sent,
+	// the watch is closed right after it
+	Overflow bool
+}
+
+// WatchOptions tunes a watch of the keys of the vault
+type WatchOptions struct {
+	// Replay asks to send first the changes committed from the block From onwards
+	Replay bool
+	From   uint64
+	// Buffer is the number of changes buffered for the consumer, DefaultWatchBuffer if not positive.
+	// The changes replayed do not count towards it.
+	Buffer int
+}
+
+// KeyWatcher is implemented by the channels whose vault notifies the changes of its keys as they are committed
+type KeyWatcher interface {
+	// WatchKeys returns a channel of the changes of the keys of the passed namespace with the passed prefix,
+	// and a function to cancel the watch, that closes the channel.
+	// The changes of a block are sent once the block is fully committed, in the order they were committed.
+	WatchKeys(namespace, prefix string, opts WatchOptions) (<-chan KeyChange, func(), error)
+}
+
+// ProjectedWrite is a write of the source namespace of a projection, in the update of the vault committing it
+type ProjectedWrite struct {
+	Key string
+	// Value is the new value of the key, nil if the key is deleted
+	Value []byte
+	// Previous is the value the key had before the write, nil if none
+	Previous []byte
+	// Block and TxNum are the height of the write
+	Block uint64
+	TxNum uint64
+}
+
+// IndexWrite is a write a projection derives into its index namespace, an empty value deletes the key
+type IndexWrite struct {
+	Key   string
+	Value []byte
+}
+
+// IndexReader reads the index namespace of a projection, including the writes derived so far in the same update
+type IndexReader interface {
+	GetState(key string) ([]byte, error)
+}
+
+// Projection derives, at commit time, the states of an index namespace from the writes of a source namespace,
+// so that the index is committed in the same update of the vault as the source, and it is never behind it.
+type Projection interface {
+	// Version identifies the logic of the projection: once it changes, the index namespace is rebuilt
+	Version() string
+	// IndexNamespace is the namespace the projection writes, no other projection, nor transaction, writes it
+	IndexNamespace() string
+	// Project returns the index writes derived from the passed write of the source namespace.
+	// It must be deterministic: it depends on the write and on the index only. An error fails the commit.
+	Project(write ProjectedWrite, index IndexReader) ([]IndexWrite, error)
+}
+
+// ProjectionManager is implemented by the channels whose vault maintains the index namespaces of projections
+type ProjectionManager interface {
+	// RegisterProjection registers the passed projection of the passed source namespace. The index namespace is
+	// rebuilt if it has been built by another version of the projection, or never built.
+	RegisterProjection(namespace string, projection Projection) error
+	// RebuildProjection rebuilds the passed index namespace by replaying the states of its source namespace
+	RebuildProjection(index string) error
+}
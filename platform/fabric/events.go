@@ -7,21 +7,54 @@ SPDX-License-Identifier: Apache-2.0
 package fabric
 
 import (
+	"sync"
+
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	"github.com/pkg/errors"
+)
+
+type (
+	EventPosition              = driver.EventPosition
+	ChaincodeEventSubscription = driver.ChaincodeEventSubscription
+	SubscriptionNotification   = driver.SubscriptionNotification
 )
 
+const (
+	// SubscriptionInterrupted is notified when the channel stops delivering the chaincode events
+	SubscriptionInterrupted = driver.SubscriptionInterrupted
+	// SubscriptionResumed is notified when the channel delivers the chaincode events again
+	SubscriptionResumed = driver.SubscriptionResumed
+)
+
+// ErrReplayWindowPruned ends the subscriptions whose missed events are no longer retained
+var ErrReplayWindowPruned = driver.ErrReplayWindowPruned
+
 // EventListener models the parameters to use for chaincode listening.
 type EventListener struct {
+	sp            view.ServiceProvider
+	ch            driver.Channel
+	chaincodeName string
+
+	// subscription serializes the subscription and its closing
+	subscription sync.Mutex
+	subscribed   bool
+	// mutex guards the listeners. The events are sent holding it for reading, until done is closed
+	mutex             sync.RWMutex
+	done              chan struct{}
 	chaincodeListener chan *committer.ChaincodeEvent
-	sp                view.ServiceProvider
-	chaincodeName     string
+	// typed listening, see TypedChaincodeEvents
+	types         *EventTypeRegistry
+	typedListener chan *TypedEvent
+	errorListener chan *EventDecodingError
 }
 
-func newEventListener(sp view.ServiceProvider, chaincodeName string) *EventListener {
+func newEventListener(sp view.ServiceProvider, ch driver.Channel, chaincodeName string) *EventListener {
 	return &EventListener{
 		sp:            sp,
+		ch:            ch,
 		chaincodeName: chaincodeName,
 	}
 }
@@ -32,24 +65,122 @@ func (e *EventListener) ChaincodeEvents() (chan *committer.ChaincodeEvent, error
 	if err != nil {
 		return nil, err
 	}
+	e.subscription.Lock()
+	defer e.subscription.Unlock()
+	e.mutex.Lock()
 	e.chaincodeListener = make(chan *committer.ChaincodeEvent, 1)
+	listener := e.chaincodeListener
+	e.mutex.Unlock()
+	e.subscribe(subscriber)
+	return listener, nil
+}
+
+// TypedChaincodeEvents returns a channel from which the chaincode events emitted by transaction functions
+// in the specified chaincode can be read with their payloads decoded to the types in the EventTypeRegistry.
+// The events that cannot be decoded are delivered, with their raw payload, on the returned error channel.
+func (e *EventListener) TypedChaincodeEvents() (<-chan *TypedEvent, <-chan *EventDecodingError, error) {
+	types, err := GetEventTypeRegistry(e.sp)
+	if err != nil {
+		return nil, nil, err
+	}
+	subscriber, err := events.GetSubscriber(e.sp)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.subscription.Lock()
+	defer e.subscription.Unlock()
+	e.mutex.Lock()
+	e.types = types
+	e.typedListener = make(chan *TypedEvent, 1)
+	e.errorListener = make(chan *EventDecodingError, 1)
+	typedListener, errorListener := e.typedListener, e.errorListener
+	e.mutex.Unlock()
+	e.subscribe(subscriber)
+	return typedListener, errorListener, nil
+}
+
+// subscribe subscribes the listener, once for all the channels it delivers the events on.
+// e.subscription must be held.
+func (e *EventListener) subscribe(subscriber events.Subscriber) {
+	if e.subscribed {
+		return
+	}
+	e.mutex.Lock()
+	e.done = make(chan struct{})
+	e.mutex.Unlock()
+	e.subscribed = true
 	subscriber.Subscribe(e.chaincodeName, e)
-	return e.chaincodeListener, nil
 }
 
-// CloseChaincodeEvents closes the channel from which chaincode events are read.
+// SubscribeChaincodeEvents subscribes to the chaincode events emitted by transaction functions in the specified chaincode,
+// committed after the passed position if not nil. Unlike ChaincodeEvents, the subscription survives the restarts of
+// the channel: it is notified of its interruptions and resumptions, and the events missed meanwhile are replayed.
+// The subscription ends with ErrReplayWindowPruned if the events it missed are no longer retained.
+func (e *EventListener) SubscribeChaincodeEvents(after *EventPosition) (ChaincodeEventSubscription, error) {
+	s, ok := e.ch.(driver.ChaincodeEventSubscriber)
+	if !ok {
+		return nil, errors.Errorf("channel [%s] does not support resumable chaincode event subscriptions", e.ch.Name())
+	}
+	return s.SubscribeChaincodeEvents(e.chaincodeName, after)
+}
+
+// CloseChaincodeEvents closes the channels from which chaincode events are read.
+// The events being delivered while closing, to readers not reading anymore, are dropped.
 func (e *EventListener) CloseChaincodeEvents() error {
-	close(e.chaincodeListener)
 	subscriber, err := events.GetSubscriber(e.sp)
 	if err != nil {
 		return err
 	}
+	e.subscription.Lock()
+	defer e.subscription.Unlock()
+	if !e.subscribed {
+		return nil
+	}
 	subscriber.Unsubscribe(e.chaincodeName, e)
+	e.subscribed = false
+	// release the deliveries in progress, then close the channels once none is sending
+	close(e.done)
 
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.chaincodeListener != nil {
+		close(e.chaincodeListener)
+		e.chaincodeListener = nil
+	}
+	if e.typedListener != nil {
+		close(e.typedListener)
+		close(e.errorListener)
+		e.typedListener, e.errorListener = nil, nil
+	}
 	return nil
 }
 
 func (e *EventListener) OnReceive(event events.Event) {
 	//todo filter events based on options passed - start block, last transactionid
-	e.chaincodeListener <- event.Message().(*committer.ChaincodeEvent)
+	ccEvent := event.Message().(*committer.ChaincodeEvent)
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.chaincodeListener != nil {
+		select {
+		case e.chaincodeListener <- ccEvent:
+		case <-e.done:
+			return
+		}
+	}
+	if e.typedListener != nil {
+		typed, err := e.types.Decode(ccEvent)
+		if err != nil {
+			logger.Warnf("failed decoding chaincode event: [%s]", err)
+			select {
+			case e.errorListener <- err.(*EventDecodingError):
+			case <-e.done:
+			}
+			return
+		}
+		select {
+		case e.typedListener <- typed:
+		case <-e.done:
+		}
+	}
 }
@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventSystem counts the subscriptions, and publishes synchronously
+type eventSystem struct {
+	lock          sync.Mutex
+	subscriptions int
+	listeners     []events.Listener
+}
+
+func (s *eventSystem) Subscribe(topic string, receiver events.Listener) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.subscriptions++
+	s.listeners = append(s.listeners, receiver)
+}
+
+func (s *eventSystem) Unsubscribe(topic string, receiver events.Listener) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = nil
+}
+
+func (s *eventSystem) Publish(event events.Event) {
+	s.lock.Lock()
+	listeners := s.listeners
+	s.lock.Unlock()
+	for _, l := range listeners {
+		l.OnReceive(event)
+	}
+}
+
+type chaincodeEvent struct {
+	event *committer.ChaincodeEvent
+}
+
+func (e *chaincodeEvent) Topic() string        { return e.event.ChaincodeID }
+func (e *chaincodeEvent) Message() interface{} { return e.event }
+
+func TestEventListener(t *testing.T) {
+	es := &eventSystem{}
+	sp := registry.New()
+	assert.NoError(t, sp.RegisterService(&events.Service{EventSystem: es}))
+	types := NewEventTypeRegistry(&disabled.Provider{})
+	assert.NoError(t, sp.RegisterService(types))
+	l := newEventListener(sp, nil, "asset")
+
+	// the listener is subscribed once, whatever the channels it delivers the events on
+	raw, err := l.ChaincodeEvents()
+	assert.NoError(t, err)
+	typed, decodingErrors, err := l.TypedChaincodeEvents()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, es.subscriptions)
+	event := &committer.ChaincodeEvent{ChaincodeID: "asset", EventName: "AssetCreated", Payload: []byte(`{}`)}
+	es.Publish(&chaincodeEvent{event: event})
+	assert.Equal(t, event, <-raw)
+	assert.Equal(t, event.Payload, (<-decodingErrors).Event.Payload)
+	assert.Len(t, raw, 0)
+	assert.Len(t, typed, 0)
+
+	// closing releases the deliveries nobody reads anymore
+	es.Publish(&chaincodeEvent{event: event})
+	delivered := make(chan struct{})
+	go func() {
+		es.Publish(&chaincodeEvent{event: event})
+		close(delivered)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, l.CloseChaincodeEvents())
+	<-delivered
+	_, ok := <-raw
+	assert.True(t, ok)
+	_, ok = <-raw
+	assert.False(t, ok)
+	assert.NoError(t, l.CloseChaincodeEvents())
+}
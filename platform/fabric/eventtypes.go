@@ -0,0 +1,146 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/pkg/errors"
+)
+
+var (
+	eventTypeRegistryType = reflect.TypeOf((*EventTypeRegistry)(nil))
+
+	eventDecodingFailuresOpts = metrics.CounterOpts{
+		Namespace:    "fabric",
+		Subsystem:    "chaincode_events",
+		Name:         "decoding_failures",
+		Help:         "The number of chaincode events whose payload does not decode to the type registered for their name.",
+		LabelNames:   []string{"chaincode", "event", "reason"},
+		StatsdFormat: "%{#fqname}.%{chaincode}.%{event}.%{reason}",
+	}
+)
+
+const (
+	// UnregisteredEventType is the reason of the failures of the events whose name has no registered type
+	UnregisteredEventType = "unregistered"
+	// EventSchemaMismatch is the reason of the failures of the events whose payload does not match the registered type
+	EventSchemaMismatch = "mismatch"
+)
+
+// TypedEvent is a chaincode event whose payload has been decoded
+type TypedEvent struct {
+	BlockNumber   uint64
+	TransactionID string
+	ChaincodeID   string
+	EventName     string
+	// Payload is the value, of the type registered for EventName, the JSON payload of the event decodes to
+	Payload interface{}
+}
+
+// EventDecodingError reports a chaincode event whose payload could not be decoded
+type EventDecodingError struct {
+	// Event is the event as received, with its raw payload
+	Event *committer.ChaincodeEvent
+	// Reason is either UnregisteredEventType or EventSchemaMismatch
+	Reason string
+	Err    error
+}
+
+func (e *EventDecodingError) Error() string {
+	return fmt.Sprintf("failed decoding event [%s] of chaincode [%s] in tx [%s]: %s", e.Event.EventName, e.Event.ChaincodeID, e.Event.TransactionID, e.Err)
+}
+
+func (e *EventDecodingError) Unwrap() error {
+	return e.Err
+}
+
+// EventTypeRegistry maps the names of chaincode events to the Go types their JSON payloads decode to.
+// Payloads carrying fields the registered type does not have are rejected, so that a drift in the schema is noticed.
+type EventTypeRegistry struct {
+	mutex    sync.RWMutex
+	types    map[string]reflect.Type
+	failures metrics.Counter
+}
+
+// NewEventTypeRegistry returns a new empty EventTypeRegistry whose decoding failures are counted with the passed provider
+func NewEventTypeRegistry(p metrics.Provider) *EventTypeRegistry {
+	return &EventTypeRegistry{
+		types:    map[string]reflect.Type{},
+		failures: p.NewCounter(eventDecodingFailuresOpts),
+	}
+}
+
+// RegisterEventType registers the type the payloads of the events with the passed name decode to.
+//
+//	registry.RegisterEventType("TransferCompleted", reflect.TypeOf(TransferCompleted{}))
+func (r *EventTypeRegistry) RegisterEventType(name string, t reflect.Type) error {
+	if len(name) == 0 {
+		return errors.New("event name cannot be empty")
+	}
+	if t == nil {
+		return errors.Errorf("no type passed for event [%s]", name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if registered, ok := r.types[name]; ok && registered != t {
+		return errors.Errorf("event [%s] already registered with type [%s]", name, registered)
+	}
+	r.types[name] = t
+	return nil
+}
+
+// Decode decodes the payload of the passed event to the type registered for its name.
+// The returned error, if any, is an EventDecodingError.
+func (r *EventTypeRegistry) Decode(event *committer.ChaincodeEvent) (*TypedEvent, error) {
+	r.mutex.RLock()
+	t, ok := r.types[event.EventName]
+	r.mutex.RUnlock()
+	if !ok {
+		return nil, r.fail(event, UnregisteredEventType, errors.Errorf("no type registered"))
+	}
+
+	v := reflect.New(t)
+	decoder := json.NewDecoder(bytes.NewReader(event.Payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v.Interface()); err != nil {
+		return nil, r.fail(event, EventSchemaMismatch, errors.Wrapf(err, "payload does not match type [%s]", t))
+	}
+	if decoder.More() {
+		return nil, r.fail(event, EventSchemaMismatch, errors.Errorf("payload has trailing data"))
+	}
+
+	return &TypedEvent{
+		BlockNumber:   event.BlockNumber,
+		TransactionID: event.TransactionID,
+		ChaincodeID:   event.ChaincodeID,
+		EventName:     event.EventName,
+		Payload:       v.Elem().Interface(),
+	}, nil
+}
+
+func (r *EventTypeRegistry) fail(event *committer.ChaincodeEvent, reason string, err error) error {
+	r.failures.With("chaincode", event.ChaincodeID, "event", event.EventName, "reason", reason).Add(1)
+	return &EventDecodingError{Event: event, Reason: reason, Err: err}
+}
+
+// GetEventTypeRegistry returns the registry of the chaincode event types registered in the passed service provider
+func GetEventTypeRegistry(sp view2.ServiceProvider) (*EventTypeRegistry, error) {
+	s, err := sp.GetService(eventTypeRegistryType)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get event type registry")
+	}
+	return s.(*EventTypeRegistry), nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/committer"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+type TransferCompleted struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+func TestEventTypeRegistry(t *testing.T) {
+	failures := &metricsfakes.Counter{}
+	failures.WithReturns(failures)
+	provider := &metricsfakes.Provider{}
+	provider.NewCounterReturns(failures)
+	r := NewEventTypeRegistry(provider)
+
+	assert.NoError(t, r.RegisterEventType("TransferCompleted", reflect.TypeOf(TransferCompleted{})))
+	assert.NoError(t, r.RegisterEventType("TransferCompleted", reflect.TypeOf(TransferCompleted{})))
+	assert.EqualError(t, r.RegisterEventType("TransferCompleted", reflect.TypeOf("")), "event [TransferCompleted] already registered with type [fabric.TransferCompleted]")
+	assert.Error(t, r.RegisterEventType("", reflect.TypeOf("")))
+
+	event := func(name, payload string) *committer.ChaincodeEvent {
+		return &committer.ChaincodeEvent{BlockNumber: 3, TransactionID: "tx1", ChaincodeID: "asset", EventName: name, Payload: []byte(payload)}
+	}
+
+	typed, err := r.Decode(event("TransferCompleted", `{"from":"alice","to":"bob","amount":10}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &TypedEvent{
+		BlockNumber:   3,
+		TransactionID: "tx1",
+		ChaincodeID:   "asset",
+		EventName:     "TransferCompleted",
+		Payload:       TransferCompleted{From: "alice", To: "bob", Amount: 10},
+	}, typed)
+	assert.Equal(t, 0, failures.AddCallCount())
+
+	check := func(ev *committer.ChaincodeEvent, reason string) {
+		_, err := r.Decode(ev)
+		derr, ok := err.(*EventDecodingError)
+		assert.True(t, ok, "expected a decoding error, got [%v]", err)
+		assert.Equal(t, reason, derr.Reason)
+		assert.Equal(t, ev.Payload, derr.Event.Payload)
+		calls := failures.WithCallCount()
+		assert.Equal(t, []string{"chaincode", "asset", "event", ev.EventName, "reason", reason}, failures.WithArgsForCall(calls-1))
+	}
+	// the schema has drifted
+	check(event("TransferCompleted", `{"from":"alice","to":"bob","amount":10,"memo":"rent"}`), EventSchemaMismatch)
+	check(event("TransferCompleted", `{"from":"alice","to":"bob","amount":"ten"}`), EventSchemaMismatch)
+	check(event("TransferCompleted", `{"from":"alice"} {}`), EventSchemaMismatch)
+	check(event("TransferCompleted", `not json`), EventSchemaMismatch)
+	check(event("AssetCreated", `{}`), UnregisteredEventType)
+	assert.Equal(t, 5, failures.AddCallCount())
+}
@@ -53,6 +53,30 @@ func (n *NetworkService) Peers() []*grpc.ConnectionConfig {
 	return n.fns.Peers()
 }
 
+// ReadOnly returns true if this node is a read-only replica on this network:
+// the transactions are neither signed nor broadcast, they fail with a *driver.ErrReadOnly
+func (n *NetworkService) ReadOnly() bool {
+	return n.fns.ReadOnly()
+}
+
+// CompatibilityReport is the outcome of the compatibility checks of the components of a network
+type CompatibilityReport = driver.CompatibilityReport
+
+// ErrIncompatible is returned by the initialization of a network, or of a channel, when the compatibility mode
+// is fail and some components run versions not supported by this node
+type ErrIncompatible = driver.ErrIncompatible
+
+// Compatibility returns the outcome of the checks of the versions of the orderers, and of the application
+// capabilities of the channels, against the ones supported by this node. It returns nil if the network
+// does not check them.
+func (n *NetworkService) Compatibility() *CompatibilityReport {
+	cc, ok := n.fns.(driver.CompatibilityChecker)
+	if !ok {
+		return nil
+	}
+	return cc.Compatibility()
+}
+
 // Channel returns the channel service for the passed id
 func (n *NetworkService) Channel(id string) (*Channel, error) {
 	n.channelMutex.RLock()
@@ -84,6 +108,50 @@ func (n *NetworkService) Channel(id string) (*Channel, error) {
 	return c, nil
 }
 
+// ArchiveOptions tell what happens to the data of a channel removed, see RemoveChannel
+type ArchiveOptions = driver.ArchiveOptions
+
+// RejoinOptions tell how a channel removed joins the network again, see RejoinChannel
+type RejoinOptions = driver.RejoinOptions
+
+// ErrChannelRemoved ends the subscriptions of a channel removed, and is returned by Channel for it
+var ErrChannelRemoved = driver.ErrChannelRemoved
+
+// RemoveChannel stops the delivery of the passed channel, ends its subscriptions with ErrChannelRemoved,
+// archives its vault to opts.Path, if set, then deletes it, and removes the channel from this network
+// until it is joined again with RejoinChannel
+func (n *NetworkService) RemoveChannel(name string, opts ArchiveOptions) error {
+	remover, ok := n.fns.(driver.ChannelRemover)
+	if !ok {
+		return errors.Errorf("network [%s] does not support removing channels", n.name)
+	}
+	if err := remover.RemoveChannel(name, opts); err != nil {
+		return err
+	}
+	n.channelMutex.Lock()
+	delete(n.channels, name)
+	n.channelMutex.Unlock()
+	return nil
+}
+
+// RejoinChannel joins again the passed channel removed, with an empty vault or with the one restored from
+// opts.Archive, and resyncs it
+func (n *NetworkService) RejoinChannel(name string, opts RejoinOptions) (*Channel, error) {
+	remover, ok := n.fns.(driver.ChannelRemover)
+	if !ok {
+		return nil, errors.Errorf("network [%s] does not support removing channels", n.name)
+	}
+	ch, err := remover.RejoinChannel(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	c := NewChannel(n.SP, n.fns, ch)
+	n.channelMutex.Lock()
+	n.channels[ch.Name()] = c
+	n.channelMutex.Unlock()
+	return c, nil
+}
+
 // IdentityProvider returns the identity provider of this network
 func (n *NetworkService) IdentityProvider() *IdentityProvider {
 	return &IdentityProvider{
@@ -191,8 +259,14 @@ func GetFabricNetworkNames(sp view2.ServiceProvider) []string {
 	return provider.Names()
 }
 
-// GetFabricNetworkService returns the Fabric Network Service for the passed id, nil if not found
+// GetFabricNetworkService returns the Fabric Network Service for the passed id, nil if not found.
+// If the id is empty, the network of the routing of the passed service provider is used, if any, see RoutingOf.
 func GetFabricNetworkService(sp view2.ServiceProvider, id string) *NetworkService {
+	if len(id) == 0 {
+		if routing, ok := RoutingOf(sp); ok {
+			id = routing.Network
+		}
+	}
 	provider := GetNetworkServiceProvider(sp)
 	if provider == nil {
 		return nil
@@ -210,10 +284,10 @@ func GetDefaultFNS(sp view2.ServiceProvider) *NetworkService {
 	return GetFabricNetworkService(sp, "")
 }
 
-// GetDefaultChannel returns the default channel of the default fns
+// GetDefaultChannel returns the default channel of the default fns, or the channel of the routing of the passed
+// service provider, if any, see Route
 func GetDefaultChannel(sp view2.ServiceProvider) *Channel {
-	network := GetDefaultFNS(sp)
-	channel, err := network.Channel("")
+	channel, _, err := RouteChannel(sp, "", "")
 	if err != nil {
 		panic(err)
 	}
@@ -231,13 +305,9 @@ func GetDefaultLocalMembership(sp view2.ServiceProvider) *LocalMembership {
 	return GetDefaultFNS(sp).LocalMembership()
 }
 
-// GetChannel returns the requested channel for the passed network
+// GetChannel returns the requested channel for the passed network, the empty values are resolved by Route
 func GetChannel(sp view2.ServiceProvider, network, channel string) *Channel {
-	fns := GetFabricNetworkService(sp, network)
-	if fns == nil {
-		panic(fmt.Sprintf("fabric network service [%s] not found", network))
-	}
-	ch, err := fns.Channel(channel)
+	ch, _, err := RouteChannel(sp, network, channel)
 	if err != nil {
 		panic(err)
 	}
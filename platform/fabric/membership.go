@@ -134,6 +134,15 @@ func (s *LocalMembership) Refresh() error {
 	return s.network.LocalMembership().Refresh()
 }
 
+// Snapshots returns the debug snapshots of the local MSPs, by name, the ones of the idemix MSPs are of type idemix.Snapshot.
+// It returns nil if the local membership does not support them.
+func (s *LocalMembership) Snapshots() map[string]interface{} {
+	if snapshotter, ok := s.network.LocalMembership().(driver.MSPSnapshotter); ok {
+		return snapshotter.Snapshots()
+	}
+	return nil
+}
+
 // Verifier is an interface which wraps the Verify method.
 type Verifier interface {
 	// Verify verifies the signature over the passed message.
@@ -148,6 +157,17 @@ func (c *MSPManager) GetMSPIDs() []string {
 	return c.ch.GetMSPIDs()
 }
 
+// ChannelPeer is a peer of another organization of the channel, as found in the channel configuration
+type ChannelPeer = driver.ChannelPeer
+
+// ChannelPeers returns the peers of the other application organizations of the channel this node is allowed
+// to contact for endorsement, together with the MSP ID of their organization.
+// Their endpoints are the anchor peers in the channel configuration plus those configured under `endorsement.peers`,
+// their TLS root certificates come from the organizations' MSPs in the channel configuration.
+func (c *MSPManager) ChannelPeers() []ChannelPeer {
+	return c.ch.ChannelPeers()
+}
+
 func (c *MSPManager) IsValid(identity view.Identity) error {
 	return c.ch.IsValid(identity)
 }
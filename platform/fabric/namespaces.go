@@ -0,0 +1,615 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/pkg/errors"
+)
+
+// SchemaNamespace is the vault namespace where the schema version of each registered namespace is recorded
+const SchemaNamespace = "_schema"
+
+var namespaceRegistryType = reflect.TypeOf((*NamespaceRegistry)(nil))
+
+// Migration migrates the state of a namespace from the schema version `from` to `from+1`.
+// It reads the state with qe, which sees the writes of the previous versions, and writes the new state to rws.
+type Migration func(from int, rws *RWSet, qe *QueryExecutor) error
+
+// MigrationReport describes the migration of a namespace on the vault of a channel
+type MigrationReport struct {
+	Namespace string
+	// From is the schema version found in the vault, To the registered one
+	From, To int
+	// Writes is the number of keys written, or deleted, by the migration
+	Writes int
+	DryRun bool
+	// Err is the reason of the failure, if any
+	Err error
+}
+
+type namespaceDef struct {
+	version int
+	migrate Migration
+}
+
+// NamespaceRegistry keeps track of the namespaces the applications store in the vault, and of their schema version.
+// The pending migrations of a namespace run in a local transaction that also records the new version,
+// they either all apply or none does. A namespace whose migration failed is not served by the vault.
+type NamespaceRegistry struct {
+	mutex      sync.RWMutex
+	namespaces map[string]*namespaceDef
+	// failed maps network and channel to the namespaces whose migration failed
+	failed map[string]map[string]error
+	// readOnly maps network and channel to the read-only namespaces and their owner
+	readOnly map[string]map[string]string
+}
+
+// NewNamespaceRegistry returns a new empty NamespaceRegistry
+func NewNamespaceRegistry() *NamespaceRegistry {
+	return &NamespaceRegistry{
+		namespaces: map[string]*namespaceDef{},
+		failed:     map[string]map[string]error{},
+		readOnly:   map[string]map[string]string{},
+	}
+}
+
+// RegisterNamespace registers the passed namespace at the passed schema version, starting from 1.
+// The migration is invoked, on each channel, once for each version between the one recorded in the vault and the registered one.
+// A namespace never migrated has version 0.
+func (r *NamespaceRegistry) RegisterNamespace(name string, version int, migrate Migration) error {
+	if len(name) == 0 {
+		return errors.New("namespace name cannot be empty")
+	}
+	if name == SchemaNamespace {
+		return errors.Errorf("namespace [%s] is reserved", name)
+	}
+	if version < 1 {
+		return errors.Errorf("invalid version [%d] for namespace [%s], it must be positive", version, name)
+	}
+	if migrate == nil {
+		return errors.Errorf("no migration passed for namespace [%s]", name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.namespaces[name]; ok {
+		return errors.Errorf("namespace [%s] already registered", name)
+	}
+	r.namespaces[name] = &namespaceDef{version: version, migrate: migrate}
+	return nil
+}
+
+// MigrateChannel runs the pending migrations of the registered namespaces on the vault of the passed channel.
+// With dryRun, the migrations run but their transactions are discarded.
+// The returned error, if any, reports the namespaces whose migration failed.
+func (r *NamespaceRegistry) MigrateChannel(ch *Channel, dryRun bool) ([]MigrationReport, error) {
+	// the migrations access the namespaces not served
+	return r.migrate(ch.fns.Name(), ch.Name(), &Vault{ch: ch.ch}, dryRun)
+}
+
+// MigrationError returns the reason why the passed namespace is not served on the passed channel, nil if it is served
+func (r *NamespaceRegistry) MigrationError(network, channel, namespace string) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.failed[channelKey(network, channel)][namespace]
+}
+
+// SetReadOnly makes the passed namespace of the vault of the passed channel read-only: the RWSets refuse
+// to write to it, while the query executors read it as usual. The owner, such as a replication
+// from another node, describes who maintains the namespace, it writes to it with Vault#ReplicateStates.
+func (r *NamespaceRegistry) SetReadOnly(network, channel, namespace, owner string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := channelKey(network, channel)
+	if r.readOnly[key] == nil {
+		r.readOnly[key] = map[string]string{}
+	}
+	r.readOnly[key][namespace] = owner
+}
+
+// ClearReadOnly makes the passed namespace of the vault of the passed channel writable again
+func (r *NamespaceRegistry) ClearReadOnly(network, channel, namespace string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.readOnly[channelKey(network, channel)], namespace)
+}
+
+// ReadOnlyOwner returns the owner of the passed namespace, and true, if it is read-only on the passed channel
+func (r *NamespaceRegistry) ReadOnlyOwner(network, channel, namespace string) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	owner, ok := r.readOnly[channelKey(network, channel)][namespace]
+	return owner, ok
+}
+
+// NamespaceDescription describes a namespace of the registry
+type NamespaceDescription struct {
+	Name string `json:"name"`
+	// Version is the registered schema version, 0 if the namespace is only read-only somewhere and not registered
+	Version int `json:"version"`
+	// Failed maps network:channel to the reason why the namespace is not served there
+	Failed map[string]string `json:"failed,omitempty"`
+	// ReadOnly maps network:channel to the owner of the namespace, where it is read-only
+	ReadOnly map[string]string `json:"readOnly,omitempty"`
+}
+
+// Namespaces returns the description of the registered and the read-only namespaces, sorted by name
+func (r *NamespaceRegistry) Namespaces() []NamespaceDescription {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	descriptions := map[string]*NamespaceDescription{}
+	get := func(name string) *NamespaceDescription {
+		d, ok := descriptions[name]
+		if !ok {
+			d = &NamespaceDescription{Name: name}
+			descriptions[name] = d
+		}
+		return d
+	}
+	for name, def := range r.namespaces {
+		get(name).Version = def.version
+	}
+	for key, namespaces := range r.failed {
+		for name, err := range namespaces {
+			d := get(name)
+			if d.Failed == nil {
+				d.Failed = map[string]string{}
+			}
+			d.Failed[key] = err.Error()
+		}
+	}
+	for key, namespaces := range r.readOnly {
+		for name, owner := range namespaces {
+			d := get(name)
+			if d.ReadOnly == nil {
+				d.ReadOnly = map[string]string{}
+			}
+			d.ReadOnly[key] = owner
+		}
+	}
+
+	res := make([]NamespaceDescription, 0, len(descriptions))
+	for _, d := range descriptions {
+		res = append(res, *d)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// namespaceVault is the part of the vault the migrations use
+type namespaceVault interface {
+	NewQueryExecutor() (*QueryExecutor, error)
+	NewRWSet(txid string) (*RWSet, error)
+	CommitLocalTX(txid string) error
+	DiscardTx(txid string) error
+}
+
+func (r *NamespaceRegistry) migrate(network, channel string, vault namespaceVault, dryRun bool) ([]MigrationReport, error) {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.namespaces))
+	for name := range r.namespaces {
+		names = append(names, name)
+	}
+	r.mutex.RUnlock()
+	sort.Strings(names)
+
+	var reports []MigrationReport
+	var failures []string
+	for _, name := range names {
+		r.mutex.RLock()
+		def := r.namespaces[name]
+		r.mutex.RUnlock()
+
+		report := migrateNamespace(vault, name, def, dryRun)
+		if report.Err != nil {
+			logger.Errorf("[%s:%s] failed migrating namespace [%s] from version [%d] to [%d]: [%s]", network, channel, name, report.From, report.To, report.Err)
+			failures = append(failures, fmt.Sprintf("%s: %s", name, report.Err))
+		} else if report.From != report.To {
+			logger.Infof("[%s:%s] migrated namespace [%s] from version [%d] to [%d], [%d] writes, dry run [%v]", network, channel, name, report.From, report.To, report.Writes, dryRun)
+		}
+		reports = append(reports, report)
+
+		if dryRun {
+			continue
+		}
+		r.mutex.Lock()
+		key := channelKey(network, channel)
+		if report.Err != nil {
+			if r.failed[key] == nil {
+				r.failed[key] = map[string]error{}
+			}
+			r.failed[key][name] = report.Err
+		} else {
+			delete(r.failed[key], name)
+		}
+		r.mutex.Unlock()
+	}
+	if len(failures) != 0 {
+		return reports, errors.Errorf("failed migrating namespaces on [%s:%s] [%s]", network, channel, strings.Join(failures, "; "))
+	}
+	return reports, nil
+}
+
+func migrateNamespace(vault namespaceVault, name string, def *namespaceDef, dryRun bool) MigrationReport {
+	report := MigrationReport{Namespace: name, To: def.version, DryRun: dryRun}
+
+	version, err := SchemaVersion(vault, name)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	report.From = version
+	switch {
+	case version == def.version:
+		return report
+	case version > def.version:
+		report.Err = errors.Errorf("the vault has version [%d], newer than the registered one", version)
+		return report
+	}
+
+	txid := fmt.Sprintf("schema-migration-%s-%d-%d", name, def.version, time.Now().UnixNano())
+	rws, err := vault.NewRWSet(txid)
+	if err != nil {
+		report.Err = errors.WithMessagef(err, "failed creating rwset for the migration")
+		return report
+	}
+	qe, err := vault.NewQueryExecutor()
+	if err != nil {
+		rws.Done()
+		discardMigration(vault, txid)
+		report.Err = errors.WithMessagef(err, "failed creating query executor for the migration")
+		return report
+	}
+
+	overlay := &migrationOverlay{writes: map[string]map[string][]byte{}}
+	mrws := &RWSet{rws: &migrationRWSet{RWSet: rws.rws, overlay: overlay}}
+	mqe := &QueryExecutor{qe: &migrationQueryExecutor{QueryExecutor: qe.qe, overlay: overlay}}
+	for from := version; from < def.version && err == nil; from++ {
+		err = runMigration(def.migrate, from, mrws, mqe)
+	}
+	if err == nil {
+		err = rws.SetState(SchemaNamespace, name, []byte(strconv.Itoa(def.version)))
+	}
+	report.Writes = overlay.size()
+	qe.Done()
+	rws.Done()
+
+	if err != nil || dryRun {
+		discardMigration(vault, txid)
+		report.Err = err
+		return report
+	}
+	// the migration is not ordered, it must not become the transaction the delivery restarts from
+	if err := vault.CommitLocalTX(txid); err != nil {
+		discardMigration(vault, txid)
+		report.Err = errors.WithMessagef(err, "failed committing the migration")
+	}
+	return report
+}
+
+func runMigration(migrate Migration, from int, rws *RWSet, qe *QueryExecutor) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("migration from version [%d] panicked: [%v]", from, r)
+		}
+	}()
+	if err := migrate(from, rws, qe); err != nil {
+		return errors.WithMessagef(err, "failed migrating from version [%d]", from)
+	}
+	return nil
+}
+
+func discardMigration(vault namespaceVault, txid string) {
+	if err := vault.DiscardTx(txid); err != nil {
+		logger.Warnf("failed discarding migration [%s]: [%s]", txid, err)
+	}
+}
+
+// SchemaVersion returns the schema version of the passed namespace recorded in the passed vault, 0 if none
+func SchemaVersion(vault interface {
+	NewQueryExecutor() (*QueryExecutor, error)
+}, namespace string) (int, error) {
+	qe, err := vault.NewQueryExecutor()
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed creating query executor")
+	}
+	defer qe.Done()
+	raw, err := qe.GetState(SchemaNamespace, namespace)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed reading the schema version of [%s]", namespace)
+	}
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid schema version of [%s]", namespace)
+	}
+	return version, nil
+}
+
+// GetNamespaceRegistry returns the registry of the vault namespaces registered in the passed service provider
+func GetNamespaceRegistry(sp view2.ServiceProvider) (*NamespaceRegistry, error) {
+	s, err := sp.GetService(namespaceRegistryType)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get namespace registry")
+	}
+	return s.(*NamespaceRegistry), nil
+}
+
+// namespaceGuard returns the check of the namespaces served by the vault of the passed channel, nil if there is no registry
+func namespaceGuard(sp view2.ServiceProvider, network, channel string) func(namespace string) error {
+	if sp == nil {
+		return nil
+	}
+	r, err := GetNamespaceRegistry(sp)
+	if err != nil {
+		return nil
+	}
+	return func(namespace string) error {
+		if err := r.MigrationError(network, channel, namespace); err != nil {
+			return errors.WithMessagef(err, "namespace [%s] not served, its migration failed", namespace)
+		}
+		return nil
+	}
+}
+
+// namespaceWriteGuard returns the check of the namespaces the vault of the passed channel accepts writes to, nil if there is no registry
+func namespaceWriteGuard(sp view2.ServiceProvider, network, channel string) func(namespace string) error {
+	if sp == nil {
+		return nil
+	}
+	r, err := GetNamespaceRegistry(sp)
+	if err != nil {
+		return nil
+	}
+	return func(namespace string) error {
+		if owner, ok := r.ReadOnlyOwner(network, channel, namespace); ok {
+			return errors.Errorf("namespace [%s] is read-only, it is maintained by [%s]", namespace, owner)
+		}
+		return nil
+	}
+}
+
+func channelKey(network, channel string) string {
+	return network + ":" + channel
+}
+
+// migrationOverlay holds the writes of a migration, so that the next versions read them
+type migrationOverlay struct {
+	writes map[string]map[string][]byte
+}
+
+func (o *migrationOverlay) set(ns, key string, value []byte) {
+	if o.writes[ns] == nil {
+		o.writes[ns] = map[string][]byte{}
+	}
+	o.writes[ns][key] = append([]byte(nil), value...)
+}
+
+func (o *migrationOverlay) get(ns, key string) ([]byte, bool) {
+	v, ok := o.writes[ns][key]
+	return v, ok
+}
+
+func (o *migrationOverlay) size() int {
+	n := 0
+	for _, keys := range o.writes {
+		n += len(keys)
+	}
+	return n
+}
+
+// keys returns the sorted keys written in the passed namespace in the range [startKey, endKey), an empty endKey has no bound
+func (o *migrationOverlay) keys(ns, startKey, endKey string) []string {
+	var res []string
+	for key := range o.writes[ns] {
+		if key >= startKey && (len(endKey) == 0 || key < endKey) {
+			res = append(res, key)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+type migrationRWSet struct {
+	fdriver.RWSet
+	overlay *migrationOverlay
+}
+
+func (m *migrationRWSet) SetState(namespace string, key string, value []byte) error {
+	if err := m.RWSet.SetState(namespace, key, value); err != nil {
+		return err
+	}
+	m.overlay.set(namespace, key, value)
+	return nil
+}
+
+func (m *migrationRWSet) DeleteState(namespace string, key string) error {
+	if err := m.RWSet.DeleteState(namespace, key); err != nil {
+		return err
+	}
+	m.overlay.set(namespace, key, nil)
+	return nil
+}
+
+type migrationQueryExecutor struct {
+	fdriver.QueryExecutor
+	overlay *migrationOverlay
+}
+
+func (m *migrationQueryExecutor) GetState(namespace string, key string) ([]byte, error) {
+	if v, ok := m.overlay.get(namespace, key); ok {
+		if len(v) == 0 {
+			return nil, nil
+		}
+		return v, nil
+	}
+	return m.QueryExecutor.GetState(namespace, key)
+}
+
+func (m *migrationQueryExecutor) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
+	it, err := m.QueryExecutor.GetStateRangeScanIterator(namespace, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return &overlayIterator{
+		it:        it,
+		namespace: namespace,
+		overlay:   m.overlay,
+		keys:      m.overlay.keys(namespace, startKey, endKey),
+	}, nil
+}
+
+// overlayIterator merges the results of the vault with the writes of the migration, both sorted by key
+type overlayIterator struct {
+	it        driver.VersionedResultsIterator
+	namespace string
+	overlay   *migrationOverlay
+	keys      []string
+	next      *driver.VersionedRead
+	exhausted bool
+}
+
+func (o *overlayIterator) Next() (*driver.VersionedRead, error) {
+	for {
+		if o.next == nil && !o.exhausted {
+			read, err := o.it.Next()
+			if err != nil {
+				return nil, err
+			}
+			if read == nil {
+				o.exhausted = true
+			}
+			o.next = read
+		}
+
+		var read *driver.VersionedRead
+		switch {
+		case o.next == nil && len(o.keys) == 0:
+			return nil, nil
+		case o.next == nil || (len(o.keys) != 0 && o.keys[0] <= o.next.Key):
+			key := o.keys[0]
+			o.keys = o.keys[1:]
+			if o.next != nil && o.next.Key == key {
+				// overwritten by the migration
+				o.next = nil
+			}
+			v, _ := o.overlay.get(o.namespace, key)
+			if len(v) == 0 {
+				// deleted by the migration
+				continue
+			}
+			read = &driver.VersionedRead{Key: key, Raw: v}
+		default:
+			read, o.next = o.next, nil
+		}
+		return read, nil
+	}
+}
+
+func (o *overlayIterator) Close() {
+	o.it.Close()
+}
+
+// guardedRWSet refuses to access the namespaces not served, and to write to the read-only ones
+type guardedRWSet struct {
+	fdriver.RWSet
+	guard      func(namespace string) error
+	writeGuard func(namespace string) error
+}
+
+func (g *guardedRWSet) check(namespace string) error {
+	if g.guard == nil {
+		return nil
+	}
+	return g.guard(namespace)
+}
+
+func (g *guardedRWSet) checkWrite(namespace string) error {
+	if err := g.check(namespace); err != nil {
+		return err
+	}
+	if g.writeGuard == nil {
+		return nil
+	}
+	return g.writeGuard(namespace)
+}
+
+func (g *guardedRWSet) SetState(namespace string, key string, value []byte) error {
+	if err := g.checkWrite(namespace); err != nil {
+		return err
+	}
+	return g.RWSet.SetState(namespace, key, value)
+}
+
+func (g *guardedRWSet) GetState(namespace string, key string, opts ...fdriver.GetStateOpt) ([]byte, error) {
+	if err := g.check(namespace); err != nil {
+		return nil, err
+	}
+	return g.RWSet.GetState(namespace, key, opts...)
+}
+
+func (g *guardedRWSet) DeleteState(namespace string, key string) error {
+	if err := g.checkWrite(namespace); err != nil {
+		return err
+	}
+	return g.RWSet.DeleteState(namespace, key)
+}
+
+func (g *guardedRWSet) GetStateMetadata(namespace, key string, opts ...fdriver.GetStateOpt) (map[string][]byte, error) {
+	if err := g.check(namespace); err != nil {
+		return nil, err
+	}
+	return g.RWSet.GetStateMetadata(namespace, key, opts...)
+}
+
+func (g *guardedRWSet) SetStateMetadata(namespace, key string, metadata map[string][]byte) error {
+	if err := g.checkWrite(namespace); err != nil {
+		return err
+	}
+	return g.RWSet.SetStateMetadata(namespace, key, metadata)
+}
+
+// guardedQueryExecutor refuses to access the namespaces not served
+type guardedQueryExecutor struct {
+	fdriver.QueryExecutor
+	guard func(namespace string) error
+}
+
+func (g *guardedQueryExecutor) GetState(namespace string, key string) ([]byte, error) {
+	if err := g.guard(namespace); err != nil {
+		return nil, err
+	}
+	return g.QueryExecutor.GetState(namespace, key)
+}
+
+func (g *guardedQueryExecutor) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	if err := g.guard(namespace); err != nil {
+		return nil, 0, 0, err
+	}
+	return g.QueryExecutor.GetStateMetadata(namespace, key)
+}
+
+func (g *guardedQueryExecutor) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
+	if err := g.guard(namespace); err != nil {
+		return nil, err
+	}
+	return g.QueryExecutor.GetStateRangeScanIterator(namespace, startKey, endKey)
+}
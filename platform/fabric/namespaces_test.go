@@ -0,0 +1,302 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/mocks"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const numAccounts = 3000
+
+// testVault exposes the generic vault as the one of a channel
+type testVault struct {
+	v *vault.Vault
+}
+
+func (t *testVault) NewQueryExecutor() (*QueryExecutor, error) {
+	qe, err := t.v.NewQueryExecutor()
+	if err != nil {
+		return nil, err
+	}
+	return &QueryExecutor{qe: qe}, nil
+}
+
+func (t *testVault) NewRWSet(txid string) (*RWSet, error) {
+	rws, err := t.v.NewRWSet(txid)
+	if err != nil {
+		return nil, err
+	}
+	return &RWSet{rws: rws}, nil
+}
+
+func (t *testVault) CommitTX(txid string, block uint64, indexInBloc int) error {
+	return t.v.CommitTX(txid, block, indexInBloc)
+}
+
+func (t *testVault) CommitLocalTX(txid string) error {
+	return t.v.CommitLocalTX(txid)
+}
+
+func (t *testVault) DiscardTx(txid string) error {
+	return t.v.DiscardTx(txid)
+}
+
+func newTestVault(t *testing.T) *testVault {
+	v, _ := openTestVault(t, "memory", "")
+	seedAccounts(t, v, 1)
+	return v
+}
+
+// seedAccounts commits the accounts in the passed block, with the transaction seed
+func seedAccounts(t *testing.T, v *testVault, block uint64) {
+	rws, err := v.NewRWSet("seed")
+	assert.NoError(t, err)
+	for i := 0; i < numAccounts; i++ {
+		assert.NoError(t, rws.SetState("accounts", fmt.Sprintf("acct.%05d", i), []byte(fmt.Sprintf("%d", i))))
+	}
+	rws.Done()
+	assert.NoError(t, v.CommitTX("seed", block, 0))
+}
+
+// openTestVault opens the vault persisted with the passed driver at the passed path, together with its txid store
+func openTestVault(t *testing.T, driver, path string) (*testVault, *txidstore.SimpleTXIDStore) {
+	c := &mocks.Config{}
+	c.UnmarshalKeyReturns(nil)
+	c.IsSetReturns(false)
+	ddb, err := db.OpenVersioned(nil, driver, path, c)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	return &testVault{v: vault.New(ddb, tidstore)}, tidstore
+}
+
+// scan returns the state of the passed namespace
+func scan(t *testing.T, v namespaceVault, ns string) map[string]string {
+	qe, err := v.NewQueryExecutor()
+	assert.NoError(t, err)
+	defer qe.Done()
+	it, err := qe.GetStateRangeScanIterator(ns, "", "")
+	assert.NoError(t, err)
+	defer it.Close()
+	res := map[string]string{}
+	for {
+		read, err := it.Next()
+		assert.NoError(t, err)
+		if read == nil {
+			return res
+		}
+		res[read.K()] = string(read.V())
+	}
+}
+
+// accountsMigration renames the keys at version 1, and adds the currency to the balances at version 2
+func accountsMigration(from int, rws *RWSet, qe *QueryExecutor) error {
+	switch from {
+	case 0:
+		it, err := qe.GetStateRangeScanIterator("accounts", "acct.", "acct/")
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		for {
+			read, err := it.Next()
+			if err != nil {
+				return err
+			}
+			if read == nil {
+				return nil
+			}
+			if err := rws.DeleteState("accounts", read.K()); err != nil {
+				return err
+			}
+			if err := rws.SetState("accounts", "account."+strings.TrimPrefix(read.K(), "acct."), read.V()); err != nil {
+				return err
+			}
+		}
+	case 1:
+		// the keys renamed at version 1 are visible, the old ones are not
+		if v, err := qe.GetState("accounts", "acct.00000"); err != nil || v != nil {
+			return errors.Errorf("old key still visible [%s][%v]", v, err)
+		}
+		it, err := qe.GetStateRangeScanIterator("accounts", "", "")
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		n := 0
+		for {
+			read, err := it.Next()
+			if err != nil {
+				return err
+			}
+			if read == nil {
+				break
+			}
+			if !strings.HasPrefix(read.K(), "account.") {
+				return errors.Errorf("unexpected key [%s]", read.K())
+			}
+			if err := rws.SetState("accounts", read.K(), append(read.V(), []byte(":EUR")...)); err != nil {
+				return err
+			}
+			n++
+		}
+		if n != numAccounts {
+			return errors.Errorf("expected [%d] accounts, got [%d]", numAccounts, n)
+		}
+		return nil
+	}
+	return errors.Errorf("unexpected version [%d]", from)
+}
+
+func TestNamespaceMigrations(t *testing.T) {
+	v := newTestVault(t)
+	r := NewNamespaceRegistry()
+	assert.NoError(t, r.RegisterNamespace("accounts", 2, accountsMigration))
+	assert.Error(t, r.RegisterNamespace("accounts", 3, accountsMigration))
+	assert.Error(t, r.RegisterNamespace(SchemaNamespace, 1, accountsMigration))
+	assert.Error(t, r.RegisterNamespace("others", 0, accountsMigration))
+
+	// the dry run reports the writes and leaves the vault as it is
+	reports, err := r.migrate("default", "mychannel", v, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []MigrationReport{{Namespace: "accounts", From: 0, To: 2, Writes: 2 * numAccounts, DryRun: true}}, reports)
+	version, err := SchemaVersion(v, "accounts")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+	state := scan(t, v, "accounts")
+	assert.Len(t, state, numAccounts)
+	assert.Equal(t, "7", state["acct.00007"])
+
+	reports, err = r.migrate("default", "mychannel", v, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []MigrationReport{{Namespace: "accounts", From: 0, To: 2, Writes: 2 * numAccounts}}, reports)
+	version, err = SchemaVersion(v, "accounts")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	state = scan(t, v, "accounts")
+	assert.Len(t, state, numAccounts)
+	for i := 0; i < numAccounts; i++ {
+		assert.Equal(t, fmt.Sprintf("%d:EUR", i), state[fmt.Sprintf("account.%05d", i)])
+	}
+	assert.NoError(t, r.MigrationError("default", "mychannel", "accounts"))
+
+	// nothing is pending anymore
+	reports, err = r.migrate("default", "mychannel", v, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []MigrationReport{{Namespace: "accounts", From: 2, To: 2}}, reports)
+}
+
+func TestNamespaceMigrationRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault")
+	v, tidstore := openTestVault(t, "badger", path)
+	seedAccounts(t, v, 5)
+
+	r := NewNamespaceRegistry()
+	assert.NoError(t, r.RegisterNamespace("accounts", 2, accountsMigration))
+	_, err := r.migrate("default", "mychannel", v, false)
+	assert.NoError(t, err)
+	assert.NoError(t, v.v.Close())
+
+	// once restarted, the delivery resumes from the last transaction ordered, not from the migration
+	v, tidstore = openTestVault(t, "badger", path)
+	defer v.v.Close()
+	last, err := tidstore.GetLastTxID()
+	assert.NoError(t, err)
+	assert.Equal(t, "seed", last)
+	version, err := SchemaVersion(v, "accounts")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	state := scan(t, v, "accounts")
+	assert.Len(t, state, numAccounts)
+	assert.Equal(t, "7:EUR", state["account.00007"])
+}
+
+func TestNamespaceMigrationFailure(t *testing.T) {
+	v := newTestVault(t)
+	r := NewNamespaceRegistry()
+	assert.NoError(t, r.RegisterNamespace("accounts", 2, func(from int, rws *RWSet, qe *QueryExecutor) error {
+		if from == 1 {
+			return errors.New("boom")
+		}
+		return accountsMigration(from, rws, qe)
+	}))
+
+	reports, err := r.migrate("default", "mychannel", v, false)
+	assert.EqualError(t, err, "failed migrating namespaces on [default:mychannel] [accounts: failed migrating from version [1]: boom]")
+	assert.Len(t, reports, 1)
+	assert.Error(t, reports[0].Err)
+
+	// none of the versions applied
+	version, err := SchemaVersion(v, "accounts")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+	state := scan(t, v, "accounts")
+	assert.Len(t, state, numAccounts)
+	assert.Equal(t, "7", state["acct.00007"])
+
+	// the namespace is not served anymore on that channel
+	assert.Error(t, r.MigrationError("default", "mychannel", "accounts"))
+	assert.NoError(t, r.MigrationError("default", "otherchannel", "accounts"))
+	guard := func(ns string) error { return r.MigrationError("default", "mychannel", ns) }
+	qe, err := v.NewQueryExecutor()
+	assert.NoError(t, err)
+	gqe := &QueryExecutor{qe: &guardedQueryExecutor{QueryExecutor: qe.qe, guard: guard}}
+	_, err = gqe.GetState("accounts", "acct.00007")
+	assert.Error(t, err)
+	_, err = gqe.GetStateRangeScanIterator("accounts", "", "")
+	assert.Error(t, err)
+	_, err = gqe.GetState(SchemaNamespace, "accounts")
+	assert.NoError(t, err)
+	gqe.Done()
+
+	// the registry reports where the namespace is not served
+	namespaces := r.Namespaces()
+	assert.Len(t, namespaces, 1)
+	assert.Equal(t, 2, namespaces[0].Version)
+	assert.Contains(t, namespaces[0].Failed["default:mychannel"], "boom")
+}
+
+func TestReadOnlyNamespace(t *testing.T) {
+	v := newTestVault(t)
+	r := NewNamespaceRegistry()
+	r.SetReadOnly("default", "mychannel", "replica", "source node")
+	owner, ok := r.ReadOnlyOwner("default", "mychannel", "replica")
+	assert.True(t, ok)
+	assert.Equal(t, "source node", owner)
+	_, ok = r.ReadOnlyOwner("default", "otherchannel", "replica")
+	assert.False(t, ok)
+	assert.Equal(t, []NamespaceDescription{{Name: "replica", ReadOnly: map[string]string{"default:mychannel": "source node"}}}, r.Namespaces())
+
+	sp := registry.New()
+	assert.NoError(t, sp.RegisterService(r))
+	writeGuard := namespaceWriteGuard(sp, "default", "mychannel")
+	rws, err := v.NewRWSet("tx1")
+	assert.NoError(t, err)
+	grws := &RWSet{rws: &guardedRWSet{RWSet: rws.rws, writeGuard: writeGuard}}
+	assert.EqualError(t, grws.SetState("replica", "k", []byte("v")), "namespace [replica] is read-only, it is maintained by [source node]")
+	assert.Error(t, grws.DeleteState("replica", "k"))
+	_, err = grws.GetState("replica", "k")
+	assert.NoError(t, err)
+	assert.NoError(t, grws.SetState("accounts", "k", []byte("v")))
+	grws.Done()
+
+	r.ClearReadOnly("default", "mychannel", "replica")
+	assert.NoError(t, writeGuard("replica"))
+}
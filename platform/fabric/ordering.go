@@ -30,3 +30,46 @@ func (n *Ordering) Broadcast(blob interface{}) error {
 		return n.network.Broadcast(blob)
 	}
 }
+
+// OrdererStatus is the outcome of the pre-flight check of an orderer
+type OrdererStatus = driver.OrdererStatus
+
+// ErrEnvelopeTooLarge is returned by Broadcast for the envelopes exceeding the size limit of their channel
+type ErrEnvelopeTooLarge = driver.ErrEnvelopeTooLarge
+
+// ErrDuplicateInFlight is wrapped by the error returned by Broadcast, when the admission of the transactions is enabled
+// with the reject policy, for the transactions semantically identical to one in flight
+var ErrDuplicateInFlight = driver.ErrDuplicateInFlight
+
+// ErrReplayedTxID is returned by Broadcast for the transactions whose identifier is final already in the vault,
+// or whose envelope has been ordered already: the peers would invalidate them as duplicates
+type ErrReplayedTxID = driver.ErrReplayedTxID
+
+// ErrStaleReadSet is returned by Broadcast, when the stale read check is enabled, for the transactions whose read set
+// is already stale with respect to the vault: some of the keys read have been changed by a transaction committed
+// after the endorsement. The check is best-effort, a transaction passing it can still fail for an MVCC conflict.
+// See Transaction#SkipStaleReadCheck to skip it.
+type ErrStaleReadSet = driver.ErrStaleReadSet
+
+// ErrTransactionExpired is returned by Broadcast for the transactions created longer than their time to live ago.
+// The time to live is the one of its chaincode, or of the network, shortened by the one of the transaction, if any,
+// see WithTTL and Transaction#SetTTL. The transactions are timed by their signed proposal.
+// The transaction is abandoned, its finality fails with this error, that matches ErrTransactionAbandoned.
+type ErrTransactionExpired = driver.ErrTransactionExpired
+
+// StaleRead is a read reported by an ErrStaleReadSet
+type StaleRead = driver.StaleRead
+
+// ErrReadOnly is returned by Broadcast, and by the operations signing transactions, on the networks where this node
+// is a read-only replica. See NetworkService#ReadOnly.
+type ErrReadOnly = driver.ErrReadOnly
+
+// Statuses returns the outcome of the pre-flight check of the known Orderer nodes checked so far.
+// It returns nil if the network does not check its orderers.
+func (n *Ordering) Statuses() []OrdererStatus {
+	oc, ok := n.network.(driver.OrdererChecker)
+	if !ok {
+		return nil
+	}
+	return oc.OrdererStatuses()
+}
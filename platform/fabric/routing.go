@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"context"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// RoutingProvenance records how the network and the channel of a transaction have been chosen
+type RoutingProvenance = driver.RoutingProvenance
+
+// ErrUnknownNetwork is returned by Route when a transaction targets a network this node is not part of
+type ErrUnknownNetwork = driver.ErrUnknownNetwork
+
+// ErrUnknownChannel is returned by Route when a transaction targets a channel this node does not have,
+// it lists the valid ones
+type ErrUnknownChannel = driver.ErrUnknownChannel
+
+const (
+	RoutingExplicit = driver.RoutingExplicit
+	RoutingContext  = driver.RoutingContext
+	RoutingDefault  = driver.RoutingDefault
+)
+
+// RoutingOf returns the routing of the passed service provider, if it is a view context carrying one,
+// see view.RoutedTo
+func RoutingOf(sp view2.ServiceProvider) (view.Routing, bool) {
+	c, ok := sp.(interface{ Context() context.Context })
+	if !ok {
+		return view.Routing{}, false
+	}
+	return view.RoutingFromContext(c.Context())
+}
+
+// Route resolves the network and the channel of a transaction. The passed values, if not empty, win over those of
+// the routing of the passed service provider, see RoutingOf, which win over the defaults of the node.
+// The networks and the channels not chosen by default are checked against those of this node.
+func Route(sp view2.ServiceProvider, network, channel string) (*NetworkService, *RoutingProvenance, error) {
+	routing, _ := RoutingOf(sp)
+	provenance := &RoutingProvenance{NetworkSource: RoutingDefault, ChannelSource: RoutingDefault}
+
+	switch {
+	case len(network) != 0:
+		provenance.Network, provenance.NetworkSource = network, RoutingExplicit
+	case len(routing.Network) != 0:
+		provenance.Network, provenance.NetworkSource = routing.Network, RoutingContext
+	}
+	if provenance.NetworkSource != RoutingDefault {
+		if !contains(GetFabricNetworkNames(sp), provenance.Network) {
+			return nil, nil, &ErrUnknownNetwork{
+				Network: provenance.Network,
+				Source:  provenance.NetworkSource,
+				Valid:   GetFabricNetworkNames(sp),
+			}
+		}
+	}
+	provider := GetNetworkServiceProvider(sp)
+	if provider == nil {
+		return nil, nil, errors.New("no Fabric Network Service Provider found")
+	}
+	fns, err := provider.FabricNetworkService(provenance.Network)
+	if err != nil {
+		return nil, nil, err
+	}
+	provenance.Network = fns.Name()
+
+	switch {
+	case len(channel) != 0:
+		provenance.Channel, provenance.ChannelSource = channel, RoutingExplicit
+	case len(routing.Channel) != 0:
+		provenance.Channel, provenance.ChannelSource = routing.Channel, RoutingContext
+	default:
+		provenance.Channel = fns.DefaultChannel()
+	}
+	if provenance.ChannelSource != RoutingDefault && !contains(fns.Channels(), provenance.Channel) {
+		return nil, nil, &ErrUnknownChannel{
+			Network: provenance.Network,
+			Channel: provenance.Channel,
+			Source:  provenance.ChannelSource,
+			Valid:   fns.Channels(),
+		}
+	}
+	return fns, provenance, nil
+}
+
+// RouteChannel returns the channel resolved by Route, together with the routing decision
+func RouteChannel(sp view2.ServiceProvider, network, channel string) (*Channel, *RoutingProvenance, error) {
+	fns, provenance, err := Route(sp, network, channel)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := fns.Channel(provenance.Channel)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ch, provenance, nil
+}
+
+// RecordTransactionRouting records in the provenance of the passed transaction how its network and its channel
+// have been chosen, see Route
+func (c *Channel) RecordTransactionRouting(txID string, routing *RoutingProvenance) error {
+	p, ok := c.ch.(driver.ProvenanceProvider)
+	if !ok {
+		return errors.Errorf("channel [%s] does not support transaction provenance", c.ch.Name())
+	}
+	return p.ProvenanceService().RecordRouting(txID, *routing)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type routingChannel struct {
+	driver.Channel
+	name string
+}
+
+func (c *routingChannel) Name() string { return c.name }
+
+type routingNetwork struct {
+	driver.FabricNetworkService
+	name     string
+	channels []string
+}
+
+func (n *routingNetwork) Name() string { return n.name }
+
+func (n *routingNetwork) DefaultChannel() string { return n.channels[0] }
+
+func (n *routingNetwork) Channels() []string { return n.channels }
+
+func (n *routingNetwork) Channel(name string) (driver.Channel, error) {
+	if len(name) == 0 {
+		name = n.DefaultChannel()
+	}
+	return &routingChannel{name: name}, nil
+}
+
+type routingProvider struct {
+	networks []*routingNetwork
+}
+
+func (p *routingProvider) Names() []string {
+	var names []string
+	for _, n := range p.networks {
+		names = append(names, n.name)
+	}
+	return names
+}
+
+func (p *routingProvider) DefaultName() string { return p.networks[0].name }
+
+func (p *routingProvider) FabricNetworkService(id string) (driver.FabricNetworkService, error) {
+	if len(id) == 0 {
+		id = p.DefaultName()
+	}
+	for _, n := range p.networks {
+		if n.name == id {
+			return n, nil
+		}
+	}
+	return nil, errors.Errorf("network [%s] not found", id)
+}
+
+// routedContext is the service provider of a view invoked with the passed metadata
+type routedContext struct {
+	view2.ServiceProvider
+	ctx context.Context
+}
+
+func (r *routedContext) Context() context.Context { return r.ctx }
+
+func newRoutedContext(sp view2.ServiceProvider, metadata map[string]string) *routedContext {
+	ctx := context.Background()
+	if routing, ok := view.RoutingFromMetadata(metadata); ok {
+		ctx = view.WithRouting(ctx, routing)
+	}
+	return &routedContext{ServiceProvider: sp, ctx: ctx}
+}
+
+func newRoutingServiceProvider(t *testing.T) view2.ServiceProvider {
+	sp := registry.New()
+	assert.NoError(t, sp.RegisterService(&routingProvider{networks: []*routingNetwork{
+		{name: "default", channels: []string{"ch1", "ch2"}},
+		{name: "other", channels: []string{"ch3"}},
+	}}))
+	assert.NoError(t, sp.RegisterService(NewNetworkServiceProvider(sp)))
+	return sp
+}
+
+func TestRouting(t *testing.T) {
+	sp := newRoutingServiceProvider(t)
+
+	// the same view targets the channel set by the metadata of its invocation only
+	channelOf := func(sp view2.ServiceProvider) string {
+		return GetDefaultChannel(sp).Name()
+	}
+	assert.Equal(t, "ch1", channelOf(newRoutedContext(sp, map[string]string{view.SessionChannelMetadata: "ch1"})))
+	assert.Equal(t, "ch2", channelOf(newRoutedContext(sp, map[string]string{view.SessionChannelMetadata: "ch2"})))
+	assert.Equal(t, "ch3", channelOf(newRoutedContext(sp, map[string]string{view.SessionNetworkMetadata: "other"})))
+	assert.Equal(t, "ch1", channelOf(sp))
+	assert.Equal(t, "other", GetFabricNetworkService(newRoutedContext(sp, map[string]string{view.SessionNetworkMetadata: "other"}), "").Name())
+
+	// the routing decision is returned
+	_, provenance, err := Route(newRoutedContext(sp, map[string]string{view.SessionChannelMetadata: "ch2"}), "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &RoutingProvenance{Network: "default", Channel: "ch2", NetworkSource: RoutingDefault, ChannelSource: RoutingContext}, provenance)
+	_, provenance, err = Route(newRoutedContext(sp, map[string]string{view.SessionChannelMetadata: "ch2"}), "", "ch1")
+	assert.NoError(t, err)
+	assert.Equal(t, &RoutingProvenance{Network: "default", Channel: "ch1", NetworkSource: RoutingDefault, ChannelSource: RoutingExplicit}, provenance)
+	_, provenance, err = Route(sp, "other", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &RoutingProvenance{Network: "other", Channel: "ch3", NetworkSource: RoutingExplicit, ChannelSource: RoutingDefault}, provenance)
+
+	// the overrides unknown to the node are refused, with the valid values
+	_, _, err = Route(newRoutedContext(sp, map[string]string{view.SessionChannelMetadata: "ch3"}), "", "")
+	unknownChannel := &ErrUnknownChannel{}
+	assert.True(t, errors.As(err, &unknownChannel))
+	assert.Equal(t, &ErrUnknownChannel{Network: "default", Channel: "ch3", Source: RoutingContext, Valid: []string{"ch1", "ch2"}}, unknownChannel)
+	assert.EqualError(t, err, "unknown channel [ch3] of network [default] set by context routing, valid channels are [ch1, ch2]")
+	_, _, err = Route(sp, "unknown", "")
+	unknownNetwork := &ErrUnknownNetwork{}
+	assert.True(t, errors.As(err, &unknownNetwork))
+	assert.Equal(t, []string{"default", "other"}, unknownNetwork.Valid)
+	assert.Panics(t, func() { GetChannel(sp, "", "ch4") })
+}
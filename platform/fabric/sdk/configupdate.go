@@ -0,0 +1,62 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+// SimulateConfigUpdateURI is the URI, relative to the web server API, of the simulation of a config update of a channel
+const SimulateConfigUpdateURI = "/fabric/{Network}/{Channel}/config/simulate"
+
+// SimulateConfigUpdateRequest is the JSON body of a config update simulation request
+type SimulateConfigUpdateRequest struct {
+	// Envelope is the envelope of type CONFIG_UPDATE, base64 encoded, as it would be submitted to the orderers
+	Envelope []byte `json:"envelope"`
+}
+
+// simulateConfigUpdateHandler validates a config update against the active configuration of a channel without
+// committing it, and returns the changes it would make. An update that does not pass the validation is answered
+// with 200 and the failing element of the configuration tree, a malformed request with 400.
+type simulateConfigUpdateHandler struct {
+	sp Registry
+}
+
+func (h *simulateConfigUpdateHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	req := &SimulateConfigUpdateRequest{}
+	if err := json.Unmarshal(bytes, req); err != nil {
+		return nil, errors.Wrapf(err, "invalid config update simulation request")
+	}
+	return req, nil
+}
+
+func (h *simulateConfigUpdateHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel := context.Vars["Network"], context.Vars["Channel"]
+	req := context.Query.(*SimulateConfigUpdateRequest)
+	if len(req.Envelope) == 0 {
+		return &web.ResponseErr{Reason: "no envelope passed"}, http.StatusBadRequest
+	}
+
+	fns := fabric.GetFabricNetworkService(h.sp, network)
+	if fns == nil {
+		return &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return &web.ResponseErr{Reason: "channel not found"}, http.StatusNotFound
+	}
+	simulation, err := ch.SimulateConfigUpdate(req.Envelope)
+	if err != nil {
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusBadRequest
+	}
+	return simulation, http.StatusOK
+}
@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
+	"github.com/pkg/errors"
+)
+
+// committers drains the block commits of the channels of all the networks.
+// It waits for the block commits in flight to complete and blocks new ones, the node is about to stop;
+// the blocks not committed are delivered again after the restart.
+type committers struct {
+	sp    Registry
+	lock  sync.Mutex
+	names []string
+	// pending are the channels whose block commits have not been paused yet
+	pending map[string]drain.Work
+}
+
+func newCommitters(sp Registry) *committers {
+	return &committers{sp: sp}
+}
+
+func (c *committers) Drain(ctx context.Context) error {
+	type channel struct {
+		id      string
+		fns     *fabric.NetworkService
+		channel string
+	}
+	var channels []channel
+	c.lock.Lock()
+	c.names, c.pending = nil, map[string]drain.Work{}
+	for _, name := range fabric.GetFabricNetworkNames(c.sp) {
+		fns := fabric.GetFabricNetworkService(c.sp, name)
+		if fns == nil {
+			continue
+		}
+		for _, ch := range fns.Channels() {
+			id := name + ":" + ch
+			channels = append(channels, channel{id: id, fns: fns, channel: ch})
+			c.names = append(c.names, id)
+			c.pending[id] = drain.Work{ID: id}
+		}
+	}
+	c.lock.Unlock()
+
+	var failed []string
+	for _, channel := range channels {
+		ch, err := channel.fns.Channel(channel.channel)
+		if err != nil {
+			logger.Errorf("failed getting channel [%s], its commits are not drained [%s]", channel.id, err)
+			failed = append(failed, channel.id)
+			continue
+		}
+		// the commits stay paused, the node stops once drained
+		if _, err := ch.Vault().PauseCommits(ctx); err != nil {
+			logger.Errorf("failed draining the commits of channel [%s] [%s]", channel.id, err)
+			failed = append(failed, channel.id)
+			continue
+		}
+		c.lock.Lock()
+		delete(c.pending, channel.id)
+		c.lock.Unlock()
+	}
+	if len(failed) != 0 {
+		return errors.Errorf("commits of channels %v not drained", failed)
+	}
+	return nil
+}
+
+// InFlight returns the channels whose block commits have not been drained yet
+func (c *committers) InFlight() []drain.Work {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var res []drain.Work
+	for _, id := range c.names {
+		if w, ok := c.pending[id]; ok {
+			res = append(res, w)
+		}
+	}
+	return res
+}
@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"context"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/sinks"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/id"
+	view3 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view"
+	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// eventsStreamer streams the events committed on a channel to the clients of the view service subscribing to them.
+// The view service authenticates the clients, the subscribers of a network are its node identity, the admins,
+// and the clients in `events.subscriptions.clients`. The other clients are refused.
+type eventsStreamer struct {
+	sp Registry
+	// subscribers are the identities allowed to subscribe to the events of each network
+	subscribers map[string][]view.Identity
+}
+
+func newEventsStreamer(sp Registry, networks []string, defaultNetwork string) (*eventsStreamer, error) {
+	s := &eventsStreamer{sp: sp, subscribers: map[string][]view.Identity{}}
+	ip := view2.GetIdentityProvider(sp)
+	for _, network := range networks {
+		c, err := config.New(view2.GetConfigService(sp), network, network == defaultNetwork)
+		if err != nil {
+			return nil, err
+		}
+		paths, err := c.SubscriptionsClients()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed loading the subscribers of fabric network [%s]", network)
+		}
+		subscribers := append([]view.Identity{ip.DefaultIdentity()}, ip.Admins()...)
+		for _, path := range paths {
+			identity, err := id.LoadIdentity(path)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed loading subscriber [%s] of fabric network [%s]", path, network)
+			}
+			subscribers = append(subscribers, identity)
+		}
+		s.subscribers[network] = subscribers
+	}
+	return s, nil
+}
+
+// stream sends the events of the subscription of the passed command until the client disconnects.
+// The stream ends with status ResourceExhausted if the client falls too far behind, OutOfRange if the resume token
+// expired, InvalidArgument if it is invalid, and PermissionDenied if the client is not allowed to subscribe.
+func (s *eventsStreamer) stream(sc *protos2.SignedCommand, command *protos2.Command, commandServer protos2.ViewService_StreamCommandServer, marshaler view3.Marshaller) error {
+	c := command.Payload.(*protos2.Command_SubscribeEvents).SubscribeEvents
+	filter := c.Filter
+	if filter == nil {
+		filter = &protos2.EventFilter{}
+	}
+
+	fns := fabric.GetFabricNetworkService(s.sp, filter.Network)
+	if fns == nil {
+		return status.Errorf(codes.NotFound, "fabric network [%s] not found", filter.Network)
+	}
+	if !s.allowed(fns.Name(), command.Header.Creator) {
+		return status.Errorf(codes.PermissionDenied, "identity [%s] not allowed to subscribe to the events of fabric network [%s]", view.Identity(command.Header.Creator), fns.Name())
+	}
+	// opening the channel starts its journal
+	ch, err := fns.Channel(filter.Channel)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "channel [%s] of fabric network [%s] not found: %s", filter.Channel, fns.Name(), err)
+	}
+	service := sinks.GetService(s.sp)
+	if service == nil {
+		return status.Error(codes.Unavailable, "event subscriptions not available")
+	}
+	sub, err := service.Subscribe(fns.Name(), ch.Name(), sinks.Filter{Types: filter.Kinds, Namespaces: filter.Namespaces, Events: filter.Names}, c.ResumeToken)
+	if err != nil {
+		return subscriptionStatus(err)
+	}
+	defer sub.Close()
+	logger.Debugf("identity [%s] subscribed to the events of [%s:%s]", view.Identity(command.Header.Creator), fns.Name(), ch.Name())
+
+	for {
+		e, token, err := sub.Next(commandServer.Context())
+		if err != nil {
+			if commandServer.Context().Err() != nil {
+				// the client disconnected
+				return nil
+			}
+			return subscriptionStatus(err)
+		}
+		r, err := marshaler.MarshalCommandResponse(sc.Command, &protos2.CommandResponse_Event{Event: &protos2.Event{
+			Kind:        e.Type,
+			Network:     e.Network,
+			Channel:     e.Channel,
+			Txid:        e.TxID,
+			Block:       e.Block,
+			TxNum:       e.TxNum,
+			Namespace:   e.Namespace,
+			Name:        e.Name,
+			Payload:     e.Payload,
+			Valid:       e.Valid,
+			Namespaces:  e.Namespaces,
+			Error:       e.Error,
+			ResumeToken: token,
+		}})
+		if err != nil {
+			return errors.WithMessagef(err, "failed marshalling event of [%s]", e.TxID)
+		}
+		if err := commandServer.Send(r); err != nil {
+			if commandServer.Context().Err() != nil {
+				return nil
+			}
+			return errors.Wrapf(err, "failed sending event of [%s]", e.TxID)
+		}
+	}
+}
+
+func (s *eventsStreamer) allowed(network string, creator view.Identity) bool {
+	for _, subscriber := range s.subscribers[network] {
+		if subscriber.Equal(creator) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionStatus returns the gRPC status ending the stream of a subscription for the passed error
+func subscriptionStatus(err error) error {
+	lagging := &sinks.ErrLagging{}
+	expired := &sinks.ErrTokenExpired{}
+	invalid := &sinks.ErrInvalidToken{}
+	switch {
+	case errors.As(err, &lagging):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.As(err, &expired):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.As(err, &invalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+)
+
+const (
+	// IntegrityURI is the URI, relative to the web server API, of the integrity check of the namespaces of a vault
+	IntegrityURI = "/fabric/{Network}/{Channel}/vault/integrity"
+	// IntegrityRepairURI is the URI, relative to the web server API, of the repair of a corrupted namespace of a vault
+	IntegrityRepairURI = "/fabric/{Network}/{Channel}/vault/integrity/{Namespace}/repair"
+)
+
+// integrityHandler verifies the namespaces of the vault of a channel against their checksum and returns the report,
+// listing the namespaces corrupted
+type integrityHandler struct {
+	quotasHandler
+}
+
+func (h *integrityHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel := context.Vars["Network"], context.Vars["Channel"]
+	ch, reason, status := h.channel(network, channel)
+	if ch == nil {
+		return reason, status
+	}
+	report, err := ch.Vault().VerifyIntegrity()
+	if err != nil {
+		logger.Errorf("failed verifying the integrity of [%s:%s]: [%s]", network, channel, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+	return report, http.StatusOK
+}
+
+// repairIntegrityHandler repairs a corrupted namespace of the vault of a channel: the namespace is re-derived from
+// the blocks of the peers, up to the height of the vault, and its checksum recomputed. It answers with the integrity
+// check of the namespace once repaired.
+type repairIntegrityHandler struct {
+	quotasHandler
+}
+
+func (h *repairIntegrityHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel, namespace := context.Vars["Network"], context.Vars["Channel"], context.Vars["Namespace"]
+	ch, reason, status := h.channel(network, channel)
+	if ch == nil {
+		return reason, status
+	}
+	integrity, err := ch.Vault().RederiveNamespace(namespace)
+	if err != nil {
+		logger.Errorf("failed repairing [%s:%s:%s]: [%s]", network, channel, namespace, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+	logger.Infof("namespace [%s:%s:%s] re-derived by the admin API: [%d] keys, [%s]", network, channel, namespace, integrity.Keys, integrity.Status)
+	return integrity, http.StatusOK
+}
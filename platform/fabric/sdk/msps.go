@@ -0,0 +1,46 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+)
+
+// MSPSnapshotsURI is the URI, relative to the web server API, of the debug snapshots of the local MSPs of a network
+const MSPSnapshotsURI = "/fabric/{Network}/msps/snapshots"
+
+// mspSnapshotsHandler returns the debug snapshots of the local MSPs of a network, by name.
+// The query parameter msp selects the snapshot of a single MSP.
+type mspSnapshotsHandler struct {
+	sp Registry
+}
+
+func (h *mspSnapshotsHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *mspSnapshotsHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	fns := fabric.GetFabricNetworkService(h.sp, context.Vars["Network"])
+	if fns == nil {
+		return &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+	}
+	snapshots := fns.LocalMembership().Snapshots()
+	if snapshots == nil {
+		return &web.ResponseErr{Reason: "snapshots not supported by the local membership"}, http.StatusNotImplemented
+	}
+	if name := context.Req.URL.Query().Get("msp"); len(name) != 0 {
+		snapshot, ok := snapshots[name]
+		if !ok {
+			return &web.ResponseErr{Reason: "msp not found"}, http.StatusNotFound
+		}
+		return snapshot, http.StatusOK
+	}
+	return snapshots, http.StatusOK
+}
@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+const (
+	// QuotasURI is the URI, relative to the web server API, of the usage of the namespaces of a vault
+	QuotasURI = "/fabric/{Network}/{Channel}/vault/quotas"
+	// QuotaURI is the URI, relative to the web server API, of the quota of a namespace of a vault
+	QuotaURI = "/fabric/{Network}/{Channel}/vault/quotas/{Namespace}"
+)
+
+// quotasHandler returns the approximate storage taken by the namespaces of the vault of a channel, with their quota
+type quotasHandler struct {
+	sp Registry
+}
+
+func (h *quotasHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *quotasHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel := context.Vars["Network"], context.Vars["Channel"]
+	ch, reason, status := h.channel(network, channel)
+	if ch == nil {
+		return reason, status
+	}
+	usage, err := ch.Vault().NamespaceUsage()
+	if err != nil {
+		logger.Errorf("failed reading the usage of the namespaces of [%s:%s]: [%s]", network, channel, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+	return usage, http.StatusOK
+}
+
+func (h *quotasHandler) channel(network, channel string) (*fabric.Channel, *web.ResponseErr, int) {
+	fns := fabric.GetFabricNetworkService(h.sp, network)
+	if fns == nil {
+		return nil, &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return nil, &web.ResponseErr{Reason: "channel not found"}, http.StatusNotFound
+	}
+	return ch, nil, http.StatusOK
+}
+
+// setQuotaHandler sets, until the node restarts, the quota of a namespace of the vault of a channel.
+// The body is a fabric.NamespaceQuota, an empty body removes the quota.
+type setQuotaHandler struct {
+	quotasHandler
+}
+
+func (h *setQuotaHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	quota := &fabric.NamespaceQuota{}
+	if len(bytes) == 0 {
+		return quota, nil
+	}
+	if err := json.Unmarshal(bytes, quota); err != nil {
+		return nil, errors.Wrapf(err, "invalid quota")
+	}
+	return quota, nil
+}
+
+func (h *setQuotaHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel, namespace := context.Vars["Network"], context.Vars["Channel"], context.Vars["Namespace"]
+	ch, reason, status := h.channel(network, channel)
+	if ch == nil {
+		return reason, status
+	}
+	if err := ch.Vault().SetNamespaceQuota(namespace, *context.Query.(*fabric.NamespaceQuota)); err != nil {
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusBadRequest
+	}
+	logger.Infof("quota of [%s:%s:%s] set by the admin API", network, channel, namespace)
+	return context.Query, http.StatusOK
+}
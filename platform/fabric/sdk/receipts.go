@@ -0,0 +1,51 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+// ReceiptURI is the URI, relative to the web server API, of the issuance of the receipt of a transaction of a channel
+const ReceiptURI = "/fabric/{Network}/{Channel}/transactions/{TxID}/receipt"
+
+// receiptHandler issues the receipt of a transaction, signed by the default identity of this node.
+// The receipt is returned as is, see services/receipt to verify it. The transactions not final are refused.
+type receiptHandler struct {
+	sp Registry
+}
+
+func (h *receiptHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *receiptHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel, txID := context.Vars["Network"], context.Vars["Channel"], context.Vars["TxID"]
+	fns := fabric.GetFabricNetworkService(h.sp, network)
+	if fns == nil {
+		return &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return &web.ResponseErr{Reason: "channel not found"}, http.StatusNotFound
+	}
+
+	receipt, err := ch.IssueReceipt(txID)
+	if err != nil {
+		if errors.Is(err, fabric.ErrNotFinal) {
+			return &web.ResponseErr{Reason: err.Error()}, http.StatusConflict
+		}
+		logger.Errorf("failed issuing the receipt of [%s:%s:%s]: [%s]", network, channel, txID, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+	logger.Infof("receipt of [%s:%s:%s] issued, status [%s]", network, channel, txID, codeNames[fabric.ValidationCode(receipt.Code)])
+	return receipt, http.StatusOK
+}
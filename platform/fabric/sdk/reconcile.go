@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/reconciliation"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+// ReconcileURI is the URI, relative to the web server API, of the reconciliation of a namespace of a vault against the peers
+const ReconcileURI = "/fabric/{Network}/{Channel}/vault/reconcile/{Namespace}"
+
+// ReconcileRequest is the JSON body, optional, of a reconciliation request
+type ReconcileRequest struct {
+	StartKey string `json:"startKey,omitempty"`
+	EndKey   string `json:"endKey,omitempty"`
+	PageSize int    `json:"pageSize,omitempty"`
+	Repair   bool   `json:"repair,omitempty"`
+	// Function is the chaincode function returning the pages of states, reconciliation.DefaultQueryFunction if empty
+	Function string `json:"function,omitempty"`
+}
+
+// reconcileHandler reconciles a namespace of the vault of a channel against the chaincode of the same name,
+// and returns the drift report. It answers with 429 if a reconciliation is already running on the channel.
+type reconcileHandler struct {
+	sp Registry
+}
+
+func (h *reconcileHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	req := &ReconcileRequest{}
+	if len(bytes) == 0 {
+		return req, nil
+	}
+	if err := json.Unmarshal(bytes, req); err != nil {
+		return nil, errors.Wrapf(err, "invalid reconciliation request")
+	}
+	return req, nil
+}
+
+func (h *reconcileHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel, namespace := context.Vars["Network"], context.Vars["Channel"], context.Vars["Namespace"]
+	req := context.Query.(*ReconcileRequest)
+	s, err := reconciliation.GetService(h.sp)
+	if err != nil {
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusServiceUnavailable
+	}
+
+	opts := reconciliation.Options{StartKey: req.StartKey, EndKey: req.EndKey, PageSize: req.PageSize, Repair: req.Repair}
+	if len(req.Function) != 0 {
+		fns := fabric.GetFabricNetworkService(h.sp, network)
+		if fns == nil {
+			return &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+		}
+		ch, err := fns.Channel(channel)
+		if err != nil {
+			return &web.ResponseErr{Reason: "channel not found"}, http.StatusNotFound
+		}
+		opts.Source = reconciliation.NewChaincodeSource(ch, req.Function)
+	}
+	report, err := s.Reconcile(context.Req.Context(), network, channel, namespace, opts)
+	if err != nil {
+		if errors.Is(err, reconciliation.ErrAlreadyRunning) {
+			return &web.ResponseErr{Reason: err.Error()}, http.StatusTooManyRequests
+		}
+		logger.Errorf("failed reconciling [%s:%s:%s]: [%s]", network, channel, namespace, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+	return report, http.StatusOK
+}
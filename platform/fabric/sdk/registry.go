@@ -0,0 +1,72 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/pkg/errors"
+)
+
+// RegistrySection is the name of the introspection section describing what is registered on the fabric networks
+const RegistrySection = "fabric"
+
+// NetworkRegistration describes a fabric network and its configured channels, ReadOnly is true if this node
+// is a read-only replica on the network
+type NetworkRegistration struct {
+	Name           string                `json:"name"`
+	DefaultChannel string                `json:"defaultChannel"`
+	ReadOnly       bool                  `json:"readOnly"`
+	Channels       []ChannelRegistration `json:"channels"`
+	// Compatibility is the outcome of the compatibility checks, nil if the network does not check its components
+	Compatibility *fabric.CompatibilityReport `json:"compatibility,omitempty"`
+}
+
+// ChannelRegistration describes what is bound to a channel, Error is set if the channel cannot be described
+type ChannelRegistration struct {
+	Name string `json:"name"`
+	*fabric.ChannelDescription
+	Error string `json:"error,omitempty"`
+}
+
+// Registrations is the description of the fabric section: the networks, and the namespaces registered in the vaults
+type Registrations struct {
+	Networks   []NetworkRegistration         `json:"networks"`
+	Namespaces []fabric.NamespaceDescription `json:"namespaces"`
+}
+
+// registrations returns the description of the fabric networks and of the registered namespaces
+func (p *SDK) registrations() (interface{}, error) {
+	namespaces, err := fabric.GetNamespaceRegistry(p.registry)
+	if err != nil {
+		return nil, err
+	}
+	res := &Registrations{Networks: []NetworkRegistration{}, Namespaces: namespaces.Namespaces()}
+	for _, name := range fabric.GetFabricNetworkNames(p.registry) {
+		fns := fabric.GetFabricNetworkService(p.registry, name)
+		if fns == nil {
+			return nil, errors.Errorf("fabric network [%s] not found", name)
+		}
+		network := NetworkRegistration{Name: name, DefaultChannel: fns.DefaultChannel(), ReadOnly: fns.ReadOnly(), Channels: []ChannelRegistration{}, Compatibility: fns.Compatibility()}
+		for _, channel := range fns.Channels() {
+			network.Channels = append(network.Channels, describeChannel(fns, channel))
+		}
+		res.Networks = append(res.Networks, network)
+	}
+	return res, nil
+}
+
+func describeChannel(fns *fabric.NetworkService, name string) ChannelRegistration {
+	res := ChannelRegistration{Name: name}
+	ch, err := fns.Channel(name)
+	if err == nil {
+		res.ChannelDescription, err = ch.Describe()
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+// ResolveTransactionURI is the URI, relative to the web server API, of the resolution of a transaction of a channel stuck in the Busy status
+const ResolveTransactionURI = "/fabric/{Network}/{Channel}/transactions/{TxID}/resolve"
+
+// auditLogger records the administrative changes to the status of the transactions
+var auditLogger = flogging.MustGetLogger("fabric-sdk.audit")
+
+// ResolveTransactionRequest is the JSON body of a resolution request
+type ResolveTransactionRequest struct {
+	// MinAge is how long the transaction must have been busy before it can be abandoned, in the format of time.ParseDuration
+	MinAge string `json:"minAge"`
+}
+
+// Resolution is the JSON representation of a fabric.Resolution
+type Resolution struct {
+	TxID     string  `json:"txid"`
+	Previous string  `json:"previous"`
+	Code     string  `json:"code"`
+	Action   string  `json:"action"`
+	Block    *uint64 `json:"block,omitempty"`
+	TxNum    *int    `json:"txNum,omitempty"`
+	Age      string  `json:"age,omitempty"`
+}
+
+// resolveTransactionHandler resolves a transaction stuck in the Busy status: the transaction is repaired if the ledger
+// of the peers has it, abandoned otherwise, once older than the requested age. Each resolution is audited.
+type resolveTransactionHandler struct {
+	sp Registry
+}
+
+func (h *resolveTransactionHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	req := &ResolveTransactionRequest{}
+	if err := json.Unmarshal(bytes, req); err != nil {
+		return nil, errors.Wrapf(err, "invalid resolution request")
+	}
+	if len(req.MinAge) == 0 {
+		return nil, errors.New("invalid resolution request, minAge is required")
+	}
+	return req, nil
+}
+
+func (h *resolveTransactionHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel, txID := context.Vars["Network"], context.Vars["Channel"], context.Vars["TxID"]
+	req := context.Query.(*ResolveTransactionRequest)
+	minAge, err := time.ParseDuration(req.MinAge)
+	if err != nil || minAge < 0 {
+		return &web.ResponseErr{Reason: "invalid minAge [" + req.MinAge + "]"}, http.StatusBadRequest
+	}
+	fns := fabric.GetFabricNetworkService(h.sp, network)
+	if fns == nil {
+		return &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return &web.ResponseErr{Reason: "channel not found"}, http.StatusNotFound
+	}
+
+	caller := context.Req.RemoteAddr
+	if context.Req.TLS != nil && len(context.Req.TLS.PeerCertificates) != 0 {
+		caller = context.Req.TLS.PeerCertificates[0].Subject.String() + "@" + caller
+	}
+	res, err := ch.ResolveStuckTransaction(txID, fabric.ResolutionPolicy{MinAge: minAge})
+	if err != nil {
+		auditLogger.Warnf("resolution of [%s:%s:%s] requested by [%s] with min age [%s] refused: [%s]", network, channel, txID, caller, minAge, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusConflict
+	}
+	previous, code := codeNames[fabric.ValidationCode(res.Previous)], codeNames[fabric.ValidationCode(res.Code)]
+	auditLogger.Infof("resolution of [%s:%s:%s] requested by [%s] with min age [%s]: [%s], status [%s] -> [%s]", network, channel, txID, caller, minAge, res.Action, previous, code)
+
+	out := &Resolution{TxID: res.TxID, Previous: previous, Code: code, Action: res.Action}
+	if res.Block != fabric.UnknownBlock {
+		out.Block, out.TxNum = &res.Block, &res.TxNum
+	}
+	if res.Age > 0 {
+		out.Age = res.Age.String()
+	}
+	return out, http.StatusOK
+}
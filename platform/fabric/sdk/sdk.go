@@ -8,17 +8,36 @@ package fabric
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic"
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/x509"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/sinks"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/crypto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/identities"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/reconciliation"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/state"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/state/vault"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/statediff"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/statesharing"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/weaver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/assert"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/introspection"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/metrics"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/metrics/operations"
+	view3 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view"
+	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/tracker"
 	"github.com/pkg/errors"
 )
@@ -39,6 +58,40 @@ type Startable interface {
 type SDK struct {
 	registry    Registry
 	fnsProvider Startable
+	// started is set to 1 once the delivery pipelines of the networks have been started
+	started int32
+}
+
+// networkChecker reports a fabric network as healthy once its delivery pipeline has been started,
+// and as long as at least one of its orderers passed the pre-flight check, if any.
+// The orderers are not checked on the read-only networks, they are never contacted.
+type networkChecker struct {
+	sdk  *SDK
+	name string
+}
+
+func (n *networkChecker) HealthCheck(ctx context.Context) error {
+	if atomic.LoadInt32(&n.sdk.started) == 0 {
+		return errors.Errorf("fabric network [%s] not started", n.name)
+	}
+	fns := fabric.GetFabricNetworkService(n.sdk.registry, n.name)
+	if fns == nil {
+		return errors.Errorf("no fabric network service found for [%s]", n.name)
+	}
+	if fns.ReadOnly() {
+		return nil
+	}
+	statuses := fns.Ordering().Statuses()
+	var degraded []string
+	for _, status := range statuses {
+		if status.Degraded {
+			degraded = append(degraded, fmt.Sprintf("%s: %s", status.Address, status.Err))
+		}
+	}
+	if len(statuses) > 0 && len(degraded) == len(statuses) {
+		return errors.Errorf("no orderer of fabric network [%s] passed the pre-flight check [%s]", n.name, strings.Join(degraded, "; "))
+	}
+	return nil
 }
 
 func NewSDK(registry Registry) *SDK {
@@ -55,6 +108,19 @@ func (p *SDK) Install() error {
 	cryptoProvider := crypto.NewProvider()
 	assert.NoError(p.registry.RegisterService(cryptoProvider))
 
+	// export of the events to the sinks configured for the channels, the channels find it when they are opened
+	sinksService := sinks.NewService(kvs.GetService(p.registry))
+	assert.NoError(p.registry.RegisterService(sinksService))
+
+	// the metrics and the commit cap shared by the networks, they find them when they are created
+	resources := generic.NewResources(
+		metrics.GetProvider(p.registry),
+		view.GetConfigService(p.registry).GetInt("fabric.committer.parallelism"),
+	)
+	assert.NoError(p.registry.RegisterService(resources))
+	// the MSPs of all the networks record their re-enrollments in the same metrics
+	assert.NoError(p.registry.RegisterService(x509.NewRenewalMetrics(metrics.GetProvider(p.registry))))
+
 	logger.Infof("Set Fabric Network Service Provider")
 	fnsConfig, err := core.NewConfig(view.GetConfigService(p.registry))
 	assert.NoError(err, "failed parsing configuration")
@@ -62,6 +128,8 @@ func (p *SDK) Install() error {
 	assert.NoError(err, "failed instantiating fabric network service provider")
 	assert.NoError(p.registry.RegisterService(p.fnsProvider))
 	assert.NoError(p.registry.RegisterService(fabric.NewNetworkServiceProvider(p.registry)))
+	assert.NoError(p.registry.RegisterService(fabric.NewEventTypeRegistry(metrics.GetProvider(p.registry))))
+	assert.NoError(p.registry.RegisterService(fabric.NewNamespaceRegistry()))
 
 	// Register processors
 	names := fabric.GetFabricNetworkNames(p.registry)
@@ -88,6 +156,73 @@ func (p *SDK) Install() error {
 	// weaver provider
 	assert.NoError(p.registry.RegisterService(weaver.NewProvider()))
 
+	// reconciliation of the vaults against the peers, the queries are paced by `fabric.reconciliation.pageInterval`
+	pageInterval := reconciliation.DefaultPageInterval
+	if cs := view.GetConfigService(p.registry); cs.IsSet("fabric.reconciliation.pageInterval") {
+		pageInterval = cs.GetDuration("fabric.reconciliation.pageInterval")
+	}
+	assert.NoError(p.registry.RegisterService(reconciliation.NewService(p.registry, pageInterval)))
+
+	// sharing of vault namespaces with other nodes, the shares journal `fabric.stateSharing.journalSize` transactions
+	journalSize := view.GetConfigService(p.registry).GetInt("fabric.stateSharing.journalSize")
+	assert.NoError(p.registry.RegisterService(statesharing.NewService(p.registry, journalSize)))
+	assert.NoError(view.GetRegistry(p.registry).RegisterResponder(&statesharing.SyncResponderView{}, &statesharing.SyncView{}))
+
+	// reconciliation of vault namespaces with other nodes, each message is awaited up to `fabric.stateDiff.timeout`
+	diffTimeout := view.GetConfigService(p.registry).GetDuration("fabric.stateDiff.timeout")
+	assert.NoError(p.registry.RegisterService(statediff.NewService(p.registry, diffTimeout)))
+	assert.NoError(view.GetRegistry(p.registry).RegisterResponder(&statediff.ReconcileResponderView{}, &statediff.ReconcileView{}))
+
+	// ephemeral identities, scoped to the flows
+	assert.NoError(p.registry.RegisterService(identities.NewService(p.registry, kvs.GetService(p.registry), view.GetSigService(p.registry))))
+
+	// health checkers
+	if s, err := p.registry.GetService(reflect.TypeOf((*operations.System)(nil))); err == nil {
+		for _, name := range names {
+			assert.NoError(s.(*operations.System).RegisterChecker("fabric."+name, &networkChecker{sdk: p, name: name}),
+				"failed registering health checker for fabric network [%s]", name)
+		}
+		assert.NoError(s.(*operations.System).RegisterChecker("fabric.sinks", sinksService), "failed registering health checker for event sinks")
+	} else {
+		logger.Debugf("operations system not available, skip registering health checkers [%s]", err)
+	}
+
+	// subscriptions of the external clients to the events of the channels, over the view service
+	if s, err := p.registry.GetService(reflect.TypeOf((*view3.Service)(nil))); err == nil {
+		streamer, err := newEventsStreamer(p.registry, names, fnsConfig.DefaultName())
+		assert.NoError(err, "failed loading the event subscribers")
+		s.(view3.Service).RegisterStreamer(reflect.TypeOf(&protos2.Command_SubscribeEvents{}), streamer.stream)
+	} else {
+		logger.Debugf("view service not available, skip registering the event subscriptions [%s]", err)
+	}
+
+	// the block commits are drained after the flows, see the drain service of the view sdk
+	if s := drain.GetService(p.registry); s != nil {
+		s.Register("fabric", newCommitters(p.registry))
+	}
+
+	// description of the registrations, served by the view sdk
+	if s := introspection.GetService(p.registry); s != nil {
+		assert.NoError(s.RegisterSection(RegistrySection, p.registrations), "failed registering fabric introspection section")
+	}
+
+	// admin endpoints, the web handler is available only if the web server is enabled
+	if h, err := p.registry.GetService(reflect.TypeOf((*web.HttpHandler)(nil))); err == nil {
+		h.(*web.HttpHandler).RegisterURI(StatusesURI, "GET", &statusesHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(ReconcileURI, "POST", &reconcileHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(SimulateConfigUpdateURI, "POST", &simulateConfigUpdateHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(TransactionURI, "GET", &transactionHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(ResolveTransactionURI, "POST", &resolveTransactionHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(ReceiptURI, "GET", &receiptHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(MSPSnapshotsURI, "GET", &mspSnapshotsHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(QuotasURI, "GET", &quotasHandler{sp: p.registry})
+		h.(*web.HttpHandler).RegisterURI(QuotaURI, "POST", &setQuotaHandler{quotasHandler{sp: p.registry}})
+		h.(*web.HttpHandler).RegisterURI(IntegrityURI, "GET", &integrityHandler{quotasHandler{sp: p.registry}})
+		h.(*web.HttpHandler).RegisterURI(IntegrityRepairURI, "POST", &repairIntegrityHandler{quotasHandler{sp: p.registry}})
+	} else {
+		logger.Debugf("web handler not available, skip registering admin endpoints [%s]", err)
+	}
+
 	return nil
 }
 
@@ -106,12 +241,16 @@ func (p *SDK) PostStart(ctx context.Context) error {
 	assert.NoError(err, "failed parsing configuration")
 
 	fnsConfig.Names()
+	p.migrateNamespaces()
+	p.collectEphemeralIdentities()
 	if err := p.fnsProvider.Start(ctx); err != nil {
 		return errors.WithMessagef(err, "failed starting fabric network service provider")
 	}
+	atomic.StoreInt32(&p.started, 1)
 
 	go func() {
 		<-ctx.Done()
+		atomic.StoreInt32(&p.started, 0)
 		if err := p.fnsProvider.Stop(); err != nil {
 			logger.Errorf("failed stopping fabric network service provider [%s]", err)
 		}
@@ -119,3 +258,41 @@ func (p *SDK) PostStart(ctx context.Context) error {
 
 	return nil
 }
+
+// collectEphemeralIdentities disposes the ephemeral identities a previous run of this node could not dispose
+func (p *SDK) collectEphemeralIdentities() {
+	s, err := identities.GetService(p.registry)
+	if err != nil {
+		logger.Debugf("ephemeral identities service not available, skip collection [%s]", err)
+		return
+	}
+	if _, err := s.Collect(); err != nil {
+		logger.Errorf("failed disposing leaked ephemeral identities, they will be retried at the next startup [%s]", err)
+	}
+}
+
+// migrateNamespaces runs the pending migrations of the registered vault namespaces on all the channels,
+// before the delivery starts. The namespaces whose migration fails are not served.
+func (p *SDK) migrateNamespaces() {
+	registry, err := fabric.GetNamespaceRegistry(p.registry)
+	if err != nil {
+		logger.Debugf("namespace registry not available, skip migrations [%s]", err)
+		return
+	}
+	for _, name := range fabric.GetFabricNetworkNames(p.registry) {
+		fns := fabric.GetFabricNetworkService(p.registry, name)
+		if fns == nil {
+			continue
+		}
+		for _, channel := range fns.Channels() {
+			ch, err := fns.Channel(channel)
+			if err != nil {
+				logger.Errorf("failed getting channel [%s:%s], skip migrations [%s]", name, channel, err)
+				continue
+			}
+			if _, err := registry.MigrateChannel(ch, false); err != nil {
+				logger.Errorf("failed migrating vault namespaces, they will not be served [%s]", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+// StatusesURI is the URI, relative to the web server API, of the listing of the transaction statuses of a vault
+const StatusesURI = "/fabric/{Network}/{Channel}/vault/statuses"
+
+var codeNames = map[fabric.ValidationCode]string{
+	fabric.Valid:           "valid",
+	fabric.Invalid:         "invalid",
+	fabric.Busy:            "busy",
+	fabric.Unknown:         "unknown",
+	fabric.HasDependencies: "hasDependencies",
+	fabric.Abandoned:       "abandoned",
+}
+
+// TxStatus is the JSON representation of a fabric.TxStatus
+type TxStatus struct {
+	TxID      string    `json:"txid"`
+	Code      string    `json:"code"`
+	Block     *uint64   `json:"block,omitempty"`
+	TxNum     *int      `json:"txNum,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// StatusPage is the JSON representation of a fabric.StatusPage
+type StatusPage struct {
+	Statuses []TxStatus `json:"statuses"`
+	Next     string     `json:"next,omitempty"`
+}
+
+// statusesHandler lists the transaction statuses of the vault of a channel.
+// The query parameters select the statuses:
+//   - code, repeated or comma separated, among valid, invalid, busy, unknown, and hasDependencies;
+//   - fromBlock and toBlock, the inclusive range of blocks;
+//   - since and until, the inclusive range of time, in RFC3339 format;
+//   - limit, the size of the page, and page, the token of the page returned as next by the previous request.
+type statusesHandler struct {
+	sp Registry
+}
+
+func (h *statusesHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *statusesHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel := context.Vars["Network"], context.Vars["Channel"]
+	fns := fabric.GetFabricNetworkService(h.sp, network)
+	if fns == nil {
+		return &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return &web.ResponseErr{Reason: "channel not found"}, http.StatusNotFound
+	}
+
+	filter, page, err := parseStatusQuery(context.Req.URL.Query())
+	if err != nil {
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusBadRequest
+	}
+	res, err := ch.Vault().ListStatuses(*filter, page)
+	if err != nil {
+		logger.Errorf("failed listing statuses of [%s:%s]: [%s]", network, channel, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+
+	out := &StatusPage{Statuses: []TxStatus{}, Next: string(res.Next)}
+	for _, s := range res.Statuses {
+		status := TxStatus{TxID: s.TxID, Code: codeNames[s.Code], Timestamp: s.Timestamp}
+		if s.Block != fabric.UnknownBlock {
+			block, txNum := s.Block, s.TxNum
+			status.Block, status.TxNum = &block, &txNum
+		}
+		out.Statuses = append(out.Statuses, status)
+	}
+	return out, http.StatusOK
+}
+
+func parseStatusQuery(query url.Values) (*fabric.StatusFilter, fabric.PageToken, error) {
+	filter := &fabric.StatusFilter{}
+	for _, value := range query["code"] {
+		for _, name := range strings.Split(value, ",") {
+			code, err := parseCode(name)
+			if err != nil {
+				return nil, "", err
+			}
+			filter.Codes = append(filter.Codes, code)
+		}
+	}
+	for _, b := range []struct {
+		name  string
+		block **uint64
+	}{{"fromBlock", &filter.FromBlock}, {"toBlock", &filter.ToBlock}} {
+		if value := query.Get(b.name); len(value) != 0 {
+			block, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, "", errors.Errorf("invalid %s [%s]", b.name, value)
+			}
+			*b.block = &block
+		}
+	}
+	for _, t := range []struct {
+		name string
+		time *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if value := query.Get(t.name); len(value) != 0 {
+			ts, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, "", errors.Errorf("invalid %s [%s], expected RFC3339 format", t.name, value)
+			}
+			*t.time = ts
+		}
+	}
+	if value := query.Get("limit"); len(value) != 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, "", errors.Errorf("invalid limit [%s]", value)
+		}
+		filter.Limit = limit
+	}
+	return filter, fabric.PageToken(query.Get("page")), nil
+}
+
+func parseCode(name string) (fabric.ValidationCode, error) {
+	for code, n := range codeNames {
+		if strings.EqualFold(n, name) {
+			return code, nil
+		}
+	}
+	return 0, errors.Errorf("invalid code [%s]", name)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+)
+
+// TransactionURI is the URI, relative to the web server API, of the inspection of a transaction of a channel
+const TransactionURI = "/fabric/{Network}/{Channel}/transactions/{TxID}"
+
+// Transaction is the JSON representation of the state of a transaction known to this node
+type Transaction struct {
+	TxID string `json:"txid"`
+	// Code is the status of the transaction in the vault
+	Code         string   `json:"code"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	// Provenance is nil if the transaction has not been endorsed nor broadcast by this node
+	Provenance *fabric.Provenance `json:"provenance,omitempty"`
+}
+
+// transactionHandler returns the status of a transaction in the vault of a channel, and the provenance
+// recorded by this node: its endorsers and the orderer that acknowledged its broadcast.
+type transactionHandler struct {
+	sp Registry
+}
+
+func (h *transactionHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *transactionHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	network, channel, txID := context.Vars["Network"], context.Vars["Channel"], context.Vars["TxID"]
+	fns := fabric.GetFabricNetworkService(h.sp, network)
+	if fns == nil {
+		return &web.ResponseErr{Reason: "network not found"}, http.StatusNotFound
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return &web.ResponseErr{Reason: "channel not found"}, http.StatusNotFound
+	}
+
+	code, deps, err := ch.Vault().Status(txID)
+	if err != nil {
+		logger.Errorf("failed getting the status of [%s:%s:%s]: [%s]", network, channel, txID, err)
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+	out := &Transaction{TxID: txID, Code: codeNames[code], Dependencies: deps}
+	if provenance, err := ch.GetTransactionProvenance(txID); err == nil {
+		out.Provenance = provenance
+	} else {
+		logger.Debugf("no provenance for [%s:%s:%s]: [%s]", network, channel, txID, err)
+	}
+	if code == fabric.Unknown && out.Provenance == nil {
+		return &web.ResponseErr{Reason: "transaction not found"}, http.StatusNotFound
+	}
+	return out, http.StatusOK
+}
@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabric
+
+import (
+	"context"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
+)
+
+// Validate checks the configuration of the fabric networks without installing the platform, see core.Validate
+func (p *SDK) Validate(ctx context.Context, config driver.ConfigService, opts *dryrun.Options, report *dryrun.Report) {
+	if !config.GetBool("fabric.enabled") {
+		logger.Infof("Fabric platform not enabled, skipping validation")
+		return
+	}
+	core.Validate(ctx, p.registry, config, opts, report)
+}
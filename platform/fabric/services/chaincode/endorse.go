@@ -86,7 +86,9 @@ func (i *endorseChaincodeView) Endorse(context view.Context) (*fabric.Envelope,
 		invocation.WithRetrySleep(i.RetrySleep)
 	}
 
+	endorsed := view.TrackPhase(context.Context(), view.PhaseEndorsement)
 	envelope, err := invocation.Call()
+	endorsed()
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +97,7 @@ func (i *invokeChaincodeView) Invoke(context view.Context) (string, []byte, erro
 	if i.SetRetrySleep {
 		invocation.WithRetrySleep(i.RetrySleep)
 	}
+	invocation.WithContext(context.Context())
 
 	txid, result, err := invocation.Submit()
 	if err != nil {
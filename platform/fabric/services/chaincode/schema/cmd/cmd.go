@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns the Cobra Command generating the Go bindings of a chaincode from its schema
+func NewCmd() *cobra.Command {
+	var schemaFile, pkg, clientFile, stubsFile string
+	cmd := &cobra.Command{
+		Use:   "ccbind",
+		Short: "Generate the Go bindings of a chaincode.",
+		Long: `Generate, from the YAML schema of the functions of a chaincode, the typed client the views invoke the chaincode with,
+and the validation stubs the chaincode dispatches its invocations with.
+Run from go:generate, the package defaults to the one of the file declaring the directive.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(schemaFile) == 0 {
+				return errors.New("the schema must be set")
+			}
+			if len(clientFile) == 0 && len(stubsFile) == 0 {
+				return errors.New("the client or the stubs output must be set")
+			}
+			if len(pkg) == 0 {
+				pkg = os.Getenv("GOPACKAGE")
+			}
+			if len(pkg) == 0 {
+				return errors.New("the package must be set")
+			}
+			s, err := schema.Load(schemaFile)
+			if err != nil {
+				return err
+			}
+			source := filepath.Base(schemaFile)
+			if len(clientFile) != 0 {
+				if err := write(clientFile, func() ([]byte, error) { return schema.GenerateClient(s, pkg, source) }); err != nil {
+					return err
+				}
+			}
+			if len(stubsFile) != 0 {
+				if err := write(stubsFile, func() ([]byte, error) { return schema.GenerateStubs(s, pkg, source) }); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&schemaFile, "schema", "s", "", "Sets the YAML schema of the chaincode")
+	flags.StringVarP(&pkg, "package", "p", "", "Sets the package of the generated files, default $GOPACKAGE")
+	flags.StringVarP(&clientFile, "client", "c", "", "Sets the output file of the client")
+	flags.StringVarP(&stubsFile, "stubs", "t", "", "Sets the output file of the validation stubs")
+	return cmd
+}
+
+func write(path string, generate func() ([]byte, error)) error {
+	raw, err := generate()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		return errors.Wrapf(err, "failed writing [%s]", path)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Encode returns the chaincode argument, or result, of the passed value: the strings and the byte slices as they are,
+// the booleans and the numbers in decimal text, the other values in JSON
+func Encode(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case bool:
+		return []byte(strconv.FormatBool(v)), nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case int32:
+		return []byte(strconv.FormatInt(int64(v), 10)), nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), nil
+	case uint:
+		return []byte(strconv.FormatUint(uint64(v), 10)), nil
+	case uint32:
+		return []byte(strconv.FormatUint(uint64(v), 10)), nil
+	case uint64:
+		return []byte(strconv.FormatUint(v, 10)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling [%T]", v)
+	}
+	return raw, nil
+}
+
+// Decode decodes the passed chaincode argument, or result, encoded by Encode, into the value pointed by target
+func Decode(raw []byte, target interface{}) error {
+	var err error
+	switch t := target.(type) {
+	case *string:
+		*t = string(raw)
+	case *[]byte:
+		*t = append([]byte(nil), raw...)
+	case *bool:
+		*t, err = strconv.ParseBool(string(raw))
+	case *int:
+		*t, err = strconv.Atoi(string(raw))
+	case *int32:
+		var v int64
+		v, err = strconv.ParseInt(string(raw), 10, 32)
+		*t = int32(v)
+	case *int64:
+		*t, err = strconv.ParseInt(string(raw), 10, 64)
+	case *uint:
+		var v uint64
+		v, err = strconv.ParseUint(string(raw), 10, 0)
+		*t = uint(v)
+	case *uint32:
+		var v uint64
+		v, err = strconv.ParseUint(string(raw), 10, 32)
+		*t = uint32(v)
+	case *uint64:
+		*t, err = strconv.ParseUint(string(raw), 10, 64)
+	case *float64:
+		*t, err = strconv.ParseFloat(string(raw), 64)
+	default:
+		err = json.Unmarshal(raw, target)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed decoding [%T]", target)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateClient returns the Go source, in the passed package, of the typed client of the chaincode described by the
+// passed schema, read from source. The client embeds the schema, and is built on chaincode.Typed.
+func GenerateClient(s *Schema, pkg, source string) ([]byte, error) {
+	return generate(clientTemplate, s, pkg, source)
+}
+
+// GenerateStubs returns the Go source, in the passed package, of the validation stubs of the chaincode described by
+// the passed schema, read from source: the interface of the functions of the latest schema version, and the dispatch
+// of the invocations decoding and validating their arguments. The stubs depend on this package only.
+func GenerateStubs(s *Schema, pkg, source string) ([]byte, error) {
+	return generate(stubsTemplate, s, pkg, source)
+}
+
+type generation struct {
+	*Schema
+	Package string
+	Source  string
+	Methods []method
+}
+
+// method is a function as generated
+type method struct {
+	*Function
+	// Current tells if the function is in the latest schema version
+	Current bool
+	// Params are the parameters of the method, Passed the arguments passed on
+	Params string
+	Passed string
+}
+
+func generate(t *template.Template, s *Schema, pkg, source string) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, errors.Errorf("invalid package name [%s]", pkg)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	g := &generation{Schema: s, Package: pkg, Source: source}
+	for i := range s.Functions {
+		f := &s.Functions[i]
+		m := method{Function: f, Current: f.Available(s.Version)}
+		var params, args []string
+		for _, arg := range f.Args {
+			params = append(params, arg.Name+" "+arg.Type)
+			args = append(args, arg.Name)
+		}
+		m.Params = strings.Join(params, ", ")
+		if len(args) != 0 {
+			m.Passed = ", " + strings.Join(args, ", ")
+		}
+		g.Methods = append(g.Methods, m)
+	}
+	b := &bytes.Buffer{}
+	if err := t.Execute(b, g); err != nil {
+		return nil, errors.Wrapf(err, "failed generating the bindings of chaincode [%s]", s.Chaincode)
+	}
+	res, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed formatting the bindings of chaincode [%s]", s.Chaincode)
+	}
+	return res, nil
+}
+
+var funcs = template.FuncMap{
+	"quote": strconv.Quote,
+	"versions": func(f *Function) string {
+		if f.Until != 0 {
+			return fmt.Sprintf("schema versions [%d, %d)", f.Since, f.Until)
+		}
+		return fmt.Sprintf("schema version %d on", f.Since)
+	},
+	"comment": func(doc string) string {
+		doc = strings.TrimSuffix(strings.TrimSpace(doc), ".")
+		if len(doc) == 0 {
+			return ""
+		}
+		return strings.ReplaceAll(doc, "\n", "\n// ") + ".\n// "
+	},
+}
+
+const header = `// Code generated by ccbind from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+`
+
+var clientTemplate = template.Must(template.New("client").Funcs(funcs).Parse(header + `
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+{{- range .Imports}}
+	{{quote .}}
+{{- end}}
+)
+
+// Schema is the schema of the chaincode {{.Chaincode}}, up to version {{.Version}}
+var Schema = &schema.Schema{
+	Chaincode: {{quote .Chaincode}},
+	Version:   {{.Version}},
+	Functions: []schema.Function{
+{{- range .Methods}}
+		{
+			Name:   {{quote .Name}},
+			Method: {{quote .Method}},
+			{{- if .Query}}
+			Query: true,
+			{{- end}}
+			{{- if .Args}}
+			Args: []schema.Arg{
+			{{- range .Args}}
+				{Name: {{quote .Name}}, Type: {{quote .Type}}},
+			{{- end}}
+			},
+			{{- end}}
+			{{- if .Returns}}
+			Returns: {{quote .Returns}},
+			{{- end}}
+			Since: {{.Since}},
+			{{- if .Until}}
+			Until: {{.Until}},
+			{{- end}}
+		},
+{{- end}}
+	},
+}
+
+// Client invokes the functions of the chaincode {{.Chaincode}}.
+// The schema version of the deployed chaincode is queried to the chaincode, unless set with WithDeployedVersion.
+type Client struct {
+	*chaincode.Typed
+}
+
+// NewClient returns a client of the chaincode {{.Chaincode}}
+func NewClient() *Client {
+	return &Client{Typed: chaincode.NewTyped(Schema)}
+}
+
+func (c *Client) WithNetwork(name string) *Client {
+	c.Typed.WithNetwork(name)
+	return c
+}
+
+func (c *Client) WithChannel(name string) *Client {
+	c.Typed.WithChannel(name)
+	return c
+}
+
+func (c *Client) WithSignerIdentity(id view.Identity) *Client {
+	c.Typed.WithSignerIdentity(id)
+	return c
+}
+
+func (c *Client) WithDeployedVersion(version int) *Client {
+	c.Typed.WithDeployedVersion(version)
+	return c
+}
+{{range .Methods}}
+// {{.Method}} {{if .Query}}queries{{else}}invokes{{end}} the function {{.Name}}. {{comment .Doc}}Available from {{versions .Function}}.
+{{- if .Query}}
+{{- if .Returns}}
+func (c *Client) {{.Method}}(context view.Context{{if .Params}}, {{.Params}}{{end}}) (res {{.Returns}}, err error) {
+	err = c.Query(context, {{quote .Name}}, &res{{.Passed}})
+	return
+}
+{{- else}}
+func (c *Client) {{.Method}}(context view.Context{{if .Params}}, {{.Params}}{{end}}) error {
+	return c.Query(context, {{quote .Name}}, nil{{.Passed}})
+}
+{{- end}}
+{{- else}}
+{{- if .Returns}}
+func (c *Client) {{.Method}}(context view.Context{{if .Params}}, {{.Params}}{{end}}) (res {{.Returns}}, txID string, err error) {
+	txID, err = c.Invoke(context, {{quote .Name}}, &res{{.Passed}})
+	return
+}
+{{- else}}
+func (c *Client) {{.Method}}(context view.Context{{if .Params}}, {{.Params}}{{end}}) (txID string, err error) {
+	return c.Invoke(context, {{quote .Name}}, nil{{.Passed}})
+}
+{{- end}}
+{{- end}}
+{{end}}`))
+
+var stubsTemplate = template.Must(template.New("stubs").Funcs(funcs).Parse(header + `
+import (
+	"strconv"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
+	"github.com/pkg/errors"
+{{- range .Imports}}
+	{{quote .}}
+{{- end}}
+)
+
+// ContractVersion is the schema version of the chaincode {{.Chaincode}} the Contract implements
+const ContractVersion = {{.Version}}
+
+// Contract is implemented by the chaincode {{.Chaincode}}, with the functions of its schema version {{.Version}}
+type Contract interface {
+{{- range .Methods}}
+{{- if .Current}}
+	// {{.Method}} implements the function {{.Name}}
+	{{.Method}}({{.Params}}) {{if .Returns}}({{.Returns}}, error){{else}}error{{end}}
+{{- end}}
+{{- end}}
+}
+
+// Dispatch decodes and validates the arguments of the invocation of the passed function, calls the method of the
+// contract implementing it, and encodes its result. It answers schema.VersionFunction with ContractVersion.
+func Dispatch(contract Contract, function string, args [][]byte) ([]byte, error) {
+	switch function {
+	case schema.VersionFunction:
+		return []byte(strconv.Itoa(ContractVersion)), nil
+{{- range .Methods}}
+{{- if .Current}}
+	case {{quote .Name}}:
+		if len(args) != {{len .Args}} {
+			return nil, errors.Errorf("function [%s] expects [{{len .Args}}] arguments, got [%d]", function, len(args))
+		}
+	{{- range $i, $arg := .Args}}
+		var a{{$i}} {{$arg.Type}}
+		if err := schema.Decode(args[{{$i}}], &a{{$i}}); err != nil {
+			return nil, errors.WithMessagef(err, "invalid argument [{{$arg.Name}}] of [%s]", function)
+		}
+	{{- end}}
+	{{- if .Returns}}
+		res, err := contract.{{.Method}}({{range $i, $arg := .Args}}{{if $i}}, {{end}}a{{$i}}{{end}})
+		if err != nil {
+			return nil, err
+		}
+		return schema.Encode(res)
+	{{- else}}
+		return nil, contract.{{.Method}}({{range $i, $arg := .Args}}{{if $i}}, {{end}}a{{$i}}{{end}})
+	{{- end}}
+{{- end}}
+{{- end}}
+	}
+	return nil, errors.Wrapf(schema.ErrUnknownFunction, "function [%s]", function)
+}
+`))
@@ -0,0 +1,210 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package schema
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// VersionFunction is the function answered by the chaincodes dispatching with the generated stubs,
+// it returns the schema version the chaincode implements
+const VersionFunction = "_schemaVersion"
+
+var (
+	// ErrUnknownFunction is returned for the functions the schema does not declare
+	ErrUnknownFunction = errors.New("unknown function")
+	// ErrNotDeployed is returned for the functions absent from the schema version of the deployed chaincode
+	ErrNotDeployed = errors.New("function not deployed")
+)
+
+// Arg is an argument, or the result, of a function
+type Arg struct {
+	Name string `yaml:"name"`
+	// Type is a Go type. The strings and the byte slices are passed as they are, the booleans and the numbers
+	// (bool, int, int32, int64, uint, uint32, uint64, float64) in decimal text, the other types in JSON
+	Type string `yaml:"type"`
+}
+
+// Function is a function of a chaincode
+type Function struct {
+	// Name is the name the chaincode is invoked with
+	Name string `yaml:"name"`
+	// Method is the name of the generated Go method, the name in camel case if not set
+	Method string `yaml:"method,omitempty"`
+	Doc    string `yaml:"doc,omitempty"`
+	// Query tells that the function does not write, it is queried instead of invoked
+	Query   bool   `yaml:"query,omitempty"`
+	Args    []Arg  `yaml:"args,omitempty"`
+	Returns string `yaml:"returns,omitempty"`
+	// Since is the schema version the function has been added in, default 1
+	Since int `yaml:"since,omitempty"`
+	// Until is the schema version the function has been removed in, 0 if still there
+	Until int `yaml:"until,omitempty"`
+}
+
+// Available tells if the function is part of the passed schema version
+func (f *Function) Available(version int) bool {
+	return version >= f.Since && (f.Until == 0 || version < f.Until)
+}
+
+// Schema describes the functions of a chaincode, across the versions of its interface
+type Schema struct {
+	Chaincode string `yaml:"chaincode"`
+	// Version is the latest schema version, the version the generated stubs implement
+	Version int `yaml:"version"`
+	// Imports are the import paths of the packages of the types of the arguments and results, if any
+	Imports   []string   `yaml:"imports,omitempty"`
+	Functions []Function `yaml:"functions"`
+}
+
+// Load reads the schema in the passed YAML file
+func Load(path string) (*Schema, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading schema [%s]", path)
+	}
+	s, err := Parse(raw)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid schema [%s]", path)
+	}
+	return s, nil
+}
+
+// Parse parses and validates the passed YAML schema, and sets the defaults of its functions
+func Parse(raw []byte) (*Schema, error) {
+	s := &Schema{}
+	if err := yaml.UnmarshalStrict(raw, s); err != nil {
+		return nil, errors.Wrap(err, "failed unmarshalling schema")
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks the schema, and sets the defaults of its functions
+func (s *Schema) Validate() error {
+	if len(s.Chaincode) == 0 {
+		return errors.New("no chaincode name")
+	}
+	if s.Version < 1 {
+		return errors.Errorf("invalid version [%d], must be at least 1", s.Version)
+	}
+	names := map[string]struct{}{}
+	methods := map[string]struct{}{}
+	for i := range s.Functions {
+		f := &s.Functions[i]
+		if len(f.Name) == 0 || f.Name == VersionFunction {
+			return errors.Errorf("invalid function name [%s]", f.Name)
+		}
+		if _, ok := names[f.Name]; ok {
+			return errors.Errorf("duplicate function [%s]", f.Name)
+		}
+		names[f.Name] = struct{}{}
+		if len(f.Method) == 0 {
+			f.Method = methodName(f.Name)
+		}
+		if !token.IsIdentifier(f.Method) || !token.IsExported(f.Method) {
+			return errors.Errorf("function [%s]: invalid method name [%s]", f.Name, f.Method)
+		}
+		if _, ok := methods[f.Method]; ok {
+			return errors.Errorf("function [%s]: duplicate method name [%s]", f.Name, f.Method)
+		}
+		methods[f.Method] = struct{}{}
+		if f.Since == 0 {
+			f.Since = 1
+		}
+		if f.Since < 1 || f.Since > s.Version {
+			return errors.Errorf("function [%s]: since [%d] out of the versions [1, %d]", f.Name, f.Since, s.Version)
+		}
+		if f.Until != 0 && (f.Until <= f.Since || f.Until > s.Version) {
+			return errors.Errorf("function [%s]: until [%d] out of the versions (%d, %d]", f.Name, f.Until, f.Since, s.Version)
+		}
+		args := map[string]struct{}{}
+		for _, arg := range f.Args {
+			if _, ok := reserved[arg.Name]; ok || !token.IsIdentifier(arg.Name) {
+				return errors.Errorf("function [%s]: invalid argument name [%s]", f.Name, arg.Name)
+			}
+			if _, ok := args[arg.Name]; ok {
+				return errors.Errorf("function [%s]: duplicate argument [%s]", f.Name, arg.Name)
+			}
+			args[arg.Name] = struct{}{}
+			if err := checkType(arg.Type); err != nil {
+				return errors.WithMessagef(err, "function [%s]: argument [%s]", f.Name, arg.Name)
+			}
+		}
+		if len(f.Returns) != 0 {
+			if err := checkType(f.Returns); err != nil {
+				return errors.WithMessagef(err, "function [%s]: result", f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Function returns the function with the passed name
+func (s *Schema) Function(name string) (*Function, error) {
+	for i := range s.Functions {
+		if s.Functions[i].Name == name {
+			return &s.Functions[i], nil
+		}
+	}
+	return nil, errors.Wrapf(ErrUnknownFunction, "function [%s] of chaincode [%s]", name, s.Chaincode)
+}
+
+// Check returns the function with the passed name, if it is part of the passed schema version
+func (s *Schema) Check(name string, version int) (*Function, error) {
+	f, err := s.Function(name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.Available(version) {
+		if f.Until != 0 {
+			return nil, errors.Wrapf(ErrNotDeployed, "function [%s] of chaincode [%s] available in schema versions [%d, %d), deployed [%d]", name, s.Chaincode, f.Since, f.Until, version)
+		}
+		return nil, errors.Wrapf(ErrNotDeployed, "function [%s] of chaincode [%s] requires schema version [%d], deployed [%d]", name, s.Chaincode, f.Since, version)
+	}
+	return f, nil
+}
+
+// reserved are the names used by the generated methods, not available to the arguments
+var reserved = map[string]struct{}{"context": {}, "c": {}, "res": {}, "txID": {}, "err": {}}
+
+func checkType(t string) error {
+	if len(t) == 0 {
+		return errors.New("no type")
+	}
+	if _, err := parser.ParseExpr(t); err != nil {
+		return errors.Errorf("invalid Go type [%s]", t)
+	}
+	return nil
+}
+
+// methodName returns the passed function name in camel case: get_asset and getAsset are GetAsset
+func methodName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,183 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package schema
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const assets = `
+chaincode: assets
+version: 3
+imports:
+  - time
+functions:
+  - name: create_asset
+    doc: |
+      Creates an asset.
+      The asset must not exist
+    args:
+      - name: id
+        type: string
+      - name: value
+        type: uint64
+      - name: ttl
+        type: time.Duration
+  - name: get_asset
+    method: Read
+    query: true
+    args:
+      - name: id
+        type: string
+    returns: "*Asset"
+  - name: transfer
+    since: 2
+    args:
+      - name: id
+        type: string
+      - name: owners
+        type: "map[string]int"
+    returns: bool
+  - name: burn
+    until: 3
+    args:
+      - name: id
+        type: string
+`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(assets))
+	assert.NoError(t, err)
+	assert.Len(t, s.Functions, 4)
+	assert.Equal(t, "CreateAsset", s.Functions[0].Method)
+	assert.Equal(t, "Read", s.Functions[1].Method)
+	assert.Equal(t, 1, s.Functions[0].Since)
+
+	for _, test := range []struct {
+		schema, err string
+	}{
+		{"version: 1", "no chaincode name"},
+		{"chaincode: cc", "invalid version [0], must be at least 1"},
+		{"chaincode: cc\nversion: 1\nfunctions: [{name: f}, {name: f}]", "duplicate function [f]"},
+		{"chaincode: cc\nversion: 1\nfunctions: [{name: get_a}, {name: getA}]", "function [getA]: duplicate method name [GetA]"},
+		{"chaincode: cc\nversion: 1\nfunctions: [{name: _schemaVersion}]", "invalid function name [_schemaVersion]"},
+		{"chaincode: cc\nversion: 1\nfunctions: [{name: f, since: 2}]", "function [f]: since [2] out of the versions [1, 1]"},
+		{"chaincode: cc\nversion: 2\nfunctions: [{name: f, since: 2, until: 2}]", "function [f]: until [2] out of the versions (2, 2]"},
+		{"chaincode: cc\nversion: 1\nfunctions: [{name: f, args: [{name: err, type: string}]}]", "function [f]: invalid argument name [err]"},
+		{"chaincode: cc\nversion: 1\nfunctions: [{name: f, args: [{name: a, type: 'map[string'}]}]", "function [f]: argument [a]: invalid Go type [map[string]"},
+		{"chaincode: cc\nversion: 1\nfunctions: [{name: f, returns: int, extra: 1}]", "failed unmarshalling schema: yaml: unmarshal errors:\n  line 3: field extra not found in type schema.Function"},
+	} {
+		_, err := Parse([]byte(test.schema))
+		assert.EqualError(t, err, test.err, test.schema)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s, err := Parse([]byte(assets))
+	assert.NoError(t, err)
+
+	f, err := s.Check("transfer", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "Transfer", f.Method)
+	_, err = s.Check("transfer", 1)
+	assert.True(t, errors.Is(err, ErrNotDeployed))
+	assert.EqualError(t, err, "function [transfer] of chaincode [assets] requires schema version [2], deployed [1]: function not deployed")
+	_, err = s.Check("burn", 2)
+	assert.NoError(t, err)
+	_, err = s.Check("burn", 3)
+	assert.EqualError(t, err, "function [burn] of chaincode [assets] available in schema versions [1, 3), deployed [3]: function not deployed")
+	_, err = s.Check("mint", 3)
+	assert.True(t, errors.Is(err, ErrUnknownFunction))
+}
+
+func TestCodec(t *testing.T) {
+	type asset struct {
+		ID    string `json:"id"`
+		Value uint64 `json:"value"`
+	}
+	for _, test := range []struct {
+		value, target interface{}
+		encoded       string
+	}{
+		{"alice", new(string), "alice"},
+		{[]byte{0, 1}, new([]byte), "\x00\x01"},
+		{true, new(bool), "true"},
+		{-42, new(int), "-42"},
+		{int32(-7), new(int32), "-7"},
+		{int64(-1 << 62), new(int64), "-4611686018427387904"},
+		{uint(7), new(uint), "7"},
+		{uint32(7), new(uint32), "7"},
+		{uint64(1<<64 - 1), new(uint64), "18446744073709551615"},
+		{0.25, new(float64), "0.25"},
+		{&asset{ID: "a1", Value: 10}, new(*asset), `{"id":"a1","value":10}`},
+		{map[string]int{"b": 2, "a": 1}, new(map[string]int), `{"a":1,"b":2}`},
+	} {
+		raw, err := Encode(test.value)
+		assert.NoError(t, err)
+		assert.Equal(t, test.encoded, string(raw))
+		assert.NoError(t, Decode(raw, test.target))
+	}
+
+	var n uint32
+	assert.Error(t, Decode([]byte("4294967296"), &n))
+	var b bool
+	assert.Error(t, Decode([]byte("yes"), &b))
+	_, err := Encode(func() {})
+	assert.Error(t, err)
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := Parse([]byte(assets))
+	assert.NoError(t, err)
+
+	// the generated files parse, and declare the methods of the functions
+	client, err := GenerateClient(s, "assets", "assets.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Burn", "CreateAsset", "Read", "Transfer", "WithChannel", "WithDeployedVersion", "WithNetwork", "WithSignerIdentity"}, methods(t, client, "Client"))
+	assert.Contains(t, string(client), "// Code generated by ccbind from assets.yaml. DO NOT EDIT.")
+	assert.Contains(t, string(client), "// CreateAsset invokes the function create_asset. Creates an asset.\n// The asset must not exist.\n// Available from schema version 1 on.")
+	assert.Contains(t, string(client), "func (c *Client) Read(context view.Context, id string) (res *Asset, err error)")
+	assert.Contains(t, string(client), "func (c *Client) Transfer(context view.Context, id string, owners map[string]int) (res bool, txID string, err error)")
+	assert.Contains(t, string(client), "func (c *Client) Burn(context view.Context, id string) (txID string, err error)")
+
+	// the stubs implement the latest version only
+	stubs, err := GenerateStubs(s, "assets", "assets.yaml")
+	assert.NoError(t, err)
+	assert.Contains(t, string(stubs), "const ContractVersion = 3")
+	assert.Contains(t, string(stubs), "CreateAsset(id string, value uint64, ttl time.Duration) error")
+	assert.NotContains(t, string(stubs), "Burn")
+	_, err = parser.ParseFile(token.NewFileSet(), "stubs.go", stubs, 0)
+	assert.NoError(t, err)
+
+	_, err = GenerateClient(s, "my-assets", "assets.yaml")
+	assert.EqualError(t, err, "invalid package name [my-assets]")
+}
+
+// methods returns the methods of the passed type declared in the passed source, sorted
+func methods(t *testing.T, src []byte, typ string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), "client.go", src, 0)
+	assert.NoError(t, err)
+	var res []string
+	for _, d := range f.Decls {
+		fn, ok := d.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil {
+			continue
+		}
+		if star, ok := fn.Recv.List[0].Type.(*ast.StarExpr); ok && star.X.(*ast.Ident).Name == typ {
+			res = append(res, fn.Name.Name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,161 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/chaincode/schema"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// Typed invokes and queries the functions of a chaincode described by a schema, with typed arguments and results.
+// A function absent from the schema version of the deployed chaincode fails before any peer is contacted.
+// The clients generated by ccbind are built on it.
+type Typed struct {
+	schema  *schema.Schema
+	network string
+	channel string
+	signer  view.Identity
+
+	lock    sync.Mutex
+	version int
+}
+
+// NewTyped returns a Typed for the chaincode described by the passed schema
+func NewTyped(s *schema.Schema) *Typed {
+	return &Typed{schema: s}
+}
+
+func (t *Typed) WithNetwork(name string) *Typed {
+	t.network = name
+	return t
+}
+
+func (t *Typed) WithChannel(name string) *Typed {
+	t.channel = name
+	return t
+}
+
+func (t *Typed) WithSignerIdentity(id view.Identity) *Typed {
+	t.signer = id
+	return t
+}
+
+// WithDeployedVersion sets the schema version of the deployed chaincode.
+// If not set, it is queried to the chaincode, with schema.VersionFunction, the first time it is needed.
+func (t *Typed) WithDeployedVersion(version int) *Typed {
+	t.lock.Lock()
+	t.version = version
+	t.lock.Unlock()
+	return t
+}
+
+// Schema returns the schema of the chaincode
+func (t *Typed) Schema() *schema.Schema {
+	return t.schema
+}
+
+// DeployedVersion returns the schema version of the deployed chaincode
+func (t *Typed) DeployedVersion(context view.Context) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.version != 0 {
+		return t.version, nil
+	}
+	query := NewQueryView(t.schema.Chaincode, schema.VersionFunction).WithNetwork(t.network).WithChannel(t.channel)
+	if !t.signer.IsNone() {
+		query.WithSignerIdentity(t.signer)
+	}
+	raw, err := query.Query(context)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed querying the schema version of chaincode [%s]", t.schema.Chaincode)
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil || version < 1 {
+		return 0, errors.Errorf("invalid schema version [%s] of chaincode [%s]", raw, t.schema.Chaincode)
+	}
+	t.version = version
+	return version, nil
+}
+
+// Invoke invokes the passed function with the passed arguments, decodes its result into the value pointed by result,
+// unless nil, and returns the ID of the transaction
+func (t *Typed) Invoke(context view.Context, function string, result interface{}, args ...interface{}) (string, error) {
+	f, encoded, err := t.prepare(context, function, args)
+	if err != nil {
+		return "", err
+	}
+	invoke := NewInvokeView(t.schema.Chaincode, function, encoded...).WithNetwork(t.network).WithChannel(t.channel)
+	if !t.signer.IsNone() {
+		invoke.WithSignerIdentity(t.signer)
+	}
+	txID, raw, err := invoke.Invoke(context)
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed invoking [%s] of chaincode [%s]", function, t.schema.Chaincode)
+	}
+	return txID, t.decode(f, raw, result)
+}
+
+// Query queries the passed function with the passed arguments, and decodes its result into the value pointed by
+// result, unless nil
+func (t *Typed) Query(context view.Context, function string, result interface{}, args ...interface{}) error {
+	f, encoded, err := t.prepare(context, function, args)
+	if err != nil {
+		return err
+	}
+	query := NewQueryView(t.schema.Chaincode, function, encoded...).WithNetwork(t.network).WithChannel(t.channel)
+	if !t.signer.IsNone() {
+		query.WithSignerIdentity(t.signer)
+	}
+	raw, err := query.Query(context)
+	if err != nil {
+		return errors.WithMessagef(err, "failed querying [%s] of chaincode [%s]", function, t.schema.Chaincode)
+	}
+	return t.decode(f, raw, result)
+}
+
+// prepare checks that the passed function is deployed, and encodes its arguments
+func (t *Typed) prepare(context view.Context, function string, args []interface{}) (*schema.Function, []interface{}, error) {
+	if _, err := t.schema.Function(function); err != nil {
+		return nil, nil, err
+	}
+	version, err := t.DeployedVersion(context)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := t.schema.Check(function, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(args) != len(f.Args) {
+		return nil, nil, errors.Errorf("function [%s] of chaincode [%s] expects [%d] arguments, got [%d]", function, t.schema.Chaincode, len(f.Args), len(args))
+	}
+	encoded := make([]interface{}, len(args))
+	for i, arg := range args {
+		raw, err := schema.Encode(arg)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "failed encoding argument [%s] of [%s]", f.Args[i].Name, function)
+		}
+		// the private chaincodes take strings only, the standard ones receive the same bytes
+		encoded[i] = string(raw)
+	}
+	return f, encoded, nil
+}
+
+func (t *Typed) decode(f *schema.Function, raw []byte, result interface{}) error {
+	if result == nil || len(f.Returns) == 0 {
+		return nil
+	}
+	if err := schema.Decode(raw, result); err != nil {
+		return errors.WithMessagef(err, "invalid result of [%s] of chaincode [%s]", f.Name, t.schema.Chaincode)
+	}
+	return nil
+}
@@ -85,9 +85,21 @@ func (t *Builder) newTransaction(creator []byte, network, channel string, nonce,
 	logger.Debugf("NewTransaction [%s,%s,%s]", view.Identity(creator).UniqueID(), channel, hash.Hashable(raw).String())
 	defer logger.Debugf("NewTransaction...done.")
 
-	fNetwork := fabric.GetFabricNetworkService(t.sp, network)
-	if fNetwork == nil {
-		return nil, errors.Errorf("fabric network service [%s] not found", network)
+	var fNetwork *fabric.NetworkService
+	var routing *fabric.RoutingProvenance
+	if len(raw) != 0 {
+		// the network and the channel are those of the transaction received
+		fNetwork = fabric.GetFabricNetworkService(t.sp, network)
+		if fNetwork == nil {
+			return nil, errors.Errorf("fabric network service [%s] not found", network)
+		}
+	} else {
+		var err error
+		fNetwork, routing, err = fabric.Route(t.sp, network, channel)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed routing transaction")
+		}
+		channel = routing.Channel
 	}
 	if len(creator) == 0 {
 		creator = fNetwork.IdentityProvider().DefaultIdentity()
@@ -100,6 +112,9 @@ func (t *Builder) newTransaction(creator []byte, network, channel string, nonce,
 	if err != nil {
 		return nil, err
 	}
+	if routing != nil {
+		t.recordRouting(fNetwork, fabricTransaction.ID(), routing)
+	}
 
 	tx := &Transaction{
 		ServiceProvider: t.sp,
@@ -119,6 +134,17 @@ func (t *Builder) newTransaction(creator []byte, network, channel string, nonce,
 	return tx, nil
 }
 
+// recordRouting records in the provenance of the passed transaction how its network and its channel have been chosen
+func (t *Builder) recordRouting(fNetwork *fabric.NetworkService, txID string, routing *fabric.RoutingProvenance) {
+	ch, err := fNetwork.Channel(routing.Channel)
+	if err == nil {
+		err = ch.RecordTransactionRouting(txID, routing)
+	}
+	if err != nil {
+		logger.Warnf("failed recording routing of transaction [%s]: [%s]", txID, err)
+	}
+}
+
 func NewTransaction(context view.Context, opts ...fabric.TransactionOption) (*Builder, *Transaction, error) {
 	txBuilder := NewBuilder(context)
 	tx, err := txBuilder.NewTransaction(opts...)
@@ -28,6 +28,8 @@ type collectEndorsementsView struct {
 }
 
 func (c *collectEndorsementsView) Call(context view.Context) (interface{}, error) {
+	// the sessions to the endorsers are accounted to the endorsement
+	defer view.TrackPhase(context.Context(), view.PhaseEndorsement)()
 	tracker, err := tracker.GetViewTracker(context)
 	if err != nil {
 		return nil, err
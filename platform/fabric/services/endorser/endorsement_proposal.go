@@ -37,6 +37,7 @@ func NewParallelCollectEndorsementsOnProposalView(tx *Transaction, parties ...vi
 }
 
 func (c *parallelCollectEndorsementsOnProposalView) Call(context view.Context) (interface{}, error) {
+	defer view.TrackPhase(context.Context(), view.PhaseEndorsement)()
 	// send Transaction to each party and wait for their responses
 	stateRaw, err := c.tx.Bytes()
 	if err != nil {
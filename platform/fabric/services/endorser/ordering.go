@@ -25,6 +25,8 @@ func (o *orderingView) Call(ctx view.Context) (interface{}, error) {
 	if fns == nil {
 		return nil, errors.Errorf("fabric network service [%s] not found", o.tx.Network())
 	}
+	// the broadcast and the finality are accounted to the ordering
+	defer view.TrackPhase(ctx.Context(), view.PhaseOrdering)()
 	tx := o.tx
 	if err := fns.Ordering().Broadcast(tx.Transaction); err != nil {
 		return nil, errors.WithMessagef(err, "failed broadcasting to [%s:%s]", o.tx.Network(), o.tx.Channel())
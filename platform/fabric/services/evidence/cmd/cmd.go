@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/evidence"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns the Cobra Command for the transaction evidence utilities
+func NewCmd() *cobra.Command {
+	rootCommand := &cobra.Command{
+		Use:   "evidence",
+		Short: "Transaction evidence utils.",
+		Long:  `Utilities for the evidence bundles of committed transactions.`,
+	}
+
+	rootCommand.AddCommand(
+		newVerifyCmd(),
+	)
+
+	return rootCommand
+}
+
+func newVerifyCmd() *cobra.Command {
+	var bundleFile, configBlockFile string
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify an evidence bundle.",
+		Long:  `Verify, without access to any node, that an evidence bundle proves that its transaction has been committed as valid on the channel configured by a trusted config block.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(bundleFile) == 0 || len(configBlockFile) == 0 {
+				return errors.New("the evidence bundle and the trusted config block must be set")
+			}
+			raw, err := ioutil.ReadFile(bundleFile)
+			if err != nil {
+				return errors.Wrapf(err, "failed reading evidence bundle")
+			}
+			bundle, err := evidence.Unmarshal(raw)
+			if err != nil {
+				return err
+			}
+			raw, err = ioutil.ReadFile(configBlockFile)
+			if err != nil {
+				return errors.Wrapf(err, "failed reading trusted config block")
+			}
+			config, err := evidence.ConfigFromBlock(raw)
+			if err != nil {
+				return err
+			}
+			if err := evidence.VerifyEvidence(bundle, config); err != nil {
+				return err
+			}
+			fmt.Printf("transaction [%s] committed as valid on channel [%s]\n", bundle.TxID, bundle.Channel)
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&bundleFile, "bundle", "b", "", "Sets the file of the evidence bundle")
+	flags.StringVarP(&configBlockFile, "config", "c", "", "Sets the file of the trusted config block of the channel")
+	return cmd
+}
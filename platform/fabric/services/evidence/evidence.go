@@ -0,0 +1,374 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package evidence
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/canonical"
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	"github.com/hyperledger/fabric/common/capabilities"
+	"github.com/hyperledger/fabric/common/cauthdsl"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/configtx"
+	"github.com/hyperledger/fabric/common/policies"
+	"github.com/hyperledger/fabric/msp"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// FormatVersion is the version of the serialization format of the evidence bundles.
+// The bundles of version 1 carry no attestation of the validation code, they are not accepted.
+const FormatVersion = 2
+
+// ErrNotValid is returned when the evidence proves that the transaction has been committed as not valid
+var ErrNotValid = errors.New("transaction not valid")
+
+type bundle struct {
+	Version int `json:"version"`
+	*driver.Evidence
+}
+
+// Marshal serializes the passed evidence bundle in canonical JSON, the nodes serialize the same evidence to the same bytes
+func Marshal(evidence *driver.Evidence) ([]byte, error) {
+	raw, err := canonical.JSON(&bundle{Version: FormatVersion, Evidence: evidence})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling evidence")
+	}
+	return raw, nil
+}
+
+// Unmarshal deserializes an evidence bundle serialized with Marshal
+func Unmarshal(raw []byte) (*driver.Evidence, error) {
+	b := &bundle{Evidence: &driver.Evidence{}}
+	if err := json.Unmarshal(raw, b); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling evidence")
+	}
+	if b.Version != FormatVersion {
+		return nil, errors.Errorf("unsupported evidence format version [%d], expected [%d]", b.Version, FormatVersion)
+	}
+	return b.Evidence, nil
+}
+
+// ConfigFromBlock returns the channel configuration carried by the passed config block,
+// for instance one fetched with `peer channel fetch config`
+func ConfigFromBlock(raw []byte) (*common.Config, error) {
+	block, err := protoutil.UnmarshalBlock(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid block")
+	}
+	return configOf(block)
+}
+
+func configOf(block *common.Block) (*common.Config, error) {
+	env, err := protoutil.ExtractEnvelope(block, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed extracting the config envelope")
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config payload")
+	}
+	chdr, err := protoutil.UnmarshalChannelHeader(payload.GetHeader().GetChannelHeader())
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel header")
+	}
+	if chdr.Type != int32(common.HeaderType_CONFIG) {
+		return nil, errors.Errorf("block [%d] is not a config block", block.GetHeader().GetNumber())
+	}
+	configEnv, err := configtx.UnmarshalConfigEnvelope(payload.Data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config envelope")
+	}
+	return configEnv.Config, nil
+}
+
+// VerifyEvidence checks, without access to any node, that the passed evidence proves that its transaction has been
+// committed as valid on a channel whose configuration is the trusted one:
+// the config block of the evidence carries the trusted configuration, the headers link it to the block of the
+// transaction, whose signatures satisfy the block validation policy of the orderers of the trusted configuration,
+// and the block carries the envelope of the transaction with the validation code of the evidence.
+// The orderers do not sign the validation codes: the code must be attested by a peer of an application
+// organization of the trusted configuration, see driver.Evidence.Attestations.
+// It returns ErrNotValid if the transaction has been committed as not valid.
+func VerifyEvidence(evidence *driver.Evidence, trustedConfig *common.Config) error {
+	if evidence == nil || trustedConfig == nil {
+		return errors.New("evidence and trusted configuration must be set")
+	}
+	block, err := unmarshalBlock(evidence.Block)
+	if err != nil {
+		return errors.WithMessagef(err, "invalid block")
+	}
+	configBlock, err := unmarshalBlock(evidence.ConfigBlock)
+	if err != nil {
+		return errors.WithMessagef(err, "invalid config block")
+	}
+
+	config, err := configOf(configBlock)
+	if err != nil {
+		return err
+	}
+	if !proto.Equal(config, trustedConfig) {
+		return errors.Errorf("config block [%d] does not carry the trusted configuration", configBlock.Header.Number)
+	}
+	for _, b := range []*common.Block{configBlock, block} {
+		channel, err := protoutil.GetChannelIDFromBlock(b)
+		if err != nil {
+			return errors.Wrapf(err, "failed getting the channel of block [%d]", b.Header.Number)
+		}
+		if channel != evidence.Channel {
+			return errors.Errorf("block [%d] belongs to channel [%s], not [%s]", b.Header.Number, channel, evidence.Channel)
+		}
+		if !bytes.Equal(protoutil.BlockDataHash(b.Data), b.Header.DataHash) {
+			return errors.Errorf("the data of block [%d] does not match its header", b.Header.Number)
+		}
+	}
+	if err := verifyChain(configBlock.Header, evidence.Headers, block.Header); err != nil {
+		return err
+	}
+
+	policy, err := blockValidationPolicy(trustedConfig)
+	if err != nil {
+		return err
+	}
+	if err := verifySignatures(block, policy); err != nil {
+		return err
+	}
+	lastConfig, err := protoutil.GetLastConfigIndexFromBlock(block)
+	if err != nil {
+		return errors.Wrapf(err, "failed getting the last config index of block [%d]", block.Header.Number)
+	}
+	if lastConfig != configBlock.Header.Number {
+		return errors.Errorf("block [%d] has been cut with config block [%d], not [%d]", block.Header.Number, lastConfig, configBlock.Header.Number)
+	}
+
+	return verifyTransaction(evidence, block, trustedConfig)
+}
+
+// verifyChain checks that the passed headers link the config block to the block of the transaction
+func verifyChain(config *common.BlockHeader, rawHeaders [][]byte, last *common.BlockHeader) error {
+	if config.Number == last.Number {
+		if len(rawHeaders) != 0 || !proto.Equal(config, last) {
+			return errors.Errorf("config block [%d] differs from the block of the transaction", config.Number)
+		}
+		return nil
+	}
+	headers := make([]*common.BlockHeader, 0, len(rawHeaders)+1)
+	for i, raw := range rawHeaders {
+		header := &common.BlockHeader{}
+		if err := proto.Unmarshal(raw, header); err != nil {
+			return errors.Wrapf(err, "invalid header [%d]", i)
+		}
+		headers = append(headers, header)
+	}
+	previous := config
+	for _, header := range append(headers, last) {
+		if header.Number != previous.Number+1 {
+			return errors.Errorf("header [%d] does not follow header [%d]", header.Number, previous.Number)
+		}
+		if !bytes.Equal(header.PreviousHash, protoutil.BlockHeaderHash(previous)) {
+			return errors.Errorf("header [%d] is not linked to header [%d]", header.Number, previous.Number)
+		}
+		previous = header
+	}
+	return nil
+}
+
+// mspManager returns the manager of the MSPs of the organizations of the passed group of the passed configuration
+func mspManager(config *common.Config, groupKey string) (msp.MSPManager, *common.ConfigGroup, error) {
+	channelGroup := config.GetChannelGroup()
+	group, ok := channelGroup.GetGroups()[groupKey]
+	if !ok {
+		return nil, nil, errors.Errorf("the trusted configuration has no group [%s]", groupKey)
+	}
+	caps := &common.Capabilities{}
+	if v, ok := channelGroup.GetValues()[channelconfig.CapabilitiesKey]; ok {
+		if err := proto.Unmarshal(v.Value, caps); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid channel capabilities")
+		}
+	}
+	handler := channelconfig.NewMSPConfigHandler(capabilities.NewChannelProvider(caps.Capabilities).MSPVersion(), factory.GetDefault())
+	for name, org := range group.Groups {
+		v, ok := org.Values[channelconfig.MSPKey]
+		if !ok {
+			return nil, nil, errors.Errorf("organization [%s] of group [%s] has no MSP", name, groupKey)
+		}
+		mspConfig := &mspproto.MSPConfig{}
+		if err := proto.Unmarshal(v.Value, mspConfig); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid MSP of organization [%s] of group [%s]", name, groupKey)
+		}
+		if _, err := handler.ProposeMSP(mspConfig); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed setting up the MSP of organization [%s] of group [%s]", name, groupKey)
+		}
+	}
+	manager, err := handler.CreateMSPManager()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed creating the MSP manager of group [%s]", groupKey)
+	}
+	return manager, group, nil
+}
+
+// blockValidationPolicy returns the block validation policy of the orderers of the passed configuration
+func blockValidationPolicy(config *common.Config) (policies.Policy, error) {
+	mspManager, ordererGroup, err := mspManager(config, channelconfig.OrdererGroupKey)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := policies.NewManagerImpl(
+		channelconfig.RootGroupKey,
+		map[int32]policies.Provider{int32(common.Policy_SIGNATURE): cauthdsl.NewPolicyProvider(mspManager)},
+		&common.ConfigGroup{Groups: map[string]*common.ConfigGroup{channelconfig.OrdererGroupKey: ordererGroup}},
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed creating the policies of the orderers")
+	}
+	policy, ok := manager.GetPolicy(policies.BlockValidation)
+	if !ok {
+		return nil, errors.Errorf("the trusted configuration has no policy [%s]", policies.BlockValidation)
+	}
+	return policy, nil
+}
+
+// verifySignatures checks that the signatures of the passed block satisfy the passed policy, as the peers do
+func verifySignatures(block *common.Block, policy policies.Policy) error {
+	metadata, err := protoutil.GetMetadataFromBlock(block, common.BlockMetadataIndex_SIGNATURES)
+	if err != nil {
+		return errors.Wrapf(err, "failed getting the signatures of block [%d]", block.Header.Number)
+	}
+	var signatures []*protoutil.SignedData
+	for _, s := range metadata.Signatures {
+		shdr, err := protoutil.UnmarshalSignatureHeader(s.SignatureHeader)
+		if err != nil {
+			return errors.Wrapf(err, "invalid signature header in block [%d]", block.Header.Number)
+		}
+		signatures = append(signatures, &protoutil.SignedData{
+			Identity:  shdr.Creator,
+			Data:      bytes.Join([][]byte{metadata.Value, s.SignatureHeader, protoutil.BlockHeaderBytes(block.Header)}, nil),
+			Signature: s.Signature,
+		})
+	}
+	if err := policy.EvaluateSignedData(signatures); err != nil {
+		return errors.Wrapf(err, "the signatures of block [%d] do not satisfy the block validation policy", block.Header.Number)
+	}
+	return nil
+}
+
+// verifyTransaction checks that the passed block carries the transaction of the evidence, with its validation code
+func verifyTransaction(evidence *driver.Evidence, block *common.Block, trustedConfig *common.Config) error {
+	if evidence.TxNum >= uint64(len(block.Data.Data)) {
+		return errors.Errorf("block [%d] has no transaction [%d]", block.Header.Number, evidence.TxNum)
+	}
+	if !bytes.Equal(block.Data.Data[evidence.TxNum], evidence.Envelope) {
+		return errors.Errorf("transaction [%d] of block [%d] is not the envelope of the evidence", evidence.TxNum, block.Header.Number)
+	}
+	env, err := protoutil.UnmarshalEnvelope(evidence.Envelope)
+	if err != nil {
+		return errors.Wrapf(err, "invalid envelope")
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return errors.Wrapf(err, "invalid payload")
+	}
+	chdr, err := protoutil.UnmarshalChannelHeader(payload.GetHeader().GetChannelHeader())
+	if err != nil {
+		return errors.Wrapf(err, "invalid channel header")
+	}
+	if chdr.TxId != evidence.TxID {
+		return errors.Errorf("the envelope is of transaction [%s], not [%s]", chdr.TxId, evidence.TxID)
+	}
+
+	filter := block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	if evidence.TxNum >= uint64(len(filter)) {
+		return errors.Errorf("block [%d] has no validation code for transaction [%d]", block.Header.Number, evidence.TxNum)
+	}
+	code := int32(filter[evidence.TxNum])
+	if code != evidence.ValidationCode {
+		return errors.Errorf("the validation code of [%s] is [%d] in the block, [%d] in the evidence", evidence.TxID, code, evidence.ValidationCode)
+	}
+	// the filter is not covered by the signatures of the orderers, the peers attest the code
+	if err := verifyAttestations(evidence, env, trustedConfig); err != nil {
+		return err
+	}
+	if code != int32(peer.TxValidationCode_VALID) {
+		return errors.Wrapf(ErrNotValid, "[%s] committed with code [%s]", evidence.TxID, peer.TxValidationCode(code))
+	}
+	if evidence.VaultStatus != driver.Valid {
+		return errors.Errorf("[%s] is valid in the block, the vault recorded status [%d]", evidence.TxID, evidence.VaultStatus)
+	}
+	return nil
+}
+
+// verifyAttestations checks that the attestations of the evidence are signed by peers of the application
+// organizations of the trusted configuration, and carry the passed envelope with the validation code of the evidence.
+// At least one attestation is required.
+func verifyAttestations(evidence *driver.Evidence, env *common.Envelope, trustedConfig *common.Config) error {
+	if len(evidence.Attestations) == 0 {
+		return errors.Errorf("the validation code of [%s] is not attested by any peer", evidence.TxID)
+	}
+	manager, _, err := mspManager(trustedConfig, channelconfig.ApplicationGroupKey)
+	if err != nil {
+		return err
+	}
+	for i, raw := range evidence.Attestations {
+		resp := &peer.ProposalResponse{}
+		if err := proto.Unmarshal(raw, resp); err != nil {
+			return errors.Wrapf(err, "invalid attestation [%d]", i)
+		}
+		if resp.Endorsement == nil {
+			return errors.Errorf("attestation [%d] is not signed", i)
+		}
+		id, err := manager.DeserializeIdentity(resp.Endorsement.Endorser)
+		if err != nil {
+			return errors.Wrapf(err, "attestation [%d] not signed by a peer of the application organizations", i)
+		}
+		if err := id.Validate(); err != nil {
+			return errors.Wrapf(err, "attestation [%d] signed by an invalid identity", i)
+		}
+		if err := id.Verify(append(resp.Payload, resp.Endorsement.Endorser...), resp.Endorsement.Signature); err != nil {
+			return errors.Wrapf(err, "invalid signature of attestation [%d]", i)
+		}
+		prp, err := protoutil.UnmarshalProposalResponsePayload(resp.Payload)
+		if err != nil {
+			return errors.Wrapf(err, "invalid payload of attestation [%d]", i)
+		}
+		action, err := protoutil.UnmarshalChaincodeAction(prp.Extension)
+		if err != nil {
+			return errors.Wrapf(err, "invalid chaincode action of attestation [%d]", i)
+		}
+		if action.GetResponse().GetStatus() != 200 {
+			return errors.Errorf("attestation [%d] is a failure [%d]", i, action.GetResponse().GetStatus())
+		}
+		pt := &peer.ProcessedTransaction{}
+		if err := proto.Unmarshal(action.Response.Payload, pt); err != nil {
+			return errors.Wrapf(err, "invalid transaction in attestation [%d]", i)
+		}
+		if !proto.Equal(pt.TransactionEnvelope, env) {
+			return errors.Errorf("attestation [%d] is not of the envelope of the evidence", i)
+		}
+		if pt.ValidationCode != evidence.ValidationCode {
+			return errors.Errorf("the validation code of [%s] is [%d] in attestation [%d], [%d] in the evidence", evidence.TxID, pt.ValidationCode, i, evidence.ValidationCode)
+		}
+	}
+	return nil
+}
+
+func unmarshalBlock(raw []byte) (*common.Block, error) {
+	block, err := protoutil.UnmarshalBlock(raw)
+	if err != nil {
+		return nil, err
+	}
+	if block.Header == nil || block.Data == nil || block.Metadata == nil || len(block.Metadata.Metadata) <= int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		return nil, errors.New("incomplete block")
+	}
+	return block, nil
+}
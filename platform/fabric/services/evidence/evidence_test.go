@@ -0,0 +1,300 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package evidence
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger/fabric-protos-go/common"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/bccsp/utils"
+	"github.com/hyperledger/fabric/common/policies"
+	"github.com/hyperledger/fabric/common/policydsl"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// orderer is an orderer identity issued by the CA of its MSP, the peers are issued the same way
+type orderer struct {
+	mspID    string
+	ca       []byte
+	cert     []byte
+	identity []byte
+	key      *ecdsa.PrivateKey
+}
+
+func newOrderer(t *testing.T, mspID string) *orderer {
+	return newMember(t, mspID, "orderer")
+}
+
+func newPeer(t *testing.T, mspID string) *orderer {
+	return newMember(t, mspID, "peer")
+}
+
+func newMember(t *testing.T, mspID, role string) *orderer {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca." + mspID},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          []byte{1},
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: role + "." + mspID},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		AuthorityKeyId: []byte{1},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	assert.NoError(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	identity, err := proto.Marshal(&mspproto.SerializedIdentity{Mspid: mspID, IdBytes: cert})
+	assert.NoError(t, err)
+	return &orderer{mspID: mspID, ca: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), cert: cert, identity: identity, key: key}
+}
+
+func (o *orderer) sign(t *testing.T, message []byte) []byte {
+	digest := sha256.Sum256(message)
+	r, s, err := ecdsa.Sign(rand.Reader, o.key, digest[:])
+	assert.NoError(t, err)
+	s, err = utils.ToLowS(&o.key.PublicKey, s)
+	assert.NoError(t, err)
+	sigma, err := utils.MarshalECDSASignature(r, s)
+	assert.NoError(t, err)
+	return sigma
+}
+
+func marshal(t *testing.T, m proto.Message) []byte {
+	raw, err := proto.Marshal(m)
+	assert.NoError(t, err)
+	return raw
+}
+
+func channelConfig(t *testing.T, o, p *orderer) *common.Config {
+	anyWriters := &common.ConfigPolicy{Policy: &common.Policy{
+		Type:  int32(common.Policy_IMPLICIT_META),
+		Value: marshal(t, &common.ImplicitMetaPolicy{SubPolicy: "Writers", Rule: common.ImplicitMetaPolicy_ANY}),
+	}}
+	return &common.Config{ChannelGroup: &common.ConfigGroup{
+		Values: map[string]*common.ConfigValue{
+			"Capabilities": {Value: marshal(t, &common.Capabilities{Capabilities: map[string]*common.Capability{"V2_0": {}}})},
+		},
+		Groups: map[string]*common.ConfigGroup{
+			"Orderer": {
+				Groups:   map[string]*common.ConfigGroup{"OrdererOrg": organization(t, o)},
+				Policies: map[string]*common.ConfigPolicy{"Writers": anyWriters, "BlockValidation": anyWriters},
+			},
+			"Application": {
+				Groups:   map[string]*common.ConfigGroup{"Org1": organization(t, p)},
+				Policies: map[string]*common.ConfigPolicy{"Writers": anyWriters},
+			},
+		},
+	}}
+}
+
+// organization returns the configuration of the organization of the passed member
+func organization(t *testing.T, m *orderer) *common.ConfigGroup {
+	fabricMSP := &mspproto.FabricMSPConfig{Name: m.mspID, RootCerts: [][]byte{m.ca}, Admins: [][]byte{m.cert}}
+	return &common.ConfigGroup{
+		Values: map[string]*common.ConfigValue{
+			"MSP": {Value: marshal(t, &mspproto.MSPConfig{Config: marshal(t, fabricMSP)})},
+		},
+		Policies: map[string]*common.ConfigPolicy{
+			"Writers": {Policy: &common.Policy{Type: int32(common.Policy_SIGNATURE), Value: marshal(t, policydsl.SignedByMspMember(m.mspID))}},
+		},
+	}
+}
+
+// attestation returns the response of the passed peer to the query of the passed envelope, with the passed code
+func attestation(t *testing.T, p *orderer, env []byte, code peer.TxValidationCode) []byte {
+	e, err := protoutil.UnmarshalEnvelope(env)
+	assert.NoError(t, err)
+	payload := marshal(t, &peer.ProposalResponsePayload{Extension: marshal(t, &peer.ChaincodeAction{Response: &peer.Response{
+		Status:  200,
+		Payload: marshal(t, &peer.ProcessedTransaction{TransactionEnvelope: e, ValidationCode: int32(code)}),
+	}})})
+	return marshal(t, &peer.ProposalResponse{
+		Response:    &peer.Response{Status: 200},
+		Payload:     payload,
+		Endorsement: &peer.Endorsement{Endorser: p.identity, Signature: p.sign(t, append(append([]byte{}, payload...), p.identity...))},
+	})
+}
+
+func envelope(t *testing.T, headerType common.HeaderType, txID string, data []byte) []byte {
+	return marshal(t, &common.Envelope{Payload: marshal(t, &common.Payload{
+		Header: &common.Header{ChannelHeader: marshal(t, &common.ChannelHeader{Type: int32(headerType), ChannelId: "ch", TxId: txID})},
+		Data:   data,
+	})})
+}
+
+// cut returns the block following the passed one, signed by the passed orderer
+func cut(t *testing.T, o *orderer, previous *common.BlockHeader, lastConfig uint64, codes []peer.TxValidationCode, data ...[]byte) *common.Block {
+	var number uint64
+	var previousHash []byte
+	if previous != nil {
+		number, previousHash = previous.Number+1, protoutil.BlockHeaderHash(previous)
+	}
+	block := protoutil.NewBlock(number, previousHash)
+	block.Data.Data = data
+	block.Header.DataHash = protoutil.BlockDataHash(block.Data)
+	filter := make([]byte, len(codes))
+	for i, code := range codes {
+		filter[i] = byte(code)
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = filter
+
+	value := marshal(t, &common.OrdererBlockMetadata{LastConfig: &common.LastConfig{Index: lastConfig}})
+	sigHeader := marshal(t, &common.SignatureHeader{Creator: o.identity, Nonce: []byte("nonce")})
+	block.Metadata.Metadata[common.BlockMetadataIndex_SIGNATURES] = marshal(t, &common.Metadata{
+		Value: value,
+		Signatures: []*common.MetadataSignature{{
+			SignatureHeader: sigHeader,
+			Signature:       o.sign(t, bytes.Join([][]byte{value, sigHeader, protoutil.BlockHeaderBytes(block.Header)}, nil)),
+		}},
+	})
+	return block
+}
+
+func TestVerifyEvidence(t *testing.T) {
+	o, p := newOrderer(t, "OrdererMSP"), newPeer(t, "Org1MSP")
+	config := channelConfig(t, o, p)
+	configBlock := cut(t, o, nil, 0, []peer.TxValidationCode{peer.TxValidationCode_VALID},
+		envelope(t, common.HeaderType_CONFIG, "", marshal(t, &common.ConfigEnvelope{Config: config})))
+	between := cut(t, o, configBlock.Header, 0, []peer.TxValidationCode{peer.TxValidationCode_VALID}, envelope(t, common.HeaderType_ENDORSER_TRANSACTION, "tx0", nil))
+	tx1, tx2 := envelope(t, common.HeaderType_ENDORSER_TRANSACTION, "tx1", []byte("1")), envelope(t, common.HeaderType_ENDORSER_TRANSACTION, "tx2", []byte("2"))
+	block := cut(t, o, between.Header, 0, []peer.TxValidationCode{peer.TxValidationCode_VALID, peer.TxValidationCode_MVCC_READ_CONFLICT}, tx1, tx2)
+
+	newEvidence := func() *driver.Evidence {
+		return &driver.Evidence{
+			Channel:        "ch",
+			TxID:           "tx1",
+			TxNum:          0,
+			Envelope:       tx1,
+			Block:          marshal(t, block),
+			ConfigBlock:    marshal(t, configBlock),
+			Headers:        [][]byte{marshal(t, between.Header)},
+			ValidationCode: int32(peer.TxValidationCode_VALID),
+			VaultStatus:    driver.Valid,
+			Attestations:   [][]byte{attestation(t, p, tx1, peer.TxValidationCode_VALID)},
+		}
+	}
+
+	// the bundle survives its serialization, the trusted configuration is read from the config block
+	raw, err := Marshal(newEvidence())
+	assert.NoError(t, err)
+	evidence, err := Unmarshal(raw)
+	assert.NoError(t, err)
+	trusted, err := ConfigFromBlock(marshal(t, configBlock))
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyEvidence(evidence, trusted))
+	_, err = Unmarshal([]byte(`{"version":1}`))
+	assert.Error(t, err)
+
+	// the evidence of a transaction committed as not valid
+	invalid := newEvidence()
+	invalid.TxID, invalid.TxNum, invalid.Envelope = "tx2", 1, tx2
+	invalid.ValidationCode, invalid.VaultStatus = int32(peer.TxValidationCode_MVCC_READ_CONFLICT), driver.Invalid
+	invalid.Attestations = [][]byte{attestation(t, p, tx2, peer.TxValidationCode_MVCC_READ_CONFLICT)}
+	assert.True(t, errors.Is(VerifyEvidence(invalid, config), ErrNotValid))
+
+	// the tampered evidences are refused
+	for name, tamper := range map[string]func(e *driver.Evidence){
+		"other envelope":        func(e *driver.Evidence) { e.Envelope = tx2 },
+		"other transaction":     func(e *driver.Evidence) { e.TxID = "tx2" },
+		"validation code":       func(e *driver.Evidence) { e.ValidationCode = int32(peer.TxValidationCode_MVCC_READ_CONFLICT) },
+		"vault status":          func(e *driver.Evidence) { e.VaultStatus = driver.Invalid },
+		"missing header":        func(e *driver.Evidence) { e.Headers = nil },
+		"other channel":         func(e *driver.Evidence) { e.Channel = "other" },
+		"config block as block": func(e *driver.Evidence) { e.Block = e.ConfigBlock },
+		"unsigned block": func(e *driver.Evidence) {
+			b := proto.Clone(block).(*common.Block)
+			b.Metadata.Metadata[common.BlockMetadataIndex_SIGNATURES] = marshal(t, &common.Metadata{})
+			e.Block = marshal(t, b)
+		},
+		"altered data": func(e *driver.Evidence) {
+			b := proto.Clone(block).(*common.Block)
+			b.Data.Data[1] = tx1
+			e.Block = marshal(t, b)
+		},
+		"not attested": func(e *driver.Evidence) { e.Attestations = nil },
+		"attested by another": func(e *driver.Evidence) {
+			e.Attestations = [][]byte{attestation(t, newPeer(t, "Org1MSP"), tx1, peer.TxValidationCode_VALID)}
+		},
+		"attested by orderer": func(e *driver.Evidence) {
+			e.Attestations = [][]byte{attestation(t, o, tx1, peer.TxValidationCode_VALID)}
+		},
+		"attested other code": func(e *driver.Evidence) {
+			e.Attestations = [][]byte{attestation(t, p, tx1, peer.TxValidationCode_MVCC_READ_CONFLICT)}
+		},
+		"attested other envelope": func(e *driver.Evidence) {
+			e.Attestations = [][]byte{attestation(t, p, tx2, peer.TxValidationCode_VALID)}
+		},
+		"altered attestation": func(e *driver.Evidence) {
+			resp := &peer.ProposalResponse{}
+			assert.NoError(t, proto.Unmarshal(e.Attestations[0], resp))
+			resp.Endorsement.Signature = p.sign(t, []byte("other"))
+			e.Attestations = [][]byte{marshal(t, resp)}
+		},
+		"altered header": func(e *driver.Evidence) {
+			h := proto.Clone(between.Header).(*common.BlockHeader)
+			h.DataHash = []byte("forged")
+			e.Headers = [][]byte{marshal(t, h)}
+		},
+	} {
+		e := newEvidence()
+		tamper(e)
+		assert.Error(t, VerifyEvidence(e, config), name)
+	}
+
+	// signed by orderers other than the trusted ones
+	intruder := newOrderer(t, "OrdererMSP")
+	forged := newEvidence()
+	forged.Block = marshal(t, cut(t, intruder, between.Header, 0, []peer.TxValidationCode{peer.TxValidationCode_VALID}, tx1))
+	assert.Error(t, VerifyEvidence(forged, config))
+	assert.Error(t, VerifyEvidence(newEvidence(), channelConfig(t, intruder, p)))
+
+	// the filter of the block is not signed by the orderers: a forged code needs a forged attestation too
+	flipped := newEvidence()
+	b := proto.Clone(block).(*common.Block)
+	b.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER][1] = byte(peer.TxValidationCode_VALID)
+	flipped.TxID, flipped.TxNum, flipped.Envelope, flipped.Block = "tx2", 1, tx2, marshal(t, b)
+	flipped.Attestations = [][]byte{attestation(t, p, tx2, peer.TxValidationCode_MVCC_READ_CONFLICT)}
+	assert.NoError(t, verifySignatures(b, mustPolicy(t, config)))
+	assert.Error(t, VerifyEvidence(flipped, config))
+}
+
+func mustPolicy(t *testing.T, config *common.Config) policies.Policy {
+	policy, err := blockValidationPolicy(config)
+	assert.NoError(t, err)
+	return policy
+}
@@ -0,0 +1,385 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package exchange
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.services.exchange")
+
+const (
+	DefaultTimeout          = 5 * time.Minute
+	DefaultWatchdogInterval = 30 * time.Second
+)
+
+// Status is the status of an exchange
+type Status string
+
+const (
+	// Preparing means that the escrows of the legs are being endorsed and committed
+	Preparing Status = "preparing"
+	// Committing means that all the escrows are committed, and the legs are being released
+	Committing Status = "committing"
+	// Committed means that all the legs have been released, this is a final status
+	Committed Status = "committed"
+	// Aborting means that the exchange failed, and the committed escrows are being refunded
+	Aborting Status = "aborting"
+	// Aborted means that all the committed escrows have been refunded, this is a final status
+	Aborted Status = "aborted"
+)
+
+// Final returns true if the exchange cannot make progress anymore
+func (s Status) Final() bool {
+	return s == Committed || s == Aborted
+}
+
+// Invocation is a chaincode invocation of an exchange leg
+type Invocation struct {
+	Function        string
+	Args            [][]byte
+	TransientMap    map[string][]byte
+	EndorsersMSPIDs []string
+}
+
+// Escrow is implemented by the application to define the chaincode invocations of a leg.
+// The invocations are computed when the exchange starts, and are stored with the coordination state,
+// so that the coordinator can complete or reverse the exchange after a restart.
+type Escrow interface {
+	// Prepare locks the asset of the leg and writes a pending-escrow record, for the passed exchange,
+	// in the namespace of the chaincode. The chaincode should refuse preparing after the passed deadline.
+	Prepare(exchangeID string, deadline time.Time) (*Invocation, error)
+	// Release completes the leg, moving the escrowed asset to its new owner.
+	// It is invoked only if all the legs have been prepared, and it must be idempotent.
+	Release(exchangeID string) (*Invocation, error)
+	// Refund compensates the leg, returning the escrowed asset to its original owner.
+	// It must be idempotent, and it must succeed if the escrow does not exist.
+	Refund(exchangeID string) (*Invocation, error)
+}
+
+// Leg is the part of an exchange that takes place on a Fabric network
+type Leg struct {
+	Network   string
+	Channel   string
+	Chaincode string
+	Escrow    Escrow `json:"-"`
+}
+
+// LegState is the coordination state of a leg
+type LegState struct {
+	Leg
+	Prepare *Invocation
+	Release *Invocation
+	Refund  *Invocation
+
+	PrepareTxID   string
+	PrepareStatus TxStatus
+	// CompletionTxID is the id of the last release or refund transaction
+	CompletionTxID string
+	// Completed is true once the release or refund transaction of the leg is committed
+	Completed bool
+}
+
+// State is the coordination state of an exchange
+type State struct {
+	ID     string
+	Status Status
+	// Deadline bounds the prepare phase
+	Deadline time.Time
+	Legs     []*LegState
+	// Reason tells why the exchange has been aborted
+	Reason string
+}
+
+type options struct {
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// Option configures a Coordinator
+type Option func(*options)
+
+// WithTimeout sets the time the legs have to be prepared, DefaultTimeout if not specified.
+// The watchdog aborts the exchanges still preparing after it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithWatchdogInterval sets how often the watchdog looks for stuck exchanges, DefaultWatchdogInterval if not specified
+func WithWatchdogInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// Coordinator runs all-or-nothing exchanges across Fabric networks with a two-phase commit.
+// In the prepare phase, the escrow of each leg is endorsed, then all of them are ordered.
+// If all the escrows commit, the legs are released, otherwise the committed escrows are refunded.
+// The coordination state is stored in the KVS at each step, and a watchdog completes or reverses
+// the exchanges that got stuck, the ones interrupted by a restart included.
+type Coordinator struct {
+	network Network
+	store   *store
+	options options
+
+	lock sync.Mutex
+	// running tracks the exchanges being driven, by id
+	running map[string]struct{}
+}
+
+// NewCoordinator returns a coordinator storing its state in the passed KVS
+func NewCoordinator(kvss *kvs.KVS, network Network, opts ...Option) *Coordinator {
+	o := options{timeout: DefaultTimeout, interval: DefaultWatchdogInterval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Coordinator{
+		network: network,
+		store:   &store{kvs: kvss},
+		options: o,
+		running: map[string]struct{}{},
+	}
+}
+
+// Execute runs the exchange with the passed id over the passed legs, and returns its final state.
+// An error is returned if the exchange cannot reach a final status, its state is left to the watchdog.
+func (c *Coordinator) Execute(ctx context.Context, exchangeID string, legs ...*Leg) (*State, error) {
+	if len(legs) < 2 {
+		return nil, errors.Errorf("an exchange requires at least two legs, got [%d]", len(legs))
+	}
+	if c.store.Exists(exchangeID) {
+		return nil, errors.Errorf("exchange [%s] already exists", exchangeID)
+	}
+	state := &State{
+		ID:       exchangeID,
+		Status:   Preparing,
+		Deadline: time.Now().Add(c.options.timeout),
+	}
+	for _, leg := range legs {
+		if leg.Escrow == nil {
+			return nil, errors.Errorf("no escrow defined for the leg on [%s:%s:%s]", leg.Network, leg.Channel, leg.Chaincode)
+		}
+		ls := &LegState{Leg: *leg, PrepareStatus: Unknown}
+		var err error
+		if ls.Prepare, err = leg.Escrow.Prepare(exchangeID, state.Deadline); err != nil {
+			return nil, errors.WithMessagef(err, "failed preparing the leg on [%s]", leg.Network)
+		}
+		if ls.Release, err = leg.Escrow.Release(exchangeID); err != nil {
+			return nil, errors.WithMessagef(err, "failed preparing the release of the leg on [%s]", leg.Network)
+		}
+		if ls.Refund, err = leg.Escrow.Refund(exchangeID); err != nil {
+			return nil, errors.WithMessagef(err, "failed preparing the refund of the leg on [%s]", leg.Network)
+		}
+		state.Legs = append(state.Legs, ls)
+	}
+
+	if !c.acquire(exchangeID) {
+		return nil, errors.Errorf("exchange [%s] is already running", exchangeID)
+	}
+	defer c.release(exchangeID)
+	if err := c.store.Put(state); err != nil {
+		return nil, err
+	}
+	if err := c.drive(ctx, state, true); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+// State returns the coordination state of the passed exchange
+func (c *Coordinator) State(exchangeID string) (*State, error) {
+	return c.store.Get(exchangeID)
+}
+
+// Start runs the watchdog until the passed context is done.
+// The exchanges that did not reach a final status are resumed right away, and then periodically.
+func (c *Coordinator) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.options.interval)
+	defer ticker.Stop()
+	for {
+		c.Recover(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Recover drives to a final status the exchanges that are not running
+func (c *Coordinator) Recover(ctx context.Context) {
+	states, err := c.store.List()
+	if err != nil {
+		logger.Errorf("failed listing exchanges: [%s]", err)
+		return
+	}
+	for _, state := range states {
+		if state.Status.Final() || !c.acquire(state.ID) {
+			continue
+		}
+		if logger.IsEnabledFor(zapcore.DebugLevel) {
+			logger.Debugf("resuming exchange [%s] in status [%s]", state.ID, state.Status)
+		}
+		if err := c.drive(ctx, state, false); err != nil {
+			logger.Warnf("exchange [%s] still in status [%s]: [%s]", state.ID, state.Status, err)
+		}
+		c.release(state.ID)
+	}
+}
+
+func (c *Coordinator) acquire(exchangeID string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.running[exchangeID]; ok {
+		return false
+	}
+	c.running[exchangeID] = struct{}{}
+	return true
+}
+
+func (c *Coordinator) release(exchangeID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.running, exchangeID)
+}
+
+// drive moves the exchange forward until it reaches a final status.
+// fresh is true if the exchange has just been created by this process, and its escrows have not been submitted yet.
+func (c *Coordinator) drive(ctx context.Context, state *State, fresh bool) error {
+	for !state.Status.Final() {
+		var err error
+		switch state.Status {
+		case Preparing:
+			err = c.prepare(ctx, state, fresh)
+		case Committing:
+			err = c.complete(ctx, state, func(ls *LegState) *Invocation { return ls.Release }, Committed)
+		case Aborting:
+			err = c.complete(ctx, state, func(ls *LegState) *Invocation { return ls.Refund }, Aborted)
+		default:
+			return errors.Errorf("exchange [%s] has invalid status [%s]", state.ID, state.Status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare runs the prepare phase: the escrows are all endorsed before any of them is ordered,
+// and the exchange commits only if all of them become final before the deadline
+func (c *Coordinator) prepare(ctx context.Context, state *State, fresh bool) error {
+	if fresh {
+		envelopes := make([]*fabric.Envelope, len(state.Legs))
+		for i, ls := range state.Legs {
+			txID, env, err := c.network.Endorse(&ls.Leg, ls.Prepare)
+			if err != nil {
+				// nothing has been ordered yet, there is nothing to compensate
+				for _, ls := range state.Legs {
+					ls.PrepareStatus = Invalid
+				}
+				return c.abort(state, errors.WithMessagef(err, "failed endorsing the escrow on [%s]", ls.Network))
+			}
+			ls.PrepareTxID = txID
+			envelopes[i] = env
+		}
+		// record the transaction ids before ordering, so that their status can be checked after a restart
+		if err := c.store.Put(state); err != nil {
+			return err
+		}
+		for i, ls := range state.Legs {
+			if err := c.network.Order(&ls.Leg, envelopes[i]); err != nil {
+				return c.abort(state, errors.WithMessagef(err, "failed ordering the escrow on [%s]", ls.Network))
+			}
+		}
+	}
+
+	deadline, cancel := context.WithDeadline(ctx, state.Deadline)
+	defer cancel()
+	for _, ls := range state.Legs {
+		if ls.PrepareStatus != Unknown {
+			continue
+		}
+		if len(ls.PrepareTxID) == 0 {
+			// the process stopped before ordering the escrows
+			return c.abort(state, errors.Errorf("escrow on [%s] never ordered", ls.Network))
+		}
+		ls.PrepareStatus = c.network.Status(deadline, &ls.Leg, ls.PrepareTxID)
+		if ls.PrepareStatus == Invalid {
+			return c.abort(state, errors.Errorf("escrow [%s] on [%s] is not valid", ls.PrepareTxID, ls.Network))
+		}
+		if ls.PrepareStatus == Unknown {
+			if time.Now().After(state.Deadline) {
+				return c.abort(state, errors.Errorf("escrow [%s] on [%s] not final before the deadline", ls.PrepareTxID, ls.Network))
+			}
+			// the context is done, the watchdog will resume the exchange
+			return errors.Errorf("escrow [%s] on [%s] not final yet", ls.PrepareTxID, ls.Network)
+		}
+	}
+	state.Status = Committing
+	return c.store.Put(state)
+}
+
+func (c *Coordinator) abort(state *State, reason error) error {
+	logger.Warnf("aborting exchange [%s]: [%s]", state.ID, reason)
+	state.Status = Aborting
+	state.Reason = reason.Error()
+	return c.store.Put(state)
+}
+
+// complete submits, for each leg, the transaction returned by the passed function until it commits.
+// The legs whose escrow is not valid have nothing to complete.
+func (c *Coordinator) complete(ctx context.Context, state *State, invocation func(*LegState) *Invocation, final Status) error {
+	for _, ls := range state.Legs {
+		if ls.Completed {
+			continue
+		}
+		if ls.PrepareStatus == Invalid {
+			ls.Completed = true
+			continue
+		}
+		// a transaction submitted before a restart might have committed in the meantime
+		if len(ls.CompletionTxID) != 0 && c.network.Status(ctx, &ls.Leg, ls.CompletionTxID) == Valid {
+			ls.Completed = true
+			if err := c.store.Put(state); err != nil {
+				return err
+			}
+			continue
+		}
+
+		txID, env, err := c.network.Endorse(&ls.Leg, invocation(ls))
+		if err != nil {
+			return errors.WithMessagef(err, "failed endorsing the completion of exchange [%s] on [%s]", state.ID, ls.Network)
+		}
+		ls.CompletionTxID = txID
+		if err := c.store.Put(state); err != nil {
+			return err
+		}
+		if err := c.network.Order(&ls.Leg, env); err != nil {
+			return errors.WithMessagef(err, "failed ordering the completion of exchange [%s] on [%s]", state.ID, ls.Network)
+		}
+		if status := c.network.Status(ctx, &ls.Leg, txID); status != Valid {
+			return errors.Errorf("completion [%s] of exchange [%s] on [%s] is [%s]", txID, state.ID, ls.Network, status)
+		}
+		ls.Completed = true
+		if err := c.store.Put(state); err != nil {
+			return err
+		}
+	}
+	state.Status = final
+	logger.Infof("exchange [%s] %s", state.ID, final)
+	return c.store.Put(state)
+}
@@ -0,0 +1,230 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package exchange_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/exchange"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// escrow encodes the phase and the exchange in the function name of its invocations
+type escrow struct{}
+
+func (e *escrow) Prepare(exchangeID string, deadline time.Time) (*exchange.Invocation, error) {
+	return &exchange.Invocation{Function: "prepare", Args: [][]byte{[]byte(exchangeID), []byte(deadline.String())}}, nil
+}
+
+func (e *escrow) Release(exchangeID string) (*exchange.Invocation, error) {
+	return &exchange.Invocation{Function: "release", Args: [][]byte{[]byte(exchangeID)}}, nil
+}
+
+func (e *escrow) Refund(exchangeID string) (*exchange.Invocation, error) {
+	return &exchange.Invocation{Function: "refund", Args: [][]byte{[]byte(exchangeID)}}, nil
+}
+
+// network is a fake Network, transactions are identified by network, function, and a counter
+type network struct {
+	lock      sync.Mutex
+	counter   int
+	envelopes map[*fabric.Envelope]string
+	// ordered lists the transactions ordered, per network
+	ordered map[string][]string
+	// endorseErrs fails the endorsement of a function on a network, keyed by network.function
+	endorseErrs map[string]error
+	// statuses overrides the status of the transactions of a function on a network, keyed by network.function.
+	// Unknown makes the status wait for the context to be done.
+	statuses map[string]exchange.TxStatus
+}
+
+func newNetwork() *network {
+	return &network{
+		envelopes:   map[*fabric.Envelope]string{},
+		ordered:     map[string][]string{},
+		endorseErrs: map[string]error{},
+		statuses:    map[string]exchange.TxStatus{},
+	}
+}
+
+func (n *network) Endorse(leg *exchange.Leg, invocation *exchange.Invocation) (string, *fabric.Envelope, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if err := n.endorseErrs[leg.Network+"."+invocation.Function]; err != nil {
+		return "", nil, err
+	}
+	n.counter++
+	txID := fmt.Sprintf("%s.%s.%d", leg.Network, invocation.Function, n.counter)
+	env := &fabric.Envelope{}
+	n.envelopes[env] = txID
+	return txID, env, nil
+}
+
+func (n *network) Order(leg *exchange.Leg, envelope *fabric.Envelope) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.ordered[leg.Network] = append(n.ordered[leg.Network], n.envelopes[envelope])
+	return nil
+}
+
+func (n *network) Status(ctx context.Context, leg *exchange.Leg, txID string) exchange.TxStatus {
+	n.lock.Lock()
+	status, ok := n.statuses[txID[:strings.LastIndex(txID, ".")]]
+	wasOrdered := false
+	for _, id := range n.ordered[leg.Network] {
+		wasOrdered = wasOrdered || id == txID
+	}
+	n.lock.Unlock()
+	if !ok {
+		status = exchange.Valid
+	}
+	if !wasOrdered || status == exchange.Unknown {
+		<-ctx.Done()
+		return exchange.Unknown
+	}
+	return status
+}
+
+func (n *network) set(key string, status exchange.TxStatus) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.statuses[key] = status
+}
+
+func (n *network) functions(network string) []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	var res []string
+	for _, txID := range n.ordered[network] {
+		res = append(res, strings.Split(txID, ".")[1])
+	}
+	return res
+}
+
+func newKVS(t *testing.T) *kvs.KVS {
+	kvss, err := kvs.NewWithConfig(registry2.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	return kvss
+}
+
+func legs() []*exchange.Leg {
+	return []*exchange.Leg{
+		{Network: "assets", Channel: "ch", Chaincode: "asset", Escrow: &escrow{}},
+		{Network: "payments", Channel: "ch", Chaincode: "payment", Escrow: &escrow{}},
+	}
+}
+
+func TestExchangeCommits(t *testing.T) {
+	n := newNetwork()
+	c := exchange.NewCoordinator(newKVS(t), n)
+
+	state, err := c.Execute(context.Background(), "x1", legs()...)
+	assert.NoError(t, err)
+	assert.Equal(t, exchange.Committed, state.Status)
+	assert.Equal(t, []string{"prepare", "release"}, n.functions("assets"))
+	assert.Equal(t, []string{"prepare", "release"}, n.functions("payments"))
+
+	stored, err := c.State("x1")
+	assert.NoError(t, err)
+	assert.Equal(t, exchange.Committed, stored.Status)
+	assert.Equal(t, [][]byte{[]byte("x1")}, stored.Legs[0].Release.Args)
+
+	_, err = c.Execute(context.Background(), "x1", legs()...)
+	assert.Error(t, err)
+	_, err = c.Execute(context.Background(), "x2", legs()[0])
+	assert.Error(t, err)
+}
+
+func TestExchangeEndorsementFailure(t *testing.T) {
+	n := newNetwork()
+	n.endorseErrs["payments.prepare"] = errors.New("insufficient funds")
+	c := exchange.NewCoordinator(newKVS(t), n)
+
+	state, err := c.Execute(context.Background(), "x1", legs()...)
+	assert.NoError(t, err)
+	assert.Equal(t, exchange.Aborted, state.Status)
+	assert.Contains(t, state.Reason, "insufficient funds")
+	// nothing has been ordered, nothing to compensate
+	assert.Empty(t, n.functions("assets"))
+	assert.Empty(t, n.functions("payments"))
+}
+
+func TestExchangeInvalidEscrow(t *testing.T) {
+	n := newNetwork()
+	n.set("payments.prepare", exchange.Invalid)
+	c := exchange.NewCoordinator(newKVS(t), n)
+
+	state, err := c.Execute(context.Background(), "x1", legs()...)
+	assert.NoError(t, err)
+	assert.Equal(t, exchange.Aborted, state.Status)
+	assert.Equal(t, []string{"prepare", "refund"}, n.functions("assets"))
+	assert.Equal(t, []string{"prepare"}, n.functions("payments"))
+}
+
+func TestExchangeRecoveryAfterRestart(t *testing.T) {
+	n := newNetwork()
+	kvss := newKVS(t)
+	n.set("payments.prepare", exchange.Unknown)
+
+	// the node stops while waiting for the finality of the escrows
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	state, err := exchange.NewCoordinator(kvss, n).Execute(ctx, "x1", legs()...)
+	assert.Error(t, err)
+	assert.Equal(t, exchange.Preparing, state.Status)
+
+	// after the restart, the escrow turns out to be committed, the watchdog completes the exchange
+	n.set("payments.prepare", exchange.Valid)
+	n.endorseErrs["payments.release"] = errors.New("peer unavailable")
+	c := exchange.NewCoordinator(kvss, n)
+	c.Recover(context.Background())
+	state, err = c.State("x1")
+	assert.NoError(t, err)
+	assert.Equal(t, exchange.Committing, state.Status)
+
+	delete(n.endorseErrs, "payments.release")
+	c.Recover(context.Background())
+	state, err = c.State("x1")
+	assert.NoError(t, err)
+	assert.Equal(t, exchange.Committed, state.Status)
+	assert.Equal(t, []string{"prepare", "release"}, n.functions("assets"))
+	assert.Equal(t, []string{"prepare", "release"}, n.functions("payments"))
+}
+
+func TestExchangeWatchdogReversesStuckExchanges(t *testing.T) {
+	n := newNetwork()
+	kvss := newKVS(t)
+	n.set("payments.prepare", exchange.Unknown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := exchange.NewCoordinator(kvss, n, exchange.WithTimeout(50*time.Millisecond)).Execute(ctx, "x1", legs()...)
+	assert.Error(t, err)
+
+	c := exchange.NewCoordinator(kvss, n, exchange.WithWatchdogInterval(10*time.Millisecond))
+	watchdog, stop := context.WithCancel(context.Background())
+	defer stop()
+	go c.Start(watchdog)
+	assert.Eventually(t, func() bool {
+		state, err := c.State("x1")
+		return err == nil && state.Status == exchange.Aborted
+	}, 5*time.Second, 10*time.Millisecond)
+	// the escrow not final by the deadline is refunded too, in case it commits later
+	assert.Equal(t, []string{"prepare", "refund"}, n.functions("assets"))
+	assert.Equal(t, []string{"prepare", "refund"}, n.functions("payments"))
+}
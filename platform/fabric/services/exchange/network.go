@@ -0,0 +1,107 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package exchange
+
+import (
+	"context"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/pkg/errors"
+)
+
+// TxStatus is the status of a transaction of an exchange, as known by the coordinator
+type TxStatus string
+
+const (
+	// Unknown means that the transaction is not final yet, or it has never been ordered
+	Unknown TxStatus = "unknown"
+	// Valid means that the transaction has been committed
+	Valid TxStatus = "valid"
+	// Invalid means that the transaction has been rejected at validation time
+	Invalid TxStatus = "invalid"
+)
+
+// Network submits the transactions of the exchange legs to their networks
+type Network interface {
+	// Endorse collects the endorsements for the passed invocation of the chaincode of the leg.
+	// It returns the id of the transaction and the envelope to be ordered.
+	Endorse(leg *Leg, invocation *Invocation) (string, *fabric.Envelope, error)
+	// Order submits the passed envelope to the ordering service of the network of the leg
+	Order(leg *Leg, envelope *fabric.Envelope) error
+	// Status waits, respecting the passed context, until the passed transaction is final, and returns its status.
+	// Unknown is returned if the transaction is not final when the context is done.
+	Status(ctx context.Context, leg *Leg, txID string) TxStatus
+}
+
+type fabricNetwork struct {
+	sp view2.ServiceProvider
+}
+
+// NewFabricNetwork returns a Network backed by the fabric network services of the passed service provider
+func NewFabricNetwork(sp view2.ServiceProvider) Network {
+	return &fabricNetwork{sp: sp}
+}
+
+func (n *fabricNetwork) channel(leg *Leg) (*fabric.NetworkService, *fabric.Channel, error) {
+	fns := fabric.GetFabricNetworkService(n.sp, leg.Network)
+	if fns == nil {
+		return nil, nil, errors.Errorf("fabric network service [%s] not found", leg.Network)
+	}
+	ch, err := fns.Channel(leg.Channel)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "failed getting channel [%s:%s]", leg.Network, leg.Channel)
+	}
+	return fns, ch, nil
+}
+
+func (n *fabricNetwork) Endorse(leg *Leg, invocation *Invocation) (string, *fabric.Envelope, error) {
+	fns, ch, err := n.channel(leg)
+	if err != nil {
+		return "", nil, err
+	}
+	args := make([]interface{}, len(invocation.Args))
+	for i, arg := range invocation.Args {
+		args[i] = arg
+	}
+	endorse := ch.Chaincode(leg.Chaincode).Endorse(invocation.Function, args...).
+		WithInvokerIdentity(fns.IdentityProvider().DefaultIdentity())
+	for k, v := range invocation.TransientMap {
+		endorse.WithTransientEntry(k, v)
+	}
+	if len(invocation.EndorsersMSPIDs) != 0 {
+		endorse.WithEndorsersByMSPIDs(invocation.EndorsersMSPIDs...)
+	}
+	env, err := endorse.Call()
+	if err != nil {
+		return "", nil, errors.WithMessagef(err, "failed endorsing [%s] on [%s:%s:%s]", invocation.Function, leg.Network, leg.Channel, leg.Chaincode)
+	}
+	return env.TxID(), env, nil
+}
+
+func (n *fabricNetwork) Order(leg *Leg, envelope *fabric.Envelope) error {
+	fns, _, err := n.channel(leg)
+	if err != nil {
+		return err
+	}
+	return fns.Ordering().Broadcast(envelope)
+}
+
+func (n *fabricNetwork) Status(ctx context.Context, leg *Leg, txID string) TxStatus {
+	_, ch, err := n.channel(leg)
+	if err != nil {
+		logger.Errorf("cannot check the status of [%s]: [%s]", txID, err)
+		return Unknown
+	}
+	if err := ch.Finality().IsFinal(ctx, txID); err == nil {
+		return Valid
+	}
+	if code, _, err := ch.Vault().Status(txID); err == nil && code == fabric.Invalid {
+		return Invalid
+	}
+	return Unknown
+}
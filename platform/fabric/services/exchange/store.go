@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package exchange
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+)
+
+const statePrefix = "fsc.fabric.exchange"
+
+// store keeps the coordination state of the exchanges in the KVS
+type store struct {
+	kvs *kvs.KVS
+}
+
+func (s *store) key(exchangeID string) (string, error) {
+	k, err := kvs.CreateCompositeKey(statePrefix, []string{exchangeID})
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed creating state key for exchange [%s]", exchangeID)
+	}
+	return k, nil
+}
+
+func (s *store) Exists(exchangeID string) bool {
+	k, err := s.key(exchangeID)
+	if err != nil {
+		return false
+	}
+	return s.kvs.Exists(k)
+}
+
+func (s *store) Put(state *State) error {
+	k, err := s.key(state.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.kvs.Put(k, state); err != nil {
+		return errors.WithMessagef(err, "failed storing state of exchange [%s]", state.ID)
+	}
+	return nil
+}
+
+func (s *store) Get(exchangeID string) (*State, error) {
+	k, err := s.key(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := s.kvs.Get(k, state); err != nil {
+		return nil, errors.WithMessagef(err, "failed loading state of exchange [%s]", exchangeID)
+	}
+	return state, nil
+}
+
+func (s *store) List() ([]*State, error) {
+	it, err := s.kvs.GetByPartialCompositeID(statePrefix, []string{})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed listing exchanges")
+	}
+	defer it.Close()
+	var states []*State
+	for it.HasNext() {
+		state := &State{}
+		if _, err := it.Next(state); err != nil {
+			return nil, errors.WithMessagef(err, "failed loading exchange state")
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
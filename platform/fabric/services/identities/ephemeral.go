@@ -0,0 +1,438 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identities
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca"
+	x5092 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/x509"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.services.identities")
+
+const (
+	// X509 identities are enrolled from the Fabric CA of the network
+	X509 = "x509"
+	// Idemix identities are pseudonyms derived from an idemix credential of this node
+	Idemix = "idemix"
+
+	// DefaultTTL is the time an ephemeral identity lives, at most, when neither the options nor the configuration set it
+	DefaultTTL = 10 * time.Minute
+
+	ephemeralPrefix = "fsc.fabric.identities.ephemeral"
+	collectTimeout  = time.Minute
+)
+
+var serviceType = reflect.TypeOf((*Service)(nil))
+
+// Options select the kind of ephemeral identity and its lifetime
+type Options struct {
+	// Network is the fabric network the identity belongs to, the default one if empty
+	Network string
+	// Kind is either X509 (default) or Idemix
+	Kind string
+	// IdemixLabel is the label of the idemix identity the pseudonyms are derived from, required for Idemix
+	IdemixLabel string
+	// TTL is the time after which the identity is disposed, even if the flow did not complete.
+	// If not positive, `fabric.<network>.ephemeral.ttl` or DefaultTTL.
+	TTL time.Duration
+}
+
+// CAConfig configures the Fabric CA the ephemeral x509 identities of a network are enrolled from.
+// It is read from `fabric.<network>.ephemeral.ca`.
+type CAConfig struct {
+	// URL is the address of the CA, for instance https://ca.org1.example.com:7054
+	URL string `yaml:"url"`
+	// Name is the name of the CA, if the server hosts more than one
+	Name string `yaml:"name,omitempty"`
+	// TLSCACerts are the paths of the certificates of the CAs the TLS certificate of the server is verified against
+	TLSCACerts []string `yaml:"tlsCACerts,omitempty"`
+	// Affiliation is the affiliation the ephemeral identities are registered with
+	Affiliation string `yaml:"affiliation,omitempty"`
+	// Registrar is the identity, allowed to register and revoke identities, the requests of this node are signed with
+	Registrar struct {
+		// MSPID is the MSP of the registrar
+		MSPID string `yaml:"mspID"`
+		// Path is the path of the MSP folder of the registrar
+		Path string `yaml:"path"`
+	} `yaml:"registrar"`
+	// Timeout is the time each request has, ca.DefaultTimeout if not positive
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// SignerRegistry binds the identities of this node to their signers
+type SignerRegistry interface {
+	RegisterSigner(identity view.Identity, signer view2.Signer, verifier view2.Verifier) error
+	DeregisterSigner(identity view.Identity) error
+}
+
+// record is stored in the KVS while an ephemeral identity is alive, so that it can be disposed
+// at the next startup if the node stops before
+type record struct {
+	Network      string
+	Kind         string
+	EnrollmentID string
+	Identity     view.Identity
+	Expires      time.Time
+}
+
+// Ephemeral is a temporary identity of this node, disposed when the flow it has been created for completes,
+// or when its TTL expires, whichever happens first.
+// It can be used as the creator of the transactions, see fabric.WithCreator.
+type Ephemeral struct {
+	record
+	service *Service
+	sk      *ecdsa.PrivateKey
+
+	once     sync.Once
+	disposed chan struct{}
+	err      error
+}
+
+// Identity returns the identity
+func (e *Ephemeral) Identity() view.Identity {
+	return e.record.Identity
+}
+
+// EnrollmentID returns the enrollment ID of an X509 identity, empty for the Idemix ones
+func (e *Ephemeral) EnrollmentID() string {
+	return e.record.EnrollmentID
+}
+
+// Expires returns the time the identity gets disposed, at the latest
+func (e *Ephemeral) Expires() time.Time {
+	return e.record.Expires
+}
+
+// Dispose disposes the identity now: the X509 identities are revoked, the signer is deregistered, and the private key wiped.
+// Only the first call has an effect, the next ones return its result.
+func (e *Ephemeral) Dispose() error {
+	e.once.Do(func() {
+		e.err = e.service.dispose(&e.record)
+		if e.sk != nil {
+			// best effort, the runtime might hold copies of the key
+			e.sk.D.SetInt64(0)
+		}
+		close(e.disposed)
+	})
+	return e.err
+}
+
+// NewEphemeral returns an ephemeral identity, disposed when the flow running in the passed context completes
+func NewEphemeral(context view.Context, opts Options) (*Ephemeral, error) {
+	s, err := GetService(context)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewEphemeral(context.Context(), opts)
+}
+
+// Service creates the ephemeral identities and disposes them
+type Service struct {
+	sp      view2.ServiceProvider
+	kvs     *kvs.KVS
+	signers SignerRegistry
+
+	lock sync.Mutex
+	cas  map[string]*authority
+}
+
+// authority is the CA of a network, with the registrar and the affiliation of the ephemeral identities
+type authority struct {
+	client      *ca.Client
+	registrar   ca.Signer
+	affiliation string
+	mspID       string
+}
+
+// NewService returns a service storing the record of the live ephemeral identities in the passed KVS
+func NewService(sp view2.ServiceProvider, kvss *kvs.KVS, signers SignerRegistry) *Service {
+	return &Service{sp: sp, kvs: kvss, signers: signers, cas: map[string]*authority{}}
+}
+
+// GetService returns the ephemeral identities service registered in the passed service provider
+func GetService(sp view2.ServiceProvider) (*Service, error) {
+	s, err := sp.GetService(serviceType)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get ephemeral identities service")
+	}
+	return s.(*Service), nil
+}
+
+// NewEphemeral returns an ephemeral identity, disposed when the passed context is done or its TTL expires
+func (s *Service) NewEphemeral(ctx context.Context, opts Options) (*Ephemeral, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	fns := fabric.GetFabricNetworkService(s.sp, opts.Network)
+	if fns == nil {
+		return nil, errors.Errorf("fabric network [%s] not found", opts.Network)
+	}
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = fns.ConfigService().GetDuration("ephemeral.ttl")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	e := &Ephemeral{
+		record:   record{Network: fns.Name(), Kind: opts.Kind, Expires: time.Now().Add(ttl)},
+		service:  s,
+		disposed: make(chan struct{}),
+	}
+	switch opts.Kind {
+	case "", X509:
+		e.Kind = X509
+		if err := s.enroll(ctx, fns, e); err != nil {
+			return nil, err
+		}
+	case Idemix:
+		info := fns.LocalMembership().GetIdentityInfoByLabel(Idemix, opts.IdemixLabel)
+		if info == nil {
+			return nil, errors.Errorf("idemix identity [%s] not found in network [%s]", opts.IdemixLabel, fns.Name())
+		}
+		// the pseudonym gets its signer registered
+		id, _, err := info.GetIdentity()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed deriving pseudonym from [%s]", opts.IdemixLabel)
+		}
+		e.record.Identity = id
+		if err := s.put(&e.record); err != nil {
+			if err1 := s.signers.DeregisterSigner(id); err1 != nil {
+				logger.Errorf("failed deregistering pseudonym [%s]: [%s]", id, err1)
+			}
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("invalid ephemeral identity kind [%s], expected [%s] or [%s]", opts.Kind, X509, Idemix)
+	}
+
+	go func() {
+		timer := time.NewTimer(ttl)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+			logger.Infof("ephemeral identity [%s] expired", e.record.Identity)
+		case <-e.disposed:
+			return
+		}
+		if err := e.Dispose(); err != nil {
+			logger.Errorf("failed disposing ephemeral identity [%s], it will be disposed at the next startup: [%s]", e.record.Identity, err)
+		}
+	}()
+	logger.Debugf("ephemeral identity [%s] of kind [%s] created, expires at [%s]", e.record.Identity, e.Kind, e.record.Expires)
+	return e, nil
+}
+
+// Collect disposes the ephemeral identities left by a previous run of this node, it must be called on startup
+func (s *Service) Collect() (int, error) {
+	it, err := s.kvs.GetByPartialCompositeID(ephemeralPrefix, []string{})
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed listing ephemeral identities")
+	}
+	var records []*record
+	for it.HasNext() {
+		r := &record{}
+		if _, err := it.Next(r); err != nil {
+			it.Close()
+			return 0, errors.WithMessagef(err, "failed loading ephemeral identity")
+		}
+		records = append(records, r)
+	}
+	it.Close()
+
+	collected := 0
+	var lastErr error
+	for _, r := range records {
+		if err := s.dispose(r); err != nil {
+			logger.Errorf("failed disposing leaked ephemeral identity [%s]: [%s]", r.Identity, err)
+			lastErr = err
+			continue
+		}
+		collected++
+	}
+	if collected != 0 {
+		logger.Infof("disposed [%d] ephemeral identities leaked by a previous run", collected)
+	}
+	return collected, lastErr
+}
+
+// enroll registers and enrolls a fresh identity with the CA of the network, and registers its signer
+func (s *Service) enroll(ctx context.Context, fns *fabric.NetworkService, e *Ephemeral) error {
+	a, err := s.authority(fns)
+	if err != nil {
+		return err
+	}
+	enrollmentID, err := randomHex("ephemeral-")
+	if err != nil {
+		return err
+	}
+	secret, err := randomHex("")
+	if err != nil {
+		return err
+	}
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return errors.Wrapf(err, "failed generating key")
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: enrollmentID}}, sk)
+	if err != nil {
+		return errors.Wrapf(err, "failed creating certificate request")
+	}
+
+	e.record.EnrollmentID, e.sk = enrollmentID, sk
+	// recorded before the registration, so that the identity is revoked even if the node stops in the middle
+	if err := s.put(&e.record); err != nil {
+		return err
+	}
+	dispose := func(err error) error {
+		if err1 := e.Dispose(); err1 != nil {
+			logger.Errorf("failed disposing [%s], it will be disposed at the next startup: [%s]", enrollmentID, err1)
+		}
+		return err
+	}
+	if err := a.client.Register(ctx, a.registrar, enrollmentID, secret, a.affiliation); err != nil {
+		return dispose(err)
+	}
+	enrollment, err := a.client.Enroll(ctx, enrollmentID, secret, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}))
+	if err != nil {
+		return dispose(err)
+	}
+	id, err := x5092.SerializeRaw(a.mspID, enrollment.Cert)
+	if err != nil {
+		return dispose(errors.WithMessagef(err, "failed serializing [%s]", enrollmentID))
+	}
+	e.record.Identity = id
+	if err := s.put(&e.record); err != nil {
+		return dispose(err)
+	}
+	if err := s.signers.RegisterSigner(id, x5092.NewEcdsaSigner(sk), x5092.NewVerifier(&sk.PublicKey)); err != nil {
+		return dispose(errors.WithMessagef(err, "failed registering signer of [%s]", enrollmentID))
+	}
+	return nil
+}
+
+// dispose revokes the X509 identities, deregisters the signer, and removes the record
+func (s *Service) dispose(r *record) error {
+	if r.Kind == X509 && len(r.EnrollmentID) != 0 {
+		fns := fabric.GetFabricNetworkService(s.sp, r.Network)
+		if fns == nil {
+			return errors.Errorf("fabric network [%s] not found", r.Network)
+		}
+		a, err := s.authority(fns)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+		err = a.client.Revoke(ctx, a.registrar, r.EnrollmentID)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	if len(r.Identity) != 0 {
+		if err := s.signers.DeregisterSigner(r.Identity); err != nil {
+			return errors.WithMessagef(err, "failed deregistering signer of [%s]", r.Identity)
+		}
+	}
+	k, err := s.key(r)
+	if err != nil {
+		return err
+	}
+	if err := s.kvs.Delete(k); err != nil {
+		return errors.WithMessagef(err, "failed deleting record of ephemeral identity [%s]", r.Identity)
+	}
+	logger.Debugf("ephemeral identity [%s] disposed", r.Identity)
+	return nil
+}
+
+// authority returns the CA of the passed network
+func (s *Service) authority(fns *fabric.NetworkService) (*authority, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if a, ok := s.cas[fns.Name()]; ok {
+		return a, nil
+	}
+
+	config := &CAConfig{}
+	if err := fns.ConfigService().UnmarshalKey("ephemeral.ca", config); err != nil {
+		return nil, errors.Wrapf(err, "failed loading the CA configuration of network [%s]", fns.Name())
+	}
+	if len(config.URL) == 0 {
+		return nil, errors.Errorf("no CA configured for the ephemeral identities of network [%s]", fns.Name())
+	}
+	registrar, err := x5092.GetSigningIdentity(fns.ConfigService().TranslatePath(config.Registrar.Path), config.Registrar.MSPID, nil)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed loading the registrar of network [%s]", fns.Name())
+	}
+	var paths []string
+	for _, path := range config.TLSCACerts {
+		paths = append(paths, fns.ConfigService().TranslatePath(path))
+	}
+	rootCAs, err := ca.LoadRootCAs(paths)
+	if err != nil {
+		return nil, err
+	}
+	a := &authority{
+		client:      ca.NewClient(config.URL, config.Name, rootCAs, config.Timeout),
+		registrar:   registrar,
+		affiliation: config.Affiliation,
+		mspID:       config.Registrar.MSPID,
+	}
+	s.cas[fns.Name()] = a
+	return a, nil
+}
+
+func (s *Service) key(r *record) (string, error) {
+	id := r.EnrollmentID
+	if len(id) == 0 {
+		id = r.Identity.UniqueID()
+	}
+	k, err := kvs.CreateCompositeKey(ephemeralPrefix, []string{r.Network, id})
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed creating key of ephemeral identity [%s]", id)
+	}
+	return k, nil
+}
+
+func (s *Service) put(r *record) error {
+	k, err := s.key(r)
+	if err != nil {
+		return err
+	}
+	if err := s.kvs.Put(k, r); err != nil {
+		return errors.WithMessagef(err, "failed storing record of ephemeral identity [%s]", r.Identity)
+	}
+	return nil
+}
+
+func randomHex(prefix string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrapf(err, "failed generating randomness")
+	}
+	return prefix + hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identities
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca"
+	camock "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/ca/mock"
+	x5092 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/x509"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	sig2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCAClient(t *testing.T) {
+	fake, err := camock.NewCA()
+	assert.NoError(t, err)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	c := ca.NewClient(server.URL, "", nil, 0)
+	ctx := context.Background()
+
+	// an ephemeral identity is registered by the registrar, and enrolled once with its secret
+	assert.NoError(t, c.Register(ctx, fake.Registrar, "alice", "secret", "org1"))
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "alice"}}, sk)
+	assert.NoError(t, err)
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+
+	_, err = c.Enroll(ctx, "alice", "wrong", csrPEM)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid credentials")
+
+	enrollment, err := c.Enroll(ctx, "alice", "secret", csrPEM)
+	assert.NoError(t, err)
+	id, err := x5092.SerializeRaw("Org1MSP", enrollment.Cert)
+	assert.NoError(t, err)
+	sigma, err := x5092.NewEcdsaSigner(sk).Sign([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, x5092.NewVerifier(&sk.PublicKey).Verify([]byte("hello"), sigma))
+	assert.NotEmpty(t, id)
+
+	assert.NoError(t, c.Revoke(ctx, fake.Registrar, "alice"))
+	assert.Equal(t, []string{"alice"}, fake.Revoked())
+
+	// the tokens of another registrar are rejected
+	other, err := camock.NewCA()
+	assert.NoError(t, err)
+	assert.Error(t, c.Revoke(ctx, other.Registrar, "alice"))
+}
+
+func TestCollect(t *testing.T) {
+	registry := registry2.New()
+	kvss, err := kvs.NewWithConfig(registry, "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+	sigService := sig2.NewSignService(registry, nil, kvss)
+	assert.NoError(t, registry.RegisterService(sigService))
+	signers := view2.GetSigService(registry)
+	s := NewService(registry, kvss, signers)
+
+	// a pseudonym leaked by a previous run
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	id := []byte("pseudonym")
+	assert.NoError(t, signers.RegisterSigner(id, x5092.NewEcdsaSigner(sk), x5092.NewVerifier(&sk.PublicKey)))
+	assert.NoError(t, s.put(&record{Network: "default", Kind: Idemix, Identity: id, Expires: time.Now()}))
+
+	s = NewService(registry, kvss, signers)
+	collected, err := s.Collect()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, collected)
+	_, err = signers.GetSigner(id)
+	assert.Error(t, err)
+
+	collected, err = s.Collect()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, collected)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/importer"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns the Cobra Command for the connection profile utilities
+func NewCmd() *cobra.Command {
+	rootCommand := &cobra.Command{
+		Use:   "profile",
+		Short: "Connection profile utils.",
+		Long:  `Utilities for the connection profiles and the wallets of the Fabric SDKs.`,
+	}
+
+	rootCommand.AddCommand(
+		newImportCmd(),
+	)
+
+	return rootCommand
+}
+
+func newImportCmd() *cobra.Command {
+	var profileFile, walletDir, outDir string
+	var caCertFiles []string
+	opts := importer.Options{}
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import a connection profile and a wallet.",
+		Long:  `Import a connection profile and a file-system wallet into the fabric section of the configuration of a node, and the MSP folders of its identities. The features not imported are reported as warnings.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(profileFile) == 0 || len(walletDir) == 0 || len(outDir) == 0 {
+				return errors.New("the connection profile, the wallet and the output directory must be set")
+			}
+			for _, f := range caCertFiles {
+				raw, err := ioutil.ReadFile(f)
+				if err != nil {
+					return errors.Wrapf(err, "failed reading certificate authority certificate")
+				}
+				opts.CACerts = append(opts.CACerts, raw)
+			}
+			res, err := importer.ImportFiles(profileFile, walletDir, outDir, opts)
+			if err != nil {
+				return err
+			}
+			for _, w := range res.Warnings {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
+			}
+			fmt.Printf("configuration written in [%s], merge it in the core.yaml of the node\n", filepath.Join(outDir, importer.ConfigFile))
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&profileFile, "profile", "p", "", "Sets the file of the connection profile, YAML or JSON")
+	flags.StringVarP(&walletDir, "wallet", "w", "", "Sets the directory of the file-system wallet")
+	flags.StringVarP(&outDir, "output", "o", "", "Sets the output directory")
+	flags.StringVarP(&opts.Network, "network", "n", importer.DefaultNetwork, "Sets the name of the fabric network")
+	flags.StringVar(&opts.Organization, "org", "", "Sets the organization of the node, the one of the client of the profile if not set")
+	flags.StringVarP(&opts.Identity, "identity", "i", "", "Sets the label of the default identity of the node")
+	flags.StringArrayVarP(&opts.Channels, "channel", "c", nil, "Adds a channel, the first one is the default")
+	flags.StringArrayVar(&caCertFiles, "ca-cert", nil, "Adds the certificate of the certificate authority issuing the wallet identities")
+	return cmd
+}
@@ -0,0 +1,649 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package importer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultNetwork is the name of the fabric network of the FSC configuration, if not set
+	DefaultNetwork = "default"
+	// ConfigFile is the file, in the output directory, of the fabric section of the FSC configuration
+	ConfigFile = "fabric.yaml"
+
+	defaultConnectionTimeout = "10s"
+)
+
+// Options of an import
+type Options struct {
+	// Network is the name of the fabric network in the FSC configuration, DefaultNetwork if not set
+	Network string
+	// Organization is the organization of the node, in the profile. The organization of the client if not set.
+	Organization string
+	// Identity is the label of the wallet identity that is the default MSP of the node.
+	// The first identity, by label, of the MSP of the organization if not set.
+	Identity string
+	// Channels are the channels of the node on top of the ones of the profile, the first one is the default.
+	// The first channel of the profile, by name, is the default if not set.
+	Channels []string
+	// CACerts are the PEM encoded certificates of the certificate authorities issuing the wallet identities,
+	// on top of the ones of the certificate authorities of the profile
+	CACerts [][]byte
+}
+
+// Result of an import
+type Result struct {
+	// Config is the fabric section of the FSC configuration, in YAML, to be merged in the core.yaml of the node
+	Config []byte
+	// Warnings are the features of the profile and of the wallet that are not imported
+	Warnings []string
+}
+
+// Output layout of an import
+type network struct {
+	Default     bool         `yaml:"default"`
+	DefaultMSP  string       `yaml:"defaultMSP"`
+	MSPs        []msp        `yaml:"msps"`
+	TLS         tls          `yaml:"tls"`
+	Orderers    []node       `yaml:"orderers,omitempty"`
+	Peers       []node       `yaml:"peers,omitempty"`
+	Endorsement *endorsement `yaml:"endorsement,omitempty"`
+	Channels    []channel    `yaml:"channels,omitempty"`
+	Vault       vault        `yaml:"vault"`
+}
+
+type msp struct {
+	ID      string `yaml:"id"`
+	MSPType string `yaml:"mspType"`
+	MSPID   string `yaml:"mspID"`
+	Path    string `yaml:"path"`
+}
+
+type file struct {
+	File string `yaml:"file"`
+}
+
+type tls struct {
+	Enabled            bool  `yaml:"enabled"`
+	ClientAuthRequired bool  `yaml:"clientAuthRequired"`
+	ClientCert         *file `yaml:"clientCert,omitempty"`
+	ClientKey          *file `yaml:"clientKey,omitempty"`
+}
+
+type node struct {
+	Address            string `yaml:"address"`
+	ConnectionTimeout  string `yaml:"connectionTimeout"`
+	TLSRootCertFile    string `yaml:"tlsRootCertFile,omitempty"`
+	ServerNameOverride string `yaml:"serverNameOverride,omitempty"`
+}
+
+type foreignPeer struct {
+	MSPID   string `yaml:"mspID"`
+	Address string `yaml:"address"`
+}
+
+type endorsement struct {
+	ForeignOrgs []string      `yaml:"foreignOrgs"`
+	Peers       []foreignPeer `yaml:"peers,omitempty"`
+}
+
+type channel struct {
+	Name    string `yaml:"Name"`
+	Default bool   `yaml:"Default"`
+}
+
+type vault struct {
+	Persistence struct {
+		Type string `yaml:"type"`
+		Opts struct {
+			Path string `yaml:"path"`
+		} `yaml:"opts"`
+	} `yaml:"persistence"`
+}
+
+// ImportFiles imports the connection profile and the file-system wallet at the passed paths, see Import.
+// The fabric section of the FSC configuration is written in ConfigFile, in the output directory.
+func ImportFiles(profilePath, walletDir, outDir string, opts Options) (*Result, error) {
+	profile, err := ReadProfile(profilePath)
+	if err != nil {
+		return nil, err
+	}
+	identities, warnings, err := ReadWallet(walletDir)
+	if err != nil {
+		return nil, err
+	}
+	res, err := Import(profile, identities, outDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Warnings = append(warnings, res.Warnings...)
+	if err := ioutil.WriteFile(filepath.Join(outDir, ConfigFile), res.Config, 0644); err != nil {
+		return nil, errors.Wrapf(err, "failed writing configuration")
+	}
+	return res, nil
+}
+
+// Import maps the passed connection profile and wallet identities to the fabric section of the FSC configuration.
+// The MSP folders of the identities and the TLS certificates are written in the output directory,
+// the configuration refers to them by absolute path. The peers of the organization of the node are its trusted peers,
+// the peers of the other organizations are used for endorsement, the orderers and the channels are mapped as they are.
+func Import(profile *ConnectionProfile, identities []*WalletIdentity, outDir string, opts Options) (*Result, error) {
+	outDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid output directory")
+	}
+	im := &importer{profile: profile, outDir: outDir, network: &network{Default: true}}
+	if len(opts.Network) == 0 {
+		opts.Network = DefaultNetwork
+	}
+	if len(opts.Organization) == 0 {
+		opts.Organization = profile.Client.Organization
+	}
+	org, ok := profile.Organizations[opts.Organization]
+	if !ok || org == nil {
+		return nil, errors.Errorf("organization [%s] not found in the profile", opts.Organization)
+	}
+	if len(org.MSPID) == 0 {
+		return nil, errors.Errorf("organization [%s] has no mspid", opts.Organization)
+	}
+
+	im.checkUnsupported(opts.Organization)
+	if err := im.importIdentities(org, identities, opts); err != nil {
+		return nil, err
+	}
+	if err := im.importPeers(opts.Organization, org); err != nil {
+		return nil, err
+	}
+	if err := im.importOrderers(); err != nil {
+		return nil, err
+	}
+	if err := im.importClientTLS(); err != nil {
+		return nil, err
+	}
+	im.importChannels(opts.Channels)
+	im.network.Vault.Persistence.Type = "badger"
+	im.network.Vault.Persistence.Opts.Path = filepath.Join(outDir, "vault")
+
+	raw, err := yaml.Marshal(yaml.MapSlice{{Key: "fabric", Value: yaml.MapSlice{
+		{Key: "enabled", Value: true},
+		{Key: opts.Network, Value: im.network},
+	}}})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling configuration")
+	}
+	return &Result{Config: raw, Warnings: im.warnings}, nil
+}
+
+type importer struct {
+	profile  *ConnectionProfile
+	outDir   string
+	network  *network
+	warnings []string
+	// tls records the schemes of the endpoints, by whether they use TLS
+	tls map[bool][]string
+}
+
+func (im *importer) warn(format string, args ...interface{}) {
+	im.warnings = append(im.warnings, fmt.Sprintf(format, args...))
+}
+
+// checkUnsupported warns about the sections of the profile that have no counterpart in FSC
+func (im *importer) checkUnsupported(orgName string) {
+	p := im.profile
+	if len(p.EntityMatchers) != 0 {
+		im.warn("entityMatchers not supported, use the addressOverrides of the network to redirect the connections")
+	}
+	if len(p.Client.Connection) != 0 {
+		im.warn("client.connection timeouts not imported, the connections of FSC time out after %s", defaultConnectionTimeout)
+	}
+	if len(p.Client.CryptoConfig) != 0 || len(p.Client.CredentialStore) != 0 {
+		im.warn("client.cryptoconfig and client.credentialStore ignored, the identities are taken from the wallet")
+	}
+	if provider := lookup(p.Client.BCCSP, "security", "default", "provider"); provider != nil && provider != "SW" {
+		im.warn("client.BCCSP provider [%v] not imported, the keys of the wallet are software keys, see the BCCSP opts of the msps for PKCS11", provider)
+	}
+	for _, name := range sortedKeys(p.Organizations) {
+		org := p.Organizations[name]
+		if org == nil {
+			continue
+		}
+		if len(org.Users) != 0 || len(org.CryptoPath) != 0 || org.AdminPrivateKey != nil || org.SignedCert != nil {
+			im.warn("users and credentials of organization [%s] ignored, the identities are taken from the wallet", name)
+		}
+	}
+	for _, name := range sortedKeys(p.CertificateAuthorities) {
+		ca := p.CertificateAuthorities[name]
+		if ca != nil && len(ca.Registrar) != 0 {
+			im.warn("registrar of certificate authority [%s] ignored, see the ephemeral ca of the network to register identities", name)
+		}
+	}
+}
+
+// importIdentities writes the MSP folders of the wallet identities
+func (im *importer) importIdentities(org *Organization, identities []*WalletIdentity, opts Options) error {
+	cas, err := im.certificateAuthorities(opts.CACerts)
+	if err != nil {
+		return err
+	}
+	for _, id := range identities {
+		if len(id.Label) == 0 || id.Label != filepath.Base(id.Label) || strings.HasPrefix(id.Label, ".") {
+			return errors.Errorf("invalid wallet identity label [%s]", id.Label)
+		}
+		cert, err := parseCertificate(id.Certificate)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid certificate of wallet identity [%s]", id.Label)
+		}
+		keyFile, err := checkKeyPair(cert, id.PrivateKey)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid private key of wallet identity [%s]", id.Label)
+		}
+		var issuer *x509.Certificate
+		for _, ca := range cas {
+			if bytes.Equal(cert.RawIssuer, ca.RawSubject) && cert.CheckSignatureFrom(ca) == nil {
+				issuer = ca
+				break
+			}
+		}
+		if issuer == nil {
+			return errors.Errorf("no certificate authority of the profile issued the certificate of wallet identity [%s], pass the certificate of its issuer", id.Label)
+		}
+
+		dir := filepath.Join(im.outDir, "msps", id.Label)
+		for _, f := range []struct {
+			path string
+			raw  []byte
+			perm os.FileMode
+		}{
+			{filepath.Join(dir, "signcerts", "cert.pem"), id.Certificate, 0644},
+			{filepath.Join(dir, "keystore", keyFile), id.PrivateKey, 0600},
+			{filepath.Join(dir, "cacerts", "ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer.Raw}), 0644},
+		} {
+			if err := writeFile(f.path, f.raw, f.perm); err != nil {
+				return err
+			}
+		}
+		im.network.MSPs = append(im.network.MSPs, msp{ID: id.Label, MSPType: "bccsp", MSPID: id.MSPID, Path: dir})
+
+		if len(opts.Identity) == 0 && len(im.network.DefaultMSP) == 0 && id.MSPID == org.MSPID {
+			im.network.DefaultMSP = id.Label
+		}
+	}
+	if len(opts.Identity) != 0 {
+		for _, m := range im.network.MSPs {
+			if m.ID == opts.Identity {
+				im.network.DefaultMSP = m.ID
+				if m.MSPID != org.MSPID {
+					im.warn("identity [%s] of msp [%s] is not of the organization of the node, [%s]", m.ID, m.MSPID, org.MSPID)
+				}
+			}
+		}
+		if len(im.network.DefaultMSP) == 0 {
+			return errors.Errorf("identity [%s] not found in the wallet", opts.Identity)
+		}
+	}
+	if len(im.network.DefaultMSP) == 0 {
+		return errors.Errorf("no identity of msp [%s] in the wallet", org.MSPID)
+	}
+	return nil
+}
+
+// certificateAuthorities returns the certificates of the certificate authorities of the profile, and the passed ones
+func (im *importer) certificateAuthorities(extra [][]byte) ([]*x509.Certificate, error) {
+	var res []*x509.Certificate
+	for _, name := range sortedKeys(im.profile.CertificateAuthorities) {
+		ca := im.profile.CertificateAuthorities[name]
+		if ca == nil {
+			continue
+		}
+		raw, err := im.profile.load(ca.TLSCACerts)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed loading certificates of certificate authority [%s]", name)
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		certs, err := parseCertificates(raw)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid certificates of certificate authority [%s]", name)
+		}
+		res = append(res, certs...)
+	}
+	for _, raw := range extra {
+		certs, err := parseCertificates(raw)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid certificate authority certificate")
+		}
+		res = append(res, certs...)
+	}
+	return res, nil
+}
+
+// importPeers maps the peers of the organization of the node to its trusted peers,
+// and the peers of the other organizations to the peers used for endorsement
+func (im *importer) importPeers(orgName string, org *Organization) error {
+	for _, name := range org.Peers {
+		ep, ok := im.profile.Peers[name]
+		if !ok || ep == nil {
+			im.warn("peer [%s] of organization [%s] not found in the profile, skipped", name, orgName)
+			continue
+		}
+		n, err := im.node("peer", name, ep)
+		if err != nil {
+			return err
+		}
+		im.network.Peers = append(im.network.Peers, n)
+	}
+	if len(im.network.Peers) == 0 {
+		return errors.Errorf("organization [%s] has no peers", orgName)
+	}
+
+	e := &endorsement{}
+	for _, name := range sortedKeys(im.profile.Organizations) {
+		other := im.profile.Organizations[name]
+		if name == orgName || other == nil || len(other.MSPID) == 0 {
+			continue
+		}
+		for _, peer := range other.Peers {
+			ep, ok := im.profile.Peers[peer]
+			if !ok || ep == nil {
+				continue
+			}
+			address, _, err := parseURL(ep.URL)
+			if err != nil {
+				return errors.WithMessagef(err, "invalid url of peer [%s]", peer)
+			}
+			e.Peers = append(e.Peers, foreignPeer{MSPID: other.MSPID, Address: address})
+		}
+		if len(other.Peers) != 0 {
+			e.ForeignOrgs = append(e.ForeignOrgs, other.MSPID)
+		}
+	}
+	if len(e.ForeignOrgs) != 0 {
+		im.network.Endorsement = e
+		im.warn("the TLS certificates of the peers of the other organizations are taken from the channel configuration, not from the profile")
+	}
+	return nil
+}
+
+func (im *importer) importOrderers() error {
+	for _, name := range sortedKeys(im.profile.Orderers) {
+		ep := im.profile.Orderers[name]
+		if ep == nil {
+			continue
+		}
+		n, err := im.node("orderer", name, ep)
+		if err != nil {
+			return err
+		}
+		im.network.Orderers = append(im.network.Orderers, n)
+	}
+	if len(im.network.Orderers) == 0 {
+		im.warn("no orderers in the profile, they are taken from the channel configuration")
+	}
+
+	if len(im.tls[true]) != 0 && len(im.tls[false]) != 0 {
+		im.warn("TLS enabled for the whole network, the endpoints %v do not use it", im.tls[false])
+	}
+	im.network.TLS.Enabled = len(im.tls[true]) != 0
+	return nil
+}
+
+func (im *importer) importClientTLS() error {
+	certs := im.profile.Client.TLSCerts
+	if certs == nil {
+		return nil
+	}
+	if certs.SystemCertPool {
+		im.warn("client.tlsCerts.systemCertPool not supported, the TLS certificates of the endpoints are the ones of the profile")
+	}
+	cert, err := im.profile.load(certs.Client.Cert)
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading client TLS certificate")
+	}
+	key, err := im.profile.load(certs.Client.Key)
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading client TLS key")
+	}
+	if len(cert) == 0 || len(key) == 0 {
+		return nil
+	}
+	certFile := filepath.Join(im.outDir, "tls", "client.crt")
+	keyFile := filepath.Join(im.outDir, "tls", "client.key")
+	if err := writeFile(certFile, cert, 0644); err != nil {
+		return err
+	}
+	if err := writeFile(keyFile, key, 0600); err != nil {
+		return err
+	}
+	im.network.TLS.ClientAuthRequired = true
+	im.network.TLS.ClientCert = &file{File: certFile}
+	im.network.TLS.ClientKey = &file{File: keyFile}
+	return nil
+}
+
+// importChannels maps the channels of the profile, and the passed ones, the roles of the peers are not imported
+func (im *importer) importChannels(extra []string) {
+	names := append([]string{}, extra...)
+	for _, name := range sortedKeys(im.profile.Channels) {
+		names = append(names, name)
+		ch := im.profile.Channels[name]
+		if ch == nil {
+			continue
+		}
+		if len(ch.Policies) != 0 {
+			im.warn("policies of channel [%s] not imported", name)
+		}
+		for _, peer := range sortedKeys(ch.Peers) {
+			roles := ch.Peers[peer]
+			if roles == nil {
+				continue
+			}
+			for _, role := range []*bool{roles.EndorsingPeer, roles.ChaincodeQuery, roles.LedgerQuery, roles.EventSource} {
+				if role != nil && !*role {
+					im.warn("roles of peer [%s] in channel [%s] not imported, the trusted peers serve all the roles", peer, name)
+					break
+				}
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		im.network.Channels = append(im.network.Channels, channel{Name: name, Default: len(im.network.Channels) == 0})
+	}
+	if len(im.network.Channels) == 0 {
+		im.warn("no channels in the profile, add them to the channels of the network")
+	}
+}
+
+// node maps a peer or an orderer, its TLS certificates are written in the output directory
+func (im *importer) node(kind, name string, ep *Endpoint) (node, error) {
+	address, secure, err := parseURL(ep.URL)
+	if err != nil {
+		return node{}, errors.WithMessagef(err, "invalid url of %s [%s]", kind, name)
+	}
+	if im.tls == nil {
+		im.tls = map[bool][]string{}
+	}
+	im.tls[secure] = append(im.tls[secure], name)
+
+	n := node{Address: address, ConnectionTimeout: defaultConnectionTimeout}
+	var unsupported []string
+	for _, option := range sortedKeys(ep.GRPCOptions) {
+		switch option {
+		case "ssl-target-name-override", "hostnameOverride":
+			if value, ok := ep.GRPCOptions[option].(string); ok && len(value) != 0 {
+				n.ServerNameOverride = value
+			}
+		default:
+			unsupported = append(unsupported, option)
+		}
+	}
+	if len(unsupported) != 0 {
+		im.warn("grpcOptions %v of %s [%s] not imported, see the keepalive of the network", unsupported, kind, name)
+	}
+
+	raw, err := im.profile.load(ep.TLSCACerts)
+	if err != nil {
+		return node{}, errors.WithMessagef(err, "failed loading TLS certificates of %s [%s]", kind, name)
+	}
+	if len(raw) == 0 {
+		if secure {
+			im.warn("%s [%s] has no TLS certificates, the connections to it fail", kind, name)
+		}
+		return n, nil
+	}
+	if _, err := parseCertificates(raw); err != nil {
+		return node{}, errors.WithMessagef(err, "invalid TLS certificates of %s [%s]", kind, name)
+	}
+	n.TLSRootCertFile = filepath.Join(im.outDir, "tls", name+"-ca.pem")
+	if err := writeFile(n.TLSRootCertFile, raw, 0644); err != nil {
+		return node{}, err
+	}
+	return n, nil
+}
+
+// parseURL returns the address of the passed url, and whether it uses TLS. The urls without scheme use TLS.
+func parseURL(raw string) (string, bool, error) {
+	if !strings.Contains(raw, "://") {
+		if len(raw) == 0 {
+			return "", false, errors.New("empty url")
+		}
+		return raw, true, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed parsing [%s]", raw)
+	}
+	switch u.Scheme {
+	case "grpcs":
+		return u.Host, true, nil
+	case "grpc":
+		return u.Host, false, nil
+	default:
+		return "", false, errors.Errorf("unsupported scheme [%s], expected grpc or grpcs", u.Scheme)
+	}
+}
+
+func parseCertificate(raw []byte) (*x509.Certificate, error) {
+	certs, err := parseCertificates(raw)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+func parseCertificates(raw []byte) ([]*x509.Certificate, error) {
+	var res []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed parsing certificate")
+		}
+		res = append(res, cert)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no PEM encoded certificate")
+	}
+	return res, nil
+}
+
+// checkKeyPair checks that the passed PEM encoded private key is the one of the certificate.
+// It returns the name of the key in the keystore, after its SKI as the software BCCSP expects.
+func checkKeyPair(cert *x509.Certificate, raw []byte) (string, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return "", errors.New("private key is not PEM encoded")
+	}
+	var key interface{}
+	var err error
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return "", errors.Wrap(err, "failed parsing private key")
+		}
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", errors.Errorf("unsupported private key [%T]", key)
+	}
+	type equaler interface {
+		Equal(crypto.PublicKey) bool
+	}
+	if pk, ok := signer.Public().(equaler); !ok || !pk.Equal(cert.PublicKey) {
+		return "", errors.New("private key does not match the certificate")
+	}
+	pk, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return "priv_sk", nil
+	}
+	ski := sha256.Sum256(elliptic.Marshal(pk.Curve, pk.X, pk.Y))
+	return hex.EncodeToString(ski[:]) + "_sk", nil
+}
+
+func writeFile(path string, raw []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "failed creating [%s]", filepath.Dir(path))
+	}
+	if err := ioutil.WriteFile(path, raw, perm); err != nil {
+		return errors.Wrapf(err, "failed writing [%s]", path)
+	}
+	return nil
+}
+
+// lookup returns the value at the passed path of nested maps, nil if not found
+func lookup(m map[string]interface{}, path ...string) interface{} {
+	var current interface{} = m
+	for _, key := range path {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			current = c[key]
+		case map[interface{}]interface{}:
+			current = c[key]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
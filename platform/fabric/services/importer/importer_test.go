@@ -0,0 +1,433 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package importer_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/config"
+	msp2 "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp"
+	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/importer"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/stretchr/testify/assert"
+)
+
+type ca struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCA(t *testing.T, name string) *ca {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{name}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	assert.NoError(t, err)
+	return &ca{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})}
+}
+
+// issue returns a certificate issued by the CA, and its private key, PEM encoded
+func (c *ca) issue(t *testing.T, name string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name, OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	assert.NoError(t, err)
+	rawKey, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawKey})
+}
+
+// writeProfile fills the connection profile template of the test-network of fabric-samples, as ccp-generate.sh does
+func writeProfile(t *testing.T, dir, template string, peerPEM, caPEM []byte) string {
+	raw, err := ioutil.ReadFile(filepath.Join("testdata", template))
+	assert.NoError(t, err)
+	encode := func(pem []byte) string {
+		if strings.HasSuffix(template, ".json") {
+			return strings.ReplaceAll(string(pem), "\n", "\\n")
+		}
+		return strings.ReplaceAll(strings.TrimSpace(string(pem)), "\n", "\n          ")
+	}
+	profile := strings.NewReplacer(
+		"${ORG}", "1",
+		"${P0PORT}", "7051",
+		"${CAPORT}", "7054",
+		"${PEERPEM}", encode(peerPEM),
+		"${CAPEM}", encode(caPEM),
+	).Replace(string(raw))
+	path := filepath.Join(dir, "connection-org1"+filepath.Ext(template))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(profile), 0644))
+	return path
+}
+
+func writeWalletIdentity(t *testing.T, dir, label, mspID, idType string, cert, key []byte) {
+	entry := map[string]interface{}{
+		"credentials": map[string]string{"certificate": string(cert), "privateKey": string(key)},
+		"mspId":       mspID,
+		"type":        idType,
+		"version":     1,
+	}
+	raw, err := json.Marshal(entry)
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, label+".id"), raw, 0600))
+}
+
+// localMembership is the part of the local MSP manager checked by the tests
+type localMembership interface {
+	Msps() []string
+	GetIdentityInfoByLabel(mspType string, label string) *fdriver.IdentityInfo
+	Identity(label string) view.Identity
+	DefaultIdentity() view.Identity
+	DefaultSigningIdentity() fdriver.SigningIdentity
+}
+
+// load loads the imported configuration as the core.yaml of a node, and its MSPs
+func load(t *testing.T, raw []byte) (*config2.Config, localMembership) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "core.yaml"), raw, 0644))
+	registry := registry2.New()
+	cp, err := config.NewProvider(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(cp))
+	kvss, err := kvs.New(registry, "memory", "")
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+	des, err := sig.NewMultiplexDeserializer(registry)
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(des))
+	c, err := config2.New(cp, "default", false)
+	assert.NoError(t, err)
+	mspService := msp2.NewLocalMSPManager(registry, c, nil, nil, nil, 100)
+	assert.NoError(t, registry.RegisterService(mspService))
+	sigService := sig.NewSignService(registry, nil, kvss)
+	assert.NoError(t, registry.RegisterService(sigService))
+	assert.NoError(t, mspService.Load())
+	return c, mspService
+}
+
+func TestImportTestNetwork(t *testing.T) {
+	for _, template := range []string{"ccp-template.yaml", "ccp-template.json"} {
+		t.Run(template, func(t *testing.T) {
+			dir := t.TempDir()
+			signCA := newCA(t, "ca.org1.example.com")
+			tlsCA := newCA(t, "tlsca.org1.example.com")
+			profile := writeProfile(t, dir, template, tlsCA.pem, signCA.pem)
+			cert, key := signCA.issue(t, "appUser")
+			wallet := filepath.Join(dir, "wallet")
+			writeWalletIdentity(t, wallet, "appUser", "Org1MSP", importer.X509Identity, cert, key)
+
+			out := filepath.Join(dir, "out")
+			res, err := importer.ImportFiles(profile, wallet, out, importer.Options{Channels: []string{"mychannel"}})
+			assert.NoError(t, err)
+			written, err := ioutil.ReadFile(filepath.Join(out, importer.ConfigFile))
+			assert.NoError(t, err)
+			assert.Equal(t, res.Config, written)
+			assert.Len(t, res.Warnings, 2)
+			assert.Contains(t, res.Warnings[0], "client.connection timeouts not imported")
+			assert.Contains(t, res.Warnings[1], "no orderers in the profile")
+
+			assert.True(t, strings.HasPrefix(string(res.Config), "fabric:\n  enabled: true\n"))
+			c, mspService := load(t, res.Config)
+			assert.True(t, c.TLSEnabled())
+			assert.False(t, c.TLSClientAuthRequired())
+			peers, err := c.Peers()
+			assert.NoError(t, err)
+			assert.Len(t, peers, 1)
+			assert.Equal(t, "localhost:7051", peers[0].Address)
+			assert.Equal(t, "peer0.org1.example.com", peers[0].ServerNameOverride)
+			assert.Equal(t, 10*time.Second, peers[0].ConnectionTimeout)
+			assert.True(t, peers[0].TLSEnabled)
+			tlsRoot, err := ioutil.ReadFile(peers[0].TLSRootCertFile)
+			assert.NoError(t, err)
+			assert.Equal(t, tlsCA.pem, tlsRoot)
+			orderers, err := c.Orderers()
+			assert.NoError(t, err)
+			assert.Empty(t, orderers)
+			channels, err := c.Channels()
+			assert.NoError(t, err)
+			assert.Len(t, channels, 1)
+			assert.Equal(t, "mychannel", channels[0].Name)
+			assert.True(t, channels[0].Default)
+			assert.Equal(t, "badger", c.VaultPersistenceType())
+			assert.Equal(t, "appUser", c.DefaultMSP())
+
+			assert.Equal(t, []string{"appUser"}, mspService.Msps())
+			ii := mspService.GetIdentityInfoByLabel(msp2.BccspMSP, "appUser")
+			assert.NotNil(t, ii)
+			assert.Equal(t, "appUser", ii.EnrollmentID)
+			id, _, err := ii.GetIdentity(nil)
+			assert.NoError(t, err)
+			assert.NotNil(t, id)
+			assert.NotNil(t, mspService.DefaultIdentity())
+			assert.NotNil(t, mspService.DefaultSigningIdentity())
+		})
+	}
+}
+
+const sdkProfile = `
+name: sdk
+version: 1.0.0
+client:
+  organization: org1
+  cryptoconfig:
+    path: ${FIXTURES}/crypto-config
+  credentialStore:
+    path: /tmp/state-store
+  BCCSP:
+    security:
+      enabled: true
+      default:
+        provider: PKCS11
+  tlsCerts:
+    systemCertPool: false
+    client:
+      key:
+        path: tls/client.key
+      cert:
+        path: tls/client.crt
+channels:
+  mychannel:
+    peers:
+      peer0.org1.example.com:
+        endorsingPeer: true
+        eventSource: false
+    policies:
+      queryChannelConfig:
+        minResponses: 1
+  _default:
+    orderers:
+    - orderer.example.com
+organizations:
+  org1:
+    mspid: Org1MSP
+    cryptoPath: peerOrganizations/org1.example.com/users/{username}@org1.example.com/msp
+    peers:
+    - peer0.org1.example.com
+    - peer1.org1.example.com
+    - peer2.org1.example.com
+    certificateAuthorities:
+    - ca.org1.example.com
+  org2:
+    mspid: Org2MSP
+    peers:
+    - peer0.org2.example.com
+orderers:
+  orderer.example.com:
+    url: orderer.example.com:7050
+    grpcOptions:
+      ssl-target-name-override: orderer.example.com
+      keep-alive-time: 0s
+      allow-insecure: false
+    tlsCACerts:
+      path: tls/orderer.pem
+peers:
+  peer0.org1.example.com:
+    url: grpcs://peer0.org1.example.com:7051
+    tlsCACerts:
+      path: tls/peer.pem
+  peer1.org1.example.com:
+    url: grpc://peer1.org1.example.com:8051
+  peer0.org2.example.com:
+    url: grpcs://peer0.org2.example.com:9051
+certificateAuthorities:
+  ca.org1.example.com:
+    url: https://ca.org1.example.com:7054
+    registrar:
+      enrollId: admin
+      enrollSecret: adminpw
+entityMatchers:
+  peer:
+  - pattern: (\w+).org1.example.com:(\d+)
+    urlSubstitutionExp: localhost:${2}
+`
+
+func TestImportSDKProfile(t *testing.T) {
+	dir := t.TempDir()
+	signCA := newCA(t, "ca.org1.example.com")
+	tlsCA := newCA(t, "tlsca.example.com")
+	clientCert, clientKey := tlsCA.issue(t, "client")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "tls"), 0755))
+	for name, raw := range map[string][]byte{"orderer.pem": tlsCA.pem, "peer.pem": tlsCA.pem, "client.crt": clientCert, "client.key": clientKey} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tls", name), raw, 0644))
+	}
+	profilePath := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(profilePath, []byte(sdkProfile), 0644))
+
+	wallet := filepath.Join(dir, "wallet")
+	cert, key := signCA.issue(t, "alice")
+	writeWalletIdentity(t, wallet, "alice", "Org1MSP", importer.X509Identity, cert, key)
+	cert, key = signCA.issue(t, "bob")
+	writeWalletIdentity(t, wallet, "bob", "Org1MSP", importer.X509Identity, cert, key)
+	writeWalletIdentity(t, wallet, "carol", "Org1MSP", "HSM-X.509", cert, nil)
+	assert.NoError(t, os.MkdirAll(filepath.Join(wallet, "legacy"), 0755))
+
+	// the issuer of the identities is not in the profile
+	out := filepath.Join(dir, "out")
+	_, err := importer.ImportFiles(profilePath, wallet, out, importer.Options{Identity: "bob"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "pass the certificate of its issuer")
+
+	res, err := importer.ImportFiles(profilePath, wallet, out, importer.Options{Identity: "bob", CACerts: [][]byte{signCA.pem}})
+	assert.NoError(t, err)
+	for _, w := range []string{
+		"wallet entry [legacy] skipped",
+		"wallet identity [carol] skipped",
+		"entityMatchers not supported",
+		"client.cryptoconfig and client.credentialStore ignored",
+		"client.BCCSP provider [PKCS11] not imported",
+		"users and credentials of organization [org1] ignored",
+		"registrar of certificate authority [ca.org1.example.com] ignored",
+		"peer [peer2.org1.example.com] of organization [org1] not found",
+		"TLS certificates of the peers of the other organizations",
+		"grpcOptions [allow-insecure keep-alive-time] of orderer [orderer.example.com] not imported",
+		"TLS enabled for the whole network, the endpoints [peer1.org1.example.com] do not use it",
+		"policies of channel [mychannel] not imported",
+		"roles of peer [peer0.org1.example.com] in channel [mychannel] not imported",
+	} {
+		found := false
+		for _, warning := range res.Warnings {
+			found = found || strings.Contains(warning, w)
+		}
+		assert.True(t, found, "warning [%s] not found in %v", w, res.Warnings)
+	}
+
+	c, mspService := load(t, res.Config)
+	assert.True(t, c.TLSEnabled())
+	assert.True(t, c.TLSClientAuthRequired())
+	raw, err := ioutil.ReadFile(c.TLSClientCertFile())
+	assert.NoError(t, err)
+	assert.Equal(t, clientCert, raw)
+	raw, err = ioutil.ReadFile(c.TLSClientKeyFile())
+	assert.NoError(t, err)
+	assert.Equal(t, clientKey, raw)
+
+	peers, err := c.Peers()
+	assert.NoError(t, err)
+	assert.Len(t, peers, 2)
+	assert.Equal(t, "peer0.org1.example.com:7051", peers[0].Address)
+	assert.Equal(t, "peer1.org1.example.com:8051", peers[1].Address)
+	assert.Empty(t, peers[1].TLSRootCertFile)
+	orderers, err := c.Orderers()
+	assert.NoError(t, err)
+	assert.Len(t, orderers, 1)
+	assert.Equal(t, "orderer.example.com:7050", orderers[0].Address)
+	assert.Equal(t, "orderer.example.com", orderers[0].ServerNameOverride)
+	raw, err = ioutil.ReadFile(orderers[0].TLSRootCertFile)
+	assert.NoError(t, err)
+	assert.Equal(t, tlsCA.pem, raw)
+
+	foreignOrgs, err := c.EndorsementForeignOrgs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Org2MSP"}, foreignOrgs)
+	foreignPeers, err := c.EndorsementForeignPeers()
+	assert.NoError(t, err)
+	assert.Equal(t, []*config2.ForeignPeer{{MSPID: "Org2MSP", Address: "peer0.org2.example.com:9051"}}, foreignPeers)
+
+	channels, err := c.Channels()
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	assert.Equal(t, "_default", channels[0].Name)
+	assert.True(t, channels[0].Default)
+	assert.Equal(t, "mychannel", channels[1].Name)
+	assert.False(t, channels[1].Default)
+
+	assert.Equal(t, "bob", c.DefaultMSP())
+	assert.Equal(t, []string{"alice", "bob"}, mspService.Msps())
+	for _, label := range mspService.Msps() {
+		assert.NotNil(t, mspService.GetIdentityInfoByLabel(msp2.BccspMSP, label))
+	}
+	assert.Equal(t, mspService.Identity("bob"), mspService.DefaultIdentity())
+}
+
+func TestImportErrors(t *testing.T) {
+	signCA := newCA(t, "ca.org1.example.com")
+	profile, err := importer.ParseProfile([]byte(`
+client:
+  organization: org1
+organizations:
+  org1:
+    mspid: Org1MSP
+    peers:
+    - peer0
+peers:
+  peer0:
+    url: grpcs://peer0:7051
+certificateAuthorities:
+  ca:
+    tlsCACerts:
+      pem: |
+` + indent(string(signCA.pem), "        ")))
+	assert.NoError(t, err)
+
+	cert, key := signCA.issue(t, "alice")
+	_, otherKey := signCA.issue(t, "bob")
+	for _, test := range []struct {
+		name       string
+		identities []*importer.WalletIdentity
+		opts       importer.Options
+		err        string
+	}{
+		{"unknown organization", nil, importer.Options{Organization: "org2"}, "organization [org2] not found"},
+		{"no identity", nil, importer.Options{}, "no identity of msp [Org1MSP] in the wallet"},
+		{"other msp", []*importer.WalletIdentity{{Label: "alice", MSPID: "Org2MSP", Certificate: cert, PrivateKey: key}}, importer.Options{}, "no identity of msp [Org1MSP]"},
+		{"unknown identity", []*importer.WalletIdentity{{Label: "alice", MSPID: "Org1MSP", Certificate: cert, PrivateKey: key}}, importer.Options{Identity: "bob"}, "identity [bob] not found"},
+		{"key mismatch", []*importer.WalletIdentity{{Label: "alice", MSPID: "Org1MSP", Certificate: cert, PrivateKey: otherKey}}, importer.Options{}, "private key does not match the certificate"},
+		{"invalid label", []*importer.WalletIdentity{{Label: "../alice", MSPID: "Org1MSP", Certificate: cert, PrivateKey: key}}, importer.Options{}, "invalid wallet identity label"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := importer.Import(profile, test.identities, t.TempDir(), test.opts)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+
+	res, err := importer.Import(profile, []*importer.WalletIdentity{{Label: "alice", MSPID: "Org1MSP", Certificate: cert, PrivateKey: key}}, t.TempDir(), importer.Options{Network: "testnet"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(res.Config), "testnet:")
+	assert.Contains(t, res.Warnings[len(res.Warnings)-1], "no channels in the profile")
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n"+prefix) + "\n"
+}
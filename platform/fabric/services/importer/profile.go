@@ -0,0 +1,190 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// ConnectionProfile is a connection profile of the Fabric SDKs (fabric-sdk-go, the Gateway SDKs),
+// as found in YAML or JSON files
+type ConnectionProfile struct {
+	Name                   string                           `yaml:"name" json:"name"`
+	Version                string                           `yaml:"version" json:"version"`
+	Client                 Client                           `yaml:"client" json:"client"`
+	Channels               map[string]*Channel              `yaml:"channels" json:"channels"`
+	Organizations          map[string]*Organization         `yaml:"organizations" json:"organizations"`
+	Orderers               map[string]*Endpoint             `yaml:"orderers" json:"orderers"`
+	Peers                  map[string]*Endpoint             `yaml:"peers" json:"peers"`
+	CertificateAuthorities map[string]*CertificateAuthority `yaml:"certificateAuthorities" json:"certificateAuthorities"`
+	EntityMatchers         map[string]interface{}           `yaml:"entityMatchers" json:"entityMatchers"`
+
+	// dir is the directory of the profile, the relative paths of the certificates are resolved against it
+	dir string
+}
+
+// Client is the section of a connection profile describing the application
+type Client struct {
+	Organization    string                 `yaml:"organization" json:"organization"`
+	Connection      map[string]interface{} `yaml:"connection" json:"connection"`
+	CryptoConfig    map[string]interface{} `yaml:"cryptoconfig" json:"cryptoconfig"`
+	CredentialStore map[string]interface{} `yaml:"credentialStore" json:"credentialStore"`
+	TLSCerts        *ClientTLSCerts        `yaml:"tlsCerts" json:"tlsCerts"`
+	BCCSP           map[string]interface{} `yaml:"BCCSP" json:"BCCSP"`
+}
+
+// ClientTLSCerts are the TLS credentials of the application, for mutual TLS
+type ClientTLSCerts struct {
+	SystemCertPool bool `yaml:"systemCertPool" json:"systemCertPool"`
+	Client         struct {
+		Key  Certificates `yaml:"key" json:"key"`
+		Cert Certificates `yaml:"cert" json:"cert"`
+	} `yaml:"client" json:"client"`
+}
+
+// Channel lists the orderers and the peers of a channel, with their roles
+type Channel struct {
+	Orderers []string                `yaml:"orderers" json:"orderers"`
+	Peers    map[string]*ChannelPeer `yaml:"peers" json:"peers"`
+	Policies map[string]interface{}  `yaml:"policies" json:"policies"`
+}
+
+// ChannelPeer are the roles of a peer in a channel, all true if not set
+type ChannelPeer struct {
+	EndorsingPeer  *bool `yaml:"endorsingPeer" json:"endorsingPeer"`
+	ChaincodeQuery *bool `yaml:"chaincodeQuery" json:"chaincodeQuery"`
+	LedgerQuery    *bool `yaml:"ledgerQuery" json:"ledgerQuery"`
+	EventSource    *bool `yaml:"eventSource" json:"eventSource"`
+}
+
+// Organization lists the peers and the certificate authorities of an organization
+type Organization struct {
+	MSPID                  string                 `yaml:"mspid" json:"mspid"`
+	CryptoPath             string                 `yaml:"cryptoPath" json:"cryptoPath"`
+	Peers                  []string               `yaml:"peers" json:"peers"`
+	CertificateAuthorities []string               `yaml:"certificateAuthorities" json:"certificateAuthorities"`
+	Users                  map[string]interface{} `yaml:"users" json:"users"`
+	AdminPrivateKey        *Certificates          `yaml:"adminPrivateKey" json:"adminPrivateKey"`
+	SignedCert             *Certificates          `yaml:"signedCert" json:"signedCert"`
+}
+
+// Endpoint is a peer or an orderer
+type Endpoint struct {
+	URL         string                 `yaml:"url" json:"url"`
+	TLSCACerts  Certificates           `yaml:"tlsCACerts" json:"tlsCACerts"`
+	GRPCOptions map[string]interface{} `yaml:"grpcOptions" json:"grpcOptions"`
+}
+
+// CertificateAuthority is a Fabric CA
+type CertificateAuthority struct {
+	URL         string                 `yaml:"url" json:"url"`
+	CAName      string                 `yaml:"caName" json:"caName"`
+	TLSCACerts  Certificates           `yaml:"tlsCACerts" json:"tlsCACerts"`
+	Registrar   map[string]interface{} `yaml:"registrar" json:"registrar"`
+	HTTPOptions map[string]interface{} `yaml:"httpOptions" json:"httpOptions"`
+}
+
+// Certificates are PEM encoded certificates or keys, given inline, as a string or a list, or by path
+type Certificates struct {
+	PEM  PEMs   `yaml:"pem" json:"pem"`
+	Path string `yaml:"path" json:"path"`
+}
+
+// PEMs is a list of PEM blocks, a single string is accepted as well
+type PEMs []string
+
+func (p *PEMs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*p = PEMs{s}
+		return nil
+	}
+	var l []string
+	if err := unmarshal(&l); err != nil {
+		return errors.Wrap(err, "pem must be a string or a list of strings")
+	}
+	*p = l
+	return nil
+}
+
+func (p *PEMs) UnmarshalJSON(raw []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		*p = PEMs{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return errors.Wrap(err, "pem must be a string or a list of strings")
+	}
+	*p = l
+	return nil
+}
+
+// ReadProfile reads a connection profile, in YAML or JSON
+func ReadProfile(path string) (*ConnectionProfile, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading connection profile")
+	}
+	profile, err := ParseProfile(raw)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed parsing connection profile [%s]", path)
+	}
+	profile.dir = filepath.Dir(path)
+	return profile, nil
+}
+
+// ParseProfile parses a connection profile, in YAML or JSON. The relative paths are resolved against the current directory.
+func ParseProfile(raw []byte) (*ConnectionProfile, error) {
+	profile := &ConnectionProfile{}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) != 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(raw, profile); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON")
+		}
+	} else if err := yaml.Unmarshal(raw, profile); err != nil {
+		return nil, errors.Wrap(err, "invalid YAML")
+	}
+	if len(profile.Organizations) == 0 {
+		return nil, errors.New("no organizations")
+	}
+	profile.dir = "."
+	return profile, nil
+}
+
+// load returns the bytes of the passed certificates, the paths can refer to environment variables
+func (p *ConnectionProfile) load(c Certificates) ([]byte, error) {
+	if len(c.PEM) != 0 {
+		var res []byte
+		for _, pem := range c.PEM {
+			res = append(res, []byte(pem)...)
+			if len(pem) != 0 && pem[len(pem)-1] != '\n' {
+				res = append(res, '\n')
+			}
+		}
+		return res, nil
+	}
+	if len(c.Path) == 0 {
+		return nil, nil
+	}
+	path := os.ExpandEnv(c.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.dir, path)
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading [%s]", path)
+	}
+	return raw, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// X509Identity is the type of the wallet identities made of an x509 certificate and a private key
+	X509Identity = "X.509"
+
+	walletSuffix = ".id"
+)
+
+// WalletIdentity is an identity of a wallet of the Fabric SDKs
+type WalletIdentity struct {
+	// Label is the name of the identity in the wallet
+	Label string
+	MSPID string
+	Type  string
+	// Certificate and PrivateKey are PEM encoded
+	Certificate []byte
+	PrivateKey  []byte
+}
+
+type walletEntry struct {
+	Credentials struct {
+		Certificate string `json:"certificate"`
+		PrivateKey  string `json:"privateKey"`
+	} `json:"credentials"`
+	MSPID   string `json:"mspId"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+// ReadWallet reads the identities of a file-system wallet, as written by fabric-sdk-go and the Gateway SDKs:
+// one JSON file, named after the label of the identity with the suffix .id, per identity.
+// The identities that cannot be imported are reported by the returned warnings, the identities are sorted by label.
+func ReadWallet(dir string) ([]*WalletIdentity, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed reading wallet")
+	}
+	var identities []*WalletIdentity
+	var warnings []string
+	for _, e := range entries {
+		if e.IsDir() {
+			warnings = append(warnings, fmt.Sprintf("wallet entry [%s] skipped, the directories of the legacy wallets are not supported", e.Name()))
+			continue
+		}
+		if !strings.HasSuffix(e.Name(), walletSuffix) {
+			continue
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed reading wallet identity")
+		}
+		entry := &walletEntry{}
+		if err := json.Unmarshal(raw, entry); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed parsing wallet identity [%s]", e.Name())
+		}
+		label := strings.TrimSuffix(e.Name(), walletSuffix)
+		if entry.Type != X509Identity {
+			warnings = append(warnings, fmt.Sprintf("wallet identity [%s] skipped, its type [%s] is not supported, only %s is", label, entry.Type, X509Identity))
+			continue
+		}
+		identities = append(identities, &WalletIdentity{
+			Label:       label,
+			MSPID:       entry.MSPID,
+			Type:        entry.Type,
+			Certificate: []byte(entry.Credentials.Certificate),
+			PrivateKey:  []byte(entry.Credentials.PrivateKey),
+		})
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].Label < identities[j].Label })
+	return identities, warnings, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package receipt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/canonical"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/x509"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/client/web"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// ErrNotValid is returned when the receipt states that the transaction has been committed as not valid
+var ErrNotValid = errors.New("transaction not valid")
+
+// Marshal serializes the passed receipt in canonical JSON
+func Marshal(receipt *driver.Receipt) ([]byte, error) {
+	raw, err := canonical.JSON(receipt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling receipt")
+	}
+	return raw, nil
+}
+
+// Unmarshal deserializes a receipt serialized with Marshal
+func Unmarshal(raw []byte) (*driver.Receipt, error) {
+	r := &driver.Receipt{}
+	if err := json.Unmarshal(raw, r); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling receipt")
+	}
+	if err := checkVersion(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// VerifyReceipt checks, without access to any node, that the passed receipt has been issued by the trusted identity,
+// the serialized x509 identity of the node, and that its statement has not been altered since.
+// It returns ErrNotValid if the receipt states that the transaction has been committed as not valid.
+func VerifyReceipt(receipt *driver.Receipt, trustedIdentity view.Identity) error {
+	if receipt == nil || trustedIdentity.IsNone() {
+		return errors.New("receipt and trusted identity must be set")
+	}
+	if err := checkVersion(receipt); err != nil {
+		return err
+	}
+	if !bytes.Equal(receipt.Issuer, trustedIdentity) {
+		return errors.Errorf("receipt of [%s] not issued by the trusted identity", receipt.TxID)
+	}
+	verifier, err := (&x509.Deserializer{}).DeserializeVerifier(trustedIdentity)
+	if err != nil {
+		return errors.WithMessagef(err, "invalid trusted identity")
+	}
+	statement, err := receipt.Statement()
+	if err != nil {
+		return err
+	}
+	if err := verifier.Verify(statement, receipt.Signature); err != nil {
+		return errors.Wrapf(err, "invalid signature on the receipt of [%s]", receipt.TxID)
+	}
+
+	switch receipt.Code {
+	case driver.Valid:
+		return nil
+	case driver.Invalid:
+		return errors.Wrapf(ErrNotValid, "transaction [%s] committed as not valid in block [%d:%d]", receipt.TxID, receipt.Block, receipt.TxNum)
+	default:
+		return errors.Errorf("receipt of [%s] states the status [%d], neither valid nor invalid", receipt.TxID, receipt.Code)
+	}
+}
+
+// checkVersion accepts the receipts of the current version, and those of the previous one in plain JSON
+func checkVersion(r *driver.Receipt) error {
+	if r.Version != driver.ReceiptVersion && r.Version != driver.ReceiptVersionJSON {
+		return errors.Errorf("unsupported receipt version [%d], expected [%d] or [%d]", r.Version, driver.ReceiptVersion, driver.ReceiptVersionJSON)
+	}
+	return nil
+}
+
+// Fetch requests the node reached by the passed client to issue the receipt of the passed transaction, through the
+// admin endpoint of the fabric sdk. The receipt is to be verified with VerifyReceipt.
+func Fetch(client *web.Client, network, channel, txID string) (*driver.Receipt, error) {
+	uri := fmt.Sprintf("/fabric/%s/%s/transactions/%s/receipt", url.PathEscape(network), url.PathEscape(channel), url.PathEscape(txID))
+	raw, err := client.Get(uri)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed fetching the receipt of [%s:%s:%s]", network, channel, txID)
+	}
+	return Unmarshal(raw)
+}
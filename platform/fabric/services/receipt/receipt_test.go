@@ -0,0 +1,155 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package receipt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	x5092 "crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/msp/x509"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/client/web"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// node is the x509 identity of an FSC node, with a self-signed certificate
+type node struct {
+	identity []byte
+	key      *ecdsa.PrivateKey
+}
+
+func newNode(t *testing.T, name string) *node {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x5092.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x5092.KeyUsageDigitalSignature,
+	}
+	der, err := x5092.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	identity, err := proto.Marshal(&mspproto.SerializedIdentity{Mspid: "Org1MSP", IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})})
+	assert.NoError(t, err)
+	return &node{identity: identity, key: key}
+}
+
+// issue returns the receipt of the passed transaction signed by the node, as the channels issue them
+func (n *node) issue(t *testing.T, txID string, code driver.ValidationCode) *driver.Receipt {
+	r := &driver.Receipt{
+		Version:  driver.ReceiptVersion,
+		Network:  "default",
+		Channel:  "mychannel",
+		TxID:     txID,
+		Code:     code,
+		Block:    7,
+		TxNum:    2,
+		IssuedAt: time.Now().UTC(),
+		Issuer:   n.identity,
+	}
+	statement, err := r.Statement()
+	assert.NoError(t, err)
+	r.Signature, err = x509.NewEcdsaSigner(n.key).Sign(statement)
+	assert.NoError(t, err)
+	return r
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	alice, bob := newNode(t, "alice"), newNode(t, "bob")
+
+	// the receipts survive their serialization
+	raw, err := Marshal(alice.issue(t, "tx1", driver.Valid))
+	assert.NoError(t, err)
+	r, err := Unmarshal(raw)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyReceipt(r, alice.identity))
+
+	// the receipts of other nodes, or altered, are refused
+	assert.EqualError(t, VerifyReceipt(r, bob.identity), "receipt of [tx1] not issued by the trusted identity")
+	r.Block = 8
+	assert.Contains(t, VerifyReceipt(r, alice.identity).Error(), "invalid signature on the receipt of [tx1]")
+	r = alice.issue(t, "tx1", driver.Valid)
+	r.Issuer, r.Signature = bob.identity, bob.issue(t, "tx1", driver.Valid).Signature
+	assert.Error(t, VerifyReceipt(r, alice.identity))
+	assert.Error(t, VerifyReceipt(nil, alice.identity))
+
+	// the status of the transaction is explicit
+	assert.True(t, errors.Is(VerifyReceipt(alice.issue(t, "tx2", driver.Invalid), alice.identity), ErrNotValid))
+	assert.EqualError(t, VerifyReceipt(alice.issue(t, "tx3", driver.Busy), alice.identity), "receipt of [tx3] states the status [3], neither valid nor invalid")
+
+	_, err = Unmarshal([]byte(`{"version":3}`))
+	assert.EqualError(t, err, "unsupported receipt version [3], expected [2] or [1]")
+}
+
+func TestFetch(t *testing.T) {
+	alice := newNode(t, "alice")
+	raw, err := Marshal(alice.issue(t, "tx1", driver.Valid))
+	assert.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/fabric/default/mychannel/transactions/tx1/receipt" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		_, _ = w.Write(raw)
+	}))
+	defer server.Close()
+	client, err := web.NewClient(&web.Config{URL: server.URL})
+	assert.NoError(t, err)
+
+	r, err := Fetch(client, "default", "mychannel", "tx1")
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyReceipt(r, alice.identity))
+	_, err = Fetch(client, "default", "mychannel", "tx2")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status code [409]")
+}
+
+func TestStatement(t *testing.T) {
+	r := &driver.Receipt{
+		Version:   driver.ReceiptVersion,
+		Network:   "default",
+		Channel:   "mychannel",
+		TxID:      "tx1",
+		Code:      driver.Valid,
+		Block:     7,
+		TxNum:     2,
+		IssuedAt:  time.Date(2022, 3, 4, 5, 6, 7, 8, time.UTC),
+		Issuer:    []byte("issuer"),
+		Signature: []byte("signature"),
+	}
+	statement, err := r.Statement()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"block":7,"channel":"mychannel","code":1,"issuedAt":"2022-03-04T05:06:07.000000008Z","issuer":"aXNzdWVy","network":"default","txID":"tx1","txNum":2,"version":2}`, string(statement))
+
+	// the receipts issued before, in plain JSON, are still verified
+	alice := newNode(t, "alice")
+	r = alice.issue(t, "tx1", driver.Valid)
+	r.Version = driver.ReceiptVersionJSON
+	statement, err = r.Statement()
+	assert.NoError(t, err)
+	assert.Contains(t, string(statement), `{"version":1,"network":"default","channel":"mychannel","txID":"tx1"`)
+	r.Signature, err = x509.NewEcdsaSigner(alice.key).Sign(statement)
+	assert.NoError(t, err)
+	raw, err := Marshal(r)
+	assert.NoError(t, err)
+	r, err = Unmarshal(raw)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyReceipt(r, alice.identity))
+}
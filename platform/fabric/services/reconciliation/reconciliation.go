@@ -0,0 +1,300 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package reconciliation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/keys"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.reconciliation")
+
+// DefaultPageSize is the number of keys read from the source per query when the options do not set it
+const DefaultPageSize = 100
+
+// DriftKind is the kind of divergence of a key between the vault and the peer
+type DriftKind string
+
+const (
+	// Missing keys are on the peer but not in the vault
+	Missing DriftKind = "missing"
+	// Extra keys are in the vault but not on the peer
+	Extra DriftKind = "extra"
+	// Differing keys have a different value, or version, in the vault and on the peer
+	Differing DriftKind = "differing"
+)
+
+// Entry is the authoritative state of a key, as read from the source
+type Entry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	// Block and TxNum are the version of the key on the peer, they are nil if the source does not know them
+	Block *uint64 `json:"block,omitempty"`
+	TxNum *uint64 `json:"txNum,omitempty"`
+}
+
+// Page is a page of the authoritative states of a range of keys, sorted by key
+type Page struct {
+	Entries []Entry `json:"entries"`
+	// Bookmark identifies the next page, it is empty if this is the last one
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+// Source reads the authoritative states of the keys of a namespace
+type Source interface {
+	// Name describes the source, it is recorded in the provenance of the repairs
+	Name() string
+	// Page returns the page, identified by the passed bookmark, of the states of the keys in [startKey, endKey).
+	// The empty bookmark identifies the first page, an empty endKey does not bound the range.
+	Page(ctx context.Context, namespace, startKey, endKey string, pageSize int, bookmark string) (*Page, error)
+}
+
+// Options select the keys to reconcile and whether the drifts are repaired
+type Options struct {
+	// StartKey and EndKey are the range of keys [StartKey, EndKey) to reconcile, empty for all of them
+	StartKey string
+	EndKey   string
+	// PageSize is the number of keys read from the source per query, DefaultPageSize if not positive
+	PageSize int
+	// Repair re-applies to the vault the authoritative states of the drifted keys
+	Repair bool
+	// Source reads the authoritative states, a ChaincodeSource querying DefaultQueryFunction if nil.
+	// It is used by Service#Reconcile only.
+	Source Source
+}
+
+// Drift is a key whose state in the vault diverges from the one on the peer
+type Drift struct {
+	Key  string    `json:"key"`
+	Kind DriftKind `json:"kind"`
+	// LocalBlock and LocalTxNum are the version of the key in the vault, zero if missing
+	LocalBlock uint64 `json:"localBlock"`
+	LocalTxNum uint64 `json:"localTxNum"`
+	// PeerBlock and PeerTxNum are the version of the key on the peer, nil if extra or not known by the source
+	PeerBlock *uint64 `json:"peerBlock,omitempty"`
+	PeerTxNum *uint64 `json:"peerTxNum,omitempty"`
+	// Repaired is true if the authoritative state has been re-applied to the vault
+	Repaired bool `json:"repaired"`
+
+	peer []byte
+}
+
+// Report lists the drifts of a namespace found by a reconciliation
+type Report struct {
+	ID        string    `json:"id"`
+	Namespace string    `json:"namespace"`
+	StartKey  string    `json:"startKey,omitempty"`
+	EndKey    string    `json:"endKey,omitempty"`
+	Source    string    `json:"source"`
+	Started   time.Time `json:"started"`
+	Completed time.Time `json:"completed"`
+	// PeerKeys and LocalKeys are the number of keys read from the source and from the vault
+	PeerKeys  int     `json:"peerKeys"`
+	LocalKeys int     `json:"localKeys"`
+	Drifts    []Drift `json:"drifts"`
+	Repaired  int     `json:"repaired"`
+}
+
+// Reconciler compares the states of the keys in a vault against those read from a source
+type Reconciler struct {
+	vault   *fabric.Vault
+	source  Source
+	limiter *Limiter
+}
+
+// NewReconciler returns a reconciler of the passed vault against the passed source.
+// The queries to the source are paced by the passed limiter, if not nil.
+func NewReconciler(vault *fabric.Vault, source Source, limiter *Limiter) *Reconciler {
+	return &Reconciler{vault: vault, source: source, limiter: limiter}
+}
+
+// Reconcile compares, page by page, the keys of the passed namespace in the vault against those read from the source.
+// Each page of the source is compared with the keys of the vault in the same range, read under a single query executor.
+// The keys of the vault starting with keys.NamespaceSeparator, the composite keys, are not compared:
+// the range queries of the chaincodes do not return them.
+//
+// If the options ask to, the drifts of each page are repaired in a single update of the vault, recording their provenance.
+// A repaired key gets the version read from the source or, if the source does not know it, keeps the version it has in the vault.
+// A key committed again since its drift has been detected is not repaired.
+// The vault can lag behind the peer: the drifts of the keys written by the last blocks disappear once the vault commits them.
+func (r *Reconciler) Reconcile(ctx context.Context, namespace string, opts Options) (*Report, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	report := &Report{
+		Namespace: namespace,
+		StartKey:  opts.StartKey,
+		EndKey:    opts.EndKey,
+		Source:    r.source.Name(),
+		Started:   time.Now(),
+		Drifts:    []Drift{},
+	}
+	report.ID = fmt.Sprintf("%s-%d", namespace, report.Started.UnixNano())
+
+	cursor, bookmark := opts.StartKey, ""
+	for {
+		if r.limiter != nil {
+			if err := r.limiter.Wait(ctx); err != nil {
+				return nil, errors.Wrapf(err, "reconciliation of [%s] interrupted", namespace)
+			}
+		}
+		page, err := r.source.Page(ctx, namespace, opts.StartKey, opts.EndKey, pageSize, bookmark)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed reading page [%s] of [%s] from [%s]", bookmark, namespace, r.source.Name())
+		}
+		// the page covers the keys up to its last, the last page up to the end of the range
+		end := opts.EndKey
+		if len(page.Bookmark) != 0 {
+			if len(page.Entries) == 0 {
+				return nil, errors.Errorf("page [%s] of [%s] is empty but not the last one", bookmark, namespace)
+			}
+			end = page.Entries[len(page.Entries)-1].Key + keys.NamespaceSeparator
+		}
+
+		drifts, locals, err := r.compare(namespace, cursor, end, page.Entries)
+		if err != nil {
+			return nil, err
+		}
+		report.PeerKeys += len(page.Entries)
+		report.LocalKeys += locals
+		if opts.Repair && len(drifts) != 0 {
+			if err := r.repair(namespace, report.ID, drifts); err != nil {
+				return nil, err
+			}
+		}
+		for _, drift := range drifts {
+			if drift.Repaired {
+				report.Repaired++
+			}
+			report.Drifts = append(report.Drifts, drift)
+		}
+
+		if len(page.Bookmark) == 0 {
+			break
+		}
+		cursor, bookmark = end, page.Bookmark
+	}
+	report.Completed = time.Now()
+
+	logger.Infof("reconciled [%s] against [%s]: [%d] peer keys, [%d] local keys, [%d] drifts, [%d] repaired",
+		namespace, report.Source, report.PeerKeys, report.LocalKeys, len(report.Drifts), report.Repaired)
+	return report, nil
+}
+
+// compare merges the passed entries with the keys of the vault in [startKey, endKey)
+func (r *Reconciler) compare(namespace, startKey, endKey string, entries []Entry) ([]Drift, int, error) {
+	qe, err := r.vault.NewQueryExecutor()
+	if err != nil {
+		return nil, 0, errors.WithMessagef(err, "failed getting query executor")
+	}
+	defer qe.Done()
+	it, err := qe.GetStateRangeScanIterator(namespace, startKey, endKey)
+	if err != nil {
+		return nil, 0, errors.WithMessagef(err, "failed scanning [%s] in [%s, %s)", namespace, startKey, endKey)
+	}
+	defer it.Close()
+
+	next := func() (*fabric.Read, error) {
+		for {
+			read, err := it.Next()
+			if err != nil || read == nil || !strings.HasPrefix(read.Key, keys.NamespaceSeparator) {
+				return read, err
+			}
+		}
+	}
+
+	var drifts []Drift
+	locals := 0
+	local, err := next()
+	for i := 0; ; {
+		if err != nil {
+			return nil, 0, errors.WithMessagef(err, "failed scanning [%s] in [%s, %s)", namespace, startKey, endKey)
+		}
+		var peer *Entry
+		if i < len(entries) {
+			peer = &entries[i]
+		}
+		switch {
+		case peer == nil && local == nil:
+			return drifts, locals, nil
+		case peer == nil || (local != nil && local.Key < peer.Key):
+			drifts = append(drifts, Drift{Key: local.Key, Kind: Extra, LocalBlock: local.Block, LocalTxNum: uint64(local.IndexInBlock)})
+			locals++
+			local, err = next()
+		case local == nil || peer.Key < local.Key:
+			drifts = append(drifts, Drift{Key: peer.Key, Kind: Missing, PeerBlock: peer.Block, PeerTxNum: peer.TxNum, peer: peer.Value})
+			i++
+		default:
+			if !bytes.Equal(local.Raw, peer.Value) ||
+				(peer.Block != nil && *peer.Block != local.Block) ||
+				(peer.TxNum != nil && *peer.TxNum != uint64(local.IndexInBlock)) {
+				drifts = append(drifts, Drift{
+					Key:        local.Key,
+					Kind:       Differing,
+					LocalBlock: local.Block,
+					LocalTxNum: uint64(local.IndexInBlock),
+					PeerBlock:  peer.Block,
+					PeerTxNum:  peer.TxNum,
+					peer:       peer.Value,
+				})
+			}
+			locals++
+			i++
+			local, err = next()
+		}
+	}
+}
+
+// repair re-applies the authoritative states of the passed drifts and marks those repaired
+func (r *Reconciler) repair(namespace, reference string, drifts []Drift) error {
+	repairs := make([]fabric.StateRepair, len(drifts))
+	for i, drift := range drifts {
+		repairs[i] = fabric.StateRepair{
+			Namespace:  namespace,
+			Key:        drift.Key,
+			Value:      drift.peer,
+			Block:      drift.LocalBlock,
+			TxNum:      drift.LocalTxNum,
+			LocalBlock: drift.LocalBlock,
+			LocalTxNum: drift.LocalTxNum,
+		}
+		if drift.PeerBlock != nil {
+			repairs[i].Block = *drift.PeerBlock
+		}
+		if drift.PeerTxNum != nil {
+			repairs[i].TxNum = *drift.PeerTxNum
+		}
+	}
+	applied, err := r.vault.RepairStates(repairs, fabric.RepairProvenance{
+		Source:    r.source.Name(),
+		Reference: reference,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "failed repairing [%d] keys of [%s]", len(repairs), namespace)
+	}
+	repaired := make(map[string]bool, len(applied))
+	for _, repair := range applied {
+		repaired[repair.Key] = true
+	}
+	for i := range drifts {
+		drifts[i].Repaired = repaired[drifts[i].Key]
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package reconciliation
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/core/generic/vault/txidstore"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNetwork struct {
+	driver.FabricNetworkService
+}
+
+func (n *fakeNetwork) Name() string { return "default" }
+
+// fakeChannel serves the vault of the channel only
+type fakeChannel struct {
+	driver.Channel
+	vault *vault.Vault
+}
+
+func (c *fakeChannel) Name() string { return "mychannel" }
+
+func (c *fakeChannel) NewQueryExecutor() (driver.QueryExecutor, error) {
+	return c.vault.NewQueryExecutor()
+}
+
+func (c *fakeChannel) RepairStates(repairs []driver.StateRepair, provenance driver.RepairProvenance) ([]driver.StateRepair, error) {
+	return c.vault.RepairStates(repairs, provenance)
+}
+
+func (c *fakeChannel) RepairRecord(namespace, key string) (*driver.RepairRecord, error) {
+	return c.vault.RepairRecord(namespace, key)
+}
+
+// fakeSource serves the passed states, sorted by key, in pages
+type fakeSource struct {
+	states  map[string]string
+	queries int
+}
+
+func (s *fakeSource) Name() string { return "fake" }
+
+func (s *fakeSource) Page(_ context.Context, _, startKey, endKey string, pageSize int, bookmark string) (*Page, error) {
+	s.queries++
+	var keys []string
+	for key := range s.states {
+		if key >= startKey && (len(endKey) == 0 || key < endKey) && key >= bookmark {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	page := &Page{}
+	if len(keys) > pageSize {
+		page.Bookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+	for _, key := range keys {
+		page.Entries = append(page.Entries, Entry{Key: key, Value: []byte(s.states[key])})
+	}
+	return page, nil
+}
+
+func newVault(t *testing.T) (*vault.Vault, *fabric.Vault) {
+	ddb, err := db.OpenVersioned(nil, "memory", "", nil)
+	assert.NoError(t, err)
+	tidstore, err := txidstore.NewTXIDStore(db.Unversioned(ddb))
+	assert.NoError(t, err)
+	v := vault.New(ddb, tidstore)
+	return v, fabric.NewChannel(nil, &fakeNetwork{}, &fakeChannel{vault: v}).Vault()
+}
+
+func commit(t *testing.T, v *vault.Vault, txid string, block uint64, states map[string]string) {
+	rws, err := v.NewRWSet(txid)
+	assert.NoError(t, err)
+	for key, value := range states {
+		assert.NoError(t, rws.SetState("ns", key, []byte(value)))
+	}
+	rws.Done()
+	assert.NoError(t, v.CommitTX(txid, block, 0))
+}
+
+func TestReconcile(t *testing.T) {
+	v, fv := newVault(t)
+	commit(t, v, "tx1", 1, map[string]string{"a": "1", "b": "2", "c": "3", "e": "5", "g": "7"})
+	source := &fakeSource{states: map[string]string{"a": "1", "b": "20", "d": "4", "e": "5", "f": "6", "g": "7"}}
+
+	// the drifts are reported, across pages
+	r := NewReconciler(fv, source, nil)
+	report, err := r.Reconcile(context.Background(), "ns", Options{PageSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, source.queries)
+	assert.Equal(t, 6, report.PeerKeys)
+	assert.Equal(t, 5, report.LocalKeys)
+	assert.Equal(t, []Drift{
+		{Key: "b", Kind: Differing, LocalBlock: 1, peer: []byte("20")},
+		{Key: "c", Kind: Extra, LocalBlock: 1},
+		{Key: "d", Kind: Missing, peer: []byte("4")},
+		{Key: "f", Kind: Missing, peer: []byte("6")},
+	}, report.Drifts)
+	assert.Equal(t, 0, report.Repaired)
+
+	// in the range only
+	report, err = r.Reconcile(context.Background(), "ns", Options{StartKey: "c", EndKey: "e"})
+	assert.NoError(t, err)
+	assert.Len(t, report.Drifts, 2)
+
+	// and repaired
+	source.states["k"] = "11"
+	commit(t, v, "tx2", 2, map[string]string{"k": "10"})
+	report, err = r.Reconcile(context.Background(), "ns", Options{PageSize: 2, Repair: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, report.Repaired)
+
+	qe, err := v.NewQueryExecutor()
+	assert.NoError(t, err)
+	for key, value := range source.states {
+		state, err := qe.GetState("ns", key)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(state))
+	}
+	state, err := qe.GetState("ns", "c")
+	assert.NoError(t, err)
+	assert.Empty(t, state)
+	qe.Done()
+
+	record, err := fv.RepairRecord("ns", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "fake", record.Source)
+	assert.Equal(t, report.ID, record.Reference)
+	assert.Equal(t, []byte("2"), record.Previous)
+	assert.Equal(t, uint64(1), record.PreviousBlock)
+	record, err = fv.RepairRecord("ns", "a")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	report, err = r.Reconcile(context.Background(), "ns", Options{})
+	assert.NoError(t, err)
+	assert.Empty(t, report.Drifts)
+
+	// unless committed again since the drift has been detected
+	applied, err := v.RepairStates([]driver.StateRepair{{Namespace: "ns", Key: "k", Value: []byte("12"), LocalBlock: 1}}, driver.RepairProvenance{})
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(50 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, l.Wait(ctx))
+	assert.NoError(t, NewLimiter(0).Wait(context.Background()))
+}
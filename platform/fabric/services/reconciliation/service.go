@@ -0,0 +1,123 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package reconciliation
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/pkg/errors"
+)
+
+// DefaultPageInterval is the minimum interval between two queries of the reconciliations to the peers
+const DefaultPageInterval = 200 * time.Millisecond
+
+var serviceType = reflect.TypeOf((*Service)(nil))
+
+// ErrAlreadyRunning is returned by Service#Reconcile when a reconciliation is running on the same channel
+var ErrAlreadyRunning = errors.New("a reconciliation is already running on the channel")
+
+// Limiter paces operations, letting one through every interval
+type Limiter struct {
+	interval time.Duration
+	lock     sync.Mutex
+	next     time.Time
+}
+
+// NewLimiter returns a limiter letting one operation through every interval, with no limit if the interval is not positive
+func NewLimiter(interval time.Duration) *Limiter {
+	return &Limiter{interval: interval}
+}
+
+// Wait blocks until the next operation can go through, or the context is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+	l.lock.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.lock.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Service runs the reconciliations of the vaults of this node, one at a time per channel.
+// The queries of all the reconciliations to the peers share the same limiter.
+type Service struct {
+	sp      view2.ServiceProvider
+	limiter *Limiter
+
+	lock    sync.Mutex
+	running map[string]bool
+}
+
+// NewService returns a service whose reconciliations query the peers at most once every pageInterval
+func NewService(sp view2.ServiceProvider, pageInterval time.Duration) *Service {
+	return &Service{sp: sp, limiter: NewLimiter(pageInterval), running: map[string]bool{}}
+}
+
+// GetService returns the reconciliation service registered in the passed service provider
+func GetService(sp view2.ServiceProvider) (*Service, error) {
+	s, err := sp.GetService(serviceType)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get reconciliation service")
+	}
+	return s.(*Service), nil
+}
+
+// Reconcile reconciles the passed namespace of the vault of the passed channel, see Reconciler#Reconcile.
+// It returns ErrAlreadyRunning if a reconciliation is running on the same channel.
+func (s *Service) Reconcile(ctx context.Context, network, channel, namespace string, opts Options) (*Report, error) {
+	fns := fabric.GetFabricNetworkService(s.sp, network)
+	if fns == nil {
+		return nil, errors.Errorf("fabric network [%s] not found", network)
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "channel [%s] not found", channel)
+	}
+
+	key := fns.Name() + ":" + ch.Name()
+	s.lock.Lock()
+	if s.running[key] {
+		s.lock.Unlock()
+		return nil, ErrAlreadyRunning
+	}
+	s.running[key] = true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.running, key)
+		s.lock.Unlock()
+	}()
+
+	source := opts.Source
+	if source == nil {
+		source = NewChaincodeSource(ch, DefaultQueryFunction)
+	}
+	return NewReconciler(ch.Vault(), source, s.limiter).Reconcile(ctx, namespace, opts)
+}
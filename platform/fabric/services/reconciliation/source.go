@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/pkg/errors"
+)
+
+// DefaultQueryFunction is the function of the chaincodes queried by a ChaincodeSource when not set otherwise
+const DefaultQueryFunction = "GetStateByRange"
+
+// ChaincodeSource reads the authoritative states of a namespace by querying the chaincode of the same name
+// on the peers of the organization of this node.
+// The function is invoked with the arguments startKey, endKey, pageSize, and bookmark, and must return
+// the JSON representation of a Page, as the chaincode can build it with GetStateByRangeWithPagination.
+// The queries bypass the query cache of the chaincode.
+type ChaincodeSource struct {
+	ch       *fabric.Channel
+	function string
+}
+
+// NewChaincodeSource returns a source querying the passed function, DefaultQueryFunction if empty
+func NewChaincodeSource(ch *fabric.Channel, function string) *ChaincodeSource {
+	if len(function) == 0 {
+		function = DefaultQueryFunction
+	}
+	return &ChaincodeSource{ch: ch, function: function}
+}
+
+func (s *ChaincodeSource) Name() string {
+	return fmt.Sprintf("chaincode function [%s] on the peers of channel [%s]", s.function, s.ch.Name())
+}
+
+func (s *ChaincodeSource) Page(ctx context.Context, namespace, startKey, endKey string, pageSize int, bookmark string) (*Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	raw, err := s.ch.Chaincode(namespace).
+		Query(s.function, startKey, endKey, strconv.Itoa(pageSize), bookmark).
+		WithEndorsersFromMyOrg().
+		WithNoCache().
+		Call()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed querying [%s:%s]", namespace, s.function)
+	}
+	page := &Page{}
+	if err := json.Unmarshal(raw, page); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling the result of [%s:%s]", namespace, s.function)
+	}
+	return page, nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statediff
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+// Request opens the reconciliation of a namespace at a height, the responder answers with the root of its digest
+type Request struct {
+	Network   string `json:"network"`
+	Channel   string `json:"channel"`
+	Namespace string `json:"namespace"`
+	// Height is the block the namespace is read at, once committed
+	Height uint64 `json:"height"`
+	Shape  Shape  `json:"shape"`
+	// Salt is chosen by the initiator, it salts all the digests of the reconciliation
+	Salt []byte `json:"salt"`
+}
+
+// Query asks the responder for the children of the passed nodes of a level of its digest, or for the entries of
+// the passed leaves if the level is the depth of the tree.
+// The initiator sends its own digests of the nodes: the responder only answers for the nodes whose digests differ,
+// and the entries of its leaves, so that it reports the differences too.
+type Query struct {
+	Level   int           `json:"level"`
+	Nodes   []int         `json:"nodes"`
+	Digests [][]byte      `json:"digests"`
+	Leaves  [][]LeafEntry `json:"leaves,omitempty"`
+	// Done closes the reconciliation, the digests match
+	Done bool `json:"done,omitempty"`
+}
+
+// Answer is the answer of the responder to a request or a query
+type Answer struct {
+	// Root is the root of the digest, in answer to the request
+	Root []byte `json:"root,omitempty"`
+	// Children are the digests of the children of the nodes queried, in the order of the query
+	Children [][][]byte `json:"children,omitempty"`
+	// Leaves are the entries of the leaves queried, in the order of the query
+	Leaves [][]LeafEntry `json:"leaves,omitempty"`
+}
+
+// Kind is the kind of a difference between the namespaces of two nodes
+type Kind string
+
+const (
+	// Missing keys are held by the remote node only
+	Missing Kind = "missing"
+	// Extra keys are held by the local node only
+	Extra Kind = "extra"
+	// Differing keys have different values on the two nodes
+	Differing Kind = "differing"
+)
+
+// Difference is a key whose state differs between the two nodes
+type Difference struct {
+	Key  string `json:"key"`
+	Kind Kind   `json:"kind"`
+	// LocalValue is the value held by this node, nil if none
+	LocalValue []byte `json:"localValue,omitempty"`
+	// RemoteValue is the value held by the remote node, nil if none or if its policy does not share the values
+	RemoteValue []byte `json:"remoteValue,omitempty"`
+}
+
+// Report is the outcome of the reconciliation of a namespace between this node and a remote one
+type Report struct {
+	Network   string        `json:"network"`
+	Channel   string        `json:"channel"`
+	Namespace string        `json:"namespace"`
+	Height    uint64        `json:"height"`
+	Party     view.Identity `json:"party"`
+	// Initiator is true if this node initiated the reconciliation
+	Initiator   bool         `json:"initiator"`
+	LocalRoot   []byte       `json:"localRoot"`
+	RemoteRoot  []byte       `json:"remoteRoot"`
+	Differences []Difference `json:"differences,omitempty"`
+	// Digests is the number of digests received from the remote node, the leaf entries included
+	Digests int `json:"digests"`
+}
+
+// Consistent returns true if the namespaces of the two nodes match
+func (r *Report) Consistent() bool {
+	return bytes.Equal(r.LocalRoot, r.RemoteRoot)
+}
+
+// diffLeaf returns the differences between the passed entries of a leaf, local and remote, sorted by key
+func diffLeaf(local, remote []LeafEntry, values func(key string) []byte) []Difference {
+	remotes := make(map[string]LeafEntry, len(remote))
+	for _, e := range remote {
+		remotes[e.Key] = e
+	}
+	var res []Difference
+	for _, l := range local {
+		r, ok := remotes[l.Key]
+		delete(remotes, l.Key)
+		switch {
+		case !ok:
+			res = append(res, Difference{Key: l.Key, Kind: Extra, LocalValue: values(l.Key)})
+		case !bytes.Equal(l.ValueDigest, r.ValueDigest):
+			res = append(res, Difference{Key: l.Key, Kind: Differing, LocalValue: values(l.Key), RemoteValue: r.Value})
+		}
+	}
+	for _, r := range remotes {
+		res = append(res, Difference{Key: r.Key, Kind: Missing, RemoteValue: r.Value})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
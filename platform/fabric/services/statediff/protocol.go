@@ -0,0 +1,258 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statediff
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Exchange sends the passed message, a request or a query, to the responder and returns its answer.
+// The queries closing the reconciliation get no answer.
+type Exchange func(msg interface{}) (*Answer, error)
+
+// Reconcile descends the passed digest, with the responder reached by the passed exchange, from the roots
+// to the leaves whose digests differ, exchanging only the digests of the nodes that differ. The entries of the
+// leaves that differ are then exchanged, with their values if shareValues is set, to identify the keys that differ.
+// The answers of the responder are checked against the digests it sent before.
+func Reconcile(local *Tree, request *Request, shareValues bool, exchange Exchange) (*Report, error) {
+	report := &Report{
+		Network:   request.Network,
+		Channel:   request.Channel,
+		Namespace: request.Namespace,
+		Height:    request.Height,
+		Initiator: true,
+		LocalRoot: local.Root(),
+	}
+	answer, err := exchange(request)
+	if err != nil {
+		return nil, err
+	}
+	if len(answer.Root) == 0 {
+		return nil, errors.Errorf("no root digest received")
+	}
+	report.RemoteRoot = answer.Root
+	report.Digests = 1
+	if report.Consistent() {
+		if _, err := exchange(&Query{Done: true}); err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+
+	shape := local.Shape()
+	nodes := []int{0}
+	remotes := [][]byte{answer.Root}
+	for level := 0; level < shape.Depth; level++ {
+		query, err := newQuery(local, level, nodes)
+		if err != nil {
+			return nil, err
+		}
+		answer, err := exchange(query)
+		if err != nil {
+			return nil, err
+		}
+		if len(answer.Children) != len(nodes) {
+			return nil, errors.Errorf("received the children of [%d] nodes of level [%d], expected [%d]", len(answer.Children), level, len(nodes))
+		}
+		var next []int
+		var nextRemotes [][]byte
+		for i, node := range nodes {
+			children := answer.Children[i]
+			if len(children) != shape.Fanout {
+				return nil, errors.Errorf("received [%d] children of node [%d] of level [%d], expected [%d]", len(children), node, level, shape.Fanout)
+			}
+			if !bytes.Equal(local.digest(children...), remotes[i]) {
+				return nil, errors.Errorf("children of node [%d] of level [%d] do not match its digest", node, level)
+			}
+			report.Digests += len(children)
+			locals, err := local.Children(level, node)
+			if err != nil {
+				return nil, err
+			}
+			for j, child := range children {
+				if !bytes.Equal(child, locals[j]) {
+					next = append(next, node*shape.Fanout+j)
+					nextRemotes = append(nextRemotes, child)
+				}
+			}
+		}
+		nodes, remotes = next, nextRemotes
+	}
+
+	query, err := newQuery(local, shape.Depth, nodes)
+	if err != nil {
+		return nil, err
+	}
+	for _, leaf := range nodes {
+		entries, err := local.Leaf(leaf, shareValues)
+		if err != nil {
+			return nil, err
+		}
+		query.Leaves = append(query.Leaves, entries)
+	}
+	answer, err = exchange(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(answer.Leaves) != len(nodes) {
+		return nil, errors.Errorf("received the entries of [%d] leaves, expected [%d]", len(answer.Leaves), len(nodes))
+	}
+	for i, leaf := range nodes {
+		entries := answer.Leaves[i]
+		if err := checkLeaf(local, leaf, entries, remotes[i]); err != nil {
+			return nil, err
+		}
+		report.Digests += len(entries)
+		report.Differences = append(report.Differences, diffLeaf(query.Leaves[i], entries, local.Value)...)
+	}
+	sort.Slice(report.Differences, func(i, j int) bool { return report.Differences[i].Key < report.Differences[j].Key })
+	return report, nil
+}
+
+// newQuery returns the query of the passed nodes of the passed level, with their local digests
+func newQuery(local *Tree, level int, nodes []int) (*Query, error) {
+	query := &Query{Level: level, Nodes: nodes}
+	for _, node := range nodes {
+		digest, err := local.Node(level, node)
+		if err != nil {
+			return nil, err
+		}
+		query.Digests = append(query.Digests, digest)
+	}
+	return query, nil
+}
+
+// checkLeaf checks that the passed entries, received from the other node, are sorted, match their values if any,
+// and match the digest of the leaf the other node sent
+func checkLeaf(local *Tree, leaf int, entries []LeafEntry, digest []byte) error {
+	for i, e := range entries {
+		if i > 0 && entries[i-1].Key >= e.Key {
+			return errors.Errorf("entries of leaf [%d] not sorted", leaf)
+		}
+		if e.Value != nil && !bytes.Equal(local.digest(e.Value), e.ValueDigest) {
+			return errors.Errorf("value of key [%s] does not match its digest", e.Key)
+		}
+	}
+	if !bytes.Equal(local.LeafDigest(entries), digest) {
+		return errors.Errorf("entries of leaf [%d] do not match its digest", leaf)
+	}
+	return nil
+}
+
+// Responder answers the queries of the initiator of a reconciliation, and reports the differences too.
+// It only answers for the nodes whose digests differ, descending the tree level by level from the root,
+// so that the initiator learns the keys of the leaves that differ only.
+type Responder struct {
+	tree        *Tree
+	shareValues bool
+	report      *Report
+
+	// level is the level of the next query, allowed its nodes
+	level   int
+	allowed map[int]bool
+}
+
+// NewResponder returns the responder to the passed request, with the passed digest
+func NewResponder(request *Request, tree *Tree, shareValues bool) *Responder {
+	return &Responder{
+		tree:        tree,
+		shareValues: shareValues,
+		report: &Report{
+			Network:   request.Network,
+			Channel:   request.Channel,
+			Namespace: request.Namespace,
+			Height:    request.Height,
+			LocalRoot: tree.Root(),
+		},
+		allowed: map[int]bool{0: true},
+	}
+}
+
+// Root returns the answer to the request
+func (r *Responder) Root() *Answer {
+	return &Answer{Root: r.tree.Root()}
+}
+
+// Answer answers the passed query, it returns nil once the reconciliation is over: then Report is complete
+func (r *Responder) Answer(query *Query) (*Answer, error) {
+	if query.Done {
+		// the initiator found the roots match
+		r.report.RemoteRoot = r.report.LocalRoot
+		return nil, nil
+	}
+	shape := r.tree.Shape()
+	if query.Level != r.level {
+		return nil, errors.Errorf("query of level [%d], expected level [%d]", query.Level, r.level)
+	}
+	if len(query.Nodes) == 0 || len(query.Nodes) != len(query.Digests) {
+		return nil, errors.Errorf("query of [%d] nodes with [%d] digests", len(query.Nodes), len(query.Digests))
+	}
+	if query.Level == 0 {
+		r.report.RemoteRoot = query.Digests[0]
+	}
+	for i, node := range query.Nodes {
+		if !r.allowed[node] {
+			return nil, errors.Errorf("node [%d] of level [%d] not under a node that differs", node, query.Level)
+		}
+		digest, err := r.tree.Node(query.Level, node)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(digest, query.Digests[i]) {
+			return nil, errors.Errorf("node [%d] of level [%d] does not differ", node, query.Level)
+		}
+		r.report.Digests++
+	}
+
+	if query.Level < shape.Depth {
+		answer := &Answer{}
+		allowed := map[int]bool{}
+		for _, node := range query.Nodes {
+			children, err := r.tree.Children(query.Level, node)
+			if err != nil {
+				return nil, err
+			}
+			answer.Children = append(answer.Children, children)
+			for j := range children {
+				allowed[node*shape.Fanout+j] = true
+			}
+		}
+		r.level++
+		r.allowed = allowed
+		return answer, nil
+	}
+
+	if len(query.Leaves) != len(query.Nodes) {
+		return nil, errors.Errorf("query of [%d] leaves with the entries of [%d]", len(query.Nodes), len(query.Leaves))
+	}
+	answer := &Answer{}
+	for i, leaf := range query.Nodes {
+		if err := checkLeaf(r.tree, leaf, query.Leaves[i], query.Digests[i]); err != nil {
+			return nil, err
+		}
+		entries, err := r.tree.Leaf(leaf, r.shareValues)
+		if err != nil {
+			return nil, err
+		}
+		answer.Leaves = append(answer.Leaves, entries)
+		r.report.Digests += len(query.Leaves[i])
+		r.report.Differences = append(r.report.Differences, diffLeaf(entries, query.Leaves[i], r.tree.Value)...)
+	}
+	sort.Slice(r.report.Differences, func(i, j int) bool { return r.report.Differences[i].Key < r.report.Differences[j].Key })
+	// no other query is allowed
+	r.level++
+	r.allowed = nil
+	return answer, nil
+}
+
+// Report returns the differences the responder found so far
+func (r *Responder) Report() *Report {
+	return r.report
+}
@@ -0,0 +1,236 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statediff
+
+import (
+	"context"
+	"crypto/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/statesharing"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.statediff")
+
+// DefaultTimeout is the time a node waits for each message of the other node during a reconciliation
+const DefaultTimeout = 30 * time.Second
+
+var serviceType = reflect.TypeOf((*Service)(nil))
+
+// Policy rules the reconciliations of a namespace other nodes initiate with this node
+type Policy struct {
+	// ACL lists the nodes allowed to reconcile the namespace with this node
+	ACL *statesharing.ACL
+	// ShareValues sends the values of the keys that differ, and not only their digests.
+	// It applies to the reconciliations this node initiates too.
+	ShareValues bool
+}
+
+// StateReader reads the states of a namespace once the passed block has been committed
+type StateReader interface {
+	States(network, channel, namespace string, height uint64) (map[string][]byte, error)
+}
+
+// Service reconciles the namespaces of the vault of this node with the ones of other nodes, at an agreed height,
+// and reports the keys that differ. The nodes exchange the digests of their namespaces only, descending from the
+// roots to the keys that differ, and the values of these keys if the policy of the namespace shares them.
+//
+// The initiator runs ReconcileView towards the other node, that responds with ReconcileResponderView,
+// registered by the SDK as the responder of ReconcileView.
+type Service struct {
+	sp      view2.ServiceProvider
+	reader  StateReader
+	timeout time.Duration
+
+	lock      sync.RWMutex
+	policies  map[string]*Policy
+	listeners []func(report *Report)
+}
+
+// NewService returns a service reading the namespaces from the vaults, at past heights, that waits for each message
+// of the other nodes up to timeout, DefaultTimeout if not positive
+func NewService(sp view2.ServiceProvider, timeout time.Duration) *Service {
+	return NewServiceWithReader(sp, &vaultReader{sp: sp}, timeout)
+}
+
+// NewServiceWithReader returns a service reading the namespaces with the passed reader
+func NewServiceWithReader(sp view2.ServiceProvider, reader StateReader, timeout time.Duration) *Service {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Service{sp: sp, reader: reader, timeout: timeout, policies: map[string]*Policy{}}
+}
+
+// GetService returns the state diff service registered in the passed service provider
+func GetService(sp view2.ServiceProvider) (*Service, error) {
+	s, err := sp.GetService(serviceType)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get state diff service")
+	}
+	return s.(*Service), nil
+}
+
+// Allow lets the nodes of the passed policy reconcile the passed namespace with this node.
+// A nil policy disallows the reconciliations again.
+func (s *Service) Allow(network, channel, namespace string, policy *Policy) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := policyKey(network, channel, namespace)
+	if policy == nil {
+		delete(s.policies, key)
+		return
+	}
+	s.policies[key] = policy
+}
+
+// OnReport registers a listener notified of the reports of the reconciliations the other nodes initiate
+func (s *Service) OnReport(listener func(report *Report)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Option sets an option of a reconciliation
+type Option func(*options)
+
+type options struct {
+	shape   Shape
+	timeout time.Duration
+}
+
+// WithShape sets the shape of the digest trees, DefaultShape if not set
+func WithShape(shape Shape) Option {
+	return func(o *options) {
+		o.shape = shape
+	}
+}
+
+// WithTimeout sets the time to wait for each message of the other node, the one of the service if not set
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// Reconcile reconciles the passed namespace, as it was once the passed block has been committed, with the passed node
+// and returns the keys that differ. The block must be committed on both nodes, and the namespace must keep its history
+// on both nodes.
+func (s *Service) Reconcile(ctx context.Context, network, channel, namespace string, height uint64, party view.Identity, opts ...Option) (*Report, error) {
+	o := &options{shape: DefaultShape(), timeout: s.timeout}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := o.shape.Validate(); err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed generating salt")
+	}
+	request := &Request{
+		Network:   network,
+		Channel:   channel,
+		Namespace: namespace,
+		Height:    height,
+		Shape:     o.shape,
+		Salt:      salt,
+	}
+	tree, err := s.Digest(request)
+	if err != nil {
+		return nil, err
+	}
+	var shareValues bool
+	if policy := s.policy(network, channel, namespace); policy != nil {
+		shareValues = policy.ShareValues
+	}
+	report, err := view2.GetManager(s.sp).InitiateView(&ReconcileView{
+		ctx:         ctx,
+		request:     request,
+		tree:        tree,
+		shareValues: shareValues,
+		party:       party,
+		timeout:     o.timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report.(*Report), nil
+}
+
+// Digest returns the digest tree of the namespace of the passed request
+func (s *Service) Digest(request *Request) (*Tree, error) {
+	states, err := s.reader.States(request.Network, request.Channel, request.Namespace, request.Height)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed reading namespace [%s] at height [%d]", policyKey(request.Network, request.Channel, request.Namespace), request.Height)
+	}
+	return NewTree(request.Shape, request.Salt, states)
+}
+
+// policy returns the policy of the passed namespace, nil if no node is allowed
+func (s *Service) policy(network, channel, namespace string) *Policy {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.policies[policyKey(network, channel, namespace)]
+}
+
+// notify passes the report to the listeners
+func (s *Service) notify(report *Report) {
+	s.lock.RLock()
+	listeners := s.listeners
+	s.lock.RUnlock()
+	for _, listener := range listeners {
+		listener(report)
+	}
+}
+
+func policyKey(network, channel, namespace string) string {
+	return network + ":" + channel + ":" + namespace
+}
+
+// vaultReader reads the namespaces from the vaults at past heights
+type vaultReader struct {
+	sp view2.ServiceProvider
+}
+
+func (r *vaultReader) States(network, channel, namespace string, height uint64) (map[string][]byte, error) {
+	fns := fabric.GetFabricNetworkService(r.sp, network)
+	if fns == nil {
+		return nil, errors.Errorf("fabric network [%s] not found", network)
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "channel [%s] not found", channel)
+	}
+	qe, err := ch.Vault().NewQueryExecutorAt(height)
+	if err != nil {
+		return nil, err
+	}
+	defer qe.Done()
+	it, err := qe.GetStateRangeScanIterator(namespace, "", "")
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	defer it.Close()
+	states := map[string][]byte{}
+	for {
+		read, err := it.Next()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+		}
+		if read == nil {
+			return states, nil
+		}
+		states[read.Key] = read.Raw
+	}
+}
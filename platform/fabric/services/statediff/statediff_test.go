@@ -0,0 +1,207 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statediff
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// pipe returns an exchange with the passed responder, the messages are marshalled as on a session.
+// tamper, if not nil, changes the answers before the initiator receives them.
+func pipe(t *testing.T, remote map[string][]byte, shareValues bool, tamper func(a *Answer)) (Exchange, func() *Responder) {
+	var responder *Responder
+	return func(msg interface{}) (*Answer, error) {
+		raw, err := json.Marshal(msg)
+		assert.NoError(t, err)
+		var answer *Answer
+		if responder == nil {
+			request := &Request{}
+			assert.NoError(t, json.Unmarshal(raw, request))
+			tree, err := NewTree(request.Shape, request.Salt, remote)
+			if err != nil {
+				return nil, err
+			}
+			responder = NewResponder(request, tree, shareValues)
+			answer = responder.Root()
+		} else {
+			query := &Query{}
+			assert.NoError(t, json.Unmarshal(raw, query))
+			if answer, err = responder.Answer(query); err != nil || answer == nil {
+				return nil, err
+			}
+		}
+		if tamper != nil {
+			tamper(answer)
+		}
+		raw, err = json.Marshal(answer)
+		assert.NoError(t, err)
+		res := &Answer{}
+		assert.NoError(t, json.Unmarshal(raw, res))
+		return res, nil
+	}, func() *Responder { return responder }
+}
+
+func states(n int) map[string][]byte {
+	res := map[string][]byte{}
+	for i := 0; i < n; i++ {
+		res[fmt.Sprintf("key%04d", i)] = []byte(fmt.Sprintf("value%d", i))
+	}
+	return res
+}
+
+func request() *Request {
+	return &Request{Network: "default", Channel: "testchannel", Namespace: "ns", Height: 10, Shape: Shape{Fanout: 4, Depth: 3}, Salt: []byte("salt")}
+}
+
+func TestReconcileConsistent(t *testing.T) {
+	local := states(100)
+	tree, err := NewTree(request().Shape, request().Salt, local)
+	assert.NoError(t, err)
+
+	exchange, responder := pipe(t, states(100), true, nil)
+	report, err := Reconcile(tree, request(), true, exchange)
+	assert.NoError(t, err)
+	assert.True(t, report.Consistent())
+	assert.Empty(t, report.Differences)
+	// the roots only have been exchanged
+	assert.Equal(t, 1, report.Digests)
+	assert.True(t, responder().Report().Consistent())
+}
+
+func TestReconcileDivergence(t *testing.T) {
+	local := states(1000)
+	remote := states(1000)
+	remote["key0010"] = []byte("diverged")
+	delete(remote, "key0500")
+	remote["key9999"] = []byte("new")
+
+	for _, shareValues := range []bool{true, false} {
+		tree, err := NewTree(request().Shape, request().Salt, local)
+		assert.NoError(t, err)
+		exchange, responder := pipe(t, remote, shareValues, nil)
+		report, err := Reconcile(tree, request(), shareValues, exchange)
+		assert.NoError(t, err)
+		assert.False(t, report.Consistent())
+		assert.True(t, report.Initiator)
+		assert.Equal(t, "ns", report.Namespace)
+
+		remoteValue := func(v string) []byte {
+			if !shareValues {
+				return nil
+			}
+			return []byte(v)
+		}
+		assert.Equal(t, []Difference{
+			{Key: "key0010", Kind: Differing, LocalValue: []byte("value10"), RemoteValue: remoteValue("diverged")},
+			{Key: "key0500", Kind: Extra, LocalValue: []byte("value500")},
+			{Key: "key9999", Kind: Missing, RemoteValue: remoteValue("new")},
+		}, report.Differences)
+		// far fewer digests than the keys have been exchanged
+		assert.True(t, report.Digests < 200, "[%d] digests", report.Digests)
+
+		// the responder reports the same differences, from its side
+		other := responder().Report()
+		assert.False(t, other.Initiator)
+		assert.Equal(t, report.LocalRoot, other.RemoteRoot)
+		assert.Equal(t, report.RemoteRoot, other.LocalRoot)
+		assert.Equal(t, []Difference{
+			{Key: "key0010", Kind: Differing, LocalValue: []byte("diverged"), RemoteValue: remoteValue("value10")},
+			{Key: "key0500", Kind: Missing, RemoteValue: remoteValue("value500")},
+			{Key: "key9999", Kind: Extra, LocalValue: []byte("new")},
+		}, other.Differences)
+	}
+}
+
+func TestReconcileTampering(t *testing.T) {
+	local := states(100)
+	remote := states(100)
+	remote["key0010"] = []byte("diverged")
+	tree, err := NewTree(request().Shape, request().Salt, local)
+	assert.NoError(t, err)
+
+	// a leaf not matching the digests sent before
+	exchange, _ := pipe(t, remote, true, func(a *Answer) {
+		for _, leaf := range a.Leaves {
+			for i := range leaf {
+				leaf[i].ValueDigest = tree.digest([]byte("forged"))
+				leaf[i].Value = []byte("forged")
+			}
+		}
+	})
+	_, err = Reconcile(tree, request(), true, exchange)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "do not match its digest")
+
+	// a value not matching its digest
+	exchange, _ = pipe(t, remote, true, func(a *Answer) {
+		for _, leaf := range a.Leaves {
+			for i := range leaf {
+				leaf[i].Value = []byte("forged")
+			}
+		}
+	})
+	_, err = Reconcile(tree, request(), true, exchange)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match its digest")
+
+	// children not matching their parent
+	exchange, _ = pipe(t, remote, true, func(a *Answer) {
+		if len(a.Children) != 0 {
+			a.Children[0][0] = tree.digest([]byte("forged"))
+		}
+	})
+	_, err = Reconcile(tree, request(), true, exchange)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "children of node [0] of level [0] do not match its digest")
+}
+
+func TestResponderRefusals(t *testing.T) {
+	r := request()
+	tree, err := NewTree(r.Shape, r.Salt, states(100))
+	assert.NoError(t, err)
+	other, err := NewTree(r.Shape, r.Salt, states(99))
+	assert.NoError(t, err)
+
+	// the nodes that match are not descended
+	_, err = NewResponder(r, tree, true).Answer(&Query{Level: 0, Nodes: []int{0}, Digests: [][]byte{tree.Root()}})
+	assert.EqualError(t, err, "node [0] of level [0] does not differ")
+
+	// the levels are descended in order, under the nodes that differ
+	responder := NewResponder(r, tree, true)
+	_, err = responder.Answer(&Query{Level: 1, Nodes: []int{0}, Digests: [][]byte{other.Root()}})
+	assert.EqualError(t, err, "query of level [1], expected level [0]")
+	answer, err := responder.Answer(&Query{Level: 0, Nodes: []int{0}, Digests: [][]byte{other.Root()}})
+	assert.NoError(t, err)
+	assert.Len(t, answer.Children, 1)
+	assert.Len(t, answer.Children[0], 4)
+	_, err = responder.Answer(&Query{Level: 1, Nodes: []int{4}, Digests: [][]byte{other.Root()}})
+	assert.EqualError(t, err, "node [4] of level [1] not under a node that differs")
+}
+
+func TestShape(t *testing.T) {
+	assert.NoError(t, DefaultShape().Validate())
+	assert.EqualError(t, Shape{Fanout: 1, Depth: 3}.Validate(), "fanout [1] out of [2, 256]")
+	assert.EqualError(t, Shape{Fanout: 16, Depth: 5}.Validate(), "depth [5] out of [1, 4]")
+	assert.EqualError(t, Shape{Fanout: 256, Depth: 3}.Validate(), "[16777216] leaves exceed the limit of [1048576] leaves")
+
+	// the digests are salted
+	a, err := NewTree(DefaultShape(), []byte("a"), states(10))
+	assert.NoError(t, err)
+	b, err := NewTree(DefaultShape(), []byte("b"), states(10))
+	assert.NoError(t, err)
+	assert.NotEqual(t, a.Root(), b.Root())
+
+	_, err = Reconcile(a, &Request{Shape: DefaultShape()}, false, func(msg interface{}) (*Answer, error) {
+		return nil, errors.New("unreachable")
+	})
+	assert.EqualError(t, err, "unreachable")
+}
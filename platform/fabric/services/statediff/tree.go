@@ -0,0 +1,218 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statediff
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultFanout is the number of children of the inner nodes of the digest trees
+	DefaultFanout = 16
+	// DefaultDepth is the number of levels of the digest trees under the root, their leaves are DefaultFanout^DefaultDepth
+	DefaultDepth = 3
+
+	// MaxFanout and MaxDepth bound the shape of the trees the nodes agree on, and then the memory a digest takes
+	MaxFanout = 256
+	MaxDepth  = 4
+	maxLeaves = 1 << 20
+)
+
+// Shape is the shape of a digest tree, both nodes must agree on it and on the salt
+type Shape struct {
+	Fanout int `json:"fanout"`
+	Depth  int `json:"depth"`
+}
+
+// DefaultShape returns the shape of the digest trees, unless the reconciliation options set another one
+func DefaultShape() Shape {
+	return Shape{Fanout: DefaultFanout, Depth: DefaultDepth}
+}
+
+// Validate checks that the shape is within MaxFanout and MaxDepth
+func (s Shape) Validate() error {
+	if s.Fanout < 2 || s.Fanout > MaxFanout {
+		return errors.Errorf("fanout [%d] out of [2, %d]", s.Fanout, MaxFanout)
+	}
+	if s.Depth < 1 || s.Depth > MaxDepth {
+		return errors.Errorf("depth [%d] out of [1, %d]", s.Depth, MaxDepth)
+	}
+	if s.leaves() > maxLeaves {
+		return errors.Errorf("[%d] leaves exceed the limit of [%d] leaves", s.leaves(), maxLeaves)
+	}
+	return nil
+}
+
+// leaves returns the number of leaves
+func (s Shape) leaves() int {
+	n := 1
+	for i := 0; i < s.Depth; i++ {
+		n *= s.Fanout
+	}
+	return n
+}
+
+// width returns the number of nodes at the passed level, the root is at level 0
+func (s Shape) width(level int) int {
+	n := 1
+	for i := 0; i < level; i++ {
+		n *= s.Fanout
+	}
+	return n
+}
+
+// LeafEntry is a key of a leaf of a digest tree, with the digest of its value
+type LeafEntry struct {
+	Key         string `json:"key"`
+	ValueDigest []byte `json:"valueDigest"`
+	// Value is sent only if the policy of the node sending the entry shares the values
+	Value []byte `json:"value,omitempty"`
+}
+
+// Tree is the Merkle tree digest of a namespace at a height.
+// The keys are spread on the leaves by the digest of the key, so that the trees of two nodes have the same shape
+// whatever keys they hold: a key is in the same leaf on both sides. A leaf is the digest of its keys, sorted, with
+// the digest of their values, an inner node the digest of its children. All the digests are salted, so that the
+// digest of a value with little entropy cannot be matched against guesses.
+type Tree struct {
+	shape  Shape
+	salt   []byte
+	values map[string][]byte
+	// levels holds the digests of the nodes, level by level from the root
+	levels [][][]byte
+	// leaves holds the entries of the non-empty leaves, sorted by key
+	leaves map[int][]LeafEntry
+}
+
+// NewTree returns the digest tree of the passed states, with the passed shape and salt
+func NewTree(shape Shape, salt []byte, states map[string][]byte) (*Tree, error) {
+	if err := shape.Validate(); err != nil {
+		return nil, err
+	}
+	t := &Tree{
+		shape:  shape,
+		salt:   salt,
+		values: states,
+		levels: make([][][]byte, shape.Depth+1),
+		leaves: map[int][]LeafEntry{},
+	}
+	for key, value := range states {
+		leaf := t.leafOf(key)
+		t.leaves[leaf] = append(t.leaves[leaf], LeafEntry{Key: key, ValueDigest: t.digest(value)})
+	}
+
+	leaves := make([][]byte, shape.leaves())
+	empty := t.digest(nil)
+	for i := range leaves {
+		entries, ok := t.leaves[i]
+		if !ok {
+			leaves[i] = empty
+			continue
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+		leaves[i] = t.leafDigest(entries)
+	}
+	t.levels[shape.Depth] = leaves
+	for level := shape.Depth - 1; level >= 0; level-- {
+		children := t.levels[level+1]
+		nodes := make([][]byte, shape.width(level))
+		for i := range nodes {
+			nodes[i] = t.digest(children[i*shape.Fanout : (i+1)*shape.Fanout]...)
+		}
+		t.levels[level] = nodes
+	}
+	return t, nil
+}
+
+// Root returns the digest of the whole namespace
+func (t *Tree) Root() []byte {
+	return t.levels[0][0]
+}
+
+// Shape returns the shape of the tree
+func (t *Tree) Shape() Shape {
+	return t.shape
+}
+
+// Node returns the digest of the passed node of the passed level
+func (t *Tree) Node(level, index int) ([]byte, error) {
+	if level < 0 || level > t.shape.Depth {
+		return nil, errors.Errorf("level [%d] out of the tree, [%d] levels deep", level, t.shape.Depth)
+	}
+	if index < 0 || index >= len(t.levels[level]) {
+		return nil, errors.Errorf("node [%d] out of level [%d]", index, level)
+	}
+	return t.levels[level][index], nil
+}
+
+// Children returns the digests of the children of the passed node of the passed level, in order
+func (t *Tree) Children(level, index int) ([][]byte, error) {
+	if level < 0 || level >= t.shape.Depth {
+		return nil, errors.Errorf("level [%d] has no children, the tree is [%d] levels deep", level, t.shape.Depth)
+	}
+	if index < 0 || index >= len(t.levels[level]) {
+		return nil, errors.Errorf("node [%d] out of level [%d]", index, level)
+	}
+	return t.levels[level+1][index*t.shape.Fanout : (index+1)*t.shape.Fanout], nil
+}
+
+// Leaf returns the entries of the passed leaf, sorted by key, with their values if withValues is set
+func (t *Tree) Leaf(index int, withValues bool) ([]LeafEntry, error) {
+	if index < 0 || index >= t.shape.leaves() {
+		return nil, errors.Errorf("leaf [%d] out of the [%d] leaves", index, t.shape.leaves())
+	}
+	entries := t.leaves[index]
+	res := make([]LeafEntry, len(entries))
+	for i, e := range entries {
+		res[i] = LeafEntry{Key: e.Key, ValueDigest: e.ValueDigest}
+		if withValues {
+			res[i].Value = t.values[e.Key]
+		}
+	}
+	return res, nil
+}
+
+// Value returns the value of the passed key, nil if the namespace does not hold it
+func (t *Tree) Value(key string) []byte {
+	return t.values[key]
+}
+
+// LeafDigest returns the digest of a leaf holding the passed entries, sorted by key, to check what a node sent
+func (t *Tree) LeafDigest(entries []LeafEntry) []byte {
+	return t.leafDigest(entries)
+}
+
+// leafOf returns the leaf of the passed key
+func (t *Tree) leafOf(key string) int {
+	h := sha256.Sum256([]byte(key))
+	return int(binary.BigEndian.Uint64(h[:8]) % uint64(t.shape.leaves()))
+}
+
+func (t *Tree) leafDigest(entries []LeafEntry) []byte {
+	parts := make([][]byte, 0, 2*len(entries))
+	for _, e := range entries {
+		key := make([]byte, 8+len(e.Key))
+		binary.BigEndian.PutUint64(key, uint64(len(e.Key)))
+		copy(key[8:], e.Key)
+		parts = append(parts, key, e.ValueDigest)
+	}
+	return t.digest(parts...)
+}
+
+// digest returns the salted digest of the concatenation of the passed parts
+func (t *Tree) digest(parts ...[]byte) []byte {
+	h := sha256.New()
+	h.Write(t.salt)
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statediff
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/services/statesharing"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/session"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// ReconcileView reconciles a namespace with another node, see Service#Reconcile
+type ReconcileView struct {
+	ctx         context.Context
+	request     *Request
+	tree        *Tree
+	shareValues bool
+	party       view.Identity
+	timeout     time.Duration
+}
+
+func (v *ReconcileView) Call(context view.Context) (interface{}, error) {
+	s, err := context.GetSession(v, v.party)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed opening session to [%s]", v.party)
+	}
+	defer s.Close()
+
+	ch := s.Receive()
+	report, err := Reconcile(v.tree, v.request, v.shareValues, func(msg interface{}) (*Answer, error) {
+		if err := send(s, msg); err != nil {
+			return nil, errors.WithMessagef(err, "failed sending to [%s]", v.party)
+		}
+		if q, ok := msg.(*Query); ok && q.Done {
+			return nil, nil
+		}
+		answer := &Answer{}
+		if err := receive(v.ctx, context.Context(), ch, v.timeout, answer); err != nil {
+			return nil, errors.WithMessagef(err, "failed receiving from [%s]", v.party)
+		}
+		return answer, nil
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed reconciling [%s] with [%s]", policyKey(v.request.Network, v.request.Channel, v.request.Namespace), v.party)
+	}
+	report.Party = v.party
+	logger.Infof("[%s] reconciled with [%s] at height [%d]: [%d] differences, [%d] digests received", policyKey(report.Network, report.Channel, report.Namespace), v.party, report.Height, len(report.Differences), report.Digests)
+	return report, nil
+}
+
+// ReconcileResponderView answers a node reconciling a namespace with this node: it checks the policy of the
+// namespace, then answers the queries of the initiator and reports the differences to the listeners of the service
+type ReconcileResponderView struct{}
+
+func (v *ReconcileResponderView) Call(context view.Context) (interface{}, error) {
+	s, raw, err := session.ReadFirstMessage(context)
+	if err != nil {
+		return nil, err
+	}
+	request := &Request{}
+	if err := json.Unmarshal(raw, request); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling reconciliation request")
+	}
+	service, err := GetService(context)
+	if err != nil {
+		return nil, err
+	}
+	caller := s.Info().Caller
+	key := policyKey(request.Network, request.Channel, request.Namespace)
+	policy := service.policy(request.Network, request.Channel, request.Namespace)
+	if policy == nil || !policy.ACL.Allows(caller, statesharing.ChannelMSPs(context, request.Network, request.Channel)) {
+		// the nodes not allowed do not learn whether the namespace exists
+		return nil, sendError(s, errors.Errorf("namespace [%s] cannot be reconciled by [%s]", key, caller))
+	}
+	if err := request.Shape.Validate(); err != nil {
+		return nil, sendError(s, err)
+	}
+	tree, err := service.Digest(request)
+	if err != nil {
+		return nil, sendError(s, err)
+	}
+
+	responder := NewResponder(request, tree, policy.ShareValues)
+	if err := send(s, responder.Root()); err != nil {
+		return nil, errors.WithMessagef(err, "failed sending root to [%s]", caller)
+	}
+	ch := s.Receive()
+	for {
+		query := &Query{}
+		if err := receive(context.Context(), context.Context(), ch, service.timeout, query); err != nil {
+			return nil, errors.WithMessagef(err, "failed receiving from [%s]", caller)
+		}
+		answer, err := responder.Answer(query)
+		if err != nil {
+			return nil, sendError(s, err)
+		}
+		if answer == nil {
+			break
+		}
+		if err := send(s, answer); err != nil {
+			return nil, errors.WithMessagef(err, "failed sending to [%s]", caller)
+		}
+		if query.Level == request.Shape.Depth {
+			break
+		}
+	}
+
+	report := responder.Report()
+	report.Party = caller
+	logger.Infof("[%s] reconciled by [%s] at height [%d]: [%d] differences", key, caller, report.Height, len(report.Differences))
+	service.notify(report)
+	return report, nil
+}
+
+func send(s view.Session, msg interface{}) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling message")
+	}
+	return s.Send(raw)
+}
+
+// sendError sends the passed error to the other node and returns it
+func sendError(s view.Session, err error) error {
+	if err1 := s.SendError([]byte(err.Error())); err1 != nil {
+		logger.Warnf("failed sending error to [%s]: [%s]", s.Info().Caller, err1)
+	}
+	return err
+}
+
+// receive unmarshals the next message of the passed channel into msg, unless a context is done or the timeout expires
+func receive(ctx, viewCtx context.Context, ch <-chan *view.Message, timeout time.Duration, msg interface{}) error {
+	if ctx == nil {
+		ctx = viewCtx
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("context done [%s]", ctx.Err())
+		case <-viewCtx.Done():
+			return errors.Errorf("context done [%s]", viewCtx.Err())
+		case <-timer.C:
+			return errors.Errorf("timeout after [%s]", timeout)
+		case m, ok := <-ch:
+			if !ok {
+				return errors.Errorf("session closed")
+			}
+			switch m.Status {
+			case view.ERROR:
+				return errors.Errorf("received error [%s]", string(m.Payload))
+			case view.SessionPeerUnreachable:
+				return errors.Errorf("peer unreachable")
+			case view.SessionPeerRecovered:
+				continue
+			}
+			if err := json.Unmarshal(m.Payload, msg); err != nil {
+				return errors.Wrapf(err, "failed unmarshalling message")
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statesharing
+
+import (
+	"encoding/json"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// Height is the position of a transaction in the ledger: its block and its position in the block
+type Height struct {
+	Block uint64 `json:"block"`
+	TxNum uint64 `json:"txNum"`
+}
+
+// After returns true if this height comes after the passed one
+func (h Height) After(o Height) bool {
+	return h.Block > o.Block || (h.Block == o.Block && h.TxNum > o.TxNum)
+}
+
+// Update is the write of a key of the shared namespace by a committed transaction
+type Update struct {
+	Key string `json:"key"`
+	// Value is nil if the key has been deleted
+	Value []byte `json:"value"`
+	// Height is the height of the transaction that wrote the key
+	Height Height `json:"height"`
+}
+
+// Batch is a sequence of updates of a shared namespace, in commit order
+type Batch struct {
+	Network   string `json:"network"`
+	Channel   string `json:"channel"`
+	Namespace string `json:"namespace"`
+	// Snapshot is true if the batch holds the whole state of the namespace, the keys it does not hold are deleted
+	Snapshot bool `json:"snapshot,omitempty"`
+	// From is the height the updates come after, the subscriber must be at that height to apply them.
+	// It is nil for the snapshots.
+	From *Height `json:"from,omitempty"`
+	// To is the height of the source once the batch is applied
+	To      Height   `json:"to"`
+	Updates []Update `json:"updates"`
+}
+
+// SignedBatch is a batch signed by the source
+type SignedBatch struct {
+	// Batch is the JSON representation of the batch, as signed
+	Batch     []byte `json:"batch"`
+	Signature []byte `json:"signature"`
+}
+
+// SyncRequest is sent by the subscriber to start the synchronization of a shared namespace
+type SyncRequest struct {
+	Network   string `json:"network"`
+	Channel   string `json:"channel"`
+	Namespace string `json:"namespace"`
+	// After is the height of the state of the subscriber, nil if it has none: the source then sends a snapshot first
+	After *Height `json:"after,omitempty"`
+}
+
+// ACL lists who can subscribe to a shared namespace: the identities of the nodes, and the MSPs
+// of the identities, allowed
+type ACL struct {
+	Identities []view.Identity
+	MSPIDs     []string
+}
+
+// IdentityValidator deserializes and validates the identities with the MSPs of a channel, see fabric.MSPManager
+type IdentityValidator interface {
+	IsValid(identity view.Identity) error
+	GetMSPIdentifier(sid []byte) (string, error)
+}
+
+// Allows returns true if the passed identity can subscribe. The identities listed are matched as they are.
+// The MSP of the other identities is the one of the channel MSP that deserializes and validates them with the
+// passed validator, the MSP ID the identity claims is not trusted. Without validator, only the identities listed are allowed.
+func (a *ACL) Allows(id view.Identity, msps IdentityValidator) bool {
+	if a == nil {
+		return false
+	}
+	for _, allowed := range a.Identities {
+		if allowed.Equal(id) {
+			return true
+		}
+	}
+	if len(a.MSPIDs) == 0 || msps == nil {
+		return false
+	}
+	if err := msps.IsValid(id); err != nil {
+		logger.Debugf("identity [%s] not valid: [%s]", id, err)
+		return false
+	}
+	mspID, err := msps.GetMSPIdentifier(id)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range a.MSPIDs {
+		if allowed == mspID {
+			return true
+		}
+	}
+	return false
+}
+
+// ChannelMSPs returns the validator of the identities with the MSPs of the passed channel, nil if the channel is not found
+func ChannelMSPs(sp view2.ServiceProvider, network, channel string) IdentityValidator {
+	ch, err := getChannel(sp, network, channel)
+	if err != nil {
+		logger.Warnf("cannot validate identities on [%s:%s]: [%s]", network, channel, err)
+		return nil
+	}
+	return ch.MSPManager()
+}
+
+// Signer signs the batches of the source
+type Signer interface {
+	Sign(message []byte) ([]byte, error)
+}
+
+// Verifier verifies the signatures of the batches received from the source
+type Verifier interface {
+	Verify(message, sigma []byte) error
+}
+
+// SignBatch signs the passed batch with the passed signer
+func SignBatch(batch *Batch, signer Signer) (*SignedBatch, error) {
+	raw, err := json.Marshal(batch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling batch")
+	}
+	sigma, err := signer.Sign(raw)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed signing batch")
+	}
+	return &SignedBatch{Batch: raw, Signature: sigma}, nil
+}
+
+// Open verifies the signature of the batch with the passed verifier and returns the batch
+func (s *SignedBatch) Open(verifier Verifier) (*Batch, error) {
+	if err := verifier.Verify(s.Batch, s.Signature); err != nil {
+		return nil, errors.WithMessagef(err, "invalid signature of batch")
+	}
+	batch := &Batch{}
+	if err := json.Unmarshal(s.Batch, batch); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling batch")
+	}
+	return batch, nil
+}
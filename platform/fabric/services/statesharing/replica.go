@@ -0,0 +1,109 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statesharing
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/pkg/errors"
+)
+
+// PositionNamespace is the vault namespace where the subscribers record the height of their replicas
+const PositionNamespace = "_statesharing"
+
+// ErrOutOfSequence is returned when a batch does not follow the state of the replica, the subscriber must sync again
+var ErrOutOfSequence = errors.New("batch out of sequence")
+
+// ReplicaVault is the part of the vault a replica uses
+type ReplicaVault interface {
+	// GetState returns the committed value of the passed key
+	GetState(namespace, key string) ([]byte, error)
+	// ReplicateStates applies the passed writes atomically, see fabric.Vault#ReplicateStates
+	ReplicateStates(writes []fabric.StateWrite, reset ...string) error
+}
+
+// vault adapts the vault of a channel to a ReplicaVault
+type vault struct {
+	*fabric.Vault
+}
+
+func (v *vault) GetState(namespace, key string) ([]byte, error) {
+	qe, err := v.NewQueryExecutor()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed creating query executor")
+	}
+	defer qe.Done()
+	return qe.GetState(namespace, key)
+}
+
+// Replica is the local copy of a namespace shared by another node. It is written by the batches of the source only,
+// the height of the replica is recorded in the vault together with their updates.
+type Replica struct {
+	network, channel, namespace string
+	vault                       ReplicaVault
+	verifier                    Verifier
+}
+
+// NewReplica returns the replica of the passed namespace in the passed vault, whose batches are verified with the passed verifier
+func NewReplica(network, channel, namespace string, v ReplicaVault, verifier Verifier) *Replica {
+	return &Replica{network: network, channel: channel, namespace: namespace, vault: v, verifier: verifier}
+}
+
+// Height returns the height of the replica, nil if it has never been synced
+func (r *Replica) Height() (*Height, error) {
+	raw, err := r.vault.GetState(PositionNamespace, r.namespace)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed reading the height of replica [%s]", r.namespace)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	height := &Height{}
+	if err := json.Unmarshal(raw, height); err != nil {
+		return nil, errors.Wrapf(err, "invalid height of replica [%s]", r.namespace)
+	}
+	return height, nil
+}
+
+// Apply verifies the passed batch and applies it to the replica, atomically with its new height.
+// It returns ErrOutOfSequence if the batch does not come after the height of the replica.
+func (r *Replica) Apply(signed *SignedBatch) (*Batch, error) {
+	batch, err := signed.Open(r.verifier)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Network != r.network || batch.Channel != r.channel || batch.Namespace != r.namespace {
+		return nil, errors.Errorf("batch of [%s:%s:%s] received for replica [%s:%s:%s]", batch.Network, batch.Channel, batch.Namespace, r.network, r.channel, r.namespace)
+	}
+	height, err := r.Height()
+	if err != nil {
+		return nil, err
+	}
+	if !batch.Snapshot && (height == nil || batch.From == nil || *batch.From != *height) {
+		return nil, errors.Wrapf(ErrOutOfSequence, "batch from [%v] received for replica [%s] at [%v]", batch.From, r.namespace, height)
+	}
+
+	raw, err := json.Marshal(batch.To)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling height")
+	}
+	writes := make([]fabric.StateWrite, 0, len(batch.Updates)+1)
+	for _, u := range batch.Updates {
+		writes = append(writes, fabric.StateWrite{Namespace: r.namespace, Key: u.Key, Value: u.Value, Block: u.Height.Block, TxNum: u.Height.TxNum})
+	}
+	writes = append(writes, fabric.StateWrite{Namespace: PositionNamespace, Key: r.namespace, Value: raw, Block: batch.To.Block, TxNum: batch.To.TxNum})
+	var reset []string
+	if batch.Snapshot {
+		reset = append(reset, r.namespace)
+	}
+	if err := r.vault.ReplicateStates(writes, reset...); err != nil {
+		return nil, errors.WithMessagef(err, "failed applying batch to replica [%s]", r.namespace)
+	}
+	logger.Debugf("[%s:%s] applied [%d] updates to replica [%s], at [%v], snapshot [%v]", r.network, r.channel, len(batch.Updates), r.namespace, batch.To, batch.Snapshot)
+	return batch, nil
+}
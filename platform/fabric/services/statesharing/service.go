@@ -0,0 +1,181 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statesharing
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fabric-sdk.statesharing")
+
+// DefaultRetryInterval is the time a subscriber waits before syncing again with the source, after a disconnection
+const DefaultRetryInterval = 5 * time.Second
+
+var serviceType = reflect.TypeOf((*Service)(nil))
+
+// Service shares namespaces of the vaults of this node with other nodes, and maintains on this node the replicas
+// of the namespaces shared by other nodes.
+//
+// A subscriber runs SyncView towards the source, that responds with SyncResponderView,
+// registered by the SDK as the responder of SyncView.
+type Service struct {
+	sp          view2.ServiceProvider
+	journalSize int
+
+	lock   sync.RWMutex
+	shares map[string]*Share
+}
+
+// NewService returns a service whose shares retain the updates of up to journalSize transactions, DefaultJournalSize if not positive
+func NewService(sp view2.ServiceProvider, journalSize int) *Service {
+	return &Service{sp: sp, journalSize: journalSize, shares: map[string]*Share{}}
+}
+
+// GetService returns the state sharing service registered in the passed service provider
+func GetService(sp view2.ServiceProvider) (*Service, error) {
+	s, err := sp.GetService(serviceType)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get state sharing service")
+	}
+	return s.(*Service), nil
+}
+
+// Share shares the passed namespace of the vault of the passed channel with the subscribers the ACL allows.
+// From then on, the committed updates of the namespace are journaled for the subscribers.
+func (s *Service) Share(network, channel, namespace string, acl *ACL) error {
+	ch, err := getChannel(s.sp, network, channel)
+	if err != nil {
+		return err
+	}
+	key := shareKey(network, ch.Name(), namespace)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.shares[key]; ok {
+		return errors.Errorf("namespace [%s] already shared", key)
+	}
+
+	v := ch.Vault()
+	share := NewShare(network, ch.Name(), namespace, acl, s.journalSize, func() ([]Update, error) {
+		return snapshot(v, namespace)
+	})
+	if err := ch.Committer().AddStateWriteListener(share.OnStateWrites); err != nil {
+		return errors.WithMessagef(err, "cannot share namespace [%s]", key)
+	}
+	// the listener is registered, the writes committed after the last transaction are journaled
+	if height := lastHeight(ch); height != nil {
+		share.SetHeight(*height)
+	}
+	s.shares[key] = share
+	logger.Infof("namespace [%s] shared", key)
+	return nil
+}
+
+// Replicate maintains the replica of the passed namespace, shared by the passed source node, in the vault of
+// the passed channel, until the context is done. The namespace is read-only for the local transactions, it is read
+// with the query executors of the vault as usual.
+// After a disconnection, the subscriber syncs again with the source from the height of the replica.
+func (s *Service) Replicate(ctx context.Context, network, channel, namespace string, source view.Identity) error {
+	ch, err := getChannel(s.sp, network, channel)
+	if err != nil {
+		return err
+	}
+	verifier, err := view2.GetSigService(s.sp).GetVerifier(source)
+	if err != nil {
+		return errors.WithMessagef(err, "cannot verify the batches of source [%s]", source)
+	}
+	registry, err := fabric.GetNamespaceRegistry(s.sp)
+	if err != nil {
+		return err
+	}
+	registry.SetReadOnly(network, ch.Name(), namespace, fmt.Sprintf("replication from [%s]", source))
+	defer registry.ClearReadOnly(network, ch.Name(), namespace)
+
+	replica := NewReplica(network, ch.Name(), namespace, &vault{Vault: ch.Vault()}, verifier)
+	for {
+		_, err := view2.GetManager(s.sp).InitiateView(&SyncView{ctx: ctx, replica: replica, source: source})
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Warnf("[%s] replication from [%s] interrupted, retry in [%s]: [%v]", shareKey(network, ch.Name(), namespace), source, DefaultRetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(DefaultRetryInterval):
+		}
+	}
+}
+
+// share returns the share of the passed namespace, nil if not shared
+func (s *Service) share(network, channel, namespace string) *Share {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.shares[shareKey(network, channel, namespace)]
+}
+
+func getChannel(sp view2.ServiceProvider, network, channel string) (*fabric.Channel, error) {
+	fns := fabric.GetFabricNetworkService(sp, network)
+	if fns == nil {
+		return nil, errors.Errorf("fabric network [%s] not found", network)
+	}
+	ch, err := fns.Channel(channel)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "channel [%s] not found", channel)
+	}
+	return ch, nil
+}
+
+// lastHeight returns the height of the last transaction committed on the passed channel, nil if not known
+func lastHeight(ch *fabric.Channel) *Height {
+	txID, err := ch.Vault().GetLastTxID()
+	if err != nil || len(txID) == 0 {
+		return nil
+	}
+	vc, block, txNum, err := ch.Committer().StatusWithHeight(txID)
+	if err != nil || vc != fabric.Valid || block == fabric.UnknownBlock || txNum < 0 {
+		return nil
+	}
+	return &Height{Block: block, TxNum: uint64(txNum)}
+}
+
+// snapshot reads the whole state of the passed namespace
+func snapshot(vault *fabric.Vault, namespace string) ([]Update, error) {
+	qe, err := vault.NewQueryExecutor()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed creating query executor")
+	}
+	defer qe.Done()
+	it, err := qe.GetStateRangeScanIterator(namespace, "", "")
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+	}
+	defer it.Close()
+	var updates []Update
+	for {
+		read, err := it.Next()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed scanning namespace [%s]", namespace)
+		}
+		if read == nil {
+			return updates, nil
+		}
+		updates = append(updates, Update{Key: read.Key, Value: read.Raw, Height: Height{Block: read.Block, TxNum: uint64(read.IndexInBlock)}})
+	}
+}
+
+func shareKey(network, channel, namespace string) string {
+	return network + ":" + channel + ":" + namespace
+}
@@ -0,0 +1,194 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statesharing
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/pkg/errors"
+)
+
+// DefaultJournalSize is the number of transactions whose updates a shared namespace retains for the catch-ups
+const DefaultJournalSize = 1000
+
+// DefaultBatchSize is the maximum number of transactions whose updates are sent in the same batch
+const DefaultBatchSize = 100
+
+// unknownHeight comes after all the others, no subscriber is covered by a journal starting there
+var unknownHeight = Height{Block: math.MaxUint64, TxNum: math.MaxUint64}
+
+// entry holds the updates of the shared namespace by a transaction
+type entry struct {
+	height  Height
+	updates []Update
+}
+
+// Share is a namespace of the vault of this node shared with the subscribers allowed by its ACL.
+//
+// The share retains the updates of the last transactions writing to the namespace, the journal. A subscriber
+// at a height the journal covers catches up with the updates after it, otherwise it gets a snapshot of the
+// namespace first. Then it follows the updates as they are committed.
+type Share struct {
+	network, channel, namespace string
+	acl                         *ACL
+	size                        int
+	// snapshot reads the whole state of the namespace from the vault
+	snapshot func() ([]Update, error)
+
+	lock sync.Mutex
+	// journal holds the updates of the last transactions, in commit order
+	journal []*entry
+	// base is the height the journal covers the updates after
+	base Height
+	// last is the height of the last transaction appended
+	last    Height
+	waiters map[chan struct{}]struct{}
+}
+
+// NewShare returns the share of the passed namespace, retaining up to size transactions, DefaultJournalSize if not positive.
+// Until SetHeight is called, the subscribers get a snapshot first.
+func NewShare(network, channel, namespace string, acl *ACL, size int, snapshot func() ([]Update, error)) *Share {
+	if size <= 0 {
+		size = DefaultJournalSize
+	}
+	return &Share{
+		network:   network,
+		channel:   channel,
+		namespace: namespace,
+		acl:       acl,
+		size:      size,
+		snapshot:  snapshot,
+		base:      unknownHeight,
+		waiters:   map[chan struct{}]struct{}{},
+	}
+}
+
+// SetHeight records the height of the last transaction committed, read once the share receives the writes:
+// the writes after it are journaled, the subscribers at that height or after catch up from the journal
+func (s *Share) SetHeight(height Height) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.base.After(height) {
+		s.base = height
+	}
+	if height.After(s.last) {
+		s.last = height
+	}
+}
+
+// Namespace returns the name of the shared namespace
+func (s *Share) Namespace() string {
+	return s.namespace
+}
+
+// ACL returns who can subscribe to the namespace
+func (s *Share) ACL() *ACL {
+	return s.acl
+}
+
+// OnStateWrites appends to the journal the updates of the shared namespace among the passed writes,
+// it is a state write listener of the committer
+func (s *Share) OnStateWrites(txID string, writes []fabric.StateWrite) {
+	var e *entry
+	for _, w := range writes {
+		if w.Namespace != s.namespace {
+			continue
+		}
+		if e == nil {
+			e = &entry{height: Height{Block: w.Block, TxNum: w.TxNum}}
+		}
+		e.updates = append(e.updates, Update{Key: w.Key, Value: w.Value, Height: e.height})
+	}
+	if e == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !e.height.After(s.last) {
+		logger.Debugf("[%s:%s] discard the updates of [%s] to [%s] at [%v], already journaled", s.network, s.channel, txID, s.namespace, e.height)
+		return
+	}
+	s.journal = append(s.journal, e)
+	if len(s.journal) > s.size {
+		s.base = s.journal[0].height
+		s.journal[0] = nil
+		s.journal = s.journal[1:]
+	}
+	s.last = e.height
+	for w := range s.waiters {
+		select {
+		case w <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Next returns the batch the subscriber at the passed height gets next, nil if there is none yet.
+// A subscriber with no height, or at a height no longer covered by the journal, gets a snapshot.
+func (s *Share) Next(after *Height) (*Batch, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	batch := &Batch{Network: s.network, Channel: s.channel, Namespace: s.namespace}
+	if after == nil || s.base.After(*after) {
+		// the snapshot is read while no update is journaled: it holds the updates up to the last one,
+		// and maybe the ones of the transactions committed next, re-sent afterwards
+		updates, err := s.snapshot()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed reading snapshot of [%s]", s.namespace)
+		}
+		batch.Snapshot = true
+		batch.To = s.last
+		batch.Updates = updates
+		// the updates not in the snapshot are in the journal, or are not journaled yet
+		if s.base.After(s.last) {
+			s.base = s.last
+		}
+		return batch, nil
+	}
+
+	i := sort.Search(len(s.journal), func(i int) bool {
+		return s.journal[i].height.After(*after)
+	})
+	if i == len(s.journal) {
+		return nil, nil
+	}
+	from := *after
+	batch.From = &from
+	for _, e := range s.journal[i:min(i+DefaultBatchSize, len(s.journal))] {
+		batch.Updates = append(batch.Updates, e.updates...)
+		batch.To = e.height
+	}
+	return batch, nil
+}
+
+// Wait returns a channel notified when new updates are journaled, to release with Release
+func (s *Share) Wait() chan struct{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	w := make(chan struct{}, 1)
+	s.waiters[w] = struct{}{}
+	return w
+}
+
+// Release stops the notifications of the passed channel
+func (s *Share) Release(w chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.waiters, w)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
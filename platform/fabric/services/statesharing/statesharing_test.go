@@ -0,0 +1,227 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statesharing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"sort"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type macSigner []byte
+
+func (m macSigner) Sign(message []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, m)
+	mac.Write(message)
+	return mac.Sum(nil), nil
+}
+
+func (m macSigner) Verify(message, sigma []byte) error {
+	expected, _ := m.Sign(message)
+	if !hmac.Equal(expected, sigma) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// memVault is a vault holding the states in memory
+type memVault struct {
+	states map[string]map[string][]byte
+}
+
+func newMemVault() *memVault {
+	return &memVault{states: map[string]map[string][]byte{}}
+}
+
+func (m *memVault) GetState(namespace, key string) ([]byte, error) {
+	return m.states[namespace][key], nil
+}
+
+func (m *memVault) ReplicateStates(writes []fabric.StateWrite, reset ...string) error {
+	for _, ns := range reset {
+		m.states[ns] = map[string][]byte{}
+	}
+	for _, w := range writes {
+		if m.states[w.Namespace] == nil {
+			m.states[w.Namespace] = map[string][]byte{}
+		}
+		if w.Value == nil {
+			delete(m.states[w.Namespace], w.Key)
+			continue
+		}
+		m.states[w.Namespace][w.Key] = w.Value
+	}
+	return nil
+}
+
+func (m *memVault) snapshot(namespace string) func() ([]Update, error) {
+	return func() ([]Update, error) {
+		var updates []Update
+		for k, v := range m.states[namespace] {
+			updates = append(updates, Update{Key: k, Value: v})
+		}
+		sort.Slice(updates, func(i, j int) bool { return updates[i].Key < updates[j].Key })
+		return updates, nil
+	}
+}
+
+// commit writes to the source vault and notifies the share, as the committer does
+func commit(source *memVault, share *Share, block, txNum uint64, writes ...fabric.StateWrite) {
+	for i := range writes {
+		writes[i].Block, writes[i].TxNum = block, txNum
+	}
+	_ = source.ReplicateStates(writes)
+	share.OnStateWrites("tx", writes)
+}
+
+func set(ns, key, value string) fabric.StateWrite {
+	return fabric.StateWrite{Namespace: ns, Key: key, Value: []byte(value)}
+}
+
+func del(ns, key string) fabric.StateWrite {
+	return fabric.StateWrite{Namespace: ns, Key: key}
+}
+
+// catchUp applies to the replica the batches the share has for it, and returns their number
+func catchUp(t *testing.T, share *Share, signer Signer, replica *Replica) int {
+	n := 0
+	for {
+		height, err := replica.Height()
+		assert.NoError(t, err)
+		batch, err := share.Next(height)
+		assert.NoError(t, err)
+		if batch == nil {
+			return n
+		}
+		signed, err := SignBatch(batch, signer)
+		assert.NoError(t, err)
+		_, err = replica.Apply(signed)
+		assert.NoError(t, err)
+		n++
+	}
+}
+
+func TestStateSharing(t *testing.T) {
+	key := macSigner("source")
+	source := newMemVault()
+	_ = source.ReplicateStates([]fabric.StateWrite{set("assets", "a", "1"), set("assets", "b", "2")})
+	share := NewShare("default", "ch", "assets", &ACL{}, 3, source.snapshot("assets"))
+	share.SetHeight(Height{Block: 5})
+
+	local := newMemVault()
+	replica := NewReplica("default", "ch", "assets", local, key)
+
+	// the first sync gets a snapshot
+	assert.Equal(t, 1, catchUp(t, share, key, replica))
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, local.states["assets"])
+	height, err := replica.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, &Height{Block: 5}, height)
+
+	// then the incremental updates, the ones of the other namespaces are not shared
+	commit(source, share, 6, 0, del("assets", "a"), set("assets", "c", "3"), set("other", "x", "9"))
+	commit(source, share, 6, 1, set("other", "y", "9"))
+	assert.Equal(t, 1, catchUp(t, share, key, replica))
+	assert.Equal(t, map[string][]byte{"b": []byte("2"), "c": []byte("3")}, local.states["assets"])
+	assert.Nil(t, local.states["other"])
+	height, err = replica.Height()
+	assert.NoError(t, err)
+	assert.Equal(t, &Height{Block: 6}, height)
+
+	// the updates missed while disconnected are caught up from the journal, a re-delivered transaction is discarded
+	commit(source, share, 7, 0, set("assets", "b", "20"))
+	commit(source, share, 7, 0, set("assets", "b", "20"))
+	commit(source, share, 8, 2, set("assets", "d", "4"))
+	batch, err := share.Next(height)
+	assert.NoError(t, err)
+	assert.False(t, batch.Snapshot)
+	assert.Equal(t, &Height{Block: 6}, batch.From)
+	assert.Equal(t, Height{Block: 8, TxNum: 2}, batch.To)
+	assert.Len(t, batch.Updates, 2)
+	assert.Equal(t, 1, catchUp(t, share, key, replica))
+	assert.Equal(t, map[string][]byte{"b": []byte("20"), "c": []byte("3"), "d": []byte("4")}, local.states["assets"])
+
+	// the batches that do not follow the replica, or not signed by the source, are refused
+	signed, err := SignBatch(batch, key)
+	assert.NoError(t, err)
+	_, err = replica.Apply(signed)
+	assert.True(t, errors.Is(err, ErrOutOfSequence))
+	forged, err := SignBatch(&Batch{Network: "default", Channel: "ch", Namespace: "assets", Snapshot: true}, macSigner("intruder"))
+	assert.NoError(t, err)
+	_, err = replica.Apply(forged)
+	assert.Error(t, err)
+	signed.Batch = bytes.Replace(signed.Batch, []byte(`"20"`), []byte(`"21"`), 1)
+	_, err = replica.Apply(signed)
+	assert.Error(t, err)
+
+	// a replica behind the journal gets a snapshot again, the keys deleted meanwhile are removed
+	behind := newMemVault()
+	_ = behind.ReplicateStates([]fabric.StateWrite{set("assets", "a", "1"), set(PositionNamespace, "assets", `{"block":5,"txNum":0}`)})
+	commit(source, share, 9, 0, set("assets", "e", "5"))
+	commit(source, share, 10, 0, set("assets", "f", "6"))
+	lagging := NewReplica("default", "ch", "assets", behind, key)
+	batch, err = share.Next(&Height{Block: 5})
+	assert.NoError(t, err)
+	assert.True(t, batch.Snapshot)
+	assert.Equal(t, 1, catchUp(t, share, key, lagging))
+	assert.Equal(t, source.states["assets"], behind.states["assets"])
+	assert.Equal(t, 1, catchUp(t, share, key, replica))
+	assert.Equal(t, source.states["assets"], local.states["assets"])
+}
+
+// msps validates the identities it knows, with the MSP they belong to
+type msps map[string]string
+
+func (m msps) IsValid(identity view.Identity) error {
+	if _, ok := m[string(identity)]; !ok {
+		return errors.New("unknown identity")
+	}
+	return nil
+}
+
+func (m msps) GetMSPIdentifier(sid []byte) (string, error) {
+	mspID, ok := m[string(sid)]
+	if !ok {
+		return "", errors.New("unknown identity")
+	}
+	return mspID, nil
+}
+
+func TestACL(t *testing.T) {
+	id := func(mspID, cert string) view.Identity {
+		raw, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: []byte(cert)})
+		assert.NoError(t, err)
+		return raw
+	}
+	alice, bob, charlie := id("Org1MSP", "alice"), id("Org2MSP", "bob"), id("Org3MSP", "charlie")
+	// mallory claims Org2MSP, her certificate is not issued by it
+	mallory := id("Org2MSP", "mallory")
+	validator := msps{string(bob): "Org2MSP", string(charlie): "Org3MSP", string(mallory): "Org3MSP"}
+
+	acl := &ACL{Identities: []view.Identity{alice}, MSPIDs: []string{"Org2MSP"}}
+	assert.True(t, acl.Allows(alice, validator))
+	assert.True(t, acl.Allows(bob, validator))
+	assert.False(t, acl.Allows(charlie, validator))
+	assert.False(t, acl.Allows(mallory, validator))
+	assert.False(t, acl.Allows(id("Org2MSP", "unknown"), validator))
+	assert.False(t, acl.Allows([]byte("not an identity"), validator))
+
+	// without validator, the MSPs are not trusted
+	assert.True(t, acl.Allows(alice, nil))
+	assert.False(t, acl.Allows(bob, nil))
+	var none *ACL
+	assert.False(t, none.Allows(alice, validator))
+}
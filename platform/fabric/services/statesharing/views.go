@@ -0,0 +1,164 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statesharing
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/session"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// closedCheckInterval is how often the source checks if the session of an idle subscriber has been closed
+const closedCheckInterval = time.Second
+
+// SyncView syncs a replica with its source, then applies the updates the source sends,
+// until the context is done or the session fails
+type SyncView struct {
+	ctx     context.Context
+	replica *Replica
+	source  view.Identity
+}
+
+func (v *SyncView) Call(context view.Context) (interface{}, error) {
+	s, err := context.GetSession(v, v.source)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed opening session to [%s]", v.source)
+	}
+	defer s.Close()
+	if hs, ok := s.(view.HeartbeatSession); ok {
+		if err := hs.EnableHeartbeats(0, 0); err != nil {
+			logger.Warnf("failed enabling heartbeats towards [%s]: [%s]", v.source, err)
+		}
+	}
+
+	height, err := v.replica.Height()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(&SyncRequest{
+		Network:   v.replica.network,
+		Channel:   v.replica.channel,
+		Namespace: v.replica.namespace,
+		After:     height,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling sync request")
+	}
+	if err := s.Send(raw); err != nil {
+		return nil, errors.WithMessagef(err, "failed sending sync request to [%s]", v.source)
+	}
+	logger.Infof("[%s:%s] sync replica [%s] with [%s] from [%v]", v.replica.network, v.replica.channel, v.replica.namespace, v.source, height)
+
+	ch := s.Receive()
+	for {
+		select {
+		case <-v.ctx.Done():
+			return nil, nil
+		case <-context.Context().Done():
+			return nil, errors.Errorf("context done [%s]", context.Context().Err())
+		case msg, ok := <-ch:
+			if !ok {
+				return nil, errors.Errorf("session with [%s] closed", v.source)
+			}
+			switch msg.Status {
+			case view.ERROR:
+				return nil, errors.Errorf("received error from [%s]: [%s]", v.source, string(msg.Payload))
+			case view.SessionPeerUnreachable:
+				return nil, errors.Errorf("source [%s] unreachable", v.source)
+			case view.SessionPeerRecovered:
+				continue
+			}
+			signed := &SignedBatch{}
+			if err := json.Unmarshal(msg.Payload, signed); err != nil {
+				return nil, errors.Wrapf(err, "failed unmarshalling batch from [%s]", v.source)
+			}
+			if _, err := v.replica.Apply(signed); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// SyncResponderView serves a subscriber of a shared namespace: it checks the ACL, sends the updates the subscriber
+// misses, or a snapshot, then the updates as they are committed, signed by this node
+type SyncResponderView struct{}
+
+func (v *SyncResponderView) Call(context view.Context) (interface{}, error) {
+	s, raw, err := session.ReadFirstMessage(context)
+	if err != nil {
+		return nil, err
+	}
+	request := &SyncRequest{}
+	if err := json.Unmarshal(raw, request); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling sync request")
+	}
+	service, err := GetService(context)
+	if err != nil {
+		return nil, err
+	}
+	share := service.share(request.Network, request.Channel, request.Namespace)
+	caller := s.Info().Caller
+	if share == nil || !share.ACL().Allows(caller, ChannelMSPs(context, request.Network, request.Channel)) {
+		// the subscribers not allowed do not learn whether the namespace is shared
+		err := errors.Errorf("namespace [%s] not shared with [%s]", shareKey(request.Network, request.Channel, request.Namespace), caller)
+		if err1 := s.SendError([]byte(err.Error())); err1 != nil {
+			logger.Warnf("failed sending error to [%s]: [%s]", caller, err1)
+		}
+		return nil, err
+	}
+	signer, err := view2.GetSigService(context).GetSigner(context.Me())
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting the signer of [%s]", context.Me())
+	}
+
+	w := share.Wait()
+	defer share.Release(w)
+	ticker := time.NewTicker(closedCheckInterval)
+	defer ticker.Stop()
+
+	after := request.After
+	for {
+		batch, err := share.Next(after)
+		if err != nil {
+			if err1 := s.SendError([]byte(err.Error())); err1 != nil {
+				logger.Warnf("failed sending error to [%s]: [%s]", caller, err1)
+			}
+			return nil, err
+		}
+		if batch != nil {
+			signed, err := SignBatch(batch, signer)
+			if err != nil {
+				return nil, err
+			}
+			raw, err := json.Marshal(signed)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed marshalling batch")
+			}
+			if err := s.Send(raw); err != nil {
+				return nil, errors.WithMessagef(err, "failed sending batch to [%s]", caller)
+			}
+			to := batch.To
+			after = &to
+			continue
+		}
+
+		select {
+		case <-w:
+		case <-ticker.C:
+			if s.Info().Closed {
+				return nil, nil
+			}
+		case <-context.Context().Done():
+			return nil, nil
+		}
+	}
+}
@@ -9,9 +9,11 @@ package fabric
 import (
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
 )
 
 type TransactionOptions struct {
@@ -19,6 +21,8 @@ type TransactionOptions struct {
 	Nonce   []byte
 	TxID    string
 	Channel string
+	// TTL is the time to live of the transaction, 0 to use the one of its chaincode or network
+	TTL time.Duration
 }
 
 type TransactionOption func(*TransactionOptions) error
@@ -61,6 +65,15 @@ func WithTxID(txid string) TransactionOption {
 	}
 }
 
+// WithTTL sets the time to live of the transaction: it is not broadcast once this time elapsed since its creation.
+// It cannot exceed the time to live of its chaincode, or of its network, see Transaction#SetTTL.
+func WithTTL(ttl time.Duration) TransactionOption {
+	return func(o *TransactionOptions) error {
+		o.TTL = ttl
+		return nil
+	}
+}
+
 type TxID struct {
 	Nonce   []byte
 	Creator []byte
@@ -327,6 +340,44 @@ func (t *Transaction) Envelope() (*Envelope, error) {
 	return &Envelope{e: env}, nil
 }
 
+// EstimatedSize returns the estimated size in bytes of the envelope of this transaction.
+// The broadcast of envelopes exceeding the size limit of the channel fails with an *ErrEnvelopeTooLarge.
+func (t *Transaction) EstimatedSize() (int, error) {
+	return t.tx.EstimatedSize()
+}
+
+// SkipStaleReadCheck makes the broadcast of this transaction skip the check of its read set against the vault,
+// when enabled for the network
+func (t *Transaction) SkipStaleReadCheck() error {
+	s, ok := t.tx.(driver.StaleReadCheckSkipper)
+	if !ok {
+		return errors.Errorf("transaction [%s] does not support skipping the stale read check", t.ID())
+	}
+	s.SkipStaleReadCheck(true)
+	return nil
+}
+
+// SetTTL sets the time to live of this transaction: its broadcast fails with an *ErrTransactionExpired once this time
+// elapsed since its proposal has been signed. 0 means the time to live of its chaincode or, if not set, of its network,
+// which also caps the one set here.
+func (t *Transaction) SetTTL(ttl time.Duration) error {
+	e, ok := t.tx.(driver.ExpirableTransaction)
+	if !ok {
+		return errors.Errorf("transaction [%s] does not support a time to live", t.ID())
+	}
+	e.SetTTL(ttl)
+	return nil
+}
+
+// Created returns when this transaction has been created, the zero time if not known
+func (t *Transaction) Created() time.Time {
+	e, ok := t.tx.(driver.ExpirableTransaction)
+	if !ok {
+		return time.Time{}
+	}
+	return e.Created()
+}
+
 type TransactionManager struct {
 	fns *NetworkService
 }
@@ -358,10 +409,16 @@ func (t *TransactionManager) NewTransaction(opts ...TransactionOption) (*Transac
 		return nil, err
 	}
 
-	return &Transaction{
+	res := &Transaction{
 		fns: t.fns,
 		tx:  tx,
-	}, nil
+	}
+	if options.TTL > 0 {
+		if err := res.SetTTL(options.TTL); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 func (t *TransactionManager) NewTransactionFromBytes(raw []byte, opts ...TransactionOption) (*Transaction, error) {
@@ -7,8 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package fabric
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
+	"time"
 
 	fdriver "github.com/hyperledger-labs/fabric-smart-client/platform/fabric/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
@@ -109,6 +111,16 @@ func (r *RWSet) SetStateMetadata(namespace, key string, metadata map[string][]by
 	return r.rws.SetStateMetadata(namespace, key, metadata)
 }
 
+// PurgePrivateData purges the passed key of the passed collection of the passed namespace.
+// The key is deleted, and the peers supporting the purges, from Fabric 2.5, remove its private values, past ones included.
+func (r *RWSet) PurgePrivateData(namespace, collection, key string) error {
+	p, ok := r.rws.(fdriver.PrivateDataPurger)
+	if !ok {
+		return errors.Errorf("the read-write set does not support purging private data")
+	}
+	return p.PurgePrivateData(namespace, collection, key)
+}
+
 func (r *RWSet) GetReadKeyAt(ns string, i int) (string, error) {
 	return r.rws.GetReadKeyAt(ns, i)
 }
@@ -188,6 +200,12 @@ func (v *Read) V() []byte {
 	return v.Raw
 }
 
+// Speculative returns true if the state has been read from the write set of a transaction not committed yet,
+// see Vault#NewQueryExecutorWithPending
+func (v *Read) Speculative() bool {
+	return v.Block == UnknownBlock
+}
+
 // ResultsIterator models an query result iterator
 type ResultsIterator struct {
 	ri driver.VersionedResultsIterator
@@ -245,6 +263,16 @@ const (
 	Busy                           // Transaction does not yet have a validity state
 	Unknown                        // Transaction is unknown
 	HasDependencies                // Transaction is unknown but has known dependencies
+	Abandoned                      // Transaction has been busy with no trace on the ledger, and has been given up
+)
+
+const (
+	// UnknownBlock is the block number returned when the height of a transaction is not known
+	UnknownBlock = fdriver.UnknownBlock
+	// UnknownTxNum is the position in the block returned when the height of a transaction is not known
+	UnknownTxNum = fdriver.UnknownTxNum
+	// SpeculativeMetadataKey is set in the metadata of the speculative states, see Vault#NewQueryExecutorWithPending
+	SpeculativeMetadataKey = fdriver.SpeculativeMetadataKey
 )
 
 type SeekStart struct{}
@@ -260,6 +288,135 @@ type TxIDEntry struct {
 	Code ValidationCode
 }
 
+// StatusFilter selects the transactions listed by Vault#ListStatuses, the zero value selects all of them
+type StatusFilter struct {
+	// Codes are the validation codes to list, all if empty
+	Codes []ValidationCode
+	// FromBlock and ToBlock, if set, bound the blocks, inclusive, the listed transactions are committed in
+	FromBlock *uint64
+	ToBlock   *uint64
+	// Since and Until, if set, bound the time, inclusive, the status of the listed transactions has been set
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of statuses in a page, it defaults to DefaultStatusPageSize
+	Limit int
+}
+
+// DefaultStatusPageSize is the number of statuses in a page when the filter does not set a limit
+const DefaultStatusPageSize = fdriver.DefaultStatusPageSize
+
+// PageToken identifies a page of statuses, the empty token identifies the first page
+type PageToken = fdriver.PageToken
+
+// TxStatus is the status of a transaction as listed by Vault#ListStatuses
+type TxStatus struct {
+	TxID string
+	Code ValidationCode
+	// Block and TxNum are the height of the transaction, UnknownBlock and UnknownTxNum if not known
+	Block uint64
+	TxNum int
+	// Timestamp is when the status has been set
+	Timestamp time.Time
+}
+
+// StatusPage is a page of statuses, in the order they have been set, or by height if the filter bounds the blocks
+type StatusPage struct {
+	Statuses []TxStatus
+	// Next identifies the next page, it is empty if this is the last one
+	Next PageToken
+}
+
+// StateRepair re-applies the authoritative state of a key, see Vault#RepairStates
+type StateRepair = fdriver.StateRepair
+
+// RepairProvenance records where the values of a repair come from
+type RepairProvenance = fdriver.RepairProvenance
+
+// RepairRecord is the provenance of the last repair of a key, with the state the key had before it
+type RepairRecord = fdriver.RepairRecord
+
+// NamespaceQuota bounds the approximate storage taken by a namespace of the vault, see Vault#SetNamespaceQuota
+type NamespaceQuota = fdriver.NamespaceQuota
+
+// NamespaceUsage is the approximate storage taken by a namespace of the vault, see Vault#NamespaceUsage
+type NamespaceUsage = fdriver.NamespaceUsage
+
+// ErrNamespaceQuotaExceeded is returned when a transaction created locally writes a namespace whose usage has
+// reached its hard quota
+type ErrNamespaceQuotaExceeded = fdriver.ErrNamespaceQuotaExceeded
+
+type (
+	// IntegrityReport is the integrity check of the namespaces of the vault, see Vault#VerifyIntegrity
+	IntegrityReport = fdriver.IntegrityReport
+	// NamespaceIntegrity is the integrity check of a namespace of the vault
+	NamespaceIntegrity = fdriver.NamespaceIntegrity
+	// IntegrityStatus is the outcome of the integrity check of a namespace
+	IntegrityStatus = fdriver.IntegrityStatus
+)
+
+// the outcomes of the integrity check of a namespace
+const (
+	IntegrityOK        = fdriver.IntegrityOK
+	IntegrityCorrupted = fdriver.IntegrityCorrupted
+	IntegrityUnchecked = fdriver.IntegrityUnchecked
+)
+
+var (
+	// ErrHistoryNotKept is returned when reading at a past height a namespace whose history is not kept
+	ErrHistoryNotKept = fdriver.ErrHistoryNotKept
+	// ErrHeightNotCommitted is returned when reading at a height not committed yet
+	ErrHeightNotCommitted = fdriver.ErrHeightNotCommitted
+	// ErrHeightBeforeHorizon is returned when reading at a height whose versions are no longer retained
+	ErrHeightBeforeHorizon = fdriver.ErrHeightBeforeHorizon
+)
+
+// StateWrite is the write of a key by a committed transaction, see Committer#AddStateWriteListener and Vault#ReplicateStates
+type StateWrite = fdriver.StateWrite
+
+// KeyChange is the change of a key of the vault committed by a valid transaction, see Vault#WatchKeys
+type KeyChange = fdriver.KeyChange
+
+// WatchOptions tunes a watch of the keys of the vault, see Vault#WatchKeys
+type WatchOptions = fdriver.WatchOptions
+
+// Projection derives, at commit time, the states of an index namespace from the writes of a source namespace,
+// see Vault#RegisterProjection
+type Projection = fdriver.Projection
+
+// ProjectedWrite is a write of the source namespace of a projection
+type ProjectedWrite = fdriver.ProjectedWrite
+
+// IndexWrite is a write a projection derives into its index namespace, an empty value deletes the key
+type IndexWrite = fdriver.IndexWrite
+
+// IndexReader reads the index namespace of a projection, including the writes derived so far in the same commit
+type IndexReader = fdriver.IndexReader
+
+// DefaultWatchBuffer is the number of changes a watch buffers, if not set with WithWatchBuffer
+const DefaultWatchBuffer = fdriver.DefaultWatchBuffer
+
+type WatchOption func(*WatchOptions) error
+
+// WithReplayFrom asks the watch to send first the changes committed from the passed block onwards
+func WithReplayFrom(block uint64) WatchOption {
+	return func(o *WatchOptions) error {
+		o.Replay = true
+		o.From = block
+		return nil
+	}
+}
+
+// WithWatchBuffer sets the number of changes the watch buffers before it overflows
+func WithWatchBuffer(size int) WatchOption {
+	return func(o *WatchOptions) error {
+		if size <= 0 {
+			return errors.Errorf("invalid watch buffer [%d], it must be positive", size)
+		}
+		o.Buffer = size
+		return nil
+	}
+}
+
 type TxIDIterator struct {
 	fdriver.TxidIterator
 }
@@ -285,6 +442,10 @@ func (t *TxIDIterator) Close() {
 // Vault models a key-value store that can be updated by committing rwsets
 type Vault struct {
 	ch fdriver.Channel
+	// guard, if set, refuses the namespaces whose migration failed, see NamespaceRegistry
+	guard func(namespace string) error
+	// writeGuard, if set, refuses the writes to the read-only namespaces, see NamespaceRegistry#SetReadOnly
+	writeGuard func(namespace string) error
 }
 
 // GetLastTxID returns the last transaction id committed
@@ -327,6 +488,141 @@ func (c *Vault) CommitTX(txid string, block uint64, indexInBloc int) error {
 	return c.ch.CommitTX(txid, block, indexInBloc, nil)
 }
 
+// CommitLocalTX commits the passed transaction, that is not ordered. It does not become the last transaction
+// committed, the one the delivery of the blocks restarts from.
+func (c *Vault) CommitLocalTX(txid string) error {
+	lc, ok := c.ch.(fdriver.LocalCommitter)
+	if !ok {
+		return errors.Errorf("vault of channel [%s] does not support local transactions", c.ch.Name())
+	}
+	return lc.CommitLocalTX(txid)
+}
+
+// PauseCommits waits for the in-flight block commits to complete and blocks new ones, so that
+// the vault can be safely backed up. The snapshot hooks are invoked once the vault is quiet.
+// The commits stay paused until resume is called or the configured max pause duration expires.
+func (c *Vault) PauseCommits(ctx context.Context) (resume func(), err error) {
+	bc, ok := c.ch.(fdriver.BackupCoordinator)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support backups", c.ch.Name())
+	}
+	return bc.PauseCommits(ctx)
+}
+
+// AddSnapshotHook registers a hook invoked, with the vault height, every time it is safe to snapshot the vault
+func (c *Vault) AddSnapshotHook(hook func(height uint64) error) error {
+	bc, ok := c.ch.(fdriver.BackupCoordinator)
+	if !ok {
+		return errors.Errorf("vault of channel [%s] does not support backups", c.ch.Name())
+	}
+	bc.AddSnapshotHook(hook)
+	return nil
+}
+
+// ListStatuses returns the page, identified by the passed token, of the statuses of the transactions matching the filter.
+// The tokens stay valid while new transactions are committed.
+func (c *Vault) ListStatuses(filter StatusFilter, page PageToken) (*StatusPage, error) {
+	sl, ok := c.ch.(fdriver.StatusLister)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support listing statuses", c.ch.Name())
+	}
+	f := fdriver.StatusFilter{
+		FromBlock: filter.FromBlock,
+		ToBlock:   filter.ToBlock,
+		Since:     filter.Since,
+		Until:     filter.Until,
+		Limit:     filter.Limit,
+	}
+	for _, code := range filter.Codes {
+		f.Codes = append(f.Codes, fdriver.ValidationCode(code))
+	}
+	p, err := sl.ListStatuses(f, page)
+	if err != nil {
+		return nil, err
+	}
+	res := &StatusPage{Next: p.Next}
+	for _, s := range p.Statuses {
+		res.Statuses = append(res.Statuses, TxStatus{
+			TxID:      s.TxID,
+			Code:      ValidationCode(s.Code),
+			Block:     s.Block,
+			TxNum:     s.TxNum,
+			Timestamp: s.Timestamp,
+		})
+	}
+	return res, nil
+}
+
+// RepairStates applies atomically the passed repairs, recording their provenance.
+// The repairs of the keys committed again since the drift has been detected are skipped, the applied ones are returned.
+func (c *Vault) RepairStates(repairs []StateRepair, provenance RepairProvenance) ([]StateRepair, error) {
+	sr, ok := c.ch.(fdriver.StateRepairer)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support repairs", c.ch.Name())
+	}
+	return sr.RepairStates(repairs, provenance)
+}
+
+// ReplicateStates applies atomically the passed writes, replicated from another node, with their version.
+// The keys of the reset namespaces that are not written are deleted.
+// The writes bypass the read-only namespaces, they are meant to maintain them.
+func (c *Vault) ReplicateStates(writes []StateWrite, reset ...string) error {
+	sr, ok := c.ch.(fdriver.StateReplicator)
+	if !ok {
+		return errors.Errorf("vault of channel [%s] does not support replication", c.ch.Name())
+	}
+	return sr.ReplicateStates(writes, reset...)
+}
+
+// RepairRecord returns the provenance of the last repair of the passed key, nil if the key has never been repaired
+func (c *Vault) RepairRecord(namespace, key string) (*RepairRecord, error) {
+	sr, ok := c.ch.(fdriver.StateRepairer)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support repairs", c.ch.Name())
+	}
+	return sr.RepairRecord(namespace, key)
+}
+
+// NamespaceUsage returns the approximate storage, in bytes, taken by the namespaces with a quota
+func (c *Vault) NamespaceUsage() ([]NamespaceUsage, error) {
+	qm, ok := c.ch.(fdriver.NamespaceQuotaManager)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support quotas", c.ch.Name())
+	}
+	return qm.NamespaceUsage()
+}
+
+// SetNamespaceQuota sets the quota of the passed namespace, a zero quota removes it.
+// Above the soft quota the commits warn, above the hard quota the transactions created locally can no longer
+// write the namespace and fail with ErrNamespaceQuotaExceeded. The transactions delivered by the blocks are applied anyway.
+func (c *Vault) SetNamespaceQuota(namespace string, quota NamespaceQuota) error {
+	qm, ok := c.ch.(fdriver.NamespaceQuotaManager)
+	if !ok {
+		return errors.Errorf("vault of channel [%s] does not support quotas", c.ch.Name())
+	}
+	return qm.SetNamespaceQuota(namespace, quota)
+}
+
+// VerifyIntegrity verifies the passed namespaces, or all those with a checksum if none is passed, against the
+// checksum the commits keep for each of them, and reports the namespaces whose states have been corrupted
+func (c *Vault) VerifyIntegrity(namespaces ...string) (*IntegrityReport, error) {
+	iv, ok := c.ch.(fdriver.IntegrityVerifier)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support integrity checks", c.ch.Name())
+	}
+	return iv.VerifyIntegrity(namespaces...)
+}
+
+// RederiveNamespace repairs a corrupted namespace: its states are re-derived from the blocks of the peers, up to the
+// height of the vault, and its checksum recomputed from them. It returns the integrity check of the namespace once repaired.
+func (c *Vault) RederiveNamespace(namespace string) (*NamespaceIntegrity, error) {
+	iv, ok := c.ch.(fdriver.IntegrityVerifier)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support integrity checks", c.ch.Name())
+	}
+	return iv.RederiveNamespace(namespace)
+}
+
 // NewQueryExecutor gives handle to a query executor.
 // A client can obtain more than one 'QueryExecutor's for parallel execution.
 // Any synchronization should be performed at the implementation level if required
@@ -335,9 +631,104 @@ func (c *Vault) NewQueryExecutor() (*QueryExecutor, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.guard != nil {
+		qe = &guardedQueryExecutor{QueryExecutor: qe, guard: c.guard}
+	}
+	return &QueryExecutor{qe: qe}, nil
+}
+
+// NewQueryExecutorAt returns a query executor reading the vault as it was once the passed block has been committed.
+// The namespaces read must keep their history, see `vault.history.namespaces`. The error matches
+// ErrHeightNotCommitted if the block is not committed yet, ErrHeightBeforeHorizon if its versions are no longer retained,
+// and ErrHistoryNotKept if no namespace keeps its history.
+func (c *Vault) NewQueryExecutorAt(height uint64) (*QueryExecutor, error) {
+	hr, ok := c.ch.(fdriver.HistoryReader)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support reads at past heights", c.ch.Name())
+	}
+	qe, err := hr.NewQueryExecutorAt(height)
+	if err != nil {
+		return nil, err
+	}
+	if c.guard != nil {
+		qe = &guardedQueryExecutor{QueryExecutor: qe, guard: c.guard}
+	}
+	return &QueryExecutor{qe: qe}, nil
+}
+
+// NewQueryExecutorWithPending returns a query executor that also reads the writes of the passed transactions
+// not committed yet, like the ones this node just broadcast, so that a view reads its own writes.
+// These states are speculative: their metadata carry SpeculativeMetadataKey, set to the id of the transaction,
+// and the reads of a range are Read#Speculative. The writes of a transaction are dropped once it is final:
+// they are read as committed once the transaction is valid, and retracted once it is invalid or abandoned.
+func (c *Vault) NewQueryExecutorWithPending(txIDs ...string) (*QueryExecutor, error) {
+	pr, ok := c.ch.(fdriver.PendingReader)
+	if !ok {
+		return nil, errors.Errorf("vault of channel [%s] does not support reads of pending transactions", c.ch.Name())
+	}
+	qe, err := pr.NewQueryExecutorWithPending(txIDs...)
+	if err != nil {
+		return nil, err
+	}
+	if c.guard != nil {
+		qe = &guardedQueryExecutor{QueryExecutor: qe, guard: c.guard}
+	}
 	return &QueryExecutor{qe: qe}, nil
 }
 
+// PruneHistory drops the versions of the passed namespace not needed to read it at the passed height or after,
+// that becomes its retention horizon
+func (c *Vault) PruneHistory(namespace string, horizon uint64) error {
+	hr, ok := c.ch.(fdriver.HistoryReader)
+	if !ok {
+		return errors.Errorf("vault of channel [%s] does not support reads at past heights", c.ch.Name())
+	}
+	return hr.PruneHistory(namespace, horizon)
+}
+
+// WatchKeys returns a channel of the changes of the keys of the passed namespace with the passed prefix, and a
+// function to cancel the watch. The changes of a block are sent once the block is fully committed, with the id
+// of their transaction; a deletion is a change with no value and KeyChange#Deleted set.
+// The channel buffers the changes, DefaultWatchBuffer unless WithWatchBuffer is passed: the commits never
+// wait for a slow consumer, that instead gets a change with KeyChange#Overflow set before the channel is closed.
+// The consumer can then watch again, replaying from the last block it processed, see WithReplayFrom.
+func (c *Vault) WatchKeys(namespace, prefix string, opts ...WatchOption) (<-chan KeyChange, func(), error) {
+	kw, ok := c.ch.(fdriver.KeyWatcher)
+	if !ok {
+		return nil, nil, errors.Errorf("vault of channel [%s] does not support watches", c.ch.Name())
+	}
+	options := WatchOptions{}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return nil, nil, err
+		}
+	}
+	return kw.WatchKeys(namespace, prefix, options)
+}
+
+// RegisterProjection registers the passed projection of the passed source namespace. From then on, each write of
+// the source namespace committed is projected into the index namespace of the projection, in the same update of the
+// vault: the index is never behind the source. A projection failing fails the commit. The index namespace is
+// rebuilt if it has been built by another version of the projection, or never built; the transactions cannot write it.
+// The projections are meant to be registered on startup, before the blocks are delivered.
+func (c *Vault) RegisterProjection(namespace string, projection Projection) error {
+	pm, ok := c.ch.(fdriver.ProjectionManager)
+	if !ok {
+		return errors.Errorf("vault of channel [%s] does not support projections", c.ch.Name())
+	}
+	return pm.RegisterProjection(namespace, projection)
+}
+
+// RebuildProjection rebuilds the passed index namespace by replaying the states of its source namespace
+// through its projection, in a single update of the vault
+func (c *Vault) RebuildProjection(index string) error {
+	pm, ok := c.ch.(fdriver.ProjectionManager)
+	if !ok {
+		return errors.Errorf("vault of channel [%s] does not support projections", c.ch.Name())
+	}
+	return pm.RebuildProjection(index)
+}
+
 // NewRWSet returns a RWSet for this ledger.
 // A client may obtain more than one such simulator; they are made unique
 // by way of the supplied txid
@@ -346,7 +737,7 @@ func (c *Vault) NewRWSet(txid string) (*RWSet, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &RWSet{rws: rws}, nil
+	return &RWSet{rws: c.guardRWSet(rws)}, nil
 }
 
 // GetRWSet returns a RWSet for this ledger whose content is unmarshalled
@@ -358,7 +749,14 @@ func (c *Vault) GetRWSet(txid string, rwset []byte) (*RWSet, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &RWSet{rws: rws}, nil
+	return &RWSet{rws: c.guardRWSet(rws)}, nil
+}
+
+func (c *Vault) guardRWSet(rws fdriver.RWSet) fdriver.RWSet {
+	if c.guard == nil && c.writeGuard == nil {
+		return rws
+	}
+	return &guardedRWSet{RWSet: rws, guard: c.guard, writeGuard: c.writeGuard}
 }
 
 // GetEphemeralRWSet returns an ephemeral RWSet for this ledger whose content is unmarshalled
@@ -107,7 +107,12 @@ func (c *Config) Identities() ([]Identity, error) {
 	return identities, nil
 }
 
+// VaultPersistenceType returns the driver of the vault persistence, as set by vault.driver or, if not set,
+// by vault.persistence.type
 func (c *Config) VaultPersistenceType() string {
+	if d := c.configService.GetString("orion." + c.prefix + "vault.driver"); len(d) != 0 {
+		return d
+	}
 	return c.configService.GetString("orion." + c.prefix + "vault.persistence.type")
 }
 
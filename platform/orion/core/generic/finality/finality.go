@@ -31,6 +31,7 @@ func (f *finality) IsFinal(ctx context.Context, txID string) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	defer view.TrackBlocking(ctx, view.BlockedOnFinality, txID)()
 	return f.committer.IsFinal(ctx, txID)
 }
 
@@ -0,0 +1,295 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// OverlaysEnv lists, comma separated, the overlay files merged in order on top of the base configuration.
+	// The relative paths are relative to the directory of the base configuration.
+	OverlaysEnv = "FSCNODE_CFG_OVERLAYS"
+	// ProfileEnv selects the section of the overlays key merged last, on top of the base configuration and the
+	// overlay files, for instance stage to merge overlays.stage
+	ProfileEnv = "FSCNODE_CFG_PROFILE"
+	// OverlaysKey is the key of the sections selected by ProfileEnv, it is not part of the effective configuration
+	OverlaysKey = "overlays"
+	// AppendSuffix annotates the key of a list of an overlay whose items are appended to the list of the
+	// configuration below, instead of replacing it
+	AppendSuffix = "+"
+	// Masked replaces the secrets in the printed effective configuration
+	Masked = "********"
+)
+
+var (
+	// placeholder matches ${VAR} and ${VAR:-default}, $${ escapes it
+	placeholder = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+	// secretKey matches the keys whose values are masked in the printed effective configuration
+	secretKey = regexp.MustCompile(`(?i)(password|passphrase|secret|token)`)
+)
+
+// effective is a configuration merged from a base file and its overlays.
+// The keys are lower case, as viper sees them, and each value remembers the file that contributed it.
+type effective struct {
+	values map[string]interface{}
+	// sources are the files that contributed the values, by dotted key. The lists are values as a whole.
+	sources map[string]string
+	// secrets are the keys whose values are interpolated from the environment
+	secrets map[string]bool
+}
+
+// loadEffective merges in order the base file and the overlay files, then the sections of their overlays key
+// selected by the profile, and interpolates the environment variables
+func loadEffective(base string, overlays []string, profile string) (*effective, error) {
+	e := &effective{values: map[string]interface{}{}, sources: map[string]string{}, secrets: map[string]bool{}}
+	type section struct {
+		file   string
+		values map[string]interface{}
+	}
+	var sections []section
+	for _, file := range append([]string{base}, overlays...) {
+		values, err := readYAML(file)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := values[OverlaysKey].(map[string]interface{}); ok && len(profile) != 0 {
+			if values, ok := s[strings.ToLower(profile)].(map[string]interface{}); ok {
+				sections = append(sections, section{file: file, values: values})
+			}
+		}
+		delete(values, OverlaysKey)
+		e.merge(e.values, values, "", file)
+	}
+	if len(profile) != 0 && len(sections) == 0 {
+		return nil, errors.Errorf("overlay section [%s.%s] not found, selected by %s", OverlaysKey, profile, ProfileEnv)
+	}
+	for _, s := range sections {
+		e.merge(e.values, s.values, "", fmt.Sprintf("%s (%s.%s)", s.file, OverlaysKey, strings.ToLower(profile)))
+	}
+	if err := e.interpolate(e.values, ""); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// overlaysFromEnv returns the overlay files listed by OverlaysEnv, relative to the directory of the base file
+func overlaysFromEnv(base string) []string {
+	var overlays []string
+	for _, file := range strings.Split(os.Getenv(OverlaysEnv), ",") {
+		if file = strings.TrimSpace(file); len(file) != 0 {
+			overlays = append(overlays, TranslatePath(filepath.Dir(base), file))
+		}
+	}
+	return overlays
+}
+
+func readYAML(file string) (map[string]interface{}, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading config file [%s]", file)
+	}
+	values := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(raw, &values); err != nil {
+		return nil, errors.Wrapf(err, "failed parsing config file [%s]", file)
+	}
+	return normalize(values).(map[string]interface{}), nil
+}
+
+// normalize returns the passed value with lower case string keys, as viper sees them
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[strings.ToLower(fmt.Sprint(k))] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[strings.ToLower(k)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			l[i] = normalize(item)
+		}
+		return l
+	default:
+		return value
+	}
+}
+
+// merge merges src in dst: the maps merge, the other values, lists included, replace the ones of dst.
+// The lists whose key ends with AppendSuffix are appended to the lists of dst.
+func (e *effective) merge(dst, src map[string]interface{}, prefix string, file string) {
+	for k, v := range src {
+		key, appended := strings.TrimSuffix(k, AppendSuffix), strings.HasSuffix(k, AppendSuffix)
+		path := prefix + key
+		if m, ok := v.(map[string]interface{}); ok {
+			if d, ok := dst[key].(map[string]interface{}); ok {
+				e.merge(d, m, path+".", file)
+				continue
+			}
+			e.forget(path)
+			d := map[string]interface{}{}
+			dst[key] = d
+			e.merge(d, m, path+".", file)
+			continue
+		}
+		source := file
+		if l, ok := v.([]interface{}); ok && appended {
+			if d, ok := dst[key].([]interface{}); ok {
+				v = append(append([]interface{}{}, d...), l...)
+				source = e.sources[path] + "," + file
+			}
+		}
+		e.forget(path)
+		dst[key] = v
+		e.sources[path] = source
+	}
+}
+
+// forget removes the sources of the passed key and of the keys below it
+func (e *effective) forget(path string) {
+	delete(e.sources, path)
+	for key := range e.sources {
+		if strings.HasPrefix(key, path+".") {
+			delete(e.sources, key)
+		}
+	}
+}
+
+// interpolate replaces the placeholders of the environment variables in the string values
+func (e *effective) interpolate(m map[string]interface{}, prefix string) error {
+	for k, v := range m {
+		path := prefix + k
+		switch value := v.(type) {
+		case map[string]interface{}:
+			if err := e.interpolate(value, path+"."); err != nil {
+				return err
+			}
+		case []interface{}:
+			for i, item := range value {
+				if s, ok := item.(string); ok {
+					res, interpolated, err := e.expand(path, s)
+					if err != nil {
+						return err
+					}
+					value[i] = res
+					e.secrets[path] = e.secrets[path] || interpolated
+				}
+			}
+		case string:
+			res, interpolated, err := e.expand(path, value)
+			if err != nil {
+				return err
+			}
+			m[k] = res
+			e.secrets[path] = interpolated
+		}
+	}
+	return nil
+}
+
+func (e *effective) expand(path, value string) (string, bool, error) {
+	var err error
+	interpolated := false
+	res := placeholder.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		groups := placeholder.FindStringSubmatch(match)
+		interpolated = true
+		if v, ok := os.LookupEnv(groups[1]); ok {
+			return v
+		}
+		if len(groups[2]) != 0 {
+			return groups[3]
+		}
+		if err == nil {
+			err = errors.Errorf("[%s] of [%s] refers to the unset environment variable [%s]", path, e.Provenance(path), groups[1])
+		}
+		return match
+	})
+	return res, interpolated, err
+}
+
+// Provenance returns the files that contributed the value of the passed key, or of the list above it,
+// empty if the key is not set
+func (e *effective) Provenance(key string) string {
+	key = strings.ToLower(key)
+	for {
+		if file, ok := e.sources[key]; ok {
+			return file
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return ""
+		}
+		key = key[:i]
+	}
+}
+
+// contributions returns, at most max of them, the keys below the passed one contributed by other files than the base,
+// with their files
+func (e *effective) contributions(key, base string, max int) []string {
+	key = strings.ToLower(key)
+	var res []string
+	for k, file := range e.sources {
+		if file != base && (len(key) == 0 || k == key || strings.HasPrefix(k, key+".")) {
+			res = append(res, fmt.Sprintf("[%s] from [%s]", k, file))
+		}
+	}
+	sort.Strings(res)
+	if len(res) > max {
+		res = append(res[:max], fmt.Sprintf("%d more", len(res)-max))
+	}
+	return res
+}
+
+// Write writes the effective configuration in YAML, the values of the secrets masked
+func (e *effective) Write(w io.Writer) error {
+	raw, err := yaml.Marshal(e.masked(e.values, ""))
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling the effective configuration")
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+func (e *effective) masked(m map[string]interface{}, prefix string) map[string]interface{} {
+	res := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		path := prefix + k
+		switch {
+		case e.secrets[path] || (secretKey.MatchString(k) && !isMap(v)):
+			res[k] = Masked
+		case isMap(v):
+			res[k] = e.masked(v.(map[string]interface{}), path+".")
+		default:
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func isMap(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
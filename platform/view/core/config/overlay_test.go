@@ -0,0 +1,149 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const baseConfig = `
+fsc:
+  id: alice
+  p2p:
+    listenAddress: 0.0.0.0:9001
+    bootstrapNode: bob
+  web:
+    enabled: true
+  endpoint:
+    resolvers:
+    - name: bob
+    - name: charlie
+  kvs:
+    password: ${FSC_TEST_KVS_PASSWORD}
+    path: $${HOME}/kvs
+fabric:
+  default:
+    orderers:
+    - address: orderer1:7050
+    - address: orderer2:7050
+    retries: 3
+overlays:
+  prod:
+    fsc:
+      web:
+        enabled: false
+`
+
+const stageConfig = `
+fsc:
+  p2p:
+    listenAddress: 0.0.0.0:${FSC_TEST_PORT:-9101}
+  endpoint:
+    resolvers+:
+    - name: dave
+fabric:
+  default:
+    orderers:
+    - address: stage-orderer:7050
+    retries: many
+overlays:
+  prod:
+    fsc:
+      endpoint:
+        resolvers+:
+        - name: eve
+`
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestOverlays(t *testing.T) {
+	dir := t.TempDir()
+	base := writeConfig(t, dir, "core.yaml", baseConfig)
+	stage := writeConfig(t, dir, "stage.yaml", stageConfig)
+	t.Setenv("FSCNODE_CFG_PATH", "")
+	t.Setenv("FSC_TEST_KVS_PASSWORD", "s3cr3t")
+
+	p, err := NewProviderWithOverlays(dir, "prod", "stage.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, base, p.ConfigFileUsed())
+
+	// the maps merge, the lists replace unless annotated, the profile section comes last
+	assert.Equal(t, "alice", p.GetString("fsc.id"))
+	assert.Equal(t, "bob", p.GetString("fsc.p2p.bootstrapNode"))
+	assert.Equal(t, "0.0.0.0:9101", p.GetString("fsc.p2p.listenAddress"))
+	assert.False(t, p.GetBool("fsc.web.enabled"))
+	var resolvers []struct{ Name string }
+	assert.NoError(t, p.UnmarshalKey("fsc.endpoint.resolvers", &resolvers))
+	assert.Equal(t, []struct{ Name string }{{"bob"}, {"charlie"}, {"dave"}, {"eve"}}, resolvers)
+	var orderers []struct{ Address string }
+	assert.NoError(t, p.UnmarshalKey("fabric.default.orderers", &orderers))
+	assert.Equal(t, []struct{ Address string }{{"stage-orderer:7050"}}, orderers)
+	assert.False(t, p.IsSet("overlays"))
+
+	// the environment variables are interpolated, $${ escapes them
+	assert.Equal(t, "s3cr3t", p.GetString("fsc.kvs.password"))
+	assert.Equal(t, "${HOME}/kvs", p.GetString("fsc.kvs.path"))
+
+	// each value remembers the file that contributed it
+	assert.Equal(t, base, p.Provenance("fsc.id"))
+	assert.Equal(t, stage, p.Provenance("fsc.p2p.listenAddress"))
+	assert.Equal(t, base+" (overlays.prod)", p.Provenance("fsc.web.enabled"))
+	assert.Equal(t, base+","+stage+","+stage+" (overlays.prod)", p.Provenance("fsc.endpoint.resolvers"))
+	assert.Equal(t, stage, p.Provenance("fabric.default.orderers"))
+	assert.Equal(t, stage, p.Provenance("fabric.default.orderers.0.address"))
+	assert.Empty(t, p.Provenance("fsc.p2p.unknown"))
+
+	// the errors point at the overlays that contributed the values
+	var c struct{ Retries int }
+	err = p.UnmarshalKey("fabric.default", &c)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid [fabric.default] in the effective configuration, overlaid by [fabric.default.orderers] from ["+stage+"], [fabric.default.retries] from ["+stage+"]")
+
+	// the effective configuration is printed with the secrets masked
+	out := &bytes.Buffer{}
+	assert.NoError(t, p.WriteEffectiveConfig(out))
+	assert.Contains(t, out.String(), "password: '"+Masked+"'")
+	assert.NotContains(t, out.String(), "s3cr3t")
+	assert.Contains(t, out.String(), "address: stage-orderer:7050")
+	assert.NotContains(t, out.String(), "overlays")
+}
+
+func TestOverlaysErrors(t *testing.T) {
+	dir := t.TempDir()
+	base := writeConfig(t, dir, "core.yaml", baseConfig)
+	writeConfig(t, dir, "stage.yaml", stageConfig)
+	t.Setenv("FSCNODE_CFG_PATH", "")
+
+	_, err := NewProviderWithOverlays(dir, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "[fsc.kvs.password] of ["+base+"] refers to the unset environment variable [FSC_TEST_KVS_PASSWORD]")
+
+	t.Setenv("FSC_TEST_KVS_PASSWORD", "s3cr3t")
+	_, err = NewProviderWithOverlays(dir, "test", "stage.yaml")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "overlay section [overlays.test] not found")
+	_, err = NewProviderWithOverlays(dir, "", "missing.yaml")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed reading config file ["+filepath.Join(dir, "missing.yaml")+"]")
+
+	// the overlays are selected by the environment too
+	t.Setenv(OverlaysEnv, "stage.yaml")
+	t.Setenv(ProfileEnv, "prod")
+	p, err := NewProvider(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:9101", p.GetString("fsc.p2p.listenAddress"))
+	assert.False(t, p.GetBool("fsc.web.enabled"))
+}
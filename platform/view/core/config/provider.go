@@ -7,7 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 
 	viperutil "github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config/viper"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
@@ -35,10 +38,29 @@ var (
 type provider struct {
 	confPath string
 	v        *viper.Viper
+	// overlays and profile select the overlays merged on top of the base configuration, see OverlaysEnv and ProfileEnv
+	overlays     []string
+	profile      string
+	fromEnv      bool
+	effectiveCfg *effective
 }
 
+// NewProvider loads the configuration found in confPath, or in the default paths, with the overlays selected by
+// OverlaysEnv and ProfileEnv
 func NewProvider(confPath string) (*provider, error) {
-	p := &provider{confPath: confPath}
+	p := &provider{confPath: confPath, profile: os.Getenv(ProfileEnv), fromEnv: true}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewProviderWithOverlays loads the configuration found in confPath, or in the default paths, with the passed overlay
+// files merged in order on top of it, then the section of the overlays key selected by the passed profile, if any.
+// The relative paths of the overlays are relative to the directory of the base configuration.
+func NewProviderWithOverlays(confPath string, profile string, overlays ...string) (*provider, error) {
+	p := &provider{confPath: confPath, profile: profile, overlays: overlays}
 	if err := p.load(); err != nil {
 		return nil, err
 	}
@@ -67,7 +89,26 @@ func (p *provider) AddDecodeHook(f driver.DecodeHookFuncType) error {
 }
 
 func (p *provider) UnmarshalKey(key string, rawVal interface{}) error {
-	return viperutil.EnhancedExactUnmarshal(p.v, key, rawVal)
+	err := viperutil.EnhancedExactUnmarshal(p.v, key, rawVal)
+	if err == nil {
+		return nil
+	}
+	// the values set by the overlays are the first suspects
+	if c := p.effectiveCfg.contributions(key, p.v.ConfigFileUsed(), 5); len(c) != 0 {
+		return errors.WithMessagef(err, "invalid [%s] in the effective configuration, overlaid by %s", key, strings.Join(c, ", "))
+	}
+	return err
+}
+
+// Provenance returns the file that contributed the value of the passed key to the effective configuration
+func (p *provider) Provenance(key string) string {
+	return p.effectiveCfg.Provenance(key)
+}
+
+// WriteEffectiveConfig writes in YAML the configuration merged from the base file and its overlays, with the
+// environment variables interpolated. The values of the secrets, interpolated or not, are masked.
+func (p *provider) WriteEffectiveConfig(w io.Writer) error {
+	return p.effectiveCfg.Write(w)
 }
 
 func (p *provider) IsSet(key string) bool {
@@ -118,6 +159,10 @@ func (p *provider) load() error {
 			return errors.WithMessagef(err, "error when reading %s config file", CmdRoot)
 		}
 	}
+	// the overlays are merged before any key is read
+	if err := p.loadEffective(); err != nil {
+		return err
+	}
 
 	// read in the legacy logging level settings and, if set,
 	// notify users of the FSCNODE_LOGGING_SPEC env variable
@@ -137,16 +182,63 @@ func (p *provider) load() error {
 	if len(loggingSpec) == 0 {
 		loggingSpec = p.v.GetString("logging.spec")
 	}
+	if len(loggingFormat) == 0 {
+		loggingFormat = p.v.GetString("logging.format")
+	}
 
 	flogging.Init(flogging.Config{
 		Format:  loggingFormat,
 		Writer:  logOutput,
 		LogSpec: loggingSpec,
+		JSON: flogging.JSONConfig{
+			TimestampKey:  p.v.GetString("logging.json.timestampKey"),
+			LevelKey:      p.v.GetString("logging.json.levelKey"),
+			LoggerKey:     p.v.GetString("logging.json.loggerKey"),
+			MessageKey:    p.v.GetString("logging.json.messageKey"),
+			CallerKey:     p.v.GetString("logging.json.callerKey"),
+			StacktraceKey: p.v.GetString("logging.json.stacktraceKey"),
+			TimeEncoding:  p.v.GetString("logging.json.timeEncoding"),
+		},
+		Redaction: flogging.RedactionConfig{
+			Mode:   flogging.RedactionMode(p.v.GetString("logging.redaction.mode")),
+			Fields: p.v.GetStringSlice("logging.redaction.fields"),
+		},
 	})
 
 	return nil
 }
 
+// loadEffective replaces the configuration read by viper with the effective configuration: the base file merged with
+// its overlays, the environment variables interpolated
+func (p *provider) loadEffective() error {
+	base := p.v.ConfigFileUsed()
+	var overlays []string
+	if p.fromEnv {
+		overlays = overlaysFromEnv(base)
+	} else {
+		for _, overlay := range p.overlays {
+			overlays = append(overlays, TranslatePath(filepath.Dir(base), overlay))
+		}
+	}
+	e, err := loadEffective(base, overlays, p.profile)
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading the effective %s configuration", CmdRoot)
+	}
+	raw, err := yaml.Marshal(e.values)
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling the effective %s configuration", CmdRoot)
+	}
+	p.v.SetConfigType("yaml")
+	if err := p.v.ReadConfig(bytes.NewReader(raw)); err != nil {
+		return errors.Wrapf(err, "failed reading the effective %s configuration", CmdRoot)
+	}
+	if len(overlays) != 0 || len(p.profile) != 0 {
+		logger.Infof("configuration [%s] overlaid by %v, profile [%s]", base, overlays, p.profile)
+	}
+	p.effectiveCfg = e
+	return nil
+}
+
 // ----------------------------------------------------------------------------------
 // InitViper()
 // ----------------------------------------------------------------------------------
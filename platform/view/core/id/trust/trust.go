@@ -0,0 +1,231 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trust
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.id.trust")
+
+const (
+	// X509Identity is the type of the identities that are PEM encoded X.509 certificates, as the FSC node identities
+	X509Identity = "x509"
+	// MSPIdentity is the type of the identities that are MSP serialized identities wrapping an X.509 certificate
+	MSPIdentity = "msp"
+
+	trustDomainsKey = "fsc.identity.trustDomains"
+)
+
+var (
+	// ErrUntrusted is matched, with errors.Is, by the errors of the identities a trust domain does not trust
+	ErrUntrusted = errors.New("identity not trusted")
+	// ErrUnknownDomain is matched, with errors.Is, by the errors of the verifications against a domain not configured
+	ErrUnknownDomain = errors.New("unknown trust domain")
+)
+
+type ConfigService interface {
+	IsSet(key string) bool
+	UnmarshalKey(key string, rawVal interface{}) error
+	TranslatePath(path string) string
+}
+
+// DomainConfig is the configuration of a trust domain
+type DomainConfig struct {
+	Name string
+	// RootCAs and IntermediateCAs are the files with the PEM encoded certificates of the CAs of the domain
+	RootCAs         []string `mapstructure:"rootCAs"`
+	IntermediateCAs []string `mapstructure:"intermediateCAs"`
+	// IdentityTypes are the types of the identities the domain accepts, X509Identity if empty
+	IdentityTypes []string `mapstructure:"identityTypes"`
+}
+
+// domain is a trust domain, err is set if it is misconfigured: the identities are verified against its anchors only
+type domain struct {
+	name          string
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	types         map[string]bool
+	err           error
+}
+
+// Service holds the trust domains of the node, the identities of the counterparties of a business network are
+// verified against the anchors of its domain only
+type Service struct {
+	domains map[string]*domain
+	now     func() time.Time
+}
+
+// NewService loads the trust domains listed under the key fsc.identity.trustDomains, each with a name, the files
+// with its root and intermediate CAs, and the identity types it accepts. A domain misconfigured is kept, the
+// verifications against it fail, as the ones against the domains not listed.
+func NewService(cs ConfigService) *Service {
+	s := &Service{domains: map[string]*domain{}, now: time.Now}
+	if !cs.IsSet(trustDomainsKey) {
+		return s
+	}
+	var configs []DomainConfig
+	if err := cs.UnmarshalKey(trustDomainsKey, &configs); err != nil {
+		logger.Errorf("failed loading the trust domains, the counterparties cannot be verified: [%s]", err)
+		return s
+	}
+	for _, c := range configs {
+		if len(c.Name) == 0 {
+			logger.Errorf("trust domain with no name, ignored")
+			continue
+		}
+		if _, ok := s.domains[c.Name]; ok {
+			s.domains[c.Name] = &domain{name: c.Name, err: errors.Errorf("trust domain [%s] declared more than once", c.Name)}
+			logger.Errorf("%s", s.domains[c.Name].err)
+			continue
+		}
+		d := newDomain(c, cs.TranslatePath)
+		if d.err != nil {
+			logger.Errorf("trust domain [%s] misconfigured, the identities verified against it are refused: [%s]", c.Name, d.err)
+		}
+		s.domains[c.Name] = d
+	}
+	return s
+}
+
+// AddDomain adds the passed trust domain, it replaces the domain with the same name, if any
+func (s *Service) AddDomain(c DomainConfig) error {
+	d := newDomain(c, func(path string) string { return path })
+	s.domains[c.Name] = d
+	return d.err
+}
+
+// Domains returns the names of the trust domains, sorted
+func (s *Service) Domains() []string {
+	names := make([]string, 0, len(s.domains))
+	for name := range s.domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Verify returns nil if the passed identity is of a type the passed trust domain accepts and its certificate
+// is issued by the CAs of the domain. The errors match ErrUntrusted.
+func (s *Service) Verify(name string, identity view.Identity) error {
+	d, ok := s.domains[name]
+	if !ok {
+		return errors.WithMessagef(ErrUntrusted, "%s [%s]", ErrUnknownDomain, name)
+	}
+	if d.err != nil {
+		return errors.WithMessagef(ErrUntrusted, "trust domain [%s] misconfigured", name)
+	}
+	if identity.IsNone() {
+		return errors.WithMessagef(ErrUntrusted, "no identity to verify against trust domain [%s]", name)
+	}
+	idType, cert, err := parse(identity)
+	if err != nil {
+		return errors.WithMessagef(ErrUntrusted, "identity [%s] cannot be verified against trust domain [%s]: %s", identity, name, err)
+	}
+	if !d.types[idType] {
+		return errors.WithMessagef(ErrUntrusted, "identity type [%s] not accepted by trust domain [%s]", idType, name)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         d.roots,
+		Intermediates: d.intermediates,
+		CurrentTime:   s.now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errors.WithMessagef(ErrUntrusted, "identity [%s] not certified by trust domain [%s]: %s", identity, name, err)
+	}
+	return nil
+}
+
+func newDomain(c DomainConfig, translate func(string) string) *domain {
+	d := &domain{name: c.Name, roots: x509.NewCertPool(), intermediates: x509.NewCertPool(), types: map[string]bool{}}
+	types := c.IdentityTypes
+	if len(types) == 0 {
+		types = []string{X509Identity}
+	}
+	for _, t := range types {
+		if t != X509Identity && t != MSPIdentity {
+			d.err = errors.Errorf("unknown identity type [%s], expected [%s] or [%s]", t, X509Identity, MSPIdentity)
+			return d
+		}
+		d.types[t] = true
+	}
+	if len(c.RootCAs) == 0 {
+		d.err = errors.New("no root CA")
+		return d
+	}
+	for _, path := range c.RootCAs {
+		if err := addCerts(d.roots, translate(path)); err != nil {
+			d.err = err
+			return d
+		}
+	}
+	for _, path := range c.IntermediateCAs {
+		if err := addCerts(d.intermediates, translate(path)); err != nil {
+			d.err = err
+			return d
+		}
+	}
+	return d
+}
+
+// addCerts adds to the passed pool the PEM encoded certificates of the passed file, which must have at least one
+func addCerts(pool *x509.CertPool, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed reading CA certificates [%s]", path)
+	}
+	found := 0
+	for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return errors.Wrapf(err, "invalid CA certificate in [%s]", path)
+		}
+		pool.AddCert(cert)
+		found++
+	}
+	if found == 0 {
+		return errors.Errorf("no CA certificate in [%s]", path)
+	}
+	return nil
+}
+
+// parse returns the type and the certificate of the passed identity
+func parse(identity view.Identity) (string, *x509.Certificate, error) {
+	if cert, err := pemCert(identity); err == nil {
+		return X509Identity, cert, nil
+	}
+	si := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(identity, si); err != nil || len(si.Mspid) == 0 {
+		return "", nil, errors.New("neither a certificate nor an msp identity")
+	}
+	cert, err := pemCert(si.IdBytes)
+	if err != nil {
+		return "", nil, errors.WithMessagef(err, "invalid certificate of msp identity [%s]", si.Mspid)
+	}
+	return MSPIdentity, cert, nil
+}
+
+func pemCert(raw []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("not a PEM encoded certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
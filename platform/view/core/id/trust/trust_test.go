@@ -0,0 +1,177 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trust
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type ca struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T, name string) *ca {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	assert.NoError(t, err)
+	return &ca{cert: cert, key: key}
+}
+
+func (c *ca) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+// issue returns the PEM encoded certificate of a node, with the passed common name, issued by the CA
+func (c *ca) issue(t *testing.T, name string) view.Identity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})
+}
+
+func writeFile(t *testing.T, dir, name string, content []byte) {
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0o600))
+}
+
+const trustDomainsConfig = `
+fsc:
+  identity:
+    trustDomains:
+    - name: network-a
+      rootCAs:
+      - ca-a.pem
+    - name: network-b
+      rootCAs:
+      - ca-b.pem
+      identityTypes:
+      - x509
+      - msp
+    - name: no-roots
+    - name: bad-roots
+      rootCAs:
+      - missing.pem
+    - name: bad-type
+      rootCAs:
+      - ca-a.pem
+      identityTypes:
+      - idemix
+    - name: twice
+      rootCAs:
+      - ca-a.pem
+    - name: twice
+      rootCAs:
+      - ca-b.pem
+`
+
+func TestTrustDomains(t *testing.T) {
+	caA := newCA(t, "ca-a")
+	caB := newCA(t, "ca-b")
+	dir := t.TempDir()
+	writeFile(t, dir, "ca-a.pem", caA.pem())
+	writeFile(t, dir, "ca-b.pem", caB.pem())
+	writeFile(t, dir, "core.yaml", []byte(trustDomainsConfig))
+	cp, err := config.NewProvider(dir)
+	assert.NoError(t, err)
+	s := NewService(cp)
+	assert.Equal(t, []string{"bad-roots", "bad-type", "network-a", "network-b", "no-roots", "twice"}, s.Domains())
+
+	alice := caA.issue(t, "alice")
+	bob := caB.issue(t, "bob")
+	bobMSP, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: bob})
+	assert.NoError(t, err)
+	aliceMSP, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: alice})
+	assert.NoError(t, err)
+
+	// each domain trusts the identities of its anchors only
+	assert.NoError(t, s.Verify("network-a", alice))
+	assert.NoError(t, s.Verify("network-b", bob))
+	assert.NoError(t, s.Verify("network-b", bobMSP))
+	for _, c := range []struct {
+		domain   string
+		identity view.Identity
+	}{
+		{"network-a", bob},
+		{"network-b", alice},
+		{"network-b", aliceMSP},
+		// network-a accepts x509 identities only
+		{"network-a", aliceMSP},
+		{"network-a", []byte("alice")},
+		{"network-a", nil},
+	} {
+		err := s.Verify(c.domain, c.identity)
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, ErrUntrusted), "%s", err)
+	}
+
+	// the domains misconfigured and the ones not configured trust nobody
+	for _, name := range []string{"no-roots", "bad-roots", "bad-type", "twice", "network-c", ""} {
+		for _, identity := range []view.Identity{alice, bob} {
+			err := s.Verify(name, identity)
+			assert.Error(t, err, "domain [%s] trusts [%s]", name, identity)
+			assert.True(t, errors.Is(err, ErrUntrusted))
+		}
+	}
+	assert.Contains(t, s.Verify("network-c", alice).Error(), ErrUnknownDomain.Error())
+
+	// the identities expire
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.Error(t, s.Verify("network-a", alice))
+}
+
+func TestNoTrustDomains(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "core.yaml", []byte("fsc:\n  id: fsc.a\n"))
+	cp, err := config.NewProvider(dir)
+	assert.NoError(t, err)
+	s := NewService(cp)
+	assert.Empty(t, s.Domains())
+	assert.True(t, errors.Is(s.Verify("network-a", []byte("alice")), ErrUntrusted))
+
+	caA := newCA(t, "ca-a")
+	assert.Error(t, s.AddDomain(DomainConfig{Name: "network-a", RootCAs: []string{filepath.Join(dir, "ca-a.pem")}}))
+	writeFile(t, dir, "ca-a.pem", caA.pem())
+	assert.NoError(t, s.AddDomain(DomainConfig{Name: "network-a", RootCAs: []string{filepath.Join(dir, "ca-a.pem")}}))
+	assert.NoError(t, s.Verify("network-a", caA.issue(t, "alice")))
+}
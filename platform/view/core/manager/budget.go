@@ -0,0 +1,484 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BudgetTime is the wall-clock time of a flow
+	BudgetTime = "time"
+	// BudgetSessions is the number of sessions concurrently open by a flow
+	BudgetSessions = "sessions"
+	// BudgetAllocation is the memory allocated while a flow runs
+	BudgetAllocation = "allocation"
+
+	DefaultBudgetSamplingInterval = time.Second
+)
+
+// ErrBudgetExceeded is matched, with errors.Is, by the errors returned by the flows aborted
+// because they exceeded their budget
+var ErrBudgetExceeded = errors.New("flow budget exceeded")
+
+// BudgetExceededError tells which resource a flow ran out of.
+// Limit and Used are in nanoseconds for BudgetTime, sessions for BudgetSessions, and bytes for BudgetAllocation.
+type BudgetExceededError struct {
+	View      string
+	ContextID string
+	Resource  string
+	Limit     uint64
+	Used      uint64
+}
+
+func (e *BudgetExceededError) Error() string {
+	switch e.Resource {
+	case BudgetTime:
+		return fmt.Sprintf("flow [%s] of view [%s] exceeded its time budget of [%s]", e.ContextID, e.View, time.Duration(e.Limit))
+	case BudgetSessions:
+		return fmt.Sprintf("flow [%s] of view [%s] exceeded its budget of [%d] open sessions", e.ContextID, e.View, e.Limit)
+	default:
+		return fmt.Sprintf("flow [%s] of view [%s] exceeded its %s budget, [%d] over [%d]", e.ContextID, e.View, e.Resource, e.Used, e.Limit)
+	}
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// Budget bounds the resources of a flow. A zero value means no bound.
+type Budget struct {
+	// Timeout bounds the wall-clock time of the whole flow, comm waits included
+	Timeout time.Duration
+	// MaxSessions bounds the number of sessions concurrently open by the flow
+	MaxSessions int
+	// MaxAllocation bounds the bytes allocated while the flow runs
+	MaxAllocation uint64
+}
+
+func (b Budget) bounded() bool {
+	return b.Timeout > 0 || b.MaxSessions > 0 || b.MaxAllocation > 0
+}
+
+// override returns this budget with the non-zero bounds of the passed one
+func (b Budget) override(o Budget) Budget {
+	if o.Timeout > 0 {
+		b.Timeout = o.Timeout
+	}
+	if o.MaxSessions > 0 {
+		b.MaxSessions = o.MaxSessions
+	}
+	if o.MaxAllocation > 0 {
+		b.MaxAllocation = o.MaxAllocation
+	}
+	return b
+}
+
+type viewBudget struct {
+	View          string
+	Timeout       time.Duration
+	MaxSessions   int
+	MaxAllocation uint64
+}
+
+type budgetConfig struct {
+	Timeout          time.Duration
+	MaxSessions      int
+	MaxAllocation    uint64
+	SamplingInterval time.Duration
+	Overrides        []viewBudget
+}
+
+var (
+	flowDurationOpts = metrics.HistogramOpts{
+		Namespace:    "view",
+		Subsystem:    "budget",
+		Name:         "flow_duration",
+		Help:         "The wall-clock time, in seconds, of the flows, per view.",
+		LabelNames:   []string{"view"},
+		StatsdFormat: "%{#fqname}.%{view}",
+	}
+	flowSessionsOpts = metrics.HistogramOpts{
+		Namespace:    "view",
+		Subsystem:    "budget",
+		Name:         "flow_sessions",
+		Help:         "The maximum number of sessions concurrently open by the flows, per view.",
+		Buckets:      []float64{0, 1, 2, 4, 8, 16, 32, 64, 128},
+		LabelNames:   []string{"view"},
+		StatsdFormat: "%{#fqname}.%{view}",
+	}
+	flowAllocationOpts = metrics.HistogramOpts{
+		Namespace:    "view",
+		Subsystem:    "budget",
+		Name:         "flow_allocated_bytes",
+		Help:         "The estimated bytes allocated by the flows, per view.",
+		Buckets:      []float64{1 << 10, 1 << 14, 1 << 17, 1 << 20, 1 << 23, 1 << 26, 1 << 28, 1 << 30},
+		LabelNames:   []string{"view"},
+		StatsdFormat: "%{#fqname}.%{view}",
+	}
+	budgetExceededOpts = metrics.CounterOpts{
+		Namespace:    "view",
+		Subsystem:    "budget",
+		Name:         "exceeded",
+		Help:         "The number of flows aborted because they exceeded their budget, per view and resource.",
+		LabelNames:   []string{"view", "resource"},
+		StatsdFormat: "%{#fqname}.%{view}.%{resource}",
+	}
+)
+
+// BudgetMetrics records the resources consumed by the flows, to tune their budgets
+type BudgetMetrics struct {
+	FlowDuration   metrics.Histogram
+	FlowSessions   metrics.Histogram
+	FlowAllocation metrics.Histogram
+	Exceeded       metrics.Counter
+}
+
+func NewBudgetMetrics(p metrics.Provider) *BudgetMetrics {
+	return &BudgetMetrics{
+		FlowDuration:   p.NewHistogram(flowDurationOpts),
+		FlowSessions:   p.NewHistogram(flowSessionsOpts),
+		FlowAllocation: p.NewHistogram(flowAllocationOpts),
+		Exceeded:       p.NewCounter(budgetExceededOpts),
+	}
+}
+
+// budgets enforces the budgets of the flows run by the view manager.
+// Go does not account the memory per goroutine, therefore the allocation of a flow is estimated by
+// sampling the bytes allocated by the whole process and dividing them among the flows running in the meantime.
+type budgets struct {
+	defaults  Budget
+	overrides map[string]Budget
+	interval  time.Duration
+	metrics   *BudgetMetrics
+
+	// err is the error loading the configured budgets
+	err error
+
+	lock           sync.Mutex
+	active         map[*flowBudget]struct{}
+	lastTotalAlloc uint64
+	stop           chan struct{}
+	// idle is closed once no flow is active, it is created by waitIdle
+	idle chan struct{}
+}
+
+// newBudgets loads the budgets from the following keys:
+// fsc.views.budget.timeout bounds the wall-clock time of a flow,
+// fsc.views.budget.maxSessions bounds the number of sessions concurrently open by a flow,
+// fsc.views.budget.maxAllocation bounds the bytes allocated by a flow,
+// fsc.views.budget.samplingInterval is the period of the allocation sampling, default 1s,
+// fsc.views.budget.overrides lists per-view budgets, by view identifier, whose non-zero bounds replace the defaults.
+// An invalid configuration is reported by the Validate of the manager.
+func newBudgets(sp driver.ServiceProvider) *budgets {
+	b := &budgets{
+		overrides: map[string]Budget{},
+		interval:  DefaultBudgetSamplingInterval,
+		active:    map[*flowBudget]struct{}{},
+	}
+	var p metrics.Provider = &disabled.Provider{}
+	if s, err := sp.GetService(reflect.TypeOf((*metrics.Provider)(nil))); err == nil {
+		p = s.(metrics.Provider)
+	}
+	b.metrics = NewBudgetMetrics(p)
+
+	s, err := sp.GetService(reflect.TypeOf((*driver.ConfigService)(nil)))
+	if err != nil {
+		return b
+	}
+	cs := s.(driver.ConfigService)
+	if !cs.IsSet("fsc.views.budget") {
+		return b
+	}
+	config := &budgetConfig{}
+	if err := cs.UnmarshalKey("fsc.views.budget", config); err != nil {
+		b.err = errors.Wrapf(err, "failed loading the flow budgets from fsc.views.budget")
+		logger.Errorf("%s", b.err)
+		return b
+	}
+	b.defaults = Budget{Timeout: config.Timeout, MaxSessions: config.MaxSessions, MaxAllocation: config.MaxAllocation}
+	for _, o := range config.Overrides {
+		b.overrides[o.View] = Budget{Timeout: o.Timeout, MaxSessions: o.MaxSessions, MaxAllocation: o.MaxAllocation}
+	}
+	if config.SamplingInterval > 0 {
+		b.interval = config.SamplingInterval
+	}
+	return b
+}
+
+// budget returns the budget of the flows of the passed view
+func (b *budgets) budget(view string) Budget {
+	if o, ok := b.overrides[view]; ok {
+		return b.defaults.override(o)
+	}
+	return b.defaults
+}
+
+// start tracks a new flow of the passed view, and returns the context the flow must run in
+func (b *budgets) start(view, contextID string, parent context.Context) (*flowBudget, context.Context) {
+	f := &flowBudget{
+		budgets:   b,
+		view:      view,
+		contextID: contextID,
+		limits:    b.budget(view),
+		started:   time.Now(),
+		exceeded:  make(chan struct{}),
+	}
+	if f.limits.Timeout > 0 {
+		f.ctx, f.cancel = context.WithTimeout(parent, f.limits.Timeout)
+		f.timer = time.AfterFunc(f.limits.Timeout, f.timeout)
+	} else {
+		f.ctx, f.cancel = context.WithCancel(parent)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.active) == 0 {
+		b.lastTotalAlloc = totalAlloc()
+		b.stop = make(chan struct{})
+		go b.sample(b.stop)
+	}
+	b.active[f] = struct{}{}
+	return f, f.ctx
+}
+
+func (b *budgets) end(f *flowBudget) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.active, f)
+	if len(b.active) == 0 {
+		close(b.stop)
+		if b.idle != nil {
+			close(b.idle)
+			b.idle = nil
+		}
+	}
+}
+
+// waitIdle returns once no flow is active, or with an error once the passed context is done
+func (b *budgets) waitIdle(ctx context.Context) error {
+	b.lock.Lock()
+	if len(b.active) == 0 {
+		b.lock.Unlock()
+		return nil
+	}
+	if b.idle == nil {
+		b.idle = make(chan struct{})
+	}
+	idle := b.idle
+	b.lock.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "[%d] flows still active", len(b.flows()))
+	}
+}
+
+// flows returns the active flows, the oldest first
+func (b *budgets) flows() []*flowBudget {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	res := make([]*flowBudget, 0, len(b.active))
+	for f := range b.active {
+		res = append(res, f)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].started.Before(res[j].started) })
+	return res
+}
+
+func (b *budgets) sample(stop chan struct{}) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.lock.Lock()
+			b.account()
+			b.lock.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// account divides the bytes allocated since the last sample among the active flows
+func (b *budgets) account() {
+	current := totalAlloc()
+	delta := current - b.lastTotalAlloc
+	b.lastTotalAlloc = current
+	if len(b.active) == 0 {
+		return
+	}
+	share := delta / uint64(len(b.active))
+	for f := range b.active {
+		f.allocate(share)
+	}
+}
+
+func totalAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.TotalAlloc
+}
+
+// flowBudget tracks the resources consumed by a flow against its budget
+type flowBudget struct {
+	budgets   *budgets
+	view      string
+	contextID string
+	limits    Budget
+	started   time.Time
+	ctx       context.Context
+	cancel    context.CancelFunc
+	timer     *time.Timer
+	// running is closed once the flow returns, it is nil if the flow does not run in its own goroutine
+	running chan struct{}
+
+	lock        sync.Mutex
+	maxSessions int
+	allocated   uint64
+	err         *BudgetExceededError
+	exceeded    chan struct{}
+}
+
+// run runs the passed function, the flow, and returns as soon as the flow exceeds its budget.
+// The flow is notified via the cancellation of its context, and it stays tracked until it returns.
+func (f *flowBudget) run(call func() (interface{}, error)) (interface{}, error) {
+	if !f.limits.bounded() {
+		return call()
+	}
+	type result struct {
+		res interface{}
+		err error
+	}
+	done := make(chan result, 1)
+	f.running = make(chan struct{})
+	go func() {
+		defer close(f.running)
+		res, err := call()
+		done <- result{res: res, err: err}
+	}()
+	select {
+	case r := <-done:
+		// the flow might have returned on its deadline
+		f.checkDeadline()
+		if err := f.error(); err != nil {
+			return nil, err
+		}
+		return r.res, r.err
+	case <-f.exceeded:
+		return nil, f.error()
+	}
+}
+
+func (f *flowBudget) checkDeadline() {
+	if f.limits.Timeout > 0 && f.ctx.Err() == context.DeadlineExceeded {
+		f.timeout()
+	}
+}
+
+func (f *flowBudget) timeout() {
+	f.exceed(BudgetTime, uint64(f.limits.Timeout), uint64(time.Since(f.started)))
+}
+
+// openSession checks that the flow can open a new session, given the number of sessions it has already open
+func (f *flowBudget) openSession(open int) error {
+	if f == nil {
+		return nil
+	}
+	if f.limits.MaxSessions > 0 && open >= f.limits.MaxSessions {
+		f.exceed(BudgetSessions, uint64(f.limits.MaxSessions), uint64(open+1))
+		return f.error()
+	}
+	f.lock.Lock()
+	if open+1 > f.maxSessions {
+		f.maxSessions = open + 1
+	}
+	f.lock.Unlock()
+	return nil
+}
+
+func (f *flowBudget) allocate(bytes uint64) {
+	f.lock.Lock()
+	f.allocated += bytes
+	allocated := f.allocated
+	f.lock.Unlock()
+	if f.limits.MaxAllocation > 0 && allocated > f.limits.MaxAllocation {
+		f.exceed(BudgetAllocation, f.limits.MaxAllocation, allocated)
+	}
+}
+
+// exceed aborts the flow, only the first breach is reported
+func (f *flowBudget) exceed(resource string, limit, used uint64) {
+	f.lock.Lock()
+	if f.err != nil {
+		f.lock.Unlock()
+		return
+	}
+	f.err = &BudgetExceededError{View: f.view, ContextID: f.contextID, Resource: resource, Limit: limit, Used: used}
+	close(f.exceeded)
+	f.lock.Unlock()
+
+	logger.Warnf("aborting flow: %s", f.err)
+	f.budgets.metrics.Exceeded.With("view", f.view, "resource", resource).Add(1)
+	f.cancel()
+}
+
+func (f *flowBudget) error() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err == nil {
+		return nil
+	}
+	return f.err
+}
+
+// end cancels the context of the flow, and releases the flow and records the resources it consumed once it returns.
+// A flow aborted on its budget might still run: it stays among the active flows until it returns.
+func (f *flowBudget) end() {
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.cancel()
+	if f.running != nil {
+		select {
+		case <-f.running:
+		default:
+			logger.Warnf("flow [%s] of view [%s] aborted but still running, wait for it to return", f.contextID, f.view)
+			go func() {
+				<-f.running
+				f.release()
+			}()
+			return
+		}
+	}
+	f.release()
+}
+
+func (f *flowBudget) release() {
+	f.budgets.end(f)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	m := f.budgets.metrics
+	m.FlowDuration.With("view", f.view).Observe(time.Since(f.started).Seconds())
+	m.FlowSessions.With("view", f.view).Observe(float64(f.maxSessions))
+	m.FlowAllocation.With("view", f.view).Observe(float64(f.allocated))
+}
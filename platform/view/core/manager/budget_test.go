@@ -0,0 +1,184 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// sessions is a comm layer whose sessions never deliver anything
+type sessions struct{}
+
+func (s *sessions) NewSessionWithID(sessionID, contextID, endpoint string, pkid []byte, caller view.Identity, msg *view.Message) (view.Session, error) {
+	a, _ := newPipe()
+	return a, nil
+}
+
+func (s *sessions) NewSession(caller string, contextID string, endpoint string, pkid []byte) (view.Session, error) {
+	a, _ := newPipe()
+	return a, nil
+}
+
+func (s *sessions) MasterSession() (view.Session, error) {
+	a, _ := newPipe()
+	return a, nil
+}
+
+func (s *sessions) DeleteSessions(sessionID string) {}
+
+type viewFunc func(context view.Context) (interface{}, error)
+
+func (f viewFunc) Call(context view.Context) (interface{}, error) {
+	return f(context)
+}
+
+func newBudgetManager(t *testing.T, budget Budget) *manager {
+	registry := registry2.New()
+	idProvider := &mock.IdentityProvider{}
+	idProvider.DefaultIdentityReturns([]byte("alice"))
+	assert.NoError(t, registry.RegisterService(idProvider))
+	assert.NoError(t, registry.RegisterService(&sessions{}))
+	resolver := &mock.EndpointService{}
+	resolver.ResolveStub = func(party view.Identity) (view.Identity, map[driver.PortName]string, []byte, error) {
+		return party, map[driver.PortName]string{driver.P2PPort: string(party)}, party, nil
+	}
+	assert.NoError(t, registry.RegisterService(resolver))
+	m := New(registry)
+	m.budgets.defaults = budget
+	m.budgets.interval = 10 * time.Millisecond
+	return m
+}
+
+func assertBudgetExceeded(t *testing.T, err error, resource string) {
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBudgetExceeded), "expected a budget error, got [%s]", err)
+	budgetErr := &BudgetExceededError{}
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, resource, budgetErr.Resource)
+}
+
+func TestBudgetTimeout(t *testing.T) {
+	m := newBudgetManager(t, Budget{Timeout: 50 * time.Millisecond})
+
+	// the flow waiting on its context sees the deadline
+	start := time.Now()
+	_, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+		_, ok := context.Context().Deadline()
+		assert.True(t, ok)
+		<-context.Context().Done()
+		return nil, context.Context().Err()
+	}))
+	assertBudgetExceeded(t, err, BudgetTime)
+
+	// the flow ignoring its context is abandoned, with its context cancelled, and tracked until it returns
+	release := make(chan struct{})
+	cancelled := make(chan error, 1)
+	_, err = m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+		<-release
+		cancelled <- context.Context().Err()
+		return "done", nil
+	}))
+	assertBudgetExceeded(t, err, BudgetTime)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Len(t, m.InFlight(), 1)
+	close(release)
+	assert.Error(t, <-cancelled)
+	assert.Eventually(t, func() bool { return len(m.InFlight()) == 0 }, time.Second, 10*time.Millisecond)
+
+	// the flows within budget are not affected
+	res, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+		return "done", nil
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "done", res)
+}
+
+func TestBudgetSessions(t *testing.T) {
+	m := newBudgetManager(t, Budget{MaxSessions: 2})
+
+	_, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+		for _, party := range []string{"bob", "charlie"} {
+			if _, err := context.GetSession(context.Initiator(), view.Identity(party)); err != nil {
+				return nil, err
+			}
+		}
+		// sessions already open do not count twice
+		if _, err := context.GetSession(context.Initiator(), view.Identity("bob")); err != nil {
+			return nil, err
+		}
+		_, err := context.GetSession(context.Initiator(), view.Identity("dave"))
+		return nil, err
+	}))
+	assertBudgetExceeded(t, err, BudgetSessions)
+}
+
+func TestBudgetAllocation(t *testing.T) {
+	m := newBudgetManager(t, Budget{MaxAllocation: 1 << 20})
+
+	allocated := make(chan int, 1)
+	_, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+		var chunks [][]byte
+		for {
+			select {
+			case <-context.Context().Done():
+				allocated <- len(chunks)
+				return nil, context.Context().Err()
+			default:
+				chunks = append(chunks, make([]byte, 64<<10))
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}))
+	assertBudgetExceeded(t, err, BudgetAllocation)
+	assert.NotZero(t, <-allocated)
+}
+
+func TestBudgetOverrides(t *testing.T) {
+	b := &budgets{
+		defaults: Budget{Timeout: time.Minute, MaxSessions: 10},
+		overrides: map[string]Budget{
+			"batch": {Timeout: time.Hour, MaxAllocation: 1 << 30},
+		},
+	}
+	assert.Equal(t, Budget{Timeout: time.Minute, MaxSessions: 10}, b.budget("transfer"))
+	assert.Equal(t, Budget{Timeout: time.Hour, MaxSessions: 10, MaxAllocation: 1 << 30}, b.budget("batch"))
+}
+
+func TestBudgetConfig(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "core.yaml"), []byte(`
+fsc:
+  views:
+    budget:
+      timeout: 5m
+      maxAllocation: 8GB
+`), 0644))
+	m, _ := newPolicyManager(t, dir)
+	assert.NoError(t, m.Validate())
+	assert.Equal(t, Budget{Timeout: 5 * time.Minute, MaxAllocation: 8 << 30}, m.budgets.defaults)
+
+	// an invalid configuration fails the manager, the flows are not left unbounded silently
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "core.yaml"), []byte(`
+fsc:
+  views:
+    budget:
+      maxSessions: many
+`), 0644))
+	m, _ = newPolicyManager(t, dir)
+	assert.Error(t, m.Validate())
+	assert.Contains(t, m.Validate().Error(), "failed loading the flow budgets from fsc.views.budget")
+}
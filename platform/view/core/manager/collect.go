@@ -0,0 +1,166 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// BroadcastAndCollect sends the passed message to the passed parties, on the sessions of the initiator of this
+// context, unless the options set another caller, and collects their replies until the quorum is met
+func (ctx *ctx) BroadcastAndCollect(parties []view.Identity, msg []byte, quorum int, timeout time.Duration, opts ...view.CollectOption) (*view.CollectResult, error) {
+	options, err := view.CompileCollectOptions(opts...)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed compiling collect options")
+	}
+	if options.Caller == nil {
+		options.Caller = ctx.initiator
+	}
+	return broadcastAndCollect(ctx.Context(), func(party view.Identity) (view.Session, error) {
+		return ctx.getSession(options.Caller, party, options.Label)
+	}, parties, msg, quorum, timeout, options)
+}
+
+// BroadcastAndCollect sends the passed message to the passed parties, on the sessions of the initiator of this
+// context, unless the options set another caller, and collects their replies until the quorum is met
+func (w *childContext) BroadcastAndCollect(parties []view.Identity, msg []byte, quorum int, timeout time.Duration, opts ...view.CollectOption) (*view.CollectResult, error) {
+	options, err := view.CompileCollectOptions(opts...)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed compiling collect options")
+	}
+	if options.Caller == nil {
+		options.Caller = w.Initiator()
+	}
+	return broadcastAndCollect(w.Context(), func(party view.Identity) (view.Session, error) {
+		return w.getSession(options.Caller, party, options.Label)
+	}, parties, msg, quorum, timeout, options)
+}
+
+type partyOutcome struct {
+	index  int
+	result *view.PartyResult
+}
+
+// broadcastAndCollect sends the passed message to each party, on the session returned by open, all in parallel,
+// and collects the replies. It returns as soon as the quorum is met, or it cannot be met anymore.
+// The parties still outstanding then either are cancelled, or complete in background, as the options tell.
+// The sessions of the parties that time out, or are cancelled, are closed.
+func broadcastAndCollect(c context.Context, open func(view.Identity) (view.Session, error), parties []view.Identity, msg []byte, quorum int, timeout time.Duration, options *view.CollectOptions) (*view.CollectResult, error) {
+	if quorum <= 0 || quorum > len(parties) {
+		return nil, errors.Errorf("invalid quorum [%d] for [%d] parties", quorum, len(parties))
+	}
+	if timeout <= 0 {
+		return nil, errors.Errorf("invalid timeout [%s]", timeout)
+	}
+	if c == nil {
+		c = context.Background()
+	}
+	defer view.TrackPhase(c, view.PhaseSession)()
+
+	c, cancel := context.WithTimeout(c, timeout)
+	// stragglersCancelled is set when the outstanding parties are cancelled because the quorum has been decided
+	var stragglersCancelled int32
+	outcomes := make(chan partyOutcome, len(parties))
+	for i, party := range parties {
+		go func(i int, party view.Identity) {
+			r := collectFrom(c, open, party, msg, timeout, &stragglersCancelled)
+			outcomes <- partyOutcome{index: i, result: r}
+		}(i, party)
+	}
+
+	res := &view.CollectResult{Parties: make([]*view.PartyResult, len(parties))}
+	for i, party := range parties {
+		res.Parties[i] = &view.PartyResult{Party: party, Outcome: view.CollectPending}
+	}
+	received, failed := 0, 0
+	for received < len(parties) && res.Replies < quorum && len(parties)-failed >= quorum {
+		o := <-outcomes
+		received++
+		res.Parties[o.index] = o.result
+		if o.result.Outcome == view.CollectReplied {
+			res.Replies++
+		} else {
+			failed++
+		}
+	}
+	res.QuorumMet = res.Replies >= quorum
+
+	if outstanding := len(parties) - received; outstanding > 0 {
+		if options.CancelStragglers {
+			atomic.StoreInt32(&stragglersCancelled, 1)
+			cancel()
+		}
+		go func() {
+			defer cancel()
+			for ; outstanding > 0; outstanding-- {
+				o := <-outcomes
+				if options.OnStraggler != nil {
+					options.OnStraggler(o.result)
+				}
+			}
+		}()
+	} else {
+		cancel()
+	}
+
+	if !res.QuorumMet {
+		return res, &view.ErrQuorumNotMet{Quorum: quorum, Replies: res.Replies, Parties: len(parties)}
+	}
+	return res, nil
+}
+
+// collectFrom sends the passed message to the passed party and waits for its reply, until the passed context is done
+func collectFrom(c context.Context, open func(view.Identity) (view.Session, error), party view.Identity, msg []byte, timeout time.Duration, stragglersCancelled *int32) *view.PartyResult {
+	r := &view.PartyResult{Party: party}
+	s, err := open(party)
+	if err != nil {
+		r.Outcome, r.Err = view.CollectError, errors.WithMessagef(err, "failed opening session with [%s]", party)
+		return r
+	}
+	if err := s.Send(msg); err != nil {
+		r.Outcome, r.Err = view.CollectError, errors.WithMessagef(err, "failed sending to [%s]", party)
+		return r
+	}
+	defer view.TrackReceive(c, s)()
+	ch := s.Receive()
+	for {
+		select {
+		case m, ok := <-ch:
+			if !ok || m == nil {
+				r.Outcome, r.Err = view.CollectError, errors.Errorf("session with [%s] closed", party)
+				return r
+			}
+			switch m.Status {
+			case view.SessionPeerUnreachable, view.SessionPeerRecovered, view.SessionConnectionLost:
+				// notifications, the reply might still arrive
+				continue
+			case view.ERROR:
+				r.Outcome, r.Err = view.CollectError, errors.Errorf("received error from [%s]: [%s]", party, string(m.Payload))
+				return r
+			}
+			r.Outcome, r.Reply = view.CollectReplied, m.Payload
+			return r
+		case <-c.Done():
+			// the reply, if it ever arrives, must not reach the next user of the session
+			s.Close()
+			switch {
+			case atomic.LoadInt32(stragglersCancelled) == 1:
+				r.Outcome, r.Err = view.CollectCancelled, errors.Errorf("cancelled waiting for [%s], the quorum has been decided", party)
+			case errors.Is(c.Err(), context.DeadlineExceeded):
+				r.Outcome, r.Err = view.CollectTimeout, errors.Errorf("[%s] did not reply within [%s]", party, timeout)
+			default:
+				r.Outcome, r.Err = view.CollectCancelled, errors.Wrapf(c.Err(), "cancelled waiting for [%s]", party)
+			}
+			return r
+		}
+	}
+}
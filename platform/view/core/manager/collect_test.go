@@ -0,0 +1,195 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedSession replies to the first message sent after the delay, with the status and the payload of its script.
+// A negative delay never replies.
+type scriptedSession struct {
+	party  string
+	delay  time.Duration
+	status int32
+	reply  string
+	in     chan *view.Message
+
+	lock   sync.Mutex
+	sent   [][]byte
+	closed bool
+}
+
+func newScriptedSession(party string, delay time.Duration, status int32, reply string) *scriptedSession {
+	return &scriptedSession{party: party, delay: delay, status: status, reply: reply, in: make(chan *view.Message, 2)}
+}
+
+func (s *scriptedSession) Info() view.SessionInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return view.SessionInfo{ID: s.party, Closed: s.closed}
+}
+
+func (s *scriptedSession) Send(payload []byte) error {
+	s.lock.Lock()
+	s.sent = append(s.sent, payload)
+	s.lock.Unlock()
+	if s.delay < 0 {
+		return nil
+	}
+	go func() {
+		time.Sleep(s.delay)
+		// a notification first, it must be skipped
+		s.in <- &view.Message{Status: view.SessionPeerRecovered}
+		s.in <- &view.Message{Status: s.status, Payload: []byte(s.reply)}
+	}()
+	return nil
+}
+
+func (s *scriptedSession) SendError(payload []byte) error { return nil }
+
+func (s *scriptedSession) Receive() <-chan *view.Message { return s.in }
+
+func (s *scriptedSession) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+}
+
+func (s *scriptedSession) sentMessages() [][]byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.sent
+}
+
+func (s *scriptedSession) isClosed() bool {
+	return s.Info().Closed
+}
+
+func opener(sessions map[string]*scriptedSession) func(view.Identity) (view.Session, error) {
+	return func(party view.Identity) (view.Session, error) {
+		s, ok := sessions[string(party)]
+		if !ok {
+			return nil, errors.Errorf("unknown party [%s]", party)
+		}
+		return s, nil
+	}
+}
+
+func TestBroadcastAndCollectQuorum(t *testing.T) {
+	sessions := map[string]*scriptedSession{
+		"alice":   newScriptedSession("alice", 0, view.OK, "a"),
+		"bob":     newScriptedSession("bob", 10*time.Millisecond, view.OK, "b"),
+		"charlie": newScriptedSession("charlie", 300*time.Millisecond, view.OK, "c"),
+	}
+	parties := []view.Identity{view.Identity("alice"), view.Identity("bob"), view.Identity("charlie")}
+	late := make(chan *view.PartyResult, 1)
+	res, err := broadcastAndCollect(context.Background(), opener(sessions), parties, []byte("quote"), 2, time.Second,
+		&view.CollectOptions{OnStraggler: func(r *view.PartyResult) { late <- r }})
+	assert.NoError(t, err)
+	assert.True(t, res.QuorumMet)
+	assert.Equal(t, 2, res.Replies)
+	assert.Equal(t, view.CollectReplied, res.Parties[0].Outcome)
+	assert.Equal(t, []byte("a"), res.Parties[0].Reply)
+	assert.Equal(t, []byte("b"), res.Parties[1].Reply)
+	assert.Equal(t, view.CollectPending, res.Parties[2].Outcome)
+	assert.Len(t, res.Replied(), 2)
+
+	// the straggler completes in background
+	select {
+	case r := <-late:
+		assert.Equal(t, view.CollectReplied, r.Outcome)
+		assert.Equal(t, []byte("c"), r.Reply)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the straggler did not complete")
+	}
+	assert.False(t, sessions["charlie"].isClosed())
+	for _, s := range sessions {
+		assert.Equal(t, [][]byte{[]byte("quote")}, s.sentMessages())
+	}
+	// the result returned does not change
+	assert.Equal(t, view.CollectPending, res.Parties[2].Outcome)
+}
+
+func TestBroadcastAndCollectCancelStragglers(t *testing.T) {
+	sessions := map[string]*scriptedSession{
+		"alice": newScriptedSession("alice", 0, view.OK, "a"),
+		"bob":   newScriptedSession("bob", -1, view.OK, ""),
+	}
+	parties := []view.Identity{view.Identity("alice"), view.Identity("bob")}
+	late := make(chan *view.PartyResult, 1)
+	res, err := broadcastAndCollect(context.Background(), opener(sessions), parties, []byte("quote"), 1, time.Minute,
+		&view.CollectOptions{CancelStragglers: true, OnStraggler: func(r *view.PartyResult) { late <- r }})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Replies)
+	r := <-late
+	assert.Equal(t, view.CollectCancelled, r.Outcome)
+	assert.True(t, sessions["bob"].isClosed())
+}
+
+func TestBroadcastAndCollectFailures(t *testing.T) {
+	sessions := map[string]*scriptedSession{
+		"alice":   newScriptedSession("alice", 0, view.OK, "a"),
+		"bob":     newScriptedSession("bob", 0, view.ERROR, "no price"),
+		"charlie": newScriptedSession("charlie", -1, view.OK, ""),
+	}
+	parties := []view.Identity{view.Identity("alice"), view.Identity("bob"), view.Identity("charlie"), view.Identity("dave")}
+
+	// charlie times out, bob replies with an error, and dave cannot be reached
+	res, err := broadcastAndCollect(context.Background(), opener(sessions), parties, []byte("quote"), 2, 50*time.Millisecond, &view.CollectOptions{})
+	assert.Error(t, err)
+	notMet := &view.ErrQuorumNotMet{}
+	assert.True(t, errors.As(err, &notMet))
+	assert.Equal(t, &view.ErrQuorumNotMet{Quorum: 2, Replies: 1, Parties: 4}, notMet)
+	assert.False(t, res.QuorumMet)
+	assert.Equal(t, view.CollectReplied, res.Parties[0].Outcome)
+	assert.Equal(t, view.CollectError, res.Parties[1].Outcome)
+	assert.Contains(t, res.Parties[1].Err.Error(), "no price")
+	assert.Equal(t, view.CollectTimeout, res.Parties[2].Outcome)
+	assert.True(t, sessions["charlie"].isClosed())
+	assert.Equal(t, view.CollectError, res.Parties[3].Outcome)
+
+	// the quorum cannot be met, the call returns without waiting for the timeout
+	start := time.Now()
+	res, err = broadcastAndCollect(context.Background(), opener(sessions), parties[1:], []byte("quote"), 3, time.Minute, &view.CollectOptions{})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, view.CollectPending, res.Parties[1].Outcome)
+
+	// the invalid quorums
+	_, err = broadcastAndCollect(context.Background(), opener(sessions), parties, nil, 0, time.Second, &view.CollectOptions{})
+	assert.Error(t, err)
+	_, err = broadcastAndCollect(context.Background(), opener(sessions), parties, nil, 5, time.Second, &view.CollectOptions{})
+	assert.Error(t, err)
+}
+
+func TestBroadcastAndCollectContextCancelled(t *testing.T) {
+	sessions := map[string]*scriptedSession{
+		"alice": newScriptedSession("alice", -1, view.OK, ""),
+		"bob":   newScriptedSession("bob", -1, view.OK, ""),
+	}
+	parties := []view.Identity{view.Identity("alice"), view.Identity("bob")}
+	c, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	res, err := broadcastAndCollect(c, opener(sessions), parties, []byte("quote"), 1, time.Minute, &view.CollectOptions{})
+	assert.Error(t, err)
+	for i, r := range res.Parties {
+		assert.Equal(t, view.CollectCancelled, r.Outcome)
+		assert.True(t, errors.Is(r.Err, context.Canceled))
+		assert.True(t, sessions[string(parties[i])].isClosed())
+	}
+}
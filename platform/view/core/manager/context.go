@@ -30,6 +30,11 @@ type ctx struct {
 	caller         view.Identity
 	resolver       driver.EndpointService
 	sessionFactory SessionFactory
+	authenticator  *authenticator
+	checkpointer   *checkpointer
+	budget         *flowBudget
+	sessionMetrics *SessionMetrics
+	trace          *flowTrace
 
 	sessionsLock       sync.RWMutex
 	sessions           map[string]view.Session
@@ -88,6 +93,9 @@ func (ctx *ctx) RunView(v view.View, opts ...view.RunViewOption) (res interface{
 		initiator = v
 	}
 
+	if options.SameContext && options.Routing != nil {
+		return nil, errors.Errorf("a routed view cannot run in the same context")
+	}
 	var cc localContext
 	if options.SameContext {
 		cc = ctx
@@ -96,6 +104,7 @@ func (ctx *ctx) RunView(v view.View, opts ...view.RunViewOption) (res interface{
 			ParentContext: ctx,
 			session:       options.Session,
 			initiator:     initiator,
+			context:       routedContext(ctx.Context(), options.Routing),
 		}
 	}
 
@@ -150,6 +159,17 @@ func (ctx *ctx) Caller() view.Identity {
 }
 
 func (ctx *ctx) GetSession(f view.View, party view.Identity) (view.Session, error) {
+	return ctx.getSession(f, party, "")
+}
+
+// GetSessionWithLabel returns the session, with the passed label, to the passed party for the initiator of this context
+func (ctx *ctx) GetSessionWithLabel(party view.Identity, label string) (view.Session, error) {
+	return ctx.getSession(ctx.initiator, party, label)
+}
+
+// getSession returns the session, with the passed label, to the passed party for the passed view.
+// The sessions with different labels to the same party are distinct.
+func (ctx *ctx) getSession(f view.View, party view.Identity, label string) (view.Session, error) {
 	// TODO: we need a mechanism to close all the sessions opened in this ctx,
 	// when the ctx goes out of scope
 	ctx.sessionsLock.Lock()
@@ -159,9 +179,9 @@ func (ctx *ctx) GetSession(f view.View, party view.Identity) (view.Session, erro
 	id := party
 
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("get session for [%s:%s]", id.UniqueID(), getIdentifier(f))
+		logger.Debugf("get session for [%s:%s], label [%s]", id.UniqueID(), getIdentifier(f), label)
 	}
-	s, ok := ctx.sessions[id.UniqueID()]
+	s, ok := ctx.sessions[sessionKey(id, label)]
 	if !ok {
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf("session for [%s] does not exists, resolve", id.UniqueID())
@@ -169,7 +189,7 @@ func (ctx *ctx) GetSession(f view.View, party view.Identity) (view.Session, erro
 
 		id, _, _, err = view2.GetEndpointService(ctx).Resolve(party)
 		if err == nil {
-			s, ok = ctx.sessions[id.UniqueID()]
+			s, ok = ctx.sessions[sessionKey(id, label)]
 			if logger.IsEnabledFor(zapcore.DebugLevel) {
 				logger.Debugf("session resolved for [%s] exists? [%v]", id.UniqueID(), ok)
 			}
@@ -179,7 +199,7 @@ func (ctx *ctx) GetSession(f view.View, party view.Identity) (view.Session, erro
 			if !partyIdentity.IsNone() {
 				id, _, _, err = view2.GetEndpointService(ctx).Resolve(partyIdentity)
 				if err == nil {
-					s, ok = ctx.sessions[id.UniqueID()]
+					s, ok = ctx.sessions[sessionKey(id, label)]
 					if logger.IsEnabledFor(zapcore.DebugLevel) {
 						logger.Debugf("session resolved for [%s] exists? [%v]", id.UniqueID(), ok)
 					}
@@ -197,7 +217,7 @@ func (ctx *ctx) GetSession(f view.View, party view.Identity) (view.Session, erro
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf("removing session [%s], it is closed", id.UniqueID(), ok)
 		}
-		delete(ctx.sessions, id.UniqueID())
+		delete(ctx.sessions, sessionKey(id, label))
 		ok = false
 	}
 
@@ -208,16 +228,25 @@ func (ctx *ctx) GetSession(f view.View, party view.Identity) (view.Session, erro
 		}
 
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("[%s] Creating new session [to:%s]", ctx.me, id)
+			logger.Debugf("[%s] Creating new session [to:%s], label [%s]", ctx.me, id, label)
 		}
-		s, err = ctx.newSession(f, ctx.id, id)
+		if err := ctx.checkSessionBudget(); err != nil {
+			return nil, err
+		}
+		s, err = ctx.newSession(f, ctx.id, id, label)
 		if err != nil {
 			return nil, err
 		}
-		ctx.sessions[id.UniqueID()] = s
+		ctx.sessionMetrics.opened(getIdentifier(f), label)
+		ctx.trace.opened(s, label)
+		ctx.sessions[sessionKey(id, label)] = s
 	} else {
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("[%s] Reusing session [to:%s]", ctx.me, id)
+			logger.Debugf("[%s] Reusing session [to:%s], label [%s]", ctx.me, id, label)
+		}
+		// the session might have been opened by a view not declaring the trust domain of this one
+		if err := ctx.verifyTrust(f, id); err != nil {
+			return nil, err
 		}
 	}
 	return s, nil
@@ -235,10 +264,14 @@ func (ctx *ctx) GetSessionByID(id string, party view.Identity) (view.Session, er
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf("[%s] Creating new session with given id [id:%s][to:%s]", ctx.me, id, party)
 		}
+		if err := ctx.checkSessionBudget(); err != nil {
+			return nil, err
+		}
 		s, err = ctx.newSessionByID(id, ctx.id, party)
 		if err != nil {
 			return nil, err
 		}
+		ctx.trace.opened(s, "")
 		ctx.sessions[key] = s
 	} else {
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
@@ -301,20 +334,70 @@ func (ctx *ctx) Dispose() {
 	ctx.sessions = map[string]view.Session{}
 }
 
-func (ctx *ctx) newSession(view view.View, contextID string, party view.Identity) (view.Session, error) {
-	_, endpoints, pkid, err := ctx.resolver.Resolve(party)
+// checkSessionBudget checks that the flow can open one more session.
+// The caller must hold the sessions lock.
+func (ctx *ctx) checkSessionBudget() error {
+	if ctx.budget == nil {
+		return nil
+	}
+	open := 0
+	for _, s := range ctx.sessions {
+		if s != nil && !s.Info().Closed {
+			open++
+		}
+	}
+	return ctx.budget.openSession(open)
+}
+
+func (ctx *ctx) newSession(view view.View, contextID string, party view.Identity, label string) (view.Session, error) {
+	id, endpoints, pkid, err := ctx.resolver.Resolve(party)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.verifyTrust(view, id); err != nil {
+		return nil, err
+	}
+	s, err := ctx.sessionFactory.NewSession(getIdentifier(view), contextID, endpoints[driver.P2PPort], pkid)
 	if err != nil {
 		return nil, err
 	}
-	return ctx.sessionFactory.NewSession(getIdentifier(view), contextID, endpoints[driver.P2PPort], pkid)
+	if err := setSessionMetadata(s, view, label, ctx.routing()); err != nil {
+		ctx.sessionFactory.DeleteSessions(s.Info().ID)
+		return nil, err
+	}
+	return ctx.authenticate(s, id, contextID, endpoints[driver.P2PPort], pkid)
 }
 
 func (ctx *ctx) newSessionByID(sessionID, contextID string, party view.Identity) (view.Session, error) {
-	_, endpoints, pkid, err := ctx.resolver.Resolve(party)
+	id, endpoints, pkid, err := ctx.resolver.Resolve(party)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ctx.sessionFactory.NewSessionWithID(sessionID, contextID, endpoints[driver.P2PPort], pkid, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.authenticate(s, id, contextID, endpoints[driver.P2PPort], pkid)
+}
+
+// authenticate runs the session handshake with the passed party, if required.
+// If the handshake fails, the session is deleted.
+func (ctx *ctx) authenticate(s view.Session, party view.Identity, contextID, endpoint string, pkid []byte) (view.Session, error) {
+	if !ctx.authenticator.Required(party) {
+		return s, nil
+	}
+	hs, err := ctx.sessionFactory.NewSession(HandshakeCaller, contextID, endpoint, pkid)
 	if err != nil {
+		return nil, errors.WithMessagef(err, "failed creating handshake session with [%s]", party)
+	}
+	defer ctx.sessionFactory.DeleteSessions(hs.Info().ID)
+
+	me := driver.GetIdentityProvider(ctx.sp).DefaultIdentity()
+	if err := ctx.authenticator.Initiate(hs, me, party, s.Info().ID, contextID); err != nil {
+		ctx.sessionFactory.DeleteSessions(s.Info().ID)
 		return nil, err
 	}
-	return ctx.sessionFactory.NewSessionWithID(sessionID, contextID, endpoints[driver.P2PPort], pkid, nil, nil)
+	return s, nil
 }
 
 func (ctx *ctx) cleanup() {
@@ -339,3 +422,72 @@ type localContext interface {
 	view.Context
 	cleanup()
 }
+
+// routing returns the routing carried by the context of this flow, if any
+func (ctx *ctx) routing() view.Routing {
+	routing, _ := view.RoutingFromContext(ctx.Context())
+	return routing
+}
+
+// verifyTrust returns nil if the passed caller declares no trust domain, or if the passed party is certified by the
+// anchors of the declared domain. It fails if the node has no trust domains.
+func (ctx *ctx) verifyTrust(caller view.View, party view.Identity) error {
+	td, ok := caller.(view.SessionTrustDomain)
+	if !ok || len(td.SessionTrustDomain()) == 0 {
+		return nil
+	}
+	domain := td.SessionTrustDomain()
+	trustDomains := driver.GetTrustDomains(ctx.sp)
+	if trustDomains == nil {
+		return errors.Errorf("cannot open session with [%s], no trust domain configured, [%s] expected", party, domain)
+	}
+	if err := trustDomains.Verify(domain, party); err != nil {
+		return errors.WithMessagef(err, "cannot open session with [%s]", party)
+	}
+	return nil
+}
+
+// setSessionMetadata makes the passed session carry the metadata declared by the passed view, if any, and the passed label.
+// The label is required by the remote end to dispatch the session, it is an error if the session cannot carry it.
+// The network and the channel of the passed routing are carried too, unless the view declares its own.
+func setSessionMetadata(s view.Session, caller view.View, label string, routing view.Routing) error {
+	var metadata map[string]string
+	if md, ok := caller.(view.SessionMetadata); ok {
+		metadata = md.SessionMetadata()
+	}
+	extra := map[string]string{}
+	if len(label) != 0 {
+		extra[view.SessionLabelMetadata] = label
+	}
+	if _, ok := view.RoutingFromMetadata(metadata); !ok {
+		if len(routing.Network) != 0 {
+			extra[view.SessionNetworkMetadata] = routing.Network
+		}
+		if len(routing.Channel) != 0 {
+			extra[view.SessionChannelMetadata] = routing.Channel
+		}
+	}
+	if len(extra) != 0 {
+		m := make(map[string]string, len(metadata)+len(extra))
+		for k, v := range metadata {
+			m[k] = v
+		}
+		for k, v := range extra {
+			m[k] = v
+		}
+		metadata = m
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	ms, ok := s.(view.MetadataSession)
+	if !ok {
+		if len(label) != 0 {
+			return errors.Errorf("session [%s] cannot carry label [%s]", s.Info().ID, label)
+		}
+		logger.Warnf("session [%s] cannot carry the metadata declared by [%s]", s.Info().ID, getIdentifier(caller))
+		return nil
+	}
+	ms.SetMetadata(metadata)
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+// Drain returns once the flows run by the manager have terminated, or with an error once the passed context is done.
+// The flows initiated with InitiateContext, whose views are run by the caller, are not tracked.
+func (cm *manager) Drain(ctx context.Context) error {
+	return cm.budgets.waitIdle(ctx)
+}
+
+// InFlight returns the flows still running, the oldest first
+func (cm *manager) InFlight() []drain.Work {
+	flows := cm.budgets.flows()
+	res := make([]drain.Work, 0, len(flows))
+	cm.recoverablesSync.RLock()
+	defer cm.recoverablesSync.RUnlock()
+	for _, f := range flows {
+		_, recoverable := cm.recoverables[f.view]
+		res = append(res, drain.Work{ID: f.contextID, View: f.view, Since: f.started, Recoverable: recoverable})
+	}
+	return res
+}
+
+// admit returns an error if the node drains and refuses new flows
+func (cm *manager) admit() error {
+	return drain.GetService(cm.sp).Check()
+}
+
+// admitSession returns an error if the node drains and the session, whose first message is passed,
+// would start a new flow. The messages of the flows in flight are still delivered.
+// The remote end is notified in case of failure.
+func (cm *manager) admitSession(msg *view.Message) error {
+	if err := cm.admit(); err != nil {
+		cm.contextsSync.RLock()
+		_, ok := cm.contexts[msg.ContextID]
+		cm.contextsSync.RUnlock()
+		if ok {
+			return nil
+		}
+		caller, err2 := driver.GetEndpointService(cm.sp).GetIdentity(msg.FromEndpoint, msg.FromPKID)
+		if err2 != nil {
+			caller = nil
+		}
+		cm.reject(msg, caller, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	m, comm := newPolicyManager(t, "")
+	d := drain.NewService(nil, nil)
+	assert.NoError(t, m.sp.(interface{ RegisterService(interface{}) error }).RegisterService(d))
+	d.Register("views", m)
+	responder := &internalResponder{ran: make(chan view.Identity, 1)}
+	initiator := getIdentifier(&internalInitiator{})
+	assert.NoError(t, m.RegisterResponder(responder, initiator))
+
+	// a flow in flight
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return nil, nil
+		}))
+		done <- err
+	}()
+	<-started
+	if assert.Len(t, m.InFlight(), 1) {
+		assert.Equal(t, getIdentifier(viewFunc(nil)), m.InFlight()[0].View)
+	}
+
+	// the flow in flight does not complete within the deadline of the drain
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, m.Drain(ctx))
+
+	assert.NoError(t, d.Start(time.Minute))
+	// new flows are refused
+	_, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) { return nil, nil }))
+	assert.True(t, errors.Is(err, drain.ErrDraining))
+	_, err = m.InitiateContext(viewFunc(func(context view.Context) (interface{}, error) { return nil, nil }))
+	assert.True(t, errors.Is(err, drain.ErrDraining))
+	// new sessions are refused with a retryable status
+	m.callView(sessionFrom("bob", initiator, nil))
+	assert.Len(t, responder.ran, 0)
+	if assert.Len(t, comm.errors, 1) {
+		assert.True(t, strings.HasPrefix(comm.errors[0], "UNAVAILABLE"), comm.errors[0])
+	}
+
+	// the flow in flight completes, the node is drained
+	close(release)
+	assert.NoError(t, <-done)
+	select {
+	case <-d.Done():
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node not drained")
+	}
+	status := d.Status()
+	assert.Equal(t, "drained", status.State)
+	assert.Empty(t, status.Unfinished)
+	assert.Empty(t, m.InFlight())
+	assert.NoError(t, m.Drain(context.Background()))
+}
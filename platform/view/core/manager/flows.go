@@ -0,0 +1,275 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// DefaultFlowHistory is the number of session events kept per flow, unless configured otherwise
+const DefaultFlowHistory = 64
+
+type flowsConfig struct {
+	Debug   bool
+	History int
+}
+
+// flowTraces tracks the flows run by the manager for their introspection: the operations they block on,
+// and the events of their sessions. It is always on: the events are kept in a bounded history per flow,
+// without their payload unless in debug mode.
+type flowTraces struct {
+	debug   bool
+	history int
+	now     func() time.Time
+
+	lock    sync.RWMutex
+	running map[string]*flowTrace
+}
+
+// newFlowTraces loads the following keys:
+// fsc.views.flows.debug records the payloads of the messages in the history of the flows, default false,
+// fsc.views.flows.history is the number of session events kept per flow, default DefaultFlowHistory.
+func newFlowTraces(sp driver.ServiceProvider) *flowTraces {
+	f := &flowTraces{history: DefaultFlowHistory, now: time.Now, running: map[string]*flowTrace{}}
+	s, err := sp.GetService(reflect.TypeOf((*driver.ConfigService)(nil)))
+	if err != nil {
+		return f
+	}
+	cs := s.(driver.ConfigService)
+	if !cs.IsSet("fsc.views.flows") {
+		return f
+	}
+	config := &flowsConfig{}
+	if err := cs.UnmarshalKey("fsc.views.flows", config); err != nil {
+		logger.Errorf("failed loading the flow introspection configuration, use the defaults: [%s]", err)
+		return f
+	}
+	f.debug = config.Debug
+	if config.History > 0 {
+		f.history = config.History
+	}
+	if f.debug {
+		logger.Warnf("flow introspection in debug mode, the payloads of the sessions are served by the admin endpoints")
+	}
+	return f
+}
+
+// start tracks a new flow, and returns the context the flow must run in: it carries the tracker of the flow,
+// and it is cancelled when the flow is aborted
+func (f *flowTraces) start(key, contextID, v string, initiator bool, caller string, parent context.Context) (*flowTrace, context.Context) {
+	t := &flowTrace{
+		traces:    f,
+		contextID: contextID,
+		view:      v,
+		initiator: initiator,
+		caller:    caller,
+		started:   f.now(),
+		blocked:   map[*blockingOperation]struct{}{},
+	}
+	ctx, cancel := context.WithCancel(view.WithFlowTracker(parent, t))
+	t.cancel = cancel
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	t.id = key
+	for n := 2; f.running[t.id] != nil; n++ {
+		t.id = key + "~" + strconv.Itoa(n)
+	}
+	f.running[t.id] = t
+	return t, ctx
+}
+
+func (f *flowTraces) end(t *flowTrace) {
+	f.lock.Lock()
+	if f.running[t.id] == t {
+		delete(f.running, t.id)
+	}
+	f.lock.Unlock()
+	t.cancel()
+}
+
+func (f *flowTraces) get(id string) (*flowTrace, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	t, ok := f.running[id]
+	if !ok {
+		return nil, errors.Wrapf(driver.ErrFlowNotFound, "flow [%s]", id)
+	}
+	return t, nil
+}
+
+// flows returns the running flows, the oldest first
+func (f *flowTraces) flows() []*flowTrace {
+	f.lock.RLock()
+	res := make([]*flowTrace, 0, len(f.running))
+	for _, t := range f.running {
+		res = append(res, t)
+	}
+	f.lock.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].started.Equal(res[j].started) {
+			return res[i].id < res[j].id
+		}
+		return res[i].started.Before(res[j].started)
+	})
+	return res
+}
+
+type blockingOperation struct {
+	operation string
+	targets   []string
+	since     time.Time
+}
+
+// flowTrace is the view.FlowTracker of a flow
+type flowTrace struct {
+	traces    *flowTraces
+	id        string
+	contextID string
+	view      string
+	initiator bool
+	caller    string
+	started   time.Time
+	cancel    context.CancelFunc
+
+	lock     sync.Mutex
+	blocked  map[*blockingOperation]struct{}
+	sessions int
+	events   []driver.SessionEvent
+	// next is the position of the next event in events, once the history is full
+	next    int
+	dropped int
+	aborted *time.Time
+}
+
+func (t *flowTrace) Block(operation string, targets ...string) func() {
+	op := &blockingOperation{operation: operation, targets: targets, since: t.traces.now()}
+	t.lock.Lock()
+	t.blocked[op] = struct{}{}
+	t.lock.Unlock()
+	return func() {
+		t.lock.Lock()
+		delete(t.blocked, op)
+		t.lock.Unlock()
+	}
+}
+
+func (t *flowTrace) Exchange(session view.Session, kind string, status int32, payload []byte) {
+	info := session.Info()
+	e := driver.SessionEvent{Kind: kind, SessionID: info.ID, Endpoint: info.Endpoint, Label: info.Label, Status: status, Size: len(payload)}
+	if t.traces.debug && len(payload) != 0 {
+		e.Payload = append([]byte(nil), payload...)
+	}
+	t.record(e)
+}
+
+// opened records the opening of the passed session by, or for, the flow.
+// The sessions implementing view.TrackedSession record their messages from now on.
+func (t *flowTrace) opened(session view.Session, label string) {
+	if t == nil {
+		return
+	}
+	if tracked, ok := session.(view.TrackedSession); ok {
+		tracked.TrackFlow(t)
+	}
+	info := session.Info()
+	t.lock.Lock()
+	t.sessions++
+	t.lock.Unlock()
+	t.record(driver.SessionEvent{Kind: driver.SessionOpened, SessionID: info.ID, Endpoint: info.Endpoint, Label: label})
+}
+
+func (t *flowTrace) record(e driver.SessionEvent) {
+	e.Time = t.traces.now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.events) < t.traces.history {
+		t.events = append(t.events, e)
+		return
+	}
+	t.events[t.next] = e
+	t.next = (t.next + 1) % len(t.events)
+	t.dropped++
+}
+
+// abort cancels the context of the flow
+func (t *flowTrace) abort() {
+	t.lock.Lock()
+	if t.aborted == nil {
+		now := t.traces.now()
+		t.aborted = &now
+	}
+	t.lock.Unlock()
+	logger.Warnf("aborting flow [%s] of view [%s] on request", t.id, t.view)
+	t.cancel()
+}
+
+// describe returns the description of the flow, with the history of its sessions if requested
+func (t *flowTrace) describe(history bool) *driver.FlowDetail {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	res := &driver.FlowDetail{RunningFlow: driver.RunningFlow{
+		ID:        t.id,
+		ContextID: t.contextID,
+		View:      t.view,
+		Initiator: t.initiator,
+		Caller:    t.caller,
+		Started:   t.started,
+		Sessions:  t.sessions,
+		Aborted:   t.aborted,
+	}}
+	for op := range t.blocked {
+		res.Blocked = append(res.Blocked, driver.BlockingOperation{Operation: op.operation, Targets: op.targets, Since: op.since})
+	}
+	sort.SliceStable(res.Blocked, func(i, j int) bool { return res.Blocked[i].Since.Before(res.Blocked[j].Since) })
+	if history {
+		res.History = make([]driver.SessionEvent, 0, len(t.events))
+		res.History = append(res.History, t.events[t.next:]...)
+		res.History = append(res.History, t.events[:t.next]...)
+		res.Dropped = t.dropped
+	}
+	return res
+}
+
+// RunningFlows returns the flows running, the oldest first.
+// The flows initiated with InitiateContext, whose views are run by the caller, are not tracked.
+func (cm *manager) RunningFlows() []driver.RunningFlow {
+	flows := cm.flows.flows()
+	res := make([]driver.RunningFlow, len(flows))
+	for i, t := range flows {
+		res[i] = t.describe(false).RunningFlow
+	}
+	return res
+}
+
+// RunningFlow returns the running flow with the passed ID, with the history of its sessions
+func (cm *manager) RunningFlow(id string) (*driver.FlowDetail, error) {
+	t, err := cm.flows.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return t.describe(true), nil
+}
+
+// AbortFlow cancels the context of the running flow with the passed ID
+func (cm *manager) AbortFlow(id string) error {
+	t, err := cm.flows.get(id)
+	if err != nil {
+		return err
+	}
+	t.abort()
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunningFlows(t *testing.T) {
+	m := newBudgetManager(t, Budget{})
+
+	blocked := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+			s, err := context.GetSession(context.Initiator(), view.Identity("bob"))
+			if err != nil {
+				return nil, err
+			}
+			// the session records what it sends in the history of the flow
+			if err := s.Send([]byte("request")); err != nil {
+				return nil, err
+			}
+			defer view.TrackBlocking(context.Context(), view.BlockedOnFinality, "tx1")()
+			blocked <- context.ID()
+			<-context.Context().Done()
+			return nil, context.Context().Err()
+		}))
+		done <- err
+	}()
+	id := <-blocked
+
+	flows := m.RunningFlows()
+	assert.Len(t, flows, 1)
+	assert.Equal(t, id, flows[0].ID)
+	assert.Equal(t, id, flows[0].ContextID)
+	assert.True(t, flows[0].Initiator)
+	assert.Equal(t, 1, flows[0].Sessions)
+	assert.Len(t, flows[0].Blocked, 1)
+	assert.Equal(t, view.BlockedOnFinality, flows[0].Blocked[0].Operation)
+	assert.Equal(t, []string{"tx1"}, flows[0].Blocked[0].Targets)
+
+	// the history tells the sizes of the messages, not their payloads
+	flow, err := m.RunningFlow(id)
+	assert.NoError(t, err)
+	assert.Len(t, flow.History, 2)
+	assert.Equal(t, driver.SessionOpened, flow.History[0].Kind)
+	assert.Equal(t, view.SessionSent, flow.History[1].Kind)
+	assert.Equal(t, len("request"), flow.History[1].Size)
+	assert.Nil(t, flow.History[1].Payload)
+
+	// the aborted flow sees its context cancelled, and is no longer tracked once it returns
+	assert.NoError(t, m.AbortFlow(id))
+	select {
+	case err := <-done:
+		assert.True(t, errors.Is(err, context.Canceled), "expected a cancellation, got [%v]", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the aborted flow did not return")
+	}
+	assert.Empty(t, m.RunningFlows())
+	_, err = m.RunningFlow(id)
+	assert.True(t, errors.Is(err, driver.ErrFlowNotFound))
+	assert.True(t, errors.Is(m.AbortFlow(id), driver.ErrFlowNotFound))
+}
+
+func TestFlowHistory(t *testing.T) {
+	f := &flowTraces{debug: true, history: 3, now: time.Now, running: map[string]*flowTrace{}}
+	first, _ := f.start("ctx", "ctx", "v", false, "alice", context.Background())
+	second, _ := f.start("ctx", "ctx", "v", false, "alice", context.Background())
+	assert.Equal(t, "ctx", first.id)
+	assert.Equal(t, "ctx~2", second.id)
+	assert.Len(t, f.flows(), 2)
+
+	s, _ := newPipe()
+	for _, payload := range []string{"a", "b", "c", "d", "e"} {
+		first.Exchange(s, view.SessionReceived, view.OK, []byte(payload))
+	}
+	detail := first.describe(true)
+	assert.Equal(t, 2, detail.Dropped)
+	var payloads []string
+	for _, e := range detail.History {
+		payloads = append(payloads, string(e.Payload))
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, payloads)
+
+	resume := first.Block(view.BlockedOnFinality, "tx1", "tx2")
+	assert.Len(t, first.describe(false).Blocked, 1)
+	resume()
+	assert.Empty(t, first.describe(false).Blocked)
+
+	f.end(first)
+	_, err := f.get("ctx")
+	assert.True(t, errors.Is(err, driver.ErrFlowNotFound))
+	_, err = f.get("ctx~2")
+	assert.NoError(t, err)
+}
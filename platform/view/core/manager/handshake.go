@@ -0,0 +1,395 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// HandshakeCaller is the view identifier carried by the messages of the handshake sessions
+	HandshakeCaller = "fsc/handshake/1.0.0"
+
+	// RejectLegacy rejects the sessions with nodes that do not support the handshake
+	RejectLegacy = "reject"
+	// AllowLegacy accepts the sessions with nodes that do not support the handshake
+	AllowLegacy = "allow-legacy"
+
+	DefaultHandshakeTimeout = 5 * time.Second
+
+	nonceSize = 24
+
+	helloStep     = 1
+	challengeStep = 2
+	responseStep  = 3
+)
+
+// HandshakeError is returned when the remote end of a session fails to prove that it controls the identity it claims
+type HandshakeError struct {
+	Endpoint view.Identity
+	Reason   string
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("session handshake with [%s] failed: %s", e.Endpoint, e.Reason)
+}
+
+// handshakeMessage is exchanged over the handshake session.
+// The initiator sends the hello, the responder answers with its challenge and signature,
+// the initiator closes with its signature. The last message does not require an answer,
+// therefore the handshake adds a single round trip to the session establishment.
+type handshakeMessage struct {
+	Step           int
+	SessionID      string
+	ContextID      string
+	Identity       view.Identity
+	InitiatorNonce []byte
+	ResponderNonce []byte
+	Signature      []byte
+}
+
+// handshakeTranscript is what each side signs
+type handshakeTranscript struct {
+	Role           string
+	SessionID      string
+	ContextID      string
+	InitiatorNonce []byte
+	ResponderNonce []byte
+}
+
+func (m *handshakeMessage) transcript(role string) ([]byte, error) {
+	return json.Marshal(&handshakeTranscript{
+		Role:           role,
+		SessionID:      m.SessionID,
+		ContextID:      m.ContextID,
+		InitiatorNonce: m.InitiatorNonce,
+		ResponderNonce: m.ResponderNonce,
+	})
+}
+
+// authenticator runs the mutual challenge-response between FSC nodes
+type authenticator struct {
+	sp        driver.ServiceProvider
+	enabled   bool
+	endpoints []string
+	policy    string
+	timeout   time.Duration
+
+	lock sync.Mutex
+	// authenticated maps session ids to the identity that proved control of it
+	authenticated map[string]view.Identity
+	waiters       map[string]chan struct{}
+}
+
+// newAuthenticator loads the handshake configuration from the following keys:
+// fsc.p2p.handshake.enabled enables the handshake for all the endpoints,
+// fsc.p2p.handshake.endpoints lists the endpoints, by resolver name, for which the handshake is enabled,
+// fsc.p2p.handshake.legacyPolicy is either `reject` (default) or `allow-legacy`,
+// fsc.p2p.handshake.timeout bounds the time to wait for the remote end.
+func newAuthenticator(sp driver.ServiceProvider) *authenticator {
+	a := &authenticator{
+		sp:            sp,
+		policy:        RejectLegacy,
+		timeout:       DefaultHandshakeTimeout,
+		authenticated: map[string]view.Identity{},
+		waiters:       map[string]chan struct{}{},
+	}
+	s, err := sp.GetService(reflect.TypeOf((*driver.ConfigService)(nil)))
+	if err != nil {
+		return a
+	}
+	cs := s.(driver.ConfigService)
+	a.enabled = cs.GetBool("fsc.p2p.handshake.enabled")
+	a.endpoints = cs.GetStringSlice("fsc.p2p.handshake.endpoints")
+	if policy := cs.GetString("fsc.p2p.handshake.legacyPolicy"); len(policy) != 0 {
+		a.policy = policy
+	}
+	if cs.IsSet("fsc.p2p.handshake.timeout") {
+		a.timeout = cs.GetDuration("fsc.p2p.handshake.timeout")
+	}
+	return a
+}
+
+// Required returns true if the handshake must be run with the passed party.
+// If one of the configured endpoints cannot be resolved, the party might be that endpoint,
+// therefore the handshake is required.
+func (a *authenticator) Required(party view.Identity) bool {
+	if a == nil {
+		return false
+	}
+	if a.enabled {
+		return true
+	}
+	if len(a.endpoints) == 0 {
+		return false
+	}
+	resolver := driver.GetEndpointService(a.sp)
+	for _, endpoint := range a.endpoints {
+		id, err := resolver.GetIdentity(endpoint, nil)
+		if err != nil {
+			logger.Warnf("failed resolving handshake endpoint [%s], require the handshake with [%s]: [%s]", endpoint, party, err)
+			return true
+		}
+		if id.Equal(party) {
+			return true
+		}
+	}
+	return false
+}
+
+// Initiate runs the initiator side of the handshake for the passed session over the handshake session hs.
+// party is the identity the remote end is expected to prove control of.
+func (a *authenticator) Initiate(hs view.Session, me, party view.Identity, sessionID, contextID string) error {
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	hello := &handshakeMessage{
+		Step:           helloStep,
+		SessionID:      sessionID,
+		ContextID:      contextID,
+		Identity:       me,
+		InitiatorNonce: nonce,
+	}
+	if err := a.send(hs, hello); err != nil {
+		return errors.WithMessagef(err, "failed sending handshake hello to [%s]", party)
+	}
+
+	var challenge *handshakeMessage
+	select {
+	case msg := <-hs.Receive():
+		if msg.Status == view.ERROR {
+			return &HandshakeError{Endpoint: party, Reason: string(msg.Payload)}
+		}
+		challenge = &handshakeMessage{}
+		if err := json.Unmarshal(msg.Payload, challenge); err != nil {
+			return &HandshakeError{Endpoint: party, Reason: "malformed challenge"}
+		}
+	case <-time.After(a.timeout):
+		return a.legacy(party)
+	}
+
+	if challenge.Step != challengeStep || challenge.SessionID != sessionID || challenge.ContextID != contextID ||
+		string(challenge.InitiatorNonce) != string(nonce) || len(challenge.ResponderNonce) != nonceSize {
+		return &HandshakeError{Endpoint: party, Reason: "challenge does not match the session"}
+	}
+	if !challenge.Identity.Equal(party) {
+		return &HandshakeError{Endpoint: party, Reason: fmt.Sprintf("unexpected identity [%s]", challenge.Identity)}
+	}
+	if err := a.verify(challenge, "responder"); err != nil {
+		return &HandshakeError{Endpoint: party, Reason: err.Error()}
+	}
+
+	response := &handshakeMessage{
+		Step:           responseStep,
+		SessionID:      sessionID,
+		ContextID:      contextID,
+		Identity:       me,
+		InitiatorNonce: nonce,
+		ResponderNonce: challenge.ResponderNonce,
+	}
+	if err := a.sign(response, "initiator"); err != nil {
+		return err
+	}
+	if err := a.send(hs, response); err != nil {
+		return errors.WithMessagef(err, "failed sending handshake response to [%s]", party)
+	}
+	return nil
+}
+
+// Respond runs the responder side of the handshake started by the passed hello.
+// caller is the identity bound to the transport endpoint the hello was received from, if known.
+// On success, the session announced in the hello is marked as authenticated.
+func (a *authenticator) Respond(hs view.Session, me, caller view.Identity, raw []byte) error {
+	hello := &handshakeMessage{}
+	if err := json.Unmarshal(raw, hello); err != nil || hello.Step != helloStep || len(hello.InitiatorNonce) != nonceSize {
+		return a.fail(hs, &HandshakeError{Endpoint: caller, Reason: "malformed hello"})
+	}
+	if !caller.IsNone() && !caller.Equal(hello.Identity) {
+		return a.fail(hs, &HandshakeError{Endpoint: hello.Identity, Reason: fmt.Sprintf("identity does not match the endpoint [%s]", caller)})
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	challenge := &handshakeMessage{
+		Step:           challengeStep,
+		SessionID:      hello.SessionID,
+		ContextID:      hello.ContextID,
+		Identity:       me,
+		InitiatorNonce: hello.InitiatorNonce,
+		ResponderNonce: nonce,
+	}
+	if err := a.sign(challenge, "responder"); err != nil {
+		return err
+	}
+	if err := a.send(hs, challenge); err != nil {
+		return errors.WithMessagef(err, "failed sending handshake challenge to [%s]", hello.Identity)
+	}
+
+	var response *handshakeMessage
+	select {
+	case msg := <-hs.Receive():
+		response = &handshakeMessage{}
+		if err := json.Unmarshal(msg.Payload, response); err != nil {
+			return &HandshakeError{Endpoint: hello.Identity, Reason: "malformed response"}
+		}
+	case <-time.After(a.timeout):
+		return &HandshakeError{Endpoint: hello.Identity, Reason: "timeout waiting for the response"}
+	}
+	if response.Step != responseStep || response.SessionID != hello.SessionID || response.ContextID != hello.ContextID ||
+		string(response.InitiatorNonce) != string(hello.InitiatorNonce) || string(response.ResponderNonce) != string(nonce) ||
+		!response.Identity.Equal(hello.Identity) {
+		return &HandshakeError{Endpoint: hello.Identity, Reason: "response does not match the session"}
+	}
+	if err := a.verify(response, "initiator"); err != nil {
+		return &HandshakeError{Endpoint: hello.Identity, Reason: err.Error()}
+	}
+
+	a.markAuthenticated(hello.SessionID, hello.Identity)
+	return nil
+}
+
+// CheckSession checks that the passed session has been authenticated by the passed caller.
+// It waits for a running handshake to complete, up to the configured timeout.
+func (a *authenticator) CheckSession(sessionID string, caller view.Identity) error {
+	if !a.Required(caller) {
+		return nil
+	}
+	a.lock.Lock()
+	id, ok := a.authenticated[sessionID]
+	var waiter chan struct{}
+	if !ok {
+		waiter = a.waiter(sessionID)
+	}
+	a.lock.Unlock()
+
+	if !ok {
+		select {
+		case <-waiter:
+			a.lock.Lock()
+			id, ok = a.authenticated[sessionID]
+			a.lock.Unlock()
+		case <-time.After(a.timeout):
+		}
+	}
+	if !ok {
+		a.forget(sessionID)
+		return a.legacy(caller)
+	}
+	a.forget(sessionID)
+	if !caller.IsNone() && !id.Equal(caller) {
+		return &HandshakeError{Endpoint: caller, Reason: fmt.Sprintf("session authenticated by [%s]", id)}
+	}
+	return nil
+}
+
+func (a *authenticator) forget(sessionID string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.authenticated, sessionID)
+	delete(a.waiters, sessionID)
+}
+
+func (a *authenticator) markAuthenticated(sessionID string, id view.Identity) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.authenticated[sessionID] = id
+	if waiter, ok := a.waiters[sessionID]; ok {
+		close(waiter)
+		delete(a.waiters, sessionID)
+	}
+	// the first message of the session is expected shortly after the handshake,
+	// if it never arrives, do not keep the entry forever
+	time.AfterFunc(10*a.timeout, func() { a.forget(sessionID) })
+}
+
+// waiter must be called holding the lock
+func (a *authenticator) waiter(sessionID string) chan struct{} {
+	waiter, ok := a.waiters[sessionID]
+	if !ok {
+		waiter = make(chan struct{})
+		a.waiters[sessionID] = waiter
+	}
+	return waiter
+}
+
+func (a *authenticator) legacy(party view.Identity) error {
+	if a.policy == AllowLegacy {
+		logger.Warnf("no handshake with [%s], the node might not support it, continue as legacy", party)
+		return nil
+	}
+	return &HandshakeError{Endpoint: party, Reason: "no handshake, legacy nodes are rejected"}
+}
+
+func (a *authenticator) sign(msg *handshakeMessage, role string) error {
+	transcript, err := msg.transcript(role)
+	if err != nil {
+		return err
+	}
+	signer, err := driver.GetSigService(a.sp).GetSigner(msg.Identity)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting signer for [%s]", msg.Identity)
+	}
+	msg.Signature, err = signer.Sign(transcript)
+	if err != nil {
+		return errors.WithMessagef(err, "failed signing handshake transcript")
+	}
+	return nil
+}
+
+func (a *authenticator) verify(msg *handshakeMessage, role string) error {
+	transcript, err := msg.transcript(role)
+	if err != nil {
+		return err
+	}
+	verifier, err := driver.GetSigService(a.sp).GetVerifier(msg.Identity)
+	if err != nil {
+		return errors.WithMessagef(err, "no verifier for [%s]", msg.Identity)
+	}
+	if err := verifier.Verify(transcript, msg.Signature); err != nil {
+		return errors.WithMessagef(err, "invalid signature")
+	}
+	return nil
+}
+
+func (a *authenticator) send(hs view.Session, msg *handshakeMessage) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if logger.IsEnabledFor(zapcore.DebugLevel) {
+		logger.Debugf("send handshake message [step:%d] for session [%s]", msg.Step, msg.SessionID)
+	}
+	return hs.Send(raw)
+}
+
+func (a *authenticator) fail(hs view.Session, err *HandshakeError) error {
+	if err2 := hs.SendError([]byte(err.Reason)); err2 != nil {
+		logger.Errorf("failed notifying handshake error [%s]", err2)
+	}
+	return err
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed generating nonce")
+	}
+	return nonce, nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// pipeSession delivers what is sent to the incoming channel of its peer
+type pipeSession struct {
+	in      chan *view.Message
+	peer    *pipeSession
+	tracker view.FlowTracker
+}
+
+func newPipe() (*pipeSession, *pipeSession) {
+	a := &pipeSession{in: make(chan *view.Message, 10)}
+	b := &pipeSession{in: make(chan *view.Message, 10)}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+func (p *pipeSession) Info() view.SessionInfo { return view.SessionInfo{ID: "hs"} }
+
+func (p *pipeSession) Send(payload []byte) error {
+	p.peer.in <- &view.Message{Status: view.OK, Payload: payload}
+	if p.tracker != nil {
+		p.tracker.Exchange(p, view.SessionSent, view.OK, payload)
+	}
+	return nil
+}
+
+func (p *pipeSession) SendError(payload []byte) error {
+	p.peer.in <- &view.Message{Status: view.ERROR, Payload: payload}
+	return nil
+}
+
+func (p *pipeSession) Receive() <-chan *view.Message { return p.in }
+
+func (p *pipeSession) Close() {}
+
+func (p *pipeSession) TrackFlow(tracker view.FlowTracker) { p.tracker = tracker }
+
+// signer produces signatures that are bound to the identity
+type signer struct {
+	id view.Identity
+}
+
+func (s *signer) Sign(message []byte) ([]byte, error) {
+	return append(append([]byte{}, s.id...), message...), nil
+}
+
+func (s *signer) Verify(message, sigma []byte) error {
+	if !bytes.Equal(sigma, append(append([]byte{}, s.id...), message...)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func newTestAuthenticator(t *testing.T, signers ...view.Identity) *authenticator {
+	registry := registry2.New()
+	sigService := &mock.SigService{}
+	sigService.GetSignerStub = func(identity view.Identity) (driver.Signer, error) {
+		for _, id := range signers {
+			if id.Equal(identity) {
+				return &signer{id: id}, nil
+			}
+		}
+		return nil, errors.Errorf("no signer for [%s]", identity)
+	}
+	sigService.GetVerifierStub = func(identity view.Identity) (driver.Verifier, error) {
+		return &signer{id: identity}, nil
+	}
+	assert.NoError(t, registry.RegisterService(sigService))
+	assert.NoError(t, registry.RegisterService(&mock.EndpointService{}))
+	a := newAuthenticator(registry)
+	a.enabled = true
+	a.timeout = 200 * time.Millisecond
+	return a
+}
+
+func TestHandshake(t *testing.T) {
+	alice := view.Identity("alice")
+	bob := view.Identity("bob")
+	initiator := newTestAuthenticator(t, alice)
+	responder := newTestAuthenticator(t, bob)
+
+	i, r := newPipe()
+	done := make(chan error, 1)
+	go func() {
+		msg := <-r.Receive()
+		done <- responder.Respond(r, bob, alice, msg.Payload)
+	}()
+	assert.NoError(t, initiator.Initiate(i, alice, bob, "session", "context"))
+	assert.NoError(t, <-done)
+
+	// the session is now authenticated, but only for alice
+	assert.NoError(t, responder.CheckSession("session", alice))
+	assert.Error(t, responder.CheckSession("session", alice), "the authentication is consumed by the first check")
+	assert.Error(t, responder.CheckSession("another session", alice))
+}
+
+func TestHandshakeImpersonation(t *testing.T) {
+	alice := view.Identity("alice")
+	bob := view.Identity("bob")
+	charlie := view.Identity("charlie")
+	// the responder claims to be bob but can only sign as charlie
+	initiator := newTestAuthenticator(t, alice)
+	responder := newTestAuthenticator(t, charlie)
+
+	i, r := newPipe()
+	go func() {
+		msg := <-r.Receive()
+		hello := msg.Payload
+		_ = responder.Respond(r, charlie, alice, hello)
+	}()
+	err := initiator.Initiate(i, alice, bob, "session", "context")
+	assert.Error(t, err)
+	_, ok := err.(*HandshakeError)
+	assert.True(t, ok, "expected a handshake error, got [%T]", err)
+}
+
+func TestHandshakeLegacy(t *testing.T) {
+	alice := view.Identity("alice")
+	bob := view.Identity("bob")
+
+	// the remote end never answers
+	initiator := newTestAuthenticator(t, alice)
+	i, _ := newPipe()
+	err := initiator.Initiate(i, alice, bob, "session", "context")
+	_, ok := err.(*HandshakeError)
+	assert.True(t, ok, "legacy nodes must be rejected by default, got [%v]", err)
+
+	initiator.policy = AllowLegacy
+	i, _ = newPipe()
+	assert.NoError(t, initiator.Initiate(i, alice, bob, "session", "context"))
+
+	// a responder requiring the handshake, facing a legacy initiator
+	responder := newTestAuthenticator(t, bob)
+	_, ok = responder.CheckSession("session", alice).(*HandshakeError)
+	assert.True(t, ok)
+	responder.policy = AllowLegacy
+	assert.NoError(t, responder.CheckSession("session", alice))
+}
+
+func TestHandshakeRequired(t *testing.T) {
+	alice := view.Identity("alice")
+	bob := view.Identity("bob")
+
+	registry := registry2.New()
+	resolver := &mock.EndpointService{}
+	resolver.GetIdentityStub = func(label string, pkiID []byte) (view.Identity, error) {
+		if label == "alice" {
+			return alice, nil
+		}
+		return nil, errors.Errorf("endpoint [%s] not found", label)
+	}
+	assert.NoError(t, registry.RegisterService(resolver))
+	a := newAuthenticator(registry)
+	assert.False(t, a.Required(bob))
+
+	a.endpoints = []string{"alice"}
+	assert.True(t, a.Required(alice))
+	assert.False(t, a.Required(bob))
+
+	// an endpoint that cannot be resolved might be any party
+	a.endpoints = []string{"alice", "charlie"}
+	assert.True(t, a.Required(alice))
+	assert.True(t, a.Required(bob))
+}
@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"reflect"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+)
+
+// labelSeparator separates the label from the identifier it qualifies, in the keys of the labeled sessions
+const labelSeparator = "#"
+
+// unlabeled is the value of the label of the metrics of the sessions without label
+const unlabeled = "default"
+
+var sessionsOpenedOpts = metrics.CounterOpts{
+	Namespace:    "view",
+	Subsystem:    "sessions",
+	Name:         "opened",
+	Help:         "The number of sessions opened, by the initiators and to respond, per view and session label.",
+	LabelNames:   []string{"view", "label"},
+	StatsdFormat: "%{#fqname}.%{view}.%{label}",
+}
+
+// SessionMetrics counts the sessions of the flows
+type SessionMetrics struct {
+	Opened metrics.Counter
+}
+
+func NewSessionMetrics(p metrics.Provider) *SessionMetrics {
+	return &SessionMetrics{Opened: p.NewCounter(sessionsOpenedOpts)}
+}
+
+// newSessionMetrics returns the session metrics of the metrics provider registered in the passed service provider, if any
+func newSessionMetrics(sp driver.ServiceProvider) *SessionMetrics {
+	var p metrics.Provider = &disabled.Provider{}
+	if s, err := sp.GetService(reflect.TypeOf((*metrics.Provider)(nil))); err == nil {
+		p = s.(metrics.Provider)
+	}
+	return NewSessionMetrics(p)
+}
+
+// opened records a session opened by, or to respond to, the passed view
+func (m *SessionMetrics) opened(v string, label string) {
+	if m == nil {
+		return
+	}
+	if len(label) == 0 {
+		label = unlabeled
+	}
+	m.Opened.With("view", v, "label", label).Add(1)
+}
+
+// labeledSessions is implemented by the contexts opening labeled sessions on behalf of the passed caller view
+type labeledSessions interface {
+	getSession(caller view.View, party view.Identity, label string) (view.Session, error)
+}
+
+// RegisterResponderWithLabel binds a responder to the sessions opened by an initiator with the passed label.
+// The labeled sessions with no responder bound to their label are dispatched to the responder of the initiator.
+func (cm *manager) RegisterResponderWithLabel(responder view.View, initiatedBy interface{}, label string) error {
+	if len(label) == 0 {
+		return errors.Errorf("empty label")
+	}
+	switch t := initiatedBy.(type) {
+	case view.View:
+		cm.registerResponderWithIdentity(responder, nil, initiatorKey(cm.GetIdentifier(t), label))
+	case string:
+		cm.registerResponderWithIdentity(responder, nil, initiatorKey(t, label))
+	default:
+		return errors.Errorf("initiatedBy must be a view or a string")
+	}
+	return nil
+}
+
+// sessionKey is the key of the session to the passed party, with the passed label, in a context
+func sessionKey(party view.Identity, label string) string {
+	if len(label) == 0 {
+		return party.UniqueID()
+	}
+	return label + labelSeparator + party.UniqueID()
+}
+
+// initiatorKey is the key of the responder bound to the sessions opened by the passed initiator with the passed label
+func initiatorKey(initiatedByID, label string) string {
+	if len(label) == 0 {
+		return initiatedByID
+	}
+	return initiatedByID + labelSeparator + label
+}
+
+// contextKey is the key of the context responding to the sessions, with the passed label, of the passed flow
+func contextKey(contextID, label string) string {
+	if len(label) == 0 {
+		return contextID
+	}
+	return contextID + labelSeparator + label
+}
+
+// messageLabel returns the label of the session the passed message is sent on, empty if unlabeled
+func messageLabel(msg *view.Message) string {
+	if msg == nil {
+		return ""
+	}
+	return msg.Metadata[view.SessionLabelMetadata]
+}
@@ -0,0 +1,292 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/manager"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// bus connects the fake comm layers of the nodes, by name
+type bus struct {
+	lock  sync.Mutex
+	nodes map[string]*busNode
+}
+
+// deliver delivers the passed message on its session at the passed node,
+// or on the master session of the node if the session does not exist there
+func (b *bus) deliver(to string, msg *view.Message) error {
+	b.lock.Lock()
+	n, ok := b.nodes[to]
+	b.lock.Unlock()
+	if !ok {
+		return errors.Errorf("unknown node [%s]", to)
+	}
+	n.lock.Lock()
+	s, ok := n.sessions[msg.SessionID]
+	n.lock.Unlock()
+	if ok {
+		s.in <- msg
+		return nil
+	}
+	n.master.in <- msg
+	return nil
+}
+
+// busNode is a fake comm layer
+type busNode struct {
+	name     string
+	bus      *bus
+	lock     sync.Mutex
+	master   *busSession
+	sessions map[string]*busSession
+}
+
+func (b *bus) newNode(name string) *busNode {
+	n := &busNode{name: name, bus: b, sessions: map[string]*busSession{}}
+	n.master = &busSession{id: "master", in: make(chan *view.Message, 10)}
+	b.lock.Lock()
+	b.nodes[name] = n
+	b.lock.Unlock()
+	return n
+}
+
+func (n *busNode) NewSessionWithID(sessionID, contextID, endpoint string, pkid []byte, caller view.Identity, msg *view.Message) (view.Session, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	s, ok := n.sessions[sessionID]
+	if !ok {
+		s = &busSession{node: n, id: sessionID, contextID: contextID, to: endpoint, caller: caller, in: make(chan *view.Message, 10)}
+		n.sessions[sessionID] = s
+	}
+	if msg != nil {
+		s.label = msg.Metadata[view.SessionLabelMetadata]
+		s.in <- msg
+	}
+	return s, nil
+}
+
+func (n *busNode) NewSession(caller string, contextID string, endpoint string, pkid []byte) (view.Session, error) {
+	s, err := n.NewSessionWithID(manager.GenerateUUID(), contextID, endpoint, pkid, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.(*busSession).callerViewID = caller
+	return s, nil
+}
+
+func (n *busNode) MasterSession() (view.Session, error) {
+	return n.master, nil
+}
+
+func (n *busNode) DeleteSessions(sessionID string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.sessions, sessionID)
+}
+
+type busSession struct {
+	node         *busNode
+	id           string
+	contextID    string
+	to           string
+	caller       view.Identity
+	callerViewID string
+	in           chan *view.Message
+
+	lock     sync.Mutex
+	metadata map[string]string
+	label    string
+}
+
+func (s *busSession) Info() view.SessionInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return view.SessionInfo{ID: s.id, Caller: s.caller, CallerViewID: s.callerViewID, Endpoint: s.to, Label: s.label}
+}
+
+func (s *busSession) SetMetadata(metadata map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.metadata = metadata
+	s.label = metadata[view.SessionLabelMetadata]
+}
+
+func (s *busSession) Send(payload []byte) error {
+	return s.send(payload, view.OK)
+}
+
+func (s *busSession) SendError(payload []byte) error {
+	return s.send(payload, view.ERROR)
+}
+
+func (s *busSession) send(payload []byte, status int32) error {
+	s.lock.Lock()
+	metadata := s.metadata
+	s.lock.Unlock()
+	return s.node.bus.deliver(s.to, &view.Message{
+		SessionID:    s.id,
+		ContextID:    s.contextID,
+		Caller:       s.callerViewID,
+		FromEndpoint: s.node.name,
+		FromPKID:     []byte(s.node.name),
+		Status:       status,
+		Payload:      payload,
+		Metadata:     metadata,
+	})
+}
+
+func (s *busSession) Receive() <-chan *view.Message { return s.in }
+
+func (s *busSession) Close() {}
+
+// quoteView opens a session per label to the party and exchanges messages on all of them, interleaved
+type quoteView struct {
+	party  view.Identity
+	labels []string
+	rounds int
+}
+
+func (q *quoteView) Call(context view.Context) (interface{}, error) {
+	sessions := make([]view.Session, len(q.labels))
+	for i, label := range q.labels {
+		s, err := view.GetSessionWithLabel(context, q.party, label)
+		if err != nil {
+			return nil, err
+		}
+		sessions[i] = s
+	}
+	replies := map[string][]string{}
+	for round := 0; round < q.rounds; round++ {
+		for _, s := range sessions {
+			if err := s.Send([]byte(fmt.Sprintf("%d", round))); err != nil {
+				return nil, err
+			}
+		}
+		for i, s := range sessions {
+			msg, err := receive(s)
+			if err != nil {
+				return nil, err
+			}
+			replies[q.labels[i]] = append(replies[q.labels[i]], string(msg.Payload))
+		}
+	}
+	return replies, nil
+}
+
+// labeledResponder answers each message of its session with its name, the label of the session and the payload
+type labeledResponder struct {
+	name   string
+	rounds int
+}
+
+func (r *labeledResponder) Call(context view.Context) (interface{}, error) {
+	session := context.Session()
+	for round := 0; round < r.rounds; round++ {
+		msg, err := receive(session)
+		if err != nil {
+			return nil, err
+		}
+		if err := session.Send([]byte(r.name + ":" + session.Info().Label + ":" + string(msg.Payload))); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+type pricingResponder struct {
+	labeledResponder
+}
+
+func receive(session view.Session) (*view.Message, error) {
+	msg := <-session.Receive()
+	if msg.Status == view.ERROR {
+		return nil, errors.Errorf("received error [%s]", msg.Payload)
+	}
+	return msg, nil
+}
+
+func newLabelsManager(t *testing.T, b *bus, name string) interface {
+	Manager
+	Start(ctx context.Context)
+	RegisterResponder(responder view.View, initiatedBy interface{}) error
+	RegisterResponderWithLabel(responder view.View, initiatedBy interface{}, label string) error
+} {
+	registry := registry2.New()
+	idProvider := &mock.IdentityProvider{}
+	idProvider.DefaultIdentityReturns([]byte(name))
+	assert.NoError(t, registry.RegisterService(idProvider))
+	assert.NoError(t, registry.RegisterService(b.newNode(name)))
+	endpointService := &mock.EndpointService{}
+	endpointService.ResolveStub = func(party view.Identity) (view.Identity, map[driver.PortName]string, []byte, error) {
+		return party, map[driver.PortName]string{driver.P2PPort: string(party)}, []byte(party), nil
+	}
+	endpointService.GetIdentityStub = func(label string, pkiID []byte) (view.Identity, error) {
+		return []byte(label), nil
+	}
+	assert.NoError(t, registry.RegisterService(endpointService))
+	return manager.New(registry)
+}
+
+func TestLabeledSessions(t *testing.T) {
+	b := &bus{nodes: map[string]*busNode{}}
+	alice := newLabelsManager(t, b, "alice")
+	bob := newLabelsManager(t, b, "bob")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go alice.Start(ctx)
+	go bob.Start(ctx)
+
+	// the pricing sessions have their own responder, the others fall back to the responder of the initiator
+	const rounds = 5
+	assert.NoError(t, bob.RegisterResponderWithLabel(&pricingResponder{labeledResponder{name: "pricing", rounds: rounds}}, &quoteView{}, "pricing"))
+	assert.NoError(t, bob.RegisterResponder(&labeledResponder{name: "default", rounds: rounds}, &quoteView{}))
+
+	res, err := alice.InitiateView(&quoteView{party: []byte("bob"), labels: []string{"pricing", "settlement"}, rounds: rounds})
+	assert.NoError(t, err)
+	replies := res.(map[string][]string)
+	for round := 0; round < rounds; round++ {
+		assert.Equal(t, fmt.Sprintf("pricing:pricing:%d", round), replies["pricing"][round])
+		assert.Equal(t, fmt.Sprintf("default:settlement:%d", round), replies["settlement"][round])
+	}
+}
+
+func TestLabeledSessionsAreCached(t *testing.T) {
+	b := &bus{nodes: map[string]*busNode{}}
+	alice := newLabelsManager(t, b, "alice")
+	b.newNode("bob")
+
+	v := &quoteView{}
+	ctx, err := alice.(interface {
+		InitiateContext(view view.View) (view.Context, error)
+	}).InitiateContext(v)
+	assert.NoError(t, err)
+	pricing, err := view.GetSessionWithLabel(ctx, []byte("bob"), "pricing")
+	assert.NoError(t, err)
+	assert.Equal(t, "pricing", pricing.Info().Label)
+	settlement, err := view.GetSessionWithLabel(ctx, []byte("bob"), "settlement")
+	assert.NoError(t, err)
+	unlabeled, err := ctx.GetSession(v, []byte("bob"))
+	assert.NoError(t, err)
+	assert.Empty(t, unlabeled.Info().Label)
+
+	assert.NotEqual(t, pricing.Info().ID, settlement.Info().ID)
+	assert.NotEqual(t, pricing.Info().ID, unlabeled.Info().ID)
+	again, err := view.GetSessionWithLabel(ctx, []byte("bob"), "pricing")
+	assert.NoError(t, err)
+	assert.Equal(t, pricing.Info().ID, again.Info().ID)
+}
@@ -41,17 +41,49 @@ type manager struct {
 	views      map[string][]*viewEntry
 	initiators map[string]string
 	factories  map[string]driver.Factory
+
+	authenticator  *authenticator
+	checkpointer   *checkpointer
+	budgets        *budgets
+	latencies      *latencies
+	policies       *policies
+	sessionMetrics *SessionMetrics
+	flows          *flowTraces
+
+	recoverablesSync sync.RWMutex
+	recoverables     map[string]view.Recoverable
+	parkedSync       sync.RWMutex
+	parked           map[string]*parkedSession
 }
 
 func New(serviceProvider driver.ServiceProvider) *manager {
 	return &manager{
-		sp: serviceProvider,
+		sp:             serviceProvider,
+		authenticator:  newAuthenticator(serviceProvider),
+		checkpointer:   &checkpointer{sp: serviceProvider},
+		budgets:        newBudgets(serviceProvider),
+		latencies:      newLatencies(serviceProvider),
+		policies:       newPolicies(serviceProvider),
+		sessionMetrics: newSessionMetrics(serviceProvider),
+		flows:          newFlowTraces(serviceProvider),
 
 		contexts:   map[string]disposableContext{},
 		views:      map[string][]*viewEntry{},
 		initiators: map[string]string{},
 		factories:  map[string]driver.Factory{},
+
+		recoverables: map[string]view.Recoverable{},
+		parked:       map[string]*parkedSession{},
+	}
+}
+
+// Validate returns the error loading the configuration of the manager, if any.
+// With invalid policies, the manager refuses all the sessions until the configuration is fixed.
+func (cm *manager) Validate() error {
+	if cm.policies.err != nil {
+		return cm.policies.err
 	}
+	return cm.budgets.err
 }
 
 func (cm *manager) GetService(typ reflect.Type) (interface{}, error) {
@@ -101,6 +133,17 @@ func (cm *manager) RegisterResponderWithIdentity(responder view.View, id view.Id
 	return nil
 }
 
+// RegisterResponderWithPolicy binds a responder to an initiator.
+// The responder is instantiated only for the sessions satisfying the passed policy,
+// unless the configuration declares another policy for the responder.
+func (cm *manager) RegisterResponderWithPolicy(responder view.View, initiatedBy interface{}, policy *driver.ResponderPolicy) error {
+	if err := cm.RegisterResponder(responder, initiatedBy); err != nil {
+		return err
+	}
+	cm.policies.register(getIdentifier(responder), policy)
+	return nil
+}
+
 func (cm *manager) GetResponder(initiatedBy interface{}) (view.View, error) {
 	var initiatedByID string
 	switch t := initiatedBy.(type) {
@@ -170,34 +213,65 @@ func (cm *manager) InitiateView(view view.View) (interface{}, error) {
 }
 
 func (cm *manager) InitiateViewWithIdentity(view view.View, id view.Identity) (interface{}, error) {
-	// Create the context
-	cm.contextsSync.Lock()
-	ctx := cm.ctx
-	cm.contextsSync.Unlock()
-	if ctx == nil {
-		ctx = context.Background()
+	return cm.initiateView(view, id, nil)
+}
+
+// InitiateViewWithRouting invokes the passed view in a context carrying the passed routing, and returns its result
+func (cm *manager) InitiateViewWithRouting(view view.View, routing view.Routing) (interface{}, error) {
+	return cm.initiateView(view, cm.me(), &routing)
+}
+
+func (cm *manager) initiateView(view view.View, id view.Identity, routing *view.Routing) (interface{}, error) {
+	if err := cm.admit(); err != nil {
+		return nil, err
 	}
-	viewContext, err := NewContextForInitiator("", ctx, cm.sp, GetCommLayer(cm.sp), driver.GetEndpointService(cm.sp), id, view)
+	// Create the context
+	viewContext, err := NewContextForInitiator("", cm.initiatorContext(routing), cm.sp, GetCommLayer(cm.sp), driver.GetEndpointService(cm.sp), id, view)
 	if err != nil {
 		return nil, err
 	}
+	return cm.initiate(viewContext, view, id)
+}
+
+// initiate runs the passed view in the passed initiator context.
+// The flow can be checkpointed, the checkpoint is removed when the flow terminates.
+// The flow runs within the budget of the view, its latency is tracked against the objective of the view,
+// what it blocks on is tracked for its introspection.
+func (cm *manager) initiate(viewContext *ctx, v view.View, id view.Identity) (interface{}, error) {
+	viewContext.authenticator = cm.authenticator
+	viewContext.checkpointer = cm.checkpointer
+	viewContext.sessionMetrics = cm.sessionMetrics
+	budget, budgetContext := cm.budgets.start(getIdentifier(v), viewContext.ID(), viewContext.context)
+	defer budget.end()
+	latency, latencyContext := cm.latencies.start(getIdentifier(v), budgetContext)
+	defer latency.end()
+	trace, traceContext := cm.flows.start(viewContext.ID(), viewContext.ID(), getIdentifier(v), true, "", latencyContext)
+	defer cm.flows.end(trace)
+	viewContext.budget = budget
+	viewContext.trace = trace
+	viewContext.context = traceContext
 	childContext := &childContext{ParentContext: viewContext}
 	cm.contextsSync.Lock()
 	cm.contexts[childContext.ID()] = childContext
 	cm.contextsSync.Unlock()
 
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("[%s] InitiateView [view:%s], [ContextID:%s]", id, getIdentifier(view), childContext.ID())
+		logger.Debugf("[%s] InitiateView [view:%s], [ContextID:%s]", id, getIdentifier(v), childContext.ID())
+	}
+	res, err := budget.run(func() (interface{}, error) {
+		return childContext.RunView(v)
+	})
+	if _, ok := v.(view.Recoverable); ok {
+		cm.checkpointer.Delete(childContext.ID())
 	}
-	res, err := childContext.RunView(view)
 	if err != nil {
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("[%s] InitiateView [view:%s], [ContextID:%s] failed [%s]", id, getIdentifier(view), childContext.ID(), err)
+			logger.Debugf("[%s] InitiateView [view:%s], [ContextID:%s] failed [%s]", id, getIdentifier(v), childContext.ID(), err)
 		}
 		return nil, err
 	}
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("[%s] InitiateView [view:%s], [ContextID:%s] terminated", id, getIdentifier(view), childContext.ID())
+		logger.Debugf("[%s] InitiateView [view:%s], [ContextID:%s] terminated", id, getIdentifier(v), childContext.ID())
 	}
 	return res, nil
 }
@@ -211,20 +285,28 @@ func (cm *manager) InitiateContextWithIdentity(view view.View, id view.Identity)
 }
 
 func (cm *manager) InitiateContextWithIdentityAndID(view view.View, id view.Identity, contextID string) (view.Context, error) {
-	// Create the context
-	cm.contextsSync.Lock()
-	ctx := cm.ctx
-	cm.contextsSync.Unlock()
-	if ctx == nil {
-		ctx = context.Background()
+	return cm.initiateContext(view, id, contextID, nil)
+}
+
+// InitiateContextWithRouting initiates a new context, carrying the passed routing, for the passed view
+func (cm *manager) InitiateContextWithRouting(view view.View, routing view.Routing) (view.Context, error) {
+	return cm.initiateContext(view, cm.me(), "", &routing)
+}
+
+func (cm *manager) initiateContext(view view.View, id view.Identity, contextID string, routing *view.Routing) (view.Context, error) {
+	if err := cm.admit(); err != nil {
+		return nil, err
 	}
 	if id.IsNone() {
 		id = cm.me()
 	}
-	viewContext, err := NewContextForInitiator(contextID, ctx, cm.sp, GetCommLayer(cm.sp), driver.GetEndpointService(cm.sp), id, view)
+	// Create the context
+	viewContext, err := NewContextForInitiator(contextID, cm.initiatorContext(routing), cm.sp, GetCommLayer(cm.sp), driver.GetEndpointService(cm.sp), id, view)
 	if err != nil {
 		return nil, err
 	}
+	viewContext.authenticator = cm.authenticator
+	viewContext.sessionMetrics = cm.sessionMetrics
 	childContext := &childContext{ParentContext: viewContext}
 	cm.contextsSync.Lock()
 	cm.contexts[childContext.ID()] = childContext
@@ -237,6 +319,20 @@ func (cm *manager) InitiateContextWithIdentityAndID(view view.View, id view.Iden
 	return childContext, nil
 }
 
+// initiatorContext returns the context of the flows initiated by this node, carrying the passed routing, if any
+func (cm *manager) initiatorContext(routing *view.Routing) context.Context {
+	cm.contextsSync.Lock()
+	ctx := cm.ctx
+	cm.contextsSync.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if routing != nil {
+		ctx = view.WithRouting(ctx, *routing)
+	}
+	return ctx
+}
+
 func (cm *manager) Start(ctx context.Context) {
 	cm.contextsSync.Lock()
 	cm.ctx = ctx
@@ -245,6 +341,7 @@ func (cm *manager) Start(ctx context.Context) {
 	if err != nil {
 		return
 	}
+	cm.recoverFlows(ctx)
 	for {
 		ch := session.Receive()
 		select {
@@ -319,12 +416,15 @@ func (cm *manager) respond(responder view.View, id view.Identity, msg *view.Mess
 	}()
 
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("[%s] Respond [from:%s], [sessionID:%s], [contextID:%s], [view:%s]", id, msg.FromEndpoint, msg.SessionID, msg.ContextID, getIdentifier(responder))
+		logger.Debugf("[%s] Respond [from:%s], [sessionID:%s], [contextID:%s], [label:%s], [view:%s]", id, msg.FromEndpoint, msg.SessionID, msg.ContextID, messageLabel(msg), getIdentifier(responder))
 	}
 
 	// get context
 	var isNew bool
-	ctx, isNew, err = cm.newContext(id, msg)
+	var budget *flowBudget
+	var latency *flowLatency
+	var trace *flowTrace
+	ctx, budget, latency, trace, isNew, err = cm.newContext(responder, id, msg)
 	if err != nil {
 		return nil, nil, errors.WithMessagef(err, "failed getting context for [%s,%s,%v]", msg.ContextID, id, msg)
 	}
@@ -336,9 +436,14 @@ func (cm *manager) respond(responder view.View, id view.Identity, msg *view.Mess
 		// delete context at the end of the execution
 		res, err = func(ctx view.Context, responder view.View) (interface{}, error) {
 			defer func() {
-				cm.deleteContext(id, ctx.ID())
+				cm.deleteContext(id, contextKey(msg.ContextID, messageLabel(msg)))
 			}()
-			return ctx.RunView(responder)
+			defer budget.end()
+			defer latency.end()
+			defer cm.flows.end(trace)
+			return budget.run(func() (interface{}, error) {
+				return ctx.RunView(responder)
+			})
 		}(ctx, responder)
 	} else {
 		res, err = ctx.RunView(responder)
@@ -351,18 +456,25 @@ func (cm *manager) respond(responder view.View, id view.Identity, msg *view.Mess
 	return ctx, res, err
 }
 
-func (cm *manager) newContext(id view.Identity, msg *view.Message) (view.Context, bool, error) {
+// newContext returns the context to run the passed responder in.
+// If a new context is created, the returned budget, latency, and trace track the flow of the responder.
+func (cm *manager) newContext(responder view.View, id view.Identity, msg *view.Message) (view.Context, *flowBudget, *flowLatency, *flowTrace, bool, error) {
 	cm.contextsSync.Lock()
 	defer cm.contextsSync.Unlock()
 
 	isNew := false
+	var budget *flowBudget
+	var latency *flowLatency
+	var trace *flowTrace
 	caller, err := driver.GetEndpointService(cm.sp).GetIdentity(msg.FromEndpoint, msg.FromPKID)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, nil, nil, false, err
 	}
 
+	// the sessions with different labels of the same flow are responded to in distinct contexts
 	contextID := msg.ContextID
-	viewContext, ok := cm.contexts[contextID]
+	key := contextKey(contextID, messageLabel(msg))
+	viewContext, ok := cm.contexts[key]
 	if ok && viewContext.Session() != nil && viewContext.Session().Info().ID != msg.SessionID {
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf(
@@ -373,27 +485,44 @@ func (cm *manager) newContext(id view.Identity, msg *view.Message) (view.Context
 				viewContext.Session().Info().ID,
 			)
 		}
-		delete(cm.contexts, contextID)
+		delete(cm.contexts, key)
 		ok = false
 	}
 	if !ok {
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("[%s] Create new context to respond [contextID:%s]\n", id, msg.ContextID)
+			logger.Debugf("[%s] Create new context to respond [contextID:%s], [label:%s]\n", id, msg.ContextID, messageLabel(msg))
 		}
 		backend, err := GetCommLayer(cm.sp).NewSessionWithID(msg.SessionID, contextID, msg.FromEndpoint, msg.FromPKID, caller, msg)
 		if err != nil {
-			return nil, false, err
+			return nil, nil, nil, nil, false, err
 		}
 		ctx := cm.ctx
 		if ctx == nil {
 			ctx = context.Background()
 		}
+		// the responders follow the routing declared by the initiator
+		if routing, ok := view.RoutingFromMetadata(msg.Metadata); ok {
+			ctx = view.WithRouting(ctx, routing)
+		}
+		budget, ctx = cm.budgets.start(getIdentifier(responder), contextID, ctx)
+		latency, ctx = cm.latencies.start(getIdentifier(responder), ctx)
+		trace, ctx = cm.flows.start(key, contextID, getIdentifier(responder), false, msg.FromEndpoint, ctx)
 		newCtx, err := NewContext(ctx, cm.sp, contextID, GetCommLayer(cm.sp), driver.GetEndpointService(cm.sp), id, backend, caller)
 		if err != nil {
-			return nil, false, err
+			budget.end()
+			cm.flows.end(trace)
+			return nil, nil, nil, nil, false, err
 		}
+		trace.opened(backend, messageLabel(msg))
+		// the first message is delivered before the session is tracked
+		trace.Exchange(backend, view.SessionReceived, msg.Status, msg.Payload)
+		newCtx.authenticator = cm.authenticator
+		newCtx.budget = budget
+		newCtx.trace = trace
+		newCtx.sessionMetrics = cm.sessionMetrics
+		cm.sessionMetrics.opened(getIdentifier(responder), messageLabel(msg))
 		childContext := &childContext{ParentContext: newCtx}
-		cm.contexts[contextID] = childContext
+		cm.contexts[key] = childContext
 		viewContext = childContext
 		isNew = true
 	} else {
@@ -402,7 +531,7 @@ func (cm *manager) newContext(id view.Identity, msg *view.Message) (view.Context
 		}
 	}
 
-	return viewContext, isNew, nil
+	return viewContext, budget, latency, trace, isNew, nil
 }
 
 func (cm *manager) deleteContext(id view.Identity, contextID string) {
@@ -419,11 +548,34 @@ func (cm *manager) deleteContext(id view.Identity, contextID string) {
 	}
 }
 
+// existResponder returns the responder bound to the label of the session of the passed message, if any,
+// the responder of its caller otherwise
 func (cm *manager) existResponder(msg *view.Message) (view.View, view.Identity, error) {
+	if label := messageLabel(msg); len(label) != 0 {
+		if responder, id, err := cm.ExistResponderForCaller(initiatorKey(msg.Caller, label)); err == nil {
+			return responder, id, nil
+		}
+	}
 	return cm.ExistResponderForCaller(msg.Caller)
 }
 
 func (cm *manager) callView(msg *view.Message) {
+	if cm.park(msg) {
+		return
+	}
+	if msg.Caller == HandshakeCaller {
+		cm.handshake(msg)
+		return
+	}
+	if err := cm.checkSession(msg); err != nil {
+		logger.Errorf("[%s] rejecting session [%s]: [%s]", cm.me(), msg.String(), err)
+		return
+	}
+	if err := cm.admitSession(msg); err != nil {
+		logger.Warnf("[%s] rejecting session [%s]: [%s]", cm.me(), msg.String(), err)
+		return
+	}
+
 	responder, id, err := cm.existResponder(msg)
 	if err != nil {
 		// TODO: No responder exists for this message
@@ -431,6 +583,10 @@ func (cm *manager) callView(msg *view.Message) {
 		logger.Errorf("[%s] No responder exists for [%s]: [%s]", cm.me(), msg.String(), err)
 		return
 	}
+	if err := cm.checkPolicy(responder, msg); err != nil {
+		logger.Errorf("[%s] rejecting session [%s]: [%s]", cm.me(), msg.String(), err)
+		return
+	}
 	if id.IsNone() {
 		id = cm.me()
 	}
@@ -456,6 +612,67 @@ func (cm *manager) callView(msg *view.Message) {
 	}
 }
 
+// handshake answers the session handshake started by the remote end
+func (cm *manager) handshake(msg *view.Message) {
+	caller, err := driver.GetEndpointService(cm.sp).GetIdentity(msg.FromEndpoint, msg.FromPKID)
+	if err != nil {
+		if logger.IsEnabledFor(zapcore.DebugLevel) {
+			logger.Debugf("cannot resolve the identity of the handshake endpoint [%s]: [%s]", msg.FromEndpoint, err)
+		}
+		caller = nil
+	}
+	hs, err := GetCommLayer(cm.sp).NewSessionWithID(msg.SessionID, msg.ContextID, msg.FromEndpoint, msg.FromPKID, caller, nil)
+	if err != nil {
+		logger.Errorf("failed creating handshake session [%s]: [%s]", msg.String(), err)
+		return
+	}
+	defer GetCommLayer(cm.sp).DeleteSessions(msg.SessionID)
+
+	if err := cm.authenticator.Respond(hs, cm.me(), caller, msg.Payload); err != nil {
+		logger.Errorf("session handshake failed [%s]: [%s]", msg.String(), err)
+	}
+}
+
+// checkSession checks that the session, whose first message is passed, has been authenticated, if required.
+// The remote end is notified in case of failure.
+func (cm *manager) checkSession(msg *view.Message) error {
+	caller, err := driver.GetEndpointService(cm.sp).GetIdentity(msg.FromEndpoint, msg.FromPKID)
+	if err != nil {
+		caller = nil
+	}
+	if err := cm.authenticator.CheckSession(msg.SessionID, caller); err != nil {
+		cm.reject(msg, caller, err)
+		return err
+	}
+	return nil
+}
+
+// checkPolicy checks that the session, whose first message is passed, satisfies the policy of the passed responder.
+// The remote end is notified in case of failure.
+func (cm *manager) checkPolicy(responder view.View, msg *view.Message) error {
+	caller, err := driver.GetEndpointService(cm.sp).GetIdentity(msg.FromEndpoint, msg.FromPKID)
+	if err != nil {
+		caller = nil
+	}
+	if err := cm.policies.check(getIdentifier(responder), caller, msg); err != nil {
+		cm.reject(msg, caller, err)
+		return err
+	}
+	return nil
+}
+
+// reject notifies the remote end that the session, whose first message is passed, is refused
+func (cm *manager) reject(msg *view.Message, caller view.Identity, cause error) {
+	session, err := GetCommLayer(cm.sp).NewSessionWithID(msg.SessionID, msg.ContextID, msg.FromEndpoint, msg.FromPKID, caller, nil)
+	if err != nil {
+		return
+	}
+	if err := session.SendError([]byte(cause.Error())); err != nil {
+		logger.Errorf("failed notifying session rejection [%s]", err)
+	}
+	GetCommLayer(cm.sp).DeleteSessions(msg.SessionID)
+}
+
 func (cm *manager) me() view.Identity {
 	return driver.GetIdentityProvider(cm.sp).DefaultIdentity()
 }
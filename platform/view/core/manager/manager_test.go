@@ -133,6 +133,41 @@ func TestRegisterResponderWithViewIdentifier(t *testing.T) {
 	assert.Equal(t, "pineapple", res)
 }
 
+type SchemaFactory struct{}
+
+func (s *SchemaFactory) NewView(in []byte) (view.View, error) {
+	return &InitiatorView{}, nil
+}
+
+func (s *SchemaFactory) InputSchema() []byte {
+	return []byte(`{"type":"object"}`)
+}
+
+func TestRegistrations(t *testing.T) {
+	registry := registry2.New()
+	idProvider := &mock.IdentityProvider{}
+	idProvider.DefaultIdentityReturns([]byte("alice"))
+	assert.NoError(t, registry.RegisterService(idProvider))
+	assert.NoError(t, registry.RegisterService(&mock2.CommLayer{}))
+	assert.NoError(t, registry.RegisterService(&mock.EndpointService{}))
+	assert.NoError(t, registry.RegisterService(&mock2.SessionFactory{}))
+
+	manager := manager.New(registry)
+	assert.NoError(t, manager.RegisterFactory("transfer", &SchemaFactory{}))
+	assert.NoError(t, manager.RegisterFactory("dummy", &DummyFactory{}))
+	assert.NoError(t, manager.RegisterResponder(&ResponderView{}, &InitiatorView{}))
+	assert.NoError(t, manager.RegisterResponderWithIdentity(&ResponderView{}, []byte("bob"), "other"))
+
+	responder, initiator := manager.GetIdentifier(&ResponderView{}), manager.GetIdentifier(&InitiatorView{})
+	assert.Equal(t, &driver.Registrations{
+		Factories: []driver.FactoryRegistration{{ID: "dummy"}, {ID: "transfer", InputSchema: []byte(`{"type":"object"}`)}},
+		Responders: []driver.ResponderRegistration{
+			{View: responder, InitiatedBy: []string{initiator, "other"}},
+			{View: responder, InitiatedBy: []string{initiator, "other"}, Identity: view.Identity("bob").UniqueID()},
+		},
+	}, manager.Registrations())
+}
+
 func registerFactory(t *testing.T, wg *sync.WaitGroup, m Manager) {
 	err := m.RegisterFactory(manager.GenerateUUID(), &DummyFactory{})
 	wg.Done()
@@ -0,0 +1,218 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+)
+
+// ErrPermissionDenied is matched, with errors.Is, by the errors of the sessions refused by the policy of their responder
+var ErrPermissionDenied = errors.New("PERMISSION_DENIED")
+
+// PermissionDeniedError tells why the policy of a responder refused a session
+type PermissionDeniedError struct {
+	Responder string
+	// Caller is the view identifier declared by the initiator of the session
+	Caller string
+	Reason string
+}
+
+func (e *PermissionDeniedError) Error() string {
+	return fmt.Sprintf("PERMISSION_DENIED: responder [%s] refused the session initiated by [%s], %s", e.Responder, e.Caller, e.Reason)
+}
+
+func (e *PermissionDeniedError) Is(target error) bool {
+	return target == ErrPermissionDenied
+}
+
+type viewPolicy struct {
+	View        string
+	Initiators  []string
+	MSPs        []string
+	Networks    []string
+	Channels    []string
+	Metadata    map[string]string
+	TrustDomain string
+}
+
+// policies holds the dispatch policies of the responders, by view identifier
+type policies struct {
+	sp driver.ServiceProvider
+
+	lock sync.RWMutex
+	// registered are the policies declared at registration
+	registered map[string]*driver.ResponderPolicy
+	// configured are the policies declared in the configuration, they take precedence
+	configured map[string]*driver.ResponderPolicy
+	// err is the error loading the configured policies, all the sessions are refused if set
+	err error
+}
+
+// newPolicies loads the policies of the responders from the key fsc.views.policies,
+// listing the policies by responder view identifier, each with the keys initiators, msps, networks, channels, metadata, and trustDomain.
+// A policy in the configuration replaces the one declared at the registration of the responder.
+// If the policies cannot be loaded, the sessions of all the responders are refused.
+func newPolicies(sp driver.ServiceProvider) *policies {
+	p := &policies{
+		sp:         sp,
+		registered: map[string]*driver.ResponderPolicy{},
+		configured: map[string]*driver.ResponderPolicy{},
+	}
+	s, err := sp.GetService(reflect.TypeOf((*driver.ConfigService)(nil)))
+	if err != nil {
+		return p
+	}
+	cs := s.(driver.ConfigService)
+	if !cs.IsSet("fsc.views.policies") {
+		return p
+	}
+	var config []viewPolicy
+	if err := cs.UnmarshalKey("fsc.views.policies", &config); err != nil {
+		p.err = errors.Wrapf(err, "failed loading the policies of the responders from fsc.views.policies")
+		logger.Errorf("%s, all the sessions are refused", p.err)
+		return p
+	}
+	for _, c := range config {
+		p.configured[c.View] = &driver.ResponderPolicy{
+			Initiators:  c.Initiators,
+			MSPs:        c.MSPs,
+			Networks:    c.Networks,
+			Channels:    c.Channels,
+			Metadata:    c.Metadata,
+			TrustDomain: c.TrustDomain,
+		}
+	}
+	return p
+}
+
+// register sets the policy declared at the registration of the passed responder, nil removes it
+func (p *policies) register(responder string, policy *driver.ResponderPolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if policy == nil {
+		delete(p.registered, responder)
+		return
+	}
+	p.registered[responder] = policy
+}
+
+// policy returns the policy in force for the passed responder, nil if none
+func (p *policies) policy(responder string) *driver.ResponderPolicy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if policy, ok := p.configured[responder]; ok {
+		return policy
+	}
+	return p.registered[responder]
+}
+
+// check returns a PermissionDeniedError if the session, whose first message is passed, does not satisfy the policy
+// of the passed responder. The caller is the identity that opened the session, nil if unknown.
+func (p *policies) check(responder string, caller view.Identity, msg *view.Message) error {
+	deny := func(format string, args ...interface{}) error {
+		return &PermissionDeniedError{Responder: responder, Caller: msg.Caller, Reason: fmt.Sprintf(format, args...)}
+	}
+	if p.err != nil {
+		return deny("%s", p.err)
+	}
+	policy := p.policy(responder)
+	if policy == nil {
+		return nil
+	}
+
+	if len(policy.TrustDomain) != 0 {
+		if err := p.verifyTrust(policy.TrustDomain, caller); err != nil {
+			return deny("identity [%s] of endpoint [%s] not trusted: %s", caller, msg.FromEndpoint, err)
+		}
+	}
+	if len(policy.Initiators) != 0 && !p.isInitiator(policy.Initiators, caller) {
+		return deny("identity [%s] of endpoint [%s] not allowed", caller, msg.FromEndpoint)
+	}
+	if len(policy.MSPs) != 0 {
+		mspID := mspIdentifier(caller)
+		if !contains(policy.MSPs, mspID) {
+			return deny("msp [%s] of endpoint [%s] not allowed", mspID, msg.FromEndpoint)
+		}
+	}
+	if network := msg.Metadata[view.SessionNetworkMetadata]; len(policy.Networks) != 0 && !contains(policy.Networks, network) {
+		return deny("network [%s] not allowed", network)
+	}
+	if channel := msg.Metadata[view.SessionChannelMetadata]; len(policy.Channels) != 0 && !contains(policy.Channels, channel) {
+		return deny("channel [%s] not allowed", channel)
+	}
+	keys := make([]string, 0, len(policy.Metadata))
+	for key := range policy.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value, ok := msg.Metadata[key]; !ok || value != policy.Metadata[key] {
+			return deny("metadata [%s] is [%s], expected [%s]", key, value, policy.Metadata[key])
+		}
+	}
+	return nil
+}
+
+// verifyTrust returns nil if the passed identity is certified by the anchors of the passed trust domain.
+// It fails if the node has no trust domains.
+func (p *policies) verifyTrust(domain string, caller view.Identity) error {
+	if caller.IsNone() {
+		return errors.New("unknown identity")
+	}
+	trustDomains := driver.GetTrustDomains(p.sp)
+	if trustDomains == nil {
+		return errors.Errorf("no trust domain configured, [%s] expected", domain)
+	}
+	return trustDomains.Verify(domain, caller)
+}
+
+// isInitiator returns true if the passed identity is one of the passed initiators
+func (p *policies) isInitiator(initiators []string, caller view.Identity) bool {
+	if caller.IsNone() {
+		return false
+	}
+	resolver := driver.GetEndpointService(p.sp)
+	for _, initiator := range initiators {
+		if caller.UniqueID() == initiator {
+			return true
+		}
+		if id, err := resolver.GetIdentity(initiator, nil); err == nil && caller.Equal(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// mspIdentifier returns the identifier of the MSP of the passed identity, empty if it is not an MSP serialized identity
+func mspIdentifier(id view.Identity) string {
+	if id.IsNone() {
+		return ""
+	}
+	si := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(id, si); err != nil {
+		return ""
+	}
+	return si.Mspid
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
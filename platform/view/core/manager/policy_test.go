@@ -0,0 +1,283 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// rejections is a comm layer recording the errors sent to the initiators
+type rejections struct {
+	sessions
+	errors []string
+}
+
+func (r *rejections) NewSessionWithID(sessionID, contextID, endpoint string, pkid []byte, caller view.Identity, msg *view.Message) (view.Session, error) {
+	return &rejectionSession{pipeSession: &pipeSession{in: make(chan *view.Message, 10)}, rejections: r}, nil
+}
+
+type rejectionSession struct {
+	*pipeSession
+	rejections *rejections
+}
+
+func (s *rejectionSession) SendError(payload []byte) error {
+	s.rejections.errors = append(s.rejections.errors, string(payload))
+	return nil
+}
+
+type internalInitiator struct{}
+
+func (internalInitiator) Call(context view.Context) (interface{}, error) {
+	return nil, nil
+}
+
+// internalResponder tells when it runs
+type internalResponder struct {
+	ran chan view.Identity
+}
+
+func (r *internalResponder) Call(context view.Context) (interface{}, error) {
+	r.ran <- context.Session().Info().Caller
+	return nil, nil
+}
+
+func newPolicyManager(t *testing.T, configPath string) (*manager, *rejections) {
+	registry := registry2.New()
+	idProvider := &mock.IdentityProvider{}
+	idProvider.DefaultIdentityReturns([]byte("alice"))
+	assert.NoError(t, registry.RegisterService(idProvider))
+	comm := &rejections{}
+	assert.NoError(t, registry.RegisterService(comm))
+	org1, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: []byte("dave")})
+	assert.NoError(t, err)
+	identities := map[string]view.Identity{"bob": []byte("bob"), "charlie": []byte("charlie"), "mallory": []byte("mallory"), "dave": org1}
+	resolver := &mock.EndpointService{}
+	resolver.GetIdentityStub = func(label string, pkiID []byte) (view.Identity, error) {
+		if id, ok := identities[label]; ok {
+			return id, nil
+		}
+		return nil, errors.Errorf("unknown endpoint [%s]", label)
+	}
+	assert.NoError(t, registry.RegisterService(resolver))
+	if len(configPath) != 0 {
+		cp, err := config.NewProvider(configPath)
+		assert.NoError(t, err)
+		assert.NoError(t, registry.RegisterService(cp))
+	}
+	return New(registry), comm
+}
+
+func sessionFrom(endpoint, caller string, metadata map[string]string) *view.Message {
+	return &view.Message{
+		SessionID:    "session-" + endpoint,
+		ContextID:    "context-" + endpoint,
+		Caller:       caller,
+		FromEndpoint: endpoint,
+		FromPKID:     []byte(endpoint),
+		Status:       view.OK,
+		Metadata:     metadata,
+	}
+}
+
+// assertDispatch checks whether the session of the passed message wakes the responder
+func assertDispatch(t *testing.T, m *manager, comm *rejections, responder *internalResponder, msg *view.Message, allowed bool) {
+	rejected := len(comm.errors)
+	m.callView(msg)
+	if allowed {
+		assert.Len(t, comm.errors, rejected, "session from [%s] rejected", msg.FromEndpoint)
+		assert.Len(t, responder.ran, 1, "responder not run for [%s]", msg.FromEndpoint)
+		<-responder.ran
+		return
+	}
+	assert.Len(t, responder.ran, 0, "responder run for [%s]", msg.FromEndpoint)
+	if assert.Len(t, comm.errors, rejected+1, "session from [%s] not rejected", msg.FromEndpoint) {
+		assert.True(t, strings.HasPrefix(comm.errors[rejected], "PERMISSION_DENIED"), comm.errors[rejected])
+	}
+}
+
+func TestResponderPolicyInitiators(t *testing.T) {
+	m, comm := newPolicyManager(t, "")
+	responder := &internalResponder{ran: make(chan view.Identity, 1)}
+	initiator := getIdentifier(&internalInitiator{})
+	assert.NoError(t, m.RegisterResponderWithPolicy(responder, &internalInitiator{}, &driver.ResponderPolicy{Initiators: []string{"bob"}}))
+
+	// mallory declares the view identifier of the internal initiator, the policy still refuses her
+	assertDispatch(t, m, comm, responder, sessionFrom("mallory", initiator, nil), false)
+	assert.Contains(t, comm.errors[0], "of endpoint [mallory] not allowed")
+	// an endpoint the node cannot resolve is refused as well
+	assertDispatch(t, m, comm, responder, sessionFrom("eve", initiator, nil), false)
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, nil), true)
+
+	// the initiators can be given by unique id
+	assert.NoError(t, m.RegisterResponderWithPolicy(responder, &internalInitiator{}, &driver.ResponderPolicy{Initiators: []string{view.Identity("charlie").UniqueID()}}))
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, nil), false)
+	assertDispatch(t, m, comm, responder, sessionFrom("charlie", initiator, nil), true)
+
+	// no policy, no constraint
+	assert.NoError(t, m.RegisterResponderWithPolicy(responder, &internalInitiator{}, nil))
+	assertDispatch(t, m, comm, responder, sessionFrom("mallory", initiator, nil), true)
+}
+
+func TestResponderPolicyMSPsAndMetadata(t *testing.T) {
+	m, comm := newPolicyManager(t, "")
+	responder := &internalResponder{ran: make(chan view.Identity, 1)}
+	initiator := getIdentifier(&internalInitiator{})
+	assert.NoError(t, m.RegisterResponderWithPolicy(responder, initiator, &driver.ResponderPolicy{
+		MSPs:     []string{"Org1MSP"},
+		Networks: []string{"default"},
+		Channels: []string{"testchannel"},
+		Metadata: map[string]string{"role": "auditor"},
+	}))
+
+	metadata := map[string]string{view.SessionNetworkMetadata: "default", view.SessionChannelMetadata: "testchannel", "role": "auditor"}
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, metadata), false)
+	assert.Contains(t, comm.errors[0], "msp [] of endpoint [bob] not allowed")
+	assertDispatch(t, m, comm, responder, sessionFrom("dave", initiator, nil), false)
+	assert.Contains(t, comm.errors[1], "network [] not allowed")
+	assertDispatch(t, m, comm, responder, sessionFrom("dave", initiator, map[string]string{view.SessionNetworkMetadata: "default", view.SessionChannelMetadata: "other"}), false)
+	assert.Contains(t, comm.errors[2], "channel [other] not allowed")
+	assertDispatch(t, m, comm, responder, sessionFrom("dave", initiator, map[string]string{view.SessionNetworkMetadata: "default", view.SessionChannelMetadata: "testchannel", "role": "endorser"}), false)
+	assert.Contains(t, comm.errors[3], "metadata [role] is [endorser], expected [auditor]")
+	assertDispatch(t, m, comm, responder, sessionFrom("dave", initiator, metadata), true)
+
+	registrations := m.Registrations()
+	assert.Len(t, registrations.Responders, 1)
+	assert.Equal(t, []string{"Org1MSP"}, registrations.Responders[0].Policy.MSPs)
+}
+
+func TestResponderPolicyConfig(t *testing.T) {
+	responderID := getIdentifier(&internalResponder{})
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "core.yaml"), []byte(`
+fsc:
+  views:
+    policies:
+      - view: `+responderID+`
+        initiators: [charlie]
+`), 0644))
+	m, comm := newPolicyManager(t, dir)
+	responder := &internalResponder{ran: make(chan view.Identity, 1)}
+	initiator := getIdentifier(&internalInitiator{})
+	assert.NoError(t, m.RegisterResponderWithPolicy(responder, initiator, &driver.ResponderPolicy{Initiators: []string{"bob"}}))
+
+	// the configuration takes precedence over the policy declared at registration
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, nil), false)
+	assertDispatch(t, m, comm, responder, sessionFrom("charlie", initiator, nil), true)
+	assert.True(t, errors.Is(m.policies.check(responderID, view.Identity("bob"), sessionFrom("bob", initiator, nil)), ErrPermissionDenied))
+}
+
+// trustDomains trusts, by domain, the listed identities
+type trustDomains map[string][]string
+
+func (d trustDomains) Verify(domain string, identity view.Identity) error {
+	trusted, ok := d[domain]
+	if !ok {
+		return errors.Errorf("unknown trust domain [%s]", domain)
+	}
+	if !contains(trusted, string(identity)) {
+		return errors.Errorf("[%s] not trusted by [%s]", identity, domain)
+	}
+	return nil
+}
+
+// trustedView opens sessions only with the parties trusted by its domain
+type trustedView struct {
+	domain string
+}
+
+func (v *trustedView) Call(context view.Context) (interface{}, error) {
+	return nil, nil
+}
+
+func (v *trustedView) SessionTrustDomain() string {
+	return v.domain
+}
+
+func TestResponderPolicyTrustDomain(t *testing.T) {
+	m, comm := newPolicyManager(t, "")
+	responder := &internalResponder{ran: make(chan view.Identity, 1)}
+	initiator := getIdentifier(&internalInitiator{})
+	assert.NoError(t, m.RegisterResponderWithPolicy(responder, &internalInitiator{}, &driver.ResponderPolicy{TrustDomain: "network-a"}))
+
+	// no trust domain configured, nobody is trusted
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, nil), false)
+
+	assert.NoError(t, m.sp.(interface{ RegisterService(interface{}) error }).RegisterService(trustDomains{"network-a": {"bob"}, "network-b": {"charlie"}}))
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, nil), true)
+	// charlie is trusted by another domain only
+	assertDispatch(t, m, comm, responder, sessionFrom("charlie", initiator, nil), false)
+	assertDispatch(t, m, comm, responder, sessionFrom("mallory", initiator, nil), false)
+
+	// a domain not configured trusts nobody
+	assert.NoError(t, m.RegisterResponderWithPolicy(responder, &internalInitiator{}, &driver.ResponderPolicy{TrustDomain: "network-c"}))
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, nil), false)
+}
+
+func TestSessionTrustDomain(t *testing.T) {
+	registry := registry2.New()
+	resolver := &mock.EndpointService{}
+	resolver.ResolveStub = func(party view.Identity) (view.Identity, map[driver.PortName]string, []byte, error) {
+		return party, map[driver.PortName]string{driver.P2PPort: string(party)}, party, nil
+	}
+	assert.NoError(t, registry.RegisterService(resolver))
+	ctx, err := NewContext(nil, registry, "context", &sessions{}, resolver, []byte("alice"), nil, nil)
+	assert.NoError(t, err)
+
+	// no trust domain configured, the views declaring one cannot open sessions
+	_, err = ctx.GetSession(&trustedView{domain: "network-a"}, []byte("bob"))
+	assert.Error(t, err)
+	_, err = ctx.GetSession(&trustedView{}, []byte("bob"))
+	assert.NoError(t, err)
+	_, err = ctx.GetSession(&trustedView{}, []byte("charlie"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, registry.RegisterService(trustDomains{"network-a": {"bob"}, "network-b": {"charlie"}}))
+	_, err = ctx.GetSession(&trustedView{domain: "network-a"}, []byte("bob"))
+	assert.NoError(t, err)
+	// the session already open with charlie is not reused either
+	_, err = ctx.GetSession(&trustedView{domain: "network-a"}, []byte("charlie"))
+	assert.Error(t, err)
+	_, err = ctx.GetSession(&trustedView{domain: "network-b"}, []byte("charlie"))
+	assert.NoError(t, err)
+	_, err = ctx.GetSession(&trustedView{domain: "network-c"}, []byte("bob"))
+	assert.Error(t, err)
+}
+
+func TestResponderPolicyConfigError(t *testing.T) {
+	responderID := getIdentifier(&internalResponder{})
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "core.yaml"), []byte(`
+fsc:
+  views:
+    policies:
+      - view: `+responderID+`
+        metadata: [network]
+`), 0644))
+	m, comm := newPolicyManager(t, dir)
+	assert.Error(t, m.Validate())
+	responder := &internalResponder{ran: make(chan view.Identity, 1)}
+	initiator := getIdentifier(&internalInitiator{})
+	assert.NoError(t, m.RegisterResponder(responder, initiator))
+
+	// an invalid configuration fails closed, the responders without a policy included
+	assertDispatch(t, m, comm, responder, sessionFrom("bob", initiator, nil), false)
+	assert.Contains(t, comm.errors[0], "failed loading the policies of the responders")
+}
@@ -0,0 +1,328 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	checkpointPrefix = "fsc.view.checkpoint"
+	// parkedMessages is the maximum number of messages parked per session
+	parkedMessages = 100
+)
+
+// sessionCheckpoint describes a session opened by a checkpointed flow
+type sessionCheckpoint struct {
+	// Key is the key under which the session is stored in the context
+	Key      string
+	ID       string
+	Endpoint string
+	PKID     []byte
+	Caller   view.Identity
+	// Label is the label of the session, if any, the messages sent after the resume carry it again
+	Label string `json:",omitempty"`
+}
+
+// flowCheckpoint is what is stored in the KVS, keyed by flow (context) id, at each checkpoint
+type flowCheckpoint struct {
+	ContextID string
+	ViewID    string
+	Me        view.Identity
+	State     []byte
+	Sessions  []sessionCheckpoint
+}
+
+// checkpointer stores the flow checkpoints in the KVS
+type checkpointer struct {
+	sp driver.ServiceProvider
+}
+
+func (c *checkpointer) kvs() (*kvs.KVS, error) {
+	s, err := c.sp.GetService(reflect.TypeOf((*kvs.KVS)(nil)))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "no kvs available to store checkpoints")
+	}
+	return s.(*kvs.KVS), nil
+}
+
+func (c *checkpointer) Store(cp *flowCheckpoint) error {
+	kvss, err := c.kvs()
+	if err != nil {
+		return err
+	}
+	k, err := kvs.CreateCompositeKey(checkpointPrefix, []string{cp.ContextID})
+	if err != nil {
+		return errors.WithMessagef(err, "failed creating checkpoint key for [%s]", cp.ContextID)
+	}
+	if err := kvss.Put(k, cp); err != nil {
+		return errors.WithMessagef(err, "failed storing checkpoint for [%s]", cp.ContextID)
+	}
+	return nil
+}
+
+func (c *checkpointer) Delete(contextID string) {
+	kvss, err := c.kvs()
+	if err != nil {
+		return
+	}
+	k, err := kvs.CreateCompositeKey(checkpointPrefix, []string{contextID})
+	if err != nil {
+		return
+	}
+	if !kvss.Exists(k) {
+		return
+	}
+	if err := kvss.Delete(k); err != nil {
+		logger.Errorf("failed deleting checkpoint for [%s]: [%s]", contextID, err)
+	}
+}
+
+func (c *checkpointer) List() ([]*flowCheckpoint, error) {
+	kvss, err := c.kvs()
+	if err != nil {
+		logger.Debugf("checkpoints not available: [%s]", err)
+		return nil, nil
+	}
+	it, err := kvss.GetByPartialCompositeID(checkpointPrefix, []string{})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed listing checkpoints")
+	}
+	defer it.Close()
+	var res []*flowCheckpoint
+	for it.HasNext() {
+		cp := &flowCheckpoint{}
+		if _, err := it.Next(cp); err != nil {
+			return nil, errors.WithMessagef(err, "failed loading checkpoint")
+		}
+		res = append(res, cp)
+	}
+	return res, nil
+}
+
+// Checkpoint stores the passed state, together with the sessions opened so far, as the last safe point of this flow
+func (ctx *ctx) Checkpoint(state interface{}) error {
+	if ctx.checkpointer == nil {
+		return errors.Errorf("context [%s] does not support checkpoints", ctx.id)
+	}
+	if _, ok := ctx.initiator.(view.Recoverable); !ok {
+		return errors.Errorf("view [%s] is not recoverable", getIdentifier(ctx.initiator))
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling state of [%s]", ctx.id)
+	}
+
+	cp := &flowCheckpoint{
+		ContextID: ctx.id,
+		ViewID:    getIdentifier(ctx.initiator),
+		Me:        ctx.me,
+		State:     raw,
+	}
+	ctx.sessionsLock.RLock()
+	for key, s := range ctx.sessions {
+		info := s.Info()
+		cp.Sessions = append(cp.Sessions, sessionCheckpoint{
+			Key:      key,
+			ID:       info.ID,
+			Endpoint: info.Endpoint,
+			PKID:     info.EndpointPKID,
+			Caller:   info.Caller,
+			Label:    info.Label,
+		})
+	}
+	ctx.sessionsLock.RUnlock()
+
+	return ctx.checkpointer.Store(cp)
+}
+
+// parkedSession buffers the messages received, on the master session, for a session of a checkpointed flow.
+// This happens when the counterparty answers while the flow has not been resumed yet.
+type parkedSession struct {
+	messages chan *view.Message
+	// done is closed when the flow terminates
+	done chan struct{}
+}
+
+func newParkedSession() *parkedSession {
+	return &parkedSession{
+		messages: make(chan *view.Message, parkedMessages),
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *parkedSession) push(msg *view.Message) {
+	select {
+	case p.messages <- msg:
+	default:
+		logger.Warnf("too many parked messages for session [%s], dropping", msg.SessionID)
+	}
+}
+
+// resumedSession is a session re-attached to a resumed flow.
+// It delivers the parked messages together with those received on the re-attached session.
+type resumedSession struct {
+	view.Session
+	incoming chan *view.Message
+}
+
+func newResumedSession(s view.Session, parked *parkedSession) *resumedSession {
+	rs := &resumedSession{Session: s, incoming: make(chan *view.Message)}
+	go func() {
+		for {
+			var msg *view.Message
+			select {
+			case msg = <-parked.messages:
+			case msg = <-s.Receive():
+			case <-parked.done:
+				return
+			}
+			select {
+			case rs.incoming <- msg:
+			case <-parked.done:
+				return
+			}
+		}
+	}()
+	return rs
+}
+
+func (s *resumedSession) Receive() <-chan *view.Message {
+	return s.incoming
+}
+
+// EnableHeartbeats enables the heartbeats on the re-attached session, if it supports them
+func (s *resumedSession) EnableHeartbeats(interval time.Duration, misses int) error {
+	hs, ok := s.Session.(view.HeartbeatSession)
+	if !ok {
+		return errors.Errorf("session [%s] does not support heartbeats", s.Info().ID)
+	}
+	return hs.EnableHeartbeats(interval, misses)
+}
+
+// PeerVersion returns the version of the remote end of the re-attached session, if it tells it
+func (s *resumedSession) PeerVersion() version.Version {
+	vs, ok := s.Session.(view.VersionSession)
+	if !ok {
+		return version.Unknown
+	}
+	return vs.PeerVersion()
+}
+
+func (cm *manager) RegisterRecoverable(prototype view.Recoverable) error {
+	cm.recoverablesSync.Lock()
+	defer cm.recoverablesSync.Unlock()
+	cm.recoverables[getIdentifier(prototype)] = prototype
+	return nil
+}
+
+// park buffers the passed message if it belongs to a session of a checkpointed flow
+func (cm *manager) park(msg *view.Message) bool {
+	cm.parkedSync.RLock()
+	parked, ok := cm.parked[msg.SessionID]
+	cm.parkedSync.RUnlock()
+	if !ok {
+		return false
+	}
+	if logger.IsEnabledFor(zapcore.DebugLevel) {
+		logger.Debugf("park message for session [%s] of a checkpointed flow", msg.SessionID)
+	}
+	parked.push(msg)
+	return true
+}
+
+func (cm *manager) unpark(cp *flowCheckpoint) {
+	cm.parkedSync.Lock()
+	defer cm.parkedSync.Unlock()
+	for _, s := range cp.Sessions {
+		if parked, ok := cm.parked[s.ID]; ok {
+			close(parked.done)
+			delete(cm.parked, s.ID)
+		}
+	}
+}
+
+// recoverFlows resumes the flows interrupted by a restart.
+// The sessions of these flows are parked before returning.
+func (cm *manager) recoverFlows(ctx context.Context) {
+	cps, err := cm.checkpointer.List()
+	if err != nil {
+		logger.Errorf("failed loading flow checkpoints: [%s]", err)
+		return
+	}
+	cm.parkedSync.Lock()
+	for _, cp := range cps {
+		for _, s := range cp.Sessions {
+			cm.parked[s.ID] = newParkedSession()
+		}
+	}
+	cm.parkedSync.Unlock()
+
+	for _, cp := range cps {
+		go cm.resume(ctx, cp)
+	}
+}
+
+func (cm *manager) resume(ctx context.Context, cp *flowCheckpoint) {
+	defer cm.unpark(cp)
+
+	cm.recoverablesSync.RLock()
+	prototype, ok := cm.recoverables[cp.ViewID]
+	cm.recoverablesSync.RUnlock()
+	if !ok {
+		logger.Errorf("cannot resume flow [%s], no recoverable view registered for [%s]", cp.ContextID, cp.ViewID)
+		cm.checkpointer.Delete(cp.ContextID)
+		return
+	}
+	v, err := prototype.Resume(cp.State)
+	if err != nil {
+		logger.Errorf("failed resuming flow [%s] of [%s]: [%s]", cp.ContextID, cp.ViewID, err)
+		cm.checkpointer.Delete(cp.ContextID)
+		return
+	}
+
+	logger.Infof("resuming flow [%s] of [%s] from its last checkpoint", cp.ContextID, cp.ViewID)
+	viewContext, err := NewContextForInitiator(cp.ContextID, ctx, cm.sp, GetCommLayer(cm.sp), driver.GetEndpointService(cm.sp), cp.Me, v)
+	if err != nil {
+		logger.Errorf("failed creating context for flow [%s]: [%s]", cp.ContextID, err)
+		return
+	}
+	// re-attach the sessions, the counterparties might still be waiting on them
+	for _, s := range cp.Sessions {
+		session, err := GetCommLayer(cm.sp).NewSessionWithID(s.ID, cp.ContextID, s.Endpoint, s.PKID, s.Caller, nil)
+		if err != nil {
+			logger.Errorf("failed re-attaching session [%s] of flow [%s]: [%s]", s.ID, cp.ContextID, err)
+			return
+		}
+		if err := setSessionMetadata(session, nil, s.Label, view.Routing{}); err != nil {
+			logger.Errorf("failed re-attaching session [%s] of flow [%s]: [%s]", s.ID, cp.ContextID, err)
+			return
+		}
+		cm.parkedSync.RLock()
+		parked := cm.parked[s.ID]
+		cm.parkedSync.RUnlock()
+		if parked == nil {
+			viewContext.sessions[s.Key] = session
+			continue
+		}
+		viewContext.sessions[s.Key] = newResumedSession(session, parked)
+	}
+
+	if _, err := cm.initiate(viewContext, v, cp.Me); err != nil {
+		logger.Errorf("resumed flow [%s] of [%s] failed: [%s]", cp.ContextID, cp.ViewID, err)
+	}
+}
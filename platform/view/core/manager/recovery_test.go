@@ -0,0 +1,309 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/manager"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver/mock"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	mock3 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// network routes the messages sent by the counterparty to the node currently running the initiator
+type network struct {
+	lock sync.Mutex
+	node *node
+	// toCounterparty carries the messages sent by the initiator
+	toCounterparty chan *view.Message
+}
+
+func (n *network) attach(node *node) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.node = node
+}
+
+// reply delivers the passed payload on the passed session of the current node,
+// or on its master session if the session does not exist there
+func (n *network) reply(sessionID string, payload string) {
+	n.lock.Lock()
+	node := n.node
+	n.lock.Unlock()
+
+	msg := &view.Message{SessionID: sessionID, Status: view.OK, Payload: []byte(payload)}
+	node.lock.Lock()
+	s, ok := node.sessions[sessionID]
+	node.lock.Unlock()
+	if ok {
+		s.in <- msg
+		return
+	}
+	node.master.in <- msg
+}
+
+// node is a fake comm layer
+type node struct {
+	network *network
+	lock    sync.Mutex
+	master  *session
+	// sessions are the sessions opened by the initiator
+	sessions map[string]*session
+	counter  int
+}
+
+func newNode(n *network) *node {
+	node := &node{network: n, sessions: map[string]*session{}}
+	node.master = &session{id: "master", in: make(chan *view.Message, 10)}
+	n.attach(node)
+	return node
+}
+
+func (n *node) NewSessionWithID(sessionID, contextID, endpoint string, pkid []byte, caller view.Identity, msg *view.Message) (view.Session, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	s, ok := n.sessions[sessionID]
+	if !ok {
+		s = &session{id: sessionID, in: make(chan *view.Message, 10), out: n.network.toCounterparty}
+		n.sessions[sessionID] = s
+	}
+	return s, nil
+}
+
+func (n *node) NewSession(caller string, contextID string, endpoint string, pkid []byte) (view.Session, error) {
+	n.lock.Lock()
+	n.counter++
+	id := caller + "." + string(rune('0'+n.counter))
+	n.lock.Unlock()
+	return n.NewSessionWithID(id, contextID, endpoint, pkid, nil, nil)
+}
+
+func (n *node) MasterSession() (view.Session, error) {
+	return n.master, nil
+}
+
+func (n *node) DeleteSessions(sessionID string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.sessions, sessionID)
+}
+
+type session struct {
+	id  string
+	in  chan *view.Message
+	out chan *view.Message
+}
+
+func (s *session) Info() view.SessionInfo { return view.SessionInfo{ID: s.id} }
+
+func (s *session) Send(payload []byte) error {
+	s.out <- &view.Message{SessionID: s.id, Status: view.OK, Payload: payload}
+	return nil
+}
+
+func (s *session) SendError(payload []byte) error {
+	s.out <- &view.Message{SessionID: s.id, Status: view.ERROR, Payload: payload}
+	return nil
+}
+
+func (s *session) Receive() <-chan *view.Message { return s.in }
+
+func (s *session) Close() {}
+
+type transferState struct {
+	Step int
+}
+
+// TransferView runs a two-step protocol with the counterparty, checkpointing after each step
+type TransferView struct {
+	transferState
+	Party view.Identity
+	// crash, if not nil, blocks the view between the two checkpoints, as if the node was killed
+	crash       chan struct{}
+	checkpoints chan int
+}
+
+func (t *TransferView) Call(context view.Context) (interface{}, error) {
+	session, err := context.GetSession(context.Initiator(), t.Party)
+	if err != nil {
+		return nil, err
+	}
+	if t.Step == 0 {
+		if err := session.Send([]byte("hello")); err != nil {
+			return nil, err
+		}
+		if err := expect(session, "ack1"); err != nil {
+			return nil, err
+		}
+		t.Step = 1
+		if err := context.(view.CheckpointContext).Checkpoint(t.transferState); err != nil {
+			return nil, err
+		}
+		t.checkpoints <- 1
+	}
+	if t.crash != nil {
+		<-t.crash
+	}
+
+	if err := session.Send([]byte("commit")); err != nil {
+		return nil, err
+	}
+	if err := expect(session, "ack2"); err != nil {
+		return nil, err
+	}
+	t.Step = 2
+	if err := context.(view.CheckpointContext).Checkpoint(t.transferState); err != nil {
+		return nil, err
+	}
+	t.checkpoints <- 2
+	return "done", nil
+}
+
+func (t *TransferView) Resume(state []byte) (view.View, error) {
+	v := &TransferView{Party: t.Party, checkpoints: t.checkpoints}
+	if err := json.Unmarshal(state, &v.transferState); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func expect(session view.Session, payload string) error {
+	select {
+	case msg := <-session.Receive():
+		if string(msg.Payload) != payload {
+			return errors.Errorf("expected [%s], got [%s]", payload, msg.Payload)
+		}
+		return nil
+	case <-time.After(5 * time.Second):
+		return errors.Errorf("timeout waiting for [%s]", payload)
+	}
+}
+
+func newRecoveryManager(t *testing.T, n *network, kvss *kvs.KVS) interface {
+	Manager
+	Start(ctx context.Context)
+	RegisterRecoverable(prototype view.Recoverable) error
+} {
+	registry := registry2.New()
+	idProvider := &mock.IdentityProvider{}
+	idProvider.DefaultIdentityReturns([]byte("alice"))
+	assert.NoError(t, registry.RegisterService(idProvider))
+	assert.NoError(t, registry.RegisterService(newNode(n)))
+	endpointService := &mock.EndpointService{}
+	endpointService.ResolveReturns([]byte("bob"), nil, nil, nil)
+	assert.NoError(t, registry.RegisterService(endpointService))
+	assert.NoError(t, registry.RegisterService(kvss))
+	return manager.New(registry)
+}
+
+func TestRecoverFlowAfterRestart(t *testing.T) {
+	kvss, err := kvs.NewWithConfig(registry2.New(), "memory", "", &mock3.ConfigProvider{})
+	assert.NoError(t, err)
+
+	n := &network{toCounterparty: make(chan *view.Message, 10)}
+	checkpoints := make(chan int, 10)
+	prototype := &TransferView{Party: []byte("bob"), checkpoints: checkpoints}
+
+	// the counterparty waits on the same session for the whole flow
+	counterpartyDone := make(chan error, 1)
+	go func() {
+		msg := <-n.toCounterparty
+		if string(msg.Payload) != "hello" {
+			counterpartyDone <- errors.Errorf("expected hello, got [%s]", msg.Payload)
+			return
+		}
+		sessionID := msg.SessionID
+		n.reply(sessionID, "ack1")
+		msg = <-n.toCounterparty
+		if string(msg.Payload) != "commit" || msg.SessionID != sessionID {
+			counterpartyDone <- errors.Errorf("expected commit on [%s], got [%s] on [%s]", sessionID, msg.Payload, msg.SessionID)
+			return
+		}
+		n.reply(sessionID, "ack2")
+		counterpartyDone <- nil
+	}()
+
+	// first run, the initiator node is killed between the two checkpoints
+	m1 := newRecoveryManager(t, n, kvss)
+	assert.NoError(t, m1.RegisterRecoverable(prototype))
+	go func() {
+		_, _ = m1.InitiateView(&TransferView{Party: []byte("bob"), crash: make(chan struct{}), checkpoints: checkpoints})
+	}()
+	assert.Equal(t, 1, <-checkpoints)
+
+	// restart: a new node resumes the flow from the first checkpoint and completes it
+	m2 := newRecoveryManager(t, n, kvss)
+	assert.NoError(t, m2.RegisterRecoverable(prototype))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m2.Start(ctx)
+
+	select {
+	case step := <-checkpoints:
+		assert.Equal(t, 2, step)
+	case <-time.After(10 * time.Second):
+		t.Fatal("flow not resumed after restart")
+	}
+	assert.NoError(t, <-counterpartyDone)
+
+	// the checkpoint is removed once the flow terminates
+	assert.Eventually(t, func() bool {
+		it, err := kvss.GetByPartialCompositeID("fsc.view.checkpoint", []string{})
+		assert.NoError(t, err)
+		defer it.Close()
+		return !it.HasNext()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRecoverFlowWithParkedMessages(t *testing.T) {
+	kvss, err := kvs.NewWithConfig(registry2.New(), "memory", "", &mock3.ConfigProvider{})
+	assert.NoError(t, err)
+
+	n := &network{toCounterparty: make(chan *view.Message, 10)}
+	checkpoints := make(chan int, 10)
+	prototype := &TransferView{Party: []byte("bob"), checkpoints: checkpoints}
+
+	// first run, the initiator node is killed between the two checkpoints
+	m1 := newRecoveryManager(t, n, kvss)
+	assert.NoError(t, m1.RegisterRecoverable(prototype))
+	go func() {
+		_, _ = m1.InitiateView(&TransferView{Party: []byte("bob"), crash: make(chan struct{}), checkpoints: checkpoints})
+	}()
+	msg := <-n.toCounterparty
+	sessionID := msg.SessionID
+	n.reply(sessionID, "ack1")
+	assert.Equal(t, 1, <-checkpoints)
+
+	// the counterparty answers while the initiator node is restarting,
+	// the message reaches the master session of the new node
+	m2 := newRecoveryManager(t, n, kvss)
+	assert.NoError(t, m2.RegisterRecoverable(prototype))
+	n.reply(sessionID, "ack2")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m2.Start(ctx)
+
+	select {
+	case step := <-checkpoints:
+		assert.Equal(t, 2, step)
+	case <-time.After(10 * time.Second):
+		t.Fatal("flow not resumed after restart")
+	}
+	msg = <-n.toCounterparty
+	assert.Equal(t, "commit", string(msg.Payload))
+	assert.Equal(t, sessionID, msg.SessionID)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+)
+
+// Registrations returns the factories, the responders and the recoverable views registered, sorted by identifier.
+// The identities the responders are bound to are reported by their unique id.
+func (cm *manager) Registrations() *driver.Registrations {
+	res := &driver.Registrations{Factories: []driver.FactoryRegistration{}, Responders: []driver.ResponderRegistration{}}
+
+	cm.factoriesSync.RLock()
+	for id, factory := range cm.factories {
+		r := driver.FactoryRegistration{ID: id}
+		if f, ok := factory.(driver.InputSchemaFactory); ok {
+			if schema := f.InputSchema(); json.Valid(schema) {
+				r.InputSchema = schema
+			} else {
+				logger.Warnf("factory [%s] declares an invalid input schema, skipped", id)
+			}
+		}
+		res.Factories = append(res.Factories, r)
+	}
+	cm.factoriesSync.RUnlock()
+	sort.Slice(res.Factories, func(i, j int) bool { return res.Factories[i].ID < res.Factories[j].ID })
+
+	cm.viewsSync.RLock()
+	initiatedBy := map[string][]string{}
+	for initiator, responder := range cm.initiators {
+		initiatedBy[responder] = append(initiatedBy[responder], initiator)
+	}
+	for id, entries := range cm.views {
+		for _, entry := range entries {
+			r := driver.ResponderRegistration{View: id, Initiator: entry.Initiator}
+			if !entry.Initiator {
+				r.InitiatedBy = initiatedBy[id]
+				sort.Strings(r.InitiatedBy)
+				r.Policy = cm.policies.policy(id)
+			}
+			if !entry.ID.IsNone() {
+				r.Identity = entry.ID.UniqueID()
+			}
+			res.Responders = append(res.Responders, r)
+		}
+	}
+	cm.viewsSync.RUnlock()
+	sort.SliceStable(res.Responders, func(i, j int) bool { return res.Responders[i].View < res.Responders[j].View })
+
+	cm.recoverablesSync.RLock()
+	for id := range cm.recoverables {
+		res.Recoverable = append(res.Recoverable, id)
+	}
+	cm.recoverablesSync.RUnlock()
+	sort.Strings(res.Recoverable)
+
+	return res
+}
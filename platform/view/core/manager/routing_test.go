@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/stretchr/testify/assert"
+)
+
+type routingManager interface {
+	InitiateViewWithRouting(view view.View, routing view.Routing) (interface{}, error)
+}
+
+// routingView returns the routing of its context, or that of the responder of the party, if set
+type routingView struct {
+	party view.Identity
+}
+
+func (r *routingView) Call(context view.Context) (interface{}, error) {
+	if r.party.IsNone() {
+		routing, _ := view.RoutingFromContext(context.Context())
+		return routing, nil
+	}
+	s, err := context.GetSession(r, r.party)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Send([]byte("routing")); err != nil {
+		return nil, err
+	}
+	msg, err := receive(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(msg.Payload), nil
+}
+
+// routingResponder answers with the network and the channel of the routing of its context
+type routingResponder struct{}
+
+func (r *routingResponder) Call(context view.Context) (interface{}, error) {
+	if _, err := receive(context.Session()); err != nil {
+		return nil, err
+	}
+	routing, _ := view.RoutingFromContext(context.Context())
+	return nil, context.Session().Send([]byte(routing.Network + "/" + routing.Channel))
+}
+
+// routedView runs the routingView routed to the passed channel
+type routedView struct {
+	channel string
+}
+
+func (r *routedView) Call(context view.Context) (interface{}, error) {
+	return context.RunView(&routingView{}, view.RoutedTo("", r.channel))
+}
+
+func TestRouting(t *testing.T) {
+	b := &bus{nodes: map[string]*busNode{}}
+	alice := newLabelsManager(t, b, "alice")
+	bob := newLabelsManager(t, b, "bob")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go alice.Start(ctx)
+	go bob.Start(ctx)
+	assert.NoError(t, bob.RegisterResponder(&routingResponder{}, &routingView{}))
+
+	// the same view follows the routing of its invocation only
+	for _, channel := range []string{"ch1", "ch2"} {
+		res, err := alice.(routingManager).InitiateViewWithRouting(&routingView{}, view.Routing{Network: "default", Channel: channel})
+		assert.NoError(t, err)
+		assert.Equal(t, view.Routing{Network: "default", Channel: channel}, res)
+
+		// the responders follow the routing of the initiator
+		res, err = alice.(routingManager).InitiateViewWithRouting(&routingView{party: []byte("bob")}, view.Routing{Network: "default", Channel: channel})
+		assert.NoError(t, err)
+		assert.Equal(t, "default/"+channel, res)
+	}
+
+	// no routing by default
+	res, err := alice.InitiateView(&routingView{})
+	assert.NoError(t, err)
+	assert.Equal(t, view.Routing{}, res)
+
+	// the views run routed to a channel have their own routing
+	res, err = alice.InitiateView(&routedView{channel: "ch2"})
+	assert.NoError(t, err)
+	assert.Equal(t, view.Routing{Channel: "ch2"}, res)
+	_, err = alice.InitiateView(&sameContextRoutedView{})
+	assert.EqualError(t, err, "a routed view cannot run in the same context")
+}
+
+type sameContextRoutedView struct{}
+
+func (r *sameContextRoutedView) Call(context view.Context) (interface{}, error) {
+	return context.RunView(&routingView{}, view.RoutedTo("", "ch1"), view.WithSameContext())
+}
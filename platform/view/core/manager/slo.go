@@ -0,0 +1,483 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultSLOWindow        = time.Hour
+	DefaultSLOAlertBurnRate = 2
+	DefaultSLOAlertMinFlows = 10
+
+	// latencySlots is the number of slots the SLO window is divided in, the window slides by one slot at a time
+	latencySlots = 60
+)
+
+// phases are the phases the latency of a flow is broken down by
+var phases = []string{view.PhaseCompute, view.PhaseSession, view.PhaseEndorsement, view.PhaseOrdering}
+
+// latencyBuckets are the upper bounds, in seconds, of the buckets of the latency histograms,
+// the percentiles are estimated from them
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// SLO is the latency objective of the flows of a view
+type SLO struct {
+	// Target is the latency the flows must not exceed
+	Target time.Duration
+	// Objective is the percentage of the flows that must meet the target, within (0, 100)
+	Objective float64
+}
+
+func (s SLO) defined() bool {
+	return s.Target > 0 && s.Objective > 0 && s.Objective < 100
+}
+
+// override returns this objective with the non-zero values of the passed one
+func (s SLO) override(o SLO) SLO {
+	if o.Target > 0 {
+		s.Target = o.Target
+	}
+	if o.Objective > 0 {
+		s.Objective = o.Objective
+	}
+	return s
+}
+
+// LatencyReport is the latency of the flows of a view over the SLO window
+type LatencyReport struct {
+	View     string
+	Flows    uint64
+	Breaches uint64
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	// SLO is the objective of the view, the zero value if none
+	SLO SLO
+	// BurnRate is the pace the error budget of the SLO is consumed at: at 1, the breaches match the objective exactly
+	BurnRate float64
+}
+
+type viewSLO struct {
+	View      string
+	Target    time.Duration
+	Objective float64
+}
+
+type sloConfig struct {
+	Target        time.Duration
+	Objective     float64
+	Window        time.Duration
+	AlertBurnRate float64
+	AlertMinFlows uint64
+	Overrides     []viewSLO
+}
+
+var (
+	phaseDurationOpts = metrics.HistogramOpts{
+		Namespace:    "view",
+		Subsystem:    "latency",
+		Name:         "phase_duration",
+		Help:         "The time, in seconds, the flows spend in each phase, per view and phase.",
+		Buckets:      latencyBuckets,
+		LabelNames:   []string{"view", "phase"},
+		StatsdFormat: "%{#fqname}.%{view}.%{phase}",
+	}
+	latencyQuantileOpts = metrics.GaugeOpts{
+		Namespace:    "view",
+		Subsystem:    "latency",
+		Name:         "quantile",
+		Help:         "The percentiles, in seconds, of the wall-clock time of the flows over the SLO window, per view.",
+		LabelNames:   []string{"view", "quantile"},
+		StatsdFormat: "%{#fqname}.%{view}.%{quantile}",
+	}
+	sloBreachesOpts = metrics.CounterOpts{
+		Namespace:    "view",
+		Subsystem:    "slo",
+		Name:         "breaches",
+		Help:         "The number of flows that exceeded the latency target of their view, per view.",
+		LabelNames:   []string{"view"},
+		StatsdFormat: "%{#fqname}.%{view}",
+	}
+	sloBurnRateOpts = metrics.GaugeOpts{
+		Namespace:    "view",
+		Subsystem:    "slo",
+		Name:         "burn_rate",
+		Help:         "The pace the error budget of the latency objective is consumed at over the SLO window, per view.",
+		LabelNames:   []string{"view"},
+		StatsdFormat: "%{#fqname}.%{view}",
+	}
+)
+
+// LatencyMetrics records the latency of the flows against the objectives of their views
+type LatencyMetrics struct {
+	PhaseDuration metrics.Histogram
+	Quantile      metrics.Gauge
+	Breaches      metrics.Counter
+	BurnRate      metrics.Gauge
+}
+
+func NewLatencyMetrics(p metrics.Provider) *LatencyMetrics {
+	return &LatencyMetrics{
+		PhaseDuration: p.NewHistogram(phaseDurationOpts),
+		Quantile:      p.NewGauge(latencyQuantileOpts),
+		Breaches:      p.NewCounter(sloBreachesOpts),
+		BurnRate:      p.NewGauge(sloBurnRateOpts),
+	}
+}
+
+// latencies tracks the latency of the flows run by the view manager, broken down by phase,
+// and the burn rate of the objectives of their views over a sliding window
+type latencies struct {
+	defaults      SLO
+	overrides     map[string]SLO
+	window        time.Duration
+	alertBurnRate float64
+	alertMinFlows uint64
+	metrics       *LatencyMetrics
+	now           func() time.Time
+
+	lock  sync.Mutex
+	views map[string]*latencyWindow
+}
+
+// newLatencies loads the latency objectives from the following keys:
+// fsc.views.slo.target is the latency the flows must not exceed,
+// fsc.views.slo.objective is the percentage of the flows that must meet the target, such as 99.5,
+// fsc.views.slo.window is the sliding window the burn rate is computed over, default 1h,
+// fsc.views.slo.alertBurnRate is the burn rate over which the health check fails, default 2,
+// fsc.views.slo.alertMinFlows is the number of flows in the window under which the health check does not fail, default 10,
+// fsc.views.slo.overrides lists per-view objectives, by view identifier, whose non-zero values replace the defaults.
+// The latency of the flows is tracked anyway, the views without objective have no burn rate.
+func newLatencies(sp driver.ServiceProvider) *latencies {
+	l := &latencies{
+		overrides:     map[string]SLO{},
+		window:        DefaultSLOWindow,
+		alertBurnRate: DefaultSLOAlertBurnRate,
+		alertMinFlows: DefaultSLOAlertMinFlows,
+		now:           time.Now,
+		views:         map[string]*latencyWindow{},
+	}
+	var p metrics.Provider = &disabled.Provider{}
+	if s, err := sp.GetService(reflect.TypeOf((*metrics.Provider)(nil))); err == nil {
+		p = s.(metrics.Provider)
+	}
+	l.metrics = NewLatencyMetrics(p)
+
+	s, err := sp.GetService(reflect.TypeOf((*driver.ConfigService)(nil)))
+	if err != nil {
+		return l
+	}
+	cs := s.(driver.ConfigService)
+	if !cs.IsSet("fsc.views.slo") {
+		return l
+	}
+	config := &sloConfig{}
+	if err := cs.UnmarshalKey("fsc.views.slo", config); err != nil {
+		logger.Errorf("failed loading latency objectives, the views have none: [%s]", err)
+		return l
+	}
+	l.defaults = SLO{Target: config.Target, Objective: config.Objective}
+	if l.defaults != (SLO{}) && !l.defaults.defined() {
+		logger.Errorf("invalid default latency objective, ignore it: target [%s], objective [%v]", l.defaults.Target, l.defaults.Objective)
+		l.defaults = SLO{}
+	}
+	for _, o := range config.Overrides {
+		slo := l.defaults.override(SLO{Target: o.Target, Objective: o.Objective})
+		if !slo.defined() {
+			logger.Errorf("invalid latency objective of view [%s], ignore it: target [%s], objective [%v]", o.View, slo.Target, slo.Objective)
+			continue
+		}
+		l.overrides[o.View] = slo
+	}
+	if config.Window > 0 {
+		l.window = config.Window
+	}
+	if config.AlertBurnRate > 0 {
+		l.alertBurnRate = config.AlertBurnRate
+	}
+	if config.AlertMinFlows > 0 {
+		l.alertMinFlows = config.AlertMinFlows
+	}
+	return l
+}
+
+// slo returns the objective of the flows of the passed view
+func (l *latencies) slo(view string) SLO {
+	if o, ok := l.overrides[view]; ok {
+		return o
+	}
+	return l.defaults
+}
+
+// start tracks the latency of a new flow of the passed view, and returns the context, carrying the tracker
+// of the phases of the flow, the flow must run in
+func (l *latencies) start(id string, parent context.Context) (*flowLatency, context.Context) {
+	f := &flowLatency{
+		latencies: l,
+		view:      id,
+		started:   l.now(),
+		phases:    map[string]time.Duration{},
+	}
+	return f, view.WithPhaseTracker(parent, f)
+}
+
+func (l *latencies) end(f *flowLatency, total time.Duration, phases map[string]time.Duration) {
+	for phase, d := range phases {
+		l.metrics.PhaseDuration.With("view", f.view, "phase", phase).Observe(d.Seconds())
+	}
+
+	slo := l.slo(f.view)
+	breach := slo.defined() && total > slo.Target
+	if breach {
+		l.metrics.Breaches.With("view", f.view).Add(1)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	w, ok := l.views[f.view]
+	if !ok {
+		w = newLatencyWindow(l.window)
+		l.views[f.view] = w
+	}
+	now := l.now()
+	w.record(now, total, breach)
+	l.publish(l.report(f.view, w, now))
+}
+
+// report returns the latency of the flows of the passed view over the window ending at the passed time.
+// l.lock must be held.
+func (l *latencies) report(view string, w *latencyWindow, now time.Time) *LatencyReport {
+	report := w.report(now)
+	report.View = view
+	report.SLO = l.slo(view)
+	if report.SLO.defined() && report.Flows > 0 {
+		report.BurnRate = float64(report.Breaches) / float64(report.Flows) / (1 - report.SLO.Objective/100)
+	}
+	return report
+}
+
+// publish sets the gauges of the view of the passed report
+func (l *latencies) publish(report *LatencyReport) {
+	l.metrics.Quantile.With("view", report.View, "quantile", "0.5").Set(report.P50.Seconds())
+	l.metrics.Quantile.With("view", report.View, "quantile", "0.9").Set(report.P90.Seconds())
+	l.metrics.Quantile.With("view", report.View, "quantile", "0.99").Set(report.P99.Seconds())
+	if report.SLO.defined() {
+		l.metrics.BurnRate.With("view", report.View).Set(report.BurnRate)
+	}
+}
+
+// reports returns the latency of the flows of each view over the SLO window, sorted by view
+func (l *latencies) reports() []*LatencyReport {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	res := make([]*LatencyReport, 0, len(l.views))
+	for v, w := range l.views {
+		res = append(res, l.report(v, w, now))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].View < res[j].View })
+	// the gauges of the views without recent flows decay too
+	for _, report := range res {
+		l.publish(report)
+	}
+	return res
+}
+
+// check fails if the burn rate of the objective of a view, over a window with enough flows, exceeds the alert burn rate
+func (l *latencies) check() error {
+	var alerts []string
+	for _, r := range l.reports() {
+		if !r.SLO.defined() || r.Flows < l.alertMinFlows || r.BurnRate <= l.alertBurnRate {
+			continue
+		}
+		alerts = append(alerts, fmt.Sprintf("[%s]: burn rate [%.2f] over [%s], [%d] of [%d] flows over [%s], p99 [%s]", r.View, r.BurnRate, l.window, r.Breaches, r.Flows, r.SLO.Target, r.P99))
+	}
+	if len(alerts) != 0 {
+		return errors.Errorf("views burning their latency objective: %s", strings.Join(alerts, ", "))
+	}
+	return nil
+}
+
+// LatencyReports returns the latency of the flows of each view over the SLO window, sorted by view
+func (cm *manager) LatencyReports() []*LatencyReport {
+	return cm.latencies.reports()
+}
+
+// CheckSLOs is the health check of the latency objectives of the views, see newLatencies.
+// It fails if a view, with enough flows in the SLO window, burns its objective faster than the alert burn rate.
+func (cm *manager) CheckSLOs(context.Context) error {
+	return cm.latencies.check()
+}
+
+// latencyWindow counts the flows of a view over a sliding window, divided in slots
+type latencyWindow struct {
+	slot  time.Duration
+	slots [latencySlots]latencySlot
+}
+
+type latencySlot struct {
+	index    int64
+	flows    uint64
+	breaches uint64
+	// buckets counts the flows per latency bucket, the last one is unbounded
+	buckets []uint64
+}
+
+func newLatencyWindow(window time.Duration) *latencyWindow {
+	w := &latencyWindow{slot: window / latencySlots}
+	if w.slot <= 0 {
+		w.slot = 1
+	}
+	for i := range w.slots {
+		w.slots[i].buckets = make([]uint64, len(latencyBuckets)+1)
+	}
+	return w
+}
+
+func (w *latencyWindow) record(now time.Time, d time.Duration, breach bool) {
+	index := now.UnixNano() / int64(w.slot)
+	s := &w.slots[index%latencySlots]
+	if s.index != index {
+		s.index, s.flows, s.breaches = index, 0, 0
+		for i := range s.buckets {
+			s.buckets[i] = 0
+		}
+	}
+	s.flows++
+	if breach {
+		s.breaches++
+	}
+	s.buckets[sort.SearchFloat64s(latencyBuckets, d.Seconds())]++
+}
+
+// report returns the flows, the breaches, and the percentiles of the window ending at the passed time
+func (w *latencyWindow) report(now time.Time) *LatencyReport {
+	index := now.UnixNano() / int64(w.slot)
+	report := &LatencyReport{}
+	buckets := make([]uint64, len(latencyBuckets)+1)
+	for i := range w.slots {
+		s := &w.slots[i]
+		if s.index <= index-latencySlots || s.index > index {
+			continue
+		}
+		report.Flows += s.flows
+		report.Breaches += s.breaches
+		for j, n := range s.buckets {
+			buckets[j] += n
+		}
+	}
+	report.P50 = quantile(0.5, buckets, report.Flows)
+	report.P90 = quantile(0.9, buckets, report.Flows)
+	report.P99 = quantile(0.99, buckets, report.Flows)
+	return report
+}
+
+// quantile estimates the passed quantile of the passed buckets, holding all together the passed count,
+// interpolating linearly within the bucket it falls in. The quantiles in the unbounded bucket are its lower bound.
+func quantile(q float64, buckets []uint64, count uint64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	rank := q * float64(count)
+	var cumulative uint64
+	for i, n := range buckets {
+		if float64(cumulative+n) < rank || n == 0 {
+			cumulative += n
+			continue
+		}
+		if i == len(latencyBuckets) {
+			break
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = latencyBuckets[i-1]
+		}
+		upper := latencyBuckets[i]
+		seconds := lower + (upper-lower)*(rank-float64(cumulative))/float64(n)
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return time.Duration(latencyBuckets[len(latencyBuckets)-1] * float64(time.Second))
+}
+
+// flowLatency tracks the phases of a flow. A phase entered while the flow is in another phase is accounted to the
+// latter: the sessions opened to collect endorsements, for instance, are accounted to the endorsement.
+type flowLatency struct {
+	latencies *latencies
+	view      string
+	started   time.Time
+
+	lock    sync.Mutex
+	phases  map[string]time.Duration
+	current string
+	depth   int
+	since   time.Time
+}
+
+// Enter marks that the flow entered the passed phase, the returned function, idempotent, marks that it left it
+func (f *flowLatency) Enter(phase string) func() {
+	f.lock.Lock()
+	if f.depth == 0 {
+		f.current = phase
+		f.since = f.latencies.now()
+	}
+	f.depth++
+	f.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			f.lock.Lock()
+			defer f.lock.Unlock()
+			f.depth--
+			if f.depth == 0 {
+				f.phases[f.current] += f.latencies.now().Sub(f.since)
+			}
+		})
+	}
+}
+
+// end records the latency of the flow, the time not accounted to any phase is compute
+func (f *flowLatency) end() {
+	f.lock.Lock()
+	now := f.latencies.now()
+	total := now.Sub(f.started)
+	res := make(map[string]time.Duration, len(phases))
+	for _, phase := range phases {
+		res[phase] = 0
+	}
+	for phase, d := range f.phases {
+		res[phase] = d
+	}
+	if f.depth > 0 {
+		// the flow terminated without leaving its phase
+		res[f.current] += now.Sub(f.since)
+	}
+	f.lock.Unlock()
+
+	var accounted time.Duration
+	for _, d := range res {
+		accounted += d
+	}
+	if compute := total - accounted; compute > 0 {
+		res[view.PhaseCompute] += compute
+	}
+	f.latencies.end(f, total, res)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+// newLatencyManager returns a manager whose latencies are measured with a clock advanced by the flows
+func newLatencyManager(t *testing.T, slo SLO) (*manager, func(d time.Duration)) {
+	m := newBudgetManager(t, Budget{})
+	now := time.Unix(1000000, 0)
+	m.latencies.now = func() time.Time { return now }
+	m.latencies.defaults = slo
+	return m, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLatencyPhases(t *testing.T) {
+	m, advance := newLatencyManager(t, SLO{})
+	phaseDuration := &metricsfakes.Histogram{}
+	phaseDuration.WithReturns(phaseDuration)
+	m.latencies.metrics.PhaseDuration = phaseDuration
+
+	_, err := m.InitiateView(viewFunc(func(context view.Context) (interface{}, error) {
+		advance(time.Second)
+		// the session opened to collect the endorsements is accounted to the endorsement
+		endorsed := view.TrackPhase(context.Context(), view.PhaseEndorsement)
+		advance(2 * time.Second)
+		received := view.TrackPhase(context.Context(), view.PhaseSession)
+		advance(time.Second)
+		received()
+		endorsed()
+		endorsed()
+		received = view.TrackPhase(context.Context(), view.PhaseSession)
+		advance(3 * time.Second)
+		received()
+		defer view.TrackPhase(context.Context(), view.PhaseOrdering)()
+		advance(4 * time.Second)
+		return nil, nil
+	}))
+	assert.NoError(t, err)
+
+	observed := map[string]float64{}
+	for i := 0; i < phaseDuration.ObserveCallCount(); i++ {
+		labels := phaseDuration.WithArgsForCall(i)
+		assert.Equal(t, []string{"view", "github.com/hyperledger-labs/fabric-smart-client/platform/view/core/manager/viewFunc", "phase"}, labels[:3])
+		observed[labels[3]] = phaseDuration.ObserveArgsForCall(i)
+	}
+	assert.Equal(t, map[string]float64{
+		view.PhaseCompute:     1,
+		view.PhaseEndorsement: 3,
+		view.PhaseSession:     3,
+		view.PhaseOrdering:    4,
+	}, observed)
+
+	// a context without tracker is ignored
+	view.TrackPhase(context.Background(), view.PhaseSession)()
+	view.TrackPhase(nil, view.PhaseSession)()
+}
+
+func TestLatencyBurnRate(t *testing.T) {
+	m, advance := newLatencyManager(t, SLO{Target: time.Second, Objective: 90})
+	m.latencies.overrides["fast"] = SLO{Target: 10 * time.Millisecond, Objective: 99}
+	burnRate := &metricsfakes.Gauge{}
+	burnRate.WithReturns(burnRate)
+	m.latencies.metrics.BurnRate = burnRate
+
+	run := func(d time.Duration) {
+		f, _ := m.latencies.start("slow", context.Background())
+		advance(d)
+		f.end()
+	}
+	for i := 0; i < 18; i++ {
+		run(200 * time.Millisecond)
+	}
+	run(2 * time.Second)
+	run(3 * time.Second)
+	f, _ := m.latencies.start("fast", context.Background())
+	f.end()
+
+	reports := m.LatencyReports()
+	if assert.Len(t, reports, 2) {
+		fast, slow := reports[0], reports[1]
+		assert.Equal(t, "fast", fast.View)
+		assert.Equal(t, uint64(1), fast.Flows)
+		assert.Zero(t, fast.BurnRate)
+		assert.Equal(t, SLO{Target: 10 * time.Millisecond, Objective: 99}, fast.SLO)
+
+		assert.Equal(t, "slow", slow.View)
+		assert.Equal(t, uint64(20), slow.Flows)
+		assert.Equal(t, uint64(2), slow.Breaches)
+		// 10% of the flows breached an objective of 90%, the budget is consumed at the pace of the objective
+		assert.InDelta(t, 1, slow.BurnRate, 1e-9)
+		assert.True(t, slow.P50 > 100*time.Millisecond && slow.P50 <= 250*time.Millisecond, "p50 [%s]", slow.P50)
+		assert.True(t, slow.P99 > time.Second && slow.P99 <= 5*time.Second, "p99 [%s]", slow.P99)
+	}
+	assert.InDelta(t, 1, burnRate.SetArgsForCall(burnRate.SetCallCount()-1), 1e-9)
+	assert.NoError(t, m.CheckSLOs(context.Background()))
+
+	// the health check fails once the budget burns faster than the alert burn rate
+	for i := 0; i < 5; i++ {
+		run(2 * time.Second)
+	}
+	err := m.CheckSLOs(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "[slow]: burn rate [2.80]")
+
+	// the breaches slide out of the window
+	advance(DefaultSLOWindow)
+	assert.NoError(t, m.CheckSLOs(context.Background()))
+	assert.Zero(t, m.LatencyReports()[1].Flows)
+}
+
+func TestLatencyQuantile(t *testing.T) {
+	buckets := make([]uint64, len(latencyBuckets)+1)
+	assert.Zero(t, quantile(0.5, buckets, 0))
+
+	// 0.5s < 10 flows <= 1s
+	buckets[7] = 10
+	assert.Equal(t, 750*time.Millisecond, quantile(0.5, buckets, 10))
+	assert.Equal(t, time.Second, quantile(1, buckets, 10))
+
+	// the flows over the last bound are reported at the last bound
+	buckets[len(latencyBuckets)] = 10
+	assert.Equal(t, 120*time.Second, quantile(0.99, buckets, 20))
+}
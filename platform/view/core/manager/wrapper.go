@@ -27,6 +27,8 @@ type childContext struct {
 	session            view.Session
 	initiator          view.View
 	errorCallbackFuncs []func()
+	// context, if set, overrides the one of the parent, it carries the routing of the view, see view.RoutedTo
+	context context.Context
 }
 
 func (w *childContext) GetService(v interface{}) (interface{}, error) {
@@ -53,10 +55,37 @@ func (w *childContext) GetSessionByID(id string, party view.Identity) (view.Sess
 	return w.ParentContext.GetSessionByID(id, party)
 }
 
+// GetSessionWithLabel returns the session, with the passed label, to the passed party for the initiator of this context
+func (w *childContext) GetSessionWithLabel(party view.Identity, label string) (view.Session, error) {
+	return w.getSession(w.Initiator(), party, label)
+}
+
+func (w *childContext) getSession(caller view.View, party view.Identity, label string) (view.Session, error) {
+	ls, ok := w.ParentContext.(labeledSessions)
+	if !ok {
+		return nil, errors.Errorf("context [%s] does not support labeled sessions", w.ID())
+	}
+	return ls.getSession(caller, party, label)
+}
+
 func (w *childContext) Context() context.Context {
+	if w.context != nil {
+		return w.context
+	}
 	return w.ParentContext.Context()
 }
 
+// routedContext returns a copy of the passed context carrying the passed routing, nil if there is no routing
+func routedContext(ctx context.Context, routing *view.Routing) context.Context {
+	if routing == nil {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return view.WithRouting(ctx, *routing)
+}
+
 func (w *childContext) Session() view.Session {
 	if w.session == nil {
 		return w.ParentContext.Session()
@@ -79,6 +108,15 @@ func (w *childContext) OnError(f func()) {
 	w.errorCallbackFuncs = append(w.errorCallbackFuncs, f)
 }
 
+// Checkpoint checkpoints the flow of the parent context, if it supports checkpoints
+func (w *childContext) Checkpoint(state interface{}) error {
+	cc, ok := w.ParentContext.(view.CheckpointContext)
+	if !ok {
+		return errors.Errorf("context [%s] does not support checkpoints", w.ID())
+	}
+	return cc.Checkpoint(state)
+}
+
 func (w *childContext) RunView(v view.View, opts ...view.RunViewOption) (res interface{}, err error) {
 	options, err := view.CompileRunViewOptions(opts...)
 	if err != nil {
@@ -89,6 +127,9 @@ func (w *childContext) RunView(v view.View, opts ...view.RunViewOption) (res int
 		initiator = v
 	}
 
+	if options.SameContext && options.Routing != nil {
+		return nil, errors.Errorf("a routed view cannot run in the same context")
+	}
 	var cc *childContext
 	if options.SameContext {
 		cc = w
@@ -97,6 +138,7 @@ func (w *childContext) RunView(v view.View, opts ...view.RunViewOption) (res int
 			ParentContext: w,
 			session:       options.Session,
 			initiator:     initiator,
+			context:       routedContext(w.Context(), options.Routing),
 		}
 		defer func() {
 			if r := recover(); r != nil {
@@ -0,0 +1,195 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package derivation
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Curve is the curve of the derived keys
+type Curve string
+
+const (
+	// P256 keys sign with ECDSA over the NIST P-256 curve, as the x509 identities
+	P256 Curve = "P256"
+	// Ed25519 keys sign with Ed25519
+	Ed25519 Curve = "Ed25519"
+
+	// MinSeedSize and MaxSeedSize bound the size in bytes of the master seed, as in BIP32
+	MinSeedSize = 16
+	MaxSeedSize = 64
+
+	hardened = uint32(0x80000000)
+)
+
+// curveSeeds are the keys of the HMAC deriving the master key of each curve, as in SLIP-0010
+var curveSeeds = map[Curve][]byte{
+	P256:    []byte("Nist256p1 seed"),
+	Ed25519: []byte("ed25519 seed"),
+}
+
+// node is a node of the hierarchy, a private key and its chain code
+type node struct {
+	key       []byte
+	chainCode []byte
+}
+
+// ParsePath returns the indexes of the hardened derivations of the passed path, m/app/42/user/7 for instance.
+// The numeric segments, below 2^31, are the indexes, as the hardened indexes of BIP32 (m/42 is m/42' of BIP32).
+// The other segments are names, their index is taken from their SHA-256 digest.
+func ParsePath(path string) ([]uint32, error) {
+	segments := strings.Split(path, "/")
+	if segments[0] != "m" {
+		return nil, errors.Errorf("invalid path [%s], it must start with m", path)
+	}
+	indexes := make([]uint32, 0, len(segments)-1)
+	for _, segment := range segments[1:] {
+		if len(segment) == 0 {
+			return nil, errors.Errorf("invalid path [%s], empty segment", path)
+		}
+		if n, err := strconv.ParseUint(segment, 10, 32); err == nil {
+			if uint32(n) >= hardened {
+				return nil, errors.Errorf("invalid path [%s], index [%d] out of range", path, n)
+			}
+			indexes = append(indexes, uint32(n)|hardened)
+			continue
+		}
+		digest := sha256.Sum256([]byte(segment))
+		indexes = append(indexes, binary.BigEndian.Uint32(digest[:4])|hardened)
+	}
+	return indexes, nil
+}
+
+// deriveKey returns the private key of the passed curve at the passed indexes, as defined by SLIP-0010
+func deriveKey(curve Curve, seed []byte, indexes []uint32) ([]byte, error) {
+	n, err := masterNode(curve, seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		n = n.child(curve, index)
+	}
+	return n.key, nil
+}
+
+func masterNode(curve Curve, seed []byte) (*node, error) {
+	curveSeed, ok := curveSeeds[curve]
+	if !ok {
+		return nil, errors.Errorf("curve [%s] not supported", curve)
+	}
+	if len(seed) < MinSeedSize || len(seed) > MaxSeedSize {
+		return nil, errors.Errorf("seed must be between [%d] and [%d] bytes, got [%d]", MinSeedSize, MaxSeedSize, len(seed))
+	}
+	data := seed
+	for {
+		i := hmacSHA512(curveSeed, data)
+		if curve == Ed25519 || validScalar(i[:32]) {
+			return &node{key: i[:32], chainCode: i[32:]}, nil
+		}
+		data = i
+	}
+}
+
+// child returns the hardened child at the passed index
+func (n *node) child(curve Curve, index uint32) *node {
+	data := make([]byte, 0, 37)
+	data = append(data, 0)
+	data = append(data, n.key...)
+	data = append(data, ser32(index)...)
+	for {
+		i := hmacSHA512(n.chainCode, data)
+		if curve == Ed25519 {
+			return &node{key: i[:32], chainCode: i[32:]}
+		}
+		if validScalar(i[:32]) {
+			order := elliptic.P256().Params().N
+			k := new(big.Int).Add(new(big.Int).SetBytes(i[:32]), new(big.Int).SetBytes(n.key))
+			k.Mod(k, order)
+			if k.Sign() != 0 {
+				return &node{key: k.FillBytes(make([]byte, 32)), chainCode: i[32:]}
+			}
+		}
+		data = append([]byte{1}, i[32:]...)
+		data = append(data, ser32(index)...)
+	}
+}
+
+// validScalar returns true if the passed bytes are a non-zero integer below the order of P-256
+func validScalar(raw []byte) bool {
+	k := new(big.Int).SetBytes(raw)
+	return k.Sign() != 0 && k.Cmp(elliptic.P256().Params().N) < 0
+}
+
+func ser32(i uint32) []byte {
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, i)
+	return raw
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Deriver derives deterministic keys from a master seed, the keys are cached
+type Deriver struct {
+	seed []byte
+
+	lock  sync.RWMutex
+	cache map[string]*Key
+}
+
+// NewDeriver returns a Deriver for the passed master seed, of MinSeedSize to MaxSeedSize bytes
+func NewDeriver(seed []byte) (*Deriver, error) {
+	if len(seed) < MinSeedSize || len(seed) > MaxSeedSize {
+		return nil, errors.Errorf("seed must be between [%d] and [%d] bytes, got [%d]", MinSeedSize, MaxSeedSize, len(seed))
+	}
+	return &Deriver{seed: seed, cache: map[string]*Key{}}, nil
+}
+
+// Derive returns the key of the passed curve at the passed path
+func (d *Deriver) Derive(curve Curve, path string) (*Key, error) {
+	id := string(curve) + ":" + path
+	d.lock.RLock()
+	k, ok := d.cache[id]
+	d.lock.RUnlock()
+	if ok {
+		return k, nil
+	}
+
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := deriveKey(curve, d.seed, indexes)
+	if err != nil {
+		return nil, err
+	}
+	k, err = newKey(curve, path, raw)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed deriving key at [%s]", path)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if cached, ok := d.cache[id]; ok {
+		return cached, nil
+	}
+	d.cache[id] = k
+	return k, nil
+}
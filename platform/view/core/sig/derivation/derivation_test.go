@@ -0,0 +1,213 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package derivation
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	sig2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// seed is the seed of the test vector 1 of SLIP-0010, whose nist256p1 chain derives non-hardened keys past m/0H
+const seed = "000102030405060708090a0b0c0d0e0f"
+
+func TestVectors(t *testing.T) {
+	raw, err := hex.DecodeString(seed)
+	assert.NoError(t, err)
+
+	vectors := []struct {
+		curve     Curve
+		path      string
+		key       string
+		chainCode string
+	}{
+		{Ed25519, "m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb"},
+		{Ed25519, "m/0", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", ""},
+		{Ed25519, "m/0/1", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", ""},
+		{Ed25519, "m/0/1/2", "92a5b23c0b8a99e37d07df3fb9966917f5d06e02ddbd909c7e184371463e9fc9", ""},
+		{P256, "m", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea"},
+		{P256, "m/0", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c", ""},
+	}
+	for _, v := range vectors {
+		indexes, err := ParsePath(v.path)
+		assert.NoError(t, err)
+		key, err := deriveKey(v.curve, raw, indexes)
+		assert.NoError(t, err)
+		assert.Equal(t, v.key, hex.EncodeToString(key), "%s %s", v.curve, v.path)
+		if len(v.chainCode) != 0 {
+			n, err := masterNode(v.curve, raw)
+			assert.NoError(t, err)
+			assert.Equal(t, v.chainCode, hex.EncodeToString(n.chainCode), "%s %s", v.curve, v.path)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	indexes, err := ParsePath("m/app/42/user/7")
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 4)
+	assert.Equal(t, uint32(0x80000000+42), indexes[1])
+	assert.Equal(t, uint32(0x80000000+7), indexes[3])
+	for _, index := range indexes {
+		assert.True(t, index >= 0x80000000)
+	}
+	other, err := ParsePath("m/app/42/group/7")
+	assert.NoError(t, err)
+	assert.Equal(t, indexes[0], other[0])
+	assert.NotEqual(t, indexes[2], other[2])
+
+	for _, path := range []string{"", "app/42", "m/", "m/app//7", "m/2147483648"} {
+		_, err := ParsePath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestDeriver(t *testing.T) {
+	raw, err := hex.DecodeString(seed)
+	assert.NoError(t, err)
+	_, err = NewDeriver(raw[:8])
+	assert.Error(t, err)
+	d, err := NewDeriver(raw)
+	assert.NoError(t, err)
+
+	for _, curve := range []Curve{P256, Ed25519} {
+		k, err := d.Derive(curve, "m/app/42/user/7")
+		assert.NoError(t, err)
+		// the keys are cached
+		k2, err := d.Derive(curve, "m/app/42/user/7")
+		assert.NoError(t, err)
+		assert.Same(t, k, k2)
+		// the derivation is deterministic
+		d2, err := NewDeriver(raw)
+		assert.NoError(t, err)
+		k3, err := d2.Derive(curve, "m/app/42/user/7")
+		assert.NoError(t, err)
+		assert.Equal(t, k.Identity, k3.Identity)
+		other, err := d.Derive(curve, "m/app/42/user/8")
+		assert.NoError(t, err)
+		assert.NotEqual(t, k.Identity, other.Identity)
+
+		sigma, err := k.Signer.Sign([]byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, k.Verifier.Verify([]byte("hello"), sigma))
+		assert.Error(t, other.Verifier.Verify([]byte("hello"), sigma))
+
+		// the identity is understood by the deserializer
+		v, err := (&Deserializer{}).DeserializeVerifier(k.Identity)
+		assert.NoError(t, err)
+		assert.NoError(t, v.Verify([]byte("hello"), sigma))
+	}
+
+	_, err = d.Derive("secp256k1", "m/0")
+	assert.Error(t, err)
+}
+
+func TestService(t *testing.T) {
+	registry := registry2.New()
+	kvss, err := kvs.NewWithConfig(registry, "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.RegisterService(kvss))
+	sigService := sig2.NewSignService(registry, nil, kvss)
+	assert.NoError(t, registry.RegisterService(sigService))
+	signers := view2.GetSigService(registry)
+
+	raw, err := hex.DecodeString(seed)
+	assert.NoError(t, err)
+	d, err := NewDeriver(raw)
+	assert.NoError(t, err)
+	s := NewService(d, sigService, kvss)
+
+	id, err := s.Register("alice", P256, "m/app/42/user/7")
+	assert.NoError(t, err)
+	assert.Equal(t, id, s.Identity("alice"))
+	// the same binding can be registered again, a different one cannot
+	_, err = s.Register("alice", P256, "m/app/42/user/7")
+	assert.NoError(t, err)
+	_, err = s.Register("alice", Ed25519, "m/app/42/user/7")
+	assert.Error(t, err)
+	assert.Nil(t, s.Identity("bob"))
+
+	// the signer of the identity is registered in the signer service
+	signer, err := signers.GetSigner(id)
+	assert.NoError(t, err)
+	sigma, err := signer.Sign([]byte("hello"))
+	assert.NoError(t, err)
+
+	// the exported public key verifies the signatures and carries the path, not the private key
+	pk, err := s.Export("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "m/app/42/user/7", pk.Path)
+	assert.Contains(t, string(pk.PEM), "PUBLIC KEY")
+	assert.NotContains(t, string(pk.PEM), "PRIVATE KEY")
+	exported, err := pk.Identity()
+	assert.NoError(t, err)
+	assert.Equal(t, id, exported)
+	v, err := pk.Verifier()
+	assert.NoError(t, err)
+	assert.NoError(t, v.Verify([]byte("hello"), sigma))
+
+	// the labels are loaded again from the KVS
+	s = NewService(d, sigService, kvss)
+	assert.NoError(t, s.Load())
+	assert.Equal(t, id, s.Identity("alice"))
+	assert.Equal(t, []Registration{{Label: "alice", Curve: P256, Path: "m/app/42/user/7"}}, s.Labels())
+}
+
+type configProvider map[string]interface{}
+
+func (c configProvider) GetPath(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c configProvider) IsSet(key string) bool {
+	_, ok := c[key]
+	return ok
+}
+
+func (c configProvider) UnmarshalKey(key string, rawVal interface{}) error {
+	registrations, ok := rawVal.(*[]*Registration)
+	if !ok {
+		return errors.Errorf("unexpected type [%T]", rawVal)
+	}
+	*registrations, _ = c[key].([]*Registration)
+	return nil
+}
+
+func TestNewServiceFromConfig(t *testing.T) {
+	registry := registry2.New()
+	sigService := sig2.NewSignService(registry, nil, nil)
+
+	s, err := NewServiceFromConfig(configProvider{}, nil, sigService, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	path := filepath.Join(t.TempDir(), "seed")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(seed+"\n"), 0600))
+	s, err = NewServiceFromConfig(configProvider{
+		"fsc.keys.derivation.seed": path,
+		"fsc.keys.derivation.identities": []*Registration{
+			{Label: "app", Curve: Ed25519, Path: "m/app/42"},
+		},
+	}, nil, sigService, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, s.Identity("app"))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("not hex"), 0600))
+	_, err = NewServiceFromConfig(configProvider{"fsc.keys.derivation.seed": path}, nil, sigService, nil)
+	assert.Error(t, err)
+}
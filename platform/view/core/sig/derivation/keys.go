@@ -0,0 +1,223 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package derivation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	x5092 "github.com/hyperledger-labs/fabric-smart-client/platform/view/core/id/x509"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/pkg/errors"
+)
+
+// Key is a derived key. Its identity is a serialized identity carrying the PEM encoded public key,
+// as the ephemeral x509 identities.
+type Key struct {
+	Curve     Curve
+	Path      string
+	Identity  view.Identity
+	PublicKey crypto.PublicKey
+	Signer    driver.Signer
+	Verifier  driver.Verifier
+}
+
+// PublicKey is the public part of a derived key, shared with the counterparties to verify its signatures.
+// It never carries the private key.
+type PublicKey struct {
+	Curve Curve  `json:"curve"`
+	Path  string `json:"path"`
+	// PEM is the PEM encoded public key
+	PEM []byte `json:"pem"`
+}
+
+func newKey(curve Curve, path string, raw []byte) (*Key, error) {
+	var pk crypto.PublicKey
+	var signer driver.Signer
+	var verifier driver.Verifier
+	switch curve {
+	case P256:
+		sk := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(raw)}
+		sk.Curve = elliptic.P256()
+		sk.X, sk.Y = sk.Curve.ScalarBaseMult(raw)
+		pk = &sk.PublicKey
+		signer = &ecdsaSigner{sk: sk}
+		verifier = x5092.NewVerifier(&sk.PublicKey)
+	case Ed25519:
+		sk := ed25519.NewKeyFromSeed(raw)
+		pk = sk.Public()
+		signer = &ed25519Signer{sk: sk}
+		verifier = &ed25519Verifier{pk: sk.Public().(ed25519.PublicKey)}
+	default:
+		return nil, errors.Errorf("curve [%s] not supported", curve)
+	}
+	id, err := serialize(pk)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Curve: curve, Path: path, Identity: id, PublicKey: pk, Signer: signer, Verifier: verifier}, nil
+}
+
+// Export returns the public part of the key
+func (k *Key) Export() (*PublicKey, error) {
+	raw, err := pemEncode(k.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKey{Curve: k.Curve, Path: k.Path, PEM: raw}, nil
+}
+
+// Identity returns the identity of the exported key
+func (p *PublicKey) Identity() (view.Identity, error) {
+	pk, err := pemDecode(p.PEM)
+	if err != nil {
+		return nil, err
+	}
+	return serialize(pk)
+}
+
+// Verifier returns a verifier of the signatures of the exported key
+func (p *PublicKey) Verifier() (driver.Verifier, error) {
+	pk, err := pemDecode(p.PEM)
+	if err != nil {
+		return nil, err
+	}
+	return newVerifier(pk)
+}
+
+// Deserializer deserializes the verifiers of the identities of the derived keys
+type Deserializer struct{}
+
+func (d *Deserializer) DeserializeVerifier(raw []byte) (driver.Verifier, error) {
+	pk, err := deserialize(raw)
+	if err != nil {
+		return nil, err
+	}
+	return newVerifier(pk)
+}
+
+func (d *Deserializer) DeserializeSigner(raw []byte) (driver.Signer, error) {
+	return nil, errors.New("not supported")
+}
+
+func (d *Deserializer) Info(raw []byte, auditInfo []byte) (string, error) {
+	pk, err := deserialize(raw)
+	if err != nil {
+		return "", err
+	}
+	switch pk.(type) {
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("Derived %s: [%s]", P256, view.Identity(raw).UniqueID()), nil
+	default:
+		return fmt.Sprintf("Derived %s: [%s]", Ed25519, view.Identity(raw).UniqueID()), nil
+	}
+}
+
+func serialize(pk crypto.PublicKey) (view.Identity, error) {
+	raw, err := pemEncode(pk)
+	if err != nil {
+		return nil, err
+	}
+	id, err := proto.Marshal(&msp.SerializedIdentity{IdBytes: raw})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed marshalling serialized identity")
+	}
+	return id, nil
+}
+
+func deserialize(raw []byte) (crypto.PublicKey, error) {
+	si := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(raw, si); err != nil {
+		return nil, errors.Wrap(err, "failed unmarshalling serialized identity")
+	}
+	if len(si.Mspid) != 0 {
+		return nil, errors.Errorf("identity of msp [%s] is not a derived key", si.Mspid)
+	}
+	return pemDecode(si.IdBytes)
+}
+
+func pemEncode(pk crypto.PublicKey) ([]byte, error) {
+	raw, err := x509.MarshalPKIXPublicKey(pk)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed marshalling public key")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: raw}), nil
+}
+
+func pemDecode(raw []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("expected a PEM encoded public key")
+	}
+	pk, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed parsing public key")
+	}
+	return pk, nil
+}
+
+func newVerifier(pk crypto.PublicKey) (driver.Verifier, error) {
+	switch k := pk.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.Errorf("curve [%s] not supported", k.Curve.Params().Name)
+		}
+		return x5092.NewVerifier(k), nil
+	case ed25519.PublicKey:
+		return &ed25519Verifier{pk: k}, nil
+	default:
+		return nil, errors.Errorf("public key [%T] not supported", pk)
+	}
+}
+
+// ecdsaSigner signs the SHA-256 digest of the messages, with low-S signatures as the x509 identities
+type ecdsaSigner struct {
+	sk *ecdsa.PrivateKey
+}
+
+func (s *ecdsaSigner) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	r, sigma, err := ecdsa.Sign(rand.Reader, s.sk, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sigma, _, err = x5092.ToLowS(&s.sk.PublicKey, sigma)
+	if err != nil {
+		return nil, err
+	}
+	return x5092.MarshalECDSASignature(r, sigma)
+}
+
+type ed25519Signer struct {
+	sk ed25519.PrivateKey
+}
+
+func (s *ed25519Signer) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(s.sk, message), nil
+}
+
+type ed25519Verifier struct {
+	pk ed25519.PublicKey
+}
+
+func (v *ed25519Verifier) Verify(message, sigma []byte) error {
+	if !ed25519.Verify(v.pk, message, sigma) {
+		return errors.New("signature not valid")
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package derivation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"sync"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.sig.derivation")
+
+var serviceType = reflect.TypeOf((*Service)(nil))
+
+// labelsKey is the KVS key of the registrations of the labels
+var labelsKey = kvs.CreateCompositeKeyOrPanic("fsc.view.sig.derivation", []string{"labels"})
+
+// SigService is the signer service the derived keys are registered in
+type SigService interface {
+	RegisterSigner(identity view.Identity, signer driver.Signer, verifier driver.Verifier) error
+}
+
+// KVS stores the registrations of the labels
+type KVS interface {
+	Exists(id string) bool
+	Put(id string, state interface{}) error
+	Get(id string, state interface{}) error
+}
+
+// ConfigProvider models the configuration of the derived keys
+type ConfigProvider interface {
+	GetPath(key string) string
+	IsSet(key string) bool
+	UnmarshalKey(key string, rawVal interface{}) error
+}
+
+// Registration binds a label to the key at a path
+type Registration struct {
+	Label string `yaml:"label" json:"label"`
+	Curve Curve  `yaml:"curve" json:"curve"`
+	Path  string `yaml:"path" json:"path"`
+}
+
+// Service derives the keys of the application from the master seed of the node and registers their signers
+// in the signer service. The derived identities can be registered with a label, like the other identities.
+type Service struct {
+	deriver    *Deriver
+	sigService SigService
+	kvs        KVS
+
+	lock       sync.RWMutex
+	labels     map[string]*Registration
+	registered map[string]bool
+}
+
+// NewService returns a Service deriving its keys with the passed deriver, the labels are stored in the passed KVS, if any
+func NewService(deriver *Deriver, sigService SigService, kvs KVS) *Service {
+	return &Service{
+		deriver:    deriver,
+		sigService: sigService,
+		kvs:        kvs,
+		labels:     map[string]*Registration{},
+		registered: map[string]bool{},
+	}
+}
+
+// NewServiceFromConfig returns the Service whose master seed is in the file at `fsc.keys.derivation.seed`,
+// hex encoded, or wrapped by the passed key manager. It returns nil if no seed is configured.
+// The identities listed at `fsc.keys.derivation.identities` are registered, as the ones stored in the KVS.
+func NewServiceFromConfig(cp ConfigProvider, km *kms.KeyManager, sigService SigService, kvs KVS) (*Service, error) {
+	path := cp.GetPath("fsc.keys.derivation.seed")
+	if len(path) == 0 {
+		return nil, nil
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading master seed file [%s]", path)
+	}
+	var seed []byte
+	if kms.IsWrappedKey(raw) {
+		if km == nil {
+			return nil, errors.Errorf("master seed [%s] is wrapped but no key manager is configured", path)
+		}
+		if seed, err = kms.UnwrapKey(km, raw); err != nil {
+			return nil, errors.WithMessagef(err, "failed unwrapping master seed [%s]", path)
+		}
+	} else if seed, err = hex.DecodeString(string(bytes.TrimSpace(raw))); err != nil {
+		return nil, errors.Wrapf(err, "master seed [%s] is not hex encoded", path)
+	}
+	deriver, err := NewDeriver(seed)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid master seed [%s]", path)
+	}
+
+	s := NewService(deriver, sigService, kvs)
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	var registrations []*Registration
+	if cp.IsSet("fsc.keys.derivation.identities") {
+		if err := cp.UnmarshalKey("fsc.keys.derivation.identities", &registrations); err != nil {
+			return nil, errors.Wrap(err, "failed loading derived identities")
+		}
+	}
+	for _, r := range registrations {
+		if _, err := s.Register(r.Label, r.Curve, r.Path); err != nil {
+			return nil, errors.WithMessagef(err, "failed registering derived identity [%s]", r.Label)
+		}
+	}
+	logger.Infof("master seed loaded, [%d] derived identities registered", len(s.Labels()))
+	return s, nil
+}
+
+// GetService returns the Service registered in the passed service provider, nil if there is none
+func GetService(sp view2.ServiceProvider) *Service {
+	if sp == nil {
+		return nil
+	}
+	s, err := sp.GetService(serviceType)
+	if err != nil {
+		return nil
+	}
+	return s.(*Service)
+}
+
+// Derive returns the key of the passed curve at the passed path, its signer is registered in the signer service
+func (s *Service) Derive(curve Curve, path string) (*Key, error) {
+	k, err := s.deriver.Derive(curve, path)
+	if err != nil {
+		return nil, err
+	}
+
+	id := k.Identity.UniqueID()
+	s.lock.RLock()
+	registered := s.registered[id]
+	s.lock.RUnlock()
+	if registered {
+		return k, nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.registered[id] {
+		return k, nil
+	}
+	if err := s.sigService.RegisterSigner(k.Identity, k.Signer, k.Verifier); err != nil {
+		return nil, errors.WithMessagef(err, "failed registering signer of derived key [%s]", path)
+	}
+	s.registered[id] = true
+	return k, nil
+}
+
+// Register binds the passed label to the key at the passed path and returns its identity.
+// A label bound already can be registered again only with the same curve and path.
+func (s *Service) Register(label string, curve Curve, path string) (view.Identity, error) {
+	if len(label) == 0 {
+		return nil, errors.New("empty label")
+	}
+	k, err := s.Derive(curve, path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if r, ok := s.labels[label]; ok {
+		if r.Curve != curve || r.Path != path {
+			return nil, errors.Errorf("label [%s] is bound already to [%s:%s]", label, r.Curve, r.Path)
+		}
+		return k.Identity, nil
+	}
+	s.labels[label] = &Registration{Label: label, Curve: curve, Path: path}
+	if err := s.store(); err != nil {
+		delete(s.labels, label)
+		return nil, err
+	}
+	logger.Debugf("label [%s] bound to derived key [%s:%s]", label, curve, path)
+	return k.Identity, nil
+}
+
+// Identity returns the identity of the derived key bound to the passed label, nil if the label is not bound
+func (s *Service) Identity(label string) view.Identity {
+	k, err := s.key(label)
+	if err != nil {
+		logger.Warnf("failed to get derived identity for label [%s]: [%s]", label, err)
+		return nil
+	}
+	return k.Identity
+}
+
+// Export returns the public key, and the path, of the derived key bound to the passed label
+func (s *Service) Export(label string) (*PublicKey, error) {
+	k, err := s.key(label)
+	if err != nil {
+		return nil, err
+	}
+	return k.Export()
+}
+
+// Labels returns the registrations of the labels, sorted by label
+func (s *Service) Labels() []Registration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	res := make([]Registration, 0, len(s.labels))
+	for _, r := range s.labels {
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Label < res[j].Label })
+	return res
+}
+
+// Load registers the labels stored in the KVS
+func (s *Service) Load() error {
+	if s.kvs == nil || !s.kvs.Exists(labelsKey) {
+		return nil
+	}
+	var registrations []*Registration
+	if err := s.kvs.Get(labelsKey, &registrations); err != nil {
+		return errors.Wrap(err, "failed loading the labels of the derived identities")
+	}
+	for _, r := range registrations {
+		if _, err := s.Register(r.Label, r.Curve, r.Path); err != nil {
+			return errors.WithMessagef(err, "failed registering derived identity [%s]", r.Label)
+		}
+	}
+	return nil
+}
+
+func (s *Service) key(label string) (*Key, error) {
+	s.lock.RLock()
+	r, ok := s.labels[label]
+	s.lock.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("label [%s] is not bound to a derived key", label)
+	}
+	return s.Derive(r.Curve, r.Path)
+}
+
+// store stores the registrations of the labels in the KVS. s.lock must be held.
+func (s *Service) store() error {
+	if s.kvs == nil {
+		return nil
+	}
+	registrations := make([]*Registration, 0, len(s.labels))
+	for _, r := range s.labels {
+		registrations = append(registrations, r)
+	}
+	sort.Slice(registrations, func(i, j int) bool { return registrations[i].Label < registrations[j].Label })
+	if err := s.kvs.Put(labelsKey, registrations); err != nil {
+		return errors.Wrap(err, "failed storing the labels of the derived identities")
+	}
+	return nil
+}
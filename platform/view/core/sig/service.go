@@ -26,6 +26,7 @@ type KVS interface {
 	Exists(id string) bool
 	Put(id string, state interface{}) error
 	Get(id string, state interface{}) error
+	Delete(id string) error
 }
 
 type service struct {
@@ -101,6 +102,31 @@ func (o *service) RegisterVerifier(identity view.Identity, verifier driver.Verif
 	return nil
 }
 
+// DeregisterSigner unbinds the passed identity from its signer and verifier.
+// Until bound again, the identity is no longer recognized as one of this node.
+func (o *service) DeregisterSigner(identity view.Identity) error {
+	o.viewsSync.Lock()
+	delete(o.signers, identity.UniqueID())
+	delete(o.verifiers, identity.UniqueID())
+	o.viewsSync.Unlock()
+
+	if o.kvs != nil {
+		k, err := kvs.CreateCompositeKey("sigService", []string{"signer", identity.UniqueID()})
+		if err != nil {
+			return errors.Wrap(err, "failed to create composite key to delete entry from kvs")
+		}
+		if o.kvs.Exists(k) {
+			if err := o.kvs.Delete(k); err != nil {
+				return errors.Wrap(err, "failed to delete entry from kvs for the passed signer")
+			}
+		}
+	}
+	if logger.IsEnabledFor(zapcore.DebugLevel) {
+		logger.Debugf("signer for [id:%s] deregistered", identity.UniqueID())
+	}
+	return nil
+}
+
 func (o *service) RegisterAuditInfo(identity view.Identity, info []byte) error {
 	k := kvs.CreateCompositeKeyOrPanic(
 		"fsc.platform.view.sig",
@@ -13,3 +13,9 @@ type Factory interface {
 	// NewView returns an instance of the View interface build using the passed argument.
 	NewView(in []byte) (view.View, error)
 }
+
+// InputSchemaFactory is implemented by the factories declaring the schema of the input of their views
+type InputSchemaFactory interface {
+	// InputSchema returns the JSON schema of the input passed to NewView
+	InputSchema() []byte
+}
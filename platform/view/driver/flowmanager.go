@@ -8,8 +8,10 @@ package driver
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
 )
 
 // ViewManager manages the lifecycle of views and contexts
@@ -24,6 +26,11 @@ type ViewManager interface {
 	InitiateContext(view view.View) (view.Context, error)
 	// InitiateContextWithIdentityAndID initiates a new context
 	InitiateContextWithIdentityAndID(view view.View, id view.Identity, contextID string) (view.Context, error)
+	// InitiateViewWithRouting invokes the passed view in a context carrying the passed routing,
+	// and returns the result produced by that view
+	InitiateViewWithRouting(view view.View, routing view.Routing) (interface{}, error)
+	// InitiateContextWithRouting initiates a new context, carrying the passed routing, for the passed view
+	InitiateContextWithRouting(view view.View, routing view.Routing) (view.Context, error)
 }
 
 // GetViewManager returns an instance of the view manager.
@@ -35,3 +42,70 @@ func GetViewManager(sp ServiceProvider) ViewManager {
 	}
 	return s.(ViewManager)
 }
+
+// ErrFlowNotFound is returned when a flow is not running
+var ErrFlowNotFound = errors.New("flow not found")
+
+// SessionOpened is the kind of the events recording the opening of the sessions of a flow, with
+// view.SessionSent and view.SessionReceived
+const SessionOpened = "opened"
+
+// BlockingOperation is an operation a running flow blocks on, see view.TrackBlocking
+type BlockingOperation struct {
+	// Operation is the kind of the operation, view.BlockedOnSession or view.BlockedOnFinality, for instance
+	Operation string    `json:"operation"`
+	Targets   []string  `json:"targets,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// SessionEvent is an event of a session of a running flow: its opening, or a message sent or received.
+// The payload of the messages is recorded in debug mode only.
+type SessionEvent struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	SessionID string    `json:"sessionID"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	Label     string    `json:"label,omitempty"`
+	Status    int32     `json:"status,omitempty"`
+	Size      int       `json:"size,omitempty"`
+	Payload   []byte    `json:"payload,omitempty"`
+}
+
+// RunningFlow describes a flow run by the view manager and what it blocks on
+type RunningFlow struct {
+	// ID identifies the flow on this node, it is the ID of its context, qualified by the label of the session
+	// of the labeled responders, and by a counter if another flow running on this node has the same
+	ID        string `json:"id"`
+	ContextID string `json:"contextID"`
+	View      string `json:"view"`
+	Initiator bool   `json:"initiator"`
+	// Caller is the endpoint that opened the session of a responder
+	Caller  string    `json:"caller,omitempty"`
+	Started time.Time `json:"started"`
+	// Blocked are the operations the flow blocks on, the oldest first, empty if it is computing
+	Blocked []BlockingOperation `json:"blocked,omitempty"`
+	// Sessions is the number of sessions opened by, or for, the flow
+	Sessions int `json:"sessions"`
+	// Aborted is when the abort of the flow has been requested, if it has
+	Aborted *time.Time `json:"aborted,omitempty"`
+}
+
+// FlowDetail is a running flow with the history of its sessions
+type FlowDetail struct {
+	RunningFlow
+	// History lists the events of the sessions of the flow, the oldest first
+	History []SessionEvent `json:"history"`
+	// Dropped is the number of the oldest events no longer in the history, it is bounded
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// FlowInspector is implemented by the view managers able to describe and abort the flows they run
+type FlowInspector interface {
+	// RunningFlows returns the flows running, the oldest first
+	RunningFlows() []RunningFlow
+	// RunningFlow returns the flow with the passed ID, ErrFlowNotFound if it is not running
+	RunningFlow(id string) (*FlowDetail, error)
+	// AbortFlow cancels the context of the flow with the passed ID, the flow terminates on its cancellation.
+	// It returns ErrFlowNotFound if the flow is not running.
+	AbortFlow(id string) error
+}
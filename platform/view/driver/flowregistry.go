@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package driver
 
 import (
+	"encoding/json"
 	"reflect"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
@@ -36,6 +37,78 @@ type Registry interface {
 
 	// GetResponder returns the responder for the passed initiator.
 	GetResponder(initiatedBy interface{}) (view.View, error)
+
+	// RegisterRecoverable registers a prototype of a recoverable view.
+	// After a restart, the interrupted flows initiated by a view with the same identifier
+	// are resumed, from their last checkpoint, using the prototype.
+	RegisterRecoverable(prototype view.Recoverable) error
+}
+
+// ResponderPolicy constrains the sessions a responder is instantiated for. The empty fields do not constrain.
+type ResponderPolicy struct {
+	// Initiators are the identities allowed to open the sessions, by endpoint name, as known to the endpoint service,
+	// or by unique id
+	Initiators []string `json:"initiators,omitempty"`
+	// MSPs are the identifiers of the MSPs the identities opening the sessions must belong to
+	MSPs []string `json:"msps,omitempty"`
+	// Networks are the networks the sessions can be about, as declared with view.SessionNetworkMetadata
+	Networks []string `json:"networks,omitempty"`
+	// Channels are the channels the sessions can be about, as declared with view.SessionChannelMetadata
+	Channels []string `json:"channels,omitempty"`
+	// Metadata are the session metadata the initiator must declare, with these values
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// TrustDomain is the trust domain, as configured under fsc.identity.trustDomains, whose anchors must certify
+	// the identities opening the sessions. The sessions are refused if the domain is not configured.
+	TrustDomain string `json:"trustDomain,omitempty"`
+}
+
+// ResponderPolicyRegistry is implemented by the registries able to constrain the sessions a responder is instantiated for
+type ResponderPolicyRegistry interface {
+	// RegisterResponderWithPolicy binds a responder to an initiator, as RegisterResponder does.
+	// The responder is instantiated only for the sessions satisfying the passed policy,
+	// unless the configuration declares another policy for the responder.
+	RegisterResponderWithPolicy(responder view.View, initiatedBy interface{}, policy *ResponderPolicy) error
+}
+
+// LabeledResponderRegistry is implemented by the registries able to bind different responders to the sessions
+// opened by the same initiator with different labels, see view.GetSessionWithLabel
+type LabeledResponderRegistry interface {
+	// RegisterResponderWithLabel binds a responder to the sessions opened by an initiator with the passed label.
+	// The labeled sessions with no responder bound to their label are dispatched to the responder of the initiator.
+	RegisterResponderWithLabel(responder view.View, initiatedBy interface{}, label string) error
+}
+
+// FactoryRegistration describes a view factory registered in a Registry
+type FactoryRegistration struct {
+	ID string `json:"id"`
+	// InputSchema is the schema declared by the factory, if it implements InputSchemaFactory
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// ResponderRegistration describes a view registered in a Registry as responder or initiator
+type ResponderRegistration struct {
+	// View is the identifier of the view
+	View string `json:"view"`
+	// InitiatedBy are the identifiers of the views the view responds to, empty for an initiator
+	InitiatedBy []string `json:"initiatedBy,omitempty"`
+	Initiator   bool     `json:"initiator,omitempty"`
+	// Identity is the unique id of the identity the view is bound to, if any
+	Identity string `json:"identity,omitempty"`
+	// Policy is the policy in force for the responder, if any
+	Policy *ResponderPolicy `json:"policy,omitempty"`
+}
+
+// Registrations describes the content of a Registry, sorted by identifier
+type Registrations struct {
+	Factories   []FactoryRegistration   `json:"factories"`
+	Responders  []ResponderRegistration `json:"responders"`
+	Recoverable []string                `json:"recoverable,omitempty"`
+}
+
+// RegistryInspector is implemented by the registries able to describe their content
+type RegistryInspector interface {
+	// Registrations returns what is registered
+	Registrations() *Registrations
 }
 
 func GetRegistry(sp ServiceProvider) Registry {
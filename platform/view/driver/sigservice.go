@@ -91,6 +91,12 @@ type SigRegistry interface {
 	RegisterVerifier(identity view.Identity, verifier Verifier) error
 }
 
+// SigDeregisterer is implemented by the SigRegistries that can forget the signer and verifier bound to an identity
+type SigDeregisterer interface {
+	// DeregisterSigner unbinds the passed identity from its signer and verifier
+	DeregisterSigner(identity view.Identity) error
+}
+
 func GetSigRegistry(sp ServiceProvider) SigRegistry {
 	s, err := sp.GetService(reflect.TypeOf((*SigRegistry)(nil)))
 	if err != nil {
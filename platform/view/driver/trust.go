@@ -0,0 +1,30 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package driver
+
+import (
+	"reflect"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+)
+
+// TrustDomains verifies the identities of the counterparties against the trust anchors of named trust domains,
+// each business network the node participates in has its own
+type TrustDomains interface {
+	// Verify returns nil if the passed identity is of a type the passed trust domain allows and is certified by
+	// the root CAs of the domain. It fails if the domain is not known or misconfigured.
+	Verify(domain string, identity view.Identity) error
+}
+
+// GetTrustDomains returns the trust domains of the node, nil if none is registered
+func GetTrustDomains(sp ServiceProvider) TrustDomains {
+	s, err := sp.GetService(reflect.TypeOf((*TrustDomains)(nil)))
+	if err != nil {
+		return nil
+	}
+	return s.(TrustDomains)
+}
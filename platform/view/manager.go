@@ -30,6 +30,11 @@ func (c *Context) GetSessionByID(id string, party view.Identity) (view.Session,
 	return c.c.GetSessionByID(id, party)
 }
 
+// GetSessionWithLabel returns the session to the passed party distinguished by the passed label
+func (c *Context) GetSessionWithLabel(party view.Identity, label string) (view.Session, error) {
+	return view.GetSessionWithLabel(c.c, party, label)
+}
+
 // Manager manages the lifecycle of views and contexts
 type Manager struct {
 	m driver.ViewManager
@@ -64,6 +69,21 @@ func (m *Manager) InitiateContext(view View) (*Context, error) {
 	return &Context{c: context}, nil
 }
 
+// InitiateViewWithRouting invokes the passed view in a context carrying the passed routing, and returns the result
+// produced by that view. The network and the channel the view does not set are the ones of the routing.
+func (m *Manager) InitiateViewWithRouting(view View, routing view.Routing) (interface{}, error) {
+	return m.m.InitiateViewWithRouting(view, routing)
+}
+
+// InitiateContextWithRouting initiates a new context, carrying the passed routing, for the passed view
+func (m *Manager) InitiateContextWithRouting(view View, routing view.Routing) (*Context, error) {
+	context, err := m.m.InitiateContextWithRouting(view, routing)
+	if err != nil {
+		return nil, err
+	}
+	return &Context{c: context}, nil
+}
+
 // InitiateContextWithIdentityAndID initiates
 func (m *Manager) InitiateContextWithIdentityAndID(view View, id view.Identity, contextID string) (view.Context, error) {
 	context, err := m.m.InitiateContextWithIdentityAndID(view, id, contextID)
@@ -11,6 +11,7 @@ import (
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
 )
 
 // View wraps a callable function.
@@ -26,6 +27,9 @@ type Factory interface {
 	NewView(in []byte) (view.View, error)
 }
 
+// ResponderPolicy constrains the sessions a responder is instantiated for
+type ResponderPolicy = driver.ResponderPolicy
+
 // Registry keeps track of the available view and view factories
 type Registry struct {
 	registry driver.Registry
@@ -64,6 +68,34 @@ func (r *Registry) RegisterResponderWithIdentity(responder View, id view.Identit
 	return r.registry.RegisterResponderWithIdentity(responder, id, initiatedBy)
 }
 
+// RegisterResponderWithPolicy binds a responder to an initiator, as RegisterResponder does.
+// The responder is instantiated only for the sessions satisfying the passed policy,
+// unless the configuration declares another policy for the responder.
+func (r *Registry) RegisterResponderWithPolicy(responder View, initiatedBy interface{}, policy *ResponderPolicy) error {
+	pr, ok := r.registry.(driver.ResponderPolicyRegistry)
+	if !ok {
+		return errors.New("the registry does not support responder policies")
+	}
+	return pr.RegisterResponderWithPolicy(responder, initiatedBy, policy)
+}
+
+// RegisterResponderWithLabel binds a responder to the sessions opened by an initiator with the passed label.
+// The labeled sessions with no responder bound to their label are dispatched to the responder of the initiator.
+func (r *Registry) RegisterResponderWithLabel(responder View, initiatedBy interface{}, label string) error {
+	lr, ok := r.registry.(driver.LabeledResponderRegistry)
+	if !ok {
+		return errors.New("the registry does not support labeled responders")
+	}
+	return lr.RegisterResponderWithLabel(responder, initiatedBy, label)
+}
+
+// RegisterRecoverable registers a prototype of a recoverable view.
+// After a restart, the interrupted flows initiated by a view with the same identifier
+// are resumed, from their last checkpoint, using the prototype.
+func (r *Registry) RegisterRecoverable(prototype view.Recoverable) error {
+	return r.registry.RegisterRecoverable(prototype)
+}
+
 // GetRegistry returns an instance of the view registry.
 // It panics, if no instance is found.
 func GetRegistry(sp ServiceProvider) *Registry {
@@ -9,3 +9,4 @@ package metadata
 // Variables defined by the Makefile and passed in with ldflags
 var Version = "latest"
 var CommitSHA = "development build"
+var BuildDate = ""
@@ -11,27 +11,38 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"sync/atomic"
+	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	config2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/core/config"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/endpoint"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/id"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/id/trust"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/id/x509"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/manager"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/core/sig/derivation"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/sdk/finality"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/assert"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/clock"
 	comm2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/comm"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/comm/deadletter"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/comm/identity"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/crypto"
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger"
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/drain"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events/simple"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	grpc2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/introspection"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver/file"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver/vault"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/metrics/operations"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view"
@@ -67,6 +78,19 @@ type WebServer interface {
 type GRPCServer interface {
 }
 
+// viewServiceName is the name of the view service on the grpc server
+const viewServiceName = "protos.ViewService"
+
+// busDrainTimeout is how long the subscribers of the event bus are given, on shutdown, to handle their queued events
+const busDrainTimeout = 5 * time.Second
+
+// healthCheckerFunc adapts a function to a health checker
+type healthCheckerFunc func(ctx context.Context) error
+
+func (f healthCheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
 type SDK struct {
 	confPath string
 	registry Registry
@@ -79,8 +103,15 @@ type SDK struct {
 
 	context          context.Context
 	operationsSystem *operations.System
+	// operationsServer, if enabled, serves the operations and the admin endpoints on their own listener
+	operationsServer *web2.Server
 
 	commService *comm2.Service
+	bus         *events.Bus
+	clock       *clock.Monitor
+	drain       *drain.Service
+	// viewServiceReady is set to 1 once the view service is serving requests
+	viewServiceReady int32
 }
 
 func NewSDK(confPath string, registry Registry) *SDK {
@@ -97,6 +128,17 @@ func (p *SDK) Install() error {
 	assert.NoError(p.registry.RegisterService(crypto.NewProvider()))
 
 	assert.NoError(p.registry.RegisterService(&events.Service{EventSystem: simple.NewEventBus()}))
+	p.bus = events.NewBus()
+	assert.NoError(p.registry.RegisterService(p.bus))
+
+	// Key Manager, the KVS and the identities may be wrapped by it
+	keyManager, err := kms.NewKeyManager(configProvider)
+	if err != nil {
+		return errors.WithMessage(err, "failed initializing key manager")
+	}
+	if keyManager != nil {
+		assert.NoError(p.registry.RegisterService(keyManager))
+	}
 
 	// KVS
 	defaultKVS, err := kvs.New(p.registry, kvs.GetDriverNameFromConf(p.registry), "_default")
@@ -109,6 +151,7 @@ func (p *SDK) Install() error {
 	des, err := sig.NewMultiplexDeserializer(p.registry)
 	assert.NoError(err, "failed loading sig verifier deserializer service")
 	des.AddDeserializer(&x509.Deserializer{})
+	des.AddDeserializer(&derivation.Deserializer{})
 	assert.NoError(p.registry.RegisterService(des))
 	signerService := sig.NewSignService(p.registry, des, defaultKVS)
 	assert.NoError(p.registry.RegisterService(signerService))
@@ -126,20 +169,51 @@ func (p *SDK) Install() error {
 	}
 	kmsDriver, err := kms.Get(fscIdentityType)
 	assert.NoError(err, "failed getting key management driver [%s]", fscIdentityType)
+	kmsDriver.KeyManager = keyManager
 
 	// Set Identity Provider
 	idProvider := id.NewProvider(configProvider, signerService, endpointService, kmsDriver)
 	assert.NoError(idProvider.Load(), "failed loading identities")
 	assert.NoError(p.registry.RegisterService(idProvider))
 
+	// Trust domains of the counterparties, by business network
+	assert.NoError(p.registry.RegisterService(trust.NewService(configProvider)))
+
+	// Derived keys of the application, if a master seed is configured
+	derivationService, err := derivation.NewServiceFromConfig(configProvider, keyManager, signerService, defaultKVS)
+	if err != nil {
+		return errors.WithMessage(err, "failed initializing derived keys")
+	}
+	if derivationService != nil {
+		assert.NoError(p.registry.RegisterService(derivationService))
+	}
+
 	// Resolver service
 	resolverService, err := endpoint.NewResolverService(configProvider, view.GetEndpointService(p.registry), idProvider)
 	assert.NoError(err, "failed instantiating endpoint resolver service")
 	assert.NoError(resolverService.LoadResolvers(), "failed loading resolvers")
 
+	assert.NoError(p.initOperationsServer(), "failed initializing operations server")
 	assert.NoError(p.initWEBServer(), "failed initializing web server")
 	assert.NoError(p.initWebOperationEndpointsAndMetrics(), "failed initializing web server endpoints and metrics")
 
+	// the dial policy of the connections to the remote endpoints, set before any client connects
+	if configProvider.IsSet("fsc.grpc.dialPolicy") {
+		dialPolicyConfig := grpc2.DialPolicyConfig{}
+		if err := configProvider.UnmarshalKey("fsc.grpc.dialPolicy", &dialPolicyConfig); err != nil {
+			return errors.Wrap(err, "failed loading the dial policy")
+		}
+		dialPolicy, err := grpc2.NewDialPolicy(dialPolicyConfig, p.operationsSystem)
+		if err != nil {
+			return errors.WithMessage(err, "invalid dial policy")
+		}
+		grpc2.SetDialPolicy(dialPolicy)
+	}
+
+	// the drain of the node before a planned restart, the components register the work they have in flight
+	p.drain = drain.NewService(defaultKVS, p.operationsSystem)
+	assert.NoError(p.registry.RegisterService(p.drain))
+
 	// View Service Server
 	marshaller, err := view2.NewResponseMarshaler(p.registry)
 	if err != nil {
@@ -159,13 +233,56 @@ func (p *SDK) Install() error {
 	if err := p.registry.RegisterService(p.viewService); err != nil {
 		return err
 	}
+	scheduler, err := view2.NewSchedulerFromConfig(configProvider, p.operationsSystem)
+	if err != nil {
+		return errors.WithMessage(err, "failed creating view scheduler")
+	}
+	if scheduler != nil {
+		if err := p.registry.RegisterService(scheduler); err != nil {
+			return err
+		}
+	}
+	deduplicator, err := view2.NewDeduplicatorFromConfig(configProvider, kvs.GetService(p.registry), p.operationsSystem)
+	if err != nil {
+		return errors.WithMessage(err, "failed creating view call deduplicator")
+	}
+	if err := p.registry.RegisterService(deduplicator); err != nil {
+		return err
+	}
+
+	// the skew of the local clock, certificate validation errors are annotated with it
+	p.clock = clock.NewMonitorFromConfig(configProvider, p.operationsSystem)
+	if err := p.registry.RegisterService(p.clock); err != nil {
+		return err
+	}
+	clock.SetDefault(p.clock)
 
 	// View Manager
 	viewManager := manager.New(p.registry)
+	if err := viewManager.Validate(); err != nil {
+		return err
+	}
 	if err := p.registry.RegisterService(viewManager); err != nil {
 		return err
 	}
 	p.viewManager = viewManager
+	p.drain.Register("views", viewManager)
+
+	// introspection of the registrations, the platforms add their own sections
+	introspectionService := introspection.NewService()
+	if err := p.registry.RegisterService(introspectionService); err != nil {
+		return err
+	}
+	if err := introspectionService.RegisterSection(introspection.ViewsSection, introspection.NewViewsSection(viewManager)); err != nil {
+		return err
+	}
+	if h, err := p.registry.GetService(reflect.TypeOf((*web2.HttpHandler)(nil))); err == nil {
+		h.(*web2.HttpHandler).RegisterURI(introspection.RegistryURI, "GET", introspection.NewHandler(introspectionService))
+		introspection.RegisterFlowsHandlers(h.(*web2.HttpHandler), viewManager)
+		h.(*web2.HttpHandler).RegisterURI(drain.URI, "POST", drain.NewHandler(p.drain))
+		h.(*web2.HttpHandler).RegisterURI(drain.URI, "GET", drain.NewHandler(p.drain))
+		h.(*web2.HttpHandler).RegisterURI(grpc2.BlockedEndpointsURI, "GET", grpc2.NewBlockedEndpointsHandler())
+	}
 
 	if err := p.installTracing(); err != nil {
 		return errors.WithMessage(err, "failed installing tracing")
@@ -175,6 +292,10 @@ func (p *SDK) Install() error {
 
 	p.initCommLayer()
 
+	if err := p.registerHealthCheckers(); err != nil {
+		return errors.WithMessage(err, "failed registering health checkers")
+	}
+
 	return nil
 }
 
@@ -219,16 +340,62 @@ func (p *SDK) initWEBServer() error {
 	})
 	h := web2.NewHttpHandler(logger)
 	p.webServer.RegisterHandler("/", h, true)
+	if p.operationsServer == nil {
+		// the handler is made available to the platforms for registering their endpoints
+		assert.NoError(p.registry.RegisterService(h), "failed registering web handler")
+	}
 
 	d := &web2.Dispatcher{
 		Logger:  logger,
 		Handler: h,
+		// the view invocations are refused while the node drains
+		Admit: func() error { return drain.GetService(p.registry).Check() },
 	}
 	web2.InstallViewHandler(logger, p.registry, d)
 
 	return nil
 }
 
+// initOperationsServer creates, if enabled, the server dedicated to the operations endpoints (metrics, health,
+// logspec and version) and to the admin endpoints of the platforms, which are then not served by the web server
+func (p *SDK) initOperationsServer() error {
+	configProvider := view.GetConfigService(p.registry)
+
+	if !configProvider.GetBool("fsc.operations.enabled") {
+		logger.Info("operations server not enabled, the operations endpoints are served by the web server")
+		return nil
+	}
+
+	listenAddr := configProvider.GetString("fsc.operations.listenAddress")
+	if len(listenAddr) == 0 {
+		return errors.New("operations server enabled but fsc.operations.listenAddress not set")
+	}
+	var clientRootCAs []string
+	for _, path := range configProvider.GetStringSlice("fsc.operations.tls.clientRootCAs.files") {
+		clientRootCAs = append(clientRootCAs, configProvider.TranslatePath(path))
+	}
+	tlsConfig := web2.TLS{
+		Enabled:           configProvider.GetBool("fsc.operations.tls.enabled"),
+		CertFile:          configProvider.GetPath("fsc.operations.tls.cert.file"),
+		KeyFile:           configProvider.GetPath("fsc.operations.tls.key.file"),
+		ClientAuth:        configProvider.GetBool("fsc.operations.tls.clientAuthRequired"),
+		ClientCACertFiles: clientRootCAs,
+	}
+	// fail now on a broken TLS configuration rather than when the server starts
+	if _, err := tlsConfig.Config(); err != nil {
+		return errors.Wrap(err, "invalid operations server TLS configuration")
+	}
+	p.operationsServer = web2.NewServer(web2.Options{
+		ListenAddress: listenAddr,
+		Logger:        logger,
+		TLS:           tlsConfig,
+	})
+	h := web2.NewHttpHandler(logger)
+	p.operationsServer.RegisterHandler("/", h, tlsConfig.Enabled)
+	// the platforms register their admin endpoints on this handler
+	return p.registry.RegisterService(h)
+}
+
 func (p *SDK) registerViewServiceServer() error {
 	if p.grpcServer == nil {
 		return nil
@@ -258,29 +425,77 @@ func (p *SDK) initGRPCServer() error {
 	serverConfig.UnaryInterceptors = append(
 		serverConfig.UnaryInterceptors,
 		grpclogging.UnaryServerInterceptor(flogging.MustGetLogger("comm.grpc.server").Zap()),
+		p.drain.UnaryServerInterceptor,
 	)
 	serverConfig.StreamInterceptors = append(
 		serverConfig.StreamInterceptors,
 		grpclogging.StreamServerInterceptor(flogging.MustGetLogger("comm.grpc.server").Zap()),
+		p.drain.StreamServerInterceptor,
 	)
 
 	p.grpcServer, err = grpc2.NewGRPCServer(listenAddr, serverConfig)
 	assert.NoError(err, "failed creating grpc server")
 
+	// the health service reports the same checks as the readiness probe of the operations system
+	if serverConfig.HealthCheckEnabled {
+		for component, checker := range p.operationsSystem.HealthCheckers() {
+			service := component
+			if component == "view" {
+				service = viewServiceName
+			}
+			p.grpcServer.RegisterHealthChecker(service, checker)
+		}
+	}
+	p.drain.OnDraining(p.grpcServer.SetNotServing)
+
 	return nil
 }
 
+// registerHealthCheckers registers the checkers of the view service, the latency objectives of the views,
+// the local clock, the comm layer, and the drain with the operations system
+func (p *SDK) registerHealthCheckers() error {
+	if err := p.operationsSystem.RegisterChecker("view", healthCheckerFunc(func(ctx context.Context) error {
+		if atomic.LoadInt32(&p.viewServiceReady) == 0 {
+			return errors.New("view service not started")
+		}
+		return nil
+	})); err != nil {
+		return err
+	}
+	if slos, ok := p.viewManager.(interface{ CheckSLOs(context.Context) error }); ok {
+		if err := p.operationsSystem.RegisterChecker("slo", healthCheckerFunc(slos.CheckSLOs)); err != nil {
+			return err
+		}
+	}
+	if err := p.operationsSystem.RegisterChecker("clock", p.clock); err != nil {
+		return err
+	}
+	if err := p.operationsSystem.RegisterChecker("drain", p.drain); err != nil {
+		return err
+	}
+	return p.operationsSystem.RegisterChecker("comm", p.commService)
+}
+
 func (p *SDK) initCommLayer() {
 	configProvider := view.GetConfigService(p.registry)
 
 	k, err := identity.NewCryptoPrivKeyFromMSP(configProvider.GetPath("fsc.identity.key.file"))
 	assert.NoError(err, "failed loading p2p node secret key")
 
+	// the messages whose delivery failed permanently are kept, if configured, to be replayed by the admins
+	deadLetters, err := deadletter.NewFromConfig(configProvider, kvs.GetService(p.registry), p.operationsSystem)
+	assert.NoError(err, "failed instantiating the dead letter queue")
+	if h, err := p.registry.GetService(reflect.TypeOf((*web2.HttpHandler)(nil))); err == nil && deadLetters != nil {
+		deadletter.RegisterHandlers(h.(*web2.HttpHandler), deadLetters)
+	}
+
 	commService, err := comm2.NewService(
 		&comm2.PrivateKeyFromCryptoKey{Key: k},
 		view.GetEndpointService(p.registry),
 		view.GetConfigService(p.registry),
 		view.GetIdentityProvider(p.registry).DefaultIdentity(),
+		p.operationsSystem,
+		deadLetters,
 	)
 	assert.NoError(err, "failed instantiating the communication service")
 	assert.NoError(p.registry.RegisterService(commService), "failed registering communication service")
@@ -290,17 +505,32 @@ func (p *SDK) initCommLayer() {
 func (p *SDK) startCommLayer() error {
 	p.commService.Start(p.context)
 
+	// the NTP server, if configured, refines the estimate of the skew of the local clock
+	if cs := view.GetConfigService(p.registry); cs.IsSet("fsc.clock.ntp.server") {
+		p.clock.StartNTP(p.context, cs.GetString("fsc.clock.ntp.server"), cs.GetDuration("fsc.clock.ntp.interval"), cs.GetDuration("fsc.clock.ntp.timeout"))
+	}
+
 	return nil
 }
 
 func (p *SDK) startViewManager() error {
 	view2.InstallViewHandler(p.registry, p.viewService)
 	go p.viewManager.Start(p.context)
+	atomic.StoreInt32(&p.viewServiceReady, 1)
 
 	return nil
 }
 
 func (p *SDK) serve() error {
+	// the operations server is started synchronously, a node whose operations endpoints cannot be bound does not start
+	if p.operationsServer != nil {
+		logger.Info("Starting operations server...")
+		if err := p.operationsServer.Start(); err != nil {
+			return errors.Wrapf(err, "failed starting operations server on [%s]", view.GetConfigService(p.registry).GetString("fsc.operations.listenAddress"))
+		}
+		logger.Infof("Operations server listening on [%s]", p.operationsServer.Addr())
+	}
+
 	// Start the grpc server. Done in a goroutine
 	go func() {
 		if p.grpcServer == nil {
@@ -335,6 +565,14 @@ func (p *SDK) serve() error {
 		}
 		logger.Info("web server stopping...done")
 
+		if p.operationsServer != nil {
+			logger.Info("operations server stopping...")
+			if err := p.operationsServer.Stop(); err != nil {
+				logger.Errorf("failed stopping operations server [%s]", err)
+			}
+			logger.Info("operations server stopping...done")
+		}
+
 		if p.grpcServer != nil {
 			logger.Info("grpc server stopping...")
 			p.grpcServer.Stop()
@@ -351,6 +589,14 @@ func (p *SDK) serve() error {
 				logger.Errorf("failed stopping operations system [%s]", err)
 			}
 		}
+
+		logger.Info("event bus draining...")
+		ctx, cancel := context.WithTimeout(context.Background(), busDrainTimeout)
+		defer cancel()
+		if err := p.bus.Close(ctx); err != nil {
+			logger.Errorf("failed draining event bus [%s]", err)
+		}
+		logger.Info("event bus draining...done")
 	}()
 	return nil
 }
@@ -363,6 +609,11 @@ func (p *SDK) getServerConfig() (grpc2.ServerConfig, error) {
 		SecOpts: grpc2.SecureOptions{
 			UseTLS: configProvider.GetBool("fsc.grpc.tls.enabled"),
 		},
+		// health and reflection are enabled unless explicitly disabled
+		HealthCheckEnabled:  !configProvider.IsSet("fsc.grpc.healthCheck.enabled") || configProvider.GetBool("fsc.grpc.healthCheck.enabled"),
+		HealthCheckInterval: configProvider.GetDuration("fsc.grpc.healthCheck.interval"),
+		HealthDrainDelay:    configProvider.GetDuration("fsc.grpc.healthCheck.drainDelay"),
+		ReflectionEnabled:   !configProvider.IsSet("fsc.grpc.reflection.enabled") || configProvider.GetBool("fsc.grpc.reflection.enabled"),
 	}
 	if serverConfig.SecOpts.UseTLS {
 		// get the certs from the file system
@@ -443,10 +694,15 @@ func (p *SDK) installTracing() error {
 func (p *SDK) initWebOperationEndpointsAndMetrics() error {
 	configProvider := view.GetConfigService(p.registry)
 
+	var server operations.Server = p.webServer
 	tlsEnabled := false
 	if configProvider.IsSet("fsc.web.tls.enabled") {
 		tlsEnabled = configProvider.GetBool("fsc.web.tls.enabled")
 	}
+	if p.operationsServer != nil {
+		server = p.operationsServer
+		tlsEnabled = configProvider.GetBool("fsc.operations.tls.enabled")
+	}
 
 	provider := configProvider.GetString("fsc.metrics.provider")
 	statsdOperationsConfig := &operations.Statsd{}
@@ -456,7 +712,7 @@ func (p *SDK) initWebOperationEndpointsAndMetrics() error {
 		}
 	}
 
-	p.operationsSystem = operations.NewSystem(p.webServer, operations.Options{
+	p.operationsSystem = operations.NewSystem(server, operations.Options{
 		Metrics: operations.MetricsOptions{
 			Provider: provider,
 			Statsd:   statsdOperationsConfig,
@@ -464,7 +720,7 @@ func (p *SDK) initWebOperationEndpointsAndMetrics() error {
 		TLS: operations.TLS{
 			Enabled: tlsEnabled,
 		},
-		Version: "1.0.0",
+		Version: version.Get().Version,
 	})
 	return p.registry.RegisterService(p.operationsSystem)
 }
@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"context"
+	"os"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/dryrun"
+	"github.com/pkg/errors"
+)
+
+const validateComponent = "view"
+
+// Validate checks the configuration of the view platform without installing it: the identity and the TLS material
+// of the servers must be readable, and the KVS openable. The KVS is opened read-only, the servers are not created,
+// their listen addresses are bound, and released at once, only if the options enable the self-check of the ports.
+func (p *SDK) Validate(ctx context.Context, config driver.ConfigService, opts *dryrun.Options, report *dryrun.Report) {
+	identityType := config.GetString("fsc.identity.type")
+	if len(identityType) == 0 || identityType == "file" {
+		report.Add(validateComponent, "identity.cert", config.GetPath("fsc.identity.cert.file"), dryrun.CheckCertificates(config.GetPath("fsc.identity.cert.file")))
+		// the key might be wrapped by the key manager, it is not parsed
+		report.Add(validateComponent, "identity.key", config.GetPath("fsc.identity.key.file"), dryrun.CheckFile(config.GetPath("fsc.identity.key.file")))
+	} else {
+		report.Warn(validateComponent, "identity", identityType, errors.Errorf("identities of type [%s] are loaded on startup only", identityType))
+	}
+
+	driverName := config.GetString("fsc.kvs.persistence.type")
+	if len(driverName) == 0 {
+		driverName = "memory"
+	}
+	kvs, err := db.OpenReadOnly(p.registry, driverName, "_default", db.NewPrefixConfig(config, "fsc.kvs.persistence.opts"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		report.Warn(validateComponent, "kvs", driverName, errors.New("kvs not created yet, it is created on startup"))
+	case err != nil:
+		report.Add(validateComponent, "kvs", driverName, err)
+	default:
+		report.Add(validateComponent, "kvs", driverName, nil)
+		if err := kvs.Close(); err != nil {
+			logger.Warnf("failed closing the kvs: %s", err)
+		}
+	}
+
+	if config.GetBool("fsc.grpc.enabled") {
+		p.validateServer(config, "grpc", "fsc.grpc.address", "fsc.grpc", opts, report)
+	}
+	if config.GetBool("fsc.web.enabled") {
+		p.validateServer(config, "web", "fsc.web.address", "fsc.web", opts, report)
+	}
+	if config.GetBool("fsc.operations.enabled") {
+		p.validateServer(config, "operations", "fsc.operations.listenAddress", "fsc.operations", opts, report)
+	}
+}
+
+// validateServer checks the TLS material of the server configured under the passed prefix, and binds its
+// listen address, if enabled by the options
+func (p *SDK) validateServer(config driver.ConfigService, name, addressKey, prefix string, opts *dryrun.Options, report *dryrun.Report) {
+	address := config.GetString(addressKey)
+	if len(address) == 0 {
+		report.Add(validateComponent, name, "", errors.Errorf("%s server enabled but %s not set", name, addressKey))
+	}
+	if config.GetBool(prefix + ".tls.enabled") {
+		report.Add(validateComponent, name+".tls.cert", config.GetPath(prefix+".tls.cert.file"), dryrun.CheckCertificates(config.GetPath(prefix+".tls.cert.file")))
+		report.Add(validateComponent, name+".tls.key", config.GetPath(prefix+".tls.key.file"), dryrun.CheckPrivateKey(config.GetPath(prefix+".tls.key.file")))
+		if config.GetBool(prefix + ".tls.clientAuthRequired") {
+			for _, path := range config.GetStringSlice(prefix + ".tls.clientRootCAs.files") {
+				report.Add(validateComponent, name+".tls.clientRootCA", config.TranslatePath(path), dryrun.CheckCertificates(config.TranslatePath(path)))
+			}
+		}
+	}
+	if opts != nil && opts.CheckPorts && len(address) != 0 {
+		report.Add(validateComponent, name+".listen", address, dryrun.Bind(address))
+	}
+}
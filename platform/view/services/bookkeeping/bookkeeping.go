@@ -0,0 +1,54 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package bookkeeping
+
+import (
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+)
+
+// Store is where the bookkeeping stores of the node write: the KVS itself, or a unit of work
+type Store interface {
+	// Put stores the passed state under the passed id
+	Put(id string, state interface{}) error
+	// Get unmarshals into the passed state the value of the passed id
+	Get(id string, state interface{}) error
+	// Exists returns true if the passed id has a value
+	Exists(id string) bool
+	// Delete deletes the passed id
+	Delete(id string) error
+}
+
+// UnitOfWork collects the writes of the bookkeeping stores of the node, such as the transaction store,
+// the checkpoints, the metadata, and the provenance, and commits them atomically: after a crash, either all
+// of them or none survive. The stores enlisted in a unit of work read its writes, the others read them
+// once committed.
+type UnitOfWork interface {
+	Store
+	// Hold locks the passed locker until the unit of work is committed or discarded. The stores updating a record
+	// with a read-modify-write hold its lock, so that the concurrent updates wait for the commit, and read its result.
+	Hold(l sync.Locker)
+	// Commit persists all the writes in a single transaction of the underlying storage
+	Commit() error
+	// Discard drops the writes, it does nothing once committed
+	Discard()
+}
+
+// Begin starts a unit of work on the bookkeeping stores of the node, they are all backed by its KVS
+func Begin(sp view.ServiceProvider) UnitOfWork {
+	return kvs.GetService(sp).NewBatch()
+}
+
+// Get returns the store the bookkeeping stores write to: the passed unit of work, if not nil, the KVS otherwise
+func Get(sp view.ServiceProvider, uow Store) Store {
+	if uow != nil {
+		return uow
+	}
+	return kvs.GetService(sp)
+}
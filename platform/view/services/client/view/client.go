@@ -11,17 +11,21 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/api"
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	grpc2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
 	hash2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view"
 	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
 	"github.com/pkg/errors"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -82,6 +86,9 @@ type client struct {
 	Time              TimeFunc
 	SigningIdentity   SigningIdentity
 	hasher            hash2.Hasher
+
+	versionLock   sync.RWMutex
+	serverVersion version.Version
 }
 
 func NewClient(config *Config, sID SigningIdentity, hasher hash2.Hasher) (*client, error) {
@@ -106,6 +113,29 @@ func NewClient(config *Config, sID SigningIdentity, hasher hash2.Hasher) (*clien
 }
 
 func (s *client) CallView(fid string, input []byte) (interface{}, error) {
+	return s.callView(context.Background(), fid, input)
+}
+
+// CallViewWithIdempotencyKey calls the passed view, the node runs it once for all the calls with the same key from
+// this client, the retries get the result of the first call
+func (s *client) CallViewWithIdempotencyKey(fid string, input []byte, key string) (interface{}, error) {
+	return s.callView(metadata.AppendToOutgoingContext(context.Background(), view2.IdempotencyKeyMetadata, key), fid, input)
+}
+
+// CallViewRoutedTo calls the passed view targeting the passed network and channel: the view runs in a context
+// carrying them, the ones it does not set are resolved from it. Empty values are left to the defaults of the node.
+func (s *client) CallViewRoutedTo(fid string, input []byte, network, channel string) (interface{}, error) {
+	var kv []string
+	if len(network) != 0 {
+		kv = append(kv, view2.NetworkMetadata, network)
+	}
+	if len(channel) != 0 {
+		kv = append(kv, view2.ChannelMetadata, channel)
+	}
+	return s.callView(metadata.AppendToOutgoingContext(context.Background(), kv...), fid, input)
+}
+
+func (s *client) callView(ctx context.Context, fid string, input []byte) (interface{}, error) {
 	logger.Infof("Calling view [%s] on input [%s]", fid, string(input))
 	payload := &protos2.Command_CallView{CallView: &protos2.CallView{
 		Fid:   fid,
@@ -116,7 +146,7 @@ func (s *client) CallView(fid string, input []byte) (interface{}, error) {
 		return nil, errors.Wrapf(err, "failed creating signed command for [%s,%s]", fid, string(input))
 	}
 
-	commandResp, err := s.processCommand(context.Background(), sc)
+	commandResp, err := s.processCommand(ctx, sc)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed process command for [%s,%s]", fid, string(input))
 	}
@@ -182,6 +212,82 @@ func (s *client) IsTxFinal(txid string, opts ...api.ServiceOption) error {
 	return errors.New(string(respPayload))
 }
 
+// EventStream is a subscription to the events committed on a channel of the node, see SubscribeEvents
+type EventStream struct {
+	conn   *grpc.ClientConn
+	cancel context.CancelFunc
+	stream protos2.ViewService_StreamCommandClient
+}
+
+// SubscribeEvents subscribes to the events of the node that pass the passed filter, following the passed resume token,
+// the resume token of the last event received, if not empty. The node must enable the subscriptions,
+// with `fabric.<network>.events.subscriptions.enabled`.
+func (s *client) SubscribeEvents(ctx context.Context, filter *protos2.EventFilter, resumeToken string) (*EventStream, error) {
+	payload := &protos2.Command_SubscribeEvents{SubscribeEvents: &protos2.SubscribeEvents{
+		Filter:      filter,
+		ResumeToken: resumeToken,
+	}}
+	sc, err := s.CreateSignedCommand(payload, s.SigningIdentity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed creating signed command to subscribe to the events at [%s]", s.Address)
+	}
+	conn, client, err := s.ViewServiceClient.CreateViewClient()
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, errors.Wrap(err, "failed creating view client")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := client.StreamCommand(ctx, sc)
+	if err != nil {
+		cancel()
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, errors.Wrapf(err, "failed subscribing to the events at [%s]", s.Address)
+	}
+	if header, err := stream.Header(); err == nil {
+		s.setServerVersion(header)
+	}
+	return &EventStream{conn: conn, cancel: cancel, stream: stream}, nil
+}
+
+// Recv returns the next event, waiting for it to be committed. The ResumeToken of the event resumes
+// the subscription after it. When the node ends the subscription, the error carries its gRPC status:
+// ResourceExhausted if the client fell too far behind, OutOfRange if the resume token expired,
+// InvalidArgument if it is invalid, and PermissionDenied if the client is not allowed to subscribe.
+func (e *EventStream) Recv() (*protos2.Event, error) {
+	scr, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	commandResp := &protos2.CommandResponse{}
+	if err := proto.Unmarshal(scr.Response, commandResp); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal command response")
+	}
+	if commandResp.GetErr() != nil {
+		// the status follows the error
+		if _, err := e.stream.Recv(); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return nil, errors.Errorf("error from view during subscription: %s", commandResp.GetErr().GetMessage())
+	}
+	if commandResp.GetEvent() == nil {
+		return nil, errors.Errorf("expected event, got [%T]", commandResp.GetPayload())
+	}
+	return commandResp.GetEvent(), nil
+}
+
+// Close ends the subscription
+func (e *EventStream) Close() error {
+	e.cancel()
+	if e.conn == nil {
+		return nil
+	}
+	return e.conn.Close()
+}
+
 // processCommand calls view client to send grpc request and returns a CommandResponse
 func (s *client) processCommand(ctx context.Context, sc *protos2.SignedCommand) (*protos2.CommandResponse, error) {
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
@@ -205,7 +311,9 @@ func (s *client) processCommand(ctx context.Context, sc *protos2.SignedCommand)
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("process command [%s]", sc.String())
 	}
-	scr, err := client.ProcessCommand(ctx, sc)
+	var header metadata.MD
+	scr, err := client.ProcessCommand(ctx, sc, grpc.Header(&header))
+	s.setServerVersion(header)
 	if err != nil {
 		logger.Errorf("failed view client process command [%s]", err)
 		return nil, errors.Wrap(err, "failed view client process command")
@@ -231,6 +339,24 @@ func (s *client) processCommand(ctx context.Context, sc *protos2.SignedCommand)
 	return commandResp, nil
 }
 
+// ServerVersion returns the version of the node, as told by its last response.
+// It is version.Unknown before the first response, or if the node does not tell its version.
+func (s *client) ServerVersion() version.Version {
+	s.versionLock.RLock()
+	defer s.versionLock.RUnlock()
+	return s.serverVersion
+}
+
+func (s *client) setServerVersion(header metadata.MD) {
+	values := header.Get(view2.VersionHeader)
+	if len(values) == 0 {
+		return
+	}
+	s.versionLock.Lock()
+	defer s.versionLock.Unlock()
+	s.serverVersion = version.Parse(values[0])
+}
+
 func (s *client) CreateSignedCommand(payload interface{}, signingIdentity SigningIdentity) (*protos2.SignedCommand, error) {
 	command, err := commandFromPayload(payload)
 	if err != nil {
@@ -292,6 +418,8 @@ func commandFromPayload(payload interface{}) (*protos2.Command, error) {
 		return &protos2.Command{Payload: t}, nil
 	case *protos2.Command_IsTxFinal:
 		return &protos2.Command{Payload: t}, nil
+	case *protos2.Command_SubscribeEvents:
+		return &protos2.Command{Payload: t}, nil
 	default:
 		return nil, errors.Errorf("command type not recognized: %T", t)
 	}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/hyperledger-labs/fabric-smart-client/pkg/api"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/introspection"
 	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
 	"github.com/pkg/errors"
 )
@@ -155,3 +156,44 @@ func (c *Client) ServerVersion() (string, error) {
 	}
 	return string(buff), nil
 }
+
+// Registry returns the description of what is registered in the node: the views, and the sections of the platforms
+func (c *Client) Registry() (introspection.Registry, error) {
+	buff, err := c.Get(introspection.RegistryURI)
+	if err != nil {
+		return nil, err
+	}
+	registry := introspection.Registry{}
+	if err := json.Unmarshal(buff, &registry); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal registry, response [%s]", string(buff))
+	}
+	return registry, nil
+}
+
+// Get returns the body of the response to a GET request to the passed URI, relative to the web server API.
+// The platforms use it to reach their admin endpoints.
+func (c *Client) Get(uri string) ([]byte, error) {
+	url := fmt.Sprintf("%s/v1%s", c.url, uri)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create http request to [%s]", url)
+	}
+	logger.Debugf("get using http request to [%s]", url)
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to process http request to [%s]", url)
+	}
+	if resp == nil {
+		return nil, errors.Errorf("failed to process http request to [%s], no response", url)
+	}
+	defer resp.Body.Close()
+	buff, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read response from http request to [%s]", url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to process http request to [%s], status code [%d], status [%s], response [%s]", url, resp.StatusCode, resp.Status, string(buff))
+	}
+	return buff, nil
+}
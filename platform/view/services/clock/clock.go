@@ -0,0 +1,272 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package clock
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.clock")
+
+const (
+	// DefaultThreshold is the skew above which the health check fails, unless configured otherwise
+	DefaultThreshold = time.Minute
+	// DefaultWindow is the age after which the timestamps observed are not considered anymore, unless configured otherwise
+	DefaultWindow = 10 * time.Minute
+	// DefaultNTPInterval is the time between two NTP queries, unless configured otherwise
+	DefaultNTPInterval = 5 * time.Minute
+	// DefaultNTPTimeout bounds each NTP query, unless configured otherwise
+	DefaultNTPTimeout = 5 * time.Second
+
+	// maxSamples bounds the timestamps retained
+	maxSamples = 1024
+)
+
+// Clock tells the local time
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System is the clock of the operating system
+var System Clock = systemClock{}
+
+var skewOpts = metrics.GaugeOpts{
+	Namespace:    "clock",
+	Name:         "skew",
+	Help:         "The estimated difference, in seconds, between the local clock and the clocks of the network, positive if the local clock is ahead.",
+	StatsdFormat: "%{#fqname}",
+}
+
+type sample struct {
+	at   time.Time
+	skew time.Duration
+}
+
+// Monitor estimates the skew of the local clock from the timestamps of the transactions committed recently and,
+// if configured, from the queries to an NTP server, whose estimate takes precedence.
+// The transactions are timestamped by their creators when they are assembled, the difference between the local time
+// at commit and their timestamp is the skew plus the time the transaction took to be committed. The estimate is then
+// the smallest difference observed in the window: the transactions of the blocks pulled to catch up do not inflate it.
+// A creator whose clock is ahead of the others makes the local clock appear behind.
+type Monitor struct {
+	clock     Clock
+	threshold time.Duration
+	window    time.Duration
+	gauge     metrics.Gauge
+
+	lock    sync.Mutex
+	samples []sample
+	ntp     *sample
+}
+
+// NewMonitor returns a monitor whose health check fails when the estimated skew exceeds the passed threshold.
+// The gauge is optional.
+func NewMonitor(clock Clock, threshold, window time.Duration, gauge metrics.Gauge) *Monitor {
+	if clock == nil {
+		clock = System
+	}
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Monitor{clock: clock, threshold: threshold, window: window, gauge: gauge}
+}
+
+// NewMonitorFromConfig returns the monitor configured with the following keys:
+// fsc.clock.threshold is the skew above which the health check fails, default 1m,
+// fsc.clock.window is the age after which the timestamps observed are not considered anymore, default 10m.
+func NewMonitorFromConfig(cs driver.ConfigService, p metrics.Provider) *Monitor {
+	var gauge metrics.Gauge
+	if p != nil {
+		gauge = p.NewGauge(skewOpts)
+	}
+	return NewMonitor(System, cs.GetDuration("fsc.clock.threshold"), cs.GetDuration("fsc.clock.window"), gauge)
+}
+
+// Observe records a timestamp set by a remote clock before now
+func (m *Monitor) Observe(remote time.Time) {
+	if m == nil {
+		return
+	}
+	now := m.clock.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.samples = append(m.samples, sample{at: now, skew: now.Sub(remote)})
+	if len(m.samples) > maxSamples {
+		m.samples = m.samples[len(m.samples)-maxSamples:]
+	}
+	m.updateMetrics(now)
+}
+
+// ObserveNTP records the skew measured against an NTP server
+func (m *Monitor) ObserveNTP(skew time.Duration) {
+	if m == nil {
+		return
+	}
+	now := m.clock.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.ntp = &sample{at: now, skew: skew}
+	m.updateMetrics(now)
+}
+
+// Skew returns the estimated skew of the local clock, positive if ahead, and false if it is not known
+func (m *Monitor) Skew() (time.Duration, bool) {
+	if m == nil {
+		return 0, false
+	}
+	now := m.clock.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.skew(now)
+}
+
+// skew returns the estimate at the passed time, m.lock must be held
+func (m *Monitor) skew(now time.Time) (time.Duration, bool) {
+	if m.ntp != nil && now.Sub(m.ntp.at) < m.window {
+		return m.ntp.skew, true
+	}
+	// the samples are in order of observation, the expired ones are dropped
+	i := 0
+	for i < len(m.samples) && now.Sub(m.samples[i].at) >= m.window {
+		i++
+	}
+	m.samples = m.samples[i:]
+	if len(m.samples) == 0 {
+		return 0, false
+	}
+	skew := time.Duration(math.MaxInt64)
+	for _, s := range m.samples {
+		if s.skew < skew {
+			skew = s.skew
+		}
+	}
+	return skew, true
+}
+
+// Exceeded returns the estimated skew if it exceeds the threshold
+func (m *Monitor) Exceeded() (time.Duration, bool) {
+	skew, ok := m.Skew()
+	if !ok || abs(skew) <= m.threshold {
+		return 0, false
+	}
+	return skew, true
+}
+
+// HealthCheck fails if the estimated skew exceeds the threshold
+func (m *Monitor) HealthCheck(context.Context) error {
+	if skew, ok := m.Exceeded(); ok {
+		return errors.Errorf("local clock appears to be %d seconds off, above the threshold of %d seconds", seconds(skew), seconds(m.threshold))
+	}
+	return nil
+}
+
+// Annotate adds to the passed certificate validation error a hint about the skew of the local clock,
+// if it exceeds the threshold
+func (m *Monitor) Annotate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if skew, ok := m.Exceeded(); ok {
+		return errors.WithMessagef(err, "local clock appears to be %d seconds off", seconds(skew))
+	}
+	return err
+}
+
+// StartNTP queries the passed NTP server at each interval, until the context is done
+func (m *Monitor) StartNTP(ctx context.Context, server string, interval, timeout time.Duration) {
+	if interval <= 0 {
+		interval = DefaultNTPInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultNTPTimeout
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			skew, err := QueryNTP(server, timeout, m.clock)
+			if err != nil {
+				logger.Warnf("failed querying NTP server [%s]: [%s]", server, err)
+			} else {
+				logger.Debugf("skew against NTP server [%s]: [%s]", server, skew)
+				m.ObserveNTP(skew)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// updateMetrics, m.lock must be held
+func (m *Monitor) updateMetrics(now time.Time) {
+	if m.gauge == nil {
+		return
+	}
+	if skew, ok := m.skew(now); ok {
+		m.gauge.Set(skew.Seconds())
+	}
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(abs(d).Round(time.Second) / time.Second)
+}
+
+var defaultMonitor atomic.Value
+
+// SetDefault sets the monitor used by Annotate
+func SetDefault(m *Monitor) {
+	defaultMonitor.Store(m)
+}
+
+// Annotate adds to the passed certificate validation error a hint about the skew of the local clock, if the default
+// monitor tells that it exceeds the threshold
+func Annotate(err error) error {
+	m, _ := defaultMonitor.Load().(*Monitor)
+	if m == nil {
+		return err
+	}
+	return m.Annotate(err)
+}
+
+// GetMonitor returns the monitor of the local clock, nil if not available
+func GetMonitor(sp view2.ServiceProvider) *Monitor {
+	s, err := sp.GetService(reflect.TypeOf((*Monitor)(nil)))
+	if err != nil {
+		return nil
+	}
+	return s.(*Monitor)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestMonitor(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	m := NewMonitor(clock, time.Minute, 10*time.Minute, nil)
+	_, ok := m.Skew()
+	assert.False(t, ok)
+	assert.NoError(t, m.HealthCheck(context.Background()))
+
+	// the local clock is 20 minutes ahead: a block pulled to catch up does not inflate the estimate
+	network := clock.Now().Add(-20 * time.Minute)
+	m.Observe(network.Add(-time.Hour))
+	m.Observe(network.Add(-2 * time.Second))
+	m.Observe(network.Add(-500 * time.Millisecond))
+	skew, ok := m.Skew()
+	assert.True(t, ok)
+	assert.Equal(t, 20*time.Minute+500*time.Millisecond, skew)
+
+	err := m.HealthCheck(context.Background())
+	assert.EqualError(t, err, "local clock appears to be 1201 seconds off, above the threshold of 60 seconds")
+	cause := errors.New("x509: certificate has expired or is not yet valid")
+	assert.EqualError(t, m.Annotate(cause), "local clock appears to be 1201 seconds off: x509: certificate has expired or is not yet valid")
+	assert.True(t, errors.Is(m.Annotate(cause), cause))
+
+	// the samples expire, a skew within the threshold is not reported
+	clock.Add(11 * time.Minute)
+	m.Observe(clock.Now().Add(-3 * time.Second))
+	skew, _ = m.Skew()
+	assert.Equal(t, 3*time.Second, skew)
+	assert.NoError(t, m.HealthCheck(context.Background()))
+	assert.Equal(t, cause, m.Annotate(cause))
+
+	// the NTP estimate takes precedence, a clock behind is reported as well
+	m.ObserveNTP(-5 * time.Minute)
+	_, ok = m.Exceeded()
+	assert.True(t, ok)
+	SetDefault(m)
+	defer SetDefault(nil)
+	assert.EqualError(t, Annotate(cause), "local clock appears to be 300 seconds off: x509: certificate has expired or is not yet valid")
+
+	// a nil monitor does nothing
+	var none *Monitor
+	none.Observe(time.Now())
+	assert.NoError(t, none.HealthCheck(context.Background()))
+	assert.Equal(t, cause, none.Annotate(cause))
+}
+
+func TestQueryNTP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// the server is 30 seconds behind the local clock
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	go func() {
+		buf := make([]byte, 48)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		response := make([]byte, 48)
+		response[0] = 0x1c
+		server := clock.Now().Add(-30 * time.Second)
+		for _, offset := range []int{32, 40} {
+			binary.BigEndian.PutUint32(response[offset:], uint32(server.Unix()+ntpEpochOffset))
+		}
+		_, _ = conn.WriteTo(response, addr)
+	}()
+
+	skew, err := QueryNTP(conn.LocalAddr().String(), time.Second, clock)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, skew)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package clock
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and the Unix epoch (1970)
+const ntpEpochOffset = 2208988800
+
+// QueryNTP returns the skew of the passed clock against the passed NTP server, positive if the clock is ahead.
+// It sends a single SNTP (RFC 4330) request.
+func QueryNTP(server string, timeout time.Duration, clock Clock) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed connecting to [%s]", server)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, errors.Wrapf(err, "failed setting deadline")
+	}
+
+	// leap indicator 0, version 3, mode 3 (client)
+	request := make([]byte, 48)
+	request[0] = 0x1b
+	sent := clock.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, errors.Wrapf(err, "failed sending request to [%s]", server)
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	received := clock.Now()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed reading response from [%s]", server)
+	}
+	if n < 48 {
+		return 0, errors.Errorf("short response from [%s], %d bytes", server, n)
+	}
+	if mode := response[0] & 0x7; mode != 4 && mode != 5 {
+		return 0, errors.Errorf("unexpected mode %d in response from [%s]", mode, server)
+	}
+
+	serverReceived := ntpTime(response[32:40])
+	serverSent := ntpTime(response[40:48])
+	// offset of the server against the clock, the delay of the network is assumed symmetric
+	offset := (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+	return -offset, nil
+}
+
+// ntpTime decodes a 64-bit NTP timestamp
+func ntpTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(secs, (frac*1e9)>>32)
+}
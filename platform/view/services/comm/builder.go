@@ -10,13 +10,13 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-core/protocol"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	"github.com/multiformats/go-multiaddr"
@@ -62,6 +62,7 @@ func newHost(ListenAddress string, keyDispenser PrivateKeyDispenser) (*P2PNode,
 		peers:            make(map[string]peer.AddrInfo),
 		incomingMessages: make(chan *messageWithStream),
 		streams:          make(map[peer.ID][]*streamHandler),
+		dialMutexes:      make(map[peer.ID]*sync.Mutex),
 		sessions:         make(map[string]*NetworkStreamSession),
 		isStopping:       false,
 	}
@@ -167,7 +168,7 @@ func (p *P2PNode) start() {
 		logger.Debugf("error while announcing: %s", err)
 	}
 
-	p.host.SetStreamHandler(protocol.ID(viewProtocol), p.handleStream())
+	p.setProtocols(nil)
 
 	p.finderWg.Add(1)
 	go p.startFinder()
@@ -9,9 +9,11 @@ package comm
 import (
 	"context"
 
+	"github.com/hyperledger/fabric/common/metrics"
 	"github.com/pkg/errors"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/comm/deadletter"
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
 
@@ -22,6 +24,7 @@ type EndpointService interface {
 
 type ConfigService interface {
 	GetString(key string) string
+	UnmarshalKey(key string, rawVal interface{}) error
 }
 
 type Service struct {
@@ -29,7 +32,10 @@ type Service struct {
 	EndpointService     EndpointService
 	ConfigService       ConfigService
 	DefaultIdentity     view2.Identity
-	Node                *P2PNode
+	MetricsProvider     metrics.Provider
+	// DeadLetters, if not nil, keeps the messages whose delivery failed permanently
+	DeadLetters *deadletter.Queue
+	Node        *P2PNode
 }
 
 func NewService(
@@ -37,12 +43,16 @@ func NewService(
 	endpointService EndpointService,
 	configService ConfigService,
 	defaultIdentity view2.Identity,
+	metricsProvider metrics.Provider,
+	deadLetters *deadletter.Queue,
 ) (*Service, error) {
 	s := &Service{
 		PrivateKeyDispenser: privateKeyDispenser,
 		EndpointService:     endpointService,
 		ConfigService:       configService,
 		DefaultIdentity:     defaultIdentity,
+		MetricsProvider:     metricsProvider,
+		DeadLetters:         deadLetters,
 	}
 	if err := s.init(); err != nil {
 		return nil, err
@@ -58,6 +68,14 @@ func (s *Service) Stop() {
 	s.Node.Stop()
 }
 
+// HealthCheck returns an error if the communication layer cannot accept incoming sessions
+func (s *Service) HealthCheck(ctx context.Context) error {
+	if s.Node == nil {
+		return errors.New("p2p node not initialized")
+	}
+	return s.Node.HealthCheck(ctx)
+}
+
 func (s *Service) NewSessionWithID(sessionID, contextID, endpoint string, pkid []byte, caller view2.Identity, msg *view2.Message) (view2.Session, error) {
 	return s.Node.NewSessionWithID(sessionID, contextID, endpoint, pkid, caller, msg)
 }
@@ -75,6 +93,26 @@ func (s *Service) DeleteSessions(sessionID string) {
 }
 
 func (s *Service) init() error {
+	compression, err := NewCompressionFromConfig(s.ConfigService)
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading p2p compression configuration")
+	}
+
+	heartbeat, err := NewHeartbeatFromConfig(s.ConfigService)
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading p2p heartbeat configuration")
+	}
+
+	multiplexing, err := NewMultiplexingFromConfig(s.ConfigService)
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading p2p multiplexing configuration")
+	}
+
+	protocols, err := NewProtocolsFromConfig(s.ConfigService, s.EndpointService)
+	if err != nil {
+		return errors.WithMessagef(err, "failed loading p2p view protocol configuration")
+	}
+
 	p2pListenAddress := s.ConfigService.GetString("fsc.p2p.listenAddress")
 	p2pBootstrapNode := s.ConfigService.GetString("fsc.p2p.bootstrapNode")
 	if len(p2pBootstrapNode) == 0 {
@@ -114,5 +152,20 @@ func (s *Service) init() error {
 			return errors.Wrapf(err, "failed to initialize node p2p manager [%s,%s]", p2pListenAddress, addr)
 		}
 	}
+
+	logger.Infof("p2p payload compression [%s], threshold [%d] bytes", compression.Algorithm, compression.Threshold)
+	s.Node.compression = compression
+	s.Node.heartbeat = heartbeat
+	s.Node.multiplexing = multiplexing
+	logger.Infof("p2p view protocol maximum version [%s], [%d] pinned endpoints", protocols.MaxVersion, len(protocols.Pinned))
+	s.Node.setProtocols(protocols)
+	if s.MetricsProvider != nil {
+		s.Node.metrics = NewMetrics(s.MetricsProvider)
+	}
+	if s.DeadLetters != nil {
+		logger.Infof("p2p dead letter queue enabled, [%d] letters queued", len(s.DeadLetters.List()))
+		s.Node.deadLetters = s.DeadLetters
+		s.DeadLetters.SetSender(&deadLetterSender{node: s.Node})
+	}
 	return nil
 }
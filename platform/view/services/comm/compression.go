@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CompressionNone disables the compression of the payloads
+	CompressionNone = "none"
+	// CompressionGzip compresses the payloads with gzip
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses the payloads with zstd
+	CompressionZstd = "zstd"
+	// DefaultCompressionThreshold is the default size, in bytes, above which the payloads are compressed
+	DefaultCompressionThreshold = 4096
+
+	// maxDecompressedPayloadSize bounds the size of a decompressed payload
+	maxDecompressedPayloadSize = 100 * 1024 * 1024
+)
+
+// Compression tells how the payloads of the session messages are compressed
+type Compression struct {
+	// Algorithm is one of CompressionNone, CompressionGzip, or CompressionZstd
+	Algorithm string
+	// Threshold is the size, in bytes, above which the payloads are compressed
+	Threshold int
+}
+
+// NewCompressionFromConfig returns the compression configured under `fsc.p2p.compression`
+func NewCompressionFromConfig(configService ConfigService) (*Compression, error) {
+	c := &Compression{
+		Algorithm: configService.GetString("fsc.p2p.compression.algorithm"),
+		Threshold: DefaultCompressionThreshold,
+	}
+	switch c.Algorithm {
+	case "":
+		c.Algorithm = CompressionNone
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm [%s], expected one of [%s, %s, %s]", c.Algorithm, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+	if threshold := configService.GetString("fsc.p2p.compression.threshold"); len(threshold) != 0 {
+		v, err := strconv.Atoi(threshold)
+		if err != nil || v < 0 {
+			return nil, errors.Errorf("invalid compression threshold [%s], expected a non-negative number of bytes", threshold)
+		}
+		c.Threshold = v
+	}
+	return c, nil
+}
+
+// compress returns the packet to be sent in place of the passed one, with the payload compressed
+// if it is larger than the threshold and compression makes it smaller
+func (c *Compression) compress(packet *ViewPacket) (*ViewPacket, error) {
+	if c == nil || c.Algorithm == CompressionNone || len(packet.Payload) < c.Threshold || len(packet.Compression) != 0 {
+		return packet, nil
+	}
+	compressed, err := compressPayload(c.Algorithm, packet.Payload)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed compressing payload with [%s]", c.Algorithm)
+	}
+	if len(compressed) >= len(packet.Payload) {
+		return packet, nil
+	}
+	return &ViewPacket{
+		SessionID:   packet.SessionID,
+		ContextID:   packet.ContextID,
+		Status:      packet.Status,
+		Payload:     compressed,
+		Caller:      packet.Caller,
+		Compression: c.Algorithm,
+		Metadata:    packet.Metadata,
+	}, nil
+}
+
+func compressPayload(algorithm string, payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch algorithm {
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressionZstd:
+		e, err := zstd.NewWriter(&buf, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		w = e
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm [%s]", algorithm)
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressPayload decompresses a payload received on a stream, regardless of the local configuration
+func decompressPayload(algorithm string, payload []byte) ([]byte, error) {
+	var r io.Reader
+	switch algorithm {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case CompressionZstd:
+		d, err := zstd.NewReader(bytes.NewReader(payload), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		r = d
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm [%s]", algorithm)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(r, maxDecompressedPayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxDecompressedPayloadSize {
+		return nil, errors.Errorf("decompressed payload exceeds [%d] bytes", maxDecompressedPayloadSize)
+	}
+	return raw, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapConfig map[string]string
+
+func (m mapConfig) GetString(key string) string {
+	return m[key]
+}
+
+func (m mapConfig) UnmarshalKey(key string, rawVal interface{}) error {
+	return nil
+}
+
+func TestCompressionConfig(t *testing.T) {
+	c, err := NewCompressionFromConfig(mapConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Compression{Algorithm: CompressionNone, Threshold: DefaultCompressionThreshold}, c)
+
+	c, err = NewCompressionFromConfig(mapConfig{
+		"fsc.p2p.compression.algorithm": "zstd",
+		"fsc.p2p.compression.threshold": "1024",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &Compression{Algorithm: CompressionZstd, Threshold: 1024}, c)
+
+	_, err = NewCompressionFromConfig(mapConfig{"fsc.p2p.compression.algorithm": "lz4"})
+	assert.Error(t, err)
+	_, err = NewCompressionFromConfig(mapConfig{"fsc.p2p.compression.threshold": "-1"})
+	assert.Error(t, err)
+}
+
+func TestCompressPayload(t *testing.T) {
+	payload := bytes.Repeat([]byte("a compressible session payload "), 1000)
+	for _, algorithm := range []string{CompressionGzip, CompressionZstd} {
+		c := &Compression{Algorithm: algorithm, Threshold: 1024}
+
+		// small payloads are sent as they are
+		small := &ViewPacket{SessionID: "s", Payload: []byte("small")}
+		packet, err := c.compress(small)
+		assert.NoError(t, err)
+		assert.Equal(t, small, packet)
+
+		original := &ViewPacket{SessionID: "s", ContextID: "c", Caller: "v", Status: 1, Payload: payload}
+		packet, err = c.compress(original)
+		assert.NoError(t, err)
+		assert.Equal(t, algorithm, packet.Compression)
+		assert.Less(t, len(packet.Payload), len(payload))
+		assert.Equal(t, "s", packet.SessionID)
+		assert.Equal(t, "c", packet.ContextID)
+		assert.Equal(t, "v", packet.Caller)
+		assert.Equal(t, int32(1), packet.Status)
+		// the original packet is left untouched, it might be sent again on another stream
+		assert.Equal(t, payload, original.Payload)
+		assert.Empty(t, original.Compression)
+
+		decompressed, err := decompressPayload(packet.Compression, packet.Payload)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, decompressed)
+	}
+
+	_, err := decompressPayload("lz4", payload)
+	assert.Error(t, err)
+	_, err = decompressPayload(CompressionGzip, payload)
+	assert.Error(t, err)
+
+	// without compression, nothing changes
+	var none *Compression
+	packet, err := none.compress(&ViewPacket{Payload: payload})
+	assert.NoError(t, err)
+	assert.Empty(t, packet.Compression)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/comm/deadletter"
+	"github.com/libp2p/go-libp2p-core/routing"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/pkg/errors"
+)
+
+// deadLetterReason returns the reason of the permanent failure of a send with the passed error,
+// empty if the failure might be transient
+func deadLetterReason(err error) string {
+	incompatible := &ErrIncompatibleProtocol{}
+	switch {
+	case errors.Is(err, ErrPayloadTooLarge):
+		return deadletter.ReasonPayloadTooLarge
+	case errors.As(err, &incompatible):
+		return deadletter.ReasonIncompatibleProtocol
+	case errors.Is(err, ErrUnknownEndpoint), errors.Is(err, swarm.ErrNoAddresses), errors.Is(err, routing.ErrNotFound):
+		return deadletter.ReasonUnknownEndpoint
+	case strings.Contains(err.Error(), "peer id mismatch"):
+		// the security transports of libp2p do not export their handshake errors
+		return deadletter.ReasonAuthentication
+	}
+	return ""
+}
+
+// deadLetter adds the passed packet, sent on the passed session, to the dead letter queue of the node,
+// if the passed error of its send is permanent
+func (p *P2PNode) deadLetter(session *NetworkStreamSession, packet *ViewPacket, err error) {
+	if p.deadLetters == nil {
+		return
+	}
+	reason := deadLetterReason(err)
+	if len(reason) == 0 {
+		return
+	}
+	if err := p.deadLetters.Add(&deadletter.Letter{
+		Reason:       reason,
+		Error:        err.Error(),
+		Endpoint:     session.endpointAddress,
+		PKID:         session.endpointID,
+		SessionID:    packet.SessionID,
+		ContextID:    packet.ContextID,
+		CallerViewID: packet.Caller,
+		Status:       packet.Status,
+		Payload:      packet.Payload,
+		Metadata:     packet.Metadata,
+	}); err != nil {
+		logger.Errorf("failed dead-lettering message on session [%s]: [%s]", packet.SessionID, err)
+	}
+}
+
+// deadLetterSender delivers again the dead letters, on their session if still open
+type deadLetterSender struct {
+	node *P2PNode
+}
+
+func (s *deadLetterSender) Redeliver(letter *deadletter.Letter) error {
+	packet := &ViewPacket{
+		ContextID: letter.ContextID,
+		SessionID: letter.SessionID,
+		Caller:    letter.CallerViewID,
+		Status:    letter.Status,
+		Payload:   letter.Payload,
+		Metadata:  letter.Metadata,
+	}
+	s.node.sessionsMutex.Lock()
+	session, ok := s.node.sessions[computeInternalSessionID(letter.SessionID, letter.Endpoint, letter.PKID)]
+	s.node.sessionsMutex.Unlock()
+	if ok {
+		// the session sends on the stream it is pinned to, after the dead letters preceding this one
+		return s.node.sendOnSession(session, packet)
+	}
+	return s.node.sendTo(string(letter.PKID), letter.Endpoint, packet)
+}
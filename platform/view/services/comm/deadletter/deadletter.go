@@ -0,0 +1,415 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package deadletter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCapacity bounds the letters kept, unless configured otherwise
+	DefaultCapacity = 1000
+	// DefaultRetention is how long the letters are kept, unless configured otherwise
+	DefaultRetention = 7 * 24 * time.Hour
+
+	// ReasonUnknownEndpoint is the reason of the messages to endpoints that cannot be resolved or reached at any address
+	ReasonUnknownEndpoint = "unknown_endpoint"
+	// ReasonAuthentication is the reason of the messages to endpoints whose identity did not match the one expected
+	ReasonAuthentication = "authentication"
+	// ReasonPayloadTooLarge is the reason of the messages whose payload exceeds the size the endpoints accept
+	ReasonPayloadTooLarge = "payload_too_large"
+	// ReasonIncompatibleProtocol is the reason of the messages to endpoints with no common view protocol version
+	ReasonIncompatibleProtocol = "incompatible_protocol"
+
+	letterPrefix = "fsc.comm.deadletter"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.comm.deadletter")
+
+// ErrNotFound is returned when a letter is not in the queue
+var ErrNotFound = errors.New("dead letter not found")
+
+// Letter is a message whose delivery failed permanently
+type Letter struct {
+	// ID identifies the letter in the queue
+	ID string
+	// Seq orders the letters, in the order they have been added
+	Seq uint64
+	// Reason is the class of the failure, one of the Reason constants
+	Reason string
+	// Error is the error of the last delivery attempted
+	Error string
+	// Endpoint and PKID are the address and the libp2p ID of the counterparty
+	Endpoint string
+	PKID     []byte
+	// SessionID, ContextID, and CallerViewID are the ones of the session the message has been sent on
+	SessionID    string
+	ContextID    string
+	CallerViewID string
+	// Status, Payload, and Metadata are the ones of the message
+	Status   int32
+	Payload  []byte `json:",omitempty"`
+	Metadata map[string]string
+	// PayloadSize is the size of the payload, which is not returned when listing the letters
+	PayloadSize int
+	// Stored is when the letter has been added
+	Stored time.Time
+	// Replays is the number of failed replays
+	Replays int
+}
+
+// Sender delivers again the messages of the letters replayed
+type Sender interface {
+	// Redeliver sends the message of the passed letter to its counterparty, on the session it has been sent on
+	Redeliver(letter *Letter) error
+}
+
+// ReplayResult tells the outcome of a replay
+type ReplayResult struct {
+	// Delivered are the IDs of the letters delivered, they are removed from the queue
+	Delivered []string
+	// Failed maps the IDs of the letters whose delivery failed again to the error
+	Failed map[string]string
+	// Skipped are the IDs of the letters not attempted, as a preceding letter of their session failed
+	Skipped []string
+}
+
+type config struct {
+	Enabled   bool
+	Capacity  int
+	Retention time.Duration
+}
+
+// ConfigService gives access to the configuration of the queue
+type ConfigService interface {
+	UnmarshalKey(key string, rawVal interface{}) error
+}
+
+// Queue keeps, in the kvs, the messages whose delivery failed permanently, so that they can be inspected,
+// replayed once the cause is fixed, or purged. The queue keeps the letters for the retention time, up to the capacity:
+// the oldest letters are evicted first.
+// The letters of a session are replayed in the order they have been added, and the replay of a session stops at its
+// first failure, so that a message is never delivered before the ones sent before it on the same session.
+// A nil Queue keeps nothing.
+type Queue struct {
+	kvs       *kvs.KVS
+	capacity  int
+	retention time.Duration
+	metrics   *Metrics
+	now       func() time.Time
+
+	lock sync.Mutex
+	// letters are the letters in the queue, without their payload, oldest first
+	letters []*Letter
+	next    uint64
+	sender  Sender
+	// replayLock serializes the replays
+	replayLock sync.Mutex
+}
+
+// New returns a queue storing the letters in the passed kvs, the letters stored by a previous instance are loaded
+func New(kvss *kvs.KVS, capacity int, retention time.Duration, m *Metrics) (*Queue, error) {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	q := &Queue{
+		kvs:       kvss,
+		capacity:  capacity,
+		retention: retention,
+		metrics:   m,
+		now:       time.Now,
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// NewFromConfig returns the queue configured with the following keys, nil if not enabled:
+// fsc.p2p.deadLetters.enabled enables the queue,
+// fsc.p2p.deadLetters.capacity bounds the letters kept, default 1000,
+// fsc.p2p.deadLetters.retention is how long the letters are kept, default 168h.
+func NewFromConfig(cs ConfigService, kvss *kvs.KVS, p metrics.Provider) (*Queue, error) {
+	c := &config{}
+	if err := cs.UnmarshalKey("fsc.p2p.deadLetters", c); err != nil {
+		return nil, errors.Wrapf(err, "failed loading the dead letter queue configuration")
+	}
+	if !c.Enabled {
+		return nil, nil
+	}
+	var m *Metrics
+	if p != nil {
+		m = NewMetrics(p)
+	}
+	return New(kvss, c.Capacity, c.Retention, m)
+}
+
+// SetSender sets the sender delivering the letters replayed
+func (q *Queue) SetSender(sender Sender) {
+	q.lock.Lock()
+	q.sender = sender
+	q.lock.Unlock()
+}
+
+// load rebuilds the index of the letters, dropping the expired ones and the ones beyond the capacity
+func (q *Queue) load() error {
+	it, err := q.kvs.GetByPartialCompositeID(letterPrefix, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed loading dead letters")
+	}
+	defer it.Close()
+	for it.HasNext() {
+		letter := &Letter{}
+		if _, err := it.Next(letter); err != nil {
+			return errors.Wrapf(err, "failed loading dead letters")
+		}
+		letter.Payload = nil
+		q.letters = append(q.letters, letter)
+		if letter.Seq >= q.next {
+			q.next = letter.Seq + 1
+		}
+	}
+	sort.Slice(q.letters, func(i, j int) bool { return q.letters[i].Seq < q.letters[j].Seq })
+	q.evict()
+	q.metrics.queued(len(q.letters))
+	return nil
+}
+
+// Add adds the passed letter to the queue, evicting the oldest letters if the queue is full
+func (q *Queue) Add(letter *Letter) error {
+	if q == nil {
+		return nil
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	l := *letter
+	l.Seq = q.next
+	l.ID = strconv.FormatUint(l.Seq, 10)
+	l.PayloadSize = len(l.Payload)
+	l.Stored = q.now()
+	if err := q.kvs.Put(q.key(l.ID), &l); err != nil {
+		return errors.Wrapf(err, "failed storing dead letter for session [%s]", l.SessionID)
+	}
+	q.next++
+	l.Payload = nil
+	q.letters = append(q.letters, &l)
+	logger.Warnf("message on session [%s] to [%s] dead-lettered as [%s], reason [%s]: %s", l.SessionID, l.Endpoint, l.ID, l.Reason, l.Error)
+	q.metrics.added(l.Reason)
+	q.evict()
+	q.metrics.queued(len(q.letters))
+	return nil
+}
+
+// List returns the letters in the queue, oldest first, without their payload
+func (q *Queue) List() []*Letter {
+	if q == nil {
+		return nil
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.evict()
+	res := make([]*Letter, len(q.letters))
+	for i, letter := range q.letters {
+		l := *letter
+		res[i] = &l
+	}
+	return res
+}
+
+// Get returns the letter with the passed ID, with its payload
+func (q *Queue) Get(id string) (*Letter, error) {
+	if q == nil {
+		return nil, ErrNotFound
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.index(id) < 0 {
+		return nil, ErrNotFound
+	}
+	letter := &Letter{}
+	if err := q.kvs.Get(q.key(id), letter); err != nil {
+		return nil, errors.Wrapf(err, "failed loading dead letter [%s]", id)
+	}
+	return letter, nil
+}
+
+// Purge removes the letters with the passed IDs, all the letters if none is passed. It returns the letters removed.
+func (q *Queue) Purge(ids ...string) ([]string, error) {
+	if q == nil {
+		return nil, nil
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(ids) == 0 {
+		for _, letter := range q.letters {
+			ids = append(ids, letter.ID)
+		}
+	}
+	var purged []string
+	for _, id := range ids {
+		if q.index(id) < 0 {
+			continue
+		}
+		if err := q.remove(id); err != nil {
+			return purged, err
+		}
+		purged = append(purged, id)
+	}
+	q.metrics.queued(len(q.letters))
+	return purged, nil
+}
+
+// Replay delivers again the letters with the passed IDs, all the letters if none is passed.
+// The letters of a session are delivered in the order they have been added. The delivered letters are removed,
+// the ones failing again stay in the queue, with the new error, and so do the following letters of their session.
+func (q *Queue) Replay(ids ...string) (*ReplayResult, error) {
+	if q == nil {
+		return nil, errors.New("dead letter queue not enabled")
+	}
+	q.replayLock.Lock()
+	defer q.replayLock.Unlock()
+
+	q.lock.Lock()
+	sender := q.sender
+	var selected []*Letter
+	if len(ids) == 0 {
+		selected = append(selected, q.letters...)
+	} else {
+		for _, id := range ids {
+			i := q.index(id)
+			if i < 0 {
+				q.lock.Unlock()
+				return nil, errors.Wrapf(ErrNotFound, "letter [%s]", id)
+			}
+			selected = append(selected, q.letters[i])
+		}
+	}
+	q.lock.Unlock()
+	if sender == nil {
+		return nil, errors.New("no sender set for the dead letter queue")
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Seq < selected[j].Seq })
+
+	res := &ReplayResult{Failed: map[string]string{}}
+	// the sessions whose replay stopped, by counterparty and session
+	stopped := map[string]bool{}
+	for _, s := range selected {
+		session := fmt.Sprintf("%x.%s", s.PKID, s.SessionID)
+		if stopped[session] {
+			res.Skipped = append(res.Skipped, s.ID)
+			continue
+		}
+		letter, err := q.Get(s.ID)
+		if err != nil {
+			// purged or evicted meanwhile
+			res.Skipped = append(res.Skipped, s.ID)
+			continue
+		}
+		if err := sender.Redeliver(letter); err != nil {
+			stopped[session] = true
+			res.Failed[s.ID] = err.Error()
+			q.metrics.replayed("failed")
+			q.failed(letter, err)
+			continue
+		}
+		res.Delivered = append(res.Delivered, s.ID)
+		q.metrics.replayed("delivered")
+		q.lock.Lock()
+		if q.index(s.ID) >= 0 {
+			if err := q.remove(s.ID); err != nil {
+				logger.Errorf("failed removing dead letter [%s] delivered: [%s]", s.ID, err)
+			}
+		}
+		q.metrics.queued(len(q.letters))
+		q.lock.Unlock()
+	}
+	return res, nil
+}
+
+// failed records the error of the failed replay of the passed letter
+func (q *Queue) failed(letter *Letter, err error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	i := q.index(letter.ID)
+	if i < 0 {
+		return
+	}
+	letter.Error = err.Error()
+	letter.Replays++
+	if err := q.kvs.Put(q.key(letter.ID), letter); err != nil {
+		logger.Errorf("failed updating dead letter [%s]: [%s]", letter.ID, err)
+		return
+	}
+	q.letters[i].Error = letter.Error
+	q.letters[i].Replays = letter.Replays
+}
+
+// evict deletes the letters expired and the oldest ones beyond the capacity. q.lock must be held
+func (q *Queue) evict() {
+	now := q.now()
+	n := 0
+	for n < len(q.letters) {
+		cause := ""
+		switch {
+		case len(q.letters)-n > q.capacity:
+			cause = "capacity"
+		case now.Sub(q.letters[n].Stored) >= q.retention:
+			cause = "retention"
+		}
+		if len(cause) == 0 {
+			break
+		}
+		if err := q.kvs.Delete(q.key(q.letters[n].ID)); err != nil {
+			logger.Errorf("failed evicting dead letter [%s]: [%s]", q.letters[n].ID, err)
+		}
+		logger.Warnf("dead letter [%s] on session [%s] evicted, cause [%s]", q.letters[n].ID, q.letters[n].SessionID, cause)
+		q.metrics.evicted(cause)
+		n++
+	}
+	q.letters = q.letters[n:]
+}
+
+// remove deletes the letter with the passed ID. q.lock must be held
+func (q *Queue) remove(id string) error {
+	if err := q.kvs.Delete(q.key(id)); err != nil {
+		return errors.Wrapf(err, "failed deleting dead letter [%s]", id)
+	}
+	i := q.index(id)
+	q.letters = append(q.letters[:i], q.letters[i+1:]...)
+	return nil
+}
+
+// index returns the position of the letter with the passed ID, -1 if not in the queue. q.lock must be held
+func (q *Queue) index(id string) int {
+	for i, letter := range q.letters {
+		if letter.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queue) key(id string) string {
+	return kvs.CreateCompositeKeyOrPanic(letterPrefix, []string{id})
+}
@@ -0,0 +1,208 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package deadletter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// sender records the payloads delivered, and fails the ones of the sessions of broken
+type sender struct {
+	delivered []string
+	broken    map[string]bool
+}
+
+func (s *sender) Redeliver(letter *Letter) error {
+	if s.broken[letter.SessionID] {
+		return errors.Errorf("endpoint [%s] still unknown", letter.Endpoint)
+	}
+	s.delivered = append(s.delivered, string(letter.Payload))
+	return nil
+}
+
+func newKVS(t *testing.T) *kvs.KVS {
+	kvss, err := kvs.NewWithConfig(registry.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	return kvss
+}
+
+func add(t *testing.T, q *Queue, session, payload string) {
+	assert.NoError(t, q.Add(&Letter{
+		Reason:    ReasonUnknownEndpoint,
+		Error:     "unknown endpoint",
+		Endpoint:  "bob",
+		PKID:      []byte("bobID"),
+		SessionID: session,
+		Payload:   []byte(payload),
+	}))
+}
+
+func ids(letters []*Letter) []string {
+	var res []string
+	for _, letter := range letters {
+		res = append(res, letter.ID)
+	}
+	return res
+}
+
+func TestQueue(t *testing.T) {
+	kvss := newKVS(t)
+	q, err := New(kvss, 3, time.Hour, NewMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+	now := time.Now()
+	q.now = func() time.Time { return now }
+
+	add(t, q, "s1", "m0")
+	add(t, q, "s1", "m1")
+	add(t, q, "s2", "m2")
+	letters := q.List()
+	assert.Equal(t, []string{"0", "1", "2"}, ids(letters))
+	assert.Nil(t, letters[0].Payload)
+	assert.Equal(t, 2, letters[0].PayloadSize)
+	letter, err := q.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("m1"), letter.Payload)
+	assert.Equal(t, "s1", letter.SessionID)
+	_, err = q.Get("7")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the oldest letter is evicted once the queue is full
+	add(t, q, "s1", "m3")
+	assert.Equal(t, []string{"1", "2", "3"}, ids(q.List()))
+
+	// the letters are persisted
+	q, err = New(kvss, 3, time.Hour, nil)
+	assert.NoError(t, err)
+	q.now = func() time.Time { return now }
+	assert.Equal(t, []string{"1", "2", "3"}, ids(q.List()))
+	add(t, q, "s3", "m4")
+	assert.Equal(t, []string{"2", "3", "4"}, ids(q.List()))
+
+	// and they expire
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, q.List())
+	q, err = New(kvss, 3, time.Hour, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, q.List())
+}
+
+func TestReplay(t *testing.T) {
+	q, err := New(newKVS(t), 10, time.Hour, nil)
+	assert.NoError(t, err)
+	_, err = q.Replay()
+	assert.Error(t, err)
+
+	s := &sender{broken: map[string]bool{"s2": true}}
+	q.SetSender(s)
+	add(t, q, "s1", "a0")
+	add(t, q, "s2", "b0")
+	add(t, q, "s1", "a1")
+	add(t, q, "s2", "b1")
+	add(t, q, "s3", "c0")
+
+	// the letters of a session are delivered in order, the replay of a session stops at its first failure
+	res, err := q.Replay("4", "2", "0", "3", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a0", "a1", "c0"}, s.delivered)
+	assert.Equal(t, []string{"0", "2", "4"}, res.Delivered)
+	assert.Equal(t, map[string]string{"1": "endpoint [bob] still unknown"}, res.Failed)
+	assert.Equal(t, []string{"3"}, res.Skipped)
+	letters := q.List()
+	assert.Equal(t, []string{"1", "3"}, ids(letters))
+	assert.Equal(t, 1, letters[0].Replays)
+	assert.Equal(t, 0, letters[1].Replays)
+
+	_, err = q.Replay("0")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// once the endpoint is fixed, the session is delivered
+	s.broken = nil
+	res, err = q.Replay()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "3"}, res.Delivered)
+	assert.Equal(t, []string{"a0", "a1", "c0", "b0", "b1"}, s.delivered)
+	assert.Empty(t, q.List())
+
+	add(t, q, "s1", "a2")
+	add(t, q, "s1", "a3")
+	purged, err := q.Purge("5", "9")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5"}, purged)
+	purged, err = q.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"6"}, purged)
+	assert.Empty(t, q.List())
+}
+
+func TestHandler(t *testing.T) {
+	q, err := New(newKVS(t), 10, time.Hour, nil)
+	assert.NoError(t, err)
+	q.SetSender(&sender{})
+	h := web.NewHttpHandler(logger)
+	RegisterHandlers(h, q)
+	server := httptest.NewServer(h)
+	defer server.Close()
+	add(t, q, "s1", "a0")
+	add(t, q, "s1", "a1")
+	add(t, q, "s2", "b0")
+
+	get := func(path string, res interface{}) int {
+		resp, err := http.Get(server.URL + "/v1" + URI + path)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(res))
+		}
+		return resp.StatusCode
+	}
+	post := func(action string, request *Request, res interface{}) int {
+		raw, err := json.Marshal(request)
+		assert.NoError(t, err)
+		resp, err := http.Post(server.URL+"/v1"+URI+"/"+action, "application/json", bytes.NewReader(raw))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(res))
+		}
+		return resp.StatusCode
+	}
+
+	var letters []*Letter
+	assert.Equal(t, http.StatusOK, get("", &letters))
+	assert.Equal(t, []string{"0", "1", "2"}, ids(letters))
+	assert.Nil(t, letters[0].Payload)
+	letter := &Letter{}
+	assert.Equal(t, http.StatusOK, get("/1", letter))
+	assert.Equal(t, []byte("a1"), letter.Payload)
+	assert.Equal(t, http.StatusNotFound, get("/7", letter))
+
+	res := &ReplayResult{}
+	assert.Equal(t, http.StatusOK, post("replay", &Request{IDs: []string{"0"}}, res))
+	assert.Equal(t, []string{"0"}, res.Delivered)
+	assert.Equal(t, http.StatusNotFound, post("replay", &Request{IDs: []string{"0"}}, res))
+
+	purged := &PurgeResult{}
+	assert.Equal(t, http.StatusBadRequest, post("purge", &Request{}, purged))
+	assert.Equal(t, http.StatusOK, post("purge", &Request{All: true}, purged))
+	assert.Equal(t, []string{"1", "2"}, purged.Purged)
+	assert.Equal(t, http.StatusOK, get("", &letters))
+	assert.Empty(t, letters)
+}
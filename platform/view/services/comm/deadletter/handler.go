@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package deadletter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+// URI is the URI, relative to the web server API, of the dead letter queue of the node.
+// GET lists the letters, without their payload, and GET URI/{id} returns a letter with its payload.
+// POST URI/replay delivers again the letters whose IDs are in the request, all the letters if none,
+// POST URI/purge removes the letters whose IDs are in the request, all the letters if all is set.
+const URI = "/admin/comm/deadletters"
+
+// Request selects the letters to replay or purge
+type Request struct {
+	IDs []string `json:"ids,omitempty"`
+	// All must be set to purge all the letters
+	All bool `json:"all,omitempty"`
+}
+
+// PurgeResult tells the letters purged
+type PurgeResult struct {
+	Purged []string
+}
+
+// Handler serves the dead letter queue of the node
+type Handler struct {
+	queue *Queue
+}
+
+func NewHandler(queue *Queue) *Handler {
+	return &Handler{queue: queue}
+}
+
+// RegisterHandlers registers the URIs of the passed queue with the passed handler
+func RegisterHandlers(h *web.HttpHandler, queue *Queue) {
+	handler := NewHandler(queue)
+	h.RegisterURI(URI, http.MethodGet, handler)
+	h.RegisterURI(URI+"/{id}", http.MethodGet, handler)
+	h.RegisterURI(URI+"/{action:replay|purge}", http.MethodPost, handler)
+}
+
+func (h *Handler) ParsePayload(bytes []byte) (interface{}, error) {
+	request := &Request{}
+	if len(bytes) == 0 {
+		return request, nil
+	}
+	if err := json.Unmarshal(bytes, request); err != nil {
+		return nil, errors.Wrapf(err, "invalid dead letter request")
+	}
+	return request, nil
+}
+
+func (h *Handler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	if h.queue == nil {
+		return &web.ResponseErr{Reason: "dead letter queue not enabled"}, http.StatusNotFound
+	}
+	if context.Req.Method != http.MethodPost {
+		id, ok := context.Vars["id"]
+		if !ok {
+			return h.queue.List(), http.StatusOK
+		}
+		letter, err := h.queue.Get(id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return &web.ResponseErr{Reason: "dead letter [" + id + "] not found"}, http.StatusNotFound
+			}
+			return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+		}
+		return letter, http.StatusOK
+	}
+
+	request, _ := context.Query.(*Request)
+	if request == nil {
+		request = &Request{}
+	}
+	if context.Vars["action"] == "purge" {
+		if len(request.IDs) == 0 && !request.All {
+			return &web.ResponseErr{Reason: "no dead letter to purge, set all to purge all of them"}, http.StatusBadRequest
+		}
+		purged, err := h.queue.Purge(request.IDs...)
+		if err != nil {
+			return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+		}
+		return &PurgeResult{Purged: purged}, http.StatusOK
+	}
+	res, err := h.queue.Replay(request.IDs...)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return &web.ResponseErr{Reason: err.Error()}, http.StatusNotFound
+		}
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusConflict
+	}
+	return res, http.StatusOK
+}
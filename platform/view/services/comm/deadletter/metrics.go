@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package deadletter
+
+import (
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+var (
+	lettersOpts = metrics.CounterOpts{
+		Namespace:    "comm",
+		Subsystem:    "deadletter",
+		Name:         "letters",
+		Help:         "The number of messages dead-lettered, by reason of the failure of their delivery.",
+		LabelNames:   []string{"reason"},
+		StatsdFormat: "%{#fqname}.%{reason}",
+	}
+	evictedOpts = metrics.CounterOpts{
+		Namespace:    "comm",
+		Subsystem:    "deadletter",
+		Name:         "evicted",
+		Help:         "The number of dead letters evicted, by cause: capacity or retention.",
+		LabelNames:   []string{"cause"},
+		StatsdFormat: "%{#fqname}.%{cause}",
+	}
+	replayedOpts = metrics.CounterOpts{
+		Namespace:    "comm",
+		Subsystem:    "deadletter",
+		Name:         "replayed",
+		Help:         "The number of dead letters replayed, by outcome: delivered or failed.",
+		LabelNames:   []string{"outcome"},
+		StatsdFormat: "%{#fqname}.%{outcome}",
+	}
+	queuedOpts = metrics.GaugeOpts{
+		Namespace:    "comm",
+		Subsystem:    "deadletter",
+		Name:         "queued",
+		Help:         "The number of dead letters in the queue.",
+		StatsdFormat: "%{#fqname}",
+	}
+)
+
+// Metrics reports the letters added, evicted, and replayed, and the size of the queue
+type Metrics struct {
+	Letters  metrics.Counter
+	Evicted  metrics.Counter
+	Replayed metrics.Counter
+	Queued   metrics.Gauge
+}
+
+func NewMetrics(p metrics.Provider) *Metrics {
+	return &Metrics{
+		Letters:  p.NewCounter(lettersOpts),
+		Evicted:  p.NewCounter(evictedOpts),
+		Replayed: p.NewCounter(replayedOpts),
+		Queued:   p.NewGauge(queuedOpts),
+	}
+}
+
+func (m *Metrics) added(reason string) {
+	if m == nil {
+		return
+	}
+	m.Letters.With("reason", reason).Add(1)
+}
+
+func (m *Metrics) evicted(cause string) {
+	if m == nil {
+		return
+	}
+	m.Evicted.With("cause", cause).Add(1)
+}
+
+func (m *Metrics) replayed(outcome string) {
+	if m == nil {
+		return
+	}
+	m.Replayed.With("outcome", outcome).Add(1)
+}
+
+func (m *Metrics) queued(n int) {
+	if m == nil {
+		return
+	}
+	m.Queued.Set(float64(n))
+}
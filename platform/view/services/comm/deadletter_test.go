@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/comm/deadletter"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetterReason(t *testing.T) {
+	assert.Equal(t, deadletter.ReasonPayloadTooLarge, deadLetterReason(errors.Wrapf(ErrPayloadTooLarge, "packet of [%d] bytes", 1)))
+	assert.Equal(t, deadletter.ReasonIncompatibleProtocol, deadLetterReason(&ErrIncompatibleProtocol{Peer: "bob"}))
+	assert.Equal(t, deadletter.ReasonUnknownEndpoint, deadLetterReason(errors.Wrapf(ErrUnknownEndpoint, "invalid endpoint ID")))
+	assert.Equal(t, deadletter.ReasonUnknownEndpoint, deadLetterReason(errors.Wrapf(swarm.ErrNoAddresses, "failed to create new stream")))
+	assert.Equal(t, deadletter.ReasonAuthentication, deadLetterReason(errors.New("failed to dial: peer id mismatch: expected bob")))
+	assert.Empty(t, deadLetterReason(errors.Wrapf(ErrConnectionLost, "failed writing")))
+}
+
+func TestDeadLetters(t *testing.T) {
+	bootstrapNode, node, _, nodeID := setupTwoNodesFromFiles(t)
+	kvss, err := kvs.NewWithConfig(registry.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	queue, err := deadletter.New(kvss, 10, time.Hour, nil)
+	assert.NoError(t, err)
+	bootstrapNode.deadLetters = queue
+	queue.SetSender(&deadLetterSender{node: bootstrapNode})
+	ctx := context.Background()
+	bootstrapNode.Start(ctx)
+	node.Start(ctx)
+	defer bootstrapNode.Stop()
+	defer node.Stop()
+
+	master, err := node.MasterSession()
+	assert.NoError(t, err)
+	session, err := bootstrapNode.NewSession("", "", "", []byte(nodeID))
+	assert.NoError(t, err)
+	defer session.Close()
+
+	// the payloads the node would not read are dead-lettered, not sent
+	err = session.Send(make([]byte, maxPacketSize+1))
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+	unknown, err := bootstrapNode.NewSession("", "", "", []byte("not a libp2p ID"))
+	assert.NoError(t, err)
+	defer unknown.Close()
+	assert.ErrorIs(t, unknown.Send([]byte("ciao")), ErrUnknownEndpoint)
+	letters := queue.List()
+	if assert.Len(t, letters, 2) {
+		assert.Equal(t, deadletter.ReasonPayloadTooLarge, letters[0].Reason)
+		assert.Equal(t, session.Info().ID, letters[0].SessionID)
+		assert.Equal(t, maxPacketSize+1, letters[0].PayloadSize)
+		assert.Equal(t, deadletter.ReasonUnknownEndpoint, letters[1].Reason)
+	}
+	res, err := queue.Replay()
+	assert.NoError(t, err)
+	assert.Len(t, res.Failed, 2)
+	_, err = queue.Purge()
+	assert.NoError(t, err)
+
+	// a letter replayed is delivered on its session
+	assert.NoError(t, queue.Add(&deadletter.Letter{
+		Reason:    deadletter.ReasonUnknownEndpoint,
+		PKID:      []byte(nodeID),
+		SessionID: session.Info().ID,
+		Payload:   []byte("replayed"),
+	}))
+	res, err = queue.Replay()
+	assert.NoError(t, err)
+	assert.Len(t, res.Delivered, 1)
+	select {
+	case msg := <-master.Receive():
+		assert.Equal(t, []byte("replayed"), msg.Payload)
+		assert.Equal(t, session.Info().ID, msg.SessionID)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the node did not receive the letter replayed")
+	}
+	assert.Empty(t, queue.List())
+}
@@ -0,0 +1,268 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultHeartbeatInterval is the default interval between two heartbeats on a session
+	DefaultHeartbeatInterval = 10 * time.Second
+	// DefaultHeartbeatMisses is the default number of consecutive heartbeats a remote end can miss before being unreachable
+	DefaultHeartbeatMisses = 3
+
+	// heartbeatStatus is the status of the packets carrying the heartbeats, they are never delivered to the views
+	heartbeatStatus = 100
+	// maxNotifications bounds the liveness notifications waiting to be delivered to a session
+	maxNotifications = 16
+)
+
+// Heartbeat holds the defaults of the heartbeats on the sessions that enable them
+type Heartbeat struct {
+	// Interval is the interval between two heartbeats
+	Interval time.Duration
+	// Misses is the number of consecutive heartbeats a remote end can miss before being unreachable
+	Misses int
+}
+
+// NewHeartbeatFromConfig returns the heartbeat defaults configured under `fsc.p2p.heartbeat`
+func NewHeartbeatFromConfig(configService ConfigService) (*Heartbeat, error) {
+	h := &Heartbeat{Interval: DefaultHeartbeatInterval, Misses: DefaultHeartbeatMisses}
+	if interval := configService.GetString("fsc.p2p.heartbeat.interval"); len(interval) != 0 {
+		v, err := time.ParseDuration(interval)
+		if err != nil || v <= 0 {
+			return nil, errors.Errorf("invalid heartbeat interval [%s], expected a positive duration", interval)
+		}
+		h.Interval = v
+	}
+	if misses := configService.GetString("fsc.p2p.heartbeat.misses"); len(misses) != 0 {
+		v, err := strconv.Atoi(misses)
+		if err != nil || v <= 0 {
+			return nil, errors.Errorf("invalid heartbeat misses [%s], expected a positive number", misses)
+		}
+		h.Misses = v
+	}
+	return h, nil
+}
+
+// Liveness is the state of the remote end of a session with heartbeats
+type Liveness struct {
+	// Enabled is true if the session has heartbeats
+	Enabled bool
+	// Reachable is false if the remote end missed its last heartbeats
+	Reachable bool
+	// LastHeartbeat is the time the last heartbeat of the remote end has been received, zero if none
+	LastHeartbeat time.Time
+}
+
+// heartbeats emits the heartbeats of a session and watches those of the remote end
+type heartbeats struct {
+	session *NetworkStreamSession
+	// endpoint is the address of the remote end, the label of its unreachable sessions in the metrics
+	endpoint string
+	interval time.Duration
+	misses   int
+
+	// beat is signalled at each heartbeat of the remote end
+	beat chan struct{}
+	// notifications are delivered to the session by a goroutine of their own,
+	// so that a view not reading the session does not stop the heartbeats
+	notifications chan int32
+	stop          chan struct{}
+	wg            sync.WaitGroup
+	// sending is 1 while a heartbeat is being sent
+	sending int32
+
+	// lock guards the liveness state, also read by Liveness
+	lock        sync.Mutex
+	last        time.Time
+	unreachable bool
+}
+
+// newHeartbeats returns the heartbeats of the passed session, whose mutex is held by the caller
+func newHeartbeats(session *NetworkStreamSession, interval time.Duration, misses int) *heartbeats {
+	return &heartbeats{
+		session:       session,
+		endpoint:      session.endpointAddress,
+		interval:      interval,
+		misses:        misses,
+		beat:          make(chan struct{}, 1),
+		notifications: make(chan int32, maxNotifications),
+		stop:          make(chan struct{}),
+		last:          time.Now(),
+	}
+}
+
+func (h *heartbeats) start() {
+	h.wg.Add(2)
+	go h.watch()
+	go h.deliver()
+}
+
+func (h *heartbeats) close() {
+	close(h.stop)
+	h.wg.Wait()
+	// the session is no longer watched, it is no longer counted as unreachable
+	h.lock.Lock()
+	unreachable := h.unreachable
+	h.unreachable = false
+	h.lock.Unlock()
+	if unreachable {
+		h.session.node.metrics.unreachable(h.endpoint, -1)
+	}
+}
+
+// received records a heartbeat of the remote end
+func (h *heartbeats) received() {
+	h.lock.Lock()
+	h.last = time.Now()
+	h.lock.Unlock()
+	select {
+	case h.beat <- struct{}{}:
+	default:
+	}
+}
+
+func (h *heartbeats) liveness() Liveness {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return Liveness{Enabled: true, Reachable: !h.unreachable, LastHeartbeat: h.last}
+}
+
+func (h *heartbeats) watch() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	h.send()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-h.beat:
+			h.check()
+		case <-ticker.C:
+			h.send()
+			h.check()
+		}
+	}
+}
+
+// check updates the liveness of the remote end and notifies its changes
+func (h *heartbeats) check() {
+	if v := h.session.ProtocolVersion(); len(v) != 0 && v != CurrentProtocolVersion {
+		// the previous versions of the view protocol do not carry heartbeats, the remote end is not watched
+		return
+	}
+	h.lock.Lock()
+	missed := time.Since(h.last) > time.Duration(h.misses)*h.interval
+	changed := missed != h.unreachable
+	h.unreachable = missed
+	h.lock.Unlock()
+	if !changed {
+		return
+	}
+
+	status := int32(view.SessionPeerRecovered)
+	if missed {
+		status = view.SessionPeerUnreachable
+		logger.Warnf("remote end of session [%s] missed [%d] heartbeats, it is unreachable", h.session.sessionID, h.misses)
+		h.session.node.metrics.unreachable(h.endpoint, 1)
+	} else {
+		logger.Infof("heartbeats of the remote end of session [%s] resumed", h.session.sessionID)
+		h.session.node.metrics.unreachable(h.endpoint, -1)
+	}
+	select {
+	case h.notifications <- status:
+	default:
+		logger.Warnf("too many liveness notifications for session [%s], dropping", h.session.sessionID)
+	}
+}
+
+// send emits a heartbeat, unless the previous one is still being sent to an unreachable node
+func (h *heartbeats) send() {
+	if !atomic.CompareAndSwapInt32(&h.sending, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&h.sending, 0)
+		payload := make([]byte, binary.MaxVarintLen64)
+		payload = payload[:binary.PutUvarint(payload, uint64(h.interval/time.Millisecond))]
+		if err := h.session.sendWithStatus(payload, heartbeatStatus); err != nil {
+			if logger.IsEnabledFor(zapcore.DebugLevel) {
+				logger.Debugf("failed sending heartbeat on session [%s]: [%s]", h.session.sessionID, err)
+			}
+		}
+	}()
+}
+
+func (h *heartbeats) deliver() {
+	defer h.wg.Done()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case status := <-h.notifications:
+			h.session.mutex.Lock()
+			msg := &view.Message{
+				SessionID:    h.session.sessionID,
+				ContextID:    h.session.contextID,
+				Caller:       h.session.callerViewID,
+				FromEndpoint: h.session.endpointAddress,
+				FromPKID:     h.session.endpointID,
+				Status:       status,
+			}
+			h.session.mutex.Unlock()
+			select {
+			case h.session.incoming <- msg:
+			case <-h.stop:
+				return
+			}
+			h.session.delivered(msg)
+		}
+	}
+}
+
+// handleHeartbeat handles a heartbeat received from the remote end of a session.
+// A session that does not have heartbeats yet enables them with the interval of the remote end.
+// The heartbeats of unknown sessions are dropped.
+func (p *P2PNode) handleHeartbeat(msg *ViewPacket, pkid []byte) {
+	p.sessionsMutex.Lock()
+	session, in := p.sessions[computeInternalSessionID(msg.SessionID, "", pkid)]
+	p.sessionsMutex.Unlock()
+	if !in {
+		if logger.IsEnabledFor(zapcore.DebugLevel) {
+			logger.Debugf("dropping heartbeat for unknown session [%s]", msg.SessionID)
+		}
+		return
+	}
+
+	var interval time.Duration
+	if ms, n := binary.Uvarint(msg.Payload); n > 0 {
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	if err := session.EnableHeartbeats(interval, 0); err != nil {
+		logger.Debugf("failed enabling heartbeats on session [%s]: [%s]", msg.SessionID, err)
+		return
+	}
+	session.mutex.Lock()
+	h := session.heartbeats
+	session.mutex.Unlock()
+	if h != nil {
+		h.received()
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeatConfig(t *testing.T) {
+	h, err := NewHeartbeatFromConfig(mapConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Heartbeat{Interval: DefaultHeartbeatInterval, Misses: DefaultHeartbeatMisses}, h)
+
+	h, err = NewHeartbeatFromConfig(mapConfig{
+		"fsc.p2p.heartbeat.interval": "1m",
+		"fsc.p2p.heartbeat.misses":   "5",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &Heartbeat{Interval: time.Minute, Misses: 5}, h)
+
+	_, err = NewHeartbeatFromConfig(mapConfig{"fsc.p2p.heartbeat.interval": "0s"})
+	assert.Error(t, err)
+	_, err = NewHeartbeatFromConfig(mapConfig{"fsc.p2p.heartbeat.misses": "none"})
+	assert.Error(t, err)
+}
+
+func TestSessionHeartbeats(t *testing.T) {
+	bootstrapNode, node, bootstrapNodeID, nodeID := setupTwoNodesFromFiles(t)
+	ctx := context.Background()
+	bootstrapNode.Start(ctx)
+	node.Start(ctx)
+	defer bootstrapNode.Stop()
+	defer node.Stop()
+
+	initiator, err := bootstrapNode.NewSessionWithID("heartbeats", "", "", []byte(nodeID), nil, nil)
+	assert.NoError(t, err)
+	responder, err := node.NewSessionWithID("heartbeats", "", "", []byte(bootstrapNodeID), nil, nil)
+	assert.NoError(t, err)
+
+	// the responder follows the initiator
+	assert.NoError(t, initiator.(view.HeartbeatSession).EnableHeartbeats(50*time.Millisecond, 3))
+	assert.Eventually(t, func() bool {
+		return responder.(*NetworkStreamSession).Liveness().Enabled
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return !initiator.(*NetworkStreamSession).Liveness().LastHeartbeat.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the heartbeats are not delivered to the views
+	assert.NoError(t, initiator.Send([]byte("ciao")))
+	msg := <-responder.Receive()
+	assert.Equal(t, []byte("ciao"), msg.Payload)
+	select {
+	case msg := <-responder.Receive():
+		t.Fatalf("unexpected message [%d]", msg.Status)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// the responder goes silent, the session is not closed
+	node.DeleteSessions("heartbeats")
+	msg = <-initiator.Receive()
+	assert.Equal(t, int32(view.SessionPeerUnreachable), msg.Status)
+	assert.False(t, initiator.(*NetworkStreamSession).Liveness().Reachable)
+	assert.False(t, initiator.Info().Closed)
+
+	// and comes back
+	_, err = node.NewSessionWithID("heartbeats", "", "", []byte(bootstrapNodeID), nil, nil)
+	assert.NoError(t, err)
+	msg = <-initiator.Receive()
+	assert.Equal(t, int32(view.SessionPeerRecovered), msg.Status)
+	assert.True(t, initiator.(*NetworkStreamSession).Liveness().Reachable)
+
+	initiator.Close()
+	assert.Error(t, initiator.(view.HeartbeatSession).EnableHeartbeats(0, 0))
+}
@@ -12,6 +12,7 @@ import (
 
 	"go.uber.org/zap/zapcore"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
 
@@ -44,12 +45,16 @@ func (p *P2PNode) getOrCreateSession(sessionID, endpointAddress, contextID, call
 		sessionID:       sessionID,
 		node:            p,
 		incoming:        make(chan *view.Message, 1),
+		inbox:           newInbox(),
 		streams:         make(map[*streamHandler]struct{}),
+		metadata:        localMetadata,
 	}
 
 	if msg != nil {
+		s.observeVersion(msg.Metadata)
+		s.label = msg.Metadata[view.SessionLabelMetadata]
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("pushing first message to [%s], [%s]", internalSessionID, msg)
+			logger.Debugf("pushing first message to [%s], [%s]", internalSessionID, flogging.Sensitive(msg))
 		}
 		s.incoming <- msg
 	} else {
@@ -59,6 +64,7 @@ func (p *P2PNode) getOrCreateSession(sessionID, endpointAddress, contextID, call
 	}
 
 	p.sessions[internalSessionID] = s
+	go s.inbox.run(s.incoming, s.delivered)
 
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("session [%s] as internal session [%s] ready", sessionID, internalSessionID)
@@ -88,15 +94,21 @@ func (p *P2PNode) MasterSession() (view.Session, error) {
 
 func (p *P2PNode) DeleteSessions(sessionID string) {
 	p.sessionsMutex.Lock()
-	defer p.sessionsMutex.Unlock()
-
+	var deleted []*NetworkStreamSession
 	for key := range p.sessions {
 		// if key starts with sessionID, delete it
 		if strings.HasPrefix(key, sessionID) {
 			if logger.IsEnabledFor(zapcore.DebugLevel) {
 				logger.Debugf("deleting session [%s]", key)
 			}
+			deleted = append(deleted, p.sessions[key])
 			delete(p.sessions, key)
 		}
 	}
+	p.sessionsMutex.Unlock()
+
+	// releasing a session might wait for its last credits to be sent
+	for _, session := range deleted {
+		session.release()
+	}
 }
@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+var (
+	payloadBytesOpts = metrics.CounterOpts{
+		Namespace:    "comm",
+		Subsystem:    "p2p",
+		Name:         "payload_bytes",
+		Help:         "The size, in bytes, of the session payloads before compression (sent) or after decompression (received).",
+		LabelNames:   []string{"direction"},
+		StatsdFormat: "%{#fqname}.%{direction}",
+	}
+	wireBytesOpts = metrics.CounterOpts{
+		Namespace:    "comm",
+		Subsystem:    "p2p",
+		Name:         "payload_wire_bytes",
+		Help:         "The size, in bytes, of the session payloads on the streams, compressed or not.",
+		LabelNames:   []string{"direction"},
+		StatsdFormat: "%{#fqname}.%{direction}",
+	}
+	sessionsUnreachableOpts = metrics.GaugeOpts{
+		Namespace:    "comm",
+		Subsystem:    "p2p",
+		Name:         "sessions_unreachable",
+		Help:         "The number of sessions with heartbeats whose remote end missed its last heartbeats, by endpoint of the remote end.",
+		LabelNames:   []string{"endpoint"},
+		StatsdFormat: "%{#fqname}.%{endpoint}",
+	}
+)
+
+// Metrics reports the size of the session payloads before and after compression,
+// and the number of sessions with heartbeats whose remote end is unreachable
+type Metrics struct {
+	PayloadBytes        metrics.Counter
+	WireBytes           metrics.Counter
+	SessionsUnreachable metrics.Gauge
+}
+
+func NewMetrics(p metrics.Provider) *Metrics {
+	return &Metrics{
+		PayloadBytes:        p.NewCounter(payloadBytesOpts),
+		WireBytes:           p.NewCounter(wireBytesOpts),
+		SessionsUnreachable: p.NewGauge(sessionsUnreachableOpts),
+	}
+}
+
+func (m *Metrics) observe(direction string, payloadBytes, wireBytes int) {
+	if m == nil {
+		return
+	}
+	m.PayloadBytes.With("direction", direction).Add(float64(payloadBytes))
+	m.WireBytes.With("direction", direction).Add(float64(wireBytes))
+}
+
+// unreachable adds the passed delta to the number of unreachable sessions with the passed remote endpoint
+func (m *Metrics) unreachable(endpoint string, delta float64) {
+	if m == nil {
+		return
+	}
+	m.SessionsUnreachable.With("endpoint", endpoint).Add(delta)
+}
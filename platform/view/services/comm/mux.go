@@ -0,0 +1,375 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"encoding/binary"
+	"strconv"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// creditStatus is the status of the packets granting credits to the sender of a session, they are never
+	// delivered to the views
+	creditStatus = 101
+
+	// DefaultMultiplexingStreams is the default number of streams the sessions with the same node share
+	DefaultMultiplexingStreams = 2
+
+	// flowControlWindow is the number of messages of a session a sender can send on a stream before the
+	// receiver grants it new credits, that is the number of messages of a session queued at the receiver
+	flowControlWindow = 64
+	// maxLegacyPending bounds the messages queued for a session by a node not supporting flow control,
+	// above it the dispatching of the messages of all the sessions waits, as it did before flow control
+	maxLegacyPending = flowControlWindow
+)
+
+// ErrConnectionLost is returned by the sends of the sessions whose stream failed, and carried by the notifications
+// with status view.SessionConnectionLost
+var ErrConnectionLost = errors.New("connection lost")
+
+var errSessionClosed = errors.New("session closed")
+
+// Multiplexing tells how the sessions with the same node share the streams
+type Multiplexing struct {
+	// Streams is the number of streams the sessions with the same node share
+	Streams int
+}
+
+// NewMultiplexingFromConfig returns the multiplexing configured under `fsc.p2p.multiplexing`
+func NewMultiplexingFromConfig(configService ConfigService) (*Multiplexing, error) {
+	m := &Multiplexing{Streams: DefaultMultiplexingStreams}
+	if streams := configService.GetString("fsc.p2p.multiplexing.streams"); len(streams) != 0 {
+		v, err := strconv.Atoi(streams)
+		if err != nil || v <= 0 {
+			return nil, errors.Errorf("invalid multiplexing streams [%s], expected a positive number", streams)
+		}
+		m.Streams = v
+	}
+	return m, nil
+}
+
+// outgoing is a packet waiting to be written on a stream
+type outgoing struct {
+	msg  proto.Message
+	done chan error
+}
+
+// sessionQueue holds the packets of a session waiting to be written on a stream, and the credits of the session
+type sessionQueue struct {
+	id       string
+	messages []*outgoing
+	credits  int
+	inRing   bool
+}
+
+// outbox schedules the packets written on a stream: the control packets (heartbeats, credits) first, then the
+// packets of the sessions in round-robin, one packet per session at a time.
+// With flow control, a session without credits is not scheduled until the receiver grants it new ones:
+// a session whose receiver does not keep up blocks only its own sends.
+type outbox struct {
+	lock        sync.Mutex
+	cond        *sync.Cond
+	flowControl bool
+	control     []*outgoing
+	queues      map[string]*sessionQueue
+	ring        []*sessionQueue
+	err         error
+}
+
+func newOutbox(flowControl bool) *outbox {
+	o := &outbox{flowControl: flowControl, queues: map[string]*sessionQueue{}}
+	o.cond = sync.NewCond(&o.lock)
+	return o
+}
+
+// push queues the passed packet and returns the channel its outcome is delivered to
+func (o *outbox) push(msg proto.Message) <-chan error {
+	out := &outgoing{msg: msg, done: make(chan error, 1)}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.err != nil {
+		out.done <- o.err
+		return out.done
+	}
+	packet, ok := msg.(*ViewPacket)
+	if !ok || packet.Status == heartbeatStatus || packet.Status == creditStatus {
+		o.control = append(o.control, out)
+	} else {
+		q := o.queue(packet.SessionID)
+		q.messages = append(q.messages, out)
+		o.schedule(q)
+	}
+	o.cond.Signal()
+	return out.done
+}
+
+// next returns the next packet to write, waiting for one if needed
+func (o *outbox) next() (*outgoing, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for {
+		if o.err != nil {
+			return nil, o.err
+		}
+		if len(o.control) > 0 {
+			out := o.control[0]
+			o.control = o.control[1:]
+			return out, nil
+		}
+		for len(o.ring) > 0 {
+			q := o.ring[0]
+			o.ring = o.ring[1:]
+			q.inRing = false
+			if len(q.messages) == 0 || (o.flowControl && q.credits <= 0) {
+				continue
+			}
+			out := q.messages[0]
+			q.messages = q.messages[1:]
+			if o.flowControl {
+				q.credits--
+			}
+			o.schedule(q)
+			return out, nil
+		}
+		o.cond.Wait()
+	}
+}
+
+// grant adds the passed credits to the passed session. The credits of the sessions with no queue, closed or never
+// sent on this stream, are ignored: a new queue starts with a full window.
+func (o *outbox) grant(sessionID string, credits int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	q, ok := o.queues[sessionID]
+	if !ok {
+		return
+	}
+	q.credits += credits
+	o.schedule(q)
+	o.cond.Signal()
+}
+
+// forget drops the state of the passed session, its queued packets fail
+func (o *outbox) forget(sessionID string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	q, ok := o.queues[sessionID]
+	if !ok {
+		return
+	}
+	for _, out := range q.messages {
+		out.done <- errSessionClosed
+	}
+	q.messages = nil
+	delete(o.queues, sessionID)
+}
+
+// fail makes the queued and the future packets fail with the passed error
+func (o *outbox) fail(err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.err != nil {
+		return
+	}
+	o.err = err
+	for _, out := range o.control {
+		out.done <- err
+	}
+	o.control = nil
+	for _, q := range o.queues {
+		for _, out := range q.messages {
+			out.done <- err
+		}
+		q.messages = nil
+	}
+	o.ring = nil
+	o.cond.Broadcast()
+}
+
+// queue returns the queue of the passed session, o.lock must be held
+func (o *outbox) queue(sessionID string) *sessionQueue {
+	q, ok := o.queues[sessionID]
+	if !ok {
+		q = &sessionQueue{id: sessionID, credits: flowControlWindow}
+		o.queues[sessionID] = q
+	}
+	return q
+}
+
+// schedule appends the passed queue to the ring if it has packets it can send, o.lock must be held
+func (o *outbox) schedule(q *sessionQueue) {
+	if q.inRing || len(q.messages) == 0 || (o.flowControl && q.credits <= 0) {
+		return
+	}
+	q.inRing = true
+	o.ring = append(o.ring, q)
+}
+
+// inbound is a message received for a session, and the stream it has been received on, nil for the notifications
+type inbound struct {
+	message *view.Message
+	stream  *streamHandler
+}
+
+type creditKey struct {
+	stream    *streamHandler
+	sessionID string
+}
+
+// inbox queues the messages received for a session and delivers them to the session from its own goroutine:
+// the dispatching of the messages of the other sessions does not wait for a view that is not reading its session.
+// Once delivered, the messages received on streams with flow control are credited back to their senders.
+type inbox struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	messages []*inbound
+	closed   bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newInbox() *inbox {
+	i := &inbox{stop: make(chan struct{}), done: make(chan struct{})}
+	i.cond = sync.NewCond(&i.lock)
+	return i
+}
+
+// push queues the passed message. For the messages of the nodes not supporting flow control, it waits while
+// maxLegacyPending messages are queued.
+func (i *inbox) push(message *view.Message, stream *streamHandler) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for stream != nil && !stream.codec.flowControl() && len(i.messages) >= maxLegacyPending && !i.closed {
+		i.cond.Wait()
+	}
+	if i.closed {
+		return
+	}
+	i.messages = append(i.messages, &inbound{message: message, stream: stream})
+	i.cond.Broadcast()
+}
+
+// run delivers the queued messages to the passed channel until the inbox is closed.
+// The passed function, if not nil, is called with each message delivered.
+func (i *inbox) run(incoming chan<- *view.Message, delivered func(*view.Message)) {
+	defer close(i.done)
+	owed := map[creditKey]int{}
+	for {
+		i.lock.Lock()
+		for len(i.messages) == 0 && !i.closed {
+			i.cond.Wait()
+		}
+		if i.closed {
+			i.lock.Unlock()
+			return
+		}
+		in := i.messages[0]
+		i.messages = i.messages[1:]
+		empty := len(i.messages) == 0
+		i.cond.Broadcast()
+		i.lock.Unlock()
+
+		select {
+		case incoming <- in.message:
+		case <-i.stop:
+			return
+		}
+		if delivered != nil {
+			delivered(in.message)
+		}
+
+		if in.stream == nil || !in.stream.codec.flowControl() {
+			continue
+		}
+		key := creditKey{stream: in.stream, sessionID: in.message.SessionID}
+		owed[key]++
+		// the credits are granted in batches, and as soon as the queue is empty
+		for key, credits := range owed {
+			if credits < flowControlWindow/4 && !empty {
+				continue
+			}
+			delete(owed, key)
+			if err := key.stream.grant(key.sessionID, credits); err != nil && logger.IsEnabledFor(zapcore.DebugLevel) {
+				logger.Debugf("failed granting credits on session [%s]: [%s]", key.sessionID, err)
+			}
+		}
+	}
+}
+
+// close stops the delivery, the messages not delivered yet are dropped
+func (i *inbox) close() {
+	i.lock.Lock()
+	if i.closed {
+		i.lock.Unlock()
+		return
+	}
+	i.closed = true
+	i.messages = nil
+	i.cond.Broadcast()
+	i.lock.Unlock()
+	close(i.stop)
+	<-i.done
+}
+
+// grant sends to the remote end the passed credits for the passed session
+func (s *streamHandler) grant(sessionID string, credits int) error {
+	payload := make([]byte, binary.MaxVarintLen64)
+	payload = payload[:binary.PutUvarint(payload, uint64(credits))]
+	return s.send(&ViewPacket{SessionID: sessionID, Status: creditStatus, Payload: payload})
+}
+
+// handleCredit handles the credits granted by the remote end
+func (s *streamHandler) handleCredit(msg *ViewPacket) {
+	credits, n := binary.Uvarint(msg.Payload)
+	if n <= 0 {
+		logger.Warnf("dropping invalid credit packet on session [%s]", msg.SessionID)
+		return
+	}
+	s.outbox.grant(msg.SessionID, int(credits))
+}
+
+// connectionLost notifies the sessions using the passed failed stream, and unpins it from the sessions sending on it.
+// The next send of these sessions opens a new stream, the sessions are preserved.
+func (p *P2PNode) connectionLost(stream *streamHandler, cause error) {
+	p.sessionsMutex.Lock()
+	var affected []*NetworkStreamSession
+	for _, session := range p.sessions {
+		session.mutex.Lock()
+		_, used := session.streams[stream]
+		if used || session.out == stream {
+			delete(session.streams, stream)
+			if session.out == stream {
+				session.out = nil
+			}
+			affected = append(affected, session)
+		}
+		session.mutex.Unlock()
+	}
+	p.sessionsMutex.Unlock()
+
+	for _, session := range affected {
+		session.mutex.Lock()
+		msg := &view.Message{
+			SessionID:    session.sessionID,
+			ContextID:    session.contextID,
+			Caller:       session.callerViewID,
+			FromEndpoint: session.endpointAddress,
+			FromPKID:     session.endpointID,
+			Status:       view.SessionConnectionLost,
+			Payload:      []byte(errors.Wrap(ErrConnectionLost, cause.Error()).Error()),
+		}
+		session.mutex.Unlock()
+		session.inbox.push(msg, nil)
+	}
+}
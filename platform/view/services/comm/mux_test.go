@@ -0,0 +1,180 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiplexingConfig(t *testing.T) {
+	m, err := NewMultiplexingFromConfig(mapConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Multiplexing{Streams: DefaultMultiplexingStreams}, m)
+
+	m, err = NewMultiplexingFromConfig(mapConfig{"fsc.p2p.multiplexing.streams": "4"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Multiplexing{Streams: 4}, m)
+
+	_, err = NewMultiplexingFromConfig(mapConfig{"fsc.p2p.multiplexing.streams": "0"})
+	assert.Error(t, err)
+}
+
+func TestOutboxFairness(t *testing.T) {
+	o := newOutbox(true)
+	next := func() string {
+		out, err := o.next()
+		assert.NoError(t, err)
+		packet := out.msg.(*ViewPacket)
+		out.done <- nil
+		return fmt.Sprintf("%s:%s", packet.SessionID, packet.Payload)
+	}
+
+	// a chatty session does not starve the others, the control packets go first
+	for i := 0; i < flowControlWindow+2; i++ {
+		o.push(&ViewPacket{SessionID: "chatty", Payload: []byte(fmt.Sprint(i))})
+	}
+	o.push(&ViewPacket{SessionID: "quiet", Payload: []byte("0")})
+	o.push(&ViewPacket{SessionID: "quiet", Payload: []byte("1")})
+	o.push(&ViewPacket{SessionID: "quiet", Status: heartbeatStatus, Payload: []byte("hb")})
+	assert.Equal(t, []string{"quiet:hb", "chatty:0", "quiet:0", "chatty:1", "quiet:1", "chatty:2"}, []string{next(), next(), next(), next(), next(), next()})
+
+	// without credits, the chatty session waits for the receiver
+	for i := 3; i < flowControlWindow; i++ {
+		assert.Equal(t, fmt.Sprintf("chatty:%d", i), next())
+	}
+	o.push(&ViewPacket{SessionID: "quiet", Payload: []byte("2")})
+	assert.Equal(t, "quiet:2", next())
+	o.grant("chatty", 1)
+	assert.Equal(t, fmt.Sprintf("chatty:%d", flowControlWindow), next())
+
+	// the packets of a closed session fail, those of a failed stream fail with ErrConnectionLost
+	closed := o.push(&ViewPacket{SessionID: "chatty", Payload: []byte("closed")})
+	o.forget("chatty")
+	assert.Error(t, <-closed)
+	pending := o.push(&ViewPacket{SessionID: "quiet", Payload: []byte("3")})
+	o.fail(errors.Wrap(ErrConnectionLost, "reset"))
+	assert.True(t, errors.Is(<-pending, ErrConnectionLost))
+	assert.True(t, errors.Is(<-o.push(&ViewPacket{SessionID: "quiet"}), ErrConnectionLost))
+	_, err := o.next()
+	assert.Error(t, err)
+}
+
+func TestOutboxForgetCredits(t *testing.T) {
+	o := newOutbox(true)
+	o.push(&ViewPacket{SessionID: "closing", Payload: []byte("0")})
+	out, err := o.next()
+	assert.NoError(t, err)
+	out.done <- nil
+	assert.Len(t, o.queues, 1)
+
+	// the credits arriving once the session is closed do not bring its queue back
+	o.forget("closing")
+	assert.Len(t, o.queues, 0)
+	o.grant("closing", 1)
+	assert.Len(t, o.queues, 0)
+	assert.Empty(t, o.ring)
+}
+
+func TestInbox(t *testing.T) {
+	i := newInbox()
+	incoming := make(chan *view.Message, 1)
+	go i.run(incoming, nil)
+
+	// pushing does not wait for the session to be read
+	for n := 0; n < 10*flowControlWindow; n++ {
+		i.push(&view.Message{Payload: []byte(fmt.Sprint(n))}, nil)
+	}
+	for n := 0; n < 10*flowControlWindow; n++ {
+		assert.Equal(t, []byte(fmt.Sprint(n)), (<-incoming).Payload)
+	}
+	i.push(&view.Message{}, nil)
+	i.close()
+	i.push(&view.Message{}, nil)
+}
+
+// TestMultiplexedSessions runs 1k concurrent sessions between two nodes, next to a session whose view does not read
+func TestMultiplexedSessions(t *testing.T) {
+	bootstrapNode, node, bootstrapNodeID, nodeID := setupTwoNodesFromFiles(t)
+	ctx := context.Background()
+	bootstrapNode.Start(ctx)
+	node.Start(ctx)
+	defer bootstrapNode.Stop()
+	defer node.Stop()
+
+	// the responder echoes the messages of the sessions it does not know, but those of the stalled session
+	master, err := node.MasterSession()
+	assert.NoError(t, err)
+	go func() {
+		for msg := range master.Receive() {
+			if msg.SessionID == "stalled" {
+				continue
+			}
+			session, err := node.NewSessionWithID(msg.SessionID, msg.ContextID, "", msg.FromPKID, nil, nil)
+			assert.NoError(t, err)
+			go func(msg *view.Message) {
+				assert.NoError(t, session.Send(msg.Payload))
+			}(msg)
+		}
+	}()
+
+	// the view of the stalled session never reads, its sender blocks once the window is full
+	_, err = node.NewSessionWithID("stalled", "", "", []byte(bootstrapNodeID), nil, nil)
+	assert.NoError(t, err)
+	stalled, err := bootstrapNode.NewSessionWithID("stalled", "", "", []byte(nodeID), nil, nil)
+	assert.NoError(t, err)
+	go func() {
+		for i := 0; i < 10*flowControlWindow; i++ {
+			if err := stalled.Send([]byte("flood")); err != nil {
+				return
+			}
+		}
+	}()
+
+	const sessions = 1000
+	var wg sync.WaitGroup
+	wg.Add(sessions)
+	for i := 0; i < sessions; i++ {
+		go func(i int) {
+			defer wg.Done()
+			session, err := bootstrapNode.NewSession("", "", "", []byte(nodeID))
+			assert.NoError(t, err)
+			defer session.Close()
+			payload := []byte(fmt.Sprintf("msg-%d", i))
+			assert.NoError(t, session.Send(payload))
+			select {
+			case msg := <-session.Receive():
+				assert.Equal(t, payload, msg.Payload)
+			case <-time.After(30 * time.Second):
+				t.Errorf("session [%d] did not receive its echo", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// all the sessions shared a small fixed set of streams, each node opens at most DefaultMultiplexingStreams
+	for _, n := range []struct {
+		node   *P2PNode
+		remote string
+	}{{bootstrapNode, nodeID}, {node, bootstrapNodeID}} {
+		ID, err := peer.Decode(n.remote)
+		assert.NoError(t, err)
+		n.node.streamsMutex.RLock()
+		streams := len(n.node.streams[ID])
+		n.node.streamsMutex.RUnlock()
+		assert.LessOrEqual(t, streams, 2*DefaultMultiplexingStreams)
+		assert.Greater(t, streams, 0)
+	}
+}
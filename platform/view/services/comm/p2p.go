@@ -18,13 +18,13 @@ import (
 	"github.com/gogo/protobuf/io"
 	"github.com/gogo/protobuf/proto"
 	proto2 "github.com/hyperledger-labs/fabric-smart-client/pkg/utils/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/comm/deadletter"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
-	"github.com/libp2p/go-libp2p-core/protocol"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	"github.com/multiformats/go-multiaddr"
@@ -33,12 +33,17 @@ import (
 )
 
 const (
-	viewProtocol     = "/fsc/view/1.0.0"
 	rendezVousString = "fsc"
 	masterSession    = "master of puppets I'm pulling your strings"
+	// maxPacketSize bounds the size of the packets read from the streams
+	maxPacketSize = 655360 * 2
 )
 
-var errStreamNotFound = errors.New("stream not found")
+// ErrPayloadTooLarge is returned by the sends of the packets the remote nodes would not read, as too large
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// ErrUnknownEndpoint is returned by the sends to the endpoints that are not valid libp2p IDs, or have no known address
+var ErrUnknownEndpoint = errors.New("unknown endpoint")
 
 var logger = flogging.MustGetLogger("view-sdk")
 
@@ -62,6 +67,20 @@ type P2PNode struct {
 	stopFinder       int32
 	finderWg         sync.WaitGroup
 	isStopping       bool
+	// compression, if not nil, compresses the payloads sent to the nodes supporting it
+	compression *Compression
+	// metrics, if not nil, reports the size of the payloads
+	metrics *Metrics
+	// heartbeat, if not nil, holds the defaults of the sessions enabling heartbeats
+	heartbeat *Heartbeat
+	// multiplexing, if not nil, tells how many streams the sessions with the same node share
+	multiplexing *Multiplexing
+	// protocols, if not nil, tells the versions of the view protocol negotiated, all the versions spoken if nil
+	protocols *Protocols
+	// dialMutexes serialize the opening of the streams towards the same node, so that the pool is not exceeded
+	dialMutexes map[peer.ID]*sync.Mutex
+	// deadLetters, if not nil, keeps the packets of the sessions whose delivery failed permanently
+	deadLetters *deadletter.Queue
 }
 
 func (p *P2PNode) Start(ctx context.Context) {
@@ -77,6 +96,12 @@ func (p *P2PNode) Stop() {
 	p.isStopping = true
 	p.streamsMutex.Unlock()
 
+	p.sessionsMutex.Lock()
+	for _, session := range p.sessions {
+		session.stopHeartbeats()
+	}
+	p.sessionsMutex.Unlock()
+
 	p.host.Close()
 	atomic.StoreInt32(&p.stopFinder, 1)
 
@@ -90,6 +115,20 @@ func (p *P2PNode) Stop() {
 	p.finderWg.Wait()
 }
 
+// HealthCheck returns an error if the node is stopping or it is not listening for incoming streams
+func (p *P2PNode) HealthCheck(ctx context.Context) error {
+	p.streamsMutex.RLock()
+	stopping := p.isStopping
+	p.streamsMutex.RUnlock()
+	if stopping {
+		return errors.New("p2p node is stopping")
+	}
+	if len(p.host.Network().ListenAddresses()) == 0 {
+		return errors.New("p2p node is not listening")
+	}
+	return nil
+}
+
 func (p *P2PNode) dispatchMessages(ctx context.Context) {
 	for {
 		select {
@@ -121,10 +160,8 @@ func (p *P2PNode) dispatchMessages(ctx context.Context) {
 				session.callerViewID = msg.message.Caller
 				session.contextID = msg.message.ContextID
 				session.endpointAddress = msg.message.FromEndpoint
-				// here we know that msg.stream is used for session:
-				// 1) increment the used counter for msg.stream
-				msg.stream.refCtr++
-				// 2) add msg.stream to the list of streams used by session
+				session.observeVersion(msg.message.Metadata)
+				// here we know that msg.stream is used for session
 				session.streams[msg.stream] = struct{}{}
 				session.mutex.Unlock()
 			}
@@ -150,9 +187,9 @@ func (p *P2PNode) dispatchMessages(ctx context.Context) {
 			p.dispatchMutex.Unlock()
 
 			if logger.IsEnabledFor(zapcore.DebugLevel) {
-				logger.Debugf("pushing message to [%s], [%s]", internalSessionID, msg.message)
+				logger.Debugf("pushing message to [%s], [%s]", internalSessionID, flogging.Sensitive(msg.message))
 			}
-			session.incoming <- msg.message
+			session.inbox.push(msg.message, msg.stream)
 		case <-ctx.Done():
 			logger.Info("closing p2p comm...")
 			return
@@ -160,32 +197,70 @@ func (p *P2PNode) dispatchMessages(ctx context.Context) {
 	}
 }
 
-func (p *P2PNode) sendWithCachedStreams(ID peer.ID, msg proto.Message) error {
-	p.streamsMutex.RLock()
-	defer p.streamsMutex.RUnlock()
-	for _, stream := range p.streams[ID] {
-		err := stream.send(msg)
-		if err == nil {
-			return nil
-		}
-		// TODO: handle the case in which there's an error
-		logger.Errorf("error while sending message [%s] to peer [%s]: %s", msg, ID, err)
-	}
-
-	return errStreamNotFound
-}
-
-// sendTo sends the passed messaged to the libp2p peer with the passed ID.
+// sendTo sends the passed messaged to the libp2p peer with the passed ID, on one of the streams shared with it.
 // If no address is specified, then libp2p will use one of the IP addresses associated to the peer in its peer store.
 // If an address is specified, then the peer store will be updated with the passed address.
 func (p *P2PNode) sendTo(IDString string, address string, msg proto.Message) error {
 	ID, err := peer.Decode(IDString)
+	if err != nil {
+		return errors.Wrapf(ErrUnknownEndpoint, "invalid endpoint ID [%s]: %s", IDString, err)
+	}
+	stream, err := p.streamTo(ID, address)
 	if err != nil {
 		return err
 	}
+	return stream.send(msg)
+}
 
-	if err := p.sendWithCachedStreams(ID, msg); err != errStreamNotFound {
-		return err
+// sendOnSession sends the passed packet on the stream the passed session is pinned to.
+// A session is pinned to a stream at its first send, so that its packets are delivered in order,
+// and again after the stream failed.
+func (p *P2PNode) sendOnSession(session *NetworkStreamSession, packet *ViewPacket) error {
+	session.mutex.Lock()
+	stream := session.out
+	endpointID, endpointAddress := string(session.endpointID), session.endpointAddress
+	session.mutex.Unlock()
+
+	if stream == nil {
+		ID, err := peer.Decode(endpointID)
+		if err != nil {
+			return errors.Wrapf(ErrUnknownEndpoint, "invalid endpoint ID [%s]: %s", endpointID, err)
+		}
+		if stream, err = p.streamTo(ID, endpointAddress); err != nil {
+			return err
+		}
+		session.mutex.Lock()
+		if session.out == nil {
+			session.out = stream
+			atomic.AddInt32(&stream.pinned, 1)
+		} else {
+			stream = session.out
+		}
+		session.mutex.Unlock()
+	}
+	return stream.send(packet)
+}
+
+// streamTo returns a stream to the peer with the passed ID. A new stream is opened until the peer has as many
+// streams as the multiplexing allows, the least used one is returned afterwards.
+func (p *P2PNode) streamTo(ID peer.ID, address string) (*streamHandler, error) {
+	maxStreams := DefaultMultiplexingStreams
+	if p.multiplexing != nil {
+		maxStreams = p.multiplexing.Streams
+	}
+
+	p.streamsMutex.Lock()
+	dialMutex, ok := p.dialMutexes[ID]
+	if !ok {
+		dialMutex = &sync.Mutex{}
+		p.dialMutexes[ID] = dialMutex
+	}
+	p.streamsMutex.Unlock()
+	dialMutex.Lock()
+	defer dialMutex.Unlock()
+
+	if stream := p.leastUsedStream(ID, maxStreams); stream != nil {
+		return stream, nil
 	}
 
 	if len(address) != 0 {
@@ -194,50 +269,88 @@ func (p *P2PNode) sendTo(IDString string, address string, msg proto.Message) err
 		current := ps.Addrs(ID)
 
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
-			logger.Debugf("sendTo, reprogram address [%s:%s]", IDString, address)
+			logger.Debugf("sendTo, reprogram address [%s:%s]", ID, address)
 			for _, m := range current {
-				logger.Debugf("sendTo, current address [%s:%s]", IDString, m.String())
+				logger.Debugf("sendTo, current address [%s:%s]", ID, m.String())
 			}
 		}
 
 		ps.ClearAddrs(ID)
 		addr, err := AddressToEndpoint(address)
 		if err != nil {
-			return errors.WithMessagef(err, "failed to parse endpoint's address [%s]", address)
+			return nil, errors.WithMessagef(err, "failed to parse endpoint's address [%s]", address)
 		}
 		s, err := multiaddr.NewMultiaddr(addr)
 		if err != nil {
-			return errors.Wrapf(err, "failed to get mutliaddr for [%s]", address)
+			return nil, errors.Wrapf(err, "failed to get mutliaddr for [%s]", address)
 		}
 		ps.AddAddr(ID, s, peerstore.OwnObservedAddrTTL)
 	}
 
-	nwStream, err := p.host.NewStream(context.Background(), ID, protocol.ID(viewProtocol))
+	// prefer the latest version of the view protocol, the stream falls back to the former ones the remote node supports
+	offered := p.offeredTo(ID)
+	nwStream, err := p.host.NewStream(context.Background(), ID, offered...)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create new stream to [%s]", ID)
+		if incompatible := p.incompatible(ID, offered); incompatible != nil {
+			return nil, incompatible
+		}
+		// the streams already open are still usable
+		if stream := p.leastUsedStream(ID, 1); stream != nil {
+			logger.Warnf("failed to create new stream to [%s], sharing the existing ones: [%s]", ID, err)
+			return stream, nil
+		}
+		return nil, errors.Wrapf(err, "failed to create new stream to [%s]", ID)
 	}
+	return p.newStreamHandler(nwStream), nil
+}
 
-	p.handleStream()(nwStream)
-
-	return p.sendWithCachedStreams(ID, msg)
+// leastUsedStream returns the stream to the passed peer with the fewest sessions pinned to it,
+// if the peer has at least the passed number of streams
+func (p *P2PNode) leastUsedStream(ID peer.ID, minStreams int) *streamHandler {
+	p.streamsMutex.RLock()
+	defer p.streamsMutex.RUnlock()
+	streams := p.streams[ID]
+	if len(streams) == 0 || len(streams) < minStreams {
+		return nil
+	}
+	res := streams[0]
+	for _, stream := range streams[1:] {
+		if atomic.LoadInt32(&stream.pinned) < atomic.LoadInt32(&res.pinned) {
+			res = stream
+		}
+	}
+	return res
 }
 
 func (p *P2PNode) handleStream() network.StreamHandler {
 	return func(stream network.Stream) {
-		sh := &streamHandler{
-			stream: stream,
-			reader: NewDelimitedReader(stream, 655360*2),
-			writer: io.NewDelimitedWriter(stream),
-			node:   p,
-		}
+		p.newStreamHandler(stream)
+	}
+}
 
-		remotePeerID := sh.stream.Conn().RemotePeer()
-		p.streamsMutex.Lock()
-		p.streams[remotePeerID] = append(p.streams[remotePeerID], sh)
-		p.streamsMutex.Unlock()
+// newStreamHandler adds the passed stream to the streams shared with the remote node and starts serving it
+func (p *P2PNode) newStreamHandler(stream network.Stream) *streamHandler {
+	codec := codecOf(stream.Protocol())
+	sh := &streamHandler{
+		stream: stream,
+		reader: NewDelimitedReader(stream, maxPacketSize),
+		writer: io.NewDelimitedWriter(stream),
+		node:   p,
+		codec:  codec,
+		outbox: newOutbox(codec.flowControl()),
+	}
 
-		go sh.handleIncoming()
+	remotePeerID := sh.stream.Conn().RemotePeer()
+	if logger.IsEnabledFor(zapcore.DebugLevel) {
+		logger.Debugf("new stream with [%s], view protocol version [%s]", remotePeerID, codec.version())
 	}
+	p.streamsMutex.Lock()
+	p.streams[remotePeerID] = append(p.streams[remotePeerID], sh)
+	p.streamsMutex.Unlock()
+
+	go sh.handleIncoming()
+	go sh.handleOutgoing()
+	return sh
 }
 
 func (p *P2PNode) Lookup(peerID string) (peer.AddrInfo, bool) {
@@ -255,13 +368,54 @@ type streamHandler struct {
 	writer io.WriteCloser
 	node   *P2PNode
 	wg     sync.WaitGroup
-	refCtr int
+	// codec writes and reads the packets of the version of the view protocol negotiated on this stream
+	codec codec
+	// outbox schedules the packets of the sessions sharing this stream
+	outbox *outbox
+	// pinned is the number of sessions sending on this stream
+	pinned int32
 }
 
 func (s *streamHandler) send(msg proto.Message) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	return s.writer.WriteMsg(msg)
+	if packet, ok := msg.(*ViewPacket); ok {
+		payloadSize := len(packet.Payload)
+		encoded, err := s.codec.encode(packet, s.node.compression)
+		if err != nil {
+			return err
+		}
+		if encoded == nil {
+			// the remote node does not know packets of this kind
+			return nil
+		}
+		if size := proto2.Size(encoded); size > maxPacketSize {
+			return errors.Wrapf(ErrPayloadTooLarge, "packet of [%d] bytes exceeds [%d] bytes", size, maxPacketSize)
+		}
+		s.node.metrics.observe("sent", payloadSize, len(encoded.Payload))
+		msg = encoded
+	}
+	return <-s.outbox.push(msg)
+}
+
+// handleOutgoing writes the packets scheduled by the outbox, until the stream fails or is closed
+func (s *streamHandler) handleOutgoing() {
+	for {
+		out, err := s.outbox.next()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		err = s.writer.WriteMsg(out.msg)
+		s.lock.Unlock()
+		if err != nil {
+			err = errors.Wrapf(ErrConnectionLost, "failed writing to [%s]: %s", s.stream.Conn().RemotePeer(), err)
+			out.done <- err
+			s.outbox.fail(err)
+			// the reading side notices the failure and notifies the sessions
+			_ = s.stream.Reset()
+			return
+		}
+		out.done <- nil
+	}
 }
 
 func (s *streamHandler) handleIncoming() {
@@ -279,14 +433,16 @@ func (s *streamHandler) handleIncoming() {
 
 			logger.Debugf("error reading message: [%s][%s]", err.Error(), debug.Stack())
 
-			// remove stream handler
+			// remove stream handler, and fail the sessions sharing it
 			remotePeerID := s.stream.Conn().RemotePeer()
+			s.outbox.fail(errors.Wrapf(ErrConnectionLost, "stream to [%s] failed: %s", remotePeerID, err))
 			s.node.streamsMutex.Lock()
 			for i, thisSH := range s.node.streams[remotePeerID] {
 				if thisSH == s {
 					s.node.streams[remotePeerID] = append(s.node.streams[remotePeerID][:i], s.node.streams[remotePeerID][i+1:]...)
 					s.wg.Done()
 					s.node.streamsMutex.Unlock()
+					s.node.connectionLost(s, err)
 					return
 				}
 			}
@@ -295,9 +451,26 @@ func (s *streamHandler) handleIncoming() {
 			// this should never happen!
 			panic("couldn't find stream handler to remove")
 		}
+		if msg.Status == creditStatus {
+			s.handleCredit(msg)
+			continue
+		}
+		if msg.Status == heartbeatStatus {
+			// heartbeats do not wait for the messages queued before them
+			s.node.handleHeartbeat(msg, []byte(s.stream.Conn().RemotePeer().String()))
+			continue
+		}
 		if logger.IsEnabledFor(zapcore.DebugLevel) {
 			logger.Debugf("incoming message from [%s] on session [%s]", msg.Caller, msg.SessionID)
 		}
+		wireSize := len(msg.Payload)
+		decoded, err := s.codec.decode(msg)
+		if err != nil {
+			logger.Errorf("dropping message from [%s] on session [%s], failed decoding: [%s]", msg.Caller, msg.SessionID, err)
+			continue
+		}
+		msg = decoded
+		s.node.metrics.observe("received", len(msg.Payload), wireSize)
 
 		s.node.incomingMessages <- &messageWithStream{
 			message: &view.Message{
@@ -306,6 +479,7 @@ func (s *streamHandler) handleIncoming() {
 				Status:       msg.Status,
 				Payload:      msg.Payload,
 				Caller:       msg.Caller,
+				Metadata:     msg.Metadata,
 				FromEndpoint: s.stream.Conn().RemoteMultiaddr().String(),
 				FromPKID:     []byte(s.stream.Conn().RemotePeer().String()),
 			},
@@ -315,6 +489,7 @@ func (s *streamHandler) handleIncoming() {
 }
 
 func (s *streamHandler) close() {
+	s.outbox.fail(errors.New("stream closed"))
 	s.reader.Close()
 	s.writer.Close()
 	s.stream.Close()
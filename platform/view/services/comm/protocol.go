@@ -0,0 +1,299 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProtocolVersion1_0 is the first version of the view protocol, its packets carry plain payloads only
+	ProtocolVersion1_0 = "1.0.0"
+	// ProtocolVersion1_1 is the version of the view protocol whose packets might carry compressed payloads
+	ProtocolVersion1_1 = "1.1.0"
+	// ProtocolVersion1_2 is the version of the view protocol adding the flow control and the heartbeats of the sessions
+	ProtocolVersion1_2 = "1.2.0"
+	// CurrentProtocolVersion is the latest version of the view protocol
+	CurrentProtocolVersion = ProtocolVersion1_2
+
+	// viewProtocolPrefix prefixes the version in the libp2p protocol of the streams, as in `/fsc/view/1.2.0`
+	viewProtocolPrefix = "/fsc/view/"
+)
+
+// ErrIncompatibleProtocol is returned when this node and a remote node do not agree on a version of the view protocol
+type ErrIncompatibleProtocol struct {
+	// Peer is the libp2p ID of the remote node
+	Peer string
+	// Local is the version this node offered, the latest not above its maximum or the one pinned for the remote node
+	Local string
+	// Remote is the latest version the remote node supports, `unknown` if it speaks none
+	Remote string
+}
+
+func (e *ErrIncompatibleProtocol) Error() string {
+	return fmt.Sprintf("no common view protocol version with [%s]: local version [%s], remote version [%s]", e.Peer, e.Local, e.Remote)
+}
+
+// codec writes and reads the packets of a version of the view protocol.
+// The versions are negotiated, one per stream, when the stream is opened.
+type codec interface {
+	// version returns the version of the view protocol, as in ProtocolVersion1_2
+	version() string
+	// flowControl returns true if the sessions on the streams of this version have flow control
+	flowControl() bool
+	// encode returns the packet to write in place of the passed one, nil if the version does not carry it
+	encode(packet *ViewPacket, compression *Compression) (*ViewPacket, error)
+	// decode returns the packet read in the form the node dispatches
+	decode(packet *ViewPacket) (*ViewPacket, error)
+}
+
+// currentCodec is the codec of CurrentProtocolVersion
+type currentCodec struct{}
+
+func (c *currentCodec) version() string {
+	return CurrentProtocolVersion
+}
+
+func (c *currentCodec) flowControl() bool {
+	return true
+}
+
+func (c *currentCodec) encode(packet *ViewPacket, compression *Compression) (*ViewPacket, error) {
+	return compression.compress(packet)
+}
+
+func (c *currentCodec) decode(packet *ViewPacket) (*ViewPacket, error) {
+	return decompress(packet)
+}
+
+// legacyCodec is the codec of the versions preceding CurrentProtocolVersion, spoken by the nodes running older software.
+// The nodes of these versions deliver any packet to the views: the heartbeats and the credits are never sent to them.
+// The metadata of the packets are sent, they are skipped by the nodes not knowing them.
+type legacyCodec struct {
+	v string
+	// compression is true if the packets of the version might carry compressed payloads
+	compression bool
+}
+
+func (c *legacyCodec) version() string {
+	return c.v
+}
+
+func (c *legacyCodec) flowControl() bool {
+	return false
+}
+
+func (c *legacyCodec) encode(packet *ViewPacket, compression *Compression) (*ViewPacket, error) {
+	if packet.Status == heartbeatStatus || packet.Status == creditStatus {
+		return nil, nil
+	}
+	if !c.compression {
+		return packet, nil
+	}
+	return compression.compress(packet)
+}
+
+func (c *legacyCodec) decode(packet *ViewPacket) (*ViewPacket, error) {
+	if !c.compression && len(packet.Compression) != 0 {
+		return nil, errors.Errorf("view protocol [%s] does not carry compressed payloads", c.v)
+	}
+	return decompress(packet)
+}
+
+// decompress decompresses the payload of the passed packet, if compressed
+func decompress(packet *ViewPacket) (*ViewPacket, error) {
+	if len(packet.Compression) == 0 {
+		return packet, nil
+	}
+	payload, err := decompressPayload(packet.Compression, packet.Payload)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed decompressing payload")
+	}
+	packet.Payload = payload
+	packet.Compression = ""
+	return packet, nil
+}
+
+// codecs are the versions of the view protocol this node speaks, from the latest
+var codecs = []codec{
+	&currentCodec{},
+	&legacyCodec{v: ProtocolVersion1_1, compression: true},
+	&legacyCodec{v: ProtocolVersion1_0},
+}
+
+// codecIndex returns the index in codecs of the passed version, -1 if this node does not speak it
+func codecIndex(v string) int {
+	for i, c := range codecs {
+		if c.version() == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// codecOf returns the codec of the passed libp2p protocol, the one of ProtocolVersion1_0 if unknown
+func codecOf(id protocol.ID) codec {
+	if i := codecIndex(strings.TrimPrefix(string(id), viewProtocolPrefix)); i >= 0 {
+		return codecs[i]
+	}
+	return codecs[len(codecs)-1]
+}
+
+func protocolID(v string) protocol.ID {
+	return protocol.ID(viewProtocolPrefix + v)
+}
+
+// PinnedProtocol pins the version of the view protocol used with a remote node
+type PinnedProtocol struct {
+	// Endpoint is the name, or the identity, of the remote node, as known to the endpoint service
+	Endpoint string `yaml:"endpoint"`
+	// Version is the version of the view protocol offered to the remote node
+	Version string `yaml:"version"`
+}
+
+// Protocols tells the versions of the view protocol a node negotiates
+type Protocols struct {
+	// MaxVersion is the latest version the node negotiates, CurrentProtocolVersion if empty
+	MaxVersion string
+	// Pinned maps the libp2p IDs of the remote nodes known to run older software to the only version
+	// offered to them when opening a stream
+	Pinned map[string]string
+}
+
+// NewProtocolsFromConfig returns the versions of the view protocol configured under `fsc.p2p.protocol`.
+// The pinned endpoints are resolved to their libp2p IDs with the passed endpoint service.
+func NewProtocolsFromConfig(configService ConfigService, endpointService EndpointService) (*Protocols, error) {
+	p := &Protocols{MaxVersion: configService.GetString("fsc.p2p.protocol.maxVersion")}
+	if len(p.MaxVersion) == 0 {
+		p.MaxVersion = CurrentProtocolVersion
+	}
+	max := codecIndex(p.MaxVersion)
+	if max < 0 {
+		return nil, errors.Errorf("unsupported view protocol version [%s], expected one of [%s]", p.MaxVersion, supportedVersions())
+	}
+
+	var pinned []PinnedProtocol
+	if err := configService.UnmarshalKey("fsc.p2p.protocol.pinned", &pinned); err != nil {
+		return nil, errors.Wrapf(err, "failed loading the pinned view protocol versions")
+	}
+	if len(pinned) == 0 {
+		return p, nil
+	}
+	p.Pinned = map[string]string{}
+	for _, pin := range pinned {
+		i := codecIndex(pin.Version)
+		if i < 0 {
+			return nil, errors.Errorf("unsupported view protocol version [%s] pinned for [%s], expected one of [%s]", pin.Version, pin.Endpoint, supportedVersions())
+		}
+		if i < max {
+			return nil, errors.Errorf("view protocol version [%s] pinned for [%s] is above the maximum version [%s]", pin.Version, pin.Endpoint, p.MaxVersion)
+		}
+		id, err := endpointService.GetIdentity(pin.Endpoint, nil)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to get the identity of the pinned endpoint [%s]", pin.Endpoint)
+		}
+		_, _, pkID, err := endpointService.Resolve(id)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to resolve the pinned endpoint [%s]", pin.Endpoint)
+		}
+		p.Pinned[string(pkID)] = pin.Version
+	}
+	return p, nil
+}
+
+func supportedVersions() string {
+	versions := make([]string, len(codecs))
+	for i, c := range codecs {
+		versions[i] = c.version()
+	}
+	return strings.Join(versions, ", ")
+}
+
+// accepted returns the codecs of the versions the node accepts the streams of, from the latest
+func (p *Protocols) accepted() []codec {
+	if p == nil || len(p.MaxVersion) == 0 {
+		return codecs
+	}
+	if i := codecIndex(p.MaxVersion); i >= 0 {
+		return codecs[i:]
+	}
+	return codecs
+}
+
+// offered returns the codecs of the versions offered to the passed peer when opening a stream, from the latest
+func (p *Protocols) offered(ID peer.ID) []codec {
+	if p != nil {
+		if v, ok := p.Pinned[ID.String()]; ok {
+			if i := codecIndex(v); i >= 0 {
+				return codecs[i : i+1]
+			}
+		}
+	}
+	return p.accepted()
+}
+
+// setProtocols makes the node negotiate the passed versions of the view protocol, all the versions it speaks if nil
+func (p *P2PNode) setProtocols(protocols *Protocols) {
+	p.streamsMutex.Lock()
+	p.protocols = protocols
+	p.streamsMutex.Unlock()
+
+	accepted := protocols.accepted()
+	for _, c := range codecs {
+		if codecIndex(c.version()) < codecIndex(accepted[0].version()) {
+			p.host.RemoveStreamHandler(protocolID(c.version()))
+			continue
+		}
+		p.host.SetStreamHandler(protocolID(c.version()), p.handleStream())
+	}
+}
+
+// offeredTo returns the libp2p protocols offered to the passed peer when opening a stream, from the latest
+func (p *P2PNode) offeredTo(ID peer.ID) []protocol.ID {
+	p.streamsMutex.RLock()
+	offered := p.protocols.offered(ID)
+	p.streamsMutex.RUnlock()
+	ids := make([]protocol.ID, len(offered))
+	for i, c := range offered {
+		ids[i] = protocolID(c.version())
+	}
+	return ids
+}
+
+// incompatible returns an ErrIncompatibleProtocol if the passed peer, as told by libp2p identify,
+// supports none of the passed protocols. It returns nil if the peer has not told its protocols yet.
+func (p *P2PNode) incompatible(ID peer.ID, offered []protocol.ID) error {
+	protocols, err := p.host.Peerstore().GetProtocols(ID)
+	if err != nil || len(protocols) == 0 {
+		return nil
+	}
+	var remote version.Version
+	for _, proto := range protocols {
+		if !strings.HasPrefix(proto, viewProtocolPrefix) {
+			continue
+		}
+		for _, o := range offered {
+			if proto == string(o) {
+				return nil
+			}
+		}
+		if v := version.Parse(strings.TrimPrefix(proto, viewProtocolPrefix)); v.Compare(remote) > 0 {
+			remote = v
+		}
+	}
+	return &ErrIncompatibleProtocol{
+		Peer:   ID.String(),
+		Local:  strings.TrimPrefix(string(offered[0]), viewProtocolPrefix),
+		Remote: remote.String(),
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package comm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// pinnedConfig is a mapConfig with pinned view protocol versions
+type pinnedConfig struct {
+	mapConfig
+	pinned []PinnedProtocol
+}
+
+func (c *pinnedConfig) UnmarshalKey(key string, rawVal interface{}) error {
+	if key == "fsc.p2p.protocol.pinned" {
+		*rawVal.(*[]PinnedProtocol) = c.pinned
+	}
+	return nil
+}
+
+// endpoints resolves the names to libp2p IDs
+type endpoints map[string]string
+
+func (e endpoints) Resolve(party view.Identity) (view.Identity, map[view2.PortName]string, []byte, error) {
+	id, ok := e[string(party)]
+	if !ok {
+		return nil, nil, nil, errors.Errorf("unknown party [%s]", party)
+	}
+	return party, nil, []byte(id), nil
+}
+
+func (e endpoints) GetIdentity(label string, pkID []byte) (view.Identity, error) {
+	return view.Identity(label), nil
+}
+
+func TestProtocolsConfig(t *testing.T) {
+	p, err := NewProtocolsFromConfig(mapConfig{}, endpoints{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Protocols{MaxVersion: CurrentProtocolVersion}, p)
+
+	p, err = NewProtocolsFromConfig(&pinnedConfig{
+		mapConfig: mapConfig{"fsc.p2p.protocol.maxVersion": ProtocolVersion1_1},
+		pinned:    []PinnedProtocol{{Endpoint: "bob", Version: ProtocolVersion1_0}},
+	}, endpoints{"bob": "bobID"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Protocols{MaxVersion: ProtocolVersion1_1, Pinned: map[string]string{"bobID": ProtocolVersion1_0}}, p)
+
+	_, err = NewProtocolsFromConfig(mapConfig{"fsc.p2p.protocol.maxVersion": "2.0.0"}, endpoints{})
+	assert.EqualError(t, err, "unsupported view protocol version [2.0.0], expected one of [1.2.0, 1.1.0, 1.0.0]")
+	_, err = NewProtocolsFromConfig(&pinnedConfig{
+		mapConfig: mapConfig{"fsc.p2p.protocol.maxVersion": ProtocolVersion1_1},
+		pinned:    []PinnedProtocol{{Endpoint: "bob", Version: ProtocolVersion1_2}},
+	}, endpoints{"bob": "bobID"})
+	assert.EqualError(t, err, "view protocol version [1.2.0] pinned for [bob] is above the maximum version [1.1.0]")
+	_, err = NewProtocolsFromConfig(&pinnedConfig{
+		mapConfig: mapConfig{},
+		pinned:    []PinnedProtocol{{Endpoint: "charlie", Version: ProtocolVersion1_0}},
+	}, endpoints{"bob": "bobID"})
+	assert.Error(t, err)
+}
+
+func TestCodecs(t *testing.T) {
+	compression := &Compression{Algorithm: CompressionGzip}
+	payload := make([]byte, 1024)
+	heartbeat := &ViewPacket{SessionID: "s", Status: heartbeatStatus}
+
+	// the first version never compresses and refuses compressed payloads
+	v10 := codecs[codecIndex(ProtocolVersion1_0)]
+	packet, err := v10.encode(&ViewPacket{SessionID: "s", Payload: payload}, compression)
+	assert.NoError(t, err)
+	assert.Empty(t, packet.Compression)
+	packet, err = v10.encode(heartbeat, compression)
+	assert.NoError(t, err)
+	assert.Nil(t, packet)
+	_, err = v10.decode(&ViewPacket{SessionID: "s", Payload: []byte("compressed"), Compression: CompressionGzip})
+	assert.EqualError(t, err, "view protocol [1.0.0] does not carry compressed payloads")
+
+	// the previous version compresses, but it does not carry heartbeats
+	v11 := codecs[codecIndex(ProtocolVersion1_1)]
+	assert.False(t, v11.flowControl())
+	packet, err = v11.encode(&ViewPacket{SessionID: "s", Payload: payload}, compression)
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionGzip, packet.Compression)
+	decoded, err := v11.decode(packet)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, decoded.Payload)
+	packet, err = v11.encode(heartbeat, compression)
+	assert.NoError(t, err)
+	assert.Nil(t, packet)
+
+	// the current version carries everything
+	current := codecOf(protocolID(CurrentProtocolVersion))
+	assert.True(t, current.flowControl())
+	packet, err = current.encode(heartbeat, compression)
+	assert.NoError(t, err)
+	assert.Equal(t, heartbeat, packet)
+	assert.Equal(t, ProtocolVersion1_0, codecOf("/fsc/view/0.9.0").version())
+}
+
+func TestCrossVersionSessions(t *testing.T) {
+	bootstrapNode, node, _, nodeID := setupTwoNodesFromFiles(t)
+	// the node runs older software, it speaks up to the previous version
+	node.setProtocols(&Protocols{MaxVersion: ProtocolVersion1_1})
+	ctx := context.Background()
+	bootstrapNode.Start(ctx)
+	node.Start(ctx)
+	defer bootstrapNode.Stop()
+	defer node.Stop()
+
+	master, err := node.MasterSession()
+	assert.NoError(t, err)
+
+	session, err := bootstrapNode.NewSession("", "", "", []byte(nodeID))
+	assert.NoError(t, err)
+	assert.NoError(t, session.Send([]byte("ciao")))
+	assert.Equal(t, ProtocolVersion1_1, session.(*NetworkStreamSession).ProtocolVersion())
+
+	var msg *view.Message
+	select {
+	case msg = <-master.Receive():
+		assert.Equal(t, []byte("ciao"), msg.Payload)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the node did not receive the first message")
+	}
+	reply, err := node.NewSessionWithID(msg.SessionID, msg.ContextID, "", msg.FromPKID, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, reply.Send([]byte("ciaoback")))
+	assert.Equal(t, ProtocolVersion1_1, reply.(*NetworkStreamSession).ProtocolVersion())
+
+	select {
+	case msg = <-session.Receive():
+		assert.Equal(t, []byte("ciaoback"), msg.Payload)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the bootstrap node did not receive the reply")
+	}
+	session.Close()
+	reply.Close()
+}
+
+func TestIncompatibleProtocol(t *testing.T) {
+	bootstrapNode, node, _, nodeID := setupTwoNodesFromFiles(t)
+	node.setProtocols(&Protocols{MaxVersion: ProtocolVersion1_1})
+	// the bootstrap node expects the node to speak the current version only
+	bootstrapNode.setProtocols(&Protocols{MaxVersion: CurrentProtocolVersion, Pinned: map[string]string{nodeID: CurrentProtocolVersion}})
+	ctx := context.Background()
+	bootstrapNode.Start(ctx)
+	node.Start(ctx)
+	defer bootstrapNode.Stop()
+	defer node.Stop()
+
+	session, err := bootstrapNode.NewSession("", "", "", []byte(nodeID))
+	assert.NoError(t, err)
+	defer session.Close()
+	// the bootstrap node learns the versions of the node by identify
+	assert.Eventually(t, func() bool {
+		err = session.Send([]byte("ciao"))
+		incompatible := &ErrIncompatibleProtocol{}
+		if !errors.As(err, &incompatible) {
+			return false
+		}
+		assert.Equal(t, &ErrIncompatibleProtocol{Peer: nodeID, Local: ProtocolVersion1_2, Remote: ProtocolVersion1_1}, incompatible)
+		return true
+	}, 30*time.Second, 500*time.Millisecond)
+	assert.EqualError(t, err, "no common view protocol version with ["+nodeID+"]: local version [1.2.0], remote version [1.1.0]")
+}
@@ -8,11 +8,22 @@ package comm
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
 	"go.uber.org/zap/zapcore"
 )
 
+// localVersion is the version of this node, carried by the messages sent
+var localVersion = version.Get().Version
+
+// localMetadata is the metadata of the messages sent on the sessions with no metadata set. It is never modified.
+var localMetadata = map[string]string{view.SessionVersionMetadata: localVersion}
+
 // NetworkStreamSession implements view.Session
 type NetworkStreamSession struct {
 	node            *P2PNode
@@ -23,9 +34,25 @@ type NetworkStreamSession struct {
 	caller          view.Identity
 	callerViewID    string
 	incoming        chan *view.Message
-	streams         map[*streamHandler]struct{}
-	closed          bool
-	mutex           sync.Mutex
+	// metadata are carried by the messages sent, see SetMetadata, together with the version of this node
+	metadata map[string]string
+	// peerVersion is the version of the remote node, as told by the last message received carrying it
+	peerVersion string
+	// label is the label of the session, set by its initiator, see view.SessionLabelMetadata
+	label string
+	// inbox delivers the messages received to incoming
+	inbox *inbox
+	// streams are the streams the messages of the session have been received on
+	streams map[*streamHandler]struct{}
+	// out is the stream the session sends on, nil until the first send and after the stream failed
+	out    *streamHandler
+	closed bool
+	mutex  sync.Mutex
+
+	// heartbeats, if not nil, emits and watches the heartbeats of the session
+	heartbeats *heartbeats
+	// tracker, if not nil, records the messages sent and delivered, see TrackFlow
+	tracker view.FlowTracker
 }
 
 func (n *NetworkStreamSession) Info() view.SessionInfo {
@@ -37,6 +64,7 @@ func (n *NetworkStreamSession) Info() view.SessionInfo {
 		Endpoint:     n.endpointAddress,
 		EndpointPKID: n.endpointID,
 		Closed:       n.closed,
+		Label:        n.label,
 	}
 	n.mutex.Unlock()
 	return ret
@@ -52,52 +80,181 @@ func (n *NetworkStreamSession) SendError(payload []byte) error {
 	return n.sendWithStatus(payload, view.ERROR)
 }
 
+// SetMetadata sets the metadata carried by the messages sent from now on
+func (n *NetworkStreamSession) SetMetadata(metadata map[string]string) {
+	m := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		m[k] = v
+	}
+	m[view.SessionVersionMetadata] = localVersion
+	n.mutex.Lock()
+	n.metadata = m
+	n.label = m[view.SessionLabelMetadata]
+	n.mutex.Unlock()
+}
+
+// PeerVersion returns the version of the remote node, as told by the messages received from it.
+// It is version.Unknown before the first message, and for the legacy nodes not telling their version.
+func (n *NetworkStreamSession) PeerVersion() version.Version {
+	n.mutex.Lock()
+	v := n.peerVersion
+	n.mutex.Unlock()
+	if len(v) == 0 {
+		return version.Unknown
+	}
+	return version.Parse(v)
+}
+
+// ProtocolVersion returns the version of the view protocol negotiated with the remote node on the stream the
+// session sends on. It is empty before the first send, and after the stream failed.
+func (n *NetworkStreamSession) ProtocolVersion() string {
+	n.mutex.Lock()
+	out := n.out
+	n.mutex.Unlock()
+	if out == nil {
+		return ""
+	}
+	return out.codec.version()
+}
+
+// TrackFlow makes the session record, in the passed tracker, the messages it sends and those it delivers
+func (n *NetworkStreamSession) TrackFlow(tracker view.FlowTracker) {
+	n.mutex.Lock()
+	n.tracker = tracker
+	n.mutex.Unlock()
+}
+
+// delivered records the delivery of the passed message, if the session is tracked
+func (n *NetworkStreamSession) delivered(msg *view.Message) {
+	n.mutex.Lock()
+	tracker := n.tracker
+	n.mutex.Unlock()
+	if tracker != nil {
+		tracker.Exchange(n, view.SessionReceived, msg.Status, msg.Payload)
+	}
+}
+
+// observeVersion records the version of the remote node carried by the passed metadata, if any.
+// n.mutex must be held, if the session is shared already.
+func (n *NetworkStreamSession) observeVersion(metadata map[string]string) {
+	if v, ok := metadata[view.SessionVersionMetadata]; ok {
+		n.peerVersion = v
+	}
+}
+
 // Receive returns a channel of messages received from the endpoint
 func (n *NetworkStreamSession) Receive() <-chan *view.Message {
 	return n.incoming
 }
 
-// Close releases all the resources allocated by this session
-func (n *NetworkStreamSession) Close() {
-	defer logger.Debugf("Closing session [%s]", n.sessionID)
-	n.node.sessionsMutex.Lock()
-	toClose := make([]*streamHandler, 0, len(n.streams))
-	for stream := range n.streams {
-		stream.refCtr--
-		if stream.refCtr == 0 {
-			toClose = append(toClose, stream)
-		}
+// EnableHeartbeats makes both ends of the session emit a heartbeat every interval.
+// The remote end is unreachable after it missed the passed number of consecutive heartbeats.
+// Non-positive values select the defaults of the node. Enabling the heartbeats again has no effect.
+func (n *NetworkStreamSession) EnableHeartbeats(interval time.Duration, misses int) error {
+	defaults := n.node.heartbeat
+	if defaults == nil {
+		defaults = &Heartbeat{Interval: DefaultHeartbeatInterval, Misses: DefaultHeartbeatMisses}
+	}
+	if interval <= 0 {
+		interval = defaults.Interval
+	}
+	if misses <= 0 {
+		misses = defaults.Misses
 	}
-	n.node.sessionsMutex.Unlock()
 
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.closed {
+		return errors.Errorf("session [%s] is closed", n.sessionID)
+	}
+	if n.heartbeats != nil {
+		return nil
+	}
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("Closing session stream [%s]", n.sessionID)
+		logger.Debugf("enable heartbeats on session [%s] every [%s], unreachable after [%d] misses", n.sessionID, interval, misses)
+	}
+	n.heartbeats = newHeartbeats(n, interval, misses)
+	n.heartbeats.start()
+	return nil
+}
+
+// Liveness returns the state of the remote end of the session, as told by its heartbeats
+func (n *NetworkStreamSession) Liveness() Liveness {
+	n.mutex.Lock()
+	h := n.heartbeats
+	n.mutex.Unlock()
+	if h == nil {
+		return Liveness{Reachable: true}
 	}
-	for _, stream := range toClose {
-		stream.close()
+	return h.liveness()
+}
+
+// stopHeartbeats stops the heartbeats of the session, if any
+func (n *NetworkStreamSession) stopHeartbeats() {
+	n.mutex.Lock()
+	h := n.heartbeats
+	n.heartbeats = nil
+	n.mutex.Unlock()
+	if h != nil {
+		h.close()
 	}
+}
+
+// Close releases all the resources allocated by this session
+func (n *NetworkStreamSession) Close() {
+	defer logger.Debugf("Closing session [%s]", n.sessionID)
+	// no heartbeat can be enabled once the session is closed
+	n.mutex.Lock()
+	n.closed = true
+	n.mutex.Unlock()
+	n.release()
 
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("Closing session incoming [%s]", n.sessionID)
 	}
 	close(n.incoming)
-	n.closed = true
 
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("Closing session [%s] done", n.sessionID)
 	}
 }
 
+// release stops the heartbeats and the delivery of the messages of the session, and unpins it from its stream.
+// The streams are shared with the other sessions with the same node, they are not closed.
+func (n *NetworkStreamSession) release() {
+	n.stopHeartbeats()
+	n.mutex.Lock()
+	out := n.out
+	n.out = nil
+	n.mutex.Unlock()
+	if out != nil {
+		atomic.AddInt32(&out.pinned, -1)
+		out.outbox.forget(n.sessionID)
+	}
+	n.inbox.close()
+}
+
 func (n *NetworkStreamSession) sendWithStatus(payload []byte, status int32) error {
-	err := n.node.sendTo(string(n.endpointID), n.endpointAddress, &ViewPacket{
+	n.mutex.Lock()
+	metadata, tracker := n.metadata, n.tracker
+	n.mutex.Unlock()
+	packet := &ViewPacket{
 		ContextID: n.contextID,
 		SessionID: n.sessionID,
 		Caller:    n.callerViewID,
 		Status:    status,
 		Payload:   payload,
-	})
+		Metadata:  metadata,
+	}
+	err := n.node.sendOnSession(n, packet)
+	if err != nil {
+		// the packet is not modified by the send, the compression replaces it
+		n.node.deadLetter(n, packet, err)
+	} else if tracker != nil {
+		tracker.Exchange(n, view.SessionSent, status, payload)
+	}
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
-		logger.Debugf("sent message [len:%d] to [%s:%s] with err [%s]", len(payload), string(n.endpointID), n.endpointAddress, err)
+		logger.Debugf("sent message [len:%d] to [%s:%s] with err [%s]", len(payload), flogging.Sensitive(string(n.endpointID)), n.endpointAddress, err)
 	}
 	return err
 }
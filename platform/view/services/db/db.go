@@ -92,6 +92,26 @@ func OpenVersioned(sp view.ServiceProvider, driverName, dataSourceName string, c
 	return d, nil
 }
 
+// OpenReadOnly returns a new *versioned* persistence handle on an existing data source, that is not modified:
+// the driver must implement driver.ReadOnlyDriver.
+func OpenReadOnly(sp view.ServiceProvider, driverName, dataSourceName string, config Config) (driver.VersionedPersistence, error) {
+	driversMu.RLock()
+	d, ok := drivers[driverName]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("driver [%s] not found", driverName)
+	}
+	rd, ok := d.(driver.ReadOnlyDriver)
+	if !ok {
+		return nil, errors.Errorf("driver [%s] does not support read-only data sources", driverName)
+	}
+	p, err := rd.NewReadOnly(sp, dataSourceName, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening read-only datasource [%s][%s]", driverName, dataSourceName)
+	}
+	return p, nil
+}
+
 // Unversioned returns the unversioned persistence from the supplied versioned one
 func Unversioned(store driver.VersionedPersistence) driver.Persistence {
 	return &unversioned.Unversioned{Versioned: store}
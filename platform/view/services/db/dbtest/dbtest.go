@@ -0,0 +1,310 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package dbtest is the conformance test suite of the versioned persistence drivers: every driver must pass it.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// Opener returns a new, empty, versioned persistence for the passed name, distinct per test case.
+// The suite closes it.
+type Opener func(t *testing.T, name string) driver.VersionedPersistence
+
+var cases = []struct {
+	name string
+	run  func(t *testing.T, db driver.VersionedPersistence)
+}{
+	{"ReadWrite", testReadWrite},
+	{"Discard", testDiscard},
+	{"Metadata", testMetadata},
+	{"RangeQueries", testRangeQueries},
+	{"ConfigTransactions", testConfigTransactions},
+	{"Snapshot", testSnapshot},
+	{"Namespaces", testNamespaces},
+}
+
+// RunConformance runs the conformance test suite against the persistences returned by the passed opener.
+// The persistences must implement driver.SnapshotProvider and driver.NamespaceLister.
+func RunConformance(t *testing.T, open Opener) {
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			db := open(t, c.name)
+			defer func() { assert.NoError(t, db.Close()) }()
+			c.run(t, db)
+		})
+	}
+}
+
+func set(t *testing.T, db driver.VersionedPersistence, ns string, values map[string]string, block, txNum uint64) {
+	assert.NoError(t, db.BeginUpdate())
+	for key, value := range values {
+		assert.NoError(t, db.SetState(ns, key, []byte(value), block, txNum))
+	}
+	assert.NoError(t, db.Commit())
+}
+
+func assertState(t *testing.T, db interface {
+	GetState(namespace, key string) ([]byte, uint64, uint64, error)
+}, ns, key, value string, block, txNum uint64) {
+	v, b, n, err := db.GetState(ns, key)
+	assert.NoError(t, err)
+	if len(value) == 0 {
+		assert.Empty(t, v, "key [%s:%s] is set", ns, key)
+	} else {
+		assert.Equal(t, []byte(value), v, "key [%s:%s]", ns, key)
+	}
+	assert.Equal(t, block, b, "block of [%s:%s]", ns, key)
+	assert.Equal(t, txNum, n, "tx number of [%s:%s]", ns, key)
+}
+
+func scan(t *testing.T, db interface {
+	GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error)
+}, ns, startKey, endKey string) []driver.VersionedRead {
+	itr, err := db.GetStateRangeScanIterator(ns, startKey, endKey)
+	assert.NoError(t, err)
+	defer itr.Close()
+	var res []driver.VersionedRead
+	for {
+		n, err := itr.Next()
+		assert.NoError(t, err)
+		if n == nil {
+			return res
+		}
+		res = append(res, *n)
+	}
+}
+
+func keysOf(reads []driver.VersionedRead) []string {
+	res := make([]string, 0, len(reads))
+	for _, r := range reads {
+		res = append(res, r.Key)
+	}
+	return res
+}
+
+func testReadWrite(t *testing.T, db driver.VersionedPersistence) {
+	ns := "namespace"
+	assertState(t, db, ns, "k1", "", 0, 0)
+
+	set(t, db, ns, map[string]string{"k1": "v1", "k2": "v2"}, 35, 1)
+	assertState(t, db, ns, "k1", "v1", 35, 1)
+	assertState(t, db, ns, "k2", "v2", 35, 1)
+	// the namespaces do not share keys
+	assertState(t, db, "namespace2", "k1", "", 0, 0)
+	assertState(t, db, "name", "spacek1", "", 0, 0)
+
+	// overwrite, delete, and set to nil, which deletes
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState(ns, "k1", []byte("v1'"), 36, 2))
+	assert.NoError(t, db.DeleteState(ns, "k2"))
+	assert.NoError(t, db.SetState(ns, "k3", []byte("v3"), 36, 3))
+	assert.NoError(t, db.SetState(ns, "k3", nil, 36, 4))
+	assert.NoError(t, db.DeleteState(ns, "missing"))
+	assert.NoError(t, db.Commit())
+	assertState(t, db, ns, "k1", "v1'", 36, 2)
+	assertState(t, db, ns, "k2", "", 0, 0)
+	assertState(t, db, ns, "k3", "", 0, 0)
+	assert.Equal(t, []string{"k1"}, keysOf(scan(t, db, ns, "", "")))
+}
+
+func testDiscard(t *testing.T, db driver.VersionedPersistence) {
+	ns := "namespace"
+	assert.Error(t, db.Commit())
+	assert.Error(t, db.Discard())
+
+	set(t, db, ns, map[string]string{"k1": "v1"}, 1, 0)
+	assert.NoError(t, db.BeginUpdate())
+	assert.Error(t, db.BeginUpdate(), "two updates in progress")
+	assert.NoError(t, db.SetState(ns, "k1", []byte("v1'"), 2, 0))
+	assert.NoError(t, db.SetState(ns, "k2", []byte("v2"), 2, 0))
+	assert.NoError(t, db.DeleteState(ns, "k1"))
+	assert.NoError(t, db.Discard())
+	assertState(t, db, ns, "k1", "v1", 1, 0)
+	assertState(t, db, ns, "k2", "", 0, 0)
+
+	// a new update can start
+	set(t, db, ns, map[string]string{"k2": "v2"}, 3, 0)
+	assertState(t, db, ns, "k2", "v2", 3, 0)
+}
+
+func testMetadata(t *testing.T, db driver.VersionedPersistence) {
+	ns := "namespace"
+	meta, b, n, err := db.GetStateMetadata(ns, "k1")
+	assert.NoError(t, err)
+	assert.Empty(t, meta)
+	assert.Equal(t, uint64(0), b)
+	assert.Equal(t, uint64(0), n)
+
+	set(t, db, ns, map[string]string{"k1": "v1"}, 35, 1)
+	meta, _, _, err = db.GetStateMetadata(ns, "k1")
+	assert.NoError(t, err)
+	assert.Empty(t, meta)
+
+	// the metadata and the value are kept apart, the version is the last set
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetStateMetadata(ns, "k1", map[string][]byte{"m1": []byte("v1"), "m2": []byte("v2")}, 36, 2))
+	assert.NoError(t, db.Commit())
+	meta, b, n, err = db.GetStateMetadata(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"m1": []byte("v1"), "m2": []byte("v2")}, meta)
+	assert.Equal(t, uint64(36), b)
+	assert.Equal(t, uint64(2), n)
+	assertState(t, db, ns, "k1", "v1", 36, 2)
+
+	set(t, db, ns, map[string]string{"k1": "v1'"}, 37, 3)
+	meta, _, _, err = db.GetStateMetadata(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"m1": []byte("v1"), "m2": []byte("v2")}, meta)
+	assertState(t, db, ns, "k1", "v1'", 37, 3)
+
+	// the metadata are deleted with the key
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.DeleteState(ns, "k1"))
+	assert.NoError(t, db.Commit())
+	meta, _, _, err = db.GetStateMetadata(ns, "k1")
+	assert.NoError(t, err)
+	assert.Empty(t, meta)
+}
+
+func testRangeQueries(t *testing.T, db driver.VersionedPersistence) {
+	ns := "namespace"
+	assert.Empty(t, scan(t, db, ns, "", ""))
+
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState(ns, "k2", []byte("k2_value"), 35, 1))
+	assert.NoError(t, db.SetState(ns, "k3", []byte("k3_value"), 35, 2))
+	assert.NoError(t, db.SetState(ns, "k1", []byte("k1_value"), 35, 3))
+	assert.NoError(t, db.SetState(ns, "k111", []byte("k111_value"), 35, 4))
+	// keys of other namespaces, before and after
+	assert.NoError(t, db.SetState("namespac", "k0", []byte("other"), 35, 5))
+	assert.NoError(t, db.SetState("namespace2", "k0", []byte("other"), 35, 5))
+	assert.NoError(t, db.Commit())
+
+	assert.Equal(t, []driver.VersionedRead{
+		{Key: "k1", Raw: []byte("k1_value"), Block: 35, IndexInBlock: 3},
+		{Key: "k111", Raw: []byte("k111_value"), Block: 35, IndexInBlock: 4},
+		{Key: "k2", Raw: []byte("k2_value"), Block: 35, IndexInBlock: 1},
+		{Key: "k3", Raw: []byte("k3_value"), Block: 35, IndexInBlock: 2},
+	}, scan(t, db, ns, "", ""))
+	// the start key is included, the end key excluded
+	assert.Equal(t, []string{"k1", "k111", "k2"}, keysOf(scan(t, db, ns, "k1", "k3")))
+	assert.Equal(t, []string{"k111", "k2"}, keysOf(scan(t, db, ns, "k11", "k3")))
+	assert.Equal(t, []string{"k2", "k3"}, keysOf(scan(t, db, ns, "k2", "")))
+	assert.Equal(t, []string{"k1", "k111"}, keysOf(scan(t, db, ns, "", "k2")))
+	assert.Empty(t, scan(t, db, ns, "k4", ""))
+	assert.Empty(t, scan(t, db, "missing", "", ""))
+
+	// an update in progress is not seen by the iterators
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState(ns, "k0", []byte("k0_value"), 36, 0))
+	assert.Equal(t, []string{"k1", "k111", "k2", "k3"}, keysOf(scan(t, db, ns, "", "")))
+	assert.NoError(t, db.Commit())
+	assert.Equal(t, []string{"k0", "k1", "k111", "k2", "k3"}, keysOf(scan(t, db, ns, "", "")))
+}
+
+// testConfigTransactions stores the config transactions as the Fabric vault does: composite keys, starting and
+// separated by the minimum unicode code point, in the _configtx namespace, read back by key and by range
+func testConfigTransactions(t *testing.T, db driver.VersionedPersistence) {
+	const (
+		ns     = "_configtx"
+		prefix = "\x00CHANNEL_CONFIG_ENV_BYTES\x00"
+	)
+	assert.NoError(t, db.BeginUpdate())
+	for i, sequence := range []string{"1", "2", "10"} {
+		assert.NoError(t, db.SetState(ns, prefix+sequence+"\x00", []byte("envelope"+sequence), uint64(i), 0))
+	}
+	assert.NoError(t, db.SetState(ns, "\x00OTHER\x001\x00", []byte("other"), 3, 0))
+	assert.NoError(t, db.Commit())
+
+	assertState(t, db, ns, prefix+"2\x00", "envelope2", 1, 0)
+	assertState(t, db, ns, prefix+"3\x00", "", 0, 0)
+	assertState(t, db, ns, prefix+"2", "", 0, 0)
+	assert.Equal(t, []string{prefix + "1\x00", prefix + "10\x00", prefix + "2\x00"}, keysOf(scan(t, db, ns, prefix, prefix+string(rune(0x10FFFF)))))
+	assert.Len(t, scan(t, db, ns, "", ""), 4)
+}
+
+func testSnapshot(t *testing.T, db driver.VersionedPersistence) {
+	sp, ok := db.(driver.SnapshotProvider)
+	if !assert.True(t, ok, "[%T] does not provide snapshots", db) {
+		return
+	}
+	ns := "namespace"
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState(ns, "k1", []byte("v1"), 35, 1))
+	assert.NoError(t, db.SetState(ns, "k2", []byte("v2"), 35, 2))
+	assert.NoError(t, db.SetStateMetadata(ns, "k2", map[string][]byte{"m": []byte("v")}, 35, 2))
+	assert.NoError(t, db.Commit())
+
+	s, err := sp.NewSnapshot()
+	assert.NoError(t, err)
+
+	// the snapshot is read while an update is in progress, and does not see it once committed
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState(ns, "k1", []byte("v1'"), 36, 0))
+	assert.NoError(t, db.SetState(ns, "k3", []byte("v3"), 36, 1))
+	assert.NoError(t, db.DeleteState(ns, "k2"))
+	assertState(t, s, ns, "k1", "v1", 35, 1)
+	assert.NoError(t, db.Commit())
+
+	assertState(t, s, ns, "k1", "v1", 35, 1)
+	assertState(t, s, ns, "k3", "", 0, 0)
+	meta, _, _, err := s.GetStateMetadata(ns, "k2")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"m": []byte("v")}, meta)
+
+	// the iterator outlives the snapshot, and sees it
+	itr, err := s.GetStateRangeScanIterator(ns, "", "")
+	assert.NoError(t, err)
+	s.Done()
+	var res []string
+	for n, err := itr.Next(); n != nil; n, err = itr.Next() {
+		assert.NoError(t, err)
+		res = append(res, n.Key+"="+string(n.Raw))
+	}
+	itr.Close()
+	assert.Equal(t, []string{"k1=v1", "k2=v2"}, res)
+
+	assertState(t, db, ns, "k1", "v1'", 36, 0)
+	assertState(t, db, ns, "k2", "", 0, 0)
+	assert.Equal(t, []string{"k1", "k3"}, keysOf(scan(t, db, ns, "", "")))
+}
+
+func testNamespaces(t *testing.T, db driver.VersionedPersistence) {
+	nl, ok := db.(driver.NamespaceLister)
+	if !assert.True(t, ok, "[%T] does not list its namespaces", db) {
+		return
+	}
+	namespaces, err := nl.Namespaces()
+	assert.NoError(t, err)
+	assert.Empty(t, namespaces)
+
+	assert.NoError(t, db.BeginUpdate())
+	for _, ns := range []string{"ns", "ns2", "a", "ns"} {
+		for _, key := range []string{"k1", "k2", ""} {
+			assert.NoError(t, db.SetState(ns, key, []byte("v"), 1, 0))
+		}
+	}
+	assert.NoError(t, db.Commit())
+	namespaces, err = nl.Namespaces()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "ns", "ns2"}, namespaces)
+
+	// the namespaces with no key left are not listed
+	assert.NoError(t, db.BeginUpdate())
+	for _, key := range []string{"k1", "k2", ""} {
+		assert.NoError(t, db.DeleteState("ns", key))
+	}
+	assert.NoError(t, db.Commit())
+	namespaces, err = nl.Namespaces()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "ns2"}, namespaces)
+}
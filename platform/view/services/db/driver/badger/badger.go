@@ -73,6 +73,24 @@ func OpenDB(opts Opts, config driver.Config) (*badgerDB, error) {
 	return &badgerDB{db: db, cancelCleaner: cancel}, nil
 }
 
+// OpenReadOnlyDB opens the badger database at the passed path in read-only mode, without the auto cleaner
+func OpenReadOnlyDB(opts Opts, config driver.Config) (*badgerDB, error) {
+	if len(opts.Path) == 0 {
+		return nil, errors.Errorf("path cannot be empty")
+	}
+
+	opt := badger.DefaultOptions(opts.Path)
+	opt.Logger = logger
+	copy(&opt, opts, config)
+	opt.ReadOnly = true
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open DB at '%s' in read-only mode", opts.Path)
+	}
+	return &badgerDB{db: db}, nil
+}
+
 //go:generate counterfeiter -o mock/badger.go -fake-name BadgerDB . badgerDBInterface
 
 // badgerDBInterface exists mainly for testing the auto cleaner
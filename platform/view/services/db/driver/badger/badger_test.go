@@ -18,6 +18,7 @@ import (
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/dbtest"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger/mock"
 	dbproto "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger/proto"
@@ -766,3 +767,141 @@ func BenchmarkBuilder(b *testing.B) {
 	}
 	result = s
 }
+
+func TestSnapshot(t *testing.T) {
+	ns := "namespace"
+
+	dbpath := filepath.Join(tempDir, "DB-TestSnapshot")
+	db, err := OpenDB(Opts{Path: dbpath}, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState(ns, "k1", []byte("v1"), 35, 1))
+	assert.NoError(t, db.SetState(ns, "k2", []byte("v2"), 35, 2))
+	assert.NoError(t, db.SetStateMetadata(ns, "k2", map[string][]byte{"m": []byte("v")}, 35, 2))
+	assert.NoError(t, db.Commit())
+
+	s, err := db.NewSnapshot()
+	assert.NoError(t, err)
+
+	// the snapshot is read while an update is in progress, and does not see it once committed
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState(ns, "k1", []byte("v1'"), 36, 0))
+	assert.NoError(t, db.SetState(ns, "k3", []byte("v3"), 36, 1))
+	v, block, txNum, err := s.GetState(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	assert.Equal(t, uint64(35), block)
+	assert.Equal(t, uint64(1), txNum)
+	assert.NoError(t, db.Commit())
+
+	v, _, _, err = s.GetState(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	meta, _, _, err := s.GetStateMetadata(ns, "k2")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"m": []byte("v")}, meta)
+
+	// the iterator outlives the snapshot, and sees it
+	itr, err := s.GetStateRangeScanIterator(ns, "", "")
+	assert.NoError(t, err)
+	s.Done()
+	var res []string
+	for n, err := itr.Next(); n != nil; n, err = itr.Next() {
+		assert.NoError(t, err)
+		res = append(res, n.Key+"="+string(n.Raw))
+	}
+	itr.Close()
+	assert.Equal(t, []string{"k1=v1", "k2=v2"}, res)
+
+	v, block, _, err = db.GetState(ns, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1'"), v)
+	assert.Equal(t, uint64(36), block)
+}
+
+func TestNamespaces(t *testing.T) {
+	dbpath := filepath.Join(tempDir, "DB-TestNamespaces")
+	db, err := OpenDB(Opts{Path: dbpath}, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	namespaces, err := db.Namespaces()
+	assert.NoError(t, err)
+	assert.Empty(t, namespaces)
+
+	assert.NoError(t, db.BeginUpdate())
+	for _, ns := range []string{"ns", "ns2", "a", "ns"} {
+		for _, key := range []string{"k1", "k2", ""} {
+			assert.NoError(t, db.SetState(ns, key, []byte("v"), 1, 0))
+		}
+	}
+	assert.NoError(t, db.Commit())
+	namespaces, err = db.Namespaces()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "ns", "ns2"}, namespaces)
+
+	// the namespaces with no key left are not listed
+	assert.NoError(t, db.BeginUpdate())
+	for _, key := range []string{"k1", "k2", ""} {
+		assert.NoError(t, db.DeleteState("ns", key))
+	}
+	assert.NoError(t, db.Commit())
+	namespaces, err = db.Namespaces()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "ns2"}, namespaces)
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func(t *testing.T, name string) driver.VersionedPersistence {
+		db, err := OpenDB(Opts{Path: filepath.Join(tempDir, "DB-TestConformance-"+name)}, nil)
+		assert.NoError(t, err)
+		return db
+	})
+}
+
+// pathConfig configures the badger driver to store the data sources in the passed directory
+type pathConfig struct {
+	path string
+}
+
+func (c *pathConfig) IsSet(key string) bool {
+	return false
+}
+
+func (c *pathConfig) UnmarshalKey(key string, rawVal interface{}) error {
+	if opts, ok := rawVal.(*Opts); ok {
+		opts.Path = c.path
+	}
+	return nil
+}
+
+func TestReadOnly(t *testing.T) {
+	config := &pathConfig{path: filepath.Join(tempDir, "DB-TestReadOnly")}
+
+	// the data source is not created
+	_, err := (&Driver{}).NewReadOnly(nil, "channel", config)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = os.Stat(filepath.Join(config.path, "channel"))
+	assert.True(t, os.IsNotExist(err))
+
+	db, err := (&Driver{}).NewVersioned(nil, "channel", config)
+	assert.NoError(t, err)
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState("ns", "k1", []byte("v1"), 1, 0))
+	assert.NoError(t, db.Commit())
+	assert.NoError(t, db.Close())
+
+	db, err = (&Driver{}).NewReadOnly(nil, "channel", config)
+	assert.NoError(t, err)
+	defer db.Close()
+	v, block, _, err := db.GetState("ns", "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	assert.Equal(t, uint64(1), block)
+	assert.NoError(t, db.BeginUpdate())
+	assert.Error(t, db.SetState("ns", "k2", []byte("v2"), 2, 0))
+	assert.NoError(t, db.Discard())
+}
@@ -42,6 +42,25 @@ func (v *Driver) NewVersioned(sp view.ServiceProvider, dataSourceName string, co
 	return OpenDB(*opts, config)
 }
 
+// NewReadOnly opens the passed data source in read-only mode: the directory is not created, if it does not exist,
+// and the values log is not garbage collected. The writes fail.
+func (v *Driver) NewReadOnly(sp view.ServiceProvider, dataSourceName string, config driver.Config) (driver.VersionedPersistence, error) {
+	opts := &Opts{}
+	if err := config.UnmarshalKey("", opts); err != nil {
+		return nil, errors.Wrapf(err, "failed getting opts")
+	}
+	if err := config.UnmarshalKey("", &opts.Options); err != nil {
+		return nil, errors.Wrapf(err, "failed getting opts")
+	}
+	path := filepath.Join(opts.Path, dataSourceName)
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrapf(err, "failed opening badger at [%s]", path)
+	}
+	opts.Path = path
+	logger.Infof("opening badger read-only at [%s]", path)
+	return OpenReadOnlyDB(*opts, config)
+}
+
 func (v *Driver) New(sp view.ServiceProvider, dataSourceName string, config driver.Config) (driver.Persistence, error) {
 	db, err := v.NewVersioned(sp, dataSourceName, config)
 	if err != nil {
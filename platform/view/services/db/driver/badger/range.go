@@ -24,11 +24,12 @@ var iteratorOptions = badger.IteratorOptions{
 }
 
 type rangeScanIterator struct {
-	txn       *badger.Txn
 	it        *badger.Iterator
 	startKey  string
 	endKey    string
 	namespace string
+	// release is called when the iterator is closed
+	release func()
 }
 
 func (r *rangeScanIterator) Next() (*driver.VersionedRead, error) {
@@ -40,6 +41,10 @@ func (r *rangeScanIterator) Next() (*driver.VersionedRead, error) {
 	if r.endKey != "" && (bytes.Compare(item.Key(), []byte(dbKey(r.namespace, r.endKey))) >= 0) {
 		return nil, nil
 	}
+	// the keys after those of the namespace belong to other namespaces
+	if !bytes.HasPrefix(item.Key(), []byte(r.namespace+keys.NamespaceSeparator)) {
+		return nil, nil
+	}
 
 	v, err := versionedValue(item, string(item.Key()))
 	if err != nil {
@@ -61,19 +66,46 @@ func (r *rangeScanIterator) Next() (*driver.VersionedRead, error) {
 
 func (r *rangeScanIterator) Close() {
 	r.it.Close()
-	r.txn.Discard()
+	r.release()
 }
 
 func (db *badgerDB) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
 	txn := db.db.NewTransaction(false)
+	return newRangeScanIterator(txn, namespace, startKey, endKey, txn.Discard), nil
+}
+
+func newRangeScanIterator(txn *badger.Txn, namespace string, startKey string, endKey string, release func()) *rangeScanIterator {
 	it := txn.NewIterator(iteratorOptions)
 	it.Seek([]byte(dbKey(namespace, startKey)))
 
 	return &rangeScanIterator{
-		txn:       txn,
 		it:        it,
 		startKey:  startKey,
 		endKey:    endKey,
 		namespace: namespace,
-	}, nil
+		release:   release,
+	}
+}
+
+// Namespaces returns, sorted, the namespaces with at least one key committed.
+// The keys are not read one by one: the iterator seeks past each namespace found.
+func (db *badgerDB) Namespaces() ([]string, error) {
+	txn := db.db.NewTransaction(false)
+	defer txn.Discard()
+	it := txn.NewIterator(badger.IteratorOptions{PrefetchValues: false})
+	defer it.Close()
+
+	var namespaces []string
+	for it.Rewind(); it.Valid(); {
+		key := string(it.Item().Key())
+		i := strings.Index(key, keys.NamespaceSeparator)
+		if i < 0 {
+			it.Next()
+			continue
+		}
+		namespaces = append(namespaces, key[:i])
+		// the separator is the lowest byte, the namespaces extending this one come after its keys
+		it.Seek([]byte(key[:i] + string(rune(keys.NamespaceSeparator[0]+1))))
+	}
+	return namespaces, nil
 }
@@ -0,0 +1,74 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package badger
+
+import (
+	"sync"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+)
+
+// snapshot reads from a read-only badger transaction, which sees the state committed when it was created.
+// The transaction is discarded when the snapshot is done and all its iterators are closed.
+type snapshot struct {
+	db  *badgerDB
+	txn *badger.Txn
+
+	lock      sync.Mutex
+	iterators int
+	done      bool
+}
+
+// NewSnapshot returns a snapshot of the state committed so far, it does not take the lock of the updates
+func (db *badgerDB) NewSnapshot() (driver.VersionedSnapshot, error) {
+	return &snapshot{db: db, txn: db.db.NewTransaction(false)}, nil
+}
+
+func (s *snapshot) GetState(namespace, key string) ([]byte, uint64, uint64, error) {
+	v, err := s.db.versionedValue(s.txn, dbKey(namespace, key))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return v.Value, v.Block, v.Txnum, nil
+}
+
+func (s *snapshot) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	v, err := s.db.versionedValue(s.txn, dbKey(namespace, key))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return v.Meta, v.Block, v.Txnum, nil
+}
+
+func (s *snapshot) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
+	s.lock.Lock()
+	s.iterators++
+	s.lock.Unlock()
+	return newRangeScanIterator(s.txn, namespace, startKey, endKey, s.closeIterator), nil
+}
+
+func (s *snapshot) Done() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.done = true
+	s.discard()
+}
+
+func (s *snapshot) closeIterator() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.iterators--
+	s.discard()
+}
+
+// discard discards the transaction once done and with no iterator open, badger panics otherwise. s.lock must be held.
+func (s *snapshot) discard() {
+	if s.done && s.iterators == 0 {
+		s.txn.Discard()
+	}
+}
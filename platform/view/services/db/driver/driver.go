@@ -72,6 +72,32 @@ type VersionedPersistence interface {
 	Discard() error
 }
 
+// VersionedSnapshot is a read-only view of a VersionedPersistence, as committed when the snapshot was taken
+type VersionedSnapshot interface {
+	// GetState gets the value and version for given namespace and key
+	GetState(namespace, key string) ([]byte, uint64, uint64, error)
+	// GetStateMetadata gets the metadata and version for given namespace and key
+	GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error)
+	// GetStateRangeScanIterator returns an iterator that contains all the key-values between given key ranges,
+	// as for VersionedPersistence
+	GetStateRangeScanIterator(namespace string, startKey string, endKey string) (VersionedResultsIterator, error)
+	// Done releases the snapshot, the iterators still open release it when closed
+	Done()
+}
+
+// SnapshotProvider is implemented by the VersionedPersistences that can read from snapshots.
+// The reads from a snapshot neither wait for nor block the updates in progress.
+type SnapshotProvider interface {
+	// NewSnapshot returns a snapshot of the state committed so far
+	NewSnapshot() (VersionedSnapshot, error)
+}
+
+// NamespaceLister is implemented by the VersionedPersistences that can list the namespaces they hold
+type NamespaceLister interface {
+	// Namespaces returns, sorted, the namespaces with at least one key committed
+	Namespaces() ([]string, error)
+}
+
 // Persistence models a key-value storage place
 type Persistence interface {
 	// SetState sets the given value for the given namespace and key
@@ -111,3 +137,11 @@ type Driver interface {
 	// New returns a new Persistence for the passed data source and config
 	New(sp view.ServiceProvider, dataSourceName string, config Config) (Persistence, error)
 }
+
+// ReadOnlyDriver is implemented by the drivers able to open a data source without modifying it:
+// the data source is neither created, if it does not exist, nor maintained
+type ReadOnlyDriver interface {
+	// NewReadOnly returns a VersionedPersistence reading the passed data source. The writes either fail,
+	// or are discarded when the persistence is closed. If the data source does not exist, the error wraps os.ErrNotExist.
+	NewReadOnly(sp view.ServiceProvider, dataSourceName string, config Config) (VersionedPersistence, error)
+}
@@ -26,19 +26,23 @@ type versionedValue struct {
 	metadata map[string][]byte
 }
 
+// database keeps the committed state in keys, which is replaced, never modified, by each commit:
+// the snapshots and the iterators read the state committed when they were created.
 type database struct {
 	keys  map[string]map[string]*versionedValue
 	mutex sync.Mutex
 	txn   map[string]map[string]*versionedValue
+	// file is where each commit writes the state through, if set
+	file string
 }
 
+// rangeIterator iterates over the keys of a namespace as committed when it was created
 type rangeIterator struct {
 	beg  int
 	cur  int
 	end  int
 	keys []string
-	db   *database
-	ns   string
+	m    map[string]*versionedValue
 }
 
 func (r *rangeIterator) Next() (*driver.VersionedRead, error) {
@@ -46,13 +50,12 @@ func (r *rangeIterator) Next() (*driver.VersionedRead, error) {
 		return nil, nil
 	}
 
-	var err error
-	var idx uint64
-	kv := &driver.VersionedRead{Key: r.keys[r.cur]}
-	kv.Raw, kv.Block, idx, err = r.db.GetState(r.ns, r.keys[r.cur])
-	kv.IndexInBlock = int(idx)
-	if err != nil {
-		return nil, err
+	vv := r.m[r.keys[r.cur]]
+	kv := &driver.VersionedRead{
+		Key:          r.keys[r.cur],
+		Raw:          append([]byte(nil), vv.value...),
+		Block:        vv.block,
+		IndexInBlock: int(vv.txnum),
 	}
 
 	r.cur++
@@ -117,6 +120,11 @@ func (db *database) Commit() error {
 		return errors.New("no commit in progress")
 	}
 
+	if len(db.file) != 0 {
+		if err := writeFile(db.file, db.txn); err != nil {
+			return err
+		}
+	}
 	db.keys = db.txn
 	db.txn = nil
 
@@ -159,7 +167,10 @@ func (db *database) mapForNamespace(ns string, add bool, mm map[string]map[strin
 }
 
 func (db *database) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
-	vv := db.mapForNamespaceForReading(namespace, false)
+	return newRangeIterator(db.mapForNamespaceForReading(namespace, false), startKey, endKey), nil
+}
+
+func newRangeIterator(vv map[string]*versionedValue, startKey string, endKey string) *rangeIterator {
 	sortedKeys := make([]string, 0, len(vv))
 	for k := range vv {
 		sortedKeys = append(sortedKeys, k)
@@ -175,15 +186,17 @@ func (db *database) GetStateRangeScanIterator(namespace string, startKey string,
 	if endKey == "" {
 		end = len(sortedKeys)
 	}
+	if end < beg {
+		end = beg
+	}
 
 	return &rangeIterator{
 		beg:  beg,
 		cur:  beg,
 		end:  end,
-		ns:   namespace,
-		db:   db,
 		keys: sortedKeys,
-	}, nil
+		m:    vv,
+	}
 }
 
 func (db *database) GetCachedStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
@@ -191,7 +204,15 @@ func (db *database) GetCachedStateRangeScanIterator(namespace string, startKey s
 }
 
 func (db *database) GetState(namespace string, key string) ([]byte, uint64, uint64, error) {
-	vv, in := db.mapForNamespaceForReading(namespace, false)[key]
+	return getState(db.mapForNamespaceForReading(namespace, false), key)
+}
+
+func (db *database) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	return getStateMetadata(db.mapForNamespaceForReading(namespace, false), key)
+}
+
+func getState(m map[string]*versionedValue, key string) ([]byte, uint64, uint64, error) {
+	vv, in := m[key]
 	if !in {
 		return nil, 0, 0, nil
 	}
@@ -199,8 +220,8 @@ func (db *database) GetState(namespace string, key string) ([]byte, uint64, uint
 	return append([]byte(nil), vv.value...), vv.block, vv.txnum, nil
 }
 
-func (db *database) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
-	vv, in := db.mapForNamespaceForReading(namespace, false)[key]
+func getStateMetadata(m map[string]*versionedValue, key string) (map[string][]byte, uint64, uint64, error) {
+	vv, in := m[key]
 	if !in {
 		return nil, 0, 0, nil
 	}
@@ -282,3 +303,43 @@ func (db *database) DeleteState(namespace string, key string) error {
 
 	return nil
 }
+
+// Namespaces returns, sorted, the namespaces with at least one key committed
+func (db *database) Namespaces() ([]string, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	namespaces := make([]string, 0, len(db.keys))
+	for ns, keys := range db.keys {
+		if len(keys) != 0 {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// snapshot reads the state committed when it was taken, the commits replace the state instead of modifying it
+type snapshot struct {
+	keys map[string]map[string]*versionedValue
+}
+
+// NewSnapshot returns a snapshot of the state committed so far, it neither waits for nor blocks the updates
+func (db *database) NewSnapshot() (driver.VersionedSnapshot, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return &snapshot{keys: db.keys}, nil
+}
+
+func (s *snapshot) GetState(namespace, key string) ([]byte, uint64, uint64, error) {
+	return getState(s.keys[namespace], key)
+}
+
+func (s *snapshot) GetStateMetadata(namespace, key string) (map[string][]byte, uint64, uint64, error) {
+	return getStateMetadata(s.keys[namespace], key)
+}
+
+func (s *snapshot) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (driver.VersionedResultsIterator, error) {
+	return newRangeIterator(s.keys[namespace], startKey, endKey), nil
+}
+
+func (s *snapshot) Done() {}
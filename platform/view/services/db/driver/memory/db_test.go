@@ -8,6 +8,8 @@ package mem
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"unicode/utf8"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/test-go/testify/assert"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/dbtest"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
 )
 
@@ -527,3 +530,110 @@ func TestCompositeKeys(t *testing.T) {
 		{Key: "\x00prefix0a0b030", Raw: []uint8{0x0, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x30, 0x61, 0x30, 0x62, 0x30, 0x33, 0x30}, Block: 0x23, IndexInBlock: 1},
 	}, res)
 }
+
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func(t *testing.T, name string) driver.VersionedPersistence {
+		return New()
+	})
+}
+
+func TestConformanceWriteThrough(t *testing.T) {
+	dir := t.TempDir()
+	dbtest.RunConformance(t, func(t *testing.T, name string) driver.VersionedPersistence {
+		db, err := (&Driver{}).NewVersioned(nil, name, &writeThroughConfig{dir: dir})
+		assert.NoError(t, err)
+		return db
+	})
+}
+
+// writeThroughConfig configures the memory driver to write through the passed directory
+type writeThroughConfig struct {
+	dir string
+}
+
+func (c *writeThroughConfig) IsSet(key string) bool {
+	return true
+}
+
+func (c *writeThroughConfig) UnmarshalKey(key string, rawVal interface{}) error {
+	rawVal.(*Opts).WriteThrough = c.dir
+	return nil
+}
+
+func TestWriteThrough(t *testing.T) {
+	dir := t.TempDir()
+	open := func() driver.VersionedPersistence {
+		db, err := (&Driver{}).NewVersioned(nil, "channel", &writeThroughConfig{dir: dir})
+		assert.NoError(t, err)
+		return db
+	}
+	db := open()
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState("ns", "k1", []byte("v1"), 35, 1))
+	assert.NoError(t, db.SetStateMetadata("ns", "k1", map[string][]byte{"m": []byte("v")}, 35, 1))
+	assert.NoError(t, db.SetState("ns2", "\x00k2\x00", []byte("v2"), 35, 2))
+	assert.NoError(t, db.Commit())
+	// the updates discarded are not written
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState("ns", "k3", []byte("v3"), 36, 0))
+	assert.NoError(t, db.Discard())
+	assert.NoError(t, db.Close())
+
+	db = open()
+	v, block, txNum, err := db.GetState("ns", "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	assert.Equal(t, uint64(35), block)
+	assert.Equal(t, uint64(1), txNum)
+	meta, _, _, err := db.GetStateMetadata("ns", "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"m": []byte("v")}, meta)
+	v, _, _, err = db.GetState("ns2", "\x00k2\x00")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2"), v)
+	v, _, _, err = db.GetState("ns", "k3")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// with no option, the state lives in memory only
+	db, err = (&Driver{}).NewVersioned(nil, "channel", nil)
+	assert.NoError(t, err)
+	v, _, _, err = db.GetState("ns", "k1")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	db, err := (&Driver{}).NewVersioned(nil, "channel", &writeThroughConfig{dir: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState("ns", "k1", []byte("v1"), 1, 0))
+	assert.NoError(t, db.Commit())
+	assert.NoError(t, db.Close())
+	raw, err := os.ReadFile(filepath.Join(dir, "channel", "state.json"))
+	assert.NoError(t, err)
+
+	// the state written through is loaded, the commits are not written through
+	db, err = (&Driver{}).NewReadOnly(nil, "channel", &writeThroughConfig{dir: dir})
+	assert.NoError(t, err)
+	v, _, _, err := db.GetState("ns", "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	assert.NoError(t, db.BeginUpdate())
+	assert.NoError(t, db.SetState("ns", "k2", []byte("v2"), 2, 0))
+	assert.NoError(t, db.Commit())
+	assert.NoError(t, db.Close())
+	again, err := os.ReadFile(filepath.Join(dir, "channel", "state.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, raw, again)
+
+	// a data source not written through is empty, nothing is created
+	db, err = (&Driver{}).NewReadOnly(nil, "other", &writeThroughConfig{dir: dir})
+	assert.NoError(t, err)
+	v, _, _, err = db.GetState("ns", "k1")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	_, err = os.Stat(filepath.Join(dir, "other"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -7,20 +7,69 @@ SPDX-License-Identifier: Apache-2.0
 package mem
 
 import (
+	"os"
+	"path/filepath"
+
 	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/unversioned"
+	"github.com/pkg/errors"
 )
 
+type Opts struct {
+	// WriteThrough is the directory where each data source writes its state through at each commit, and loads it
+	// from when opened. If empty, the state lives in memory only.
+	WriteThrough string
+}
+
 type Driver struct{}
 
 func (v *Driver) NewVersioned(sp view2.ServiceProvider, dataSourceName string, config driver.Config) (driver.VersionedPersistence, error) {
-	return New(), nil
+	opts := &Opts{}
+	if config != nil {
+		if err := config.UnmarshalKey("", opts); err != nil {
+			return nil, errors.Wrapf(err, "failed getting opts")
+		}
+	}
+	if len(opts.WriteThrough) == 0 {
+		return New(), nil
+	}
+	dir := filepath.Join(opts.WriteThrough, dataSourceName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed creating directory [%s]", dir)
+	}
+	file := filepath.Join(dir, "state.json")
+	logger.Infof("opening memory db writing through [%s]", file)
+	return NewWithFile(file)
+}
+
+// NewReadOnly returns a database loading the state written through by the passed data source, if configured.
+// The commits are kept in memory only, they are discarded when the database is closed.
+func (v *Driver) NewReadOnly(sp view2.ServiceProvider, dataSourceName string, config driver.Config) (driver.VersionedPersistence, error) {
+	opts := &Opts{}
+	if config != nil {
+		if err := config.UnmarshalKey("", opts); err != nil {
+			return nil, errors.Wrapf(err, "failed getting opts")
+		}
+	}
+	if len(opts.WriteThrough) == 0 {
+		return New(), nil
+	}
+	db, err := NewWithFile(filepath.Join(opts.WriteThrough, dataSourceName, "state.json"))
+	if err != nil {
+		return nil, err
+	}
+	db.file = ""
+	return db, nil
 }
 
 func (v *Driver) New(sp view2.ServiceProvider, dataSourceName string, config driver.Config) (driver.Persistence, error) {
-	return &unversioned.Unversioned{Versioned: New()}, nil
+	db, err := v.NewVersioned(sp, dataSourceName, config)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to create memory driver for [%s]", dataSourceName)
+	}
+	return &unversioned.Unversioned{Versioned: db}, nil
 }
 
 func init() {
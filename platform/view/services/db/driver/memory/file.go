@@ -0,0 +1,77 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mem
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// fileEntry is a key of the write-through file, the file lists them sorted by namespace and key to be diffable
+type fileEntry struct {
+	Namespace string            `json:"namespace"`
+	Key       string            `json:"key"`
+	Block     uint64            `json:"block"`
+	TxNum     uint64            `json:"txnum"`
+	Value     []byte            `json:"value,omitempty"`
+	Metadata  map[string][]byte `json:"metadata,omitempty"`
+}
+
+// NewWithFile returns a database loading its state from the passed file, if it exists, and writing each commit
+// through it. It is meant for debugging, the file is rewritten as a whole at each commit.
+func NewWithFile(path string) (*database, error) {
+	db := New()
+	db.file = path
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading [%s]", path)
+	}
+	var entries []fileEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling [%s]", path)
+	}
+	for _, e := range entries {
+		m := db.mapForNamespace(e.Namespace, true, db.keys)
+		m[e.Key] = &versionedValue{block: e.Block, txnum: e.TxNum, value: e.Value, metadata: e.Metadata}
+	}
+	return db, nil
+}
+
+// writeFile writes the passed state to the passed file, atomically
+func writeFile(path string, keys map[string]map[string]*versionedValue) error {
+	entries := make([]fileEntry, 0, len(keys))
+	for ns, m := range keys {
+		for key, vv := range m {
+			entries = append(entries, fileEntry{Namespace: ns, Key: key, Block: vv.block, TxNum: vv.txnum, Value: vv.value, Metadata: vv.metadata})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Namespace != entries[j].Namespace {
+			return entries[i].Namespace < entries[j].Namespace
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	raw, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling the state")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return errors.Wrapf(err, "failed writing [%s]", tmp)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrapf(err, "failed renaming [%s] to [%s]", tmp, filepath.Base(path))
+	}
+	return nil
+}
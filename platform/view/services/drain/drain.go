@@ -0,0 +1,394 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package drain
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.drain")
+
+const (
+	// DefaultTimeout is the time given to the work in flight to complete, if the drain does not set a deadline
+	DefaultTimeout = 5 * time.Minute
+	// DefaultGracePeriod is the time given to each component drained after the deadline expired, to flush its state
+	DefaultGracePeriod = 30 * time.Second
+
+	metricsInterval = time.Second
+	reportPrefix    = "fsc.drain"
+)
+
+const (
+	// Serving is the state of a node accepting new work
+	Serving int32 = iota
+	// Draining is the state of a node refusing new work and waiting for the work in flight to complete
+	Draining
+	// Drained is the state of a node whose components have been drained, it is about to stop
+	Drained
+)
+
+var stateNames = map[int32]string{Serving: "serving", Draining: "draining", Drained: "drained"}
+
+// ErrDraining is returned to the new work refused while the node drains, it can be retried on another node,
+// or on this node once it has restarted
+var ErrDraining = errors.New("UNAVAILABLE: the node is draining, retry later")
+
+// ErrAlreadyStarted is returned by Drain if the node is already draining, or drained
+var ErrAlreadyStarted = errors.New("drain already started")
+
+var (
+	stateOpts = metrics.GaugeOpts{
+		Namespace:    "drain",
+		Name:         "state",
+		Help:         "The drain state of the node: 0 serving, 1 draining, 2 drained.",
+		StatsdFormat: "%{#fqname}",
+	}
+	inFlightOpts = metrics.GaugeOpts{
+		Namespace:    "drain",
+		Name:         "in_flight",
+		Help:         "The units of work in flight, per component, while the node drains.",
+		LabelNames:   []string{"component"},
+		StatsdFormat: "%{#fqname}.%{component}",
+	}
+)
+
+// Metrics exposes the progress of the drain to the orchestrators
+type Metrics struct {
+	State    metrics.Gauge
+	InFlight metrics.Gauge
+}
+
+func NewMetrics(p metrics.Provider) *Metrics {
+	return &Metrics{
+		State:    p.NewGauge(stateOpts),
+		InFlight: p.NewGauge(inFlightOpts),
+	}
+}
+
+// Work is a unit of work in flight in a component
+type Work struct {
+	Component string `json:"component"`
+	// ID identifies the work in its component, for example the context of a flow
+	ID string `json:"id"`
+	// View is the identifier of the view of a flow
+	View  string    `json:"view,omitempty"`
+	Since time.Time `json:"since,omitempty"`
+	// Recoverable tells whether the work resumes from its last checkpoint after the restart
+	Recoverable bool `json:"recoverable,omitempty"`
+}
+
+// Drainable is implemented by the components whose work in flight must complete before the node stops.
+// The new work is refused since the drain starts, see Service.Check.
+type Drainable interface {
+	// Drain returns once the work in flight has completed, or with an error once the context is done
+	Drain(ctx context.Context) error
+	// InFlight returns the work still in flight
+	InFlight() []Work
+}
+
+// Report describes the progress of a drain
+type Report struct {
+	State     string    `json:"state"`
+	Started   time.Time `json:"started,omitempty"`
+	Deadline  time.Time `json:"deadline,omitempty"`
+	Completed time.Time `json:"completed,omitempty"`
+	// InFlight is the number of units of work in flight, per component
+	InFlight map[string]int `json:"inFlight,omitempty"`
+	// Unfinished is the work that did not complete, it is recorded for the recovery after the restart
+	Unfinished []Work `json:"unfinished,omitempty"`
+	// Previous is the report of the drain that preceded the restart of the node, if any
+	Previous *Report `json:"previous,omitempty"`
+}
+
+type component struct {
+	name      string
+	drainable Drainable
+}
+
+// Service drains the node before a planned restart: it makes the node refuse new work, waits for the components
+// to complete their work in flight, in the order of their registration, records the work that did not complete,
+// and then invokes the drained hooks, which stop the listeners of the node.
+type Service struct {
+	kvs         *kvs.KVS
+	metrics     *Metrics
+	gracePeriod time.Duration
+	state       int32
+
+	lock       sync.Mutex
+	components []component
+	onDraining []func()
+	onDrained  []func()
+	report     *Report
+	previous   *Report
+	done       chan struct{}
+}
+
+// NewService returns a serving node, the work left unfinished by the drain that preceded the restart, if any,
+// is loaded from the passed KVS
+func NewService(kvss *kvs.KVS, p metrics.Provider) *Service {
+	if p == nil {
+		p = &disabled.Provider{}
+	}
+	s := &Service{
+		kvs:         kvss,
+		metrics:     NewMetrics(p),
+		gracePeriod: DefaultGracePeriod,
+		done:        make(chan struct{}),
+	}
+	s.metrics.State.Set(float64(Serving))
+	s.previous = s.loadReport()
+	if s.previous != nil && len(s.previous.Unfinished) != 0 {
+		logger.Warnf("the drain before the restart left [%d] units of work unfinished, the recoverable flows are resumed from their checkpoint: %v", len(s.previous.Unfinished), s.previous.Unfinished)
+	}
+	return s
+}
+
+// GetService returns the drain service registered in the passed service provider, nil if not available
+func GetService(sp driver.ServiceProvider) *Service {
+	s, err := sp.GetService(reflect.TypeOf((*Service)(nil)))
+	if err != nil {
+		return nil
+	}
+	return s.(*Service)
+}
+
+// Register adds the passed component, the components are drained in the order of their registration
+func (s *Service) Register(name string, d Drainable) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.components = append(s.components, component{name: name, drainable: d})
+}
+
+// OnDraining registers a function invoked when the drain starts
+func (s *Service) OnDraining(f func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.onDraining = append(s.onDraining, f)
+}
+
+// OnDrained registers a function invoked once the components are drained
+func (s *Service) OnDrained(f func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.onDrained = append(s.onDrained, f)
+}
+
+// Done returns a channel closed once the node is drained
+func (s *Service) Done() <-chan struct{} {
+	return s.done
+}
+
+// State returns the drain state of the node, see Serving, Draining, and Drained
+func (s *Service) State() int32 {
+	if s == nil {
+		return Serving
+	}
+	return atomic.LoadInt32(&s.state)
+}
+
+// Check returns ErrDraining if the node does not accept new work
+func (s *Service) Check() error {
+	if s.State() != Serving {
+		return ErrDraining
+	}
+	return nil
+}
+
+// HealthCheck fails once the node drains, the orchestrators stop routing work to it
+func (s *Service) HealthCheck(context.Context) error {
+	return s.Check()
+}
+
+// Drain drains the node, the work in flight is given until the deadline of the passed context to complete.
+// It returns the report of the drain, and an error if some work did not complete.
+func (s *Service) Drain(ctx context.Context) (*Report, error) {
+	if err := s.begin(ctx); err != nil {
+		return nil, err
+	}
+	return s.drain(ctx)
+}
+
+// Start starts draining the node in the background, the work in flight is given the passed timeout to complete
+func (s *Service) Start(timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if err := s.begin(ctx); err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		defer cancel()
+		if _, err := s.drain(ctx); err != nil {
+			logger.Warnf("drain completed with errors: [%s]", err)
+		}
+	}()
+	return nil
+}
+
+// Status returns the progress of the drain
+func (s *Service) Status() *Report {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	report := &Report{State: stateNames[s.State()], Previous: s.previous}
+	if s.report != nil {
+		*report = *s.report
+		report.State = stateNames[s.State()]
+		report.Previous = s.previous
+	}
+	if s.State() == Draining {
+		report.InFlight = map[string]int{}
+		for _, c := range s.components {
+			report.InFlight[c.name] = len(c.drainable.InFlight())
+		}
+	}
+	return report
+}
+
+func (s *Service) begin(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&s.state, Serving, Draining) {
+		return ErrAlreadyStarted
+	}
+	s.metrics.State.Set(float64(Draining))
+
+	s.lock.Lock()
+	s.report = &Report{Started: time.Now()}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.report.Deadline = deadline
+	}
+	hooks := append([]func(){}, s.onDraining...)
+	s.lock.Unlock()
+
+	logger.Infof("draining the node, new work is refused...")
+	for _, hook := range hooks {
+		hook()
+	}
+	return nil
+}
+
+func (s *Service) drain(ctx context.Context) (*Report, error) {
+	s.lock.Lock()
+	components := append([]component{}, s.components...)
+	s.lock.Unlock()
+
+	stop := make(chan struct{})
+	go s.updateMetrics(components, stop)
+
+	for _, c := range components {
+		cctx := ctx
+		if ctx.Err() != nil {
+			// the deadline expired, the component can still flush its state
+			var cancel context.CancelFunc
+			cctx, cancel = context.WithTimeout(context.Background(), s.gracePeriod)
+			defer cancel()
+		}
+		logger.Infof("draining [%s]...", c.name)
+		if err := c.drainable.Drain(cctx); err != nil {
+			logger.Warnf("draining [%s] failed: [%s]", c.name, err)
+			continue
+		}
+		logger.Infof("draining [%s]...done", c.name)
+	}
+	close(stop)
+
+	var unfinished []Work
+	for _, c := range components {
+		for _, w := range c.drainable.InFlight() {
+			w.Component = c.name
+			unfinished = append(unfinished, w)
+		}
+		s.metrics.InFlight.With("component", c.name).Set(0)
+	}
+
+	s.lock.Lock()
+	s.report.Completed = time.Now()
+	s.report.Unfinished = unfinished
+	report := *s.report
+	hooks := append([]func(){}, s.onDrained...)
+	s.lock.Unlock()
+	report.State = stateNames[Drained]
+
+	if err := s.storeReport(&report); err != nil {
+		logger.Errorf("failed recording the unfinished work [%s]", err)
+	}
+	atomic.StoreInt32(&s.state, Drained)
+	s.metrics.State.Set(float64(Drained))
+	logger.Infof("node drained, [%d] units of work unfinished", len(unfinished))
+
+	for _, hook := range hooks {
+		hook()
+	}
+	close(s.done)
+
+	if len(unfinished) != 0 {
+		return &report, errors.Errorf("[%d] units of work did not complete", len(unfinished))
+	}
+	return &report, nil
+}
+
+func (s *Service) updateMetrics(components []component, stop chan struct{}) {
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for {
+		for _, c := range components {
+			s.metrics.InFlight.With("component", c.name).Set(float64(len(c.drainable.InFlight())))
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *Service) reportKey() (string, error) {
+	return kvs.CreateCompositeKey(reportPrefix, []string{"report"})
+}
+
+// storeReport records the passed report, the next instance of the node finds it
+func (s *Service) storeReport(report *Report) error {
+	if s.kvs == nil {
+		return nil
+	}
+	k, err := s.reportKey()
+	if err != nil {
+		return err
+	}
+	return s.kvs.Put(k, report)
+}
+
+// loadReport returns the report recorded before the restart, if any, and removes it
+func (s *Service) loadReport() *Report {
+	if s.kvs == nil {
+		return nil
+	}
+	k, err := s.reportKey()
+	if err != nil || !s.kvs.Exists(k) {
+		return nil
+	}
+	report := &Report{}
+	if err := s.kvs.Get(k, report); err != nil {
+		logger.Errorf("failed loading the report of the previous drain [%s]", err)
+		return nil
+	}
+	if err := s.kvs.Delete(k); err != nil {
+		logger.Errorf("failed removing the report of the previous drain [%s]", err)
+	}
+	return report
+}
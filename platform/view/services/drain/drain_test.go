@@ -0,0 +1,195 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package drain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flows is a component whose work completes when released
+type flows struct {
+	lock     sync.Mutex
+	inFlight []Work
+	released chan struct{}
+}
+
+func (f *flows) Drain(ctx context.Context) error {
+	select {
+	case <-f.released:
+		f.lock.Lock()
+		f.inFlight = nil
+		f.lock.Unlock()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *flows) InFlight() []Work {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]Work{}, f.inFlight...)
+}
+
+// committer is a component that flushes its state even after the deadline
+type committer struct {
+	flushed bool
+}
+
+func (c *committer) Drain(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	c.flushed = true
+	return nil
+}
+
+func (c *committer) InFlight() []Work {
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	kvss, err := kvs.NewWithConfig(registry.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	s := NewService(kvss, nil)
+	assert.NoError(t, s.Check())
+	assert.Equal(t, "serving", s.Status().State)
+
+	views := &flows{released: make(chan struct{}), inFlight: []Work{{ID: "ctx1", View: "pay", Recoverable: true}}}
+	commits := &committer{}
+	s.Register("views", views)
+	s.Register("commits", commits)
+	var hooks []string
+	s.OnDraining(func() { hooks = append(hooks, "draining") })
+	s.OnDrained(func() { hooks = append(hooks, "drained") })
+
+	// the flow does not complete within the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err := s.Drain(ctx)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(s.Check(), ErrDraining))
+	assert.Error(t, s.HealthCheck(context.Background()))
+	assert.Equal(t, []string{"draining", "drained"}, hooks)
+	assert.Equal(t, "drained", report.State)
+	assert.Equal(t, []Work{{Component: "views", ID: "ctx1", View: "pay", Recoverable: true}}, report.Unfinished)
+	// the components after the deadline are still given the grace period
+	assert.True(t, commits.flushed)
+	<-s.Done()
+
+	_, err = s.Drain(context.Background())
+	assert.Equal(t, ErrAlreadyStarted, err)
+	assert.Equal(t, ErrAlreadyStarted, s.Start(time.Minute))
+
+	// after the restart, the unfinished work is reported once
+	restarted := NewService(kvss, nil)
+	if assert.NotNil(t, restarted.Status().Previous) {
+		assert.Equal(t, report.Unfinished, restarted.Status().Previous.Unfinished)
+	}
+	assert.Nil(t, NewService(kvss, nil).Status().Previous)
+}
+
+func TestStart(t *testing.T) {
+	s := NewService(nil, nil)
+	views := &flows{released: make(chan struct{}), inFlight: []Work{{ID: "ctx1"}, {ID: "ctx2"}}}
+	s.Register("views", views)
+
+	assert.NoError(t, s.Start(time.Minute))
+	status := s.Status()
+	assert.Equal(t, "draining", status.State)
+	assert.Equal(t, map[string]int{"views": 2}, status.InFlight)
+	assert.False(t, status.Deadline.IsZero())
+
+	close(views.released)
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node not drained")
+	}
+	status = s.Status()
+	assert.Equal(t, "drained", status.State)
+	assert.Empty(t, status.Unfinished)
+}
+
+func TestHandler(t *testing.T) {
+	s := NewService(nil, nil)
+	s.Register("views", &flows{released: make(chan struct{})})
+	h := web.NewHttpHandler(logger)
+	h.RegisterURI(URI, http.MethodPost, NewHandler(s))
+	h.RegisterURI(URI, http.MethodGet, NewHandler(s))
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	get := func() int {
+		resp, err := http.Get(server.URL + "/v1" + URI)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	post := func(query string) int {
+		resp, err := http.Post(server.URL+"/v1"+URI+query, "application/json", nil)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusBadRequest, post("?timeout=soon"))
+	assert.NoError(t, s.Check())
+	assert.Equal(t, http.StatusOK, post("?timeout=5m"))
+	assert.Equal(t, http.StatusConflict, post(""))
+	assert.Equal(t, "draining", s.Status().State)
+	assert.Equal(t, http.StatusOK, get())
+}
+
+func TestInterceptor(t *testing.T) {
+	s := NewService(nil, nil)
+	command := func(c *protos.Command) *protos.SignedCommand {
+		raw, err := proto.Marshal(c)
+		assert.NoError(t, err)
+		return &protos.SignedCommand{Command: raw}
+	}
+	initiate := command(&protos.Command{Payload: &protos.Command_InitiateView{InitiateView: &protos.InitiateView{Fid: "pay"}}})
+	call := command(&protos.Command{Payload: &protos.Command_CallView{CallView: &protos.CallView{Fid: "pay"}}})
+	track := command(&protos.Command{Payload: &protos.Command_TrackView{TrackView: &protos.TrackView{Cid: "ctx1"}}})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "served", nil
+	}
+
+	for _, req := range []*protos.SignedCommand{initiate, call, track} {
+		res, err := s.UnaryServerInterceptor(context.Background(), req, &grpc.UnaryServerInfo{}, handler)
+		assert.NoError(t, err)
+		assert.Equal(t, "served", res)
+	}
+
+	assert.NoError(t, s.Start(time.Minute))
+	for _, req := range []*protos.SignedCommand{initiate, call} {
+		_, err := s.UnaryServerInterceptor(context.Background(), req, &grpc.UnaryServerInfo{}, handler)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	}
+	// the views in flight can still be tracked
+	res, err := s.UnaryServerInterceptor(context.Background(), track, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "served", res)
+}
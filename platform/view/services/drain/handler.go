@@ -0,0 +1,51 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package drain
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+)
+
+// URI is the URI, relative to the web server API, of the drain of the node.
+// POST starts the drain, the query parameter timeout bounds the time given to the work in flight, default 5m.
+// GET returns the progress of the drain.
+const URI = "/admin/drain"
+
+// Handler starts the drain of the node and serves its progress
+type Handler struct {
+	service *Service
+}
+
+// NewHandler returns a handler of the drain of the passed service
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *Handler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	if context.Req.Method != http.MethodPost {
+		return h.service.Status(), http.StatusOK
+	}
+
+	timeout := DefaultTimeout
+	if value := context.Req.URL.Query().Get("timeout"); len(value) != 0 {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 {
+			return &web.ResponseErr{Reason: "invalid timeout [" + value + "]"}, http.StatusBadRequest
+		}
+	}
+	if err := h.service.Start(timeout); err != nil {
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusConflict
+	}
+	return h.service.Status(), http.StatusAccepted
+}
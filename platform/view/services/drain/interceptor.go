@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package drain
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor refuses, with codes.Unavailable, the view invocations received while the node drains.
+// The other commands, such as the tracking of the views in flight, are still served.
+func (s *Service) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.admit(req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor refuses, with codes.Unavailable, the view invocations streamed while the node drains
+func (s *Service) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &admittedStream{ServerStream: ss, service: s})
+}
+
+// admit returns an error if the passed request invokes a view and the node drains
+func (s *Service) admit(req interface{}) error {
+	if s.Check() == nil {
+		return nil
+	}
+	sc, ok := req.(*protos2.SignedCommand)
+	if !ok {
+		return nil
+	}
+	command := &protos2.Command{}
+	if err := proto.Unmarshal(sc.Command, command); err != nil {
+		return nil
+	}
+	switch command.Payload.(type) {
+	case *protos2.Command_InitiateView, *protos2.Command_CallView:
+		return status.Error(codes.Unavailable, ErrDraining.Error())
+	}
+	return nil
+}
+
+type admittedStream struct {
+	grpc.ServerStream
+	service *Service
+}
+
+func (s *admittedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.service.admit(m)
+}
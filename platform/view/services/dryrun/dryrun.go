@@ -0,0 +1,227 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dryrun
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/pkg/errors"
+)
+
+// DefaultDialTimeout bounds each dial of a remote endpoint, if the options do not set one
+const DefaultDialTimeout = 5 * time.Second
+
+const (
+	// Passed is the status of a check that found no problem
+	Passed = "passed"
+	// Failed is the status of a check that found a problem, the node would not start, or not work, as configured
+	Failed = "failed"
+	// Warning is the status of a check that found something the node handles on startup,
+	// such as a vault not created yet, or that could not be checked without modifying the state
+	Warning = "warning"
+)
+
+// Check is the outcome of the validation of one item of the configuration of a node
+type Check struct {
+	// Component is the part of the node the item belongs to, such as the view platform or a fabric network
+	Component string `json:"component"`
+	// Name identifies the check, such as identity.cert or vault
+	Name string `json:"name"`
+	// Target is what has been checked, such as a file, an address or a channel
+	Target string `json:"target,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report aggregates the outcomes of all the checks of a dry-run, the checks keep running after a failure.
+// The checks can be added concurrently.
+type Report struct {
+	// OK is false if any check failed
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+
+	lock sync.Mutex
+}
+
+func NewReport() *Report {
+	return &Report{OK: true, Checks: []Check{}}
+}
+
+// Add records the outcome of the passed check, failed if the passed error is not nil
+func (r *Report) Add(component, name, target string, err error) {
+	check := Check{Component: component, Name: name, Target: target, Status: Passed}
+	if err != nil {
+		check.Status = Failed
+		check.Error = err.Error()
+	}
+	r.add(check)
+}
+
+// Warn records a check that did not fail, with the passed reason
+func (r *Report) Warn(component, name, target string, reason error) {
+	r.add(Check{Component: component, Name: name, Target: target, Status: Warning, Error: reason.Error()})
+}
+
+func (r *Report) add(check Check) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if check.Status == Failed {
+		r.OK = false
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+// Failures returns the checks that failed
+func (r *Report) Failures() []Check {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var res []Check
+	for _, check := range r.Checks {
+		if check.Status == Failed {
+			res = append(res, check)
+		}
+	}
+	return res
+}
+
+// WriteJSON writes the report to the passed writer, indented
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	raw, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed marshalling dry-run report")
+	}
+	if _, err := w.Write(append(raw, '\n')); err != nil {
+		return errors.Wrapf(err, "failed writing dry-run report")
+	}
+	return nil
+}
+
+// Options configure a dry-run
+type Options struct {
+	// CheckPorts enables the self-check of the listen addresses of the node: each is bound and released at once.
+	// Otherwise, no port is bound.
+	CheckPorts bool
+	// DialTimeout bounds each dial of a remote endpoint, DefaultDialTimeout if zero
+	DialTimeout time.Duration
+}
+
+func (o *Options) dialTimeout() time.Duration {
+	if o == nil || o.DialTimeout <= 0 {
+		return DefaultDialTimeout
+	}
+	return o.DialTimeout
+}
+
+// Validator is implemented by the SDKs able to validate their configuration without starting their services.
+// Validate must not modify the state of the node, write to its stores, or send anything but the connection attempts
+// to the remote endpoints, and it must add to the report all the problems it finds, not just the first one.
+type Validator interface {
+	Validate(ctx context.Context, config driver.ConfigService, opts *Options, report *Report)
+}
+
+// CheckFile fails if the passed file cannot be read
+func CheckFile(path string) error {
+	if len(path) == 0 {
+		return errors.New("no file configured")
+	}
+	if _, err := os.ReadFile(path); err != nil {
+		return errors.Wrapf(err, "failed reading [%s]", path)
+	}
+	return nil
+}
+
+// CheckCertificates fails if the passed file does not contain PEM encoded x509 certificates, or any of them
+// cannot be parsed, or has expired
+func CheckCertificates(path string) error {
+	if len(path) == 0 {
+		return errors.New("no certificate configured")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed reading [%s]", path)
+	}
+	found := false
+	for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return errors.Wrapf(err, "failed parsing certificate in [%s]", path)
+		}
+		if time.Now().After(cert.NotAfter) {
+			return errors.Errorf("certificate [%s] in [%s] expired at [%s]", cert.Subject, path, cert.NotAfter)
+		}
+		found = true
+	}
+	if !found {
+		return errors.Errorf("no PEM encoded certificate found in [%s]", path)
+	}
+	return nil
+}
+
+// CheckPrivateKey fails if the passed file does not contain a PEM encoded private key that can be parsed
+func CheckPrivateKey(path string) error {
+	if len(path) == 0 {
+		return errors.New("no private key configured")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed reading [%s]", path)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return errors.Errorf("no PEM encoded private key found in [%s]", path)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return nil
+	}
+	if _, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return nil
+	}
+	if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return nil
+	}
+	return errors.Errorf("failed parsing private key in [%s]", path)
+}
+
+// Dial fails if a TCP connection to the passed address cannot be established within the timeout of the options.
+// The connection is closed at once, nothing is sent.
+func Dial(ctx context.Context, address string, opts *Options) error {
+	if len(address) == 0 {
+		return errors.New("no address configured")
+	}
+	dialer := &net.Dialer{Timeout: opts.dialTimeout()}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, "failed dialing [%s]", address)
+	}
+	return conn.Close()
+}
+
+// Bind fails if the passed listen address cannot be bound. The listener is closed at once.
+func Bind(address string) error {
+	if len(address) == 0 {
+		return errors.New("no address configured")
+	}
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, "failed binding [%s]", address)
+	}
+	return l.Close()
+}
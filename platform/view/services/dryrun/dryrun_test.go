@@ -0,0 +1,109 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dryrun
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	r := NewReport()
+	r.Add("view", "identity.cert", "cert.pem", nil)
+	r.Warn("fabric.default", "vault", "mychannel", errors.New("not created yet"))
+	assert.True(t, r.OK)
+	assert.Empty(t, r.Failures())
+
+	// the checks keep being added after a failure
+	r.Add("view", "identity.key", "key.pem", errors.New("no such file"))
+	r.Add("fabric.default", "peer", "peer0:7051", nil)
+	assert.False(t, r.OK)
+	assert.Equal(t, []Check{{Component: "view", Name: "identity.key", Target: "key.pem", Status: Failed, Error: "no such file"}}, r.Failures())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, r.WriteJSON(buf))
+	decoded := &struct {
+		OK     bool    `json:"ok"`
+		Checks []Check `json:"checks"`
+	}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+	assert.False(t, decoded.OK)
+	assert.Len(t, decoded.Checks, 4)
+	assert.Equal(t, Warning, decoded.Checks[1].Status)
+	assert.Equal(t, "not created yet", decoded.Checks[1].Error)
+}
+
+func writeCertificate(t *testing.T, dir string, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    notAfter.Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certPath := filepath.Join(dir, "cert.pem")
+	assert.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), 0600))
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	keyPath := filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return certPath, keyPath
+}
+
+func TestCryptoMaterial(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeCertificate(t, dir, time.Now().Add(time.Hour))
+	assert.NoError(t, CheckCertificates(certPath))
+	assert.NoError(t, CheckPrivateKey(keyPath))
+	assert.NoError(t, CheckFile(keyPath))
+
+	// the certificate and the key are swapped
+	assert.Error(t, CheckCertificates(keyPath))
+	assert.Error(t, CheckPrivateKey(certPath))
+
+	assert.Error(t, CheckCertificates(filepath.Join(dir, "missing.pem")))
+	assert.Error(t, CheckPrivateKey(""))
+	assert.Error(t, CheckFile(filepath.Join(dir, "missing.pem")))
+
+	expired := t.TempDir()
+	certPath, _ = writeCertificate(t, expired, time.Now().Add(-time.Hour))
+	assert.Error(t, CheckCertificates(certPath))
+}
+
+func TestConnectivity(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := l.Addr().String()
+
+	assert.NoError(t, Dial(context.Background(), address, &Options{DialTimeout: time.Second}))
+	// an address in use cannot be bound
+	assert.Error(t, Bind(address))
+
+	assert.NoError(t, l.Close())
+	assert.Error(t, Dial(context.Background(), address, nil))
+	assert.NoError(t, Bind(address))
+	assert.Error(t, Dial(context.Background(), "", nil))
+}
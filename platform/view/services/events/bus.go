@@ -0,0 +1,352 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package events
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.events")
+
+// OverflowPolicy determines what happens when an event is published to a subscriber whose queue is full
+type OverflowPolicy int
+
+const (
+	// Block makes the publisher wait until the subscriber has room for the event
+	Block OverflowPolicy = iota
+	// DropOldest discards the oldest event in the queue of the subscriber to make room for the new one
+	DropOldest
+	// FailSubscriber cancels the subscription: the events in its queue are discarded and Err returns ErrOverflow
+	FailSubscriber
+)
+
+// DefaultQueueSize is the number of events a subscriber can queue when no size is given
+const DefaultQueueSize = 100
+
+var (
+	// ErrBusClosed is returned when subscribing to, or publishing on, a closed bus
+	ErrBusClosed = errors.New("event bus closed")
+	// ErrOverflow is reported by the subscriptions cancelled by the FailSubscriber policy
+	ErrOverflow = errors.New("subscriber queue overflow")
+)
+
+type topic struct {
+	event reflect.Type
+	key   string
+}
+
+// Bus delivers typed events to subscribers. The topic of an event is its Go type, optionally narrowed by a key.
+// Each subscriber has its own bounded queue and goroutine, a slow subscriber does not delay the others
+// unless its overflow policy is Block.
+//
+// Ordering: a subscriber receives the events of its topic in the order they are published by the same goroutine,
+// minus the ones discarded by the DropOldest policy. Handlers are invoked one at a time per subscriber.
+// There is no ordering between different subscribers, nor between events published concurrently.
+type Bus struct {
+	mutex  sync.RWMutex
+	closed bool
+	topics map[topic][]*Subscription
+}
+
+// NewBus returns a new empty Bus
+func NewBus() *Bus {
+	return &Bus{topics: map[topic][]*Subscription{}}
+}
+
+type subscriptionOptions struct {
+	key    string
+	size   int
+	policy OverflowPolicy
+}
+
+// SubscriptionOption configures a subscription
+type SubscriptionOption func(*subscriptionOptions)
+
+// WithKey restricts the subscription to the events published with the passed key
+func WithKey(key string) SubscriptionOption {
+	return func(o *subscriptionOptions) {
+		o.key = key
+	}
+}
+
+// WithQueueSize sets the number of events the subscriber can queue, it defaults to DefaultQueueSize
+func WithQueueSize(size int) SubscriptionOption {
+	return func(o *subscriptionOptions) {
+		o.size = size
+	}
+}
+
+// WithOverflowPolicy sets what happens when the queue of the subscriber is full, it defaults to Block
+func WithOverflowPolicy(policy OverflowPolicy) SubscriptionOption {
+	return func(o *subscriptionOptions) {
+		o.policy = policy
+	}
+}
+
+// Subscribe subscribes the passed handler to the events of type T.
+// A handler must not publish on the bus with the Block policy events it is itself subscribed to,
+// as its queue cannot be drained while it waits.
+//
+//	sub, err := events.Subscribe[ConfigApplied](bus, func(e ConfigApplied) { ... })
+func Subscribe[T any](bus *Bus, handler func(T), opts ...SubscriptionOption) (*Subscription, error) {
+	if handler == nil {
+		return nil, errors.New("nil handler")
+	}
+	o := &subscriptionOptions{size: DefaultQueueSize, policy: Block}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.size <= 0 {
+		return nil, errors.Errorf("invalid queue size [%d]", o.size)
+	}
+	switch o.policy {
+	case Block, DropOldest, FailSubscriber:
+	default:
+		return nil, errors.Errorf("invalid overflow policy [%d]", o.policy)
+	}
+
+	s := &Subscription{
+		bus:     bus,
+		topic:   topic{event: typeOf[T](), key: o.key},
+		handler: func(event interface{}) { handler(event.(T)) },
+		size:    o.size,
+		policy:  o.policy,
+		done:    make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mutex)
+
+	bus.mutex.Lock()
+	if bus.closed {
+		bus.mutex.Unlock()
+		return nil, ErrBusClosed
+	}
+	bus.topics[s.topic] = append(bus.topics[s.topic], s)
+	bus.mutex.Unlock()
+
+	go s.run()
+	return s, nil
+}
+
+// Publish publishes the passed event to the subscribers to the events of type T with no key,
+// and to the ones with any of the passed keys.
+func Publish[T any](bus *Bus, event T, keys ...string) error {
+	t := typeOf[T]()
+
+	bus.mutex.RLock()
+	if bus.closed {
+		bus.mutex.RUnlock()
+		return ErrBusClosed
+	}
+	subs := append([]*Subscription(nil), bus.topics[topic{event: t}]...)
+	for _, key := range keys {
+		for _, s := range bus.topics[topic{event: t, key: key}] {
+			if !contains(subs, s) {
+				subs = append(subs, s)
+			}
+		}
+	}
+	bus.mutex.RUnlock()
+
+	// the bus is not locked while waiting for the subscribers with the Block policy
+	for _, s := range subs {
+		s.enqueue(event)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for the subscribers to handle the events in their queues,
+// or for the passed context to be done.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	var subs []*Subscription
+	for _, list := range b.topics {
+		subs = append(subs, list...)
+	}
+	b.mutex.Unlock()
+
+	for _, s := range subs {
+		s.close(false, nil)
+	}
+	for _, s := range subs {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "failed draining event subscribers")
+		}
+	}
+	return nil
+}
+
+func (b *Bus) remove(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	list := b.topics[s.topic]
+	for i, e := range list {
+		if e == s {
+			list = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(b.topics, s.topic)
+	} else {
+		b.topics[s.topic] = list
+	}
+}
+
+// Subscription is the subscription of a handler to a topic of a Bus
+type Subscription struct {
+	bus     *Bus
+	topic   topic
+	handler func(event interface{})
+	size    int
+	policy  OverflowPolicy
+
+	mutex sync.Mutex
+	// cond signals that the queue has changed, or that the subscription has been closed
+	cond    *sync.Cond
+	queue   []interface{}
+	closed  bool
+	err     error
+	dropped uint64
+	done    chan struct{}
+}
+
+// Unsubscribe cancels the subscription, the events still in the queue are discarded.
+// The handler may still be running when Unsubscribe returns, see Done.
+func (s *Subscription) Unsubscribe() {
+	s.close(true, nil)
+}
+
+// Done is closed once the handler has returned for the last time
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns ErrOverflow if the subscription has been cancelled by the FailSubscriber policy, nil otherwise
+func (s *Subscription) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// Dropped returns the number of events discarded by the DropOldest policy
+func (s *Subscription) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+func (s *Subscription) enqueue(event interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for !s.closed && len(s.queue) >= s.size {
+		switch s.policy {
+		case DropOldest:
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.dropped++
+		case FailSubscriber:
+			logger.Warnf("event subscriber to [%s] overflowed its queue of [%d], cancelling it", s.topic.event, s.size)
+			s.closeLocked(true, ErrOverflow)
+		default:
+			s.cond.Wait()
+		}
+	}
+	if s.closed {
+		return
+	}
+	s.queue = append(s.queue, event)
+	s.cond.Broadcast()
+}
+
+func (s *Subscription) close(discard bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closeLocked(discard, err)
+}
+
+func (s *Subscription) closeLocked(discard bool, err error) {
+	if discard {
+		s.queue = nil
+	}
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+func (s *Subscription) run() {
+	defer close(s.done)
+	defer s.bus.remove(s)
+
+	for {
+		s.mutex.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			// closed and drained
+			s.mutex.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+
+		s.deliver(event)
+	}
+}
+
+func (s *Subscription) deliver(event interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("event handler of [%s] panicked: [%v]", s.topic.event, r)
+		}
+	}()
+	s.handler(event)
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func contains(subs []*Subscription, s *Subscription) bool {
+	for _, e := range subs {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+var busLookUp = &Bus{}
+
+// GetBus returns the event bus registered in the passed service provider
+func GetBus(sp view.ServiceProvider) (*Bus, error) {
+	s, err := sp.GetService(busLookUp)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get event bus from registry")
+	}
+	return s.(*Bus), nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package events_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/events"
+	"github.com/stretchr/testify/assert"
+)
+
+type ConfigApplied struct {
+	Sequence uint64
+}
+
+type Finality struct {
+	TxID string
+}
+
+// recorder records the events it receives, the handler blocks while the recorder is held
+type recorder[T any] struct {
+	mutex  sync.Mutex
+	events []T
+	hold   chan struct{}
+}
+
+func newRecorder[T any]() *recorder[T] {
+	hold := make(chan struct{})
+	close(hold)
+	return &recorder[T]{hold: hold}
+}
+
+func (r *recorder[T]) handle(e T) {
+	<-r.hold
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder[T]) received() []T {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]T(nil), r.events...)
+}
+
+func publishSequences(t *testing.T, bus *events.Bus, from, to uint64) {
+	for seq := from; seq <= to; seq++ {
+		assert.NoError(t, events.Publish(bus, ConfigApplied{Sequence: seq}))
+	}
+}
+
+func sequences(from, to uint64) []ConfigApplied {
+	var res []ConfigApplied
+	for seq := from; seq <= to; seq++ {
+		res = append(res, ConfigApplied{Sequence: seq})
+	}
+	return res
+}
+
+func TestBusOrdering(t *testing.T) {
+	bus := events.NewBus()
+	r1, r2 := newRecorder[ConfigApplied](), newRecorder[ConfigApplied]()
+	_, err := events.Subscribe(bus, r1.handle)
+	assert.NoError(t, err)
+	_, err = events.Subscribe(bus, r2.handle, events.WithQueueSize(1))
+	assert.NoError(t, err)
+	// other types are different topics
+	other := newRecorder[Finality]()
+	_, err = events.Subscribe(bus, other.handle)
+	assert.NoError(t, err)
+
+	publishSequences(t, bus, 1, 50)
+	assert.NoError(t, bus.Close(context.Background()))
+
+	// every subscriber gets all the events in order, even with a small queue
+	assert.Equal(t, sequences(1, 50), r1.received())
+	assert.Equal(t, sequences(1, 50), r2.received())
+	assert.Empty(t, other.received())
+
+	assert.Equal(t, events.ErrBusClosed, events.Publish(bus, ConfigApplied{}))
+	_, err = events.Subscribe(bus, r1.handle)
+	assert.Equal(t, events.ErrBusClosed, err)
+}
+
+func TestBusKeys(t *testing.T) {
+	bus := events.NewBus()
+	all, tx1, tx2 := newRecorder[Finality](), newRecorder[Finality](), newRecorder[Finality]()
+	_, err := events.Subscribe(bus, all.handle)
+	assert.NoError(t, err)
+	_, err = events.Subscribe(bus, tx1.handle, events.WithKey("tx1"))
+	assert.NoError(t, err)
+	_, err = events.Subscribe(bus, tx2.handle, events.WithKey("tx2"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, events.Publish(bus, Finality{TxID: "tx1"}, "tx1"))
+	// an event can be published with several keys, a subscriber gets it once
+	assert.NoError(t, events.Publish(bus, Finality{TxID: "tx2"}, "tx2", "tx1", "tx2"))
+	assert.NoError(t, bus.Close(context.Background()))
+
+	assert.Equal(t, []Finality{{TxID: "tx1"}, {TxID: "tx2"}}, all.received())
+	assert.Equal(t, []Finality{{TxID: "tx1"}, {TxID: "tx2"}}, tx1.received())
+	assert.Equal(t, []Finality{{TxID: "tx2"}}, tx2.received())
+}
+
+func TestBusDropOldest(t *testing.T) {
+	bus := events.NewBus()
+	r := newRecorder[ConfigApplied]()
+	r.hold = make(chan struct{})
+	taken := make(chan struct{}, 10)
+	sub, err := events.Subscribe(bus, func(e ConfigApplied) {
+		taken <- struct{}{}
+		r.handle(e)
+	}, events.WithQueueSize(3), events.WithOverflowPolicy(events.DropOldest))
+	assert.NoError(t, err)
+
+	// the first event is taken by the handler, which is held
+	publishSequences(t, bus, 1, 1)
+	<-taken
+	publishSequences(t, bus, 2, 10)
+	assert.Equal(t, uint64(6), sub.Dropped())
+
+	close(r.hold)
+	assert.NoError(t, bus.Close(context.Background()))
+	assert.Equal(t, append(sequences(1, 1), sequences(8, 10)...), r.received())
+	assert.NoError(t, sub.Err())
+}
+
+func TestBusFailSubscriber(t *testing.T) {
+	bus := events.NewBus()
+	slow, fast := newRecorder[ConfigApplied](), newRecorder[ConfigApplied]()
+	slow.hold = make(chan struct{})
+	sub, err := events.Subscribe(bus, slow.handle, events.WithQueueSize(2), events.WithOverflowPolicy(events.FailSubscriber))
+	assert.NoError(t, err)
+	_, err = events.Subscribe(bus, fast.handle)
+	assert.NoError(t, err)
+
+	publishSequences(t, bus, 1, 10)
+	close(slow.hold)
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription not cancelled")
+	}
+	assert.Equal(t, events.ErrOverflow, sub.Err())
+
+	// the other subscribers are not affected
+	publishSequences(t, bus, 11, 20)
+	assert.NoError(t, bus.Close(context.Background()))
+	assert.Equal(t, sequences(1, 20), fast.received())
+	assert.True(t, len(slow.received()) < 10)
+}
+
+func TestBusBlock(t *testing.T) {
+	bus := events.NewBus()
+	r := newRecorder[ConfigApplied]()
+	r.hold = make(chan struct{})
+	_, err := events.Subscribe(bus, r.handle, events.WithQueueSize(1))
+	assert.NoError(t, err)
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		publishSequences(t, bus, 1, 5)
+	}()
+	select {
+	case <-published:
+		t.Fatal("publisher not blocked by a full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(r.hold)
+	<-published
+	assert.NoError(t, bus.Close(context.Background()))
+	assert.Equal(t, sequences(1, 5), r.received())
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	bus := events.NewBus()
+	r := newRecorder[ConfigApplied]()
+	sub, err := events.Subscribe(bus, r.handle)
+	assert.NoError(t, err)
+
+	publishSequences(t, bus, 1, 3)
+	assert.Eventually(t, func() bool { return len(r.received()) == 3 }, time.Second, 10*time.Millisecond)
+	sub.Unsubscribe()
+	<-sub.Done()
+	publishSequences(t, bus, 4, 6)
+	assert.NoError(t, bus.Close(context.Background()))
+	assert.Equal(t, sequences(1, 3), r.received())
+}
+
+func TestBusCloseTimeout(t *testing.T) {
+	bus := events.NewBus()
+	r := newRecorder[ConfigApplied]()
+	r.hold = make(chan struct{})
+	defer close(r.hold)
+	_, err := events.Subscribe(bus, r.handle)
+	assert.NoError(t, err)
+	publishSequences(t, bus, 1, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bus.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestBusPanickingHandler(t *testing.T) {
+	bus := events.NewBus()
+	r := newRecorder[ConfigApplied]()
+	_, err := events.Subscribe(bus, func(e ConfigApplied) {
+		if e.Sequence == 2 {
+			panic("boom")
+		}
+		r.handle(e)
+	})
+	assert.NoError(t, err)
+
+	publishSequences(t, bus, 1, 3)
+	assert.NoError(t, bus.Close(context.Background()))
+	assert.Equal(t, []ConfigApplied{{Sequence: 1}, {Sequence: 3}}, r.received())
+}
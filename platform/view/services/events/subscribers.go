@@ -69,3 +69,15 @@ func (s *Subscribers) Delete(id string, wrapped interface{}) {
 		}
 	}
 }
+
+// Len returns the number of bindings
+func (s *Subscribers) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n := 0
+	for _, list := range s.backend {
+		n += len(list)
+	}
+	return n
+}
@@ -44,6 +44,9 @@ type EncodingSelector interface {
 // implementations. The core also references the logging configuration to
 // determine the proper encoding to use, the writer to delegate to, and the
 // enabled levels.
+//
+// If a Redactor is provided, the values of the sensitive fields are replaced
+// before being encoded.
 type Core struct {
 	zapcore.LevelEnabler
 	Levels   *LoggerLevels
@@ -51,6 +54,7 @@ type Core struct {
 	Selector EncodingSelector
 	Output   zapcore.WriteSyncer
 	Observer Observer
+	Redactor *Redactor
 }
 
 //go:generate counterfeiter -o mock/observer.go -fake-name Observer . Observer
@@ -61,6 +65,7 @@ type Observer interface {
 }
 
 func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	fields = c.Redactor.Fields(fields)
 	clones := map[Encoding]zapcore.Encoder{}
 	for name, enc := range c.Encoders {
 		clone := enc.Clone()
@@ -75,6 +80,7 @@ func (c *Core) With(fields []zapcore.Field) zapcore.Core {
 		Selector:     c.Selector,
 		Output:       c.Output,
 		Observer:     c.Observer,
+		Redactor:     c.Redactor,
 	}
 }
 
@@ -92,6 +98,7 @@ func (c *Core) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.Checked
 func (c *Core) Write(e zapcore.Entry, fields []zapcore.Field) error {
 	encoding := c.Selector.Encoding()
 	enc := c.Encoders[encoding]
+	fields = c.Redactor.Fields(fields)
 
 	buf, err := enc.EncodeEntry(e, fields)
 	if err != nil {
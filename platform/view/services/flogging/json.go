@@ -0,0 +1,132 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package flogging
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// JSONConfig maps the keys of the log records formatted as JSON.
+// Empty keys are given their default value.
+type JSONConfig struct {
+	// TimestampKey is the key of the time of the record, it defaults to ts
+	TimestampKey string
+	// LevelKey is the key of the level of the record, it defaults to level
+	LevelKey string
+	// LoggerKey is the key of the name of the logger, it defaults to name
+	LoggerKey string
+	// MessageKey is the key of the message, it defaults to msg
+	MessageKey string
+	// CallerKey is the key of the caller, it defaults to caller
+	CallerKey string
+	// StacktraceKey is the key of the stacktrace, it defaults to stacktrace
+	StacktraceKey string
+	// TimeEncoding is the encoding of the time of the record, one of epoch, millis, nanos, iso8601, rfc3339, and rfc3339nano.
+	// It defaults to epoch.
+	TimeEncoding string
+}
+
+// encoderConfig returns the zap encoder configuration with the mapping applied
+func (c JSONConfig) encoderConfig() (zapcore.EncoderConfig, error) {
+	ec := zap.NewProductionEncoderConfig()
+	ec.NameKey = "name"
+	for _, m := range []struct {
+		key   *string
+		value string
+	}{
+		{&ec.TimeKey, c.TimestampKey},
+		{&ec.LevelKey, c.LevelKey},
+		{&ec.NameKey, c.LoggerKey},
+		{&ec.MessageKey, c.MessageKey},
+		{&ec.CallerKey, c.CallerKey},
+		{&ec.StacktraceKey, c.StacktraceKey},
+	} {
+		if len(m.value) != 0 {
+			*m.key = m.value
+		}
+	}
+	switch c.TimeEncoding {
+	case "":
+	case "epoch", "millis", "nanos", "iso8601", "rfc3339", "rfc3339nano":
+		// zap falls back to epoch for the encodings it does not know, they are checked first
+		if err := ec.EncodeTime.UnmarshalText([]byte(c.TimeEncoding)); err != nil {
+			return zapcore.EncoderConfig{}, errors.Wrapf(err, "invalid time encoding [%s]", c.TimeEncoding)
+		}
+	default:
+		return zapcore.EncoderConfig{}, errors.Errorf("invalid time encoding [%s]", c.TimeEncoding)
+	}
+	return ec, nil
+}
+
+// jsonEncoder is a zapcore.Encoder that formats records as JSON with the key mapping
+// in use when the record is written, not the one in use when the logger is created.
+// Loggers are mostly created at package initialization, before the logging system is configured.
+type jsonEncoder struct {
+	config func() zapcore.EncoderConfig
+	fields []zapcore.Field
+}
+
+func newJSONEncoder(config func() zapcore.EncoderConfig) *jsonEncoder {
+	return &jsonEncoder{config: config}
+}
+
+func (e *jsonEncoder) Clone() zapcore.Encoder {
+	fields := make([]zapcore.Field, len(e.fields))
+	copy(fields, e.fields)
+	return &jsonEncoder{config: e.config, fields: fields}
+}
+
+func (e *jsonEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	enc := zapcore.NewJSONEncoder(e.config())
+	addFields(enc, e.fields)
+	return enc.EncodeEntry(entry, fields)
+}
+
+func (e *jsonEncoder) add(f zapcore.Field) { e.fields = append(e.fields, f) }
+
+func (e *jsonEncoder) AddArray(k string, v zapcore.ArrayMarshaler) error {
+	e.add(zap.Array(k, v))
+	return nil
+}
+
+func (e *jsonEncoder) AddObject(k string, v zapcore.ObjectMarshaler) error {
+	e.add(zap.Object(k, v))
+	return nil
+}
+
+func (e *jsonEncoder) AddReflected(k string, v interface{}) error {
+	e.add(zap.Reflect(k, v))
+	return nil
+}
+
+func (e *jsonEncoder) AddBinary(k string, v []byte)          { e.add(zap.Binary(k, v)) }
+func (e *jsonEncoder) AddByteString(k string, v []byte)      { e.add(zap.ByteString(k, v)) }
+func (e *jsonEncoder) AddBool(k string, v bool)              { e.add(zap.Bool(k, v)) }
+func (e *jsonEncoder) AddComplex128(k string, v complex128)  { e.add(zap.Complex128(k, v)) }
+func (e *jsonEncoder) AddComplex64(k string, v complex64)    { e.add(zap.Complex64(k, v)) }
+func (e *jsonEncoder) AddDuration(k string, v time.Duration) { e.add(zap.Duration(k, v)) }
+func (e *jsonEncoder) AddFloat64(k string, v float64)        { e.add(zap.Float64(k, v)) }
+func (e *jsonEncoder) AddFloat32(k string, v float32)        { e.add(zap.Float32(k, v)) }
+func (e *jsonEncoder) AddInt(k string, v int)                { e.add(zap.Int(k, v)) }
+func (e *jsonEncoder) AddInt64(k string, v int64)            { e.add(zap.Int64(k, v)) }
+func (e *jsonEncoder) AddInt32(k string, v int32)            { e.add(zap.Int32(k, v)) }
+func (e *jsonEncoder) AddInt16(k string, v int16)            { e.add(zap.Int16(k, v)) }
+func (e *jsonEncoder) AddInt8(k string, v int8)              { e.add(zap.Int8(k, v)) }
+func (e *jsonEncoder) AddString(k, v string)                 { e.add(zap.String(k, v)) }
+func (e *jsonEncoder) AddTime(k string, v time.Time)         { e.add(zap.Time(k, v)) }
+func (e *jsonEncoder) AddUint(k string, v uint)              { e.add(zap.Uint(k, v)) }
+func (e *jsonEncoder) AddUint64(k string, v uint64)          { e.add(zap.Uint64(k, v)) }
+func (e *jsonEncoder) AddUint32(k string, v uint32)          { e.add(zap.Uint32(k, v)) }
+func (e *jsonEncoder) AddUint16(k string, v uint16)          { e.add(zap.Uint16(k, v)) }
+func (e *jsonEncoder) AddUint8(k string, v uint8)            { e.add(zap.Uint8(k, v)) }
+func (e *jsonEncoder) AddUintptr(k string, v uintptr)        { e.add(zap.Uintptr(k, v)) }
+func (e *jsonEncoder) OpenNamespace(k string)                { e.add(zap.Namespace(k)) }
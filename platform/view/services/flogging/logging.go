@@ -40,6 +40,16 @@ type Config struct {
 	//
 	// If a Writer is not provided, os.Stderr will be used as the log sink.
 	Writer io.Writer
+
+	// JSON maps the keys of the log records formatted as JSON.
+	//
+	// If JSON is not provided, the default keys will be used.
+	JSON JSONConfig
+
+	// Redaction determines how the values of sensitive fields are logged.
+	//
+	// If Redaction is not provided, sensitive values are logged as they are.
+	Redaction RedactionConfig
 }
 
 // Logging maintains the state associated with the fabric logging system. It is
@@ -51,9 +61,11 @@ type Logging struct {
 	mutex          sync.RWMutex
 	encoding       Encoding
 	encoderConfig  zapcore.EncoderConfig
+	jsonConfig     zapcore.EncoderConfig
 	multiFormatter *fabenc.MultiFormatter
 	writer         zapcore.WriteSyncer
 	observer       Observer
+	redactor       *Redactor
 }
 
 // New creates a new logging system and initializes it with the provided
@@ -67,7 +79,9 @@ func New(c Config) (*Logging, error) {
 			defaultLevel: defaultLevel,
 		},
 		encoderConfig:  encoderConfig,
+		jsonConfig:     encoderConfig,
 		multiFormatter: fabenc.NewMultiFormatter(),
+		redactor:       newRedactor(),
 	}
 
 	err := l.Apply(c)
@@ -84,6 +98,16 @@ func (l *Logging) Apply(c Config) error {
 		return err
 	}
 
+	err = l.SetJSON(c.JSON)
+	if err != nil {
+		return err
+	}
+
+	err = l.redactor.Configure(c.Redaction)
+	if err != nil {
+		return err
+	}
+
 	if c.LogSpec == "" {
 		c.LogSpec = os.Getenv("FSCNODE_LOGGING_SPEC")
 	}
@@ -135,6 +159,35 @@ func (l *Logging) SetFormat(format string) error {
 	return nil
 }
 
+// SetJSON updates the keys of the log records formatted as JSON. Log entries
+// created after this method has completed will use the new keys, also when
+// written by loggers created before.
+//
+// An error is returned if the time encoding cannot be parsed.
+func (l *Logging) SetJSON(c JSONConfig) error {
+	ec, err := c.encoderConfig()
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	l.jsonConfig = ec
+	l.mutex.Unlock()
+
+	return nil
+}
+
+func (l *Logging) jsonEncoderConfig() zapcore.EncoderConfig {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.jsonConfig
+}
+
+// Redactor returns the redactor of sensitive values used by the loggers of the logging system.
+func (l *Logging) Redactor() *Redactor {
+	return l.redactor
+}
+
 // SetWriter controls which writer formatted log records are written to.
 // Writers, with the exception of an *os.File, need to be safe for concurrent
 // use by multiple go routines.
@@ -210,13 +263,14 @@ func (l *Logging) ZapLogger(name string) *zap.Logger {
 		LevelEnabler: l.LoggerLevels,
 		Levels:       l.LoggerLevels,
 		Encoders: map[Encoding]zapcore.Encoder{
-			JSON:    zapcore.NewJSONEncoder(l.encoderConfig),
+			JSON:    newJSONEncoder(l.jsonEncoderConfig),
 			CONSOLE: fabenc.NewFormatEncoder(l.multiFormatter),
 			LOGFMT:  zaplogfmt.NewEncoder(l.encoderConfig),
 		},
 		Selector: l,
 		Output:   l,
 		Observer: l,
+		Redactor: l.redactor,
 	}
 	l.mutex.RUnlock()
 
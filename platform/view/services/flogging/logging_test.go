@@ -157,3 +157,27 @@ func TestLoggerCoreCheck(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, logger.Core().Enabled(zapcore.DebugLevel), "debug should now be enabled at debug level")
 }
+
+func TestJSONKeys(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := flogging.New(flogging.Config{Format: "json", LogSpec: "debug", Writer: buf})
+	assert.NoError(t, err)
+
+	// the keys apply to the loggers created before their configuration too
+	logger := logging.Logger("foo").With("key", "value")
+	err = logging.SetJSON(flogging.JSONConfig{
+		TimestampKey: "@timestamp",
+		LevelKey:     "severity",
+		LoggerKey:    "logger",
+		MessageKey:   "message",
+		CallerKey:    "source",
+		TimeEncoding: "rfc3339",
+	})
+	assert.NoError(t, err)
+	logger.Debugw("a message", "other", 1)
+
+	assert.Regexp(t, `^{"severity":"debug","@timestamp":"[^"]+","logger":"foo","source":"flogging/logging_test.go:\d+","message":"a message","key":"value","other":1}\s+$`, buf.String())
+
+	err = logging.SetJSON(flogging.JSONConfig{TimeEncoding: "sundial"})
+	assert.EqualError(t, err, `invalid time encoding [sundial]`)
+}
@@ -0,0 +1,218 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package flogging
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactionMode determines how the values of sensitive fields are logged
+type RedactionMode string
+
+const (
+	// NoRedaction logs sensitive values as they are
+	NoRedaction RedactionMode = "none"
+	// Redact replaces sensitive values with the Redacted marker
+	Redact RedactionMode = "redact"
+	// Hash replaces sensitive values with their SHA-256 hash, so that they can still be correlated across records
+	Hash RedactionMode = "hash"
+
+	// Redacted replaces the sensitive values when the mode is Redact
+	Redacted = "[REDACTED]"
+)
+
+// RedactionConfig determines which values are sensitive and how they are logged.
+type RedactionConfig struct {
+	// Mode is how sensitive values are logged. If Mode is not provided, NoRedaction is used.
+	Mode RedactionMode
+	// Fields lists the keys of the structured fields whose values are sensitive,
+	// in addition to the ones registered with RegisterSensitiveFields.
+	Fields []string
+}
+
+// Redactor replaces the values of sensitive fields, and of the values marked with Sensitive, according to its mode.
+type Redactor struct {
+	mutex sync.RWMutex
+	mode  RedactionMode
+	// registered are the sensitive keys registered by the code
+	registered map[string]struct{}
+	// configured are the sensitive keys given by the configuration
+	configured map[string]struct{}
+}
+
+func newRedactor() *Redactor {
+	return &Redactor{
+		mode:       NoRedaction,
+		registered: map[string]struct{}{},
+		configured: map[string]struct{}{},
+	}
+}
+
+// Configure applies the passed configuration, replacing the sensitive keys of the previous one
+func (r *Redactor) Configure(c RedactionConfig) error {
+	mode := c.Mode
+	switch mode {
+	case "":
+		mode = NoRedaction
+	case NoRedaction, Redact, Hash:
+	default:
+		return errors.Errorf("invalid redaction mode [%s]", mode)
+	}
+	configured := map[string]struct{}{}
+	for _, key := range c.Fields {
+		configured[key] = struct{}{}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.mode = mode
+	r.configured = configured
+	return nil
+}
+
+// Register marks the structured fields with the passed keys as sensitive
+func (r *Redactor) Register(keys ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, key := range keys {
+		r.registered[key] = struct{}{}
+	}
+}
+
+// Mode returns the redaction mode in use
+func (r *Redactor) Mode() RedactionMode {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.mode
+}
+
+// Fields returns the passed fields with the values of the sensitive ones replaced.
+// The passed slice is returned as it is if no field needs to be replaced.
+func (r *Redactor) Fields(fields []zapcore.Field) []zapcore.Field {
+	if r == nil || len(fields) == 0 {
+		return fields
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.mode == NoRedaction {
+		return fields
+	}
+
+	var redacted []zapcore.Field
+	for i, f := range fields {
+		if !r.isSensitive(f.Key) {
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]zapcore.Field, len(fields))
+			copy(redacted, fields)
+		}
+		redacted[i] = zap.String(f.Key, r.redact(fieldValue(f)))
+	}
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
+
+func (r *Redactor) isSensitive(key string) bool {
+	if _, ok := r.registered[key]; ok {
+		return true
+	}
+	_, ok := r.configured[key]
+	return ok
+}
+
+func (r *Redactor) replace(v interface{}) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.redact(v)
+}
+
+// redact returns the replacement of the passed value according to the mode
+func (r *Redactor) redact(v interface{}) string {
+	if r.mode == Redact {
+		return Redacted
+	}
+	var raw []byte
+	switch t := v.(type) {
+	case []byte:
+		raw = t
+	case string:
+		raw = []byte(t)
+	default:
+		raw = []byte(fmt.Sprint(t))
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(raw))
+}
+
+// fieldValue returns the value of the passed field, as it would be encoded
+func fieldValue(f zapcore.Field) interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return enc.Fields[f.Key]
+}
+
+// Sensitive marks the passed value as sensitive when used as the operand of a formatted log message:
+// it is formatted as it is, redacted, or hashed, according to the redaction mode of the logging system.
+//
+//	logger.Debugf("sent message to [%s]", flogging.Sensitive(identity))
+func Sensitive(v interface{}) fmt.Formatter {
+	return &sensitive{v: v, redactor: Global.redactor}
+}
+
+type sensitive struct {
+	v        interface{}
+	redactor *Redactor
+}
+
+func (s *sensitive) Format(f fmt.State, verb rune) {
+	if s.redactor.Mode() == NoRedaction {
+		fmt.Fprintf(f, formatDirective(f, verb), s.v)
+		return
+	}
+	// the replacement of the value is always a string, what matters is the value the verb would print
+	var v interface{} = s.v
+	if _, ok := v.([]byte); !ok {
+		v = fmt.Sprintf(formatDirective(f, verb), s.v)
+	}
+	fmt.Fprint(f, s.redactor.replace(v))
+}
+
+func (s *sensitive) String() string {
+	return fmt.Sprintf("%v", s)
+}
+
+// formatDirective rebuilds the directive the passed state and verb have been parsed from
+func formatDirective(f fmt.State, verb rune) string {
+	directive := []byte{'%'}
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			directive = append(directive, byte(flag))
+		}
+	}
+	if width, ok := f.Width(); ok {
+		directive = strconv.AppendInt(directive, int64(width), 10)
+	}
+	if precision, ok := f.Precision(); ok {
+		directive = append(directive, '.')
+		directive = strconv.AppendInt(directive, int64(precision), 10)
+	}
+	return string(append(directive, string(verb)...))
+}
+
+// RegisterSensitiveFields marks the structured fields with the passed keys as sensitive
+func RegisterSensitiveFields(keys ...string) {
+	Global.redactor.Register(keys...)
+}
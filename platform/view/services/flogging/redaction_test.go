@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package flogging_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+)
+
+func TestRedactionFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := flogging.New(flogging.Config{
+		Format:    "json",
+		LogSpec:   "debug",
+		Writer:    buf,
+		Redaction: flogging.RedactionConfig{Mode: flogging.Redact, Fields: []string{"payload"}},
+	})
+	assert.NoError(t, err)
+	logging.Redactor().Register("identity")
+
+	logger := logging.Logger("foo").With("identity", []byte("alice"))
+	logger.Debugw("a message", "payload", "secret", "size", 6)
+	assert.Contains(t, buf.String(), `"msg":"a message","identity":"[REDACTED]","payload":"[REDACTED]","size":6}`)
+
+	buf.Reset()
+	err = logging.Apply(flogging.Config{
+		Format:    "json",
+		LogSpec:   "debug",
+		Writer:    buf,
+		Redaction: flogging.RedactionConfig{Mode: flogging.Hash},
+	})
+	assert.NoError(t, err)
+	logger = logging.Logger("foo")
+	logger.Debugw("a message", "identity", "alice", "payload", "secret")
+	assert.Contains(t, buf.String(), fmt.Sprintf(`"identity":"sha256:%x","payload":"secret"}`, sha256.Sum256([]byte("alice"))))
+
+	buf.Reset()
+	err = logging.Apply(flogging.Config{Format: "json", LogSpec: "debug", Writer: buf})
+	assert.NoError(t, err)
+	logger.Debugw("a message", "identity", "alice")
+	assert.Contains(t, buf.String(), `"identity":"alice"}`)
+
+	err = logging.Apply(flogging.Config{Redaction: flogging.RedactionConfig{Mode: "shred"}})
+	assert.EqualError(t, err, "invalid redaction mode [shred]")
+}
+
+func TestSensitive(t *testing.T) {
+	flogging.Reset()
+	defer flogging.Reset()
+
+	buf := &bytes.Buffer{}
+	flogging.Init(flogging.Config{Format: "%{message}", LogSpec: "debug", Writer: buf})
+	logger := flogging.MustGetLogger("testlogger")
+
+	logger.Debugf("sent [%s][%5.2f][%x]", flogging.Sensitive("alice"), flogging.Sensitive(3.14159), flogging.Sensitive([]byte{1, 2}))
+	assert.Equal(t, "sent [alice][ 3.14][0102]\n", buf.String())
+
+	buf.Reset()
+	flogging.Init(flogging.Config{
+		Format:    "%{message}",
+		LogSpec:   "debug",
+		Writer:    buf,
+		Redaction: flogging.RedactionConfig{Mode: flogging.Redact},
+	})
+	logger.Debugf("sent [%s] to [%v]", flogging.Sensitive("payload"), flogging.Sensitive(struct{ A int }{1}))
+	assert.Equal(t, "sent [[REDACTED]] to [[REDACTED]]\n", buf.String())
+
+	buf.Reset()
+	flogging.Init(flogging.Config{
+		Format:    "%{message}",
+		LogSpec:   "debug",
+		Writer:    buf,
+		Redaction: flogging.RedactionConfig{Mode: flogging.Hash},
+	})
+	logger.Debugf("sent [%s]", flogging.Sensitive("payload"))
+	assert.Equal(t, fmt.Sprintf("sent [sha256:%x]\n", sha256.Sum256([]byte("payload"))), buf.String())
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("payload"))), fmt.Sprint(flogging.Sensitive("payload")))
+}
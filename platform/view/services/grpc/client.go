@@ -73,6 +73,14 @@ type Client struct {
 	grpcConns []*grpc.ClientConn
 	// Mutex on grpcConns
 	grpcCMux sync.Mutex
+	// Compression of the messages sent on the connections
+	compression        string
+	compressionMetrics *CompressionMetrics
+	// resolution of the names in the addresses, nil to leave it to gRPC
+	resolution *ResolutionConfig
+	// interceptors of the connections, after the registered ones
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
 }
 
 // NewGRPCClient creates a new implementation of Client given an address
@@ -87,6 +95,9 @@ func NewGRPCClient(config ClientConfig) (*Client, error) {
 	if err != nil {
 		return client, err
 	}
+	if client.tlsConfig != nil {
+		client.tlsConfig.ServerName = config.ServerNameOverride
+	}
 
 	// keepalive options
 
@@ -103,6 +114,19 @@ func NewGRPCClient(config ClientConfig) (*Client, error) {
 		client.dialOpts = append(client.dialOpts, grpc.FailOnNonTempDialError(true))
 	}
 	client.timeout = config.Timeout
+	if err := ValidateCompression(config.Compression); err != nil {
+		return client, err
+	}
+	client.compression = config.Compression
+	client.compressionMetrics = config.CompressionMetrics
+	if config.Resolution != nil {
+		if err := config.Resolution.Validate(); err != nil {
+			return client, err
+		}
+		client.resolution = config.Resolution
+	}
+	client.unaryInterceptors = config.UnaryInterceptors
+	client.streamInterceptors = config.StreamInterceptors
 	// set send/recv message size to package defaults
 	client.maxRecvMsgSize = MaxRecvMsgSize
 	client.maxSendMsgSize = MaxSendMsgSize
@@ -124,7 +148,10 @@ func CreateGRPCClient(config *ConnectionConfig) (*Client, error) {
 			ServerTimeout:     60 * time.Second,
 			ServerMinInterval: 60 * time.Second,
 		},
-		Timeout: timeout,
+		Timeout:            timeout,
+		Compression:        config.Compression,
+		Resolution:         config.Resolution,
+		ServerNameOverride: config.ServerNameOverride,
 	}
 
 	if config.TLSEnabled {
@@ -226,6 +253,7 @@ func (client *Client) MutualTLSRequired() bool {
 // SetMaxRecvMsgSize sets the maximum message size the client can receive
 func (client *Client) SetMaxRecvMsgSize(size int) {
 	client.maxRecvMsgSize = size
+	zstdCodec.raiseLimit(size)
 }
 
 // SetMaxSendMsgSize sets the maximum message size the client can send
@@ -278,9 +306,54 @@ func (client *Client) NewConnection(address string, tlsOptions ...TLSOption) (*g
 		grpc.MaxCallSendMsgSize(client.maxSendMsgSize),
 	))
 
+	// the registered interceptors and the ones of the client run before the ones of the platform
+	registered := RegisteredInterceptors()
+	if unary := append(registered.UnaryClient, client.unaryInterceptors...); len(unary) != 0 {
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(unary...))
+	}
+	if stream := append(registered.StreamClient, client.streamInterceptors...); len(stream) != 0 {
+		dialOpts = append(dialOpts, grpc.WithChainStreamInterceptor(stream...))
+	}
+
+	// the compression is negotiated per connection
+	if compressionEnabled(client.compression) {
+		negotiator := newCompressionNegotiator(client.compression)
+		dialOpts = append(dialOpts,
+			grpc.WithChainUnaryInterceptor(negotiator.unaryInterceptor),
+			grpc.WithChainStreamInterceptor(negotiator.streamInterceptor),
+		)
+		if client.compressionMetrics != nil {
+			dialOpts = append(dialOpts, grpc.WithStatsHandler(&compressionStatsHandler{
+				compression: client.compression,
+				metrics:     client.compressionMetrics,
+			}))
+		}
+	}
+
+	// the names are resolved again periodically, the calls balanced across the resolved addresses
+	target := address
+	if client.resolution != nil {
+		target = resolutionScheme + ":///" + address
+		dialOpts = append(dialOpts,
+			grpc.WithResolvers(&resolverBuilder{config: *client.resolution}),
+			grpc.WithDefaultServiceConfig(client.resolution.serviceConfig()),
+		)
+	}
+
+	// the endpoints refused by the dial policy are never contacted
+	if policy := GetDialPolicy(); policy != nil {
+		opt, err := policy.DialOption(address)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to create new connection")
+		}
+		if opt != nil {
+			dialOpts = append(dialOpts, opt)
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
 	defer cancel()
-	conn, err := grpc.DialContext(ctx, address, dialOpts...)
+	conn, err := grpc.DialContext(ctx, target, dialOpts...)
 	if err != nil {
 		commLogger.Debugf("failed to create new connection to [%s][%v]: [%s]", address, dialOpts, errors.WithStack(err))
 		return nil, errors.WithMessage(errors.WithStack(err), "failed to create new connection")
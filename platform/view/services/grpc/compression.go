@@ -0,0 +1,264 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// CompressionNone disables the compression of the messages
+	CompressionNone = "none"
+	// CompressionGzip compresses the messages with gzip
+	CompressionGzip = gzip.Name
+	// CompressionZstd compresses the messages with zstd
+	CompressionZstd = "zstd"
+)
+
+// zstdCodec is the registered zstd compressor, shared by all the clients and servers
+var zstdCodec = &zstdCompressor{limit: int64(MaxRecvMsgSize)}
+
+func init() {
+	// servers accept, and answer with, any of the registered compressors
+	encoding.RegisterCompressor(zstdCodec)
+}
+
+// ValidateCompression returns an error if the passed compression is not supported.
+// The empty string is equivalent to CompressionNone.
+func ValidateCompression(compression string) error {
+	switch compression {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return nil
+	default:
+		return errors.Errorf("unsupported compression [%s], expected one of [%s, %s, %s]", compression, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+}
+
+func compressionEnabled(compression string) bool {
+	return len(compression) != 0 && compression != CompressionNone
+}
+
+type zstdCompressor struct {
+	encoders sync.Pool
+	// limit is the max size of a decompressed message, the largest max receive message size configured
+	limit int64
+}
+
+// raiseLimit makes the decompressed messages up to the passed size acceptable
+func (c *zstdCompressor) raiseLimit(size int) {
+	for {
+		limit := atomic.LoadInt64(&c.limit)
+		if int64(size) <= limit || atomic.CompareAndSwapInt64(&c.limit, limit, int64(size)) {
+			return
+		}
+	}
+}
+
+func (c *zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if e, ok := c.encoders.Get().(*zstd.Encoder); ok {
+		e.Reset(w)
+		return &zstdWriter{Encoder: e, pool: &c.encoders}, nil
+	}
+	e, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{Encoder: e, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	limit := atomic.LoadInt64(&c.limit)
+	// a message is never decompressed beyond the max receive message size
+	raw, err := ioutil.ReadAll(io.LimitReader(d, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, errors.Errorf("decompressed message larger than the max receive message size [%d]", limit)
+	}
+	return bytes.NewReader(raw), nil
+}
+
+// zstdWriter returns the encoder to the pool once closed
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// compressionNegotiator compresses the calls of a connection with the configured compressor
+// until the server reports that it cannot decompress them. From then on, the calls are sent uncompressed.
+type compressionNegotiator struct {
+	compressor  string
+	unsupported int32
+}
+
+func newCompressionNegotiator(compressor string) *compressionNegotiator {
+	return &compressionNegotiator{compressor: compressor}
+}
+
+func (n *compressionNegotiator) enabled() bool {
+	return atomic.LoadInt32(&n.unsupported) == 0
+}
+
+// check disables the compression if the passed error tells that the server does not support it
+func (n *compressionNegotiator) check(err error) bool {
+	if err == nil {
+		return false
+	}
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Unimplemented || !strings.Contains(st.Message(), "grpc-encoding") {
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&n.unsupported, 0, 1) {
+		commLogger.Warnf("server does not support [%s] compression, falling back to uncompressed calls: [%s]", n.compressor, st.Message())
+	}
+	return true
+}
+
+func (n *compressionNegotiator) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !n.enabled() {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.UseCompressor(n.compressor))...)
+	if n.check(err) {
+		// retry uncompressed
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	return err
+}
+
+func (n *compressionNegotiator) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if !n.enabled() {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	cs, err := streamer(ctx, desc, cc, method, append(opts, grpc.UseCompressor(n.compressor))...)
+	if err != nil {
+		if n.check(err) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		return nil, err
+	}
+	return &negotiatingClientStream{ClientStream: cs, negotiator: n}, nil
+}
+
+// negotiatingClientStream reports to the negotiator the errors of the streams, the server
+// rejects a compressed stream only when the first message is received
+type negotiatingClientStream struct {
+	grpc.ClientStream
+	negotiator *compressionNegotiator
+}
+
+func (s *negotiatingClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	s.negotiator.check(err)
+	return err
+}
+
+var (
+	uncompressedBytesOpts = metrics.CounterOpts{
+		Namespace:    "grpc",
+		Subsystem:    "comm",
+		Name:         "uncompressed_bytes",
+		Help:         "The size, in bytes, of the messages of the compressed client connections before compression (sent) or after decompression (received).",
+		LabelNames:   []string{"compression", "direction"},
+		StatsdFormat: "%{#fqname}.%{compression}.%{direction}",
+	}
+	compressedBytesOpts = metrics.CounterOpts{
+		Namespace:    "grpc",
+		Subsystem:    "comm",
+		Name:         "compressed_bytes",
+		Help:         "The size, in bytes, of the messages of the compressed client connections on the wire.",
+		LabelNames:   []string{"compression", "direction"},
+		StatsdFormat: "%{#fqname}.%{compression}.%{direction}",
+	}
+
+	compressionMetricsLock sync.Mutex
+	compressionMetrics     = map[metrics.Provider]*CompressionMetrics{}
+)
+
+// CompressionMetrics reports the bytes before and after compression of the client connections
+type CompressionMetrics struct {
+	UncompressedBytes metrics.Counter
+	CompressedBytes   metrics.Counter
+}
+
+// GetCompressionMetrics returns the compression metrics backed by the passed provider.
+// The metrics are created once per provider, and shared by all the clients.
+func GetCompressionMetrics(p metrics.Provider) *CompressionMetrics {
+	compressionMetricsLock.Lock()
+	defer compressionMetricsLock.Unlock()
+	m, ok := compressionMetrics[p]
+	if !ok {
+		m = &CompressionMetrics{
+			UncompressedBytes: p.NewCounter(uncompressedBytesOpts),
+			CompressedBytes:   p.NewCounter(compressedBytesOpts),
+		}
+		compressionMetrics[p] = m
+	}
+	return m
+}
+
+// compressionStatsHandler feeds CompressionMetrics from the payload stats of the calls
+type compressionStatsHandler struct {
+	compression string
+	metrics     *CompressionMetrics
+}
+
+func (h *compressionStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	switch p := s.(type) {
+	case *stats.OutPayload:
+		h.add("sent", p.Length, p.WireLength)
+	case *stats.InPayload:
+		h.add("received", p.Length, p.WireLength)
+	}
+}
+
+func (h *compressionStatsHandler) add(direction string, length, wireLength int) {
+	h.metrics.UncompressedBytes.With("compression", h.compression, "direction", direction).Add(float64(length))
+	h.metrics.CompressedBytes.With("compression", h.compression, "direction", direction).Add(float64(wireLength))
+}
+
+func (h *compressionStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
@@ -0,0 +1,249 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc/testpb"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+type compressionTestServer struct{}
+
+func (s *compressionTestServer) EmptyCall(context.Context, *testpb.Empty) (*testpb.Empty, error) {
+	return &testpb.Empty{}, nil
+}
+
+func (s *compressionTestServer) EmptyStream(stream testpb.EmptyService_EmptyStreamServer) error {
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	return stream.Send(&testpb.Empty{})
+}
+
+func TestCompressedConnection(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv, err := NewGRPCServerFromListener(lis, ServerConfig{})
+	assert.NoError(t, err)
+	testpb.RegisterEmptyServiceServer(srv.Server(), &compressionTestServer{})
+	go srv.Start()
+	defer srv.Stop()
+
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		uncompressed := &metricsfakes.Counter{}
+		uncompressed.WithReturns(uncompressed)
+		compressed := &metricsfakes.Counter{}
+		compressed.WithReturns(compressed)
+
+		client, err := NewGRPCClient(ClientConfig{
+			Timeout:     5 * time.Second,
+			Compression: compression,
+			CompressionMetrics: &CompressionMetrics{
+				UncompressedBytes: uncompressed,
+				CompressedBytes:   compressed,
+			},
+		})
+		assert.NoError(t, err)
+		conn, err := client.NewConnection(lis.Addr().String())
+		assert.NoError(t, err)
+
+		_, err = testpb.NewEmptyServiceClient(conn).EmptyCall(context.Background(), &testpb.Empty{})
+		assert.NoError(t, err)
+		stream, err := testpb.NewEmptyServiceClient(conn).EmptyStream(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(&testpb.Empty{}))
+		_, err = stream.Recv()
+		assert.NoError(t, err)
+
+		assert.NotZero(t, uncompressed.AddCallCount())
+		assert.NotZero(t, compressed.AddCallCount())
+		labels := uncompressed.WithArgsForCall(0)
+		assert.Equal(t, []string{"compression", compression, "direction", "sent"}, labels)
+		client.Close()
+	}
+
+	_, err = NewGRPCClient(ClientConfig{Compression: "lz4"})
+	assert.Error(t, err)
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	unsupported := status.Errorf(codes.Unimplemented, "grpc: Decompressor is not installed for grpc-encoding %q", CompressionZstd)
+	var calls []bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		compressed := false
+		for _, opt := range opts {
+			if c, ok := opt.(grpc.CompressorCallOption); ok && c.CompressorType == CompressionZstd {
+				compressed = true
+			}
+		}
+		calls = append(calls, compressed)
+		if compressed {
+			return unsupported
+		}
+		return nil
+	}
+
+	n := newCompressionNegotiator(CompressionZstd)
+	// the first call is retried uncompressed, the following ones are not compressed anymore
+	assert.NoError(t, n.unaryInterceptor(context.Background(), "/m", nil, nil, nil, invoker))
+	assert.Equal(t, []bool{true, false}, calls)
+	assert.NoError(t, n.unaryInterceptor(context.Background(), "/m", nil, nil, nil, invoker))
+	assert.Equal(t, []bool{true, false, false}, calls)
+
+	// other errors do not disable the compression
+	n = newCompressionNegotiator(CompressionZstd)
+	assert.False(t, n.check(status.Error(codes.Unimplemented, "unknown method")))
+	assert.False(t, n.check(status.Error(codes.Unavailable, "grpc-encoding")))
+	assert.True(t, n.enabled())
+	assert.True(t, n.check(unsupported))
+	assert.False(t, n.enabled())
+}
+
+// blockStream returns a sequence of serialized blocks resembling those delivered by a peer:
+// endorser transactions carrying read-write sets, certificates, and signatures
+func blockStream(b *testing.B, numBlocks, txsPerBlock int) [][]byte {
+	cert := bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----MIICKjCCAdCgAwIBAgIQ"), 16)
+	random := func(n int) []byte {
+		r := make([]byte, n)
+		_, err := rand.Read(r)
+		assert.NoError(b, err)
+		return r
+	}
+
+	var blocks [][]byte
+	for i := 0; i < numBlocks; i++ {
+		block := &common.Block{
+			Header: &common.BlockHeader{Number: uint64(i), PreviousHash: random(32), DataHash: random(32)},
+			Data:   &common.BlockData{},
+		}
+		for j := 0; j < txsPerBlock; j++ {
+			kvrws := &kvrwset.KVRWSet{}
+			for k := 0; k < 5; k++ {
+				key := fmt.Sprintf("\x00asset\x00%d\x00%d\x00", i, k)
+				kvrws.Reads = append(kvrws.Reads, &kvrwset.KVRead{Key: key, Version: &kvrwset.Version{BlockNum: uint64(i), TxNum: uint64(j)}})
+				kvrws.Writes = append(kvrws.Writes, &kvrwset.KVWrite{Key: key, Value: []byte(fmt.Sprintf(`{"owner":"alice","value":%d,"type":"asset"}`, k))})
+			}
+			rawKVRWS, err := proto.Marshal(kvrws)
+			assert.NoError(b, err)
+			rawRWS, err := proto.Marshal(&rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{{Namespace: "mycc", Rwset: rawKVRWS}}})
+			assert.NoError(b, err)
+			action, err := proto.Marshal(&pb.ChaincodeAction{Results: rawRWS, Response: &pb.Response{Status: 200}})
+			assert.NoError(b, err)
+			prp, err := proto.Marshal(&pb.ProposalResponsePayload{ProposalHash: random(32), Extension: action})
+			assert.NoError(b, err)
+			cea, err := proto.Marshal(&pb.ChaincodeEndorsedAction{
+				ProposalResponsePayload: prp,
+				Endorsements: []*pb.Endorsement{
+					{Endorser: cert, Signature: random(71)},
+					{Endorser: cert, Signature: random(71)},
+				},
+			})
+			assert.NoError(b, err)
+			tx, err := proto.Marshal(&pb.Transaction{Actions: []*pb.TransactionAction{{Header: cert, Payload: cea}}})
+			assert.NoError(b, err)
+			payload, err := proto.Marshal(&common.Payload{
+				Header: &common.Header{ChannelHeader: random(64), SignatureHeader: append(cert, random(24)...)},
+				Data:   tx,
+			})
+			assert.NoError(b, err)
+			env, err := proto.Marshal(&common.Envelope{Payload: payload, Signature: random(71)})
+			assert.NoError(b, err)
+			block.Data.Data = append(block.Data.Data, env)
+		}
+		raw, err := proto.Marshal(block)
+		assert.NoError(b, err)
+		blocks = append(blocks, raw)
+	}
+	return blocks
+}
+
+// BenchmarkBlockStreamCompression measures the CPU cost of compressing and decompressing a block stream,
+// and reports the bandwidth saved as the ratio between the compressed and the uncompressed sizes
+func BenchmarkBlockStreamCompression(b *testing.B) {
+	blocks := blockStream(b, 10, 50)
+	var total int
+	for _, block := range blocks {
+		total += len(block)
+	}
+
+	for _, name := range []string{CompressionGzip, CompressionZstd} {
+		compressor := encoding.GetCompressor(name)
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(total))
+			b.ReportAllocs()
+			var compressed int
+			for i := 0; i < b.N; i++ {
+				compressed = 0
+				for _, block := range blocks {
+					var buf bytes.Buffer
+					w, err := compressor.Compress(&buf)
+					assert.NoError(b, err)
+					_, err = w.Write(block)
+					assert.NoError(b, err)
+					assert.NoError(b, w.Close())
+					compressed += buf.Len()
+
+					r, err := compressor.Decompress(&buf)
+					assert.NoError(b, err)
+					var out bytes.Buffer
+					_, err = out.ReadFrom(r)
+					assert.NoError(b, err)
+				}
+			}
+			b.ReportMetric(float64(compressed)/float64(total), "ratio")
+		})
+	}
+}
+
+func TestZstdDecompressionLimit(t *testing.T) {
+	c := &zstdCompressor{limit: 1024}
+	compress := func(size int) []byte {
+		b := &bytes.Buffer{}
+		w, err := c.Compress(b)
+		assert.NoError(t, err)
+		_, err = w.Write(make([]byte, size))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		return b.Bytes()
+	}
+
+	r, err := c.Decompress(bytes.NewReader(compress(1024)))
+	assert.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, raw, 1024)
+
+	// zeros compress well, the message must not be inflated beyond the limit
+	bomb := compress(1024*1024 + 1)
+	assert.Less(t, len(bomb), 1024)
+	_, err = c.Decompress(bytes.NewReader(bomb))
+	assert.EqualError(t, err, "decompressed message larger than the max receive message size [1024]")
+
+	c.raiseLimit(512)
+	c.raiseLimit(2 * 1024 * 1024)
+	_, err = c.Decompress(bytes.NewReader(bomb))
+	assert.NoError(t, err)
+}
@@ -45,12 +45,20 @@ var (
 
 // ConnectionConfig contains data required to establish grpc connection to a peer or orderer
 type ConnectionConfig struct {
-	Address            string        `yaml:"address,omitempty"`
-	ConnectionTimeout  time.Duration `yaml:"connectionTimeout,omitempty"`
-	TLSEnabled         bool          `yaml:"tlsEnabled,omitempty"`
-	TLSRootCertFile    string        `yaml:"tlsRootCertFile,omitempty"`
-	TLSRootCertBytes   [][]byte      `yaml:"tlsRootCertBytes,omitempty"`
-	ServerNameOverride string        `yaml:"serverNameOverride,omitempty"`
+	Address           string        `yaml:"address,omitempty"`
+	ConnectionTimeout time.Duration `yaml:"connectionTimeout,omitempty"`
+	TLSEnabled        bool          `yaml:"tlsEnabled,omitempty"`
+	TLSRootCertFile   string        `yaml:"tlsRootCertFile,omitempty"`
+	TLSRootCertBytes  [][]byte      `yaml:"tlsRootCertBytes,omitempty"`
+	// ServerNameOverride, if not empty, is the name sent by SNI, and verified against the certificate of the server,
+	// in place of the host of Address
+	ServerNameOverride string `yaml:"serverNameOverride,omitempty"`
+	// Compression is the compression of the messages sent on the connection, one of none (default), gzip, or zstd.
+	// The compression is disabled for the connection if the server does not support it.
+	Compression string `yaml:"compression,omitempty"`
+	// Resolution configures the periodic resolution of the name in the address, and the balancing of the calls
+	// across the resolved addresses. If nil, the name is resolved by gRPC when the connection is dialed.
+	Resolution *ResolutionConfig `yaml:"resolution,omitempty"`
 }
 
 // ServerConfig defines the parameters for configuring a GRPCServer instance
@@ -63,15 +71,23 @@ type ServerConfig struct {
 	// KaOpts defines the keepalive parameters
 	KaOpts KeepaliveOptions
 	// StreamInterceptors specifies a list of interceptors to apply to
-	// streaming RPCs.  They are executed in order.
+	// streaming RPCs.  They are executed in order, before the ones registered with RegisterInterceptors.
 	StreamInterceptors []grpc.StreamServerInterceptor
 	// UnaryInterceptors specifies a list of interceptors to apply to unary
-	// RPCs.  They are executed in order.
+	// RPCs.  They are executed in order, before the ones registered with RegisterInterceptors.
 	UnaryInterceptors []grpc.UnaryServerInterceptor
 	// Logger specifies the logger the server will use
 	Logger *flogging.FabricLogger
 	// HealthCheckEnabled enables the gRPC Health Checking Protocol for the server
 	HealthCheckEnabled bool
+	// HealthCheckInterval is the interval between two runs of the health checkers
+	HealthCheckInterval time.Duration
+	// HealthDrainDelay is how long the server keeps serving, once all its services are set to not serving,
+	// before stopping. This lets the load balancers and the clients watching the health service move away
+	// before the connections are closed. If 0, the server stops right away
+	HealthDrainDelay time.Duration
+	// ReflectionEnabled enables the gRPC Server Reflection Protocol for the server
+	ReflectionEnabled bool
 	// ServerStatsHandler should be set if metrics on connections are to be reported.
 	ServerStatsHandler *ServerStatsHandler
 }
@@ -87,6 +103,20 @@ type ClientConfig struct {
 	Timeout time.Duration
 	// AsyncConnect makes connection creation non blocking
 	AsyncConnect bool
+	// Compression is the compression of the messages sent by the client, one of none (default), gzip, or zstd
+	Compression string
+	// CompressionMetrics, if not nil, reports the bytes before and after compression
+	CompressionMetrics *CompressionMetrics
+	// Resolution, if not nil, configures the periodic resolution of the names in the addresses of the connections
+	Resolution *ResolutionConfig
+	// ServerNameOverride, if not empty, is the name sent by SNI, and verified against the certificates of the
+	// servers, in place of the host of the dialed addresses, as when the servers are behind an ingress routing by name.
+	// The ServerNameOverride option of a connection takes precedence.
+	ServerNameOverride string
+	// UnaryInterceptors and StreamInterceptors are applied to the connections of the client, after the ones
+	// registered with RegisterInterceptors. They are executed in order.
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
 }
 
 // Clone clones this ClientConfig
@@ -12,10 +12,12 @@ import (
 	"crypto/x509"
 	"errors"
 	"net"
+	"strings"
 	"sync"
 
 	"google.golang.org/grpc/credentials"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/clock"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 )
 
@@ -35,7 +37,9 @@ func NewServerTransportCredentials(
 	logger *flogging.FabricLogger) credentials.TransportCredentials {
 	// NOTE: unlike the default grpc/credentials implementation, we do not
 	// clone the tls.Config which allows us to update it dynamically
-	serverConfig.config.NextProtos = alpnProtoStr
+	serverConfig.config.NextProtos = appendH2(serverConfig.config.NextProtos)
+	// the certificate is selected by the name the client asks for by SNI
+	serverConfig.enableServerNames()
 	// override TLS version and ensure it is 1.2
 	serverConfig.config.MinVersion = tls.VersionTLS12
 	serverConfig.config.MaxVersion = tls.VersionTLS12
@@ -53,6 +57,12 @@ type serverCreds struct {
 type TLSConfig struct {
 	config *tls.Config
 	lock   sync.RWMutex
+	// certificates are the certificates served to the clients asking for their name by SNI, indexed by name
+	certificates map[string]*tls.Certificate
+	// fallback, if not nil, returns the certificate served to the clients asking for any other name
+	fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	// serverNames is true once the certificates are selected by the names asked for
+	serverNames bool
 }
 
 func NewTLSConfig(config *tls.Config) *TLSConfig {
@@ -86,6 +96,79 @@ func (t *TLSConfig) SetClientCAs(certPool *x509.CertPool) {
 	t.config.ClientCAs = certPool
 }
 
+// SetServerNameCertificate serves the passed certificate to the clients asking for the passed name by SNI,
+// in place of the default certificate. The name is either a host name, or a wildcard as in `*.example.com`.
+func (t *TLSConfig) SetServerNameCertificate(serverName string, cert tls.Certificate) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.certificates == nil {
+		t.certificates = map[string]*tls.Certificate{}
+	}
+	t.certificates[normalizeServerName(serverName)] = &cert
+}
+
+// RemoveServerNameCertificate serves the default certificate again to the clients asking for the passed name
+func (t *TLSConfig) RemoveServerNameCertificate(serverName string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.certificates, normalizeServerName(serverName))
+}
+
+// enableServerNames makes GetCertificate of the configuration select the certificates by the names asked for.
+// The GetCertificate set before, if any, returns the default certificate.
+func (t *TLSConfig) enableServerNames() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.serverNames {
+		return
+	}
+	t.serverNames = true
+	t.fallback = t.config.GetCertificate
+	t.config.GetCertificate = t.getCertificate
+}
+
+// getCertificate returns the certificate registered for the name the client asks for, the exact name first,
+// then the wildcard of its parent domain. Otherwise, it returns the default certificate: the one of the fallback,
+// if any, or the first of the configuration.
+func (t *TLSConfig) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	t.lock.RLock()
+	name := normalizeServerName(hello.ServerName)
+	cert, ok := t.certificates[name]
+	if !ok && len(name) != 0 {
+		if labels := strings.SplitN(name, ".", 2); len(labels) == 2 {
+			cert, ok = t.certificates["*."+labels[1]]
+		}
+	}
+	fallback := t.fallback
+	t.lock.RUnlock()
+
+	if ok {
+		return cert, nil
+	}
+	if fallback != nil {
+		return fallback(hello)
+	}
+	// crypto/tls serves the certificates of the configuration
+	return nil, nil
+}
+
+func normalizeServerName(serverName string) string {
+	return strings.ToLower(strings.TrimSuffix(serverName, "."))
+}
+
+// appendH2 returns the passed ALPN protocols with h2, the one of gRPC, appended if missing
+func appendH2(protos []string) []string {
+	for _, proto := range protos {
+		if proto == alpnProtoStr[0] {
+			return protos
+		}
+	}
+	return append(append([]string(nil), protos...), alpnProtoStr...)
+}
+
 // ClientHandShake is not implemented for `serverCreds`.
 func (sc *serverCreds) ClientHandshake(context.Context,
 	string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
@@ -98,6 +181,7 @@ func (sc *serverCreds) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.
 
 	conn := tls.Server(rawConn, &serverConfig)
 	if err := conn.Handshake(); err != nil {
+		err = clock.Annotate(err)
 		if sc.logger != nil {
 			sc.logger.With("remote address",
 				conn.RemoteAddr().String()).Errorf("TLS handshake failed with error %s", err)
@@ -142,7 +226,12 @@ func (dtc *DynamicClientCredentials) latestConfig() *tls.Config {
 }
 
 func (dtc *DynamicClientCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
-	return credentials.NewTLS(dtc.latestConfig()).ClientHandshake(ctx, authority, rawConn)
+	conn, info, err := credentials.NewTLS(dtc.latestConfig()).ClientHandshake(ctx, authority, rawConn)
+	if err != nil {
+		// a certificate not valid yet, or already expired, may be due to the local clock
+		return nil, nil, clock.Annotate(err)
+	}
+	return conn, info, nil
 }
 
 func (dtc *DynamicClientCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
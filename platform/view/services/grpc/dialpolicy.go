@@ -0,0 +1,343 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+var blockedDialsOpts = metrics.CounterOpts{
+	Namespace:    "grpc",
+	Subsystem:    "comm",
+	Name:         "dials_blocked",
+	Help:         "The connections to endpoints refused by the dial policy, by endpoint and by source of the endpoint.",
+	LabelNames:   []string{"endpoint", "source"},
+	StatsdFormat: "%{#fqname}.%{endpoint}.%{source}",
+}
+
+// DialPolicyConfig configures the endpoints the clients may connect to. Each entry is either a CIDR range, an IP,
+// or a host pattern, matched case-insensitively, where * matches any sequence of characters, dots included:
+// *.example.com matches peer0.org1.example.com.
+type DialPolicyConfig struct {
+	// Allow lists the endpoints allowed. If empty, all the endpoints not denied are allowed.
+	Allow []string `yaml:"allow,omitempty"`
+	// Deny lists the endpoints refused, it takes precedence over Allow
+	Deny []string `yaml:"deny,omitempty"`
+}
+
+// EndpointNotAllowedError is returned by the connections to the endpoints the dial policy refuses
+type EndpointNotAllowedError struct {
+	Address string
+	Source  string
+	Reason  string
+}
+
+func (e *EndpointNotAllowedError) Error() string {
+	if len(e.Source) == 0 {
+		return fmt.Sprintf("endpoint [%s] not allowed by the dial policy: %s", e.Address, e.Reason)
+	}
+	return fmt.Sprintf("endpoint [%s] from [%s] not allowed by the dial policy: %s", e.Address, e.Source, e.Reason)
+}
+
+// Temporary returns false, gRPC gives up the connection at once
+func (e *EndpointNotAllowedError) Temporary() bool {
+	return false
+}
+
+// BlockedEndpoint reports the connections refused to an endpoint
+type BlockedEndpoint struct {
+	Address string    `json:"address"`
+	Source  string    `json:"source,omitempty"`
+	Reason  string    `json:"reason"`
+	Count   uint64    `json:"count"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+}
+
+type dialRules struct {
+	hosts []string
+	cidrs []*net.IPNet
+}
+
+func parseDialRules(entries []string) (*dialRules, error) {
+	r := &dialRules{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			r.cidrs = append(r.cidrs, cidr)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.cidrs = append(r.cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		pattern := strings.ToLower(strings.ReplaceAll(entry, "/", ""))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid host pattern [%s]", entry)
+		}
+		r.hosts = append(r.hosts, pattern)
+	}
+	return r, nil
+}
+
+func (r *dialRules) empty() bool {
+	return len(r.hosts) == 0 && len(r.cidrs) == 0
+}
+
+func (r *dialRules) matchHost(host string) (string, bool) {
+	host = strings.ToLower(host)
+	for _, pattern := range r.hosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+func (r *dialRules) matchIP(ip net.IP) (string, bool) {
+	for _, cidr := range r.cidrs {
+		if cidr.Contains(ip) {
+			return cidr.String(), true
+		}
+	}
+	return "", false
+}
+
+// DialPolicy refuses the connections to the endpoints outside the allowed ones, before any packet is sent
+// to them. The host of an address is checked against the host patterns and, if it is an IP, against the CIDR
+// ranges. The names are checked against the CIDR ranges once resolved, just before dialing: the names allowed
+// by a host pattern must not resolve to a denied range, the others must resolve to an allowed one.
+// The sources of the endpoints, such as the configuration or a channel config update, are recorded by the
+// components that learn them, they are reported with the endpoints blocked.
+type DialPolicy struct {
+	allow   *dialRules
+	deny    *dialRules
+	blocked metrics.Counter
+
+	lock     sync.RWMutex
+	sources  map[string]string
+	refusals map[string]*BlockedEndpoint
+}
+
+// NewDialPolicy returns the dial policy of the passed configuration, the blocked connections are counted
+// with the passed provider, if not nil
+func NewDialPolicy(config DialPolicyConfig, p metrics.Provider) (*DialPolicy, error) {
+	allow, err := parseDialRules(config.Allow)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid allow list")
+	}
+	deny, err := parseDialRules(config.Deny)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid deny list")
+	}
+	policy := &DialPolicy{
+		allow:    allow,
+		deny:     deny,
+		sources:  map[string]string{},
+		refusals: map[string]*BlockedEndpoint{},
+	}
+	if p != nil {
+		policy.blocked = p.NewCounter(blockedDialsOpts)
+	}
+	return policy, nil
+}
+
+// RecordSource records where the passed address has been learned from, the last source recorded is reported
+func (p *DialPolicy) RecordSource(address, source string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.sources[address] = source
+}
+
+// Check returns an EndpointNotAllowedError if the passed address is refused. The names are checked against
+// the CIDR ranges when dialed, once resolved.
+func (p *DialPolicy) Check(address string) error {
+	_, err := p.check(address)
+	return err
+}
+
+// check returns the CIDR check of the addresses the passed address resolves to, nil if none is needed
+func (p *DialPolicy) check(address string) (func(ip net.IP) string, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if reason := p.checkIP(ip); len(reason) != 0 {
+			return nil, p.refuse(address, reason)
+		}
+		return nil, nil
+	}
+
+	if pattern, ok := p.deny.matchHost(host); ok {
+		return nil, p.refuse(address, "host matches denied ["+pattern+"]")
+	}
+	if _, ok := p.allow.matchHost(host); ok || p.allow.empty() {
+		// allowed by name, the addresses resolved must not be denied
+		if len(p.deny.cidrs) == 0 {
+			return nil, nil
+		}
+		return p.checkDenied, nil
+	}
+	if len(p.allow.cidrs) == 0 {
+		return nil, p.refuse(address, "host matches no allowed endpoint")
+	}
+	// the addresses resolved must be allowed
+	return p.checkIP, nil
+}
+
+// checkIP returns why the passed IP is refused, empty if allowed
+func (p *DialPolicy) checkIP(ip net.IP) string {
+	if reason := p.checkDenied(ip); len(reason) != 0 {
+		return reason
+	}
+	if p.allow.empty() {
+		return ""
+	}
+	if _, ok := p.allow.matchIP(ip); ok {
+		return ""
+	}
+	if _, ok := p.allow.matchHost(ip.String()); ok {
+		return ""
+	}
+	return "address [" + ip.String() + "] matches no allowed endpoint"
+}
+
+// checkDenied returns why the passed IP is refused, empty if not denied
+func (p *DialPolicy) checkDenied(ip net.IP) string {
+	if cidr, ok := p.deny.matchIP(ip); ok {
+		return "address [" + ip.String() + "] in denied [" + cidr + "]"
+	}
+	if pattern, ok := p.deny.matchHost(ip.String()); ok {
+		return "address [" + ip.String() + "] matches denied [" + pattern + "]"
+	}
+	return ""
+}
+
+// dialer returns the dialer of the connections to the passed address, each address resolved is checked with
+// the passed check, the connection is established with the first passing it
+func (p *DialPolicy) dialer(address string, check func(ip net.IP) string) func(ctx context.Context, addr string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid address [%s]", addr)
+		}
+		var ips []net.IP
+		if ip := net.ParseIP(host); ip != nil {
+			ips = []net.IP{ip}
+		} else {
+			addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed resolving [%s]", host)
+			}
+			for _, a := range addrs {
+				ips = append(ips, a.IP)
+			}
+		}
+		reason := "no address resolved for [" + host + "]"
+		for _, ip := range ips {
+			if reason = check(ip); len(reason) != 0 {
+				continue
+			}
+			return (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+		}
+		return nil, p.refuse(address, reason)
+	}
+}
+
+// DialOption returns the option of the connection to the passed address enforcing the policy on the addresses
+// it resolves to, nil if not needed. It returns an EndpointNotAllowedError if the address is refused.
+func (p *DialPolicy) DialOption(address string) (grpc.DialOption, error) {
+	check, err := p.check(address)
+	if err != nil || check == nil {
+		return nil, err
+	}
+	return grpc.WithContextDialer(p.dialer(address, check)), nil
+}
+
+// refuse records and logs the refusal of the passed address, and returns the error of the connection
+func (p *DialPolicy) refuse(address, reason string) error {
+	now := time.Now()
+	p.lock.Lock()
+	source := p.sources[address]
+	b, ok := p.refusals[address]
+	if !ok {
+		b = &BlockedEndpoint{Address: address, First: now}
+		p.refusals[address] = b
+	}
+	b.Source, b.Reason, b.Last = source, reason, now
+	b.Count++
+	p.lock.Unlock()
+
+	if p.blocked != nil {
+		label := source
+		if len(label) == 0 {
+			label = "unknown"
+		}
+		p.blocked.With("endpoint", address, "source", label).Add(1)
+	}
+	err := &EndpointNotAllowedError{Address: address, Source: source, Reason: reason}
+	commLogger.Warnf("connection refused: %s", err)
+	return err
+}
+
+// Blocked returns the endpoints whose connections have been refused, sorted by address
+func (p *DialPolicy) Blocked() []BlockedEndpoint {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	res := make([]BlockedEndpoint, 0, len(p.refusals))
+	for _, b := range p.refusals {
+		res = append(res, *b)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Address < res[j].Address })
+	return res
+}
+
+// dialPolicy holds the dial policy of the process, applied to all the connections of the clients
+var dialPolicy atomic.Value
+
+type dialPolicyHolder struct {
+	policy *DialPolicy
+}
+
+// SetDialPolicy sets the dial policy applied to the new connections of all the clients, nil to remove it
+func SetDialPolicy(p *DialPolicy) {
+	dialPolicy.Store(dialPolicyHolder{policy: p})
+}
+
+// GetDialPolicy returns the dial policy applied to the new connections, nil if none is set
+func GetDialPolicy() *DialPolicy {
+	h, _ := dialPolicy.Load().(dialPolicyHolder)
+	return h.policy
+}
+
+// RecordEndpointSource records, with the dial policy set, where the passed address has been learned from,
+// such as the configuration or a channel config update. It does nothing if no policy is set.
+func RecordEndpointSource(address, source string) {
+	if p := GetDialPolicy(); p != nil {
+		p.RecordSource(address, source)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc/testpb"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialPolicyCheck(t *testing.T) {
+	policy, err := NewDialPolicy(DialPolicyConfig{
+		Allow: []string{"*.example.com", "10.0.0.0/8", "192.168.1.1"},
+		Deny:  []string{"evil.example.com", "10.1.0.0/16"},
+	}, nil)
+	assert.NoError(t, err)
+
+	for address, allowed := range map[string]bool{
+		"peer0.org1.example.com:7051": true,
+		"PEER0.ORG1.EXAMPLE.COM:7051": true,
+		"evil.example.com:7051":       false,
+		"peer0.org1.other.com:7051":   true, // checked against the CIDR ranges once resolved
+		"10.2.3.4:7050":               true,
+		"10.1.3.4:7050":               false,
+		"192.168.1.1:7050":            true,
+		"192.168.1.2:7050":            false,
+	} {
+		err := policy.Check(address)
+		assert.Equal(t, allowed, err == nil, "address [%s]: %v", address, err)
+	}
+
+	// without allowed CIDR ranges, the names not matching are refused at once
+	policy, err = NewDialPolicy(DialPolicyConfig{Allow: []string{"*.example.com"}}, nil)
+	assert.NoError(t, err)
+	policy.RecordSource("peer0.org1.other.com:7051", "discovery of channel [mychannel]")
+	err = policy.Check("peer0.org1.other.com:7051")
+	nae := &EndpointNotAllowedError{}
+	assert.True(t, errors.As(err, &nae))
+	assert.Equal(t, "discovery of channel [mychannel]", nae.Source)
+	assert.NoError(t, policy.Check("orderer.example.com:7050"))
+
+	_, err = NewDialPolicy(DialPolicyConfig{Deny: []string{"[a-"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestDialPolicyConnection(t *testing.T) {
+	port, servers, listeners := startServers(t, "127.0.0.1")
+	counter := &metricsfakes.Counter{}
+	counter.WithReturns(counter)
+	provider := &metricsfakes.Provider{}
+	provider.NewCounterReturns(counter)
+
+	policy, err := NewDialPolicy(DialPolicyConfig{Deny: []string{"127.0.0.0/8"}}, provider)
+	assert.NoError(t, err)
+	SetDialPolicy(policy)
+	defer SetDialPolicy(nil)
+	policy.RecordSource(net.JoinHostPort("localhost", port), "configuration of network [default]")
+
+	client, err := NewGRPCClient(ClientConfig{Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	// denied as IP and as name resolved, nothing reaches the server
+	_, err = client.NewConnection(net.JoinHostPort("127.0.0.1", port))
+	nae := &EndpointNotAllowedError{}
+	assert.True(t, errors.As(err, &nae), "%v", err)
+	_, err = client.NewConnection(net.JoinHostPort("localhost", port))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not allowed by the dial policy")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&listeners[0].accepted))
+
+	blocked := policy.Blocked()
+	assert.Len(t, blocked, 2)
+	assert.Equal(t, net.JoinHostPort("localhost", port), blocked[1].Address)
+	assert.Equal(t, "configuration of network [default]", blocked[1].Source)
+	assert.Equal(t, 2, counter.AddCallCount())
+	assert.Equal(t, []string{"endpoint", net.JoinHostPort("localhost", port), "source", "configuration of network [default]"}, counter.WithArgsForCall(1))
+
+	// allowed by range once resolved
+	policy, err = NewDialPolicy(DialPolicyConfig{Allow: []string{"127.0.0.1/32"}}, nil)
+	assert.NoError(t, err)
+	SetDialPolicy(policy)
+	conn, err := client.NewConnection(net.JoinHostPort("localhost", port))
+	assert.NoError(t, err)
+	_, err = testpb.NewEmptyServiceClient(conn).EmptyCall(context.Background(), &testpb.Empty{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&servers[0].calls))
+	assert.Empty(t, policy.Blocked())
+}
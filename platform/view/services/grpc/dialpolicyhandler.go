@@ -0,0 +1,37 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+)
+
+// BlockedEndpointsURI is the URI, relative to the web server API, of the endpoints whose connections have been
+// refused by the dial policy
+const BlockedEndpointsURI = "/admin/grpc/blocked"
+
+// BlockedEndpointsHandler serves the endpoints blocked by the dial policy set
+type BlockedEndpointsHandler struct{}
+
+// NewBlockedEndpointsHandler returns a handler of the endpoints blocked by the dial policy set
+func NewBlockedEndpointsHandler() *BlockedEndpointsHandler {
+	return &BlockedEndpointsHandler{}
+}
+
+func (h *BlockedEndpointsHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *BlockedEndpointsHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	policy := GetDialPolicy()
+	if policy == nil {
+		return []BlockedEndpoint{}, http.StatusOK
+	}
+	return policy.Blocked(), http.StatusOK
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/fabric-lib-go/healthz"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// DefaultHealthCheckInterval is the default interval between two runs of the health checkers
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// RegisterHealthChecker binds the passed checker to the passed service name.
+// The serving status of the service on the health server is updated from the result of the checker,
+// the overall status (the empty service name) is serving only if all the checkers succeed.
+func (gServer *GRPCServer) RegisterHealthChecker(service string, checker healthz.HealthChecker) {
+	gServer.lock.Lock()
+	defer gServer.lock.Unlock()
+	gServer.healthCheckers[service] = checker
+}
+
+// updateHealth runs the registered health checkers and updates the serving statuses accordingly
+func (gServer *GRPCServer) updateHealth() {
+	gServer.lock.Lock()
+	checkers := make(map[string]healthz.HealthChecker, len(gServer.healthCheckers))
+	for service, checker := range gServer.healthCheckers {
+		checkers[service] = checker
+	}
+	gServer.lock.Unlock()
+
+	overall := healthpb.HealthCheckResponse_SERVING
+	for service, checker := range checkers {
+		status := healthpb.HealthCheckResponse_SERVING
+		ctx, cancel := context.WithTimeout(context.Background(), gServer.healthCheckInterval)
+		if err := checker.HealthCheck(ctx); err != nil {
+			commLogger.Debugf("health check failed for [%s]: [%s]", service, err)
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		cancel()
+		gServer.healthServer.SetServingStatus(service, status)
+	}
+	gServer.healthServer.SetServingStatus("", overall)
+}
+
+func (gServer *GRPCServer) monitorHealth() {
+	ticker := time.NewTicker(gServer.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			gServer.updateHealth()
+		case <-gServer.stopHealth:
+			return
+		}
+	}
+}
+
+// SetNotServing sets all the services to not serving, before the server stops, for example while the node drains.
+// Further updates of the health checkers are ignored.
+func (gServer *GRPCServer) SetNotServing() {
+	gServer.shutdownHealth()
+}
+
+// shutdownHealth sets all the services to not serving, further updates are ignored
+func (gServer *GRPCServer) shutdownHealth() {
+	gServer.stopHealthOnce.Do(func() {
+		close(gServer.stopHealth)
+		gServer.healthServer.Shutdown()
+	})
+}
@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	grpc3 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc/testpb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+type toggleChecker struct {
+	healthy int32
+}
+
+func (c *toggleChecker) HealthCheck(context.Context) error {
+	if atomic.LoadInt32(&c.healthy) == 1 {
+		return nil
+	}
+	return errors.New("not ready")
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv, err := grpc3.NewGRPCServerFromListener(lis, grpc3.ServerConfig{
+		HealthCheckEnabled:  true,
+		HealthCheckInterval: 10 * time.Millisecond,
+		ReflectionEnabled:   true,
+	})
+	assert.NoError(t, err)
+	testpb.RegisterEmptyServiceServer(srv.Server(), &emptyServiceServer{})
+	checker := &toggleChecker{}
+	srv.RegisterHealthChecker("comm", checker)
+
+	go srv.Start()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.Status
+	}
+
+	// the checker fails, the overall status follows it
+	assert.Eventually(t, func() bool {
+		return status("comm") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status("EmptyService"))
+
+	atomic.StoreInt32(&checker.healthy, 1)
+	assert.Eventually(t, func() bool {
+		return status("comm") == healthpb.HealthCheckResponse_SERVING && status("") == healthpb.HealthCheckResponse_SERVING
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// reflection lists the registered services
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	assert.Contains(t, services, "EmptyService")
+	assert.Contains(t, services, "grpc.health.v1.Health")
+	assert.NoError(t, stream.CloseSend())
+}
+
+func TestReflectionDisabled(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv, err := grpc3.NewGRPCServerFromListener(lis, grpc3.ServerConfig{})
+	assert.NoError(t, err)
+	_, ok := srv.Server().GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]
+	assert.False(t, ok)
+	_, ok = srv.Server().GetServiceInfo()["grpc.health.v1.Health"]
+	assert.False(t, ok)
+}
+
+func TestHealthDrainDelay(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv, err := grpc3.NewGRPCServerFromListener(lis, grpc3.ServerConfig{
+		HealthCheckEnabled: true,
+		HealthDrainDelay:   time.Second,
+	})
+	assert.NoError(t, err)
+	testpb.RegisterEmptyServiceServer(srv.Server(), &emptyServiceServer{})
+	go srv.Start()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "EmptyService"})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// while draining, the server answers that it is not serving
+	stopped := make(chan struct{})
+	start := time.Now()
+	go func() {
+		srv.Stop()
+		close(stopped)
+	}()
+	assert.Eventually(t, func() bool {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "EmptyService"})
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_NOT_SERVING
+	}, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-stopped:
+		t.Fatal("the server stopped before the drain delay")
+	default:
+	}
+	<-stopped
+	assert.True(t, time.Since(start) >= time.Second)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// Interceptors are the interceptors applied to the connections of the clients and to the servers of the process
+type Interceptors struct {
+	UnaryClient  []grpc.UnaryClientInterceptor
+	StreamClient []grpc.StreamClientInterceptor
+	UnaryServer  []grpc.UnaryServerInterceptor
+	StreamServer []grpc.StreamServerInterceptor
+}
+
+// InterceptorOption adds interceptors to register
+type InterceptorOption func(*Interceptors)
+
+// WithUnaryClientInterceptors adds interceptors of the unary calls of the client connections
+func WithUnaryClientInterceptors(interceptors ...grpc.UnaryClientInterceptor) InterceptorOption {
+	return func(i *Interceptors) {
+		i.UnaryClient = append(i.UnaryClient, interceptors...)
+	}
+}
+
+// WithStreamClientInterceptors adds interceptors of the streams of the client connections
+func WithStreamClientInterceptors(interceptors ...grpc.StreamClientInterceptor) InterceptorOption {
+	return func(i *Interceptors) {
+		i.StreamClient = append(i.StreamClient, interceptors...)
+	}
+}
+
+// WithUnaryServerInterceptors adds interceptors of the unary calls served
+func WithUnaryServerInterceptors(interceptors ...grpc.UnaryServerInterceptor) InterceptorOption {
+	return func(i *Interceptors) {
+		i.UnaryServer = append(i.UnaryServer, interceptors...)
+	}
+}
+
+// WithStreamServerInterceptors adds interceptors of the streams served
+func WithStreamServerInterceptors(interceptors ...grpc.StreamServerInterceptor) InterceptorOption {
+	return func(i *Interceptors) {
+		i.StreamServer = append(i.StreamServer, interceptors...)
+	}
+}
+
+var (
+	registeredLock sync.RWMutex
+	registered     Interceptors
+)
+
+// RegisterInterceptors registers interceptors applied to all the connections created from then on by the clients,
+// including the ones to the peers and orderers of the Fabric networks, and to all the servers created from then on.
+// They run in the order of registration, after the ones registered before.
+//
+// On a client connection, the registered interceptors run first, then the ones of the ClientConfig, and last the
+// ones of the platform, such as the negotiation of the compression, closest to the wire.
+// On a server, the interceptors of the ServerConfig, the ones of the platform such as the logging, run first,
+// then the registered ones, closest to the handler.
+func RegisterInterceptors(opts ...InterceptorOption) {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+	for _, opt := range opts {
+		opt(&registered)
+	}
+}
+
+// RegisteredInterceptors returns a copy of the interceptors registered
+func RegisteredInterceptors() Interceptors {
+	registeredLock.RLock()
+	defer registeredLock.RUnlock()
+	return Interceptors{
+		UnaryClient:  append([]grpc.UnaryClientInterceptor(nil), registered.UnaryClient...),
+		StreamClient: append([]grpc.StreamClientInterceptor(nil), registered.StreamClient...),
+		UnaryServer:  append([]grpc.UnaryServerInterceptor(nil), registered.UnaryServer...),
+		StreamServer: append([]grpc.StreamServerInterceptor(nil), registered.StreamServer...),
+	}
+}
+
+// ResetInterceptors removes all the interceptors registered, it is meant for the tests
+func ResetInterceptors() {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+	registered = Interceptors{}
+}
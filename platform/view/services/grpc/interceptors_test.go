@@ -0,0 +1,150 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	grpc3 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc"
+	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
+	"github.com/hyperledger/fabric-protos-go/common"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type viewService struct {
+	protos2.UnimplementedViewServiceServer
+	token chan string
+}
+
+func (s *viewService) ProcessCommand(ctx context.Context, _ *protos2.SignedCommand) (*protos2.SignedCommandResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.token <- md.Get("token")[0]
+	return &protos2.SignedCommandResponse{}, nil
+}
+
+type broadcastService struct {
+	ab.UnimplementedAtomicBroadcastServer
+	token chan string
+}
+
+func (s *broadcastService) Broadcast(srv ab.AtomicBroadcast_BroadcastServer) error {
+	md, _ := metadata.FromIncomingContext(srv.Context())
+	s.token <- md.Get("token")[0]
+	if _, err := srv.Recv(); err != nil {
+		return err
+	}
+	return srv.Send(&ab.BroadcastResponse{Status: common.Status_SUCCESS})
+}
+
+// trace records the interceptors run, in order
+type trace struct {
+	lock  sync.Mutex
+	calls []string
+}
+
+func (t *trace) add(call string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.calls = append(t.calls, call)
+}
+
+func (t *trace) get() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]string(nil), t.calls...)
+}
+
+func (t *trace) unaryServer(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		t.add(name + " " + info.FullMethod)
+		return handler(ctx, req)
+	}
+}
+
+func (t *trace) streamServer(name string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		t.add(name + " " + info.FullMethod)
+		return handler(srv, ss)
+	}
+}
+
+func (t *trace) unaryClient(name string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		t.add(name + " " + method)
+		return invoker(metadata.AppendToOutgoingContext(ctx, "token", name), method, req, reply, cc, opts...)
+	}
+}
+
+func (t *trace) streamClient(name string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		t.add(name + " " + method)
+		return streamer(metadata.AppendToOutgoingContext(ctx, "token", name), desc, cc, method, opts...)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	defer grpc3.ResetInterceptors()
+	tr := &trace{}
+	grpc3.RegisterInterceptors(
+		grpc3.WithUnaryClientInterceptors(tr.unaryClient("auth")),
+		grpc3.WithStreamClientInterceptors(tr.streamClient("auth")),
+		grpc3.WithUnaryServerInterceptors(tr.unaryServer("logging")),
+	)
+	grpc3.RegisterInterceptors(grpc3.WithStreamServerInterceptors(tr.streamServer("logging")))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv, err := grpc3.NewGRPCServerFromListener(lis, grpc3.ServerConfig{
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{tr.unaryServer("platform")},
+		StreamInterceptors: []grpc.StreamServerInterceptor{tr.streamServer("platform")},
+	})
+	assert.NoError(t, err)
+	views := &viewService{token: make(chan string, 1)}
+	orderer := &broadcastService{token: make(chan string, 1)}
+	protos2.RegisterViewServiceServer(srv.Server(), views)
+	ab.RegisterAtomicBroadcastServer(srv.Server(), orderer)
+	go srv.Start()
+	defer srv.Stop()
+
+	client, err := grpc3.NewGRPCClient(grpc3.ClientConfig{
+		Timeout:            time.Second,
+		Compression:        grpc3.CompressionGzip,
+		UnaryInterceptors:  []grpc.UnaryClientInterceptor{tr.unaryClient("client")},
+		StreamInterceptors: []grpc.StreamClientInterceptor{tr.streamClient("client")},
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+	conn, err := client.NewConnection(srv.Address())
+	assert.NoError(t, err)
+
+	// view service
+	_, err = protos2.NewViewServiceClient(conn).ProcessCommand(context.Background(), &protos2.SignedCommand{})
+	assert.NoError(t, err)
+	assert.Equal(t, "auth", <-views.token)
+
+	// orderer broadcast
+	stream, err := ab.NewAtomicBroadcastClient(conn).Broadcast(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&common.Envelope{}))
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, common.Status_SUCCESS, resp.Status)
+	assert.Equal(t, "auth", <-orderer.token)
+
+	process, broadcast := "/protos.ViewService/ProcessCommand", "/orderer.AtomicBroadcast/Broadcast"
+	assert.Equal(t, []string{
+		"auth " + process, "client " + process, "platform " + process, "logging " + process,
+		"auth " + broadcast, "client " + broadcast, "platform " + broadcast, "logging " + broadcast,
+	}, tr.get())
+}
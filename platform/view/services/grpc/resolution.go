@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	// DefaultResolutionInterval is the interval between two resolutions of the name of an address
+	DefaultResolutionInterval = 30 * time.Second
+	// LoadBalancingRoundRobin spreads the calls across the resolved addresses
+	LoadBalancingRoundRobin = "round_robin"
+	// LoadBalancingPickFirst sends the calls to the first resolved address that can be reached
+	LoadBalancingPickFirst = "pick_first"
+
+	// resolutionScheme is the scheme of the targets resolved by the resolver of this package
+	resolutionScheme = "fsc-dns"
+	// resolutionTimeout is the time each resolution has
+	resolutionTimeout = 10 * time.Second
+)
+
+// LookupHostFunc resolves the passed host to its addresses, as net.Resolver#LookupHost does
+type LookupHostFunc func(ctx context.Context, host string) ([]string, error)
+
+// ResolutionConfig configures the resolution of the name in the address of a connection.
+// The name is resolved again periodically, the calls are balanced across the resolved addresses, and
+// the connections are re-dialed when they reach their maximum age, even if the resolved addresses did not change.
+type ResolutionConfig struct {
+	// Interval is the interval between two resolutions, DefaultResolutionInterval if not positive
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// LoadBalancing is either round_robin (default) or pick_first
+	LoadBalancing string `yaml:"loadBalancing,omitempty"`
+	// MaxConnectionAge, if positive, is the age after which the connections are re-dialed, and the name resolved again
+	MaxConnectionAge time.Duration `yaml:"maxConnectionAge,omitempty"`
+	// LookupHost resolves the names, the default resolver if nil
+	LookupHost LookupHostFunc `yaml:"-"`
+}
+
+// Validate returns an error if the load balancing policy is not supported
+func (c *ResolutionConfig) Validate() error {
+	switch c.LoadBalancing {
+	case "", LoadBalancingRoundRobin, LoadBalancingPickFirst:
+		return nil
+	default:
+		return errors.Errorf("invalid load balancing policy [%s], expected [%s] or [%s]", c.LoadBalancing, LoadBalancingRoundRobin, LoadBalancingPickFirst)
+	}
+}
+
+func (c *ResolutionConfig) serviceConfig() string {
+	lb := c.LoadBalancing
+	if len(lb) == 0 {
+		lb = LoadBalancingRoundRobin
+	}
+	return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, lb)
+}
+
+// generationKey is the key of the attribute of the resolved addresses that changes when the connections must be re-dialed.
+// The balancers dial again the addresses whose attributes changed.
+type generationKey struct{}
+
+type resolverBuilder struct {
+	config ResolutionConfig
+}
+
+func (b *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid address [%s]", target.Endpoint)
+	}
+	lookup := b.config.LookupHost
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+	interval := b.config.Interval
+	if interval <= 0 {
+		interval = DefaultResolutionInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsResolver{
+		host:       host,
+		port:       port,
+		cc:         cc,
+		lookup:     lookup,
+		interval:   interval,
+		maxAge:     b.config.MaxConnectionAge,
+		ctx:        ctx,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+func (b *resolverBuilder) Scheme() string {
+	return resolutionScheme
+}
+
+// dnsResolver resolves the name of an address periodically, when gRPC asks it to, and when the connections
+// reach their maximum age
+type dnsResolver struct {
+	host     string
+	port     string
+	cc       resolver.ClientConn
+	lookup   LookupHostFunc
+	interval time.Duration
+	maxAge   time.Duration
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	resolveNow chan struct{}
+
+	// hosts are the last resolved hosts and generation the one of the connections to them,
+	// they are accessed by the watch goroutine only
+	hosts      []string
+	generation int
+}
+
+func (r *dnsResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *dnsResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *dnsResolver) watch() {
+	defer r.wg.Done()
+
+	r.resolve(false)
+	resolution := time.NewTicker(r.interval)
+	defer resolution.Stop()
+	var redial <-chan time.Time
+	if r.maxAge > 0 {
+		age := time.NewTicker(r.maxAge)
+		defer age.Stop()
+		redial = age.C
+	}
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-resolution.C:
+			r.resolve(false)
+		case <-r.resolveNow:
+			r.resolve(false)
+		case <-redial:
+			r.resolve(true)
+		}
+	}
+}
+
+// resolve updates the addresses of the connection if they changed. If redial is true, the connections
+// to the addresses are dialed again anyway.
+func (r *dnsResolver) resolve(redial bool) {
+	ctx, cancel := context.WithTimeout(r.ctx, resolutionTimeout)
+	hosts, err := r.lookup(ctx, r.host)
+	cancel()
+	if err != nil {
+		if r.ctx.Err() != nil {
+			return
+		}
+		commLogger.Warnf("failed resolving [%s], keep the addresses resolved before [%v]: [%s]", r.host, r.hosts, err)
+		if r.hosts == nil {
+			r.cc.ReportError(errors.Wrapf(err, "failed resolving [%s]", r.host))
+		}
+		return
+	}
+	sort.Strings(hosts)
+	if !redial && equalHosts(hosts, r.hosts) {
+		return
+	}
+	if redial {
+		r.generation++
+	}
+	commLogger.Debugf("resolved [%s] to [%v], generation [%d]", r.host, hosts, r.generation)
+	r.hosts = hosts
+
+	addresses := make([]resolver.Address, len(hosts))
+	for i, host := range hosts {
+		addresses[i] = resolver.Address{
+			Addr:       net.JoinHostPort(host, r.port),
+			Attributes: attributes.New(generationKey{}, r.generation),
+		}
+	}
+	if err := r.cc.UpdateState(resolver.State{Addresses: addresses}); err != nil {
+		commLogger.Debugf("failed updating the addresses of [%s]: [%s]", r.host, err)
+	}
+}
+
+func equalHosts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/grpc/testpb"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingServer struct {
+	calls int32
+}
+
+func (s *countingServer) EmptyCall(context.Context, *testpb.Empty) (*testpb.Empty, error) {
+	atomic.AddInt32(&s.calls, 1)
+	return &testpb.Empty{}, nil
+}
+
+func (s *countingServer) EmptyStream(testpb.EmptyService_EmptyStreamServer) error {
+	return nil
+}
+
+// countingListener counts the connections accepted
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+	return c, err
+}
+
+// fakeRecords resolves every name to the hosts it is set to
+type fakeRecords struct {
+	lock        sync.Mutex
+	hosts       []string
+	resolutions int
+}
+
+func (r *fakeRecords) set(hosts ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.hosts = hosts
+}
+
+func (r *fakeRecords) lookup(context.Context, string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.resolutions++
+	return r.hosts, nil
+}
+
+// startServers starts a server on each of the passed loopback hosts, all on the same port
+func startServers(t *testing.T, hosts ...string) (string, []*countingServer, []*countingListener) {
+	for attempt := 0; attempt < 10; attempt++ {
+		first, err := net.Listen("tcp", net.JoinHostPort(hosts[0], "0"))
+		assert.NoError(t, err)
+		_, port, _ := net.SplitHostPort(first.Addr().String())
+		listeners := []net.Listener{first}
+		for _, host := range hosts[1:] {
+			lis, err := net.Listen("tcp", net.JoinHostPort(host, port))
+			if err != nil {
+				break
+			}
+			listeners = append(listeners, lis)
+		}
+		if len(listeners) != len(hosts) {
+			for _, lis := range listeners {
+				lis.Close()
+			}
+			continue
+		}
+
+		var servers []*countingServer
+		var counting []*countingListener
+		for _, lis := range listeners {
+			cl := &countingListener{Listener: lis}
+			srv, err := NewGRPCServerFromListener(cl, ServerConfig{})
+			assert.NoError(t, err)
+			s := &countingServer{}
+			testpb.RegisterEmptyServiceServer(srv.Server(), s)
+			go srv.Start()
+			t.Cleanup(srv.Stop)
+			servers = append(servers, s)
+			counting = append(counting, cl)
+		}
+		return port, servers, counting
+	}
+	t.Fatalf("no port available on %v", hosts)
+	return "", nil, nil
+}
+
+func TestResolutionFollowsRecords(t *testing.T) {
+	port, servers, _ := startServers(t, "127.0.0.1", "127.0.0.2")
+	records := &fakeRecords{}
+	records.set("127.0.0.1")
+
+	client, err := NewGRPCClient(ClientConfig{
+		Timeout: 5 * time.Second,
+		Resolution: &ResolutionConfig{
+			Interval:   50 * time.Millisecond,
+			LookupHost: records.lookup,
+		},
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+	conn, err := client.NewConnection(net.JoinHostPort("orderer.example.com", port))
+	assert.NoError(t, err)
+	es := testpb.NewEmptyServiceClient(conn)
+
+	for i := 0; i < 5; i++ {
+		_, err := es.EmptyCall(context.Background(), &testpb.Empty{})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(5), atomic.LoadInt32(&servers[0].calls))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&servers[1].calls))
+
+	// the record changes during a maintenance, the traffic moves to the new address
+	records.set("127.0.0.2")
+	assert.Eventually(t, func() bool {
+		_, err := es.EmptyCall(context.Background(), &testpb.Empty{})
+		assert.NoError(t, err)
+		return atomic.LoadInt32(&servers[1].calls) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	before := atomic.LoadInt32(&servers[0].calls)
+	for i := 0; i < 5; i++ {
+		_, err := es.EmptyCall(context.Background(), &testpb.Empty{})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, before, atomic.LoadInt32(&servers[0].calls))
+
+	// with both addresses, the calls are balanced across them
+	records.set("127.0.0.1", "127.0.0.2")
+	assert.Eventually(t, func() bool {
+		_, err := es.EmptyCall(context.Background(), &testpb.Empty{})
+		assert.NoError(t, err)
+		return atomic.LoadInt32(&servers[0].calls) > before
+	}, 5*time.Second, 10*time.Millisecond)
+	first, second := atomic.LoadInt32(&servers[0].calls), atomic.LoadInt32(&servers[1].calls)
+	for i := 0; i < 10; i++ {
+		_, err := es.EmptyCall(context.Background(), &testpb.Empty{})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(5), atomic.LoadInt32(&servers[0].calls)-first)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&servers[1].calls)-second)
+}
+
+func TestResolutionMaxConnectionAge(t *testing.T) {
+	port, _, listeners := startServers(t, "127.0.0.1")
+	records := &fakeRecords{}
+	records.set("127.0.0.1")
+
+	client, err := NewGRPCClient(ClientConfig{
+		Timeout: 5 * time.Second,
+		Resolution: &ResolutionConfig{
+			Interval:         time.Hour,
+			MaxConnectionAge: 100 * time.Millisecond,
+			LookupHost:       records.lookup,
+		},
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+	conn, err := client.NewConnection(net.JoinHostPort("localhost", port))
+	assert.NoError(t, err)
+	es := testpb.NewEmptyServiceClient(conn)
+	_, err = es.EmptyCall(context.Background(), &testpb.Empty{})
+	assert.NoError(t, err)
+
+	// the connection is dialed again, even if the records did not change
+	assert.Eventually(t, func() bool {
+		_, err := es.EmptyCall(context.Background(), &testpb.Empty{})
+		assert.NoError(t, err)
+		return atomic.LoadInt32(&listeners[0].accepted) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+	records.lock.Lock()
+	assert.GreaterOrEqual(t, records.resolutions, 3)
+	records.lock.Unlock()
+}
+
+func TestResolutionConfig(t *testing.T) {
+	_, err := NewGRPCClient(ClientConfig{Resolution: &ResolutionConfig{LoadBalancing: "random"}})
+	assert.EqualError(t, err, "invalid load balancing policy [random], expected [round_robin] or [pick_first]")
+
+	c := &ResolutionConfig{}
+	assert.Equal(t, `{"loadBalancingConfig":[{"round_robin":{}}]}`, c.serviceConfig())
+	c.LoadBalancing = LoadBalancingPickFirst
+	assert.Equal(t, `{"loadBalancingConfig":[{"pick_first":{}}]}`, c.serviceConfig())
+	assert.Equal(t, resolutionScheme, (&resolverBuilder{}).Scheme())
+}
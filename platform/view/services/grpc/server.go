@@ -15,10 +15,12 @@ import (
 	"time"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/hyperledger/fabric-lib-go/healthz"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 type GRPCServer struct {
@@ -40,6 +42,12 @@ type GRPCServer struct {
 	tls *TLSConfig
 	// Server for gRPC Health Check Protocol.
 	healthServer *health.Server
+	// checkers used to update the serving statuses of the health server, indexed by service
+	healthCheckers      map[string]healthz.HealthChecker
+	healthCheckInterval time.Duration
+	healthDrainDelay    time.Duration
+	stopHealth          chan struct{}
+	stopHealthOnce      sync.Once
 }
 
 // NewGRPCServer creates a new implementation of a GRPCServer given a
@@ -64,9 +72,11 @@ func NewGRPCServer(address string, serverConfig ServerConfig) (*GRPCServer, erro
 // an existing net.Listener instance using default keepalive
 func NewGRPCServerFromListener(listener net.Listener, serverConfig ServerConfig) (*GRPCServer, error) {
 	grpcServer := &GRPCServer{
-		address:  listener.Addr().String(),
-		listener: listener,
-		lock:     &sync.Mutex{},
+		address:        listener.Addr().String(),
+		listener:       listener,
+		lock:           &sync.Mutex{},
+		healthCheckers: map[string]healthz.HealthChecker{},
+		stopHealth:     make(chan struct{}),
 	}
 
 	//set up our server options
@@ -145,18 +155,21 @@ func NewGRPCServerFromListener(listener net.Listener, serverConfig ServerConfig)
 	serverOpts = append(
 		serverOpts,
 		grpc.ConnectionTimeout(serverConfig.ConnectionTimeout))
-	// set the interceptors
-	if len(serverConfig.StreamInterceptors) > 0 {
+	// set the interceptors, the ones of the config run before the registered ones
+	registered := RegisteredInterceptors()
+	streamInterceptors := append(append([]grpc.StreamServerInterceptor(nil), serverConfig.StreamInterceptors...), registered.StreamServer...)
+	if len(streamInterceptors) > 0 {
 		serverOpts = append(
 			serverOpts,
-			grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(serverConfig.StreamInterceptors...)),
+			grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)),
 		)
 	}
 
-	if len(serverConfig.UnaryInterceptors) > 0 {
+	unaryInterceptors := append(append([]grpc.UnaryServerInterceptor(nil), serverConfig.UnaryInterceptors...), registered.UnaryServer...)
+	if len(unaryInterceptors) > 0 {
 		serverOpts = append(
 			serverOpts,
-			grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(serverConfig.UnaryInterceptors...)),
+			grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)),
 		)
 	}
 
@@ -169,6 +182,15 @@ func NewGRPCServerFromListener(listener net.Listener, serverConfig ServerConfig)
 	if serverConfig.HealthCheckEnabled {
 		grpcServer.healthServer = health.NewServer()
 		healthpb.RegisterHealthServer(grpcServer.server, grpcServer.healthServer)
+		grpcServer.healthCheckInterval = serverConfig.HealthCheckInterval
+		if grpcServer.healthCheckInterval <= 0 {
+			grpcServer.healthCheckInterval = DefaultHealthCheckInterval
+		}
+		grpcServer.healthDrainDelay = serverConfig.HealthDrainDelay
+	}
+
+	if serverConfig.ReflectionEnabled {
+		reflection.Register(grpcServer.server)
 	}
 
 	return grpcServer, nil
@@ -179,6 +201,23 @@ func (gServer *GRPCServer) SetServerCertificate(cert tls.Certificate) {
 	gServer.serverCertificate.Store(cert)
 }
 
+// SetServerNameCertificate serves the passed certificate to the clients asking for the passed name by SNI,
+// as when the server is behind an ingress routing by name. The other clients are served the server certificate.
+func (gServer *GRPCServer) SetServerNameCertificate(serverName string, cert tls.Certificate) error {
+	if gServer.tls == nil {
+		return errors.New("TLS is not enabled for the server")
+	}
+	gServer.tls.SetServerNameCertificate(serverName, cert)
+	return nil
+}
+
+// RemoveServerNameCertificate serves the server certificate again to the clients asking for the passed name
+func (gServer *GRPCServer) RemoveServerNameCertificate(serverName string) {
+	if gServer.tls != nil {
+		gServer.tls.RemoveServerNameCertificate(serverName)
+	}
+}
+
 // Address returns the listen address for this GRPCServer instance
 func (gServer *GRPCServer) Address() string {
 	return gServer.address
@@ -214,7 +253,8 @@ func (gServer *GRPCServer) MutualTLSRequired() bool {
 
 // Start starts the underlying grpc.Server
 func (gServer *GRPCServer) Start() error {
-	// if health check is enabled, set the health status for all registered services
+	// if health check is enabled, set the health status for all registered services,
+	// the services with a health checker are then updated from their checker
 	if gServer.healthServer != nil {
 		for name := range gServer.server.GetServiceInfo() {
 			gServer.healthServer.SetServingStatus(
@@ -227,12 +267,23 @@ func (gServer *GRPCServer) Start() error {
 			"",
 			healthpb.HealthCheckResponse_SERVING,
 		)
+		gServer.updateHealth()
+		go gServer.monitorHealth()
 	}
 	return gServer.server.Serve(gServer.listener)
 }
 
-// Stop stops the underlying grpc.Server
+// Stop stops the underlying grpc.Server.
+// If health check is enabled, all the services are set to not serving before the listener is closed,
+// and the server keeps serving for the drain delay of its configuration.
 func (gServer *GRPCServer) Stop() {
+	if gServer.healthServer != nil {
+		gServer.shutdownHealth()
+		if gServer.healthDrainDelay > 0 {
+			commLogger.Infof("services set to not serving, stopping the grpc server in [%s]", gServer.healthDrainDelay)
+			time.Sleep(gServer.healthDrainDelay)
+		}
+	}
 	gServer.server.Stop()
 }
 
@@ -1115,6 +1115,96 @@ func TestUpdateTLSCert(t *testing.T) {
 	assert.Contains(t, err.Error(), "context deadline exceeded")
 }
 
+func TestServerNameCertificates(t *testing.T) {
+	t.Parallel()
+
+	ca, err := tlsgen.NewCA()
+	assert.NoError(t, err)
+	newKeyPair := func(host string) (*tlsgen.CertKeyPair, tls.Certificate) {
+		kp, err := ca.NewServerCertKeyPair(host)
+		assert.NoError(t, err)
+		cert, err := tls.X509KeyPair(kp.Cert, kp.Key)
+		assert.NoError(t, err)
+		return kp, cert
+	}
+	defaultKP, _ := newKeyPair("127.0.0.1")
+	aliceKP, aliceCert := newKeyPair("alice.example.com")
+	bobKP, bobCert := newKeyPair("*.bob.example.com")
+
+	// one listener serves the nodes behind the ingress
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv, err := grpc3.NewGRPCServerFromListener(lis, grpc3.ServerConfig{
+		SecOpts: grpc3.SecureOptions{
+			UseTLS:      true,
+			Key:         defaultKP.Key,
+			Certificate: defaultKP.Cert,
+		},
+	})
+	assert.NoError(t, err)
+	testpb.RegisterEmptyServiceServer(srv.Server(), &emptyServiceServer{})
+	assert.NoError(t, srv.SetServerNameCertificate("alice.example.com", aliceCert))
+	assert.NoError(t, srv.SetServerNameCertificate("*.bob.example.com", bobCert))
+	go srv.Start()
+	defer srv.Stop()
+
+	// the certificate is selected by SNI, h2 is negotiated by ALPN.
+	// The certificates served are compared, the default one is not valid for the names of the nodes.
+	handshake := func(serverName string) tls.ConnectionState {
+		conn, err := tls.Dial("tcp", srv.Address(), &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"h2"},
+		})
+		if !assert.NoError(t, err, serverName) {
+			return tls.ConnectionState{}
+		}
+		defer conn.Close()
+		return conn.ConnectionState()
+	}
+	for serverName, kp := range map[string]*tlsgen.CertKeyPair{
+		"alice.example.com":       aliceKP,
+		"ALICE.example.com.":      aliceKP,
+		"node1.bob.example.com":   bobKP,
+		"127.0.0.1":               defaultKP,
+		"charlie.example.com":     defaultKP,
+		"node1.alice.example.com": defaultKP,
+	} {
+		state := handshake(serverName)
+		assert.Equal(t, "h2", state.NegotiatedProtocol, serverName)
+		if assert.NotEmpty(t, state.PeerCertificates, serverName) {
+			assert.Equal(t, kp.TLSCert.Raw, state.PeerCertificates[0].Raw, serverName)
+		}
+	}
+
+	// the clients dial the address of the ingress, and ask for the name of the node
+	invoke := func(serverNameOverride string) error {
+		client, err := grpc3.CreateGRPCClient(&grpc3.ConnectionConfig{
+			Address:            srv.Address(),
+			ConnectionTimeout:  testTimeout,
+			TLSEnabled:         true,
+			TLSRootCertBytes:   [][]byte{ca.CertBytes()},
+			ServerNameOverride: serverNameOverride,
+		})
+		assert.NoError(t, err)
+		defer client.Close()
+		conn, err := client.NewConnection(srv.Address())
+		if err != nil {
+			return err
+		}
+		_, err = testpb.NewEmptyServiceClient(conn).EmptyCall(context.Background(), new(testpb.Empty))
+		return err
+	}
+	assert.NoError(t, invoke("alice.example.com"))
+	assert.NoError(t, invoke("node1.bob.example.com"))
+	assert.NoError(t, invoke(""))
+
+	// once removed, the default certificate is served, and it is not valid for the name
+	srv.RemoveServerNameCertificate("alice.example.com")
+	assert.Equal(t, defaultKP.TLSCert.Raw, handshake("alice.example.com").PeerCertificates[0].Raw)
+	assert.Error(t, invoke("alice.example.com"))
+}
+
 func TestCipherSuites(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package introspection
+
+import (
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+)
+
+// FlowsURI is the URI, relative to the web server API, of the flows running on the node.
+// GET lists the flows with the operations they block on, GET FlowsURI/{id} returns a flow with the history of
+// its sessions, and POST FlowsURI/{id}/abort cancels the context of a flow.
+// The IDs of the flows must be escaped in the path.
+const FlowsURI = "/admin/flows"
+
+// FlowsHandler serves the flows running on the node
+type FlowsHandler struct {
+	inspector driver.FlowInspector
+}
+
+// NewFlowsHandler returns a handler serving the flows of the passed inspector
+func NewFlowsHandler(inspector driver.FlowInspector) *FlowsHandler {
+	return &FlowsHandler{inspector: inspector}
+}
+
+// RegisterFlowsHandlers registers the URIs of the flows of the passed inspector with the passed handler
+func RegisterFlowsHandlers(h *web.HttpHandler, inspector driver.FlowInspector) {
+	handler := NewFlowsHandler(inspector)
+	h.RegisterURI(FlowsURI, http.MethodGet, handler)
+	h.RegisterURI(FlowsURI+"/{id}", http.MethodGet, handler)
+	h.RegisterURI(FlowsURI+"/{id}/abort", http.MethodPost, handler)
+}
+
+func (h *FlowsHandler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *FlowsHandler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	id, ok := context.Vars["id"]
+	if !ok {
+		return h.inspector.RunningFlows(), http.StatusOK
+	}
+	if context.Req.Method == http.MethodPost {
+		if err := h.inspector.AbortFlow(id); err != nil {
+			return flowError(id, err)
+		}
+		return nil, http.StatusOK
+	}
+	flow, err := h.inspector.RunningFlow(id)
+	if err != nil {
+		return flowError(id, err)
+	}
+	return flow, http.StatusOK
+}
+
+func flowError(id string, err error) (interface{}, int) {
+	if errors.Is(err, driver.ErrFlowNotFound) {
+		return &web.ResponseErr{Reason: "flow [" + id + "] not found"}, http.StatusNotFound
+	}
+	return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+}
@@ -0,0 +1,87 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package introspection_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/introspection"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type inspector struct {
+	flows   []driver.RunningFlow
+	aborted []string
+}
+
+func (i *inspector) RunningFlows() []driver.RunningFlow {
+	return i.flows
+}
+
+func (i *inspector) RunningFlow(id string) (*driver.FlowDetail, error) {
+	for _, f := range i.flows {
+		if f.ID == id {
+			return &driver.FlowDetail{RunningFlow: f, History: []driver.SessionEvent{{Kind: driver.SessionOpened, SessionID: "s1"}}}, nil
+		}
+	}
+	return nil, errors.Wrapf(driver.ErrFlowNotFound, "flow [%s]", id)
+}
+
+func (i *inspector) AbortFlow(id string) error {
+	if _, err := i.RunningFlow(id); err != nil {
+		return err
+	}
+	i.aborted = append(i.aborted, id)
+	return nil
+}
+
+func TestFlows(t *testing.T) {
+	i := &inspector{flows: []driver.RunningFlow{{ID: "ctx#label", View: "responder", Blocked: []driver.BlockingOperation{{Operation: "finality", Targets: []string{"tx1"}}}}}}
+	h := web.NewHttpHandler(flogging.MustGetLogger("test"))
+	introspection.RegisterFlowsHandlers(h, i)
+	server := httptest.NewServer(h)
+	defer server.Close()
+	flowURL := server.URL + "/v1" + introspection.FlowsURI + "/" + url.PathEscape("ctx#label")
+
+	res, err := http.Get(server.URL + "/v1" + introspection.FlowsURI)
+	assert.NoError(t, err)
+	var flows []driver.RunningFlow
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&flows))
+	res.Body.Close()
+	assert.Equal(t, i.flows, flows)
+
+	res, err = http.Get(flowURL)
+	assert.NoError(t, err)
+	detail := &driver.FlowDetail{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(detail))
+	res.Body.Close()
+	assert.Equal(t, "ctx#label", detail.ID)
+	assert.Len(t, detail.History, 1)
+
+	res, err = http.Post(flowURL+"/abort", "application/json", nil)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, []string{"ctx#label"}, i.aborted)
+
+	res, err = http.Get(server.URL + "/v1" + introspection.FlowsURI + "/missing")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	res, err = http.Post(server.URL+"/v1"+introspection.FlowsURI+"/missing/abort", "application/json", nil)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
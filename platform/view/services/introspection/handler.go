@@ -0,0 +1,38 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package introspection
+
+import (
+	"net/http"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+)
+
+// RegistryURI is the URI, relative to the web server API, of the description of the registrations of the node
+const RegistryURI = "/admin/registry"
+
+// Handler serves the description of the registrations of the node
+type Handler struct {
+	service *Service
+}
+
+// NewHandler returns a handler serving the registry of the passed service
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) ParsePayload(bytes []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *Handler) HandleRequest(context *web.ReqContext) (interface{}, int) {
+	registry, err := h.service.Registry()
+	if err != nil {
+		return &web.ResponseErr{Reason: err.Error()}, http.StatusInternalServerError
+	}
+	return registry, http.StatusOK
+}
@@ -0,0 +1,161 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package introspection
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.introspection")
+
+// ViewsSection is the name of the section describing the views registered in the view manager
+const ViewsSection = "views"
+
+// Section returns the description of what a component has registered in its own registries.
+// The description is marshalled to JSON and must not contain sensitive values, such as keys or secrets.
+type Section func() (interface{}, error)
+
+// Service assembles the description of what is registered in the node from the sections the platforms register.
+// It does not keep its own bookkeeping: each section reads the registry of its component when the description is requested.
+type Service struct {
+	lock     sync.RWMutex
+	sections map[string]Section
+}
+
+// NewService returns a service without sections
+func NewService() *Service {
+	return &Service{sections: map[string]Section{}}
+}
+
+// GetService returns the introspection service registered in the passed service provider, nil if not available
+func GetService(sp driver.ServiceProvider) *Service {
+	s, err := sp.GetService(reflect.TypeOf((*Service)(nil)))
+	if err != nil {
+		return nil
+	}
+	return s.(*Service)
+}
+
+// RegisterSection registers the section with the passed name
+func (s *Service) RegisterSection(name string, section Section) error {
+	if len(name) == 0 {
+		return errors.New("section name cannot be empty")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.sections[name]; ok {
+		return errors.Errorf("section [%s] already registered", name)
+	}
+	s.sections[name] = section
+	return nil
+}
+
+// Registry returns the description of the registrations, one entry per section.
+// The sections failing are reported by the returned error, the others are returned anyway.
+func (s *Service) Registry() (Registry, error) {
+	s.lock.RLock()
+	names := make([]string, 0, len(s.sections))
+	for name := range s.sections {
+		names = append(names, name)
+	}
+	s.lock.RUnlock()
+	sort.Strings(names)
+
+	res := Registry{}
+	var failures []string
+	for _, name := range names {
+		s.lock.RLock()
+		section := s.sections[name]
+		s.lock.RUnlock()
+
+		description, err := section()
+		if err == nil {
+			res[name], err = json.Marshal(description)
+		}
+		if err != nil {
+			logger.Errorf("failed describing section [%s]: [%s]", name, err)
+			failures = append(failures, name+": "+err.Error())
+		}
+	}
+	if len(failures) != 0 {
+		return res, errors.Errorf("failed describing sections [%s]", strings.Join(failures, "; "))
+	}
+	return res, nil
+}
+
+// NewViewsSection returns the section describing the factories, the responders and the recoverable views of the passed registry
+func NewViewsSection(registry driver.RegistryInspector) Section {
+	return func() (interface{}, error) {
+		return registry.Registrations(), nil
+	}
+}
+
+// Registry is the description of the registrations of a node, the JSON description of each section by name
+type Registry map[string]json.RawMessage
+
+// Section unmarshals the section with the passed name into v
+func (r Registry) Section(name string, v interface{}) error {
+	raw, ok := r[name]
+	if !ok {
+		return errors.Errorf("section [%s] not found", name)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.Wrapf(err, "failed unmarshalling section [%s]", name)
+	}
+	return nil
+}
+
+// Views returns the views section
+func (r Registry) Views() (*driver.Registrations, error) {
+	res := &driver.Registrations{}
+	if err := r.Section(ViewsSection, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ExpectViews returns an error listing the passed view factories and responders not registered.
+// Deployment tooling can use it to assert the expected registrations of a node.
+func (r Registry) ExpectViews(factories []string, responders []string) error {
+	views, err := r.Views()
+	if err != nil {
+		return err
+	}
+	registered := map[string]bool{}
+	for _, f := range views.Factories {
+		registered["factory "+f.ID] = true
+	}
+	for _, v := range views.Responders {
+		if !v.Initiator {
+			registered["responder "+v.View] = true
+		}
+	}
+
+	var missing []string
+	for _, id := range factories {
+		if !registered["factory "+id] {
+			missing = append(missing, "factory "+id)
+		}
+	}
+	for _, id := range responders {
+		if !registered["responder "+id] {
+			missing = append(missing, "responder "+id)
+		}
+	}
+	if len(missing) != 0 {
+		return errors.Errorf("missing registrations [%s]", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package introspection_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	webclient "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/client/web"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/introspection"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/web"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type registry struct {
+	registrations *driver.Registrations
+}
+
+func (r *registry) Registrations() *driver.Registrations {
+	return r.registrations
+}
+
+func TestRegistry(t *testing.T) {
+	s := introspection.NewService()
+	views := &driver.Registrations{
+		Factories:  []driver.FactoryRegistration{{ID: "transfer", InputSchema: []byte(`{"type":"object"}`)}},
+		Responders: []driver.ResponderRegistration{{View: "responder", InitiatedBy: []string{"initiator"}}, {View: "initiator", Initiator: true}},
+	}
+	assert.NoError(t, s.RegisterSection(introspection.ViewsSection, introspection.NewViewsSection(&registry{registrations: views})))
+	assert.NoError(t, s.RegisterSection("platform", func() (interface{}, error) { return map[string]int{"networks": 2}, nil }))
+	assert.EqualError(t, s.RegisterSection("platform", nil), "section [platform] already registered")
+
+	h := web.NewHttpHandler(flogging.MustGetLogger("test"))
+	h.RegisterURI(introspection.RegistryURI, "GET", introspection.NewHandler(s))
+	server := httptest.NewServer(h)
+	defer server.Close()
+	client, err := webclient.NewClient(&webclient.Config{URL: server.URL})
+	assert.NoError(t, err)
+
+	r, err := client.Registry()
+	assert.NoError(t, err)
+	described, err := r.Views()
+	assert.NoError(t, err)
+	assert.Equal(t, views, described)
+	platform := map[string]int{}
+	assert.NoError(t, r.Section("platform", &platform))
+	assert.Equal(t, map[string]int{"networks": 2}, platform)
+	assert.EqualError(t, r.Section("missing", &platform), "section [missing] not found")
+
+	// the deployment tooling asserts the expected registrations
+	assert.NoError(t, r.ExpectViews([]string{"transfer"}, []string{"responder"}))
+	assert.EqualError(t, r.ExpectViews([]string{"transfer", "mint"}, []string{"initiator"}), "missing registrations [factory mint, responder initiator]")
+
+	// a failing section fails the request
+	assert.NoError(t, s.RegisterSection("failing", func() (interface{}, error) { return nil, errors.New("unavailable") }))
+	_, err = client.Registry()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed describing sections [failing: unavailable]")
+}
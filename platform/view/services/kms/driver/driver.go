@@ -7,7 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package driver
 
 import (
+	"time"
+
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
 )
 
 type ConfigProvider interface {
@@ -33,3 +36,37 @@ type Driver interface {
 	// Load returns the signer, verifier and signing identity bound to the byte representation of passed pem encoded public key.
 	Load(configProvider ConfigProvider) (view.Identity, Signer, Verifier, error)
 }
+
+// KeyManagerDriver is implemented by the drivers able to load an identity whose key is wrapped by a key manager
+type KeyManagerDriver interface {
+	// LoadWithKeyManager is Load, the key of the identity is unwrapped with the passed key manager
+	LoadWithKeyManager(configProvider ConfigProvider, keyManager KeyManager) (view.Identity, Signer, Verifier, error)
+}
+
+// ErrSignNotSupported is returned by the key managers that do not sign with the keys they hold
+var ErrSignNotSupported = errors.New("remote signing not supported by the key manager")
+
+// KeyManager models a key management service holding the keys that wrap the secrets of the node.
+// The keys never leave the service, they are referred to by their id.
+type KeyManager interface {
+	// Encrypt wraps the passed plaintext with the key of the passed id
+	Encrypt(keyID string, plaintext []byte) ([]byte, error)
+	// Decrypt unwraps the passed ciphertext, returned by Encrypt, with the key of the passed id
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+	// Sign signs the passed message with the key of the passed id, or it returns ErrSignNotSupported
+	Sign(keyID string, message []byte) ([]byte, error)
+}
+
+// KeyManagerConfigProvider models the configuration of the key managers
+type KeyManagerConfigProvider interface {
+	GetString(key string) string
+	GetDuration(key string) time.Duration
+	GetPath(key string) string
+	IsSet(key string) bool
+}
+
+// KeyManagerProvider returns a key manager connected to its service, as configured by the passed config provider.
+// It returns an error if the service is not reachable.
+type KeyManagerProvider interface {
+	New(configProvider KeyManagerConfigProvider) (KeyManager, error)
+}
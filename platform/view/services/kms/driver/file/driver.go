@@ -19,6 +19,11 @@ import (
 type Driver struct{}
 
 func (d *Driver) Load(configProvider driver.ConfigProvider) (view.Identity, driver.Signer, driver.Verifier, error) {
+	return d.LoadWithKeyManager(configProvider, nil)
+}
+
+// LoadWithKeyManager is Load, the key file can hold a key wrapped by the passed key manager
+func (d *Driver) LoadWithKeyManager(configProvider driver.ConfigProvider, keyManager driver.KeyManager) (view.Identity, driver.Signer, driver.Verifier, error) {
 	idPEM, err := ioutil.ReadFile(configProvider.GetPath("fsc.identity.cert.file"))
 	if err != nil {
 		return nil, nil, nil, errors.Wrapf(err, "failed loading SFC Node Identity")
@@ -27,6 +32,15 @@ func (d *Driver) Load(configProvider driver.ConfigProvider) (view.Identity, driv
 	if err != nil {
 		return nil, nil, nil, errors.Wrapf(err, "failed reading file [%s]", fileCont)
 	}
+	if kms.IsWrappedKey(fileCont) {
+		if keyManager == nil {
+			return nil, nil, nil, errors.Errorf("key file [%s] is wrapped but no key manager is configured", configProvider.GetPath("fsc.identity.key.file"))
+		}
+		fileCont, err = kms.UnwrapKey(keyManager, fileCont)
+		if err != nil {
+			return nil, nil, nil, errors.WithMessagef(err, "failed unwrapping key file [%s]", configProvider.GetPath("fsc.identity.key.file"))
+		}
+	}
 	id, verifier, err := ecdsa.NewIdentityFromPEMCert(idPEM)
 	if err != nil {
 		return nil, nil, nil, errors.Wrap(err, "failed loading default verifier")
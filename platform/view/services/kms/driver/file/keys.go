@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+
+	kms "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	"github.com/pkg/errors"
+)
+
+// MasterKeySize is the minimum size in bytes of the master key of the file key manager
+const MasterKeySize = 32
+
+// KeyManagerProvider returns key managers whose keys are derived from a master key stored in a file.
+// They are meant for development: the master key is as sensitive as the secrets it wraps.
+type KeyManagerProvider struct{}
+
+func (p *KeyManagerProvider) New(configProvider driver.KeyManagerConfigProvider) (driver.KeyManager, error) {
+	path := configProvider.GetPath("fsc.keys.file.masterKey")
+	if len(path) == 0 {
+		return nil, errors.New("no master key file configured at [fsc.keys.file.masterKey]")
+	}
+	masterKey, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading master key file [%s]", path)
+	}
+	return NewKeyManager(masterKey)
+}
+
+// KeyManager wraps the secrets with AES-GCM, the key of each id is derived from the master key
+type KeyManager struct {
+	masterKey []byte
+}
+
+// NewKeyManager returns a key manager deriving its keys from the passed master key
+func NewKeyManager(masterKey []byte) (*KeyManager, error) {
+	if len(masterKey) < MasterKeySize {
+		return nil, errors.Errorf("master key must be at least [%d] bytes, got [%d]", MasterKeySize, len(masterKey))
+	}
+	return &KeyManager{masterKey: masterKey}, nil
+}
+
+func (k *KeyManager) Encrypt(keyID string, plaintext []byte) ([]byte, error) {
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "failed generating nonce")
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(keyID)), nil
+}
+
+func (k *KeyManager) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed decrypting with key [%s]", keyID)
+	}
+	return plaintext, nil
+}
+
+// Sign is not supported, the file key manager only wraps secrets
+func (k *KeyManager) Sign(keyID string, message []byte) ([]byte, error) {
+	return nil, driver.ErrSignNotSupported
+}
+
+func (k *KeyManager) aead(keyID string) (cipher.AEAD, error) {
+	if len(keyID) == 0 {
+		return nil, errors.New("empty key id")
+	}
+	mac := hmac.New(sha256.New, k.masterKey)
+	mac.Write([]byte(keyID))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating cipher")
+	}
+	return cipher.NewGCM(block)
+}
+
+func init() {
+	kms.RegisterKeyManager("file", &KeyManagerProvider{})
+}
@@ -0,0 +1,230 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	kms "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMount is the path where the transit secrets engine is mounted, if not configured
+	DefaultMount = "transit"
+	// DefaultTimeout is the timeout of the requests to Vault, if not configured
+	DefaultTimeout = 10 * time.Second
+
+	// tokenEnv is the environment variable read when the token is not configured
+	tokenEnv = "VAULT_TOKEN"
+	// versionPrefix prefixes the signatures and the ciphertexts returned by the transit engine
+	versionPrefix = "vault:v"
+)
+
+// KeyManagerProvider returns key managers backed by the transit secrets engine of HashiCorp Vault
+type KeyManagerProvider struct{}
+
+// New returns a key manager for the Vault set by `fsc.keys.vault`.
+// It fails if Vault cannot be reached or if the token is not valid.
+func (p *KeyManagerProvider) New(configProvider driver.KeyManagerConfigProvider) (driver.KeyManager, error) {
+	address := configProvider.GetString("fsc.keys.vault.address")
+	if len(address) == 0 {
+		return nil, errors.New("no vault address configured at [fsc.keys.vault.address]")
+	}
+	token := configProvider.GetString("fsc.keys.vault.token")
+	if len(token) == 0 {
+		token = os.Getenv(tokenEnv)
+	}
+	if len(token) == 0 {
+		return nil, errors.Errorf("no vault token configured at [fsc.keys.vault.token] or in [%s]", tokenEnv)
+	}
+	mount := configProvider.GetString("fsc.keys.vault.mount")
+	timeout := configProvider.GetDuration("fsc.keys.vault.timeout")
+
+	var tlsConfig *tls.Config
+	if rootCert := configProvider.GetPath("fsc.keys.vault.tls.rootCert"); len(rootCert) != 0 {
+		raw, err := ioutil.ReadFile(rootCert)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading vault root certificate [%s]", rootCert)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw) {
+			return nil, errors.Errorf("no certificate found in [%s]", rootCert)
+		}
+		tlsConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	km, err := NewKeyManager(address, token, mount, timeout, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := km.Check(); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// KeyManager wraps the secrets with the keys of a transit secrets engine, and signs with them
+type KeyManager struct {
+	address string
+	token   string
+	mount   string
+	client  *http.Client
+}
+
+// NewKeyManager returns a key manager for the transit engine mounted at the passed path of the Vault at the passed address.
+// If the tls config is nil, the default one is used.
+func NewKeyManager(address, token, mount string, timeout time.Duration, tlsConfig *tls.Config) (*KeyManager, error) {
+	u, err := url.Parse(address)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, errors.Errorf("invalid vault address [%s]", address)
+	}
+	if len(mount) == 0 {
+		mount = DefaultMount
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &KeyManager{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		mount:   strings.Trim(mount, "/"),
+		client:  &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// Check returns an error if Vault cannot be reached or if the token is not valid
+func (k *KeyManager) Check() error {
+	if err := k.do(http.MethodGet, "auth/token/lookup-self", nil, nil); err != nil {
+		return errors.WithMessagef(err, "vault at [%s] not reachable", k.address)
+	}
+	return nil
+}
+
+func (k *KeyManager) Encrypt(keyID string, plaintext []byte) ([]byte, error) {
+	res := &struct {
+		Ciphertext string `json:"ciphertext"`
+	}{}
+	req := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := k.do(http.MethodPost, k.mount+"/encrypt/"+url.PathEscape(keyID), req, res); err != nil {
+		return nil, errors.WithMessagef(err, "failed encrypting with key [%s]", keyID)
+	}
+	return []byte(res.Ciphertext), nil
+}
+
+func (k *KeyManager) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	res := &struct {
+		Plaintext string `json:"plaintext"`
+	}{}
+	req := map[string]string{"ciphertext": string(ciphertext)}
+	if err := k.do(http.MethodPost, k.mount+"/decrypt/"+url.PathEscape(keyID), req, res); err != nil {
+		return nil, errors.WithMessagef(err, "failed decrypting with key [%s]", keyID)
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(res.Plaintext)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid plaintext returned by vault")
+	}
+	return plaintext, nil
+}
+
+// Sign returns the ASN.1 encoded signature of the sha256 digest of the passed message.
+// The key of the passed id must be an ECDSA key.
+func (k *KeyManager) Sign(keyID string, message []byte) ([]byte, error) {
+	res := &struct {
+		Signature string `json:"signature"`
+	}{}
+	req := map[string]string{
+		"input":                base64.StdEncoding.EncodeToString(message),
+		"hash_algorithm":       "sha2-256",
+		"marshaling_algorithm": "asn1",
+	}
+	if err := k.do(http.MethodPost, k.mount+"/sign/"+url.PathEscape(keyID), req, res); err != nil {
+		return nil, errors.WithMessagef(err, "failed signing with key [%s]", keyID)
+	}
+	// the signature is in the form vault:v<version>:<base64>
+	parts := strings.SplitN(res.Signature, ":", 3)
+	if len(parts) != 3 || !strings.HasPrefix(res.Signature, versionPrefix) {
+		return nil, errors.Errorf("invalid signature returned by vault [%s]", res.Signature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid signature returned by vault")
+	}
+	return sig, nil
+}
+
+// do sends the passed request to the passed path of the api, and it unmarshals the data of the response into res, if not nil
+func (k *KeyManager) do(method, path string, req interface{}, res interface{}) error {
+	var body io.Reader
+	if req != nil {
+		raw, err := json.Marshal(req)
+		if err != nil {
+			return errors.Wrap(err, "failed marshalling request")
+		}
+		body = bytes.NewReader(raw)
+	}
+	r, err := http.NewRequest(method, k.address+"/v1/"+path, body)
+	if err != nil {
+		return errors.Wrap(err, "failed creating request")
+	}
+	r.Header.Set("X-Vault-Token", k.token)
+	if req != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := k.client.Do(r)
+	if err != nil {
+		return errors.Wrapf(err, "failed sending request to vault")
+	}
+	defer resp.Body.Close()
+
+	envelope := &struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}{}
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed reading response from vault")
+	}
+	if len(raw) != 0 {
+		if err := json.Unmarshal(raw, envelope); err != nil {
+			return errors.Wrapf(err, "invalid response from vault, status [%d]", resp.StatusCode)
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("vault returned status [%d]: [%s]", resp.StatusCode, strings.Join(envelope.Errors, ", "))
+	}
+	if res == nil {
+		return nil
+	}
+	if len(envelope.Data) == 0 {
+		return errors.New("vault returned no data")
+	}
+	if err := json.Unmarshal(envelope.Data, res); err != nil {
+		return errors.Wrap(err, "invalid data returned by vault")
+	}
+	return nil
+}
+
+func init() {
+	kms.RegisterKeyManager("vault", &KeyManagerProvider{})
+}
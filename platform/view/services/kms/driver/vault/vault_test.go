@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type config map[string]string
+
+func (c config) GetString(key string) string { return c[key] }
+
+func (c config) GetDuration(key string) time.Duration {
+	d, _ := time.ParseDuration(c[key])
+	return d
+}
+
+func (c config) GetPath(key string) string { return c[key] }
+
+func (c config) IsSet(key string) bool {
+	_, ok := c[key]
+	return ok
+}
+
+// transit emulates the transit secrets engine mounted at /v1/keys, the ciphertexts are the reversed plaintexts
+func transit(t *testing.T) *httptest.Server {
+	reply := func(w http.ResponseWriter, status int, data interface{}, errs ...string) {
+		w.WriteHeader(status)
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"data": data, "errors": errs}))
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			reply(w, http.StatusForbidden, nil, "permission denied")
+			return
+		}
+		req := map[string]string{}
+		if r.Method == http.MethodPost {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		}
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			reply(w, http.StatusOK, map[string]string{"id": "s.token"})
+		case "/v1/keys/encrypt/node":
+			plaintext, err := base64.StdEncoding.DecodeString(req["plaintext"])
+			assert.NoError(t, err)
+			reply(w, http.StatusOK, map[string]string{"ciphertext": "vault:v1:" + reverse(string(plaintext))})
+		case "/v1/keys/decrypt/node":
+			plaintext := reverse(strings.TrimPrefix(req["ciphertext"], "vault:v1:"))
+			reply(w, http.StatusOK, map[string]string{"plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext))})
+		case "/v1/keys/sign/node":
+			assert.Equal(t, "asn1", req["marshaling_algorithm"])
+			reply(w, http.StatusOK, map[string]string{"signature": "vault:v1:" + base64.StdEncoding.EncodeToString([]byte("sig:"+req["input"]))})
+		default:
+			reply(w, http.StatusBadRequest, nil, "unsupported path")
+		}
+	}))
+}
+
+func reverse(s string) string {
+	r := []byte(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func TestKeyManager(t *testing.T) {
+	server := transit(t)
+	defer server.Close()
+	p := &KeyManagerProvider{}
+
+	_, err := p.New(config{"fsc.keys.vault.token": "s.token"})
+	assert.Error(t, err)
+	_, err = p.New(config{"fsc.keys.vault.address": server.URL, "fsc.keys.vault.token": "s.wrong", "fsc.keys.vault.mount": "keys"})
+	assert.EqualError(t, err, "vault at ["+server.URL+"] not reachable: vault returned status [403]: [permission denied]")
+
+	km, err := p.New(config{"fsc.keys.vault.address": server.URL, "fsc.keys.vault.token": "s.token", "fsc.keys.vault.mount": "/keys/"})
+	assert.NoError(t, err)
+
+	ciphertext, err := km.Encrypt("node", []byte("secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, "vault:v1:terces", string(ciphertext))
+	plaintext, err := km.Decrypt("node", ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+
+	sig, err := km.Sign("node", []byte("msg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "sig:"+base64.StdEncoding.EncodeToString([]byte("msg")), string(sig))
+
+	_, err = km.Encrypt("other", []byte("secret"))
+	assert.EqualError(t, err, "failed encrypting with key [other]: vault returned status [400]: [unsupported path]")
+}
+
+func TestKeyManagerUnreachable(t *testing.T) {
+	server := transit(t)
+	server.Close()
+
+	_, err := (&KeyManagerProvider{}).New(config{"fsc.keys.vault.address": server.URL, "fsc.keys.vault.token": "s.token", "fsc.keys.vault.timeout": "100ms"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "vault at ["+server.URL+"] not reachable")
+}
@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kms
+
+import (
+	"encoding/pem"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// WrappedKeyPEMType is the type of the PEM blocks holding a key wrapped by a key manager
+	WrappedKeyPEMType = "WRAPPED PRIVATE KEY"
+	// KeyIDPEMHeader is the header of a wrapped key with the id of the key that wraps it
+	KeyIDPEMHeader = "Key-Id"
+
+	// DefaultKeyManagerRetryInterval is the time between two attempts to reach the key manager at startup
+	DefaultKeyManagerRetryInterval = time.Second
+)
+
+var (
+	logger = flogging.MustGetLogger("view-sdk.kms")
+
+	keyManagersMu sync.RWMutex
+	keyManagers   = make(map[string]driver.KeyManagerProvider)
+	keyManager    = &KeyManager{}
+)
+
+// RegisterKeyManager makes a key manager provider available by the provided name.
+// If RegisterKeyManager is called twice with the same name or if provider is nil,
+// it panics.
+func RegisterKeyManager(name string, provider driver.KeyManagerProvider) {
+	keyManagersMu.Lock()
+	defer keyManagersMu.Unlock()
+	if provider == nil {
+		panic("RegisterKeyManager provider is nil")
+	}
+	if _, dup := keyManagers[name]; dup {
+		panic("RegisterKeyManager called twice for provider " + name)
+	}
+	keyManagers[name] = provider
+}
+
+// KeyManagers returns a sorted list of the names of the registered key manager providers.
+func KeyManagers() []string {
+	keyManagersMu.RLock()
+	defer keyManagersMu.RUnlock()
+	list := make([]string, 0, len(keyManagers))
+	for name := range keyManagers {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// KeyManager is the key manager configured for the node
+type KeyManager struct {
+	driver.KeyManager
+	// Provider is the name of the provider of the key manager
+	Provider string
+}
+
+// NewKeyManager returns the key manager set by `fsc.keys.provider`, nil if none is set.
+// The key manager must be reachable within `fsc.keys.grace`, it is attempted every `fsc.keys.retryInterval`.
+// Without a grace period, it is attempted once.
+func NewKeyManager(cp driver.KeyManagerConfigProvider) (*KeyManager, error) {
+	name := cp.GetString("fsc.keys.provider")
+	if len(name) == 0 {
+		return nil, nil
+	}
+	keyManagersMu.RLock()
+	provider, ok := keyManagers[name]
+	keyManagersMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("no key manager found with name [%s], available [%v]", name, KeyManagers())
+	}
+
+	grace := cp.GetDuration("fsc.keys.grace")
+	retryInterval := DefaultKeyManagerRetryInterval
+	if cp.IsSet("fsc.keys.retryInterval") {
+		retryInterval = cp.GetDuration("fsc.keys.retryInterval")
+	}
+	deadline := time.Now().Add(grace)
+	for attempt := 1; ; attempt++ {
+		km, err := provider.New(cp)
+		if err == nil {
+			logger.Infof("key manager [%s] ready", name)
+			return &KeyManager{KeyManager: km, Provider: name}, nil
+		}
+		if !time.Now().Add(retryInterval).Before(deadline) {
+			return nil, errors.WithMessagef(err, "key manager [%s] not reachable after [%d] attempts in [%s]", name, attempt, grace)
+		}
+		logger.Warnf("key manager [%s] not reachable, retry in [%s]: [%s]", name, retryInterval, err)
+		time.Sleep(retryInterval)
+	}
+}
+
+// GetKeyManager returns the key manager registered in the passed context, nil if there is none
+func GetKeyManager(sp view.ServiceProvider) *KeyManager {
+	if sp == nil {
+		return nil
+	}
+	s, err := sp.GetService(keyManager)
+	if err != nil {
+		return nil
+	}
+	return s.(*KeyManager)
+}
+
+// WrapKey returns a PEM block of type WrappedKeyPEMType with the passed key wrapped by the key of the passed id
+func WrapKey(km driver.KeyManager, keyID string, raw []byte) ([]byte, error) {
+	ciphertext, err := km.Encrypt(keyID, raw)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed wrapping key with [%s]", keyID)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    WrappedKeyPEMType,
+		Headers: map[string]string{KeyIDPEMHeader: keyID},
+		Bytes:   ciphertext,
+	}), nil
+}
+
+// UnwrapKey returns the key wrapped in the passed PEM block by WrapKey.
+// The keys that are not wrapped are returned as they are.
+func UnwrapKey(km driver.KeyManager, raw []byte) ([]byte, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != WrappedKeyPEMType {
+		return raw, nil
+	}
+	if km == nil {
+		return nil, errors.New("key is wrapped but no key manager is configured")
+	}
+	keyID, ok := block.Headers[KeyIDPEMHeader]
+	if !ok {
+		return nil, errors.Errorf("wrapped key without [%s] header", KeyIDPEMHeader)
+	}
+	key, err := km.Decrypt(keyID, block.Bytes)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed unwrapping key with [%s]", keyID)
+	}
+	return key, nil
+}
+
+// IsWrappedKey returns true if the passed raw is a PEM block returned by WrapKey
+func IsWrappedKey(raw []byte) bool {
+	block, _ := pem.Decode(raw)
+	return block != nil && block.Type == WrappedKeyPEMType
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 	"github.com/pkg/errors"
 )
 
@@ -48,6 +49,21 @@ func Drivers() []string {
 
 type KMS struct {
 	driver.Driver
+	// KeyManager unwraps the key of the identity of the node, nil if the key is not wrapped
+	KeyManager *KeyManager
+}
+
+// Load returns the signer, verifier and signing identity of the node.
+// If a key manager is set, the driver must be able to unwrap the key with it.
+func (k *KMS) Load(configProvider driver.ConfigProvider) (view.Identity, driver.Signer, driver.Verifier, error) {
+	if k.KeyManager == nil {
+		return k.Driver.Load(configProvider)
+	}
+	d, ok := k.Driver.(driver.KeyManagerDriver)
+	if !ok {
+		return nil, nil, nil, errors.Errorf("kms driver [%T] does not support key manager [%s]", k.Driver, k.KeyManager.Provider)
+	}
+	return d.LoadWithKeyManager(configProvider, k.KeyManager)
 }
 
 // Get returns the registered drivers.
@@ -0,0 +1,206 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvs
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// batchWrite is a write collected by a batch, the raw value is nil for a deletion
+type batchWrite struct {
+	id     string
+	raw    []byte
+	stored []byte
+}
+
+// Batch collects writes to the KVS, committed atomically in a single transaction of the underlying store.
+// The writes are visible to the reads of the batch only until committed, then to all.
+// A batch is used by a single unit of work, it is discarded if not committed.
+type Batch struct {
+	kvs *KVS
+
+	lock   sync.Mutex
+	writes []*batchWrite
+	index  map[string]*batchWrite
+	done   bool
+	// held are the lockers held until the batch is committed or discarded
+	held []sync.Locker
+}
+
+// NewBatch returns a new empty batch of writes to this KVS
+func (o *KVS) NewBatch() *Batch {
+	return &Batch{kvs: o, index: map[string]*batchWrite{}}
+}
+
+// Put records the passed state under the passed id, it replaces the writes of the id recorded before
+func (b *Batch) Put(id string, state interface{}) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal state with id [%s]", id)
+	}
+	stored := raw
+	if b.kvs.cipher != nil {
+		stored, err = b.kvs.cipher.seal(id, raw)
+		if err != nil {
+			return errors.WithMessagef(err, "cannot encrypt state with id [%s]", id)
+		}
+	}
+	return b.record(&batchWrite{id: id, raw: raw, stored: stored})
+}
+
+// Delete records the deletion of the passed id
+func (b *Batch) Delete(id string) error {
+	return b.record(&batchWrite{id: id})
+}
+
+func (b *Batch) record(w *batchWrite) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.done {
+		return errors.Errorf("batch already committed or discarded, cannot write [%s]", w.id)
+	}
+	if prev, ok := b.index[w.id]; ok {
+		*prev = *w
+		return nil
+	}
+	b.writes = append(b.writes, w)
+	b.index[w.id] = w
+	return nil
+}
+
+// Hold locks the passed locker, if not held already, and keeps it locked until this batch is committed or discarded.
+// The stores enlisted in the batch hold the lock of their read-modify-write updates, so that a concurrent update
+// written directly is not overwritten by the commit of the batch. It does nothing once committed or discarded.
+func (b *Batch) Hold(l sync.Locker) {
+	b.lock.Lock()
+	for _, held := range b.held {
+		if held == l {
+			b.lock.Unlock()
+			return
+		}
+	}
+	b.lock.Unlock()
+
+	l.Lock()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.done {
+		l.Unlock()
+		return
+	}
+	b.held = append(b.held, l)
+}
+
+// release unlocks the lockers held, the lock of the batch is held by the caller
+func (b *Batch) release() {
+	for _, l := range b.held {
+		l.Unlock()
+	}
+	b.held = nil
+}
+
+// Get unmarshals into the passed state the value of the passed id, as written by this batch if it did
+func (b *Batch) Get(id string, state interface{}) error {
+	b.lock.Lock()
+	w, ok := b.index[id]
+	b.lock.Unlock()
+	if !ok {
+		return b.kvs.Get(id, state)
+	}
+	if w.raw == nil {
+		return errors.Errorf("state [%s,%s] does not exist", b.kvs.namespace, id)
+	}
+	if err := json.Unmarshal(w.raw, state); err != nil {
+		return errors.Wrapf(err, "failed retrieving state [%s,%s], cannot unmarshal state", b.kvs.namespace, id)
+	}
+	return nil
+}
+
+// Exists returns true if the passed id has a value, as written by this batch if it did
+func (b *Batch) Exists(id string) bool {
+	b.lock.Lock()
+	w, ok := b.index[id]
+	b.lock.Unlock()
+	if !ok {
+		return b.kvs.Exists(id)
+	}
+	return len(w.raw) != 0
+}
+
+// Len returns the number of ids written by this batch
+func (b *Batch) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.writes)
+}
+
+// Commit applies all the writes of this batch in a single transaction of the store: either all of them are
+// persisted or none. The lockers held are unlocked afterwards, and the batch cannot be used anymore.
+func (b *Batch) Commit() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.done {
+		return errors.New("batch already committed or discarded")
+	}
+	b.done = true
+	defer b.release()
+	if len(b.writes) == 0 {
+		return nil
+	}
+
+	o := b.kvs
+	o.putMutex.Lock()
+	defer o.putMutex.Unlock()
+
+	if err := o.store.BeginUpdate(); err != nil {
+		return errors.WithMessagef(err, "begin update of batch of [%d] writes failed", len(b.writes))
+	}
+	for _, w := range b.writes {
+		var err error
+		if w.raw == nil {
+			err = o.store.DeleteState(o.namespace, w.id)
+		} else {
+			err = o.store.SetState(o.namespace, w.id, w.stored)
+		}
+		if err != nil {
+			if err1 := o.store.Discard(); err1 != nil {
+				logger.Debugf("got error %s; discarding caused %s", err.Error(), err1.Error())
+			}
+			return errors.WithMessagef(err, "failed writing [%s], batch of [%d] writes discarded", w.id, len(b.writes))
+		}
+	}
+	if err := o.store.Commit(); err != nil {
+		// the cached values of the ids written might be stale, since the store might have applied the writes
+		for _, w := range b.writes {
+			o.cache.Delete(w.id)
+		}
+		return errors.WithMessagef(err, "committing batch of [%d] writes failed", len(b.writes))
+	}
+
+	for _, w := range b.writes {
+		if w.raw == nil {
+			o.cache.Delete(w.id)
+			continue
+		}
+		o.cache.Add(w.id, w.raw)
+	}
+	return nil
+}
+
+// Discard drops the writes of this batch, nothing is persisted, and unlocks the lockers held.
+// It does nothing if the batch has been committed.
+func (b *Batch) Discard() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.done = true
+	b.writes = nil
+	b.index = map[string]*batchWrite{}
+	b.release()
+}
@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvs_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// crash fails the writes of the store at the configured point, as a crash of the node would interrupt them
+var crash = &crashPoint{}
+
+type crashPoint struct {
+	// setState is the number of the SetState failing, 0 for none
+	setState int
+	// commit fails the commit, the writes are not persisted
+	commit bool
+	calls  int
+}
+
+type crashingDriver struct {
+	badger.Driver
+}
+
+func (d *crashingDriver) New(sp view.ServiceProvider, dataSourceName string, config driver.Config) (driver.Persistence, error) {
+	p, err := d.Driver.New(sp, dataSourceName, config)
+	if err != nil {
+		return nil, err
+	}
+	return &crashingPersistence{Persistence: p}, nil
+}
+
+type crashingPersistence struct {
+	driver.Persistence
+}
+
+func (p *crashingPersistence) SetState(namespace, key string, value []byte) error {
+	crash.calls++
+	if crash.setState == crash.calls {
+		return errors.New("crashed")
+	}
+	return p.Persistence.SetState(namespace, key, value)
+}
+
+func (p *crashingPersistence) Commit() error {
+	if crash.commit {
+		// the writes are never persisted
+		if err := p.Persistence.Discard(); err != nil {
+			return err
+		}
+		return errors.New("crashed")
+	}
+	return p.Persistence.Commit()
+}
+
+func init() {
+	db.Register("crashing", &crashingDriver{})
+}
+
+func TestBatch(t *testing.T) {
+	path := t.TempDir()
+	cp := &mock.ConfigProvider{}
+	cp.UnmarshalKeyStub = func(s string, i interface{}) error {
+		if v, ok := i.(*badger.Opts); ok {
+			*v = badger.Opts{Path: path}
+		}
+		return nil
+	}
+	registry := registry2.New()
+	kvstore, err := kvs.NewWithConfig(registry, "crashing", "_default", cp)
+	assert.NoError(t, err)
+
+	assert.NoError(t, kvstore.Put("k0", &stuff{"old", 0}))
+
+	// the writes are visible to the batch only until committed
+	batch := kvstore.NewBatch()
+	assert.NoError(t, batch.Put("k1", &stuff{"santa", 1}))
+	assert.NoError(t, batch.Put("k2", &stuff{"claws", 2}))
+	assert.NoError(t, batch.Delete("k0"))
+	assert.NoError(t, batch.Put("k1", &stuff{"santa", 11}))
+	assert.Equal(t, 3, batch.Len())
+	val := &stuff{}
+	assert.NoError(t, batch.Get("k1", val))
+	assert.Equal(t, &stuff{"santa", 11}, val)
+	assert.False(t, batch.Exists("k0"))
+	assert.True(t, kvstore.Exists("k0"))
+	assert.False(t, kvstore.Exists("k1"))
+	assert.NoError(t, batch.Commit())
+	assert.Error(t, batch.Put("k3", &stuff{}))
+	assert.Error(t, batch.Commit())
+
+	assert.False(t, kvstore.Exists("k0"))
+	assert.NoError(t, kvstore.Get("k1", val))
+	assert.Equal(t, &stuff{"santa", 11}, val)
+
+	// a crash in the middle of the writes or at commit leaves none of them, also after a restart
+	for _, point := range []crashPoint{{setState: 2}, {setState: 3}, {commit: true}} {
+		*crash = point
+		batch := kvstore.NewBatch()
+		assert.NoError(t, batch.Put("k1", &stuff{"santa", 111}))
+		assert.NoError(t, batch.Put("k2", &stuff{"claws", 222}))
+		assert.NoError(t, batch.Put("k3", &stuff{"rudolph", 3}))
+		assert.Error(t, batch.Commit())
+		*crash = crashPoint{}
+
+		for _, store := range []*kvs.KVS{kvstore, nil} {
+			if store == nil {
+				kvstore.Stop()
+				kvstore, err = kvs.NewWithConfig(registry, "crashing", "_default", cp)
+				assert.NoError(t, err)
+				store = kvstore
+			}
+			assert.NoError(t, store.Get("k1", val))
+			assert.Equal(t, &stuff{"santa", 11}, val)
+			assert.NoError(t, store.Get("k2", val))
+			assert.Equal(t, &stuff{"claws", 2}, val)
+			assert.False(t, store.Exists("k3"))
+		}
+	}
+
+	// a discarded batch writes nothing
+	batch = kvstore.NewBatch()
+	assert.NoError(t, batch.Put("k3", &stuff{"rudolph", 3}))
+	batch.Discard()
+	assert.Error(t, batch.Commit())
+	assert.False(t, kvstore.Exists("k3"))
+	kvstore.Stop()
+}
@@ -0,0 +1,109 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
+	kmsdriver "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// keysNamespaceSuffix suffixes the namespace where a KVS stores its wrapped data encryption key
+	keysNamespaceSuffix = "$keys"
+	dataKeyID           = "dek"
+	dataKeySize         = 32
+	// encryptedValuePrefix marks the encrypted values. The values stored before enabling the encryption
+	// are JSON documents, they never start with it and they are still readable.
+	encryptedValuePrefix byte = 0x01
+)
+
+// valueCipher encrypts the values of a KVS with a data encryption key wrapped by the key manager.
+// The key manager is called once, when the KVS is opened.
+type valueCipher struct {
+	aead cipher.AEAD
+}
+
+// newValueCipher unwraps the data encryption key of the passed namespace, or it generates and stores one if there is none
+func newValueCipher(store driver.Persistence, namespace string, km kmsdriver.KeyManager, keyID string) (*valueCipher, error) {
+	keysNamespace := namespace + keysNamespaceSuffix
+	wrapped, err := store.GetState(keysNamespace, dataKeyID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed reading data encryption key of [%s]", namespace)
+	}
+
+	var dek []byte
+	if len(wrapped) == 0 {
+		logger.Infof("generating data encryption key of kvs [%s]", namespace)
+		dek = make([]byte, dataKeySize)
+		if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+			return nil, errors.Wrap(err, "failed generating data encryption key")
+		}
+		wrapped, err = km.Encrypt(keyID, dek)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed wrapping data encryption key of [%s]", namespace)
+		}
+		if err := store.BeginUpdate(); err != nil {
+			return nil, errors.WithMessagef(err, "begin update for data encryption key failed")
+		}
+		if err := store.SetState(keysNamespace, dataKeyID, wrapped); err != nil {
+			if err1 := store.Discard(); err1 != nil {
+				logger.Debugf("got error %s; discarding caused %s", err.Error(), err1.Error())
+			}
+			return nil, errors.WithMessagef(err, "failed storing data encryption key of [%s]", namespace)
+		}
+		if err := store.Commit(); err != nil {
+			return nil, errors.WithMessagef(err, "committing data encryption key failed")
+		}
+	} else {
+		dek, err = km.Decrypt(keyID, wrapped)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed unwrapping data encryption key of [%s]", namespace)
+		}
+	}
+
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data encryption key")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating cipher")
+	}
+	return &valueCipher{aead: aead}, nil
+}
+
+// seal encrypts the value of the passed id, the id is authenticated with it
+func (c *valueCipher) seal(id string, raw []byte) ([]byte, error) {
+	out := make([]byte, 1+c.aead.NonceSize(), 1+c.aead.NonceSize()+len(raw)+c.aead.Overhead())
+	out[0] = encryptedValuePrefix
+	if _, err := io.ReadFull(rand.Reader, out[1:]); err != nil {
+		return nil, errors.Wrap(err, "failed generating nonce")
+	}
+	return c.aead.Seal(out, out[1:], raw, []byte(id)), nil
+}
+
+// open decrypts the value of the passed id, the values that are not encrypted are returned as they are
+func (c *valueCipher) open(id string, raw []byte) ([]byte, error) {
+	if len(raw) == 0 || raw[0] != encryptedValuePrefix {
+		return raw, nil
+	}
+	if len(raw) < 1+c.aead.NonceSize() {
+		return nil, errors.Errorf("encrypted value of [%s] too short", id)
+	}
+	nonce := raw[1 : 1+c.aead.NonceSize()]
+	plaintext, err := c.aead.Open(nil, nonce, raw[1+c.aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed decrypting value of [%s]", id)
+	}
+	return plaintext, nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
 	"github.com/pkg/errors"
 	"go.uber.org/zap/zapcore"
 )
@@ -31,6 +32,10 @@ const (
 	defaultCacheSize         = 100
 )
 
+// encryptionKeyIDConfigKey is the id of the key, held by the key manager of the node, that wraps the data encryption key.
+// If set, the values are encrypted.
+const encryptionKeyIDConfigKey = "fsc.kvs.encryption.keyId"
+
 type cache interface {
 	Get(key string) (interface{}, bool)
 	Add(key string, value interface{})
@@ -61,6 +66,9 @@ type KVS struct {
 
 	putMutex sync.RWMutex
 	cache    cache
+
+	// cipher encrypts the values in the store, nil if they are stored in clear
+	cipher *valueCipher
 }
 
 // New returns a new KVS instance for the passed namespace using the passed driver
@@ -82,10 +90,27 @@ func NewWithConfig(sp view.ServiceProvider, driverName, namespace string, cp Con
 
 	logger.Debugf("opening kvs with namespace=`%s` and cacheSize=`%d`", namespace, cacheSize)
 
+	var valueCipher *valueCipher
+	if cp.IsSet(encryptionKeyIDConfigKey) {
+		var keyID string
+		if err := cp.UnmarshalKey(encryptionKeyIDConfigKey, &keyID); err != nil {
+			return nil, errors.Wrapf(err, "failed loading encryption key id from configuration")
+		}
+		km := kms.GetKeyManager(sp)
+		if km == nil {
+			return nil, errors.Errorf("kvs encryption requires a key manager, none is configured at [fsc.keys.provider]")
+		}
+		valueCipher, err = newValueCipher(persistence, namespace, km, keyID)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed initializing encryption of kvs [%s]", namespace)
+		}
+	}
+
 	return &KVS{
 		namespace: namespace,
 		store:     persistence,
 		cache:     secondcache.New(cacheSize),
+		cipher:    valueCipher,
 	}, nil
 }
 
@@ -120,6 +145,12 @@ func (o *KVS) Exists(id string) bool {
 		o.cache.Delete(id)
 		return false
 	}
+	raw, err = o.open(id, raw)
+	if err != nil {
+		logger.Errorf("failed decrypting state [%s,%s]: [%s]", o.namespace, id, err)
+		o.cache.Delete(id)
+		return false
+	}
 	o.cache.Add(id, raw)
 	if logger.IsEnabledFor(zapcore.DebugLevel) {
 		logger.Debugf("state [%s,%s] exists [%v]", o.namespace, id, len(raw) != 0)
@@ -137,13 +168,21 @@ func (o *KVS) Put(id string, state interface{}) error {
 		return errors.Wrapf(err, "cannot marshal state with id [%s]", id)
 	}
 
+	stored := raw
+	if o.cipher != nil {
+		stored, err = o.cipher.seal(id, raw)
+		if err != nil {
+			return errors.WithMessagef(err, "cannot encrypt state with id [%s]", id)
+		}
+	}
+
 	err = o.store.BeginUpdate()
 	if err != nil {
 		return errors.WithMessagef(err, "begin update for id [%s] failed", id)
 	}
 
-	logger.Debugf("store [%d] bytes into key [%s:%s]", len(raw), o.namespace, id)
-	err = o.store.SetState(o.namespace, id, raw)
+	logger.Debugf("store [%d] bytes into key [%s:%s]", len(stored), o.namespace, id)
+	err = o.store.SetState(o.namespace, id, stored)
 	if err != nil {
 		if err1 := o.store.Discard(); err1 != nil {
 			if logger.IsEnabledFor(zapcore.DebugLevel) {
@@ -184,6 +223,10 @@ func (o *KVS) Get(id string, state interface{}) error {
 		if len(raw) == 0 {
 			return errors.Errorf("state [%s,%s] does not exist", o.namespace, id)
 		}
+		raw, err = o.open(id, raw)
+		if err != nil {
+			return errors.WithMessagef(err, "failed retrieving state [%s,%s]", o.namespace, id)
+		}
 	}
 
 	if err := json.Unmarshal(raw, state); err != nil {
@@ -247,7 +290,15 @@ func (o *KVS) GetByPartialCompositeID(prefix string, attrs []string) (Iterator,
 		return nil, errors.Errorf("store access failure for GetStateRangeScanIterator [%s], ns [%s] range [%s,%s]", err, o.namespace, startKey, endKey)
 	}
 
-	return &it{ri: itr}, nil
+	return &it{ri: itr, kvs: o}, nil
+}
+
+// open decrypts the passed value of the passed id, if the values are encrypted
+func (o *KVS) open(id string, raw []byte) ([]byte, error) {
+	if o.cipher == nil {
+		return raw, nil
+	}
+	return o.cipher.open(id, raw)
 }
 
 func (o *KVS) Stop() {
@@ -259,6 +310,7 @@ func (o *KVS) Stop() {
 type it struct {
 	ri   driver.ResultsIterator
 	next *driver.Read
+	kvs  *KVS
 }
 
 func (i *it) HasNext() bool {
@@ -278,7 +330,11 @@ func (i *it) Close() error {
 // Next unmarshals the current state into the given state object.
 // It also returns the key of the current state.
 func (i *it) Next(state interface{}) (string, error) {
-	return i.next.Key, json.Unmarshal(i.next.Raw, state)
+	raw, err := i.kvs.open(i.next.Key, i.next.Raw)
+	if err != nil {
+		return i.next.Key, err
+	}
+	return i.next.Key, json.Unmarshal(raw, state)
 }
 
 // GetService returns the KVS instance registered in the passed context.
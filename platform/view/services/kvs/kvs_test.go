@@ -11,10 +11,13 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger"
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/badger"
 	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kms/driver/file"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
 	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
@@ -126,3 +129,76 @@ func TestMemoryKVS(t *testing.T) {
 	cp := &mock.ConfigProvider{}
 	testRound(t, "memory", cp)
 }
+
+func TestEncryptedKVS(t *testing.T) {
+	path, err := ioutil.TempDir(os.TempDir(), "kvstest-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	km, err := file.NewKeyManager([]byte("0123456789abcdef0123456789abcdef"))
+	assert.NoError(t, err)
+	registry := registry2.New()
+	assert.NoError(t, registry.RegisterService(&kms.KeyManager{KeyManager: km, Provider: "file"}))
+
+	cp := &mock.ConfigProvider{}
+	cp.UnmarshalKeyStub = func(s string, i interface{}) error {
+		switch v := i.(type) {
+		case *badger.Opts:
+			*v = badger.Opts{Path: path}
+		case *string:
+			*v = "kvs"
+		}
+		return nil
+	}
+	plain := &mock.ConfigProvider{}
+	plain.UnmarshalKeyStub = cp.UnmarshalKeyStub
+
+	// a value stored before enabling the encryption
+	kvstore, err := kvs.NewWithConfig(registry, "badger", "_default", plain)
+	assert.NoError(t, err)
+	k0, err := kvs.CreateCompositeKey("k", []string{"0"})
+	assert.NoError(t, err)
+	assert.NoError(t, kvstore.Put(k0, &stuff{"clear", 0}))
+	kvstore.Stop()
+
+	cp.IsSetStub = func(key string) bool { return key == "fsc.kvs.encryption.keyId" }
+	kvstore, err = kvs.NewWithConfig(registry, "badger", "_default", cp)
+	assert.NoError(t, err)
+	k1, err := kvs.CreateCompositeKey("k", []string{"1"})
+	assert.NoError(t, err)
+	assert.NoError(t, kvstore.Put(k1, &stuff{"santa", 1}))
+	kvstore.Stop()
+
+	// the store holds the ciphertext
+	store, err := db.Open(registry, "badger", "_default", db.NewPrefixConfig(plain, "fsc.kvs.persistence.opts"))
+	assert.NoError(t, err)
+	raw, err := store.GetState("_default", k1)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, raw)
+	assert.NotContains(t, string(raw), "santa")
+	assert.NoError(t, store.Close())
+
+	// the data encryption key is unwrapped at the next opening, and both values are readable
+	kvstore, err = kvs.NewWithConfig(registry, "badger", "_default", cp)
+	assert.NoError(t, err)
+	val := &stuff{}
+	assert.NoError(t, kvstore.Get(k1, val))
+	assert.Equal(t, &stuff{"santa", 1}, val)
+	assert.True(t, kvstore.Exists(k1))
+	it, err := kvstore.GetByPartialCompositeID("k", []string{})
+	assert.NoError(t, err)
+	var vals []stuff
+	for it.HasNext() {
+		val := stuff{}
+		_, err := it.Next(&val)
+		assert.NoError(t, err)
+		vals = append(vals, val)
+	}
+	assert.NoError(t, it.Close())
+	assert.Equal(t, []stuff{{"clear", 0}, {"santa", 1}}, vals)
+	kvstore.Stop()
+
+	// the encryption requires a key manager
+	_, err = kvs.NewWithConfig(registry2.New(), "memory", "_default", cp)
+	assert.Error(t, err)
+}
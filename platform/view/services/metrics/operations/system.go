@@ -11,10 +11,11 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	kitstatsd "github.com/go-kit/kit/metrics/statsd"
-	"github.com/hyperledger-labs/fabric-smart-client/platform/view/sdk/metadata"
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging/httpadmin"
 	"github.com/hyperledger/fabric-lib-go/healthz"
@@ -67,6 +68,8 @@ type System struct {
 
 	logger          Logger
 	healthHandler   *healthz.HealthHandler
+	checkersLock    sync.RWMutex
+	checkers        map[string]healthz.HealthChecker
 	options         Options
 	statsd          *kitstatsd.Statsd
 	collectorTicker *time.Ticker
@@ -81,9 +84,10 @@ func NewSystem(server Server, o Options) *System {
 	}
 
 	system := &System{
-		Server:  server,
-		logger:  logger,
-		options: o,
+		Server:   server,
+		logger:   logger,
+		options:  o,
+		checkers: map[string]healthz.HealthChecker{},
 	}
 
 	system.initializeHealthCheckHandler()
@@ -117,7 +121,24 @@ func (s *System) Stop() error {
 }
 
 func (s *System) RegisterChecker(component string, checker healthz.HealthChecker) error {
-	return s.healthHandler.RegisterChecker(component, checker)
+	if err := s.healthHandler.RegisterChecker(component, checker); err != nil {
+		return err
+	}
+	s.checkersLock.Lock()
+	defer s.checkersLock.Unlock()
+	s.checkers[component] = checker
+	return nil
+}
+
+// HealthCheckers returns the checkers registered so far, indexed by component
+func (s *System) HealthCheckers() map[string]healthz.HealthChecker {
+	s.checkersLock.RLock()
+	defer s.checkersLock.RUnlock()
+	res := make(map[string]healthz.HealthChecker, len(s.checkers))
+	for component, checker := range s.checkers {
+		res[component] = checker
+	}
+	return res
 }
 
 func (s *System) Log(keyvals ...interface{}) error {
@@ -206,13 +227,17 @@ func (s *System) initializeHealthCheckHandler() {
 }
 
 func (s *System) initializeVersionInfoHandler() {
+	info := version.Get()
 	versionInfo := &VersionInfoHandler{
-		CommitSHA: metadata.CommitSHA,
-		Version:   metadata.Version,
+		CommitSHA: info.CommitSHA,
+		Version:   info.Version,
+		BuildDate: info.BuildDate,
+		GoVersion: info.GoVersion,
+		Platform:  info.Platform,
 	}
 	// swagger:operation GET /version operations version
 	// ---
-	// summary: Returns the orderer or peer version and the commit SHA on which the release was created.
+	// summary: Returns the version of the node, the commit SHA and the date of its build, and the go version and platform.
 	// responses:
 	//     '200':
 	//        description: Ok.
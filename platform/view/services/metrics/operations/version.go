@@ -17,6 +17,9 @@ import (
 type VersionInfoHandler struct {
 	CommitSHA string `json:"CommitSHA,omitempty"`
 	Version   string `json:"Version,omitempty"`
+	BuildDate string `json:"BuildDate,omitempty"`
+	GoVersion string `json:"GoVersion,omitempty"`
+	Platform  string `json:"Platform,omitempty"`
 }
 
 func (m *VersionInfoHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	"bytes"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// VerifierProvider returns the verifier of the signatures of an identity, like view.SigService
+type VerifierProvider interface {
+	GetVerifier(identity view.Identity) (view2.Verifier, error)
+}
+
+// Request is sent to the parties asked to sign the payload of a collection
+type Request struct {
+	// ID identifies the collection, it is part of the message signed
+	ID      string
+	Payload []byte
+}
+
+// Message returns the message the parties sign: the id of the collection followed by the payload,
+// so that a signature cannot be replayed in another collection
+func (r *Request) Message() []byte {
+	return Message(r.ID, r.Payload)
+}
+
+// Message returns the message signed by the parties of the passed collection over the passed payload
+func Message(id string, payload []byte) []byte {
+	return append(append([]byte(id), 0), payload...)
+}
+
+// Signature is the signature of a party over the payload of a collection
+type Signature struct {
+	// Party is the party asked to sign
+	Party view.Identity
+	// Signer is the identity the party signed with, its node identity
+	Signer    view.Identity
+	Signature []byte
+}
+
+// Failure tells why a party did not sign the payload of a collection
+type Failure struct {
+	Party  view.Identity
+	Reason string
+}
+
+// SignatureBundle collects the signatures of the parties over the payload of a collection.
+// A bundle is complete when each party has signed, the parties that did not are reported with the reason.
+type SignatureBundle struct {
+	ID         string
+	Payload    []byte
+	Parties    []view.Identity
+	Signatures []*Signature
+	// Failures are the parties that did not sign, the last time they have been asked
+	Failures []*Failure `json:",omitempty"`
+
+	verifiers VerifierProvider
+}
+
+// WithVerifierProvider sets the verifiers of the signatures of the bundle, like the ones of view.GetSigService.
+// The bundles returned by the collections have it set already.
+func (b *SignatureBundle) WithVerifierProvider(verifiers VerifierProvider) *SignatureBundle {
+	b.verifiers = verifiers
+	return b
+}
+
+// Signature returns the signature of the passed party, nil if the party has not signed
+func (b *SignatureBundle) Signature(party view.Identity) *Signature {
+	for _, s := range b.Signatures {
+		if s.Party.Equal(party) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Missing returns the parties that have not signed
+func (b *SignatureBundle) Missing() []view.Identity {
+	var missing []view.Identity
+	for _, party := range b.Parties {
+		if b.Signature(party) == nil {
+			missing = append(missing, party)
+		}
+	}
+	return missing
+}
+
+// Complete returns true if each party has signed
+func (b *SignatureBundle) Complete() bool {
+	return len(b.Missing()) == 0
+}
+
+// Verify verifies the signature of the passed party over the passed payload
+func (b *SignatureBundle) Verify(party view.Identity, payload []byte) error {
+	s := b.Signature(party)
+	if s == nil {
+		return errors.Errorf("party [%s] has not signed", party)
+	}
+	if b.verifiers == nil {
+		return errors.New("no verifier provider set")
+	}
+	verifier, err := b.verifiers.GetVerifier(s.Signer)
+	if err != nil {
+		return errors.WithMessagef(err, "failed getting verifier of [%s]", s.Signer)
+	}
+	if err := verifier.Verify(Message(b.ID, payload), s.Signature); err != nil {
+		return errors.WithMessagef(err, "invalid signature of party [%s]", party)
+	}
+	return nil
+}
+
+// VerifyAll verifies that the bundle is complete and that each party signed the passed payload
+func (b *SignatureBundle) VerifyAll(payload []byte) error {
+	if !bytes.Equal(payload, b.Payload) {
+		return errors.Errorf("collection [%s] is over a different payload", b.ID)
+	}
+	if missing := b.Missing(); len(missing) != 0 {
+		return errors.Errorf("collection [%s] is not complete, [%d] of [%d] parties have not signed", b.ID, len(missing), len(b.Parties))
+	}
+	for _, party := range b.Parties {
+		if err := b.Verify(party, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	session2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/session"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("view-sdk.multisig")
+
+// DefaultTimeout is the time a party has to sign, once asked
+const DefaultTimeout = time.Minute
+
+// response is the answer of a party that signed
+type response struct {
+	Signer    view.Identity
+	Signature []byte
+}
+
+type collectSignaturesView struct {
+	id      string
+	payload []byte
+	parties []view.Identity
+	timeout time.Duration
+}
+
+// NewCollectSignaturesView returns a view asking, in parallel, the passed parties to sign the passed payload,
+// each with its node identity. The parties run NewSignView in the responder of the initiator of the context.
+// The view returns a *SignatureBundle, complete or not: the parties that did not sign are reported in its failures.
+// The progress is stored under the passed id: running again a collection with the same id, for example after
+// a restart, asks only the parties that have not signed yet. The progress of a complete collection is dropped.
+func NewCollectSignaturesView(id string, payload []byte, parties ...view.Identity) *collectSignaturesView {
+	return &collectSignaturesView{id: id, payload: payload, parties: parties, timeout: DefaultTimeout}
+}
+
+// WithTimeout sets the time each party has to sign, DefaultTimeout if not set
+func (c *collectSignaturesView) WithTimeout(timeout time.Duration) *collectSignaturesView {
+	c.timeout = timeout
+	return c
+}
+
+func (c *collectSignaturesView) Call(context view.Context) (interface{}, error) {
+	sigService := view2.GetSigService(context)
+	bundle, err := collect(&store{kvs: kvs.GetService(context)}, c.id, c.payload, c.parties, func(party view.Identity) (*Signature, error) {
+		if context.IsMe(party) {
+			return signLocally(sigService, party, &Request{ID: c.id, Payload: c.payload})
+		}
+		return c.ask(context, sigService, party)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundle.WithVerifierProvider(sigService), nil
+}
+
+// ask asks the passed party to sign, and verifies the signature received
+func (c *collectSignaturesView) ask(context view.Context, verifiers VerifierProvider, party view.Identity) (*Signature, error) {
+	s, err := session2.NewJSON(context, context.Initiator(), party)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed opening session to [%s]", party)
+	}
+	request := &Request{ID: c.id, Payload: c.payload}
+	if err := s.Send(request); err != nil {
+		return nil, errors.WithMessagef(err, "failed sending the payload to [%s]", party)
+	}
+	res := &response{}
+	if err := s.ReceiveWithTimeout(res, c.timeout); err != nil {
+		return nil, err
+	}
+	if !res.Signer.Equal(party) && !view2.GetEndpointService(context).IsBoundTo(res.Signer, party) {
+		return nil, errors.Errorf("signer [%s] is not bound to [%s]", res.Signer, party)
+	}
+	verifier, err := verifiers.GetVerifier(res.Signer)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting verifier of [%s]", res.Signer)
+	}
+	if err := verifier.Verify(request.Message(), res.Signature); err != nil {
+		return nil, errors.WithMessagef(err, "invalid signature from [%s]", party)
+	}
+	return &Signature{Party: party, Signer: res.Signer, Signature: res.Signature}, nil
+}
+
+// collect asks the parties that have not signed yet, in parallel, and stores the progress after each signature
+func collect(store *store, id string, payload []byte, parties []view.Identity, ask func(party view.Identity) (*Signature, error)) (*SignatureBundle, error) {
+	if len(id) == 0 {
+		return nil, errors.New("empty collection id")
+	}
+	if len(parties) == 0 {
+		return nil, errors.Errorf("no party to collect the signatures of collection [%s] from", id)
+	}
+	bundle, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		bundle = &SignatureBundle{ID: id, Payload: payload, Parties: parties}
+	} else if !bytes.Equal(bundle.Payload, payload) {
+		return nil, errors.Errorf("collection [%s] exists already with a different payload", id)
+	} else {
+		logger.Infof("resume collection [%s], [%d] signatures collected already", id, len(bundle.Signatures))
+		bundle.Parties = parties
+		bundle.Failures = nil
+	}
+
+	var lock sync.Mutex
+	var storeErr error
+	var wg sync.WaitGroup
+	for _, party := range bundle.Missing() {
+		wg.Add(1)
+		go func(party view.Identity) {
+			defer wg.Done()
+			signature, err := ask(party)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				logger.Warnf("party [%s] did not sign collection [%s]: [%s]", party, id, err)
+				bundle.Failures = append(bundle.Failures, &Failure{Party: party, Reason: err.Error()})
+				return
+			}
+			bundle.Signatures = append(bundle.Signatures, signature)
+			if err := store.Put(bundle); err != nil && storeErr == nil {
+				storeErr = err
+			}
+		}(party)
+	}
+	wg.Wait()
+	if storeErr != nil {
+		return nil, storeErr
+	}
+
+	if bundle.Complete() {
+		if err := store.Delete(id); err != nil {
+			logger.Warnf("failed dropping the progress of collection [%s]: [%s]", id, err)
+		}
+	}
+	logger.Debugf("collection [%s]: [%d] signatures, [%d] failures", id, len(bundle.Signatures), len(bundle.Failures))
+	return bundle, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	registry2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSigner signs by prefixing the message with its identity
+type fakeSigner struct {
+	identity view.Identity
+}
+
+func (s *fakeSigner) Sign(message []byte) ([]byte, error) {
+	return append(append([]byte{}, s.identity...), message...), nil
+}
+
+func (s *fakeSigner) Verify(message, signature []byte) error {
+	if !bytes.Equal(signature, append(append([]byte{}, s.identity...), message...)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+type fakeVerifiers struct{}
+
+func (f *fakeVerifiers) GetVerifier(identity view.Identity) (view2.Verifier, error) {
+	return &fakeSigner{identity: identity}, nil
+}
+
+// parties sign, except the ones refusing
+type parties struct {
+	lock    sync.Mutex
+	asked   []string
+	refused map[string]bool
+}
+
+func (p *parties) ask(id string, payload []byte) func(party view.Identity) (*Signature, error) {
+	return func(party view.Identity) (*Signature, error) {
+		p.lock.Lock()
+		p.asked = append(p.asked, string(party))
+		refused := p.refused[string(party)]
+		p.lock.Unlock()
+		if refused {
+			return nil, errors.New("refused")
+		}
+		raw, _ := (&fakeSigner{identity: party}).Sign(Message(id, payload))
+		return &Signature{Party: party, Signer: party, Signature: raw}, nil
+	}
+}
+
+func newStore(t *testing.T) *store {
+	kvss, err := kvs.NewWithConfig(registry2.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	return &store{kvs: kvss}
+}
+
+func TestCollect(t *testing.T) {
+	s := newStore(t)
+	signers := []view.Identity{view.Identity("alice"), view.Identity("bob"), view.Identity("charlie")}
+	payload := []byte("payload")
+	p := &parties{refused: map[string]bool{"charlie": true}}
+
+	// a party refuses, the others sign
+	bundle, err := collect(s, "c1", payload, signers, p.ask("c1", payload))
+	assert.NoError(t, err)
+	assert.False(t, bundle.Complete())
+	assert.Len(t, bundle.Signatures, 2)
+	assert.Equal(t, []*Failure{{Party: view.Identity("charlie"), Reason: "refused"}}, bundle.Failures)
+	assert.Equal(t, []view.Identity{view.Identity("charlie")}, bundle.Missing())
+	bundle.WithVerifierProvider(&fakeVerifiers{})
+	assert.NoError(t, bundle.Verify(view.Identity("alice"), payload))
+	assert.EqualError(t, bundle.VerifyAll(payload), "collection [c1] is not complete, [1] of [3] parties have not signed")
+
+	// the progress is stored, the same collection over another payload is refused
+	_, err = collect(s, "c1", []byte("other"), signers, p.ask("c1", payload))
+	assert.EqualError(t, err, "collection [c1] exists already with a different payload")
+
+	// resuming asks only the party that has not signed
+	p.asked = nil
+	p.refused = nil
+	bundle, err = collect(s, "c1", payload, signers, p.ask("c1", payload))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"charlie"}, p.asked)
+	assert.True(t, bundle.Complete())
+	assert.Empty(t, bundle.Failures)
+	bundle.WithVerifierProvider(&fakeVerifiers{})
+	assert.NoError(t, bundle.VerifyAll(payload))
+	assert.Error(t, bundle.VerifyAll([]byte("other")))
+
+	// the progress of a complete collection is dropped
+	stored, err := s.Get("c1")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestVerifyAll(t *testing.T) {
+	payload := []byte("payload")
+	signature, _ := (&fakeSigner{identity: view.Identity("alice")}).Sign(Message("c1", payload))
+	bundle := &SignatureBundle{
+		ID:         "c1",
+		Payload:    payload,
+		Parties:    []view.Identity{view.Identity("alice")},
+		Signatures: []*Signature{{Party: view.Identity("alice"), Signer: view.Identity("alice"), Signature: signature}},
+	}
+	assert.EqualError(t, bundle.VerifyAll(payload), "no verifier provider set")
+	bundle.WithVerifierProvider(&fakeVerifiers{})
+	assert.NoError(t, bundle.VerifyAll(payload))
+
+	// the signatures are bound to the collection
+	bundle.ID = "c2"
+	assert.Error(t, bundle.VerifyAll(payload))
+}
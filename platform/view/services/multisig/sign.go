@@ -0,0 +1,72 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	session2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/session"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+)
+
+// Approver decides whether the node signs the payload of a collection, by returning nil.
+// The error returned is sent back to the initiator as the reason of the refusal.
+type Approver func(context view.Context, request *Request) error
+
+type signView struct {
+	approver Approver
+}
+
+// NewSignView returns the view answering, in a responder, a request of NewCollectSignaturesView:
+// if the passed approver approves the payload, the payload is signed with the node identity.
+// A nil approver approves every payload. The view returns the *Request received.
+func NewSignView(approver Approver) *signView {
+	return &signView{approver: approver}
+}
+
+func (s *signView) Call(context view.Context) (interface{}, error) {
+	session := session2.JSON(context)
+	request := &Request{}
+	if err := session.Receive(request); err != nil {
+		return nil, errors.WithMessagef(err, "failed receiving the request")
+	}
+	if s.approver != nil {
+		if err := s.approver(context, request); err != nil {
+			logger.Infof("refused to sign collection [%s]: [%s]", request.ID, err)
+			if err1 := session.SendError("refused: " + err.Error()); err1 != nil {
+				logger.Errorf("failed sending the refusal of collection [%s]: [%s]", request.ID, err1)
+			}
+			return nil, errors.WithMessagef(err, "refused to sign collection [%s]", request.ID)
+		}
+	}
+
+	signature, err := signLocally(view2.GetSigService(context), view2.GetIdentityProvider(context).DefaultIdentity(), request)
+	if err != nil {
+		if err1 := session.SendError("failed signing"); err1 != nil {
+			logger.Errorf("failed sending the failure of collection [%s]: [%s]", request.ID, err1)
+		}
+		return nil, err
+	}
+	if err := session.Send(&response{Signer: signature.Signer, Signature: signature.Signature}); err != nil {
+		return nil, errors.WithMessagef(err, "failed sending the signature of collection [%s]", request.ID)
+	}
+	logger.Debugf("signed collection [%s]", request.ID)
+	return request, nil
+}
+
+// signLocally signs the passed request with the passed identity of this node
+func signLocally(sigService *view2.SigService, identity view.Identity, request *Request) (*Signature, error) {
+	signer, err := sigService.GetSigner(identity)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed getting signer of [%s]", identity)
+	}
+	raw, err := signer.Sign(request.Message())
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed signing collection [%s]", request.ID)
+	}
+	return &Signature{Party: identity, Signer: identity, Signature: raw}, nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multisig
+
+import (
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/pkg/errors"
+)
+
+const progressPrefix = "fsc.view.multisig"
+
+// store keeps the progress of the collections in the KVS: the signatures collected so far
+type store struct {
+	kvs *kvs.KVS
+}
+
+func (s *store) key(id string) (string, error) {
+	k, err := kvs.CreateCompositeKey(progressPrefix, []string{id})
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed creating progress key for collection [%s]", id)
+	}
+	return k, nil
+}
+
+// Get returns the progress of the passed collection, nil if there is none
+func (s *store) Get(id string) (*SignatureBundle, error) {
+	k, err := s.key(id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.kvs.Exists(k) {
+		return nil, nil
+	}
+	bundle := &SignatureBundle{}
+	if err := s.kvs.Get(k, bundle); err != nil {
+		return nil, errors.WithMessagef(err, "failed loading progress of collection [%s]", id)
+	}
+	return bundle, nil
+}
+
+func (s *store) Put(bundle *SignatureBundle) error {
+	k, err := s.key(bundle.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.kvs.Put(k, bundle); err != nil {
+		return errors.WithMessagef(err, "failed storing progress of collection [%s]", bundle.ID)
+	}
+	return nil
+}
+
+func (s *store) Delete(id string) error {
+	k, err := s.key(id)
+	if err != nil {
+		return err
+	}
+	if err := s.kvs.Delete(k); err != nil {
+		return errors.WithMessagef(err, "failed deleting progress of collection [%s]", id)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// IdempotencyKeyMetadata is the key of the gRPC metadata carrying the idempotency key of a view call
+	IdempotencyKeyMetadata = "fsc-idempotency-key"
+	// DefaultIdempotencyRetention is how long the results of the calls with an idempotency key are kept, unless configured otherwise
+	DefaultIdempotencyRetention = 24 * time.Hour
+	// DefaultIdempotencyCapacity bounds the results kept, unless configured otherwise
+	DefaultIdempotencyCapacity = 10000
+	// MaxIdempotencyKeyLength bounds the length of the idempotency keys
+	MaxIdempotencyKeyLength = 256
+
+	idempotencyPrefix = "fsc.view.idempotency"
+)
+
+var deduplicatedOpts = metrics.CounterOpts{
+	Namespace:    "view",
+	Subsystem:    "idempotency",
+	Name:         "deduplicated",
+	Help:         "The number of view calls answered with the result of a call with the same idempotency key, by state of that call: pending or completed.",
+	LabelNames:   []string{"state"},
+	StatsdFormat: "%{#fqname}.%{state}",
+}
+
+// IdempotencyMetrics counts the view calls deduplicated
+type IdempotencyMetrics struct {
+	Deduplicated metrics.Counter
+}
+
+func NewIdempotencyMetrics(p metrics.Provider) *IdempotencyMetrics {
+	return &IdempotencyMetrics{Deduplicated: p.NewCounter(deduplicatedOpts)}
+}
+
+type idempotencyConfig struct {
+	Retention time.Duration
+	Capacity  int
+}
+
+// idempotencyRecord is the result of a call, as stored in the kvs
+type idempotencyRecord struct {
+	// Digest is the digest of the view identifier and of the input of the call
+	Digest []byte
+	Result []byte
+	Stored time.Time
+}
+
+// pendingCall is a call running, the duplicates wait for its result
+type pendingCall struct {
+	digest []byte
+	done   chan struct{}
+	result []byte
+	err    error
+}
+
+type storedKey struct {
+	id     string
+	stored time.Time
+}
+
+// Deduplicator runs at most once the view calls with the same idempotency key, from the same client.
+// The results of the successful calls are stored in the kvs for the retention time, up to the capacity, the oldest
+// ones are evicted first. A duplicate gets the stored result, or, if the first call is still running, waits for it.
+// The failed calls are not stored, so that they can be retried. A key reused for another view, or another input, is refused.
+// A nil Deduplicator runs all the calls.
+type Deduplicator struct {
+	kvs       *kvs.KVS
+	retention time.Duration
+	capacity  int
+	metrics   *IdempotencyMetrics
+	now       func() time.Time
+
+	lock    sync.Mutex
+	pending map[string]*pendingCall
+	// stored are the keys of the stored results, oldest first
+	stored []storedKey
+}
+
+// NewDeduplicator returns a deduplicator storing the results in the passed kvs, the results stored by a previous
+// instance are loaded.
+func NewDeduplicator(kvss *kvs.KVS, retention time.Duration, capacity int, m *IdempotencyMetrics) (*Deduplicator, error) {
+	if retention <= 0 {
+		retention = DefaultIdempotencyRetention
+	}
+	if capacity <= 0 {
+		capacity = DefaultIdempotencyCapacity
+	}
+	d := &Deduplicator{
+		kvs:       kvss,
+		retention: retention,
+		capacity:  capacity,
+		metrics:   m,
+		now:       time.Now,
+		pending:   map[string]*pendingCall{},
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// NewDeduplicatorFromConfig returns the deduplicator configured with the following keys:
+// fsc.views.idempotency.retention is how long the results are kept, default 24h,
+// fsc.views.idempotency.capacity bounds the results kept, default 10000.
+func NewDeduplicatorFromConfig(cs driver.ConfigService, kvss *kvs.KVS, p metrics.Provider) (*Deduplicator, error) {
+	config := &idempotencyConfig{}
+	if cs.IsSet("fsc.views.idempotency") {
+		if err := cs.UnmarshalKey("fsc.views.idempotency", config); err != nil {
+			return nil, errors.Wrapf(err, "failed loading view idempotency")
+		}
+	}
+	return NewDeduplicator(kvss, config.Retention, config.Capacity, NewIdempotencyMetrics(p))
+}
+
+// load rebuilds the index of the stored results, dropping the expired ones and the ones beyond the capacity
+func (d *Deduplicator) load() error {
+	it, err := d.kvs.GetByPartialCompositeID(idempotencyPrefix, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed loading idempotency keys")
+	}
+	defer it.Close()
+	for it.HasNext() {
+		record := &idempotencyRecord{}
+		id, err := it.Next(record)
+		if err != nil {
+			return errors.Wrapf(err, "failed loading idempotency keys")
+		}
+		d.stored = append(d.stored, storedKey{id: id, stored: record.Stored})
+	}
+	sort.SliceStable(d.stored, func(i, j int) bool { return d.stored[i].stored.Before(d.stored[j].stored) })
+	d.evict()
+	return nil
+}
+
+// Do runs the passed view call, unless a call with the same idempotency key from the same client ran or is running,
+// in which case its result is returned. The creator is the identity of the client, which scopes the keys.
+func (d *Deduplicator) Do(ctx context.Context, creator []byte, key string, fid string, input []byte, run func() ([]byte, error)) ([]byte, error) {
+	if d == nil || len(key) == 0 {
+		return run()
+	}
+	id, err := d.id(creator, key)
+	if err != nil {
+		return nil, err
+	}
+	digest := inputDigest(fid, input)
+
+	d.lock.Lock()
+	if p, ok := d.pending[id]; ok {
+		d.lock.Unlock()
+		if !bytes.Equal(p.digest, digest) {
+			return nil, errors.Errorf("idempotency key [%s] already used for another call", key)
+		}
+		d.metrics.Deduplicated.With("state", "pending").Add(1)
+		logger.Debugf("call of view [%s] with idempotency key [%s] attached to the pending one", fid, key)
+		select {
+		case <-p.done:
+			return p.result, p.err
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "call of view [%s] with idempotency key [%s] not completed", fid, key)
+		}
+	}
+	d.evict()
+	if record, ok := d.get(id); ok {
+		d.lock.Unlock()
+		if !bytes.Equal(record.Digest, digest) {
+			return nil, errors.Errorf("idempotency key [%s] already used for another call", key)
+		}
+		d.metrics.Deduplicated.With("state", "completed").Add(1)
+		logger.Debugf("call of view [%s] with idempotency key [%s] answered with the stored result", fid, key)
+		return record.Result, nil
+	}
+	// the error stays set if the call panics
+	p := &pendingCall{digest: digest, done: make(chan struct{}), err: errors.Errorf("call of view [%s] aborted", fid)}
+	d.pending[id] = p
+	d.lock.Unlock()
+	defer d.complete(id, p, fid, key)
+
+	p.result, p.err = run()
+	return p.result, p.err
+}
+
+// complete stores the result of the passed call, if successful, and hands it to the duplicates waiting for it
+func (d *Deduplicator) complete(id string, p *pendingCall, fid, key string) {
+	d.lock.Lock()
+	delete(d.pending, id)
+	if p.err == nil {
+		record := &idempotencyRecord{Digest: p.digest, Result: p.result, Stored: d.now()}
+		if err := d.kvs.Put(id, record); err != nil {
+			// the call succeeded anyway, a duplicate would run it again
+			logger.Errorf("failed storing the result of the call of view [%s] with idempotency key [%s]: [%s]", fid, key, err)
+		} else {
+			d.stored = append(d.stored, storedKey{id: id, stored: record.Stored})
+			d.evict()
+		}
+	}
+	d.lock.Unlock()
+	close(p.done)
+}
+
+// get returns the stored result with the passed id, if any. d.lock must be held
+func (d *Deduplicator) get(id string) (*idempotencyRecord, bool) {
+	if !d.kvs.Exists(id) {
+		return nil, false
+	}
+	record := &idempotencyRecord{}
+	if err := d.kvs.Get(id, record); err != nil {
+		logger.Errorf("failed reading the result stored for [%s]: [%s]", id, err)
+		return nil, false
+	}
+	return record, true
+}
+
+// evict deletes the stored results expired and the oldest ones beyond the capacity. d.lock must be held
+func (d *Deduplicator) evict() {
+	now := d.now()
+	n := 0
+	for n < len(d.stored) && (len(d.stored)-n > d.capacity || now.Sub(d.stored[n].stored) >= d.retention) {
+		if err := d.kvs.Delete(d.stored[n].id); err != nil {
+			logger.Errorf("failed evicting the result stored for [%s]: [%s]", d.stored[n].id, err)
+		}
+		n++
+	}
+	d.stored = d.stored[n:]
+}
+
+// id returns the kvs key of the result of the call with the passed idempotency key from the passed client
+func (d *Deduplicator) id(creator []byte, key string) (string, error) {
+	if len(key) > MaxIdempotencyKeyLength {
+		return "", errors.Errorf("idempotency key longer than %d bytes", MaxIdempotencyKeyLength)
+	}
+	client := sha256.Sum256(creator)
+	id, err := kvs.CreateCompositeKey(idempotencyPrefix, []string{hex.EncodeToString(client[:]), key})
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid idempotency key [%s]", key)
+	}
+	return id, nil
+}
+
+func inputDigest(fid string, input []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(fid))
+	h.Write([]byte{0})
+	h.Write(input)
+	return h.Sum(nil)
+}
+
+// IdempotencyKey returns the idempotency key of the call in the gRPC metadata of the passed context, empty if none
+func IdempotencyKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(IdempotencyKeyMetadata)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// GetDeduplicator returns the deduplicator of the view calls, nil if not available
+func GetDeduplicator(sp view2.ServiceProvider) *Deduplicator {
+	s, err := sp.GetService(reflect.TypeOf((*Deduplicator)(nil)))
+	if err != nil {
+		return nil
+	}
+	return s.(*Deduplicator)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/db/driver/memory"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/kvs/mock"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/registry"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestDeduplicator(t *testing.T) {
+	kvss, err := kvs.NewWithConfig(registry.New(), "memory", "", &mock.ConfigProvider{})
+	assert.NoError(t, err)
+	d, err := NewDeduplicator(kvss, time.Hour, 2, NewIdempotencyMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	runs := int32(0)
+	run := func(result string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			atomic.AddInt32(&runs, 1)
+			return []byte(result), nil
+		}
+	}
+
+	// the duplicates attach to the pending call
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		res, err := d.Do(context.Background(), []byte("alice"), "k1", "transfer", []byte("in"), func() ([]byte, error) {
+			close(started)
+			<-release
+			return run("r1")()
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("r1"), res)
+	}()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = d.Do(ctx, []byte("alice"), "k1", "transfer", []byte("in"), run("r2"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		res, err := d.Do(context.Background(), []byte("alice"), "k1", "transfer", []byte("in"), run("r2"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("r1"), res)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+	// then they get the stored result, the keys are scoped per client
+	res, err := d.Do(context.Background(), []byte("alice"), "k1", "transfer", []byte("in"), run("r2"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("r1"), res)
+	res, err = d.Do(context.Background(), []byte("bob"), "k1", "transfer", []byte("in"), run("r2"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("r2"), res)
+	_, err = d.Do(context.Background(), []byte("alice"), "k1", "transfer", []byte("other"), run("r3"))
+	assert.EqualError(t, err, "idempotency key [k1] already used for another call")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+
+	// the failures are not stored
+	now = now.Add(time.Minute)
+	_, err = d.Do(context.Background(), []byte("alice"), "k2", "transfer", nil, func() ([]byte, error) { return nil, errors.New("boom") })
+	assert.EqualError(t, err, "boom")
+	res, err = d.Do(context.Background(), []byte("alice"), "k2", "transfer", nil, run("r4"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("r4"), res)
+
+	// the capacity evicts the oldest results, the others survive a restart until they expire
+	d, err = NewDeduplicator(kvss, time.Hour, 2, NewIdempotencyMetrics(&disabled.Provider{}))
+	assert.NoError(t, err)
+	d.now = func() time.Time { return now }
+	assert.Len(t, d.stored, 2)
+	res, err = d.Do(context.Background(), []byte("alice"), "k1", "transfer", []byte("in"), run("r5"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("r5"), res)
+	res, err = d.Do(context.Background(), []byte("alice"), "k2", "transfer", nil, run("r6"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("r4"), res)
+	now = now.Add(time.Hour)
+	res, err = d.Do(context.Background(), []byte("alice"), "k2", "transfer", nil, run("r6"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("r6"), res)
+
+	// without key, or deduplicator, the calls always run
+	res, err = (*Deduplicator)(nil).Do(context.Background(), []byte("alice"), "k1", "transfer", nil, run("r7"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("r7"), res)
+	assert.Equal(t, "", IdempotencyKey(context.Background()))
+	assert.Equal(t, "k1", IdempotencyKey(metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyMetadata, "k1"))))
+}
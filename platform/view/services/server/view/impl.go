@@ -11,10 +11,14 @@ import (
 	"reflect"
 	"runtime/debug"
 	"strconv"
+	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 var logger = flogging.MustGetLogger("view-sdk.server")
@@ -27,6 +31,23 @@ type PolicyChecker interface {
 	Check(sc *protos2.SignedCommand, c *protos2.Command) error
 }
 
+// VersionHeader and CommitHeader are the keys of the gRPC header of every response of the server,
+// carrying the version of the node and the commit it has been built from
+const (
+	VersionHeader = "fsc-version"
+	CommitHeader  = "fsc-commit"
+)
+
+type queueDelayKey struct{}
+
+// SetQueueDelay reports, from a processor, the time its command has been queued for,
+// the server returns it in the header of the response
+func SetQueueDelay(ctx context.Context, delay time.Duration) {
+	if d, ok := ctx.Value(queueDelayKey{}).(*time.Duration); ok {
+		*d = delay
+	}
+}
+
 // Service is responsible for processing view commands.
 type server struct {
 	Marshaller    Marshaller
@@ -35,6 +56,7 @@ type server struct {
 	processors map[reflect.Type]Processor
 	streamers  map[reflect.Type]Streamer
 	metrics    *Metrics
+	header     metadata.MD
 }
 
 func NewViewServiceServer(marshaller Marshaller, policyChecker PolicyChecker, metrics *Metrics) (*server, error) {
@@ -44,6 +66,7 @@ func NewViewServiceServer(marshaller Marshaller, policyChecker PolicyChecker, me
 		processors:    map[reflect.Type]Processor{},
 		streamers:     map[reflect.Type]Streamer{},
 		metrics:       metrics,
+		header:        versionHeader(),
 	}, nil
 }
 
@@ -56,6 +79,9 @@ func (s *server) ProcessCommand(ctx context.Context, sc *protos2.SignedCommand)
 	}()
 
 	logger.Debugf("Processes Command invoked...")
+	if err := grpc.SetHeader(ctx, s.header); err != nil {
+		logger.Debugf("failed setting version header: [%s]", err)
+	}
 
 	command, err := UnmarshalCommand(sc.Command)
 	if err != nil {
@@ -81,9 +107,10 @@ func (s *server) ProcessCommand(ctx context.Context, sc *protos2.SignedCommand)
 
 	p, ok := s.processors[reflect.TypeOf(command.GetPayload())]
 	var payload interface{}
+	queueDelay := new(time.Duration)
 	switch ok {
 	case true:
-		payload, err = p(ctx, command)
+		payload, err = p(context.WithValue(ctx, queueDelayKey{}, queueDelay), command)
 	default:
 		err = errors.Errorf("command type not recognized: %T", reflect.TypeOf(command.GetPayload()))
 	}
@@ -95,7 +122,7 @@ func (s *server) ProcessCommand(ctx context.Context, sc *protos2.SignedCommand)
 	}
 
 	logger.Debugf("Preparing response")
-	cr, err = s.Marshaller.MarshalCommandResponse(sc.Command, payload)
+	cr, err = s.Marshaller.MarshalCommandResponse(sc.Command, payload, WithQueueDelay(*queueDelay))
 	logger.Debugf("Done with err [%s]", err)
 
 	return
@@ -110,6 +137,9 @@ func (s *server) StreamCommand(sc *protos2.SignedCommand, commandServer protos2.
 	}()
 
 	logger.Debugf("Stream Command invoked...")
+	if err := commandServer.SetHeader(s.header); err != nil {
+		logger.Debugf("failed setting version header: [%s]", err)
+	}
 
 	command, err := UnmarshalCommand(sc.Command)
 	if err != nil {
@@ -187,3 +217,9 @@ func (s *server) streamError(err error, sc *protos2.SignedCommand, commandServer
 	return err
 
 }
+
+// versionHeader returns the gRPC header carrying the build information of the node
+func versionHeader() metadata.MD {
+	info := version.Get()
+	return metadata.Pairs(VersionHeader, info.Version, CommitHeader, info.CommitSHA)
+}
@@ -45,7 +45,7 @@ func NewResponseMarshaler(sp driver.ServiceProvider) (*ResponseMarshaler, error)
 	}, nil
 }
 
-func (s *ResponseMarshaler) MarshalCommandResponse(command []byte, responsePayload interface{}) (*protos2.SignedCommandResponse, error) {
+func (s *ResponseMarshaler) MarshalCommandResponse(command []byte, responsePayload interface{}, opts ...ResponseOption) (*protos2.SignedCommandResponse, error) {
 	cr, err := commandResponseFromPayload(responsePayload)
 	if err != nil {
 		return nil, err
@@ -62,6 +62,9 @@ func (s *ResponseMarshaler) MarshalCommandResponse(command []byte, responsePaylo
 		CommandHash: s.computeHash(command),
 		Timestamp:   ts,
 	}
+	for _, opt := range opts {
+		opt(cr.Header)
+	}
 
 	return s.createSignedCommandResponse(cr)
 }
@@ -108,6 +111,8 @@ func commandResponseFromPayload(payload interface{}) (*protos2.CommandResponse,
 		return &protos2.CommandResponse{Payload: t}, nil
 	case *protos2.CommandResponse_IsTxFinalResponse:
 		return &protos2.CommandResponse{Payload: t}, nil
+	case *protos2.CommandResponse_Event:
+		return &protos2.CommandResponse{Payload: t}, nil
 	default:
 		return nil, errors.Errorf("command type not recognized: %T", t)
 	}
@@ -8,6 +8,7 @@ import (
 	math "math"
 
 	proto "github.com/golang/protobuf/proto"
+	duration "github.com/golang/protobuf/ptypes/duration"
 	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 )
 
@@ -356,6 +357,7 @@ type Command struct {
 	//	*Command_TrackView
 	//	*Command_CallView
 	//	*Command_IsTxFinal
+	//	*Command_SubscribeEvents
 	Payload              isCommand_Payload `protobuf_oneof:"payload"`
 	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
 	XXX_unrecognized     []byte            `json:"-"`
@@ -414,6 +416,10 @@ type Command_IsTxFinal struct {
 	IsTxFinal *IsTxFinal `protobuf:"bytes,5,opt,name=isTxFinal,proto3,oneof"`
 }
 
+type Command_SubscribeEvents struct {
+	SubscribeEvents *SubscribeEvents `protobuf:"bytes,6,opt,name=subscribeEvents,proto3,oneof"`
+}
+
 func (*Command_InitiateView) isCommand_Payload() {}
 
 func (*Command_TrackView) isCommand_Payload() {}
@@ -422,6 +428,8 @@ func (*Command_CallView) isCommand_Payload() {}
 
 func (*Command_IsTxFinal) isCommand_Payload() {}
 
+func (*Command_SubscribeEvents) isCommand_Payload() {}
+
 func (m *Command) GetPayload() isCommand_Payload {
 	if m != nil {
 		return m.Payload
@@ -457,6 +465,13 @@ func (m *Command) GetIsTxFinal() *IsTxFinal {
 	return nil
 }
 
+func (m *Command) GetSubscribeEvents() *SubscribeEvents {
+	if x, ok := m.GetPayload().(*Command_SubscribeEvents); ok {
+		return x.SubscribeEvents
+	}
+	return nil
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*Command) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
@@ -464,6 +479,7 @@ func (*Command) XXX_OneofWrappers() []interface{} {
 		(*Command_TrackView)(nil),
 		(*Command_CallView)(nil),
 		(*Command_IsTxFinal)(nil),
+		(*Command_SubscribeEvents)(nil),
 	}
 }
 
@@ -527,10 +543,12 @@ type CommandResponseHeader struct {
 	// asynchronous system and for security reasons (accountability, non-repudiation)
 	CommandHash []byte `protobuf:"bytes,2,opt,name=command_hash,json=commandHash,proto3" json:"command_hash,omitempty"`
 	// Creator is the identity of the party creating this message
-	Creator              []byte   `protobuf:"bytes,3,opt,name=creator,proto3" json:"creator,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Creator []byte `protobuf:"bytes,3,opt,name=creator,proto3" json:"creator,omitempty"`
+	// QueueDelay is the time the command has been queued for before being processed
+	QueueDelay           *duration.Duration `protobuf:"bytes,4,opt,name=queue_delay,json=queueDelay,proto3" json:"queue_delay,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
 }
 
 func (m *CommandResponseHeader) Reset()         { *m = CommandResponseHeader{} }
@@ -579,6 +597,13 @@ func (m *CommandResponseHeader) GetCreator() []byte {
 	return nil
 }
 
+func (m *CommandResponseHeader) GetQueueDelay() *duration.Duration {
+	if m != nil {
+		return m.QueueDelay
+	}
+	return nil
+}
+
 // Error reports an application error
 type Error struct {
 	// Message associated with this response.
@@ -641,6 +666,7 @@ type CommandResponse struct {
 	//	*CommandResponse_TrackViewResponse
 	//	*CommandResponse_CallViewResponse
 	//	*CommandResponse_IsTxFinalResponse
+	//	*CommandResponse_Event
 	Payload              isCommandResponse_Payload `protobuf_oneof:"payload"`
 	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
 	XXX_unrecognized     []byte                    `json:"-"`
@@ -703,6 +729,10 @@ type CommandResponse_IsTxFinalResponse struct {
 	IsTxFinalResponse *IsTxFinalResponse `protobuf:"bytes,6,opt,name=isTxFinalResponse,proto3,oneof"`
 }
 
+type CommandResponse_Event struct {
+	Event *Event `protobuf:"bytes,7,opt,name=event,proto3,oneof"`
+}
+
 func (*CommandResponse_Err) isCommandResponse_Payload() {}
 
 func (*CommandResponse_InitiateViewResponse) isCommandResponse_Payload() {}
@@ -713,6 +743,8 @@ func (*CommandResponse_CallViewResponse) isCommandResponse_Payload() {}
 
 func (*CommandResponse_IsTxFinalResponse) isCommandResponse_Payload() {}
 
+func (*CommandResponse_Event) isCommandResponse_Payload() {}
+
 func (m *CommandResponse) GetPayload() isCommandResponse_Payload {
 	if m != nil {
 		return m.Payload
@@ -755,6 +787,13 @@ func (m *CommandResponse) GetIsTxFinalResponse() *IsTxFinalResponse {
 	return nil
 }
 
+func (m *CommandResponse) GetEvent() *Event {
+	if x, ok := m.GetPayload().(*CommandResponse_Event); ok {
+		return x.Event
+	}
+	return nil
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*CommandResponse) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
@@ -763,6 +802,7 @@ func (*CommandResponse) XXX_OneofWrappers() []interface{} {
 		(*CommandResponse_TrackViewResponse)(nil),
 		(*CommandResponse_CallViewResponse)(nil),
 		(*CommandResponse_IsTxFinalResponse)(nil),
+		(*CommandResponse_Event)(nil),
 	}
 }
 
@@ -835,45 +875,50 @@ func init() {
 func init() { proto.RegisterFile("commands.proto", fileDescriptor_0dff099eb2e3dfdb) }
 
 var fileDescriptor_0dff099eb2e3dfdb = []byte{
-	// 629 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x95, 0x4d, 0x6f, 0xd3, 0x4c,
-	0x10, 0xc7, 0xe3, 0xe6, 0x69, 0xda, 0x8c, 0xd3, 0x3e, 0xe9, 0x2a, 0x45, 0x26, 0x6a, 0x45, 0xeb,
-	0x03, 0xaa, 0x90, 0x70, 0x45, 0x10, 0x08, 0xc1, 0xad, 0x15, 0xc5, 0x39, 0xb2, 0x54, 0x1c, 0xb8,
-	0x54, 0x5b, 0x7b, 0x9b, 0xac, 0x70, 0xec, 0x68, 0x77, 0x23, 0xe8, 0x8d, 0x4f, 0xd0, 0x23, 0x9f,
-	0x81, 0x8f, 0x89, 0xf6, 0x35, 0x8e, 0x13, 0x21, 0x21, 0x4e, 0xd9, 0xf1, 0xce, 0xcc, 0x7f, 0x5e,
-	0x7e, 0xb1, 0x61, 0x3f, 0xab, 0x66, 0x33, 0x52, 0xe6, 0x22, 0x99, 0xf3, 0x4a, 0x56, 0xa8, 0xa3,
-	0x7f, 0xc4, 0xf0, 0xc9, 0xa4, 0xaa, 0x26, 0x05, 0x3d, 0xd7, 0xe6, 0xed, 0xe2, 0xee, 0x5c, 0xb2,
-	0x19, 0x15, 0x92, 0xcc, 0xe6, 0xc6, 0x71, 0xb8, 0x7f, 0xc7, 0x4a, 0x52, 0x30, 0x79, 0x6f, 0xec,
-	0xf8, 0x35, 0xf4, 0xc6, 0x25, 0x93, 0x8c, 0x48, 0xfa, 0x99, 0xd1, 0x6f, 0xa8, 0x0f, 0xed, 0x3b,
-	0x96, 0x47, 0xc1, 0x49, 0x70, 0xd6, 0xc5, 0xea, 0x88, 0x06, 0xb0, 0xcd, 0xca, 0xf9, 0x42, 0x46,
-	0x5b, 0x27, 0xc1, 0x59, 0x0f, 0x1b, 0x23, 0x3e, 0x83, 0x41, 0x3d, 0x0e, 0x53, 0x31, 0xaf, 0x4a,
-	0x41, 0x55, 0x7c, 0xb6, 0x8c, 0xcf, 0x58, 0x1e, 0x8f, 0x60, 0xf7, 0x92, 0x14, 0xc5, 0x5f, 0x65,
-	0x7f, 0x06, 0x7d, 0x17, 0xe3, 0x33, 0x3f, 0x82, 0x0e, 0xa7, 0x62, 0x51, 0x48, 0x1d, 0xde, 0xc3,
-	0xd6, 0x8a, 0x8f, 0xa1, 0x7b, 0xcd, 0x49, 0xf6, 0xd5, 0x09, 0x34, 0xe4, 0x9f, 0xc3, 0x81, 0xbf,
-	0xf6, 0xb9, 0x22, 0xd8, 0x99, 0x93, 0xfb, 0xa2, 0x22, 0xb9, 0x4d, 0xe6, 0xcc, 0xf8, 0x67, 0x00,
-	0x9d, 0x94, 0x92, 0x9c, 0x72, 0xf4, 0x06, 0xba, 0x7e, 0x7a, 0xda, 0x2d, 0x1c, 0x0d, 0x13, 0x33,
-	0xdf, 0xc4, 0xcd, 0x37, 0xb9, 0x76, 0x1e, 0x78, 0xe9, 0xac, 0x9a, 0x2a, 0xab, 0x32, 0xa3, 0x51,
-	0xdb, 0x34, 0xa5, 0x0d, 0x25, 0x9a, 0x71, 0x4a, 0x64, 0xc5, 0xa3, 0xff, 0x8c, 0xa8, 0x35, 0x51,
-	0x0c, 0x7b, 0xb2, 0x10, 0x37, 0x19, 0xe5, 0xf2, 0x66, 0x4a, 0xc4, 0x34, 0xda, 0xd6, 0xf7, 0xa1,
-	0x2c, 0xc4, 0x25, 0xe5, 0x32, 0x25, 0x62, 0x1a, 0x3f, 0x6c, 0xc1, 0xce, 0xa5, 0x59, 0x3a, 0x7a,
-	0x0a, 0x9d, 0xa9, 0xae, 0xd1, 0x96, 0xb5, 0x6f, 0xea, 0x11, 0x89, 0xa9, 0x1c, 0xdb, 0x5b, 0xf4,
-	0x16, 0x7a, 0xac, 0xb6, 0x24, 0x3d, 0xe3, 0x70, 0x34, 0x70, 0xde, 0xf5, 0x05, 0xa6, 0x2d, 0xbc,
-	0xe2, 0x8b, 0x5e, 0x40, 0x57, 0xba, 0xb9, 0xe9, 0x3e, 0xc2, 0xd1, 0x81, 0x0b, 0xf4, 0x03, 0x4d,
-	0x5b, 0x78, 0xe9, 0x85, 0x12, 0xd8, 0xcd, 0xec, 0xd6, 0x74, 0x87, 0xe1, 0xa8, 0xef, 0x22, 0xdc,
-	0x36, 0xd3, 0x16, 0xf6, 0x3e, 0x4a, 0x82, 0x89, 0xeb, 0xef, 0x57, 0x8a, 0x48, 0xdd, 0x72, 0x4d,
-	0x62, 0xec, 0x2e, 0x94, 0x84, 0xf7, 0xba, 0xe8, 0xfa, 0xc5, 0xc5, 0x1f, 0x60, 0xef, 0x13, 0x9b,
-	0x94, 0x34, 0x77, 0x53, 0x51, 0xf3, 0x35, 0x47, 0xb7, 0x54, 0x6b, 0xa2, 0x23, 0xe8, 0x0a, 0x36,
-	0x29, 0x89, 0x5c, 0x70, 0x6a, 0x41, 0x5b, 0x3e, 0x88, 0x1f, 0x02, 0x38, 0xb4, 0x39, 0x1c, 0x20,
-	0xff, 0x4c, 0xc0, 0x29, 0xf4, 0xac, 0xb8, 0x59, 0xa8, 0x11, 0x0d, 0xed, 0x33, 0xb5, 0xd0, 0x3a,
-	0x0e, 0xed, 0x15, 0x1c, 0xe2, 0x77, 0xb0, 0xfd, 0x9e, 0xf3, 0x8a, 0x2b, 0x97, 0x19, 0x15, 0x82,
-	0x4c, 0xa8, 0x25, 0xda, 0x99, 0x75, 0x80, 0xb7, 0x56, 0x01, 0xfe, 0xd5, 0x86, 0xff, 0x1b, 0xdd,
-	0xa0, 0x57, 0x0d, 0x5e, 0x8e, 0xfd, 0x5a, 0x36, 0xb5, 0xed, 0xf1, 0x39, 0x85, 0x36, 0xe5, 0xdc,
-	0x52, 0xb3, 0xe7, 0x62, 0x74, 0x69, 0x69, 0x0b, 0xab, 0x3b, 0x84, 0x61, 0xc0, 0x36, 0xbc, 0x06,
-	0x2c, 0x30, 0x47, 0x9b, 0x48, 0xf3, 0x62, 0x2d, 0xbc, 0x31, 0x16, 0x8d, 0xe1, 0x40, 0x36, 0xff,
-	0xb1, 0x96, 0xa7, 0xc7, 0x6b, 0x04, 0xd6, 0xb2, 0xad, 0x47, 0xa1, 0x2b, 0xe8, 0x67, 0x8d, 0xf7,
-	0x88, 0x05, 0x2d, 0x6a, 0x92, 0x59, 0x4b, 0xb4, 0x16, 0xa3, 0x4a, 0xf2, 0x0c, 0xfa, 0x44, 0x9d,
-	0xd5, 0x92, 0xc6, 0x4d, 0x07, 0x55, 0xd2, 0x5a, 0x54, 0x9d, 0xe0, 0x8f, 0x70, 0xb8, 0x42, 0xb0,
-	0x97, 0x1b, 0xc2, 0x2e, 0x77, 0x2a, 0x06, 0x65, 0x6f, 0xff, 0x99, 0xe5, 0x8b, 0xf0, 0x8b, 0xfd,
-	0x12, 0xfc, 0x08, 0x82, 0x5b, 0x73, 0x7c, 0xf9, 0x3b, 0x00, 0x00, 0xff, 0xff, 0xf2, 0x52, 0xf8,
-	0x32, 0x2d, 0x06, 0x00, 0x00,
+	// 716 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0xc1, 0x6e, 0xdb, 0x38,
+	0x10, 0xb5, 0xe3, 0xb5, 0x13, 0x8f, 0x9c, 0xc4, 0x21, 0x9c, 0x5d, 0xad, 0x91, 0xec, 0x26, 0x02,
+	0x76, 0x11, 0x2c, 0xb0, 0x0e, 0xd6, 0x8b, 0x16, 0x45, 0x7a, 0x8b, 0x93, 0x54, 0x3e, 0x96, 0x09,
+	0x7a, 0xe8, 0x25, 0xa0, 0x25, 0xc6, 0x26, 0x2a, 0x4b, 0x2e, 0x49, 0xb5, 0xcd, 0xad, 0x5f, 0xd1,
+	0x8f, 0xea, 0xa5, 0x87, 0xfe, 0x50, 0x41, 0x8a, 0xa4, 0x65, 0xd9, 0x28, 0x50, 0xf4, 0x64, 0x0d,
+	0x67, 0xde, 0xbc, 0xe1, 0xcc, 0x1b, 0x1a, 0xf6, 0xa2, 0x6c, 0x3e, 0x27, 0x69, 0x2c, 0x06, 0x0b,
+	0x9e, 0xc9, 0x0c, 0xb5, 0xf4, 0x8f, 0xe8, 0xff, 0x39, 0xcd, 0xb2, 0x69, 0x42, 0xcf, 0xb5, 0x39,
+	0xc9, 0x1f, 0xce, 0x25, 0x9b, 0x53, 0x21, 0xc9, 0x7c, 0x51, 0x04, 0xf6, 0xf7, 0x1e, 0x58, 0x4a,
+	0x12, 0x26, 0x1f, 0x8d, 0xfd, 0x47, 0x15, 0x10, 0xe7, 0x9c, 0x48, 0x96, 0xa5, 0xc6, 0xdf, 0xa1,
+	0xef, 0x68, 0x2a, 0x0d, 0x4d, 0xf0, 0x14, 0x3a, 0xe3, 0x94, 0x49, 0x46, 0x24, 0x7d, 0xc5, 0xe8,
+	0x7b, 0xd4, 0x85, 0xc6, 0x03, 0x8b, 0xfd, 0xfa, 0x49, 0xfd, 0xac, 0x8d, 0xd5, 0x27, 0xea, 0x41,
+	0x93, 0xa5, 0x8b, 0x5c, 0xfa, 0x5b, 0x27, 0xf5, 0xb3, 0x0e, 0x2e, 0x8c, 0xe0, 0x0c, 0x7a, 0x65,
+	0x1c, 0xa6, 0x62, 0x91, 0xa5, 0x82, 0x2a, 0x7c, 0xb4, 0xc4, 0x47, 0x2c, 0x0e, 0x86, 0xb0, 0x33,
+	0x22, 0x49, 0xf2, 0x43, 0xd9, 0xff, 0x81, 0xae, 0xc5, 0xb8, 0xcc, 0xbf, 0x42, 0x8b, 0x53, 0x91,
+	0x27, 0x52, 0xc3, 0x3b, 0xd8, 0x58, 0xc1, 0x31, 0xb4, 0xef, 0x38, 0x89, 0xde, 0x58, 0x82, 0x0a,
+	0xfd, 0xbf, 0x70, 0xe0, 0xdc, 0x2e, 0x97, 0x0f, 0xdb, 0x0b, 0xf2, 0x98, 0x64, 0x24, 0x36, 0xc9,
+	0xac, 0x19, 0x7c, 0xaa, 0x43, 0x2b, 0xa4, 0x24, 0xa6, 0x1c, 0x3d, 0x83, 0xb6, 0xeb, 0xb5, 0x0e,
+	0xf3, 0x86, 0xfd, 0x41, 0xd1, 0xdc, 0x81, 0x6d, 0xee, 0xe0, 0xce, 0x46, 0xe0, 0x65, 0xb0, 0xba,
+	0x54, 0x9a, 0xa5, 0x11, 0xf5, 0x1b, 0xc5, 0xa5, 0xb4, 0xa1, 0x48, 0x23, 0x4e, 0x89, 0xcc, 0xb8,
+	0xff, 0x4b, 0x41, 0x6a, 0x4c, 0x14, 0xc0, 0xae, 0x4c, 0xc4, 0x7d, 0x44, 0xb9, 0xbc, 0x9f, 0x11,
+	0x31, 0xf3, 0x9b, 0xda, 0xef, 0xc9, 0x44, 0x8c, 0x28, 0x97, 0x21, 0x11, 0xb3, 0xe0, 0xeb, 0x16,
+	0x6c, 0x8f, 0x0a, 0x89, 0xa0, 0xbf, 0xa1, 0x35, 0xd3, 0x35, 0x9a, 0xb2, 0xf6, 0x8a, 0x7a, 0xc4,
+	0xa0, 0xa8, 0x1c, 0x1b, 0x2f, 0xba, 0x80, 0x0e, 0x2b, 0x0d, 0x49, 0xf7, 0xd8, 0x1b, 0xf6, 0x6c,
+	0x74, 0x79, 0x80, 0x61, 0x0d, 0xaf, 0xc4, 0xa2, 0xff, 0xa0, 0x2d, 0x6d, 0xdf, 0xf4, 0x3d, 0xbc,
+	0xe1, 0x81, 0x05, 0xba, 0x86, 0x86, 0x35, 0xbc, 0x8c, 0x42, 0x03, 0xd8, 0x89, 0xcc, 0xd4, 0xf4,
+	0x0d, 0xbd, 0x61, 0xd7, 0x22, 0xec, 0x34, 0xc3, 0x1a, 0x76, 0x31, 0x8a, 0x82, 0x89, 0xbb, 0x0f,
+	0x37, 0x4a, 0xbf, 0x7e, 0x73, 0x95, 0x62, 0x6c, 0x1d, 0x8a, 0xc2, 0x45, 0xa1, 0x11, 0xec, 0x8b,
+	0x7c, 0x22, 0x22, 0xce, 0x26, 0xf4, 0x5a, 0xeb, 0xd8, 0x6f, 0x69, 0xe0, 0x6f, 0x16, 0x78, 0xbb,
+	0xea, 0x0e, 0x6b, 0xb8, 0x8a, 0xb8, 0x6c, 0xbb, 0xe9, 0x07, 0x2f, 0x60, 0xf7, 0x96, 0x4d, 0x53,
+	0x1a, 0xdb, 0xd6, 0xaa, 0x21, 0x15, 0x9f, 0x56, 0x19, 0xc6, 0x44, 0x47, 0xd0, 0x16, 0x6c, 0x9a,
+	0x12, 0x99, 0x73, 0x6a, 0xd4, 0xba, 0x3c, 0x08, 0x3e, 0xd7, 0xe1, 0xd0, 0xe4, 0xb0, 0x2a, 0xfb,
+	0x69, 0x19, 0x9d, 0x42, 0xc7, 0x90, 0x17, 0xaa, 0x28, 0x48, 0x3d, 0x73, 0xa6, 0x54, 0x51, 0xd6,
+	0x54, 0x63, 0x55, 0x53, 0x17, 0xe0, 0xbd, 0xcd, 0x69, 0x4e, 0xef, 0x63, 0x9a, 0x90, 0x47, 0x33,
+	0x8f, 0xdf, 0xd7, 0x88, 0xaf, 0xcc, 0xe3, 0x80, 0x41, 0x47, 0x5f, 0xa9, 0xe0, 0xe0, 0x39, 0x34,
+	0xaf, 0x39, 0xcf, 0xb8, 0x4a, 0x3f, 0xa7, 0x42, 0x90, 0x29, 0x35, 0x2b, 0x65, 0xcd, 0xf2, 0x06,
+	0x6d, 0xad, 0x6e, 0xd0, 0x97, 0x06, 0xec, 0x57, 0x3a, 0x81, 0x9e, 0x54, 0x04, 0x7b, 0xec, 0x74,
+	0xb1, 0xa9, 0x65, 0x4e, 0xbf, 0xa7, 0xd0, 0xa0, 0x9c, 0x1b, 0xd9, 0xee, 0x5a, 0x8c, 0x2e, 0x2d,
+	0xac, 0x61, 0xe5, 0x43, 0x18, 0x7a, 0x6c, 0xc3, 0x3b, 0x64, 0x14, 0x7b, 0xb4, 0x49, 0xea, 0x8e,
+	0xac, 0x86, 0x37, 0x62, 0xd1, 0x18, 0x0e, 0x64, 0xf5, 0xc9, 0x70, 0x0d, 0xac, 0xae, 0x40, 0x29,
+	0xdb, 0x3a, 0x0a, 0xdd, 0x40, 0x37, 0xaa, 0x3c, 0x64, 0x46, 0xe9, 0x7e, 0x75, 0x35, 0x4a, 0x89,
+	0xd6, 0x30, 0xaa, 0x24, 0xb7, 0x04, 0x2e, 0x51, 0x6b, 0xb5, 0xa4, 0x71, 0x35, 0x40, 0x95, 0xb4,
+	0x86, 0x42, 0x7f, 0x41, 0x53, 0xff, 0x03, 0xf8, 0xdb, 0x95, 0xb6, 0xaa, 0xc3, 0xb0, 0x86, 0x0b,
+	0x6f, 0x79, 0x49, 0x5e, 0xc2, 0xe1, 0xca, 0x92, 0xb8, 0x54, 0x7d, 0xd8, 0xe1, 0xb6, 0x98, 0x62,
+	0x5b, 0x9c, 0xfd, 0xfd, 0x75, 0xb9, 0xf4, 0x5e, 0x9b, 0xff, 0xb7, 0x8f, 0xf5, 0xfa, 0xa4, 0xf8,
+	0xfc, 0xff, 0xdb, 0x00, 0xdc, 0x7d, 0x6c, 0x61, 0x03, 0x07, 0x00, 0x00,
 }
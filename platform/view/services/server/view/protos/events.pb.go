@@ -0,0 +1,320 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: events.proto
+
+package protos
+
+import (
+	fmt "fmt"
+	math "math"
+
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// EventFilter selects the events of a subscription, the fields not set match everything
+type EventFilter struct {
+	Network string `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	Channel string `protobuf:"bytes,2,opt,name=channel,proto3" json:"channel,omitempty"`
+	// namespaces are the chaincode of a chaincode event, the namespaces written by a transaction
+	Namespaces []string `protobuf:"bytes,3,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	// kinds are finality, chaincode, or config
+	Kinds []string `protobuf:"bytes,4,rep,name=kinds,proto3" json:"kinds,omitempty"`
+	// names are the names of the chaincode events
+	Names                []string `protobuf:"bytes,5,rep,name=names,proto3" json:"names,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EventFilter) Reset()         { *m = EventFilter{} }
+func (m *EventFilter) String() string { return proto.CompactTextString(m) }
+func (*EventFilter) ProtoMessage()    {}
+func (*EventFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{0}
+}
+
+func (m *EventFilter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EventFilter.Unmarshal(m, b)
+}
+func (m *EventFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EventFilter.Marshal(b, m, deterministic)
+}
+func (m *EventFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EventFilter.Merge(m, src)
+}
+func (m *EventFilter) XXX_Size() int {
+	return xxx_messageInfo_EventFilter.Size(m)
+}
+func (m *EventFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_EventFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EventFilter proto.InternalMessageInfo
+
+func (m *EventFilter) GetNetwork() string {
+	if m != nil {
+		return m.Network
+	}
+	return ""
+}
+
+func (m *EventFilter) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+func (m *EventFilter) GetNamespaces() []string {
+	if m != nil {
+		return m.Namespaces
+	}
+	return nil
+}
+
+func (m *EventFilter) GetKinds() []string {
+	if m != nil {
+		return m.Kinds
+	}
+	return nil
+}
+
+func (m *EventFilter) GetNames() []string {
+	if m != nil {
+		return m.Names
+	}
+	return nil
+}
+
+// SubscribeEvents streams the events committed on a channel, from the one following the resume token, if set
+type SubscribeEvents struct {
+	Filter *EventFilter `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// resume_token is the token of the last event received, the events following it are streamed first
+	ResumeToken          string   `protobuf:"bytes,2,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeEvents) Reset()         { *m = SubscribeEvents{} }
+func (m *SubscribeEvents) String() string { return proto.CompactTextString(m) }
+func (*SubscribeEvents) ProtoMessage()    {}
+func (*SubscribeEvents) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{1}
+}
+
+func (m *SubscribeEvents) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubscribeEvents.Unmarshal(m, b)
+}
+func (m *SubscribeEvents) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubscribeEvents.Marshal(b, m, deterministic)
+}
+func (m *SubscribeEvents) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeEvents.Merge(m, src)
+}
+func (m *SubscribeEvents) XXX_Size() int {
+	return xxx_messageInfo_SubscribeEvents.Size(m)
+}
+func (m *SubscribeEvents) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeEvents.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeEvents proto.InternalMessageInfo
+
+func (m *SubscribeEvents) GetFilter() *EventFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+func (m *SubscribeEvents) GetResumeToken() string {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return ""
+}
+
+// Event is an event committed on a channel
+type Event struct {
+	// kind is finality, chaincode, or config
+	Kind    string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Network string `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	Channel string `protobuf:"bytes,3,opt,name=channel,proto3" json:"channel,omitempty"`
+	Txid    string `protobuf:"bytes,4,opt,name=txid,proto3" json:"txid,omitempty"`
+	Block   uint64 `protobuf:"varint,5,opt,name=block,proto3" json:"block,omitempty"`
+	TxNum   uint64 `protobuf:"varint,6,opt,name=tx_num,json=txNum,proto3" json:"tx_num,omitempty"`
+	// namespace, name and payload are the chaincode, the name and the payload of a chaincode event
+	Namespace string `protobuf:"bytes,7,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name      string `protobuf:"bytes,8,opt,name=name,proto3" json:"name,omitempty"`
+	Payload   []byte `protobuf:"bytes,9,opt,name=payload,proto3" json:"payload,omitempty"`
+	// valid, namespaces and error are the outcome, the namespaces written and the error of a finality event
+	Valid      bool     `protobuf:"varint,10,opt,name=valid,proto3" json:"valid,omitempty"`
+	Namespaces []string `protobuf:"bytes,11,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	Error      string   `protobuf:"bytes,12,opt,name=error,proto3" json:"error,omitempty"`
+	// resume_token resumes the subscription after this event
+	ResumeToken          string   `protobuf:"bytes,13,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+func (*Event) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{2}
+}
+
+func (m *Event) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Event.Unmarshal(m, b)
+}
+func (m *Event) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Event.Marshal(b, m, deterministic)
+}
+func (m *Event) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Event.Merge(m, src)
+}
+func (m *Event) XXX_Size() int {
+	return xxx_messageInfo_Event.Size(m)
+}
+func (m *Event) XXX_DiscardUnknown() {
+	xxx_messageInfo_Event.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Event proto.InternalMessageInfo
+
+func (m *Event) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Event) GetNetwork() string {
+	if m != nil {
+		return m.Network
+	}
+	return ""
+}
+
+func (m *Event) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+func (m *Event) GetTxid() string {
+	if m != nil {
+		return m.Txid
+	}
+	return ""
+}
+
+func (m *Event) GetBlock() uint64 {
+	if m != nil {
+		return m.Block
+	}
+	return 0
+}
+
+func (m *Event) GetTxNum() uint64 {
+	if m != nil {
+		return m.TxNum
+	}
+	return 0
+}
+
+func (m *Event) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *Event) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Event) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Event) GetValid() bool {
+	if m != nil {
+		return m.Valid
+	}
+	return false
+}
+
+func (m *Event) GetNamespaces() []string {
+	if m != nil {
+		return m.Namespaces
+	}
+	return nil
+}
+
+func (m *Event) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Event) GetResumeToken() string {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*EventFilter)(nil), "protos.EventFilter")
+	proto.RegisterType((*SubscribeEvents)(nil), "protos.SubscribeEvents")
+	proto.RegisterType((*Event)(nil), "protos.Event")
+}
+
+func init() { proto.RegisterFile("events.proto", fileDescriptor_8f22242cb04491f9) }
+
+var fileDescriptor_8f22242cb04491f9 = []byte{
+	// 342 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x92, 0x41, 0x4e, 0xeb, 0x30,
+	0x10, 0x86, 0xe5, 0x36, 0x49, 0x9b, 0x49, 0x9e, 0x9e, 0xe4, 0xf7, 0x90, 0x66, 0x81, 0x50, 0xe8,
+	0x2a, 0x12, 0x52, 0x17, 0x70, 0x03, 0x24, 0x58, 0xb2, 0x08, 0xac, 0xd8, 0x54, 0x4e, 0x62, 0x44,
+	0x94, 0xc4, 0xae, 0x6c, 0xa7, 0x94, 0x1d, 0x17, 0xe0, 0x64, 0x5c, 0x0a, 0xd9, 0x4e, 0xa1, 0x50,
+	0x58, 0x75, 0xbe, 0x7f, 0xdc, 0xf1, 0x3f, 0xbf, 0x03, 0x29, 0xdf, 0x70, 0x61, 0xf4, 0x72, 0xad,
+	0xa4, 0x91, 0x34, 0x72, 0x3f, 0x7a, 0xf1, 0x4a, 0x20, 0xb9, 0xb2, 0x8d, 0xeb, 0xa6, 0x33, 0x5c,
+	0x51, 0x84, 0x99, 0xe0, 0xe6, 0x49, 0xaa, 0x16, 0x49, 0x46, 0xf2, 0xb8, 0xd8, 0xa1, 0xed, 0x54,
+	0x8f, 0x4c, 0x08, 0xde, 0xe1, 0xc4, 0x77, 0x46, 0xa4, 0x27, 0x00, 0x82, 0xf5, 0x5c, 0xaf, 0x59,
+	0xc5, 0x35, 0x4e, 0xb3, 0x69, 0x1e, 0x17, 0x7b, 0x0a, 0xfd, 0x0f, 0x61, 0xdb, 0x88, 0x5a, 0x63,
+	0xe0, 0x5a, 0x1e, 0xac, 0xea, 0xce, 0x60, 0xe8, 0x55, 0x07, 0x0b, 0x06, 0x7f, 0x6f, 0x87, 0x52,
+	0x57, 0xaa, 0x29, 0xb9, 0xf3, 0xa5, 0xe9, 0x19, 0x44, 0x0f, 0xce, 0x9c, 0x73, 0x94, 0x9c, 0xff,
+	0xf3, 0x2b, 0xe8, 0xe5, 0x9e, 0xef, 0x62, 0x3c, 0x42, 0x4f, 0x21, 0x55, 0x5c, 0x0f, 0x3d, 0x5f,
+	0x19, 0xd9, 0x72, 0x31, 0x5a, 0x4d, 0xbc, 0x76, 0x67, 0xa5, 0xc5, 0xdb, 0x04, 0x42, 0xf7, 0x57,
+	0x4a, 0x21, 0xb0, 0x5e, 0xc6, 0x4d, 0x5d, 0xbd, 0x1f, 0xc0, 0xe4, 0xd7, 0x00, 0xa6, 0x5f, 0x03,
+	0xa0, 0x10, 0x98, 0x6d, 0x53, 0x63, 0xe0, 0xe7, 0xd8, 0xda, 0xae, 0x57, 0x76, 0xb2, 0x6a, 0x31,
+	0xcc, 0x48, 0x1e, 0x14, 0x1e, 0xe8, 0x11, 0x44, 0x66, 0xbb, 0x12, 0x43, 0x8f, 0x91, 0x97, 0xcd,
+	0xf6, 0x66, 0xe8, 0xe9, 0x31, 0xc4, 0x1f, 0x79, 0xe1, 0xcc, 0x4d, 0xf9, 0x14, 0xec, 0x78, 0x0b,
+	0x38, 0xf7, 0xe3, 0x6d, 0x6d, 0xcd, 0xac, 0xd9, 0x73, 0x27, 0x59, 0x8d, 0x71, 0x46, 0xf2, 0xb4,
+	0xd8, 0xa1, 0xbd, 0x78, 0xc3, 0xba, 0xa6, 0x46, 0xc8, 0x48, 0x3e, 0x2f, 0x3c, 0x7c, 0x7b, 0xa3,
+	0xe4, 0xa7, 0x37, 0xe2, 0x4a, 0x49, 0x85, 0xa9, 0xbb, 0xc4, 0xc3, 0x41, 0x9a, 0x7f, 0x0e, 0xd2,
+	0xbc, 0x4c, 0xee, 0xc7, 0x4f, 0xe9, 0x85, 0x90, 0xd2, 0x97, 0x17, 0xef, 0x03, 0x00, 0x6d, 0x35,
+	0x60, 0xf3, 0x6c, 0x02, 0x00, 0x00,
+}
@@ -6,4 +6,4 @@ SPDX-License-Identifier: Apache-2.0
 
 package protos
 
-//go:generate protoc commands.proto finality.proto service.proto --go_out=plugins=grpc,Mgoogle/protobuf/timestamp.proto=github.com/golang/protobuf/ptypes/timestamp:.
+//go:generate protoc commands.proto events.proto finality.proto service.proto --go_out=plugins=grpc,Mgoogle/protobuf/timestamp.proto=github.com/golang/protobuf/ptypes/timestamp,Mgoogle/protobuf/duration.proto=github.com/golang/protobuf/ptypes/duration:.
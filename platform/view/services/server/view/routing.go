@@ -0,0 +1,41 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"context"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// NetworkMetadata is the key of the gRPC metadata carrying the network a view invocation targets
+	NetworkMetadata = "fsc-network"
+	// ChannelMetadata is the key of the gRPC metadata carrying the channel a view invocation targets
+	ChannelMetadata = "fsc-channel"
+)
+
+// Routing returns the routing of the invocation in the gRPC metadata of the passed context, if any.
+// The view invoked runs in a context carrying it, see view.Routing.
+func Routing(ctx context.Context) (*view.Routing, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	routing := &view.Routing{}
+	if values := md.Get(NetworkMetadata); len(values) != 0 {
+		routing.Network = values[0]
+	}
+	if values := md.Get(ChannelMetadata); len(values) != 0 {
+		routing.Channel = values[0]
+	}
+	if routing.IsEmpty() {
+		return nil, false
+	}
+	return routing, true
+}
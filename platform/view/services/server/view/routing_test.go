@@ -0,0 +1,30 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRouting(t *testing.T) {
+	_, ok := Routing(context.Background())
+	assert.False(t, ok)
+	_, ok = Routing(metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyMetadata, "k1")))
+	assert.False(t, ok)
+
+	routing, ok := Routing(metadata.NewIncomingContext(context.Background(), metadata.Pairs(ChannelMetadata, "ch2")))
+	assert.True(t, ok)
+	assert.Equal(t, &view.Routing{Channel: "ch2"}, routing)
+	routing, ok = Routing(metadata.NewIncomingContext(context.Background(), metadata.Pairs(NetworkMetadata, "other", ChannelMetadata, "ch3")))
+	assert.True(t, ok)
+	assert.Equal(t, &view.Routing{Network: "other", Channel: "ch3"}, routing)
+}
@@ -0,0 +1,371 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultClass is the priority class of the views not assigned to any class, unless configured otherwise
+	DefaultClass = "default"
+	// DefaultSchedulingConcurrency bounds the view invocations running at the same time, unless configured otherwise
+	DefaultSchedulingConcurrency = 64
+	// DefaultSchedulingAging is the time after which a queued invocation is served before any other, unless configured otherwise
+	DefaultSchedulingAging = 5 * time.Second
+)
+
+// Class is a priority class of views
+type Class struct {
+	Name string
+	// Weight is the share of the free slots the class gets when it competes with the other classes, default 1
+	Weight int
+	// Concurrency bounds the invocations of the class running at the same time, 0 for the concurrency of the scheduler
+	Concurrency int
+}
+
+type viewClass struct {
+	View  string
+	Class string
+}
+
+type schedulingConfig struct {
+	Concurrency  int
+	Aging        time.Duration
+	DefaultClass string
+	Classes      []Class
+	Views        []viewClass
+}
+
+var (
+	queueDepthOpts = metrics.GaugeOpts{
+		Namespace:    "view",
+		Subsystem:    "scheduler",
+		Name:         "queue_depth",
+		Help:         "The number of view invocations waiting to run, per priority class.",
+		LabelNames:   []string{"class"},
+		StatsdFormat: "%{#fqname}.%{class}",
+	}
+	runningOpts = metrics.GaugeOpts{
+		Namespace:    "view",
+		Subsystem:    "scheduler",
+		Name:         "running",
+		Help:         "The number of view invocations running, per priority class.",
+		LabelNames:   []string{"class"},
+		StatsdFormat: "%{#fqname}.%{class}",
+	}
+	waitTimeOpts = metrics.HistogramOpts{
+		Namespace:    "view",
+		Subsystem:    "scheduler",
+		Name:         "wait_time",
+		Help:         "The time, in seconds, the view invocations waited to run, per priority class.",
+		LabelNames:   []string{"class"},
+		StatsdFormat: "%{#fqname}.%{class}",
+	}
+)
+
+// SchedulerMetrics records the queues of the priority classes, to tune their weights
+type SchedulerMetrics struct {
+	QueueDepth metrics.Gauge
+	Running    metrics.Gauge
+	WaitTime   metrics.Histogram
+}
+
+func NewSchedulerMetrics(p metrics.Provider) *SchedulerMetrics {
+	return &SchedulerMetrics{
+		QueueDepth: p.NewGauge(queueDepthOpts),
+		Running:    p.NewGauge(runningOpts),
+		WaitTime:   p.NewHistogram(waitTimeOpts),
+	}
+}
+
+// Scheduler schedules the view invocations received from the clients by priority class.
+// Each class runs up to its concurrency at the same time, and all together up to the concurrency of the scheduler.
+// When a slot frees up, the classes waiting for one share it by weighted fair queueing: over time, each class gets
+// a number of slots proportional to its weight. An invocation queued for longer than the aging time is served before
+// any other, which bounds the time the invocations of the lowest classes can starve.
+// A nil Scheduler runs the invocations right away.
+type Scheduler struct {
+	concurrency  int
+	aging        time.Duration
+	defaultClass string
+	metrics      *SchedulerMetrics
+
+	lock    sync.Mutex
+	classes map[string]*class
+	views   map[string]string
+	running int
+	// vtime is the pass of the class served last, the classes becoming active start from it
+	vtime float64
+}
+
+// class is the state of a priority class.
+// Its pass grows with the inverse of its weight at each invocation served, the class with the lowest pass is served next.
+type class struct {
+	Class
+	queue   []*waiter
+	running int
+	pass    float64
+}
+
+func (c *class) eligible() bool {
+	return len(c.queue) != 0 && c.running < c.Concurrency
+}
+
+type waiter struct {
+	class    *class
+	view     string
+	enqueued time.Time
+	granted  chan struct{}
+}
+
+// NewScheduler returns a scheduler of the passed classes. The views not assigned to any class are in the default class,
+// which is added with weight 1 if not passed.
+func NewScheduler(concurrency int, aging time.Duration, defaultClass string, classes []Class, m *SchedulerMetrics) (*Scheduler, error) {
+	if concurrency <= 0 {
+		concurrency = DefaultSchedulingConcurrency
+	}
+	if aging <= 0 {
+		aging = DefaultSchedulingAging
+	}
+	if len(defaultClass) == 0 {
+		defaultClass = DefaultClass
+	}
+	s := &Scheduler{
+		concurrency:  concurrency,
+		aging:        aging,
+		defaultClass: defaultClass,
+		metrics:      m,
+		classes:      map[string]*class{},
+		views:        map[string]string{},
+	}
+	for _, c := range classes {
+		if len(c.Name) == 0 {
+			return nil, errors.New("priority class without name")
+		}
+		if _, ok := s.classes[c.Name]; ok {
+			return nil, errors.Errorf("priority class [%s] defined twice", c.Name)
+		}
+		if c.Weight < 0 || c.Concurrency < 0 {
+			return nil, errors.Errorf("priority class [%s] with negative weight or concurrency", c.Name)
+		}
+		s.classes[c.Name] = newClass(c, concurrency)
+	}
+	if _, ok := s.classes[defaultClass]; !ok {
+		s.classes[defaultClass] = newClass(Class{Name: defaultClass}, concurrency)
+	}
+	return s, nil
+}
+
+func newClass(c Class, concurrency int) *class {
+	if c.Weight == 0 {
+		c.Weight = 1
+	}
+	if c.Concurrency == 0 || c.Concurrency > concurrency {
+		c.Concurrency = concurrency
+	}
+	return &class{Class: c}
+}
+
+// NewSchedulerFromConfig returns the scheduler configured with the following keys, nil if fsc.views.scheduling is not set:
+// fsc.views.scheduling.concurrency bounds the invocations running at the same time, default 64,
+// fsc.views.scheduling.aging is the time after which a queued invocation is served before any other, default 5s,
+// fsc.views.scheduling.defaultClass is the class of the views not assigned to any class, default `default`,
+// fsc.views.scheduling.classes lists the classes, with name, weight, and concurrency,
+// fsc.views.scheduling.views assigns the views, by identifier, to their class.
+func NewSchedulerFromConfig(cs driver.ConfigService, p metrics.Provider) (*Scheduler, error) {
+	if !cs.IsSet("fsc.views.scheduling") {
+		return nil, nil
+	}
+	config := &schedulingConfig{}
+	if err := cs.UnmarshalKey("fsc.views.scheduling", config); err != nil {
+		return nil, errors.Wrapf(err, "failed loading view scheduling")
+	}
+	s, err := NewScheduler(config.Concurrency, config.Aging, config.DefaultClass, config.Classes, NewSchedulerMetrics(p))
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range config.Views {
+		if err := s.SetClass(v.View, v.Class); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SetClass assigns the views with the passed identifier, as registered with Registry#RegisterFactory, to the passed class
+func (s *Scheduler) SetClass(view, class string) error {
+	if s == nil {
+		return errors.New("view scheduling is not enabled")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.classes[class]; !ok {
+		return errors.Errorf("priority class [%s] not defined", class)
+	}
+	s.views[view] = class
+	return nil
+}
+
+// ClassOf returns the class of the views with the passed identifier
+func (s *Scheduler) ClassOf(view string) string {
+	if s == nil {
+		return ""
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.classOf(view).Name
+}
+
+func (s *Scheduler) classOf(view string) *class {
+	if name, ok := s.views[view]; ok {
+		return s.classes[name]
+	}
+	return s.classes[s.defaultClass]
+}
+
+// Acquire waits until an invocation of the view with the passed identifier can run, or the context is done.
+// It returns the function to call once the invocation is over, and the time the invocation has been queued for.
+func (s *Scheduler) Acquire(ctx context.Context, view string) (func(), time.Duration, error) {
+	if s == nil {
+		return func() {}, 0, nil
+	}
+	s.lock.Lock()
+	c := s.classOf(view)
+	w := &waiter{class: c, view: view, enqueued: time.Now(), granted: make(chan struct{})}
+	if len(c.queue) == 0 && c.running == 0 && c.pass < s.vtime {
+		// an idle class does not accumulate credit
+		c.pass = s.vtime
+	}
+	c.queue = append(c.queue, w)
+	s.dispatch()
+	s.lock.Unlock()
+
+	select {
+	case <-w.granted:
+	case <-ctx.Done():
+		s.lock.Lock()
+		select {
+		case <-w.granted:
+			// granted in the meantime
+			s.release(c)
+		default:
+			s.remove(w)
+		}
+		s.lock.Unlock()
+		return nil, time.Since(w.enqueued), errors.Wrapf(ctx.Err(), "view [%s] not scheduled", view)
+	}
+	delay := time.Since(w.enqueued)
+	once := &sync.Once{}
+	return func() {
+		once.Do(func() {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			s.release(c)
+		})
+	}, delay, nil
+}
+
+// dispatch grants the free slots to the waiters, s.lock must be held
+func (s *Scheduler) dispatch() {
+	now := time.Now()
+	for s.running < s.concurrency {
+		c := s.next(now)
+		if c == nil {
+			break
+		}
+		w := c.queue[0]
+		c.queue = c.queue[1:]
+		c.running++
+		s.running++
+		s.vtime = c.pass
+		c.pass += 1 / float64(c.Weight)
+		close(w.granted)
+		if s.metrics != nil {
+			s.metrics.WaitTime.With("class", c.Name).Observe(now.Sub(w.enqueued).Seconds())
+		}
+	}
+	s.updateMetrics()
+}
+
+// next returns the class to serve next: the one of the oldest invocation queued for longer than the aging time,
+// if any, otherwise the one with the lowest pass. s.lock must be held
+func (s *Scheduler) next(now time.Time) *class {
+	var aged, fair *class
+	for _, c := range s.sortedClasses() {
+		if !c.eligible() {
+			continue
+		}
+		if now.Sub(c.queue[0].enqueued) >= s.aging && (aged == nil || c.queue[0].enqueued.Before(aged.queue[0].enqueued)) {
+			aged = c
+		}
+		if fair == nil || c.pass < fair.pass {
+			fair = c
+		}
+	}
+	if aged != nil {
+		return aged
+	}
+	return fair
+}
+
+// sortedClasses returns the classes by name, so that the ties are broken the same way. s.lock must be held
+func (s *Scheduler) sortedClasses() []*class {
+	classes := make([]*class, 0, len(s.classes))
+	for _, c := range s.classes {
+		classes = append(classes, c)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+	return classes
+}
+
+// release frees the slot of an invocation of the passed class. s.lock must be held
+func (s *Scheduler) release(c *class) {
+	c.running--
+	s.running--
+	s.dispatch()
+}
+
+// remove drops the passed waiter from its queue. s.lock must be held
+func (s *Scheduler) remove(w *waiter) {
+	queue := w.class.queue
+	for i, o := range queue {
+		if o == w {
+			w.class.queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	s.updateMetrics()
+}
+
+func (s *Scheduler) updateMetrics() {
+	if s.metrics == nil {
+		return
+	}
+	for name, c := range s.classes {
+		s.metrics.QueueDepth.With("class", name).Set(float64(len(c.queue)))
+		s.metrics.Running.With("class", name).Set(float64(c.running))
+	}
+}
+
+// GetScheduler returns the scheduler of the view invocations, nil if the scheduling is not enabled
+func GetScheduler(sp view2.ServiceProvider) *Scheduler {
+	s, err := sp.GetService(reflect.TypeOf((*Scheduler)(nil)))
+	if err != nil {
+		return nil
+	}
+	return s.(*Scheduler)
+}
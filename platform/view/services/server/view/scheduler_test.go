@@ -0,0 +1,167 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// acquireAsync queues an invocation of the passed view and reports it on the passed channel once it runs
+func acquireAsync(t *testing.T, s *Scheduler, view string, started chan<- string, releases chan<- func()) {
+	go func() {
+		release, _, err := s.Acquire(context.Background(), view)
+		assert.NoError(t, err)
+		releases <- release
+		started <- view
+	}()
+}
+
+// waitQueued waits until the passed number of invocations are queued
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	assert.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		queued := 0
+		for _, c := range s.classes {
+			queued += len(c.queue)
+		}
+		return queued == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestSchedulerWeights(t *testing.T) {
+	s, err := NewScheduler(1, time.Hour, "", []Class{{Name: "high", Weight: 3}, {Name: "low", Weight: 1}}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, s.SetClass("h", "high"))
+	assert.NoError(t, s.SetClass("l", "low"))
+	assert.Error(t, s.SetClass("x", "unknown"))
+	assert.Equal(t, "high", s.ClassOf("h"))
+	assert.Equal(t, DefaultClass, s.ClassOf("x"))
+
+	// hold the only slot, then queue as many invocations of both classes
+	hold, _, err := s.Acquire(context.Background(), "x")
+	assert.NoError(t, err)
+	started, releases := make(chan string, 16), make(chan func(), 16)
+	for i := 0; i < 8; i++ {
+		acquireAsync(t, s, "h", started, releases)
+		acquireAsync(t, s, "l", started, releases)
+	}
+	waitQueued(t, s, 16)
+
+	// the slots are shared in proportion to the weights
+	hold()
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		counts[<-started]++
+		(<-releases)()
+	}
+	assert.Equal(t, map[string]int{"h": 6, "l": 2}, counts)
+	for i := 0; i < 8; i++ {
+		<-started
+		(<-releases)()
+	}
+}
+
+func TestSchedulerClassConcurrency(t *testing.T) {
+	s, err := NewScheduler(4, time.Hour, "", []Class{{Name: "batch", Concurrency: 1}}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, s.SetClass("b", "batch"))
+
+	release, _, err := s.Acquire(context.Background(), "b")
+	assert.NoError(t, err)
+	started, releases := make(chan string, 4), make(chan func(), 4)
+	acquireAsync(t, s, "b", started, releases)
+	waitQueued(t, s, 1)
+
+	// the other classes still run while batch is at its cap
+	other, _, err := s.Acquire(context.Background(), "x")
+	assert.NoError(t, err)
+	other()
+	select {
+	case <-started:
+		t.Fatal("batch exceeded its concurrency")
+	default:
+	}
+
+	release()
+	assert.Equal(t, "b", <-started)
+	(<-releases)()
+}
+
+func TestSchedulerAging(t *testing.T) {
+	s, err := NewScheduler(1, 50*time.Millisecond, "", []Class{{Name: "high", Weight: 100}, {Name: "low", Weight: 1}}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, s.SetClass("h", "high"))
+	assert.NoError(t, s.SetClass("l", "low"))
+
+	hold, _, err := s.Acquire(context.Background(), "h")
+	assert.NoError(t, err)
+	started, releases := make(chan string, 16), make(chan func(), 16)
+	acquireAsync(t, s, "l", started, releases)
+	waitQueued(t, s, 1)
+	for i := 0; i < 4; i++ {
+		acquireAsync(t, s, "h", started, releases)
+	}
+	waitQueued(t, s, 5)
+
+	// once aged, the low class invocation is served first despite the weights
+	time.Sleep(60 * time.Millisecond)
+	hold()
+	assert.Equal(t, "l", <-started)
+	(<-releases)()
+	for i := 0; i < 4; i++ {
+		<-started
+		(<-releases)()
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s, err := NewScheduler(1, time.Hour, "", nil, nil)
+	assert.NoError(t, err)
+	hold, _, err := s.Acquire(context.Background(), "x")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, err := s.Acquire(ctx, "x")
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+	waitQueued(t, s, 1)
+	cancel()
+	wg.Wait()
+	waitQueued(t, s, 0)
+
+	// releasing twice frees the slot once, the delay is reported
+	hold()
+	hold()
+	release, delay, err := s.Acquire(context.Background(), "x")
+	assert.NoError(t, err)
+	assert.True(t, delay >= 0)
+	release()
+	assert.Equal(t, 0, s.running)
+}
+
+func TestSchedulerNil(t *testing.T) {
+	var s *Scheduler
+	release, delay, err := s.Acquire(context.Background(), "x")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), delay)
+	release()
+	assert.Equal(t, "", s.ClassOf("x"))
+	assert.Error(t, s.SetClass("x", "default"))
+
+	_, err = NewScheduler(1, 0, "", []Class{{Name: "a"}, {Name: "a"}}, nil)
+	assert.Error(t, err)
+}
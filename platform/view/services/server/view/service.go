@@ -9,14 +9,28 @@ package view
 import (
 	"context"
 	"reflect"
+	"time"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 // A Marshaller is responsible for marshaling and signing command responses.
 type Marshaller interface {
-	MarshalCommandResponse(command []byte, responsePayload interface{}) (*protos2.SignedCommandResponse, error)
+	MarshalCommandResponse(command []byte, responsePayload interface{}, opts ...ResponseOption) (*protos2.SignedCommandResponse, error)
+}
+
+// ResponseOption completes the header of a command response
+type ResponseOption func(header *protos2.CommandResponseHeader)
+
+// WithQueueDelay reports in the header of the response the time the command has been queued for
+func WithQueueDelay(delay time.Duration) ResponseOption {
+	return func(header *protos2.CommandResponseHeader) {
+		if delay > 0 {
+			header.QueueDelay = durationpb.New(delay)
+		}
+	}
 }
 
 type Processor func(ctx context.Context, command *protos2.Command) (interface{}, error)
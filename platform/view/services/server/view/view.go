@@ -17,6 +17,7 @@ import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
 	protos2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/tracker"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
 
 type viewHandler struct {
@@ -37,12 +38,18 @@ func (s *viewHandler) initiateView(ctx context.Context, command *protos2.Command
 	input := initiateView.Input
 	log.Printf("Initiate view [%s]", fid)
 
+	release, err := s.schedule(ctx, fid)
+	if err != nil {
+		return nil, err
+	}
 	viewManager := view.GetManager(s.sp)
 	f, err := viewManager.NewView(fid, input)
 	if err != nil {
+		release()
 		return nil, errors.Errorf("failed instantiating view [%s], err [%s]", fid, err)
 	}
-	contextID, err := s.RunView(viewManager, f)
+	routing, _ := Routing(ctx)
+	contextID, err := s.runView(viewManager, f, routing, release)
 	if err != nil {
 		return nil, errors.Errorf("failed running view [%s], err %s", fid, err)
 	}
@@ -83,12 +90,37 @@ func (s *viewHandler) callView(ctx context.Context, command *protos2.Command) (i
 	input := callView.Input
 	logger.Debugf("Call view [%s] on input [%v]", fid, string(input))
 
+	// the calls with the same idempotency key, from the same client, run once
+	raw, err := GetDeduplicator(s.sp).Do(ctx, command.Header.Creator, IdempotencyKey(ctx), fid, input, func() ([]byte, error) {
+		return s.runCall(ctx, fid, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("Finished call view [%s] on input [%v]", fid, string(input))
+	return &protos2.CommandResponse_CallViewResponse{CallViewResponse: &protos2.CallViewResponse{
+		Result: raw,
+	}}, nil
+}
+
+// runCall runs the passed view on the passed input and returns its marshalled result
+func (s *viewHandler) runCall(ctx context.Context, fid string, input []byte) ([]byte, error) {
+	release, err := s.schedule(ctx, fid)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	viewManager := view.GetManager(s.sp)
 	f, err := viewManager.NewView(fid, input)
 	if err != nil {
 		return nil, errors.Errorf("failed instantiating view [%s], err [%s]", fid, err)
 	}
-	result, err := viewManager.InitiateView(f)
+	var result interface{}
+	if routing, ok := Routing(ctx); ok {
+		result, err = viewManager.InitiateViewWithRouting(f, *routing)
+	} else {
+		result, err = viewManager.InitiateView(f)
+	}
 	if err != nil {
 		return nil, errors.Errorf("failed running view [%s], err %s", fid, err)
 	}
@@ -99,26 +131,51 @@ func (s *viewHandler) callView(ctx context.Context, command *protos2.Command) (i
 			return nil, errors.Errorf("failed marshalling result produced by view [%s], err [%s]", fid, err)
 		}
 	}
-	logger.Debugf("Finished call view [%s] on input [%v]", fid, string(input))
-	return &protos2.CommandResponse_CallViewResponse{CallViewResponse: &protos2.CallViewResponse{
-		Result: raw,
-	}}, nil
+	return raw, nil
+}
+
+// schedule waits until the scheduler, if any, lets an invocation of the passed view run,
+// and reports the time it has been queued for in the response
+func (s *viewHandler) schedule(ctx context.Context, fid string) (func(), error) {
+	release, delay, err := GetScheduler(s.sp).Acquire(ctx, fid)
+	if err != nil {
+		return nil, err
+	}
+	SetQueueDelay(ctx, delay)
+	return release, nil
 }
 
 func (s *viewHandler) RunView(manager *view.Manager, view view.View) (string, error) {
-	context, err := manager.InitiateContext(view)
+	return s.runView(manager, view, nil, func() {})
+}
+
+// runView runs the passed view in a new context, carrying the passed routing if any,
+// release is called once the view is over
+func (s *viewHandler) runView(manager *view.Manager, v view.View, routing *view2.Routing, release func()) (string, error) {
+	var context *view.Context
+	var err error
+	if routing != nil {
+		context, err = manager.InitiateContextWithRouting(v, *routing)
+	} else {
+		context, err = manager.InitiateContext(v)
+	}
 	if err != nil {
+		release()
 		return "", err
 	}
 
 	// Get the tracker
 	viewTracker, err := tracker.GetViewTracker(s.sp)
 	if err != nil {
+		release()
 		return "", err
 	}
 
 	// Run the view
-	go s.runViewWithTracking(view, context, viewTracker)
+	go func() {
+		defer release()
+		s.runViewWithTracking(v, context, viewTracker)
+	}()
 
 	return context.ID(), nil
 }
@@ -16,6 +16,8 @@ type Dispatcher struct {
 	vc      ViewCaller
 	Logger  logger
 	Handler *HttpHandler
+	// Admit, if set, returns an error when the node refuses new view invocations, they get 503 Service Unavailable
+	Admit func() error
 }
 
 func (rd *Dispatcher) HandleRequest(context *ReqContext) (response interface{}, statusCode int) {
@@ -26,6 +28,12 @@ func (rd *Dispatcher) HandleRequest(context *ReqContext) (response interface{},
 		return &ResponseErr{Reason: "internal error"}, 500
 	}
 
+	if rd.Admit != nil {
+		if err := rd.Admit(); err != nil {
+			return &ResponseErr{Reason: err.Error()}, 503
+		}
+	}
+
 	viewID := context.Vars["View"]
 	escapedViewID := strings.Replace(viewID, "\n", "", -1)
 	escapedViewID = strings.Replace(escapedViewID, "\r", "", -1)
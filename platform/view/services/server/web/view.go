@@ -7,9 +7,11 @@ SPDX-License-Identifier: Apache-2.0
 package web
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view"
+	view2 "github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/server/view/protos"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/tracker"
 	"github.com/pkg/errors"
@@ -38,6 +40,11 @@ func InstallViewHandler(l logger, sp view.ServiceProvider, d dispatcher) {
 func (s *viewHandler) callView(vid string, input []byte) (interface{}, error) {
 	s.logger.Debugf("Call view [%s] on input [%v]", vid, string(input))
 
+	release, _, err := view2.GetScheduler(s.sp).Acquire(context.Background(), vid)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	viewManager := view.GetManager(s.sp)
 	f, err := viewManager.NewView(vid, input)
 	if err != nil {
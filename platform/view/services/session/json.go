@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/flogging"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
@@ -41,46 +42,39 @@ func JSON(context view.Context) *jsonSession {
 }
 
 func (j *jsonSession) Receive(state interface{}) error {
-	timeout := time.NewTimer(time.Second * 10)
-	defer timeout.Stop()
+	return j.ReceiveWithTimeout(state, time.Second*10)
+}
 
-	ch := j.s.Receive()
-	var raw []byte
-	select {
-	case msg := <-ch:
-		if msg.Status == view.ERROR {
-			return errors.Errorf("received error from remote [%s]", string(msg.Payload))
-		}
-		raw = msg.Payload
-	case <-timeout.C:
-		return errors.New("time out reached")
-	case <-j.context.Done():
-		return errors.Errorf("context done [%s]", j.context.Err())
+func (j *jsonSession) ReceiveWithTimeout(state interface{}, d time.Duration) error {
+	raw, err := j.receiveRaw(d)
+	if err != nil {
+		return err
 	}
 	logger.Debugf("json session, received message [%s]", hash.Hashable(raw).String())
 	return json.Unmarshal(raw, state)
 }
 
-func (j *jsonSession) ReceiveWithTimeout(state interface{}, d time.Duration) error {
+// receiveRaw returns the payload of the next message received, within the passed timeout.
+// The wait is accounted to the session phase of the flow, and tracked for its introspection.
+func (j *jsonSession) receiveRaw(d time.Duration) ([]byte, error) {
+	defer view.TrackPhase(j.context, view.PhaseSession)()
+	defer view.TrackReceive(j.context, j.s)()
 	timeout := time.NewTimer(d)
 	defer timeout.Stop()
 
 	// TODO: use opts
 	ch := j.s.Receive()
-	var raw []byte
 	select {
 	case msg := <-ch:
 		if msg.Status == view.ERROR {
-			return errors.Errorf("received error from remote [%s]", string(msg.Payload))
+			return nil, errors.Errorf("received error from remote [%s]", string(msg.Payload))
 		}
-		raw = msg.Payload
+		return msg.Payload, nil
 	case <-timeout.C:
-		return errors.New("time out reached")
+		return nil, errors.New("time out reached")
 	case <-j.context.Done():
-		return errors.Errorf("context done [%s]", j.context.Err())
+		return nil, errors.Errorf("context done [%s]", j.context.Err())
 	}
-	logger.Debugf("json session, received message [%s]", hash.Hashable(raw).String())
-	return json.Unmarshal(raw, state)
 }
 
 func (j *jsonSession) Send(state interface{}) error {
@@ -105,3 +99,8 @@ func (j *jsonSession) SendError(err string) error {
 func (j *jsonSession) Session() Session {
 	return j.s
 }
+
+// PeerVersion returns the version of the node at the remote end of the session, see PeerVersion
+func (j *jsonSession) PeerVersion() version.Version {
+	return PeerVersion(j.s)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
 )
 
@@ -36,7 +37,9 @@ func ReadMessageWithTimeout(session Session, d time.Duration) ([]byte, error) {
 }
 
 func ReadFirstMessage(context view.Context) (Session, []byte, error) {
+	defer view.TrackPhase(context.Context(), view.PhaseSession)()
 	session := context.Session()
+	defer view.TrackReceive(context.Context(), session)()
 	ch := session.Receive()
 	var payload []byte
 
@@ -57,7 +60,9 @@ func ReadFirstMessage(context view.Context) (Session, []byte, error) {
 }
 
 func ReadFirstMessageOrPanic(context view.Context) []byte {
+	defer view.TrackPhase(context.Context(), view.PhaseSession)()
 	session := context.Session()
+	defer view.TrackReceive(context.Context(), session)()
 	ch := session.Receive()
 	var payload []byte
 
@@ -76,3 +81,25 @@ func ReadFirstMessageOrPanic(context view.Context) []byte {
 
 	return payload
 }
+
+// EnableHeartbeats enables the application-level heartbeats on the passed session.
+// The notifications with status view.SessionPeerUnreachable and view.SessionPeerRecovered are then
+// delivered on the session together with the messages of the remote end.
+func EnableHeartbeats(session view.Session, interval time.Duration, misses int) error {
+	hs, ok := session.(view.HeartbeatSession)
+	if !ok {
+		return errors.Errorf("session [%s] does not support heartbeats", session.Info().ID)
+	}
+	return hs.EnableHeartbeats(interval, misses)
+}
+
+// PeerVersion returns the version of the node at the remote end of the passed session, as told by the messages
+// received from it. It returns version.Unknown for the legacy nodes, the sessions not telling the version,
+// and before the first message.
+func PeerVersion(session view.Session) version.Version {
+	vs, ok := session.(view.VersionSession)
+	if !ok {
+		return version.Unknown
+	}
+	return vs.PeerVersion()
+}
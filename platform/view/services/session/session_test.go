@@ -0,0 +1,37 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package session
+
+import (
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// versioned is a session telling the version of the remote node
+type versioned struct {
+	loopback
+	version string
+}
+
+func (v *versioned) PeerVersion() version.Version { return version.Parse(v.version) }
+
+func TestPeerVersion(t *testing.T) {
+	s := &versioned{version: "v0.3.1"}
+	assert.True(t, PeerVersion(s).AtLeast("0.3.0"))
+	assert.False(t, PeerVersion(s).AtLeast("0.4.0"))
+	assert.True(t, (&jsonSession{s: s}).PeerVersion().AtLeast("0.3.0"))
+
+	// a development build
+	s.version = "latest"
+	assert.True(t, PeerVersion(s).IsUnknown())
+
+	// the sessions not telling the version report the sentinel, not an error
+	assert.Equal(t, version.Unknown, PeerVersion(&loopback{}))
+	assert.False(t, (&jsonSession{s: &loopback{}}).PeerVersion().AtLeast("0.0.0"))
+}
@@ -0,0 +1,190 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/services/hash"
+	"github.com/pkg/errors"
+)
+
+// MessageType identifies the schema of a message exchanged with SendTyped and ReceiveTyped
+type MessageType struct {
+	Name    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+func (t MessageType) String() string {
+	return fmt.Sprintf("%s/v%d", t.Name, t.Version)
+}
+
+// ErrIncompatibleMessage is returned when the message received is not of the type, or of the version, expected.
+// Got is zero if the message received is not a typed message.
+type ErrIncompatibleMessage struct {
+	Got  MessageType
+	Want MessageType
+}
+
+func (e *ErrIncompatibleMessage) Error() string {
+	return fmt.Sprintf("incompatible message: got [%s], want [%s]", e.Got, e.Want)
+}
+
+// UpConverter converts the payload of a message from its version to the next one
+type UpConverter func(payload []byte) ([]byte, error)
+
+// envelope is the wire format of the typed messages
+type envelope struct {
+	MessageType
+	Payload json.RawMessage `json:"payload"`
+}
+
+var schemas = struct {
+	lock       sync.RWMutex
+	types      map[reflect.Type]MessageType
+	converters map[string]map[int]UpConverter
+}{
+	types:      map[reflect.Type]MessageType{},
+	converters: map[string]map[int]UpConverter{},
+}
+
+// RegisterMessage registers the Go type of the passed value as the passed version of the message type with the
+// passed name. Each version of a message type is usually a distinct Go type, the last one being the current one.
+func RegisterMessage(v interface{}, name string, version int) error {
+	if len(name) == 0 {
+		return errors.New("message type without name")
+	}
+	t := messageGoType(v)
+	schemas.lock.Lock()
+	defer schemas.lock.Unlock()
+	if mt, ok := schemas.types[t]; ok {
+		return errors.Errorf("go type [%s] already registered as [%s]", t, mt)
+	}
+	schemas.types[t] = MessageType{Name: name, Version: version}
+	return nil
+}
+
+// RegisterUpConverter registers the conversion of the payloads of the passed message type from the passed version
+// to the next one. The receivers expecting a later version convert the messages of the older versions
+// one version at a time.
+func RegisterUpConverter(name string, from int, converter UpConverter) error {
+	schemas.lock.Lock()
+	defer schemas.lock.Unlock()
+	if _, ok := schemas.converters[name][from]; ok {
+		return errors.Errorf("up-converter of [%s] already registered", MessageType{Name: name, Version: from})
+	}
+	if schemas.converters[name] == nil {
+		schemas.converters[name] = map[int]UpConverter{}
+	}
+	schemas.converters[name][from] = converter
+	return nil
+}
+
+// MessageTypeOf returns the message type the Go type of the passed value is registered as
+func MessageTypeOf(v interface{}) (MessageType, error) {
+	t := messageGoType(v)
+	schemas.lock.RLock()
+	defer schemas.lock.RUnlock()
+	mt, ok := schemas.types[t]
+	if !ok {
+		return MessageType{}, errors.Errorf("go type [%s] not registered as a message type", t)
+	}
+	return mt, nil
+}
+
+// SendTyped sends the passed value wrapped in an envelope carrying its message type and version.
+// The Go type of the value must be registered with RegisterMessage.
+func (j *jsonSession) SendTyped(v interface{}) error {
+	raw, err := MarshalTyped(v)
+	if err != nil {
+		return err
+	}
+	logger.Debugf("json session, send typed message [%s]", hash.Hashable(raw).String())
+	return j.s.Send(raw)
+}
+
+// ReceiveTyped receives a message sent with SendTyped into the passed pointer, converting the older versions of its
+// message type with the registered up-converters. An ErrIncompatibleMessage is returned if the message is not of
+// the message type of the passed value, or cannot be converted to its version.
+func (j *jsonSession) ReceiveTyped(v interface{}) error {
+	return j.ReceiveTypedWithTimeout(v, time.Second*10)
+}
+
+// ReceiveTypedWithTimeout is ReceiveTyped with the passed timeout
+func (j *jsonSession) ReceiveTypedWithTimeout(v interface{}, d time.Duration) error {
+	raw, err := j.receiveRaw(d)
+	if err != nil {
+		return err
+	}
+	logger.Debugf("json session, received typed message [%s]", hash.Hashable(raw).String())
+	return UnmarshalTyped(raw, v)
+}
+
+// ReceiveTyped receives a message of type T sent with SendTyped. See jsonSession#ReceiveTyped.
+func ReceiveTyped[T any](j *jsonSession) (T, error) {
+	var v T
+	err := j.ReceiveTyped(&v)
+	return v, err
+}
+
+// MarshalTyped returns the wire format of the passed value, as sent by SendTyped
+func MarshalTyped(v interface{}) ([]byte, error) {
+	mt, err := MessageTypeOf(v)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed marshalling message [%s]", mt)
+	}
+	return json.Marshal(&envelope{MessageType: mt, Payload: payload})
+}
+
+// UnmarshalTyped unmarshals a message in the wire format of SendTyped into the passed pointer.
+// See jsonSession#ReceiveTyped.
+func UnmarshalTyped(raw []byte, v interface{}) error {
+	want, err := MessageTypeOf(v)
+	if err != nil {
+		return err
+	}
+	env := &envelope{}
+	if err := json.Unmarshal(raw, env); err != nil || len(env.Name) == 0 {
+		return &ErrIncompatibleMessage{Want: want}
+	}
+	if env.Name != want.Name || env.Version > want.Version {
+		return &ErrIncompatibleMessage{Got: env.MessageType, Want: want}
+	}
+	payload := []byte(env.Payload)
+	for version := env.Version; version < want.Version; version++ {
+		schemas.lock.RLock()
+		converter, ok := schemas.converters[want.Name][version]
+		schemas.lock.RUnlock()
+		if !ok {
+			return &ErrIncompatibleMessage{Got: env.MessageType, Want: want}
+		}
+		if payload, err = converter(payload); err != nil {
+			return errors.WithMessagef(err, "failed converting [%s] to the next version", MessageType{Name: want.Name, Version: version})
+		}
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return errors.Wrapf(err, "failed unmarshalling message [%s]", env.MessageType)
+	}
+	return nil
+}
+
+// messageGoType returns the Go type of the passed value, dereferenced
+func messageGoType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
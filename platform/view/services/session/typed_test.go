@@ -0,0 +1,136 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// loopback is a session receiving the messages it sends
+type loopback struct {
+	ch chan *view.Message
+}
+
+func (l *loopback) Info() view.SessionInfo { return view.SessionInfo{ID: "loopback"} }
+
+func (l *loopback) Send(payload []byte) error {
+	l.ch <- &view.Message{Status: view.OK, Payload: payload}
+	return nil
+}
+
+func (l *loopback) SendError(payload []byte) error {
+	l.ch <- &view.Message{Status: view.ERROR, Payload: payload}
+	return nil
+}
+
+func (l *loopback) Receive() <-chan *view.Message { return l.ch }
+
+func (l *loopback) Close() {}
+
+// orderV1 and orderV2 are two versions of the same message, as sent by two versions of an application
+type orderV1 struct {
+	Item string
+}
+
+type orderV2 struct {
+	Items    []string
+	Quantity int
+}
+
+type ack struct {
+	OK bool
+}
+
+func init() {
+	for _, err := range []error{
+		RegisterMessage(&orderV1{}, "test.order", 1),
+		RegisterMessage(orderV2{}, "test.order", 2),
+		RegisterMessage(&ack{}, "test.ack", 1),
+		RegisterUpConverter("test.order", 1, func(payload []byte) ([]byte, error) {
+			v1 := &orderV1{}
+			if err := json.Unmarshal(payload, v1); err != nil {
+				return nil, err
+			}
+			return json.Marshal(&orderV2{Items: []string{v1.Item}, Quantity: 1})
+		}),
+	} {
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestTypedMessages(t *testing.T) {
+	s := &jsonSession{s: &loopback{ch: make(chan *view.Message, 1)}, context: context.Background()}
+
+	// same version
+	assert.NoError(t, s.SendTyped(&orderV2{Items: []string{"a", "b"}, Quantity: 2}))
+	v2, err := ReceiveTyped[orderV2](s)
+	assert.NoError(t, err)
+	assert.Equal(t, orderV2{Items: []string{"a", "b"}, Quantity: 2}, v2)
+
+	// an older version is up-converted
+	assert.NoError(t, s.SendTyped(orderV1{Item: "a"}))
+	v2, err = ReceiveTyped[orderV2](s)
+	assert.NoError(t, err)
+	assert.Equal(t, orderV2{Items: []string{"a"}, Quantity: 1}, v2)
+
+	// a newer version cannot be understood
+	assert.NoError(t, s.SendTyped(&orderV2{Items: []string{"a"}}))
+	_, err = ReceiveTyped[orderV1](s)
+	incompatible := &ErrIncompatibleMessage{}
+	assert.True(t, errors.As(err, &incompatible))
+	assert.Equal(t, &ErrIncompatibleMessage{Got: MessageType{Name: "test.order", Version: 2}, Want: MessageType{Name: "test.order", Version: 1}}, incompatible)
+
+	// another message type
+	assert.NoError(t, s.SendTyped(&ack{OK: true}))
+	_, err = ReceiveTyped[orderV2](s)
+	assert.True(t, errors.As(err, &incompatible))
+	assert.Equal(t, MessageType{Name: "test.ack", Version: 1}, incompatible.Got)
+
+	// a message sent with Send is not a typed message, raw Send and Receive keep working
+	assert.NoError(t, s.Send(&ack{OK: true}))
+	_, err = ReceiveTyped[ack](s)
+	assert.True(t, errors.As(err, &incompatible))
+	assert.Equal(t, MessageType{}, incompatible.Got)
+	assert.NoError(t, s.Send(&ack{OK: true}))
+	a := &ack{}
+	assert.NoError(t, s.Receive(a))
+	assert.True(t, a.OK)
+
+	// the errors of the remote are still reported as such
+	assert.NoError(t, s.SendError("boom"))
+	_, err = ReceiveTyped[ack](s)
+	assert.EqualError(t, err, "received error from remote [boom]")
+
+	// the types must be registered
+	assert.Error(t, s.SendTyped(struct{}{}))
+	assert.Error(t, RegisterMessage(&ack{}, "test.ack", 2))
+	assert.Error(t, RegisterUpConverter("test.order", 1, nil))
+}
+
+func TestTypedMessagesWithoutConverter(t *testing.T) {
+	type itemV1 struct{ Name string }
+	type itemV3 struct{ ID string }
+	assert.NoError(t, RegisterMessage(itemV1{}, "test.item", 1))
+	assert.NoError(t, RegisterMessage(itemV3{}, "test.item", 3))
+	assert.NoError(t, RegisterUpConverter("test.item", 1, func(payload []byte) ([]byte, error) { return payload, nil }))
+
+	// the conversion from version 2 to 3 is missing
+	raw, err := MarshalTyped(itemV1{Name: "a"})
+	assert.NoError(t, err)
+	err = UnmarshalTyped(raw, &itemV3{})
+	incompatible := &ErrIncompatibleMessage{}
+	assert.True(t, errors.As(err, &incompatible))
+	assert.Equal(t, "incompatible message: got [test.item/v1], want [test.item/v3]", err.Error())
+}
@@ -9,6 +9,7 @@ package view
 import (
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/driver"
 	"github.com/hyperledger-labs/fabric-smart-client/platform/view/view"
+	"github.com/pkg/errors"
 )
 
 // Signer is an interface which wraps the Sign method.
@@ -84,6 +85,15 @@ func (s *SigService) RegisterSigner(identity view.Identity, signer Signer, verif
 	return s.sigRegistry.RegisterSigner(identity, signer, verifier)
 }
 
+// DeregisterSigner unbinds the passed identity from its signer and verifier
+func (s *SigService) DeregisterSigner(identity view.Identity) error {
+	d, ok := s.sigRegistry.(driver.SigDeregisterer)
+	if !ok {
+		return errors.New("the sig registry does not support deregistering signers")
+	}
+	return d.DeregisterSigner(identity)
+}
+
 // IsMe returns true if a signer was ever registered for the passed identity
 func (s *SigService) IsMe(identity view.Identity) bool {
 	return s.sigService.IsMe(identity)
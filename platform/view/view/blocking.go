@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import "context"
+
+// The operations a flow blocks on, see TrackBlocking
+const (
+	// BlockedOnSession is the wait for a message of a session, the targets are the remote endpoint and the session ID
+	BlockedOnSession = "session"
+	// BlockedOnFinality is the wait for the finality of transactions, the targets are their IDs
+	BlockedOnFinality = "finality"
+)
+
+// The kinds of the exchanges on the sessions of a flow, see FlowTracker#Exchange
+const (
+	SessionSent     = "sent"
+	SessionReceived = "received"
+)
+
+// FlowTracker records what a flow blocks on and exchanges on its sessions, for the introspection of the running flows.
+// It is always on, its methods must be cheap.
+type FlowTracker interface {
+	// Block marks that the flow blocks on the passed operation, the returned function marks that it resumed
+	Block(operation string, targets ...string) func()
+	// Exchange records that the flow sent, or received, as kind tells, a message with the passed status and payload
+	// on the passed session
+	Exchange(session Session, kind string, status int32, payload []byte)
+}
+
+// TrackedSession is implemented by the sessions recording their exchanges in the tracker of the flow they belong to
+type TrackedSession interface {
+	Session
+	// TrackFlow makes the session record, in the passed tracker, the messages it sends and those it delivers
+	TrackFlow(tracker FlowTracker)
+}
+
+type flowTrackerKey struct{}
+
+// WithFlowTracker returns a copy of the passed context carrying the passed tracker
+func WithFlowTracker(ctx context.Context, tracker FlowTracker) context.Context {
+	return context.WithValue(ctx, flowTrackerKey{}, tracker)
+}
+
+// TrackBlocking marks that the flow running in the passed context blocks on the passed operation, and returns the
+// function marking that it resumed. It does nothing if the context carries no tracker. Usage:
+//
+//	defer view.TrackBlocking(ctx, view.BlockedOnFinality, txID)()
+func TrackBlocking(ctx context.Context, operation string, targets ...string) func() {
+	tracker := flowTracker(ctx)
+	if tracker == nil {
+		return func() {}
+	}
+	return tracker.Block(operation, targets...)
+}
+
+// TrackReceive marks that the flow running in the passed context waits for a message of the passed session,
+// see TrackBlocking
+func TrackReceive(ctx context.Context, session Session) func() {
+	tracker := flowTracker(ctx)
+	if tracker == nil {
+		return func() {}
+	}
+	info := session.Info()
+	return tracker.Block(BlockedOnSession, info.Endpoint, info.ID)
+}
+
+func flowTracker(ctx context.Context) FlowTracker {
+	if ctx == nil {
+		return nil
+	}
+	tracker, _ := ctx.Value(flowTrackerKey{}).(FlowTracker)
+	return tracker
+}
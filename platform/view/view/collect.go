@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import (
+	"fmt"
+)
+
+// CollectOutcome is the outcome of a party of a BroadcastAndCollect
+type CollectOutcome int
+
+const (
+	CollectPending   CollectOutcome = iota // The party has not replied yet when the quorum has been met
+	CollectReplied                         // The party replied
+	CollectTimeout                         // The party did not reply before the timeout
+	CollectError                           // The session with the party failed, or the party replied with an error
+	CollectCancelled                       // The context has been cancelled, or the stragglers, before the party replied
+)
+
+var collectOutcomeNames = map[CollectOutcome]string{
+	CollectPending:   "pending",
+	CollectReplied:   "replied",
+	CollectTimeout:   "timeout",
+	CollectError:     "error",
+	CollectCancelled: "cancelled",
+}
+
+func (o CollectOutcome) String() string {
+	if name, ok := collectOutcomeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("outcome(%d)", int(o))
+}
+
+// PartyResult is the outcome of a party of a BroadcastAndCollect
+type PartyResult struct {
+	Party   Identity
+	Outcome CollectOutcome
+	// Reply is the payload replied by the party, if it replied
+	Reply []byte
+	// Err tells why the party did not reply, if the outcome is CollectError, CollectTimeout or CollectCancelled
+	Err error
+}
+
+// CollectResult is the result of a BroadcastAndCollect
+type CollectResult struct {
+	// Parties are the outcomes of the parties, in the order they have been passed.
+	// The outcome of the parties not replied when the quorum has been met is CollectPending.
+	Parties []*PartyResult
+	// Replies is the number of parties that replied
+	Replies int
+	// QuorumMet is true if at least the quorum of parties replied
+	QuorumMet bool
+}
+
+// Replied returns the outcomes of the parties that replied, in the order they have been passed
+func (r *CollectResult) Replied() []*PartyResult {
+	var res []*PartyResult
+	for _, p := range r.Parties {
+		if p.Outcome == CollectReplied {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// ErrQuorumNotMet is returned by BroadcastAndCollect, together with the result, when less than the
+// quorum of parties replied, either because the others failed or they timed out
+type ErrQuorumNotMet struct {
+	Quorum  int
+	Replies int
+	Parties int
+}
+
+func (e *ErrQuorumNotMet) Error() string {
+	return fmt.Sprintf("quorum not met, [%d] out of [%d] parties replied, [%d] required", e.Replies, e.Parties, e.Quorum)
+}
+
+// CollectOptions models the options of a BroadcastAndCollect
+type CollectOptions struct {
+	// Caller is the view the sessions are opened for, the initiator of the context if not set
+	Caller View
+	// Label is the label of the sessions, none if empty. See GetSessionWithLabel.
+	Label string
+	// CancelStragglers makes the parties not replied when the quorum is met cancelled, and their sessions closed.
+	// Otherwise, they complete in background, until they reply or the timeout elapses.
+	CancelStragglers bool
+	// OnStraggler, if set, is called with the outcome of each party completing in background after the quorum is met
+	OnStraggler func(*PartyResult)
+}
+
+// CompileCollectOptions compiles a set of CollectOption to a CollectOptions
+func CompileCollectOptions(opts ...CollectOption) (*CollectOptions, error) {
+	options := &CollectOptions{}
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// CollectOption models a function that set options of a BroadcastAndCollect
+type CollectOption func(*CollectOptions) error
+
+// WithCollectCaller sets the view the sessions are opened for
+func WithCollectCaller(caller View) CollectOption {
+	return func(o *CollectOptions) error {
+		o.Caller = caller
+		return nil
+	}
+}
+
+// WithCollectLabel sets the label of the sessions, so that the replies of the stragglers do not reach the
+// other sessions with the same parties
+func WithCollectLabel(label string) CollectOption {
+	return func(o *CollectOptions) error {
+		o.Label = label
+		return nil
+	}
+}
+
+// WithCancelStragglers cancels the parties not replied when the quorum is met, and closes their sessions
+func WithCancelStragglers() CollectOption {
+	return func(o *CollectOptions) error {
+		o.CancelStragglers = true
+		return nil
+	}
+}
+
+// WithOnStraggler sets the function called with the outcome of each party completing in background
+// after the quorum is met
+func WithOnStraggler(f func(*PartyResult)) CollectOption {
+	return func(o *CollectOptions) error {
+		o.OnStraggler = f
+		return nil
+	}
+}
@@ -6,7 +6,12 @@ SPDX-License-Identifier: Apache-2.0
 
 package view
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 // RunViewOptions models the options to run a view
 type RunViewOptions struct {
@@ -14,6 +19,8 @@ type RunViewOptions struct {
 	AsInitiator bool
 	Call        func(Context) (interface{}, error)
 	SameContext bool
+	// Routing, if set, is carried by the context of the view, see RoutedTo
+	Routing *Routing
 }
 
 // CompileRunViewOptions compiles a set of RunViewOption to a RunViewOptions
@@ -68,6 +75,52 @@ type MutableContext interface {
 	ResetSessions() error
 }
 
+// CheckpointContext is implemented by the contexts supporting the checkpoints of the flows running in them
+type CheckpointContext interface {
+	// Checkpoint stores the passed state as the last safe point of the flow running in this context.
+	// If the node restarts before the flow terminates, the flow is resumed from this state.
+	// Only flows whose initiator is Recoverable can be checkpointed.
+	Checkpoint(state interface{}) error
+}
+
+// LabeledSessionContext is implemented by the contexts opening several sessions to the same party, see GetSessionWithLabel
+type LabeledSessionContext interface {
+	// GetSessionWithLabel returns a session to the passed remote party, for the view initiating this context,
+	// distinguished by the passed label from the other sessions to the same party.
+	// The sessions with different labels run concurrently, the remote party dispatches each of them
+	// to the responder bound to its label, if any. Caching may be used.
+	GetSessionWithLabel(party Identity, label string) (Session, error)
+}
+
+// GetSessionWithLabel returns the session with the passed label to the passed party, for the view initiating the
+// passed context. It fails if the context does not support labeled sessions, see LabeledSessionContext.
+func GetSessionWithLabel(ctx Context, party Identity, label string) (Session, error) {
+	lc, ok := ctx.(LabeledSessionContext)
+	if !ok {
+		return nil, errors.Errorf("context [%s] does not support labeled sessions", ctx.ID())
+	}
+	return lc.GetSessionWithLabel(party, label)
+}
+
+// CollectContext is implemented by the contexts collecting the replies of several parties, see BroadcastAndCollect
+type CollectContext interface {
+	// BroadcastAndCollect sends the passed message to the passed parties, on sessions opened in parallel, and
+	// collects their replies. It returns when the quorum of parties replied, or when it cannot be met anymore,
+	// failing then with an *ErrQuorumNotMet, together with the outcome of each party.
+	// The parties not replied within the timeout time out. The cancellation of the context cancels them all.
+	BroadcastAndCollect(parties []Identity, msg []byte, quorum int, timeout time.Duration, opts ...CollectOption) (*CollectResult, error)
+}
+
+// BroadcastAndCollect sends the passed message to the passed parties and collects their replies, on the passed
+// context. It fails if the context does not support it, see CollectContext.
+func BroadcastAndCollect(ctx Context, parties []Identity, msg []byte, quorum int, timeout time.Duration, opts ...CollectOption) (*CollectResult, error) {
+	cc, ok := ctx.(CollectContext)
+	if !ok {
+		return nil, errors.Errorf("context [%s] does not support collecting replies", ctx.ID())
+	}
+	return cc.BroadcastAndCollect(parties, msg, quorum, timeout, opts...)
+}
+
 // Context gives a view information about the environment in which it is in execution
 type Context interface {
 	// GetService returns an instance of the given type
@@ -0,0 +1,49 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import "context"
+
+// The phases the latency of a flow is broken down by
+const (
+	// PhaseCompute is the time the flow spends in none of the other phases
+	PhaseCompute = "compute"
+	// PhaseSession is the time the flow waits for the messages of its sessions
+	PhaseSession = "session"
+	// PhaseEndorsement is the time the flow collects the endorsements of its transactions
+	PhaseEndorsement = "endorsement"
+	// PhaseOrdering is the time the flow waits for its transactions to be ordered and final
+	PhaseOrdering = "ordering"
+)
+
+// PhaseTracker breaks down the latency of a flow by phase
+type PhaseTracker interface {
+	// Enter marks that the flow entered the passed phase, the returned function marks that it left it
+	Enter(phase string) func()
+}
+
+type phaseTrackerKey struct{}
+
+// WithPhaseTracker returns a copy of the passed context carrying the passed tracker
+func WithPhaseTracker(ctx context.Context, tracker PhaseTracker) context.Context {
+	return context.WithValue(ctx, phaseTrackerKey{}, tracker)
+}
+
+// TrackPhase marks that the flow running in the passed context entered the passed phase, and returns the function
+// marking that it left it. It does nothing if the context carries no tracker. Usage:
+//
+//	defer view.TrackPhase(ctx, view.PhaseOrdering)()
+func TrackPhase(ctx context.Context, phase string) func() {
+	if ctx == nil {
+		return func() {}
+	}
+	tracker, ok := ctx.Value(phaseTrackerKey{}).(PhaseTracker)
+	if !ok {
+		return func() {}
+	}
+	return tracker.Enter(phase)
+}
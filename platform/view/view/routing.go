@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package view
+
+import "context"
+
+// Routing tells the network and the channel a flow targets, when they are not fixed by the views themselves.
+// Empty fields are left to the defaults of the node.
+type Routing struct {
+	Network string
+	Channel string
+}
+
+// IsEmpty returns true if the routing targets neither a network nor a channel
+func (r Routing) IsEmpty() bool {
+	return len(r.Network) == 0 && len(r.Channel) == 0
+}
+
+type routingKey struct{}
+
+// WithRouting returns a copy of the passed context carrying the passed routing
+func WithRouting(ctx context.Context, routing Routing) context.Context {
+	return context.WithValue(ctx, routingKey{}, routing)
+}
+
+// RoutingFromContext returns the routing carried by the passed context, if any
+func RoutingFromContext(ctx context.Context) (Routing, bool) {
+	if ctx == nil {
+		return Routing{}, false
+	}
+	routing, ok := ctx.Value(routingKey{}).(Routing)
+	return routing, ok
+}
+
+// RoutingFromMetadata returns the routing declared by the passed metadata, with the keys SessionNetworkMetadata
+// and SessionChannelMetadata, if any
+func RoutingFromMetadata(metadata map[string]string) (Routing, bool) {
+	routing := Routing{Network: metadata[SessionNetworkMetadata], Channel: metadata[SessionChannelMetadata]}
+	return routing, !routing.IsEmpty()
+}
+
+// RoutedTo runs the view in a new context carrying the passed routing, so that the network and the channel
+// the view does not set are the passed ones. Empty values are left to the defaults of the node.
+func RoutedTo(network, channel string) RunViewOption {
+	return func(o *RunViewOptions) error {
+		o.Routing = &Routing{Network: network, Channel: channel}
+		return nil
+	}
+}
@@ -8,6 +8,9 @@ package view
 
 import (
 	"fmt"
+	"time"
+
+	"github.com/hyperledger-labs/fabric-smart-client/pkg/version"
 )
 
 const (
@@ -15,6 +18,29 @@ const (
 	ERROR = 500
 )
 
+const (
+	// SessionPeerUnreachable is the status of the notification delivered on a session with heartbeats
+	// when the remote end missed them. The session is not closed, messages might still arrive.
+	SessionPeerUnreachable = 503
+	// SessionPeerRecovered is the status of the notification delivered when the heartbeats of the remote end resume
+	SessionPeerRecovered = 202
+	// SessionConnectionLost is the status of the notification delivered on a session when the connection it shares
+	// with the other sessions with the same node fails. The session is not closed: its next send reconnects,
+	// the messages in flight might have been lost.
+	SessionConnectionLost = 504
+)
+
+const (
+	// SessionNetworkMetadata is the metadata key of the network a session is about
+	SessionNetworkMetadata = "fsc.network"
+	// SessionChannelMetadata is the metadata key of the channel a session is about
+	SessionChannelMetadata = "fsc.channel"
+	// SessionVersionMetadata is the metadata key of the version of the node sending a message, see VersionSession
+	SessionVersionMetadata = "fsc.version"
+	// SessionLabelMetadata is the metadata key of the label of a session, see GetSessionWithLabel
+	SessionLabelMetadata = "fsc.label"
+)
+
 type Message struct {
 	SessionID    string // Session Identifier
 	ContextID    string // Context Identifier
@@ -23,6 +49,10 @@ type Message struct {
 	FromPKID     []byte // PK identifier of the caller
 	Status       int32  // Message Status (OK, ERROR)
 	Payload      []byte // Payload
+	// Metadata is declared by the initiator of the session, see SessionMetadata.
+	// It carries also the version of the node of the sender, see SessionVersionMetadata,
+	// and the label of the session, see SessionLabelMetadata.
+	Metadata map[string]string
 }
 
 func (m *Message) String() string {
@@ -40,6 +70,8 @@ type SessionInfo struct {
 	Endpoint     string
 	EndpointPKID []byte
 	Closed       bool
+	// Label distinguishes the sessions opened by the same flow with the same party, empty for the unlabeled ones
+	Label string
 }
 
 func (i *SessionInfo) String() string {
@@ -62,3 +94,44 @@ type Session interface {
 	// Close releases all the resources allocated by this session
 	Close()
 }
+
+// HeartbeatSession is implemented by the sessions supporting application-level heartbeats.
+// When heartbeats are enabled, the notifications with status SessionPeerUnreachable and SessionPeerRecovered
+// are delivered on the channel returned by Receive, together with the messages of the remote end.
+type HeartbeatSession interface {
+	Session
+
+	// EnableHeartbeats makes both ends of the session emit a heartbeat every interval.
+	// The remote end is unreachable after it missed the passed number of consecutive heartbeats.
+	// Non-positive values select the defaults of the node.
+	EnableHeartbeats(interval time.Duration, misses int) error
+}
+
+// SessionMetadata is implemented by the views declaring metadata on the sessions they open.
+// The responders can require them, see the dispatch policies of the view manager.
+type SessionMetadata interface {
+	SessionMetadata() map[string]string
+}
+
+// SessionTrustDomain is implemented by the views opening sessions only with the parties certified by the anchors
+// of a trust domain, as configured under fsc.identity.trustDomains
+type SessionTrustDomain interface {
+	SessionTrustDomain() string
+}
+
+// MetadataSession is implemented by the sessions able to carry metadata to the remote end
+type MetadataSession interface {
+	Session
+
+	// SetMetadata sets the metadata carried by the messages sent from now on
+	SetMetadata(metadata map[string]string)
+}
+
+// VersionSession is implemented by the sessions telling the version of the node at the remote end
+type VersionSession interface {
+	Session
+
+	// PeerVersion returns the version of the node at the remote end, as told by the messages received from it.
+	// It is version.Unknown before the first message, and for the nodes not telling their version.
+	PeerVersion() version.Version
+}
@@ -12,3 +12,12 @@ type View interface {
 	// It returns a result and error in case of failure.
 	Call(context Context) (interface{}, error)
 }
+
+// Recoverable is implemented by the views whose execution can be resumed after a node restart.
+// A recoverable view stores its progress by calling CheckpointContext#Checkpoint, on its context, at safe points.
+type Recoverable interface {
+	View
+	// Resume returns a new instance of the view that continues the flow from the passed state,
+	// the JSON representation of the one stored by the last call to CheckpointContext#Checkpoint.
+	Resume(state []byte) (View, error)
+}